@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package backoff allows retrying an operation with backoff.
+// Package backoff allows retrying an operation with backoff, and stopping
+// calls to failing endpoints with a circuit breaker.
 package backoff
 
 import (
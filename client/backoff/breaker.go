@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backoff
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker.Call without calling the
+// function when the circuit of the endpoint is open. It has code Unavailable,
+// so is retried by Retry, whose backoff then paces the probes of the endpoint.
+var ErrCircuitOpen = status.Error(codes.Unavailable, "circuit breaker open")
+
+// Default parameters of a CircuitBreaker.
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenFor          = 30 * time.Second
+)
+
+// State is the state of the circuit of an endpoint.
+type State int
+
+const (
+	// Closed circuits let all calls through.
+	Closed State = iota
+	// Open circuits fail all calls with ErrCircuitOpen.
+	Open
+	// HalfOpen circuits let a single probe call through, whose result closes
+	// or reopens the circuit.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops calls to endpoints which keep failing, so that clients
+// don't keep waiting on a server which is down. The circuit of an endpoint
+// opens after FailureThreshold consecutive failed calls, and calls to it then
+// fail immediately with ErrCircuitOpen. After OpenFor, a single call probes
+// the endpoint, and closes the circuit if it succeeds, or reopens it.
+//
+// A call fails if it returns an error which IsRetryable, other than because
+// its context was canceled. Other errors show that the endpoint is up, so
+// count as successes.
+//
+// The zero value is ready to use with the default parameters, and is safe for
+// concurrent use.
+type CircuitBreaker struct {
+	FailureThreshold int              // DefaultFailureThreshold if 0.
+	OpenFor          time.Duration    // DefaultOpenFor if 0.
+	TimeSource       clock.TimeSource // clock.System if nil.
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of an endpoint.
+type circuit struct {
+	failures  int       // Consecutive failed calls.
+	openUntil time.Time // Zero if the circuit is closed.
+	probing   bool      // Whether a half-open probe is in flight.
+}
+
+// Call calls f unless the circuit of endpoint is open, and records its result.
+func (cb *CircuitBreaker) Call(ctx context.Context, endpoint string, f func() error) error {
+	if err := cb.allow(endpoint); err != nil {
+		return err
+	}
+	err := f()
+	cb.record(endpoint, failed(ctx, err))
+	return err
+}
+
+// State returns the current state of the circuit of endpoint.
+func (cb *CircuitBreaker) State(endpoint string) State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c, ok := cb.circuits[endpoint]
+	switch {
+	case !ok || c.openUntil.IsZero():
+		return Closed
+	case c.probing || !cb.now().Before(c.openUntil):
+		return HalfOpen
+	}
+	return Open
+}
+
+func (cb *CircuitBreaker) allow(endpoint string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c := cb.circuits[endpoint]
+	if c == nil || c.openUntil.IsZero() {
+		return nil
+	}
+	if c.probing || cb.now().Before(c.openUntil) {
+		return ErrCircuitOpen
+	}
+	c.probing = true
+	return nil
+}
+
+func (cb *CircuitBreaker) record(endpoint string, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.circuits == nil {
+		cb.circuits = make(map[string]*circuit)
+	}
+	c := cb.circuits[endpoint]
+	if c == nil {
+		c = &circuit{}
+		cb.circuits[endpoint] = c
+	}
+	if !failed {
+		*c = circuit{}
+		return
+	}
+	c.failures++
+	threshold := cb.FailureThreshold
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+	if c.probing || c.failures >= threshold {
+		openFor := cb.OpenFor
+		if openFor <= 0 {
+			openFor = DefaultOpenFor
+		}
+		c.openUntil = cb.now().Add(openFor)
+		c.probing = false
+	}
+}
+
+func (cb *CircuitBreaker) now() time.Time {
+	if cb.TimeSource == nil {
+		return clock.System.Now()
+	}
+	return cb.TimeSource.Now()
+}
+
+// failed returns whether a call with context ctx which returned err shows that
+// its endpoint is failing.
+func failed(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	return IsRetryable(err)
+}
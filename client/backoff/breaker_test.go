@@ -0,0 +1,150 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backoff
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	ts := clock.NewFake(time.Unix(1000, 0))
+	cb := &CircuitBreaker{FailureThreshold: 3, OpenFor: time.Minute, TimeSource: ts}
+	unavailable := status.Error(codes.Unavailable, "down")
+	calls := 0
+	call := func(err error) error {
+		return cb.Call(ctx, "a", func() error {
+			calls++
+			return err
+		})
+	}
+	checkState := func(want State) {
+		t.Helper()
+		if got := cb.State("a"); got != want {
+			t.Errorf("State() = %v, want %v", got, want)
+		}
+	}
+
+	// A success resets the count of consecutive failures.
+	for _, err := range []error{unavailable, unavailable, nil, unavailable, unavailable} {
+		if got := call(err); got != err {
+			t.Errorf("Call() = %v, want %v", got, err)
+		}
+	}
+	checkState(Closed)
+	// Errors which aren't retriable aren't failures.
+	if err := call(status.Error(codes.NotFound, "no tree")); status.Code(err) != codes.NotFound {
+		t.Errorf("Call() = %v, want NotFound", err)
+	}
+	checkState(Closed)
+	for i := 0; i < 3; i++ {
+		call(unavailable)
+	}
+	checkState(Open)
+
+	calls = 0
+	if err := call(nil); err != ErrCircuitOpen {
+		t.Errorf("Call() on open circuit = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 0 {
+		t.Errorf("Call() on open circuit called f %d times", calls)
+	}
+	if got := cb.State("b"); got != Closed {
+		t.Errorf("State() of other endpoint = %v, want %v", got, Closed)
+	}
+
+	// A failed probe reopens the circuit.
+	ts.Set(ts.Now().Add(time.Minute))
+	checkState(HalfOpen)
+	if err := call(unavailable); err != unavailable {
+		t.Errorf("Probe Call() = %v, want %v", err, unavailable)
+	}
+	checkState(Open)
+	ts.Set(ts.Now().Add(time.Minute - 1))
+	checkState(Open)
+
+	// A successful one closes it.
+	ts.Set(ts.Now().Add(1))
+	if err := call(nil); err != nil {
+		t.Errorf("Probe Call() = %v", err)
+	}
+	checkState(Closed)
+	if calls != 2 {
+		t.Errorf("f called %d times, want 2 probes", calls)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	ctx := context.Background()
+	ts := clock.NewFake(time.Unix(1000, 0))
+	cb := &CircuitBreaker{FailureThreshold: 1, TimeSource: ts}
+	cb.Call(ctx, "a", func() error { return status.Error(codes.Unavailable, "down") })
+	ts.Set(ts.Now().Add(DefaultOpenFor))
+
+	err := cb.Call(ctx, "a", func() error {
+		// Other calls fail while the probe is in flight.
+		if err := cb.Call(ctx, "a", func() error { return nil }); err != ErrCircuitOpen {
+			t.Errorf("Call() during probe = %v, want ErrCircuitOpen", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Probe Call() = %v", err)
+	}
+	if got := cb.State("a"); got != Closed {
+		t.Errorf("State() = %v, want %v", got, Closed)
+	}
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cb := &CircuitBreaker{FailureThreshold: 1}
+	err := cb.Call(ctx, "a", func() error { return status.FromContextError(ctx.Err()).Err() })
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("Call() = %v, want Canceled", err)
+	}
+	if got := cb.State("a"); got != Closed {
+		t.Errorf("State() after canceled call = %v, want %v", got, Closed)
+	}
+}
+
+func TestCircuitBreakerRetry(t *testing.T) {
+	ts := clock.NewFake(time.Unix(1000, 0))
+	cb := &CircuitBreaker{FailureThreshold: 2, TimeSource: ts}
+	b := &Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := b.Retry(ctx, func() error {
+		return cb.Call(ctx, "a", func() error {
+			calls++
+			return status.Error(codes.Unavailable, "down")
+		})
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Retry() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The endpoint isn't called again once the circuit is open.
+	if calls != 2 {
+		t.Errorf("Endpoint called %d times, want 2", calls)
+	}
+}
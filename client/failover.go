@@ -0,0 +1,598 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultUnhealthyFor is the default time a backend which failed is tried
+// after the others.
+const DefaultUnhealthyFor = 30 * time.Second
+
+// CallType is a kind of log RPC, whose calls are hedged and failed over
+// according to the same CallPolicy.
+type CallType int
+
+const (
+	// ReadCall is any RPC which doesn't change the log.
+	ReadCall CallType = iota
+	// WriteCall is any RPC which queues or adds leaves, or initializes the log.
+	WriteCall
+)
+
+// CallPolicy configures how calls of a CallType are sent to the backends of a
+// FailoverLogClient.
+type CallPolicy struct {
+	// HedgeDelay is the time after which a call which hasn't completed is also
+	// sent to the next backend, and so on, the first response being used.
+	// Calls aren't hedged if it's zero.
+	HedgeDelay time.Duration
+	// MaxAttempts is the maximum number of backends a call is sent to, either
+	// hedged or after a backend failed. It's the number of backends if zero,
+	// and a value of 1 disables both hedging and failover.
+	MaxAttempts int
+}
+
+// FailoverOptions configures a FailoverLogClient.
+type FailoverOptions struct {
+	// Policies are the policies of each CallType. Calls of types without a
+	// policy fail over to all the backends, and aren't hedged.
+	Policies map[CallType]CallPolicy
+	// UnhealthyFor is the time a backend which failed a call is tried after
+	// the others. DefaultUnhealthyFor is used if it's zero.
+	UnhealthyFor time.Duration
+	// TimeSource is used for hedging and health tracking. clock.System is used
+	// if nil.
+	TimeSource clock.TimeSource
+}
+
+// FailoverLogClient is a trillian.TrillianLogClient which sends calls to
+// several backends serving the same logs, e.g. the replicas of a
+// deployment, so that a LogClient created with it keeps working when some of
+// them are slow or down. Calls go to the backends in the order they were
+// given, skipping those which recently failed, and move on to the next one
+// when a backend fails with an error which another backend may not return.
+// Calls can also be hedged, i.e. sent to the next backend too if the current
+// one is slow to respond. Streaming calls are failed over when they can't be
+// started or their first receive fails, but never hedged.
+type FailoverLogClient struct {
+	backends []trillian.TrillianLogClient
+	opts     FailoverOptions
+
+	mu sync.Mutex
+	// unhealthyUntil holds the time until which each backend is tried after
+	// the others.
+	unhealthyUntil []time.Time
+}
+
+// NewFailoverLogClient returns a FailoverLogClient which sends calls to the
+// given backends, preferring them in that order.
+func NewFailoverLogClient(backends []trillian.TrillianLogClient, opts FailoverOptions) (*FailoverLogClient, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("no backends")
+	}
+	if opts.UnhealthyFor == 0 {
+		opts.UnhealthyFor = DefaultUnhealthyFor
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	return &FailoverLogClient{
+		backends:       backends,
+		opts:           opts,
+		unhealthyUntil: make([]time.Time, len(backends)),
+	}, nil
+}
+
+// Healthy returns whether each backend is currently considered healthy.
+func (f *FailoverLogClient) Healthy() []bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.opts.TimeSource.Now()
+	healthy := make([]bool, len(f.backends))
+	for i, until := range f.unhealthyUntil {
+		healthy[i] = !now.Before(until)
+	}
+	return healthy
+}
+
+// order returns the indices of the backends in the order they should be
+// tried: the healthy ones as configured, and then the others, from the one
+// which failed the longest ago.
+func (f *FailoverLogClient) order() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.opts.TimeSource.Now()
+	order := make([]int, len(f.backends))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ua, ub := f.unhealthyUntil[order[a]], f.unhealthyUntil[order[b]]
+		if !now.Before(ua) || !now.Before(ub) {
+			return !now.Before(ua) && now.Before(ub)
+		}
+		return ua.Before(ub)
+	})
+	return order
+}
+
+func (f *FailoverLogClient) setHealthy(i int, healthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if healthy {
+		f.unhealthyUntil[i] = time.Time{}
+		return
+	}
+	f.unhealthyUntil[i] = f.opts.TimeSource.Now().Add(f.opts.UnhealthyFor)
+}
+
+// failover returns whether a call which failed with err should be sent to
+// another backend.
+func failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Unknown, codes.Internal, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+type attemptResult struct {
+	backend int
+	resp    interface{}
+	err     error
+}
+
+// call sends a call of type ct to the backends using fn, as configured by the
+// policy of ct, and returns the first successful response, or the last error.
+func (f *FailoverLogClient) call(ctx context.Context, ct CallType, fn func(context.Context, trillian.TrillianLogClient) (interface{}, error)) (interface{}, error) {
+	policy := f.opts.Policies[ct]
+	order := f.order()
+	maxAttempts := len(order)
+	if policy.MaxAttempts > 0 && policy.MaxAttempts < maxAttempts {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	// Attempts still in flight once the call returns are canceled.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan attemptResult, maxAttempts)
+	started, pending := 0, 0
+	var hedge clock.Timer
+	var hedgeC <-chan time.Time
+	start := func() {
+		i := order[started]
+		started++
+		pending++
+		go func() {
+			resp, err := fn(ctx, f.backends[i])
+			results <- attemptResult{backend: i, resp: resp, err: err}
+		}()
+		if hedge != nil {
+			hedge.Stop()
+			hedge, hedgeC = nil, nil
+		}
+		if policy.HedgeDelay > 0 && started < maxAttempts {
+			hedge = f.opts.TimeSource.NewTimer(policy.HedgeDelay)
+			hedgeC = hedge.Chan()
+		}
+	}
+	defer func() {
+		if hedge != nil {
+			hedge.Stop()
+		}
+	}()
+
+	start()
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-hedgeC:
+			glog.V(1).Infof("Hedging call after %v", policy.HedgeDelay)
+			hedge, hedgeC = nil, nil
+			start()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				f.setHealthy(r.backend, true)
+				return r.resp, nil
+			}
+			if !failover(ctx, r.err) {
+				return nil, r.err
+			}
+			glog.V(1).Infof("Backend %d failed, failing over: %v", r.backend, r.err)
+			f.setHealthy(r.backend, false)
+			lastErr = r.err
+			if started < maxAttempts {
+				start()
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// QueueLeaf implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	resp, err := f.call(ctx, WriteCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.QueueLeaf(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.QueueLeafResponse), nil
+}
+
+// GetInclusionProof implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetInclusionProof(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetInclusionProofResponse), nil
+}
+
+// GetInclusionProofByHash implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetInclusionProofByHash(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetInclusionProofByHashResponse), nil
+}
+
+// GetConsistencyProof implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetConsistencyProof(ctx context.Context, in *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetConsistencyProof(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetConsistencyProofResponse), nil
+}
+
+// GetLatestSignedLogRoot implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetLatestSignedLogRoot(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLatestSignedLogRootResponse), nil
+}
+
+// GetEntryAndProof implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetEntryAndProof(ctx context.Context, in *trillian.GetEntryAndProofRequest, opts ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetEntryAndProof(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetEntryAndProofResponse), nil
+}
+
+// InitLog implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) InitLog(ctx context.Context, in *trillian.InitLogRequest, opts ...grpc.CallOption) (*trillian.InitLogResponse, error) {
+	resp, err := f.call(ctx, WriteCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.InitLog(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.InitLogResponse), nil
+}
+
+// AddSequencedLeaves implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) AddSequencedLeaves(ctx context.Context, in *trillian.AddSequencedLeavesRequest, opts ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	resp, err := f.call(ctx, WriteCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.AddSequencedLeaves(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.AddSequencedLeavesResponse), nil
+}
+
+// GetLeavesByRange implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetLeavesByRange(ctx context.Context, in *trillian.GetLeavesByRangeRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetLeavesByRange(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByRangeResponse), nil
+}
+
+// QueueLeaves implements trillian.TrillianLogClient. The stream runs on the
+// first backend which accepts it and answers its first request.
+func (f *FailoverLogClient) QueueLeaves(ctx context.Context, opts ...grpc.CallOption) (trillian.TrillianLog_QueueLeavesClient, error) {
+	s, err := f.stream(ctx, WriteCall, func(c trillian.TrillianLogClient) (grpc.ClientStream, error) {
+		return c.QueueLeaves(ctx, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return queueLeavesStream{s}, nil
+}
+
+// GetImportProgress implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetImportProgress(ctx context.Context, in *trillian.GetImportProgressRequest, opts ...grpc.CallOption) (*trillian.GetImportProgressResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetImportProgress(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetImportProgressResponse), nil
+}
+
+// GetCompactRange implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetCompactRange(ctx context.Context, in *trillian.GetCompactRangeRequest, opts ...grpc.CallOption) (*trillian.GetCompactRangeResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetCompactRange(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetCompactRangeResponse), nil
+}
+
+// GetLeavesByHash implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetLeavesByHash(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByHashResponse), nil
+}
+
+// GetLeavesByIndexList implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetLeavesByIndexList(ctx context.Context, in *trillian.GetLeavesByIndexListRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexListResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetLeavesByIndexList(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByIndexListResponse), nil
+}
+
+// GetSignedLogRootAtTime implements trillian.TrillianLogClient.
+func (f *FailoverLogClient) GetSignedLogRootAtTime(ctx context.Context, in *trillian.GetSignedLogRootAtTimeRequest, opts ...grpc.CallOption) (*trillian.GetSignedLogRootAtTimeResponse, error) {
+	resp, err := f.call(ctx, ReadCall, func(ctx context.Context, c trillian.TrillianLogClient) (interface{}, error) {
+		return c.GetSignedLogRootAtTime(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetSignedLogRootAtTimeResponse), nil
+}
+
+// StreamLeaves implements trillian.TrillianLogClient. The stream runs on the
+// first backend which accepts it and sends its first response.
+func (f *FailoverLogClient) StreamLeaves(ctx context.Context, in *trillian.StreamLeavesRequest, opts ...grpc.CallOption) (trillian.TrillianLog_StreamLeavesClient, error) {
+	s, err := f.stream(ctx, ReadCall, func(c trillian.TrillianLogClient) (grpc.ClientStream, error) {
+		return c.StreamLeaves(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return streamLeavesStream{s}, nil
+}
+
+// stream starts a streaming call of type ct using open, on the backends in
+// turn until one accepts it, up to the MaxAttempts of the policy of ct. gRPC
+// reports most errors of a stream on its first receive rather than when it's
+// started, so the call also moves on to the next backend if the first receive
+// fails, and the messages sent so far are sent again there.
+func (f *FailoverLogClient) stream(ctx context.Context, ct CallType, open func(trillian.TrillianLogClient) (grpc.ClientStream, error)) (*failoverStream, error) {
+	order := f.order()
+	if max := f.opts.Policies[ct].MaxAttempts; max > 0 && max < len(order) {
+		order = order[:max]
+	}
+	s := &failoverStream{f: f, ctx: ctx, open: open, order: order}
+	if err := s.next(nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// failoverStream is a grpc.ClientStream which moves to the next backend if
+// its first receive fails.
+type failoverStream struct {
+	f     *FailoverLogClient
+	ctx   context.Context
+	open  func(trillian.TrillianLogClient) (grpc.ClientStream, error)
+	order []int
+
+	mu sync.Mutex
+	// cur is the stream on backend idx.
+	cur grpc.ClientStream
+	idx int
+	// sent holds the messages sent until a message is received, to send them
+	// again on the next backend.
+	sent       []interface{}
+	closedSend bool
+	received   bool
+}
+
+// next starts the stream on the next backend which accepts it, and sends it
+// the messages sent so far. It returns the last error, or lastErr if there is
+// no backend left. It must be called with mu held, or before the stream is
+// returned.
+func (s *failoverStream) next(lastErr error) error {
+	err := lastErr
+	for len(s.order) > 0 {
+		i := s.order[0]
+		s.order = s.order[1:]
+		var cs grpc.ClientStream
+		if cs, err = s.open(s.f.backends[i]); err == nil {
+			if err = s.replay(cs); err == nil {
+				s.cur, s.idx = cs, i
+				return nil
+			}
+		}
+		if !failover(s.ctx, err) {
+			return err
+		}
+		glog.V(1).Infof("Backend %d failed to start stream, failing over: %v", i, err)
+		s.f.setHealthy(i, false)
+	}
+	return err
+}
+
+// replay sends the messages sent so far to cs. Sends which fail because cs
+// broke are ignored, as its error is returned by its first receive.
+func (s *failoverStream) replay(cs grpc.ClientStream) error {
+	for _, m := range s.sent {
+		if err := cs.SendMsg(m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if s.closedSend {
+		return cs.CloseSend()
+	}
+	return nil
+}
+
+func (s *failoverStream) current() grpc.ClientStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+func (s *failoverStream) Header() (metadata.MD, error) {
+	return s.current().Header()
+}
+
+func (s *failoverStream) Trailer() metadata.MD {
+	return s.current().Trailer()
+}
+
+func (s *failoverStream) Context() context.Context {
+	return s.current().Context()
+}
+
+func (s *failoverStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closedSend = true
+	return s.cur.CloseSend()
+}
+
+func (s *failoverStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.received {
+		return s.cur.SendMsg(m)
+	}
+	s.sent = append(s.sent, m)
+	// A broken stream returns its error from the first receive, which may
+	// fail over and send m again.
+	if err := s.cur.SendMsg(m); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (s *failoverStream) RecvMsg(m interface{}) error {
+	for {
+		s.mu.Lock()
+		cs, i, received := s.cur, s.idx, s.received
+		s.mu.Unlock()
+		err := cs.RecvMsg(m)
+		if received {
+			return err
+		}
+		if err == nil || err == io.EOF {
+			s.mu.Lock()
+			s.received, s.sent = true, nil
+			s.mu.Unlock()
+			s.f.setHealthy(i, true)
+			return err
+		}
+		if !failover(s.ctx, err) {
+			return err
+		}
+		glog.V(1).Infof("Backend %d failed stream, failing over: %v", i, err)
+		s.f.setHealthy(i, false)
+		s.mu.Lock()
+		err = s.next(err)
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// queueLeavesStream is a trillian.TrillianLog_QueueLeavesClient on a
+// failoverStream.
+type queueLeavesStream struct {
+	*failoverStream
+}
+
+func (s queueLeavesStream) Send(m *trillian.QueueLeavesRequest) error {
+	return s.SendMsg(m)
+}
+
+func (s queueLeavesStream) Recv() (*trillian.QueueLeavesResponse, error) {
+	m := new(trillian.QueueLeavesResponse)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// streamLeavesStream is a trillian.TrillianLog_StreamLeavesClient on a
+// failoverStream.
+type streamLeavesStream struct {
+	*failoverStream
+}
+
+func (s streamLeavesStream) Recv() (*trillian.StreamLeavesResponse, error) {
+	m := new(trillian.StreamLeavesResponse)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,403 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBackend answers GetLatestSignedLogRoot and QueueLeaf with err, or blocks
+// until the call is canceled if block is set.
+type fakeBackend struct {
+	trillian.TrillianLogClient
+	name  string
+	block bool
+
+	mu       sync.Mutex
+	err      error
+	calls    int
+	canceled chan struct{}
+}
+
+func newFakeBackend(name string, err error) *fakeBackend {
+	return &fakeBackend{name: name, err: err, canceled: make(chan struct{}, 10)}
+}
+
+func (b *fakeBackend) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+func (b *fakeBackend) numCalls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+func (b *fakeBackend) answer(ctx context.Context) error {
+	b.mu.Lock()
+	b.calls++
+	err := b.err
+	b.mu.Unlock()
+	if b.block {
+		<-ctx.Done()
+		b.canceled <- struct{}{}
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return err
+}
+
+func (b *fakeBackend) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	if err := b.answer(ctx); err != nil {
+		return nil, err
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: []byte(b.name)}}, nil
+}
+
+func (b *fakeBackend) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	if err := b.answer(ctx); err != nil {
+		return nil, err
+	}
+	return &trillian.QueueLeafResponse{QueuedLeaf: &trillian.QueuedLogLeaf{Leaf: &trillian.LogLeaf{LeafValue: []byte(b.name)}}}, nil
+}
+
+func newTestFailoverClient(t *testing.T, opts FailoverOptions, backends ...*fakeBackend) *FailoverLogClient {
+	t.Helper()
+	var clients []trillian.TrillianLogClient
+	for _, b := range backends {
+		clients = append(clients, b)
+	}
+	f, err := NewFailoverLogClient(clients, opts)
+	if err != nil {
+		t.Fatalf("NewFailoverLogClient(): %v", err)
+	}
+	return f
+}
+
+func getRootFrom(t *testing.T, f *FailoverLogClient) (string, error) {
+	t.Helper()
+	resp, err := f.GetLatestSignedLogRoot(context.Background(), &trillian.GetLatestSignedLogRootRequest{})
+	if err != nil {
+		return "", err
+	}
+	return string(resp.SignedLogRoot.LogRoot), nil
+}
+
+func TestNewFailoverLogClientNoBackends(t *testing.T) {
+	if _, err := NewFailoverLogClient(nil, FailoverOptions{}); err == nil {
+		t.Error("NewFailoverLogClient() with no backends succeeded, want error")
+	}
+}
+
+func TestFailoverLogClientFailover(t *testing.T) {
+	ts := clock.NewFake(time.Unix(1000, 0))
+	b0 := newFakeBackend("b0", status.Error(codes.Unavailable, "down"))
+	b1 := newFakeBackend("b1", nil)
+	f := newTestFailoverClient(t, FailoverOptions{UnhealthyFor: time.Minute, TimeSource: ts}, b0, b1)
+
+	for _, want := range []string{"b1", "b1"} {
+		got, err := getRootFrom(t, f)
+		if err != nil {
+			t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+		}
+		if got != want {
+			t.Errorf("GetLatestSignedLogRoot() answered by %s, want %s", got, want)
+		}
+	}
+	// The unhealthy backend isn't tried first again.
+	if got, want := b0.numCalls(), 1; got != want {
+		t.Errorf("Unhealthy backend called %d times, want %d", got, want)
+	}
+	if got, want := f.Healthy(), []bool{false, true}; got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Healthy() = %v, want %v", got, want)
+	}
+
+	// It's preferred again once it recovered.
+	b0.setErr(nil)
+	ts.Set(ts.Now().Add(time.Minute))
+	if got, err := getRootFrom(t, f); err != nil || got != "b0" {
+		t.Errorf("GetLatestSignedLogRoot() after recovery answered by %q, %v, want b0", got, err)
+	}
+}
+
+func TestFailoverLogClientErrors(t *testing.T) {
+	for _, test := range []struct {
+		desc        string
+		errs        []error
+		maxAttempts int
+		wantCode    codes.Code
+		wantCalls   []int
+	}{
+		{
+			desc:      "not-retriable",
+			errs:      []error{status.Error(codes.NotFound, "no tree"), nil},
+			wantCode:  codes.NotFound,
+			wantCalls: []int{1, 0},
+		},
+		{
+			desc:      "all-fail",
+			errs:      []error{status.Error(codes.Unavailable, "down"), status.Error(codes.ResourceExhausted, "busy")},
+			wantCode:  codes.ResourceExhausted,
+			wantCalls: []int{1, 1},
+		},
+		{
+			desc:        "max-attempts",
+			errs:        []error{status.Error(codes.Unavailable, "down"), nil},
+			maxAttempts: 1,
+			wantCode:    codes.Unavailable,
+			wantCalls:   []int{1, 0},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var backends []*fakeBackend
+			for _, err := range test.errs {
+				backends = append(backends, newFakeBackend("b", err))
+			}
+			f := newTestFailoverClient(t, FailoverOptions{
+				Policies: map[CallType]CallPolicy{ReadCall: {MaxAttempts: test.maxAttempts}},
+			}, backends...)
+			_, err := getRootFrom(t, f)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("GetLatestSignedLogRoot(): %v, want code %v", err, test.wantCode)
+			}
+			for i, b := range backends {
+				if got, want := b.numCalls(), test.wantCalls[i]; got != want {
+					t.Errorf("Backend %d called %d times, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFailoverLogClientHedging(t *testing.T) {
+	b0 := newFakeBackend("b0", nil)
+	b0.block = true
+	b1 := newFakeBackend("b1", nil)
+	f := newTestFailoverClient(t, FailoverOptions{
+		Policies: map[CallType]CallPolicy{ReadCall: {HedgeDelay: 10 * time.Millisecond}},
+	}, b0, b1)
+
+	got, err := getRootFrom(t, f)
+	if err != nil {
+		t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+	}
+	if got != "b1" {
+		t.Errorf("Hedged GetLatestSignedLogRoot() answered by %s, want b1", got)
+	}
+	// The slow call is canceled once the hedged one succeeded.
+	select {
+	case <-b0.canceled:
+	case <-time.After(10 * time.Second):
+		t.Error("Slow call wasn't canceled")
+	}
+	// Being slow doesn't make a backend unhealthy.
+	if got := f.Healthy(); !got[0] || !got[1] {
+		t.Errorf("Healthy() = %v, want all healthy", got)
+	}
+}
+
+func TestFailoverLogClientWritesNotHedged(t *testing.T) {
+	b0 := newFakeBackend("b0", nil)
+	b0.block = true
+	b1 := newFakeBackend("b1", nil)
+	f := newTestFailoverClient(t, FailoverOptions{
+		Policies: map[CallType]CallPolicy{ReadCall: {HedgeDelay: time.Millisecond}},
+	}, b0, b1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := f.QueueLeaf(ctx, &trillian.QueueLeafRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("QueueLeaf(): %v, want deadline exceeded", err)
+	}
+	if got := b1.numCalls(); got != 0 {
+		t.Errorf("Write sent to second backend %d times, want 0", got)
+	}
+}
+
+// fakeClientStream is a stream whose first receive fails with err, and which
+// otherwise answers each message sent with a response naming its backend.
+type fakeClientStream struct {
+	grpc.ClientStream
+	name string
+	err  error
+	sent int
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error {
+	s.sent++
+	return nil
+}
+
+func (s *fakeClientStream) CloseSend() error {
+	return nil
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.sent == 0 {
+		return io.EOF
+	}
+	s.sent--
+	switch m := m.(type) {
+	case *trillian.StreamLeavesResponse:
+		m.Leaves = []*trillian.LogLeaf{{LeafValue: []byte(s.name)}}
+	case *trillian.QueueLeavesResponse:
+		m.QueuedLeaves = []*trillian.QueuedLogLeaf{{Leaf: &trillian.LogLeaf{LeafValue: []byte(s.name)}}}
+	}
+	return nil
+}
+
+type streamResponse interface {
+	GetLeaves() []*trillian.LogLeaf
+}
+
+func (b *fakeBackend) StreamLeaves(ctx context.Context, in *trillian.StreamLeavesRequest, opts ...grpc.CallOption) (trillian.TrillianLog_StreamLeavesClient, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls++
+	// The request is sent when the stream starts.
+	return streamLeavesClient{&fakeClientStream{name: b.name, err: b.err, sent: 1}}, nil
+}
+
+func (b *fakeBackend) QueueLeaves(ctx context.Context, opts ...grpc.CallOption) (trillian.TrillianLog_QueueLeavesClient, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls++
+	return queueLeavesClient{&fakeClientStream{name: b.name, err: b.err}}, nil
+}
+
+type streamLeavesClient struct {
+	*fakeClientStream
+}
+
+func (c streamLeavesClient) Recv() (*trillian.StreamLeavesResponse, error) {
+	m := new(trillian.StreamLeavesResponse)
+	return m, c.RecvMsg(m)
+}
+
+type queueLeavesClient struct {
+	*fakeClientStream
+}
+
+func (c queueLeavesClient) Send(m *trillian.QueueLeavesRequest) error {
+	return c.SendMsg(m)
+}
+
+func (c queueLeavesClient) Recv() (*trillian.QueueLeavesResponse, error) {
+	m := new(trillian.QueueLeavesResponse)
+	return m, c.RecvMsg(m)
+}
+
+func TestFailoverLogClientStreamFailover(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		desc      string
+		errs      []error
+		wantName  string
+		wantCode  codes.Code
+		wantCalls []int
+	}{
+		{
+			desc:      "first-recv-fails",
+			errs:      []error{status.Error(codes.Unavailable, "down"), nil},
+			wantName:  "b1",
+			wantCalls: []int{1, 1},
+		},
+		{
+			desc:      "not-retriable",
+			errs:      []error{status.Error(codes.NotFound, "no tree"), nil},
+			wantCode:  codes.NotFound,
+			wantCalls: []int{1, 0},
+		},
+		{
+			desc:      "all-fail",
+			errs:      []error{status.Error(codes.Unavailable, "down"), status.Error(codes.ResourceExhausted, "busy")},
+			wantCode:  codes.ResourceExhausted,
+			wantCalls: []int{1, 1},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			for _, call := range []struct {
+				name string
+				recv func(f *FailoverLogClient) (streamResponse, error)
+			}{
+				{name: "StreamLeaves", recv: func(f *FailoverLogClient) (streamResponse, error) {
+					s, err := f.StreamLeaves(ctx, &trillian.StreamLeavesRequest{})
+					if err != nil {
+						return nil, err
+					}
+					return s.Recv()
+				}},
+				{name: "QueueLeaves", recv: func(f *FailoverLogClient) (streamResponse, error) {
+					s, err := f.QueueLeaves(ctx)
+					if err != nil {
+						return nil, err
+					}
+					if err := s.Send(&trillian.QueueLeavesRequest{}); err != nil {
+						return nil, err
+					}
+					resp, err := s.Recv()
+					if err != nil {
+						return nil, err
+					}
+					return queuedLeaves{resp}, nil
+				}},
+			} {
+				backends := []*fakeBackend{newFakeBackend("b0", test.errs[0]), newFakeBackend("b1", test.errs[1])}
+				f := newTestFailoverClient(t, FailoverOptions{}, backends...)
+				resp, err := call.recv(f)
+				if got := status.Code(err); got != test.wantCode {
+					t.Fatalf("%s: Recv(): %v, want code %v", call.name, err, test.wantCode)
+				}
+				if err == nil {
+					if got := string(resp.GetLeaves()[0].LeafValue); got != test.wantName {
+						t.Errorf("%s: Recv() answered by %s, want %s", call.name, got, test.wantName)
+					}
+				}
+				for i, b := range backends {
+					if got, want := b.numCalls(), test.wantCalls[i]; got != want {
+						t.Errorf("%s: backend %d called %d times, want %d", call.name, i, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// queuedLeaves gives a QueueLeavesResponse the leaves of a StreamLeavesResponse.
+type queuedLeaves struct {
+	*trillian.QueueLeavesResponse
+}
+
+func (q queuedLeaves) GetLeaves() []*trillian.LogLeaf {
+	var leaves []*trillian.LogLeaf
+	for _, l := range q.QueuedLeaves {
+		leaves = append(leaves, l.Leaf)
+	}
+	return leaves
+}
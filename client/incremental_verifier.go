@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// IncrementalState is the state of an IncrementalVerifier. It can be persisted,
+// e.g. as JSON, to resume verification later with NewIncrementalVerifier.
+type IncrementalState struct {
+	// Size is the number of leaves consumed by the verifier.
+	Size uint64
+	// Hashes are the hashes of the compact range of the leaves consumed by the
+	// verifier, i.e. of the perfect subtrees covering [0, Size), left to right.
+	Hashes [][]byte
+}
+
+// IncrementalVerifier verifies a log incrementally: it consumes the leaves of
+// the log in order, and checks that they hash to the log roots of the same
+// size. It only keeps the compact range of the leaves consumed so far, so its
+// memory is logarithmic in the size of the log, unlike building a full Merkle
+// tree of the log.
+//
+// An IncrementalVerifier is not safe for concurrent use.
+type IncrementalVerifier struct {
+	hasher merkle.LogHasher
+	rng    *compact.Range
+}
+
+// NewIncrementalVerifier returns an IncrementalVerifier which resumes from
+// state, or starts from an empty log if state is nil.
+func NewIncrementalVerifier(hasher merkle.LogHasher, state *IncrementalState) (*IncrementalVerifier, error) {
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	if state == nil {
+		return &IncrementalVerifier{hasher: hasher, rng: rf.NewEmptyRange(0)}, nil
+	}
+	hashes := make([][]byte, len(state.Hashes))
+	for i, h := range state.Hashes {
+		if got, want := len(h), hasher.Size(); got != want {
+			return nil, fmt.Errorf("client: NewIncrementalVerifier(): state.Hashes[%d]: %d bytes, want %d", i, got, want)
+		}
+		hashes[i] = append([]byte(nil), h...)
+	}
+	rng, err := rf.NewRange(0, state.Size, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("client: NewIncrementalVerifier(): invalid state: %v", err)
+	}
+	return &IncrementalVerifier{hasher: hasher, rng: rng}, nil
+}
+
+// State returns the current state of the verifier.
+func (v *IncrementalVerifier) State() IncrementalState {
+	hashes := make([][]byte, 0, len(v.rng.Hashes()))
+	for _, h := range v.rng.Hashes() {
+		hashes = append(hashes, append([]byte(nil), h...))
+	}
+	return IncrementalState{Size: v.rng.End(), Hashes: hashes}
+}
+
+// Size returns the number of leaves consumed by the verifier, which is also the
+// index of the next leaf it expects.
+func (v *IncrementalVerifier) Size() uint64 {
+	return v.rng.End()
+}
+
+// Append consumes leaves, which must be the next leaves of the log in order. If
+// a leaf has its MerkleLeafHash set, it must match the hash of its LeafValue.
+// If an error is returned, none of the leaves is consumed.
+func (v *IncrementalVerifier) Append(leaves ...*trillian.LogLeaf) error {
+	next := v.rng.End()
+	hashes := make([][]byte, 0, len(leaves))
+	for i, leaf := range leaves {
+		if got, want := leaf.LeafIndex, int64(next)+int64(i); got != want {
+			return fmt.Errorf("leaves[%d].LeafIndex: %d, want %d", i, got, want)
+		}
+		hash := v.hasher.HashLeaf(leaf.LeafValue)
+		if len(leaf.MerkleLeafHash) != 0 && !bytes.Equal(leaf.MerkleLeafHash, hash) {
+			return fmt.Errorf("leaves[%d].MerkleLeafHash: %x, want %x", i, leaf.MerkleLeafHash, hash)
+		}
+		hashes = append(hashes, hash)
+	}
+	for _, hash := range hashes {
+		if err := v.rng.Append(hash, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RootHash returns the root hash of the tree of the leaves consumed so far.
+func (v *IncrementalVerifier) RootHash() ([]byte, error) {
+	if v.rng.End() == 0 {
+		return v.hasher.EmptyRoot(), nil
+	}
+	return v.rng.GetRootHash(nil)
+}
+
+// VerifyRoot checks that root is the root of the tree of the leaves consumed so
+// far. The caller must consume exactly root.TreeSize leaves before verifying a
+// root, and should check that root is consistent with previously verified roots
+// with LogVerifier.VerifyRoot.
+func (v *IncrementalVerifier) VerifyRoot(root *types.LogRootV1) error {
+	if root == nil {
+		return fmt.Errorf("VerifyRoot() error: root == nil")
+	}
+	if got, want := root.TreeSize, v.rng.End(); got != want {
+		return fmt.Errorf("VerifyRoot() error: root.TreeSize: %d, want %d leaves consumed", got, want)
+	}
+	hash, err := v.RootHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root.RootHash, hash) {
+		return fmt.Errorf("VerifyRoot() error: root hash %x at size %d, want %x", root.RootHash, root.TreeSize, hash)
+	}
+	return nil
+}
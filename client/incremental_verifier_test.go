@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	inmemory "github.com/transparency-dev/merkle/testonly"
+)
+
+func testLeaves(begin, end int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, 0, end-begin)
+	for i := begin; i < end; i++ {
+		leaves = append(leaves, &trillian.LogLeaf{LeafIndex: int64(i), LeafValue: []byte(fmt.Sprintf("leaf %d", i))})
+	}
+	return leaves
+}
+
+func TestIncrementalVerifier(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := inmemory.New(hasher)
+	v, err := NewIncrementalVerifier(hasher, nil)
+	if err != nil {
+		t.Fatalf("NewIncrementalVerifier(): %v", err)
+	}
+	for size := 0; size <= 70; size++ {
+		if size > 0 {
+			leaf := testLeaves(size-1, size)[0]
+			tree.AppendData(leaf.LeafValue)
+			if err := v.Append(leaf); err != nil {
+				t.Fatalf("Append(%d): %v", leaf.LeafIndex, err)
+			}
+		}
+		root := &types.LogRootV1{TreeSize: uint64(size), RootHash: tree.Hash()}
+		if err := v.VerifyRoot(root); err != nil {
+			t.Errorf("VerifyRoot(%d): %v", size, err)
+		}
+
+		// Resume from a persisted state.
+		state, err := json.Marshal(v.State())
+		if err != nil {
+			t.Fatalf("Marshal(): %v", err)
+		}
+		var s IncrementalState
+		if err := json.Unmarshal(state, &s); err != nil {
+			t.Fatalf("Unmarshal(): %v", err)
+		}
+		if v, err = NewIncrementalVerifier(hasher, &s); err != nil {
+			t.Fatalf("NewIncrementalVerifier(%d): %v", size, err)
+		}
+		if err := v.VerifyRoot(root); err != nil {
+			t.Errorf("VerifyRoot(%d) after resuming: %v", size, err)
+		}
+	}
+}
+
+func TestIncrementalVerifierErrors(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := inmemory.New(hasher)
+	leaves := testLeaves(0, 3)
+	for _, leaf := range leaves {
+		tree.AppendData(leaf.LeafValue)
+	}
+	v, err := NewIncrementalVerifier(hasher, nil)
+	if err != nil {
+		t.Fatalf("NewIncrementalVerifier(): %v", err)
+	}
+
+	badHash := &trillian.LogLeaf{LeafIndex: 0, LeafValue: []byte("value"), MerkleLeafHash: hasher.HashLeaf([]byte("other"))}
+	for _, test := range []struct {
+		desc   string
+		leaves []*trillian.LogLeaf
+	}{
+		{desc: "gap", leaves: testLeaves(1, 2)},
+		{desc: "out-of-order", leaves: []*trillian.LogLeaf{leaves[1], leaves[0]}},
+		{desc: "bad-leaf-hash", leaves: []*trillian.LogLeaf{badHash}},
+	} {
+		if err := v.Append(test.leaves...); err == nil {
+			t.Errorf("%s: Append() succeeded, want error", test.desc)
+		}
+		if got := v.Size(); got != 0 {
+			t.Errorf("%s: Size()=%d after failed Append(), want 0", test.desc, got)
+		}
+	}
+
+	if err := v.Append(leaves...); err != nil {
+		t.Fatalf("Append(): %v", err)
+	}
+	for _, test := range []struct {
+		desc string
+		root *types.LogRootV1
+	}{
+		{desc: "nil"},
+		{desc: "smaller", root: &types.LogRootV1{TreeSize: 2, RootHash: tree.HashAt(2)}},
+		{desc: "larger", root: &types.LogRootV1{TreeSize: 4, RootHash: tree.Hash()}},
+		{desc: "wrong-hash", root: &types.LogRootV1{TreeSize: 3, RootHash: tree.HashAt(2)}},
+	} {
+		if err := v.VerifyRoot(test.root); err == nil {
+			t.Errorf("%s: VerifyRoot() succeeded, want error", test.desc)
+		}
+	}
+
+	for _, test := range []struct {
+		desc  string
+		state IncrementalState
+	}{
+		{desc: "missing-hashes", state: IncrementalState{Size: 3, Hashes: [][]byte{tree.HashAt(2)}}},
+		{desc: "short-hash", state: IncrementalState{Size: 1, Hashes: [][]byte{{1, 2, 3}}}},
+	} {
+		if _, err := NewIncrementalVerifier(hasher, &test.state); err == nil {
+			t.Errorf("%s: NewIncrementalVerifier() succeeded, want error", test.desc)
+		}
+	}
+}
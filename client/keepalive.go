@@ -0,0 +1,36 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"time"
+
+	"google.golang.org/grpc/keepalive"
+)
+
+// DefaultKeepalive is the suggested keepalive configuration of connections to
+// Trillian servers, passed to grpc.WithKeepaliveParams when dialing them.
+//
+// Idle connections are pinged every minute, which keeps them open through
+// cloud load balancers that silently drop connections idle for a few minutes,
+// and closed if a ping isn't answered within 20 seconds, so that calls move to
+// a new connection instead of hanging on a broken one. Servers must accept
+// pings this often, which Trillian servers do unless their
+// --rpc_keepalive_min_time flag is raised above a minute.
+var DefaultKeepalive = keepalive.ClientParameters{
+	Time:                time.Minute,
+	Timeout:             20 * time.Second,
+	PermitWithoutStream: true,
+}
@@ -40,9 +40,14 @@ type LogClient struct {
 	root          types.LogRootV1
 	rootLock      sync.Mutex
 	updateLock    sync.Mutex
+
+	// Metrics, if set, records the latency of RPCs, retries and verification
+	// failures.
+	Metrics *Metrics
 }
 
-// New returns a new LogClient.
+// New returns a new LogClient. The client may be a FailoverLogClient, to
+// spread calls across several log servers.
 func New(logID int64, client trillian.TrillianLogClient, verifier *LogVerifier, root types.LogRootV1) *LogClient {
 	return &LogClient{
 		LogVerifier: verifier,
@@ -77,21 +82,25 @@ func (c *LogClient) AddLeaf(ctx context.Context, data []byte) error {
 
 // ListByIndex returns the requested leaves by index.
 func (c *LogClient) ListByIndex(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	startTime := time.Now()
 	resp, err := c.client.GetLeavesByRange(ctx,
 		&trillian.GetLeavesByRangeRequest{
 			LogId:      c.LogID,
 			StartIndex: start,
 			Count:      count,
 		})
+	c.Metrics.observeRPC(c.LogID, "GetLeavesByRange", startTime, err)
 	if err != nil {
 		return nil, err
 	}
 	// Verify that we got back the requested leaves.
 	if len(resp.Leaves) < int(count) {
+		c.Metrics.verifyFailed(c.LogID, verifyLeaves)
 		return nil, fmt.Errorf("len(Leaves)=%d, want %d", len(resp.Leaves), count)
 	}
 	for i, l := range resp.Leaves {
 		if want := start + int64(i); l.LeafIndex != want {
+			c.Metrics.verifyFailed(c.LogID, verifyLeaves)
 			return nil, fmt.Errorf("Leaves[%d].LeafIndex=%d, want %d", i, l.LeafIndex, want)
 		}
 	}
@@ -118,6 +127,7 @@ func (c *LogClient) WaitForRootUpdate(ctx context.Context) (*types.LogRootV1, er
 			}
 		case codes.Unavailable, codes.NotFound, codes.FailedPrecondition:
 			// Retry.
+			c.Metrics.retry(c.LogID, "root_update")
 		default:
 			return nil, err
 		}
@@ -133,11 +143,13 @@ func (c *LogClient) WaitForRootUpdate(ctx context.Context) (*types.LogRootV1, er
 // getAndVerifyLatestRoot fetches and verifies the latest root against a trusted root, seen in the past.
 // Pass nil for trusted if this is the first time querying this log.
 func (c *LogClient) getAndVerifyLatestRoot(ctx context.Context, trusted *types.LogRootV1) (*types.LogRootV1, error) {
+	startTime := time.Now()
 	resp, err := c.client.GetLatestSignedLogRoot(ctx,
 		&trillian.GetLatestSignedLogRootRequest{
 			LogId:         c.LogID,
 			FirstTreeSize: int64(trusted.TreeSize),
 		})
+	c.Metrics.observeRPC(c.LogID, "GetLatestSignedLogRoot", startTime, err)
 	if err != nil {
 		return nil, err
 	}
@@ -152,6 +164,7 @@ func (c *LogClient) getAndVerifyLatestRoot(ctx context.Context, trusted *types.L
 	// TODO(gbelvin): Remove this hack when all implementations store digital signatures.
 	var logRoot types.LogRootV1
 	if err := logRoot.UnmarshalBinary(resp.GetSignedLogRoot().LogRoot); err != nil {
+		c.Metrics.verifyFailed(c.LogID, verifyRoot)
 		return nil, err
 	}
 
@@ -165,6 +178,7 @@ func (c *LogClient) getAndVerifyLatestRoot(ctx context.Context, trusted *types.L
 	// Verify root update if the tree / the latest signed log root isn't empty.
 	if logRoot.TreeSize > 0 {
 		if _, err := c.VerifyRoot(trusted, resp.GetSignedLogRoot(), resp.GetProof().GetHashes()); err != nil {
+			c.Metrics.verifyFailed(c.LogID, verifyRoot)
 			return nil, err
 		}
 	}
@@ -259,6 +273,7 @@ func (c *LogClient) WaitForInclusion(ctx context.Context, data []byte) error {
 		}
 
 		// If not found or tree is empty, wait for a root update before retrying again.
+		c.Metrics.retry(c.LogID, "inclusion")
 		if _, err := c.WaitForRootUpdate(ctx); err != nil {
 			return err
 		}
@@ -268,12 +283,14 @@ func (c *LogClient) WaitForInclusion(ctx context.Context, data []byte) error {
 }
 
 func (c *LogClient) getAndVerifyInclusionProof(ctx context.Context, leafHash []byte, sth *types.LogRootV1) (bool, error) {
+	startTime := time.Now()
 	resp, err := c.client.GetInclusionProofByHash(ctx,
 		&trillian.GetInclusionProofByHashRequest{
 			LogId:    c.LogID,
 			LeafHash: leafHash,
 			TreeSize: int64(sth.TreeSize),
 		})
+	c.Metrics.observeRPC(c.LogID, "GetInclusionProofByHash", startTime, err)
 	if err != nil {
 		return false, err
 	}
@@ -282,12 +299,59 @@ func (c *LogClient) getAndVerifyInclusionProof(ctx context.Context, leafHash []b
 	}
 	for _, proof := range resp.Proof {
 		if err := c.VerifyInclusionByHash(sth, leafHash, proof); err != nil {
+			c.Metrics.verifyFailed(c.LogID, verifyInclusion)
 			return false, fmt.Errorf("VerifyInclusionByHash(): %v", err)
 		}
 	}
 	return true, nil
 }
 
+// GetProofBundle returns a proof bundle of data, which must be included in the
+// current trusted root, e.g. after WaitForInclusion returned. The bundle can be
+// stored, and verified later with VerifyProofBundle.
+//
+// Trillian doesn't sign log roots, so the LogRootSignature of the bundle is
+// left empty. Verifiers created by NewLogVerifierWithKeys reject such bundles:
+// callers must set it to the signature of the bundle's LogRoot obtained from
+// the personality serving the log first.
+func (c *LogClient) GetProofBundle(ctx context.Context, data []byte) (*types.ProofBundleV1, error) {
+	root := c.GetRoot()
+	if root.TreeSize == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "no trusted root including leaves")
+	}
+	leafHash := c.hasher.HashLeaf(data)
+	startTime := time.Now()
+	resp, err := c.client.GetInclusionProofByHash(ctx,
+		&trillian.GetInclusionProofByHashRequest{
+			LogId:    c.LogID,
+			LeafHash: leafHash,
+			TreeSize: int64(root.TreeSize),
+		})
+	c.Metrics.observeRPC(c.LogID, "GetInclusionProofByHash", startTime, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Proof) < 1 {
+		return nil, status.Errorf(codes.NotFound, "leaf not included in tree of size %d", root.TreeSize)
+	}
+	pf := resp.Proof[0]
+	if err := c.VerifyInclusionByHash(root, leafHash, pf); err != nil {
+		c.Metrics.verifyFailed(c.LogID, verifyInclusion)
+		return nil, fmt.Errorf("VerifyInclusionByHash(): %v", err)
+	}
+	logRoot, err := root.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &types.ProofBundleV1{
+		TreeID:         c.LogID,
+		LeafIndex:      uint64(pf.LeafIndex),
+		LeafValue:      data,
+		InclusionProof: pf.Hashes,
+		LogRoot:        logRoot,
+	}, nil
+}
+
 // AddSequencedLeaves adds any number of pre-sequenced leaves to the log.
 // Indexes must be contiguous.
 func (c *LogClient) AddSequencedLeaves(ctx context.Context, dataByIndex map[int64][]byte) error {
@@ -310,10 +374,12 @@ func (c *LogClient) AddSequencedLeaves(ctx context.Context, dataByIndex map[int6
 		leaf.LeafIndex = index
 		leaves = append(leaves, leaf)
 	}
+	startTime := time.Now()
 	resp, err := c.client.AddSequencedLeaves(ctx, &trillian.AddSequencedLeavesRequest{
 		LogId:  c.LogID,
 		Leaves: leaves,
 	})
+	c.Metrics.observeRPC(c.LogID, "AddSequencedLeaves", startTime, err)
 	for _, leaf := range resp.GetResults() {
 		if s := status.FromProto(leaf.GetStatus()); s.Code() != codes.OK && s.Code() != codes.AlreadyExists {
 			return status.Errorf(s.Code(), "unexpected fail status in AddSequencedLeaves: %+v, err: %v", leaf, s.Message())
@@ -326,10 +392,12 @@ func (c *LogClient) AddSequencedLeaves(ctx context.Context, dataByIndex map[int6
 // AlreadyExists is considered a success case by this function.
 func (c *LogClient) QueueLeaf(ctx context.Context, data []byte) error {
 	leaf := prepareLeaf(c.hasher, data)
+	startTime := time.Now()
 	_, err := c.client.QueueLeaf(ctx, &trillian.QueueLeafRequest{
 		LogId: c.LogID,
 		Leaf:  leaf,
 	})
+	c.Metrics.observeRPC(c.LogID, "QueueLeaf", startTime, err)
 	return err
 }
 
@@ -12,11 +12,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// The log client tests need storage, which doesn't build for WebAssembly.
+//go:build !js && !wasip1
+
 package client
 
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
 	"testing"
 	"time"
@@ -25,10 +30,14 @@ import (
 	"github.com/google/trillian/testonly/integration"
 	"github.com/google/trillian/types"
 	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/google/trillian/storage/testdb"
 	stestonly "github.com/google/trillian/storage/testonly"
+	inmemory "github.com/transparency-dev/merkle/testonly"
 )
 
 func TestAddGetLeaf(t *testing.T) {
@@ -240,3 +249,72 @@ func TestAddSequencedLeaves(t *testing.T) {
 		})
 	}
 }
+
+// inclusionLogClient serves inclusion proofs of a tree of leaves.
+type inclusionLogClient struct {
+	trillian.TrillianLogClient
+	tree *inmemory.Tree
+}
+
+func (c *inclusionLogClient) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	for i := uint64(0); i < c.tree.Size(); i++ {
+		if bytes.Equal(c.tree.LeafHash(i), in.LeafHash) {
+			hashes, err := c.tree.InclusionProof(i, uint64(in.TreeSize))
+			if err != nil {
+				return nil, err
+			}
+			return &trillian.GetInclusionProofByHashResponse{Proof: []*trillian.Proof{{LeafIndex: int64(i), Hashes: hashes}}}, nil
+		}
+	}
+	return &trillian.GetInclusionProofByHashResponse{}, nil
+}
+
+func TestGetProofBundle(t *testing.T) {
+	ctx := context.Background()
+	tree := inmemory.New(rfc6962.DefaultHasher)
+	for _, leaf := range []string{"A", "B", "C"} {
+		tree.AppendData([]byte(leaf))
+	}
+	verifier := NewLogVerifier(rfc6962.DefaultHasher)
+	root := types.LogRootV1{TreeSize: tree.Size(), RootHash: tree.Hash()}
+	client := New(5, &inclusionLogClient{tree: tree}, verifier, root)
+
+	bundle, err := client.GetProofBundle(ctx, []byte("B"))
+	if err != nil {
+		t.Fatalf("GetProofBundle(): %v", err)
+	}
+	if got, want := bundle.TreeID, int64(5); got != want {
+		t.Errorf("GetProofBundle().TreeID = %d, want %d", got, want)
+	}
+	if _, err := verifier.VerifyProofBundle(bundle); err != nil {
+		t.Errorf("VerifyProofBundle(): %v", err)
+	}
+
+	// Verifying the signature of the root needs one from the personality.
+	if len(bundle.LogRootSignature) != 0 {
+		t.Errorf("GetProofBundle().LogRootSignature = %x, want none", bundle.LogRootSignature)
+	}
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	signed, err := NewLogVerifierWithKeys(rfc6962.DefaultHasher, pub)
+	if err != nil {
+		t.Fatalf("NewLogVerifierWithKeys(): %v", err)
+	}
+	if _, err := signed.VerifyProofBundle(bundle); err == nil {
+		t.Error("VerifyProofBundle() of unsigned bundle with keys succeeded, want error")
+	}
+	bundle.LogRootSignature = ed25519.Sign(key, bundle.LogRoot)
+	if _, err := signed.VerifyProofBundle(bundle); err != nil {
+		t.Errorf("VerifyProofBundle() of signed bundle with keys: %v", err)
+	}
+
+	if _, err := client.GetProofBundle(ctx, []byte("D")); status.Code(err) != codes.NotFound {
+		t.Errorf("GetProofBundle() of missing leaf: %v, want NotFound", err)
+	}
+	empty := New(5, &inclusionLogClient{tree: tree}, verifier, types.LogRootV1{})
+	if _, err := empty.GetProofBundle(ctx, []byte("B")); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("GetProofBundle() without trusted root: %v, want FailedPrecondition", err)
+	}
+}
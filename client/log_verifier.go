@@ -15,12 +15,20 @@
 package client
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/types"
 	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
 	"github.com/transparency-dev/merkle/proof"
 	"github.com/transparency-dev/merkle/rfc6962"
 )
@@ -31,6 +39,8 @@ import (
 type LogVerifier struct {
 	// hasher is the hash strategy used to compute nodes in the Merkle tree.
 	hasher merkle.LogHasher
+	// keys are the public keys accepted by VerifySignedRoot.
+	keys []crypto.PublicKey
 }
 
 // NewLogVerifier returns an object that can verify output from Trillian Logs.
@@ -38,6 +48,27 @@ func NewLogVerifier(hasher merkle.LogHasher) *LogVerifier {
 	return &LogVerifier{hasher: hasher}
 }
 
+// NewLogVerifierWithKeys returns a LogVerifier that also checks the signatures of
+// log roots with VerifySignedRoot. A signature made by any of keys is accepted,
+// so that clients can keep verifying roots while the log rotates its signing
+// key: configure both the old and new keys for the duration of the rotation.
+//
+// Supported keys are *ecdsa.PublicKey and *rsa.PublicKey, for signatures of the
+// SHA-256 digest of the log root, and ed25519.PublicKey.
+func NewLogVerifierWithKeys(hasher merkle.LogHasher, keys ...crypto.PublicKey) (*LogVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("client: NewLogVerifierWithKeys(): no keys")
+	}
+	for i, key := range keys {
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, fmt.Errorf("client: NewLogVerifierWithKeys(): keys[%d]: unsupported key type %T", i, key)
+		}
+	}
+	return &LogVerifier{hasher: hasher, keys: append([]crypto.PublicKey(nil), keys...)}, nil
+}
+
 // NewLogVerifierFromTree creates a new LogVerifier using the algorithms
 // specified by a Trillian Tree object.
 func NewLogVerifierFromTree(config *trillian.Tree) (*LogVerifier, error) {
@@ -77,6 +108,48 @@ func (c *LogVerifier) VerifyRoot(trusted *types.LogRootV1, newRoot *trillian.Sig
 	return &r, nil
 }
 
+// VerifySignedRoot verifies that signature is a valid signature of newRoot by
+// any of the keys of the verifier, and then verifies newRoot as VerifyRoot does.
+// Trillian doesn't sign log roots itself, so signatures are usually obtained
+// from the personality serving the log.
+func (c *LogVerifier) VerifySignedRoot(trusted *types.LogRootV1, newRoot *trillian.SignedLogRoot, signature []byte, consistency [][]byte) (*types.LogRootV1, error) {
+	if newRoot == nil {
+		return nil, fmt.Errorf("VerifySignedRoot() error: newRoot == nil")
+	}
+	if len(c.keys) == 0 {
+		return nil, fmt.Errorf("VerifySignedRoot() error: no keys configured")
+	}
+	var errs []error
+	for _, key := range c.keys {
+		err := verifySignature(key, newRoot.LogRoot, signature)
+		if err == nil {
+			return c.VerifyRoot(trusted, newRoot, consistency)
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("VerifySignedRoot() error: signature not valid for any of %d keys: %v", len(c.keys), errs)
+}
+
+// verifySignature verifies that sig is a signature of data by key.
+func verifySignature(key crypto.PublicKey, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return errors.New("ECDSA signature verification failed")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return errors.New("Ed25519 signature verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", key)
+}
+
 // VerifyInclusionByHash verifies that the inclusion proof for the given Merkle leafHash
 // matches the given trusted root.
 func (c *LogVerifier) VerifyInclusionByHash(trusted *types.LogRootV1, leafHash []byte, pf *trillian.Proof) error {
@@ -89,3 +162,78 @@ func (c *LogVerifier) VerifyInclusionByHash(trusted *types.LogRootV1, leafHash [
 
 	return proof.VerifyInclusion(c.hasher, uint64(pf.LeafIndex), trusted.TreeSize, leafHash, pf.Hashes, trusted.RootHash)
 }
+
+// VerifyLeafRange verifies that the leaves with the given Merkle leafHashes are
+// the leaves of the trusted root from index begin, with the compact ranges
+// returned by GetCompactRange for [0, begin) and [begin+len(leafHashes),
+// trusted.TreeSize). A contiguous section of the log is thus verified with a
+// few hashes, instead of an inclusion proof per leaf.
+func (c *LogVerifier) VerifyLeafRange(trusted *types.LogRootV1, begin uint64, leafHashes, left, right [][]byte) error {
+	if trusted == nil {
+		return fmt.Errorf("VerifyLeafRange() error: trusted == nil")
+	}
+	end := begin + uint64(len(leafHashes))
+	if end > trusted.TreeSize {
+		return fmt.Errorf("VerifyLeafRange() error: leaves [%d, %d) beyond tree size %d", begin, end, trusted.TreeSize)
+	}
+	rf := &compact.RangeFactory{Hash: c.hasher.HashChildren}
+	r, err := rf.NewRange(0, begin, left)
+	if err != nil {
+		return fmt.Errorf("VerifyLeafRange() error: range [0, %d): %v", begin, err)
+	}
+	for _, hash := range leafHashes {
+		if err := r.Append(hash, nil); err != nil {
+			return fmt.Errorf("VerifyLeafRange() error: %v", err)
+		}
+	}
+	rightRange, err := rf.NewRange(end, trusted.TreeSize, right)
+	if err != nil {
+		return fmt.Errorf("VerifyLeafRange() error: range [%d, %d): %v", end, trusted.TreeSize, err)
+	}
+	if err := r.AppendRange(rightRange, nil); err != nil {
+		return fmt.Errorf("VerifyLeafRange() error: %v", err)
+	}
+	root := c.hasher.EmptyRoot()
+	if trusted.TreeSize > 0 {
+		if root, err = r.GetRootHash(nil); err != nil {
+			return fmt.Errorf("VerifyLeafRange() error: %v", err)
+		}
+	}
+	if !bytes.Equal(root, trusted.RootHash) {
+		return fmt.Errorf("VerifyLeafRange() error: calculated root %x, want %x", root, trusted.RootHash)
+	}
+	return nil
+}
+
+// VerifyProofBundle verifies offline that the leaf of bundle is included in
+// its log root, and returns that root. If the verifier has keys, the root must
+// also be signed by one of them, as VerifySignedRoot checks. Callers should
+// check that bundle.TreeID is the log they expect, and may check the returned
+// root against other roots of the log with VerifyRoot.
+func (c *LogVerifier) VerifyProofBundle(bundle *types.ProofBundleV1) (*types.LogRootV1, error) {
+	if bundle == nil {
+		return nil, fmt.Errorf("VerifyProofBundle() error: bundle == nil")
+	}
+	if bundle.LeafIndex > math.MaxInt64 {
+		return nil, fmt.Errorf("VerifyProofBundle() error: invalid leaf index %d", bundle.LeafIndex)
+	}
+	slr := &trillian.SignedLogRoot{LogRoot: bundle.LogRoot}
+	var root *types.LogRootV1
+	var err error
+	if len(c.keys) > 0 {
+		if len(bundle.LogRootSignature) == 0 {
+			return nil, fmt.Errorf("VerifyProofBundle() error: log root isn't signed")
+		}
+		root, err = c.VerifySignedRoot(&types.LogRootV1{}, slr, bundle.LogRootSignature, nil)
+	} else {
+		root, err = c.VerifyRoot(&types.LogRootV1{}, slr, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("VerifyProofBundle() error: %v", err)
+	}
+	pf := &trillian.Proof{LeafIndex: int64(bundle.LeafIndex), Hashes: bundle.InclusionProof}
+	if err := c.VerifyInclusionByHash(root, c.hasher.HashLeaf(bundle.LeafValue), pf); err != nil {
+		return nil, fmt.Errorf("VerifyProofBundle() error: %v", err)
+	}
+	return root, nil
+}
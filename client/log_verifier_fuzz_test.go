@@ -0,0 +1,173 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+
+	inmemory "github.com/transparency-dev/merkle/testonly"
+)
+
+// The fuzz targets below check that verifying proofs and roots from untrusted
+// logs doesn't panic, and doesn't accept inconsistent data. They're seeded
+// with valid proofs of a small tree. Run with e.g.:
+//
+//	go test ./client -fuzz FuzzVerifyRoot
+
+const fuzzTreeSize = 11
+
+func fuzzTree() *inmemory.Tree {
+	tree := inmemory.New(rfc6962.DefaultHasher)
+	for i := 0; i < fuzzTreeSize; i++ {
+		tree.AppendData([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return tree
+}
+
+// joinHashes and splitHashes pack proofs into the byte slices the fuzzer can
+// generate. The last split hash is short unless len(b) is a multiple of the
+// hash size, which tests malformed hashes too.
+func joinHashes(hashes [][]byte) []byte {
+	return bytes.Join(hashes, nil)
+}
+
+func splitHashes(b []byte) [][]byte {
+	var hashes [][]byte
+	for len(b) > 0 {
+		n := rfc6962.DefaultHasher.Size()
+		if n > len(b) {
+			n = len(b)
+		}
+		hashes = append(hashes, b[:n])
+		b = b[n:]
+	}
+	return hashes
+}
+
+// compactRanges returns the compact ranges of tree left and right of [begin,
+// end), as returned by GetCompactRange.
+func compactRanges(tb testing.TB, tree *inmemory.Tree, begin, end uint64) ([][]byte, [][]byte) {
+	tb.Helper()
+	rf := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+	compactRange := func(begin, end uint64) [][]byte {
+		r := rf.NewEmptyRange(begin)
+		for i := begin; i < end; i++ {
+			if err := r.Append(tree.LeafHash(i), nil); err != nil {
+				tb.Fatalf("Append(): %v", err)
+			}
+		}
+		return r.Hashes()
+	}
+	return compactRange(0, begin), compactRange(end, tree.Size())
+}
+
+func mustMarshalRoot(tb testing.TB, root *types.LogRootV1) []byte {
+	tb.Helper()
+	b, err := root.MarshalBinary()
+	if err != nil {
+		tb.Fatalf("MarshalBinary(): %v", err)
+	}
+	return b
+}
+
+func FuzzVerifyRoot(f *testing.F) {
+	tree := fuzzTree()
+	for _, sizes := range [][2]uint64{{0, 5}, {1, fuzzTreeSize}, {4, 7}, {7, fuzzTreeSize}, {fuzzTreeSize, fuzzTreeSize}} {
+		pf, err := tree.ConsistencyProof(sizes[0], sizes[1])
+		if err != nil {
+			f.Fatalf("ConsistencyProof(%d, %d): %v", sizes[0], sizes[1], err)
+		}
+		trusted := mustMarshalRoot(f, &types.LogRootV1{TreeSize: sizes[0], RootHash: tree.HashAt(sizes[0])})
+		newRoot := mustMarshalRoot(f, &types.LogRootV1{TreeSize: sizes[1], RootHash: tree.HashAt(sizes[1])})
+		f.Add(trusted, newRoot, joinHashes(pf))
+	}
+
+	v := NewLogVerifier(rfc6962.DefaultHasher)
+	f.Fuzz(func(t *testing.T, trustedRoot, newRoot, consistency []byte) {
+		var trusted types.LogRootV1
+		if err := trusted.UnmarshalBinary(trustedRoot); err != nil {
+			return
+		}
+		got, err := v.VerifyRoot(&trusted, &trillian.SignedLogRoot{LogRoot: newRoot}, splitHashes(consistency))
+		if err != nil {
+			return
+		}
+		if trusted.TreeSize == 0 {
+			return
+		}
+		if got.TreeSize < trusted.TreeSize {
+			t.Errorf("VerifyRoot() accepted root of size %d, smaller than trusted size %d", got.TreeSize, trusted.TreeSize)
+		}
+		if got.TreeSize == trusted.TreeSize && !bytes.Equal(got.RootHash, trusted.RootHash) {
+			t.Errorf("VerifyRoot() accepted root hash %x of size %d, trusted %x", got.RootHash, got.TreeSize, trusted.RootHash)
+		}
+	})
+}
+
+func FuzzVerifyInclusionByHash(f *testing.F) {
+	tree := fuzzTree()
+	for _, idx := range [][2]uint64{{0, 1}, {3, 7}, {6, fuzzTreeSize}, {fuzzTreeSize - 1, fuzzTreeSize}} {
+		pf, err := tree.InclusionProof(idx[0], idx[1])
+		if err != nil {
+			f.Fatalf("InclusionProof(%d, %d): %v", idx[0], idx[1], err)
+		}
+		f.Add(idx[1], tree.HashAt(idx[1]), tree.LeafHash(idx[0]), int64(idx[0]), joinHashes(pf))
+	}
+
+	v := NewLogVerifier(rfc6962.DefaultHasher)
+	f.Fuzz(func(t *testing.T, size uint64, rootHash, leafHash []byte, index int64, hashes []byte) {
+		trusted := &types.LogRootV1{TreeSize: size, RootHash: rootHash}
+		pf := &trillian.Proof{LeafIndex: index, Hashes: splitHashes(hashes)}
+		if err := v.VerifyInclusionByHash(trusted, leafHash, pf); err != nil {
+			return
+		}
+		if index < 0 || uint64(index) >= size {
+			t.Errorf("VerifyInclusionByHash() accepted index %d outside of tree size %d", index, size)
+		}
+	})
+}
+
+func FuzzVerifyLeafRange(f *testing.F) {
+	tree := fuzzTree()
+	for _, r := range [][2]uint64{{0, fuzzTreeSize}, {3, 5}, {8, fuzzTreeSize}} {
+		left, right := compactRanges(f, tree, r[0], r[1])
+		var leaves [][]byte
+		for i := r[0]; i < r[1]; i++ {
+			leaves = append(leaves, tree.LeafHash(i))
+		}
+		f.Add(uint64(fuzzTreeSize), tree.Hash(), r[0], joinHashes(leaves), joinHashes(left), joinHashes(right))
+	}
+
+	v := NewLogVerifier(rfc6962.DefaultHasher)
+	f.Fuzz(func(t *testing.T, size uint64, rootHash []byte, begin uint64, leaves, left, right []byte) {
+		trusted := &types.LogRootV1{TreeSize: size, RootHash: rootHash}
+		leafHashes := splitHashes(leaves)
+		if err := v.VerifyLeafRange(trusted, begin, leafHashes, splitHashes(left), splitHashes(right)); err != nil {
+			return
+		}
+		if end := begin + uint64(len(leafHashes)); end < begin || end > size {
+			t.Errorf("VerifyLeafRange() accepted leaves [%d, %d) outside of tree size %d", begin, end, size)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/status"
+)
+
+// Kinds of verification failures counted by Metrics.
+const (
+	verifyRoot      = "root"
+	verifyInclusion = "inclusion"
+	verifyLeaves    = "leaves"
+)
+
+// Metrics are the metrics exported by LogClients. A single Metrics should be
+// shared by all the LogClients of an application, as metric factories like
+// Prometheus reject metrics created twice. The metrics of each log are
+// labelled by its ID.
+type Metrics struct {
+	rpcLatency     monitoring.Histogram
+	retries        monitoring.Counter
+	verifyFailures monitoring.Counter
+}
+
+// NewMetrics creates the metrics of LogClients using mf.
+func NewMetrics(mf monitoring.MetricFactory) *Metrics {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &Metrics{
+		rpcLatency:     mf.NewHistogram("log_client_rpc_latency", "Latency of log RPCs in seconds, by method and gRPC status code", monitoring.TreeIDLabel, "method", "code"),
+		retries:        mf.NewCounter("log_client_retries", "Number of times an operation was retried after polling the log", monitoring.TreeIDLabel, "operation"),
+		verifyFailures: mf.NewCounter("log_client_verification_failures", "Number of responses from the log which failed verification, by kind", monitoring.TreeIDLabel, "kind"),
+	}
+}
+
+// The methods below do nothing if m is nil, so that LogClients without metrics
+// don't need to check.
+
+func (m *Metrics) observeRPC(logID int64, method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.rpcLatency.Observe(clock.SecondsSince(clock.System, start), strconv.FormatInt(logID, 10), method, status.Code(err).String())
+}
+
+func (m *Metrics) retry(logID int64, operation string) {
+	if m == nil {
+		return
+	}
+	m.retries.Inc(strconv.FormatInt(logID, 10), operation)
+}
+
+func (m *Metrics) verifyFailed(logID int64, kind string) {
+	if m == nil {
+		return
+	}
+	m.verifyFailures.Inc(strconv.FormatInt(logID, 10), kind)
+}
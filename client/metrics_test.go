@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogClientMetrics(t *testing.T) {
+	ctx := context.Background()
+	backend := newFakeBackend("not a log root", nil)
+	c := New(7, backend, NewLogVerifier(rfc6962.DefaultHasher), types.LogRootV1{})
+	c.Metrics = NewMetrics(monitoring.InertMetricFactory{})
+
+	if err := c.QueueLeaf(ctx, []byte("data")); err != nil {
+		t.Fatalf("QueueLeaf(): %v", err)
+	}
+	if _, err := c.UpdateRoot(ctx); err == nil {
+		t.Fatal("UpdateRoot() with invalid root succeeded, want error")
+	}
+	backend.setErr(status.Error(codes.Unavailable, "down"))
+	waitCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForRootUpdate(waitCtx); err == nil {
+		t.Fatal("WaitForRootUpdate() with unavailable log succeeded, want error")
+	}
+
+	for _, method := range []string{"QueueLeaf", "GetLatestSignedLogRoot"} {
+		if count, _ := c.Metrics.rpcLatency.Info("7", method, "OK"); count != 1 {
+			t.Errorf("%s latency observed %d times, want 1", method, count)
+		}
+	}
+	if count, _ := c.Metrics.rpcLatency.Info("7", "GetLatestSignedLogRoot", "Unavailable"); count < 2 {
+		t.Errorf("Failed GetLatestSignedLogRoot latency observed %d times, want at least 2", count)
+	}
+	if got := c.Metrics.retries.Value("7", "root_update"); got < 1 {
+		t.Errorf("Root update retries = %v, want at least 1", got)
+	}
+	if got := c.Metrics.verifyFailures.Value("7", verifyRoot); got != 1 {
+		t.Errorf("Root verification failures = %v, want 1", got)
+	}
+}
+
+func TestLogClientNoMetrics(t *testing.T) {
+	c := New(7, newFakeBackend("", nil), NewLogVerifier(rfc6962.DefaultHasher), types.LogRootV1{})
+	if err := c.QueueLeaf(context.Background(), []byte("data")); err != nil {
+		t.Fatalf("QueueLeaf() without metrics: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBackgroundTimeout is the default value of MultiLog.BackgroundTimeout.
+const DefaultBackgroundTimeout = time.Minute
+
+// MultiLog submits leaves to several Trillian logs concurrently, and considers a submission
+// successful once a quorum of the logs has accepted it.
+type MultiLog struct {
+	// Logs are the clients of the logs that leaves are submitted to.
+	Logs []*LogClient
+	// Quorum is the number of logs that must accept a leaf for a submission to succeed.
+	Quorum int
+	// BackgroundTimeout bounds the submission to each log, or the deadline of the caller's
+	// context does if it's later. Submissions aren't canceled once the result is known, nor
+	// when the caller's context is, so that a leaf isn't left in only some of the logs that
+	// would have accepted it. Defaults to DefaultBackgroundTimeout if zero.
+	BackgroundTimeout time.Duration
+}
+
+// NewMultiLog returns a MultiLog that submits leaves to logs, requiring quorum of them to succeed.
+func NewMultiLog(quorum int, logs ...*LogClient) (*MultiLog, error) {
+	if quorum <= 0 || quorum > len(logs) {
+		return nil, fmt.Errorf("quorum must be in [1, %d], got %d", len(logs), quorum)
+	}
+	return &MultiLog{Logs: logs, Quorum: quorum}, nil
+}
+
+// QuorumError is returned by MultiLog when too many logs failed a submission for the quorum
+// to be reached.
+type QuorumError struct {
+	// Quorum is the number of successful submissions that was required.
+	Quorum int
+	// Succeeded is the number of successful submissions.
+	Succeeded int
+	// Errors maps the indices in MultiLog.Logs of the logs that failed the submission to their
+	// errors.
+	Errors map[int]error
+}
+
+// Error returns a description of the QuorumError, including the errors of each log.
+func (e *QuorumError) Error() string {
+	indices := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	errs := make([]string, 0, len(indices))
+	for _, i := range indices {
+		errs = append(errs, fmt.Sprintf("Logs[%d]: %v", i, e.Errors[i]))
+	}
+	return fmt.Sprintf("quorum not reached: %d of %d required logs succeeded: %s", e.Succeeded, e.Quorum, strings.Join(errs, "; "))
+}
+
+// QueueLeaf queues data in all logs, and returns once Quorum logs have durably queued it.
+// AlreadyExists is considered a success case by this function.
+// Submissions still in flight once the result is known finish in the background.
+func (m *MultiLog) QueueLeaf(ctx context.Context, data []byte) error {
+	return m.forQuorum(ctx, func(ctx context.Context, c *LogClient) error {
+		return c.QueueLeaf(ctx, data)
+	})
+}
+
+// AddLeaf adds data to all logs, and blocks until Quorum logs have returned a verified
+// inclusion proof for it, as LogClient.AddLeaf does.
+// Submissions still in flight once the result is known finish in the background.
+func (m *MultiLog) AddLeaf(ctx context.Context, data []byte) error {
+	return m.forQuorum(ctx, func(ctx context.Context, c *LogClient) error {
+		return c.AddLeaf(ctx, data)
+	})
+}
+
+type logResult struct {
+	index int
+	err   error
+}
+
+// forQuorum calls fn concurrently for each log, and returns as soon as Quorum calls succeeded,
+// or as soon as too many failed for the quorum to be reached, in which case a *QuorumError is
+// returned. The calls have the values of ctx but their own deadline, see BackgroundTimeout,
+// and run to completion even after forQuorum has returned.
+func (m *MultiLog) forQuorum(ctx context.Context, fn func(context.Context, *LogClient) error) error {
+	if m.Quorum <= 0 || m.Quorum > len(m.Logs) {
+		return fmt.Errorf("quorum must be in [1, %d], got %d", len(m.Logs), m.Quorum)
+	}
+
+	timeout := m.BackgroundTimeout
+	if timeout <= 0 {
+		timeout = DefaultBackgroundTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.After(deadline) {
+		deadline = d
+	}
+	callCtx, cancel := context.WithDeadline(valuesOnly{ctx}, deadline)
+
+	// Buffered so that calls finishing after the result is known don't block.
+	results := make(chan logResult, len(m.Logs))
+	var wg sync.WaitGroup
+	for i, c := range m.Logs {
+		wg.Add(1)
+		go func(i int, c *LogClient) {
+			defer wg.Done()
+			results <- logResult{index: i, err: fn(callCtx, c)}
+		}(i, c)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	succeeded := 0
+	errs := make(map[int]error)
+	for range m.Logs {
+		var r logResult
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if r.err != nil {
+			errs[r.index] = r.err
+		} else {
+			succeeded++
+		}
+		switch {
+		case succeeded >= m.Quorum:
+			return nil
+		case len(m.Logs)-len(errs) < m.Quorum:
+			return &QuorumError{Quorum: m.Quorum, Succeeded: succeeded, Errors: errs}
+		}
+	}
+	// Unreachable: one of the cases above holds once all results are in.
+	return &QuorumError{Quorum: m.Quorum, Succeeded: succeeded, Errors: errs}
+}
+
+// valuesOnly is a context with the values of the context it wraps, but without its deadline
+// and cancellation.
+type valuesOnly struct {
+	context.Context
+}
+
+func (valuesOnly) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valuesOnly) Done() <-chan struct{}       { return nil }
+func (valuesOnly) Err() error                  { return nil }
@@ -0,0 +1,170 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc"
+)
+
+// queueLeafClient is a TrillianLogClient whose QueueLeaf returns err, or blocks until the
+// context is done if block is set.
+type queueLeafClient struct {
+	trillian.TrillianLogClient
+	err   error
+	block bool
+}
+
+func (c *queueLeafClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	if c.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &trillian.QueueLeafResponse{QueuedLeaf: &trillian.QueuedLogLeaf{Leaf: in.Leaf}}, nil
+}
+
+// slowClient is a TrillianLogClient whose QueueLeaf succeeds once release is closed, and then
+// sends the error of its context to ctxErr.
+type slowClient struct {
+	trillian.TrillianLogClient
+	release chan struct{}
+	ctxErr  chan error
+}
+
+func (c *slowClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	<-c.release
+	c.ctxErr <- ctx.Err()
+	return &trillian.QueueLeafResponse{QueuedLeaf: &trillian.QueuedLogLeaf{Leaf: in.Leaf}}, nil
+}
+
+func TestNewMultiLog(t *testing.T) {
+	logs := []*LogClient{{}, {}}
+	for _, test := range []struct {
+		quorum  int
+		wantErr bool
+	}{
+		{quorum: -1, wantErr: true},
+		{quorum: 0, wantErr: true},
+		{quorum: 1},
+		{quorum: 2},
+		{quorum: 3, wantErr: true},
+	} {
+		_, err := NewMultiLog(test.quorum, logs...)
+		if got := err != nil; got != test.wantErr {
+			t.Errorf("NewMultiLog(%d): %v, want error: %v", test.quorum, err, test.wantErr)
+		}
+	}
+}
+
+func TestMultiLogQueueLeaf(t *testing.T) {
+	ok := &queueLeafClient{}
+	blocked := &queueLeafClient{block: true}
+	failed := &queueLeafClient{err: errors.New("failed")}
+
+	for _, test := range []struct {
+		desc       string
+		clients    []trillian.TrillianLogClient
+		quorum     int
+		wantFailed []int
+	}{
+		{desc: "all succeed", clients: []trillian.TrillianLogClient{ok, ok, ok}, quorum: 3},
+		{desc: "quorum succeeds", clients: []trillian.TrillianLogClient{failed, ok, ok}, quorum: 2},
+		{desc: "quorum before blocked log", clients: []trillian.TrillianLogClient{ok, blocked, ok}, quorum: 2},
+		{desc: "quorum fails", clients: []trillian.TrillianLogClient{failed, ok, failed}, quorum: 2, wantFailed: []int{0, 2}},
+		{desc: "quorum unreachable despite blocked log", clients: []trillian.TrillianLogClient{failed, blocked, failed}, quorum: 2, wantFailed: []int{0, 2}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var logs []*LogClient
+			for i, c := range test.clients {
+				logs = append(logs, New(int64(i), c, NewLogVerifier(rfc6962.DefaultHasher), types.LogRootV1{}))
+			}
+			m, err := NewMultiLog(test.quorum, logs...)
+			if err != nil {
+				t.Fatalf("NewMultiLog(): %v", err)
+			}
+
+			err = m.QueueLeaf(context.Background(), []byte("A"))
+			if len(test.wantFailed) == 0 {
+				if err != nil {
+					t.Errorf("QueueLeaf(): %v, want nil", err)
+				}
+				return
+			}
+			var qErr *QuorumError
+			if !errors.As(err, &qErr) {
+				t.Fatalf("QueueLeaf(): %v, want QuorumError", err)
+			}
+			if got, want := len(qErr.Errors), len(test.wantFailed); got != want {
+				t.Errorf("QueueLeaf(): %d failed logs, want %d: %v", got, want, err)
+			}
+			for _, i := range test.wantFailed {
+				if qErr.Errors[i] == nil {
+					t.Errorf("QueueLeaf(): no error for Logs[%d]: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiLogFinishesInBackground(t *testing.T) {
+	newLog := func(c trillian.TrillianLogClient) *LogClient {
+		return New(0, c, NewLogVerifier(rfc6962.DefaultHasher), types.LogRootV1{})
+	}
+	for _, test := range []struct {
+		desc   string
+		cancel bool
+	}{
+		{desc: "after quorum"},
+		{desc: "after caller canceled", cancel: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			slow := &slowClient{release: make(chan struct{}), ctxErr: make(chan error, 1)}
+			quorum := 1
+			logs := []*LogClient{newLog(&queueLeafClient{}), newLog(slow)}
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.cancel {
+				quorum = 2
+				cancel()
+			} else {
+				defer cancel()
+			}
+			m, err := NewMultiLog(quorum, logs...)
+			if err != nil {
+				t.Fatalf("NewMultiLog(): %v", err)
+			}
+			m.BackgroundTimeout = time.Minute
+
+			err = m.QueueLeaf(ctx, []byte("A"))
+			if gotErr, wantErr := err != nil, test.cancel; gotErr != wantErr {
+				t.Errorf("QueueLeaf(): %v, want error: %v", err, wantErr)
+			}
+			cancel()
+			close(slow.release)
+			if err := <-slow.ctxErr; err != nil {
+				t.Errorf("Slow submission's context: %v, want still active", err)
+			}
+		})
+	}
+}
@@ -16,15 +16,26 @@ package rpcflags
 
 import (
 	"flag"
+	"fmt"
 
 	"github.com/golang/glog"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/util/compression"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 )
 
-// tlsCertFile is the flag-assigned value for the path to the Trillian server's TLS certificate.
-var tlsCertFile = flag.String("tls_cert_file", "", "Path to the file containing the Trillian server's PEM-encoded public TLS certificate. If unset, unsecured connections will be used")
+var (
+	// tlsCertFile is the flag-assigned value for the path to the Trillian server's TLS certificate.
+	tlsCertFile = flag.String("tls_cert_file", "", "Path to the file containing the Trillian server's PEM-encoded public TLS certificate. If unset, unsecured connections will be used")
+	// rpcCompressor is the flag-assigned value for the name of the compressor used for RPCs.
+	rpcCompressor = flag.String("rpc_compressor", "", fmt.Sprintf("Compressor used for RPC requests, which the server also uses for its responses. One of: %v. If unset, messages aren't compressed", compression.Names()))
+	// keepaliveTime and keepaliveTimeout are the flag-assigned keepalive settings of the connection.
+	keepaliveTime    = flag.Duration("rpc_client_keepalive_time", 0, fmt.Sprintf("If set, time after which an idle connection to the Trillian server is pinged, e.g. %v to keep it open through load balancers which drop idle connections. Trillian servers don't accept pings more often than their --rpc_keepalive_min_time", client.DefaultKeepalive.Time))
+	keepaliveTimeout = flag.Duration("rpc_client_keepalive_timeout", client.DefaultKeepalive.Timeout, "Time waited for the response to a keepalive ping before the connection is closed, with --rpc_client_keepalive_time")
+)
 
 // NewClientDialOptionsFromFlags returns a list of grpc.DialOption values to be
 // passed as DialOption arguments to grpc.Dial
@@ -42,5 +53,20 @@ func NewClientDialOptionsFromFlags() ([]grpc.DialOption, error) {
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
 	}
 
+	if name := *rpcCompressor; name != "" {
+		if err := compression.Register(name); err != nil {
+			return nil, err
+		}
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(name)))
+	}
+
+	if *keepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                *keepaliveTime,
+			Timeout:             *keepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
 	return dialOpts, nil
 }
@@ -0,0 +1,199 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sigstore renders Trillian log roots and proofs in the formats used
+// by sigstore: the checkpoints and proofs of the Rekor API, and the proofs of
+// sigstore bundles. Services built on Trillian can use it to serve roots and
+// proofs which existing sigstore verifiers accept.
+package sigstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/trillian/types"
+)
+
+const (
+	// timestampPrefix starts the extension line holding the timestamp of the
+	// root in checkpoints, as Rekor writes it.
+	timestampPrefix = "Timestamp: "
+	// sigPrefix starts the signature lines of signed notes.
+	sigPrefix = "\u2014 "
+)
+
+// FormatCheckpoint returns the body of the checkpoint of root for the log
+// identified by origin, e.g. "rekor.example.com - 1234". Its lines are the
+// origin, the tree size, the base64 encoded root hash, and the timestamp of
+// the root in nanoseconds, followed by extensions, one per line.
+func FormatCheckpoint(origin string, root *types.LogRootV1, extensions ...string) (string, error) {
+	if origin == "" || strings.Contains(origin, "\n") {
+		return "", fmt.Errorf("invalid origin %q", origin)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%d\n%s\n", origin, root.TreeSize, base64.StdEncoding.EncodeToString(root.RootHash))
+	fmt.Fprintf(&b, "%s%d\n", timestampPrefix, root.TimestampNanos)
+	for _, e := range extensions {
+		if e == "" || strings.Contains(e, "\n") {
+			return "", fmt.Errorf("invalid extension %q", e)
+		}
+		fmt.Fprintf(&b, "%s\n", e)
+	}
+	return b.String(), nil
+}
+
+// ParseCheckpoint parses the body of a checkpoint, and returns its origin, the
+// log root it holds, and its extensions other than the timestamp. The root's
+// timestamp is zero if the checkpoint has none.
+func ParseCheckpoint(body string) (string, *types.LogRootV1, []string, error) {
+	if !strings.HasSuffix(body, "\n") {
+		return "", nil, nil, errors.New("checkpoint doesn't end with a newline")
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) < 3 {
+		return "", nil, nil, fmt.Errorf("checkpoint has %d lines, want at least 3", len(lines))
+	}
+	origin := lines[0]
+	if origin == "" {
+		return "", nil, nil, errors.New("checkpoint has empty origin")
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid tree size: %v", err)
+	}
+	hash, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid root hash: %v", err)
+	}
+	root := &types.LogRootV1{TreeSize: size, RootHash: hash}
+	var extensions []string
+	timestamped := false
+	for _, line := range lines[3:] {
+		if line == "" {
+			return "", nil, nil, errors.New("checkpoint has empty line")
+		}
+		if ts := strings.TrimPrefix(line, timestampPrefix); ts != line && !timestamped {
+			if root.TimestampNanos, err = strconv.ParseUint(ts, 10, 64); err != nil {
+				return "", nil, nil, fmt.Errorf("invalid timestamp: %v", err)
+			}
+			timestamped = true
+			continue
+		}
+		extensions = append(extensions, line)
+	}
+	return origin, root, extensions, nil
+}
+
+// SignCheckpoint signs the checkpoint body as a signed note, as Rekor does,
+// and returns the note: the body, a blank line, and a signature line naming
+// the signer name. The signature is prefixed with the first 4 bytes of the
+// SHA-256 hash of the PKIX encoding of the public key of signer.
+//
+// ECDSA and RSA keys sign the SHA-256 digest of the body, and Ed25519 keys
+// sign the body itself.
+func SignCheckpoint(body, name string, signer crypto.Signer) (string, error) {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == '+' }) >= 0 {
+		return "", fmt.Errorf("invalid signer name %q", name)
+	}
+	if _, _, _, err := ParseCheckpoint(body); err != nil {
+		return "", fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	hint, err := keyHint(signer.Public())
+	if err != nil {
+		return "", err
+	}
+	msg, opts := []byte(body), crypto.SignerOpts(crypto.Hash(0))
+	if _, ok := signer.Public().(ed25519.PublicKey); !ok {
+		digest := sha256.Sum256(msg)
+		msg, opts = digest[:], crypto.SHA256
+	}
+	sig, err := signer.Sign(rand.Reader, msg, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign checkpoint: %v", err)
+	}
+	return fmt.Sprintf("%s\n%s%s %s\n", body, sigPrefix, name, base64.StdEncoding.EncodeToString(append(hint, sig...))), nil
+}
+
+// VerifyCheckpoint verifies that note is a checkpoint signed by the signer name
+// with key, as SignCheckpoint signs them, and returns its body. Signatures of
+// other signers are ignored.
+func VerifyCheckpoint(note, name string, key crypto.PublicKey) (string, error) {
+	i := strings.LastIndex(note, "\n\n")
+	if i < 0 {
+		return "", errors.New("note has no signatures")
+	}
+	body, sigs := note[:i+1], note[i+2:]
+	hint, err := keyHint(key)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(sigs, "\n") {
+		if !strings.HasPrefix(line, sigPrefix) {
+			continue
+		}
+		fields := strings.Split(strings.TrimPrefix(line, sigPrefix), " ")
+		if len(fields) != 2 || fields[0] != name {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) < 4 || !bytes.Equal(sig[:4], hint) {
+			continue
+		}
+		if err := verify(key, []byte(body), sig[4:]); err != nil {
+			return "", fmt.Errorf("invalid signature of %s: %v", name, err)
+		}
+		return body, nil
+	}
+	return "", fmt.Errorf("no signature of %s with the key", name)
+}
+
+func keyHint(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %v", err)
+	}
+	hash := sha256.Sum256(der)
+	return hash[:4], nil
+}
+
+func verify(key crypto.PublicKey, msg, sig []byte) error {
+	digest := sha256.Sum256(msg)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return errors.New("ECDSA signature verification failed")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, msg, sig) {
+			return errors.New("Ed25519 signature verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", key)
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sigstore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/types"
+)
+
+var testRoot = &types.LogRootV1{
+	TreeSize:       12,
+	RootHash:       []byte("0123456789abcdef0123456789abcdef"),
+	TimestampNanos: 1650000000000000000,
+}
+
+func TestFormatCheckpoint(t *testing.T) {
+	body, err := FormatCheckpoint("rekor.example.com - 42", testRoot, "extra")
+	if err != nil {
+		t.Fatalf("FormatCheckpoint(): %v", err)
+	}
+	want := "rekor.example.com - 42\n12\nMDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\nTimestamp: 1650000000000000000\nextra\n"
+	if body != want {
+		t.Errorf("FormatCheckpoint() = %q, want %q", body, want)
+	}
+
+	origin, root, extensions, err := ParseCheckpoint(body)
+	if err != nil {
+		t.Fatalf("ParseCheckpoint(): %v", err)
+	}
+	if origin != "rekor.example.com - 42" {
+		t.Errorf("ParseCheckpoint() origin = %q", origin)
+	}
+	if !reflect.DeepEqual(root, testRoot) {
+		t.Errorf("ParseCheckpoint() root = %+v, want %+v", root, testRoot)
+	}
+	if want := []string{"extra"}; !reflect.DeepEqual(extensions, want) {
+		t.Errorf("ParseCheckpoint() extensions = %q, want %q", extensions, want)
+	}
+
+	for _, test := range []struct {
+		origin     string
+		extensions []string
+	}{
+		{origin: ""},
+		{origin: "two\nlines"},
+		{origin: "origin", extensions: []string{""}},
+		{origin: "origin", extensions: []string{"two\nlines"}},
+	} {
+		if _, err := FormatCheckpoint(test.origin, testRoot, test.extensions...); err == nil {
+			t.Errorf("FormatCheckpoint(%q, %q) succeeded, want error", test.origin, test.extensions)
+		}
+	}
+}
+
+func TestParseCheckpointErrors(t *testing.T) {
+	for _, body := range []string{
+		"",
+		"origin\n12\n",
+		"origin\n12\nAAAA",
+		"\n12\nAAAA\n",
+		"origin\nx\nAAAA\n",
+		"origin\n12\n!!!\n",
+		"origin\n12\nAAAA\nTimestamp: x\n",
+		"origin\n12\nAAAA\n\nextra\n",
+	} {
+		if _, _, _, err := ParseCheckpoint(body); err == nil {
+			t.Errorf("ParseCheckpoint(%q) succeeded, want error", body)
+		}
+	}
+}
+
+func TestSignCheckpoint(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	body, err := FormatCheckpoint("origin", testRoot)
+	if err != nil {
+		t.Fatalf("FormatCheckpoint(): %v", err)
+	}
+
+	for _, signer := range []crypto.Signer{ecKey, edKey, rsaKey} {
+		note, err := SignCheckpoint(body, "log", signer)
+		if err != nil {
+			t.Fatalf("%T: SignCheckpoint(): %v", signer, err)
+		}
+		if !strings.HasPrefix(note, body+"\n— log ") {
+			t.Errorf("%T: SignCheckpoint() = %q, want body and signature line", signer, note)
+		}
+		got, err := VerifyCheckpoint(note, "log", signer.Public())
+		if err != nil {
+			t.Errorf("%T: VerifyCheckpoint(): %v", signer, err)
+		}
+		if got != body {
+			t.Errorf("%T: VerifyCheckpoint() = %q, want %q", signer, got, body)
+		}
+
+		// Co-signatures of other signers are ignored.
+		cosigned := note + "— witness AAAAAAAA\n"
+		if _, err := VerifyCheckpoint(cosigned, "log", signer.Public()); err != nil {
+			t.Errorf("%T: VerifyCheckpoint() of co-signed note: %v", signer, err)
+		}
+		if _, err := VerifyCheckpoint(note, "other", signer.Public()); err == nil {
+			t.Errorf("%T: VerifyCheckpoint() with other name succeeded, want error", signer)
+		}
+		if _, err := VerifyCheckpoint(note, "log", ecKey.Public()); err == nil && signer != ecKey {
+			t.Errorf("%T: VerifyCheckpoint() with other key succeeded, want error", signer)
+		}
+		tampered := strings.Replace(note, "origin", "forged", 1)
+		if _, err := VerifyCheckpoint(tampered, "log", signer.Public()); err == nil {
+			t.Errorf("%T: VerifyCheckpoint() of tampered note succeeded, want error", signer)
+		}
+	}
+
+	for _, name := range []string{"", "two words", "a+b"} {
+		if _, err := SignCheckpoint(body, name, ecKey); err == nil {
+			t.Errorf("SignCheckpoint() with name %q succeeded, want error", name)
+		}
+	}
+	if _, err := SignCheckpoint("not a checkpoint", "log", ecKey); err == nil {
+		t.Error("SignCheckpoint() of invalid checkpoint succeeded, want error")
+	}
+}
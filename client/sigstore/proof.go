@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sigstore
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+)
+
+// InclusionProof is an inclusion proof as returned by the Rekor API, with hex
+// encoded hashes. It's marshalled to JSON as Rekor's.
+type InclusionProof struct {
+	LogIndex   int64    `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int64    `json:"treeSize"`
+	Hashes     []string `json:"hashes"`
+	Checkpoint string   `json:"checkpoint,omitempty"`
+}
+
+// NewInclusionProof returns the Rekor API encoding of the inclusion proof pf
+// for root, with the signed checkpoint of root, which may be empty.
+func NewInclusionProof(root *types.LogRootV1, pf *trillian.Proof, checkpoint string) *InclusionProof {
+	return &InclusionProof{
+		LogIndex:   pf.GetLeafIndex(),
+		RootHash:   hex.EncodeToString(root.RootHash),
+		TreeSize:   int64(root.TreeSize),
+		Hashes:     HexHashes(pf.GetHashes()),
+		Checkpoint: checkpoint,
+	}
+}
+
+// ConsistencyProof is a consistency proof as returned by the Rekor API, with
+// hex encoded hashes. It's marshalled to JSON as Rekor's.
+type ConsistencyProof struct {
+	RootHash string   `json:"rootHash"`
+	Hashes   []string `json:"hashes"`
+}
+
+// NewConsistencyProof returns the Rekor API encoding of the consistency proof
+// pf from an older root to root.
+func NewConsistencyProof(root *types.LogRootV1, pf *trillian.Proof) *ConsistencyProof {
+	return &ConsistencyProof{
+		RootHash: hex.EncodeToString(root.RootHash),
+		Hashes:   HexHashes(pf.GetHashes()),
+	}
+}
+
+// BundleInclusionProof is an inclusion proof as held by sigstore bundles, with
+// base64 encoded hashes. It's marshalled to JSON as the InclusionProof
+// message of sigstore's protobuf specs is by protojson, with 64-bit integers
+// as strings.
+type BundleInclusionProof struct {
+	LogIndex   int64            `json:"logIndex,string"`
+	RootHash   string           `json:"rootHash"`
+	TreeSize   int64            `json:"treeSize,string"`
+	Hashes     []string         `json:"hashes"`
+	Checkpoint BundleCheckpoint `json:"checkpoint"`
+}
+
+// BundleCheckpoint is a signed checkpoint as held by sigstore bundles.
+type BundleCheckpoint struct {
+	Envelope string `json:"envelope"`
+}
+
+// NewBundleInclusionProof returns the sigstore bundle encoding of the
+// inclusion proof pf for root, with the signed checkpoint of root.
+func NewBundleInclusionProof(root *types.LogRootV1, pf *trillian.Proof, checkpoint string) *BundleInclusionProof {
+	return &BundleInclusionProof{
+		LogIndex:   pf.GetLeafIndex(),
+		RootHash:   base64.StdEncoding.EncodeToString(root.RootHash),
+		TreeSize:   int64(root.TreeSize),
+		Hashes:     Base64Hashes(pf.GetHashes()),
+		Checkpoint: BundleCheckpoint{Envelope: checkpoint},
+	}
+}
+
+// HexHashes returns the hex encodings of hashes.
+func HexHashes(hashes [][]byte) []string {
+	return encodeHashes(hashes, hex.EncodeToString)
+}
+
+// Base64Hashes returns the standard base64 encodings of hashes.
+func Base64Hashes(hashes [][]byte) []string {
+	return encodeHashes(hashes, base64.StdEncoding.EncodeToString)
+}
+
+func encodeHashes(hashes [][]byte, encode func([]byte) string) []string {
+	encoded := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		encoded = append(encoded, encode(h))
+	}
+	return encoded
+}
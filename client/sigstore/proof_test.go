@@ -0,0 +1,64 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sigstore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+)
+
+func TestProofJSON(t *testing.T) {
+	root := &types.LogRootV1{TreeSize: 5, RootHash: []byte{0xab, 0xcd}}
+	pf := &trillian.Proof{LeafIndex: 3, Hashes: [][]byte{{0x01, 0x02}, {0xff}}}
+
+	for _, test := range []struct {
+		desc  string
+		proof interface{}
+		want  string
+	}{
+		{
+			desc:  "rekor-inclusion",
+			proof: NewInclusionProof(root, pf, "checkpoint\n"),
+			want:  `{"logIndex":3,"rootHash":"abcd","treeSize":5,"hashes":["0102","ff"],"checkpoint":"checkpoint\n"}`,
+		},
+		{
+			desc:  "rekor-inclusion-no-checkpoint",
+			proof: NewInclusionProof(root, &trillian.Proof{}, ""),
+			want:  `{"logIndex":0,"rootHash":"abcd","treeSize":5,"hashes":[]}`,
+		},
+		{
+			desc:  "rekor-consistency",
+			proof: NewConsistencyProof(root, pf),
+			want:  `{"rootHash":"abcd","hashes":["0102","ff"]}`,
+		},
+		{
+			desc:  "bundle-inclusion",
+			proof: NewBundleInclusionProof(root, pf, "checkpoint\n"),
+			want:  `{"logIndex":"3","rootHash":"q80=","treeSize":"5","hashes":["AQI=","/w=="],"checkpoint":{"envelope":"checkpoint\n"}}`,
+		},
+	} {
+		got, err := json.Marshal(test.proof)
+		if err != nil {
+			t.Errorf("%s: json.Marshal(): %v", test.desc, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: json.Marshal() = %s, want %s", test.desc, got, test.want)
+		}
+	}
+}
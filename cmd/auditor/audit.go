@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// checkpoint is the auditing progress persisted between runs. It holds the
+// compact range covering the leaves [0, TreeSize) audited so far, which is
+// all that is needed to continue rebuilding the tree.
+type checkpoint struct {
+	TreeID   int64    `json:"tree_id"`
+	TreeSize uint64   `json:"tree_size"`
+	Hashes   [][]byte `json:"hashes"`
+}
+
+// auditor rebuilds the Merkle tree of a log from its leaves, keeping only a
+// compact range in memory.
+type auditor struct {
+	client trillian.TrillianLogClient
+	treeID int64
+	// batchSize is the maximum number of leaves requested per
+	// GetLeavesByRange call.
+	batchSize int64
+	// checkpointFile, if not empty, is where progress is saved, and loaded
+	// from when starting.
+	checkpointFile string
+	// checkpointEvery is the number of leaves between saved checkpoints.
+	checkpointEvery uint64
+}
+
+var rf = &compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+
+// audit downloads all leaves of the log up to its latest signed root, and
+// returns the root if the hash of the rebuilt tree matches it.
+func (a *auditor) audit(ctx context.Context) (*types.LogRootV1, error) {
+	resp, err := a.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: a.treeID})
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestSignedLogRoot: %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(resp.GetSignedLogRoot().GetLogRoot()); err != nil {
+		return nil, fmt.Errorf("failed to parse log root: %v", err)
+	}
+
+	cr, err := a.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if cr.End() > root.TreeSize {
+		return nil, fmt.Errorf("checkpoint at size %d is ahead of the latest root at size %d", cr.End(), root.TreeSize)
+	}
+	if cr.End() > 0 {
+		glog.Infof("Resuming audit of tree %d at size %d", a.treeID, cr.End())
+	}
+
+	saved := cr.End()
+	for cr.End() < root.TreeSize {
+		if err := a.fetch(ctx, cr, root.TreeSize); err != nil {
+			return nil, err
+		}
+		if a.checkpointFile != "" && cr.End()-saved >= a.checkpointEvery {
+			if err := a.saveCheckpoint(cr); err != nil {
+				return nil, err
+			}
+			saved = cr.End()
+			glog.Infof("Audited tree %d up to size %d of %d", a.treeID, cr.End(), root.TreeSize)
+		}
+	}
+
+	hash := rfc6962.DefaultHasher.EmptyRoot()
+	if root.TreeSize > 0 {
+		if hash, err = cr.GetRootHash(nil); err != nil {
+			return nil, fmt.Errorf("failed to compute root hash: %v", err)
+		}
+	}
+	if !bytes.Equal(hash, root.RootHash) {
+		return nil, fmt.Errorf("rebuilt root hash at size %d is %x, signed root has %x", root.TreeSize, hash, root.RootHash)
+	}
+	if a.checkpointFile != "" && cr.End() != saved {
+		if err := a.saveCheckpoint(cr); err != nil {
+			return nil, err
+		}
+	}
+	return &root, nil
+}
+
+// fetch requests the next batch of leaves below size and appends them to cr.
+func (a *auditor) fetch(ctx context.Context, cr *compact.Range, size uint64) error {
+	start := cr.End()
+	count := size - start
+	if count > uint64(a.batchSize) {
+		count = uint64(a.batchSize)
+	}
+	resp, err := a.client.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{
+		LogId:      a.treeID,
+		StartIndex: int64(start),
+		Count:      int64(count),
+	})
+	if err != nil {
+		return fmt.Errorf("GetLeavesByRange(%d, %d): %v", start, count, err)
+	}
+	if len(resp.Leaves) == 0 {
+		return fmt.Errorf("GetLeavesByRange(%d, %d): no leaves returned", start, count)
+	}
+	if uint64(len(resp.Leaves)) > count {
+		return fmt.Errorf("GetLeavesByRange(%d, %d): got %d leaves", start, count, len(resp.Leaves))
+	}
+	for i, leaf := range resp.Leaves {
+		if want := int64(start) + int64(i); leaf.LeafIndex != want {
+			return fmt.Errorf("Leaves[%d].LeafIndex=%d, want %d", i, leaf.LeafIndex, want)
+		}
+		hash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue)
+		if !bytes.Equal(hash, leaf.MerkleLeafHash) {
+			return fmt.Errorf("leaf %d: value hashes to %x, served Merkle leaf hash is %x", leaf.LeafIndex, hash, leaf.MerkleLeafHash)
+		}
+		if err := cr.Append(hash, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCheckpoint returns the compact range stored in the checkpoint file, or
+// an empty range if there is no checkpoint yet.
+func (a *auditor) loadCheckpoint() (*compact.Range, error) {
+	if a.checkpointFile == "" {
+		return rf.NewEmptyRange(0), nil
+	}
+	data, err := os.ReadFile(a.checkpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return rf.NewEmptyRange(0), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %q: %v", a.checkpointFile, err)
+	}
+	if cp.TreeID != a.treeID {
+		return nil, fmt.Errorf("checkpoint %q is for tree %d, not %d", a.checkpointFile, cp.TreeID, a.treeID)
+	}
+	cr, err := rf.NewRange(0, cp.TreeSize, cp.Hashes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %q: %v", a.checkpointFile, err)
+	}
+	return cr, nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint file with cr.
+func (a *auditor) saveCheckpoint(cr *compact.Range) error {
+	data, err := json.Marshal(&checkpoint{TreeID: a.treeID, TreeSize: cr.End(), Hashes: cr.Hashes()})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(a.checkpointFile), filepath.Base(a.checkpointFile)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), a.checkpointFile); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	return nil
+}
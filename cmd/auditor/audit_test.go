@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"google.golang.org/protobuf/proto"
+)
+
+// newFakeLog returns a log of the given size, which serves at most 3 leaves
+// per GetLeavesByRange call.
+func newFakeLog(size int) *testonly.FakeLog {
+	l := testonly.NewFakeLog(size)
+	l.MaxCount = 3
+	return l
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	for _, size := range []int{0, 1, 7, 64, 100} {
+		t.Run(fmt.Sprintf("size:%d", size), func(t *testing.T) {
+			a := &auditor{client: newFakeLog(size).Client(), treeID: 1, batchSize: 5}
+			root, err := a.audit(ctx)
+			if err != nil {
+				t.Fatalf("audit: %v", err)
+			}
+			if got, want := root.TreeSize, uint64(size); got != want {
+				t.Errorf("TreeSize=%d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestAuditDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc   string
+		tamper func(*testonly.FakeLog)
+	}{
+		{desc: "leaf-value", tamper: func(l *testonly.FakeLog) { l.Leaves[4].LeafValue = []byte("other") }},
+		{desc: "leaf-index", tamper: func(l *testonly.FakeLog) { l.Leaves[4].LeafIndex = 5 }},
+		{desc: "root", tamper: func(l *testonly.FakeLog) { l.Leaves = l.Leaves[:9] }},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Tampering with the leaves doesn't affect the served root.
+			l := newFakeLog(10)
+			tc.tamper(l)
+			a := &auditor{client: l.Client(), treeID: 1, batchSize: 100}
+			if _, err := a.audit(ctx); err == nil {
+				t.Error("audit: got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestAuditCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "checkpoint.json")
+	l := newFakeLog(20)
+	f := l.Client()
+	// Count the leaves fetched by the auditor.
+	var fetched int
+	f.Handle("GetLeavesByRange", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		resp, err := l.GetLeavesByRange(req.(*trillian.GetLeavesByRangeRequest))
+		if err == nil {
+			fetched += len(resp.Leaves)
+		}
+		return resp, err
+	})
+	a := &auditor{client: f, treeID: 1, batchSize: 4, checkpointFile: file, checkpointEvery: 6}
+	if _, err := a.audit(ctx); err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+
+	// Only the new leaves are fetched when auditing again.
+	for i := 20; i < 25; i++ {
+		l.Add([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	fetched = 0
+	root, err := a.audit(ctx)
+	if err != nil {
+		t.Fatalf("audit (resumed): %v", err)
+	}
+	if got, want := root.TreeSize, uint64(25); got != want {
+		t.Errorf("TreeSize=%d, want %d", got, want)
+	}
+	if got, want := fetched, 5; got != want {
+		t.Errorf("fetched %d leaves, want %d", got, want)
+	}
+
+	// A checkpoint of another tree is rejected.
+	b := &auditor{client: f, treeID: 2, batchSize: 4, checkpointFile: file, checkpointEvery: 6}
+	if _, err := b.audit(ctx); err == nil {
+		t.Error("audit (other tree): got nil error, want error")
+	}
+}
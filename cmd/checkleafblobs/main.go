@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// checkleafblobs command, which checks that the leaves of a tree in MySQL
+// storage written to a leaf blob store have consistent blobs.
+//
+// Example usage:
+// $ ./checkleafblobs --mysql_uri=${DB_URI} --mysql_leaf_blob_url=gs://bucket/prefix --tree_id=123
+//
+// The command exits with a non-zero status if any leaf is inconsistent.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/mysql"
+
+	// Register supported leaf blob stores.
+	_ "github.com/google/trillian/storage/blob/azblob"
+	_ "github.com/google/trillian/storage/blob/gcs"
+	_ "github.com/google/trillian/storage/blob/s3"
+)
+
+var (
+	treeID      = flag.Int64("tree_id", 0, "The ID of the tree to check")
+	maxProblems = flag.Int("max_problems", 100, "Maximum number of inconsistent leaves to log, all of them are counted")
+)
+
+func run(ctx context.Context) (int64, int, error) {
+	if *mysql.LeafBlobURL == "" {
+		return 0, 0, errors.New("--mysql_leaf_blob_url is required")
+	}
+	db, err := mysql.GetDatabase()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+	store, err := blob.Open(ctx, *mysql.LeafBlobURL)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer store.Close()
+
+	problems := 0
+	checked, err := mysql.CheckLeafBlobs(ctx, db, store, *treeID, func(leafIdentityHash []byte, problem error) {
+		if problems < *maxProblems {
+			glog.Errorf("Leaf %x: %v", leafIdentityHash, problem)
+		}
+		problems++
+	})
+	return checked, problems, err
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *treeID == 0 {
+		glog.Exitf("Usage: %s [flags] --tree_id=ID", flag.CommandLine.Name())
+	}
+	checked, problems, err := run(context.Background())
+	if err != nil {
+		glog.Exitf("Failed to check leaf blobs of tree %d after %d leaves: %v", *treeID, checked, err)
+	}
+	if problems > 0 {
+		glog.Exitf("Found %d inconsistent leaves out of %d in tree %d", problems, checked, *treeID)
+	}
+	glog.Infof("Checked %d leaves of tree %d, all consistent", checked, *treeID)
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"bitbucket.org/creachadair/shell"
+	"github.com/golang/glog"
+	"sigs.k8s.io/yaml"
+)
+
+// isYAML returns whether the config file at path is a YAML file, rather than
+// a flag file.
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// parseYAMLFile sets the flags listed in the YAML config file at path, except
+// those set on the command line.
+func parseYAMLFile(path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	cmdLine, err := recordFlags(os.Args[1:])
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if _, ok := cmdLine[name]; ok {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+// readConfigFile returns the values of the flags listed in the config file at
+// path, keyed by flag name, without setting them.
+//
+// YAML files hold a mapping of flag names to values. Lists are joined with
+// commas, for flags taking comma-separated values. Other files hold flags as
+// they would be passed on the command line. Environment variables are
+// expanded in both formats.
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isYAML(path) {
+		args, valid := shell.Split(string(file))
+		if !valid {
+			return nil, errors.New("flag file contains unclosed quotations")
+		}
+		for i := range args {
+			args[i] = os.ExpandEnv(args[i])
+		}
+		return recordFlags(args)
+	}
+
+	// Decode through JSON with UseNumber, so that large integers aren't turned
+	// into floats.
+	j, err := yaml.YAMLToJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config file: %v", err)
+	}
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("YAML config file must be a mapping of flag names to values: %v", err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("flag provided but not defined: -%s", name)
+		}
+		value, err := yamlFlagValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for flag -%s: %v", name, err)
+		}
+		values[name] = os.ExpandEnv(value)
+	}
+	return values, nil
+}
+
+func yamlFlagValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number, bool:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "", nil
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, e := range v {
+			p, err := yamlFlagValue(e)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, p)
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// recordedFlag is a flag.Value which records the values it's set to, instead
+// of applying them.
+type recordedFlag struct {
+	name     string
+	boolFlag bool
+	values   map[string]string
+}
+
+func (f *recordedFlag) String() string { return "" }
+
+func (f *recordedFlag) Set(value string) error {
+	f.values[f.name] = value
+	return nil
+}
+
+func (f *recordedFlag) IsBoolFlag() bool { return f.boolFlag }
+
+// recordFlags parses args as flags of flag.CommandLine, and returns the values
+// of the flags they set, keyed by flag name, without setting them.
+func recordFlags(args []string) (map[string]string, error) {
+	values := make(map[string]string)
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		fs.Var(&recordedFlag{name: f.Name, boolFlag: ok && b.IsBoolFlag(), values: values}, f.Name, f.Usage)
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ConfigReloader updates a set of reloadable flags from a config file, as
+// parsed by ParseFlagFile, while the process is running. Flags set on the
+// command line keep their value.
+type ConfigReloader struct {
+	path       string
+	reloadable []string
+	cmdLine    map[string]string
+	onReload   func(changed []string)
+}
+
+// NewConfigReloader returns a ConfigReloader for the config file at path. The
+// named flags are reloaded, and onReload is called with the names of the flags
+// whose value changed after each reload that changed any.
+func NewConfigReloader(path string, reloadable []string, onReload func(changed []string)) (*ConfigReloader, error) {
+	for _, name := range reloadable {
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("reloadable flag -%s is not defined", name)
+		}
+	}
+	cmdLine, err := recordFlags(os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]string(nil), reloadable...)
+	sort.Strings(sorted)
+	return &ConfigReloader{path: path, reloadable: sorted, cmdLine: cmdLine, onReload: onReload}, nil
+}
+
+// Reload reads the config file, and sets the reloadable flags to their value
+// in the file, or to their default value if the file doesn't list them. It
+// returns the names of the flags whose value changed, and calls onReload with
+// them.
+func (r *ConfigReloader) Reload() ([]string, error) {
+	values, err := readConfigFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %q: %v", r.path, err)
+	}
+	var changed []string
+	for _, name := range r.reloadable {
+		if _, ok := r.cmdLine[name]; ok {
+			continue
+		}
+		f := flag.Lookup(name)
+		value, ok := values[name]
+		if !ok {
+			value = f.DefValue
+		}
+		if value == f.Value.String() {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			err = fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err)
+			if len(changed) > 0 {
+				// Apply the flags which were already changed.
+				r.onReload(changed)
+			}
+			return changed, err
+		}
+		changed = append(changed, name)
+	}
+	if len(changed) > 0 {
+		r.onReload(changed)
+	}
+	return changed, nil
+}
+
+// Run reloads the config file each time the process receives SIGHUP, until
+// ctx is done.
+func (r *ConfigReloader) Run(ctx context.Context) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-sigs:
+			changed, err := r.Reload()
+			if err != nil {
+				glog.Errorf("Failed to reload config file %q, changed flags before the failure: %v: %v", r.path, changed, err)
+				continue
+			}
+			glog.Infof("Reloaded config file %q, changed flags: %v", r.path, changed)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	yamlStr  = flag.String("yaml_str", "default", "")
+	yamlInt  = flag.Int64("yaml_int", 1, "")
+	yamlBool = flag.Bool("yaml_bool", false, "")
+	yamlList = flag.String("yaml_list", "", "")
+)
+
+// withArgs sets the command line arguments for the duration of the test.
+func withArgs(t *testing.T, args ...string) {
+	t.Helper()
+	orig := os.Args
+	os.Args = append([]string{orig[0]}, args...)
+	t.Cleanup(func() { os.Args = orig })
+}
+
+// writeFile writes contents to a file called name in a temporary directory.
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	return path
+}
+
+// resetFlags restores the default values of the test flags.
+func resetFlags() {
+	*yamlStr, *yamlInt, *yamlBool, *yamlList = "default", 1, false, ""
+}
+
+func TestParseFlagFileYAML(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		contents string
+		cliArgs  []string
+		wantErr  bool
+		wantStr  string
+		wantInt  int64
+		wantBool bool
+		wantList string
+	}{
+		{
+			desc:     "all types",
+			contents: "yaml_str: one\nyaml_int: 10000000000\nyaml_bool: true\nyaml_list: [a, b]\n",
+			wantStr:  "one",
+			wantInt:  10000000000,
+			wantBool: true,
+			wantList: "a,b",
+		},
+		{
+			desc:     "overridden by command-line",
+			contents: "yaml_str: one\nyaml_int: 2\n",
+			cliArgs:  []string{"--yaml_str=two"},
+			wantStr:  "two",
+			wantInt:  2,
+		},
+		{
+			desc:     "environment variable",
+			contents: "yaml_str: $YAML_TEST_VAR\n",
+			wantStr:  "from env",
+			wantInt:  1,
+		},
+		{desc: "undefined flag", contents: "yaml_undefined: 1\n", wantErr: true},
+		{desc: "invalid value", contents: "yaml_int: one\n", wantErr: true},
+		{desc: "nested value", contents: "yaml_str:\n  a: b\n", wantErr: true},
+		{desc: "not a mapping", contents: "- yaml_str\n", wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			resetFlags()
+			withArgs(t, test.cliArgs...)
+			if err := flag.CommandLine.Parse(test.cliArgs); err != nil {
+				t.Fatalf("Parse(): %v", err)
+			}
+			t.Setenv("YAML_TEST_VAR", "from env")
+			path := writeFile(t, "config.yaml", test.contents)
+
+			err := ParseFlagFile(path)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("ParseFlagFile(): %v, want error: %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			got := []interface{}{*yamlStr, *yamlInt, *yamlBool, *yamlList}
+			want := []interface{}{test.wantStr, test.wantInt, test.wantBool, test.wantList}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("flags diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigReloader(t *testing.T) {
+	for _, name := range []string{"config.yaml", "config.flags"} {
+		t.Run(name, func(t *testing.T) {
+			resetFlags()
+			withArgs(t, "--yaml_bool")
+			*yamlBool = true
+
+			contents := map[string][]string{
+				"config.yaml":  {"yaml_str: one\nyaml_int: 2\nyaml_bool: false\n", "yaml_str: three\nyaml_list: x\n"},
+				"config.flags": {"--yaml_str=one --yaml_int=2 --yaml_bool=false", "--yaml_str=three --yaml_list=x"},
+			}[name]
+			path := writeFile(t, name, contents[0])
+
+			var reloads [][]string
+			r, err := NewConfigReloader(path, []string{"yaml_str", "yaml_int", "yaml_bool"}, func(changed []string) {
+				reloads = append(reloads, changed)
+			})
+			if err != nil {
+				t.Fatalf("NewConfigReloader(): %v", err)
+			}
+
+			if _, err := r.Reload(); err != nil {
+				t.Fatalf("Reload(): %v", err)
+			}
+			// yaml_bool is set on the command line, so it isn't reloaded.
+			if got, want := []interface{}{*yamlStr, *yamlInt, *yamlBool}, []interface{}{"one", int64(2), true}; !cmp.Equal(got, want) {
+				t.Errorf("after first Reload() flags = %v, want %v", got, want)
+			}
+
+			if err := ioutil.WriteFile(path, []byte(contents[1]), 0644); err != nil {
+				t.Fatalf("WriteFile(): %v", err)
+			}
+			if _, err := r.Reload(); err != nil {
+				t.Fatalf("Reload(): %v", err)
+			}
+			// yaml_int is reset to its default, and yaml_list isn't reloadable.
+			if got, want := []interface{}{*yamlStr, *yamlInt, *yamlList}, []interface{}{"three", int64(1), ""}; !cmp.Equal(got, want) {
+				t.Errorf("after second Reload() flags = %v, want %v", got, want)
+			}
+
+			// Reloading an unchanged file doesn't call onReload.
+			if _, err := r.Reload(); err != nil {
+				t.Fatalf("Reload(): %v", err)
+			}
+			if diff := cmp.Diff(reloads, [][]string{{"yaml_int", "yaml_str"}, {"yaml_int", "yaml_str"}}); diff != "" {
+				t.Errorf("onReload calls diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewConfigReloaderUndefinedFlag(t *testing.T) {
+	if _, err := NewConfigReloader("config.yaml", []string{"yaml_undefined"}, func([]string) {}); err == nil {
+		t.Error("NewConfigReloader() with undefined flag succeeded, want error")
+	}
+}
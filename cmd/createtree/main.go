@@ -24,6 +24,13 @@
 //
 // Several flags are provided to configure the create tree, most of which try to
 // assume reasonable defaults.
+//
+// Alternatively, the complete tree can be provided in JSON or YAML format using
+// the protobuf JSON mapping of trillian.Tree, e.g.:
+// $ ./createtree --admin_server=host:port --tree_file=tree.yaml --output_format=json
+//
+// Tree flags which are set to values other than their defaults override the
+// corresponding fields of the tree file.
 package main
 
 import (
@@ -31,6 +38,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -39,18 +49,32 @@ import (
 	"github.com/google/trillian/client/rpcflags"
 	"github.com/google/trillian/cmd"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/yaml"
 )
 
 var (
 	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
 	rpcDeadline     = flag.Duration("rpc_deadline", time.Second*10, "Deadline for RPC requests")
 
-	treeState       = flag.String("tree_state", trillian.TreeState_ACTIVE.String(), "State of the new tree")
-	treeType        = flag.String("tree_type", trillian.TreeType_LOG.String(), "Type of the new tree")
-	displayName     = flag.String("display_name", "", "Display name of the new tree")
-	description     = flag.String("description", "", "Description of the new tree")
-	maxRootDuration = flag.Duration("max_root_duration", time.Hour, "Interval after which a new signed root is produced despite no submissions; zero means never")
+	treeState        = flag.String("tree_state", trillian.TreeState_ACTIVE.String(), "State of the new tree")
+	treeType         = flag.String("tree_type", trillian.TreeType_LOG.String(), "Type of the new tree")
+	displayName      = flag.String("display_name", "", "Display name of the new tree")
+	description      = flag.String("description", "", "Description of the new tree")
+	maxRootDuration  = flag.Duration("max_root_duration", time.Hour, "Interval after which a new signed root is produced despite no submissions; zero means never")
+	leafDedupPolicy  = flag.String("leaf_dedup_policy", trillian.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH.String(), "Policy used to deduplicate leaves submitted to the new tree")
+	guardWindow      = flag.Duration("sequencer_guard_window", 0, "Time after which leaves submitted to the new tree are eligible for sequencing; zero means the signer's")
+	batchSize        = flag.Int("sequencer_batch_size", 0, "Max number of leaves of the new tree sequenced per pass; zero means the signer's")
+	priority         = flag.String("priority", trillian.TreePriority_NORMAL_PRIORITY.String(), "Priority of the new tree in the log signer")
+	signerAffinity   = flag.String("signer_affinity", "", "Comma-separated labels of the log signer instances which may run the new tree; empty means any")
+	antiAffinity     = flag.String("anti_affinity_group", "", "Anti-affinity group of the new tree, whose trees are spread across log signer instances")
+	maxLeafValueSize = flag.Int("max_leaf_value_size", 0, "Max size in bytes of the LeafValue of leaves queued to the new tree; zero means the log server's")
+	maxExtraDataSize = flag.Int("max_extra_data_size", 0, "Max size in bytes of the ExtraData of leaves queued to the new tree; zero means the log server's")
+	maxUnsequenced   = flag.Int("max_unsequenced_leaves", 0, "Max number of unsequenced leaves queued to the new tree before new leaves are rejected; zero means the log server's")
+	compactRetained  = flag.Int("compact_retained_revisions", 0, "Number of latest revisions of the new tree whose subtrees are retained by compaction; zero means the log signer's")
+	treeFile         = flag.String("tree_file", "", "If set, JSON or YAML file (by extension) containing the tree to create, in the protobuf JSON format")
+	outputFormat     = flag.String("output_format", "id", "Output format of the created tree. One of: id, json")
 
 	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
 
@@ -96,18 +120,148 @@ func newRequest() (*trillian.CreateTreeRequest, error) {
 		return nil, fmt.Errorf("unknown TreeType: %v", *treeType)
 	}
 
-	ctr := &trillian.CreateTreeRequest{Tree: &trillian.Tree{
-		TreeState:       trillian.TreeState(ts),
-		TreeType:        trillian.TreeType(tt),
-		DisplayName:     *displayName,
-		Description:     *description,
-		MaxRootDuration: durationpb.New(*maxRootDuration),
-	}}
+	dp, ok := trillian.LeafDedupPolicy_value[*leafDedupPolicy]
+	if !ok {
+		return nil, fmt.Errorf("unknown LeafDedupPolicy: %v", *leafDedupPolicy)
+	}
+
+	tp, ok := trillian.TreePriority_value[*priority]
+	if !ok {
+		return nil, fmt.Errorf("unknown TreePriority: %v", *priority)
+	}
+
+	var tree *trillian.Tree
+	if *treeFile == "" {
+		tree = &trillian.Tree{
+			TreeState:       trillian.TreeState(ts),
+			TreeType:        trillian.TreeType(tt),
+			DisplayName:     *displayName,
+			Description:     *description,
+			MaxRootDuration: durationpb.New(*maxRootDuration),
+			LeafDedupPolicy: trillian.LeafDedupPolicy(dp),
+
+			SequencerBatchSize:       int32(*batchSize),
+			Priority:                 trillian.TreePriority(tp),
+			SignerAffinity:           splitLabels(*signerAffinity),
+			AntiAffinityGroup:        *antiAffinity,
+			MaxLeafValueSize:         int32(*maxLeafValueSize),
+			MaxExtraDataSize:         int32(*maxExtraDataSize),
+			MaxUnsequencedLeaves:     int32(*maxUnsequenced),
+			CompactRetainedRevisions: int32(*compactRetained),
+		}
+		if *guardWindow != 0 {
+			tree.SequencerGuardWindow = durationpb.New(*guardWindow)
+		}
+	} else {
+		var err error
+		if tree, err = readTreeFile(*treeFile); err != nil {
+			return nil, err
+		}
+		if flagChanged("tree_state") {
+			tree.TreeState = trillian.TreeState(ts)
+		}
+		if flagChanged("tree_type") {
+			tree.TreeType = trillian.TreeType(tt)
+		}
+		if flagChanged("display_name") {
+			tree.DisplayName = *displayName
+		}
+		if flagChanged("description") {
+			tree.Description = *description
+		}
+		if flagChanged("max_root_duration") {
+			tree.MaxRootDuration = durationpb.New(*maxRootDuration)
+		}
+		if flagChanged("leaf_dedup_policy") {
+			tree.LeafDedupPolicy = trillian.LeafDedupPolicy(dp)
+		}
+		if flagChanged("sequencer_guard_window") {
+			tree.SequencerGuardWindow = durationpb.New(*guardWindow)
+		}
+		if flagChanged("sequencer_batch_size") {
+			tree.SequencerBatchSize = int32(*batchSize)
+		}
+		if flagChanged("priority") {
+			tree.Priority = trillian.TreePriority(tp)
+		}
+		if flagChanged("signer_affinity") {
+			tree.SignerAffinity = splitLabels(*signerAffinity)
+		}
+		if flagChanged("anti_affinity_group") {
+			tree.AntiAffinityGroup = *antiAffinity
+		}
+		if flagChanged("max_leaf_value_size") {
+			tree.MaxLeafValueSize = int32(*maxLeafValueSize)
+		}
+		if flagChanged("max_extra_data_size") {
+			tree.MaxExtraDataSize = int32(*maxExtraDataSize)
+		}
+		if flagChanged("max_unsequenced_leaves") {
+			tree.MaxUnsequencedLeaves = int32(*maxUnsequenced)
+		}
+		if flagChanged("compact_retained_revisions") {
+			tree.CompactRetainedRevisions = int32(*compactRetained)
+		}
+	}
+
+	ctr := &trillian.CreateTreeRequest{Tree: tree}
 	glog.Infof("Creating tree %+v", ctr.Tree)
 
 	return ctr, nil
 }
 
+// flagChanged returns whether the named flag is set to a value other than its
+// default.
+func flagChanged(name string) bool {
+	f := flag.Lookup(name)
+	return f.Value.String() != f.DefValue
+}
+
+// splitLabels splits a comma-separated list of labels.
+func splitLabels(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// readTreeFile parses the tree contained in the given JSON or YAML file.
+func readTreeFile(path string) (*trillian.Tree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree file: %v", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse tree file %q: %v", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tree file extension %q, want .json, .yaml or .yml", ext)
+	}
+	tree := &trillian.Tree{}
+	if err := protojson.Unmarshal(data, tree); err != nil {
+		return nil, fmt.Errorf("failed to parse tree file %q: %v", path, err)
+	}
+	return tree, nil
+}
+
+// formatTree returns the output printed for the created tree.
+func formatTree(tree *trillian.Tree) (string, error) {
+	switch *outputFormat {
+	case "id":
+		return fmt.Sprint(tree.TreeId), nil
+	case "json":
+		data, err := protojson.MarshalOptions{Multiline: true}.Marshal(tree)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("unknown --output_format %q, want id or json", *outputFormat)
+}
+
 func main() {
 	flag.Parse()
 	defer glog.Flush()
@@ -125,8 +279,11 @@ func main() {
 		glog.Exitf("Failed to create tree: %v", err)
 	}
 
-	// DO NOT change the output format, scripts are meant to depend on it.
-	// If you really want to change it, provide an output_format flag and
-	// keep the default as-is.
-	fmt.Println(tree.TreeId)
+	// DO NOT change the default output format, scripts are meant to depend on
+	// it. New formats should be added to --output_format instead.
+	out, err := formatTree(tree)
+	if err != nil {
+		glog.Exitf("Failed to format tree: %v", err)
+	}
+	fmt.Println(out)
 }
@@ -17,6 +17,9 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -26,6 +29,7 @@ import (
 	"github.com/google/trillian/testonly/flagsaver"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 )
@@ -52,6 +56,16 @@ func TestCreateTree(t *testing.T) {
 	nonDefaultTree.TreeType = trillian.TreeType_LOG
 	nonDefaultTree.DisplayName = "Llamas Log"
 	nonDefaultTree.Description = "For all your digital llama needs!"
+	nonDefaultTree.LeafDedupPolicy = trillian.LeafDedupPolicy_NO_DEDUP
+	nonDefaultTree.SequencerGuardWindow = durationpb.New(5 * time.Second)
+	nonDefaultTree.SequencerBatchSize = 100
+	nonDefaultTree.Priority = trillian.TreePriority_LOW_PRIORITY
+	nonDefaultTree.SignerAffinity = []string{"a", "b"}
+	nonDefaultTree.AntiAffinityGroup = "hot"
+	nonDefaultTree.MaxLeafValueSize = 1024
+	nonDefaultTree.MaxExtraDataSize = 256
+	nonDefaultTree.MaxUnsequencedLeaves = 1000
+	nonDefaultTree.CompactRetainedRevisions = 10
 
 	runTest(t, []*testCase{
 		{
@@ -65,6 +79,16 @@ func TestCreateTree(t *testing.T) {
 				*treeType = nonDefaultTree.TreeType.String()
 				*displayName = nonDefaultTree.DisplayName
 				*description = nonDefaultTree.Description
+				*leafDedupPolicy = nonDefaultTree.LeafDedupPolicy.String()
+				*guardWindow = nonDefaultTree.SequencerGuardWindow.AsDuration()
+				*batchSize = int(nonDefaultTree.SequencerBatchSize)
+				*priority = nonDefaultTree.Priority.String()
+				*signerAffinity = "a,b"
+				*antiAffinity = nonDefaultTree.AntiAffinityGroup
+				*maxLeafValueSize = int(nonDefaultTree.MaxLeafValueSize)
+				*maxExtraDataSize = int(nonDefaultTree.MaxExtraDataSize)
+				*maxUnsequenced = int(nonDefaultTree.MaxUnsequencedLeaves)
+				*compactRetained = int(nonDefaultTree.CompactRetainedRevisions)
 			},
 			wantTree: nonDefaultTree,
 		},
@@ -87,6 +111,18 @@ func TestCreateTree(t *testing.T) {
 			validateErr: errors.New("unknown TreeType"),
 			wantErr:     true,
 		},
+		{
+			desc:        "invalidDedupPolicy",
+			setFlags:    func() { *leafDedupPolicy = "LLAMA!" },
+			validateErr: errors.New("unknown LeafDedupPolicy"),
+			wantErr:     true,
+		},
+		{
+			desc:        "invalidPriority",
+			setFlags:    func() { *priority = "LLAMA!" },
+			validateErr: errors.New("unknown TreePriority"),
+			wantErr:     true,
+		},
 		{
 			desc:      "createErr",
 			createErr: status.Errorf(codes.Unavailable, "create tree failed"),
@@ -170,3 +206,87 @@ func expectCalls(call *gomock.Call, err error, prevErr ...error) *gomock.Call {
 	// If this function succeeds it should only be called once.
 	return call.Times(1)
 }
+
+func TestNewRequestFromTreeFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		return path
+	}
+	fileTree := &trillian.Tree{
+		TreeState:       trillian.TreeState_ACTIVE,
+		TreeType:        trillian.TreeType_PREORDERED_LOG,
+		DisplayName:     "Llamas Log",
+		MaxRootDuration: durationpb.New(90 * time.Second),
+	}
+	jsonFile := writeFile("tree.json", `{"treeState": "ACTIVE", "treeType": "PREORDERED_LOG", "displayName": "Llamas Log", "maxRootDuration": "90s"}`)
+	yamlFile := writeFile("tree.yaml", "tree_state: ACTIVE\ntree_type: PREORDERED_LOG\ndisplay_name: Llamas Log\nmax_root_duration: 90s\n")
+
+	overridden := proto.Clone(fileTree).(*trillian.Tree)
+	overridden.DisplayName = "Alpacas Log"
+
+	for _, tc := range []struct {
+		desc     string
+		file     string
+		flags    map[string]string
+		wantTree *trillian.Tree
+		wantErr  bool
+	}{
+		{desc: "json", file: jsonFile, wantTree: fileTree},
+		{desc: "yaml", file: yamlFile, wantTree: fileTree},
+		{desc: "flagOverride", file: yamlFile, flags: map[string]string{"display_name": "Alpacas Log"}, wantTree: overridden},
+		{desc: "unknownField", file: writeFile("bad.json", `{"llamas": 1}`), wantErr: true},
+		{desc: "unknownExtension", file: writeFile("tree.txt", `{}`), wantErr: true},
+		{desc: "missingFile", file: filepath.Join(dir, "missing.json"), wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer flagsaver.Save().MustRestore()
+			*treeFile = tc.file
+			for name, value := range tc.flags {
+				if err := flag.Set(name, value); err != nil {
+					t.Fatalf("flag.Set(%q): %v", name, err)
+				}
+			}
+
+			req, err := newRequest()
+			if hasErr := err != nil; hasErr != tc.wantErr {
+				t.Fatalf("newRequest() = (_, %v), wantErr = %v", err, tc.wantErr)
+			} else if hasErr {
+				return
+			}
+			if !proto.Equal(req.Tree, tc.wantTree) {
+				t.Errorf("newRequest().Tree = %v, want %v", req.Tree, tc.wantTree)
+			}
+		})
+	}
+}
+
+func TestFormatTree(t *testing.T) {
+	defer flagsaver.Save().MustRestore()
+	tree := &trillian.Tree{TreeId: 12345, TreeType: trillian.TreeType_LOG}
+
+	if got, err := formatTree(tree); err != nil || got != "12345" {
+		t.Errorf("formatTree() = (%q, %v), want (%q, nil)", got, err, "12345")
+	}
+
+	*outputFormat = "json"
+	got, err := formatTree(tree)
+	if err != nil {
+		t.Fatalf("formatTree() returned err = %v", err)
+	}
+	parsed := &trillian.Tree{}
+	if err := protojson.Unmarshal([]byte(got), parsed); err != nil {
+		t.Fatalf("protojson.Unmarshal(%q): %v", got, err)
+	}
+	if !proto.Equal(parsed, tree) {
+		t.Errorf("formatTree() = %v, want %v", parsed, tree)
+	}
+
+	*outputFormat = "llamas"
+	if _, err := formatTree(tree); err == nil {
+		t.Error("formatTree() with unknown format returned err = nil")
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// manifestFile is the name of the manifest in the directory of an export.
+const manifestFile = "manifest.json"
+
+// manifest describes an export of the leaves [Begin, End) of a log, with the
+// hashes needed to verify it offline against LogRoot.
+type manifest struct {
+	TreeID int64 `json:"tree_id"`
+	// LogRoot is the TLS encoded types.LogRootV1 the leaves were exported
+	// under, as served in the SignedLogRoot of the log.
+	LogRoot []byte `json:"log_root"`
+	Begin   uint64 `json:"begin"`
+	End     uint64 `json:"end"`
+	// RangeHashes is the compact range of the leaves [Begin, End).
+	RangeHashes [][]byte `json:"range_hashes"`
+	// LeftHashes and RightHashes are the compact ranges of the leaves
+	// [0, Begin) and [End, TreeSize) of LogRoot.
+	LeftHashes  [][]byte `json:"left_hashes"`
+	RightHashes [][]byte `json:"right_hashes"`
+	// Batches are the files holding the leaves, in order.
+	Batches []batchFile `json:"batches"`
+}
+
+// batchFile is a file of an export, holding the JSON array of the leaves
+// [Begin, End).
+type batchFile struct {
+	Name   string `json:"name"`
+	Begin  uint64 `json:"begin"`
+	End    uint64 `json:"end"`
+	SHA256 []byte `json:"sha256"`
+}
+
+// exportedLeaf is a leaf in a batch file.
+type exportedLeaf struct {
+	LeafIndex uint64 `json:"leaf_index"`
+	LeafValue []byte `json:"leaf_value"`
+	ExtraData []byte `json:"extra_data,omitempty"`
+}
+
+// exporter writes ranges of leaves of a log to files.
+type exporter struct {
+	client trillian.TrillianLogClient
+	treeID int64
+	// batchSize is the maximum number of leaves per file, and per
+	// GetLeavesByRange call.
+	batchSize int64
+	// dir is the directory the files are written to.
+	dir string
+}
+
+var rf = &compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+
+// export writes the leaves [begin, end) of the latest root of the log to files,
+// or up to its tree size if end is zero, and returns the manifest it wrote
+// along with them.
+func (e *exporter) export(ctx context.Context, begin, end uint64) (*manifest, error) {
+	resp, err := e.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: e.treeID})
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestSignedLogRoot: %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(resp.GetSignedLogRoot().GetLogRoot()); err != nil {
+		return nil, fmt.Errorf("failed to parse log root: %v", err)
+	}
+	if end == 0 {
+		end = root.TreeSize
+	}
+	if begin >= end || end > root.TreeSize {
+		return nil, fmt.Errorf("invalid range [%d, %d) of tree of size %d", begin, end, root.TreeSize)
+	}
+
+	left, err := e.compactRange(ctx, 0, begin, root.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.compactRange(ctx, end, root.TreeSize, root.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	m := &manifest{
+		TreeID:      e.treeID,
+		LogRoot:     resp.SignedLogRoot.LogRoot,
+		Begin:       begin,
+		End:         end,
+		LeftHashes:  left,
+		RightHashes: right,
+	}
+	cr := rf.NewEmptyRange(begin)
+	for cr.End() < end {
+		b, err := e.exportBatch(ctx, cr, end)
+		if err != nil {
+			return nil, err
+		}
+		m.Batches = append(m.Batches, *b)
+	}
+	m.RangeHashes = cr.Hashes()
+	if err := checkRange(&root, left, cr, right); err != nil {
+		return nil, err
+	}
+	if err := e.saveManifest(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// compactRange returns the hashes of the compact range [begin, end) of the tree
+// of the given size.
+func (e *exporter) compactRange(ctx context.Context, begin, end, size uint64) ([][]byte, error) {
+	if begin == end {
+		return nil, nil
+	}
+	resp, err := e.client.GetCompactRange(ctx, &trillian.GetCompactRangeRequest{
+		LogId:    e.treeID,
+		Begin:    int64(begin),
+		End:      int64(end),
+		TreeSize: int64(size),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetCompactRange(%d, %d): %v", begin, end, err)
+	}
+	if len(resp.Hashes) == 0 {
+		return nil, fmt.Errorf("GetCompactRange(%d, %d): no hashes returned for tree size %d", begin, end, size)
+	}
+	return resp.Hashes, nil
+}
+
+// exportBatch fetches the next batch of leaves below end, appends them to cr,
+// and writes them to a file.
+func (e *exporter) exportBatch(ctx context.Context, cr *compact.Range, end uint64) (*batchFile, error) {
+	start := cr.End()
+	if end-start > uint64(e.batchSize) {
+		end = start + uint64(e.batchSize)
+	}
+	leaves := make([]exportedLeaf, 0, end-start)
+	for cr.End() < end {
+		next := cr.End()
+		resp, err := e.client.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{
+			LogId:      e.treeID,
+			StartIndex: int64(next),
+			Count:      int64(end - next),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetLeavesByRange(%d, %d): %v", next, end-next, err)
+		}
+		if len(resp.Leaves) == 0 {
+			return nil, fmt.Errorf("GetLeavesByRange(%d, %d): no leaves returned", next, end-next)
+		}
+		if uint64(len(resp.Leaves)) > end-next {
+			return nil, fmt.Errorf("GetLeavesByRange(%d, %d): got %d leaves", next, end-next, len(resp.Leaves))
+		}
+		for i, leaf := range resp.Leaves {
+			if want := int64(next) + int64(i); leaf.LeafIndex != want {
+				return nil, fmt.Errorf("Leaves[%d].LeafIndex=%d, want %d", i, leaf.LeafIndex, want)
+			}
+			hash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue)
+			if !bytes.Equal(hash, leaf.MerkleLeafHash) {
+				return nil, fmt.Errorf("leaf %d: value hashes to %x, served Merkle leaf hash is %x", leaf.LeafIndex, hash, leaf.MerkleLeafHash)
+			}
+			if err := cr.Append(hash, nil); err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, exportedLeaf{LeafIndex: uint64(leaf.LeafIndex), LeafValue: leaf.LeafValue, ExtraData: leaf.ExtraData})
+		}
+	}
+
+	data, err := json.Marshal(leaves)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("leaves-%d-%d.json", start, end)
+	if err := os.WriteFile(filepath.Join(e.dir, name), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write leaves: %v", err)
+	}
+	digest := sha256.Sum256(data)
+	return &batchFile{Name: name, Begin: start, End: end, SHA256: digest[:]}, nil
+}
+
+// saveManifest atomically replaces the manifest file of the export with m, so
+// that an export is only complete once the manifest exists.
+func (e *exporter) saveManifest(m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(e.dir, manifestFile+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save manifest: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save manifest: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save manifest: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(e.dir, manifestFile)); err != nil {
+		return fmt.Errorf("failed to save manifest: %v", err)
+	}
+	return nil
+}
+
+// verifyExport verifies the export in dir offline, and returns its manifest
+// and log root if its files hold exactly the leaves of the manifest's range of
+// its log root. The log root itself is only as trustworthy as the manifest;
+// checkTrustedRoot compares it with one known to be published by the log.
+func verifyExport(dir string) (*manifest, *types.LogRootV1, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(m.LogRoot); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse log root: %v", err)
+	}
+	if m.Begin >= m.End || m.End > root.TreeSize {
+		return nil, nil, fmt.Errorf("invalid range [%d, %d) of tree of size %d", m.Begin, m.End, root.TreeSize)
+	}
+
+	cr := rf.NewEmptyRange(m.Begin)
+	for _, b := range m.Batches {
+		if b.Begin != cr.End() || b.End <= b.Begin {
+			return nil, nil, fmt.Errorf("batch %q covers [%d, %d), want it to start at %d", b.Name, b.Begin, b.End, cr.End())
+		}
+		if b.Name != filepath.Base(b.Name) {
+			return nil, nil, fmt.Errorf("batch %q is not in the export directory", b.Name)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, b.Name))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read leaves: %v", err)
+		}
+		if digest := sha256.Sum256(data); !bytes.Equal(digest[:], b.SHA256) {
+			return nil, nil, fmt.Errorf("batch %q has SHA-256 digest %x, manifest has %x", b.Name, digest, b.SHA256)
+		}
+		var leaves []exportedLeaf
+		if err := json.Unmarshal(data, &leaves); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse batch %q: %v", b.Name, err)
+		}
+		if got, want := uint64(len(leaves)), b.End-b.Begin; got != want {
+			return nil, nil, fmt.Errorf("batch %q has %d leaves, want %d", b.Name, got, want)
+		}
+		for _, leaf := range leaves {
+			if leaf.LeafIndex != cr.End() {
+				return nil, nil, fmt.Errorf("batch %q has leaf index %d, want %d", b.Name, leaf.LeafIndex, cr.End())
+			}
+			if err := cr.Append(rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue), nil); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if cr.End() != m.End {
+		return nil, nil, fmt.Errorf("batches cover leaves up to %d, want %d", cr.End(), m.End)
+	}
+	if !equalHashes(cr.Hashes(), m.RangeHashes) {
+		return nil, nil, fmt.Errorf("leaves [%d, %d) don't match the compact range of the manifest", m.Begin, m.End)
+	}
+	if err := checkRange(&root, m.LeftHashes, cr, m.RightHashes); err != nil {
+		return nil, nil, err
+	}
+	return &m, &root, nil
+}
+
+// checkRange checks that cr, between the compact ranges left of the leaves
+// [0, cr.Begin()) and right of the leaves [cr.End(), root.TreeSize), rebuilds
+// the root hash of root.
+func checkRange(root *types.LogRootV1, left [][]byte, cr *compact.Range, right [][]byte) error {
+	// The range takes ownership of its hashes, and merges nodes into them.
+	r, err := rf.NewRange(0, cr.Begin(), append([][]byte(nil), left...))
+	if err != nil {
+		return fmt.Errorf("invalid compact range [0, %d): %v", cr.Begin(), err)
+	}
+	if err := r.AppendRange(cr, nil); err != nil {
+		return err
+	}
+	rightRange, err := rf.NewRange(cr.End(), root.TreeSize, right)
+	if err != nil {
+		return fmt.Errorf("invalid compact range [%d, %d): %v", cr.End(), root.TreeSize, err)
+	}
+	if err := r.AppendRange(rightRange, nil); err != nil {
+		return err
+	}
+	hash, err := r.GetRootHash(nil)
+	if err != nil {
+		return fmt.Errorf("failed to compute root hash: %v", err)
+	}
+	if !bytes.Equal(hash, root.RootHash) {
+		return fmt.Errorf("leaves [%d, %d) rebuild root hash %x at size %d, log root has %x", cr.Begin(), cr.End(), hash, root.TreeSize, root.RootHash)
+	}
+	return nil
+}
+
+// checkTrustedRoot checks that root is the trusted root of the given size and
+// root hash, e.g. one taken from a checkpoint published by the log.
+func checkTrustedRoot(root *types.LogRootV1, size uint64, hash []byte) error {
+	if root.TreeSize != size {
+		return fmt.Errorf("export is under a log root of size %d, want trusted size %d", root.TreeSize, size)
+	}
+	if !bytes.Equal(root.RootHash, hash) {
+		return fmt.Errorf("export is under a log root with root hash %x, want trusted root hash %x", root.RootHash, hash)
+	}
+	return nil
+}
+
+func equalHashes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
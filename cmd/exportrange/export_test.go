@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// newFakeLog returns a log of the given size, which serves at most 3 leaves
+// per GetLeavesByRange call.
+func newFakeLog(size int) *testonly.FakeLog {
+	l := testonly.NewFakeLog(size)
+	l.MaxCount = 3
+	return l
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		begin, end  uint64
+		wantEnd     uint64
+		wantBatches int
+	}{
+		{begin: 0, end: 0, wantEnd: 21, wantBatches: 5},
+		{begin: 0, end: 1, wantEnd: 1, wantBatches: 1},
+		{begin: 20, end: 21, wantEnd: 21, wantBatches: 1},
+		{begin: 3, end: 13, wantEnd: 13, wantBatches: 2},
+		{begin: 7, end: 0, wantEnd: 21, wantBatches: 3},
+	} {
+		t.Run(fmt.Sprintf("range:%d-%d", tc.begin, tc.end), func(t *testing.T) {
+			dir := t.TempDir()
+			e := &exporter{client: newFakeLog(21).Client(), treeID: 1, batchSize: 5, dir: dir}
+			m, err := e.export(ctx, tc.begin, tc.end)
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if m.End != tc.wantEnd || len(m.Batches) != tc.wantBatches {
+				t.Errorf("export: got range end %d with %d batches, want %d with %d", m.End, len(m.Batches), tc.wantEnd, tc.wantBatches)
+			}
+			got, root, err := verifyExport(dir)
+			if err != nil {
+				t.Fatalf("verifyExport: %v", err)
+			}
+			if got.Begin != tc.begin || got.End != tc.wantEnd {
+				t.Errorf("verifyExport: got range [%d, %d), want [%d, %d)", got.Begin, got.End, tc.begin, tc.wantEnd)
+			}
+			if root.TreeSize != 21 {
+				t.Errorf("verifyExport: got root of size %d, want 21", root.TreeSize)
+			}
+		})
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc       string
+		begin, end uint64
+		tamper     func(*testonly.FakeLog)
+	}{
+		{desc: "empty-range", begin: 4, end: 4},
+		{desc: "beyond-tree", begin: 4, end: 11},
+		{desc: "leaf-value", end: 10, tamper: func(l *testonly.FakeLog) {
+			l.Leaves[4] = &trillian.LogLeaf{LeafValue: []byte("other"), MerkleLeafHash: l.Leaves[4].MerkleLeafHash, LeafIndex: 4}
+		}},
+		{desc: "leaf-index", end: 10, tamper: func(l *testonly.FakeLog) {
+			l.Leaves[4] = &trillian.LogLeaf{LeafValue: l.Leaves[4].LeafValue, MerkleLeafHash: l.Leaves[4].MerkleLeafHash, LeafIndex: 5}
+		}},
+		{desc: "root", begin: 2, end: 5, tamper: func(l *testonly.FakeLog) {
+			value := []byte("other")
+			l.Leaves[3] = &trillian.LogLeaf{LeafValue: value, MerkleLeafHash: rfc6962.DefaultHasher.HashLeaf(value), LeafIndex: 3}
+		}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			l := newFakeLog(10)
+			if tc.tamper != nil {
+				tc.tamper(l)
+			}
+			dir := t.TempDir()
+			e := &exporter{client: l.Client(), treeID: 1, batchSize: 5, dir: dir}
+			if _, err := e.export(ctx, tc.begin, tc.end); err == nil {
+				t.Fatal("export: got nil error, want error")
+			}
+			if _, err := os.Stat(filepath.Join(dir, manifestFile)); err == nil {
+				t.Error("export: wrote manifest of failed export")
+			}
+		})
+	}
+}
+
+func TestVerifyExportDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc   string
+		tamper func(t *testing.T, dir string, m *manifest)
+	}{
+		{desc: "batch-file", tamper: func(t *testing.T, dir string, m *manifest) {
+			writeFile(t, filepath.Join(dir, m.Batches[1].Name), []byte("[]"))
+		}},
+		{desc: "batch-leaf", tamper: func(t *testing.T, dir string, m *manifest) {
+			data := []byte(`[{"leaf_index":5,"leaf_value":"b3RoZXI="}]`)
+			writeFile(t, filepath.Join(dir, m.Batches[1].Name), data)
+			digest := sha256.Sum256(data)
+			m.Batches[1].SHA256 = digest[:]
+			m.Batches[1].End = 6
+			m.Batches = m.Batches[:2]
+			m.End = 6
+		}},
+		{desc: "missing-batch", tamper: func(t *testing.T, dir string, m *manifest) {
+			m.Batches = m.Batches[:1]
+		}},
+		{desc: "batch-outside-dir", tamper: func(t *testing.T, dir string, m *manifest) {
+			m.Batches[0].Name = "../" + m.Batches[0].Name
+		}},
+		{desc: "left-hashes", tamper: func(t *testing.T, dir string, m *manifest) {
+			m.LeftHashes[0] = m.RangeHashes[0]
+		}},
+		{desc: "range-hashes", tamper: func(t *testing.T, dir string, m *manifest) {
+			m.RangeHashes = m.RightHashes
+		}},
+		{desc: "log-root", tamper: func(t *testing.T, dir string, m *manifest) {
+			root, err := (&types.LogRootV1{TreeSize: 13, RootHash: m.RangeHashes[0]}).MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			m.LogRoot = root
+		}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			e := &exporter{client: newFakeLog(13).Client(), treeID: 1, batchSize: 4, dir: dir}
+			m, err := e.export(ctx, 1, 11)
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			tc.tamper(t, dir, m)
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			writeFile(t, filepath.Join(dir, manifestFile), data)
+			if _, _, err := verifyExport(dir); err == nil {
+				t.Error("verifyExport: got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestCheckTrustedRoot(t *testing.T) {
+	root := &types.LogRootV1{TreeSize: 13, RootHash: []byte("root")}
+	for _, tc := range []struct {
+		desc    string
+		size    uint64
+		hash    []byte
+		wantErr bool
+	}{
+		{desc: "trusted", size: 13, hash: []byte("root")},
+		{desc: "other-size", size: 12, hash: []byte("root"), wantErr: true},
+		{desc: "other-hash", size: 13, hash: []byte("fake"), wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := checkTrustedRoot(root, tc.size, tc.hash); (err != nil) != tc.wantErr {
+				t.Errorf("checkTrustedRoot: got error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
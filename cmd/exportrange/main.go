@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// exportrange command, which exports a range of leaves of a log to files that
+// can be verified offline.
+//
+// Example usage:
+// $ ./exportrange --log_server=host:port --tree_id=123456789 --begin=1000 --end=2000 --output_dir=/tmp/export
+// $ ./exportrange --verify --trusted_size=2000 --trusted_root_hash=<hex> --output_dir=/tmp/export
+//
+// The leaves [begin, end) are exported under the latest root of the log, in
+// files of up to --batch_size leaves each. The leaf values are checked against
+// their Merkle leaf hashes as RFC 6962 specifies, and the command fails if the
+// exported leaves don't rebuild the root.
+//
+// The output directory also gets a manifest.json file, holding the log root,
+// the compact range hashes of the exported leaves, and those of the leaves
+// [0, begin) and [end, tree size), as returned by GetCompactRange. With the
+// SHA-256 digest of each file, the manifest lets anyone given the files
+// verify with --verify that they hold exactly the leaves [begin, end) of the
+// log root, without contacting the log. Anyone can make up a manifest and
+// files consistent with each other, so recipients must also check that the
+// log root is one the log has published, by passing its size and root hash as
+// --trusted_size and --trusted_root_hash, or by comparing the printed root.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+)
+
+var (
+	logServerAddr = flag.String("log_server", "", "Address of the gRPC Trillian Log Server (host:port)")
+	treeID        = flag.Int64("tree_id", 0, "The ID of the tree to export leaves of")
+	begin         = flag.Uint64("begin", 0, "Index of the first leaf to export")
+	end           = flag.Uint64("end", 0, "Index after the last leaf to export. If zero, leaves are exported up to the latest tree size")
+	batchSize     = flag.Int64("batch_size", 1000, "Max number of leaves per exported file, and per GetLeavesByRange call")
+	outputDir     = flag.String("output_dir", "", "Directory to export leaves to, or to verify an export in with --verify")
+	verify        = flag.Bool("verify", false, "If true, verify the export in --output_dir offline instead of exporting")
+	trustedSize   = flag.Uint64("trusted_size", 0, "With --verify, the tree size of a log root known to be published by the log, which the export must be under")
+	trustedHash   = flag.String("trusted_root_hash", "", "With --verify, the hex-encoded root hash of the log root of --trusted_size")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+)
+
+func run(ctx context.Context) (string, error) {
+	if *outputDir == "" {
+		return "", errors.New("empty --output_dir, please provide the directory of the export")
+	}
+	if *verify {
+		m, root, err := verifyExport(*outputDir)
+		if err != nil {
+			return "", err
+		}
+		out := fmt.Sprintf("Verified leaves [%d, %d) of tree %d under log root of size %d with root hash %x", m.Begin, m.End, m.TreeID, root.TreeSize, root.RootHash)
+		if *trustedHash == "" {
+			if *trustedSize != 0 {
+				return "", errors.New("--trusted_size needs --trusted_root_hash")
+			}
+			return out + "\nThe log root was not checked against a trusted one, see --trusted_root_hash", nil
+		}
+		hash, err := hex.DecodeString(*trustedHash)
+		if err != nil {
+			return "", fmt.Errorf("invalid --trusted_root_hash: %v", err)
+		}
+		if err := checkTrustedRoot(root, *trustedSize, hash); err != nil {
+			return "", err
+		}
+		return out + ", which is the trusted root", nil
+	}
+
+	if *logServerAddr == "" {
+		return "", errors.New("empty --log_server, please provide the Log server host:port")
+	}
+	if *treeID == 0 {
+		return "", errors.New("empty --tree_id, please provide the ID of the tree to export")
+	}
+	if *batchSize <= 0 {
+		return "", fmt.Errorf("--batch_size must be positive, got %d", *batchSize)
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	conn, err := grpc.Dial(*logServerAddr, dialOpts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to dial %v: %v", *logServerAddr, err)
+	}
+	defer conn.Close()
+
+	e := &exporter{
+		client:    trillian.NewTrillianLogClient(conn),
+		treeID:    *treeID,
+		batchSize: *batchSize,
+		dir:       *outputDir,
+	}
+	m, err := e.export(ctx, *begin, *end)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Exported leaves [%d, %d) of tree %d in %d files", m.Begin, m.End, m.TreeID, len(m.Batches)), nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.AwaitSignal(ctx, cancel)
+
+	out, err := run(ctx)
+	if err != nil {
+		glog.Exitf("Failed to export range: %v", err)
+	}
+	fmt.Println(out)
+}
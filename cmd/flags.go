@@ -47,7 +47,13 @@ func parseFlags(file string) error {
 // path. Re-calls flag.Parse() after parsing the flags in the file
 // so that flags provided on the command line take precedence over
 // flags provided in the file.
+//
+// Files with a .yaml or .yml extension hold a mapping of flag names to
+// values instead, e.g. "batch_size: 1000".
 func ParseFlagFile(path string) error {
+	if isYAML(path) {
+		return parseYAMLFile(path)
+	}
 	file, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
@@ -0,0 +1,62 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package provider registers the storage providers which are linked into the
+// Trillian binaries, so that they can be selected with --storage_system.
+//
+// All the storage implementations of this repository are included by default.
+// Each of them can be left out of a build with a build tag, which also drops
+// its dependencies from the binaries:
+//
+//	trillian_no_mysql          MySQL / MariaDB
+//	trillian_no_cloudspanner   Cloud Spanner
+//	trillian_no_cloudbigtable  Cloud Bigtable
+//
+// For example:
+//
+//	go build -tags trillian_no_cloudspanner,trillian_no_cloudbigtable ./cmd/trillian_log_server
+//
+// Storage implementations from outside of this repository register themselves
+// with storage.RegisterProvider when their package is imported. They are added
+// to a binary by a file in its main package which imports them for side
+// effects, e.g. cmd/trillian_log_server/mystorage.go:
+//
+//	package main
+//
+//	import _ "example.com/mystorage"
+//
+// and are then selected by their registered name like any other provider.
+package provider
+
+import "github.com/google/trillian/storage"
+
+// defaultStorageSystem is the storage provider used unless another one is
+// selected, if it is linked in.
+const defaultStorageSystem = "mysql"
+
+// DefaultStorageSystem returns the name of the storage provider to use as the
+// default value of the --storage_system flags. This is MySQL, or the first
+// registered provider if MySQL was left out of the build.
+func DefaultStorageSystem() string {
+	providers := storage.Providers()
+	for _, p := range providers {
+		if p == defaultStorageSystem {
+			return p
+		}
+	}
+	if len(providers) > 0 {
+		return providers[0]
+	}
+	return ""
+}
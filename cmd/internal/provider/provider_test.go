@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/storage"
+)
+
+func TestProvidersRegistered(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, p := range storage.Providers() {
+		registered[p] = true
+	}
+	// The test binary is built without any of the trillian_no_* tags.
+	for _, want := range []string{"mysql", "cloud_spanner", "cloud_bigtable"} {
+		if !registered[want] {
+			t.Errorf("storage provider %q not registered, got %v", want, storage.Providers())
+		}
+	}
+}
+
+func TestDefaultStorageSystem(t *testing.T) {
+	if got, want := DefaultStorageSystem(), "mysql"; got != want {
+		t.Errorf("DefaultStorageSystem() = %q, want %q", got, want)
+	}
+}
+
+// TestBuildWithoutMySQL checks that the servers build with the
+// trillian_no_mysql tag, and that it leaves out everything which depends on
+// the MySQL storage.
+func TestBuildWithoutMySQL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping builds in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("go command not found: %v", err)
+	}
+	for _, pkg := range []string{
+		"github.com/google/trillian/cmd/trillian_log_server",
+		"github.com/google/trillian/cmd/trillian_log_signer",
+	} {
+		if out, err := exec.Command(goBin, "build", "-tags", "trillian_no_mysql", "-o", os.DevNull, pkg).CombinedOutput(); err != nil {
+			t.Errorf("go build -tags trillian_no_mysql %s: %v\n%s", pkg, err, out)
+			continue
+		}
+		out, err := exec.Command(goBin, "list", "-deps", "-tags", "trillian_no_mysql", pkg).Output()
+		if err != nil {
+			t.Fatalf("go list -deps -tags trillian_no_mysql %s: %v", pkg, err)
+		}
+		for _, dep := range strings.Fields(string(out)) {
+			if strings.HasPrefix(dep, "github.com/google/trillian/storage/mysql") ||
+				dep == "github.com/google/trillian/quota/mysqlqm" ||
+				dep == "github.com/go-sql-driver/mysql" {
+				t.Errorf("%s built with trillian_no_mysql depends on %s", pkg, dep)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverutil
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util/clock"
+)
+
+// DefaultTLSReloadInterval is the suggested interval between checks of the TLS
+// certificate and key files for changes.
+const DefaultTLSReloadInterval = time.Minute
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// certReloader serves the certificate of a pair of certificate and key files,
+// and loads them again when they change, so that rotated certificates are
+// served without restarting the server. The files are checked for changes
+// during handshakes, at most once per interval.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	timeSource        clock.TimeSource
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	stamps  [2]fileStamp
+	checked time.Time
+}
+
+// newCertReloader returns a certReloader of the given files, which must hold a
+// valid key pair.
+func newCertReloader(certFile, keyFile string, interval time.Duration, ts clock.TimeSource) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both a TLS certificate and key file must be set")
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval, timeSource: ts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = ts.Now()
+	return r, nil
+}
+
+// load reads the key pair, and replaces the served certificate if it's valid.
+// r.mu must be held, unless r isn't shared yet.
+func (r *certReloader) load() error {
+	var stamps [2]fileStamp
+	for i, path := range []string{r.certFile, r.keyFile} {
+		s, err := statFile(path)
+		if err != nil {
+			return err
+		}
+		stamps[i] = s
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair from %q and %q: %v", r.certFile, r.keyFile, err)
+	}
+	r.cert = &cert
+	r.stamps = stamps
+	return nil
+}
+
+// changed returns whether either file differs from the loaded version.
+func (r *certReloader) changed() bool {
+	for i, path := range []string{r.certFile, r.keyFile} {
+		s, err := statFile(path)
+		if err != nil {
+			// The file may be in the middle of being replaced, keep the old one.
+			glog.Warningf("Failed to check TLS file for changes: %v", err)
+			return false
+		}
+		if s != r.stamps[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCertificate returns the current certificate, having first reloaded it if
+// the files changed. It's meant to be used as tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := r.timeSource.Now(); now.Sub(r.checked) >= r.interval {
+		r.checked = now
+		if r.changed() {
+			// On failure, e.g. when only one of the files was replaced so far,
+			// keep serving the old certificate and retry at the next check.
+			if err := r.load(); err != nil {
+				glog.Warningf("Keeping the previous TLS certificate: %v", err)
+			} else {
+				glog.Infof("Reloaded TLS certificate from %q", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
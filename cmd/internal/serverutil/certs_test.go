@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+)
+
+// writeKeyPair writes a new self-signed certificate for name and its key to
+// the given files, with the given modification time, and returns the DER
+// encoding of the certificate.
+func writeKeyPair(t *testing.T, certFile, keyFile, name string, modTime time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() = %v", err)
+	}
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("WriteFile() = %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes() = %v", err)
+		}
+	}
+	return der
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	base := time.Now().Truncate(time.Second)
+	ts := clock.NewFake(base)
+
+	if _, err := newCertReloader(certFile, "", time.Minute, ts); err == nil {
+		t.Error("newCertReloader() without key file succeeded, want error")
+	}
+	if _, err := newCertReloader(certFile, keyFile, time.Minute, ts); err == nil {
+		t.Error("newCertReloader() of missing files succeeded, want error")
+	}
+
+	first := writeKeyPair(t, certFile, keyFile, "first", base.Add(-time.Hour))
+	r, err := newCertReloader(certFile, keyFile, time.Minute, ts)
+	if err != nil {
+		t.Fatalf("newCertReloader() = %v", err)
+	}
+
+	check := func(desc string, want []byte) {
+		t.Helper()
+		cert, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("%s: GetCertificate() = %v", desc, err)
+		}
+		if got := cert.Certificate[0]; string(got) != string(want) {
+			t.Errorf("%s: GetCertificate() returned the wrong certificate", desc)
+		}
+	}
+	check("initial", first)
+
+	second := writeKeyPair(t, certFile, keyFile, "second", base)
+	check("before interval", first)
+	ts.Set(base.Add(time.Minute))
+	check("after interval", second)
+
+	// A certificate replaced without its key is ignored until both match.
+	ts.Set(base.Add(2 * time.Minute))
+	writeKeyPair(t, certFile, keyFile, "third", base.Add(time.Minute))
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	check("invalid key", second)
+	ts.Set(base.Add(3 * time.Minute))
+	third := writeKeyPair(t, certFile, keyFile, "third", base.Add(2*time.Minute))
+	check("fixed key", third)
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverutil
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+var (
+	keepaliveTime          = flag.Duration("rpc_keepalive_time", 0, "Time after which the server pings idle RPC connections to check they are alive, zero means the gRPC default of 2h")
+	keepaliveTimeout       = flag.Duration("rpc_keepalive_timeout", 0, "Time the server waits for a ping response before closing an RPC connection, zero means the gRPC default of 20s")
+	keepaliveMinTime       = flag.Duration("rpc_keepalive_min_time", DefaultKeepaliveMinTime, "Minimum interval between the keepalive pings of clients, which are disconnected if they ping more often")
+	keepaliveWithoutStream = flag.Bool("rpc_keepalive_permit_without_stream", true, "If true, clients may send keepalive pings on connections without RPCs in progress, which keeps idle connections open through load balancers")
+	maxConnectionIdle      = flag.Duration("rpc_max_connection_idle", 0, "Time after which idle RPC connections are closed gracefully, zero means infinity")
+	maxConnectionAge       = flag.Duration("rpc_max_connection_age", 0, "Time after which RPC connections are closed gracefully, e.g. to rebalance clients across servers, zero means infinity")
+	maxConnectionAgeGrace  = flag.Duration("rpc_max_connection_age_grace", 0, "Time given to the RPCs in progress to complete after --rpc_max_connection_age, after which the connection is closed forcibly, zero means infinity")
+	maxConcurrentStreams   = flag.Uint("rpc_max_concurrent_streams", 0, "Maximum number of concurrent RPCs on each connection, zero means unlimited")
+)
+
+// DefaultKeepaliveMinTime is the default minimum interval between the
+// keepalive pings of clients. It's shorter than the gRPC default of 5m, so
+// that clients can keep connections open through load balancers which drop
+// them after a few idle minutes.
+const DefaultKeepaliveMinTime = time.Minute
+
+// Keepalive configures the keepalive pings and the connection management of
+// the gRPC servers. Zero values use the gRPC defaults.
+type Keepalive struct {
+	// Server holds the keepalive pings sent by the server, and the limits of
+	// the age of connections.
+	Server keepalive.ServerParameters
+	// Enforcement is the policy applied to the keepalive pings of clients.
+	Enforcement keepalive.EnforcementPolicy
+	// MaxConcurrentStreams is the maximum number of concurrent RPCs on each
+	// connection.
+	MaxConcurrentStreams uint32
+}
+
+// KeepaliveFromFlags returns the Keepalive configured by the --rpc_keepalive_*,
+// --rpc_max_connection_* and --rpc_max_concurrent_streams flags.
+func KeepaliveFromFlags() (Keepalive, error) {
+	if *maxConcurrentStreams > 1<<32-1 {
+		return Keepalive{}, fmt.Errorf("--rpc_max_concurrent_streams too large: %d", *maxConcurrentStreams)
+	}
+	return Keepalive{
+		Server: keepalive.ServerParameters{
+			Time:                  *keepaliveTime,
+			Timeout:               *keepaliveTimeout,
+			MaxConnectionIdle:     *maxConnectionIdle,
+			MaxConnectionAge:      *maxConnectionAge,
+			MaxConnectionAgeGrace: *maxConnectionAgeGrace,
+		},
+		Enforcement: keepalive.EnforcementPolicy{
+			MinTime:             *keepaliveMinTime,
+			PermitWithoutStream: *keepaliveWithoutStream,
+		},
+		MaxConcurrentStreams: uint32(*maxConcurrentStreams),
+	}, nil
+}
+
+// serverOptions returns the gRPC server options applying k.
+func (k Keepalive) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if k.Server != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(k.Server))
+	}
+	if k.Enforcement != (keepalive.EnforcementPolicy{}) {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(k.Enforcement))
+	}
+	if k.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(k.MaxConcurrentStreams))
+	}
+	return opts
+}
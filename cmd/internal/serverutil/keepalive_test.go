@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverutil
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/keepalive"
+
+	// Linked in so that flags clashing with the client ones, which would
+	// panic at init in binaries linking both, fail this test.
+	_ "github.com/google/trillian/client/rpcflags"
+)
+
+func TestKeepaliveFromFlags(t *testing.T) {
+	k, err := KeepaliveFromFlags()
+	if err != nil {
+		t.Fatalf("KeepaliveFromFlags(): %v", err)
+	}
+	want := Keepalive{Enforcement: keepalive.EnforcementPolicy{MinTime: DefaultKeepaliveMinTime, PermitWithoutStream: true}}
+	if diff := cmp.Diff(want, k); diff != "" {
+		t.Errorf("KeepaliveFromFlags() with default flags diff (-want +got):\n%s", diff)
+	}
+	if got := len(k.serverOptions()); got != 1 {
+		t.Errorf("serverOptions() with default flags returned %d options, want 1", got)
+	}
+
+	flags := map[string]string{
+		"rpc_keepalive_time":                  "10m",
+		"rpc_keepalive_timeout":               "30s",
+		"rpc_keepalive_min_time":              "30s",
+		"rpc_keepalive_permit_without_stream": "false",
+		"rpc_max_connection_idle":             "1h",
+		"rpc_max_connection_age":              "2h",
+		"rpc_max_connection_age_grace":        "1m",
+		"rpc_max_concurrent_streams":          "100",
+	}
+	for name, value := range flags {
+		f := flag.Lookup(name)
+		defer f.Value.Set(f.DefValue)
+		if err := f.Value.Set(value); err != nil {
+			t.Fatalf("Set(%q, %q): %v", name, value, err)
+		}
+	}
+	k, err = KeepaliveFromFlags()
+	if err != nil {
+		t.Fatalf("KeepaliveFromFlags(): %v", err)
+	}
+	want = Keepalive{
+		Server: keepalive.ServerParameters{
+			Time:                  10 * time.Minute,
+			Timeout:               30 * time.Second,
+			MaxConnectionIdle:     time.Hour,
+			MaxConnectionAge:      2 * time.Hour,
+			MaxConnectionAgeGrace: time.Minute,
+		},
+		Enforcement:          keepalive.EnforcementPolicy{MinTime: 30 * time.Second},
+		MaxConcurrentStreams: 100,
+	}
+	if diff := cmp.Diff(want, k); diff != "" {
+		t.Errorf("KeepaliveFromFlags() diff (-want +got):\n%s", diff)
+	}
+	if got := len(k.serverOptions()); got != 3 {
+		t.Errorf("serverOptions() returned %d options, want 3", got)
+	}
+	if got := len(Keepalive{}.serverOptions()); got != 0 {
+		t.Errorf("serverOptions() of zero Keepalive returned %d options, want 0", got)
+	}
+}
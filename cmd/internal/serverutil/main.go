@@ -17,16 +17,21 @@ package serverutil
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/quota"
 	"github.com/google/trillian/server/admin"
 	"github.com/google/trillian/server/interceptor"
 	"github.com/google/trillian/util/clock"
@@ -34,8 +39,12 @@ import (
 	"go.etcd.io/etcd/client/v3/naming/endpoints"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -55,26 +64,59 @@ const (
 
 // Main encapsulates the data and logic to start a Trillian server (Log or Map).
 type Main struct {
-	// Endpoints for RPC and HTTP servers.
+	// Endpoints for RPC and HTTP servers, see Endpoints for the format.
 	// HTTP is optional, if empty it'll not be bound.
 	RPCEndpoint, HTTPEndpoint string
+	// AdminRPCEndpoint, if set, holds the endpoints the Admin Server is bound
+	// to instead of RPCEndpoint, e.g. to only expose it on localhost.
+	AdminRPCEndpoint string
 
 	// TLS Certificate and Key files for the server.
 	TLSCertFile, TLSKeyFile string
+	// TLSReloadInterval is the interval at which the TLS files are checked for
+	// changes, which are then served without a restart. Zero means
+	// DefaultTLSReloadInterval.
+	TLSReloadInterval time.Duration
+
+	// Keepalive configures the keepalive pings and connection management of
+	// the gRPC servers, see KeepaliveFromFlags.
+	Keepalive Keepalive
 
 	DBClose func() error
+	// OnShutdown, if set, is called once the servers have stopped and before
+	// DBClose, to let background work which uses the storage finish.
+	OnShutdown func()
 
 	Registry extension.Registry
 
 	StatsPrefix string
 	QuotaDryRun bool
+	// QuotaDryRunGroups are the quota groups whose lack of tokens doesn't block requests, if
+	// QuotaDryRun is false.
+	QuotaDryRunGroups []quota.Group
+	// QuotaByteCosts are the byte costs of the quota specs charged by size.
+	QuotaByteCosts []quota.ByteCost
+
+	// DefaultRPCDeadline is the deadline set on unary RPCs received without one, and
+	// MaxRPCDeadline the longest deadline accepted from clients. Zero disables either limit.
+	DefaultRPCDeadline, MaxRPCDeadline time.Duration
+
+	// ReadOnly makes the server reject the RPCs which write to storage, and disables tree GC.
+	ReadOnly bool
+
+	// Authenticator, if set, authenticates RPCs with bearer tokens, before quota is charged.
+	Authenticator *interceptor.Authenticator
+
+	// RequestLimiter, if set, rejects oversized requests to logs, before quota is charged.
+	RequestLimiter *interceptor.RequestLimiter
 
 	// RegisterServerFn is called to register RPC servers.
 	RegisterServerFn func(*grpc.Server, extension.Registry) error
 
-	// IsHealthy will be called whenever "/healthz" is called on the mux.
+	// IsHealthy will be called whenever "/healthz" is called on the mux, or
+	// the grpc.health.v1.Health service is checked.
 	// A nil return value from this function will result in a 200-OK response
-	// on the /healthz endpoint.
+	// on the /healthz endpoint, and a SERVING status.
 	IsHealthy func(context.Context) error
 	// HealthyDeadline is the maximum duration to wait wait for a successful
 	// IsHealthy() call.
@@ -105,6 +147,29 @@ func (m *Main) healthz(rw http.ResponseWriter, req *http.Request) {
 	rw.Write([]byte("ok"))
 }
 
+// healthServer implements the gRPC health checking protocol with Main.IsHealthy, for the
+// server as a whole. Watch isn't implemented.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	m *Main
+}
+
+func (h healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service != "" {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	resp := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}
+	if h.m.IsHealthy != nil {
+		ctx, cancel := context.WithTimeout(ctx, h.m.HealthyDeadline)
+		defer cancel()
+		if err := h.m.IsHealthy(ctx); err != nil {
+			glog.Warningf("Health check failed: %v", err)
+			resp.Status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return resp, nil
+}
+
 // Run starts the configured server. Blocks until the server exits.
 func (m *Main) Run(ctx context.Context) error {
 	glog.CopyStandardLogTo("WARNING")
@@ -113,77 +178,96 @@ func (m *Main) Run(ctx context.Context) error {
 		m.HealthyDeadline = 5 * time.Second
 	}
 
-	srv, err := m.newGRPCServer()
+	tlsConfig, err := m.newTLSConfig()
+	if err != nil {
+		glog.Exitf("Error loading TLS certificate: %v", err)
+	}
+	serverOpts, err := m.newGRPCServerOptions(tlsConfig)
 	if err != nil {
 		glog.Exitf("Error creating gRPC server: %v", err)
 	}
+	srv := grpc.NewServer(serverOpts...)
 	defer srv.GracefulStop()
 
-	defer m.DBClose()
+	defer func() {
+		if m.OnShutdown != nil {
+			m.OnShutdown()
+		}
+		m.DBClose()
+	}()
 
 	if err := m.RegisterServerFn(srv, m.Registry); err != nil {
 		return err
 	}
-	trillian.RegisterTrillianAdminServer(srv, admin.New(m.Registry, m.AllowedTreeTypes))
+	adminSrv := srv
+	if m.AdminRPCEndpoint != "" {
+		adminSrv = grpc.NewServer(serverOpts...)
+		defer adminSrv.GracefulStop()
+		reflection.Register(adminSrv)
+	}
+	trillian.RegisterTrillianAdminServer(adminSrv, admin.New(m.Registry, m.AllowedTreeTypes))
+	healthpb.RegisterHealthServer(srv, healthServer{m: m})
 	reflection.Register(srv)
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	if endpoint := m.HTTPEndpoint; endpoint != "" {
+	if m.HTTPEndpoint != "" {
 		http.Handle("/metrics", promhttp.Handler())
 		http.HandleFunc("/healthz", m.healthz)
 
-		s := &http.Server{
-			Addr: endpoint,
-		}
-
-		run := func() error {
-			glog.Infof("HTTP server starting on %v", endpoint)
-
-			var err error
-			// Let http.ListenAndServeTLS handle the error case when only one of the flags is set.
-			if m.TLSCertFile != "" || m.TLSKeyFile != "" {
-				err = s.ListenAndServeTLS(m.TLSCertFile, m.TLSKeyFile)
-			} else {
-				err = s.ListenAndServe()
+		s := &http.Server{TLSConfig: tlsConfig}
+		for _, endpoint := range Endpoints(m.HTTPEndpoint) {
+			lis, err := Listen(endpoint)
+			if err != nil {
+				return err
 			}
+			endpoint := endpoint
 
-			if err != nil {
-				if errors.Is(err, http.ErrServerClosed) {
-					return nil
+			run := func() error {
+				glog.Infof("HTTP server starting on %v", endpoint)
+
+				var err error
+				if tlsConfig != nil {
+					// The certificate is served by tlsConfig.GetCertificate.
+					err = s.ServeTLS(lis, "", "")
+				} else {
+					err = s.Serve(lis)
 				}
 
-				err = fmt.Errorf("HTTP server stopped: %v", err)
-			}
+				if err != nil {
+					if errors.Is(err, http.ErrServerClosed) {
+						return nil
+					}
 
-			return err
-		}
+					err = fmt.Errorf("HTTP server stopped: %v", err)
+				}
 
-		shutdown := func() {
-			glog.Infof("Stopping HTTP server...")
-			glog.Flush()
+				return err
+			}
+
+			shutdown := func() {
+				glog.Infof("Stopping HTTP server...")
+				glog.Flush()
 
-			// 15 second exit time limit
-			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-			defer cancel()
+				// 15 second exit time limit
+				ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+				defer cancel()
 
-			if err := s.Shutdown(ctx); err != nil {
-				glog.Errorf("Failed to http server shutdown: %v", err)
+				if err := s.Shutdown(ctx); err != nil {
+					glog.Errorf("Failed to http server shutdown: %v", err)
+				}
 			}
-		}
 
-		g.Go(func() error {
-			return srvRun(ctx, run, shutdown)
-		})
+			g.Go(func() error {
+				return srvRun(ctx, run, shutdown)
+			})
+		}
 	}
 
-	glog.Infof("RPC server starting on %v", m.RPCEndpoint)
-	lis, err := net.Listen("tcp", m.RPCEndpoint)
-	if err != nil {
-		return err
+	if m.TreeGCEnabled && m.ReadOnly {
+		glog.Info("Deleted tree GC disabled in read-only mode")
 	}
-
-	if m.TreeGCEnabled {
+	if m.TreeGCEnabled && !m.ReadOnly {
 		g.Go(func() error {
 			glog.Info("Deleted tree GC started")
 			gc := admin.NewDeletedTreeGC(
@@ -196,25 +280,15 @@ func (m *Main) Run(ctx context.Context) error {
 		})
 	}
 
-	run := func() error {
-		if err := srv.Serve(lis); err != nil {
-			return fmt.Errorf("RPC server terminated: %v", err)
-		}
-
-		return nil
+	if err := serveRPC(ctx, g, "RPC", srv, m.RPCEndpoint); err != nil {
+		return err
 	}
-
-	shutdown := func() {
-		glog.Infof("Stopping RPC server...")
-		glog.Flush()
-
-		srv.GracefulStop()
+	if adminSrv != srv {
+		if err := serveRPC(ctx, g, "Admin RPC", adminSrv, m.AdminRPCEndpoint); err != nil {
+			return err
+		}
 	}
 
-	g.Go(func() error {
-		return srvRun(ctx, run, shutdown)
-	})
-
 	// wait for all jobs to exit gracefully
 	err = g.Wait()
 
@@ -224,42 +298,167 @@ func (m *Main) Run(ctx context.Context) error {
 	return err
 }
 
-// newGRPCServer starts a new Trillian gRPC server.
-func (m *Main) newGRPCServer() (*grpc.Server, error) {
-	stats := monitoring.NewRPCStatsInterceptor(clock.System, m.StatsPrefix, m.Registry.MetricFactory)
-	ti := interceptor.New(m.Registry.AdminStorage, m.Registry.QuotaManager, m.QuotaDryRun, m.Registry.MetricFactory)
+// serveRPC runs srv on each of the given endpoints in g.
+func serveRPC(ctx context.Context, g *errgroup.Group, name string, srv *grpc.Server, endpoints string) error {
+	for _, endpoint := range Endpoints(endpoints) {
+		glog.Infof("%s server starting on %v", name, endpoint)
+		lis, err := Listen(endpoint)
+		if err != nil {
+			return err
+		}
+
+		run := func() error {
+			if err := srv.Serve(lis); err != nil {
+				return fmt.Errorf("%s server terminated: %v", name, err)
+			}
+
+			return nil
+		}
+
+		shutdown := func() {
+			glog.Infof("Stopping %s server...", name)
+			glog.Flush()
+
+			srv.GracefulStop()
+		}
+
+		g.Go(func() error {
+			return srvRun(ctx, run, shutdown)
+		})
+	}
+	return nil
+}
+
+// Endpoints splits a comma-separated list of endpoints. Each endpoint is either
+// a TCP host:port, or a Unix domain socket path prefixed by "unix:" or
+// "unix://", in the same format that gRPC clients dial.
+func Endpoints(endpoints string) []string {
+	var ret []string
+	for _, e := range strings.Split(endpoints, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// unixSocketPath returns the socket path of a Unix domain socket endpoint, or
+// false if the endpoint is a TCP one.
+func unixSocketPath(endpoint string) (string, bool) {
+	if !strings.HasPrefix(endpoint, "unix:") {
+		return "", false
+	}
+	path := strings.TrimPrefix(endpoint, "unix:")
+	return strings.TrimPrefix(path, "//"), true
+}
+
+// Listen listens on a single endpoint, as described in Endpoints. A stale
+// socket file, e.g. left by a previous process, is replaced. A socket which
+// accepts connections is left to the process serving it.
+func Listen(endpoint string) (net.Listener, error) {
+	path, ok := unixSocketPath(endpoint)
+	if !ok {
+		return net.Listen("tcp", endpoint)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %q is in use by another process", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("failed to check whether socket %q is stale: %v", path, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %q: %v", path, err)
+		}
+	}
+	return net.Listen("unix", path)
+}
 
+// newGRPCServerOptions returns the options of the Trillian gRPC servers.
+func (m *Main) newGRPCServerOptions(tlsConfig *tls.Config) ([]grpc.ServerOption, error) {
+	if m.MaxRPCDeadline > 0 && m.DefaultRPCDeadline > m.MaxRPCDeadline {
+		return nil, fmt.Errorf("default RPC deadline %v exceeds the maximum RPC deadline %v", m.DefaultRPCDeadline, m.MaxRPCDeadline)
+	}
+	stats := monitoring.NewRPCStatsInterceptor(clock.System, m.StatsPrefix, m.Registry.MetricFactory)
+	ti := interceptor.New(m.Registry.AdminStorage, m.Registry.QuotaManager, m.QuotaDryRun, m.Registry.MetricFactory,
+		interceptor.QuotaDryRunGroups(m.QuotaDryRunGroups...), interceptor.QuotaByteCosts(m.QuotaByteCosts...))
+
+	unary := []grpc.UnaryServerInterceptor{
+		interceptor.RequestID,
+		stats.Interceptor(),
+		interceptor.ErrorWrapper,
+		interceptor.Deadline(m.DefaultRPCDeadline, m.MaxRPCDeadline),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		interceptor.StreamRequestID,
+		interceptor.StreamErrorWrapper,
+	}
+	if m.Authenticator != nil {
+		unary = append(unary, m.Authenticator.UnaryInterceptor)
+		stream = append(stream, m.Authenticator.StreamInterceptor)
+	}
+	if m.ReadOnly {
+		// Reject writes before the TrillianInterceptor charges quota for them.
+		unary = append(unary, interceptor.ReadOnly)
+		stream = append(stream, interceptor.StreamReadOnly)
+	}
+	if m.RequestLimiter != nil {
+		unary = append(unary, m.RequestLimiter.UnaryInterceptor)
+		stream = append(stream, m.RequestLimiter.StreamInterceptor)
+	}
 	serverOpts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			stats.Interceptor(),
-			interceptor.ErrorWrapper,
-			ti.UnaryInterceptor,
-		)),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(append(unary, ti.UnaryInterceptor)...)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(append(stream, ti.StreamInterceptor)...)),
 	}
+	serverOpts = append(serverOpts, m.Keepalive.serverOptions()...)
 	serverOpts = append(serverOpts, m.ExtraOptions...)
 
-	// Let credentials.NewServerTLSFromFile handle the error case when only one of the flags is set.
-	if m.TLSCertFile != "" || m.TLSKeyFile != "" {
-		serverCreds, err := credentials.NewServerTLSFromFile(m.TLSCertFile, m.TLSKeyFile)
-		if err != nil {
-			return nil, err
-		}
-		serverOpts = append(serverOpts, grpc.Creds(serverCreds))
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
 
-	s := grpc.NewServer(serverOpts...)
+	return serverOpts, nil
+}
 
-	return s, nil
+// newTLSConfig returns the TLS configuration shared by the gRPC and HTTP
+// servers, or nil if TLS isn't configured. The certificate is reloaded when
+// its files change, so that it can be rotated without dropping in-flight work.
+func (m *Main) newTLSConfig() (*tls.Config, error) {
+	if m.TLSCertFile == "" && m.TLSKeyFile == "" {
+		return nil, nil
+	}
+	interval := m.TLSReloadInterval
+	if interval == 0 {
+		interval = DefaultTLSReloadInterval
+	}
+	r, err := newCertReloader(m.TLSCertFile, m.TLSKeyFile, interval, clock.System)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{GetCertificate: r.GetCertificate}, nil
 }
 
 // AnnounceSelf announces this binary's presence to etcd. This calls the cancel
 // function if the keepalive lease with etcd expires.  Returns a function that
 // should be called on process exit.
 // AnnounceSelf does nothing if client is nil.
+// The endpoint may be a list of endpoints as described in Endpoints, of which
+// all TCP endpoints are announced.
 func AnnounceSelf(ctx context.Context, client *clientv3.Client, etcdService, endpoint string, cancel func()) func() {
 	if client == nil {
 		return func() {}
 	}
+	var announced []string
+	for _, e := range Endpoints(endpoint) {
+		if _, ok := unixSocketPath(e); !ok {
+			announced = append(announced, e)
+		}
+	}
+	if len(announced) == 0 {
+		return func() {}
+	}
 
 	// Get a lease so our entry self-destructs.
 	leaseRsp, err := client.Grant(ctx, 30)
@@ -277,15 +476,18 @@ func AnnounceSelf(ctx context.Context, client *clientv3.Client, etcdService, end
 	if err != nil {
 		glog.Exitf("Failed to create etcd manager: %v", err)
 	}
-	fullEndpoint := fmt.Sprintf("%s/%s", etcdService, endpoint)
-	em.AddEndpoint(ctx, fullEndpoint, endpoints.Endpoint{Addr: endpoint})
+	for _, e := range announced {
+		em.AddEndpoint(ctx, fmt.Sprintf("%s/%s", etcdService, e), endpoints.Endpoint{Addr: e})
+	}
 	glog.Infof("Announcing our presence in %v", etcdService)
 
 	return func() {
 		// Use a background context because the original context may have been cancelled.
 		glog.Infof("Removing our presence in %v", etcdService)
 		ctx := context.Background()
-		em.DeleteEndpoint(ctx, fullEndpoint)
+		for _, e := range announced {
+			em.DeleteEndpoint(ctx, fmt.Sprintf("%s/%s", etcdService, e))
+		}
 		client.Revoke(ctx, leaseRsp.ID)
 	}
 }
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/extension"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestEndpoints(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{in: "", want: nil},
+		{in: "localhost:8090", want: []string{"localhost:8090"}},
+		{in: "localhost:8090, unix:///tmp/trillian.sock,", want: []string{"localhost:8090", "unix:///tmp/trillian.sock"}},
+	} {
+		if diff := cmp.Diff(Endpoints(tc.in), tc.want); diff != "" {
+			t.Errorf("Endpoints(%q) diff (-got +want):\n%s", tc.in, diff)
+		}
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trillian.sock")
+	for _, endpoint := range []string{"unix://" + path, "unix:" + path} {
+		lis, err := Listen(endpoint)
+		if err != nil {
+			t.Fatalf("Listen(%q): %v", endpoint, err)
+		}
+		if got := lis.Addr().Network(); got != "unix" {
+			t.Errorf("Listen(%q): network %q, want unix", endpoint, got)
+		}
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatalf("Dial(%q): %v", path, err)
+		}
+		conn.Close()
+		// Leave the socket file behind, as a crashed process would, so that
+		// the second iteration has to replace it.
+		lis.(*net.UnixListener).SetUnlinkOnClose(false)
+		lis.Close()
+	}
+}
+
+func TestListenUnixInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trillian.sock")
+	lis, err := Listen("unix://" + path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer lis.Close()
+	if _, err := Listen("unix://" + path); err == nil {
+		t.Fatal("Listen on a socket in use: got nil error, want error")
+	}
+	// The socket of the first listener must still work.
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial(%q): %v", path, err)
+	}
+	conn.Close()
+}
+
+func TestListenTCP(t *testing.T) {
+	lis, err := Listen("localhost:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer lis.Close()
+	if got := lis.Addr().Network(); got != "tcp" {
+		t.Errorf("Listen: network %q, want tcp", got)
+	}
+}
+
+func TestHealthServer(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc      string
+		isHealthy func(context.Context) error
+		service   string
+		want      healthpb.HealthCheckResponse_ServingStatus
+		wantCode  codes.Code
+	}{
+		{desc: "no check", want: healthpb.HealthCheckResponse_SERVING},
+		{desc: "healthy", isHealthy: func(context.Context) error { return nil }, want: healthpb.HealthCheckResponse_SERVING},
+		{desc: "unhealthy", isHealthy: func(context.Context) error { return errors.New("stale root") }, want: healthpb.HealthCheckResponse_NOT_SERVING},
+		{desc: "unknown service", service: "trillian.TrillianMap", wantCode: codes.NotFound},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := healthServer{m: &Main{IsHealthy: tc.isHealthy, HealthyDeadline: time.Second}}
+			resp, err := h.Check(ctx, &healthpb.HealthCheckRequest{Service: tc.service})
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("Check() = %v, want code %v", err, tc.wantCode)
+			}
+			if got := resp.GetStatus(); err == nil && got != tc.want {
+				t.Errorf("Check() status = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunShutdownBeforeDBClose(t *testing.T) {
+	var calls []string
+	m := &Main{
+		RPCEndpoint:      "localhost:0",
+		RegisterServerFn: func(*grpc.Server, extension.Registry) error { return nil },
+		OnShutdown:       func() { calls = append(calls, "OnShutdown") },
+		DBClose: func() error {
+			calls = append(calls, "DBClose")
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The server may be stopped before it starts serving, which is reported
+	// as an error, and doesn't matter here.
+	_ = m.Run(ctx)
+	if diff := cmp.Diff(calls, []string{"OnShutdown", "DBClose"}); diff != "" {
+		t.Errorf("Run() calls diff (-got +want):\n%s", diff)
+	}
+}
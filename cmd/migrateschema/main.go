@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// migrateschema command, which manages the version of the schema of a MySQL
+// database.
+//
+// Example usage:
+// $ ./migrateschema --mysql_uri=${DB_URI} up
+//
+// Commands:
+//   up        applies migrations, up to --version or the latest version
+//   down      reverts migrations, down to --version or the previous version
+//   verify    checks that the schema is at the latest version
+//   version   prints the version of the schema
+//   force     marks the schema as cleanly at --version, without changing it
+//   partition partitions the leaf tables into --leaf_partitions partitions by tree
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/storage/mysql/schema"
+)
+
+var (
+	version        = flag.Int("version", -1, "The schema version to migrate to, or to mark the schema as being at with force")
+	leafPartitions = flag.Int("leaf_partitions", 0, "The number of partitions to partition the leaf tables into by tree ID with partition, which drops the foreign keys of these tables")
+)
+
+func run(ctx context.Context, command string) error {
+	db, err := mysql.GetDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		target := *version
+		if target < 0 {
+			target = schema.LatestVersion()
+		}
+		return schema.Migrate(ctx, db, target)
+	case "down":
+		target := *version
+		if target < 0 {
+			current, _, err := schema.Version(ctx, db)
+			if err != nil {
+				return err
+			}
+			target = current - 1
+		}
+		return schema.Migrate(ctx, db, target)
+	case "verify":
+		return schema.Check(ctx, db)
+	case "version":
+		v, dirty, err := schema.Version(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d (dirty: %v)\n", v, dirty)
+		return nil
+	case "force":
+		if *version < 0 {
+			return errors.New("force requires --version")
+		}
+		return schema.Force(ctx, db, *version)
+	case "partition":
+		if *leafPartitions <= 0 {
+			return errors.New("partition requires --leaf_partitions")
+		}
+		return schema.PartitionLeafTables(ctx, db, *leafPartitions)
+	}
+	return fmt.Errorf("unknown command %q, want one of up, down, verify, version, force or partition", command)
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if flag.NArg() != 1 {
+		glog.Exitf("Usage: %s [flags] up|down|verify|version|force|partition", flag.CommandLine.Name())
+	}
+	if err := run(context.Background(), flag.Arg(0)); err != nil {
+		glog.Exitf("Failed to %s schema: %v", flag.Arg(0), err)
+	}
+	glog.Infof("Schema %s succeeded, latest version is %d", flag.Arg(0), schema.LatestVersion())
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the migratetree
+// command, which copies a live log tree from one storage system to another.
+//
+// Example usage:
+// $ ./migratetree --source_storage_system=mysql --dest_storage_system=cloud_spanner --tree_id=123456789 --cutover
+//
+// The source tree keeps serving while its leaves are copied. Copying runs in
+// passes, each pass catching up with the leaves integrated into the source
+// tree since the previous one and verifying that both trees have the same
+// root hash. With --cutover, the source tree is then drained and frozen, the
+// final delta is copied and verified, and the destination tree is switched to
+// the original type and state of the source tree.
+//
+// An interrupted migration can be resumed by passing the ID of the
+// destination tree in --dest_tree_id.
+//
+// Storage systems are configured using their usual flags, so the source and
+// destination must be different storage systems.
+//
+// The command outputs the ID of the destination tree to stdout.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/cmd/internal/provider"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/encrypted"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/clock"
+
+	// Register supported leaf blob stores.
+	_ "github.com/google/trillian/storage/blob/azblob"
+	_ "github.com/google/trillian/storage/blob/gcs"
+	_ "github.com/google/trillian/storage/blob/s3"
+
+	// Register supported leaf encryption KMSs.
+	_ "github.com/google/trillian/crypto/envelope/awskms"
+	_ "github.com/google/trillian/crypto/envelope/gcpkms"
+)
+
+var (
+	srcStorageSystem = flag.String("source_storage_system", provider.DefaultStorageSystem(), fmt.Sprintf("Storage system the tree is migrated from. One of: %v", storage.Providers()))
+	dstStorageSystem = flag.String("dest_storage_system", "cloud_spanner", fmt.Sprintf("Storage system the tree is migrated to. One of: %v", storage.Providers()))
+	treeID           = flag.Int64("tree_id", 0, "The ID of the tree to migrate")
+	dstTreeID        = flag.Int64("dest_tree_id", 0, "If set, the ID of the destination tree of a previously started migration to resume")
+	batchSize        = flag.Int("batch_size", 1000, "Max number of leaves to copy per batch")
+	catchUpInterval  = flag.Duration("catch_up_interval", 10*time.Second, "Time between catch-up passes")
+	catchUpThreshold = flag.Uint64("catch_up_threshold", 1000, "Number of leaves the destination tree may lag behind the source tree for copying to be considered caught up")
+	cutover          = flag.Bool("cutover", false, "If true, freeze the source tree and switch over to the destination tree once caught up")
+	pollInterval     = flag.Duration("drain_poll_interval", time.Second, "Time between checks of the source tree size while it is draining")
+	quietPeriod      = flag.Duration("drain_quiet_period", 30*time.Second, "How long the size of the draining source tree must stay unchanged before it is frozen")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+)
+
+func newBackend(name string, mf monitoring.MetricFactory) (backend, func() error, error) {
+	sp, err := storage.NewProvider(name, mf)
+	if err != nil {
+		return backend{}, nil, fmt.Errorf("failed to get storage provider %q: %v", name, err)
+	}
+	// Leaves are decrypted from the source tree and encrypted again for the
+	// destination tree, as ciphertexts are bound to their tree.
+	ls, closeEncryption, err := encrypted.NewLogStorageFromFlags(sp.LogStorage())
+	if err != nil {
+		sp.Close()
+		return backend{}, nil, fmt.Errorf("failed to set up leaf encryption: %v", err)
+	}
+	closeAll := func() error {
+		closeEncryption()
+		return sp.Close()
+	}
+	return backend{admin: sp.AdminStorage(), log: ls}, closeAll, nil
+}
+
+func migrate(ctx context.Context) (int64, error) {
+	if *treeID == 0 {
+		return 0, errors.New("empty --tree_id, please provide the ID of the tree to migrate")
+	}
+
+	mf := monitoring.InertMetricFactory{}
+	log.InitMetrics(mf)
+	src, closeSrc, err := newBackend(*srcStorageSystem, mf)
+	if err != nil {
+		return 0, err
+	}
+	defer closeSrc()
+	dst, closeDst, err := newBackend(*dstStorageSystem, mf)
+	if err != nil {
+		return 0, err
+	}
+	defer closeDst()
+
+	m := &migrator{src: src, dst: dst, batchSize: *batchSize, ts: clock.System}
+	mig, err := m.prepare(ctx, *treeID, *dstTreeID)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		root, err := m.catchUp(ctx, mig)
+		if err != nil {
+			return mig.dstTree.TreeId, err
+		}
+		glog.Infof("Destination tree %d verified at size %d, root hash %x", mig.dstTree.TreeId, root.TreeSize, root.RootHash)
+
+		latest, err := latestRoot(ctx, src.log, mig.srcTree)
+		if err != nil {
+			return mig.dstTree.TreeId, err
+		}
+		if lag := latest.TreeSize - root.TreeSize; lag <= *catchUpThreshold {
+			break
+		}
+		if err := clock.SleepContext(ctx, *catchUpInterval); err != nil {
+			return mig.dstTree.TreeId, err
+		}
+	}
+
+	if *cutover {
+		if _, err := m.cutover(ctx, mig, *pollInterval, *quietPeriod); err != nil {
+			return mig.dstTree.TreeId, err
+		}
+	}
+	return mig.dstTree.TreeId, nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.AwaitSignal(ctx, cancel)
+
+	id, err := migrate(ctx)
+	if err != nil {
+		if id != 0 {
+			glog.Errorf("Migration can be resumed with --dest_tree_id=%d", id)
+		}
+		glog.Exitf("Failed to migrate tree: %v", err)
+	}
+	fmt.Println(id)
+}
@@ -0,0 +1,363 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// backend groups the storage interfaces of one side of a migration.
+type backend struct {
+	admin storage.AdminStorage
+	log   storage.LogStorage
+}
+
+// migrator copies a log tree from one storage backend to another.
+//
+// The destination tree is created as a PREORDERED_LOG, so that leaves can be
+// written at the exact indices they have in the source tree and integrated
+// with the regular sequencing code. This reproduces the source Merkle tree
+// node for node. At cutover the destination tree is switched to the type and
+// state of the source tree.
+type migrator struct {
+	src, dst  backend
+	batchSize int
+	ts        clock.TimeSource
+}
+
+// migration is the state of a single tree migration.
+type migration struct {
+	srcTree *trillian.Tree
+	dstTree *trillian.Tree
+	// srcState is the state of the source tree before migration started, it is
+	// applied to the destination tree at cutover.
+	srcState trillian.TreeState
+}
+
+// prepare loads the source tree and either creates the destination tree, or
+// loads it if dstTreeID is non-zero (i.e. the migration is being resumed).
+func (m *migrator) prepare(ctx context.Context, srcTreeID, dstTreeID int64) (*migration, error) {
+	srcTree, err := storage.GetTree(ctx, m.src.admin, srcTreeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source tree %d: %v", srcTreeID, err)
+	}
+	switch srcTree.TreeType {
+	case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+	default:
+		return nil, fmt.Errorf("tree %d has unsupported type %v", srcTreeID, srcTree.TreeType)
+	}
+	if srcTree.Deleted {
+		return nil, fmt.Errorf("tree %d is deleted", srcTreeID)
+	}
+	mig := &migration{srcTree: srcTree, srcState: srcTree.TreeState}
+
+	if dstTreeID != 0 {
+		if mig.dstTree, err = storage.GetTree(ctx, m.dst.admin, dstTreeID); err != nil {
+			return nil, fmt.Errorf("failed to get destination tree %d: %v", dstTreeID, err)
+		}
+		if got, want := mig.dstTree.TreeType, trillian.TreeType_PREORDERED_LOG; got != want {
+			return nil, fmt.Errorf("destination tree %d has type %v, want %v", dstTreeID, got, want)
+		}
+		glog.Infof("Resuming migration of tree %d to tree %d", srcTreeID, dstTreeID)
+		return mig, nil
+	}
+
+	tree := proto.Clone(srcTree).(*trillian.Tree)
+	tree.TreeId = 0
+	tree.TreeType = trillian.TreeType_PREORDERED_LOG
+	tree.TreeState = trillian.TreeState_ACTIVE
+	tree.CreateTime = nil
+	tree.UpdateTime = nil
+	// Storage settings are specific to the source backend.
+	tree.StorageSettings = nil
+	if mig.dstTree, err = storage.CreateTree(ctx, m.dst.admin, tree); err != nil {
+		return nil, fmt.Errorf("failed to create destination tree: %v", err)
+	}
+	if err := m.initDestination(ctx, mig.dstTree); err != nil {
+		return nil, err
+	}
+	glog.Infof("Created destination tree %d for tree %d", mig.dstTree.TreeId, srcTreeID)
+	return mig, nil
+}
+
+// initDestination stores the empty root for a freshly created tree, as InitLog
+// would do.
+func (m *migrator) initDestination(ctx context.Context, tree *trillian.Tree) error {
+	return m.dst.log.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if _, err := tx.LatestSignedLogRoot(ctx); err != nil && err != storage.ErrTreeNeedsInit {
+			return err
+		}
+		logRoot, err := (&types.LogRootV1{
+			RootHash:       rfc6962.DefaultHasher.EmptyRoot(),
+			TimestampNanos: uint64(m.ts.Now().UnixNano()),
+		}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: logRoot})
+	})
+}
+
+// latestRoot returns the latest log root of tree in the given storage.
+func latestRoot(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree) (*types.LogRootV1, error) {
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, err
+	}
+	return &root, tx.Commit(ctx)
+}
+
+// leavesByRange reads leaves [start, start+count) from the given storage.
+func leavesByRange(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree, start, count int64) ([]*trillian.LogLeaf, error) {
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	leaves, err := tx.GetLeavesByRange(ctx, start, count)
+	if err != nil {
+		return nil, err
+	}
+	return leaves, tx.Commit(ctx)
+}
+
+// catchUp copies all leaves integrated into the source tree, but not yet into
+// the destination tree, and integrates them. It returns the source root which
+// the destination tree has been verified to match.
+func (m *migrator) catchUp(ctx context.Context, mig *migration) (*types.LogRootV1, error) {
+	srcRoot, err := latestRoot(ctx, m.src.log, mig.srcTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source root: %v", err)
+	}
+	dstRoot, err := latestRoot(ctx, m.dst.log, mig.dstTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination root: %v", err)
+	}
+	if dstRoot.TreeSize > srcRoot.TreeSize {
+		return nil, fmt.Errorf("destination tree size %d is ahead of source tree size %d", dstRoot.TreeSize, srcRoot.TreeSize)
+	}
+
+	for next := dstRoot.TreeSize; next < srcRoot.TreeSize; {
+		count := srcRoot.TreeSize - next
+		if count > uint64(m.batchSize) {
+			count = uint64(m.batchSize)
+		}
+		leaves, err := leavesByRange(ctx, m.src.log, mig.srcTree, int64(next), int64(count))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source leaves [%d, %d): %v", next, next+count, err)
+		}
+		if len(leaves) == 0 {
+			return nil, fmt.Errorf("source returned no leaves at index %d", next)
+		}
+		conflicts, err := m.copyLeaves(ctx, mig, leaves)
+		if err != nil {
+			return nil, err
+		}
+		next += uint64(len(leaves))
+		if err := m.integrate(ctx, mig, next); err != nil {
+			return nil, err
+		}
+		// Leaves already present may not have been integrated before, so
+		// they're compared once they are.
+		for leaf, copyErr := range conflicts {
+			if err := m.checkCopied(ctx, mig, leaf); err != nil {
+				return nil, fmt.Errorf("failed to copy leaf %d: %v: %v", leaf.LeafIndex, copyErr, err)
+			}
+		}
+	}
+
+	dstRoot, err = latestRoot(ctx, m.dst.log, mig.dstTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination root: %v", err)
+	}
+	if err := verifyRoots(srcRoot, dstRoot); err != nil {
+		return nil, err
+	}
+	return srcRoot, nil
+}
+
+// copyLeaves writes the leaves into the destination tree at their indices. It
+// returns the leaves which conflict with those already present, e.g. because a
+// previous run of the migration was interrupted, mapped to the error they got.
+// These must be checked with checkCopied once integrated.
+func (m *migrator) copyLeaves(ctx context.Context, mig *migration, leaves []*trillian.LogLeaf) (map[*trillian.LogLeaf]error, error) {
+	res, err := m.dst.log.AddSequencedLeaves(ctx, mig.dstTree, leaves, m.ts.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to add leaves to destination: %v", err)
+	}
+	conflicts := make(map[*trillian.LogLeaf]error)
+	for i, r := range res {
+		if codes.Code(r.GetStatus().GetCode()) != codes.OK {
+			conflicts[leaves[i]] = status.FromProto(r.Status).Err()
+		}
+	}
+	return conflicts, nil
+}
+
+// checkCopied returns nil iff the destination tree contains leaf, with the
+// same contents, at its index.
+func (m *migrator) checkCopied(ctx context.Context, mig *migration, leaf *trillian.LogLeaf) error {
+	stored, err := leavesByRange(ctx, m.dst.log, mig.dstTree, leaf.LeafIndex, 1)
+	if err != nil {
+		return err
+	}
+	if len(stored) != 1 || !sameLeaf(stored[0], leaf) {
+		return fmt.Errorf("destination has a different leaf at index %d", leaf.LeafIndex)
+	}
+	return nil
+}
+
+// sameLeaf returns whether a and b have the same hashes and contents.
+func sameLeaf(a, b *trillian.LogLeaf) bool {
+	return bytes.Equal(a.MerkleLeafHash, b.MerkleLeafHash) &&
+		bytes.Equal(a.LeafIdentityHash, b.LeafIdentityHash) &&
+		bytes.Equal(a.LeafValue, b.LeafValue) &&
+		bytes.Equal(a.ExtraData, b.ExtraData)
+}
+
+// integrate sequences the copied leaves in the destination tree until it
+// reaches the given size.
+func (m *migrator) integrate(ctx context.Context, mig *migration, size uint64) error {
+	for {
+		root, err := latestRoot(ctx, m.dst.log, mig.dstTree)
+		if err != nil {
+			return fmt.Errorf("failed to read destination root: %v", err)
+		}
+		if root.TreeSize >= size {
+			return nil
+		}
+		n, err := log.IntegrateBatch(ctx, mig.dstTree, m.batchSize, 0, 0, m.ts, m.dst.log, quota.Noop())
+		if err != nil {
+			return fmt.Errorf("failed to integrate destination leaves: %v", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("destination tree stuck at size %d, want %d", root.TreeSize, size)
+		}
+	}
+}
+
+// verifyRoots checks that the destination root commits to the same tree as
+// the source root.
+func verifyRoots(src, dst *types.LogRootV1) error {
+	if src.TreeSize != dst.TreeSize {
+		return fmt.Errorf("tree size mismatch: source %d, destination %d", src.TreeSize, dst.TreeSize)
+	}
+	if !bytes.Equal(src.RootHash, dst.RootHash) {
+		return fmt.Errorf("root hash mismatch at size %d: source %x, destination %x", src.TreeSize, src.RootHash, dst.RootHash)
+	}
+	return nil
+}
+
+// cutover stops writes to the source tree, waits for its queue to drain,
+// performs a final catch-up and verification, and then makes the destination
+// tree the live copy. quietPeriod is how long the source tree size must stay
+// unchanged while DRAINING before it is considered drained.
+func (m *migrator) cutover(ctx context.Context, mig *migration, pollInterval, quietPeriod time.Duration) (*types.LogRootV1, error) {
+	if mig.srcTree.TreeState != trillian.TreeState_FROZEN {
+		if err := m.setSourceState(ctx, mig, trillian.TreeState_DRAINING); err != nil {
+			return nil, err
+		}
+		if err := m.awaitDrained(ctx, mig, pollInterval, quietPeriod); err != nil {
+			return nil, err
+		}
+		if err := m.setSourceState(ctx, mig, trillian.TreeState_FROZEN); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := m.catchUp(ctx, mig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tree type changes are only permitted while the tree is FROZEN.
+	updates := []func(*trillian.Tree){
+		func(t *trillian.Tree) { t.TreeState = trillian.TreeState_FROZEN },
+		func(t *trillian.Tree) { t.TreeType = mig.srcTree.TreeType },
+		func(t *trillian.Tree) { t.TreeState = liveState(mig.srcState) },
+	}
+	for _, update := range updates {
+		if mig.dstTree, err = storage.UpdateTree(ctx, m.dst.admin, mig.dstTree.TreeId, update); err != nil {
+			return nil, fmt.Errorf("failed to update destination tree: %v", err)
+		}
+	}
+	glog.Infof("Cutover complete: tree %d is now served by tree %d at size %d", mig.srcTree.TreeId, mig.dstTree.TreeId, root.TreeSize)
+	return root, nil
+}
+
+// liveState returns the state the destination tree should have after
+// cutover: the source tree's original state, except that a draining tree is
+// drained by then.
+func liveState(s trillian.TreeState) trillian.TreeState {
+	if s == trillian.TreeState_DRAINING {
+		return trillian.TreeState_FROZEN
+	}
+	return s
+}
+
+func (m *migrator) setSourceState(ctx context.Context, mig *migration, state trillian.TreeState) error {
+	tree, err := storage.UpdateTree(ctx, m.src.admin, mig.srcTree.TreeId, func(t *trillian.Tree) { t.TreeState = state })
+	if err != nil {
+		return fmt.Errorf("failed to set source tree state to %v: %v", state, err)
+	}
+	mig.srcTree = tree
+	glog.Infof("Source tree %d is now %v", tree.TreeId, state)
+	return nil
+}
+
+// awaitDrained waits until the source tree size hasn't changed for quietPeriod.
+func (m *migrator) awaitDrained(ctx context.Context, mig *migration, pollInterval, quietPeriod time.Duration) error {
+	var size uint64
+	var since time.Time
+	for {
+		root, err := latestRoot(ctx, m.src.log, mig.srcTree)
+		if err != nil {
+			return fmt.Errorf("failed to read source root: %v", err)
+		}
+		now := m.ts.Now()
+		if since.IsZero() || root.TreeSize != size {
+			size, since = root.TreeSize, now
+		} else if now.Sub(since) >= quietPeriod {
+			return nil
+		}
+		if err := clock.SleepSource(ctx, pollInterval, m.ts); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func newMemoryBackend() backend {
+	ts := memory.NewTreeStorage()
+	return backend{admin: memory.NewAdminStorage(ts), log: memory.NewLogStorage(ts, nil)}
+}
+
+// newSourceTree creates an initialised LOG tree in b.
+func newSourceTree(ctx context.Context, t *testing.T, b backend, ts clock.TimeSource) *trillian.Tree {
+	t.Helper()
+	tree, err := storage.CreateTree(ctx, b.admin, &trillian.Tree{
+		TreeType:        trillian.TreeType_LOG,
+		TreeState:       trillian.TreeState_ACTIVE,
+		DisplayName:     "source",
+		MaxRootDuration: durationpb.New(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateTree: %v", err)
+	}
+	m := &migrator{dst: b, ts: ts}
+	if err := m.initDestination(ctx, tree); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	return tree
+}
+
+// addLeaves queues and integrates count leaves into the source tree.
+func addLeaves(ctx context.Context, t *testing.T, b backend, tree *trillian.Tree, ts clock.TimeSource, start, count int) {
+	t.Helper()
+	leaves := make([]*trillian.LogLeaf, 0, count)
+	for i := start; i < start+count; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		id := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafValue:        value,
+			LeafIdentityHash: id[:],
+			MerkleLeafHash:   rfc6962.DefaultHasher.HashLeaf(value),
+		})
+	}
+	if _, err := b.log.QueueLeaves(ctx, tree, leaves, ts.Now()); err != nil {
+		t.Fatalf("QueueLeaves: %v", err)
+	}
+	if _, err := log.IntegrateBatch(ctx, tree, count, 0, 0, ts, b.log, quota.Noop()); err != nil {
+		t.Fatalf("IntegrateBatch: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	log.InitMetrics(nil)
+	ts := clock.System
+	src, dst := newMemoryBackend(), newMemoryBackend()
+	srcTree := newSourceTree(ctx, t, src, ts)
+	addLeaves(ctx, t, src, srcTree, ts, 0, 25)
+
+	m := &migrator{src: src, dst: dst, batchSize: 7, ts: ts}
+	mig, err := m.prepare(ctx, srcTree.TreeId, 0)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if got, want := mig.dstTree.TreeType, trillian.TreeType_PREORDERED_LOG; got != want {
+		t.Errorf("destination TreeType=%v, want %v", got, want)
+	}
+	root, err := m.catchUp(ctx, mig)
+	if err != nil {
+		t.Fatalf("catchUp: %v", err)
+	}
+	if got, want := root.TreeSize, uint64(25); got != want {
+		t.Errorf("catchUp: TreeSize=%d, want %d", got, want)
+	}
+
+	// The source keeps serving writes, and the migration is resumed.
+	addLeaves(ctx, t, src, srcTree, ts, 25, 10)
+	m = &migrator{src: src, dst: dst, batchSize: 4, ts: ts}
+	if mig, err = m.prepare(ctx, srcTree.TreeId, mig.dstTree.TreeId); err != nil {
+		t.Fatalf("prepare (resume): %v", err)
+	}
+	root, err = m.cutover(ctx, mig, time.Millisecond, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("cutover: %v", err)
+	}
+	if got, want := root.TreeSize, uint64(35); got != want {
+		t.Errorf("cutover: TreeSize=%d, want %d", got, want)
+	}
+
+	gotSrc, err := storage.GetTree(ctx, src.admin, srcTree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree(source): %v", err)
+	}
+	if got, want := gotSrc.TreeState, trillian.TreeState_FROZEN; got != want {
+		t.Errorf("source TreeState=%v, want %v", got, want)
+	}
+	gotDst, err := storage.GetTree(ctx, dst.admin, mig.dstTree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree(destination): %v", err)
+	}
+	if got, want := gotDst.TreeType, trillian.TreeType_LOG; got != want {
+		t.Errorf("destination TreeType=%v, want %v", got, want)
+	}
+	if got, want := gotDst.TreeState, trillian.TreeState_ACTIVE; got != want {
+		t.Errorf("destination TreeState=%v, want %v", got, want)
+	}
+	if got, want := gotDst.DisplayName, "source"; got != want {
+		t.Errorf("destination DisplayName=%q, want %q", got, want)
+	}
+
+	// The destination tree is now a regular log which accepts new leaves.
+	addLeaves(ctx, t, dst, gotDst, ts, 35, 3)
+	dstRoot, err := latestRoot(ctx, dst.log, gotDst)
+	if err != nil {
+		t.Fatalf("latestRoot: %v", err)
+	}
+	if got, want := dstRoot.TreeSize, uint64(38); got != want {
+		t.Errorf("destination TreeSize=%d, want %d", got, want)
+	}
+}
+
+func TestCatchUpDetectsDivergence(t *testing.T) {
+	ctx := context.Background()
+	log.InitMetrics(nil)
+	ts := clock.System
+	src, dst := newMemoryBackend(), newMemoryBackend()
+	srcTree := newSourceTree(ctx, t, src, ts)
+	addLeaves(ctx, t, src, srcTree, ts, 0, 5)
+
+	m := &migrator{src: src, dst: dst, batchSize: 10, ts: ts}
+	mig, err := m.prepare(ctx, srcTree.TreeId, 0)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	// Put a different leaf at index 0 of the destination.
+	bad := []byte("bad")
+	id := sha256.Sum256(bad)
+	if _, err := dst.log.AddSequencedLeaves(ctx, mig.dstTree, []*trillian.LogLeaf{{
+		LeafValue:        bad,
+		LeafIdentityHash: id[:],
+		MerkleLeafHash:   rfc6962.DefaultHasher.HashLeaf(bad),
+	}}, ts.Now()); err != nil {
+		t.Fatalf("AddSequencedLeaves: %v", err)
+	}
+	if _, err := m.catchUp(ctx, mig); err == nil {
+		t.Error("catchUp: got nil error, want divergence error")
+	}
+}
+
+func TestCatchUpComparesExistingLeaves(t *testing.T) {
+	ctx := context.Background()
+	log.InitMetrics(nil)
+	ts := clock.System
+	for _, tc := range []struct {
+		desc      string
+		extraData []byte
+		wantErr   bool
+	}{
+		{desc: "same"},
+		// The root hash doesn't cover the extra data.
+		{desc: "different-extra-data", extraData: []byte("extra"), wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			src, dst := newMemoryBackend(), newMemoryBackend()
+			srcTree := newSourceTree(ctx, t, src, ts)
+			addLeaves(ctx, t, src, srcTree, ts, 0, 5)
+
+			m := &migrator{src: src, dst: dst, batchSize: 10, ts: ts}
+			mig, err := m.prepare(ctx, srcTree.TreeId, 0)
+			if err != nil {
+				t.Fatalf("prepare: %v", err)
+			}
+			// Copy the leaf at index 1 as an interrupted run would have.
+			leaves, err := leavesByRange(ctx, src.log, srcTree, 1, 1)
+			if err != nil {
+				t.Fatalf("leavesByRange: %v", err)
+			}
+			leaf := proto.Clone(leaves[0]).(*trillian.LogLeaf)
+			leaf.ExtraData = tc.extraData
+			if _, err := dst.log.AddSequencedLeaves(ctx, mig.dstTree, []*trillian.LogLeaf{leaf}, ts.Now()); err != nil {
+				t.Fatalf("AddSequencedLeaves: %v", err)
+			}
+			if _, err := m.catchUp(ctx, mig); (err != nil) != tc.wantErr {
+				t.Errorf("catchUp: got error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
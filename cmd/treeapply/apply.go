@@ -0,0 +1,297 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sigs.k8s.io/yaml"
+)
+
+// managedFields are the Tree fields that can be reconciled, i.e. those which
+// UpdateTree accepts in its update mask.
+var managedFields = []protoreflect.Name{
+	"tree_state",
+	"tree_type",
+	"display_name",
+	"description",
+	"storage_settings",
+	"max_root_duration",
+	"sequencer_guard_window",
+	"sequencer_batch_size",
+	"priority",
+	"signer_affinity",
+	"anti_affinity_group",
+	"max_leaf_value_size",
+	"max_extra_data_size",
+	"max_unsequenced_leaves",
+	"compact_retained_revisions",
+}
+
+// desiredTree is a tree of the manifest, along with the fields it sets.
+// Fields not present in the manifest are left untouched in existing trees.
+type desiredTree struct {
+	tree   *trillian.Tree
+	fields []protoreflect.Name
+}
+
+// key returns how the tree is identified in logs and errors.
+func (d *desiredTree) key() string {
+	if id := d.tree.TreeId; id != 0 {
+		return fmt.Sprint(id)
+	}
+	return fmt.Sprintf("%q", d.tree.DisplayName)
+}
+
+// parseManifest parses a JSON or YAML manifest of the form
+// {"trees": [<Tree>, ...]}, where each tree is in the protobuf JSON format.
+// Trees are identified by their tree_id if set, or else their display_name.
+func parseManifest(path string, data []byte) ([]*desiredTree, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		var err error
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown manifest extension %q, want .json, .yaml or .yml", ext)
+	}
+
+	var manifest struct {
+		Trees []json.RawMessage `json:"trees"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+
+	fds := (&trillian.Tree{}).ProtoReflect().Descriptor().Fields()
+	ids := make(map[int64]bool)
+	names := make(map[string]bool)
+	desired := make([]*desiredTree, 0, len(manifest.Trees))
+	for i, raw := range manifest.Trees {
+		d := &desiredTree{tree: &trillian.Tree{}}
+		if err := protojson.Unmarshal(raw, d.tree); err != nil {
+			return nil, fmt.Errorf("trees[%d]: %v", i, err)
+		}
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return nil, fmt.Errorf("trees[%d]: %v", i, err)
+		}
+		for _, name := range managedFields {
+			fd := fds.ByName(name)
+			_, jsonOK := keys[fd.JSONName()]
+			_, nameOK := keys[string(fd.Name())]
+			if jsonOK || nameOK {
+				d.fields = append(d.fields, name)
+			}
+		}
+
+		switch t := d.tree; {
+		case t.TreeId != 0:
+			if ids[t.TreeId] {
+				return nil, fmt.Errorf("trees[%d]: duplicate tree_id %d", i, t.TreeId)
+			}
+			ids[t.TreeId] = true
+		case t.DisplayName != "":
+			if names[t.DisplayName] {
+				return nil, fmt.Errorf("trees[%d]: duplicate display_name %q", i, t.DisplayName)
+			}
+			names[t.DisplayName] = true
+		default:
+			return nil, fmt.Errorf("trees[%d]: one of tree_id or display_name is required", i)
+		}
+		desired = append(desired, d)
+	}
+	return desired, nil
+}
+
+// pruneMode determines what happens to existing trees not in the manifest.
+type pruneMode string
+
+const (
+	pruneNone   pruneMode = "none"
+	pruneFreeze pruneMode = "freeze"
+	pruneDelete pruneMode = "delete"
+)
+
+// applier reconciles the trees of an admin server with a manifest.
+type applier struct {
+	admin trillian.TrillianAdminClient
+	log   trillian.TrillianLogClient
+	prune pruneMode
+	// dryRun reports the changes which would be made without making them.
+	dryRun bool
+}
+
+// apply makes the trees of the admin server match desired, and returns a
+// description of each change made.
+func (a *applier) apply(ctx context.Context, desired []*desiredTree) ([]string, error) {
+	resp, err := a.admin.ListTrees(ctx, &trillian.ListTreesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("ListTrees: %v", err)
+	}
+	byID := make(map[int64]*trillian.Tree)
+	byName := make(map[string][]*trillian.Tree)
+	for _, t := range resp.Tree {
+		byID[t.TreeId] = t
+		byName[t.DisplayName] = append(byName[t.DisplayName], t)
+	}
+
+	// Match all trees before making any change, so that ambiguous manifests
+	// fail without side effects.
+	existing := make([]*trillian.Tree, len(desired))
+	matched := make(map[int64]bool)
+	for i, d := range desired {
+		if id := d.tree.TreeId; id != 0 {
+			t, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("tree %d not found", id)
+			}
+			existing[i] = t
+		} else if ts := byName[d.tree.DisplayName]; len(ts) > 1 {
+			return nil, fmt.Errorf("tree %s is ambiguous: %d trees have this display_name", d.key(), len(ts))
+		} else if len(ts) == 1 {
+			existing[i] = ts[0]
+		}
+		if t := existing[i]; t != nil {
+			if matched[t.TreeId] {
+				return nil, fmt.Errorf("tree %d matches more than one manifest entry", t.TreeId)
+			}
+			matched[t.TreeId] = true
+		}
+	}
+
+	var changes []string
+	for i, d := range desired {
+		var change string
+		var err error
+		if existing[i] == nil {
+			change, err = a.create(ctx, d)
+		} else {
+			change, err = a.update(ctx, d, existing[i])
+		}
+		if err != nil {
+			return changes, err
+		}
+		if change != "" {
+			changes = append(changes, change)
+		}
+	}
+
+	if a.prune == pruneNone {
+		return changes, nil
+	}
+	for _, t := range resp.Tree {
+		if matched[t.TreeId] {
+			continue
+		}
+		change, err := a.pruneTree(ctx, t)
+		if err != nil {
+			return changes, err
+		}
+		if change != "" {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (a *applier) create(ctx context.Context, d *desiredTree) (string, error) {
+	if a.dryRun {
+		return fmt.Sprintf("create %s", d.key()), nil
+	}
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{Tree: d.tree}, a.admin, a.log)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tree %s: %v", d.key(), err)
+	}
+	return fmt.Sprintf("create %s: tree %d", d.key(), tree.TreeId), nil
+}
+
+func (a *applier) update(ctx context.Context, d *desiredTree, existing *trillian.Tree) (string, error) {
+	want, got := d.tree.ProtoReflect(), existing.ProtoReflect()
+	fds := want.Descriptor().Fields()
+	var paths []string
+	for _, name := range d.fields {
+		fd := fds.ByName(name)
+		if !fieldEqual(fd, want.Get(fd), got.Get(fd)) {
+			paths = append(paths, string(name))
+		}
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+	change := fmt.Sprintf("update %d: %s", existing.TreeId, strings.Join(paths, ","))
+	if a.dryRun {
+		return change, nil
+	}
+	tree := proto.Clone(d.tree).(*trillian.Tree)
+	tree.TreeId = existing.TreeId
+	if _, err := a.admin.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+		Tree:       tree,
+		UpdateMask: &field_mask.FieldMask{Paths: paths},
+	}); err != nil {
+		return "", fmt.Errorf("failed to update tree %d: %v", existing.TreeId, err)
+	}
+	return change, nil
+}
+
+// fieldEqual returns whether two values of the field fd are equal.
+func fieldEqual(fd protoreflect.FieldDescriptor, x, y protoreflect.Value) bool {
+	if fd.Message() != nil {
+		return proto.Equal(x.Message().Interface(), y.Message().Interface())
+	}
+	return x.Interface() == y.Interface()
+}
+
+func (a *applier) pruneTree(ctx context.Context, t *trillian.Tree) (string, error) {
+	switch a.prune {
+	case pruneFreeze:
+		if t.TreeState == trillian.TreeState_FROZEN {
+			return "", nil
+		}
+		change := fmt.Sprintf("freeze %d", t.TreeId)
+		if a.dryRun {
+			return change, nil
+		}
+		if _, err := a.admin.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+			Tree:       &trillian.Tree{TreeId: t.TreeId, TreeState: trillian.TreeState_FROZEN},
+			UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_state"}},
+		}); err != nil {
+			return "", fmt.Errorf("failed to freeze tree %d: %v", t.TreeId, err)
+		}
+		return change, nil
+	case pruneDelete:
+		change := fmt.Sprintf("delete %d", t.TreeId)
+		if a.dryRun {
+			return change, nil
+		}
+		if _, err := a.admin.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: t.TreeId}); err != nil {
+			return "", fmt.Errorf("failed to delete tree %d: %v", t.TreeId, err)
+		}
+		return change, nil
+	}
+	return "", fmt.Errorf("unknown prune mode %q", a.prune)
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeAdmin keeps trees in memory, applying update masks like the admin server.
+type fakeAdmin struct {
+	trillian.TrillianAdminClient
+	trees  map[int64]*trillian.Tree
+	nextID int64
+}
+
+func newFakeAdmin(trees ...*trillian.Tree) *fakeAdmin {
+	f := &fakeAdmin{trees: make(map[int64]*trillian.Tree), nextID: 1000}
+	for _, t := range trees {
+		f.trees[t.TreeId] = proto.Clone(t).(*trillian.Tree)
+	}
+	return f
+}
+
+func (f *fakeAdmin) ListTrees(context.Context, *trillian.ListTreesRequest, ...grpc.CallOption) (*trillian.ListTreesResponse, error) {
+	resp := &trillian.ListTreesResponse{}
+	for _, t := range f.trees {
+		if !t.Deleted {
+			resp.Tree = append(resp.Tree, proto.Clone(t).(*trillian.Tree))
+		}
+	}
+	sort.Slice(resp.Tree, func(i, j int) bool { return resp.Tree[i].TreeId < resp.Tree[j].TreeId })
+	return resp, nil
+}
+
+func (f *fakeAdmin) CreateTree(_ context.Context, req *trillian.CreateTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	t := proto.Clone(req.Tree).(*trillian.Tree)
+	f.nextID++
+	t.TreeId = f.nextID
+	f.trees[t.TreeId] = t
+	return t, nil
+}
+
+func (f *fakeAdmin) UpdateTree(_ context.Context, req *trillian.UpdateTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	from, to := req.Tree.ProtoReflect(), f.trees[req.Tree.TreeId].ProtoReflect()
+	for _, path := range req.UpdateMask.Paths {
+		fd := from.Descriptor().Fields().ByTextName(path)
+		to.Set(fd, from.Get(fd))
+	}
+	return f.trees[req.Tree.TreeId], nil
+}
+
+func (f *fakeAdmin) DeleteTree(_ context.Context, req *trillian.DeleteTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	f.trees[req.TreeId].Deleted = true
+	return f.trees[req.TreeId], nil
+}
+
+type fakeLog struct {
+	trillian.TrillianLogClient
+}
+
+func (fakeLog) InitLog(context.Context, *trillian.InitLogRequest, ...grpc.CallOption) (*trillian.InitLogResponse, error) {
+	return &trillian.InitLogResponse{}, nil
+}
+
+func (fakeLog) GetLatestSignedLogRoot(context.Context, *trillian.GetLatestSignedLogRootRequest, ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	return &trillian.GetLatestSignedLogRootResponse{}, nil
+}
+
+const manifestYAML = `
+trees:
+- display_name: new
+  tree_type: LOG
+  tree_state: ACTIVE
+  max_root_duration: 60s
+- displayName: drifted
+  description: Updated
+- tree_id: 2
+  tree_state: FROZEN
+`
+
+func existingTrees() []*trillian.Tree {
+	return []*trillian.Tree{
+		{TreeId: 1, DisplayName: "drifted", Description: "Original", TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_ACTIVE},
+		{TreeId: 2, DisplayName: "by-id", TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_ACTIVE},
+		{TreeId: 3, DisplayName: "unlisted", TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_ACTIVE},
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	desired, err := parseManifest("trees.yaml", []byte(manifestYAML))
+	if err != nil {
+		t.Fatalf("parseManifest: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc        string
+		prune       pruneMode
+		dryRun      bool
+		wantChanges []string
+		wantState3  trillian.TreeState
+		wantDeleted bool
+	}{
+		{
+			desc:        "pruneNone",
+			prune:       pruneNone,
+			wantChanges: []string{`create "new": tree 1001`, "update 1: description", "update 2: tree_state"},
+			wantState3:  trillian.TreeState_ACTIVE,
+		},
+		{
+			desc:        "pruneFreeze",
+			prune:       pruneFreeze,
+			wantChanges: []string{`create "new": tree 1001`, "update 1: description", "update 2: tree_state", "freeze 3"},
+			wantState3:  trillian.TreeState_FROZEN,
+		},
+		{
+			desc:        "pruneDelete",
+			prune:       pruneDelete,
+			wantChanges: []string{`create "new": tree 1001`, "update 1: description", "update 2: tree_state", "delete 3"},
+			wantState3:  trillian.TreeState_ACTIVE,
+			wantDeleted: true,
+		},
+		{
+			desc:        "dryRun",
+			prune:       pruneDelete,
+			dryRun:      true,
+			wantChanges: []string{`create "new"`, "update 1: description", "update 2: tree_state", "delete 3"},
+			wantState3:  trillian.TreeState_ACTIVE,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			admin := newFakeAdmin(existingTrees()...)
+			a := &applier{admin: admin, log: fakeLog{}, prune: tc.prune, dryRun: tc.dryRun}
+			changes, err := a.apply(ctx, desired)
+			if err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if diff := cmp.Diff(changes, tc.wantChanges); diff != "" {
+				t.Errorf("apply: changes diff (-got +want):\n%s", diff)
+			}
+			if got := admin.trees[3]; got.TreeState != tc.wantState3 || got.Deleted != tc.wantDeleted {
+				t.Errorf("unlisted tree: state=%v deleted=%v, want %v %v", got.TreeState, got.Deleted, tc.wantState3, tc.wantDeleted)
+			}
+			if tc.dryRun {
+				if diff := cmp.Diff(admin.trees[1], existingTrees()[0], cmp.Comparer(proto.Equal)); diff != "" {
+					t.Errorf("dry run changed tree 1 (-got +want):\n%s", diff)
+				}
+				return
+			}
+
+			created := admin.trees[1001]
+			want := &trillian.Tree{TreeId: 1001, DisplayName: "new", TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_ACTIVE, MaxRootDuration: durationpb.New(time.Minute)}
+			if !proto.Equal(created, want) {
+				t.Errorf("created tree = %v, want %v", created, want)
+			}
+			// Fields not in the manifest are untouched.
+			if got := admin.trees[1]; got.Description != "Updated" || got.TreeState != trillian.TreeState_ACTIVE {
+				t.Errorf("updated tree = %v, want description updated and state unchanged", got)
+			}
+
+			// Applying again is a no-op.
+			changes, err = a.apply(ctx, desired)
+			if err != nil {
+				t.Fatalf("apply (again): %v", err)
+			}
+			if len(changes) != 0 {
+				t.Errorf("apply (again): changes = %v, want none", changes)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc     string
+		manifest string
+		trees    []*trillian.Tree
+	}{
+		{desc: "unknownID", manifest: `{"trees": [{"treeId": 42}]}`},
+		{
+			desc:     "ambiguousName",
+			manifest: `{"trees": [{"displayName": "dup"}]}`,
+			trees:    []*trillian.Tree{{TreeId: 1, DisplayName: "dup"}, {TreeId: 2, DisplayName: "dup"}},
+		},
+		{
+			desc:     "matchedTwice",
+			manifest: `{"trees": [{"treeId": 1}, {"displayName": "one"}]}`,
+			trees:    []*trillian.Tree{{TreeId: 1, DisplayName: "one"}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			desired, err := parseManifest("trees.json", []byte(tc.manifest))
+			if err != nil {
+				t.Fatalf("parseManifest: %v", err)
+			}
+			admin := newFakeAdmin(tc.trees...)
+			a := &applier{admin: admin, log: fakeLog{}, prune: pruneDelete}
+			changes, err := a.apply(ctx, desired)
+			if err == nil {
+				t.Errorf("apply: got nil error, want error")
+			}
+			if len(changes) != 0 {
+				t.Errorf("apply: changes = %v, want none", changes)
+			}
+		})
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc, path, manifest string
+	}{
+		{desc: "noKey", path: "t.json", manifest: `{"trees": [{"treeType": "LOG"}]}`},
+		{desc: "duplicateID", path: "t.json", manifest: `{"trees": [{"treeId": 1}, {"treeId": 1}]}`},
+		{desc: "duplicateName", path: "t.json", manifest: `{"trees": [{"displayName": "a"}, {"displayName": "a"}]}`},
+		{desc: "unknownField", path: "t.json", manifest: `{"trees": [{"displayName": "a", "llamas": 1}]}`},
+		{desc: "badYAML", path: "t.yaml", manifest: "trees: [\n"},
+		{desc: "badExtension", path: "t.txt", manifest: `{"trees": []}`},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := parseManifest(tc.path, []byte(tc.manifest)); err == nil {
+				t.Error("parseManifest: got nil error, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the treeapply
+// command, which reconciles the trees of an admin server with a manifest.
+//
+// Example usage:
+// $ ./treeapply --admin_server=host:port --manifest=trees.yaml --prune=freeze
+//
+// The manifest lists the desired trees in the protobuf JSON format of
+// trillian.Tree, in JSON or YAML:
+//
+//	trees:
+//	- display_name: ct-2022
+//	  tree_type: LOG
+//	  tree_state: ACTIVE
+//	  max_root_duration: 3600s
+//	- tree_id: 123456789
+//	  tree_state: FROZEN
+//
+// Trees are matched by tree_id if set, or else by display_name. Missing trees
+// are created, and fields set in the manifest which differ from an existing
+// tree are updated, while fields not set in the manifest are left as they are.
+// Existing trees not listed in the manifest are left alone, or frozen or
+// deleted depending on --prune.
+//
+// Applying the same manifest again makes no changes. The command outputs one
+// line per change to stdout; with --dry_run, the changes are only printed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/cmd"
+	"google.golang.org/grpc"
+)
+
+var (
+	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	rpcDeadline     = flag.Duration("rpc_deadline", time.Minute, "Deadline for applying the whole manifest")
+	manifestFile    = flag.String("manifest", "", "JSON or YAML file (by extension) listing the desired trees")
+	prune           = flag.String("prune", string(pruneNone), "What to do with existing trees not in the manifest. One of: none, freeze, delete")
+	dryRun          = flag.Bool("dry_run", false, "If true, print the changes which would be made without making them")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+)
+
+func apply(ctx context.Context) ([]string, error) {
+	if *adminServerAddr == "" {
+		return nil, errors.New("empty --admin_server, please provide the Admin server host:port")
+	}
+	if *manifestFile == "" {
+		return nil, errors.New("empty --manifest, please provide the manifest file")
+	}
+	mode := pruneMode(*prune)
+	switch mode {
+	case pruneNone, pruneFreeze, pruneDelete:
+	default:
+		return nil, fmt.Errorf("unknown --prune %q, want none, freeze or delete", *prune)
+	}
+
+	data, err := os.ReadFile(*manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	desired, err := parseManifest(*manifestFile, data)
+	if err != nil {
+		return nil, err
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	conn, err := grpc.Dial(*adminServerAddr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %v: %v", *adminServerAddr, err)
+	}
+	defer conn.Close()
+
+	a := &applier{
+		admin:  trillian.NewTrillianAdminClient(conn),
+		log:    trillian.NewTrillianLogClient(conn),
+		prune:  mode,
+		dryRun: *dryRun,
+	}
+	return a.apply(ctx, desired)
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *rpcDeadline)
+	defer cancel()
+	changes, err := apply(ctx)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if err != nil {
+		glog.Exitf("Failed to apply manifest: %v", err)
+	}
+}
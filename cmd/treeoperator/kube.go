@@ -0,0 +1,260 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	crdGroup    = "trillian.transparency.dev"
+	crdVersion  = "v1alpha1"
+	crdResource = "trilliantrees"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// trillianTree is a TrillianTree custom resource.
+type trillianTree struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     treeSpec   `json:"spec"`
+	Status   treeStatus `json:"status,omitempty"`
+}
+
+// objectMeta holds the Kubernetes object metadata used by the operator.
+type objectMeta struct {
+	Name              string   `json:"name"`
+	Namespace         string   `json:"namespace"`
+	UID               string   `json:"uid,omitempty"`
+	ResourceVersion   string   `json:"resourceVersion,omitempty"`
+	Generation        int64    `json:"generation,omitempty"`
+	DeletionTimestamp *string  `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string `json:"finalizers,omitempty"`
+}
+
+// treeSpec is the desired state of a tree.
+type treeSpec struct {
+	DisplayName     string `json:"displayName,omitempty"`
+	Description     string `json:"description,omitempty"`
+	TreeType        string `json:"treeType,omitempty"`
+	TreeState       string `json:"treeState,omitempty"`
+	MaxRootDuration string `json:"maxRootDuration,omitempty"`
+	// The following fields override the defaults of the log signer and log
+	// server for the tree.
+	SequencerGuardWindow     string   `json:"sequencerGuardWindow,omitempty"`
+	SequencerBatchSize       int32    `json:"sequencerBatchSize,omitempty"`
+	Priority                 string   `json:"priority,omitempty"`
+	SignerAffinity           []string `json:"signerAffinity,omitempty"`
+	AntiAffinityGroup        string   `json:"antiAffinityGroup,omitempty"`
+	MaxLeafValueSize         int32    `json:"maxLeafValueSize,omitempty"`
+	MaxExtraDataSize         int32    `json:"maxExtraDataSize,omitempty"`
+	MaxUnsequencedLeaves     int32    `json:"maxUnsequencedLeaves,omitempty"`
+	CompactRetainedRevisions int32    `json:"compactRetainedRevisions,omitempty"`
+	// DeletionPolicy is what happens to the tree when the resource is
+	// deleted: Retain (the default), Freeze or Delete.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// treeStatus is the observed state of a tree.
+type treeStatus struct {
+	TreeID             int64  `json:"treeID,omitempty"`
+	TreeState          string `json:"treeState,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	Message            string `json:"message,omitempty"`
+}
+
+// kubeAPI is the subset of the Kubernetes API used by the operator.
+type kubeAPI interface {
+	// list returns all TrillianTrees, and the resource version to start
+	// watching from.
+	list(ctx context.Context) ([]*trillianTree, string, error)
+	// watch calls fn for each change of a TrillianTree after resourceVersion,
+	// until ctx is done or the server ends the watch.
+	watch(ctx context.Context, resourceVersion string, fn func(eventType string, t *trillianTree)) error
+	// patchStatus replaces the status of t.
+	patchStatus(ctx context.Context, t *trillianTree, status treeStatus) error
+	// setFinalizers replaces the finalizers of t.
+	setFinalizers(ctx context.Context, t *trillianTree, finalizers []string) error
+}
+
+// kubeClient is a minimal client for the TrillianTree resources of the
+// Kubernetes API server.
+type kubeClient struct {
+	server    string
+	token     string
+	namespace string
+	hc        *http.Client
+}
+
+// newInClusterClient returns a client configured from the service account of
+// the pod it runs in.
+func newInClusterClient(namespace string) (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, please provide --kube_api_server")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("failed to parse service account CA")
+	}
+	return &kubeClient{
+		server:    "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		hc:        &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}},
+	}, nil
+}
+
+// collection returns the URL path of the TrillianTrees in namespace, or in
+// all namespaces if it is empty.
+func collection(namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", crdGroup, crdVersion, crdResource)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", crdGroup, crdVersion, url.PathEscape(namespace), crdResource)
+}
+
+func (k *kubeClient) do(ctx context.Context, method, path string, query url.Values, contentType string, body interface{}) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	u := k.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+	resp, err := k.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+func (k *kubeClient) list(ctx context.Context) ([]*trillianTree, string, error) {
+	resp, err := k.do(ctx, http.MethodGet, collection(k.namespace), nil, "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []*trillianTree `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("failed to decode TrillianTree list: %v", err)
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+func (k *kubeClient) watch(ctx context.Context, resourceVersion string, fn func(string, *trillianTree)) error {
+	q := url.Values{"watch": {"true"}, "allowWatchBookmarks": {"false"}}
+	if resourceVersion != "" {
+		q.Set("resourceVersion", resourceVersion)
+	}
+	resp, err := k.do(ctx, http.MethodGet, collection(k.namespace), q, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&event); err == io.EOF || ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode watch event: %v", err)
+		}
+		if event.Type == "ERROR" {
+			return fmt.Errorf("watch error: %s", event.Object)
+		}
+		var t trillianTree
+		if err := json.Unmarshal(event.Object, &t); err != nil {
+			return fmt.Errorf("failed to decode TrillianTree: %v", err)
+		}
+		fn(event.Type, &t)
+	}
+}
+
+func (k *kubeClient) patchStatus(ctx context.Context, t *trillianTree, status treeStatus) error {
+	path := collection(t.Metadata.Namespace) + "/" + url.PathEscape(t.Metadata.Name) + "/status"
+	resp, err := k.do(ctx, http.MethodPatch, path, nil, "application/merge-patch+json", map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (k *kubeClient) setFinalizers(ctx context.Context, t *trillianTree, finalizers []string) error {
+	if finalizers == nil {
+		finalizers = []string{}
+	}
+	// The resource version makes the patch fail if the finalizers were changed
+	// concurrently.
+	patch := map[string]interface{}{"metadata": map[string]interface{}{
+		"finalizers":      finalizers,
+		"resourceVersion": t.Metadata.ResourceVersion,
+	}}
+	path := collection(t.Metadata.Namespace) + "/" + url.PathEscape(t.Metadata.Name)
+	resp, err := k.do(ctx, http.MethodPatch, path, nil, "application/merge-patch+json", patch)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
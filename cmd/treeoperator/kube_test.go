@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKubeClient(t *testing.T) {
+	ctx := context.Background()
+	const base = "/apis/trillian.transparency.dev/v1alpha1/namespaces/ns/trilliantrees"
+	var patches []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer secret"; got != want {
+			t.Errorf("Authorization=%q, want %q", got, want)
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == base && r.URL.Query().Get("watch") == "true":
+			if got, want := r.URL.Query().Get("resourceVersion"), "10"; got != want {
+				t.Errorf("watch resourceVersion=%q, want %q", got, want)
+			}
+			fmt.Fprintln(w, `{"type": "MODIFIED", "object": {"metadata": {"name": "a", "namespace": "ns"}, "spec": {"treeState": "FROZEN"}}}`)
+			fmt.Fprintln(w, `{"type": "DELETED", "object": {"metadata": {"name": "b", "namespace": "ns"}}}`)
+		case r.Method == http.MethodGet && r.URL.Path == base:
+			fmt.Fprint(w, `{"metadata": {"resourceVersion": "10"}, "items": [{"metadata": {"name": "a", "namespace": "ns", "uid": "u"}, "spec": {"displayName": "A"}, "status": {"treeID": 5}}]}`)
+		case r.Method == http.MethodPatch:
+			if got, want := r.Header.Get("Content-Type"), "application/merge-patch+json"; got != want {
+				t.Errorf("Content-Type=%q, want %q", got, want)
+			}
+			body, _ := io.ReadAll(r.Body)
+			patches = append(patches, r.URL.Path+" "+string(body))
+			fmt.Fprint(w, `{}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	k := &kubeClient{server: srv.URL, token: "secret", namespace: "ns", hc: srv.Client()}
+
+	trees, rv, err := k.list(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if rv != "10" || len(trees) != 1 {
+		t.Fatalf("list: got %d trees at %q, want 1 at %q", len(trees), rv, "10")
+	}
+	want := &trillianTree{
+		Metadata: objectMeta{Name: "a", Namespace: "ns", UID: "u"},
+		Spec:     treeSpec{DisplayName: "A"},
+		Status:   treeStatus{TreeID: 5},
+	}
+	if diff := cmp.Diff(trees[0], want); diff != "" {
+		t.Errorf("list: diff (-got +want):\n%s", diff)
+	}
+
+	var events []string
+	if err := k.watch(ctx, rv, func(eventType string, t *trillianTree) {
+		events = append(events, eventType+" "+t.Metadata.Name)
+	}); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if diff := cmp.Diff(events, []string{"MODIFIED a", "DELETED b"}); diff != "" {
+		t.Errorf("watch: events diff (-got +want):\n%s", diff)
+	}
+
+	want.Metadata.ResourceVersion = "11"
+	if err := k.patchStatus(ctx, want, treeStatus{TreeID: 5, TreeState: "ACTIVE"}); err != nil {
+		t.Fatalf("patchStatus: %v", err)
+	}
+	if err := k.setFinalizers(ctx, want, nil); err != nil {
+		t.Fatalf("setFinalizers: %v", err)
+	}
+	wantPatches := []string{
+		base + `/a/status {"status":{"treeID":5,"treeState":"ACTIVE"}}`,
+		base + `/a {"metadata":{"finalizers":[],"resourceVersion":"11"}}`,
+	}
+	if diff := cmp.Diff(patches, wantPatches); diff != "" {
+		t.Errorf("patches diff (-got +want):\n%s", diff)
+	}
+
+	k.namespace = "other"
+	if _, _, err := k.list(ctx); err == nil {
+		t.Error("list (unknown namespace): got nil error, want error")
+	}
+}
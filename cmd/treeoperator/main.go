@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// treeoperator command, a Kubernetes controller managing Trillian trees.
+//
+// Example usage:
+// $ ./treeoperator --admin_server=trillian-log-service:8090 --namespace=default
+//
+// The operator watches TrillianTree custom resources (see
+// examples/deployment/kubernetes/trillian-tree-crd.yaml) and reconciles them
+// against the admin API: a tree is created for each new TrillianTree, and its
+// fields are updated whenever they drift from the spec. The ID and state of the
+// tree are written back into the status of the TrillianTree.
+//
+// When a TrillianTree is deleted, its tree is retained, frozen or deleted
+// according to spec.deletionPolicy.
+//
+// Inside a cluster the operator uses the credentials of its service account.
+// Outside a cluster, --kube_api_server can point it at e.g. `kubectl proxy`.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+)
+
+var (
+	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	namespace       = flag.String("namespace", "", "Namespace to watch TrillianTrees in, empty means all namespaces")
+	kubeAPIServer   = flag.String("kube_api_server", "", "If set, URL of the Kubernetes API server to use instead of the in-cluster configuration")
+	kubeTokenFile   = flag.String("kube_token_file", "", "If set, file containing the bearer token for --kube_api_server")
+	resyncInterval  = flag.Duration("resync_interval", 5*time.Minute, "Interval at which all TrillianTrees are reconciled, even if unchanged")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+)
+
+func newKubeClient() (*kubeClient, error) {
+	if *kubeAPIServer == "" {
+		return newInClusterClient(*namespace)
+	}
+	k := &kubeClient{
+		server:    strings.TrimSuffix(*kubeAPIServer, "/"),
+		namespace: *namespace,
+		hc:        http.DefaultClient,
+	}
+	if *kubeTokenFile != "" {
+		token, err := os.ReadFile(*kubeTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --kube_token_file: %v", err)
+		}
+		k.token = strings.TrimSpace(string(token))
+	}
+	return k, nil
+}
+
+func run(ctx context.Context) error {
+	if *adminServerAddr == "" {
+		return errors.New("empty --admin_server, please provide the Admin server host:port")
+	}
+	kube, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	conn, err := grpc.Dial(*adminServerAddr, dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *adminServerAddr, err)
+	}
+	defer conn.Close()
+
+	r := newReconciler(kube, trillian.NewTrillianAdminClient(conn), trillian.NewTrillianLogClient(conn))
+	r.run(ctx, *resyncInterval)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.AwaitSignal(ctx, cancel)
+
+	if err := run(ctx); err != nil {
+		glog.Exitf("Operator failed: %v", err)
+	}
+}
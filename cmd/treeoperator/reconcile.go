@@ -0,0 +1,316 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// finalizer makes Kubernetes wait for the operator to apply the deletion
+// policy before removing a TrillianTree.
+const finalizer = crdGroup + "/tree"
+
+const (
+	policyRetain = "Retain"
+	policyFreeze = "Freeze"
+	policyDelete = "Delete"
+)
+
+// reconciler makes the trees of the admin server match TrillianTrees.
+type reconciler struct {
+	kube  kubeAPI
+	admin trillian.TrillianAdminClient
+	log   trillian.TrillianLogClient
+
+	// created maps the UIDs of TrillianTrees to the IDs of the trees created
+	// for them. Watch events can carry objects older than the status update
+	// recording a newly created tree, which must not cause another creation.
+	created map[string]int64
+}
+
+func newReconciler(kube kubeAPI, admin trillian.TrillianAdminClient, log trillian.TrillianLogClient) *reconciler {
+	return &reconciler{kube: kube, admin: admin, log: log, created: make(map[string]int64)}
+}
+
+// tree returns the tree described by spec, with defaults applied.
+func (s *treeSpec) tree() (*trillian.Tree, error) {
+	tree := &trillian.Tree{
+		TreeType:        trillian.TreeType_LOG,
+		TreeState:       trillian.TreeState_ACTIVE,
+		DisplayName:     s.DisplayName,
+		Description:     s.Description,
+		MaxRootDuration: durationpb.New(time.Hour),
+	}
+	if s.TreeType != "" {
+		tt, ok := trillian.TreeType_value[s.TreeType]
+		if !ok {
+			return nil, fmt.Errorf("unknown treeType %q", s.TreeType)
+		}
+		tree.TreeType = trillian.TreeType(tt)
+	}
+	if s.TreeState != "" {
+		ts, ok := trillian.TreeState_value[s.TreeState]
+		if !ok {
+			return nil, fmt.Errorf("unknown treeState %q", s.TreeState)
+		}
+		tree.TreeState = trillian.TreeState(ts)
+	}
+	if s.MaxRootDuration != "" {
+		d, err := time.ParseDuration(s.MaxRootDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxRootDuration: %v", err)
+		}
+		tree.MaxRootDuration = durationpb.New(d)
+	}
+	if s.SequencerGuardWindow != "" {
+		d, err := time.ParseDuration(s.SequencerGuardWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sequencerGuardWindow: %v", err)
+		}
+		tree.SequencerGuardWindow = durationpb.New(d)
+	}
+	tree.SequencerBatchSize = s.SequencerBatchSize
+	if s.Priority != "" {
+		p, ok := trillian.TreePriority_value[s.Priority]
+		if !ok {
+			return nil, fmt.Errorf("unknown priority %q", s.Priority)
+		}
+		tree.Priority = trillian.TreePriority(p)
+	}
+	tree.SignerAffinity = s.SignerAffinity
+	tree.AntiAffinityGroup = s.AntiAffinityGroup
+	tree.MaxLeafValueSize = s.MaxLeafValueSize
+	tree.MaxExtraDataSize = s.MaxExtraDataSize
+	tree.MaxUnsequencedLeaves = s.MaxUnsequencedLeaves
+	tree.CompactRetainedRevisions = s.CompactRetainedRevisions
+	switch s.DeletionPolicy {
+	case "", policyRetain, policyFreeze, policyDelete:
+	default:
+		return nil, fmt.Errorf("unknown deletionPolicy %q", s.DeletionPolicy)
+	}
+	return tree, nil
+}
+
+// reconcile brings the tree of t in line with its spec, and records the
+// result in the status of t.
+func (r *reconciler) reconcile(ctx context.Context, t *trillianTree) error {
+	if t.Metadata.DeletionTimestamp != nil {
+		return r.finalize(ctx, t)
+	}
+	if !hasFinalizer(t) {
+		if err := r.kube.setFinalizers(ctx, t, append(t.Metadata.Finalizers, finalizer)); err != nil {
+			return fmt.Errorf("failed to add finalizer: %v", err)
+		}
+	}
+
+	observed := t.Status
+	if t.Status.TreeID == 0 {
+		t.Status.TreeID = r.created[t.Metadata.UID]
+	}
+	status := treeStatus{TreeID: t.Status.TreeID, ObservedGeneration: t.Metadata.Generation}
+	tree, err := r.apply(ctx, t)
+	if err != nil {
+		status.TreeState = t.Status.TreeState
+		status.Message = err.Error()
+	} else {
+		status.TreeID = tree.TreeId
+		status.TreeState = tree.TreeState.String()
+	}
+	if status != observed {
+		if perr := r.kube.patchStatus(ctx, t, status); perr != nil {
+			return fmt.Errorf("failed to update status (tree %d): %v", status.TreeID, perr)
+		}
+	}
+	return err
+}
+
+// apply creates or updates the tree of t.
+func (r *reconciler) apply(ctx context.Context, t *trillianTree) (*trillian.Tree, error) {
+	want, err := t.Spec.tree()
+	if err != nil {
+		return nil, err
+	}
+	if t.Status.TreeID == 0 {
+		tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{Tree: want}, r.admin, r.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tree: %v", err)
+		}
+		r.created[t.Metadata.UID] = tree.TreeId
+		glog.Infof("%s/%s: created tree %d", t.Metadata.Namespace, t.Metadata.Name, tree.TreeId)
+		return tree, nil
+	}
+
+	tree, err := r.admin.GetTree(ctx, &trillian.GetTreeRequest{TreeId: t.Status.TreeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree %d: %v", t.Status.TreeID, err)
+	}
+	var paths []string
+	if tree.TreeType != want.TreeType {
+		paths = append(paths, "tree_type")
+	}
+	if tree.TreeState != want.TreeState {
+		paths = append(paths, "tree_state")
+	}
+	if tree.DisplayName != want.DisplayName {
+		paths = append(paths, "display_name")
+	}
+	if tree.Description != want.Description {
+		paths = append(paths, "description")
+	}
+	if !proto.Equal(tree.MaxRootDuration, want.MaxRootDuration) {
+		paths = append(paths, "max_root_duration")
+	}
+	if tree.GetSequencerGuardWindow().AsDuration() != want.GetSequencerGuardWindow().AsDuration() {
+		paths = append(paths, "sequencer_guard_window")
+	}
+	if tree.SequencerBatchSize != want.SequencerBatchSize {
+		paths = append(paths, "sequencer_batch_size")
+	}
+	if tree.Priority != want.Priority {
+		paths = append(paths, "priority")
+	}
+	if !equalStrings(tree.SignerAffinity, want.SignerAffinity) {
+		paths = append(paths, "signer_affinity")
+	}
+	if tree.AntiAffinityGroup != want.AntiAffinityGroup {
+		paths = append(paths, "anti_affinity_group")
+	}
+	if tree.MaxLeafValueSize != want.MaxLeafValueSize {
+		paths = append(paths, "max_leaf_value_size")
+	}
+	if tree.MaxExtraDataSize != want.MaxExtraDataSize {
+		paths = append(paths, "max_extra_data_size")
+	}
+	if tree.MaxUnsequencedLeaves != want.MaxUnsequencedLeaves {
+		paths = append(paths, "max_unsequenced_leaves")
+	}
+	if tree.CompactRetainedRevisions != want.CompactRetainedRevisions {
+		paths = append(paths, "compact_retained_revisions")
+	}
+	if len(paths) == 0 {
+		return tree, nil
+	}
+	want.TreeId = tree.TreeId
+	tree, err = r.admin.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+		Tree:       want,
+		UpdateMask: &field_mask.FieldMask{Paths: paths},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tree %d: %v", want.TreeId, err)
+	}
+	glog.Infof("%s/%s: updated %v of tree %d", t.Metadata.Namespace, t.Metadata.Name, paths, tree.TreeId)
+	return tree, nil
+}
+
+// finalize applies the deletion policy of t, and then allows Kubernetes to
+// remove it.
+func (r *reconciler) finalize(ctx context.Context, t *trillianTree) error {
+	if !hasFinalizer(t) {
+		return nil
+	}
+	if id := t.Status.TreeID; id != 0 {
+		switch t.Spec.DeletionPolicy {
+		case policyFreeze:
+			if _, err := r.admin.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: id, TreeState: trillian.TreeState_FROZEN},
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_state"}},
+			}); err != nil {
+				return fmt.Errorf("failed to freeze tree %d: %v", id, err)
+			}
+			glog.Infof("%s/%s: froze tree %d", t.Metadata.Namespace, t.Metadata.Name, id)
+		case policyDelete:
+			if _, err := r.admin.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: id}); err != nil {
+				return fmt.Errorf("failed to delete tree %d: %v", id, err)
+			}
+			glog.Infof("%s/%s: deleted tree %d", t.Metadata.Namespace, t.Metadata.Name, id)
+		}
+	}
+	var finalizers []string
+	for _, f := range t.Metadata.Finalizers {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	return r.kube.setFinalizers(ctx, t, finalizers)
+}
+
+func hasFinalizer(t *trillianTree) bool {
+	for _, f := range t.Metadata.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// run reconciles all TrillianTrees, and then each changed TrillianTree, until
+// ctx is done. All TrillianTrees are reconciled again every resync interval.
+func (r *reconciler) run(ctx context.Context, resync time.Duration) {
+	for ctx.Err() == nil {
+		trees, rv, err := r.kube.list(ctx)
+		if err != nil {
+			glog.Warningf("Failed to list TrillianTrees: %v", err)
+		}
+		for _, t := range trees {
+			r.reconcileAndLog(ctx, t)
+		}
+
+		wctx, cancel := context.WithTimeout(ctx, resync)
+		if err == nil {
+			err = r.kube.watch(wctx, rv, func(eventType string, t *trillianTree) {
+				if eventType == "ADDED" || eventType == "MODIFIED" {
+					r.reconcileAndLog(wctx, t)
+				}
+			})
+			if err != nil {
+				glog.Warningf("Failed to watch TrillianTrees: %v", err)
+			}
+		}
+		if err != nil {
+			// Back off until the next resync.
+			<-wctx.Done()
+		}
+		cancel()
+	}
+}
+
+func (r *reconciler) reconcileAndLog(ctx context.Context, t *trillianTree) {
+	if err := r.reconcile(ctx, t); err != nil {
+		glog.Warningf("%s/%s: %v", t.Metadata.Namespace, t.Metadata.Name, err)
+	}
+}
+
+// equalStrings returns whether a and b hold the same strings in the same order,
+// treating nil and empty slices alike.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeKube records the updates made to TrillianTrees.
+type fakeKube struct {
+	kubeAPI
+	statuses   []treeStatus
+	finalizers [][]string
+}
+
+func (f *fakeKube) patchStatus(_ context.Context, _ *trillianTree, status treeStatus) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func (f *fakeKube) setFinalizers(_ context.Context, _ *trillianTree, finalizers []string) error {
+	f.finalizers = append(f.finalizers, finalizers)
+	return nil
+}
+
+// fakeAdmin keeps trees in memory, applying update masks like the admin server.
+type fakeAdmin struct {
+	trillian.TrillianAdminClient
+	trees   map[int64]*trillian.Tree
+	created int
+}
+
+func (f *fakeAdmin) CreateTree(_ context.Context, req *trillian.CreateTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	f.created++
+	t := proto.Clone(req.Tree).(*trillian.Tree)
+	t.TreeId = int64(100 + f.created)
+	f.trees[t.TreeId] = t
+	return t, nil
+}
+
+func (f *fakeAdmin) GetTree(_ context.Context, req *trillian.GetTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	return proto.Clone(f.trees[req.TreeId]).(*trillian.Tree), nil
+}
+
+func (f *fakeAdmin) UpdateTree(_ context.Context, req *trillian.UpdateTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	from, to := req.Tree.ProtoReflect(), f.trees[req.Tree.TreeId].ProtoReflect()
+	for _, path := range req.UpdateMask.Paths {
+		fd := from.Descriptor().Fields().ByTextName(path)
+		to.Set(fd, from.Get(fd))
+	}
+	return proto.Clone(f.trees[req.Tree.TreeId]).(*trillian.Tree), nil
+}
+
+func (f *fakeAdmin) DeleteTree(_ context.Context, req *trillian.DeleteTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	f.trees[req.TreeId].Deleted = true
+	return f.trees[req.TreeId], nil
+}
+
+type fakeLog struct {
+	trillian.TrillianLogClient
+}
+
+func (fakeLog) InitLog(context.Context, *trillian.InitLogRequest, ...grpc.CallOption) (*trillian.InitLogResponse, error) {
+	return &trillian.InitLogResponse{}, nil
+}
+
+func (fakeLog) GetLatestSignedLogRoot(context.Context, *trillian.GetLatestSignedLogRootRequest, ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	return &trillian.GetLatestSignedLogRootResponse{}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	kube := &fakeKube{}
+	admin := &fakeAdmin{trees: make(map[int64]*trillian.Tree)}
+	r := newReconciler(kube, admin, fakeLog{})
+
+	obj := &trillianTree{
+		Metadata: objectMeta{Name: "log", Namespace: "default", UID: "uid-1", Generation: 1},
+		Spec:     treeSpec{DisplayName: "Log", MaxRootDuration: "10m"},
+	}
+	if err := r.reconcile(ctx, obj); err != nil {
+		t.Fatalf("reconcile (create): %v", err)
+	}
+	want := &trillian.Tree{
+		TreeId:          101,
+		TreeType:        trillian.TreeType_LOG,
+		TreeState:       trillian.TreeState_ACTIVE,
+		DisplayName:     "Log",
+		MaxRootDuration: durationpb.New(10 * time.Minute),
+	}
+	if got := admin.trees[101]; !proto.Equal(got, want) {
+		t.Errorf("created tree = %v, want %v", got, want)
+	}
+	if diff := cmp.Diff(kube.finalizers, [][]string{{finalizer}}); diff != "" {
+		t.Errorf("finalizers diff (-got +want):\n%s", diff)
+	}
+	wantStatus := treeStatus{TreeID: 101, TreeState: "ACTIVE", ObservedGeneration: 1}
+	if diff := cmp.Diff(kube.statuses, []treeStatus{wantStatus}); diff != "" {
+		t.Errorf("statuses diff (-got +want):\n%s", diff)
+	}
+
+	// A stale object, without the status, doesn't create another tree.
+	obj.Metadata.Finalizers = []string{finalizer}
+	if err := r.reconcile(ctx, obj); err != nil {
+		t.Fatalf("reconcile (stale): %v", err)
+	}
+	if admin.created != 1 {
+		t.Errorf("created %d trees, want 1", admin.created)
+	}
+
+	// Changing the spec updates the tree.
+	obj.Status = wantStatus
+	obj.Metadata.Generation = 2
+	obj.Spec.TreeState = "FROZEN"
+	obj.Spec.Description = "Frozen"
+	obj.Spec.SequencerGuardWindow = "5s"
+	obj.Spec.SequencerBatchSize = 100
+	obj.Spec.Priority = "HIGH_PRIORITY"
+	obj.Spec.SignerAffinity = []string{"zone-a"}
+	obj.Spec.AntiAffinityGroup = "ct"
+	kube.statuses = nil
+	if err := r.reconcile(ctx, obj); err != nil {
+		t.Fatalf("reconcile (update): %v", err)
+	}
+	want.TreeState = trillian.TreeState_FROZEN
+	want.Description = "Frozen"
+	want.SequencerGuardWindow = durationpb.New(5 * time.Second)
+	want.SequencerBatchSize = 100
+	want.Priority = trillian.TreePriority_HIGH_PRIORITY
+	want.SignerAffinity = []string{"zone-a"}
+	want.AntiAffinityGroup = "ct"
+	if got := admin.trees[101]; !proto.Equal(got, want) {
+		t.Errorf("updated tree = %v, want %v", got, want)
+	}
+	wantStatus = treeStatus{TreeID: 101, TreeState: "FROZEN", ObservedGeneration: 2}
+	if diff := cmp.Diff(kube.statuses, []treeStatus{wantStatus}); diff != "" {
+		t.Errorf("statuses diff (-got +want):\n%s", diff)
+	}
+
+	// Reconciling an up to date object changes nothing.
+	obj.Status = wantStatus
+	kube.statuses = nil
+	if err := r.reconcile(ctx, obj); err != nil {
+		t.Fatalf("reconcile (no-op): %v", err)
+	}
+	if len(kube.statuses) != 0 {
+		t.Errorf("statuses = %v, want none", kube.statuses)
+	}
+
+	// An invalid spec is reported in the status.
+	obj.Metadata.Generation = 3
+	obj.Spec.TreeType = "LLAMA"
+	if err := r.reconcile(ctx, obj); err == nil {
+		t.Error("reconcile (invalid): got nil error, want error")
+	}
+	if len(kube.statuses) != 1 || kube.statuses[0].Message == "" {
+		t.Errorf("statuses = %v, want one with a message", kube.statuses)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	ctx := context.Background()
+	deleted := "2022-01-01T00:00:00Z"
+	for _, tc := range []struct {
+		policy      string
+		wantState   trillian.TreeState
+		wantDeleted bool
+	}{
+		{policy: "", wantState: trillian.TreeState_ACTIVE},
+		{policy: policyRetain, wantState: trillian.TreeState_ACTIVE},
+		{policy: policyFreeze, wantState: trillian.TreeState_FROZEN},
+		{policy: policyDelete, wantState: trillian.TreeState_ACTIVE, wantDeleted: true},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			kube := &fakeKube{}
+			admin := &fakeAdmin{trees: map[int64]*trillian.Tree{
+				7: {TreeId: 7, TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_ACTIVE},
+			}}
+			r := newReconciler(kube, admin, fakeLog{})
+			obj := &trillianTree{
+				Metadata: objectMeta{Name: "log", DeletionTimestamp: &deleted, Finalizers: []string{"other", finalizer}},
+				Spec:     treeSpec{DeletionPolicy: tc.policy},
+				Status:   treeStatus{TreeID: 7},
+			}
+			if err := r.reconcile(ctx, obj); err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+			if got := admin.trees[7]; got.TreeState != tc.wantState || got.Deleted != tc.wantDeleted {
+				t.Errorf("tree: state=%v deleted=%v, want %v %v", got.TreeState, got.Deleted, tc.wantState, tc.wantDeleted)
+			}
+			if diff := cmp.Diff(kube.finalizers, [][]string{{"other"}}); diff != "" {
+				t.Errorf("finalizers diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// runner runs the commands of trillian_client against a log.
+type runner struct {
+	client trillian.TrillianLogClient
+	treeID int64
+	// trusted is the root which the latest root must be consistent with, or
+	// an empty root to trust the latest root as it is.
+	trusted types.LogRootV1
+	// json selects the output of one JSON object per line rather than text.
+	json bool
+	out  io.Writer
+}
+
+// queueResult is the output of the queue command.
+type queueResult struct {
+	LeafHash string `json:"leaf_hash"`
+	// Status is "queued", or "already_exists" for duplicate leaves.
+	Status string `json:"status"`
+}
+
+// inclusionResult is the output of the inclusion command.
+type inclusionResult struct {
+	LeafHash  string          `json:"leaf_hash"`
+	LeafIndex uint64          `json:"leaf_index"`
+	Root      types.LogRootV1 `json:"root"`
+	Hashes    []string        `json:"hashes"`
+}
+
+// consistencyResult is the output of the consistency command.
+type consistencyResult struct {
+	FirstSize      uint64   `json:"first_size"`
+	FirstRootHash  string   `json:"first_root_hash"`
+	SecondSize     uint64   `json:"second_size"`
+	SecondRootHash string   `json:"second_root_hash"`
+	Hashes         []string `json:"hashes"`
+}
+
+// leafResult is the output of the tail command for each leaf.
+type leafResult struct {
+	LeafIndex int64  `json:"leaf_index"`
+	LeafHash  string `json:"leaf_hash"`
+	LeafValue []byte `json:"leaf_value"`
+	ExtraData []byte `json:"extra_data,omitempty"`
+}
+
+// print outputs v as JSON if the runner is configured so, or text otherwise.
+func (r *runner) print(text string, v interface{}) error {
+	if r.json {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	_, err := fmt.Fprintln(r.out, text)
+	return err
+}
+
+// logClient returns a client verifying roots from the trusted root.
+func (r *runner) logClient() *client.LogClient {
+	return client.New(r.treeID, r.client, client.NewLogVerifier(rfc6962.DefaultHasher), r.trusted)
+}
+
+// latestRoot fetches the latest root with c, and verifies that it is
+// consistent with the trusted root of c.
+func (r *runner) latestRoot(ctx context.Context, c *client.LogClient) (*types.LogRootV1, error) {
+	root, err := c.UpdateRoot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch and verify the latest root: %v", err)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("latest root is not newer than the trusted root of size %d", r.trusted.TreeSize)
+	}
+	return root, nil
+}
+
+func (r *runner) queue(ctx context.Context, data []byte) error {
+	leafHash := rfc6962.DefaultHasher.HashLeaf(data)
+	resp, err := r.client.QueueLeaf(ctx, &trillian.QueueLeafRequest{
+		LogId: r.treeID,
+		Leaf:  &trillian.LogLeaf{LeafValue: data, MerkleLeafHash: leafHash},
+	})
+	if err != nil {
+		return fmt.Errorf("QueueLeaf: %v", err)
+	}
+	res := queueResult{LeafHash: hex.EncodeToString(leafHash), Status: "queued"}
+	text := fmt.Sprintf("Queued leaf %x", leafHash)
+	switch s := status.FromProto(resp.GetQueuedLeaf().GetStatus()); s.Code() {
+	case codes.OK:
+	case codes.AlreadyExists:
+		res.Status = "already_exists"
+		text = fmt.Sprintf("Leaf %x already exists", leafHash)
+	default:
+		return fmt.Errorf("QueueLeaf: %v", s.Err())
+	}
+	return r.print(text, res)
+}
+
+func (r *runner) root(ctx context.Context) error {
+	root, err := r.latestRoot(ctx, r.logClient())
+	if err != nil {
+		return err
+	}
+	return r.print(root.String(), root)
+}
+
+func (r *runner) inclusion(ctx context.Context, data []byte) error {
+	c := r.logClient()
+	root, err := r.latestRoot(ctx, c)
+	if err != nil {
+		return err
+	}
+	bundle, err := c.GetProofBundle(ctx, data)
+	if err != nil {
+		return fmt.Errorf("failed to fetch and verify inclusion proof: %v", err)
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(data)
+	text := fmt.Sprintf("Leaf %x is included at index %d in %v", leafHash, bundle.LeafIndex, root)
+	return r.print(text, inclusionResult{
+		LeafHash:  hex.EncodeToString(leafHash),
+		LeafIndex: bundle.LeafIndex,
+		Root:      *root,
+		Hashes:    hexHashes(bundle.InclusionProof),
+	})
+}
+
+func (r *runner) consistency(ctx context.Context, first, second *types.LogRootV1) error {
+	resp, err := r.client.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+		LogId:          r.treeID,
+		FirstTreeSize:  int64(first.TreeSize),
+		SecondTreeSize: int64(second.TreeSize),
+	})
+	if err != nil {
+		return fmt.Errorf("GetConsistencyProof: %v", err)
+	}
+	hashes := resp.GetProof().GetHashes()
+	if err := proof.VerifyConsistency(rfc6962.DefaultHasher, first.TreeSize, second.TreeSize, hashes, first.RootHash, second.RootHash); err != nil {
+		return fmt.Errorf("failed to verify consistency proof from %d->%d %x->%x: %v", first.TreeSize, second.TreeSize, first.RootHash, second.RootHash, err)
+	}
+	text := fmt.Sprintf("Root of size %d is consistent with root of size %d", second.TreeSize, first.TreeSize)
+	return r.print(text, consistencyResult{
+		FirstSize:      first.TreeSize,
+		FirstRootHash:  hex.EncodeToString(first.RootHash),
+		SecondSize:     second.TreeSize,
+		SecondRootHash: hex.EncodeToString(second.RootHash),
+		Hashes:         hexHashes(hashes),
+	})
+}
+
+// tail outputs the leaves from index start as the root of the log grows,
+// requesting at most batchSize leaves at a time. It starts at the current tree
+// size if start is negative, and returns after maxLeaves leaves if not zero, or
+// when ctx is done.
+func (r *runner) tail(ctx context.Context, start, batchSize int64, maxLeaves uint64) error {
+	c := r.logClient()
+	root, err := r.latestRoot(ctx, c)
+	if err != nil {
+		return err
+	}
+	next := start
+	if next < 0 {
+		next = int64(root.TreeSize)
+	}
+	var count uint64
+	for {
+		for size := int64(c.GetRoot().TreeSize); next < size; {
+			n := size - next
+			if n > batchSize {
+				n = batchSize
+			}
+			if maxLeaves > 0 && uint64(n) > maxLeaves-count {
+				n = int64(maxLeaves - count)
+			}
+			leaves, err := c.ListByIndex(ctx, next, n)
+			if err != nil {
+				return fmt.Errorf("failed to get leaves [%d, %d): %v", next, next+n, err)
+			}
+			for _, leaf := range leaves[:n] {
+				if want := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue); !bytes.Equal(leaf.MerkleLeafHash, want) {
+					return fmt.Errorf("leaf %d has hash %x, want %x", leaf.LeafIndex, leaf.MerkleLeafHash, want)
+				}
+				text := fmt.Sprintf("%d %x %q", leaf.LeafIndex, leaf.MerkleLeafHash, leaf.LeafValue)
+				if err := r.print(text, leafResult{
+					LeafIndex: leaf.LeafIndex,
+					LeafHash:  hex.EncodeToString(leaf.MerkleLeafHash),
+					LeafValue: leaf.LeafValue,
+					ExtraData: leaf.ExtraData,
+				}); err != nil {
+					return err
+				}
+			}
+			next += n
+			if count += uint64(n); maxLeaves > 0 && count >= maxLeaves {
+				return nil
+			}
+		}
+		if _, err := c.WaitForRootUpdate(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to fetch and verify the latest root: %v", err)
+		}
+	}
+}
+
+// parseRoot returns the root of the given tree size and hex encoded root hash.
+func parseRoot(size, hash string) (*types.LogRootV1, error) {
+	treeSize, err := strconv.ParseUint(size, 10, 63)
+	if err != nil || treeSize == 0 {
+		return nil, fmt.Errorf("invalid tree size %q, want a positive integer", size)
+	}
+	rootHash, err := hex.DecodeString(hash)
+	if err != nil || len(rootHash) == 0 {
+		return nil, fmt.Errorf("invalid root hash %q, want a hex encoded hash", hash)
+	}
+	return &types.LogRootV1{TreeSize: treeSize, RootHash: rootHash}, nil
+}
+
+func hexHashes(hashes [][]byte) []string {
+	ret := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		ret = append(ret, hex.EncodeToString(h))
+	}
+	return ret
+}
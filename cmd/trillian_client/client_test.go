@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestRunner(l *testonly.FakeLog, json bool) (*runner, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &runner{client: l.Client(), treeID: 1, json: json, out: &out}, &out
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	l := testonly.NewFakeLog(2)
+	r, out := newTestRunner(l, true)
+	hash := hex.EncodeToString(rfc6962.DefaultHasher.HashLeaf([]byte("new")))
+	if err := r.queue(ctx, []byte("new")); err != nil {
+		t.Fatalf("queue(): %v", err)
+	}
+	if err := r.queue(ctx, []byte("new")); err != nil {
+		t.Fatalf("queue() of duplicate: %v", err)
+	}
+	want := fmt.Sprintf("{\"leaf_hash\":%q,\"status\":\"queued\"}\n{\"leaf_hash\":%q,\"status\":\"already_exists\"}\n", hash, hash)
+	if got := out.String(); got != want {
+		t.Errorf("queue() output %q, want %q", got, want)
+	}
+	if got, want := l.Tree.Size(), uint64(3); got != want {
+		t.Errorf("tree size %d, want %d", got, want)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	ctx := context.Background()
+	l := testonly.NewFakeLog(5)
+	r, out := newTestRunner(l, false)
+	if err := r.root(ctx); err != nil {
+		t.Fatalf("root(): %v", err)
+	}
+	if want := fmt.Sprintf("TreeSize: 5, RootHash: %x", l.Tree.Hash()); !strings.Contains(out.String(), want) {
+		t.Errorf("root() output %q, want it to contain %q", out.String(), want)
+	}
+
+	r.trusted = types.LogRootV1{TreeSize: 3, RootHash: l.Tree.HashAt(3)}
+	if err := r.root(ctx); err != nil {
+		t.Errorf("root() with consistent trusted root: %v", err)
+	}
+	r.trusted = types.LogRootV1{TreeSize: 3, RootHash: l.Tree.HashAt(2)}
+	if err := r.root(ctx); err == nil {
+		t.Error("root() with inconsistent trusted root succeeded, want error")
+	}
+}
+
+func TestInclusion(t *testing.T) {
+	ctx := context.Background()
+	l := testonly.NewFakeLog(5)
+	r, out := newTestRunner(l, false)
+	if err := r.inclusion(ctx, []byte("leaf 3")); err != nil {
+		t.Fatalf("inclusion(): %v", err)
+	}
+	if want := fmt.Sprintf("Leaf %x is included at index 3", l.Tree.LeafHash(3)); !strings.HasPrefix(out.String(), want) {
+		t.Errorf("inclusion() output %q, want prefix %q", out.String(), want)
+	}
+	if err := r.inclusion(ctx, []byte("missing")); err == nil {
+		t.Error("inclusion() of missing leaf succeeded, want error")
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	ctx := context.Background()
+	l := testonly.NewFakeLog(7)
+	r, out := newTestRunner(l, true)
+	first := &types.LogRootV1{TreeSize: 3, RootHash: l.Tree.HashAt(3)}
+	second := &types.LogRootV1{TreeSize: 7, RootHash: l.Tree.HashAt(7)}
+	if err := r.consistency(ctx, first, second); err != nil {
+		t.Fatalf("consistency(): %v", err)
+	}
+	if want := fmt.Sprintf(`{"first_size":3,"first_root_hash":"%x","second_size":7,"second_root_hash":"%x","hashes":[`, first.RootHash, second.RootHash); !strings.HasPrefix(out.String(), want) {
+		t.Errorf("consistency() output %q, want prefix %q", out.String(), want)
+	}
+
+	second.RootHash = l.Tree.HashAt(6)
+	if err := r.consistency(ctx, first, second); err == nil {
+		t.Error("consistency() with wrong root hash succeeded, want error")
+	}
+}
+
+func TestTail(t *testing.T) {
+	ctx := context.Background()
+	l := testonly.NewFakeLog(3)
+	r, out := newTestRunner(l, false)
+	// The log grows by a leaf before serving each root.
+	f := r.client.(*testonly.FakeLogClient)
+	f.Handle("GetLatestSignedLogRoot", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		l.Add([]byte(fmt.Sprintf("leaf %d", l.Tree.Size())))
+		return l.GetLatestSignedLogRoot(req.(*trillian.GetLatestSignedLogRootRequest))
+	})
+	if err := r.tail(ctx, 2, 2, 4); err != nil {
+		t.Fatalf("tail(): %v", err)
+	}
+	var want string
+	for i := 2; i < 6; i++ {
+		value := fmt.Sprintf("leaf %d", i)
+		want += fmt.Sprintf("%d %x %q\n", i, rfc6962.DefaultHasher.HashLeaf([]byte(value)), value)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("tail() output %q, want %q", got, want)
+	}
+
+	// Tailing stops without error when ctx is done while waiting for the log
+	// to grow.
+	r, _ = newTestRunner(l, false)
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := r.tail(cctx, -1, 10, 0); err != nil {
+		t.Errorf("tail() until canceled: %v", err)
+	}
+}
+
+func TestParseRoot(t *testing.T) {
+	root, err := parseRoot("12", "abcd")
+	if err != nil {
+		t.Fatalf("parseRoot(): %v", err)
+	}
+	if root.TreeSize != 12 || !bytes.Equal(root.RootHash, []byte{0xab, 0xcd}) {
+		t.Errorf("parseRoot() = %v, want size 12 and hash abcd", root)
+	}
+	for _, test := range []struct{ size, hash string }{
+		{"0", "abcd"},
+		{"-1", "abcd"},
+		{"x", "abcd"},
+		{"12", ""},
+		{"12", "xyz"},
+	} {
+		if _, err := parseRoot(test.size, test.hash); err == nil {
+			t.Errorf("parseRoot(%q, %q) succeeded, want error", test.size, test.hash)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// trillian_client command, which lets operators query and write to a log.
+//
+// Example usage:
+// $ ./trillian_client --log_server=host:port --tree_id=123456789 <command> [args]
+//
+// The commands are:
+//
+//	queue FILE
+//	    Queues the contents of FILE, or of stdin if FILE is -, as a leaf.
+//	root
+//	    Fetches the latest root. With --trusted_size and --trusted_root_hash,
+//	    verifies that it is consistent with that trusted root.
+//	inclusion FILE
+//	    Fetches the latest root as root does, and verifies the inclusion proof
+//	    of the contents of FILE in it.
+//	consistency SIZE1 HASH1 SIZE2 HASH2
+//	    Verifies the consistency proof between two roots, given by their tree
+//	    sizes and hex encoded root hashes.
+//	tail
+//	    Outputs the leaves added to the log as its root grows, verifying that
+//	    each root is consistent with the previous one. Starts at --start_index,
+//	    or at the current tree size, and stops after --max_leaves leaves, if set,
+//	    or when interrupted.
+//
+// Leaf hashes are computed as RFC 6962 specifies. The command outputs its
+// results to stdout as text, or as one JSON object per line with
+// --output=json, and fails if any verification fails.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+)
+
+var (
+	logServerAddr   = flag.String("log_server", "", "Address of the gRPC Trillian Log Server (host:port)")
+	treeID          = flag.Int64("tree_id", 0, "The ID of the tree to query")
+	rpcDeadline     = flag.Duration("rpc_deadline", time.Minute, "Deadline for commands other than tail")
+	output          = flag.String("output", outputText, "Output format. One of: text, json")
+	trustedSize     = flag.Uint64("trusted_size", 0, "If set, tree size of a trusted root which the latest root must be consistent with")
+	trustedRootHash = flag.String("trusted_root_hash", "", "Hex encoded root hash of the trusted root of size --trusted_size")
+	startIndex      = flag.Int64("start_index", -1, "Index of the first leaf output by tail. If negative, tail starts at the current tree size")
+	batchSize       = flag.Int64("batch_size", 1000, "Max number of leaves to request per GetLeavesByRange call by tail")
+	maxLeaves       = flag.Uint64("max_leaves", 0, "If set, number of leaves after which tail stops")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+func run(ctx context.Context, args []string) error {
+	if *logServerAddr == "" {
+		return errors.New("empty --log_server, please provide the Log server host:port")
+	}
+	if *treeID == 0 {
+		return errors.New("empty --tree_id, please provide the ID of the tree to query")
+	}
+	if len(args) == 0 {
+		return errors.New("no command, want one of: queue, root, inclusion, consistency, tail")
+	}
+	if *output != outputText && *output != outputJSON {
+		return fmt.Errorf("unknown --output %q, want text or json", *output)
+	}
+	trusted, err := trustedRoot()
+	if err != nil {
+		return err
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	conn, err := grpc.Dial(*logServerAddr, dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *logServerAddr, err)
+	}
+	defer conn.Close()
+
+	r := &runner{
+		client:  trillian.NewTrillianLogClient(conn),
+		treeID:  *treeID,
+		trusted: trusted,
+		json:    *output == outputJSON,
+		out:     os.Stdout,
+	}
+	name, args := args[0], args[1:]
+	if name == "tail" {
+		if len(args) != 0 {
+			return errors.New("usage: tail")
+		}
+		if *batchSize <= 0 {
+			return fmt.Errorf("--batch_size must be positive, got %d", *batchSize)
+		}
+		return r.tail(ctx, *startIndex, *batchSize, *maxLeaves)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *rpcDeadline)
+	defer cancel()
+	switch name {
+	case "queue":
+		if len(args) != 1 {
+			return errors.New("usage: queue FILE")
+		}
+		data, err := readFile(args[0])
+		if err != nil {
+			return err
+		}
+		return r.queue(ctx, data)
+	case "root":
+		if len(args) != 0 {
+			return errors.New("usage: root")
+		}
+		return r.root(ctx)
+	case "inclusion":
+		if len(args) != 1 {
+			return errors.New("usage: inclusion FILE")
+		}
+		data, err := readFile(args[0])
+		if err != nil {
+			return err
+		}
+		return r.inclusion(ctx, data)
+	case "consistency":
+		if len(args) != 4 {
+			return errors.New("usage: consistency SIZE1 HASH1 SIZE2 HASH2")
+		}
+		first, err := parseRoot(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		second, err := parseRoot(args[2], args[3])
+		if err != nil {
+			return err
+		}
+		return r.consistency(ctx, first, second)
+	default:
+		return fmt.Errorf("unknown command %q, want one of: queue, root, inclusion, consistency, tail", name)
+	}
+}
+
+// trustedRoot returns the root set with --trusted_size and
+// --trusted_root_hash, which is empty if they aren't set.
+func trustedRoot() (types.LogRootV1, error) {
+	if *trustedSize == 0 {
+		if *trustedRootHash != "" {
+			return types.LogRootV1{}, errors.New("--trusted_root_hash is set without --trusted_size")
+		}
+		return types.LogRootV1{}, nil
+	}
+	hash, err := hex.DecodeString(*trustedRootHash)
+	if err != nil || len(hash) == 0 {
+		return types.LogRootV1{}, fmt.Errorf("invalid --trusted_root_hash %q, want the hex encoded root hash of the trusted root", *trustedRootHash)
+	}
+	return types.LogRootV1{TreeSize: *trustedSize, RootHash: hash}, nil
+}
+
+// readFile returns the contents of the named file, or of stdin if name is -.
+func readFile(name string) ([]byte, error) {
+	if name == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %v", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaf: %v", err)
+	}
+	return data, nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.AwaitSignal(ctx, cancel)
+
+	if err := run(ctx, flag.Args()); err != nil {
+		glog.Exitf("Failed to run command: %v", err)
+	}
+}
@@ -29,57 +29,123 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/cmd/internal/provider"
 	"github.com/google/trillian/cmd/internal/serverutil"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/diagnostics"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/monitoring/opencensus"
 	"github.com/google/trillian/monitoring/prometheus"
+	"github.com/google/trillian/monitoring/statsd"
 	"github.com/google/trillian/quota"
 	"github.com/google/trillian/quota/etcd"
 	"github.com/google/trillian/quota/etcd/quotaapi"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/server/backlog"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/server/metering"
+	"github.com/google/trillian/server/notify"
+	"github.com/google/trillian/server/readcache"
+	"github.com/google/trillian/server/validator"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/encrypted"
 	"github.com/google/trillian/util"
 	"github.com/google/trillian/util/clock"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"github.com/google/trillian/util/compression"
+	"github.com/google/trillian/util/election2"
 	"google.golang.org/grpc"
 
-	// Register supported storage providers.
-	_ "github.com/google/trillian/storage/cloudspanner"
-	_ "github.com/google/trillian/storage/mysql"
+	// Register supported leaf blob stores.
+	_ "github.com/google/trillian/storage/blob/azblob"
+	_ "github.com/google/trillian/storage/blob/gcs"
+	_ "github.com/google/trillian/storage/blob/s3"
 
-	// Load MySQL quota provider
-	_ "github.com/google/trillian/quota/mysqlqm"
+	// Register supported leaf encryption KMSs.
+	_ "github.com/google/trillian/crypto/envelope/awskms"
+	_ "github.com/google/trillian/crypto/envelope/gcpkms"
 )
 
 var (
-	rpcEndpoint     = flag.String("rpc_endpoint", "localhost:8090", "Endpoint for RPC requests (host:port)")
-	httpEndpoint    = flag.String("http_endpoint", "localhost:8091", "Endpoint for HTTP metrics (host:port, empty means disabled)")
-	healthzTimeout  = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
-	tlsCertFile     = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
-	tlsKeyFile      = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
-	etcdService     = flag.String("etcd_service", "trillian-logserver", "Service name to announce ourselves under")
-	etcdHTTPService = flag.String("etcd_http_service", "trillian-logserver-http", "Service name to announce our HTTP endpoint under")
+	rpcEndpoint        = flag.String("rpc_endpoint", "localhost:8090", "Comma-separated endpoints for RPC requests (host:port, or unix:///path for a Unix domain socket)")
+	httpEndpoint       = flag.String("http_endpoint", "localhost:8091", "Comma-separated endpoints for HTTP metrics (host:port or unix:///path, empty means disabled)")
+	adminRPCEndpoint   = flag.String("admin_rpc_endpoint", "", "If set, comma-separated endpoints to serve the Admin API on instead of --rpc_endpoint, e.g. localhost:8092")
+	healthzTimeout     = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
+	healthzProbeTreeID = flag.Int64("healthz_probe_tree_id", 0, "If set, health checks read the latest root of this log, which must be at most its max_root_duration plus --healthz_probe_slack old, rather than only pinging the database")
+	healthzProbeSlack  = flag.Duration("healthz_probe_slack", time.Minute, "Age above the max_root_duration of the --healthz_probe_tree_id log at which its root makes health checks fail")
+	tlsCertFile        = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
+	tlsKeyFile         = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
+	tlsReloadInterval  = flag.Duration("tls_reload_interval", serverutil.DefaultTLSReloadInterval, "Interval at which --tls_cert_file and --tls_key_file are checked for changes, which are served without a restart")
+	etcdService        = flag.String("etcd_service", "trillian-logserver", "Service name to announce ourselves under")
+	etcdHTTPService    = flag.String("etcd_http_service", "trillian-logserver-http", "Service name to announce our HTTP endpoint under")
 
-	quotaSystem = flag.String("quota_system", "mysql", fmt.Sprintf("Quota system to use. One of: %v", quota.Providers()))
-	quotaDryRun = flag.Bool("quota_dry_run", false, "If true no requests are blocked due to lack of tokens")
+	quotaSystem        = flag.String("quota_system", "mysql", fmt.Sprintf("Quota system to use. One of: %v", quota.Providers()))
+	quotaDryRun        = flag.Bool("quota_dry_run", false, "If true no requests are blocked due to lack of tokens")
+	quotaDryRunGroups  = flag.String("quota_dry_run_groups", "", "Comma-separated quota groups whose lack of tokens doesn't block requests, e.g. \"user,tenant\". Requests that would have been denied are counted by the interceptor_request_dry_run_denied_count metric")
+	quotaBytesPerToken = flag.String("quota_bytes_per_token", "", "Comma-separated quota specs charged by size rather than by count, in the \"group[/kind]=bytes\" format, e.g. \"tenant/write=1024\". Requests are charged one token per the given number of bytes of their leaves, or of their responses for reads")
 
-	storageSystem = flag.String("storage_system", "mysql", fmt.Sprintf("Storage system to use. One of: %v", storage.Providers()))
+	defaultRPCDeadline = flag.Duration("default_rpc_deadline", 0, "Deadline of the RPCs received without one, zero means --max_rpc_deadline")
+	maxRPCDeadline     = flag.Duration("max_rpc_deadline", 0, "Maximum deadline of RPCs, above which requests are rejected with INVALID_ARGUMENT; zero means unlimited. Streaming RPCs aren't limited")
+	authIssuers        = flag.String("auth_oidc_issuers", "", "If set, comma-separated URLs of the OIDC issuers whose JWT bearer tokens authenticate RPCs. Requests with invalid tokens are rejected, and the token subject is charged for user quota")
+	authAudiences      = flag.String("auth_audiences", "", "Comma-separated audiences, one of which the tokens of --auth_oidc_issuers must be issued for")
+	authAnonymousReads = flag.Bool("auth_anonymous_reads", false, "If true, with --auth_oidc_issuers, requests without a token may still call the RPCs which don't write to storage")
+	authTenantClaim    = flag.String("auth_tenant_claim", "", "Claim of the tokens of --auth_oidc_issuers naming the tenants charged for requests, instead of their x-trillian-tenant metadata. If unset, authenticated requests are charged to no tenant")
+	authRoleClaim      = flag.String("auth_role_claim", "roles", "Claim of the tokens of --auth_oidc_issuers naming the roles of their subject")
+	authAdminRoles     = flag.String("auth_admin_roles", "", "Comma-separated roles of --auth_role_claim allowed to call the TrillianAdmin RPCs. If unset, no one can call them when --auth_oidc_issuers is set")
+	adminAuditLogID    = flag.Int64("admin_audit_log_id", 0, "If set, ID of a log of this deployment to which the tree and quota changes made through the admin RPCs are appended, as JSON entries. The log must be created and initialized beforehand, and can't be deleted")
+	rpcCompressors     = flag.String("rpc_compressors", "gzip,zstd", fmt.Sprintf("Comma-separated compressors accepted for RPC messages, which are also used to compress the responses. Any of: %v. gzip is always accepted", compression.Names()))
+
+	storageSystem = flag.String("storage_system", provider.DefaultStorageSystem(), fmt.Sprintf("Storage system to use. One of: %v", storage.Providers()))
+	readOnly      = flag.Bool("read_only", false, "If true, RPCs which write to storage are rejected with PERMISSION_DENIED, and tree GC is disabled")
+
+	leafValidators   = flag.String("leaf_validators", "", fmt.Sprintf("Comma-separated leaf validators to run, in order, before leaves are written. Any of: %v", validator.Validators()))
+	maxLeafValueSize = flag.Int("max_leaf_value_size", 0, "Maximum size in bytes of the LeafValue of queued leaves, for trees which don't set their own, zero means unlimited")
+	maxExtraDataSize = flag.Int("max_extra_data_size", 0, "Maximum size in bytes of the ExtraData of queued leaves, for trees which don't set their own, zero means unlimited")
+
+	maxUnsequencedLeaves   = flag.Int64("max_unsequenced_leaves", 0, "Maximum number of unsequenced leaves of a log, above which QueueLeaf requests are rejected with RESOURCE_EXHAUSTED, for trees which don't set their own; zero means unlimited")
+	backlogRetryAfter      = flag.Duration("backlog_retry_after", backlog.DefaultRetryAfter, "Delay after which clients rejected due to --max_unsequenced_leaves may retry, sent in the retry-after response header")
+	backlogRefreshInterval = flag.Duration("backlog_refresh_interval", backlog.DefaultRefreshInterval, "Minimum interval between two counts of the unsequenced leaves of a log")
+
+	maxRequestBytes   = flag.Int("max_request_bytes", 0, "Maximum serialized size in bytes of the requests to a log, above which they are rejected with INVALID_ARGUMENT; zero means unlimited")
+	maxBatchLeaves    = flag.Int("max_batch_leaves", 0, "Maximum number of leaves written by a request to a log, above which it is rejected with INVALID_ARGUMENT; zero means unlimited")
+	maxInFlightBytes  = flag.Int64("max_in_flight_request_bytes", 0, "Maximum total size in bytes of the unary requests of a caller to a log being handled at once, above which they are rejected with RESOURCE_EXHAUSTED; zero means unlimited")
+	treeRequestLimits = flag.String("tree_request_limits", "", "Comma-separated per-tree overrides of --max_request_bytes, --max_batch_leaves and --max_in_flight_request_bytes, as treeID:maxRequestBytes:maxBatchLeaves:maxInFlightBytes")
+
+	readCache        = flag.String("read_cache", "", "If set, caches the responses of read requests to logs in: memory (optionally memory:?max_bytes=N), redis://host:port or memcache://host:port[,host:port...]. Log signers must use the same Redis or memcached cache to invalidate the roots of the logs they grow")
+	readCacheRootTTL = flag.Duration("read_cache_root_ttl", readcache.DefaultRootTTL, "Time for which the latest root of a log is cached, which bounds its staleness")
+	readCacheTTL     = flag.Duration("read_cache_ttl", readcache.DefaultTTL, "Time for which proofs and leaves are cached")
+
+	stallCheckInterval = flag.Duration("webhook_stall_check_interval", notify.DefaultStallCheckInterval, "Interval between checks of the logs for stalled sequencing, reported to --webhook_urls; zero disables the checks, e.g. on all but one log server")
 
 	treeGCEnabled            = flag.Bool("tree_gc", true, "If true, tree garbage collection (hard-deletion) is periodically performed")
 	treeDeleteThreshold      = flag.Duration("tree_delete_threshold", serverutil.DefaultTreeDeleteThreshold, "Minimum period a tree has to remain deleted before being hard-deleted")
 	treeDeleteMinRunInterval = flag.Duration("tree_delete_min_run_interval", serverutil.DefaultTreeDeleteMinInterval, "Minimum interval between tree garbage collection sweeps. Actual runs happen randomly between [minInterval,2*minInterval).")
 
+	embeddedSigner       = flag.Bool("embedded_signer", false, "If true, the log signer runs in this process too, sharing its storage, and sequences all the logs without master election. This process must then be the only log server and signer using the storage")
+	batchSize            = flag.Int("batch_size", 1000, "Max number of leaves to process per batch, for trees which don't set their own, with --embedded_signer")
+	numSequencers        = flag.Int("num_sequencers", 10, "Number of sequencer workers to run in parallel, with --embedded_signer")
+	sequencerInterval    = flag.Duration("sequencer_interval", 100*time.Millisecond, "Time between each sequencing pass through all logs, with --embedded_signer")
+	sequencerGuardWindow = flag.Duration("sequencer_guard_window", 0, "If set, the time elapsed before submitted leaves are eligible for sequencing, for trees which don't set their own, with --embedded_signer")
+	shutdownGracePeriod  = flag.Duration("shutdown_grace_period", 30*time.Second, "Time given to the in-progress sequencing pass to finish on shutdown, after which it's aborted, with --embedded_signer")
+
 	tracing          = flag.Bool("tracing", false, "If true opencensus Stackdriver tracing will be enabled. See https://opencensus.io/.")
 	tracingProjectID = flag.String("tracing_project_id", "", "project ID to pass to stackdriver. Can be empty for GCP, consult docs for other platforms.")
 	tracingPercent   = flag.Int("tracing_percent", 0, "Percent of requests to be traced. Zero is a special case to use the DefaultSampler")
 
+	logFormat = flag.String("log_format", logging.FormatGlog, fmt.Sprintf("Format of the structured logs of the RPC handlers. One of: %v", logging.Formats))
+	logLevel  = flag.String("log_level", "info", "Minimum level of the structured logs of the RPC handlers: debug, info, warn or error")
+	logLevels = flag.String("log_levels", "", "Comma-separated per-subsystem overrides of --log_level, as subsystem=level, e.g. interceptor=debug")
+
 	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
 
 	// Profiling related flags.
-	cpuProfile = flag.String("cpuprofile", "", "If set, write CPU profile to this file")
-	memProfile = flag.String("memprofile", "", "If set, write memory profile to this file")
+	cpuProfile        = flag.String("cpuprofile", "", "If set, write CPU profile to this file")
+	memProfile        = flag.String("memprofile", "", "If set, write memory profile to this file")
+	slowRPCThreshold  = flag.Duration("slow_rpc_threshold", 0, "If set, with --slow_op_profile_dest, profiles are captured while a unary RPC runs longer than this")
+	slowPassThreshold = flag.Duration("slow_sequencing_pass_threshold", 0, "If set, with --slow_op_profile_dest, profiles are captured while a sequencing pass over a log runs longer than this, with --embedded_signer")
 )
 
 func main() {
@@ -92,12 +158,55 @@ func main() {
 		}
 	}
 
+	if err := configureLogging(); err != nil {
+		glog.Exitf("Invalid logging flags: %v", err)
+	}
+
+	compressors, err := compression.ParseNames(*rpcCompressors)
+	if err != nil {
+		glog.Exitf("Invalid --rpc_compressors: %v", err)
+	}
+	if err := compression.Register(compressors...); err != nil {
+		glog.Exitf("Failed to register compressors: %v", err)
+	}
+	if *embeddedSigner && *readOnly {
+		glog.Exit("--embedded_signer can't be used with --read_only")
+	}
+	var authenticator *interceptor.Authenticator
+	if *authIssuers != "" {
+		if *authAudiences == "" {
+			glog.Exit("--auth_oidc_issuers needs --auth_audiences")
+		}
+		var issuers []interceptor.OIDCIssuer
+		for _, url := range strings.Split(*authIssuers, ",") {
+			issuers = append(issuers, interceptor.OIDCIssuer{URL: url, Audiences: strings.Split(*authAudiences, ",")})
+		}
+		var adminRoles []string
+		if *authAdminRoles != "" {
+			adminRoles = strings.Split(*authAdminRoles, ",")
+		}
+		authenticator, err = interceptor.NewAuthenticator(interceptor.AuthOptions{
+			Issuers:        issuers,
+			AnonymousReads: *authAnonymousReads,
+			TenantClaim:    *authTenantClaim,
+			RoleClaim:      *authRoleClaim,
+			AdminRoles:     adminRoles,
+		})
+		if err != nil {
+			glog.Exitf("Invalid authentication flags: %v", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go util.AwaitSignal(ctx, cancel)
 
 	var options []grpc.ServerOption
-	mf := prometheus.MetricFactory{}
+	mf, closeMetrics, err := statsd.NewMetricFactoryFromFlags(prometheus.MetricFactory{})
+	if err != nil {
+		glog.Exitf("Failed to initialize StatsD metrics: %v", err)
+	}
+	defer closeMetrics()
 	monitoring.SetStartSpan(opencensus.StartSpan)
 
 	if *tracing {
@@ -114,15 +223,17 @@ func main() {
 		glog.Exitf("Failed to get storage provider: %v", err)
 	}
 	defer sp.Close()
+	logStorage, closeEncryption, err := encrypted.NewLogStorageFromFlags(sp.LogStorage())
+	if err != nil {
+		glog.Exitf("Failed to set up leaf encryption: %v", err)
+	}
+	defer closeEncryption()
 
-	var client *clientv3.Client
-	if servers := *etcd.Servers; servers != "" {
-		if client, err = clientv3.New(clientv3.Config{
-			Endpoints:   strings.Split(servers, ","),
-			DialTimeout: 5 * time.Second,
-		}); err != nil {
-			glog.Exitf("Failed to connect to etcd at %v: %v", servers, err)
-		}
+	client, err := etcd.NewClientFromFlags()
+	if err != nil {
+		glog.Exitf("Failed to connect to etcd: %v", err)
+	}
+	if client != nil {
 		defer client.Close()
 	}
 
@@ -139,12 +250,107 @@ func main() {
 	if err != nil {
 		glog.Exitf("Error creating quota manager: %v", err)
 	}
+	dryRunGroups, err := quota.ParseGroups(*quotaDryRunGroups)
+	if err != nil {
+		glog.Exitf("Invalid --quota_dry_run_groups: %v", err)
+	}
+	byteCosts, err := quota.ParseByteCosts(*quotaBytesPerToken)
+	if err != nil {
+		glog.Exitf("Invalid --quota_bytes_per_token: %v", err)
+	}
+
+	// Trees may set their own size limits, so they're always checked.
+	def := validator.SizeLimit{MaxLeafValueSize: *maxLeafValueSize, MaxExtraDataSize: *maxExtraDataSize}
+	validators := []validator.LeafValidator{validator.NewSizeLimits(def, mf)}
+	if *leafValidators != "" {
+		v, err := validator.New(strings.Split(*leafValidators, ","))
+		if err != nil {
+			glog.Exitf("Error creating leaf validators: %v", err)
+		}
+		validators = append(validators, v)
+	}
+	lv := validator.Chain(validators...)
+
+	// Trees may set their own backlog limits, so the limiter is installed
+	// whenever the storage can count unsequenced leaves.
+	var bl *backlog.Limiter
+	if counter, ok := sp.LogStorage().(storage.UnsequencedCounter); ok {
+		bl = backlog.NewLimiter(counter, *maxUnsequencedLeaves, mf)
+		bl.RetryAfter = *backlogRetryAfter
+		bl.RefreshInterval = *backlogRefreshInterval
+	} else if *maxUnsequencedLeaves > 0 {
+		glog.Exitf("Storage system %q can't count unsequenced leaves, required by --max_unsequenced_leaves", *storageSystem)
+	}
+
+	var rl *interceptor.RequestLimiter
+	perTreeRequestLimits, err := interceptor.ParseRequestLimits(*treeRequestLimits)
+	if err != nil {
+		glog.Exitf("Invalid --tree_request_limits: %v", err)
+	}
+	if *maxRequestBytes > 0 || *maxBatchLeaves > 0 || *maxInFlightBytes > 0 || len(perTreeRequestLimits) > 0 {
+		rl = interceptor.NewRequestLimiter(defaultRequestLimit(), perTreeRequestLimits, mf)
+	}
+
+	if *configFile != "" {
+		reloader, err := newConfigReloader(qm, bl, rl)
+		if err != nil {
+			glog.Exitf("Failed to watch config file %q: %v", *configFile, err)
+		}
+		go reloader.Run(ctx)
+	}
+
+	var rc *readcache.Cache
+	if *readCache != "" {
+		store, err := readcache.OpenStore(*readCache)
+		if err != nil {
+			glog.Exitf("Failed to open read cache: %v", err)
+		}
+		rc = readcache.New(store, readcache.Options{RootTTL: *readCacheRootTTL, TTL: *readCacheTTL}, mf)
+		defer rc.Close()
+	}
+
+	meter, err := metering.NewMeterFromFlags(ctx, mf)
+	if err != nil {
+		glog.Exitf("Failed to set up metering: %v", err)
+	}
+	if meter != nil {
+		// Export the last, partial, window once the server has stopped.
+		defer func() {
+			if err := meter.Close(); err != nil {
+				glog.Warningf("Failed to export the last metering window: %v", err)
+			}
+		}()
+		go meter.Run(ctx)
+	}
+
+	notifier, err := notify.NewNotifierFromFlags(mf)
+	if err != nil {
+		glog.Exitf("Failed to set up webhook notifications: %v", err)
+	}
+	if notifier != nil {
+		go notifier.Run(ctx)
+		if *stallCheckInterval > 0 {
+			go notifier.MonitorStalls(ctx, sp.AdminStorage(), logStorage, *stallCheckInterval)
+		}
+	}
 
 	registry := extension.Registry{
-		AdminStorage:  sp.AdminStorage(),
-		LogStorage:    sp.LogStorage(),
-		QuotaManager:  qm,
-		MetricFactory: mf,
+		AdminStorage:   sp.AdminStorage(),
+		LogStorage:     logStorage,
+		QuotaManager:   qm,
+		LeafValidator:  lv,
+		BacklogLimiter: bl,
+		ReadCache:      rc,
+		Meter:          meter,
+		Notifier:       notifier,
+		MetricFactory:  mf,
+	}
+
+	if *adminAuditLogID != 0 {
+		// Chained after the server's own interceptors, so that requests are
+		// authenticated first.
+		auditLog := admin.NewAuditLog(registry, *adminAuditLogID, clock.System)
+		options = append(options, grpc.ChainUnaryInterceptor(auditLog.UnaryInterceptor))
 	}
 
 	// Enable CPU profile if requested.
@@ -154,16 +360,42 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	capturer, err := diagnostics.NewCapturerFromFlags(ctx)
+	if err != nil {
+		glog.Exitf("Failed to set up profiling of slow operations: %v", err)
+	}
+	if capturer != nil {
+		defer capturer.Close()
+		if *slowRPCThreshold > 0 {
+			options = append(options, grpc.ChainUnaryInterceptor(capturer.UnaryInterceptor(*slowRPCThreshold)))
+		}
+	}
+
+	keepalive, err := serverutil.KeepaliveFromFlags()
+	if err != nil {
+		glog.Exitf("Invalid keepalive flags: %v", err)
+	}
+
 	m := serverutil.Main{
-		RPCEndpoint:  *rpcEndpoint,
-		HTTPEndpoint: *httpEndpoint,
-		TLSCertFile:  *tlsCertFile,
-		TLSKeyFile:   *tlsKeyFile,
-		StatsPrefix:  "log",
-		ExtraOptions: options,
-		QuotaDryRun:  *quotaDryRun,
-		DBClose:      sp.Close,
-		Registry:     registry,
+		RPCEndpoint:        *rpcEndpoint,
+		HTTPEndpoint:       *httpEndpoint,
+		AdminRPCEndpoint:   *adminRPCEndpoint,
+		TLSCertFile:        *tlsCertFile,
+		TLSKeyFile:         *tlsKeyFile,
+		TLSReloadInterval:  *tlsReloadInterval,
+		Keepalive:          keepalive,
+		StatsPrefix:        "log",
+		ExtraOptions:       options,
+		QuotaDryRun:        *quotaDryRun,
+		QuotaDryRunGroups:  dryRunGroups,
+		QuotaByteCosts:     byteCosts,
+		DefaultRPCDeadline: *defaultRPCDeadline,
+		MaxRPCDeadline:     *maxRPCDeadline,
+		ReadOnly:           *readOnly,
+		Authenticator:      authenticator,
+		RequestLimiter:     rl,
+		DBClose:            sp.Close,
+		Registry:           registry,
 		RegisterServerFn: func(s *grpc.Server, registry extension.Registry) error {
 			logServer := server.NewTrillianLogRPCServer(registry, clock.System)
 			if err := logServer.IsHealthy(); err != nil {
@@ -186,6 +418,39 @@ func main() {
 		TreeDeleteMinInterval: *treeDeleteMinRunInterval,
 	}
 
+	if *healthzProbeTreeID != 0 {
+		m.IsHealthy = server.NewHealthChecker(registry, *healthzProbeTreeID, *healthzProbeSlack, clock.System).Check
+	}
+
+	// Start the sequencing loop of the embedded signer, which is shut down
+	// gracefully once the server has stopped. It's master for all the logs.
+	if *embeddedSigner {
+		signerRegistry := registry
+		signerRegistry.ElectionFactory = election2.NoopFactory{}
+		info := log.OperationInfo{
+			Registry:    signerRegistry,
+			BatchSize:   *batchSize,
+			NumWorkers:  *numSequencers,
+			RunInterval: *sequencerInterval,
+			TimeSource:  clock.System,
+		}
+		if capturer != nil && *slowPassThreshold > 0 {
+			info.WatchPass = capturer.PassWatcher(*slowPassThreshold)
+		}
+		sequencerTask := log.NewOperationManager(info, log.NewSequencerManager(signerRegistry, *sequencerGuardWindow))
+		opCtx, cancelOps := context.WithCancel(context.Background())
+		defer cancelOps()
+		go sequencerTask.OperationLoop(opCtx)
+		// The last pass must finish before the storage is closed.
+		m.OnShutdown = func() {
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), *shutdownGracePeriod)
+			defer cancelShutdown()
+			if err := sequencerTask.Shutdown(shutdownCtx); err != nil {
+				glog.Warningf("Sequencing didn't shut down within %v: %v", *shutdownGracePeriod, err)
+			}
+		}
+	}
+
 	if err := m.Run(ctx); err != nil {
 		glog.Exitf("Server exited with error: %v", err)
 	}
@@ -203,3 +468,60 @@ func mustCreate(fileName string) *os.File {
 	}
 	return f
 }
+
+// configureLogging configures the monitoring/logging package from the flags.
+func configureLogging() error {
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return fmt.Errorf("--log_level: %v", err)
+	}
+	levels, err := logging.ParseLevels(*logLevels)
+	if err != nil {
+		return fmt.Errorf("--log_levels: %v", err)
+	}
+	if err := logging.Configure(logging.Config{Format: *logFormat, Level: level, Levels: levels}); err != nil {
+		return fmt.Errorf("--log_format: %v", err)
+	}
+	return nil
+}
+
+// defaultRequestLimit returns the request limit of the trees without one in
+// --tree_request_limits.
+func defaultRequestLimit() interceptor.RequestLimit {
+	return interceptor.RequestLimit{MaxRequestBytes: *maxRequestBytes, MaxBatchLeaves: *maxBatchLeaves, MaxInFlightBytes: *maxInFlightBytes}
+}
+
+// newConfigReloader returns a ConfigReloader which applies the settings of
+// --config that can change without a restart: logging, and the limits of the
+// backlog limiter, of the request limiter and of the MySQL quota manager, if
+// enabled.
+func newConfigReloader(qm quota.Manager, bl *backlog.Limiter, rl *interceptor.RequestLimiter) (*cmd.ConfigReloader, error) {
+	reloadable := []string{"v", "log_format", "log_level", "log_levels"}
+	if bl != nil {
+		reloadable = append(reloadable, "max_unsequenced_leaves")
+	}
+	if rl != nil {
+		reloadable = append(reloadable, "max_request_bytes", "max_batch_leaves", "max_in_flight_request_bytes", "tree_request_limits")
+	}
+	qmFlags, reloadQM := mySQLQuotaReloader(qm)
+	reloadable = append(reloadable, qmFlags...)
+	return cmd.NewConfigReloader(*configFile, reloadable, func(changed []string) {
+		if err := configureLogging(); err != nil {
+			glog.Errorf("Invalid logging flags in config file: %v", err)
+		}
+		if bl != nil {
+			bl.SetDefault(*maxUnsequencedLeaves)
+		}
+		if rl != nil {
+			perTree, err := interceptor.ParseRequestLimits(*treeRequestLimits)
+			if err != nil {
+				glog.Errorf("Invalid --tree_request_limits in config file: %v", err)
+			} else {
+				rl.SetLimits(defaultRequestLimit(), perTree)
+			}
+		}
+		if reloadQM != nil {
+			reloadQM()
+		}
+	})
+}
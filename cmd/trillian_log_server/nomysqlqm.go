@@ -0,0 +1,26 @@
+//go:build trillian_no_mysql
+// +build trillian_no_mysql
+
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "github.com/google/trillian/quota"
+
+// mySQLQuotaReloader returns no flags, as the MySQL quota manager is excluded
+// from the build.
+func mySQLQuotaReloader(quota.Manager) ([]string, func()) {
+	return nil, nil
+}
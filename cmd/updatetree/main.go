@@ -17,6 +17,7 @@
 //
 // Example usage:
 // $ ./updatetree --admin_server=host:port --tree_id=123456789 --tree_state=FROZEN
+// $ ./updatetree --admin_server=host:port --tree_id=123456789 --sequencer_guard_window=5s --sequencer_batch_size=100
 //
 // The output is minimal to allow for easy usage in automated scripts.
 package main
@@ -26,6 +27,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -38,17 +40,32 @@ import (
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 var (
-	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
-	rpcDeadline     = flag.Duration("rpc_deadline", time.Second*10, "Deadline for RPC requests")
-	treeID          = flag.Int64("tree_id", 0, "The ID of the tree to be set updated")
-	treeState       = flag.String("tree_state", "", "If set the tree state will be updated")
-	treeType        = flag.String("tree_type", "", "If set the tree type will be updated")
-	printTree       = flag.Bool("print", false, "Print the resulting tree")
+	adminServerAddr  = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	rpcDeadline      = flag.Duration("rpc_deadline", time.Second*10, "Deadline for RPC requests")
+	treeID           = flag.Int64("tree_id", 0, "The ID of the tree to be set updated")
+	treeState        = flag.String("tree_state", "", "If set the tree state will be updated")
+	treeType         = flag.String("tree_type", "", "If set the tree type will be updated")
+	maxRootDuration  = flag.String("max_root_duration", "", "If set the max root duration will be updated, e.g. 1h; zero means never")
+	guardWindow      = flag.String("sequencer_guard_window", "", "If set the sequencer guard window will be updated, e.g. 5s; zero means the signer's")
+	batchSize        = flag.Int("sequencer_batch_size", -1, "If non-negative the sequencer batch size will be updated; zero means the signer's")
+	priority         = flag.String("priority", "", "If set the tree priority will be updated")
+	signerAffinity   = flag.String("signer_affinity", "", "If set the signer affinity will be updated, as comma-separated labels; - clears it")
+	antiAffinity     = flag.String("anti_affinity_group", "", "If set the anti-affinity group will be updated; - clears it")
+	maxLeafValueSize = flag.Int("max_leaf_value_size", -1, "If non-negative the max LeafValue size will be updated; zero means the log server's")
+	maxExtraDataSize = flag.Int("max_extra_data_size", -1, "If non-negative the max ExtraData size will be updated; zero means the log server's")
+	maxUnsequenced   = flag.Int("max_unsequenced_leaves", -1, "If non-negative the max number of unsequenced leaves will be updated; zero means the log server's")
+	compactRetained  = flag.Int("compact_retained_revisions", -1, "If non-negative the number of revisions retained by compaction will be updated; zero means the log signer's")
+	printTree        = flag.Bool("print", false, "Print the resulting tree")
 )
 
+// clearValue is the flag value which clears the signer affinity or the
+// anti-affinity group, which can't be a valid label.
+const clearValue = "-"
+
 // TODO(Martin2112): Pass everything needed into this and don't refer to flags.
 func updateTree(ctx context.Context) (*trillian.Tree, error) {
 	if *adminServerAddr == "" {
@@ -84,6 +101,72 @@ func updateTree(ctx context.Context) (*trillian.Tree, error) {
 		paths = append(paths, "tree_type")
 	}
 
+	if len(*maxRootDuration) > 0 {
+		d, err := time.ParseDuration(*maxRootDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max root duration: %w", err)
+		}
+		tree.MaxRootDuration = durationpb.New(d)
+		paths = append(paths, "max_root_duration")
+	}
+
+	if len(*guardWindow) > 0 {
+		d, err := time.ParseDuration(*guardWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sequencer guard window: %w", err)
+		}
+		tree.SequencerGuardWindow = durationpb.New(d)
+		paths = append(paths, "sequencer_guard_window")
+	}
+
+	if *batchSize >= 0 {
+		tree.SequencerBatchSize = int32(*batchSize)
+		paths = append(paths, "sequencer_batch_size")
+	}
+
+	if len(*priority) > 0 {
+		p, ok := trillian.TreePriority_value[*priority]
+		if !ok {
+			return nil, fmt.Errorf("invalid tree priority: %v", *priority)
+		}
+		tree.Priority = trillian.TreePriority(p)
+		paths = append(paths, "priority")
+	}
+
+	if len(*signerAffinity) > 0 {
+		if *signerAffinity != clearValue {
+			tree.SignerAffinity = strings.Split(*signerAffinity, ",")
+		}
+		paths = append(paths, "signer_affinity")
+	}
+
+	if len(*antiAffinity) > 0 {
+		if *antiAffinity != clearValue {
+			tree.AntiAffinityGroup = *antiAffinity
+		}
+		paths = append(paths, "anti_affinity_group")
+	}
+
+	if *maxLeafValueSize >= 0 {
+		tree.MaxLeafValueSize = int32(*maxLeafValueSize)
+		paths = append(paths, "max_leaf_value_size")
+	}
+
+	if *maxExtraDataSize >= 0 {
+		tree.MaxExtraDataSize = int32(*maxExtraDataSize)
+		paths = append(paths, "max_extra_data_size")
+	}
+
+	if *maxUnsequenced >= 0 {
+		tree.MaxUnsequencedLeaves = int32(*maxUnsequenced)
+		paths = append(paths, "max_unsequenced_leaves")
+	}
+
+	if *compactRetained >= 0 {
+		tree.CompactRetainedRevisions = int32(*compactRetained)
+		paths = append(paths, "compact_retained_revisions")
+	}
+
 	if len(paths) == 0 {
 		return nil, errors.New("nothing to change")
 	}
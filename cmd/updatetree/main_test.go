@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/trillian"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/testonly/flagsaver"
@@ -34,6 +35,7 @@ type testCase struct {
 	updateTree *trillian.Tree
 	wantErr    bool
 	wantState  trillian.TreeState
+	wantPaths  []string
 }
 
 func TestFreezeTree(t *testing.T) {
@@ -62,6 +64,132 @@ func TestFreezeTree(t *testing.T) {
 			},
 			wantState: trillian.TreeState_FROZEN,
 		},
+		{
+			desc: "validUpdateSequencing",
+			setFlags: func() {
+				*treeID = 12345
+				*maxRootDuration = "1h"
+				*guardWindow = "0"
+				*batchSize = 100
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:    12345,
+				TreeState: trillian.TreeState_ACTIVE,
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"max_root_duration", "sequencer_guard_window", "sequencer_batch_size"},
+		},
+		{
+			desc: "validUpdatePriority",
+			setFlags: func() {
+				*treeID = 12345
+				*priority = "HIGH_PRIORITY"
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:    12345,
+				TreeState: trillian.TreeState_ACTIVE,
+				Priority:  trillian.TreePriority_HIGH_PRIORITY,
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"priority"},
+		},
+		{
+			desc: "validUpdateAffinity",
+			setFlags: func() {
+				*treeID = 12345
+				*signerAffinity = "a,b"
+				*antiAffinity = "hot"
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:            12345,
+				TreeState:         trillian.TreeState_ACTIVE,
+				SignerAffinity:    []string{"a", "b"},
+				AntiAffinityGroup: "hot",
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"signer_affinity", "anti_affinity_group"},
+		},
+		{
+			desc: "validClearAffinity",
+			setFlags: func() {
+				*treeID = 12345
+				*signerAffinity = "-"
+				*antiAffinity = "-"
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:    12345,
+				TreeState: trillian.TreeState_ACTIVE,
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"signer_affinity", "anti_affinity_group"},
+		},
+		{
+			desc: "validUpdateLeafSizeLimits",
+			setFlags: func() {
+				*treeID = 12345
+				*maxLeafValueSize = 1024
+				*maxExtraDataSize = 256
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:           12345,
+				TreeState:        trillian.TreeState_ACTIVE,
+				MaxLeafValueSize: 1024,
+				MaxExtraDataSize: 256,
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"max_leaf_value_size", "max_extra_data_size"},
+		},
+		{
+			desc: "updateMaxUnsequencedLeaves",
+			setFlags: func() {
+				*treeID = 12345
+				*maxUnsequenced = 1000
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:               12345,
+				TreeState:            trillian.TreeState_ACTIVE,
+				MaxUnsequencedLeaves: 1000,
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"max_unsequenced_leaves"},
+		},
+		{
+			desc: "updateCompactRetainedRevisions",
+			setFlags: func() {
+				*treeID = 12345
+				*compactRetained = 10
+			},
+			wantRPC: true,
+			updateTree: &trillian.Tree{
+				TreeId:                   12345,
+				TreeState:                trillian.TreeState_ACTIVE,
+				CompactRetainedRevisions: 10,
+			},
+			wantState: trillian.TreeState_ACTIVE,
+			wantPaths: []string{"compact_retained_revisions"},
+		},
+		{
+			desc: "updateInvalidPriority",
+			setFlags: func() {
+				*treeID = 12345
+				*priority = "URGENT"
+			},
+			wantErr: true,
+		},
+		{
+			desc: "updateInvalidGuardWindow",
+			setFlags: func() {
+				*treeID = 12345
+				*guardWindow = "5 seconds"
+			},
+			wantErr: true,
+		},
 		{
 			desc: "updateInvalidState",
 			setFlags: func() {
@@ -132,6 +260,13 @@ func runTest(t *testing.T, tests []*testCase) {
 			// We might not get as far as updating the tree on the admin server.
 			if tc.wantRPC {
 				call := s.Admin.EXPECT().UpdateTree(gomock.Any(), gomock.Any()).Return(tc.updateTree, tc.updateErr)
+				if tc.wantPaths != nil {
+					call = call.Do(func(_ context.Context, req *trillian.UpdateTreeRequest) {
+						if diff := cmp.Diff(req.GetUpdateMask().GetPaths(), tc.wantPaths); diff != "" {
+							t.Errorf("UpdateTree() paths diff (-got +want):\n%s", diff)
+						}
+					})
+				}
 				expectCalls(call, tc.updateErr)
 			}
 
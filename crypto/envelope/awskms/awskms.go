@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awskms provides key encryption keys held by AWS KMS, opened with
+// URIs naming a key ID or alias, like awskms://alias/trillian, or an ARN, like
+// awskms:arn:aws:kms:us-east-1:111122223333:key/1234abcd. Credentials and
+// the region are found like the AWS CLI finds them, and the region can be
+// overridden with the region URI parameter.
+package awskms
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto/envelope"
+)
+
+// Scheme is the URI scheme of AWS KMS keys.
+const Scheme = "awskms"
+
+func init() {
+	if err := envelope.RegisterKMS(Scheme, open); err != nil {
+		glog.Fatalf("Failed to register KMS %v: %v", Scheme, err)
+	}
+}
+
+func open(_ context.Context, u *url.URL) (envelope.KMS, error) {
+	keyID := strings.TrimPrefix(u.Host+u.Path, "/")
+	if u.Opaque != "" {
+		keyID = u.Opaque
+	}
+	if keyID == "" {
+		return nil, fmt.Errorf("%v: missing key, want %s://alias/name", u, Scheme)
+	}
+	cfg := aws.NewConfig()
+	if region := u.Query().Get("region"); region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return &keyKMS{client: kms.New(sess), keyID: keyID}, nil
+}
+
+// keyKMS wraps keys with an AWS KMS key. AWS KMS records the key in the
+// wrapped keys, so the ARN of the key is only recorded for information.
+type keyKMS struct {
+	client *kms.KMS
+	keyID  string
+}
+
+func (k *keyKMS) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	out, err := k.client.EncryptWithContext(ctx, &kms.EncryptInput{KeyId: aws.String(k.keyID), Plaintext: dek})
+	if err != nil {
+		return "", nil, err
+	}
+	return aws.StringValue(out.KeyId), out.CiphertextBlob, nil
+}
+
+func (k *keyKMS) Unwrap(ctx context.Context, _ string, wrapped []byte) ([]byte, error) {
+	out, err := k.client.DecryptWithContext(ctx, &kms.DecryptInput{KeyId: aws.String(k.keyID), CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+func (k *keyKMS) Close() error {
+	return nil
+}
@@ -0,0 +1,335 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envelope provides per-tree envelope encryption of data at rest.
+//
+// Data is encrypted with AES-256-GCM under data encryption keys (DEKs), which
+// are generated per tree and rotated periodically. DEKs are wrapped by a key
+// encryption key held by a KMS, and each encrypted value records the KMS key
+// URI, the key version and the wrapped DEK it was encrypted with, so that
+// values stay readable after the DEK or the key encryption key is rotated.
+//
+// KMSs are selected by the scheme of the key URI. The local scheme, for key
+// rings held in files, is always available, and other KMSs register themselves
+// when their packages are imported.
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+)
+
+const (
+	// DefaultDEKLifetime is the time after which the DEK of a tree is rotated,
+	// if not specified otherwise.
+	DefaultDEKLifetime = time.Hour
+	// TreeIDPlaceholder is replaced with the ID of a tree in key URIs.
+	TreeIDPlaceholder = "{tree_id}"
+
+	// magic prefixes encrypted values, which distinguishes them from values
+	// written before encryption was enabled.
+	magic   = "\x89TRLENC\x01"
+	dekSize = 32
+	// maxDEKUses bounds the number of values encrypted under a DEK, well below
+	// the 2^32 limit of AES-GCM with random nonces.
+	maxDEKUses = 1 << 30
+	// maxCachedDEKs bounds the number of unwrapped DEKs kept for decryption.
+	maxCachedDEKs = 4096
+)
+
+// Options holds the settings of an Encrypter.
+type Options struct {
+	// KeyURI is the URI of the key encryption key of trees, in which
+	// TreeIDPlaceholder is replaced with the tree ID to use per-tree keys, e.g.
+	// gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/tree-{tree_id}.
+	KeyURI string
+	// PreviousKeyURIs are the URIs of key encryption keys which were replaced
+	// by KeyURI, and still protect values which must be decrypted.
+	PreviousKeyURIs []string
+	// DEKLifetime is the time after which the DEK of a tree is rotated.
+	// DefaultDEKLifetime is used if it is zero.
+	DEKLifetime time.Duration
+	// TimeSource is used for rotating DEKs. The system clock is used if it is
+	// nil.
+	TimeSource clock.TimeSource
+}
+
+// Encrypter encrypts and decrypts the values of trees. It's safe for
+// concurrent use.
+type Encrypter struct {
+	opts Options
+
+	mu sync.Mutex
+	// kms holds the opened KMSs by resolved key URI.
+	kms map[string]KMS
+	// current holds the DEK currently used for encrypting the values of each
+	// tree.
+	current map[int64]*dek
+	// unwrapped caches DEKs for decryption, by envelope header.
+	unwrapped map[string]cipher.AEAD
+}
+
+// dek is a data encryption key, with the envelope header of the values it
+// encrypts.
+type dek struct {
+	aead   cipher.AEAD
+	header []byte
+	expiry time.Time
+	uses   int
+}
+
+// NewEncrypter returns an Encrypter with the given options. KMSs are opened
+// when first used.
+func NewEncrypter(opts Options) (*Encrypter, error) {
+	if opts.KeyURI == "" {
+		return nil, errors.New("envelope: no key URI")
+	}
+	if opts.DEKLifetime <= 0 {
+		opts.DEKLifetime = DefaultDEKLifetime
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	return &Encrypter{
+		opts:      opts,
+		kms:       make(map[string]KMS),
+		current:   make(map[int64]*dek),
+		unwrapped: make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// IsEncrypted returns whether data was returned by Encrypter.Encrypt, rather
+// than written before encryption was enabled.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Encrypt encrypts plaintext, a value of the given tree. The additional data
+// must be passed again to decrypt it, which binds the value to its context.
+func (e *Encrypter) Encrypt(ctx context.Context, treeID int64, plaintext, additionalData []byte) ([]byte, error) {
+	d, err := e.currentDEK(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := d.aead.NonceSize()
+	out := make([]byte, len(d.header)+nonceSize, len(d.header)+nonceSize+len(plaintext)+d.aead.Overhead())
+	copy(out, d.header)
+	nonce := out[len(d.header):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return d.aead.Seal(out, nonce, plaintext, aad(d.header, additionalData)), nil
+}
+
+// Decrypt decrypts a value of the given tree returned by Encrypt. Values which
+// aren't encrypted are returned unchanged.
+func (e *Encrypter) Decrypt(ctx context.Context, treeID int64, data, additionalData []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	header, uri, keyID, wrapped, rest, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := e.unwrap(ctx, treeID, header, uri, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("envelope: truncated value")
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad(header, additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("envelope: failed to decrypt value of tree %d: %v", treeID, err)
+	}
+	return plaintext, nil
+}
+
+// Close closes the KMSs opened by the Encrypter.
+func (e *Encrypter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var firstErr error
+	for uri, k := range e.kms {
+		if err := k.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(e.kms, uri)
+	}
+	return firstErr
+}
+
+// currentDEK returns the DEK for encrypting the values of the tree, after
+// generating a new one if the current one has expired. The KMS is called
+// without holding the lock, so that rotating the DEK of a tree doesn't block
+// the other trees.
+func (e *Encrypter) currentDEK(ctx context.Context, treeID int64) (*dek, error) {
+	now := e.opts.TimeSource.Now()
+	e.mu.Lock()
+	if d := e.current[treeID]; d != nil && now.Before(d.expiry) && d.uses < maxDEKUses {
+		d.uses++
+		e.mu.Unlock()
+		return d, nil
+	}
+	e.mu.Unlock()
+
+	uri := resolveURI(e.opts.KeyURI, treeID)
+	k, err := e.openKMS(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, dekSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := k.Wrap(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: failed to wrap DEK of tree %d with %s: %v", treeID, uri, err)
+	}
+	aead, err := NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	d := &dek{aead: aead, header: marshalHeader(uri, keyID, wrapped), expiry: now.Add(e.opts.DEKLifetime), uses: 1}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.current[treeID] = d
+	e.cacheLocked(string(d.header), aead)
+	return d, nil
+}
+
+// unwrap returns the DEK of an envelope header, after checking that its key
+// URI is one of those of the tree.
+func (e *Encrypter) unwrap(ctx context.Context, treeID int64, header []byte, uri, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	if !e.isTreeKeyURI(treeID, uri) {
+		return nil, fmt.Errorf("envelope: value of tree %d encrypted with unexpected key %s", treeID, uri)
+	}
+	e.mu.Lock()
+	aead := e.unwrapped[string(header)]
+	e.mu.Unlock()
+	if aead != nil {
+		return aead, nil
+	}
+
+	k, err := e.openKMS(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	key, err := k.Unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: failed to unwrap DEK of tree %d with %s version %s: %v", treeID, uri, keyID, err)
+	}
+	if aead, err = NewAEAD(key); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cacheLocked(string(header), aead)
+	return aead, nil
+}
+
+func (e *Encrypter) isTreeKeyURI(treeID int64, uri string) bool {
+	if uri == resolveURI(e.opts.KeyURI, treeID) {
+		return true
+	}
+	for _, prev := range e.opts.PreviousKeyURIs {
+		if uri == resolveURI(prev, treeID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Encrypter) cacheLocked(header string, aead cipher.AEAD) {
+	if len(e.unwrapped) >= maxCachedDEKs {
+		e.unwrapped = make(map[string]cipher.AEAD)
+	}
+	e.unwrapped[header] = aead
+}
+
+func (e *Encrypter) openKMS(ctx context.Context, uri string) (KMS, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if k, ok := e.kms[uri]; ok {
+		return k, nil
+	}
+	k, err := OpenKMS(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	e.kms[uri] = k
+	return k, nil
+}
+
+func resolveURI(uri string, treeID int64) string {
+	return strings.ReplaceAll(uri, TreeIDPlaceholder, strconv.FormatInt(treeID, 10))
+}
+
+// aad returns the additional data authenticated with a value, which covers its
+// envelope header.
+func aad(header, additionalData []byte) []byte {
+	return append(append([]byte(nil), header...), additionalData...)
+}
+
+// marshalHeader returns the envelope header recording the key which wraps a
+// DEK: the magic prefix followed by the key URI, the key version and the
+// wrapped DEK, each prefixed with its length as a uvarint.
+func marshalHeader(uri, keyID string, wrapped []byte) []byte {
+	h := []byte(magic)
+	var l [binary.MaxVarintLen64]byte
+	for _, field := range [][]byte{[]byte(uri), []byte(keyID), wrapped} {
+		h = append(h, l[:binary.PutUvarint(l[:], uint64(len(field)))]...)
+		h = append(h, field...)
+	}
+	return h
+}
+
+// parseHeader splits an encrypted value into its header, the fields of its
+// header, and the rest of the value.
+func parseHeader(data []byte) (header []byte, uri, keyID string, wrapped, rest []byte, err error) {
+	rest = data[len(magic):]
+	var fields [3][]byte
+	for i := range fields {
+		n, l := binary.Uvarint(rest)
+		if l <= 0 || n > uint64(len(rest)-l) {
+			return nil, "", "", nil, nil, errors.New("envelope: invalid header")
+		}
+		fields[i], rest = rest[l:l+int(n)], rest[l+int(n):]
+	}
+	return data[:len(data)-len(rest)], string(fields[0]), string(fields[1]), fields[2], rest, nil
+}
+
+// NewAEAD returns an AES-GCM AEAD with the given 16, 24 or 32 byte key.
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
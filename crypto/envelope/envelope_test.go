@@ -0,0 +1,293 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+)
+
+// writeKeyRing writes a key ring file with keys of the given IDs, and returns
+// its URI.
+func writeKeyRing(t *testing.T, path string, ids ...string) string {
+	t.Helper()
+	var lines []string
+	for _, id := range ids {
+		key := bytes.Repeat([]byte(id[:1]), dekSize)
+		lines = append(lines, fmt.Sprintf("%s %s", id, base64.StdEncoding.EncodeToString(key)))
+	}
+	if err := os.WriteFile(path, []byte("# Test keys.\n"+strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("Failed to write key ring: %v", err)
+	}
+	return LocalScheme + "://" + path
+}
+
+func mustNewEncrypter(t *testing.T, opts Options) *Encrypter {
+	t.Helper()
+	e, err := NewEncrypter(opts)
+	if err != nil {
+		t.Fatalf("NewEncrypter() = %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	uri := writeKeyRing(t, filepath.Join(t.TempDir(), "keyring"), "a1")
+	e := mustNewEncrypter(t, Options{KeyURI: uri})
+
+	plaintext, ad := []byte("leaf value"), []byte("leaf 1")
+	ct, err := e.Encrypt(ctx, 1, plaintext, ad)
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	if !IsEncrypted(ct) {
+		t.Errorf("IsEncrypted(%x) = false", ct)
+	}
+	if bytes.Contains(ct, plaintext) {
+		t.Errorf("Encrypt() = %x, contains the plaintext", ct)
+	}
+	got, err := e.Decrypt(ctx, 1, ct, ad)
+	if err != nil {
+		t.Fatalf("Decrypt() = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %q, want %q", got, plaintext)
+	}
+
+	// Values written before encryption was enabled are returned unchanged.
+	if IsEncrypted(plaintext) {
+		t.Errorf("IsEncrypted(%q) = true", plaintext)
+	}
+	if got, err := e.Decrypt(ctx, 1, plaintext, ad); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt(plaintext) = %q, %v, want %q, nil", got, err, plaintext)
+	}
+
+	tampered := append([]byte(nil), ct...)
+	tampered[len(tampered)-1] ^= 1
+	for _, tc := range []struct {
+		desc   string
+		treeID int64
+		data   []byte
+		ad     []byte
+	}{
+		{desc: "other-additional-data", treeID: 1, data: ct, ad: []byte("leaf 2")},
+		{desc: "tampered", treeID: 1, data: tampered, ad: ad},
+		{desc: "truncated", treeID: 1, data: ct[:len(magic)+3], ad: ad},
+		{desc: "header-only", treeID: 1, data: ct[:len(ct)-len(plaintext)-28], ad: ad},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got, err := e.Decrypt(ctx, tc.treeID, tc.data, tc.ad); err == nil {
+				t.Errorf("Decrypt() = %q, want error", got)
+			}
+		})
+	}
+}
+
+// countingKMS counts the keys wrapped and unwrapped by a KMS.
+type countingKMS struct {
+	KMS
+	wraps, unwraps *int32
+}
+
+func (k countingKMS) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	atomic.AddInt32(k.wraps, 1)
+	return k.KMS.Wrap(ctx, dek)
+}
+
+func (k countingKMS) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	atomic.AddInt32(k.unwraps, 1)
+	return k.KMS.Unwrap(ctx, keyID, wrapped)
+}
+
+func TestDEKRotation(t *testing.T) {
+	ctx := context.Background()
+	uri := writeKeyRing(t, filepath.Join(t.TempDir(), "keyring"), "a1")
+	var wraps, unwraps int32
+	ts := clock.NewFake(time.Unix(1600000000, 0))
+	e := mustNewEncrypter(t, Options{KeyURI: uri, DEKLifetime: time.Minute, TimeSource: ts})
+	local, err := OpenKMS(ctx, uri)
+	if err != nil {
+		t.Fatalf("OpenKMS() = %v", err)
+	}
+	e.kms[uri] = countingKMS{KMS: local, wraps: &wraps, unwraps: &unwraps}
+
+	encrypt := func(treeID int64) []byte {
+		t.Helper()
+		ct, err := e.Encrypt(ctx, treeID, []byte("value"), nil)
+		if err != nil {
+			t.Fatalf("Encrypt() = %v", err)
+		}
+		return ct
+	}
+	ct1 := encrypt(1)
+	ct2 := encrypt(1)
+	if got, want := atomic.LoadInt32(&wraps), int32(1); got != want {
+		t.Errorf("Wrapped %d DEKs, want %d", got, want)
+	}
+	encrypt(2)
+	if got, want := atomic.LoadInt32(&wraps), int32(2); got != want {
+		t.Errorf("Wrapped %d DEKs after encrypting for another tree, want %d", got, want)
+	}
+	ts.Set(ts.Now().Add(time.Minute))
+	ct3 := encrypt(1)
+	if got, want := atomic.LoadInt32(&wraps), int32(3); got != want {
+		t.Errorf("Wrapped %d DEKs after the DEK expired, want %d", got, want)
+	}
+	h1, h3 := ct1[:len(ct1)-len("value")-28], ct3[:len(ct3)-len("value")-28]
+	if bytes.Equal(h1, h3) {
+		t.Error("DEK not rotated after it expired")
+	}
+
+	// A fresh Encrypter unwraps each DEK once.
+	e2 := mustNewEncrypter(t, Options{KeyURI: uri})
+	e2.kms[uri] = countingKMS{KMS: local, wraps: &wraps, unwraps: &unwraps}
+	for _, ct := range [][]byte{ct1, ct2, ct3, ct1} {
+		if got, err := e2.Decrypt(ctx, 1, ct, nil); err != nil || string(got) != "value" {
+			t.Errorf("Decrypt() = %q, %v, want %q, nil", got, err, "value")
+		}
+	}
+	if got, want := atomic.LoadInt32(&unwraps), int32(2); got != want {
+		t.Errorf("Unwrapped %d DEKs, want %d", got, want)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old")
+	oldURI := writeKeyRing(t, oldPath, "a1")
+	e := mustNewEncrypter(t, Options{KeyURI: oldURI})
+	ct1, err := e.Encrypt(ctx, 1, []byte("value"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+
+	// Rotating the version of the key keeps old values readable.
+	writeKeyRing(t, oldPath, "a1", "b2")
+	e = mustNewEncrypter(t, Options{KeyURI: oldURI})
+	ct2, err := e.Encrypt(ctx, 1, []byte("value"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	for _, tc := range []struct {
+		ct        []byte
+		wantKeyID string
+	}{{ct: ct1, wantKeyID: "a1"}, {ct: ct2, wantKeyID: "b2"}} {
+		_, _, keyID, _, _, err := parseHeader(tc.ct)
+		if err != nil {
+			t.Fatalf("parseHeader() = %v", err)
+		}
+		if keyID != tc.wantKeyID {
+			t.Errorf("Value encrypted with key version %q, want %q", keyID, tc.wantKeyID)
+		}
+		if got, err := e.Decrypt(ctx, 1, tc.ct, nil); err != nil || string(got) != "value" {
+			t.Errorf("Decrypt() = %q, %v, want %q, nil", got, err, "value")
+		}
+	}
+
+	// Replacing the key needs the old one to be listed as previous.
+	newURI := writeKeyRing(t, filepath.Join(dir, "new"), "c3")
+	e = mustNewEncrypter(t, Options{KeyURI: newURI})
+	if _, err := e.Decrypt(ctx, 1, ct1, nil); err == nil {
+		t.Error("Decrypt() with replaced key succeeded, want error")
+	}
+	e = mustNewEncrypter(t, Options{KeyURI: newURI, PreviousKeyURIs: []string{oldURI}})
+	if got, err := e.Decrypt(ctx, 1, ct1, nil); err != nil || string(got) != "value" {
+		t.Errorf("Decrypt() = %q, %v, want %q, nil", got, err, "value")
+	}
+}
+
+func TestPerTreeKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeKeyRing(t, filepath.Join(dir, "tree-1"), "a1")
+	writeKeyRing(t, filepath.Join(dir, "tree-2"), "b1")
+	e := mustNewEncrypter(t, Options{KeyURI: LocalScheme + "://" + filepath.Join(dir, "tree-"+TreeIDPlaceholder)})
+
+	ct, err := e.Encrypt(ctx, 1, []byte("value"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	if _, uri, _, _, _, err := parseHeader(ct); err != nil || !strings.HasSuffix(uri, "/tree-1") {
+		t.Errorf("Value encrypted with key %q, %v, want key of tree 1", uri, err)
+	}
+	if _, err := e.Decrypt(ctx, 2, ct, nil); err == nil {
+		t.Error("Decrypt() of value of tree 1 as tree 2 succeeded, want error")
+	}
+	if _, err := e.Encrypt(ctx, 3, []byte("value"), nil); err == nil {
+		t.Error("Encrypt() for tree without key succeeded, want error")
+	}
+}
+
+func TestOpenLocalKMS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, dekSize))
+	for _, tc := range []struct {
+		desc    string
+		content string
+		wantErr bool
+	}{
+		{desc: "ok", content: "# comment\n\na1 " + key + "\nb2 " + key + "\n"},
+		{desc: "empty", content: "# comment\n", wantErr: true},
+		{desc: "missing-key", content: "a1\n", wantErr: true},
+		{desc: "bad-base64", content: "a1 !!!\n", wantErr: true},
+		{desc: "short-key", content: "a1 AAAA\n", wantErr: true},
+		{desc: "duplicate", content: "a1 " + key + "\na1 " + key + "\n", wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(dir, tc.desc)
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() = %v", err)
+			}
+			k, err := OpenKMS(ctx, LocalScheme+"://"+path)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("OpenKMS() = %v, want error: %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			keyID, wrapped, err := k.Wrap(ctx, []byte("dek"))
+			if err != nil {
+				t.Fatalf("Wrap() = %v", err)
+			}
+			if keyID != "b2" {
+				t.Errorf("Wrap() used key %q, want the last one", keyID)
+			}
+			if got, err := k.Unwrap(ctx, keyID, wrapped); err != nil || string(got) != "dek" {
+				t.Errorf("Unwrap() = %q, %v, want %q, nil", got, err, "dek")
+			}
+			if _, err := k.Unwrap(ctx, "a1", wrapped); err == nil {
+				t.Error("Unwrap() with wrong key version succeeded, want error")
+			}
+		})
+	}
+
+	if _, err := OpenKMS(ctx, "unknown://key"); err == nil {
+		t.Error("OpenKMS() of unknown scheme succeeded, want error")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcpkms provides key encryption keys held by Google Cloud KMS, opened
+// with URIs like
+// gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k. Credentials are
+// found with Application Default Credentials.
+package gcpkms
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto/envelope"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// Scheme is the URI scheme of Google Cloud KMS keys.
+const Scheme = "gcpkms"
+
+func init() {
+	if err := envelope.RegisterKMS(Scheme, open); err != nil {
+		glog.Fatalf("Failed to register KMS %v: %v", Scheme, err)
+	}
+}
+
+func open(ctx context.Context, u *url.URL) (envelope.KMS, error) {
+	name := strings.TrimPrefix(u.Host+u.Path, "/")
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/cryptoKeys/") {
+		return nil, fmt.Errorf("%v: want %s://projects/p/locations/l/keyRings/r/cryptoKeys/k", u, Scheme)
+	}
+	client, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud KMS client: %v", err)
+	}
+	return &keyKMS{client: client, name: name}, nil
+}
+
+// keyKMS wraps keys with the primary version of a Cloud KMS key. Cloud KMS
+// records the version in the wrapped keys, so the name of the version is only
+// recorded for information.
+type keyKMS struct {
+	client *kms.KeyManagementClient
+	name   string
+}
+
+func (k *keyKMS) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	resp, err := k.client.Encrypt(ctx, &kmspb.EncryptRequest{Name: k.name, Plaintext: dek})
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Name, resp.Ciphertext, nil
+}
+
+func (k *keyKMS) Unwrap(ctx context.Context, _ string, wrapped []byte) ([]byte, error) {
+	resp, err := k.client.Decrypt(ctx, &kmspb.DecryptRequest{Name: k.name, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (k *keyKMS) Close() error {
+	return k.client.Close()
+}
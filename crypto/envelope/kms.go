@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// KMS wraps and unwraps data encryption keys with a key encryption key, which
+// it holds and doesn't reveal. Implementations must be safe for concurrent use.
+type KMS interface {
+	// Wrap encrypts dek with the primary version of the key encryption key,
+	// and returns the ID of that version with the wrapped key.
+	Wrap(ctx context.Context, dek []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a key wrapped by the version keyID of the key encryption
+	// key.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// Close releases the resources held by the KMS.
+	Close() error
+}
+
+// OpenKMSFunc is the signature of a function which can be registered to open
+// the key encryption keys with URIs of a scheme.
+type OpenKMSFunc func(ctx context.Context, u *url.URL) (KMS, error)
+
+var (
+	kmsMu       sync.RWMutex
+	kmsByScheme = make(map[string]OpenKMSFunc)
+)
+
+// RegisterKMS registers the function opening key encryption keys with URIs of
+// the given scheme.
+func RegisterKMS(scheme string, open OpenKMSFunc) error {
+	kmsMu.Lock()
+	defer kmsMu.Unlock()
+
+	if _, exists := kmsByScheme[scheme]; exists {
+		return fmt.Errorf("KMS scheme %v already registered", scheme)
+	}
+	kmsByScheme[scheme] = open
+	return nil
+}
+
+// KMSSchemes returns the registered URI schemes, sorted.
+func KMSSchemes() []string {
+	kmsMu.RLock()
+	defer kmsMu.RUnlock()
+
+	r := make([]string, 0, len(kmsByScheme))
+	for k := range kmsByScheme {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// OpenKMS opens the key encryption key with the given URI, with the KMS
+// registered for its scheme.
+func OpenKMS(ctx context.Context, uri string) (KMS, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key URI %q: %v", uri, err)
+	}
+	kmsMu.RLock()
+	open := kmsByScheme[u.Scheme]
+	kmsMu.RUnlock()
+	if open == nil {
+		return nil, fmt.Errorf("no KMS for scheme %q of %q, want one of %v", u.Scheme, uri, KMSSchemes())
+	}
+	return open(ctx, u)
+}
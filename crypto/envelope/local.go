@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// LocalScheme is the URI scheme of local key rings, e.g. local:///etc/trillian/keyring.
+const LocalScheme = "local"
+
+func init() {
+	if err := RegisterKMS(LocalScheme, openLocalKMS); err != nil {
+		glog.Fatalf("Failed to register KMS %v: %v", LocalScheme, err)
+	}
+}
+
+// LocalKey is a version of the key encryption key of a LocalKMS.
+type LocalKey struct {
+	// ID identifies the version, and is recorded with the keys it wraps.
+	ID string
+	// Key is an AES-256 key.
+	Key []byte
+}
+
+// LocalKMS is a KMS holding its key encryption key in memory. It's meant for
+// tests and deployments without a KMS service, which then need to protect the
+// key ring file like other secrets.
+type LocalKMS struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewLocalKMS returns a LocalKMS with the given key versions, the last of
+// which is primary.
+func NewLocalKMS(keys []LocalKey) (*LocalKMS, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	k := &LocalKMS{aeads: make(map[string]cipher.AEAD)}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key with empty ID")
+		}
+		if _, ok := k.aeads[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		if len(key.Key) != dekSize {
+			return nil, fmt.Errorf("key %q: %d bytes, want %d", key.ID, len(key.Key), dekSize)
+		}
+		aead, err := NewAEAD(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[key.ID] = aead
+		k.primary = key.ID
+	}
+	return k, nil
+}
+
+// openLocalKMS reads a key ring file, with one key version per line made of
+// its ID and its base64-encoded key, separated by a space. The last version is
+// primary, so keys are rotated by appending a version. Empty lines, and lines
+// starting with #, are ignored.
+func openLocalKMS(_ context.Context, u *url.URL) (KMS, error) {
+	data, err := ioutil.ReadFile(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key ring: %v", err)
+	}
+	var keys []LocalKey
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want ID and key", u.Path, n)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid key: %v", u.Path, n, err)
+		}
+		keys = append(keys, LocalKey{ID: fields[0], Key: key})
+	}
+	k, err := NewLocalKMS(keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", u.Path, err)
+	}
+	return k, nil
+}
+
+// Wrap implements KMS.
+func (k *LocalKMS) Wrap(_ context.Context, dek []byte) (string, []byte, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.primary, aead.Seal(nonce, nonce, dek, []byte(k.primary)), nil
+}
+
+// Unwrap implements KMS.
+func (k *LocalKMS) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(keyID))
+}
+
+// Close implements KMS.
+func (k *LocalKMS) Close() error {
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encrypted handles private keys encrypted with a secret held outside
+// the protos which store them: a passphrase read from an environment variable
+// or a file, or a KMS key.
+//
+// Keys are encrypted with AES-256-GCM. For passphrases, the AES key is derived
+// from the passphrase with scrypt. For KMS keys, the AES key is random and
+// wrapped by the KMS key, through the KMSs supported by crypto/envelope.
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/google/trillian/crypto/envelope"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"golang.org/x/crypto/scrypt"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// EnvScheme and FileScheme prefix the URIs of passphrases held by
+	// environment variables and files.
+	EnvScheme  = "env:"
+	FileScheme = "file:"
+
+	keySize  = 32
+	saltSize = 16
+	// The scrypt parameters recommended for interactive logins in 2017, which
+	// cost well under a second, as keys are only decrypted at startup.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// FromProto builds a crypto.Signer from a proto.Message, which must be of type
+// EncryptedPrivateKey.
+func FromProto(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+	if pb, ok := pb.(*keyspb.EncryptedPrivateKey); ok {
+		return DecryptPrivateKey(ctx, pb)
+	}
+	return nil, fmt.Errorf("encrypted: got %T, want *keyspb.EncryptedPrivateKey", pb)
+}
+
+// EncryptPrivateKey encrypts a private key, DER-encoded or as an unencrypted
+// PEM block, with the secret of secretURI.
+func EncryptPrivateKey(ctx context.Context, key []byte, secretURI string) (*keyspb.EncryptedPrivateKey, error) {
+	pb := &keyspb.EncryptedPrivateKey{SecretUri: secretURI}
+	var aesKey []byte
+	if isPassphrase(secretURI) {
+		passphrase, err := readPassphrase(secretURI)
+		if err != nil {
+			return nil, err
+		}
+		pb.Salt = make([]byte, saltSize)
+		if _, err := rand.Read(pb.Salt); err != nil {
+			return nil, err
+		}
+		if aesKey, err = deriveKey(passphrase, pb.Salt); err != nil {
+			return nil, err
+		}
+	} else {
+		aesKey = make([]byte, keySize)
+		if _, err := rand.Read(aesKey); err != nil {
+			return nil, err
+		}
+		kms, err := envelope.OpenKMS(ctx, secretURI)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted: %v", err)
+		}
+		defer kms.Close()
+		if pb.KmsKeyId, pb.WrappedKey, err = kms.Wrap(ctx, aesKey); err != nil {
+			return nil, fmt.Errorf("encrypted: failed to wrap key with %s: %v", secretURI, err)
+		}
+	}
+
+	aead, err := envelope.NewAEAD(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	pb.Ciphertext = aead.Seal(nonce, nonce, key, []byte(secretURI))
+	return pb, nil
+}
+
+// DecryptPrivateKey decrypts a private key encrypted by EncryptPrivateKey.
+func DecryptPrivateKey(ctx context.Context, pb *keyspb.EncryptedPrivateKey) (crypto.Signer, error) {
+	uri := pb.GetSecretUri()
+	if uri == "" {
+		return nil, errors.New("encrypted: no secret URI")
+	}
+	var aesKey []byte
+	if isPassphrase(uri) {
+		passphrase, err := readPassphrase(uri)
+		if err != nil {
+			return nil, err
+		}
+		if aesKey, err = deriveKey(passphrase, pb.GetSalt()); err != nil {
+			return nil, err
+		}
+	} else {
+		kms, err := envelope.OpenKMS(ctx, uri)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted: %v", err)
+		}
+		defer kms.Close()
+		if aesKey, err = kms.Unwrap(ctx, pb.GetKmsKeyId(), pb.GetWrappedKey()); err != nil {
+			return nil, fmt.Errorf("encrypted: failed to unwrap key with %s: %v", uri, err)
+		}
+	}
+
+	aead, err := envelope.NewAEAD(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: %v", err)
+	}
+	ciphertext := pb.GetCiphertext()
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted: truncated ciphertext")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, ciphertext, []byte(uri))
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: failed to decrypt private key with %s: %v", uri, err)
+	}
+	if block, _ := pem.Decode(key); block != nil {
+		key = block.Bytes
+	}
+	return der.UnmarshalPrivateKey(key)
+}
+
+func isPassphrase(uri string) bool {
+	return strings.HasPrefix(uri, EnvScheme) || strings.HasPrefix(uri, FileScheme)
+}
+
+// readPassphrase returns the passphrase of an env: or file: URI. Trailing
+// newlines of files are ignored.
+func readPassphrase(uri string) ([]byte, error) {
+	var passphrase []byte
+	if name := strings.TrimPrefix(uri, EnvScheme); name != uri {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("encrypted: environment variable %s isn't set", name)
+		}
+		passphrase = []byte(value)
+	} else {
+		path := strings.TrimPrefix(uri, FileScheme)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted: failed to read passphrase: %v", err)
+		}
+		passphrase = bytes.TrimRight(data, "\r\n")
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("encrypted: empty passphrase in %s", uri)
+	}
+	return passphrase, nil
+}
+
+func deriveKey(passphrase, salt []byte) ([]byte, error) {
+	if len(salt) == 0 {
+		return nil, errors.New("encrypted: no salt")
+	}
+	return scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/google/trillian/crypto/keys/encrypted"
+	"github.com/google/trillian/crypto/keys/testonly"
+	"github.com/google/trillian/crypto/keyspb"
+	"google.golang.org/protobuf/proto"
+
+	_ "github.com/golang/glog"
+)
+
+// ECDSA private key in DER format, base64-encoded.
+const privKeyBase64 = "MIGHAgEAMBMGByqGSM49AgEGCCqGSM49AwEHBG0wawIBAQQgS81mfpvtTmaINn+gtrYXn4XpxxgE655GLSKsA3hhjHmhRANCAASwBWDdgHS04V/cN0LZgc8vZaK4I1HWLLCoaOO27Z0B1aS1aqBE7g1Oo8ldSCBJAvee866kcHhZkVniPdCG2ZZG"
+
+func TestEncryptPrivateKey(t *testing.T) {
+	ctx := context.Background()
+	keyDER, err := base64.StdEncoding.DecodeString(privKeyBase64)
+	if err != nil {
+		t.Fatalf("Could not decode test key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	dir := t.TempDir()
+	passFile := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(passFile, []byte("towel\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyRing := filepath.Join(dir, "keyring")
+	kek := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if err := os.WriteFile(keyRing, []byte("v1 "+kek+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TRILLIAN_TEST_KEY_PASSPHRASE", "towel")
+
+	for _, test := range []struct {
+		desc      string
+		key       []byte
+		secretURI string
+	}{
+		{desc: "env DER", key: keyDER, secretURI: "env:TRILLIAN_TEST_KEY_PASSPHRASE"},
+		{desc: "file PEM", key: keyPEM, secretURI: "file:" + passFile},
+		{desc: "KMS", key: keyDER, secretURI: "local://" + keyRing},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			pb, err := EncryptPrivateKey(ctx, test.key, test.secretURI)
+			if err != nil {
+				t.Fatalf("EncryptPrivateKey() = %v", err)
+			}
+			signer, err := FromProto(ctx, pb)
+			if err != nil {
+				t.Fatalf("FromProto() = %v", err)
+			}
+			if err := testonly.SignAndVerify(signer, signer.Public()); err != nil {
+				t.Errorf("SignAndVerify() = %v", err)
+			}
+
+			corrupt := proto.Clone(pb).(*keyspb.EncryptedPrivateKey)
+			corrupt.Ciphertext[len(corrupt.Ciphertext)-1] ^= 1
+			if _, err := FromProto(ctx, corrupt); err == nil {
+				t.Error("FromProto() of corrupt ciphertext succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestDecryptPrivateKeyErrors(t *testing.T) {
+	ctx := context.Background()
+	keyDER, err := base64.StdEncoding.DecodeString(privKeyBase64)
+	if err != nil {
+		t.Fatalf("Could not decode test key: %v", err)
+	}
+	t.Setenv("TRILLIAN_TEST_KEY_PASSPHRASE", "towel")
+	pb, err := EncryptPrivateKey(ctx, keyDER, "env:TRILLIAN_TEST_KEY_PASSPHRASE")
+	if err != nil {
+		t.Fatalf("EncryptPrivateKey() = %v", err)
+	}
+
+	t.Setenv("TRILLIAN_TEST_KEY_PASSPHRASE", "wrong")
+	if _, err := DecryptPrivateKey(ctx, pb); err == nil {
+		t.Error("DecryptPrivateKey() with wrong passphrase succeeded, want error")
+	}
+	pb.SecretUri = "env:TRILLIAN_TEST_UNSET_PASSPHRASE"
+	if _, err := DecryptPrivateKey(ctx, pb); err == nil {
+		t.Error("DecryptPrivateKey() with unset variable succeeded, want error")
+	}
+	pb.SecretUri = ""
+	if _, err := DecryptPrivateKey(ctx, pb); err == nil {
+		t.Error("DecryptPrivateKey() without secret URI succeeded, want error")
+	}
+	if _, err := FromProto(ctx, &keyspb.PrivateKey{Der: keyDER}); err == nil {
+		t.Error("FromProto(PrivateKey) succeeded, want error")
+	}
+}
@@ -296,6 +296,97 @@ func (x *PrivateKey) GetDer() []byte {
 	return nil
 }
 
+// EncryptedPrivateKey is a private key encrypted with a secret held outside
+// the message, so that storing the message doesn't reveal the key. The key is
+// decrypted with the secret when the signer is created.
+type EncryptedPrivateKey struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// URI of the secret the key is encrypted with, one of:
+	//   env:NAME for a passphrase held by the environment variable NAME,
+	//   file:PATH for a passphrase held by a file, or
+	//   the URI of a KMS key supported by crypto/envelope, e.g. gcpkms://...
+	SecretUri string `protobuf:"bytes,1,opt,name=secret_uri,json=secretUri,proto3" json:"secret_uri,omitempty"`
+	// For passphrases, the scrypt salt of the key derived from the passphrase.
+	Salt []byte `protobuf:"bytes,2,opt,name=salt,proto3" json:"salt,omitempty"`
+	// For KMS keys, the version of the KMS key which wrapped the data key, and
+	// the wrapped data key.
+	KmsKeyId   string `protobuf:"bytes,3,opt,name=kms_key_id,json=kmsKeyId,proto3" json:"kms_key_id,omitempty"`
+	WrappedKey []byte `protobuf:"bytes,4,opt,name=wrapped_key,json=wrappedKey,proto3" json:"wrapped_key,omitempty"`
+	// The private key, DER-encoded or as an unencrypted PEM block, encrypted
+	// with AES-256-GCM and prefixed with the nonce.
+	Ciphertext []byte `protobuf:"bytes,5,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+}
+
+func (x *EncryptedPrivateKey) Reset() {
+	*x = EncryptedPrivateKey{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EncryptedPrivateKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptedPrivateKey) ProtoMessage() {}
+
+func (x *EncryptedPrivateKey) ProtoReflect() protoreflect.Message {
+	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptedPrivateKey.ProtoReflect.Descriptor instead.
+func (*EncryptedPrivateKey) Descriptor() ([]byte, []int) {
+	return file_crypto_keyspb_keyspb_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EncryptedPrivateKey) GetSecretUri() string {
+	if x != nil {
+		return x.SecretUri
+	}
+	return ""
+}
+
+func (x *EncryptedPrivateKey) GetSalt() []byte {
+	if x != nil {
+		return x.Salt
+	}
+	return nil
+}
+
+func (x *EncryptedPrivateKey) GetKmsKeyId() string {
+	if x != nil {
+		return x.KmsKeyId
+	}
+	return ""
+}
+
+func (x *EncryptedPrivateKey) GetWrappedKey() []byte {
+	if x != nil {
+		return x.WrappedKey
+	}
+	return nil
+}
+
+func (x *EncryptedPrivateKey) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
 // PublicKey is a public key, used for verifying signatures.
 type PublicKey struct {
 	state         protoimpl.MessageState
@@ -309,7 +400,7 @@ type PublicKey struct {
 func (x *PublicKey) Reset() {
 	*x = PublicKey{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[3]
+		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -322,7 +413,7 @@ func (x *PublicKey) String() string {
 func (*PublicKey) ProtoMessage() {}
 
 func (x *PublicKey) ProtoReflect() protoreflect.Message {
-	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[3]
+	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -335,7 +426,7 @@ func (x *PublicKey) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PublicKey.ProtoReflect.Descriptor instead.
 func (*PublicKey) Descriptor() ([]byte, []int) {
-	return file_crypto_keyspb_keyspb_proto_rawDescGZIP(), []int{3}
+	return file_crypto_keyspb_keyspb_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *PublicKey) GetDer() []byte {
@@ -362,7 +453,7 @@ type PKCS11Config struct {
 func (x *PKCS11Config) Reset() {
 	*x = PKCS11Config{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[4]
+		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -375,7 +466,7 @@ func (x *PKCS11Config) String() string {
 func (*PKCS11Config) ProtoMessage() {}
 
 func (x *PKCS11Config) ProtoReflect() protoreflect.Message {
-	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[4]
+	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -388,7 +479,7 @@ func (x *PKCS11Config) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PKCS11Config.ProtoReflect.Descriptor instead.
 func (*PKCS11Config) Descriptor() ([]byte, []int) {
-	return file_crypto_keyspb_keyspb_proto_rawDescGZIP(), []int{4}
+	return file_crypto_keyspb_keyspb_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *PKCS11Config) GetTokenLabel() string {
@@ -426,7 +517,7 @@ type Specification_ECDSA struct {
 func (x *Specification_ECDSA) Reset() {
 	*x = Specification_ECDSA{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[5]
+		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -439,7 +530,7 @@ func (x *Specification_ECDSA) String() string {
 func (*Specification_ECDSA) ProtoMessage() {}
 
 func (x *Specification_ECDSA) ProtoReflect() protoreflect.Message {
-	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[5]
+	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -477,7 +568,7 @@ type Specification_RSA struct {
 func (x *Specification_RSA) Reset() {
 	*x = Specification_RSA{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[6]
+		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -490,7 +581,7 @@ func (x *Specification_RSA) String() string {
 func (*Specification_RSA) ProtoMessage() {}
 
 func (x *Specification_RSA) ProtoReflect() protoreflect.Message {
-	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[6]
+	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -523,7 +614,7 @@ type Specification_Ed25519 struct {
 func (x *Specification_Ed25519) Reset() {
 	*x = Specification_Ed25519{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[7]
+		mi := &file_crypto_keyspb_keyspb_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -536,7 +627,7 @@ func (x *Specification_Ed25519) String() string {
 func (*Specification_Ed25519) ProtoMessage() {}
 
 func (x *Specification_Ed25519) ProtoReflect() protoreflect.Message {
-	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[7]
+	mi := &file_crypto_keyspb_keyspb_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -587,18 +678,29 @@ var file_crypto_keyspb_keyspb_proto_rawDesc = []byte{
 	0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x61,
 	0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x22, 0x1e, 0x0a, 0x0a, 0x50, 0x72, 0x69, 0x76, 0x61, 0x74,
 	0x65, 0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x03, 0x64, 0x65, 0x72, 0x22, 0x1d, 0x0a, 0x09, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63,
-	0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x03, 0x64, 0x65, 0x72, 0x22, 0x60, 0x0a, 0x0c, 0x50, 0x4b, 0x43, 0x53, 0x31, 0x31, 0x43,
-	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x5f, 0x6c,
-	0x61, 0x62, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x6f, 0x6b, 0x65,
-	0x6e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x69, 0x6e, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x03, 0x70, 0x69, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x75, 0x62, 0x6c,
-	0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x75,
-	0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x42, 0x2a, 0x5a, 0x28, 0x67, 0x69, 0x74, 0x68, 0x75,
-	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69,
-	0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2f, 0x63, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x2f, 0x6b, 0x65, 0x79,
-	0x73, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x0c, 0x52, 0x03, 0x64, 0x65, 0x72, 0x22, 0xa7, 0x01, 0x0a, 0x13, 0x45, 0x6e, 0x63, 0x72, 0x79,
+	0x70, 0x74, 0x65, 0x64, 0x50, 0x72, 0x69, 0x76, 0x61, 0x74, 0x65, 0x4b, 0x65, 0x79, 0x12, 0x1d,
+	0x0a, 0x0a, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x5f, 0x75, 0x72, 0x69, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x55, 0x72, 0x69, 0x12, 0x12, 0x0a,
+	0x04, 0x73, 0x61, 0x6c, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x73, 0x61, 0x6c,
+	0x74, 0x12, 0x1c, 0x0a, 0x0a, 0x6b, 0x6d, 0x73, 0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x69, 0x64, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6b, 0x6d, 0x73, 0x4b, 0x65, 0x79, 0x49, 0x64, 0x12,
+	0x1f, 0x0a, 0x0b, 0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x64, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x64, 0x4b, 0x65, 0x79,
+	0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74,
+	0x22, 0x1d, 0x0a, 0x09, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x64, 0x65, 0x72, 0x22,
+	0x60, 0x0a, 0x0c, 0x50, 0x4b, 0x43, 0x53, 0x31, 0x31, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12,
+	0x1f, 0x0a, 0x0b, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x5f, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x4c, 0x61, 0x62, 0x65, 0x6c,
+	0x12, 0x10, 0x0a, 0x03, 0x70, 0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x70,
+	0x69, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65,
+	0x79, 0x42, 0x2a, 0x5a, 0x28, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2f,
+	0x63, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x2f, 0x6b, 0x65, 0x79, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -614,22 +716,23 @@ func file_crypto_keyspb_keyspb_proto_rawDescGZIP() []byte {
 }
 
 var file_crypto_keyspb_keyspb_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_crypto_keyspb_keyspb_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_crypto_keyspb_keyspb_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_crypto_keyspb_keyspb_proto_goTypes = []interface{}{
 	(Specification_ECDSA_Curve)(0), // 0: keyspb.Specification.ECDSA.Curve
 	(*Specification)(nil),          // 1: keyspb.Specification
 	(*PEMKeyFile)(nil),             // 2: keyspb.PEMKeyFile
 	(*PrivateKey)(nil),             // 3: keyspb.PrivateKey
-	(*PublicKey)(nil),              // 4: keyspb.PublicKey
-	(*PKCS11Config)(nil),           // 5: keyspb.PKCS11Config
-	(*Specification_ECDSA)(nil),    // 6: keyspb.Specification.ECDSA
-	(*Specification_RSA)(nil),      // 7: keyspb.Specification.RSA
-	(*Specification_Ed25519)(nil),  // 8: keyspb.Specification.Ed25519
+	(*EncryptedPrivateKey)(nil),    // 4: keyspb.EncryptedPrivateKey
+	(*PublicKey)(nil),              // 5: keyspb.PublicKey
+	(*PKCS11Config)(nil),           // 6: keyspb.PKCS11Config
+	(*Specification_ECDSA)(nil),    // 7: keyspb.Specification.ECDSA
+	(*Specification_RSA)(nil),      // 8: keyspb.Specification.RSA
+	(*Specification_Ed25519)(nil),  // 9: keyspb.Specification.Ed25519
 }
 var file_crypto_keyspb_keyspb_proto_depIdxs = []int32{
-	6, // 0: keyspb.Specification.ecdsa_params:type_name -> keyspb.Specification.ECDSA
-	7, // 1: keyspb.Specification.rsa_params:type_name -> keyspb.Specification.RSA
-	8, // 2: keyspb.Specification.ed25519_params:type_name -> keyspb.Specification.Ed25519
+	7, // 0: keyspb.Specification.ecdsa_params:type_name -> keyspb.Specification.ECDSA
+	8, // 1: keyspb.Specification.rsa_params:type_name -> keyspb.Specification.RSA
+	9, // 2: keyspb.Specification.ed25519_params:type_name -> keyspb.Specification.Ed25519
 	0, // 3: keyspb.Specification.ECDSA.curve:type_name -> keyspb.Specification.ECDSA.Curve
 	4, // [4:4] is the sub-list for method output_type
 	4, // [4:4] is the sub-list for method input_type
@@ -681,7 +784,7 @@ func file_crypto_keyspb_keyspb_proto_init() {
 			}
 		}
 		file_crypto_keyspb_keyspb_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PublicKey); i {
+			switch v := v.(*EncryptedPrivateKey); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -693,7 +796,7 @@ func file_crypto_keyspb_keyspb_proto_init() {
 			}
 		}
 		file_crypto_keyspb_keyspb_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PKCS11Config); i {
+			switch v := v.(*PublicKey); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -705,7 +808,7 @@ func file_crypto_keyspb_keyspb_proto_init() {
 			}
 		}
 		file_crypto_keyspb_keyspb_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Specification_ECDSA); i {
+			switch v := v.(*PKCS11Config); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -717,7 +820,7 @@ func file_crypto_keyspb_keyspb_proto_init() {
 			}
 		}
 		file_crypto_keyspb_keyspb_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Specification_RSA); i {
+			switch v := v.(*Specification_ECDSA); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -729,6 +832,18 @@ func file_crypto_keyspb_keyspb_proto_init() {
 			}
 		}
 		file_crypto_keyspb_keyspb_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Specification_RSA); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crypto_keyspb_keyspb_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Specification_Ed25519); i {
 			case 0:
 				return &v.state
@@ -752,7 +867,7 @@ func file_crypto_keyspb_keyspb_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_crypto_keyspb_keyspb_proto_rawDesc,
 			NumEnums:      1,
-			NumMessages:   8,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -42,7 +42,7 @@ gcloud auth configure-docker
 go get github.com/google/trillian/...
 cd $GOPATH/src/github.com/google/trillian
 
-images="log_server log_signer"
+images="log_server log_signer tree_operator"
 echo "Building and pushing docker images: ${images}"
 for thing in ${images}; do
   echo "  - ${thing}"
@@ -64,7 +64,7 @@ kubectl delete --namespace="${NAMESPACE}" configmap deploy-config || true
 envsubst < ${CONFIGMAP} | kubectl create --namespace="${NAMESPACE}" -f -
 
 # Launch with kubernetes
-kubeconfigs="trillian-log-deployment.yaml trillian-log-service.yaml trillian-log-signer-deployment.yaml trillian-log-signer-service.yaml"
+kubeconfigs="trillian-log-deployment.yaml trillian-log-service.yaml trillian-log-signer-deployment.yaml trillian-log-signer-service.yaml trillian-tree-crd.yaml trillian-tree-operator.yaml"
 for thing in ${kubeconfigs}; do
   echo ${thing}
   envsubst < ${DIR}/${thing} | kubectl apply --namespace="${NAMESPACE}" -f -
@@ -19,6 +19,11 @@ package extension
 import (
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/quota"
+	"github.com/google/trillian/server/backlog"
+	"github.com/google/trillian/server/metering"
+	"github.com/google/trillian/server/notify"
+	"github.com/google/trillian/server/readcache"
+	"github.com/google/trillian/server/validator"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util/election2"
 )
@@ -35,6 +40,17 @@ type Registry struct {
 	ElectionFactory election2.Factory
 	// QuotaManager provides rate limiting capabilities for Trillian.
 	QuotaManager quota.Manager
+	// LeafValidator, if set, inspects leaves before they're written to logs, and may reject them.
+	LeafValidator validator.LeafValidator
+	// BacklogLimiter, if set, rejects leaves queued to logs whose unsequenced backlog is full.
+	BacklogLimiter *backlog.Limiter
+	// ReadCache, if set, caches the responses of read requests to logs. Log
+	// signers invalidate the cached roots of the logs they grow.
+	ReadCache *readcache.Cache
+	// Meter, if set, meters the leaves written to logs per tree and tenant for billing.
+	Meter *metering.Meter
+	// Notifier, if set, POSTs events about the lifecycle and sequencing of trees to webhooks.
+	Notifier *notify.Notifier
 	// MetricFactory provides metrics for monitoring.
 	monitoring.MetricFactory
 	// SetProcessStatus sets the current process status for diagnostic purposes.
@@ -0,0 +1,317 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	inmemory "github.com/transparency-dev/merkle/testonly"
+)
+
+// Corruption is a manipulation that a ByzantineLogClient applies to the responses of the log.
+type Corruption int
+
+const (
+	// NoCorruption forwards all responses unmodified.
+	NoCorruption Corruption = iota
+	// CorruptInclusionProof flips a bit in the inclusion proofs returned by the log.
+	CorruptInclusionProof
+	// CorruptConsistencyProof flips a bit in the consistency proofs returned by the log.
+	CorruptConsistencyProof
+	// CorruptRootHash flips a bit in the root hash of the latest log root.
+	CorruptRootHash
+	// StaleRoot replaces the latest log root with the first root seen by the client.
+	StaleRoot
+	// TruncateLeafRange drops the last leaf of each range of leaves returned by the log.
+	TruncateLeafRange
+	// TruncateLeafValue drops the last byte of the value of each leaf returned by the log.
+	TruncateLeafValue
+)
+
+// Corruptions lists all the manipulations supported by ByzantineLogClient.
+var Corruptions = []Corruption{
+	CorruptInclusionProof,
+	CorruptConsistencyProof,
+	CorruptRootHash,
+	StaleRoot,
+	TruncateLeafRange,
+	TruncateLeafValue,
+}
+
+// String returns the name of the Corruption.
+func (c Corruption) String() string {
+	switch c {
+	case NoCorruption:
+		return "NoCorruption"
+	case CorruptInclusionProof:
+		return "CorruptInclusionProof"
+	case CorruptConsistencyProof:
+		return "CorruptConsistencyProof"
+	case CorruptRootHash:
+		return "CorruptRootHash"
+	case StaleRoot:
+		return "StaleRoot"
+	case TruncateLeafRange:
+		return "TruncateLeafRange"
+	case TruncateLeafValue:
+		return "TruncateLeafValue"
+	}
+	return fmt.Sprintf("Corruption(%d)", int(c))
+}
+
+// ByzantineLogClient is a TrillianLogClient that simulates a misbehaving log server by corrupting
+// the responses of the wrapped client, according to its current Corruption.
+type ByzantineLogClient struct {
+	trillian.TrillianLogClient
+
+	mu         sync.Mutex
+	corruption Corruption
+	// firstRoot is the first log root received by the client, served in StaleRoot mode.
+	firstRoot *trillian.SignedLogRoot
+}
+
+// NewByzantineLogClient returns a ByzantineLogClient wrapping client. It doesn't corrupt any
+// responses until SetCorruption is called.
+func NewByzantineLogClient(client trillian.TrillianLogClient) *ByzantineLogClient {
+	return &ByzantineLogClient{TrillianLogClient: client}
+}
+
+// SetCorruption sets the manipulation applied to subsequent responses.
+func (c *ByzantineLogClient) SetCorruption(corruption Corruption) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.corruption = corruption
+}
+
+func (c *ByzantineLogClient) getCorruption() Corruption {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.corruption
+}
+
+// GetLatestSignedLogRoot forwards requests and optionally corrupts or replaces the returned root.
+func (c *ByzantineLogClient) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	resp, err := c.TrillianLogClient.GetLatestSignedLogRoot(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.firstRoot == nil {
+		c.firstRoot = proto.Clone(resp.SignedLogRoot).(*trillian.SignedLogRoot)
+	}
+	firstRoot := c.firstRoot
+	c.mu.Unlock()
+
+	switch c.getCorruption() {
+	case CorruptRootHash:
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(resp.SignedLogRoot.GetLogRoot()); err != nil {
+			return nil, err
+		}
+		root.RootHash = corruptHash(root.RootHash)
+		logRoot, err := root.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		resp.SignedLogRoot = &trillian.SignedLogRoot{LogRoot: logRoot}
+	case StaleRoot:
+		resp.SignedLogRoot = proto.Clone(firstRoot).(*trillian.SignedLogRoot)
+	case CorruptConsistencyProof:
+		corruptProof(resp.Proof)
+	}
+	return resp, nil
+}
+
+// GetInclusionProof forwards requests and optionally corrupts the returned proof.
+func (c *ByzantineLogClient) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	resp, err := c.TrillianLogClient.GetInclusionProof(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.getCorruption() == CorruptInclusionProof {
+		corruptProof(resp.Proof)
+	}
+	return resp, nil
+}
+
+// GetInclusionProofByHash forwards requests and optionally corrupts the returned proofs.
+func (c *ByzantineLogClient) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	resp, err := c.TrillianLogClient.GetInclusionProofByHash(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.getCorruption() == CorruptInclusionProof {
+		for _, p := range resp.Proof {
+			corruptProof(p)
+		}
+	}
+	return resp, nil
+}
+
+// GetConsistencyProof forwards requests and optionally corrupts the returned proof.
+func (c *ByzantineLogClient) GetConsistencyProof(ctx context.Context, in *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	resp, err := c.TrillianLogClient.GetConsistencyProof(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.getCorruption() == CorruptConsistencyProof {
+		corruptProof(resp.Proof)
+	}
+	return resp, nil
+}
+
+// GetLeavesByRange forwards requests and optionally truncates the returned leaves.
+func (c *ByzantineLogClient) GetLeavesByRange(ctx context.Context, in *trillian.GetLeavesByRangeRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error) {
+	resp, err := c.TrillianLogClient.GetLeavesByRange(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	switch c.getCorruption() {
+	case TruncateLeafRange:
+		if l := len(resp.Leaves); l > 0 {
+			resp.Leaves = resp.Leaves[:l-1]
+		}
+	case TruncateLeafValue:
+		for _, leaf := range resp.Leaves {
+			if l := len(leaf.LeafValue); l > 0 {
+				leaf.LeafValue = leaf.LeafValue[:l-1]
+			}
+		}
+	}
+	return resp, nil
+}
+
+// corruptProof modifies p so that it no longer verifies. Empty proofs are extended with a bogus
+// hash. Nil proofs are left untouched, as they're used to signal skew.
+func corruptProof(p *trillian.Proof) {
+	if p == nil {
+		return
+	}
+	if len(p.Hashes) == 0 {
+		p.Hashes = [][]byte{make([]byte, rfc6962.DefaultHasher.Size())}
+		return
+	}
+	p.Hashes[0] = corruptHash(p.Hashes[0])
+}
+
+// corruptHash returns a copy of hash with one bit flipped.
+func corruptHash(hash []byte) []byte {
+	ret := append([]byte(nil), hash...)
+	if len(ret) == 0 {
+		return []byte{1}
+	}
+	ret[0] ^= 4
+	return ret
+}
+
+// RunByzantineLogIntegration runs a log integration test using the given client and test
+// parameters, and then checks that every Corruption of the log responses is detected both by
+// the in-memory tree comparison of RunLogIntegration and by the verification of the client
+// library.
+//
+// params.CheckLogEmpty must be set, so that StaleRoot has an old root to serve.
+func RunByzantineLogIntegration(logClient trillian.TrillianLogClient, params TestParameters) error {
+	if !params.CheckLogEmpty {
+		return errors.New("byzantine integration test requires CheckLogEmpty")
+	}
+
+	bc := NewByzantineLogClient(logClient)
+	if err := RunLogIntegration(bc, params); err != nil {
+		return fmt.Errorf("honest log integration failed: %v", err)
+	}
+
+	entries, err := readEntries(params.TreeID, logClient, params)
+	if err != nil {
+		return fmt.Errorf("could not read back log entries: %v", err)
+	}
+	tree := buildMerkleTree(entries, params)
+	if err := checkClientVerification(bc, params, tree); err != nil {
+		return fmt.Errorf("honest client verification failed: %v", err)
+	}
+
+	// Subsequent runs only read and verify the already populated log.
+	readParams := params
+	readParams.CheckLogEmpty = false
+	readParams.QueueLeaves = false
+	readParams.AwaitSequencing = false
+
+	for _, corruption := range Corruptions {
+		glog.Infof("Checking that %v is detected ...", corruption)
+		bc.SetCorruption(corruption)
+		err := RunLogIntegration(bc, readParams)
+		if err == nil {
+			return fmt.Errorf("%v not detected by log integration", corruption)
+		}
+		glog.Infof("%v detected by log integration: %v", corruption, err)
+
+		err = checkClientVerification(bc, params, tree)
+		if err == nil {
+			return fmt.Errorf("%v not detected by client verification", corruption)
+		}
+		glog.Infof("%v detected by client verification: %v", corruption, err)
+	}
+	return nil
+}
+
+// checkClientVerification uses the client library to verify the log served by logClient, starting
+// from a root at half the size of tree, trusted by the client.
+func checkClientVerification(logClient trillian.TrillianLogClient, params TestParameters, tree *inmemory.Tree) error {
+	trustedSize := tree.Size() / 2
+	trusted := types.LogRootV1{TreeSize: trustedSize, RootHash: tree.HashAt(trustedSize)}
+	lc := client.New(params.TreeID, logClient, client.NewLogVerifier(rfc6962.DefaultHasher), trusted)
+
+	ctx, cancel := getRPCDeadlineContext(params)
+	defer cancel()
+
+	root, err := lc.UpdateRoot(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateRoot(): %v", err)
+	}
+	if root == nil {
+		return fmt.Errorf("UpdateRoot() did not advance the trusted root of size %d", trustedSize)
+	}
+
+	leaves, err := lc.ListByIndex(ctx, 0, min(params.ReadBatchSize, int64(root.TreeSize)))
+	if err != nil {
+		return fmt.Errorf("ListByIndex(): %v", err)
+	}
+	for _, leaf := range leaves {
+		resp, err := logClient.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+			LogId:     params.TreeID,
+			LeafIndex: leaf.LeafIndex,
+			TreeSize:  int64(root.TreeSize),
+		})
+		if err != nil {
+			return fmt.Errorf("GetInclusionProof(%d): %v", leaf.LeafIndex, err)
+		}
+		leafHash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue)
+		if err := lc.VerifyInclusionByHash(root, leafHash, resp.Proof); err != nil {
+			return fmt.Errorf("VerifyInclusionByHash(%d): %v", leaf.LeafIndex, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RunCompatibilityChecks calls the log and admin RPCs which RunLogIntegration
+// doesn't, against a log of at least 2 sequenced leaves, and checks that their
+// responses verify against the latest root of the log, whose encoding must
+// round-trip. Together with RunLogIntegration, it checks the wire
+// compatibility of servers of other releases with the client code in this
+// tree. RPCs added after the initial release of the API may be unimplemented
+// by the servers, in which case they're skipped.
+func RunCompatibilityChecks(logClient trillian.TrillianLogClient, adminClient trillian.TrillianAdminClient, params TestParameters) error {
+	ctx, cancel := getRPCDeadlineContext(params)
+	defer cancel()
+
+	tree, err := adminClient.GetTree(ctx, &trillian.GetTreeRequest{TreeId: params.TreeID})
+	if err != nil {
+		return fmt.Errorf("GetTree(): %v", err)
+	}
+	if got, want := tree.TreeType, trillian.TreeType_LOG; got != want {
+		return fmt.Errorf("GetTree() returned tree of type %v, want %v", got, want)
+	}
+	trees, err := adminClient.ListTrees(ctx, &trillian.ListTreesRequest{})
+	if err != nil {
+		return fmt.Errorf("ListTrees(): %v", err)
+	}
+	listed := false
+	for _, t := range trees.Tree {
+		listed = listed || t.TreeId == params.TreeID
+	}
+	if !listed {
+		return fmt.Errorf("ListTrees() didn't return tree %d", params.TreeID)
+	}
+
+	resp, err := logClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: params.TreeID})
+	if err != nil {
+		return fmt.Errorf("GetLatestSignedLogRoot(): %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(resp.SignedLogRoot.GetLogRoot()); err != nil {
+		return fmt.Errorf("could not read log root: %v", err)
+	}
+	if b, err := root.MarshalBinary(); err != nil {
+		return fmt.Errorf("could not encode log root: %v", err)
+	} else if !bytes.Equal(b, resp.SignedLogRoot.LogRoot) {
+		return fmt.Errorf("log root %x encodes back to %x", resp.SignedLogRoot.LogRoot, b)
+	}
+	if root.TreeSize < 2 {
+		return fmt.Errorf("log has %d leaves, want at least 2", root.TreeSize)
+	}
+	size := int64(root.TreeSize)
+
+	leaves, err := logClient.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{LogId: params.TreeID, StartIndex: 0, Count: 2})
+	if err != nil {
+		return fmt.Errorf("GetLeavesByRange(): %v", err)
+	}
+	if got := len(leaves.Leaves); got != 2 {
+		return fmt.Errorf("GetLeavesByRange() returned %d leaves, want 2", got)
+	}
+	leaf := leaves.Leaves[1]
+	leafHash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue)
+
+	byHash, err := logClient.GetInclusionProofByHash(ctx, &trillian.GetInclusionProofByHashRequest{LogId: params.TreeID, LeafHash: leafHash, TreeSize: size})
+	if err != nil {
+		return fmt.Errorf("GetInclusionProofByHash(): %v", err)
+	}
+	if len(byHash.Proof) == 0 {
+		return fmt.Errorf("GetInclusionProofByHash() returned no proof")
+	}
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, uint64(byHash.Proof[0].LeafIndex), root.TreeSize, leafHash, byHash.Proof[0].Hashes, root.RootHash); err != nil {
+		return fmt.Errorf("GetInclusionProofByHash() proof doesn't verify: %v", err)
+	}
+
+	entry, err := logClient.GetEntryAndProof(ctx, &trillian.GetEntryAndProofRequest{LogId: params.TreeID, LeafIndex: 1, TreeSize: size})
+	if err != nil {
+		return fmt.Errorf("GetEntryAndProof(): %v", err)
+	}
+	if !bytes.Equal(entry.Leaf.GetLeafValue(), leaf.LeafValue) {
+		return fmt.Errorf("GetEntryAndProof() returned leaf %q, want %q", entry.Leaf.GetLeafValue(), leaf.LeafValue)
+	}
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, 1, root.TreeSize, leafHash, entry.Proof.GetHashes(), root.RootHash); err != nil {
+		return fmt.Errorf("GetEntryAndProof() proof doesn't verify: %v", err)
+	}
+
+	firstRoot := rfc6962.DefaultHasher.HashLeaf(leaves.Leaves[0].LeafValue)
+	consistency, err := logClient.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{LogId: params.TreeID, FirstTreeSize: 1, SecondTreeSize: size})
+	if err != nil {
+		return fmt.Errorf("GetConsistencyProof(): %v", err)
+	}
+	if err := proof.VerifyConsistency(rfc6962.DefaultHasher, 1, root.TreeSize, consistency.Proof.GetHashes(), firstRoot, root.RootHash); err != nil {
+		return fmt.Errorf("GetConsistencyProof() proof doesn't verify: %v", err)
+	}
+
+	switch byIndex, err := logClient.GetLeavesByIndexList(ctx, &trillian.GetLeavesByIndexListRequest{LogId: params.TreeID, LeafIndex: []int64{1}}); {
+	case unimplemented("GetLeavesByIndexList", err):
+	case err != nil:
+		return fmt.Errorf("GetLeavesByIndexList(): %v", err)
+	case len(byIndex.Leaves) != 1 || !bytes.Equal(byIndex.Leaves[0].LeafValue, leaf.LeafValue):
+		return fmt.Errorf("GetLeavesByIndexList() returned %v, want leaf %q", byIndex.Leaves, leaf.LeafValue)
+	}
+
+	switch rng, err := logClient.GetCompactRange(ctx, &trillian.GetCompactRangeRequest{LogId: params.TreeID, Begin: 0, End: size, TreeSize: size}); {
+	case unimplemented("GetCompactRange", err):
+	case err != nil:
+		return fmt.Errorf("GetCompactRange(): %v", err)
+	default:
+		rf := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+		r, err := rf.NewRange(0, root.TreeSize, rng.Hashes)
+		if err != nil {
+			return fmt.Errorf("GetCompactRange() returned invalid range: %v", err)
+		}
+		if hash, err := r.GetRootHash(nil); err != nil {
+			return fmt.Errorf("GetCompactRange() root hash: %v", err)
+		} else if !bytes.Equal(hash, root.RootHash) {
+			return fmt.Errorf("GetCompactRange() root hash is %x, want %x", hash, root.RootHash)
+		}
+	}
+
+	switch usage, err := adminClient.GetTreeUsage(ctx, &trillian.GetTreeUsageRequest{TreeId: params.TreeID}); {
+	case unimplemented("GetTreeUsage", err):
+	case err != nil:
+		return fmt.Errorf("GetTreeUsage(): %v", err)
+	case usage.TreeId != params.TreeID:
+		return fmt.Errorf("GetTreeUsage() returned usage of tree %d", usage.TreeId)
+	}
+	return nil
+}
+
+// unimplemented returns whether err says the RPC isn't implemented by the
+// server, and logs that it's skipped if so.
+func unimplemented(rpc string, err error) bool {
+	if status.Code(err) != codes.Unimplemented {
+		return false
+	}
+	glog.Infof("Skipping %s, which isn't implemented by the server: %v", rpc, err)
+	return true
+}
@@ -103,6 +103,9 @@ kill_pid() {
 #   - number of log signers to run
 # Env:
 #   - If TEST_MYSQL_URI is set, uses that for the server --mysql_uri flag.
+#   - If TEST_SPANNER_DATABASE is set, uses that CloudSpanner database instead
+#     of MySQL, creating it if need be. SPANNER_EMULATOR_HOST selects the
+#     Cloud Spanner emulator.
 # Populates:
 #  - HTTP_SERVER_1   : first HTTP server
 #  - RPC_SERVER_1    : first RPC server
@@ -121,13 +124,20 @@ log_prep_test() {
   local rpc_server_count=${1:-1}
   local log_signer_count=${2:-1}
 
-  # Wipe the test database
-  yes | bash "${TRILLIAN_PATH}/scripts/resetdb.sh"
-
   local logserver_opts=''
   local logsigner_opts=''
   local has_etcd=0
 
+  if [[ "${TEST_SPANNER_DATABASE}" != "" ]]; then
+    # The log server creates the database before the signers are started.
+    local spanner_opts="--storage_system=cloud_spanner --cloudspanner_uri=${TEST_SPANNER_DATABASE} --quota_system=noop"
+    logserver_opts+=" ${spanner_opts} --cloudspanner_create_database"
+    logsigner_opts+=" ${spanner_opts}"
+  else
+    # Wipe the test database
+    yes | bash "${TRILLIAN_PATH}/scripts/resetdb.sh"
+  fi
+
   if [[ "${TEST_MYSQL_URI}" != "" ]]; then
     logserver_opts+=" --mysql_uri=${TEST_MYSQL_URI}"
     logsigner_opts+=" --mysql_uri=${TEST_MYSQL_URI}"
@@ -19,18 +19,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/client"
 	"github.com/google/trillian/client/backoff"
 	"github.com/google/trillian/types"
 	"github.com/transparency-dev/merkle/proof"
 	"github.com/transparency-dev/merkle/rfc6962"
 	inmemory "github.com/transparency-dev/merkle/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// queueLeavesBatchSize is the number of leaves sent in each request on the QueueLeaves stream.
+const queueLeavesBatchSize = 100
+
 // TestParameters bundles up all the settings for a test run
 type TestParameters struct {
 	TreeID              int64
@@ -47,6 +54,15 @@ type TestParameters struct {
 	SequencingPollWait  time.Duration
 	RPCRequestDeadline  time.Duration
 	CustomLeafPrefix    string
+	// Seed is the seed of the shuffling of the leaves before they are queued.
+	// A seed based on the current time is used, and logged, if it's 0. The
+	// leaves are queued in the same order by all runs with the same seed and
+	// leaf counts.
+	Seed int64
+	// QueueStart is the number of shuffled leaves which were already queued by
+	// a previous run with the same Seed, so that queueing resumes from the next
+	// leaf. The log isn't checked to be empty if it's set.
+	QueueStart int64
 }
 
 // DefaultTestParameters builds a TestParameters object for a normal
@@ -91,7 +107,7 @@ var consistencyProofBadTestParams = []consistencyProofParams{{0, 0}, {-1, 0}, {1
 // parameters.
 func RunLogIntegration(client trillian.TrillianLogClient, params TestParameters) error {
 	// Step 1 - Optionally check log starts empty then optionally queue leaves on server
-	if params.CheckLogEmpty {
+	if params.CheckLogEmpty && params.QueueStart == 0 {
 		glog.Infof("Checking log is empty before starting test")
 		resp, err := getLatestSignedLogRoot(client, params)
 		if err != nil {
@@ -110,9 +126,12 @@ func RunLogIntegration(client trillian.TrillianLogClient, params TestParameters)
 
 	preEntries := genEntries(params)
 	if params.QueueLeaves {
-		glog.Infof("Queueing %d leaves to log server ...", params.LeafCount)
-		if err := queueLeaves(client, params, preEntries); err != nil {
-			return fmt.Errorf("failed to queue leaves: %v", err)
+		if start := params.QueueStart; start < 0 || start > params.LeafCount {
+			return fmt.Errorf("queue start %d is outside of the %d leaves", start, params.LeafCount)
+		}
+		glog.Infof("Queueing %d leaves to log server from leaf %d ...", params.LeafCount-params.QueueStart, params.QueueStart)
+		if queued, err := queueLeaves(client, params, preEntries[params.QueueStart:]); err != nil {
+			return fmt.Errorf("failed to queue leaves, resume with queue start %d: %v", params.QueueStart+queued, err)
 		}
 	}
 
@@ -134,12 +153,12 @@ func RunLogIntegration(client trillian.TrillianLogClient, params TestParameters)
 		return fmt.Errorf("written and read entries mismatch: %v", err)
 	}
 
-	// Step 4 - Cross validation between log and memory tree root hashes
-	glog.Infof("Checking log STH with our constructed in-memory tree ...")
-	tree := buildMerkleTree(entries, params)
-	if err := checkLogRootHashMatches(tree, client, params); err != nil {
+	// Step 4 - Cross validation between log and incrementally verified root hashes
+	glog.Infof("Checking log STH with the leaves read back ...")
+	if err := checkLogRootHashMatches(entries, client, params); err != nil {
 		return fmt.Errorf("log consistency check failed: %v", err)
 	}
+	tree := buildMerkleTree(entries, params)
 
 	// Now that the basic tree has passed validation we can start testing proofs
 
@@ -210,10 +229,12 @@ func genEntries(params TestParameters) []*trillian.LogLeaf {
 
 	// Shuffle the leaves to see if that breaks things, but record the rand seed
 	// so we can reproduce failures.
-	seed := time.Now().UnixNano()
-	rand.Seed(seed)
-	perm := rand.Perm(int(params.LeafCount))
-	glog.Infof("Generating %d leaves, %d unique, using permutation seed %d", params.LeafCount, params.UniqueLeaves, seed)
+	seed := params.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	perm := rand.New(rand.NewSource(seed)).Perm(int(params.LeafCount))
+	glog.Infof("Generating %d leaves, %d unique, using permutation seed %d (set the seed to reproduce this order)", params.LeafCount, params.UniqueLeaves, seed)
 
 	leaves := make([]*trillian.LogLeaf, 0, params.LeafCount)
 	for l := int64(0); l < params.LeafCount; l++ {
@@ -222,17 +243,86 @@ func genEntries(params TestParameters) []*trillian.LogLeaf {
 	return leaves
 }
 
-func queueLeaves(client trillian.TrillianLogClient, params TestParameters, entries []*trillian.LogLeaf) error {
+// queueLeaves queues entries in order, and returns the number of leading
+// entries which were queued, which is less than their number on error.
+func queueLeaves(client trillian.TrillianLogClient, params TestParameters, entries []*trillian.LogLeaf) (int64, error) {
 	glog.Infof("Queueing %d leaves...", len(entries))
 
-	for _, leaf := range entries {
-		ctx, cancel := getRPCDeadlineContext(params)
-		b := &backoff.Backoff{
-			Min:    100 * time.Millisecond,
-			Max:    10 * time.Second,
-			Factor: 2,
-			Jitter: true,
+	var acked int64
+	for start := 0; start < len(entries); start += queueLeavesBatchSize {
+		end := start + queueLeavesBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		n, err := queueLeavesBatch(client, params, entries[start:end])
+		if status.Code(err) == codes.Unimplemented {
+			// Servers older than the QueueLeaves stream only have QueueLeaf.
+			glog.Infof("QueueLeaves is not implemented by the server, queueing leaves one by one")
+			n, err := queueLeavesOneByOne(client, params, entries[start:])
+			return acked + n, err
+		}
+		acked += n
+		if err != nil {
+			return acked, err
+		}
+	}
+	return acked, nil
+}
+
+// queueLeavesBatch queues the given leaves on a QueueLeaves stream of their
+// own, which is retried with a new stream on retriable errors until the RPC
+// deadline. Leaves which were queued by a failed attempt are reported as
+// duplicates by the next one.
+func queueLeavesBatch(client trillian.TrillianLogClient, params TestParameters, leaves []*trillian.LogLeaf) (int64, error) {
+	ctx, cancel := getRPCDeadlineContext(params)
+	defer cancel()
+	b := newQueueBackoff()
+	var acked int64
+	err := b.Retry(ctx, func() error {
+		streamCtx, cancelStream := context.WithCancel(ctx)
+		defer cancelStream()
+		acked = 0
+		stream, err := client.QueueLeaves(streamCtx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&trillian.QueueLeavesRequest{LogId: params.TreeID, Leaves: leaves}); err != nil {
+			// The status of the stream is returned by Recv.
+			_, err = stream.Recv()
+			return err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
 		}
+		for acked < int64(len(leaves)) {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				return fmt.Errorf("QueueLeaves stream ended after %d of %d leaves", acked, len(leaves))
+			}
+			if err != nil {
+				return err
+			}
+			for _, leaf := range resp.QueuedLeaves {
+				// Duplicate leaves are expected, as some tests queue fewer unique
+				// leaves than leaves, or resume queueing.
+				if code := codes.Code(leaf.GetStatus().GetCode()); code != codes.OK && code != codes.AlreadyExists {
+					return fmt.Errorf("failed to queue leaf: %v", status.ErrorProto(leaf.Status))
+				}
+				acked++
+			}
+		}
+		return nil
+	})
+	return acked, err
+}
+
+// queueLeavesOneByOne queues the given leaves with QueueLeaf, retrying each
+// request on retriable errors until the RPC deadline.
+func queueLeavesOneByOne(client trillian.TrillianLogClient, params TestParameters, leaves []*trillian.LogLeaf) (int64, error) {
+	var acked int64
+	for _, leaf := range leaves {
+		ctx, cancel := getRPCDeadlineContext(params)
+		b := newQueueBackoff()
 		err := b.Retry(ctx, func() error {
 			_, err := client.QueueLeaf(ctx, &trillian.QueueLeafRequest{
 				LogId: params.TreeID,
@@ -242,10 +332,20 @@ func queueLeaves(client trillian.TrillianLogClient, params TestParameters, entri
 		})
 		cancel()
 		if err != nil {
-			return err
+			return acked, err
 		}
+		acked++
+	}
+	return acked, nil
+}
+
+func newQueueBackoff() *backoff.Backoff {
+	return &backoff.Backoff{
+		Min:    100 * time.Millisecond,
+		Max:    10 * time.Second,
+		Factor: 2,
+		Jitter: true,
 	}
-	return nil
 }
 
 func waitForSequencing(treeID int64, client trillian.TrillianLogClient, params TestParameters) error {
@@ -346,9 +446,9 @@ func verifyEntries(written, read []*trillian.LogLeaf) error {
 	return nil
 }
 
-func checkLogRootHashMatches(tree *inmemory.Tree, client trillian.TrillianLogClient, params TestParameters) error {
-	// Check the STH against the hash we got from our tree
-	resp, err := getLatestSignedLogRoot(client, params)
+func checkLogRootHashMatches(leaves []*trillian.LogLeaf, logClient trillian.TrillianLogClient, params TestParameters) error {
+	// Check the STH against the hash of the leaves, as a monitor would
+	resp, err := getLatestSignedLogRoot(logClient, params)
 	if err != nil {
 		return err
 	}
@@ -357,12 +457,14 @@ func checkLogRootHashMatches(tree *inmemory.Tree, client trillian.TrillianLogCli
 		return err
 	}
 
-	// Hash must not be empty and must match the one we built ourselves
-	if got, want := root.RootHash, tree.Hash(); !bytes.Equal(got, want) {
-		return fmt.Errorf("root hash mismatch expected got: %x want: %x", got, want)
+	v, err := client.NewIncrementalVerifier(rfc6962.DefaultHasher, nil)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	if err := v.Append(leaves...); err != nil {
+		return err
+	}
+	return v.VerifyRoot(&root)
 }
 
 // checkInclusionProofLeafOutOfRange requests an inclusion proof beyond the current tree size. This
@@ -15,21 +15,39 @@
 package integration
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/client"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
 	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/mysql"
 	"github.com/google/trillian/storage/testdb"
 	"github.com/google/trillian/testonly/integration"
+	"github.com/google/trillian/testonly/replay"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/compact"
+	"pgregory.net/rapid"
 
 	stestonly "github.com/google/trillian/storage/testonly"
 )
@@ -37,6 +55,11 @@ import (
 var (
 	treeIDFlag                 = flag.Int64("treeid", -1, "The tree id to use")
 	serverFlag                 = flag.String("log_rpc_server", "localhost:8092", "Server address:port")
+	adminServerFlag            = flag.String("admin_rpc_server", "", "Admin server address:port. If set, the tree is checked to be an active log before the test")
+	tlsFlag                    = flag.Bool("tls", false, "If true, connects to the servers over TLS, trusting the system roots unless --tls_ca_file is set")
+	tlsCAFileFlag              = flag.String("tls_ca_file", "", "Path to the PEM certificates of the authorities trusted to sign the certificates of the servers. Implies --tls")
+	tlsServerNameFlag          = flag.String("tls_server_name", "", "Overrides the name checked against the certificates of the servers. Implies --tls")
+	authTokenFileFlag          = flag.String("auth_token_file", "", "Path to a file containing a bearer token sent with every RPC. Requires TLS")
 	queueLeavesFlag            = flag.Bool("queue_leaves", true, "If true queues leaves, false just reads from the log")
 	awaitSequencingFlag        = flag.Bool("await_sequencing", true, "If true then waits until log size is at least num_leaves")
 	checkLogEmptyFlag          = flag.Bool("check_log_empty", true, "If true ensures log is empty before queuing anything")
@@ -49,6 +72,12 @@ var (
 	waitBetweenQueueChecksFlag = flag.Duration("queue_poll_wait", time.Second*5, "How frequently to check the queue while waiting")
 	rpcRequestDeadlineFlag     = flag.Duration("rpc_deadline", time.Second*10, "Deadline to use for all RPC requests")
 	customLeafPrefixFlag       = flag.String("custom_leaf_prefix", "", "Prefix string added to all queued leaves")
+	seedFlag                   = flag.Int64("seed", 0, "Seed of the order in which leaves are queued, to reproduce the order of a previous run. A random seed is used, and logged, if zero")
+	queueStartFlag             = flag.Int64("queue_start", 0, "Number of leaves already queued by a previous run with the same --seed, to resume queueing from. Skips --check_log_empty")
+	logPropertiesFlag          = flag.Bool("log_properties", false, "If true, runs property-based tests against logs created with --admin_rpc_server. Use --rapid.checks to set the number of test cases")
+	soakDurationFlag           = flag.Duration("soak_duration", 0, "If set, runs a soak test of the log for this long, including the time of the run resumed from --soak_checkpoint_file")
+	soakCheckpointFileFlag     = flag.String("soak_checkpoint_file", "", "File to save the state of the soak test to, and resume it from")
+	maxMergeDelayFlag          = flag.Duration("max_merge_delay", 5*time.Minute, "Time within which the soak test expects queued leaves to be sequenced")
 )
 
 func TestLiveLogIntegration(t *testing.T) {
@@ -72,22 +101,44 @@ func TestLiveLogIntegration(t *testing.T) {
 		SequencingPollWait:  *waitBetweenQueueChecksFlag,
 		RPCRequestDeadline:  *rpcRequestDeadlineFlag,
 		CustomLeafPrefix:    *customLeafPrefixFlag,
+		Seed:                *seedFlag,
+		QueueStart:          *queueStartFlag,
 	}
 	if params.StartLeaf < 0 || params.LeafCount <= 0 {
 		t.Fatalf("Start leaf index must be >= 0 (%d) and number of leaves must be > 0 (%d)", params.StartLeaf, params.LeafCount)
 	}
 
+	opts := RemoteOptions{
+		LogAddress:    *serverFlag,
+		AdminAddress:  *adminServerFlag,
+		TLS:           *tlsFlag,
+		TLSCAFile:     *tlsCAFileFlag,
+		TLSServerName: *tlsServerNameFlag,
+	}
+	if *authTokenFileFlag != "" {
+		token, err := os.ReadFile(*authTokenFileFlag)
+		if err != nil {
+			t.Fatalf("Failed to read auth token: %v", err)
+		}
+		opts.AuthToken = strings.TrimSpace(string(token))
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// TODO: Other options apart from insecure connections
-	conn, err := grpc.DialContext(ctx, *serverFlag, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	remote, err := DialRemote(ctx, opts)
 	if err != nil {
-		t.Fatalf("Failed to connect to log server: %v", err)
+		t.Fatalf("Failed to connect to Trillian: %v", err)
+	}
+	defer remote.Close()
+
+	if *adminServerFlag != "" {
+		if _, err := remote.CheckTree(ctx, params.TreeID); err != nil {
+			t.Fatalf("Unusable tree: %v", err)
+		}
 	}
-	defer conn.Close()
 
-	lc := trillian.NewTrillianLogClient(conn)
+	lc := remote.Log
 	if err := RunLogIntegration(lc, params); err != nil {
 		t.Fatalf("Test failed: %v", err)
 	}
@@ -147,3 +198,573 @@ func TestInProcessLogIntegrationDuplicateLeaves(t *testing.T) {
 		t.Fatalf("Test failed: %v", err)
 	}
 }
+
+func TestGenEntriesSeed(t *testing.T) {
+	params := DefaultTestParameters(1)
+	params.UniqueLeaves = 100
+	params.Seed = 42
+	leaves := genEntries(params)
+	if diff := cmp.Diff(leaves, genEntries(params), protocmp.Transform()); diff != "" {
+		t.Errorf("genEntries() with the same seed diff (-first +second):\n%s", diff)
+	}
+	params.Seed = 43
+	if diff := cmp.Diff(leaves, genEntries(params), protocmp.Transform()); diff == "" {
+		t.Error("genEntries() with different seeds returned the same leaves")
+	}
+}
+
+func TestInProcessLogIntegrationResumeQueueing(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	// Queue a prefix of the leaves, as if queueing was interrupted, and resume.
+	params := DefaultTestParameters(tree.TreeId)
+	params.LeafCount = 200
+	params.UniqueLeaves = 200
+	params.SequencingPollWait = 100 * time.Millisecond
+	params.Seed = 1
+	if _, err := queueLeaves(env.Log, params, genEntries(params)[:120]); err != nil {
+		t.Fatalf("queueLeaves(): %v", err)
+	}
+	params.QueueStart = 120
+	if err := RunLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func TestInProcessLogProperties(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	rapid.Check(t, CheckLogProperties(PropertyTarget{
+		Log:   env.Log,
+		Admin: env.Admin,
+		Tree:  stestonly.LogTree,
+		Integrate: func(ctx context.Context, treeID int64) error {
+			tree, err := storage.GetTree(ctx, reggie.AdminStorage, treeID)
+			if err != nil {
+				return err
+			}
+			_, err = log.IntegrateBatch(ctx, tree, 1000, 0, 0, clock.System, reggie.LogStorage, reggie.QuotaManager)
+			return err
+		},
+		NoDedup:             true,
+		MaxSteps:            20,
+		SequencingPollWait:  100 * time.Millisecond,
+		SequencingWaitTotal: 10 * time.Second,
+	}))
+}
+
+func TestLiveLogProperties(t *testing.T) {
+	flag.Parse()
+	if !*logPropertiesFlag || *adminServerFlag == "" {
+		t.Skip("Log property tests skipped as --log_properties and --admin_rpc_server aren't set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	remote, err := DialRemote(ctx, RemoteOptions{LogAddress: *serverFlag, AdminAddress: *adminServerFlag})
+	if err != nil {
+		t.Fatalf("Failed to connect to Trillian: %v", err)
+	}
+	defer remote.Close()
+
+	rapid.Check(t, CheckLogProperties(PropertyTarget{
+		Log:                 remote.Log,
+		Admin:               remote.Admin,
+		Tree:                stestonly.LogTree,
+		MaxSteps:            20,
+		SequencingPollWait:  *waitBetweenQueueChecksFlag,
+		SequencingWaitTotal: *waitForSequencingFlag,
+	}))
+}
+
+func TestInProcessLogSoak(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultSoakParameters(tree.TreeId, time.Hour)
+	params.CheckEvery = 200 * time.Millisecond
+	params.MaxMergeDelay = 10 * time.Second
+	params.MaxPending = 100
+	params.CheckpointFile = filepath.Join(t.TempDir(), "soak.json")
+	params.CheckpointEvery = 100 * time.Millisecond
+	params.Seed = 1
+
+	// Interrupt a first run, as if it crashed, and resume it.
+	cctx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+	defer cancel()
+	if err := RunLogSoak(cctx, env.Log, params); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunLogSoak() with interruption: %v, want %v", err, context.DeadlineExceeded)
+	}
+	first, err := loadSoakState(params)
+	if err != nil {
+		t.Fatalf("loadSoakState(): %v", err)
+	}
+	if first.Queued == 0 || first.Elapsed == 0 {
+		t.Fatalf("Interrupted run saved state after %v with %d leaves queued, want progress", first.Elapsed, first.Queued)
+	}
+
+	params.Duration = first.Elapsed + time.Second
+	if err := RunLogSoak(ctx, env.Log, params); err != nil {
+		t.Fatalf("RunLogSoak(): %v", err)
+	}
+	state, err := loadSoakState(params)
+	if err != nil {
+		t.Fatalf("loadSoakState(): %v", err)
+	}
+	if state.Seed != first.Seed || state.Queued <= first.Queued || state.Elapsed < params.Duration {
+		t.Errorf("Resumed run saved seed %d after %v with %d leaves queued, want seed %d after %v with more than %d", state.Seed, state.Elapsed, state.Queued, first.Seed, params.Duration, first.Queued)
+	}
+	if len(state.Pending) != 0 || uint64(len(state.Sequenced)) != state.Queued {
+		t.Errorf("Resumed run saved %d leaves pending and %d sequenced of %d, want all sequenced", len(state.Pending), len(state.Sequenced), state.Queued)
+	}
+}
+
+func TestLiveLogSoak(t *testing.T) {
+	flag.Parse()
+	if *treeIDFlag == -1 || *soakDurationFlag == 0 {
+		t.Skip("Log soak test skipped as --treeid and --soak_duration aren't set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	remote, err := DialRemote(ctx, RemoteOptions{LogAddress: *serverFlag})
+	if err != nil {
+		t.Fatalf("Failed to connect to Trillian: %v", err)
+	}
+	defer remote.Close()
+
+	params := DefaultSoakParameters(*treeIDFlag, *soakDurationFlag)
+	params.MaxMergeDelay = *maxMergeDelayFlag
+	params.ReadBatchSize = *readBatchSizeFlag
+	params.RPCRequestDeadline = *rpcRequestDeadlineFlag
+	params.Seed = *seedFlag
+	params.CheckpointFile = *soakCheckpointFileFlag
+	if err := RunLogSoak(context.Background(), remote.Log, params); err != nil {
+		t.Fatalf("Soak test failed: %v", err)
+	}
+}
+
+func TestInProcessByzantineLogIntegration(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	ms := memory.NewLogStorage(ts, nil)
+
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   ms,
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	params.SequencingPollWait = time.Second
+	if err := RunByzantineLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func TestInProcessMultiLogIntegration(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	const numTrees = 4
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	treeIDs := make([]int64, 0, numTrees)
+	for i := 0; i < numTrees; i++ {
+		tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+			Tree: stestonly.LogTree,
+		}, env.Admin, env.Log)
+		if err != nil {
+			t.Fatalf("Failed to create log: %v", err)
+		}
+		treeIDs = append(treeIDs, tree.TreeId)
+	}
+
+	params := DefaultTestParameters(0)
+	params.LeafCount = 200
+	params.UniqueLeaves = 200
+	params.SequencingPollWait = 100 * time.Millisecond
+	if err := RunMultiLogIntegration(env.Log, treeIDs, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func TestInProcessFakeTime(t *testing.T) {
+	ctx := context.Background()
+	const guardWindow = time.Minute
+	const maxRootDuration = time.Hour
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+	fakeTime := clock.NewFake(time.Now())
+
+	env, err := integration.NewLogEnvWithOptions(ctx, 1, reggie, integration.LogEnvOptions{
+		TimeSource:           fakeTime,
+		SequencerGuardWindow: guardWindow,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	logTree := proto.Clone(stestonly.LogTree).(*trillian.Tree)
+	logTree.MaxRootDuration = durationpb.New(maxRootDuration)
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{Tree: logTree}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	// tick moves the fake clock forward by d in steps of the sequencer
+	// interval, so that the sequencer runs at each step.
+	tick := func(d time.Duration) {
+		for end := fakeTime.Now().Add(d); fakeTime.Now().Before(end); {
+			fakeTime.Set(fakeTime.Now().Add(integration.SequencerInterval))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	root := func() types.LogRootV1 {
+		t.Helper()
+		resp, err := env.Log.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: tree.TreeId})
+		if err != nil {
+			t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(resp.SignedLogRoot.LogRoot); err != nil {
+			t.Fatalf("UnmarshalBinary(): %v", err)
+		}
+		return root
+	}
+	// awaitRoot ticks the fake clock until the log has a root newer than
+	// the given one.
+	awaitRoot := func(prev types.LogRootV1) types.LogRootV1 {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 10*time.Second; {
+			tick(integration.SequencerInterval)
+			if r := root(); r.TimestampNanos > prev.TimestampNanos {
+				return r
+			}
+		}
+		t.Fatalf("No root newer than %+v", prev)
+		return prev
+	}
+
+	initRoot := root()
+	if _, err := env.Log.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: &trillian.LogLeaf{LeafValue: []byte("Leaf 0")}}); err != nil {
+		t.Fatalf("QueueLeaf(): %v", err)
+	}
+	tick(guardWindow / 2)
+	if r := root(); r.TimestampNanos != initRoot.TimestampNanos {
+		t.Fatalf("Leaf sequenced within the guard window, root %+v", r)
+	}
+	leafRoot := awaitRoot(initRoot)
+	if leafRoot.TreeSize != 1 {
+		t.Fatalf("Root after the guard window has size %d, want 1", leafRoot.TreeSize)
+	}
+	if got := time.Duration(leafRoot.TimestampNanos - initRoot.TimestampNanos); got < guardWindow {
+		t.Errorf("Leaf sequenced %v after it was queued, want at least %v", got, guardWindow)
+	}
+
+	// Without new leaves, a new root is only signed once the latest is older
+	// than the max root duration.
+	fakeTime.Set(fakeTime.Now().Add(maxRootDuration - guardWindow))
+	tick(guardWindow / 2)
+	if r := root(); r.TimestampNanos != leafRoot.TimestampNanos {
+		t.Fatalf("Root signed within the max root duration: %+v", r)
+	}
+	newRoot := awaitRoot(leafRoot)
+	if got := time.Duration(newRoot.TimestampNanos - leafRoot.TimestampNanos); got < maxRootDuration {
+		t.Errorf("New root signed %v after the previous one, want at least %v", got, maxRootDuration)
+	}
+	if newRoot.TreeSize != leafRoot.TreeSize {
+		t.Errorf("New root has size %d, want %d", newRoot.TreeSize, leafRoot.TreeSize)
+	}
+}
+
+func TestInProcessCompatibilityChecks(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	params.LeafCount = 200
+	params.UniqueLeaves = 200
+	params.SequencingPollWait = 100 * time.Millisecond
+	if err := RunLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := RunCompatibilityChecks(env.Log, env.Admin, params); err != nil {
+		t.Fatalf("Compatibility checks failed: %v", err)
+	}
+}
+
+// memoryTamperer corrupts the leaves of memory storage in place, and the
+// Merkle nodes through its storage API.
+type memoryTamperer struct {
+	reggie extension.Registry
+}
+
+func (m memoryTamperer) FlipLeafBit(ctx context.Context, treeID, index int64) error {
+	return memory.TamperLeaf(m.reggie.LogStorage, treeID, index, func(leaf *trillian.LogLeaf) {
+		value := append([]byte(nil), leaf.LeafValue...)
+		value[0] ^= 1
+		leaf.LeafValue = value
+	})
+}
+
+func (m memoryTamperer) FlipNodeBit(ctx context.Context, treeID int64, id compact.NodeID) error {
+	return FlipStoredNodeBit(ctx, m.reggie.LogStorage, m.reggie.AdminStorage, treeID, id)
+}
+
+func TestInProcessTamperingIntegration(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	params.SequencingPollWait = 100 * time.Millisecond
+	scrub := func(ctx context.Context, treeID int64) (*log.ScrubReport, error) {
+		tree, err := storage.GetTree(ctx, reggie.AdminStorage, treeID)
+		if err != nil {
+			return nil, err
+		}
+		return log.ScrubTree(ctx, tree, reggie.LogStorage, log.ScrubOptions{})
+	}
+	if err := RunTamperingIntegration(env.Log, params, memoryTamperer{reggie: reggie}, scrub); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+// mysqlTamperer corrupts the leaves of MySQL storage with SQL statements, and
+// the Merkle nodes through its storage API.
+type mysqlTamperer struct {
+	db     *sql.DB
+	reggie extension.Registry
+}
+
+func (m mysqlTamperer) FlipLeafBit(ctx context.Context, treeID, index int64) error {
+	var id, value []byte
+	if err := m.db.QueryRowContext(ctx, `
+		SELECT l.LeafIdentityHash, l.LeafValue
+		FROM SequencedLeafData s JOIN LeafData l
+		ON s.TreeId = l.TreeId AND s.LeafIdentityHash = l.LeafIdentityHash
+		WHERE s.TreeId = ? AND s.SequenceNumber = ?`, treeID, index).Scan(&id, &value); err != nil {
+		return err
+	}
+	value[0] ^= 1
+	_, err := m.db.ExecContext(ctx, "UPDATE LeafData SET LeafValue = ? WHERE TreeId = ? AND LeafIdentityHash = ?", value, treeID, id)
+	return err
+}
+
+func (m mysqlTamperer) FlipNodeBit(ctx context.Context, treeID int64, id compact.NodeID) error {
+	return FlipStoredNodeBit(ctx, m.reggie.LogStorage, m.reggie.AdminStorage, treeID, id)
+}
+
+func TestInProcessMySQLTamperingIntegration(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	const numSequencers = 2
+	db, done, err := testdb.NewTrillianDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done(ctx)
+	reggie := extension.Registry{
+		AdminStorage: mysql.NewAdminStorage(db),
+		LogStorage:   mysql.NewLogStorage(db, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, reggie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	params.SequencingPollWait = 100 * time.Millisecond
+	scrub := func(ctx context.Context, treeID int64) (*log.ScrubReport, error) {
+		tree, err := storage.GetTree(ctx, reggie.AdminStorage, treeID)
+		if err != nil {
+			return nil, err
+		}
+		return log.ScrubTree(ctx, tree, reggie.LogStorage, log.ScrubOptions{})
+	}
+	if err := RunTamperingIntegration(env.Log, params, mysqlTamperer{db: db, reggie: reggie}, scrub); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func TestReplayLogIntegration(t *testing.T) {
+	ctx := context.Background()
+	const numSequencers = 2
+	ts := memory.NewTreeStorage()
+	reggie := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+
+	// Record a run against in-process servers.
+	rec := replay.NewRecorder()
+	clientOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, rec.DialOptions()...)
+	env, err := integration.NewLogEnvWithRegistryAndGRPCOptions(ctx, numSequencers, reggie, nil, clientOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	params.LeafCount = 200
+	params.UniqueLeaves = 200
+	params.SequencingPollWait = 100 * time.Millisecond
+	params.Seed = 1
+	if err := RunLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	var recording bytes.Buffer
+	if err := rec.Save(&recording); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	// Replay it without the servers.
+	conn, err := replay.Load(&recording)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if err := RunLogIntegration(trillian.NewTrillianLogClient(conn), params); err != nil {
+		t.Fatalf("Replayed test failed: %v", err)
+	}
+	// Only the calls of the tree creation are left.
+	for _, method := range conn.Unused() {
+		if !strings.Contains(method, "CreateTree") && !strings.Contains(method, "InitLog") && !strings.Contains(method, "GetTree") {
+			t.Errorf("Recorded call of %s wasn't replayed", method)
+		}
+	}
+}
@@ -35,7 +35,8 @@ go test \
   ./ \
   --log_rpc_server="${TRILLIAN_SERVER}" \
   --treeid ${TEST_TREE_ID} \
-  --alsologtostderr
+  --alsologtostderr \
+  "${@:2}"
 RESULT=$?
 set -e
 popd
@@ -0,0 +1,367 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	inmemory "github.com/transparency-dev/merkle/testonly"
+	"google.golang.org/grpc/codes"
+	"pgregory.net/rapid"
+)
+
+// PropertyTarget is the log server checked by the property-based tests of
+// CheckLogProperties.
+type PropertyTarget struct {
+	Log   trillian.TrillianLogClient
+	Admin trillian.TrillianAdminClient
+	// Tree is the template of the trees created for each test case.
+	Tree *trillian.Tree
+	// Integrate, if set, integrates the queued leaves of a tree, so that the
+	// tests don't wait for the sequencers of the log to do so.
+	Integrate func(ctx context.Context, treeID int64) error
+	// NoDedup is set if the storage of the log doesn't deduplicate leaves,
+	// like the memory storage, so that duplicate leaves are sequenced again.
+	NoDedup bool
+	// MaxSteps is the maximum number of operations of a test case.
+	MaxSteps int
+	// SequencingPollWait is the time between checks that the queued leaves
+	// of a tree are sequenced, which fail after SequencingWaitTotal.
+	SequencingPollWait  time.Duration
+	SequencingWaitTotal time.Duration
+}
+
+// CheckLogProperties returns a rapid property which runs a random sequence
+// of operations against a new log of the target: queueing leaves, some of
+// them duplicates, waiting for them to be sequenced, reading leaves, and
+// getting inclusion and consistency proofs. Every result is checked against an
+// in-memory reference tree of the leaves sequenced by the log. The operations
+// are logged, so that rapid reports the minimal sequence of operations which
+// fails when it shrinks a failing test case.
+func CheckLogProperties(target PropertyTarget) func(*rapid.T) {
+	return func(t *rapid.T) {
+		m := newLogMachine(t, target)
+		defer m.cleanup()
+		actions := map[string]func(*rapid.T){
+			"queue":         m.queue,
+			"sequence":      m.sequence,
+			"read":          m.read,
+			"inclusion":     m.inclusion,
+			"inclusionHash": m.inclusionByHash,
+			"consistency":   m.consistency,
+			"latestLogRoot": m.latestRoot,
+		}
+		names := make([]string, 0, len(actions))
+		for name := range actions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		steps := rapid.IntRange(1, target.MaxSteps).Draw(t, "steps").(int)
+		for i := 0; i < steps; i++ {
+			actions[rapid.SampledFrom(names).Draw(t, "action").(string)](t)
+		}
+	}
+}
+
+// logMachine is the state of a test case of CheckLogProperties.
+type logMachine struct {
+	ctx    context.Context
+	target PropertyTarget
+	treeID int64
+	// ref is the reference tree of the sequenced leaves, whose values are in
+	// leaves.
+	ref    *inmemory.Tree
+	leaves [][]byte
+	// queued are the values of all the leaves queued, and pending the number
+	// of times each value is queued but not sequenced yet.
+	queued  [][]byte
+	pending map[string]int
+	// numPending is the total number of leaves pending.
+	numPending int
+}
+
+func newLogMachine(t *rapid.T, target PropertyTarget) *logMachine {
+	ctx := context.Background()
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{Tree: target.Tree}, target.Admin, target.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+	t.Logf("Created log %d", tree.TreeId)
+	return &logMachine{
+		ctx:     ctx,
+		target:  target,
+		treeID:  tree.TreeId,
+		ref:     inmemory.New(rfc6962.DefaultHasher),
+		pending: make(map[string]int),
+	}
+}
+
+func (m *logMachine) cleanup() {
+	if _, err := m.target.Admin.DeleteTree(m.ctx, &trillian.DeleteTreeRequest{TreeId: m.treeID}); err != nil {
+		glog.Warningf("Failed to delete log %d: %v", m.treeID, err)
+	}
+}
+
+// size returns the number of leaves known to be sequenced.
+func (m *logMachine) size() uint64 {
+	return m.ref.Size()
+}
+
+// queue queues a few leaves, which are new or duplicates of queued leaves.
+func (m *logMachine) queue(t *rapid.T) {
+	count := rapid.IntRange(1, 8).Draw(t, "count").(int)
+	for i := 0; i < count; i++ {
+		var value []byte
+		if len(m.queued) > 0 && rapid.Bool().Draw(t, "duplicate").(bool) {
+			value = m.queued[rapid.IntRange(0, len(m.queued)-1).Draw(t, "duplicateOf").(int)]
+		} else {
+			value = []byte(fmt.Sprintf("leaf %d", len(m.queued)))
+		}
+		t.Logf("QueueLeaf(%q)", value)
+		resp, err := m.target.Log.QueueLeaf(m.ctx, &trillian.QueueLeafRequest{LogId: m.treeID, Leaf: &trillian.LogLeaf{LeafValue: value}})
+		if err != nil {
+			t.Fatalf("QueueLeaf(%q): %v", value, err)
+		}
+		dup := m.isQueued(value) && !m.target.NoDedup
+		got, want := codes.Code(resp.QueuedLeaf.GetStatus().GetCode()), codes.OK
+		if dup {
+			want = codes.AlreadyExists
+		}
+		if got != want {
+			t.Fatalf("QueueLeaf(%q) returned status %v, want %v", value, got, want)
+		}
+		if !dup {
+			m.queued = append(m.queued, value)
+			m.pending[string(value)]++
+			m.numPending++
+		}
+	}
+}
+
+func (m *logMachine) isQueued(value []byte) bool {
+	for _, v := range m.queued {
+		if bytes.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sequence waits for the pending leaves to be sequenced, checks that they
+// were, and adds them to the reference tree.
+func (m *logMachine) sequence(t *rapid.T) {
+	want := m.size() + uint64(m.numPending)
+	t.Logf("Sequence(%d pending)", m.numPending)
+	if m.target.Integrate != nil {
+		if err := m.target.Integrate(m.ctx, m.treeID); err != nil {
+			t.Fatalf("Integrate(): %v", err)
+		}
+	}
+	root := m.awaitSize(t, want)
+	if root.TreeSize != want {
+		t.Fatalf("Log grew to size %d, want %d", root.TreeSize, want)
+	}
+	if want == m.size() {
+		return
+	}
+
+	resp, err := m.target.Log.GetLeavesByRange(m.ctx, &trillian.GetLeavesByRangeRequest{LogId: m.treeID, StartIndex: int64(m.size()), Count: int64(want - m.size())})
+	if err != nil {
+		t.Fatalf("GetLeavesByRange(): %v", err)
+	}
+	if got, want := len(resp.Leaves), m.numPending; got != want {
+		t.Fatalf("GetLeavesByRange() returned %d sequenced leaves, want %d", got, want)
+	}
+	for i, leaf := range resp.Leaves {
+		if got, want := leaf.LeafIndex, int64(m.size()); got != want {
+			t.Fatalf("GetLeavesByRange() returned leaf %d at index %d, want %d", i, got, want)
+		}
+		if m.pending[string(leaf.LeafValue)] == 0 {
+			t.Fatalf("Leaf %q sequenced at %d wasn't pending", leaf.LeafValue, leaf.LeafIndex)
+		}
+		m.pending[string(leaf.LeafValue)]--
+		m.numPending--
+		m.leaves = append(m.leaves, leaf.LeafValue)
+		m.ref.AppendData(leaf.LeafValue)
+	}
+	if !bytes.Equal(root.RootHash, m.ref.Hash()) {
+		t.Fatalf("Root hash at size %d is %x, want %x", root.TreeSize, root.RootHash, m.ref.Hash())
+	}
+}
+
+// awaitSize waits for a root of the log of at least the given size, and
+// returns it.
+func (m *logMachine) awaitSize(t *rapid.T, size uint64) *types.LogRootV1 {
+	deadline := time.Now().Add(m.target.SequencingWaitTotal)
+	for {
+		root := m.getRoot(t)
+		if root.TreeSize >= size {
+			return root
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Log didn't grow to size %d within %v, is %d", size, m.target.SequencingWaitTotal, root.TreeSize)
+		}
+		time.Sleep(m.target.SequencingPollWait)
+	}
+}
+
+func (m *logMachine) getRoot(t *rapid.T) *types.LogRootV1 {
+	resp, err := m.target.Log.GetLatestSignedLogRoot(m.ctx, &trillian.GetLatestSignedLogRootRequest{LogId: m.treeID})
+	if err != nil {
+		t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(resp.SignedLogRoot.GetLogRoot()); err != nil {
+		t.Fatalf("Failed to unmarshal log root: %v", err)
+	}
+	return &root
+}
+
+// latestRoot checks that the latest root of the log is consistent with the
+// sequenced and pending leaves.
+func (m *logMachine) latestRoot(t *rapid.T) {
+	t.Logf("GetLatestSignedLogRoot()")
+	root := m.getRoot(t)
+	if min, max := m.size(), m.size()+uint64(m.numPending); root.TreeSize < min || root.TreeSize > max {
+		t.Fatalf("Latest root has size %d, want %d to %d", root.TreeSize, min, max)
+	}
+	if root.TreeSize == m.size() && !bytes.Equal(root.RootHash, m.ref.Hash()) {
+		t.Fatalf("Root hash at size %d is %x, want %x", root.TreeSize, root.RootHash, m.ref.Hash())
+	}
+}
+
+// read reads a range of sequenced leaves.
+func (m *logMachine) read(t *rapid.T) {
+	if m.size() == 0 {
+		return
+	}
+	start := rapid.Uint64Range(0, m.size()-1).Draw(t, "start").(uint64)
+	count := rapid.Uint64Range(1, m.size()-start).Draw(t, "count").(uint64)
+	t.Logf("GetLeavesByRange(%d, %d)", start, count)
+	resp, err := m.target.Log.GetLeavesByRange(m.ctx, &trillian.GetLeavesByRangeRequest{LogId: m.treeID, StartIndex: int64(start), Count: int64(count)})
+	if err != nil {
+		t.Fatalf("GetLeavesByRange(%d, %d): %v", start, count, err)
+	}
+	if got := uint64(len(resp.Leaves)); got != count {
+		t.Fatalf("GetLeavesByRange(%d, %d) returned %d leaves", start, count, got)
+	}
+	for i, leaf := range resp.Leaves {
+		index := start + uint64(i)
+		if leaf.LeafIndex != int64(index) || !bytes.Equal(leaf.LeafValue, m.leaves[index]) || !bytes.Equal(leaf.MerkleLeafHash, m.ref.LeafHash(index)) {
+			t.Fatalf("GetLeavesByRange(%d, %d) returned leaf %q at %d, want %q at %d", start, count, leaf.LeafValue, leaf.LeafIndex, m.leaves[index], index)
+		}
+	}
+}
+
+// drawProofSize draws a size of at least min of the sequenced tree, at which to
+// get a proof.
+func (m *logMachine) drawProofSize(t *rapid.T, min uint64, label string) uint64 {
+	return rapid.Uint64Range(min, m.size()).Draw(t, label).(uint64)
+}
+
+// inclusion gets the inclusion proof of a sequenced leaf by its index.
+func (m *logMachine) inclusion(t *rapid.T) {
+	if m.size() == 0 {
+		return
+	}
+	size := m.drawProofSize(t, 1, "size")
+	index := rapid.Uint64Range(0, size-1).Draw(t, "index").(uint64)
+	t.Logf("GetInclusionProof(%d, %d)", index, size)
+	resp, err := m.target.Log.GetInclusionProof(m.ctx, &trillian.GetInclusionProofRequest{LogId: m.treeID, LeafIndex: int64(index), TreeSize: int64(size)})
+	if err != nil {
+		t.Fatalf("GetInclusionProof(%d, %d): %v", index, size, err)
+	}
+	m.checkInclusion(t, resp.Proof, index, size)
+}
+
+// inclusionByHash gets the inclusion proof of a sequenced leaf by its hash.
+func (m *logMachine) inclusionByHash(t *rapid.T) {
+	if m.size() == 0 {
+		return
+	}
+	size := m.drawProofSize(t, 1, "size")
+	index := rapid.Uint64Range(0, size-1).Draw(t, "index").(uint64)
+	t.Logf("GetInclusionProofByHash(%q, %d)", m.leaves[index], size)
+	resp, err := m.target.Log.GetInclusionProofByHash(m.ctx, &trillian.GetInclusionProofByHashRequest{LogId: m.treeID, LeafHash: m.ref.LeafHash(index), TreeSize: int64(size)})
+	if err != nil {
+		t.Fatalf("GetInclusionProofByHash(%d, %d): %v", index, size, err)
+	}
+	// There is a proof for each sequenced copy of the leaf, which includes
+	// the one at index.
+	var found bool
+	for _, proof := range resp.Proof {
+		i := uint64(proof.GetLeafIndex())
+		if i >= size || !bytes.Equal(m.leaves[i], m.leaves[index]) {
+			t.Fatalf("GetInclusionProofByHash(%d, %d) returned a proof of leaf %d", index, size, i)
+		}
+		m.checkInclusion(t, proof, i, size)
+		found = found || i == index
+	}
+	if !found {
+		t.Fatalf("GetInclusionProofByHash(%d, %d) returned no proof of leaf %d", index, size, index)
+	}
+}
+
+func (m *logMachine) checkInclusion(t *rapid.T, proof *trillian.Proof, index, size uint64) {
+	want, err := m.ref.InclusionProof(index, size)
+	if err != nil {
+		t.Fatalf("Reference InclusionProof(%d, %d): %v", index, size, err)
+	}
+	if proof.GetLeafIndex() != int64(index) || !equalHashes(proof.GetHashes(), want) {
+		t.Fatalf("Inclusion proof of %d at %d is %x at %d, want %x", index, size, proof.GetHashes(), proof.GetLeafIndex(), want)
+	}
+}
+
+// consistency gets the consistency proof between two sizes of the log.
+func (m *logMachine) consistency(t *rapid.T) {
+	if m.size() == 0 {
+		return
+	}
+	size2 := m.drawProofSize(t, 1, "size2")
+	size1 := rapid.Uint64Range(1, size2).Draw(t, "size1").(uint64)
+	t.Logf("GetConsistencyProof(%d, %d)", size1, size2)
+	resp, err := m.target.Log.GetConsistencyProof(m.ctx, &trillian.GetConsistencyProofRequest{LogId: m.treeID, FirstTreeSize: int64(size1), SecondTreeSize: int64(size2)})
+	if err != nil {
+		t.Fatalf("GetConsistencyProof(%d, %d): %v", size1, size2, err)
+	}
+	want, err := m.ref.ConsistencyProof(size1, size2)
+	if err != nil {
+		t.Fatalf("Reference ConsistencyProof(%d, %d): %v", size1, size2, err)
+	}
+	if got := resp.Proof.GetHashes(); !equalHashes(got, want) {
+		t.Fatalf("Consistency proof from %d to %d is %x, want %x", size1, size2, got, want)
+	}
+}
+
+func equalHashes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
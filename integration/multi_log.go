@@ -0,0 +1,89 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// RunMultiLogIntegration runs the log integration test against each of the
+// given trees concurrently, with the same client and test parameters other
+// than the tree ID. The leaves of each tree are given a distinct prefix, so
+// that once all the runs have finished each tree can be checked to hold only
+// its own leaves, and to have a root which matches them and differs from the
+// roots of the other trees.
+func RunMultiLogIntegration(client trillian.TrillianLogClient, treeIDs []int64, params TestParameters) error {
+	treeParams := make([]TestParameters, len(treeIDs))
+	for i, treeID := range treeIDs {
+		p := params
+		p.TreeID = treeID
+		p.CustomLeafPrefix = fmt.Sprintf("%sTree %d ", params.CustomLeafPrefix, treeID)
+		treeParams[i] = p
+	}
+
+	glog.Infof("Running log integration against %d trees concurrently ...", len(treeIDs))
+	var g errgroup.Group
+	for _, p := range treeParams {
+		p := p
+		g.Go(func() error {
+			if err := RunLogIntegration(client, p); err != nil {
+				return fmt.Errorf("tree %d: %v", p.TreeID, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// The trees are checked again once all of them have been written to, so
+	// that leaves or roots written to the wrong tree late are caught too.
+	glog.Infof("Checking isolation of %d trees ...", len(treeIDs))
+	roots := make(map[string]int64)
+	for _, p := range treeParams {
+		entries, err := readEntries(p.TreeID, client, p)
+		if err != nil {
+			return fmt.Errorf("tree %d: could not read back log entries: %v", p.TreeID, err)
+		}
+		for _, e := range entries {
+			if !bytes.HasPrefix(e.LeafValue, []byte(p.CustomLeafPrefix)) {
+				return fmt.Errorf("tree %d: leaf %d has value %q of another tree", p.TreeID, e.LeafIndex, e.LeafValue)
+			}
+		}
+		if err := checkLogRootHashMatches(entries, client, p); err != nil {
+			return fmt.Errorf("tree %d: log consistency check failed: %v", p.TreeID, err)
+		}
+
+		resp, err := getLatestSignedLogRoot(client, p)
+		if err != nil {
+			return fmt.Errorf("tree %d: failed to get latest log root: %v", p.TreeID, err)
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(resp.SignedLogRoot.GetLogRoot()); err != nil {
+			return fmt.Errorf("tree %d: could not read log root: %v", p.TreeID, err)
+		}
+		if other, ok := roots[string(root.RootHash)]; ok {
+			return fmt.Errorf("trees %d and %d have the same root hash %x", other, p.TreeID, root.RootHash)
+		}
+		roots[string(root.RootHash)] = p.TreeID
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// RemoteOptions describes how to connect to an existing Trillian deployment,
+// e.g. a staging or production one.
+type RemoteOptions struct {
+	// LogAddress is the host:port of the log server.
+	LogAddress string
+	// AdminAddress is the host:port of the admin server. It defaults to
+	// LogAddress, as the log server also serves the admin API.
+	AdminAddress string
+	// TLS selects whether connections use TLS. It is implied by TLSCAFile and
+	// TLSServerName.
+	TLS bool
+	// TLSCAFile is the path to the PEM certificates of the authorities trusted
+	// to sign the certificates of the servers. The system roots are used if
+	// it's unset.
+	TLSCAFile string
+	// TLSServerName overrides the name checked against the certificates of
+	// the servers, which defaults to the host of their addresses.
+	TLSServerName string
+	// AuthToken, if set, is sent as a bearer token with every RPC. It requires
+	// TLS.
+	AuthToken string
+}
+
+// Remote holds the clients of an existing Trillian deployment.
+type Remote struct {
+	Log   trillian.TrillianLogClient
+	Admin trillian.TrillianAdminClient
+	conns []*grpc.ClientConn
+}
+
+// DialRemote connects to the Trillian deployment described by opts.
+func DialRemote(ctx context.Context, opts RemoteOptions) (*Remote, error) {
+	if opts.LogAddress == "" {
+		return nil, errors.New("no log server address")
+	}
+	dialOpts, err := opts.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Remote{}
+	logConn, err := grpc.DialContext(ctx, opts.LogAddress, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to log server %q: %v", opts.LogAddress, err)
+	}
+	r.conns = append(r.conns, logConn)
+	r.Log = trillian.NewTrillianLogClient(logConn)
+
+	adminConn := logConn
+	if opts.AdminAddress != "" && opts.AdminAddress != opts.LogAddress {
+		if adminConn, err = grpc.DialContext(ctx, opts.AdminAddress, dialOpts...); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to connect to admin server %q: %v", opts.AdminAddress, err)
+		}
+		r.conns = append(r.conns, adminConn)
+	}
+	r.Admin = trillian.NewTrillianAdminClient(adminConn)
+	return r, nil
+}
+
+// Close closes the connections of the clients.
+func (r *Remote) Close() error {
+	var firstErr error
+	for _, conn := range r.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// CheckTree returns the tree with the given ID, or an error if it isn't an
+// active log which the integration tests can use.
+func (r *Remote) CheckTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	tree, err := r.Admin.GetTree(ctx, &trillian.GetTreeRequest{TreeId: treeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree %d: %v", treeID, err)
+	}
+	if tree.TreeType != trillian.TreeType_LOG {
+		return nil, fmt.Errorf("tree %d is a %v, want a %v", treeID, tree.TreeType, trillian.TreeType_LOG)
+	}
+	if tree.TreeState != trillian.TreeState_ACTIVE {
+		return nil, fmt.Errorf("tree %d is %v, want %v", treeID, tree.TreeState, trillian.TreeState_ACTIVE)
+	}
+	return tree, nil
+}
+
+func (opts RemoteOptions) dialOptions() ([]grpc.DialOption, error) {
+	useTLS := opts.TLS || opts.TLSCAFile != "" || opts.TLSServerName != ""
+	if opts.AuthToken != "" && !useTLS {
+		return nil, errors.New("auth tokens can only be sent over TLS")
+	}
+
+	var dialOpts []grpc.DialOption
+	switch {
+	case opts.TLSCAFile != "":
+		creds, err := credentials.NewClientTLSFromFile(opts.TLSCAFile, opts.TLSServerName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %v", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
+	case useTLS:
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{ServerName: opts.TLSServerName})))
+	default:
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if opts.AuthToken != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerToken(opts.AuthToken)))
+	}
+	return dialOpts, nil
+}
+
+// bearerToken is a grpc PerRPCCredentials sending an OAuth2 bearer token.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// selfSignedCert returns a TLS certificate for the given name, and its PEM
+// encoding.
+func selfSignedCert(t *testing.T, name string) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() = %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+type fakeAdminServer struct {
+	trillian.UnimplementedTrillianAdminServer
+	tree *trillian.Tree
+	auth chan []string
+}
+
+func (s *fakeAdminServer) GetTree(ctx context.Context, req *trillian.GetTreeRequest) (*trillian.Tree, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.auth <- md.Get("authorization")
+	return s.tree, nil
+}
+
+func TestDialRemote(t *testing.T) {
+	ctx := context.Background()
+	const serverName = "trillian.example.com"
+	cert, certPEM := selfSignedCert(t, serverName)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	admin := &fakeAdminServer{
+		tree: &trillian.Tree{TreeId: 5, TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_FROZEN},
+		auth: make(chan []string, 1),
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	trillian.RegisterTrillianAdminServer(s, admin)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	r, err := DialRemote(ctx, RemoteOptions{
+		LogAddress:    lis.Addr().String(),
+		TLSCAFile:     caFile,
+		TLSServerName: serverName,
+		AuthToken:     "secret",
+	})
+	if err != nil {
+		t.Fatalf("DialRemote() = %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.CheckTree(ctx, 5); err == nil {
+		t.Error("CheckTree(frozen tree) = nil, want error")
+	}
+	if got, want := <-admin.auth, []string{"Bearer secret"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("authorization = %v, want %v", got, want)
+	}
+	admin.tree.TreeState = trillian.TreeState_ACTIVE
+	if _, err := r.CheckTree(ctx, 5); err != nil {
+		t.Errorf("CheckTree(active log) = %v", err)
+	}
+}
+
+func TestDialRemoteErrors(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc string
+		opts RemoteOptions
+	}{
+		{desc: "no address", opts: RemoteOptions{}},
+		{desc: "token without TLS", opts: RemoteOptions{LogAddress: "localhost:1", AuthToken: "secret"}},
+		{desc: "missing CA file", opts: RemoteOptions{LogAddress: "localhost:1", TLSCAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if r, err := DialRemote(ctx, tc.opts); err == nil {
+				r.Close()
+				t.Error("DialRemote() = nil, want error")
+			}
+		})
+	}
+}
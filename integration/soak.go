@@ -0,0 +1,546 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// soakRootHistory is the number of recent roots kept to request
+	// consistency proofs from.
+	soakRootHistory = 16
+	// soakLossSamples is the number of sequenced leaves read back by index at
+	// each check of the invariants.
+	soakLossSamples = 10
+)
+
+// SoakParameters are the settings of RunLogSoak.
+type SoakParameters struct {
+	TreeID int64
+	// Duration is how long the soak test runs for in total, including the
+	// time spent by the runs it resumes.
+	Duration time.Duration
+	// CheckEvery is the time between checks of the invariants of the log.
+	CheckEvery time.Duration
+	// MaxMergeDelay is the time within which queued leaves must be sequenced.
+	MaxMergeDelay time.Duration
+	// WriteBatchSize is the maximum number of leaves queued per write, and
+	// ReadBatchSize the maximum number of leaves read per range read.
+	WriteBatchSize     int
+	ReadBatchSize      int64
+	RPCRequestDeadline time.Duration
+	// MaxPending is the number of leaves pending beyond which no leaves are
+	// queued, so that the test doesn't queue leaves faster than the log
+	// sequences them.
+	MaxPending int
+	// Seed is the seed of the operations and of the leaf values. A seed based
+	// on the current time is used, and logged, if it's 0. It's ignored when
+	// resuming from a checkpoint, which keeps the seed of the run.
+	Seed int64
+	// CheckpointFile, if set, is the file the state of the run is saved to
+	// every CheckpointEvery, and resumed from if it exists, so that a crash of
+	// the test doesn't lose the leaves queued and the roots checked.
+	CheckpointFile  string
+	CheckpointEvery time.Duration
+}
+
+// DefaultSoakParameters builds a SoakParameters object for a soak test of the
+// given log for the given duration.
+func DefaultSoakParameters(treeID int64, duration time.Duration) SoakParameters {
+	return SoakParameters{
+		TreeID:             treeID,
+		Duration:           duration,
+		CheckEvery:         time.Minute,
+		MaxMergeDelay:      5 * time.Minute,
+		WriteBatchSize:     20,
+		ReadBatchSize:      50,
+		RPCRequestDeadline: time.Second * 30,
+		MaxPending:         1000,
+		CheckpointEvery:    5 * time.Minute,
+	}
+}
+
+// soakState is the state of a soak test, which is saved to its checkpoint.
+// The leaves queued by the test are numbered, and their values are given by
+// soakLeafValue of the seed and their number.
+type soakState struct {
+	TreeID int64 `json:"tree_id"`
+	Seed   int64 `json:"seed"`
+	// Queued is the number of leaves queued.
+	Queued uint64 `json:"queued"`
+	// Pending holds the UNIX time in nanoseconds at which each leaf not seen
+	// sequenced yet was queued, by leaf number.
+	Pending map[uint64]int64 `json:"pending"`
+	// Sequenced holds the index of each leaf seen sequenced, by leaf number.
+	Sequenced map[uint64]int64 `json:"sequenced"`
+	// Root is the TLS encoded types.LogRootV1 last checked.
+	Root []byte `json:"root"`
+	// Elapsed is the time spent by the test so far.
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+func soakLeafValue(seed int64, n uint64) []byte {
+	return []byte(fmt.Sprintf("soak %d %d", seed, n))
+}
+
+// soaker runs a soak test.
+type soaker struct {
+	client trillian.TrillianLogClient
+	params SoakParameters
+	rand   *rand.Rand
+	state  *soakState
+	// root is the latest root checked, and roots the recent ones.
+	root  types.LogRootV1
+	roots []types.LogRootV1
+	// numbers are the numbers of the leaves in state.Sequenced, and byIndex
+	// the numbers of the leaves by index.
+	numbers []uint64
+	byIndex map[int64]uint64
+}
+
+// RunLogSoak runs a soak test of a log for params.Duration, which continuously
+// mixes queueing leaves, reading ranges of leaves, and checking inclusion and
+// consistency proofs. Every params.CheckEvery, it checks the invariants of the
+// log: its root only advances, successive roots are consistent, the leaves
+// queued are sequenced within params.MaxMergeDelay, and sequenced leaves keep
+// their index and value. Finally, it waits for all queued leaves to be
+// sequenced. The log may be written to by others during the test.
+func RunLogSoak(ctx context.Context, client trillian.TrillianLogClient, params SoakParameters) error {
+	state, err := loadSoakState(params)
+	if err != nil {
+		return err
+	}
+	s := &soaker{
+		client:  client,
+		params:  params,
+		rand:    rand.New(rand.NewSource(state.Seed + int64(state.Queued))),
+		state:   state,
+		byIndex: make(map[int64]uint64),
+	}
+	if len(state.Root) > 0 {
+		if err := s.root.UnmarshalBinary(state.Root); err != nil {
+			return fmt.Errorf("invalid root in checkpoint: %v", err)
+		}
+		s.roots = append(s.roots, s.root)
+	}
+	for n, index := range state.Sequenced {
+		s.numbers = append(s.numbers, n)
+		s.byIndex[index] = n
+	}
+	sort.Slice(s.numbers, func(i, j int) bool { return s.numbers[i] < s.numbers[j] })
+	glog.Infof("Soak testing log %d for %v with seed %d, from %v with %d leaves queued", params.TreeID, params.Duration, state.Seed, state.Elapsed, state.Queued)
+
+	ops := []func(context.Context) error{s.write, s.write, s.readRange, s.readRange, s.checkInclusion, s.checkConsistency, s.updateRoot}
+	last := time.Now()
+	lastCheck, lastCheckpoint := last, last
+	for state.Elapsed < params.Duration {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := ops[s.rand.Intn(len(ops))](ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		now := time.Now()
+		state.Elapsed += now.Sub(last)
+		last = now
+		if now.Sub(lastCheck) >= params.CheckEvery {
+			if err := s.checkInvariants(ctx, false); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+			lastCheck = now
+		}
+		if params.CheckpointFile != "" && now.Sub(lastCheckpoint) >= params.CheckpointEvery {
+			if err := s.saveCheckpoint(); err != nil {
+				return err
+			}
+			lastCheckpoint = now
+		}
+	}
+
+	glog.Infof("Soak test done, waiting for %d pending leaves to be sequenced", len(state.Pending))
+	deadline := time.Now().Add(params.MaxMergeDelay)
+	for {
+		if err := s.checkInvariants(ctx, true); err != nil {
+			return err
+		}
+		if len(state.Pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d leaves not sequenced within %v", len(state.Pending), params.MaxMergeDelay)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(params.CheckEvery):
+		}
+	}
+	if params.CheckpointFile != "" {
+		if err := s.saveCheckpoint(); err != nil {
+			return err
+		}
+	}
+	glog.Infof("Soak test passed with %d leaves sequenced, at tree size %d", len(state.Sequenced), s.root.TreeSize)
+	return nil
+}
+
+// loadSoakState returns the state saved in the checkpoint file of params, or
+// the state of a new run if there is no checkpoint yet.
+func loadSoakState(params SoakParameters) (*soakState, error) {
+	state := &soakState{
+		TreeID:    params.TreeID,
+		Seed:      params.Seed,
+		Pending:   make(map[uint64]int64),
+		Sequenced: make(map[uint64]int64),
+	}
+	if state.Seed == 0 {
+		state.Seed = time.Now().UnixNano()
+	}
+	if params.CheckpointFile == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(params.CheckpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %q: %v", params.CheckpointFile, err)
+	}
+	if state.TreeID != params.TreeID {
+		return nil, fmt.Errorf("checkpoint %q is for tree %d, not %d", params.CheckpointFile, state.TreeID, params.TreeID)
+	}
+	return state, nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint file with the state.
+func (s *soaker) saveCheckpoint() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	file := s.params.CheckpointFile
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	glog.Infof("Saved soak test checkpoint after %v with %d leaves queued", s.state.Elapsed, s.state.Queued)
+	return nil
+}
+
+func (s *soaker) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.params.RPCRequestDeadline)
+}
+
+// write queues a few new leaves, unless MaxPending leaves are pending.
+func (s *soaker) write(ctx context.Context) error {
+	count := 1 + s.rand.Intn(s.params.WriteBatchSize)
+	if room := s.params.MaxPending - len(s.state.Pending); count > room {
+		count = room
+	}
+	for i := 0; i < count; i++ {
+		n := s.state.Queued
+		value := soakLeafValue(s.state.Seed, n)
+		cctx, cancel := s.rpcContext(ctx)
+		resp, err := s.client.QueueLeaf(cctx, &trillian.QueueLeafRequest{
+			LogId: s.params.TreeID,
+			Leaf:  &trillian.LogLeaf{LeafValue: value},
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("QueueLeaf(%q): %v", value, err)
+		}
+		// Leaves queued after the last checkpoint of a crashed run are queued
+		// again when it resumes.
+		if code := codes.Code(resp.QueuedLeaf.GetStatus().GetCode()); code != codes.OK && code != codes.AlreadyExists {
+			return fmt.Errorf("QueueLeaf(%q) returned status %v", value, code)
+		}
+		s.state.Pending[n] = time.Now().UnixNano()
+		s.state.Queued++
+	}
+	return nil
+}
+
+// readRange reads a random range of leaves of the latest root checked, and
+// checks the leaves queued by the test against their known indices.
+func (s *soaker) readRange(ctx context.Context) error {
+	size := int64(s.root.TreeSize)
+	if size == 0 {
+		return nil
+	}
+	start := s.rand.Int63n(size)
+	count := size - start
+	if count > s.params.ReadBatchSize {
+		count = s.params.ReadBatchSize
+	}
+	cctx, cancel := s.rpcContext(ctx)
+	defer cancel()
+	resp, err := s.client.GetLeavesByRange(cctx, &trillian.GetLeavesByRangeRequest{
+		LogId:      s.params.TreeID,
+		StartIndex: start,
+		Count:      count,
+	})
+	if err != nil {
+		return fmt.Errorf("GetLeavesByRange(%d, %d): %v", start, count, err)
+	}
+	if got := int64(len(resp.Leaves)); got == 0 || got > count {
+		return fmt.Errorf("GetLeavesByRange(%d, %d): got %d leaves", start, count, got)
+	}
+	for i, leaf := range resp.Leaves {
+		if want := start + int64(i); leaf.LeafIndex != want {
+			return fmt.Errorf("GetLeavesByRange(%d, %d): Leaves[%d].LeafIndex=%d, want %d", start, count, i, leaf.LeafIndex, want)
+		}
+		if err := s.checkLeaf(leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLeaf checks a leaf read from the log, and records the index of the
+// leaf queued by the test which it holds, if any.
+func (s *soaker) checkLeaf(leaf *trillian.LogLeaf) error {
+	if hash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue); !bytes.Equal(hash, leaf.MerkleLeafHash) {
+		return fmt.Errorf("leaf %d: value hashes to %x, served Merkle leaf hash is %x", leaf.LeafIndex, hash, leaf.MerkleLeafHash)
+	}
+	if n, ok := s.byIndex[leaf.LeafIndex]; ok {
+		if want := soakLeafValue(s.state.Seed, n); !bytes.Equal(leaf.LeafValue, want) {
+			return fmt.Errorf("leaf %d has value %q, previously %q", leaf.LeafIndex, leaf.LeafValue, want)
+		}
+		return nil
+	}
+	var seed int64
+	var n uint64
+	if _, err := fmt.Sscanf(string(leaf.LeafValue), "soak %d %d", &seed, &n); err != nil || seed != s.state.Seed {
+		return nil
+	}
+	// Leaves may be duplicated if the storage doesn't deduplicate them, so
+	// only the first index seen is recorded.
+	if _, ok := s.state.Sequenced[n]; !ok && n < s.state.Queued {
+		s.sequenced(n, leaf.LeafIndex)
+	}
+	return nil
+}
+
+// sequenced records that leaf number n is sequenced at index.
+func (s *soaker) sequenced(n uint64, index int64) {
+	delete(s.state.Pending, n)
+	s.state.Sequenced[n] = index
+	s.numbers = append(s.numbers, n)
+	s.byIndex[index] = n
+}
+
+// checkInclusion checks the inclusion proof of a random sequenced leaf in the
+// latest root checked.
+func (s *soaker) checkInclusion(ctx context.Context) error {
+	if len(s.numbers) == 0 {
+		return nil
+	}
+	n := s.numbers[s.rand.Intn(len(s.numbers))]
+	index := s.state.Sequenced[n]
+	if uint64(index) >= s.root.TreeSize {
+		return nil
+	}
+	cctx, cancel := s.rpcContext(ctx)
+	defer cancel()
+	resp, err := s.client.GetInclusionProof(cctx, &trillian.GetInclusionProofRequest{
+		LogId:     s.params.TreeID,
+		LeafIndex: index,
+		TreeSize:  int64(s.root.TreeSize),
+	})
+	if err != nil {
+		return fmt.Errorf("GetInclusionProof(%d, %d): %v", index, s.root.TreeSize, err)
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(soakLeafValue(s.state.Seed, n))
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, uint64(index), s.root.TreeSize, leafHash, resp.GetProof().GetHashes(), s.root.RootHash); err != nil {
+		return fmt.Errorf("inclusion proof of leaf %d in tree of size %d: %v", index, s.root.TreeSize, err)
+	}
+	return nil
+}
+
+// checkConsistency checks the consistency proof between a random recent root
+// and the latest root checked.
+func (s *soaker) checkConsistency(ctx context.Context) error {
+	if len(s.roots) == 0 {
+		return nil
+	}
+	old := s.roots[s.rand.Intn(len(s.roots))]
+	if old.TreeSize == 0 || old.TreeSize == s.root.TreeSize {
+		return nil
+	}
+	cctx, cancel := s.rpcContext(ctx)
+	defer cancel()
+	resp, err := s.client.GetConsistencyProof(cctx, &trillian.GetConsistencyProofRequest{
+		LogId:          s.params.TreeID,
+		FirstTreeSize:  int64(old.TreeSize),
+		SecondTreeSize: int64(s.root.TreeSize),
+	})
+	if err != nil {
+		return fmt.Errorf("GetConsistencyProof(%d, %d): %v", old.TreeSize, s.root.TreeSize, err)
+	}
+	if err := proof.VerifyConsistency(rfc6962.DefaultHasher, old.TreeSize, s.root.TreeSize, resp.GetProof().GetHashes(), old.RootHash, s.root.RootHash); err != nil {
+		return fmt.Errorf("consistency proof from %d to %d: %v", old.TreeSize, s.root.TreeSize, err)
+	}
+	return nil
+}
+
+// checkInvariants checks that the latest root of the log advances from the
+// last one checked, and is consistent with it, that the leaves pending for
+// more than MaxMergeDelay are sequenced, and that a sample of the sequenced
+// leaves keep their values. If all is set, all pending leaves are looked up,
+// but don't need to be sequenced yet.
+func (s *soaker) checkInvariants(ctx context.Context, all bool) error {
+	if err := s.updateRoot(ctx); err != nil {
+		return err
+	}
+
+	pending := make([]uint64, 0, len(s.state.Pending))
+	for n := range s.state.Pending {
+		pending = append(pending, n)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+	now := time.Now()
+	for _, n := range pending {
+		queued := time.Unix(0, s.state.Pending[n])
+		late := now.Sub(queued) > s.params.MaxMergeDelay
+		if !all && !late {
+			continue
+		}
+		index, err := s.findLeaf(ctx, n)
+		if err != nil {
+			return err
+		}
+		if index >= 0 {
+			s.sequenced(n, index)
+		} else if late {
+			return fmt.Errorf("leaf %d queued at %v is not sequenced in tree of size %d after %v", n, queued, s.root.TreeSize, s.params.MaxMergeDelay)
+		}
+	}
+
+	for i := 0; i < soakLossSamples && len(s.numbers) > 0; i++ {
+		index := s.state.Sequenced[s.numbers[s.rand.Intn(len(s.numbers))]]
+		cctx, cancel := s.rpcContext(ctx)
+		resp, err := s.client.GetLeavesByRange(cctx, &trillian.GetLeavesByRangeRequest{
+			LogId:      s.params.TreeID,
+			StartIndex: index,
+			Count:      1,
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("GetLeavesByRange(%d, 1): %v", index, err)
+		}
+		if len(resp.Leaves) != 1 || resp.Leaves[0].LeafIndex != index {
+			return fmt.Errorf("leaf %d is lost: GetLeavesByRange(%d, 1) returned %d leaves", index, index, len(resp.Leaves))
+		}
+		if err := s.checkLeaf(resp.Leaves[0]); err != nil {
+			return err
+		}
+	}
+	glog.Infof("Soak test invariants hold at tree size %d, after %v with %d leaves queued and %d pending", s.root.TreeSize, s.state.Elapsed, s.state.Queued, len(s.state.Pending))
+	return nil
+}
+
+// updateRoot fetches the latest root, and checks that it advances from the
+// last one checked, and is consistent with it.
+func (s *soaker) updateRoot(ctx context.Context) error {
+	cctx, cancel := s.rpcContext(ctx)
+	defer cancel()
+	resp, err := s.client.GetLatestSignedLogRoot(cctx, &trillian.GetLatestSignedLogRootRequest{
+		LogId:         s.params.TreeID,
+		FirstTreeSize: int64(s.root.TreeSize),
+	})
+	if err != nil {
+		return fmt.Errorf("GetLatestSignedLogRoot: %v", err)
+	}
+	verifier := client.NewLogVerifier(rfc6962.DefaultHasher)
+	root, err := verifier.VerifyRoot(&s.root, resp.GetSignedLogRoot(), resp.GetProof().GetHashes())
+	if err != nil {
+		return fmt.Errorf("latest root is not consistent with root at size %d: %v", s.root.TreeSize, err)
+	}
+	if root.TreeSize < s.root.TreeSize || root.TimestampNanos < s.root.TimestampNanos {
+		return fmt.Errorf("root went back from size %d at %d to size %d at %d", s.root.TreeSize, s.root.TimestampNanos, root.TreeSize, root.TimestampNanos)
+	}
+	s.root = *root
+	if s.state.Root, err = root.MarshalBinary(); err != nil {
+		return err
+	}
+	if s.roots = append(s.roots, *root); len(s.roots) > soakRootHistory {
+		s.roots = s.roots[1:]
+	}
+	return nil
+}
+
+// findLeaf returns the index of leaf number n in the latest root checked,
+// after checking its inclusion proof, or -1 if it's not sequenced yet.
+func (s *soaker) findLeaf(ctx context.Context, n uint64) (int64, error) {
+	if s.root.TreeSize == 0 {
+		return -1, nil
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(soakLeafValue(s.state.Seed, n))
+	cctx, cancel := s.rpcContext(ctx)
+	defer cancel()
+	resp, err := s.client.GetInclusionProofByHash(cctx, &trillian.GetInclusionProofByHashRequest{
+		LogId:    s.params.TreeID,
+		LeafHash: leafHash,
+		TreeSize: int64(s.root.TreeSize),
+	})
+	if status.Code(err) == codes.NotFound || (err == nil && len(resp.Proof) == 0) {
+		return -1, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("GetInclusionProofByHash(%x, %d): %v", leafHash, s.root.TreeSize, err)
+	}
+	pf := resp.Proof[0]
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, uint64(pf.LeafIndex), s.root.TreeSize, leafHash, pf.Hashes, s.root.RootHash); err != nil {
+		return 0, fmt.Errorf("inclusion proof of leaf %d in tree of size %d: %v", pf.LeafIndex, s.root.TreeSize, err)
+	}
+	return pf.LeafIndex, nil
+}
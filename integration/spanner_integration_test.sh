@@ -0,0 +1,33 @@
+#!/bin/bash
+# spanner_integration_test.sh runs the log integration test with CloudSpanner
+# storage, against the Cloud Spanner emulator. No GCP project or credentials
+# are needed.
+#
+# If SPANNER_EMULATOR_HOST is set, the emulator listening there is used.
+# Otherwise one is started with Docker, and removed at the end of the test.
+# Arguments are passed to log_integration_test.sh.
+set -e
+INTEGRATION_DIR="$( cd "$( dirname "$0" )" && pwd )"
+. "${INTEGRATION_DIR}"/functions.sh
+
+EMULATOR_IMAGE=${EMULATOR_IMAGE:-gcr.io/cloud-spanner-emulator/emulator}
+EMULATOR_CONTAINER=''
+
+if [[ -z "${SPANNER_EMULATOR_HOST}" ]]; then
+  grpc_port=$(pick_unused_port)
+  rest_port=$(pick_unused_port ${grpc_port})
+  echo "Starting Cloud Spanner emulator on localhost:${grpc_port}"
+  EMULATOR_CONTAINER=$(docker run --detach --rm \
+    --publish "${grpc_port}:9010" --publish "${rest_port}:9020" \
+    "${EMULATOR_IMAGE}")
+  trap 'docker stop "${EMULATOR_CONTAINER}" > /dev/null' EXIT
+  # The gRPC port doesn't answer HTTP requests, but the REST one does.
+  wait_for_server_startup ${rest_port}
+  export SPANNER_EMULATOR_HOST="localhost:${grpc_port}"
+fi
+
+# The database, and its instance, are created by the log server.
+export TEST_SPANNER_DATABASE=${TEST_SPANNER_DATABASE:-projects/test-project/instances/test-instance/databases/trillian-$$}
+echo "Using CloudSpanner database ${TEST_SPANNER_DATABASE} on ${SPANNER_EMULATOR_HOST}"
+
+run_test "Log integration test (CloudSpanner emulator)" "${INTEGRATION_DIR}/log_integration_test.sh" "" "$@"
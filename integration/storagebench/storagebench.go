@@ -0,0 +1,307 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storagebench runs the same log workloads against any storage
+// implementation, so that storage drivers, and changes to them, can be
+// compared. Each driver runs the suite from one of its benchmarks:
+//
+//	func BenchmarkLogStorage(b *testing.B) {
+//		storagebench.RunLogStorageBenchmarks(b, func(ctx context.Context, b *testing.B) storage.Provider {
+//			...
+//		})
+//	}
+//
+// and the results of `go test -bench LogStorage` can be compared with
+// benchstat. Besides the time per operation, the benchmarks report their
+// throughput, and the median and 99th percentile latencies of their
+// operations.
+package storagebench
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// batchSize is the number of leaves appended by each sequencing pass.
+	batchSize = 100
+	// proofTreeSize is the size of the log read by the proof benchmarks.
+	proofTreeSize = 1 << 12
+)
+
+// ProviderFactory returns the storage for a benchmark to use. The benchmark
+// closes it.
+type ProviderFactory = func(ctx context.Context, b *testing.B) storage.Provider
+
+// RunLogStorageBenchmarks runs all the log storage benchmarks against the
+// storage returned by factory.
+func RunLogStorageBenchmarks(b *testing.B, factory ProviderFactory) {
+	ctx := context.Background()
+	log.InitMetrics(nil)
+	for _, bm := range []struct {
+		name string
+		f    func(ctx context.Context, b *testing.B, env *env)
+	}{
+		{name: "SequentialAppend", f: benchmarkSequentialAppend},
+		{name: "RandomProofReads", f: benchmarkRandomProofReads},
+		{name: "Mixed", f: benchmarkMixed},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			env := newEnv(ctx, b, factory(ctx, b))
+			defer env.close(b)
+			bm.f(ctx, b, env)
+		})
+	}
+}
+
+// env holds the storage and the log of a benchmark.
+type env struct {
+	sp     storage.Provider
+	ls     storage.LogStorage
+	server *server.TrillianLogRPCServer
+	tree   *trillian.Tree
+	// next is the index of the next leaf to append.
+	next int64
+}
+
+func newEnv(ctx context.Context, b *testing.B, sp storage.Provider) *env {
+	b.Helper()
+	registry := extension.Registry{
+		AdminStorage: sp.AdminStorage(),
+		LogStorage:   sp.LogStorage(),
+		QuotaManager: quota.Noop(),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, &trillian.Tree{
+		TreeState:       trillian.TreeState_ACTIVE,
+		TreeType:        trillian.TreeType_LOG,
+		MaxRootDuration: durationpb.New(0),
+	})
+	if err != nil {
+		b.Fatalf("CreateTree() = %v", err)
+	}
+	root, err := (&types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot(), TimestampNanos: uint64(time.Now().UnixNano())}).MarshalBinary()
+	if err != nil {
+		b.Fatalf("MarshalBinary() = %v", err)
+	}
+	if err := registry.LogStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		b.Fatalf("StoreSignedLogRoot() = %v", err)
+	}
+	return &env{
+		sp:     sp,
+		ls:     registry.LogStorage,
+		server: server.NewTrillianLogRPCServer(registry, clock.System),
+		tree:   tree,
+	}
+}
+
+func (e *env) close(b *testing.B) {
+	if err := e.sp.Close(); err != nil {
+		b.Errorf("Close() = %v", err)
+	}
+}
+
+// appendBatch queues and sequences up to batchSize new leaves.
+func (e *env) appendBatch(ctx context.Context, n int) error {
+	leaves := make([]*trillian.LogLeaf, n)
+	for i := range leaves {
+		leaves[i] = newLeaf(e.tree.TreeId, e.next+int64(i))
+	}
+	e.next += int64(n)
+	if _, err := e.ls.QueueLeaves(ctx, e.tree, leaves, time.Now()); err != nil {
+		return fmt.Errorf("QueueLeaves() = %v", err)
+	}
+	for sequenced := 0; sequenced < n; {
+		count, err := log.IntegrateBatch(ctx, e.tree, n-sequenced, 0, 0, clock.System, e.ls, quota.Noop())
+		if err != nil {
+			return fmt.Errorf("IntegrateBatch() = %v", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("IntegrateBatch() sequenced no leaves, %d left", n-sequenced)
+		}
+		sequenced += count
+	}
+	return nil
+}
+
+// populate appends leaves until the log has the given size.
+func (e *env) populate(ctx context.Context, b *testing.B, size int64) {
+	b.Helper()
+	for e.next < size {
+		n := batchSize
+		if left := size - e.next; left < int64(n) {
+			n = int(left)
+		}
+		if err := e.appendBatch(ctx, n); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// getProof fetches the inclusion proof of a random leaf within the first
+// size leaves.
+func (e *env) getProof(ctx context.Context, rng *rand.Rand, size int64) error {
+	_, err := e.server.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+		LogId:     e.tree.TreeId,
+		LeafIndex: rng.Int63n(size),
+		TreeSize:  size,
+	})
+	return err
+}
+
+func newLeaf(treeID, index int64) *trillian.LogLeaf {
+	value := []byte(fmt.Sprintf("storagebench leaf %d of tree %d", index, treeID))
+	identityHash := sha256.Sum256(value)
+	return &trillian.LogLeaf{
+		LeafValue:        value,
+		LeafIdentityHash: identityHash[:],
+		MerkleLeafHash:   rfc6962.DefaultHasher.HashLeaf(value),
+	}
+}
+
+// benchmarkSequentialAppend measures appending leaves in batches, as the
+// signer does. An operation is a leaf.
+func benchmarkSequentialAppend(ctx context.Context, b *testing.B, e *env) {
+	var lats latencies
+	b.ResetTimer()
+	start := time.Now()
+	for done := 0; done < b.N; done += batchSize {
+		n := batchSize
+		if left := b.N - done; left < n {
+			n = left
+		}
+		opStart := time.Now()
+		if err := e.appendBatch(ctx, n); err != nil {
+			b.Fatal(err)
+		}
+		lats.add(time.Since(opStart))
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "leaves/s")
+	lats.report(b, "batch")
+}
+
+// benchmarkRandomProofReads measures fetching the inclusion proofs of random
+// leaves of a log. An operation is a proof.
+func benchmarkRandomProofReads(ctx context.Context, b *testing.B, e *env) {
+	e.populate(ctx, b, proofTreeSize)
+	rng := rand.New(rand.NewSource(1))
+	var lats latencies
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		opStart := time.Now()
+		if err := e.getProof(ctx, rng, proofTreeSize); err != nil {
+			b.Fatalf("GetInclusionProof() = %v", err)
+		}
+		lats.add(time.Since(opStart))
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "proofs/s")
+	lats.report(b, "proof")
+}
+
+// benchmarkMixed measures fetching proofs from parallel readers while leaves
+// are appended to the log. An operation is a proof.
+func benchmarkMixed(ctx context.Context, b *testing.B, e *env) {
+	e.populate(ctx, b, proofTreeSize)
+	ctx, cancel := context.WithCancel(ctx)
+	appended := make(chan int64, 1)
+	var lats latencies
+	b.ResetTimer()
+	start := time.Now()
+	go func() {
+		var n int64
+		defer func() { appended <- n }()
+		for ctx.Err() == nil {
+			if err := e.appendBatch(ctx, batchSize); err != nil {
+				if ctx.Err() == nil {
+					b.Error(err)
+				}
+				return
+			}
+			n += batchSize
+		}
+	}()
+	var seed int64
+	var mu sync.Mutex
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		seed++
+		rng := rand.New(rand.NewSource(seed))
+		mu.Unlock()
+		for pb.Next() {
+			opStart := time.Now()
+			// Proofs are read within the populated part of the log, whose size
+			// is known without reading the root.
+			if err := e.getProof(ctx, rng, proofTreeSize); err != nil {
+				b.Errorf("GetInclusionProof() = %v", err)
+				return
+			}
+			lats.add(time.Since(opStart))
+		}
+	})
+	b.StopTimer()
+	elapsed := time.Since(start).Seconds()
+	cancel()
+	b.ReportMetric(float64(b.N)/elapsed, "proofs/s")
+	b.ReportMetric(float64(<-appended)/elapsed, "leaves/s")
+	lats.report(b, "proof")
+}
+
+// latencies collects the latencies of the operations of a benchmark. It is
+// safe for concurrent use.
+type latencies struct {
+	mu  sync.Mutex
+	lat []time.Duration
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lat = append(l.lat, d)
+}
+
+// report reports the median and 99th percentile latencies of the operations,
+// in milliseconds.
+func (l *latencies) report(b *testing.B, op string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lat) == 0 {
+		return
+	}
+	sort.Slice(l.lat, func(i, j int) bool { return l.lat[i] < l.lat[j] })
+	for _, p := range []int{50, 99} {
+		d := l.lat[(len(l.lat)-1)*p/100]
+		b.ReportMetric(float64(d)/float64(time.Millisecond), fmt.Sprintf("p%d-%s-ms", p, op))
+	}
+}
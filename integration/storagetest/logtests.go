@@ -456,6 +456,85 @@ func (*logTests) TestDequeueLeaves(ctx context.Context, t *testing.T, s storage.
 	}
 }
 
+func (*logTests) TestCountUnsequenced(ctx context.Context, t *testing.T, s storage.LogStorage, as storage.AdminStorage) {
+	counter, ok := s.(storage.UnsequencedCounter)
+	if !ok {
+		t.Skipf("%T does not implement storage.UnsequencedCounter", s)
+	}
+	const leavesToInsert = 5
+	tree := mustCreateTree(ctx, t, as, storageto.LogTree)
+	mustSignAndStoreLogRoot(ctx, t, s, tree, &types.LogRootV1{})
+
+	checkCount := func(want int64) {
+		t.Helper()
+		got, err := counter.CountUnsequenced(ctx, tree)
+		if err != nil {
+			t.Fatalf("CountUnsequenced(): %v", err)
+		}
+		if got != want {
+			t.Errorf("CountUnsequenced()=%d, want %d", got, want)
+		}
+	}
+
+	checkCount(0)
+	leaves := createTestLeaves(leavesToInsert, 20)
+	if _, err := s.QueueLeaves(ctx, tree, leaves, fakeDequeueCutoffTime); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	checkCount(leavesToInsert)
+
+	cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	dequeueAndSequence(cctx, t, s, tree, fakeDequeueCutoffTime, leavesToInsert, 0)
+	checkCount(0)
+}
+
+func (*logTests) TestOldestUnsequenced(ctx context.Context, t *testing.T, s storage.LogStorage, as storage.AdminStorage) {
+	ager, ok := s.(storage.UnsequencedAger)
+	if !ok {
+		t.Skipf("%T does not implement storage.UnsequencedAger", s)
+	}
+
+	checkOldest := func(t *testing.T, tree *trillian.Tree, want time.Time) {
+		t.Helper()
+		got, err := ager.OldestUnsequenced(ctx, tree)
+		if err != nil {
+			t.Fatalf("OldestUnsequenced(): %v", err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("OldestUnsequenced()=%v, want %v", got, want)
+		}
+	}
+
+	t.Run("mixed", func(t *testing.T) {
+		tree := mustCreateTree(ctx, t, as, storageto.LogTree)
+		mustSignAndStoreLogRoot(ctx, t, s, tree, &types.LogRootV1{})
+		checkOldest(t, tree, time.Time{})
+		oldTime := fakeDequeueCutoffTime.Add(-time.Minute)
+		if _, err := s.QueueLeaves(ctx, tree, createTestLeaves(3, 30), fakeDequeueCutoffTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		if _, err := s.QueueLeaves(ctx, tree, createTestLeaves(2, 20), oldTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		checkOldest(t, tree, oldTime)
+	})
+
+	t.Run("sequenced", func(t *testing.T) {
+		tree := mustCreateTree(ctx, t, as, storageto.LogTree)
+		mustSignAndStoreLogRoot(ctx, t, s, tree, &types.LogRootV1{})
+		if _, err := s.QueueLeaves(ctx, tree, createTestLeaves(5, 20), fakeDequeueCutoffTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		checkOldest(t, tree, fakeDequeueCutoffTime)
+
+		cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		dequeueAndSequence(cctx, t, s, tree, fakeDequeueCutoffTime, 5, 0)
+		checkOldest(t, tree, time.Time{})
+	})
+}
+
 // dequeueAndSequence repeatedly dequeues in a single transaction until limit is reached or a timeout occurs.
 // Then, it sequences the leaves with UpdateSequencedLeaves.
 func dequeueAndSequence(ctx context.Context, t *testing.T, ls storage.LogStorage, tree *trillian.Tree, ts time.Time, limit int, startIndex int64) []*trillian.LogLeaf {
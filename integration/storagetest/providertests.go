@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian/storage"
+
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+// ProviderFactory creates a storage.Provider backed by empty storage for a test
+// to use. The factory is responsible for closing the provider, for example with
+// t.Cleanup.
+type ProviderFactory = func(ctx context.Context, t *testing.T) storage.Provider
+
+// RunProviderTests runs all the log and admin storage conformance tests against
+// the storage implementation of the providers returned by providerFactory.
+//
+// Out-of-tree storage implementations can run it from their own tests to check
+// that they behave like the ones of this repository before being registered
+// with storage.RegisterProvider, e.g.:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.RunProviderTests(t, func(ctx context.Context, t *testing.T) storage.Provider {
+//			sp := newTestProvider(t)
+//			t.Cleanup(func() { sp.Close() })
+//			return sp
+//		})
+//	}
+func RunProviderTests(t *testing.T, providerFactory ProviderFactory) {
+	ctx := context.Background()
+	t.Run("LogStorage", func(t *testing.T) {
+		RunLogStorageTests(t, func(ctx context.Context, t *testing.T) (storage.LogStorage, storage.AdminStorage) {
+			sp := providerFactory(ctx, t)
+			return sp.LogStorage(), sp.AdminStorage()
+		})
+	})
+	t.Run("AdminStorage", func(t *testing.T) {
+		tester := &storageto.AdminStorageTester{NewAdminStorage: func() storage.AdminStorage {
+			return providerFactory(ctx, t).AdminStorage()
+		}}
+		tester.RunAllTests(t)
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// Tamperer corrupts the data of logs in their storage, behind the back of the
+// log servers. Each method flips a bit, so that calling it again restores the
+// data.
+type Tamperer interface {
+	// FlipLeafBit flips a bit of the value of the stored leaf at index.
+	FlipLeafBit(ctx context.Context, treeID, index int64) error
+	// FlipNodeBit flips a bit of the stored hash of a Merkle node.
+	FlipNodeBit(ctx context.Context, treeID int64, id compact.NodeID) error
+}
+
+// ScrubFunc scrubs the storage of a log, e.g. with log.ScrubTree.
+type ScrubFunc func(ctx context.Context, treeID int64) (*log.ScrubReport, error)
+
+// tamperedLeaf and tamperedNode are the leaf and node corrupted by
+// RunTamperingIntegration. The node is the sibling of leaf 3.
+var (
+	tamperedLeaf int64 = 1
+	tamperedNode       = compact.NewNodeID(0, 2)
+)
+
+// RunTamperingIntegration runs a log integration test using the given client
+// and test parameters, and then checks that corrupting a stored leaf, and a
+// stored Merkle node, with tamperer is detected by the verification of the
+// client library against a root trusted before the corruption, and by
+// scrubbing the log if scrub is set. The corruption is undone after each
+// check, and the log is then checked to verify again.
+func RunTamperingIntegration(logClient trillian.TrillianLogClient, params TestParameters, tamperer Tamperer, scrub ScrubFunc) error {
+	if params.LeafCount < 4 || params.ReadBatchSize < 4 {
+		return errors.New("tampering integration test requires at least 4 leaves read in a batch")
+	}
+	if err := RunLogIntegration(logClient, params); err != nil {
+		return fmt.Errorf("log integration failed: %v", err)
+	}
+	entries, err := readEntries(params.TreeID, logClient, params)
+	if err != nil {
+		return fmt.Errorf("could not read back log entries: %v", err)
+	}
+	tree := buildMerkleTree(entries, params)
+	// check verifies the log, and expects a corruption of the given kind to be
+	// detected, or none if it's UNKNOWN_CORRUPTION_KIND.
+	check := func(kind trillian.TreeCorruption_Kind) error {
+		if err := checkClientVerification(logClient, params, tree); kind == trillian.TreeCorruption_UNKNOWN_CORRUPTION_KIND && err != nil {
+			return fmt.Errorf("client verification failed: %v", err)
+		} else if kind != trillian.TreeCorruption_UNKNOWN_CORRUPTION_KIND && err == nil {
+			return errors.New("not detected by client verification")
+		} else if err != nil {
+			glog.Infof("Detected by client verification: %v", err)
+		}
+		if scrub == nil {
+			return nil
+		}
+		ctx, cancel := getRPCDeadlineContext(params)
+		defer cancel()
+		report, err := scrub(ctx, params.TreeID)
+		if err != nil {
+			return fmt.Errorf("scrub failed: %v", err)
+		}
+		if kind == trillian.TreeCorruption_UNKNOWN_CORRUPTION_KIND {
+			if report.CorruptionCount != 0 {
+				return fmt.Errorf("scrub found %d corruptions: %v", report.CorruptionCount, report.Corruptions)
+			}
+			return nil
+		}
+		for _, c := range report.Corruptions {
+			if c.Kind == kind {
+				glog.Infof("Detected by scrubbing: %v", c)
+				return nil
+			}
+		}
+		return fmt.Errorf("scrub didn't find %v, found %v", kind, report.Corruptions)
+	}
+	if err := check(trillian.TreeCorruption_UNKNOWN_CORRUPTION_KIND); err != nil {
+		return fmt.Errorf("before tampering: %v", err)
+	}
+
+	for _, t := range []struct {
+		desc string
+		kind trillian.TreeCorruption_Kind
+		flip func(ctx context.Context) error
+	}{
+		{
+			desc: fmt.Sprintf("leaf %d", tamperedLeaf),
+			kind: trillian.TreeCorruption_LEAF_HASH_MISMATCH,
+			flip: func(ctx context.Context) error { return tamperer.FlipLeafBit(ctx, params.TreeID, tamperedLeaf) },
+		},
+		{
+			desc: fmt.Sprintf("node %+v", tamperedNode),
+			kind: trillian.TreeCorruption_NODE_HASH_MISMATCH,
+			flip: func(ctx context.Context) error { return tamperer.FlipNodeBit(ctx, params.TreeID, tamperedNode) },
+		},
+	} {
+		glog.Infof("Checking that tampering with %s is detected ...", t.desc)
+		ctx, cancel := getRPCDeadlineContext(params)
+		err := t.flip(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to tamper with %s: %v", t.desc, err)
+		}
+		if err := check(t.kind); err != nil {
+			return fmt.Errorf("tampering with %s: %v", t.desc, err)
+		}
+
+		ctx, cancel = getRPCDeadlineContext(params)
+		err = t.flip(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %v", t.desc, err)
+		}
+		if err := check(trillian.TreeCorruption_UNKNOWN_CORRUPTION_KIND); err != nil {
+			return fmt.Errorf("after restoring %s: %v", t.desc, err)
+		}
+	}
+	return nil
+}
+
+// FlipStoredNodeBit flips a bit of the hash of a Merkle node of a log through
+// the storage API, for storage which doesn't expose its data otherwise. The
+// latest root of the log is stored again, one nanosecond later, so that the
+// node is read at the latest revision of the tree.
+func FlipStoredNodeBit(ctx context.Context, ls storage.LogStorage, as storage.AdminStorage, treeID int64, id compact.NodeID) error {
+	t, err := storage.GetTree(ctx, as, treeID)
+	if err != nil {
+		return err
+	}
+	return ls.ReadWriteTransaction(ctx, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		nodes, err := tx.GetMerkleNodes(ctx, []compact.NodeID{id})
+		if err != nil {
+			return err
+		}
+		if len(nodes) != 1 {
+			return fmt.Errorf("node %+v not found", id)
+		}
+		hash := append([]byte(nil), nodes[0].Hash...)
+		hash[0] ^= 1
+		if err := tx.SetMerkleNodes(ctx, []tree.Node{{ID: id, Hash: hash}}); err != nil {
+			return err
+		}
+
+		slr, err := tx.LatestSignedLogRoot(ctx)
+		if err != nil {
+			return err
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+			return err
+		}
+		root.TimestampNanos++
+		logRoot, err := root.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: logRoot})
+	})
+}
@@ -0,0 +1,280 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly/integration"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+// The version skew tests run the code in this tree against servers of another
+// release, and the integration test of another release against servers built
+// from this tree. All the servers share the storage selected with
+// --skew_server_flags, e.g. a MySQL database with the schema of the oldest
+// release.
+var (
+	skewLogServerFlag       = flag.String("skew_log_server", "", "Path to the trillian_log_server binary of another release. With --skew_log_signer, the client code in this tree is tested against it, and so is upgrading its logs")
+	skewLogSignerFlag       = flag.String("skew_log_signer", "", "Path to the trillian_log_signer binary of another release")
+	skewIntegrationTestFlag = flag.String("skew_integration_test", "", "Path to the integration test binary of another release, built with go test -c, which is run against servers built from this tree")
+	skewServerFlagsFlag     = flag.String("skew_server_flags", "", "Space separated flags passed to all the servers of the version skew tests, e.g. to select their storage")
+)
+
+// logServers is a log server and signer running in their own processes.
+type logServers struct {
+	addr  string
+	conn  *grpc.ClientConn
+	procs []*exec.Cmd
+}
+
+// startLogServers runs the given log server and signer binaries, and connects
+// to the server. They're stopped at the end of the test unless stop is called
+// before.
+func startLogServers(t *testing.T, serverBin, signerBin string) *logServers {
+	t.Helper()
+	s := &logServers{addr: fmt.Sprintf("localhost:%d", freePort(t))}
+	t.Cleanup(s.stop)
+	s.start(t, serverBin, "--rpc_endpoint="+s.addr, fmt.Sprintf("--http_endpoint=localhost:%d", freePort(t)))
+	s.start(t, signerBin, "--force_master", "--sequencer_interval=100ms", "--batch_size=500",
+		fmt.Sprintf("--rpc_endpoint=localhost:%d", freePort(t)), fmt.Sprintf("--http_endpoint=localhost:%d", freePort(t)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, s.addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to connect to %s started from %s: %v", s.addr, serverBin, err)
+	}
+	s.conn = conn
+	return s
+}
+
+func (s *logServers) start(t *testing.T, bin string, args ...string) {
+	t.Helper()
+	args = append(args, strings.Fields(*skewServerFlagsFlag)...)
+	cmd := exec.Command(bin, append(args, "--logtostderr")...)
+	logFile := filepath.Join(t.TempDir(), filepath.Base(bin)+".log")
+	out, err := os.Create(logFile)
+	if err != nil {
+		t.Fatalf("Failed to create log file: %v", err)
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start %s: %v", bin, err)
+	}
+	s.procs = append(s.procs, cmd)
+	t.Cleanup(func() {
+		out.Close()
+		if t.Failed() {
+			if b, err := os.ReadFile(logFile); err == nil {
+				t.Logf("Log of %s:\n%s", bin, b)
+			}
+		}
+	})
+}
+
+// stop interrupts the servers, and kills them if they don't exit in time.
+func (s *logServers) stop() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	for _, cmd := range s.procs {
+		_ = cmd.Process.Signal(os.Interrupt)
+		done := make(chan struct{})
+		go func() {
+			_ = cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			_ = cmd.Process.Kill()
+			<-done
+		}
+	}
+	s.procs = nil
+}
+
+// createLog creates and initializes a log, using the client code in this tree.
+func (s *logServers) createLog(t *testing.T) *trillian.Tree {
+	t.Helper()
+	tree, err := client.CreateAndInitTree(context.Background(), &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, trillian.NewTrillianAdminClient(s.conn), trillian.NewTrillianLogClient(s.conn))
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+	return tree
+}
+
+// buildCurrentServers builds the log server and signer of this tree.
+func buildCurrentServers(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	var bins []string
+	for _, name := range []string{"trillian_log_server", "trillian_log_signer"} {
+		bin := filepath.Join(dir, name)
+		cmd := exec.Command("go", "build", "-o", bin, "github.com/google/trillian/cmd/"+name)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to build %s: %v\n%s", name, err, out)
+		}
+		bins = append(bins, bin)
+	}
+	return bins[0], bins[1]
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to pick a port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func skewParams(treeID int64) TestParameters {
+	params := DefaultTestParameters(treeID)
+	params.SequencingPollWait = time.Second
+	return params
+}
+
+func TestVersionSkewCurrentClient(t *testing.T) {
+	if *skewLogServerFlag == "" || *skewLogSignerFlag == "" {
+		t.Skip("--skew_log_server and --skew_log_signer not set, skipping")
+	}
+	s := startLogServers(t, *skewLogServerFlag, *skewLogSignerFlag)
+	params := skewParams(s.createLog(t).TreeId)
+	logClient := trillian.NewTrillianLogClient(s.conn)
+	if err := RunLogIntegration(logClient, params); err != nil {
+		t.Fatalf("Log integration against servers of another release failed: %v", err)
+	}
+	if err := RunCompatibilityChecks(logClient, trillian.NewTrillianAdminClient(s.conn), params); err != nil {
+		t.Fatalf("Compatibility checks against servers of another release failed: %v", err)
+	}
+}
+
+func TestVersionSkewCurrentServers(t *testing.T) {
+	if *skewIntegrationTestFlag == "" {
+		t.Skip("--skew_integration_test not set, skipping")
+	}
+	serverBin, signerBin := buildCurrentServers(t)
+	s := startLogServers(t, serverBin, signerBin)
+	tree := s.createLog(t)
+
+	cmd := exec.Command(*skewIntegrationTestFlag, "-test.run=^TestLiveLogIntegration$", "-test.v",
+		"--log_rpc_server="+s.addr, fmt.Sprintf("--treeid=%d", tree.TreeId), "--queue_poll_wait=1s")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Integration test of another release against current servers failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "--- PASS: TestLiveLogIntegration") {
+		t.Fatalf("Integration test of another release didn't run TestLiveLogIntegration:\n%s", out)
+	}
+}
+
+func TestVersionSkewUpgrade(t *testing.T) {
+	if *skewLogServerFlag == "" || *skewLogSignerFlag == "" {
+		t.Skip("--skew_log_server and --skew_log_signer not set, skipping")
+	}
+	old := startLogServers(t, *skewLogServerFlag, *skewLogSignerFlag)
+	params := skewParams(old.createLog(t).TreeId)
+	params.Seed = time.Now().UnixNano()
+	if err := RunLogIntegration(trillian.NewTrillianLogClient(old.conn), params); err != nil {
+		t.Fatalf("Log integration against servers of another release failed: %v", err)
+	}
+	old.stop()
+
+	// The servers built from this tree must serve the log written by the
+	// servers of the other release, and keep appending to it.
+	serverBin, signerBin := buildCurrentServers(t)
+	s := startLogServers(t, serverBin, signerBin)
+	logClient := trillian.NewTrillianLogClient(s.conn)
+	params.CheckLogEmpty = false
+	params.QueueLeaves = false
+	if err := RunLogIntegration(logClient, params); err != nil {
+		t.Fatalf("Log integration of upgraded log failed: %v", err)
+	}
+	if err := RunCompatibilityChecks(logClient, trillian.NewTrillianAdminClient(s.conn), params); err != nil {
+		t.Fatalf("Compatibility checks of upgraded log failed: %v", err)
+	}
+	// Starting from an empty trusted root checks the consistency of the
+	// upgraded log from its first leaf.
+	c, err := client.NewFromTree(logClient, stestonly.LogTree, types.LogRootV1{})
+	if err != nil {
+		t.Fatalf("NewFromTree(): %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), params.SequencingWaitTotal)
+	defer cancel()
+	if err := c.AddLeaf(ctx, []byte(fmt.Sprintf("Leaf after upgrade %d", params.Seed))); err != nil {
+		t.Fatalf("AddLeaf() after upgrade: %v", err)
+	}
+}
+
+// noStreamLogClient is a TrillianLogClient of a server which predates the
+// QueueLeaves stream, as the releases the version skew tests run against.
+type noStreamLogClient struct {
+	trillian.TrillianLogClient
+}
+
+func (noStreamLogClient) QueueLeaves(ctx context.Context, opts ...grpc.CallOption) (trillian.TrillianLog_QueueLeavesClient, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method QueueLeaves for service trillian.TrillianLog")
+}
+
+// TestVersionSkewQueueLeafFallback checks that the current client code falls
+// back to QueueLeaf with servers which don't have the QueueLeaves stream.
+func TestVersionSkewQueueLeafFallback(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	env, err := integration.NewLogEnvWithRegistry(ctx, 1, extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{Tree: stestonly.LogTree}, env.Admin, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+	params := DefaultTestParameters(tree.TreeId)
+	if err := RunLogIntegration(noStreamLogClient{env.Log}, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
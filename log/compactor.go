@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	compactOnce        sync.Once
+	compactRuns        monitoring.Counter
+	compactFailures    monitoring.Counter
+	compactedRevisions monitoring.Counter
+)
+
+func initCompactMetrics(mf monitoring.MetricFactory) {
+	compactOnce.Do(func() {
+		if mf == nil {
+			mf = monitoring.InertMetricFactory{}
+		}
+		compactRuns = mf.NewCounter("compact_runs", "Number of completed subtree compactions of a tree", logIDLabel)
+		compactFailures = mf.NewCounter("compact_failures", "Number of subtree compactions of a tree that failed", logIDLabel)
+		compactedRevisions = mf.NewCounter("compacted_subtree_revisions", "Number of obsolete subtree revisions deleted by compaction", logIDLabel)
+	})
+}
+
+// Compactor periodically deletes the historical subtree revisions of the
+// active log trees this instance is master for which aren't read at any of
+// their latest revisions, in storage implementing storage.SubtreeCompactor. Each revision of a tree is
+// written by the integration of a batch of leaves or the signing of a new
+// root, so that the revisions retained bound how far behind the latest root
+// readers of the tree, such as lagging replicas, can be.
+type Compactor struct {
+	// Admin is used to look up the trees to compact.
+	Admin storage.AdminStorage
+	// Log is the storage the trees are compacted in.
+	Log storage.LogStorage
+	// Interval is the time between the starts of two compaction passes over
+	// all trees.
+	Interval time.Duration
+	// Retained is the number of latest revisions retained for trees whose
+	// CompactRetainedRevisions is zero. Zero disables their compaction.
+	Retained int64
+	// IsMaster reports whether this instance is master for a log, so that
+	// each log is compacted by a single instance. All logs are compacted if
+	// it is nil.
+	IsMaster func(logID int64) bool
+	// TimeSource is used to wait between passes. The system clock is used if
+	// it is nil.
+	TimeSource clock.TimeSource
+}
+
+// NewCompactor returns a Compactor which registers its metrics with mf.
+func NewCompactor(admin storage.AdminStorage, ls storage.LogStorage, interval time.Duration, retained int64, mf monitoring.MetricFactory) *Compactor {
+	initCompactMetrics(mf)
+	return &Compactor{Admin: admin, Log: ls, Interval: interval, Retained: retained}
+}
+
+// Run compacts all active trees every Interval until ctx is done.
+func (c *Compactor) Run(ctx context.Context) {
+	ts := c.TimeSource
+	if ts == nil {
+		ts = clock.System
+	}
+	for {
+		start := ts.Now()
+		c.compactAll(ctx)
+		wait := c.Interval - ts.Now().Sub(start)
+		if wait < 0 {
+			wait = 0
+		}
+		if err := clock.SleepSource(ctx, wait, ts); err != nil {
+			glog.Infof("Compactor stopping: %v", err)
+			return
+		}
+	}
+}
+
+// retained returns the number of revisions of the tree to retain, or zero if
+// it isn't compacted.
+func (c *Compactor) retained(tree *trillian.Tree) int64 {
+	if tree.CompactRetainedRevisions > 0 {
+		return int64(tree.CompactRetainedRevisions)
+	}
+	return c.Retained
+}
+
+// compactAll compacts each active tree this instance is master for, and
+// returns the total number of subtree revisions deleted.
+func (c *Compactor) compactAll(ctx context.Context) int64 {
+	initCompactMetrics(nil)
+	compactor, ok := c.Log.(storage.SubtreeCompactor)
+	if !ok {
+		glog.Warning("Compactor: storage doesn't support subtree compaction")
+		return 0
+	}
+	ids, err := c.Log.GetActiveLogIDs(ctx)
+	if err != nil {
+		glog.Warningf("Compactor failed to list active logs: %v", err)
+		return 0
+	}
+	var total int64
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return total
+		}
+		if c.IsMaster != nil && !c.IsMaster(id) {
+			continue
+		}
+		tree, err := storage.GetTree(ctx, c.Admin, id)
+		if err != nil {
+			glog.Warningf("%v: compactor failed to get tree: %v", id, err)
+			continue
+		}
+		retained := c.retained(tree)
+		if retained <= 0 {
+			continue
+		}
+		label := strconv.FormatInt(id, 10)
+		deleted, err := compactor.CompactSubtrees(ctx, tree, retained)
+		compactedRevisions.Add(float64(deleted), label)
+		total += deleted
+		if status.Code(err) == codes.Unimplemented {
+			// Storage wrapping another one may not be able to compact it.
+			glog.Warningf("Compactor: storage doesn't support subtree compaction: %v", err)
+			return total
+		}
+		if err != nil {
+			compactFailures.Inc(label)
+			glog.Warningf("%v: compaction failed after deleting %d subtree revisions: %v", id, deleted, err)
+			continue
+		}
+		compactRuns.Inc(label)
+		glog.V(1).Infof("%v: compaction deleted %d subtree revisions, retaining %d revisions", id, deleted, retained)
+	}
+	return total
+}
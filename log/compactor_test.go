@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCompactor(t *testing.T) {
+	ctx := context.Background()
+	InitMetrics(nil)
+	ts := memory.NewTreeStorage()
+	ls, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	goodHash := func(_ int, value []byte) []byte { return rfc6962.DefaultHasher.HashLeaf(value) }
+
+	// Integrate one leaf per revision, each of which rewrites the same subtree.
+	tree := newScrubTestTree(ctx, t, ls, as, 1, goodHash)
+	skipped := newScrubTestTree(ctx, t, ls, as, 1, goodHash)
+	for i := 1; i < 5; i++ {
+		for _, tr := range []*trillian.Tree{tree, skipped} {
+			value := []byte(fmt.Sprintf("leaf %d", i))
+			id := sha256.Sum256(value)
+			leaf := &trillian.LogLeaf{LeafValue: value, LeafIdentityHash: id[:], MerkleLeafHash: goodHash(i, value), LeafIndex: int64(i)}
+			if _, err := ls.AddSequencedLeaves(ctx, tr, []*trillian.LogLeaf{leaf}, time.Now()); err != nil {
+				t.Fatalf("AddSequencedLeaves: %v", err)
+			}
+			if _, err := IntegrateBatch(ctx, tr, 1, 0, 0, clock.System, ls, quota.Noop()); err != nil {
+				t.Fatalf("IntegrateBatch: %v", err)
+			}
+		}
+	}
+	countRevisions := func() map[int64]int {
+		ret := make(map[int64]int)
+		for _, tr := range []*trillian.Tree{tree, skipped} {
+			memory.DumpSubtrees(ls, tr.TreeId, func(string, *storagepb.SubtreeProto) { ret[tr.TreeId]++ })
+		}
+		return ret
+	}
+	before := countRevisions()
+
+	// Only the tree which sets its own retention is compacted.
+	if _, err := storage.UpdateTree(ctx, as, tree.TreeId, func(tree *trillian.Tree) { tree.CompactRetainedRevisions = 2 }); err != nil {
+		t.Fatalf("UpdateTree: %v", err)
+	}
+	c := NewCompactor(as, ls, time.Hour, 0, nil)
+	deleted := c.compactAll(ctx)
+	if got, want := deleted, int64(before[tree.TreeId]-2); got != want {
+		t.Errorf("compactAll() deleted %d subtree revisions, want %d", got, want)
+	}
+	want := map[int64]int{tree.TreeId: 2, skipped.TreeId: before[skipped.TreeId]}
+	if diff := cmp.Diff(want, countRevisions()); diff != "" {
+		t.Errorf("subtree revisions diff (-want +got):\n%s", diff)
+	}
+	if got := c.compactAll(ctx); got != 0 {
+		t.Errorf("compactAll() again deleted %d subtree revisions, want 0", got)
+	}
+
+	// The tree can still be read at its latest revision.
+	report, err := ScrubTree(ctx, tree, ls, ScrubOptions{})
+	if err != nil {
+		t.Fatalf("ScrubTree: %v", err)
+	}
+	if report.TreeSize != 5 || report.CorruptionCount != 0 {
+		t.Errorf("ScrubTree() = %+v, want size 5 without corruptions", report)
+	}
+}
+
+func TestCompactorUnsupportedStorage(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	ls, as := struct{ storage.LogStorage }{memory.NewLogStorage(ts, nil)}, memory.NewAdminStorage(ts)
+	c := NewCompactor(as, ls, time.Hour, 1, nil /* mf */)
+	if got := c.compactAll(ctx); got != 0 {
+		t.Errorf("compactAll() deleted %d subtree revisions, want 0", got)
+	}
+
+	// Storage wrapping another one may implement SubtreeCompactor without
+	// supporting it.
+	goodHash := func(_ int, value []byte) []byte { return rfc6962.DefaultHasher.HashLeaf(value) }
+	newScrubTestTree(ctx, t, ls, as, 2, goodHash)
+	c = NewCompactor(as, unimplementedCompactor{ls}, time.Hour, 1, nil /* mf */)
+	if got := c.compactAll(ctx); got != 0 {
+		t.Errorf("compactAll() deleted %d subtree revisions, want 0", got)
+	}
+}
+
+type unimplementedCompactor struct {
+	storage.LogStorage
+}
+
+func (unimplementedCompactor) CompactSubtrees(context.Context, *trillian.Tree, int64) (int64, error) {
+	return 0, status.Error(codes.Unimplemented, "compaction is not supported")
+}
+
+func TestCompactorMastership(t *testing.T) {
+	ctx := context.Background()
+	InitMetrics(nil)
+	ts := memory.NewTreeStorage()
+	ls, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	goodHash := func(_ int, value []byte) []byte { return rfc6962.DefaultHasher.HashLeaf(value) }
+	tree := newScrubTestTree(ctx, t, ls, as, 1, goodHash)
+	value := []byte("leaf 1")
+	id := sha256.Sum256(value)
+	leaf := &trillian.LogLeaf{LeafValue: value, LeafIdentityHash: id[:], MerkleLeafHash: goodHash(1, value), LeafIndex: 1}
+	if _, err := ls.AddSequencedLeaves(ctx, tree, []*trillian.LogLeaf{leaf}, time.Now()); err != nil {
+		t.Fatalf("AddSequencedLeaves: %v", err)
+	}
+	if _, err := IntegrateBatch(ctx, tree, 1, 0, 0, clock.System, ls, quota.Noop()); err != nil {
+		t.Fatalf("IntegrateBatch: %v", err)
+	}
+
+	c := NewCompactor(as, ls, time.Hour, 1, nil /* mf */)
+	master := false
+	c.IsMaster = func(logID int64) bool { return master }
+	if got := c.compactAll(ctx); got != 0 {
+		t.Errorf("compactAll() without mastership deleted %d subtree revisions, want 0", got)
+	}
+	master = true
+	if got := c.compactAll(ctx); got == 0 {
+		t.Error("compactAll() with mastership deleted no subtree revisions")
+	}
+}
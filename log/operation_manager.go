@@ -18,6 +18,7 @@ package log
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -26,28 +27,41 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util/clock"
 	"github.com/google/trillian/util/election"
-	"golang.org/x/sync/semaphore"
+	"github.com/google/trillian/util/election2"
 )
 
+// errDraining is returned by operateOnce when Shutdown has been called.
+var errDraining = errors.New("log operation manager is draining")
+
 var (
 	// DefaultTimeout is the default timeout on a single log operation run.
 	DefaultTimeout = 60 * time.Second
+	// TreeRefreshInterval is the time after which the tree of a log is read
+	// again, to pick up changes to its priority and affinity.
+	TreeRefreshInterval = time.Minute
 
 	once              sync.Once
 	knownLogs         monitoring.Gauge
 	resignations      monitoring.Counter
 	isMaster          monitoring.Gauge
+	masterSince       monitoring.Gauge
+	masterAcquired    monitoring.Counter
+	masterLost        monitoring.Counter
 	signingRuns       monitoring.Counter
 	failedSigningRuns monitoring.Counter
 	entriesAdded      monitoring.Counter
 	batchesAdded      monitoring.Counter
+	schedulingDelay   monitoring.Histogram
 )
 
+const priorityLabel = "priority"
+
 func createMetrics(mf monitoring.MetricFactory) {
 	if mf == nil {
 		mf = monitoring.InertMetricFactory{}
@@ -55,6 +69,9 @@ func createMetrics(mf monitoring.MetricFactory) {
 	knownLogs = mf.NewGauge("known_logs", "Set to 1 for known logs (whether this instance is master or not)", logIDLabel)
 	resignations = mf.NewCounter("master_resignations", "Number of mastership resignations", logIDLabel)
 	isMaster = mf.NewGauge("is_master", "Whether this instance is master (0/1)", logIDLabel)
+	masterSince = mf.NewGauge("master_since", "Time at which this instance became master in seconds since the epoch, or 0 if it isn't master", logIDLabel)
+	masterAcquired = mf.NewCounter("master_acquisitions", "Number of times this instance became master", logIDLabel)
+	masterLost = mf.NewCounter("master_losses", "Number of times this instance stopped being master, including resignations", logIDLabel)
 	signingRuns = mf.NewCounter("signing_runs", "Number of times a signing run has succeeded", logIDLabel)
 	failedSigningRuns = mf.NewCounter("failed_signing_runs", "Number of times a signing run has failed", logIDLabel)
 	// entriesAdded is the total number of entries that have been added to the
@@ -68,6 +85,9 @@ func createMetrics(mf monitoring.MetricFactory) {
 	// entriesAdded / batchesAdded is average batch size. These can be used for
 	// tuning sequencing or evaluating performance.
 	batchesAdded = mf.NewCounter("batches_added", "Number of times a non zero number of entries was added", logIDLabel)
+	// schedulingDelay is the time logs wait for a worker in each pass, which
+	// grows for lower priorities when the signer is overloaded.
+	schedulingDelay = mf.NewHistogram("scheduling_delay", "Delay between the start of a pass and the operation on a log in seconds, by tree priority", priorityLabel)
 }
 
 // Operation defines a task that operates on a log. Examples are scheduling, signing,
@@ -95,21 +115,43 @@ type OperationInfo struct {
 
 	// Election-related configuration. Copied for each log.
 	ElectionConfig election.RunnerConfig
+	// Affinity makes the manager honour the signer affinity and anti-affinity
+	// group of trees in elections.
+	Affinity bool
+	// InstanceLabels are the labels of this instance, which are matched against
+	// the signer affinity of trees.
+	InstanceLabels []string
+	// AntiAffinityDelay is how long to wait before campaigning for mastership
+	// of a log while master for another log of the same anti-affinity group.
+	AntiAffinityDelay time.Duration
 
 	// RunInterval is the time between starting batches of processing.  If a
 	// batch takes longer than this interval to complete, the next batch
 	// will start immediately.
 	RunInterval time.Duration
-	// NumWorkers is the number of worker goroutines to run in parallel.
+	// NumWorkers is the number of worker goroutines to run in parallel, i.e.
+	// the maximum number of concurrent operation runs over all logs.
 	NumWorkers int
+	// MaxRunsPerTree is the maximum number of concurrent operation runs on a
+	// single log, when it's run again within a pass because its last run
+	// processed a full batch. If unset, a log is run by one worker at a time,
+	// which operations that don't tolerate concurrent runs on a log rely on.
+	MaxRunsPerTree int
 	// Timeout sets an optional timeout on each operation run.
 	// If unset, default to the value of DefaultTimeout.
 	Timeout time.Duration
+	// WatchPass, if set, is called as each pass over a log starts, and the
+	// function it returns with the outcome of the pass, e.g. to profile slow
+	// passes.
+	WatchPass func(logID int64) (done func(err error))
 }
 
 // OperationManager controls scheduling activities for logs.
 type OperationManager struct {
 	info OperationInfo
+	// infoMutex guards the BatchSize and RunInterval fields of info, which can
+	// be changed while the manager is running.
+	infoMutex sync.Mutex
 
 	// logOperation is the task that gets run for active logs.
 	logOperation Operation
@@ -118,6 +160,9 @@ type OperationManager struct {
 	runnerWG sync.WaitGroup
 	// runnerCancels contains cancel function for each logID election Runner.
 	runnerCancels map[string]context.CancelFunc
+	// runnerMutex guards runnerCancels, which Shutdown accesses concurrently
+	// with the OperationLoop.
+	runnerMutex sync.Mutex
 	// pendingResignations delivers resignation requests from election Runners.
 	pendingResignations chan election.Resignation
 
@@ -125,10 +170,27 @@ type OperationManager struct {
 
 	// Cache of logID => name. Names are assumed not to change during runtime.
 	logNames map[int64]string
+	// Cache of logID => tree, which is refreshed as priorities and affinities
+	// may change.
+	trees map[int64]cachedTree
 	// A recent list of active logs that this instance is master for.
 	lastHeld []int64
-	// idsMutex guards logNames and lastHeld fields.
+	// idsMutex guards logNames, trees and lastHeld fields.
 	idsMutex sync.Mutex
+
+	// drain is closed by Shutdown, after setting drainCtx, to make the
+	// OperationLoop exit after the current pass.
+	drain     chan struct{}
+	drainCtx  context.Context
+	drainOnce sync.Once
+	// done is closed when the OperationLoop has exited.
+	done chan struct{}
+}
+
+// cachedTree is the tree of a log, and when to read it again.
+type cachedTree struct {
+	tree   *trillian.Tree
+	expiry time.Time
 }
 
 // NewOperationManager creates a new OperationManager instance.
@@ -139,13 +201,30 @@ func NewOperationManager(info OperationInfo, logOperation Operation) *OperationM
 	if info.Timeout == 0 {
 		info.Timeout = DefaultTimeout
 	}
-	tracker := election.NewMasterTracker(nil, func(id string, v bool) {
-		val := 0.0
+	// held is only accessed by the notify function, which the tracker calls
+	// under its lock.
+	held := make(map[string]bool)
+	var tracker *election.MasterTracker
+	tracker = election.NewMasterTracker(nil, func(id string, v bool) {
+		val, since := 0.0, 0.0
 		if v {
 			val = 1.0
+			since = float64(tracker.TimeSource.Now().UnixNano()) / float64(time.Second)
 		}
 		isMaster.Set(val, id)
+		if v != held[id] {
+			if v {
+				masterAcquired.Inc(id)
+			} else {
+				masterLost.Inc(id)
+			}
+			held[id] = v
+			masterSince.Set(since, id)
+		}
 	})
+	if info.TimeSource != nil {
+		tracker.TimeSource = info.TimeSource
+	}
 	return &OperationManager{
 		info:                info,
 		logOperation:        logOperation,
@@ -153,7 +232,27 @@ func NewOperationManager(info OperationInfo, logOperation Operation) *OperationM
 		pendingResignations: make(chan election.Resignation, 100),
 		tracker:             tracker,
 		logNames:            make(map[int64]string),
+		trees:               make(map[int64]cachedTree),
+		drain:               make(chan struct{}),
+		done:                make(chan struct{}),
+	}
+}
+
+// MastershipServer returns a server for the electionpb.Mastership service,
+// which reports the mastership of the logs known to this instance.
+func (o *OperationManager) MastershipServer(instanceID string) *election.StatusServer {
+	lookup, _ := o.info.Registry.ElectionFactory.(election2.MasterLookup)
+	return election.NewStatusServer(instanceID, o.tracker, lookup)
+}
+
+// IsMaster returns whether this instance is currently master for the log. It
+// always is if no election factory is configured.
+func (o *OperationManager) IsMaster(logID int64) bool {
+	if o.info.Registry.ElectionFactory == nil {
+		return true
 	}
+	_, held := o.tracker.MasterSince(strconv.FormatInt(logID, 10))
+	return held
 }
 
 // logName maps a logID to a human-readable name, caching results along the way.
@@ -179,6 +278,97 @@ func (o *OperationManager) logName(ctx context.Context, logID int64) string {
 	return o.logNames[logID]
 }
 
+// logTree returns the tree of a log, caching results for TreeRefreshInterval.
+// It returns nil if the tree can't be read. The tree is read without holding
+// idsMutex, so concurrent callers may read it more than once.
+func (o *OperationManager) logTree(ctx context.Context, logID int64) *trillian.Tree {
+	now := o.info.TimeSource.Now()
+	o.idsMutex.Lock()
+	t, ok := o.trees[logID]
+	o.idsMutex.Unlock()
+	if ok && now.Before(t.expiry) {
+		return t.tree
+	}
+
+	tree, err := storage.GetTree(ctx, o.info.Registry.AdminStorage, logID)
+	if err != nil {
+		glog.Errorf("%v: failed to get log tree: %v", logID, err)
+		return nil
+	}
+	o.idsMutex.Lock()
+	defer o.idsMutex.Unlock()
+	o.trees[logID] = cachedTree{tree: tree, expiry: now.Add(TreeRefreshInterval)}
+	return tree
+}
+
+// logSchedule holds the settings of a log which govern how it's scheduled
+// in a pass.
+type logSchedule struct {
+	// priority orders the first runs of logs in a pass.
+	priority trillian.TreePriority
+	// batchSize is the number of items processed by a run which uses a full
+	// batch, making the log run again if a worker is free.
+	batchSize int
+}
+
+// logSchedule returns the scheduling settings of a log, given the default
+// batch size. Logs whose tree can't be read have the normal priority.
+func (o *OperationManager) logSchedule(ctx context.Context, logID int64, batchSize int) logSchedule {
+	tree := o.logTree(ctx, logID)
+	if size := tree.GetSequencerBatchSize(); size > 0 {
+		batchSize = int(size)
+	}
+	return logSchedule{priority: tree.GetPriority(), batchSize: batchSize}
+}
+
+// hasAffinity returns whether this instance may run the given log, i.e. its
+// tree has no signer affinity or one of the labels of the instance. Logs whose
+// tree can't be read are run as if they had no affinity.
+func (o *OperationManager) hasAffinity(ctx context.Context, logID int64) bool {
+	labels := o.logTree(ctx, logID).GetSignerAffinity()
+	if len(labels) == 0 {
+		return true
+	}
+	for _, label := range labels {
+		for _, own := range o.info.InstanceLabels {
+			if label == own {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// campaignDelay returns how long to wait before campaigning for mastership of
+// the given log, which is AntiAffinityDelay if this instance is master for
+// another log of its anti-affinity group. It only uses the cached trees, as
+// it's called by the election Runners.
+func (o *OperationManager) campaignDelay(logID string) time.Duration {
+	held := o.tracker.Held()
+	o.idsMutex.Lock()
+	defer o.idsMutex.Unlock()
+	group := o.cachedGroupLocked(logID)
+	if group == "" {
+		return 0
+	}
+	for _, id := range held {
+		if id != logID && o.cachedGroupLocked(id) == group {
+			return o.info.AntiAffinityDelay
+		}
+	}
+	return 0
+}
+
+// cachedGroupLocked returns the anti-affinity group of the cached tree of a
+// log. It must be called with idsMutex held.
+func (o *OperationManager) cachedGroupLocked(logID string) string {
+	id, err := strconv.ParseInt(logID, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return o.trees[id].tree.GetAntiAffinityGroup()
+}
+
 func (o *OperationManager) heldInfo(ctx context.Context, logIDs []int64) string {
 	names := make([]string, 0, len(logIDs))
 	for _, logID := range logIDs {
@@ -203,16 +393,33 @@ func (o *OperationManager) masterFor(ctx context.Context, allIDs []int64) ([]int
 	allStringIDs := make([]string, 0, len(allIDs))
 	for _, id := range allIDs {
 		s := strconv.FormatInt(id, 10)
+		knownLogs.Set(1, s)
+		// Stop the election of logs which this instance may no longer run, so
+		// that their mastership moves to other instances.
+		if o.info.Affinity && !o.hasAffinity(ctx, id) {
+			o.runnerMutex.Lock()
+			if cancel := o.runnerCancels[s]; cancel != nil {
+				glog.Infof("%s: stop master election, as the log has no affinity with this instance", s)
+				cancel()
+				delete(o.runnerCancels, s)
+			}
+			o.runnerMutex.Unlock()
+			continue
+		}
 		allStringIDs = append(allStringIDs, s)
 	}
 
-	// Synchronize the set of log IDs with those we are tracking mastership for.
-	for _, logID := range allStringIDs {
-		knownLogs.Set(1, logID)
-		if o.runnerCancels[logID] == nil {
-			o.tracker.Set(logID, false) // Initialise tracking for this ID.
-			o.runnerCancels[logID] = o.runElectionWithRestarts(ctx, logID)
+	// Synchronize the set of log IDs with those we are tracking mastership for,
+	// unless shutting down, which mustn't claim mastership of new logs.
+	if !o.draining() {
+		o.runnerMutex.Lock()
+		for _, logID := range allStringIDs {
+			if o.runnerCancels[logID] == nil {
+				o.tracker.Set(logID, false) // Initialise tracking for this ID.
+				o.runnerCancels[logID] = o.runElectionWithRestarts(ctx, logID)
+			}
 		}
+		o.runnerMutex.Unlock()
 	}
 
 	held := o.tracker.Held()
@@ -251,6 +458,9 @@ func (o *OperationManager) runElectionWithRestarts(ctx context.Context, logID st
 		// Warning: NewRunner can attempt to modify the config. Make a separate
 		// copy of the config for each log, to avoid data races.
 		config := o.info.ElectionConfig
+		if o.info.Affinity && o.info.AntiAffinityDelay > 0 {
+			config.CampaignDelay = o.campaignDelay
+		}
 		// TODO(pavelkalinnikov): Passing the cancel function is not needed here.
 		r := election.NewRunner(logID, &config, o.tracker, cancel, e)
 		r.Run(ctx, o.pendingResignations)
@@ -308,10 +518,34 @@ func (o *OperationManager) getLogsAndExecutePass(ctx context.Context) error {
 	}
 	o.updateHeldIDs(ctx, logIDs, activeIDs)
 
-	executePassForAll(runCtx, &o.info, o.logOperation, logIDs)
+	o.infoMutex.Lock()
+	info := o.info
+	o.infoMutex.Unlock()
+
+	schedules := make(map[int64]logSchedule, len(logIDs))
+	for _, logID := range logIDs {
+		schedules[logID] = o.logSchedule(ctx, logID, info.BatchSize)
+	}
+	executePassForAll(runCtx, &info, o.logOperation, logIDs, schedules)
 	return nil
 }
 
+// SetBatchSize changes the BatchSize passed to operations, from the next pass
+// onwards.
+func (o *OperationManager) SetBatchSize(batchSize int) {
+	o.infoMutex.Lock()
+	defer o.infoMutex.Unlock()
+	o.info.BatchSize = batchSize
+}
+
+// SetRunInterval changes the RunInterval between passes, from the next pass
+// onwards.
+func (o *OperationManager) SetRunInterval(interval time.Duration) {
+	o.infoMutex.Lock()
+	defer o.infoMutex.Unlock()
+	o.info.RunInterval = interval
+}
+
 // OperationSingle performs a single pass of the manager.
 //
 // TODO(pavelkalinnikov): Deprecate this because it doesn't clean up any state,
@@ -322,11 +556,29 @@ func (o *OperationManager) OperationSingle(ctx context.Context) {
 	}
 }
 
-// OperationLoop starts the manager working. It continues until told to exit.
+// OperationLoop starts the manager working. It continues until ctx is canceled,
+// or Shutdown is called.
 // TODO(Martin2112): No mechanism for error reporting etc., this is OK for v1 but needs work
 func (o *OperationManager) OperationLoop(ctx context.Context) {
+	defer close(o.done)
 	glog.Infof("Log operation manager starting")
 
+	// Passes are aborted if a graceful shutdown runs out of time.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-o.drain:
+			select {
+			case <-o.drainCtx.Done():
+				glog.Warningf("Log operation manager shutdown timed out, aborting: %v", o.drainCtx.Err())
+				cancel()
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+	}()
+
 	// Outer loop, runs until terminated.
 	for {
 		if err := o.operateOnce(ctx); err != nil {
@@ -335,13 +587,9 @@ func (o *OperationManager) OperationLoop(ctx context.Context) {
 		}
 	}
 
-	// Terminate all the election Runners.
-	for logID, cancel := range o.runnerCancels {
-		if cancel != nil {
-			glog.V(1).Infof("cancel election runner for %s", logID)
-			cancel()
-		}
-	}
+	// Terminate all the election Runners, which resign the mastership they
+	// hold.
+	o.stopElections(false)
 
 	// Drain any remaining resignations which might have triggered.
 	close(o.pendingResignations)
@@ -355,6 +603,58 @@ func (o *OperationManager) OperationLoop(ctx context.Context) {
 	glog.Infof("wait for termination of election runners...done")
 }
 
+// Shutdown makes the OperationLoop exit gracefully: it stops campaigning for
+// mastership of the logs this instance isn't master for, lets the current pass
+// finish, and then resigns the mastership of the other logs, so that other
+// instances can take them over without waiting for it to expire. The pass is
+// aborted if ctx is done before it finishes.
+//
+// Shutdown returns once the OperationLoop has exited, or ctx is done.
+func (o *OperationManager) Shutdown(ctx context.Context) error {
+	o.drainOnce.Do(func() {
+		glog.Infof("Log operation manager draining")
+		o.drainCtx = ctx
+		close(o.drain)
+		o.stopElections(true)
+	})
+	select {
+	case <-o.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// draining returns whether Shutdown has been called.
+func (o *OperationManager) draining() bool {
+	select {
+	case <-o.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopElections cancels the election Runners, except those of the logs this
+// instance is master for if keepHeld is set.
+func (o *OperationManager) stopElections(keepHeld bool) {
+	held := make(map[string]bool)
+	if keepHeld {
+		for _, id := range o.tracker.Held() {
+			held[id] = true
+		}
+	}
+	o.runnerMutex.Lock()
+	defer o.runnerMutex.Unlock()
+	for logID, cancel := range o.runnerCancels {
+		if cancel != nil && !held[logID] {
+			glog.V(1).Infof("cancel election runner for %s", logID)
+			cancel()
+			delete(o.runnerCancels, logID)
+		}
+	}
+}
+
 // operateOnce runs a single round of operation for each of the active logs
 // that this instance is master for. Returns an error only if the context is
 // canceled, i.e. the operation is being shut down.
@@ -385,16 +685,26 @@ func (o *OperationManager) operateOnce(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
+	case <-o.drain:
+		return errDraining
 	default:
 	}
 
 	// Wait for the configured time before going for another pass.
 	duration := o.info.TimeSource.Now().Sub(start)
+	o.infoMutex.Lock()
 	wait := o.info.RunInterval - duration
+	o.infoMutex.Unlock()
 	if wait > 0 {
 		glog.V(1).Infof("Processing started at %v for %v; wait %v before next run", start, duration, wait)
-		if err := clock.SleepContext(ctx, wait); err != nil {
-			return err
+		timer := o.info.TimeSource.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.drain:
+			return errDraining
+		case <-timer.Chan():
 		}
 	} else {
 		glog.V(1).Infof("Processing started at %v for %v; start next run immediately", start, duration)
@@ -403,31 +713,56 @@ func (o *OperationManager) operateOnce(ctx context.Context) error {
 }
 
 // executePassForAll runs ExecutePass of the given operation for each of the
-// passed-in logs, allowing up to a configurable number of parallel operations.
-func executePassForAll(ctx context.Context, info *OperationInfo, op Operation, logIDs []int64) {
+// passed-in logs, with a pool of up to NumWorkers workers which take the logs
+// from a shared passQueue. Each log is run once, from the highest priority to
+// the lowest, so that when there are more logs than workers, higher priority
+// logs don't wait for lower priority ones. Workers which would otherwise be
+// idle run logs that processed a full batch again, until RunInterval has
+// elapsed since the start of the pass, so that busy logs don't wait for the
+// next pass while other logs are done.
+func executePassForAll(ctx context.Context, info *OperationInfo, op Operation, logIDs []int64, schedules map[int64]logSchedule) {
 	startBatch := info.TimeSource.Now()
+	logIDs = append([]int64(nil), logIDs...)
+	sort.SliceStable(logIDs, func(i, j int) bool {
+		return priorityRank(schedules[logIDs[i]].priority) < priorityRank(schedules[logIDs[j]].priority)
+	})
 
 	numWorkers := info.NumWorkers
 	if numWorkers <= 0 {
 		glog.Warning("Running executor with NumWorkers <= 0, assuming 1")
 		numWorkers = 1
 	}
+	maxPerTree := info.MaxRunsPerTree
+	if maxPerTree <= 0 {
+		maxPerTree = 1
+	}
+	if n := len(logIDs) * maxPerTree; numWorkers > n {
+		numWorkers = n
+	}
 	glog.V(1).Infof("Running executor with %d worker(s)", numWorkers)
 
-	sem := semaphore.NewWeighted(int64(numWorkers))
+	q := newPassQueue(logIDs, maxPerTree, startBatch.Add(info.RunInterval), info.TimeSource)
 	var wg sync.WaitGroup
-	for _, logID := range logIDs {
-		if err := sem.Acquire(ctx, 1); err != nil {
-			break // Terminate because the context is canceled.
-		}
+	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go func(logID int64) {
+		go func() {
 			defer wg.Done()
-			defer sem.Release(1)
-			if err := executePass(ctx, info, op, logID); err != nil {
-				glog.Errorf("ExecutePass(%v) failed: %v", logID, err)
+			for {
+				logID, first, ok := q.next(ctx)
+				if !ok {
+					return // The pass is over, or the context is canceled.
+				}
+				if first {
+					schedulingDelay.Observe(clock.SecondsSince(info.TimeSource, startBatch), schedules[logID].priority.String())
+				}
+				count, err := executePass(ctx, info, op, logID)
+				if err != nil {
+					glog.Errorf("ExecutePass(%v) failed: %v", logID, err)
+				}
+				batchSize := schedules[logID].batchSize
+				q.done(logID, err == nil && batchSize > 0 && count >= batchSize)
 			}
-		}(logID)
+		}()
 	}
 
 	// Wait for the workers to consume all of the logIDs.
@@ -436,14 +771,31 @@ func executePassForAll(ctx context.Context, info *OperationInfo, op Operation, l
 	glog.V(1).Infof("Group run completed in %.2f seconds", d)
 }
 
-// executePass runs ExecutePass of the given operation for the passed-in log.
-func executePass(ctx context.Context, info *OperationInfo, op Operation, logID int64) error {
+// priorityRank orders tree priorities from the highest to the lowest.
+func priorityRank(p trillian.TreePriority) int {
+	switch p {
+	case trillian.TreePriority_HIGH_PRIORITY:
+		return 0
+	case trillian.TreePriority_LOW_PRIORITY:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// executePass runs ExecutePass of the given operation for the passed-in log,
+// and returns the number of items processed.
+func executePass(ctx context.Context, info *OperationInfo, op Operation, logID int64) (count int, err error) {
 	label := strconv.FormatInt(logID, 10)
 	start := info.TimeSource.Now()
-	count, err := op.ExecutePass(ctx, logID, info)
+	if info.WatchPass != nil {
+		done := info.WatchPass(logID)
+		defer func() { done(err) }()
+	}
+	count, err = op.ExecutePass(ctx, logID, info)
 	if err != nil {
 		failedSigningRuns.Inc(label)
-		return err
+		return 0, err
 	}
 
 	// This indicates signing activity is proceeding on the logID.
@@ -456,5 +808,5 @@ func executePass(ctx context.Context, info *OperationInfo, op Operation, logID i
 	} else {
 		glog.V(1).Infof("%v: no items to process", logID)
 	}
-	return nil
+	return count, nil
 }
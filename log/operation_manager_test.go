@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -31,6 +32,7 @@ import (
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util/clock"
 	"github.com/google/trillian/util/election"
+	"github.com/google/trillian/util/election/electionpb"
 	"github.com/google/trillian/util/election2"
 	eto "github.com/google/trillian/util/election2/testonly"
 )
@@ -192,6 +194,42 @@ func TestOperationManagerExecutePassError(t *testing.T) {
 	}
 }
 
+func TestOperationManagerWatchPass(t *testing.T) {
+	ctx := context.Background()
+	logID1 := int64(451)
+	logID2 := int64(145)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage, mockAdmin := setupLogIDs(ctrl, map[int64]string{logID1: "LogID1", logID2: "LogID2"})
+	registry := extension.Registry{
+		LogStorage:   fakeStorage,
+		AdminStorage: mockAdmin,
+	}
+
+	passErr := errors.New("test error")
+	mockLogOp := NewMockOperation(ctrl)
+	mockLogOp.EXPECT().ExecutePass(gomock.Any(), logID1, gomock.Any()).Return(1, nil)
+	mockLogOp.EXPECT().ExecutePass(gomock.Any(), logID2, gomock.Any()).Return(0, passErr)
+
+	var mu sync.Mutex
+	outcomes := make(map[int64]error)
+	info := defaultOperationInfo(registry)
+	info.WatchPass = func(logID int64) func(error) {
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			outcomes[logID] = err
+		}
+	}
+	lom := NewOperationManager(info, mockLogOp)
+	lom.OperationSingle(ctx)
+
+	if want := map[int64]error{logID1: nil, logID2: passErr}; !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("watched passes = %v, want %v", outcomes, want)
+	}
+}
+
 func TestOperationManagerOperationLoopPassesIDs(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -263,7 +301,9 @@ func TestOperationManagerOperationLoopExitOnContext(t *testing.T) {
 
 	mockLogOp := NewMockOperation(ctrl)
 	infoMatcher := logOpInfoMatcher{50}
+	passStarted := make(chan struct{})
 	mockLogOp.EXPECT().ExecutePass(gomock.Any(), logID1, infoMatcher).Do(func(_ context.Context, _ int64, _ *OperationInfo) {
+		close(passStarted)
 		// Wind the clock on so that we queue up a resignation while we're "working" on a signing run
 		fakeTime.Set(fakeTime.Now().Add(d * 3))
 		// Give some slack for it to take effect...
@@ -274,10 +314,19 @@ func TestOperationManagerOperationLoopExitOnContext(t *testing.T) {
 
 	lom := NewOperationManager(info, mockLogOp)
 
-	// Prompt a signing run to happen once we're running the operation loop:
+	// Prompt a signing run to happen once we're running the operation loop. The
+	// loop waits for the run interval on the fake clock, so keep winding it on
+	// until the run starts.
 	go func() {
 		time.Sleep(time.Second)
-		fakeTime.Set(fakeTime.Now().Add(info.RunInterval * 2))
+		for {
+			fakeTime.Set(fakeTime.Now().Add(info.RunInterval * 2))
+			select {
+			case <-passStarted:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
 	}()
 
 	t.Logf("Entering operationLoop")
@@ -285,6 +334,60 @@ func TestOperationManagerOperationLoopExitOnContext(t *testing.T) {
 	t.Logf("Exited operationLoop")
 }
 
+func TestOperationManagerOperationLoopWaitsOnTimeSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage, mockAdmin := setupLogIDs(ctrl, map[int64]string{451: "LogID1"})
+	registry := extension.Registry{
+		LogStorage:   fakeStorage,
+		AdminStorage: mockAdmin,
+	}
+
+	fakeTime := clock.NewFake(time.Now())
+	info := defaultOperationInfo(registry)
+	info.RunInterval = time.Hour
+	info.TimeSource = fakeTime
+
+	passes := make(chan struct{}, 2)
+	mockLogOp := NewMockOperation(ctrl)
+	mockLogOp.EXPECT().ExecutePass(gomock.Any(), int64(451), gomock.Any()).MinTimes(2).Do(func(_ context.Context, _ int64, _ *OperationInfo) {
+		select {
+		case passes <- struct{}{}:
+		default:
+		}
+	}).Return(0, nil)
+
+	lom := NewOperationManager(info, mockLogOp)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lom.OperationLoop(ctx)
+	}()
+
+	<-passes
+	select {
+	case <-passes:
+		t.Fatal("Second pass ran before the run interval passed")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// The next pass runs as soon as the run interval has passed on the fake
+	// clock, rather than an hour later.
+	for waiting := true; waiting; {
+		fakeTime.Set(fakeTime.Now().Add(info.RunInterval))
+		select {
+		case <-passes:
+			waiting = false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+}
+
 func TestOperationManagerOperationLoopExecutePassError(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -344,6 +447,149 @@ func TestOperationManagerOperationLoopExecutePassError(t *testing.T) {
 	}
 }
 
+func TestOperationManagerPriorities(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	priorities := map[int64]trillian.TreePriority{
+		1: trillian.TreePriority_LOW_PRIORITY,
+		2: trillian.TreePriority_NORMAL_PRIORITY,
+		3: trillian.TreePriority_HIGH_PRIORITY,
+		4: trillian.TreePriority_NORMAL_PRIORITY,
+	}
+	fakeStorage := storage.NewMockLogStorage(ctrl)
+	fakeStorage.EXPECT().GetActiveLogIDs(gomock.Any()).Return([]int64{1, 2, 3, 4}, nil)
+	mockAdmin := storage.NewMockAdminStorage(ctrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	for id, p := range priorities {
+		mockAdminTx.EXPECT().GetTree(gomock.Any(), id).AnyTimes().Return(&trillian.Tree{TreeId: id, Priority: p}, nil)
+	}
+	mockAdminTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockAdminTx.EXPECT().Close().AnyTimes().Return(nil)
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(mockAdminTx, nil)
+	registry := extension.Registry{LogStorage: fakeStorage, AdminStorage: mockAdmin}
+
+	// With a single worker, logs are handed over by decreasing priority, and in
+	// the listed order within the same priority.
+	mockLogOp := NewMockOperation(ctrl)
+	var calls []*gomock.Call
+	for _, id := range []int64{3, 2, 4, 1} {
+		calls = append(calls, mockLogOp.EXPECT().ExecutePass(gomock.Any(), id, gomock.Any()).Return(0, nil))
+	}
+	gomock.InOrder(calls...)
+
+	highLabel := trillian.TreePriority_HIGH_PRIORITY.String()
+	highCount, _ := schedulingDelay.Info(highLabel)
+
+	info := defaultOperationInfo(registry)
+	lom := NewOperationManager(info, mockLogOp)
+	lom.OperationSingle(ctx)
+
+	if got, _ := schedulingDelay.Info(highLabel); got != highCount+1 {
+		t.Errorf("scheduling_delay count for %s = %d, want %d", highLabel, got, highCount+1)
+	}
+}
+
+func TestOperationManagerRunsFullLogsAgain(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage := storage.NewMockLogStorage(ctrl)
+	fakeStorage.EXPECT().GetActiveLogIDs(gomock.Any()).Return([]int64{1, 2}, nil)
+	mockAdmin := storage.NewMockAdminStorage(ctrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), int64(1)).AnyTimes().Return(&trillian.Tree{TreeId: 1}, nil)
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), int64(2)).AnyTimes().Return(&trillian.Tree{TreeId: 2, SequencerBatchSize: 5}, nil)
+	mockAdminTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockAdminTx.EXPECT().Close().AnyTimes().Return(nil)
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(mockAdminTx, nil)
+	registry := extension.Registry{LogStorage: fakeStorage, AdminStorage: mockAdmin}
+
+	// Logs whose run processes a full batch, using the batch size of their
+	// tree if set, are run again in the same pass.
+	mockLogOp := NewMockOperation(ctrl)
+	gomock.InOrder(
+		mockLogOp.EXPECT().ExecutePass(gomock.Any(), int64(1), gomock.Any()).Return(5, nil),
+		mockLogOp.EXPECT().ExecutePass(gomock.Any(), int64(2), gomock.Any()).Return(5, nil),
+		mockLogOp.EXPECT().ExecutePass(gomock.Any(), int64(2), gomock.Any()).Return(2, nil),
+	)
+
+	info := defaultOperationInfo(registry)
+	lom := NewOperationManager(info, mockLogOp)
+	lom.OperationSingle(ctx)
+}
+
+func TestOperationManagerAffinity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	trees := map[int64]*trillian.Tree{
+		1: {TreeId: 1},
+		2: {TreeId: 2, SignerAffinity: []string{"a", "b"}},
+		3: {TreeId: 3, SignerAffinity: []string{"c"}},
+		4: {TreeId: 4, AntiAffinityGroup: "hot"},
+		5: {TreeId: 5, AntiAffinityGroup: "hot"},
+	}
+	mockAdmin := storage.NewMockAdminStorage(ctrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	for id, tree := range trees {
+		mockAdminTx.EXPECT().GetTree(gomock.Any(), id).AnyTimes().Return(tree, nil)
+	}
+	mockAdminTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockAdminTx.EXPECT().Close().AnyTimes().Return(nil)
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(mockAdminTx, nil)
+
+	info := OperationInfo{
+		Registry:          extension.Registry{AdminStorage: mockAdmin, ElectionFactory: election2.NoopFactory{}},
+		TimeSource:        clock.System,
+		Affinity:          true,
+		InstanceLabels:    []string{"b"},
+		AntiAffinityDelay: time.Minute,
+	}
+	lom := NewOperationManager(info, nil)
+
+	// Check mastership twice, to give the election threads a chance to get started and report.
+	allIDs := []int64{1, 2, 3, 4}
+	lom.masterFor(ctx, allIDs)
+	time.Sleep(100 * time.Millisecond)
+	logIDs, err := lom.masterFor(ctx, allIDs)
+	if want := []int64{1, 2, 4}; err != nil || !reflect.DeepEqual(logIDs, want) {
+		t.Errorf("masterFor()=%v,%v; want %v,nil", logIDs, err, want)
+	}
+	if _, ok := lom.runnerCancels["3"]; ok {
+		t.Error("masterFor() started an election for a log without affinity")
+	}
+
+	// Log 5 is delayed, as log 4 of the same anti-affinity group is held.
+	lom.logTree(ctx, 5)
+	for _, tc := range []struct {
+		logID string
+		want  time.Duration
+	}{
+		{logID: "1", want: 0},
+		{logID: "4", want: 0},
+		{logID: "5", want: time.Minute},
+	} {
+		if got := lom.campaignDelay(tc.logID); got != tc.want {
+			t.Errorf("campaignDelay(%s)=%v, want %v", tc.logID, got, tc.want)
+		}
+	}
+
+	// Elections are stopped for logs which lose their affinity.
+	trees[2].SignerAffinity = []string{"c"}
+	delete(lom.trees, 2)
+	if logIDs, err := lom.masterFor(ctx, allIDs); err != nil || !reflect.DeepEqual(logIDs, []int64{1, 4}) {
+		t.Errorf("masterFor()=%v,%v; want [1 4],nil", logIDs, err)
+	}
+	if _, ok := lom.runnerCancels["2"]; ok {
+		t.Error("masterFor() kept the election of a log which lost its affinity")
+	}
+}
+
 func TestHeldInfo(t *testing.T) {
 	ctx := context.Background()
 	ctrl := gomock.NewController(t)
@@ -423,6 +669,171 @@ func TestMasterFor(t *testing.T) {
 	}
 }
 
+func TestMastershipServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	info := OperationInfo{
+		Registry:   extension.Registry{ElectionFactory: masterForEvenFactory{}},
+		TimeSource: clock.System,
+	}
+	lom := NewOperationManager(info, nil)
+	acquired := testonly.NewCounterSnapshot(masterAcquired, "2")
+
+	lom.masterFor(ctx, []int64{1, 2})
+	time.Sleep(100 * time.Millisecond)
+	resp, err := lom.MastershipServer("self").GetMastership(ctx, &electionpb.GetMastershipRequest{})
+	if err != nil {
+		t.Fatalf("GetMastership()=_,%v", err)
+	}
+	if got := len(resp.Resources); got != 2 {
+		t.Fatalf("GetMastership() returned %d resources, want 2", got)
+	}
+	// Only log 2 is held, after a single transition.
+	for i, want := range []bool{false, true} {
+		r := resp.Resources[i]
+		wantTransitions := 0
+		if want {
+			wantTransitions = 1
+		}
+		if r.IsMaster != want || (r.MasterSince != nil) != want || len(r.Transitions) != wantTransitions {
+			t.Errorf("GetMastership() returned %v for log %d, want is_master=%v", r, i+1, want)
+		}
+	}
+	if got := acquired.Delta(); got != 1 {
+		t.Errorf("master_acquisitions delta=%v, want 1", got)
+	}
+}
+
+func TestOperationManagerShutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage, mockAdmin := setupLogIDs(ctrl, map[int64]string{1: "LogID1", 2: "LogID2"})
+	factory := &closingFactory{Factory: masterForEvenFactory{}, closed: make(map[string]bool)}
+	registry := extension.Registry{
+		LogStorage:      fakeStorage,
+		AdminStorage:    mockAdmin,
+		ElectionFactory: factory,
+	}
+
+	// The pass on log 2, which this instance is master for, is in progress
+	// when shutting down, and completes without being canceled.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	mockLogOp := NewMockOperation(ctrl)
+	mockLogOp.EXPECT().ExecutePass(gomock.Any(), int64(2), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, _ int64, _ *OperationInfo) (int, error) {
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			close(started)
+			<-release
+			if err := ctx.Err(); err != nil {
+				t.Errorf("ExecutePass() context done during shutdown: %v", err)
+			}
+		}
+		return 1, nil
+	})
+
+	info := defaultOperationInfo(registry)
+	info.RunInterval = 10 * time.Millisecond
+	info.TimeSource = clock.System
+	lom := NewOperationManager(info, mockLogOp)
+	go lom.OperationLoop(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	shutdown := make(chan error)
+	go func() { shutdown <- lom.Shutdown(ctx) }()
+
+	// The election of log 1 is stopped first, while the pass is in progress.
+	for !factory.isClosed("1") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if factory.isClosed("2") {
+		t.Error("Shutdown() closed the election of log 2 before the pass finished")
+	}
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown()=%v, want nil", err)
+	}
+	if !factory.isClosed("2") {
+		t.Error("Shutdown() didn't close the election of log 2")
+	}
+}
+
+func TestOperationManagerShutdownTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage, mockAdmin := setupLogIDs(ctrl, map[int64]string{1: "LogID1"})
+	registry := extension.Registry{
+		LogStorage:   fakeStorage,
+		AdminStorage: mockAdmin,
+	}
+
+	// The pass doesn't finish before the grace period, so it's aborted.
+	started := make(chan struct{})
+	mockLogOp := NewMockOperation(ctrl)
+	mockLogOp.EXPECT().ExecutePass(gomock.Any(), int64(1), gomock.Any()).DoAndReturn(func(ctx context.Context, _ int64, _ *OperationInfo) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	info := defaultOperationInfo(registry)
+	info.TimeSource = clock.System
+	lom := NewOperationManager(info, mockLogOp)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lom.OperationLoop(context.Background())
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := lom.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown()=%v, want %v", err, context.DeadlineExceeded)
+	}
+	<-done
+}
+
+// closingFactory records which of the elections it creates are closed.
+type closingFactory struct {
+	election2.Factory
+	mu     sync.Mutex
+	closed map[string]bool
+}
+
+func (f *closingFactory) NewElection(ctx context.Context, treeID string) (election2.Election, error) {
+	e, err := f.Factory.NewElection(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return &closingElection{Election: e, f: f, id: treeID}, nil
+}
+
+func (f *closingFactory) isClosed(treeID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed[treeID]
+}
+
+type closingElection struct {
+	election2.Election
+	f  *closingFactory
+	id string
+}
+
+func (e *closingElection) Close(ctx context.Context) error {
+	e.f.mu.Lock()
+	e.f.closed[e.id] = true
+	e.f.mu.Unlock()
+	return e.Election.Close(ctx)
+}
+
 type alwaysMasterFactory struct{}
 
 func (m alwaysMasterFactory) NewElection(ctx context.Context, treeID string) (election2.Election, error) {
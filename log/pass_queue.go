@@ -0,0 +1,118 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+)
+
+// passQueue hands the logs of a pass over to a pool of workers, which take the
+// next log to run as soon as they are done with the previous one.
+//
+// Each log is run once, in the order given, before any log is run again, so
+// that busy logs can't hold up the others. Logs whose run may have left work
+// behind are then run again in the order they became due, while the deadline
+// of the pass hasn't passed, and no more than maxPerTree at a time.
+type passQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// first holds the logs which haven't been run yet in this pass.
+	first []int64
+	// again holds the logs to run again. A log is listed as many times as it
+	// may be run concurrently.
+	again []int64
+	// queued is the number of times each log is listed in again.
+	queued map[int64]int
+	// running is the number of runs in progress of each log.
+	running map[int64]int
+	// inFlight is the number of runs in progress over all logs.
+	inFlight int
+
+	maxPerTree int
+	deadline   time.Time
+	timeSource clock.TimeSource
+}
+
+// newPassQueue creates a passQueue for the given logs, which are run again
+// until the deadline, by at most maxPerTree workers at a time.
+func newPassQueue(logIDs []int64, maxPerTree int, deadline time.Time, ts clock.TimeSource) *passQueue {
+	q := &passQueue{
+		first:      logIDs,
+		queued:     make(map[int64]int),
+		running:    make(map[int64]int),
+		maxPerTree: maxPerTree,
+		deadline:   deadline,
+		timeSource: ts,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// next returns the next log to run, and whether this is its first run in the
+// pass. It blocks while there is no log to run but runs in progress may add
+// some, and returns false once the pass is over or ctx is done. Each log
+// returned must be passed to done once its run is over.
+func (q *passQueue) next(ctx context.Context) (logID int64, first, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ctx.Err() == nil {
+		if len(q.first) > 0 {
+			logID, q.first = q.first[0], q.first[1:]
+			q.start(logID)
+			return logID, true, true
+		}
+		if len(q.again) > 0 && q.timeSource.Now().Before(q.deadline) {
+			for i, id := range q.again {
+				if q.running[id] < q.maxPerTree {
+					q.again = append(q.again[:i], q.again[i+1:]...)
+					q.queued[id]--
+					q.start(id)
+					return id, false, true
+				}
+			}
+		}
+		if q.inFlight == 0 {
+			break
+		}
+		q.cond.Wait()
+	}
+	return 0, false, false
+}
+
+// start records the start of a run of the given log. It must be called with
+// mu held.
+func (q *passQueue) start(logID int64) {
+	q.running[logID]++
+	q.inFlight++
+}
+
+// done records the end of a run of the given log. If more is set, the run may
+// have left work behind, and the log is queued to run again.
+func (q *passQueue) done(logID int64, more bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running[logID]--
+	q.inFlight--
+	for more && q.running[logID]+q.queued[logID] < q.maxPerTree {
+		q.again = append(q.again, logID)
+		q.queued[logID]++
+	}
+	q.cond.Broadcast()
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+)
+
+// fakeOperation processes a full batch in the first full[logID] runs of each
+// log, and nothing afterwards. It records the order of the runs, and the
+// maximum number of concurrent runs of a log.
+type fakeOperation struct {
+	mu         sync.Mutex
+	full       map[int64]int
+	runs       []int64
+	running    map[int64]int
+	maxRunning int
+	delay      time.Duration
+	onRun      func()
+}
+
+func (f *fakeOperation) ExecutePass(ctx context.Context, logID int64, info *OperationInfo) (int, error) {
+	f.mu.Lock()
+	f.runs = append(f.runs, logID)
+	f.running[logID]++
+	if f.running[logID] > f.maxRunning {
+		f.maxRunning = f.running[logID]
+	}
+	count := 0
+	if f.full[logID] > 0 {
+		f.full[logID]--
+		count = info.BatchSize
+	}
+	f.mu.Unlock()
+
+	if f.onRun != nil {
+		f.onRun()
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[logID]--
+	return count, nil
+}
+
+func TestExecutePassForAllRunsLogsAgain(t *testing.T) {
+	once.Do(func() { createMetrics(nil) })
+	ctx := context.Background()
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc        string
+		workers     int
+		maxPerTree  int
+		batchSizes  map[int64]int
+		full        map[int64]int
+		expire      bool
+		wantRuns    []int64
+		wantCounts  map[int64]int
+		wantMaxRuns int
+	}{
+		{
+			desc:       "round-robin",
+			workers:    1,
+			batchSizes: map[int64]int{1: 10, 2: 10, 3: 10},
+			full:       map[int64]int{1: 3, 3: 1},
+			// Each log is run once before full logs take turns.
+			wantRuns: []int64{1, 2, 3, 1, 3, 1, 1},
+		},
+		{
+			desc:       "no-batch-size",
+			workers:    1,
+			batchSizes: map[int64]int{1: 0, 2: 10},
+			full:       map[int64]int{1: 3, 2: 1},
+			wantRuns:   []int64{1, 2, 2},
+		},
+		{
+			desc:       "deadline",
+			workers:    1,
+			batchSizes: map[int64]int{1: 10, 2: 10},
+			full:       map[int64]int{1: 3, 2: 3},
+			expire:     true,
+			wantRuns:   []int64{1, 2},
+		},
+		{
+			desc:        "one-run-per-tree",
+			workers:     4,
+			batchSizes:  map[int64]int{1: 10, 2: 10},
+			full:        map[int64]int{1: 20},
+			wantCounts:  map[int64]int{1: 21, 2: 1},
+			wantMaxRuns: 1,
+		},
+		{
+			desc:        "runs-per-tree",
+			workers:     4,
+			maxPerTree:  3,
+			batchSizes:  map[int64]int{1: 10, 2: 10},
+			full:        map[int64]int{1: 20},
+			wantMaxRuns: 3,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ts := clock.NewFake(start)
+			op := &fakeOperation{full: tc.full, running: make(map[int64]int)}
+			if tc.workers > 1 {
+				op.delay = time.Millisecond
+			}
+			if tc.expire {
+				op.onRun = func() { ts.Set(start.Add(time.Hour)) }
+			}
+			info := &OperationInfo{
+				BatchSize:      10,
+				NumWorkers:     tc.workers,
+				MaxRunsPerTree: tc.maxPerTree,
+				RunInterval:    time.Minute,
+				TimeSource:     ts,
+			}
+			var logIDs []int64
+			schedules := make(map[int64]logSchedule)
+			for _, id := range []int64{1, 2, 3} {
+				if size, ok := tc.batchSizes[id]; ok {
+					logIDs = append(logIDs, id)
+					schedules[id] = logSchedule{batchSize: size}
+				}
+			}
+
+			executePassForAll(ctx, info, op, logIDs, schedules)
+
+			if tc.wantRuns != nil && !reflect.DeepEqual(op.runs, tc.wantRuns) {
+				t.Errorf("runs = %v, want %v", op.runs, tc.wantRuns)
+			}
+			if tc.wantCounts != nil {
+				counts := make(map[int64]int)
+				for _, id := range op.runs {
+					counts[id]++
+				}
+				if !reflect.DeepEqual(counts, tc.wantCounts) {
+					t.Errorf("run counts = %v, want %v", counts, tc.wantCounts)
+				}
+			}
+			if !tc.expire {
+				for id, n := range op.full {
+					if n > 0 && tc.batchSizes[id] > 0 {
+						t.Errorf("log %d has %d full batches left", id, n)
+					}
+				}
+			}
+			if tc.wantMaxRuns > 0 && op.maxRunning > tc.wantMaxRuns {
+				t.Errorf("max concurrent runs of a log = %d, want at most %d", op.maxRunning, tc.wantMaxRuns)
+			}
+		})
+	}
+}
+
+func TestPassQueueCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := newPassQueue([]int64{1, 2}, 1, time.Now().Add(time.Hour), clock.System)
+	if id, first, ok := q.next(ctx); !ok || !first || id != 1 {
+		t.Fatalf("next() = %d, %v, %v, want 1, true, true", id, first, ok)
+	}
+	cancel()
+	if _, _, ok := q.next(ctx); ok {
+		t.Error("next() after cancel returned a log")
+	}
+	q.done(1, true)
+	if _, _, ok := q.next(ctx); ok {
+		t.Error("next() after cancel returned a log to run again")
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// DefaultScrubBatchSize is the number of leaves read per storage transaction
+// when scrubbing, if not specified otherwise.
+const DefaultScrubBatchSize = 1000
+
+var (
+	scrubOnce         sync.Once
+	scrubRuns         monitoring.Counter
+	scrubFailures     monitoring.Counter
+	scrubLeaves       monitoring.Counter
+	scrubCorruptions  monitoring.Counter
+	scrubLastTreeSize monitoring.Gauge
+)
+
+// InitScrubMetrics sets up the metrics reported by ScrubTree. Can be called
+// more than once, but only the first call has any effect. If it isn't called
+// before ScrubTree, the metrics are not exported.
+func InitScrubMetrics(mf monitoring.MetricFactory) {
+	scrubOnce.Do(func() {
+		if mf == nil {
+			mf = monitoring.InertMetricFactory{}
+		}
+		scrubRuns = mf.NewCounter("scrub_runs", "Number of completed scrubs of a tree", logIDLabel)
+		scrubFailures = mf.NewCounter("scrub_failures", "Number of scrubs of a tree that failed to complete", logIDLabel)
+		scrubLeaves = mf.NewCounter("scrub_leaves", "Number of leaves checked by scrubbing", logIDLabel)
+		scrubCorruptions = mf.NewCounter("scrub_corruptions", "Number of corruptions found by scrubbing", logIDLabel, "kind")
+		scrubLastTreeSize = mf.NewGauge("scrub_last_tree_size", "Tree size verified by the last completed scrub", logIDLabel)
+	})
+}
+
+// ScrubOptions controls how a tree is scrubbed.
+type ScrubOptions struct {
+	// BatchSize is the maximum number of leaves read per storage transaction.
+	// DefaultScrubBatchSize is used if it is zero.
+	BatchSize int
+	// Pause is the time to wait between batches, which limits the load that
+	// scrubbing puts on the storage.
+	Pause time.Duration
+	// MaxLeavesPerSecond, if positive, is the maximum rate at which leaves are
+	// checked. The scrub of a tree waits after each batch, including the last
+	// one, so that the rate holds across the trees scrubbed in turn.
+	MaxLeavesPerSecond int
+	// MaxCorruptions is the maximum number of corruptions kept in the
+	// ScrubReport. All corruptions are counted regardless.
+	MaxCorruptions int
+	// TimeSource is used for pausing between batches. The system clock is used
+	// if it is nil.
+	TimeSource clock.TimeSource
+}
+
+// ScrubReport is the outcome of scrubbing a tree.
+type ScrubReport struct {
+	// TreeSize is the size of the signed root the tree was verified against.
+	TreeSize uint64
+	// Leaves is the number of leaves checked.
+	Leaves uint64
+	// Nodes is the number of stored Merkle nodes checked.
+	Nodes uint64
+	// Corruptions holds up to ScrubOptions.MaxCorruptions corruptions found.
+	Corruptions []*trillian.TreeCorruption
+	// CorruptionCount is the total number of corruptions found.
+	CorruptionCount uint64
+}
+
+func (r *ScrubReport) add(opts ScrubOptions, label string, kind trillian.TreeCorruption_Kind, id compact.NodeID, format string, args ...interface{}) {
+	r.CorruptionCount++
+	scrubCorruptions.Inc(label, kind.String())
+	desc := fmt.Sprintf(format, args...)
+	glog.Errorf("%s: scrub found %v at node (%d, %d): %s", label, kind, id.Level, id.Index, desc)
+	if opts.MaxCorruptions > 0 && len(r.Corruptions) >= opts.MaxCorruptions {
+		return
+	}
+	r.Corruptions = append(r.Corruptions, &trillian.TreeCorruption{
+		Kind:        kind,
+		Level:       int32(id.Level),
+		Index:       int64(id.Index),
+		Description: desc,
+	})
+}
+
+// ScrubTree re-verifies the stored data of a log tree. It reads all leaves
+// covered by the latest signed root, recomputes their Merkle leaf hashes and
+// the internal node hashes, and compares them with the stored hashes and the
+// signed root hash.
+//
+// Leaves are read in batches, each in its own snapshot transaction. Corruption
+// is reported in the returned ScrubReport, and an error is returned only if
+// the scrub could not be completed, e.g. because storage is unavailable.
+func ScrubTree(ctx context.Context, tree *trillian.Tree, ls storage.ReadOnlyLogStorage, opts ScrubOptions) (*ScrubReport, error) {
+	InitScrubMetrics(nil)
+	label := strconv.FormatInt(tree.TreeId, 10)
+	report, err := scrubTree(ctx, tree, ls, opts, label)
+	if err != nil {
+		scrubFailures.Inc(label)
+		return nil, err
+	}
+	scrubRuns.Inc(label)
+	scrubLastTreeSize.Set(float64(report.TreeSize), label)
+	return report, nil
+}
+
+func scrubTree(ctx context.Context, tree *trillian.Tree, ls storage.ReadOnlyLogStorage, opts ScrubOptions, label string) (*ScrubReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultScrubBatchSize
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	hasher := rfc6962.DefaultHasher
+
+	var root types.LogRootV1
+	if err := runSnapshot(ctx, ls, tree, func(tx storage.ReadOnlyLogTreeTX) error {
+		slr, err := tx.LatestSignedLogRoot(ctx)
+		if err != nil {
+			return err
+		}
+		return root.UnmarshalBinary(slr.LogRoot)
+	}); err != nil {
+		return nil, fmt.Errorf("%v: failed to read latest root: %v", tree.TreeId, err)
+	}
+
+	report := &ScrubReport{TreeSize: root.TreeSize}
+	started := opts.TimeSource.Now()
+	cr := (&compact.RangeFactory{Hash: hasher.HashChildren}).NewEmptyRange(0)
+	for cr.End() < root.TreeSize {
+		if cr.End() > 0 && opts.Pause > 0 {
+			if err := clock.SleepSource(ctx, opts.Pause, opts.TimeSource); err != nil {
+				return nil, err
+			}
+		}
+		start := cr.End()
+		count := root.TreeSize - start
+		if count > uint64(opts.BatchSize) {
+			count = uint64(opts.BatchSize)
+		}
+		if err := runSnapshot(ctx, ls, tree, func(tx storage.ReadOnlyLogTreeTX) error {
+			return scrubBatch(ctx, tx, cr, count, report, opts, label)
+		}); err != nil {
+			return nil, fmt.Errorf("%v: failed to scrub leaves [%d, %d): %v", tree.TreeId, start, start+count, err)
+		}
+		if cr.End() == start {
+			// The remaining leaves are missing, the root can't be verified.
+			return report, nil
+		}
+		if opts.MaxLeavesPerSecond > 0 {
+			due := started.Add(time.Duration(cr.End()) * time.Second / time.Duration(opts.MaxLeavesPerSecond))
+			if wait := due.Sub(opts.TimeSource.Now()); wait > 0 {
+				if err := clock.SleepSource(ctx, wait, opts.TimeSource); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	hash, err := cr.GetRootHash(nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: failed to compute root hash: %v", tree.TreeId, err)
+	}
+	if root.TreeSize == 0 {
+		hash = hasher.EmptyRoot()
+	}
+	if !bytes.Equal(hash, root.RootHash) {
+		report.add(opts, label, trillian.TreeCorruption_ROOT_HASH_MISMATCH, compact.NewNodeID(0, 0),
+			"root hash at size %d is %x, signed root has %x", root.TreeSize, hash, root.RootHash)
+	}
+	return report, nil
+}
+
+// scrubBatch checks up to count leaves following the end of cr, and the Merkle
+// nodes they complete, and appends these leaves to cr. On a missing leaf cr is
+// left at the preceding leaf.
+func scrubBatch(ctx context.Context, tx storage.ReadOnlyLogTreeTX, cr *compact.Range, count uint64, report *ScrubReport, opts ScrubOptions, label string) error {
+	start := cr.End()
+	leaves, err := tx.GetLeavesByRange(ctx, int64(start), int64(count))
+	if err != nil {
+		return err
+	}
+
+	var ids []compact.NodeID
+	var hashes [][]byte
+	visit := func(id compact.NodeID, hash []byte) {
+		ids = append(ids, id)
+		hashes = append(hashes, hash)
+	}
+	for i, leaf := range leaves {
+		idx := start + uint64(i)
+		if leaf.LeafIndex != int64(idx) {
+			break
+		}
+		hash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue)
+		if !bytes.Equal(hash, leaf.MerkleLeafHash) {
+			report.add(opts, label, trillian.TreeCorruption_LEAF_HASH_MISMATCH, compact.NewNodeID(0, idx),
+				"leaf value hashes to %x, stored Merkle leaf hash is %x", hash, leaf.MerkleLeafHash)
+		}
+		// Continue with the recomputed hash, so that the corruption is also
+		// reflected in the nodes and root that commit to this leaf.
+		if err := cr.Append(hash, visit); err != nil {
+			return err
+		}
+		report.Leaves++
+	}
+	scrubLeaves.Add(float64(cr.End()-start), label)
+	if cr.End() < start+count {
+		report.add(opts, label, trillian.TreeCorruption_MISSING_LEAF, compact.NewNodeID(0, cr.End()),
+			"leaf %d is missing", cr.End())
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	nodes, err := tx.GetMerkleNodes(ctx, ids)
+	if err != nil {
+		return err
+	}
+	stored := make(map[compact.NodeID][]byte, len(nodes))
+	for _, n := range nodes {
+		stored[n.ID] = n.Hash
+	}
+	for i, id := range ids {
+		got, ok := stored[id]
+		switch {
+		case !ok:
+			report.add(opts, label, trillian.TreeCorruption_MISSING_NODE, id, "node is missing")
+		case !bytes.Equal(got, hashes[i]):
+			report.add(opts, label, trillian.TreeCorruption_NODE_HASH_MISMATCH, id,
+				"stored hash is %x, recomputed %x", got, hashes[i])
+		}
+	}
+	report.Nodes += uint64(len(ids))
+	return nil
+}
+
+func runSnapshot(ctx context.Context, ls storage.ReadOnlyLogStorage, tree *trillian.Tree, f func(storage.ReadOnlyLogTreeTX) error) error {
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Scrubber periodically scrubs all active log trees in the background.
+type Scrubber struct {
+	// Admin is used to look up the trees to scrub.
+	Admin storage.AdminStorage
+	// Log is the storage the trees are scrubbed in.
+	Log storage.LogStorage
+	// Interval is the time between the starts of two scrubbing passes over
+	// all trees.
+	Interval time.Duration
+	// Options are applied to the scrub of each tree.
+	Options ScrubOptions
+	// IsMaster reports whether this instance is master for a log, so that
+	// each log is scrubbed by a single instance. All logs are scrubbed if it
+	// is nil.
+	IsMaster func(logID int64) bool
+}
+
+// NewScrubber returns a Scrubber which registers its metrics with mf.
+func NewScrubber(admin storage.AdminStorage, ls storage.LogStorage, interval time.Duration, opts ScrubOptions, mf monitoring.MetricFactory) *Scrubber {
+	InitScrubMetrics(mf)
+	return &Scrubber{Admin: admin, Log: ls, Interval: interval, Options: opts}
+}
+
+// Run scrubs all active trees every Interval until ctx is done.
+func (s *Scrubber) Run(ctx context.Context) {
+	ts := s.Options.TimeSource
+	if ts == nil {
+		ts = clock.System
+	}
+	for {
+		start := ts.Now()
+		s.scrubAll(ctx)
+		wait := s.Interval - ts.Now().Sub(start)
+		if wait < 0 {
+			wait = 0
+		}
+		if err := clock.SleepSource(ctx, wait, ts); err != nil {
+			glog.Infof("Scrubber stopping: %v", err)
+			return
+		}
+	}
+}
+
+// scrubAll scrubs the active trees, and returns the number of trees scrubbed.
+func (s *Scrubber) scrubAll(ctx context.Context) int {
+	ids, err := s.Log.GetActiveLogIDs(ctx)
+	if err != nil {
+		glog.Warningf("Scrubber failed to list active logs: %v", err)
+		return 0
+	}
+	scrubbed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return scrubbed
+		}
+		if s.IsMaster != nil && !s.IsMaster(id) {
+			continue
+		}
+		tree, err := storage.GetTree(ctx, s.Admin, id)
+		if err != nil {
+			glog.Warningf("%v: scrubber failed to get tree: %v", id, err)
+			continue
+		}
+		report, err := ScrubTree(ctx, tree, s.Log, s.Options)
+		if err != nil {
+			glog.Warningf("%v: scrub failed: %v", id, err)
+			continue
+		}
+		scrubbed++
+		glog.Infof("%v: scrubbed %d leaves and %d nodes at size %d, found %d corruptions", id, report.Leaves, report.Nodes, report.TreeSize, report.CorruptionCount)
+	}
+	return scrubbed
+}
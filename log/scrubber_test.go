@@ -0,0 +1,198 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// newScrubTestTree creates an initialised PREORDERED_LOG tree with the given
+// leaves integrated. Leaf i gets the Merkle leaf hash returned by hash(i, value).
+func newScrubTestTree(ctx context.Context, t *testing.T, ls storage.LogStorage, as storage.AdminStorage, size int, hash func(int, []byte) []byte) *trillian.Tree {
+	t.Helper()
+	tree, err := storage.CreateTree(ctx, as, &trillian.Tree{
+		TreeType:        trillian.TreeType_PREORDERED_LOG,
+		TreeState:       trillian.TreeState_ACTIVE,
+		MaxRootDuration: durationpb.New(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateTree: %v", err)
+	}
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		root, err := (&types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot()}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	leaves := make([]*trillian.LogLeaf, 0, size)
+	for i := 0; i < size; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		id := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafValue:        value,
+			LeafIdentityHash: id[:],
+			MerkleLeafHash:   hash(i, value),
+			LeafIndex:        int64(i),
+		})
+	}
+	if _, err := ls.AddSequencedLeaves(ctx, tree, leaves, time.Now()); err != nil {
+		t.Fatalf("AddSequencedLeaves: %v", err)
+	}
+	if _, err := IntegrateBatch(ctx, tree, size, 0, 0, clock.System, ls, quota.Noop()); err != nil {
+		t.Fatalf("IntegrateBatch: %v", err)
+	}
+	return tree
+}
+
+func TestScrubTree(t *testing.T) {
+	ctx := context.Background()
+	InitMetrics(nil)
+	goodHash := func(_ int, value []byte) []byte { return rfc6962.DefaultHasher.HashLeaf(value) }
+
+	for _, tc := range []struct {
+		desc       string
+		size       int
+		batchSize  int
+		hash       func(int, []byte) []byte
+		wantKinds  []trillian.TreeCorruption_Kind
+		wantLeaves uint64
+	}{
+		{desc: "empty", size: 0, batchSize: 3, hash: goodHash},
+		{desc: "single-batch", size: 5, batchSize: 10, hash: goodHash, wantLeaves: 5},
+		{desc: "many-batches", size: 37, batchSize: 4, hash: goodHash, wantLeaves: 37},
+		{
+			desc:      "bad-leaf-hash",
+			size:      10,
+			batchSize: 3,
+			hash: func(i int, value []byte) []byte {
+				if i == 6 {
+					return rfc6962.DefaultHasher.HashLeaf([]byte("other"))
+				}
+				return rfc6962.DefaultHasher.HashLeaf(value)
+			},
+			// The stored nodes and the root commit to the bad leaf hash, so
+			// all nodes on the path from leaf 6 mismatch as well as the root.
+			wantKinds: []trillian.TreeCorruption_Kind{
+				trillian.TreeCorruption_LEAF_HASH_MISMATCH,
+				trillian.TreeCorruption_NODE_HASH_MISMATCH,
+				trillian.TreeCorruption_NODE_HASH_MISMATCH,
+				trillian.TreeCorruption_NODE_HASH_MISMATCH,
+				trillian.TreeCorruption_NODE_HASH_MISMATCH,
+				trillian.TreeCorruption_ROOT_HASH_MISMATCH,
+			},
+			wantLeaves: 10,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ts := memory.NewTreeStorage()
+			ls, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+			tree := newScrubTestTree(ctx, t, ls, as, tc.size, tc.hash)
+
+			report, err := ScrubTree(ctx, tree, ls, ScrubOptions{BatchSize: tc.batchSize})
+			if err != nil {
+				t.Fatalf("ScrubTree: %v", err)
+			}
+			if got, want := report.TreeSize, uint64(tc.size); got != want {
+				t.Errorf("TreeSize=%d, want %d", got, want)
+			}
+			if got, want := report.Leaves, tc.wantLeaves; got != want {
+				t.Errorf("Leaves=%d, want %d", got, want)
+			}
+			var gotKinds []trillian.TreeCorruption_Kind
+			for _, c := range report.Corruptions {
+				gotKinds = append(gotKinds, c.Kind)
+			}
+			if fmt.Sprint(gotKinds) != fmt.Sprint(tc.wantKinds) {
+				t.Errorf("corruption kinds=%v, want %v", gotKinds, tc.wantKinds)
+			}
+			if got, want := report.CorruptionCount, uint64(len(tc.wantKinds)); got != want {
+				t.Errorf("CorruptionCount=%d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestScrubTreeMaxCorruptions(t *testing.T) {
+	ctx := context.Background()
+	InitMetrics(nil)
+	ts := memory.NewTreeStorage()
+	ls, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	tree := newScrubTestTree(ctx, t, ls, as, 8, func(i int, _ []byte) []byte {
+		return rfc6962.DefaultHasher.HashLeaf([]byte("wrong"))
+	})
+
+	report, err := ScrubTree(ctx, tree, ls, ScrubOptions{MaxCorruptions: 3})
+	if err != nil {
+		t.Fatalf("ScrubTree: %v", err)
+	}
+	if got, want := len(report.Corruptions), 3; got != want {
+		t.Errorf("len(Corruptions)=%d, want %d", got, want)
+	}
+	if got := report.CorruptionCount; got <= 3 {
+		t.Errorf("CorruptionCount=%d, want > 3", got)
+	}
+}
+
+func TestScrubTreeMaxLeavesPerSecond(t *testing.T) {
+	ctx := context.Background()
+	InitMetrics(nil)
+	ts := memory.NewTreeStorage()
+	ls, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	tree := newScrubTestTree(ctx, t, ls, as, 20, func(_ int, value []byte) []byte { return rfc6962.DefaultHasher.HashLeaf(value) })
+
+	start := time.Now()
+	if _, err := ScrubTree(ctx, tree, ls, ScrubOptions{BatchSize: 5, MaxLeavesPerSecond: 200}); err != nil {
+		t.Fatalf("ScrubTree: %v", err)
+	}
+	if got, want := time.Since(start), 100*time.Millisecond; got < want {
+		t.Errorf("ScrubTree of 20 leaves at 200 leaves/s took %v, want at least %v", got, want)
+	}
+}
+
+func TestScrubberMastership(t *testing.T) {
+	ctx := context.Background()
+	InitMetrics(nil)
+	ts := memory.NewTreeStorage()
+	ls, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	newScrubTestTree(ctx, t, ls, as, 3, func(_ int, value []byte) []byte { return rfc6962.DefaultHasher.HashLeaf(value) })
+
+	s := NewScrubber(as, ls, time.Hour, ScrubOptions{}, nil /* mf */)
+	master := false
+	s.IsMaster = func(logID int64) bool { return master }
+	if got := s.scrubAll(ctx); got != 0 {
+		t.Errorf("scrubAll() without mastership scrubbed %d trees, want 0", got)
+	}
+	master = true
+	if got := s.scrubAll(ctx); got != 1 {
+		t.Errorf("scrubAll() with mastership scrubbed %d trees, want 1", got)
+	}
+}
@@ -33,6 +33,8 @@ import (
 	"github.com/google/trillian/util/clock"
 	"github.com/transparency-dev/merkle/compact"
 	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -53,6 +55,8 @@ var (
 	seqCounter             monitoring.Counter
 	seqMergeDelay          monitoring.Histogram
 	seqTimestamp           monitoring.Gauge
+	seqUnsequenced         monitoring.Gauge
+	seqOldestUnsequenced   monitoring.Gauge
 
 	// QuotaIncreaseFactor is the multiplier used for the number of tokens added back to
 	// sequencing-based quotas. The resulting PutTokens call is equivalent to
@@ -98,6 +102,8 @@ func InitMetrics(mf monitoring.MetricFactory) {
 		seqStoreRootLatency = mf.NewHistogram("sequencer_latency_store_root", "Latency of store-root part of sequencer batch operation in seconds", logIDLabel)
 		seqCounter = mf.NewCounter("sequencer_sequenced", "Number of leaves sequenced", logIDLabel)
 		seqMergeDelay = mf.NewHistogram("sequencer_merge_delay", "Delay between queuing and integration of leaves", logIDLabel)
+		seqUnsequenced = mf.NewGauge("sequencer_unsequenced_leaves", "Number of queued leaves not yet sequenced after the last batch", logIDLabel)
+		seqOldestUnsequenced = mf.NewGauge("sequencer_oldest_unsequenced_age", "Age in seconds of the oldest queued leaf not yet sequenced after the last batch", logIDLabel)
 	})
 }
 
@@ -274,6 +280,13 @@ func (s *preorderedLogSequencingTask) update(ctx context.Context, leaves []*tril
 // IntegrateBatch wraps up all the operations needed to take a batch of queued
 // or sequenced leaves and integrate them into the tree.
 func IntegrateBatch(ctx context.Context, tree *trillian.Tree, limit int, guardWindow, maxRootDurationInterval time.Duration, ts clock.TimeSource, ls storage.LogStorage, qm quota.Manager) (int, error) {
+	n, _, err := integrateBatch(ctx, tree, limit, guardWindow, maxRootDurationInterval, ts, ls, qm)
+	return n, err
+}
+
+// integrateBatch is IntegrateBatch, which also returns the new root of the
+// tree, or nil if none was stored.
+func integrateBatch(ctx context.Context, tree *trillian.Tree, limit int, guardWindow, maxRootDurationInterval time.Duration, ts clock.TimeSource, ls storage.LogStorage, qm quota.Manager) (int, *types.LogRootV1, error) {
 	start := ts.Now()
 	label := strconv.FormatInt(tree.TreeId, 10)
 
@@ -409,17 +422,50 @@ func IntegrateBatch(ctx context.Context, tree *trillian.Tree, limit int, guardWi
 		return nil
 	})
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	// Let quota.Manager know about newly-sequenced entries.
 	replenishQuota(ctx, numLeaves, tree.TreeId, qm)
 
 	seqCounter.Add(float64(numLeaves), label)
+	if tree.TreeType == trillian.TreeType_LOG {
+		updateQueueMetrics(ctx, tree, label, ts, ls)
+	}
 	if newSLR != nil {
 		glog.Infof("%v: sequenced %v leaves, size %v", tree.TreeId, numLeaves, newLogRoot.TreeSize)
 	}
-	return numLeaves, nil
+	return numLeaves, newLogRoot, nil
+}
+
+// updateQueueMetrics sets the metrics describing the leaves of tree still
+// waiting to be sequenced, if ls can report them. Storage wrapping another one
+// may implement the interfaces without supporting them, and return
+// Unimplemented errors. Other failures are only logged, so that they don't
+// affect sequencing.
+func updateQueueMetrics(ctx context.Context, tree *trillian.Tree, label string, ts clock.TimeSource, ls storage.LogStorage) {
+	if c, ok := ls.(storage.UnsequencedCounter); ok {
+		count, err := c.CountUnsequenced(ctx, tree)
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+		case err != nil:
+			glog.Warningf("%v: failed to count unsequenced leaves: %v", tree.TreeId, err)
+		default:
+			seqUnsequenced.Set(float64(count), label)
+		}
+	}
+	if a, ok := ls.(storage.UnsequencedAger); ok {
+		oldest, err := a.OldestUnsequenced(ctx, tree)
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+		case err != nil:
+			glog.Warningf("%v: failed to read oldest unsequenced leaf: %v", tree.TreeId, err)
+		case oldest.IsZero():
+			seqOldestUnsequenced.Set(0, label)
+		default:
+			seqOldestUnsequenced.Set(clock.SecondsSince(ts, oldest), label)
+		}
+	}
 }
 
 // replenishQuota replenishes all quotas, such as {Tree/Global, Read/Write},
@@ -22,6 +22,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/notify"
 	"github.com/google/trillian/trees"
 )
 
@@ -59,9 +60,28 @@ func (s *SequencerManager) ExecutePass(ctx context.Context, logID int64, info *O
 		glog.Warning("failed to parse tree.MaxRootDuration, using zero")
 		maxRootDuration = 0
 	}
-	leaves, err := IntegrateBatch(ctx, tree, info.BatchSize, s.guardWindow, maxRootDuration, info.TimeSource, s.registry.LogStorage, s.registry.QuotaManager)
+	// The tree's own sequencing settings, if any, override those of the signer.
+	guardWindow := s.guardWindow
+	if gw := tree.SequencerGuardWindow.AsDuration(); gw > 0 {
+		guardWindow = gw
+	}
+	batchSize := info.BatchSize
+	if tree.SequencerBatchSize > 0 {
+		batchSize = int(tree.SequencerBatchSize)
+	}
+	leaves, root, err := integrateBatch(ctx, tree, batchSize, guardWindow, maxRootDuration, info.TimeSource, s.registry.LogStorage, s.registry.QuotaManager)
 	if err != nil {
 		return 0, fmt.Errorf("failed to integrate batch for %v: %v", logID, err)
 	}
+	// Stalls are detected by the log server instead, as a signer which stopped
+	// running the log couldn't report them.
+	if n := s.registry.Notifier; n != nil && leaves > 0 && root != nil {
+		n.Notify(ctx, notify.Event{Type: notify.RootAdvanced, TreeID: tree.TreeId, TreeSize: root.TreeSize, RootHash: root.RootHash})
+	}
+	// Roots which only refresh the timestamp of the tree expire from the read
+	// cache instead, which doesn't hold up readers.
+	if leaves > 0 && s.registry.ReadCache != nil {
+		s.registry.ReadCache.InvalidateRoot(ctx, logID)
+	}
 	return leaves, nil
 }
@@ -34,6 +34,7 @@ import (
 	"github.com/transparency-dev/merkle/compact"
 	"github.com/transparency-dev/merkle/rfc6962"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // Arbitrary time for use in tests
@@ -236,3 +237,37 @@ func createTestInfo(registry extension.Registry) *OperationInfo {
 		TimeSource:  fakeTimeSource,
 	}
 }
+
+func TestSequencerManagerTreeOverrides(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	logTree := proto.Clone(stestonly.LogTree).(*trillian.Tree)
+	logTree.SequencerGuardWindow = durationpb.New(time.Second * 10)
+	logTree.SequencerBatchSize = 7
+	logID := logTree.GetTreeId()
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+	mockAdmin := &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{mockAdminTx}}
+	mockTx := storage.NewMockLogTreeTX(mockCtrl)
+	fakeStorage := &stestonly.FakeLogStorage{TX: mockTx}
+
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testSignedRoot0, nil)
+	// Expect the guard window and batch size of the tree rather than those of the manager.
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 7, fakeTime.Add(-time.Second*10)).Return([]*trillian.LogLeaf{}, nil)
+
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(logTree, nil)
+	mockAdminTx.EXPECT().Commit().Return(nil)
+	mockAdminTx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: mockAdmin,
+		LogStorage:   fakeStorage,
+		QuotaManager: quota.Noop(),
+	}
+
+	sm := NewSequencerManager(registry, time.Second*5)
+	sm.ExecutePass(ctx, logID, createTestInfo(registry))
+}
@@ -691,3 +691,67 @@ func TestIntegrateBatch_PutTokens(t *testing.T) {
 		}()
 	}
 }
+
+// queueStatsStorage is a FakeLogStorage which reports a fixed queue.
+type queueStatsStorage struct {
+	stestonly.FakeLogStorage
+	count  int64
+	oldest time.Time
+	err    error
+}
+
+func (s *queueStatsStorage) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	return s.count, s.err
+}
+
+func (s *queueStatsStorage) OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error) {
+	return s.oldest, s.err
+}
+
+func TestUpdateQueueMetrics(t *testing.T) {
+	InitMetrics(nil)
+	ctx := context.Background()
+	ts := clock.NewFake(fakeTime)
+
+	for _, test := range []struct {
+		desc       string
+		treeID     int64
+		ls         storage.LogStorage
+		wantCount  float64
+		wantOldest float64
+	}{
+		{
+			desc:       "backlog",
+			treeID:     100,
+			ls:         &queueStatsStorage{count: 5, oldest: fakeTime.Add(-90 * time.Second)},
+			wantCount:  5,
+			wantOldest: 90,
+		},
+		{
+			desc:   "empty",
+			treeID: 101,
+			ls:     &queueStatsStorage{},
+		},
+		{
+			desc:   "error",
+			treeID: 102,
+			ls:     &queueStatsStorage{count: 5, oldest: fakeTime.Add(-time.Second), err: errors.New("stats failed")},
+		},
+		{
+			desc:   "unsupported",
+			treeID: 103,
+			ls:     &stestonly.FakeLogStorage{},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			label := fmt.Sprint(test.treeID)
+			updateQueueMetrics(ctx, &trillian.Tree{TreeId: test.treeID}, label, ts, test.ls)
+			if got := seqUnsequenced.Value(label); got != test.wantCount {
+				t.Errorf("sequencer_unsequenced_leaves=%v, want %v", got, test.wantCount)
+			}
+			if got := seqOldestUnsequenced.Value(label); got != test.wantOldest {
+				t.Errorf("sequencer_oldest_unsequenced_age=%v, want %v", got, test.wantOldest)
+			}
+		})
+	}
+}
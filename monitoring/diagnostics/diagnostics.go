@@ -0,0 +1,386 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics captures CPU, heap and goroutine profiles of the
+// process while an operation, like a sequencing pass or an RPC, runs longer
+// than its latency threshold, so that latency anomalies can be investigated
+// after the fact.
+//
+// The profiles of each slow operation are stored under their own prefix,
+// e.g. 20221015T103000.250Z-sequencing_pass-1234/, together with a
+// metadata.json file describing the operation. They can be inspected with
+// `go tool pprof`.
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/storage/blob"
+	"google.golang.org/grpc"
+)
+
+var (
+	profileDest        = flag.String("slow_op_profile_dest", "", "If set, the directory, or blob store URL like gs://bucket/prefix, where profiles of operations running longer than their threshold are stored")
+	cpuProfileDuration = flag.Duration("slow_op_cpu_profile_duration", DefaultCPUProfileDuration, "Duration of the CPU profiles captured for slow operations")
+	minInterval        = flag.Duration("slow_op_profile_min_interval", DefaultMinInterval, "Minimum time between the starts of two captures of profiles of slow operations")
+)
+
+const (
+	// DefaultCPUProfileDuration is the default duration of CPU profiles.
+	DefaultCPUProfileDuration = 10 * time.Second
+	// DefaultMinInterval is the default minimum time between captures.
+	DefaultMinInterval = 10 * time.Minute
+
+	// MetadataFile is the name of the file describing the slow operation,
+	// next to its profiles.
+	MetadataFile = "metadata.json"
+
+	// putTimeout bounds the time taken to store each file of a capture.
+	putTimeout = time.Minute
+	// finishTimeout bounds the time a capture waits for its operation to
+	// finish, to record its latency, before storing the metadata.
+	finishTimeout = time.Minute
+)
+
+// Kinds of operations watched by a Capturer.
+const (
+	// KindSequencingPass is a pass of the sequencer over a log.
+	KindSequencingPass = "sequencing_pass"
+	// KindRPC is an RPC served by the process.
+	KindRPC = "rpc"
+)
+
+// Operation identifies a watched operation.
+type Operation struct {
+	// Kind is one of the Kind* constants.
+	Kind string
+	// Name is the name of the operation within its kind, e.g. the full
+	// method name of an RPC.
+	Name string
+	// TreeID is the tree the operation works on, if any.
+	TreeID int64
+	// RequestID is the request ID of an RPC, which also appears in the logs.
+	RequestID string
+}
+
+// Metadata describes the slow operation of a capture, and its profiles.
+type Metadata struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name,omitempty"`
+	TreeID    int64  `json:"tree_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Start is when the operation started.
+	Start time.Time `json:"start"`
+	// Threshold is the latency threshold of the operation, in seconds.
+	Threshold float64 `json:"threshold_secs"`
+	// Latency is the latency of the operation if Finished is set, and the
+	// time it had been running for when the capture gave up waiting for it
+	// otherwise, in seconds.
+	Latency  float64 `json:"latency_secs"`
+	Finished bool    `json:"finished"`
+	// Error is the error the operation failed with, if any.
+	Error string `json:"error,omitempty"`
+	// Profiles are the names of the profiles captured, and ProfileErrors the
+	// reasons why others weren't.
+	Profiles      []string `json:"profiles"`
+	ProfileErrors []string `json:"profile_errors,omitempty"`
+}
+
+// Options configures a Capturer.
+type Options struct {
+	// CPUProfileDuration is the duration of CPU profiles. Zero disables
+	// them.
+	CPUProfileDuration time.Duration
+	// MinInterval is the minimum time between the starts of two captures,
+	// which bounds the overhead of profiling when many operations are slow.
+	MinInterval time.Duration
+}
+
+// Capturer captures profiles of the process while operations are slow. At
+// most one capture runs at a time, and operations becoming slow meanwhile, or
+// within MinInterval of the previous capture, are ignored.
+type Capturer struct {
+	store blob.Store
+	opts  Options
+
+	mu   sync.Mutex
+	busy bool
+	last time.Time
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewCapturer returns a Capturer storing profiles in store, which it closes
+// when closed.
+func NewCapturer(store blob.Store, opts Options) *Capturer {
+	return &Capturer{store: store, opts: opts, closed: make(chan struct{})}
+}
+
+// NewCapturerFromFlags returns a Capturer storing profiles at the destination
+// set by --slow_op_profile_dest, or nil if it's unset.
+func NewCapturerFromFlags(ctx context.Context) (*Capturer, error) {
+	if *profileDest == "" {
+		return nil, nil
+	}
+	store, err := OpenStore(ctx, *profileDest)
+	if err != nil {
+		return nil, err
+	}
+	return NewCapturer(store, Options{CPUProfileDuration: *cpuProfileDuration, MinInterval: *minInterval}), nil
+}
+
+// OpenStore opens the blob store at dest if it's a URL, and otherwise returns
+// a store keeping blobs as files under the directory dest.
+func OpenStore(ctx context.Context, dest string) (blob.Store, error) {
+	if strings.Contains(dest, "://") {
+		return blob.Open(ctx, dest)
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %v", err)
+	}
+	return dirStore(dest), nil
+}
+
+// Watch starts watching op, and captures profiles if it's still running after
+// threshold. The returned function must be called when op finishes, with its
+// error.
+func (c *Capturer) Watch(op Operation, threshold time.Duration) (done func(err error)) {
+	w := &watch{op: op, start: time.Now(), threshold: threshold, finished: make(chan struct{})}
+	timer := time.AfterFunc(threshold, func() { c.capture(w) })
+	return func(err error) {
+		timer.Stop()
+		w.finish(err)
+	}
+}
+
+// UnaryInterceptor returns a grpc.UnaryServerInterceptor watching the RPCs
+// served with the given threshold.
+func (c *Capturer) UnaryInterceptor(threshold time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		op := Operation{Kind: KindRPC, Name: info.FullMethod, RequestID: logging.RequestID(ctx)}
+		if r, ok := req.(interface{ GetLogId() int64 }); ok {
+			op.TreeID = r.GetLogId()
+		}
+		done := c.Watch(op, threshold)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// PassWatcher returns a function watching the sequencing passes over logs
+// with the given threshold, for log.OperationInfo.WatchPass.
+func (c *Capturer) PassWatcher(threshold time.Duration) func(logID int64) func(error) {
+	return func(logID int64) func(error) {
+		return c.Watch(Operation{Kind: KindSequencingPass, TreeID: logID}, threshold)
+	}
+}
+
+// Close interrupts the CPU profile in progress, if any, waits for the
+// captures to be stored, and closes the store.
+func (c *Capturer) Close() error {
+	// Captures check c.closed and join c.wg under c.mu.
+	c.mu.Lock()
+	c.closeOnce.Do(func() { close(c.closed) })
+	c.mu.Unlock()
+	c.wg.Wait()
+	return c.store.Close()
+}
+
+// watch is an operation being watched.
+type watch struct {
+	op        Operation
+	start     time.Time
+	threshold time.Duration
+	// finished is closed when the operation finishes.
+	finished chan struct{}
+
+	mu  sync.Mutex
+	end time.Time
+	err error
+}
+
+func (w *watch) finish(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.end, w.err = time.Now(), err
+	close(w.finished)
+}
+
+// metadata returns the metadata of the capture of w, as of now.
+func (w *watch) metadata() *Metadata {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	md := &Metadata{
+		Kind:      w.op.Kind,
+		Name:      w.op.Name,
+		TreeID:    w.op.TreeID,
+		RequestID: w.op.RequestID,
+		Start:     w.start,
+		Threshold: w.threshold.Seconds(),
+		Latency:   time.Since(w.start).Seconds(),
+	}
+	if !w.end.IsZero() {
+		md.Latency, md.Finished = w.end.Sub(w.start).Seconds(), true
+		if w.err != nil {
+			md.Error = w.err.Error()
+		}
+	}
+	return md
+}
+
+// prefix returns the prefix of the keys of the capture of w.
+func (w *watch) prefix() string {
+	p := fmt.Sprintf("%s-%s", w.start.UTC().Format("20060102T150405.000Z"), w.op.Kind)
+	if w.op.TreeID != 0 {
+		p = fmt.Sprintf("%s-%d", p, w.op.TreeID)
+	}
+	return p + "/"
+}
+
+// capture captures and stores the profiles of w, unless another capture is
+// running or ran too recently.
+func (c *Capturer) capture(w *watch) {
+	c.mu.Lock()
+	now := time.Now()
+	if c.busy || (!c.last.IsZero() && now.Sub(c.last) < c.opts.MinInterval) {
+		c.mu.Unlock()
+		glog.V(1).Infof("Not profiling slow %s %q: capture in progress or too recent", w.op.Kind, w.op.Name)
+		return
+	}
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		return
+	default:
+	}
+	c.busy, c.last = true, now
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.busy = false
+		c.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	prefix := w.prefix()
+	glog.Warningf("%s %q of tree %d running for over %v, capturing profiles to %s", w.op.Kind, w.op.Name, w.op.TreeID, w.threshold, prefix)
+	var names, errs []string
+	put := func(name string, data []byte) {
+		ctx, cancel := context.WithTimeout(context.Background(), putTimeout)
+		defer cancel()
+		if err := c.store.Put(ctx, prefix+name, data); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		names = append(names, name)
+	}
+
+	// The goroutine profile goes first, to show where the operation is stuck.
+	for _, p := range []string{"goroutine", "heap"} {
+		var buf bytes.Buffer
+		if err := pprof.Lookup(p).WriteTo(&buf, 0); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		put(p+".pb.gz", buf.Bytes())
+	}
+	if c.opts.CPUProfileDuration > 0 {
+		if data, err := c.cpuProfile(); err != nil {
+			errs = append(errs, fmt.Sprintf("cpu: %v", err))
+		} else {
+			put("cpu.pb.gz", data)
+		}
+	}
+
+	t := time.NewTimer(finishTimeout)
+	select {
+	case <-w.finished:
+	case <-t.C:
+	case <-c.closed:
+	}
+	t.Stop()
+	md := w.metadata()
+	md.Profiles, md.ProfileErrors = names, errs
+	data, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		glog.Errorf("Failed to marshal metadata of profiles %s: %v", prefix, err)
+		return
+	}
+	put(MetadataFile, data)
+	if len(errs) > 0 {
+		glog.Errorf("Failed to capture some profiles to %s: %v", prefix, errs)
+	}
+}
+
+// cpuProfile returns a CPU profile of the process over CPUProfileDuration, or
+// until the Capturer is closed. It fails if another CPU profile is running,
+// e.g. one requested with --cpuprofile.
+func (c *Capturer) cpuProfile() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return nil, err
+	}
+	t := time.NewTimer(c.opts.CPUProfileDuration)
+	select {
+	case <-t.C:
+	case <-c.closed:
+		t.Stop()
+	}
+	pprof.StopCPUProfile()
+	return buf.Bytes(), nil
+}
+
+// dirStore is a blob.Store keeping blobs as files under a directory. Keys
+// are slash-separated paths relative to it.
+type dirStore string
+
+// Put implements blob.Store.
+func (d dirStore) Put(_ context.Context, key string, data []byte) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Get implements blob.Store.
+func (d dirStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(string(d), filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%q: %w", key, blob.ErrNotFound)
+	}
+	return data, err
+}
+
+// Close implements blob.Store.
+func (d dirStore) Close() error {
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring/logging"
+	"google.golang.org/grpc"
+)
+
+const threshold = 20 * time.Millisecond
+
+// captures returns the metadata of the captures stored under dir, by prefix.
+func captures(t *testing.T, dir string) map[string]*Metadata {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() = %v", err)
+	}
+	mds := make(map[string]*Metadata)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name(), MetadataFile))
+		if err != nil {
+			t.Fatalf("ReadFile() = %v", err)
+		}
+		var md Metadata
+		if err := json.Unmarshal(data, &md); err != nil {
+			t.Fatalf("Unmarshal() = %v", err)
+		}
+		for _, p := range md.Profiles {
+			if fi, err := os.Stat(filepath.Join(dir, e.Name(), p)); err != nil || fi.Size() == 0 {
+				t.Errorf("profile %s/%s is missing or empty: %v", e.Name(), p, err)
+			}
+		}
+		mds[e.Name()] = &md
+	}
+	return mds
+}
+
+func newTestCapturer(t *testing.T, opts Options) (*Capturer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := OpenStore(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("OpenStore() = %v", err)
+	}
+	return NewCapturer(store, opts), dir
+}
+
+func TestWatch(t *testing.T) {
+	c, dir := newTestCapturer(t, Options{CPUProfileDuration: 10 * time.Millisecond})
+
+	// Fast operations aren't profiled.
+	c.Watch(Operation{Kind: KindSequencingPass, TreeID: 1}, threshold)(nil)
+
+	done := c.Watch(Operation{Kind: KindSequencingPass, TreeID: 2}, threshold)
+	time.Sleep(5 * threshold)
+	done(errors.New("failed"))
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	mds := captures(t, dir)
+	if len(mds) != 1 {
+		t.Fatalf("got %d captures, want 1", len(mds))
+	}
+	for prefix, md := range mds {
+		if want := "-sequencing_pass-2"; !strings.HasSuffix(prefix, want) {
+			t.Errorf("capture prefix = %q, want suffix %q", prefix, want)
+		}
+		if md.Kind != KindSequencingPass || md.TreeID != 2 || md.Threshold != threshold.Seconds() {
+			t.Errorf("metadata = %+v, want sequencing pass of tree 2 with threshold %v", md, threshold)
+		}
+		if !md.Finished || md.Error != "failed" || md.Latency < threshold.Seconds() {
+			t.Errorf("metadata = %+v, want finished pass which failed after %v", md, threshold)
+		}
+		sort.Strings(md.Profiles)
+		if diff := cmp.Diff([]string{"cpu.pb.gz", "goroutine.pb.gz", "heap.pb.gz"}, md.Profiles); diff != "" {
+			t.Errorf("profiles diff (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestWatchRateLimit(t *testing.T) {
+	c, dir := newTestCapturer(t, Options{MinInterval: time.Hour})
+	for i := 0; i < 3; i++ {
+		done := c.Watch(Operation{Kind: KindSequencingPass, TreeID: int64(i + 1)}, threshold)
+		time.Sleep(3 * threshold)
+		done(nil)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if got := len(captures(t, dir)); got != 1 {
+		t.Errorf("got %d captures, want 1", got)
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	c, dir := newTestCapturer(t, Options{})
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/GetInclusionProof"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(5 * threshold)
+		return &trillian.GetInclusionProofResponse{}, nil
+	}
+	if _, err := c.UnaryInterceptor(threshold)(ctx, &trillian.GetInclusionProofRequest{LogId: 3}, info, handler); err != nil {
+		t.Fatalf("UnaryInterceptor() = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	for _, md := range captures(t, dir) {
+		md.Start, md.Latency = time.Time{}, 0
+		want := &Metadata{
+			Kind:      KindRPC,
+			Name:      info.FullMethod,
+			TreeID:    3,
+			RequestID: "req-1",
+			Threshold: threshold.Seconds(),
+			Finished:  true,
+			Profiles:  []string{"goroutine.pb.gz", "heap.pb.gz"},
+		}
+		if diff := cmp.Diff(want, md); diff != "" {
+			t.Errorf("metadata diff (-want +got):\n%s", diff)
+		}
+		return
+	}
+	t.Error("got no capture, want 1")
+}
+
+func TestCloseInterruptsCPUProfile(t *testing.T) {
+	c, _ := newTestCapturer(t, Options{CPUProfileDuration: time.Hour})
+	done := c.Watch(Operation{Kind: KindSequencingPass}, time.Millisecond)
+	defer done(nil)
+	time.Sleep(10 * threshold)
+
+	closed := make(chan error)
+	go func() { closed <- c.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close() didn't interrupt the CPU profile")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// requestError is an error annotated with the request it occurred in.
+type requestError struct {
+	id  string
+	err error
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("%v (%s=%s)", e.err, RequestIDKey, e.id)
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// AnnotateError returns err with the request ID carried by ctx added to its
+// message, so that errors reported by clients, and the server logs about
+// them, can be correlated. gRPC status errors keep their code and details,
+// and other errors can still be unwrapped. err is returned unmodified if it's
+// nil, ctx has no request ID, or err already names it.
+func AnnotateError(ctx context.Context, err error) error {
+	id := RequestID(ctx)
+	if err == nil || id == "" || strings.Contains(err.Error(), id) {
+		return err
+	}
+	if s, ok := status.FromError(err); ok {
+		p := s.Proto()
+		p.Message = (&requestError{id: id, err: errors.New(p.Message)}).Error()
+		return status.ErrorProto(p)
+	}
+	return &requestError{id: id, err: err}
+}
+
+// WithRequestInfo returns the gRPC status error of err, annotated as by
+// AnnotateError, with an errdetails.RequestInfo detail holding the request ID
+// carried by ctx, which clients can read with RequestIDFromError. err is
+// returned unmodified if it's nil or ctx has no request ID.
+func WithRequestInfo(ctx context.Context, err error) error {
+	id := RequestID(ctx)
+	if err == nil || id == "" {
+		return err
+	}
+	if RequestIDFromError(err) != "" {
+		return err
+	}
+	p := status.Convert(AnnotateError(ctx, err)).Proto()
+	detail, aErr := anypb.New(&errdetails.RequestInfo{RequestId: id})
+	if aErr != nil {
+		return err
+	}
+	p.Details = append(p.Details, detail)
+	return status.ErrorProto(p)
+}
+
+// RequestIDFromError returns the request ID of the errdetails.RequestInfo
+// detail of a gRPC status error, or "" if there is none.
+func RequestIDFromError(err error) string {
+	s, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.RequestInfo); ok && info.RequestId != "" {
+			return info.RequestId
+		}
+	}
+	return ""
+}
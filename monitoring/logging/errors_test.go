@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAnnotateError(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	if err := AnnotateError(context.Background(), sql.ErrNoRows); err != sql.ErrNoRows {
+		t.Errorf("AnnotateError() without request ID = %v, want unmodified error", err)
+	}
+	if err := AnnotateError(ctx, nil); err != nil {
+		t.Errorf("AnnotateError(nil) = %v, want nil", err)
+	}
+
+	err := AnnotateError(ctx, sql.ErrNoRows)
+	if want := "sql: no rows in result set (request_id=req-1)"; err.Error() != want {
+		t.Errorf("AnnotateError() = %q, want %q", err, want)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Error("AnnotateError() doesn't wrap the original error")
+	}
+	if again := AnnotateError(ctx, err); again != err {
+		t.Errorf("AnnotateError() of annotated error = %v, want unmodified error", again)
+	}
+
+	withDetail, dErr := status.New(codes.NotFound, "no tree").WithDetails(&errdetails.ResourceInfo{ResourceName: "tree"})
+	if dErr != nil {
+		t.Fatalf("WithDetails() = %v", dErr)
+	}
+	s := status.Convert(AnnotateError(ctx, withDetail.Err()))
+	if s.Code() != codes.NotFound || s.Message() != "no tree (request_id=req-1)" || len(s.Details()) != 1 {
+		t.Errorf("AnnotateError() of status = %v with details %v, want annotated NotFound with its detail", s.Err(), s.Details())
+	}
+}
+
+func TestWithRequestInfo(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	if err := WithRequestInfo(context.Background(), sql.ErrNoRows); err != sql.ErrNoRows {
+		t.Errorf("WithRequestInfo() without request ID = %v, want unmodified error", err)
+	}
+	if err := WithRequestInfo(ctx, nil); err != nil {
+		t.Errorf("WithRequestInfo(nil) = %v, want nil", err)
+	}
+
+	err := WithRequestInfo(ctx, status.Error(codes.Internal, "failed"))
+	if got := RequestIDFromError(err); got != "req-1" {
+		t.Errorf("RequestIDFromError() = %q, want %q", got, "req-1")
+	}
+	if s := status.Convert(err); s.Code() != codes.Internal || s.Message() != "failed (request_id=req-1)" {
+		t.Errorf("WithRequestInfo() = %v, want annotated Internal error", err)
+	}
+	if again := WithRequestInfo(ctx, err); len(status.Convert(again).Details()) != 1 {
+		t.Errorf("WithRequestInfo() of error with request info added details: %v", status.Convert(again).Details())
+	}
+
+	if got := RequestIDFromError(errors.New("plain")); got != "" {
+		t.Errorf("RequestIDFromError() of plain error = %q, want none", got)
+	}
+}
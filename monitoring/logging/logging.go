@@ -0,0 +1,315 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging provides structured, levelled logging for the Trillian
+// servers. Its API follows log/slog, so that callers can later switch to it
+// once the minimum supported Go version allows.
+//
+// Each Logger belongs to a subsystem, whose minimum level can be configured
+// separately, and adds the request ID found in the context of each call to the
+// records it emits. By default records are written through glog, so that the
+// existing glog flags keep applying; Configure can switch to JSON lines.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Level is the importance of a log record. Its values match those of slog.
+type Level int
+
+const (
+	// LevelDebug is the level of detailed records, only useful when debugging.
+	LevelDebug Level = -4
+	// LevelInfo is the level of records about normal operation.
+	LevelInfo Level = 0
+	// LevelWarn is the level of records about unexpected but handled conditions.
+	LevelWarn Level = 4
+	// LevelError is the level of records about failed operations.
+	LevelError Level = 8
+)
+
+// String returns the name of the level, as used in log records.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel returns the Level named s, case-insensitively.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q, want one of debug, info, warn or error", s)
+}
+
+// ParseLevels parses per-subsystem levels from a comma-separated list of
+// "subsystem=level" entries, e.g. "interceptor=debug,admin=warn".
+func ParseLevels(spec string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	if spec == "" {
+		return levels, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid log level %q, want subsystem=level", entry)
+		}
+		if _, ok := levels[parts[0]]; ok {
+			return nil, fmt.Errorf("duplicate log level for subsystem %q", parts[0])
+		}
+		level, err := ParseLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		levels[parts[0]] = level
+	}
+	return levels, nil
+}
+
+const (
+	// FormatGlog writes records through glog, as human-readable text.
+	FormatGlog = "glog"
+	// FormatJSON writes records as JSON objects, one per line.
+	FormatJSON = "json"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatGlog, FormatJSON}
+
+// Config is the configuration of the package, shared by all Loggers.
+type Config struct {
+	// Format is one of Formats. Empty means FormatGlog.
+	Format string
+	// Output is where FormatJSON records are written. Nil means os.Stderr.
+	Output io.Writer
+	// Level is the minimum level of the records of subsystems without an
+	// entry in Levels.
+	Level Level
+	// Levels maps subsystems to the minimum level of their records.
+	Levels map[string]Level
+}
+
+var (
+	mu  sync.Mutex // Guards cfg.
+	cfg = Config{Format: FormatGlog}
+
+	// writeMu serializes the writes of FormatJSON records, so that records
+	// don't interleave in outputs which aren't safe for concurrent use.
+	writeMu sync.Mutex
+)
+
+// Configure sets the configuration used by all Loggers.
+func Configure(c Config) error {
+	switch c.Format {
+	case "":
+		c.Format = FormatGlog
+	case FormatGlog, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q, want one of %v", c.Format, Formats)
+	}
+	if c.Output == nil {
+		c.Output = os.Stderr
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	return nil
+}
+
+// Logger emits the log records of a subsystem.
+type Logger struct {
+	subsystem string
+	// attrs are the key/value pairs added to all records of the Logger.
+	attrs []interface{}
+}
+
+// New returns a Logger for the given subsystem.
+func New(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+// With returns a Logger which adds the given key/value pairs to all records.
+func (l *Logger) With(args ...interface{}) *Logger {
+	attrs := make([]interface{}, 0, len(l.attrs)+len(args))
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, args...)
+	return &Logger{subsystem: l.subsystem, attrs: attrs}
+}
+
+// Enabled returns whether records of the given level are emitted.
+func (l *Logger) Enabled(level Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return level >= minLevel(l.subsystem)
+}
+
+// Debug logs msg and the given key/value pairs at LevelDebug.
+func (l *Logger) Debug(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, LevelDebug, msg, args)
+}
+
+// Info logs msg and the given key/value pairs at LevelInfo.
+func (l *Logger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, LevelInfo, msg, args)
+}
+
+// Warn logs msg and the given key/value pairs at LevelWarn.
+func (l *Logger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, LevelWarn, msg, args)
+}
+
+// Error logs msg and the given key/value pairs at LevelError.
+func (l *Logger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, LevelError, msg, args)
+}
+
+// minLevel returns the minimum level of subsystem. It must be called with mu held.
+func minLevel(subsystem string) Level {
+	if level, ok := cfg.Levels[subsystem]; ok {
+		return level
+	}
+	return cfg.Level
+}
+
+func (l *Logger) log(ctx context.Context, level Level, msg string, args []interface{}) {
+	// Only the configuration is read under mu, so that records are formatted
+	// and written concurrently.
+	mu.Lock()
+	enabled := level >= minLevel(l.subsystem)
+	format, output := cfg.Format, cfg.Output
+	mu.Unlock()
+	if !enabled {
+		return
+	}
+
+	attrs := make([]interface{}, 0, 2+len(l.attrs)+len(args))
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, RequestIDKey, id)
+	}
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, args...)
+
+	if format == FormatJSON {
+		writeJSON(output, level, l.subsystem, msg, attrs)
+		return
+	}
+	// Depth 2 attributes the record to the caller of Debug, Info, etc.
+	text := formatText(l.subsystem, msg, attrs)
+	switch {
+	case level >= LevelError:
+		glog.ErrorDepth(2, text)
+	case level >= LevelWarn:
+		glog.WarningDepth(2, text)
+	default:
+		glog.InfoDepth(2, text)
+	}
+}
+
+// badKey is the key of values passed without one, as in slog.
+const badKey = "!BADKEY"
+
+// forEachAttr calls fn for each key/value pair of attrs.
+func forEachAttr(attrs []interface{}, fn func(key string, value interface{})) {
+	for i := 0; i < len(attrs); i += 2 {
+		if i+1 == len(attrs) {
+			fn(badKey, attrs[i])
+			return
+		}
+		key, ok := attrs[i].(string)
+		if !ok {
+			fn(badKey, attrs[i])
+			i--
+			continue
+		}
+		fn(key, attrs[i+1])
+	}
+}
+
+func formatText(subsystem, msg string, attrs []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", subsystem, msg)
+	forEachAttr(attrs, func(key string, value interface{}) {
+		v := fmt.Sprint(value)
+		if strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, v)
+	})
+	return b.String()
+}
+
+func writeJSON(w io.Writer, level Level, subsystem, msg string, attrs []interface{}) {
+	var b strings.Builder
+	b.WriteByte('{')
+	writeField := func(key string, value interface{}) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.Write(jsonValue(key))
+		b.WriteByte(':')
+		b.Write(jsonValue(value))
+	}
+	writeField("time", time.Now().Format(time.RFC3339Nano))
+	writeField("level", level.String())
+	writeField("subsystem", subsystem)
+	writeField("msg", msg)
+	forEachAttr(attrs, writeField)
+	b.WriteString("}\n")
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	// There is nowhere to report failures to write logs.
+	_, _ = io.WriteString(w, b.String())
+}
+
+// jsonValue returns the JSON encoding of v. Errors and values which can't be
+// encoded are written as strings.
+func jsonValue(v interface{}) []byte {
+	switch v := v.(type) {
+	case error:
+		return jsonValue(v.Error())
+	case fmt.Stringer:
+		return jsonValue(v.String())
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return b
+}
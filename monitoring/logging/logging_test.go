@@ -0,0 +1,190 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// configureJSON makes loggers write JSON to the returned buffer for the
+// duration of the test.
+func configureJSON(t *testing.T, level Level, levels map[string]Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Configure(Config{Format: FormatJSON, Output: &buf, Level: level, Levels: levels}); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	t.Cleanup(func() {
+		if err := Configure(Config{}); err != nil {
+			t.Errorf("Configure(): %v", err)
+		}
+	})
+	return &buf
+}
+
+// records decodes the JSON records written to buf, without their time.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var recs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("Invalid JSON record %q: %v", line, err)
+		}
+		if _, ok := rec["time"]; !ok {
+			t.Errorf("Record %q has no time", line)
+		}
+		delete(rec, "time")
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestJSON(t *testing.T) {
+	buf := configureJSON(t, LevelInfo, nil)
+	ctx := WithRequestID(context.Background(), "abc")
+	l := New("test").With("tree_id", 123)
+	l.Info(ctx, "hello", "err", errors.New("boom"), "count", 2, "dangling")
+
+	want := []map[string]interface{}{{
+		"level":      "INFO",
+		"subsystem":  "test",
+		"msg":        "hello",
+		RequestIDKey: "abc",
+		"tree_id":    123.0,
+		"err":        "boom",
+		"count":      2.0,
+		badKey:       "dangling",
+	}}
+	if diff := cmp.Diff(records(t, buf), want); diff != "" {
+		t.Errorf("records diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	buf := configureJSON(t, LevelWarn, map[string]Level{"verbose": LevelDebug, "quiet": LevelError})
+	ctx := context.Background()
+	for _, subsystem := range []string{"default", "verbose", "quiet"} {
+		l := New(subsystem)
+		l.Debug(ctx, "debug")
+		l.Info(ctx, "info")
+		l.Warn(ctx, "warn")
+		l.Error(ctx, "error")
+	}
+
+	var got []string
+	for _, rec := range records(t, buf) {
+		got = append(got, rec["subsystem"].(string)+":"+rec["msg"].(string))
+	}
+	want := []string{
+		"default:warn", "default:error",
+		"verbose:debug", "verbose:info", "verbose:warn", "verbose:error",
+		"quiet:error",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("logged records diff (-got +want):\n%s", diff)
+	}
+	if !New("verbose").Enabled(LevelDebug) || New("default").Enabled(LevelInfo) {
+		t.Error("Enabled() doesn't match the configured levels")
+	}
+}
+
+// blockingWriter blocks writes until unblock is closed.
+type blockingWriter struct {
+	writing chan struct{}
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	close(w.writing)
+	<-w.unblock
+	return len(p), nil
+}
+
+func TestWriteDoesNotBlockConfig(t *testing.T) {
+	w := &blockingWriter{writing: make(chan struct{}), unblock: make(chan struct{})}
+	if err := Configure(Config{Format: FormatJSON, Output: w, Level: LevelInfo}); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	defer func() {
+		if err := Configure(Config{}); err != nil {
+			t.Errorf("Configure(): %v", err)
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		New("test").Info(context.Background(), "slow")
+	}()
+	<-w.writing
+
+	// Levels are read and disabled records dropped while the record is being
+	// written.
+	l := New("test")
+	if l.Enabled(LevelDebug) {
+		t.Error("Enabled(LevelDebug) = true, want false")
+	}
+	l.Debug(context.Background(), "dropped")
+	close(w.unblock)
+	<-done
+}
+
+func TestFormatText(t *testing.T) {
+	got := formatText("test", "hello", []interface{}{"a", 1, "b", "two words", 3})
+	if want := `test: hello a=1 b="two words" !BADKEY=3`; got != want {
+		t.Errorf("formatText()=%q, want %q", got, want)
+	}
+}
+
+func TestConfigureInvalidFormat(t *testing.T) {
+	if err := Configure(Config{Format: "xml"}); err == nil {
+		t.Error("Configure() with unknown format succeeded, want error")
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	for _, test := range []struct {
+		spec    string
+		want    map[string]Level
+		wantErr bool
+	}{
+		{spec: "", want: map[string]Level{}},
+		{spec: "server=debug", want: map[string]Level{"server": LevelDebug}},
+		{spec: "server=WARN,admin=error", want: map[string]Level{"server": LevelWarn, "admin": LevelError}},
+		{spec: "server", wantErr: true},
+		{spec: "=debug", wantErr: true},
+		{spec: "server=loud", wantErr: true},
+		{spec: "server=debug,server=info", wantErr: true},
+	} {
+		got, err := ParseLevels(test.spec)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseLevels(%q): %v, want error: %v", test.spec, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(got, test.want); diff != "" && !test.wantErr {
+			t.Errorf("ParseLevels(%q) diff (-got +want):\n%s", test.spec, diff)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"net/http"
 
 	"contrib.go.opencensus.io/exporter/stackdriver"
+	"github.com/google/trillian/monitoring/logging"
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats/view"
@@ -105,10 +106,14 @@ func applyConfig(percent int) error {
 	return nil
 }
 
-// StartSpan starts a new tracing span.
+// StartSpan starts a new tracing span, with a request_id attribute holding the
+// request ID carried by ctx, if any.
 // The returned context should be used for all child calls within the span, and
 // the returned func should be called to close the span.
 func StartSpan(ctx context.Context, name string) (context.Context, func()) {
 	ctx, span := trace.StartSpan(ctx, name)
+	if id := logging.RequestID(ctx); id != "" {
+		span.AddAttributes(trace.StringAttribute(logging.RequestIDKey, id))
+	}
 	return ctx, span.End
 }
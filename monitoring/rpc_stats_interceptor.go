@@ -18,13 +18,22 @@ package monitoring
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/trillian/util/clock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-const traceSpanRoot = "/trillian/mon/"
+const (
+	traceSpanRoot = "/trillian/mon/"
+
+	// UnknownTreeID is the tree ID label of the requests to trees which no
+	// request has succeeded for yet.
+	UnknownTreeID = "unknown"
+)
 
 // RPCStatsInterceptor provides a gRPC interceptor that records statistics about the RPCs passing through it.
 type RPCStatsInterceptor struct {
@@ -35,6 +44,17 @@ type RPCStatsInterceptor struct {
 	ReqSuccessLatency Histogram
 	ReqErrorCount     Counter
 	ReqErrorLatency   Histogram
+	// ReqCodeCount counts responses by method and gRPC status code.
+	ReqCodeCount Counter
+	// TreeReqCodeCount and TreeReqLatency break responses down by tree, for requests that address
+	// a tree. Only the trees which a request has succeeded for, and so exist, are labelled with
+	// their IDs, so that clients can't create labels with arbitrary IDs. The requests to other
+	// trees are labelled with UnknownTreeID.
+	TreeReqCodeCount Counter
+	TreeReqLatency   Histogram
+
+	mu    sync.RWMutex
+	known map[string]bool // The IDs of the trees a request has succeeded for.
 }
 
 // NewRPCStatsInterceptor creates a new RPCStatsInterceptor for the given application/component, with
@@ -51,6 +71,10 @@ func NewRPCStatsInterceptor(timeSource clock.TimeSource, prefix string, mf Metri
 		ReqSuccessLatency: mf.NewHistogram(prefixedName(prefix, "rpc_success_latency"), "Latency of successful requests in seconds", "method"),
 		ReqErrorCount:     mf.NewCounter(prefixedName(prefix, "rpc_errors"), "Number of errored requests", "method"),
 		ReqErrorLatency:   mf.NewHistogram(prefixedName(prefix, "rpc_error_latency"), "Latency of errored requests in seconds", "method"),
+		ReqCodeCount:      mf.NewCounter(prefixedName(prefix, "rpc_responses"), "Number of responses by gRPC status code", "method", "code"),
+		TreeReqCodeCount:  mf.NewCounter(prefixedName(prefix, "rpc_tree_responses"), "Number of responses by tree and gRPC status code", "method", TreeIDLabel, "code"),
+		TreeReqLatency:    mf.NewHistogram(prefixedName(prefix, "rpc_tree_latency"), "Latency of requests by tree in seconds", "method", TreeIDLabel),
+		known:             make(map[string]bool),
 	}
 	return &interceptor
 }
@@ -59,17 +83,60 @@ func prefixedName(prefix, name string) string {
 	return fmt.Sprintf("%s_%s", prefix, name)
 }
 
-func (r *RPCStatsInterceptor) recordFailureLatency(labels []string, startTime time.Time) {
+// record records the outcome of a request to method, and to treeID if not empty.
+func (r *RPCStatsInterceptor) record(method, treeID string, code codes.Code, startTime time.Time) {
 	latency := clock.SecondsSince(r.timeSource, startTime)
-	r.ReqErrorCount.Inc(labels...)
-	r.ReqErrorLatency.Observe(latency, labels...)
+	if code == codes.OK {
+		r.ReqSuccessCount.Inc(method)
+		r.ReqSuccessLatency.Observe(latency, method)
+	} else {
+		r.ReqErrorCount.Inc(method)
+		r.ReqErrorLatency.Observe(latency, method)
+	}
+	r.ReqCodeCount.Inc(method, code.String())
+	if treeID != "" {
+		label := r.treeLabel(treeID, code)
+		r.TreeReqCodeCount.Inc(method, label, code.String())
+		r.TreeReqLatency.Observe(latency, method, label)
+	}
+}
+
+// treeLabel returns the tree ID label of a request to treeID which completed
+// with code, which is UnknownTreeID unless a request to the tree succeeded.
+func (r *RPCStatsInterceptor) treeLabel(treeID string, code codes.Code) string {
+	r.mu.RLock()
+	known := r.known[treeID]
+	r.mu.RUnlock()
+	if known {
+		return treeID
+	}
+	if code != codes.OK {
+		return UnknownTreeID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.known[treeID] = true
+	return treeID
+}
+
+// requestTreeID returns the ID of the tree addressed by req, or an empty string if it doesn't
+// address a tree.
+func requestTreeID(req interface{}) string {
+	switch req := req.(type) {
+	case interface{ GetLogId() int64 }:
+		return fmt.Sprint(req.GetLogId())
+	case interface{ GetTreeId() int64 }:
+		return fmt.Sprint(req.GetTreeId())
+	}
+	return ""
 }
 
 // Interceptor returns a UnaryServerInterceptor that can be registered with an RPC server and
 // will record request counts / errors and latencies for that servers handlers
 func (r *RPCStatsInterceptor) Interceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		labels := []string{info.FullMethod}
+		method := info.FullMethod
+		treeID := requestTreeID(req)
 
 		// This interceptor wraps the request handler so we should track the
 		// additional latency it imposes.
@@ -77,13 +144,13 @@ func (r *RPCStatsInterceptor) Interceptor() grpc.UnaryServerInterceptor {
 		defer spanEnd()
 
 		// Increase the request count for the method and start the clock
-		r.ReqCount.Inc(labels...)
+		r.ReqCount.Inc(method)
 		startTime := r.timeSource.Now()
 
 		defer func() {
 			if rec := recover(); rec != nil {
 				// If we reach here then the handler exited via panic, count it as a server failure
-				r.recordFailureLatency(labels, startTime)
+				r.record(method, treeID, codes.Internal, startTime)
 				panic(rec)
 			}
 		}()
@@ -92,13 +159,7 @@ func (r *RPCStatsInterceptor) Interceptor() grpc.UnaryServerInterceptor {
 		rsp, err := handler(ctx, req)
 
 		// Record success / failure and latency
-		if err != nil {
-			r.recordFailureLatency(labels, startTime)
-		} else {
-			latency := clock.SecondsSince(r.timeSource, startTime)
-			r.ReqSuccessCount.Inc(labels...)
-			r.ReqSuccessLatency.Observe(latency, labels...)
-		}
+		r.record(method, treeID, status.Code(err), startTime)
 
 		// Pass the result of the handler invocation back
 		return rsp, err
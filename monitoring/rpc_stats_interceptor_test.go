@@ -21,9 +21,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/trillian"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/util/clock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Arbitrary time for use in tests
@@ -179,6 +182,73 @@ func TestMultipleErrorRequestsTotalLatency(t *testing.T) {
 	}
 }
 
+func TestRequestsByCodeAndTree(t *testing.T) {
+	ts := clock.PredefinedFake{
+		Base: fakeTime,
+		Delays: []time.Duration{
+			0,
+			time.Millisecond * 250,
+			0,
+			time.Millisecond * 500,
+			0,
+			time.Millisecond * 400,
+			0,
+			time.Millisecond * 100,
+			0,
+			time.Millisecond * 200,
+		},
+	}
+	stats := monitoring.NewRPCStatsInterceptor(&ts, "test_code_tree", monitoring.InertMetricFactory{})
+	i := stats.Interceptor()
+
+	for _, tc := range []struct {
+		req interface{}
+		err error
+	}{
+		// Tree 12 is unknown until a request to it succeeds.
+		{req: &trillian.GetLatestSignedLogRootRequest{LogId: 12}, err: status.Error(codes.NotFound, "no root")},
+		{req: &trillian.GetLatestSignedLogRootRequest{LogId: 12}},
+		{req: &trillian.GetLatestSignedLogRootRequest{LogId: 12}, err: status.Error(codes.NotFound, "no root")},
+		{req: &trillian.GetLatestSignedLogRootRequest{LogId: 13}, err: status.Error(codes.NotFound, "no tree")},
+		{req: "wibble", err: errors.New("bang")},
+	} {
+		handler := recordingUnaryHandler{err: tc.err}
+		if _, err := i(context.Background(), tc.req, &grpc.UnaryServerInfo{FullMethod: "testmethod"}, handler.handler()); err != tc.err {
+			t.Fatalf("interceptor()=_,%v; want _,%v", err, tc.err)
+		}
+	}
+
+	for _, c := range []struct {
+		code codes.Code
+		want float64
+	}{
+		{code: codes.OK, want: 1},
+		{code: codes.NotFound, want: 3},
+		{code: codes.Unknown, want: 1},
+		{code: codes.Internal, want: 0},
+	} {
+		if got := stats.ReqCodeCount.Value("testmethod", c.code.String()); got != c.want {
+			t.Errorf("stats.ReqCodeCount(%v)=%v; want %v", c.code, got, c.want)
+		}
+	}
+	if got, want := stats.TreeReqCodeCount.Value("testmethod", "12", codes.NotFound.String()), 1.0; got != want {
+		t.Errorf("stats.TreeReqCodeCount(NotFound)=%v; want %v", got, want)
+	}
+	if got, want := stats.TreeReqCodeCount.Value("testmethod", monitoring.UnknownTreeID, codes.NotFound.String()), 2.0; got != want {
+		t.Errorf("stats.TreeReqCodeCount(%s, NotFound)=%v; want %v", monitoring.UnknownTreeID, got, want)
+	}
+	if got, want := stats.TreeReqCodeCount.Value("testmethod", "13", codes.NotFound.String()), 0.0; got != want {
+		t.Errorf("stats.TreeReqCodeCount(13, NotFound)=%v; want %v", got, want)
+	}
+	if got, want := stats.TreeReqCodeCount.Value("testmethod", "12", codes.Unknown.String()), 0.0; got != want {
+		t.Errorf("stats.TreeReqCodeCount(Unknown)=%v; want %v", got, want)
+	}
+	count, sum := stats.TreeReqLatency.Info("testmethod", "12")
+	if wantCount, wantSum := uint64(2), 0.9; count != wantCount || sum != wantSum {
+		t.Errorf("stats.TreeReqLatency.Info=%v,%v; want %v,%v", count, sum, wantCount, wantSum)
+	}
+}
+
 func TestCanInitializeNilMetricFactory(t *testing.T) {
 	ts := clock.PredefinedFake{
 		Base:   fakeTime,
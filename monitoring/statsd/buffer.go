@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultMaxPacketSize is the default maximum size of the packets sent by
+	// a BufferedWriter, which fits in the MTU of most networks.
+	DefaultMaxPacketSize = 1432
+	// DefaultFlushInterval is the default maximum time updates are buffered
+	// by a BufferedWriter.
+	DefaultFlushInterval = time.Second
+)
+
+// BufferedWriter batches the StatsD lines written to it into newline-separated
+// packets of up to a maximum size, which are written to the underlying writer,
+// such as a UDP connection, when full and at least every flush interval. Lines
+// longer than the maximum size are written on their own.
+type BufferedWriter struct {
+	w       io.Writer
+	maxSize int
+
+	mu   sync.Mutex
+	buf  []byte
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBufferedWriter returns a BufferedWriter writing packets of up to maxSize
+// bytes to w, at least every interval until Close is called.
+func NewBufferedWriter(w io.Writer, maxSize int, interval time.Duration) *BufferedWriter {
+	b := &BufferedWriter{
+		w:       w,
+		maxSize: maxSize,
+		buf:     make([]byte, 0, maxSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// run flushes the buffer every interval until Close is called.
+func (b *BufferedWriter) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.Flush()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Write buffers a single line, first writing the buffered lines if it doesn't
+// fit with them in a packet. It never fails, as sending is best effort.
+func (b *BufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buf) > 0 && len(b.buf)+1+len(p) > b.maxSize {
+		b.flushLocked()
+	}
+	if len(b.buf) > 0 {
+		b.buf = append(b.buf, '\n')
+	}
+	b.buf = append(b.buf, p...)
+	if len(b.buf) >= b.maxSize {
+		b.flushLocked()
+	}
+	return len(p), nil
+}
+
+// Flush writes the buffered lines.
+func (b *BufferedWriter) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+}
+
+func (b *BufferedWriter) flushLocked() {
+	if len(b.buf) == 0 {
+		return
+	}
+	if _, err := b.w.Write(b.buf); err != nil {
+		// Sending is best effort, and fails e.g. while the agent restarts.
+		glog.V(1).Infof("Failed to send %d bytes of metrics: %v", len(b.buf), err)
+	}
+	b.buf = b.buf[:0]
+}
+
+// Close stops the periodic flushes, and writes the buffered lines. It doesn't
+// close the underlying writer.
+func (b *BufferedWriter) Close() {
+	close(b.stop)
+	<-b.done
+	b.Flush()
+}
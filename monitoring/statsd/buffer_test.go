@@ -0,0 +1,37 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	var r lineRecorder
+	w := NewBufferedWriter(&r, 12, time.Hour)
+	for _, line := range []string{"a:1|c", "b:2|c", "c:3|c", "long_name:4|c", "d:5|c"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q): %v", line, err)
+		}
+	}
+	w.Close()
+	want := []string{"a:1|c\nb:2|c", "c:3|c", "long_name:4|c", "d:5|c"}
+	if diff := cmp.Diff(want, r.lines); diff != "" {
+		t.Errorf("packets diff (-want +got):\n%s", diff)
+	}
+}
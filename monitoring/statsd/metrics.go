@@ -0,0 +1,271 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statsd provides a StatsD-based implementation of the MetricFactory
+// abstraction, for deployments which push metrics to a StatsD or DogStatsD
+// agent rather than have them scraped by Prometheus.
+package statsd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+)
+
+var (
+	address = flag.String("statsd_address", "", "If set, metrics are sent to the StatsD agent at this UDP host:port instead of being exported for Prometheus")
+	prefix  = flag.String("statsd_prefix", "", "Prefix of the names of the metrics sent to --statsd_address, e.g. \"trillian.\"")
+	tags    = flag.Bool("statsd_tags", true, "If true, metric labels are sent as DogStatsD tags, otherwise their values are appended to the metric names")
+
+	maxPacketSize = flag.Int("statsd_max_packet_size", DefaultMaxPacketSize, "Maximum size in bytes of the UDP packets sent to --statsd_address, each of which holds as many metric updates as fit")
+	flushInterval = flag.Duration("statsd_flush_interval", DefaultFlushInterval, "Maximum time metric updates are buffered before being sent to --statsd_address")
+)
+
+// NewMetricFactoryFromFlags returns a MetricFactory sending metrics to the
+// agent set by --statsd_address, or fallback if it's unset. The updates are
+// batched into packets by a BufferedWriter. The returned function sends the
+// buffered updates, and closes the connection to the agent.
+func NewMetricFactoryFromFlags(fallback monitoring.MetricFactory) (monitoring.MetricFactory, func() error, error) {
+	if *address == "" {
+		return fallback, func() error { return nil }, nil
+	}
+	conn, err := net.Dial("udp", *address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to dial StatsD agent: %v", err)
+	}
+	w := NewBufferedWriter(conn, *maxPacketSize, *flushInterval)
+	closeFn := func() error {
+		w.Close()
+		return conn.Close()
+	}
+	return MetricFactory{Prefix: *prefix, Tags: *tags, Writer: w}, closeFn, nil
+}
+
+// MetricFactory allows the creation of StatsD-based metrics. The metrics also
+// keep their values in memory, so that they can be read back like the other
+// implementations.
+type MetricFactory struct {
+	// Prefix is an identifier that will be used before local metric names that
+	// are reported, e.g. "trillian.".
+	Prefix string
+	// Tags selects whether labels are sent as DogStatsD tags. Otherwise, as
+	// plain StatsD has no labels, their values are appended to the metric
+	// names, separated by dots.
+	Tags bool
+	// Writer receives each metric update as a StatsD line, in a single write.
+	// It must be safe for concurrent use, like a UDP connection.
+	Writer io.Writer
+}
+
+// NewCounter creates a new Counter object backed by StatsD.
+func (smf MetricFactory) NewCounter(name, help string, labelNames ...string) monitoring.Counter {
+	return &Counter{
+		sender: smf.sender(name, "c", labelNames),
+		local:  monitoring.InertMetricFactory{}.NewCounter(name, help, labelNames...),
+	}
+}
+
+// NewGauge creates a new Gauge object backed by StatsD.
+func (smf MetricFactory) NewGauge(name, help string, labelNames ...string) monitoring.Gauge {
+	return &Gauge{
+		sender: smf.sender(name, "g", labelNames),
+		local:  monitoring.InertMetricFactory{}.NewGauge(name, help, labelNames...),
+	}
+}
+
+// NewHistogram creates a new Histogram object backed by StatsD. Histograms
+// are sent as DogStatsD histograms if Tags is set, and as StatsD timers
+// otherwise. Timers are in milliseconds, so the observations, which are in
+// seconds like the latencies measured by Trillian, are scaled for them.
+func (smf MetricFactory) NewHistogram(name, help string, labelNames ...string) monitoring.Histogram {
+	metricType, scale := "ms", float64(time.Second/time.Millisecond)
+	if smf.Tags {
+		metricType, scale = "h", 1
+	}
+	return &Histogram{
+		sender: smf.sender(name, metricType, labelNames),
+		scale:  scale,
+		local:  monitoring.InertMetricFactory{}.NewHistogram(name, help, labelNames...),
+	}
+}
+
+// NewHistogramWithBuckets creates a new Histogram object backed by StatsD.
+// The buckets are not used, as the agent computes the distribution.
+func (smf MetricFactory) NewHistogramWithBuckets(name, help string, _ []float64, labelNames ...string) monitoring.Histogram {
+	return smf.NewHistogram(name, help, labelNames...)
+}
+
+func (smf MetricFactory) sender(name, metricType string, labelNames []string) sender {
+	return sender{w: smf.Writer, name: smf.Prefix + name, metricType: metricType, tags: smf.Tags, labelNames: labelNames}
+}
+
+// sender writes the StatsD lines of a metric.
+type sender struct {
+	w          io.Writer
+	name       string
+	metricType string
+	tags       bool
+	labelNames []string
+}
+
+// checkLabels returns whether labelVals match the label names of the metric,
+// which the local values check too.
+func (s sender) checkLabels(labelVals []string) bool {
+	if len(labelVals) != len(s.labelNames) {
+		glog.Errorf("%s: invalid label count %d; want %d", s.name, len(labelVals), len(s.labelNames))
+		return false
+	}
+	return true
+}
+
+// send writes a line with the given value and labels.
+func (s sender) send(val float64, labelVals []string) {
+	var b strings.Builder
+	b.WriteString(s.name)
+	if !s.tags {
+		for _, v := range labelVals {
+			b.WriteByte('.')
+			b.WriteString(sanitize(v, ".:|@#,\n"))
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatFloat(val, 'f', -1, 64))
+	b.WriteByte('|')
+	b.WriteString(s.metricType)
+	if s.tags && len(labelVals) > 0 {
+		b.WriteString("|#")
+		for i, v := range labelVals {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(s.labelNames[i])
+			b.WriteByte(':')
+			b.WriteString(sanitize(v, "|@#,\n"))
+		}
+	}
+	if _, err := io.WriteString(s.w, b.String()); err != nil {
+		// Sending is best effort, and fails e.g. while the agent restarts.
+		glog.V(1).Infof("Failed to send metric %s: %v", s.name, err)
+	}
+}
+
+// sanitize replaces the characters of v which are special in StatsD lines.
+func sanitize(v, special string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(special, r) {
+			return '_'
+		}
+		return r
+	}, v)
+}
+
+// Counter is a StatsD counter, which sends the increments of its value.
+type Counter struct {
+	sender
+	local monitoring.Counter
+}
+
+// Inc adds 1 to a counter.
+func (m *Counter) Inc(labelVals ...string) {
+	m.Add(1.0, labelVals...)
+}
+
+// Add adds the given amount to a counter.
+func (m *Counter) Add(val float64, labelVals ...string) {
+	if !m.checkLabels(labelVals) {
+		return
+	}
+	m.local.Add(val, labelVals...)
+	m.send(val, labelVals)
+}
+
+// Value returns the current amount of a counter.
+func (m *Counter) Value(labelVals ...string) float64 {
+	return m.local.Value(labelVals...)
+}
+
+// Gauge is a StatsD gauge. It sends its new value whenever it changes, rather
+// than the change, so that lost updates don't skew it.
+type Gauge struct {
+	sender
+	// mu serializes updates, so that the last value sent is the current one.
+	mu    sync.Mutex
+	local monitoring.Gauge
+}
+
+// Inc adds 1 to a gauge.
+func (m *Gauge) Inc(labelVals ...string) {
+	m.Add(1.0, labelVals...)
+}
+
+// Dec subtracts 1 from a gauge.
+func (m *Gauge) Dec(labelVals ...string) {
+	m.Add(-1.0, labelVals...)
+}
+
+// Add adds given value to a gauge.
+func (m *Gauge) Add(val float64, labelVals ...string) {
+	if !m.checkLabels(labelVals) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.local.Add(val, labelVals...)
+	m.send(m.local.Value(labelVals...), labelVals)
+}
+
+// Set sets the value of a gauge.
+func (m *Gauge) Set(val float64, labelVals ...string) {
+	if !m.checkLabels(labelVals) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.local.Set(val, labelVals...)
+	m.send(val, labelVals)
+}
+
+// Value returns the current amount of a gauge.
+func (m *Gauge) Value(labelVals ...string) float64 {
+	return m.local.Value(labelVals...)
+}
+
+// Histogram is a StatsD histogram, which sends each observation.
+type Histogram struct {
+	sender
+	scale float64 // Multiplies the values sent.
+	local monitoring.Histogram
+}
+
+// Observe adds a single observation to the histogram.
+func (m *Histogram) Observe(val float64, labelVals ...string) {
+	if !m.checkLabels(labelVals) {
+		return
+	}
+	m.local.Observe(val, labelVals...)
+	m.send(val*m.scale, labelVals)
+}
+
+// Info returns the count and sum of observations for the histogram.
+func (m *Histogram) Info(labelVals ...string) (uint64, float64) {
+	return m.local.Info(labelVals...)
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/monitoring/testonly"
+)
+
+// lineRecorder records the lines written to it.
+type lineRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *lineRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, string(p))
+	return len(p), nil
+}
+
+func TestCounter(t *testing.T) {
+	testonly.TestCounter(t, MetricFactory{Prefix: "TestCounter", Tags: true, Writer: ioutil.Discard})
+}
+
+func TestGauge(t *testing.T) {
+	testonly.TestGauge(t, MetricFactory{Prefix: "TestGauge", Tags: true, Writer: ioutil.Discard})
+}
+
+func TestHistogram(t *testing.T) {
+	testonly.TestHistogram(t, MetricFactory{Prefix: "TestHistogram", Tags: true, Writer: ioutil.Discard})
+}
+
+func TestLines(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tags bool
+		want []string
+	}{
+		{
+			name: "tags",
+			tags: true,
+			want: []string{
+				"trillian.requests:1|c|#method:get,tree_id:12",
+				"trillian.requests:2.5|c|#method:get,tree_id:12",
+				"trillian.leaves:3|g",
+				"trillian.leaves:2|g",
+				"trillian.leaves:7|g",
+				"trillian.latency:0.25|h|#method:a_b_c",
+			},
+		},
+		{
+			name: "no tags",
+			want: []string{
+				"trillian.requests.get.12:1|c",
+				"trillian.requests.get.12:2.5|c",
+				"trillian.leaves:3|g",
+				"trillian.leaves:2|g",
+				"trillian.leaves:7|g",
+				"trillian.latency.a_b_c:250|ms",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var r lineRecorder
+			mf := MetricFactory{Prefix: "trillian.", Tags: tc.tags, Writer: &r}
+
+			c := mf.NewCounter("requests", "Test only", "method", "tree_id")
+			c.Inc("get", "12")
+			c.Add(2.5, "get", "12")
+			// Updates with invalid labels aren't sent.
+			c.Inc("get")
+
+			g := mf.NewGauge("leaves", "Test only")
+			g.Add(3)
+			g.Dec()
+			g.Set(7)
+
+			h := mf.NewHistogramWithBuckets("latency", "Test only", []float64{1, 2}, "method")
+			h.Observe(0.25, "a|b,c")
+
+			if diff := cmp.Diff(tc.want, r.lines); diff != "" {
+				t.Errorf("lines diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/srv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/namespace"
+)
+
+var (
+	discoverySRV     = flag.String("etcd_discovery_srv", "", "Domain whose DNS SRV records list the etcd servers, used instead of --etcd_servers")
+	autoSyncInterval = flag.Duration("etcd_auto_sync_interval", 0, "Interval at which the etcd endpoints are updated with the members of the cluster; zero disables updates")
+	tlsCertFile      = flag.String("etcd_tls_cert_file", "", "Path to the client certificate presented to etcd")
+	tlsKeyFile       = flag.String("etcd_tls_key_file", "", "Path to the key of --etcd_tls_cert_file")
+	tlsCAFile        = flag.String("etcd_tls_ca_file", "", "Path to the CA certificates etcd's certificate is verified with, instead of the system ones. etcd is connected to over TLS if this or --etcd_tls_cert_file is set")
+	username         = flag.String("etcd_username", "", "User name to authenticate to etcd with")
+	passwordFile     = flag.String("etcd_password_file", "", "Path to the file holding the password of --etcd_username")
+	keyNamespace     = flag.String("etcd_namespace", "", "Prefix added to all the etcd keys used, e.g. /trillian/prod, so that deployments can share an etcd cluster")
+)
+
+// ClientOptions configures the etcd clients returned by NewClient.
+type ClientOptions struct {
+	// Endpoints are the etcd servers to connect to.
+	Endpoints []string
+	// DiscoverySRV, if set, is the domain whose DNS SRV records list the etcd
+	// servers, which are used if Endpoints is empty.
+	DiscoverySRV string
+	// AutoSyncInterval, if non-zero, is the interval at which the endpoints are
+	// updated with the members of the cluster.
+	AutoSyncInterval time.Duration
+	// DialTimeout bounds the initial connection to etcd.
+	DialTimeout time.Duration
+
+	// TLSCertFile and TLSKeyFile hold the client certificate, and TLSCAFile
+	// the CA certificates the servers are verified with. If any of them is set,
+	// etcd is connected to over TLS.
+	TLSCertFile, TLSKeyFile, TLSCAFile string
+
+	// Username and Password, if set, authenticate the client to etcd.
+	Username, Password string
+
+	// Namespace, if set, prefixes all the keys read and written, as well as
+	// watched, through the client.
+	Namespace string
+}
+
+// NewClient returns an etcd client configured by opts.
+func NewClient(opts ClientOptions) (*clientv3.Client, error) {
+	endpoints := opts.Endpoints
+	if len(endpoints) == 0 && opts.DiscoverySRV != "" {
+		srvs, err := srv.GetClient("etcd-client", opts.DiscoverySRV, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover etcd servers of %q: %v", opts.DiscoverySRV, err)
+		}
+		endpoints = srvs.Endpoints
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no etcd servers configured")
+	}
+
+	cfg := clientv3.Config{
+		Endpoints:        endpoints,
+		AutoSyncInterval: opts.AutoSyncInterval,
+		DialTimeout:      opts.DialTimeout,
+		Username:         opts.Username,
+		Password:         opts.Password,
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" || opts.TLSCAFile != "" {
+		tlsConfig, err := newTLSConfig(opts.TLSCertFile, opts.TLSKeyFile, opts.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLS = tlsConfig
+	}
+
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to etcd at %v: %v", endpoints, err)
+	}
+	if opts.Namespace != "" {
+		client.KV = namespace.NewKV(client.KV, opts.Namespace)
+		client.Watcher = namespace.NewWatcher(client.Watcher, opts.Namespace)
+		client.Lease = namespace.NewLease(client.Lease, opts.Namespace)
+	}
+	return client, nil
+}
+
+// newTLSConfig returns the TLS configuration of an etcd client.
+func newTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd CA certificates: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificates found in %q", caFile)
+		}
+	}
+	return cfg, nil
+}
+
+// NewClientFromFlags returns an etcd client configured by the --etcd_* flags,
+// or nil if neither --etcd_servers nor --etcd_discovery_srv is set.
+func NewClientFromFlags() (*clientv3.Client, error) {
+	if *Servers == "" && *discoverySRV == "" {
+		return nil, nil
+	}
+	opts := ClientOptions{
+		DiscoverySRV:     *discoverySRV,
+		AutoSyncInterval: *autoSyncInterval,
+		DialTimeout:      5 * time.Second,
+		TLSCertFile:      *tlsCertFile,
+		TLSKeyFile:       *tlsKeyFile,
+		TLSCAFile:        *tlsCAFile,
+		Username:         *username,
+		Namespace:        *keyNamespace,
+	}
+	if *Servers != "" {
+		opts.Endpoints = strings.Split(*Servers, ",")
+	}
+	if *passwordFile != "" {
+		password, err := os.ReadFile(*passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd password: %v", err)
+		}
+		opts.Password = strings.TrimSpace(string(password))
+	}
+	return NewClient(opts)
+}
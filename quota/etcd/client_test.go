@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testetcd "github.com/google/trillian/testonly/integration/etcd"
+)
+
+func TestNewClientNamespace(t *testing.T) {
+	ctx := context.Background()
+	e, raw, cleanup, err := testetcd.StartEtcd()
+	if err != nil {
+		t.Fatalf("StartEtcd() = %v", err)
+	}
+	defer cleanup()
+
+	client, err := NewClient(ClientOptions{
+		Endpoints:   []string{e.Config().LCUrls[0].String()},
+		DialTimeout: 5 * time.Second,
+		Namespace:   "/trillian/test/",
+	})
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Put(ctx, "quotas/global/read/config", "cfg"); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	resp, err := raw.Get(ctx, "/trillian/test/quotas/global/read/config")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if len(resp.Kvs) != 1 || string(resp.Kvs[0].Value) != "cfg" {
+		t.Errorf("Get() of namespaced key = %v, want the value put through the namespaced client", resp.Kvs)
+	}
+	if resp, err := raw.Get(ctx, "quotas/global/read/config"); err != nil || len(resp.Kvs) != 0 {
+		t.Errorf("Get() of key outside namespace = %v, %v, want no keys", resp, err)
+	}
+}
+
+func TestNewClientErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		opts ClientOptions
+	}{
+		{desc: "noEndpoints", opts: ClientOptions{}},
+		{desc: "certWithoutKey", opts: ClientOptions{Endpoints: []string{"localhost:2379"}, TLSCertFile: notPEM}},
+		{desc: "missingCA", opts: ClientOptions{Endpoints: []string{"localhost:2379"}, TLSCAFile: filepath.Join(dir, "missing.pem")}},
+		{desc: "invalidCA", opts: ClientOptions{Endpoints: []string{"localhost:2379"}, TLSCAFile: notPEM}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if client, err := NewClient(test.opts); err == nil {
+				client.Close()
+				t.Error("NewClient() succeeded, want error")
+			}
+		})
+	}
+}
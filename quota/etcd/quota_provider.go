@@ -17,14 +17,11 @@ package etcd
 import (
 	"flag"
 	"fmt"
-	"strings"
-	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian/quota"
 	"github.com/google/trillian/quota/cacheqm"
 	"github.com/google/trillian/quota/etcd/etcdqm"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // QuotaManagerName identifies the etcd quota implementation.
@@ -47,15 +44,12 @@ func init() {
 }
 
 func newEtcdQuotaManager() (quota.Manager, error) {
-	if *Servers == "" {
-		return nil, fmt.Errorf("can't create etcd quotamanager - etcd_servers flag is unset")
-	}
-	client, err := clientv3.New(clientv3.Config{
-		Endpoints:   strings.Split(*Servers, ","),
-		DialTimeout: 5 * time.Second,
-	})
+	client, err := NewClientFromFlags()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to etcd at %v: %v", *Servers, err)
+		return nil, err
+	}
+	if client == nil {
+		return nil, fmt.Errorf("can't create etcd quotamanager - etcd_servers and etcd_discovery_srv flags are unset")
 	}
 
 	var qm quota.Manager = etcdqm.New(client)
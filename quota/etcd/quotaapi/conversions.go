@@ -27,10 +27,11 @@ const (
 	maxTokensPath       = "max_tokens"
 	sequencingBasedPath = "sequencing_based"
 	timeBasedPath       = "time_based"
+	schedulesPath       = "schedules"
 )
 
 var (
-	commonMask          = &field_mask.FieldMask{Paths: []string{statePath, maxTokensPath}}
+	commonMask          = &field_mask.FieldMask{Paths: []string{statePath, maxTokensPath, schedulesPath}}
 	sequencingBasedMask = &field_mask.FieldMask{Paths: append(commonMask.Paths, sequencingBasedPath)}
 	timeBasedMask       = &field_mask.FieldMask{Paths: append(commonMask.Paths, timeBasedPath)}
 
@@ -46,7 +47,7 @@ func validateMask(mask *field_mask.FieldMask) error {
 	timeBasedFound := false
 	for _, path := range mask.Paths {
 		switch path {
-		case statePath, maxTokensPath:
+		case statePath, maxTokensPath, schedulesPath:
 			// OK
 		case sequencingBasedPath:
 			if timeBasedFound {
@@ -75,6 +76,20 @@ func applyMask(src *quotapb.Config, dest *storagepb.Config, mask *field_mask.Fie
 			dest.State = storagepb.Config_State(storagepb.Config_State_value[src.State.String()])
 		case maxTokensPath:
 			dest.MaxTokens = src.MaxTokens
+		case schedulesPath:
+			dest.Schedules = nil
+			for _, s := range src.Schedules {
+				dest.Schedules = append(dest.Schedules, &storagepb.Schedule{
+					Days:                     s.Days,
+					StartMinute:              s.StartMinute,
+					EndMinute:                s.EndMinute,
+					TimeZone:                 s.TimeZone,
+					MaxTokens:                s.MaxTokens,
+					TokensToReplenish:        s.TokensToReplenish,
+					ReplenishIntervalSeconds: s.ReplenishIntervalSeconds,
+					Freeze:                   s.Freeze,
+				})
+			}
 		case sequencingBasedPath:
 			if src.GetSequencingBased() == nil {
 				dest.ReplenishmentStrategy = nil
@@ -105,6 +120,18 @@ func convertToAPI(src *storagepb.Config) *quotapb.Config {
 		State:     quotapb.Config_State(quotapb.Config_State_value[src.State.String()]),
 		MaxTokens: src.MaxTokens,
 	}
+	for _, s := range src.Schedules {
+		dest.Schedules = append(dest.Schedules, &quotapb.Schedule{
+			Days:                     s.Days,
+			StartMinute:              s.StartMinute,
+			EndMinute:                s.EndMinute,
+			TimeZone:                 s.TimeZone,
+			MaxTokens:                s.MaxTokens,
+			TokensToReplenish:        s.TokensToReplenish,
+			ReplenishIntervalSeconds: s.ReplenishIntervalSeconds,
+			Freeze:                   s.Freeze,
+		})
+	}
 	sb := src.GetSequencingBased()
 	tb := src.GetTimeBased()
 	switch {
@@ -199,6 +199,27 @@ func TestConvert_APIAndStorage(t *testing.T) {
 			api:     apiTimeConfig,
 			storage: storageTimeConfig,
 		},
+		{
+			desc: "schedules",
+			api: &quotapb.Config{
+				Name:      "quotas/global/write/config",
+				State:     quotapb.Config_ENABLED,
+				MaxTokens: 100,
+				Schedules: []*quotapb.Schedule{
+					{Days: []int32{0, 6}, StartMinute: 60, EndMinute: 120, TimeZone: "UTC", MaxTokens: 1000},
+					{StartMinute: 1380, EndMinute: 300, TokensToReplenish: 10, ReplenishIntervalSeconds: 5, Freeze: true},
+				},
+			},
+			storage: &storagepb.Config{
+				Name:      "quotas/global/write/config",
+				State:     storagepb.Config_ENABLED,
+				MaxTokens: 100,
+				Schedules: []*storagepb.Schedule{
+					{Days: []int32{0, 6}, StartMinute: 60, EndMinute: 120, TimeZone: "UTC", MaxTokens: 1000},
+					{StartMinute: 1380, EndMinute: 300, TokensToReplenish: 10, ReplenishIntervalSeconds: 5, Freeze: true},
+				},
+			},
+		},
 		{
 			desc:    "zeroed",
 			api:     &quotapb.Config{},
@@ -19,11 +19,15 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/google/trillian/quota"
 )
 
 const (
-	collectionTrees = "trees"
-	wildcard        = "-"
+	collectionTrees    = "trees"
+	collectionUsers    = "users"
+	collectionPrefixes = "prefixes"
+	wildcard           = "-"
 )
 
 var (
@@ -32,7 +36,7 @@ var (
 	// that doesn't make much sense, as if this format is used it has to be a global quota query.
 	globalRE *regexp.Regexp
 
-	// treeUsersRE is a broader regex for all trees/ and users/ name filters.
+	// treeUsersRE is a broader regex for all trees/, users/, prefixes/ and tenants/ name filters.
 	// It doesn't guard against the following specific situations:
 	// 1. quotas/trees/<id>/<kind>/config where id is not an int64
 	// 2. quotas/-/<id>/<kind>/config where id != "-", which is a possibly ambiguous query. It could
@@ -47,7 +51,7 @@ func init() {
 	if err != nil {
 		panic(fmt.Sprintf("globalRE: %v", err))
 	}
-	treesUsersRE, err = regexp.Compile("^quotas/(-|trees|users)/[^/]+/(-|read|write)/config$")
+	treesUsersRE, err = regexp.Compile("^quotas/(-|trees|users|prefixes|tenants)/[^/]+/(-|read|write)/config$")
 	if err != nil {
 		panic(fmt.Sprintf("treesUsersRE: %v", err))
 	}
@@ -81,6 +85,15 @@ func newNameFilter(name string) (nameFilter, error) {
 		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
 			return nil, fmt.Errorf("invalid name filter: %q, ID %q is not a valid 64-bit integer", name, id)
 		}
+	case collectionPrefixes:
+		id := nf[2]
+		if id == wildcard {
+			break
+		}
+		// prefix must be a network in CIDR notation
+		if _, err := quota.ParsePrefix(strings.Replace(id, "_", "/", 1)); err != nil {
+			return nil, fmt.Errorf("invalid name filter: %q, ID %q is not a valid network prefix: %v", name, id, err)
+		}
 	case wildcard:
 		id := nf[2]
 		if id != wildcard {
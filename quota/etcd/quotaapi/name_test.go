@@ -43,6 +43,15 @@ func TestNewNameFilter(t *testing.T) {
 		{name: "quotas/users/llama/-/config"}, // all quotas for user "llama"
 		{name: "quotas/users/-/-/config"},     // all users
 
+		{name: "quotas/prefixes/10.0.0.0_8/read/config"},
+		{name: "quotas/prefixes/2001:db8::_32/write/config"},
+		{name: "quotas/prefixes/-/read/config"},                         // all prefixes/read
+		{name: "quotas/prefixes/10.0.0.1_8/read/config", wantErr: true}, // host bits set
+		{name: "quotas/prefixes/llama/read/config", wantErr: true},      // not a prefix
+
+		{name: "quotas/tenants/acme/read/config"},
+		{name: "quotas/tenants/-/-/config"}, // all tenants
+
 		{name: "quotas/-/1/read/config", wantErr: true}, // not allowed, use either trees/1 or users/1
 		{name: "quotas/-/1/write/config", wantErr: true},
 		{name: "quotas/-/1/-/config", wantErr: true},
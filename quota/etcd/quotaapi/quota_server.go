@@ -17,6 +17,9 @@ package quotaapi
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian/quota/etcd/quotapb"
@@ -121,13 +124,9 @@ func (s *Server) getConfig(ctx context.Context, name string, cfgs *storagepb.Con
 
 // ListConfigs implements quotapb.QuotaServer.ListConfigs.
 func (s *Server) ListConfigs(ctx context.Context, req *quotapb.ListConfigsRequest) (*quotapb.ListConfigsResponse, error) {
-	nfs := make([]nameFilter, 0, len(req.Names))
-	for _, name := range req.Names {
-		nf, err := newNameFilter(name)
-		if err != nil {
-			return nil, status.Error(codes.InvalidArgument, err.Error())
-		}
-		nfs = append(nfs, nf)
+	cf, err := newConfigFilter(req.Names, req.TreeId, req.User)
+	if err != nil {
+		return nil, err
 	}
 
 	cfgs, err := s.qs.Configs(ctx)
@@ -136,7 +135,7 @@ func (s *Server) ListConfigs(ctx context.Context, req *quotapb.ListConfigsReques
 	}
 	resp := &quotapb.ListConfigsResponse{}
 	for _, cfg := range cfgs.Configs {
-		if listMatches(nfs, cfg) {
+		if cf.matches(cfg) {
 			resp.Configs = append(resp.Configs, listView(req.View, cfg))
 		}
 	}
@@ -159,6 +158,100 @@ func (s *Server) ListConfigs(ctx context.Context, req *quotapb.ListConfigsReques
 	return resp, nil
 }
 
+// GetUsage implements quotapb.QuotaServer.GetUsage.
+func (s *Server) GetUsage(ctx context.Context, req *quotapb.GetUsageRequest) (*quotapb.GetUsageResponse, error) {
+	cf, err := newConfigFilter(req.Names, req.TreeId, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgs, err := s.qs.Configs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &quotapb.GetUsageResponse{}
+	var names []string
+	now := time.Now()
+	for _, cfg := range cfgs.Configs {
+		if !cf.matches(cfg) {
+			continue
+		}
+		// Report the max tokens in effect, which may be overridden by a schedule.
+		cfg, _ = storage.ScheduledConfig(cfg, now)
+		resp.Usages = append(resp.Usages, &quotapb.Usage{
+			Name:      cfg.Name,
+			State:     quotapb.Config_State(quotapb.Config_State_value[cfg.State.String()]),
+			MaxTokens: cfg.MaxTokens,
+		})
+		if cfg.State == storagepb.Config_ENABLED {
+			names = append(names, cfg.Name)
+		}
+	}
+	if len(names) == 0 {
+		return resp, nil
+	}
+
+	// Unlike ListConfigs, peeking is the point of GetUsage, so errors are returned.
+	tokens, err := s.qs.Peek(ctx, names)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error peeking token counts: %v", err)
+	}
+	for _, u := range resp.Usages {
+		if u.State != quotapb.Config_ENABLED {
+			continue
+		}
+		u.CurrentTokens = tokens[u.Name]
+		u.UsedTokens = u.MaxTokens - u.CurrentTokens
+	}
+	return resp, nil
+}
+
+// configFilter selects the configs returned by ListConfigs and GetUsage.
+type configFilter struct {
+	names []nameFilter
+	// prefixes holds the name prefixes of the requested tree and user configs, if any.
+	prefixes []string
+}
+
+func newConfigFilter(names []string, treeID int64, user string) (*configFilter, error) {
+	cf := &configFilter{names: make([]nameFilter, 0, len(names))}
+	for _, name := range names {
+		nf, err := newNameFilter(name)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		cf.names = append(cf.names, nf)
+	}
+	switch {
+	case treeID < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tree_id: %v", treeID)
+	case treeID > 0:
+		cf.prefixes = append(cf.prefixes, fmt.Sprintf("quotas/%v/%v/", collectionTrees, treeID))
+	}
+	if user != "" {
+		if user == wildcard || strings.Contains(user, "/") {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid user: %q", user)
+		}
+		cf.prefixes = append(cf.prefixes, fmt.Sprintf("quotas/%v/%v/", collectionUsers, user))
+	}
+	return cf, nil
+}
+
+func (cf *configFilter) matches(cfg *storagepb.Config) bool {
+	if !listMatches(cf.names, cfg) {
+		return false
+	}
+	if len(cf.prefixes) == 0 {
+		return true
+	}
+	for _, p := range cf.prefixes {
+		if strings.HasPrefix(cfg.Name, p) {
+			return true
+		}
+	}
+	return false
+}
+
 func listMatches(nfs []nameFilter, cfg *storagepb.Config) bool {
 	if len(nfs) == 0 {
 		return true // Match all
@@ -712,6 +712,24 @@ func TestServer_ListConfigs(t *testing.T) {
 			req:      &quotapb.ListConfigsRequest{Names: []string{"quotas/users/-/-/config"}},
 			wantCfgs: []*quotapb.Config{basicUserRead, basicUserWrite},
 		},
+		{
+			desc:     "treeID",
+			req:      &quotapb.ListConfigsRequest{TreeId: 2},
+			wantCfgs: []*quotapb.Config{basicTree2Read, basicTree2Write},
+		},
+		{
+			desc:     "treeIDAndUser",
+			req:      &quotapb.ListConfigsRequest{TreeId: 1, User: "llama"},
+			wantCfgs: []*quotapb.Config{basicTree1Read, basicTree1Write, basicUserRead, basicUserWrite},
+		},
+		{
+			desc: "userAndNames",
+			req: &quotapb.ListConfigsRequest{
+				Names: []string{"quotas/-/-/read/config"},
+				User:  "llama",
+			},
+			wantCfgs: []*quotapb.Config{basicUserRead},
+		},
 		{
 			desc: "unknowns",
 			req: &quotapb.ListConfigsRequest{
@@ -761,6 +779,16 @@ func TestServer_ListConfigsErrors(t *testing.T) {
 			req:      &quotapb.ListConfigsRequest{Names: []string{"bad/quota/name"}},
 			wantCode: codes.InvalidArgument,
 		},
+		{
+			desc:     "negativeTreeID",
+			req:      &quotapb.ListConfigsRequest{TreeId: -1},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:     "badUser",
+			req:      &quotapb.ListConfigsRequest{User: "llama/read"},
+			wantCode: codes.InvalidArgument,
+		},
 	}
 
 	ctx := context.Background()
@@ -772,6 +800,94 @@ func TestServer_ListConfigsErrors(t *testing.T) {
 	}
 }
 
+func TestServer_GetUsage(t *testing.T) {
+	ctx := context.Background()
+	if err := reset(ctx); err != nil {
+		t.Fatalf("reset() returned err = %v", err)
+	}
+
+	disabledTree2Read := proto.Clone(tree2Read).(*quotapb.Config)
+	disabledTree2Read.State = quotapb.Config_DISABLED
+	for _, cfg := range []*quotapb.Config{globalWrite, tree1Write, disabledTree2Read, userWrite} {
+		if _, err := quotaClient.CreateConfig(ctx, &quotapb.CreateConfigRequest{
+			Name:   cfg.Name,
+			Config: cfg,
+		}); err != nil {
+			t.Fatalf("%q: CreateConfig() returned err = %v", cfg.Name, err)
+		}
+	}
+	qs := storage.QuotaStorage{Client: etcdClient}
+	if err := qs.Get(ctx, []string{tree1Write.Name}, 30); err != nil {
+		t.Fatalf("Get() returned err = %v", err)
+	}
+
+	usage := func(cfg *quotapb.Config, current int64) *quotapb.Usage {
+		u := &quotapb.Usage{Name: cfg.Name, State: cfg.State, MaxTokens: cfg.MaxTokens}
+		if cfg.State == quotapb.Config_ENABLED {
+			u.CurrentTokens = current
+			u.UsedTokens = cfg.MaxTokens - current
+		}
+		return u
+	}
+	globalUsage := usage(globalWrite, 100)
+	tree1Usage := usage(tree1Write, 70)
+	tree2Usage := usage(disabledTree2Read, 0)
+	userUsage := usage(userWrite, 100)
+
+	tests := []struct {
+		desc string
+		req  *quotapb.GetUsageRequest
+		want []*quotapb.Usage
+	}{
+		{
+			desc: "all",
+			req:  &quotapb.GetUsageRequest{},
+			want: []*quotapb.Usage{globalUsage, tree1Usage, tree2Usage, userUsage},
+		},
+		{
+			desc: "names",
+			req:  &quotapb.GetUsageRequest{Names: []string{"quotas/global/write/config", "quotas/-/-/write/config"}},
+			want: []*quotapb.Usage{globalUsage, tree1Usage, userUsage},
+		},
+		{
+			desc: "treeID",
+			req:  &quotapb.GetUsageRequest{TreeId: 1},
+			want: []*quotapb.Usage{tree1Usage},
+		},
+		{
+			desc: "disabled",
+			req:  &quotapb.GetUsageRequest{TreeId: 2},
+			want: []*quotapb.Usage{tree2Usage},
+		},
+		{
+			desc: "user",
+			req:  &quotapb.GetUsageRequest{User: "llama"},
+			want: []*quotapb.Usage{userUsage},
+		},
+		{
+			desc: "unknown",
+			req:  &quotapb.GetUsageRequest{TreeId: 99999},
+		},
+	}
+	for _, test := range tests {
+		resp, err := quotaClient.GetUsage(ctx, test.req)
+		if err != nil {
+			t.Errorf("%v: GetUsage() returned err = %v", test.desc, err)
+			continue
+		}
+		got := resp.Usages
+		sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+		sort.Slice(test.want, func(i, j int) bool { return test.want[i].Name < test.want[j].Name })
+		if diff := cmp.Diff(got, test.want, cmp.Comparer(proto.Equal)); diff != "" {
+			t.Errorf("%v: GetUsage() diff (-got +want):\n%v", test.desc, diff)
+		}
+	}
+
+	if _, err := quotaClient.GetUsage(ctx, &quotapb.GetUsageRequest{Names: []string{"bad/quota/name"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetUsage() with bad name returned err = %v, want code %s", err, codes.InvalidArgument)
+	}
+}
+
 func reset(ctx context.Context) error {
 	qs := storage.QuotaStorage{Client: etcdClient}
 	_, err := qs.UpdateConfigs(ctx, true /* reset */, func(c *storagepb.Configs) { *c = storagepb.Configs{} })
@@ -32,6 +32,9 @@ type QuotaClient interface {
 	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*Config, error)
 	// Lists quotas according to the specified criteria.
 	ListConfigs(ctx context.Context, in *ListConfigsRequest, opts ...grpc.CallOption) (*ListConfigsResponse, error)
+	// Returns the current token counts of quotas, so operators can see how close
+	// they are to their limits.
+	GetUsage(ctx context.Context, in *GetUsageRequest, opts ...grpc.CallOption) (*GetUsageResponse, error)
 	// Updates a quota.
 	UpdateConfig(ctx context.Context, in *UpdateConfigRequest, opts ...grpc.CallOption) (*Config, error)
 }
@@ -80,6 +83,15 @@ func (c *quotaClient) ListConfigs(ctx context.Context, in *ListConfigsRequest, o
 	return out, nil
 }
 
+func (c *quotaClient) GetUsage(ctx context.Context, in *GetUsageRequest, opts ...grpc.CallOption) (*GetUsageResponse, error) {
+	out := new(GetUsageResponse)
+	err := c.cc.Invoke(ctx, "/quotapb.Quota/GetUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *quotaClient) UpdateConfig(ctx context.Context, in *UpdateConfigRequest, opts ...grpc.CallOption) (*Config, error) {
 	out := new(Config)
 	err := c.cc.Invoke(ctx, "/quotapb.Quota/UpdateConfig", in, out, opts...)
@@ -102,6 +114,9 @@ type QuotaServer interface {
 	GetConfig(context.Context, *GetConfigRequest) (*Config, error)
 	// Lists quotas according to the specified criteria.
 	ListConfigs(context.Context, *ListConfigsRequest) (*ListConfigsResponse, error)
+	// Returns the current token counts of quotas, so operators can see how close
+	// they are to their limits.
+	GetUsage(context.Context, *GetUsageRequest) (*GetUsageResponse, error)
 	// Updates a quota.
 	UpdateConfig(context.Context, *UpdateConfigRequest) (*Config, error)
 }
@@ -122,6 +137,9 @@ func (UnimplementedQuotaServer) GetConfig(context.Context, *GetConfigRequest) (*
 func (UnimplementedQuotaServer) ListConfigs(context.Context, *ListConfigsRequest) (*ListConfigsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListConfigs not implemented")
 }
+func (UnimplementedQuotaServer) GetUsage(context.Context, *GetUsageRequest) (*GetUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsage not implemented")
+}
 func (UnimplementedQuotaServer) UpdateConfig(context.Context, *UpdateConfigRequest) (*Config, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateConfig not implemented")
 }
@@ -209,6 +227,24 @@ func _Quota_ListConfigs_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Quota_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuotaServer).GetUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/quotapb.Quota/GetUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuotaServer).GetUsage(ctx, req.(*GetUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Quota_UpdateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateConfigRequest)
 	if err := dec(in); err != nil {
@@ -250,6 +286,10 @@ var Quota_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListConfigs",
 			Handler:    _Quota_ListConfigs_Handler,
 		},
+		{
+			MethodName: "GetUsage",
+			Handler:    _Quota_GetUsage_Handler,
+		},
 		{
 			MethodName: "UpdateConfig",
 			Handler:    _Quota_UpdateConfig_Handler,
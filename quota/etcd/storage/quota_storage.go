@@ -42,9 +42,11 @@ const (
 var (
 	timeSource = clock.System
 
-	globalPattern *regexp.Regexp
-	treesPattern  *regexp.Regexp
-	usersPattern  *regexp.Regexp
+	globalPattern   *regexp.Regexp
+	treesPattern    *regexp.Regexp
+	usersPattern    *regexp.Regexp
+	prefixesPattern *regexp.Regexp
+	tenantsPattern  *regexp.Regexp
 )
 
 func init() {
@@ -61,6 +63,14 @@ func init() {
 	if err != nil {
 		glog.Fatalf("bad users pattern: %v", err)
 	}
+	prefixesPattern, err = regexp.Compile("^quotas/prefixes/[^/]+/(read|write)/config$")
+	if err != nil {
+		glog.Fatalf("bad prefixes pattern: %v", err)
+	}
+	tenantsPattern, err = regexp.Compile("^quotas/tenants/[^/]+/(read|write)/config$")
+	if err != nil {
+		glog.Fatalf("bad tenants pattern: %v", err)
+	}
 }
 
 // IsNameValid returns true if name is a valid quota name.
@@ -68,8 +78,12 @@ func IsNameValid(name string) bool {
 	switch {
 	case globalPattern.MatchString(name):
 		return true
-	case usersPattern.MatchString(name):
+	case usersPattern.MatchString(name), tenantsPattern.MatchString(name):
 		return true
+	case prefixesPattern.MatchString(name):
+		// Prefix must be a network in CIDR notation
+		_, err := quota.ParsePrefix(namePrefix(name))
+		return err == nil
 	case treesPattern.MatchString(name):
 		// Tree ID must fit on an int64
 		id := strings.Split(name, "/")[2]
@@ -79,6 +93,11 @@ func IsNameValid(name string) bool {
 	return false
 }
 
+// namePrefix returns the network prefix of a quotas/prefixes/ config name.
+func namePrefix(name string) string {
+	return strings.Replace(strings.Split(name, "/")[2], "_", "/", 1)
+}
+
 // QuotaStorage is the interface between the etcd-based quota implementations (quota.Manager and
 // RPCs) and etcd itself.
 type QuotaStorage struct {
@@ -189,6 +208,9 @@ func validate(cfgs *storagepb.Configs) error {
 			if usersPattern.MatchString(cfg.Name) {
 				return status.Errorf(codes.InvalidArgument, "user quotas cannot use sequencing-based replenishment (Configs[%v].ReplenishmentStrategy)", i)
 			}
+			if prefixesPattern.MatchString(cfg.Name) || tenantsPattern.MatchString(cfg.Name) {
+				return status.Errorf(codes.InvalidArgument, "prefix and tenant quotas cannot use sequencing-based replenishment (Configs[%v].ReplenishmentStrategy)", i)
+			}
 			if strings.HasSuffix(cfg.Name, "/read/config") {
 				return status.Errorf(codes.InvalidArgument, "read quotas cannot use sequencing-based replenishment (Configs[%v].ReplenishmentStrategy)", i)
 			}
@@ -202,6 +224,9 @@ func validate(cfgs *storagepb.Configs) error {
 		default:
 			return status.Errorf(codes.InvalidArgument, "unsupported replenishment strategy (Configs[%v].ReplenishmentStrategy = %T)", i, s)
 		}
+		if err := validateSchedules(cfg, i); err != nil {
+			return err
+		}
 		if names[cfg.Name] {
 			return status.Errorf(codes.InvalidArgument, "duplicate config name found at Configs[%v].Name", i)
 		}
@@ -242,7 +267,9 @@ func (qs *QuotaStorage) mod(ctx context.Context, names []string, add int64) erro
 
 // Peek returns a map of quota name to tokens for the named quotas.
 // Unknown or disabled quotas are considered infinite and returned as having quota.MaxTokens tokens,
-// therefore all requested names are guaranteed to be in the resulting map
+// therefore all requested names are guaranteed to be in the resulting map.
+// Prefix quotas are returned with the tokens of the most limiting prefix that contains them.
+// Quotas frozen by their schedule are returned as having zero tokens.
 func (qs *QuotaStorage) Peek(ctx context.Context, names []string) (map[string]int64, error) {
 	now := timeSource.Now()
 	tokens := make(map[string]int64)
@@ -254,7 +281,9 @@ func (qs *QuotaStorage) Peek(ctx context.Context, names []string) (map[string]in
 		} else {
 			t, err = modBucket(s, cfg, now, 0 /* add */)
 		}
-		tokens[name] = t
+		if prev, ok := tokens[name]; !ok || t < prev {
+			tokens[name] = t
+		}
 		return err
 	})
 	return tokens, err
@@ -305,6 +334,9 @@ const (
 // transaction.
 // By default, fn is only called for known, enabled configs. See forNamesMode for other behaviors.
 // Names are validated and de-duped automatically.
+// Prefix names specify the configs of all prefixes that contain them, so, for example,
+// "quotas/prefixes/10.1.2.3_32/read/config" calls fn for the "10.0.0.0_8" and "10.1.0.0_16"
+// configs, if they exist. fn is called with the specified name in this case.
 func (qs *QuotaStorage) forNames(ctx context.Context, names []string, mode forNamesMode, fn func(concurrency.STM, string, *storagepb.Config) error) error {
 	for _, name := range names {
 		if !IsNameValid(name) {
@@ -326,15 +358,12 @@ func (qs *QuotaStorage) forNames(ctx context.Context, names []string, mode forNa
 			seenNames[name] = true
 
 			emitted := false
-			for _, cfg := range cfgs.Configs {
-				if cfg.Name == name {
-					if cfg.State == storagepb.Config_ENABLED {
-						if err := fn(s, name, cfg); err != nil {
-							return err
-						}
-						emitted = true
+			for _, cfg := range matchingConfigs(cfgs, name) {
+				if cfg.State == storagepb.Config_ENABLED {
+					if err := fn(s, name, cfg); err != nil {
+						return err
 					}
-					break
+					emitted = true
 				}
 			}
 			if !emitted && mode == emitInfinite {
@@ -348,6 +377,34 @@ func (qs *QuotaStorage) forNames(ctx context.Context, names []string, mode forNa
 	return err
 }
 
+// matchingConfigs returns the configs specified by name, as described in forNames.
+func matchingConfigs(cfgs *storagepb.Configs, name string) []*storagepb.Config {
+	if !prefixesPattern.MatchString(name) {
+		for _, cfg := range cfgs.Configs {
+			if cfg.Name == name {
+				return []*storagepb.Config{cfg}
+			}
+		}
+		return nil
+	}
+
+	kind := strings.Split(name, "/")[3]
+	prefixToConfig := make(map[string]*storagepb.Config)
+	var prefixes []string
+	for _, cfg := range cfgs.Configs {
+		if prefixesPattern.MatchString(cfg.Name) && strings.Split(cfg.Name, "/")[3] == kind {
+			p := namePrefix(cfg.Name)
+			prefixToConfig[p] = cfg
+			prefixes = append(prefixes, p)
+		}
+	}
+	var matches []*storagepb.Config
+	for _, p := range quota.ContainingPrefixes(namePrefix(name), prefixes) {
+		matches = append(matches, prefixToConfig[p])
+	}
+	return matches
+}
+
 func getConfigs(s concurrency.STM) (*storagepb.Configs, error) {
 	// TODO(codingllama): Consider watching configs instead of re-reading
 	cfgs := &storagepb.Configs{}
@@ -368,9 +425,15 @@ func getConfigs(s concurrency.STM) (*storagepb.Configs, error) {
 // that are above ceiling (eg, due to lowered max tokens) will also be constrained to the
 // appropriate ceiling. As a consequence, calls with add = 0 are still useful for peeking and the
 // explained side-effects.
-// modBucket returns the current token count for cfg.
+// The settings of cfg may be overridden by its schedule active at now, and frozen quotas reject
+// negative adds.
+// modBucket returns the current token count for cfg, which is zero for frozen quotas.
 func modBucket(s concurrency.STM, cfg *storagepb.Config, now time.Time, add int64) (int64, error) {
 	key := bucketKey(cfg)
+	cfg, frozen := ScheduledConfig(cfg, now)
+	if frozen && add < 0 {
+		return 0, fmt.Errorf("%v is frozen by its schedule", cfg.Name)
+	}
 
 	val := s.Get(key)
 	var prevBucket storagepb.Bucket
@@ -408,6 +471,9 @@ func modBucket(s concurrency.STM, cfg *storagepb.Config, now time.Time, add int6
 		s.Put(key, string(pb))
 	}
 
+	if frozen {
+		return 0, nil
+	}
 	return newBucket.Tokens, nil
 }
 
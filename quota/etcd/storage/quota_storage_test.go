@@ -102,6 +102,9 @@ func TestIsNameValid(t *testing.T) {
 		{name: "quotas/global/write/config", want: true},
 		{name: "quotas/trees/12356/read/config", want: true},
 		{name: "quotas/users/llama/write/config", want: true},
+		{name: "quotas/prefixes/10.0.0.0_8/read/config", want: true},
+		{name: "quotas/prefixes/2001:db8::_32/write/config", want: true},
+		{name: "quotas/tenants/acme/write/config", want: true},
 
 		{name: "bad/quota/name"},
 		{name: "badprefix/quotas/global/read/config"},
@@ -110,6 +113,9 @@ func TestIsNameValid(t *testing.T) {
 		{name: "quotas/global/bad/config"},
 		{name: "quotas/trees/bad/read/config"},
 		{name: "quotas/trees/11111111111111111111/read/config"}, // ID > MaxInt64
+		{name: "quotas/prefixes/10.0.0.1_8/read/config"},        // host bits set
+		{name: "quotas/prefixes/10.0.0.0/8/read/config"},        // "/" not replaced
+		{name: "quotas/prefixes/llama/read/config"},
 	}
 	for _, test := range tests {
 		if got := IsNameValid(test.name); got != test.want {
@@ -286,6 +292,18 @@ func TestQuotaStorage_UpdateConfigsErrors(t *testing.T) {
 
 	duplicateNames := &storagepb.Configs{Configs: []*storagepb.Config{globalRead, globalWrite, globalWrite}}
 
+	invalidScheduleDay := deepCopy(globalWriteCfgs)
+	invalidScheduleDay.Configs[0].Schedules = []*storagepb.Schedule{{Days: []int32{7}, EndMinute: 60}}
+
+	invalidScheduleMinute := deepCopy(globalWriteCfgs)
+	invalidScheduleMinute.Configs[0].Schedules = []*storagepb.Schedule{{EndMinute: 24 * 60}}
+
+	invalidScheduleTimeZone := deepCopy(globalWriteCfgs)
+	invalidScheduleTimeZone.Configs[0].Schedules = []*storagepb.Schedule{{EndMinute: 60, TimeZone: "Not/A_Zone"}}
+
+	sequencingBasedScheduleReplenishment := deepCopy(globalWriteCfgs)
+	sequencingBasedScheduleReplenishment.Configs[0].Schedules = []*storagepb.Schedule{{EndMinute: 60, TokensToReplenish: 10}}
+
 	sequencingBasedStrategy := &storagepb.Config_SequencingBased{SequencingBased: &storagepb.SequencingBasedStrategy{}}
 	sequencingBasedUserQuota := &storagepb.Configs{
 		Configs: []*storagepb.Config{
@@ -372,6 +390,26 @@ func TestQuotaStorage_UpdateConfigsErrors(t *testing.T) {
 			update:  updater(duplicateNames),
 			wantErr: "duplicate config name",
 		},
+		{
+			desc:    "invalidScheduleDay",
+			update:  updater(invalidScheduleDay),
+			wantErr: "schedule day must be between 0 and 6",
+		},
+		{
+			desc:    "invalidScheduleMinute",
+			update:  updater(invalidScheduleMinute),
+			wantErr: "schedule end minute must be between 0 and 1439",
+		},
+		{
+			desc:    "invalidScheduleTimeZone",
+			update:  updater(invalidScheduleTimeZone),
+			wantErr: "schedule time zone invalid",
+		},
+		{
+			desc:    "sequencingBasedScheduleReplenishment",
+			update:  updater(sequencingBasedScheduleReplenishment),
+			wantErr: "only apply to time-based quotas",
+		},
 		{
 			desc:    "sequencingBasedUserQuota",
 			update:  updater(sequencingBasedUserQuota),
@@ -594,6 +632,159 @@ func TestQuotaStorage_Get(t *testing.T) {
 	}
 }
 
+func TestQuotaStorage_GetPrefixes(t *testing.T) {
+	defer setupTimeSource(clock.NewFake(time.Now()))()
+
+	newPrefixConfig := func(name string, maxTokens int64) *storagepb.Config {
+		return &storagepb.Config{
+			Name:      name,
+			State:     storagepb.Config_ENABLED,
+			MaxTokens: maxTokens,
+			ReplenishmentStrategy: &storagepb.Config_TimeBased{
+				TimeBased: &storagepb.TimeBasedStrategy{
+					ReplenishIntervalSeconds: 100,
+					TokensToReplenish:        100,
+				},
+			},
+		}
+	}
+	prefix8 := newPrefixConfig("quotas/prefixes/10.0.0.0_8/read/config", 100)
+	prefix16 := newPrefixConfig("quotas/prefixes/10.1.0.0_16/read/config", 10)
+	prefixCfgs := &storagepb.Configs{Configs: []*storagepb.Config{prefix8, prefix16}}
+
+	const (
+		host1 = "quotas/prefixes/10.1.2.3_32/read/config"
+		host2 = "quotas/prefixes/10.2.3.4_32/read/config"
+		host3 = "quotas/prefixes/192.168.0.1_32/read/config"
+	)
+
+	tests := []struct {
+		desc       string
+		names      []string
+		tokens     int64
+		wantTokens map[string]int64
+	}{
+		{
+			desc:   "nestedPrefixes",
+			names:  []string{host1},
+			tokens: 3,
+			wantTokens: map[string]int64{
+				prefix8.Name:  prefix8.MaxTokens - 3,
+				prefix16.Name: prefix16.MaxTokens - 3,
+				host1:         prefix16.MaxTokens - 3, // most limiting prefix
+			},
+		},
+		{
+			desc:   "outerPrefix",
+			names:  []string{host2},
+			tokens: 3,
+			wantTokens: map[string]int64{
+				prefix8.Name:  prefix8.MaxTokens - 3,
+				prefix16.Name: prefix16.MaxTokens,
+				host2:         prefix8.MaxTokens - 3,
+			},
+		},
+		{
+			desc:   "noPrefix",
+			names:  []string{host3},
+			tokens: 3,
+			wantTokens: map[string]int64{
+				prefix8.Name:  prefix8.MaxTokens,
+				prefix16.Name: prefix16.MaxTokens,
+				host3:         quotaMaxTokens,
+			},
+		},
+	}
+
+	ctx := context.Background()
+	qs := &QuotaStorage{Client: client}
+	for _, test := range tests {
+		if err := setupTokens(ctx, qs, prefixCfgs, nil /* initialTokens */); err != nil {
+			t.Errorf("%v: setupTokens() returned err = %v", test.desc, err)
+			continue
+		}
+		if err := qs.Get(ctx, test.names, test.tokens); err != nil {
+			t.Errorf("%v: Get() returned err = %v", test.desc, err)
+			continue
+		}
+		if err := peekAndDiff(ctx, qs, test.wantTokens); err != nil {
+			t.Errorf("%v: %v", test.desc, err)
+		}
+	}
+
+	// Tokens are denied if any of the containing prefixes is exhausted.
+	if err := setupTokens(ctx, qs, prefixCfgs, nil /* initialTokens */); err != nil {
+		t.Fatalf("setupTokens() returned err = %v", err)
+	}
+	if err := qs.Get(ctx, []string{host1}, prefix16.MaxTokens+1); err == nil {
+		t.Errorf("Get(%v) returned err = nil, want non-nil", prefix16.MaxTokens+1)
+	}
+}
+
+func TestQuotaStorage_Schedules(t *testing.T) {
+	// Monday, 10:00 UTC.
+	fakeTime := clock.NewFake(time.Date(2022, time.June, 6, 10, 0, 0, 0, time.UTC))
+	defer setupTimeSource(fakeTime)()
+
+	cfg := &storagepb.Config{
+		Name:      "quotas/users/llama/write/config",
+		State:     storagepb.Config_ENABLED,
+		MaxTokens: 10,
+		ReplenishmentStrategy: &storagepb.Config_TimeBased{
+			TimeBased: &storagepb.TimeBasedStrategy{
+				ReplenishIntervalSeconds: 60,
+				TokensToReplenish:        1,
+			},
+		},
+		Schedules: []*storagepb.Schedule{
+			// Maintenance freeze on Mondays, from 11:00 to 12:00.
+			{Days: []int32{1}, StartMinute: 11 * 60, EndMinute: 12 * 60, Freeze: true},
+			// Backfills at night, from 22:00 to 06:00.
+			{StartMinute: 22 * 60, EndMinute: 6 * 60, MaxTokens: 1000, TokensToReplenish: 1000},
+		},
+	}
+	names := []string{cfg.Name}
+
+	ctx := context.Background()
+	qs := &QuotaStorage{Client: client}
+	if err := setupTokens(ctx, qs, &storagepb.Configs{Configs: []*storagepb.Config{cfg}}, nil); err != nil {
+		t.Fatalf("setupTokens() returned err = %v", err)
+	}
+	if err := qs.Get(ctx, names, 10); err != nil {
+		t.Fatalf("Get() returned err = %v", err)
+	}
+
+	// Frozen: no tokens may be acquired, even after replenishment.
+	fakeTime.Set(fakeTime.Now().Add(90 * time.Minute))
+	if err := peekAndDiff(ctx, qs, map[string]int64{cfg.Name: 0}); err != nil {
+		t.Errorf("frozen: %v", err)
+	}
+	if err := qs.Get(ctx, names, 1); err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Errorf("frozen: Get() returned err = %v, want frozen error", err)
+	}
+
+	// Daytime: tokens replenished during and after the freeze are available.
+	fakeTime.Set(fakeTime.Now().Add(time.Hour))
+	if err := peekAndDiff(ctx, qs, map[string]int64{cfg.Name: 2}); err != nil {
+		t.Errorf("daytime: %v", err)
+	}
+
+	// Night: the quota is replenished well above its daytime maximum.
+	fakeTime.Set(time.Date(2022, time.June, 7, 1, 0, 0, 0, time.UTC))
+	if err := peekAndDiff(ctx, qs, map[string]int64{cfg.Name: 1000}); err != nil {
+		t.Errorf("night: %v", err)
+	}
+	if err := qs.Get(ctx, names, 500); err != nil {
+		t.Errorf("night: Get() returned err = %v", err)
+	}
+
+	// Morning: back to the daytime maximum.
+	fakeTime.Set(time.Date(2022, time.June, 7, 7, 0, 0, 0, time.UTC))
+	if err := peekAndDiff(ctx, qs, map[string]int64{cfg.Name: 10}); err != nil {
+		t.Errorf("morning: %v", err)
+	}
+}
+
 func TestQuotaStorage_GetErrors(t *testing.T) {
 	tests := []struct {
 		desc   string
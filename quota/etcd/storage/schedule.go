@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/quota/etcd/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const minutesPerDay = 24 * 60
+
+// locations caches the time zones of schedules, by name.
+var locations sync.Map
+
+// ScheduledConfig returns cfg with the settings overridden by its schedule active at now, if any,
+// and whether the schedule freezes the quota.
+func ScheduledConfig(cfg *storagepb.Config, now time.Time) (*storagepb.Config, bool) {
+	var active *storagepb.Schedule
+	for _, s := range cfg.Schedules {
+		if inWindow(s, now) {
+			active = s
+			break
+		}
+	}
+	if active == nil {
+		return cfg, false
+	}
+
+	scheduled := proto.Clone(cfg).(*storagepb.Config)
+	if active.MaxTokens > 0 {
+		scheduled.MaxTokens = active.MaxTokens
+	}
+	if tb := scheduled.GetTimeBased(); tb != nil {
+		if active.TokensToReplenish > 0 {
+			tb.TokensToReplenish = active.TokensToReplenish
+		}
+		if active.ReplenishIntervalSeconds > 0 {
+			tb.ReplenishIntervalSeconds = active.ReplenishIntervalSeconds
+		}
+	}
+	return scheduled, active.Freeze
+}
+
+// inWindow returns true if now is within the window of s. Windows that end on the next day are
+// checked both from the current and from the previous day.
+func inWindow(s *storagepb.Schedule, now time.Time) bool {
+	loc, err := location(s.TimeZone)
+	if err != nil {
+		return false // Rejected by validation
+	}
+	t := now.In(loc)
+	minute := int32(t.Hour()*60 + t.Minute())
+	day := t.Weekday()
+	if s.StartMinute < s.EndMinute {
+		return startsOn(s, day) && s.StartMinute <= minute && minute < s.EndMinute
+	}
+	if minute >= s.StartMinute {
+		return startsOn(s, day)
+	}
+	return minute < s.EndMinute && startsOn(s, (day+6)%7)
+}
+
+func startsOn(s *storagepb.Schedule, day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+func validateSchedules(cfg *storagepb.Config, i int) error {
+	for j, s := range cfg.Schedules {
+		field := fmt.Sprintf("Configs[%v].Schedules[%v]", i, j)
+		for _, d := range s.Days {
+			if d < 0 || d > 6 {
+				return status.Errorf(codes.InvalidArgument, "schedule day must be between 0 and 6 (%v.Days = %v)", field, s.Days)
+			}
+		}
+		if m := s.StartMinute; m < 0 || m >= minutesPerDay {
+			return status.Errorf(codes.InvalidArgument, "schedule start minute must be between 0 and %v (%v.StartMinute = %v)", minutesPerDay-1, field, m)
+		}
+		if m := s.EndMinute; m < 0 || m >= minutesPerDay {
+			return status.Errorf(codes.InvalidArgument, "schedule end minute must be between 0 and %v (%v.EndMinute = %v)", minutesPerDay-1, field, m)
+		}
+		if _, err := location(s.TimeZone); err != nil {
+			return status.Errorf(codes.InvalidArgument, "schedule time zone invalid (%v.TimeZone = %q): %v", field, s.TimeZone, err)
+		}
+		if s.MaxTokens < 0 || s.TokensToReplenish < 0 || s.ReplenishIntervalSeconds < 0 {
+			return status.Errorf(codes.InvalidArgument, "schedule overrides must be >= 0 (%v)", field)
+		}
+		if (s.TokensToReplenish > 0 || s.ReplenishIntervalSeconds > 0) && cfg.GetTimeBased() == nil {
+			return status.Errorf(codes.InvalidArgument, "schedule replenishment overrides only apply to time-based quotas (%v)", field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/quota/etcd/storagepb"
+)
+
+func TestScheduledConfig(t *testing.T) {
+	cfg := &storagepb.Config{
+		Name:      "quotas/global/write/config",
+		State:     storagepb.Config_ENABLED,
+		MaxTokens: 100,
+		ReplenishmentStrategy: &storagepb.Config_TimeBased{
+			TimeBased: &storagepb.TimeBasedStrategy{TokensToReplenish: 10, ReplenishIntervalSeconds: 60},
+		},
+		Schedules: []*storagepb.Schedule{
+			// Saturdays, from 09:00 to 10:00 in New York.
+			{Days: []int32{6}, StartMinute: 9 * 60, EndMinute: 10 * 60, TimeZone: "America/New_York", Freeze: true},
+			// Fridays, from 22:00 to 02:00.
+			{Days: []int32{5}, StartMinute: 22 * 60, EndMinute: 2 * 60, MaxTokens: 1000, ReplenishIntervalSeconds: 1},
+		},
+	}
+	for _, test := range []struct {
+		desc       string
+		now        time.Time
+		wantMax    int64
+		wantPeriod int64
+		wantFrozen bool
+	}{
+		{desc: "none", now: time.Date(2022, time.June, 3, 21, 59, 0, 0, time.UTC), wantMax: 100, wantPeriod: 60},
+		{desc: "start", now: time.Date(2022, time.June, 3, 22, 0, 0, 0, time.UTC), wantMax: 1000, wantPeriod: 1},
+		{desc: "nextDay", now: time.Date(2022, time.June, 4, 1, 59, 0, 0, time.UTC), wantMax: 1000, wantPeriod: 1},
+		{desc: "end", now: time.Date(2022, time.June, 4, 2, 0, 0, 0, time.UTC), wantMax: 100, wantPeriod: 60},
+		{desc: "otherDay", now: time.Date(2022, time.June, 5, 1, 0, 0, 0, time.UTC), wantMax: 100, wantPeriod: 60},
+		{desc: "timeZone", now: time.Date(2022, time.June, 4, 13, 30, 0, 0, time.UTC), wantMax: 100, wantPeriod: 60, wantFrozen: true},
+	} {
+		got, frozen := ScheduledConfig(cfg, test.now)
+		if got.MaxTokens != test.wantMax || got.GetTimeBased().ReplenishIntervalSeconds != test.wantPeriod || frozen != test.wantFrozen {
+			t.Errorf("%v: ScheduledConfig() = (max %v, period %v, %v), want (max %v, period %v, %v)", test.desc,
+				got.MaxTokens, got.GetTimeBased().ReplenishIntervalSeconds, frozen, test.wantMax, test.wantPeriod, test.wantFrozen)
+		}
+	}
+	if cfg.MaxTokens != 100 {
+		t.Errorf("ScheduledConfig() modified cfg")
+	}
+}
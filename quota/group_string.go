@@ -11,11 +11,13 @@ func _() {
 	_ = x[Global-0]
 	_ = x[Tree-1]
 	_ = x[User-2]
+	_ = x[Prefix-3]
+	_ = x[Tenant-4]
 }
 
-const _Group_name = "GlobalTreeUser"
+const _Group_name = "GlobalTreeUserPrefixTenant"
 
-var _Group_index = [...]uint8{0, 6, 10, 14}
+var _Group_index = [...]uint8{0, 6, 10, 14, 20, 26}
 
 func (i Group) String() string {
 	if i < 0 || i >= Group(len(_Group_index)-1) {
@@ -59,8 +59,8 @@ func (m *m) add(c monitoring.Counter, tokens int, specs []Spec, success bool) {
 		return
 	}
 	for _, spec := range specs {
-		if spec.Group == User {
-			// Don't populate per-user labels.
+		if spec.Group == User || spec.Group == Prefix || spec.Group == Tenant {
+			// Don't populate per-user, per-network or per-tenant labels.
 			continue
 		}
 		c.Add(float64(tokens), spec.Name(), fmt.Sprint(success))
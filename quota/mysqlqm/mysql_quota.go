@@ -20,6 +20,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/google/trillian/quota"
 )
@@ -53,9 +54,13 @@ var ErrTooManyUnsequencedRows = errors.New("too many unsequenced rows")
 // rows (to be exact, tokens = MaxUnsequencedRows - actualUnsequencedRows).
 // Other quotas are considered infinite.
 type QuotaManager struct {
-	DB                 *sql.DB
+	DB *sql.DB
+	// MaxUnsequencedRows is the limit of Unsequenced rows. Use SetMaxUnsequencedRows to change
+	// it once the QuotaManager is in use.
 	MaxUnsequencedRows int
 	UseSelectCount     bool
+
+	mu sync.Mutex // Guards MaxUnsequencedRows after SetMaxUnsequencedRows.
 }
 
 // GetTokens implements quota.Manager.GetTokens.
@@ -71,13 +76,27 @@ func (m *QuotaManager) GetTokens(ctx context.Context, numTokens int, specs []quo
 		if err != nil {
 			return err
 		}
-		if count+numTokens > m.MaxUnsequencedRows {
+		if count+numTokens > m.maxUnsequencedRows() {
 			return ErrTooManyUnsequencedRows
 		}
 	}
 	return nil
 }
 
+// SetMaxUnsequencedRows changes MaxUnsequencedRows. It is safe to call while the QuotaManager
+// is in use.
+func (m *QuotaManager) SetMaxUnsequencedRows(max int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.MaxUnsequencedRows = max
+}
+
+func (m *QuotaManager) maxUnsequencedRows() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.MaxUnsequencedRows
+}
+
 // PutTokens implements quota.Manager.PutTokens.
 // It's a noop for QuotaManager.
 func (m *QuotaManager) PutTokens(ctx context.Context, numTokens int, specs []quota.Spec) error {
@@ -25,7 +25,8 @@ import (
 // QuotaManagerName identifies the MySQL quota implementation.
 const QuotaManagerName = "mysql"
 
-var maxUnsequencedRows = flag.Int("max_unsequenced_rows", DefaultMaxUnsequenced, "Max number of unsequenced rows before rate limiting kicks in. "+
+// MaxUnsequencedRows is the MaxUnsequencedRows of the QuotaManagers created by the provider.
+var MaxUnsequencedRows = flag.Int("max_unsequenced_rows", DefaultMaxUnsequenced, "Max number of unsequenced rows before rate limiting kicks in. "+
 	"Only effective for quota_system=mysql.")
 
 func init() {
@@ -41,7 +42,7 @@ func newMySQLQuotaManager() (quota.Manager, error) {
 	}
 	qm := &QuotaManager{
 		DB:                 db,
-		MaxUnsequencedRows: *maxUnsequencedRows,
+		MaxUnsequencedRows: *MaxUnsequencedRows,
 	}
 	glog.Info("Using MySQL QuotaManager")
 	return qm, nil
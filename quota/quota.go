@@ -17,13 +17,15 @@ package quota
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 )
 
 // MaxTokens is the maximum number of available tokens a quota may have.
 const MaxTokens = int(^uint(0) >> 1) // MaxInt
 
-// Group represents the scope of a token (Global, Tree or User).
+// Group represents the scope of a token (Global, Tree, User, Prefix or Tenant).
 type Group int
 
 const (
@@ -38,6 +40,15 @@ const (
 	// User is the per-user token scope.
 	// Users are defined according to each implementation.
 	User
+
+	// Prefix is the per-network token scope.
+	// Networks are IP address prefixes, allowing requests from all addresses within a range to
+	// be limited together.
+	Prefix
+
+	// Tenant is the per-tenant token scope.
+	// Tenants are named groups of users, resolved from request metadata.
+	Tenant
 )
 
 // Kind represents the purpose of each token (Read or Write).
@@ -68,6 +79,18 @@ type Spec struct {
 	// Not used for other specs.
 	User string
 
+	// Prefix identifies the network for specs of the Prefix group, in CIDR notation (e.g.
+	// "10.0.0.0/8").
+	// Requests are charged to the host prefix of their client address (e.g. "10.1.2.3/32").
+	// Implementations may additionally charge every configured prefix that contains it, see
+	// ContainingPrefixes.
+	// Not used for other specs.
+	Prefix string
+
+	// Tenant identifies the tenant for specs of the Tenant group.
+	// Not used for other specs.
+	Tenant string
+
 	// Refundable indicates that the tokens acquired before the operation should be returned if
 	// the operation fails.
 	Refundable bool
@@ -79,21 +102,157 @@ type Spec struct {
 // Names are created as follows:
 // * Global quotas are mapped to "global/read" or "global/write"
 // * Tree quotas are mapped to "trees/$TreeID/$Kind". E.g., "trees/10/read".
-// * User quotas are mapped to "users/$User/$Kind". E.g., "trees/10/read".
+// * User quotas are mapped to "users/$User/$Kind". E.g., "users/alice/read".
+// * Prefix quotas are mapped to "prefixes/$Prefix/$Kind", with the "/" of the prefix replaced by
+//   "_". E.g., "prefixes/10.0.0.0_8/read".
+// * Tenant quotas are mapped to "tenants/$Tenant/$Kind". E.g., "tenants/acme/read".
 func (s Spec) Name() string {
 	group := strings.ToLower(fmt.Sprint(s.Group))
 	kind := strings.ToLower(fmt.Sprint(s.Kind))
 	if s.Group == Global {
 		return fmt.Sprintf("%v/%v", group, kind)
 	}
+	collection := group + "s"
 	var user string
 	switch s.Group {
 	case Tree:
 		user = fmt.Sprint(s.TreeID)
 	case User:
 		user = s.User
+	case Prefix:
+		collection = "prefixes"
+		user = strings.Replace(s.Prefix, "/", "_", 1)
+	case Tenant:
+		user = s.Tenant
+	}
+	return fmt.Sprintf("%v/%v/%v", collection, user, kind)
+}
+
+// ParseGroups parses a comma-separated list of group names, e.g. "user,tenant". Names are
+// case-insensitive.
+func ParseGroups(groups string) ([]Group, error) {
+	var ret []Group
+	for _, name := range strings.Split(groups, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		g, err := parseGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, g)
+	}
+	return ret, nil
+}
+
+func parseGroup(name string) (Group, error) {
+	for g := Global; g <= Tenant; g++ {
+		if strings.EqualFold(name, g.String()) {
+			return g, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown quota group %q", name)
+}
+
+// ByteCost makes the specs of a group and kind charged by size rather than by count: requests
+// are charged one token per BytesPerToken bytes of the leaves they write, or of the responses
+// they read, instead of one token per leaf or entry.
+type ByteCost struct {
+	Group
+	Kind
+	BytesPerToken int
+}
+
+// Tokens returns the number of tokens charged for size bytes, which is at least one.
+func (c ByteCost) Tokens(size int) int {
+	if tokens := (size + c.BytesPerToken - 1) / c.BytesPerToken; tokens > 1 {
+		return tokens
+	}
+	return 1
+}
+
+// ParseByteCosts parses a comma-separated list of byte costs in the "group[/kind]=bytes" format,
+// e.g. "tenant/write=1024,user=4096", where costs without a kind apply to both kinds.
+func ParseByteCosts(costs string) ([]ByteCost, error) {
+	var ret []ByteCost
+	for _, c := range strings.Split(costs, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		parts := strings.SplitN(c, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid byte cost %q, want group[/kind]=bytes", c)
+		}
+		bytes, err := strconv.Atoi(parts[1])
+		if err != nil || bytes <= 0 {
+			return nil, fmt.Errorf("invalid bytes per token in %q", c)
+		}
+		groupKind := strings.SplitN(parts[0], "/", 2)
+		g, err := parseGroup(groupKind[0])
+		if err != nil {
+			return nil, err
+		}
+		kinds := []Kind{Read, Write}
+		if len(groupKind) == 2 {
+			switch {
+			case strings.EqualFold(groupKind[1], Read.String()):
+				kinds = []Kind{Read}
+			case strings.EqualFold(groupKind[1], Write.String()):
+				kinds = []Kind{Write}
+			default:
+				return nil, fmt.Errorf("unknown quota kind in %q", c)
+			}
+		}
+		for _, k := range kinds {
+			ret = append(ret, ByteCost{Group: g, Kind: k, BytesPerToken: bytes})
+		}
+	}
+	return ret, nil
+}
+
+// HostPrefix returns the single-address prefix of ip, in CIDR notation, as used for the Prefix
+// specs of requests.
+func HostPrefix(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}).String()
+}
+
+// ParsePrefix parses a network prefix in CIDR notation, as used by Spec.Prefix. It fails if the
+// prefix has host bits set (e.g. "10.0.0.1/8"), so each network has a single representation.
+func ParsePrefix(prefix string) (*net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if !ip.Equal(network.IP) {
+		return nil, fmt.Errorf("prefix %q has host bits set, want %v", prefix, network)
+	}
+	return network, nil
+}
+
+// ContainingPrefixes returns those of prefixes that contain the network prefix, including
+// prefix itself. Invalid prefixes are ignored.
+func ContainingPrefixes(prefix string, prefixes []string) []string {
+	network, err := ParsePrefix(prefix)
+	if err != nil {
+		return nil
+	}
+	ones, bits := network.Mask.Size()
+	var ret []string
+	for _, p := range prefixes {
+		n, err := ParsePrefix(p)
+		if err != nil {
+			continue
+		}
+		if o, b := n.Mask.Size(); b == bits && o <= ones && n.Contains(network.IP) {
+			ret = append(ret, p)
+		}
 	}
-	return fmt.Sprintf("%vs/%v/%v", group, user, kind)
+	return ret
 }
 
 // String returns a description of Spec.
@@ -14,7 +14,12 @@
 
 package quota
 
-import "testing"
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
 
 func TestSpec_Name(t *testing.T) {
 	tests := []struct {
@@ -27,6 +32,9 @@ func TestSpec_Name(t *testing.T) {
 		{spec: Spec{Group: Tree, Kind: Write, TreeID: 10}, want: "trees/10/write"},
 		{spec: Spec{Group: User, Kind: Read, User: "alpaca"}, want: "users/alpaca/read"},
 		{spec: Spec{Group: User, Kind: Write, User: "llama"}, want: "users/llama/write"},
+		{spec: Spec{Group: Prefix, Kind: Read, Prefix: "10.0.0.0/8"}, want: "prefixes/10.0.0.0_8/read"},
+		{spec: Spec{Group: Prefix, Kind: Write, Prefix: "2001:db8::/32"}, want: "prefixes/2001:db8::_32/write"},
+		{spec: Spec{Group: Tenant, Kind: Read, Tenant: "acme"}, want: "tenants/acme/read"},
 	}
 	for _, test := range tests {
 		if got := test.spec.Name(); got != test.want {
@@ -34,3 +42,127 @@ func TestSpec_Name(t *testing.T) {
 		}
 	}
 }
+
+func TestParseGroups(t *testing.T) {
+	for _, test := range []struct {
+		groups  string
+		want    []Group
+		wantErr bool
+	}{
+		{groups: ""},
+		{groups: "tree", want: []Group{Tree}},
+		{groups: "User, tenant,PREFIX", want: []Group{User, Tenant, Prefix}},
+		{groups: "global,", want: []Group{Global}},
+		{groups: "users", wantErr: true},
+	} {
+		got, err := ParseGroups(test.groups)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseGroups(%q) = %v, wantErr = %v", test.groups, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(got, test.want); diff != "" {
+			t.Errorf("ParseGroups(%q) diff (-got +want):\n%v", test.groups, diff)
+		}
+	}
+}
+
+func TestByteCost_Tokens(t *testing.T) {
+	c := ByteCost{Group: Tenant, Kind: Write, BytesPerToken: 100}
+	for _, test := range []struct {
+		size, want int
+	}{
+		{size: 0, want: 1},
+		{size: 1, want: 1},
+		{size: 100, want: 1},
+		{size: 101, want: 2},
+		{size: 1000000, want: 10000},
+	} {
+		if got := c.Tokens(test.size); got != test.want {
+			t.Errorf("Tokens(%v) = %v, want = %v", test.size, got, test.want)
+		}
+	}
+}
+
+func TestParseByteCosts(t *testing.T) {
+	for _, test := range []struct {
+		costs   string
+		want    []ByteCost
+		wantErr bool
+	}{
+		{costs: ""},
+		{
+			costs: "tenant/write=1024, user=4096",
+			want: []ByteCost{
+				{Group: Tenant, Kind: Write, BytesPerToken: 1024},
+				{Group: User, Kind: Read, BytesPerToken: 4096},
+				{Group: User, Kind: Write, BytesPerToken: 4096},
+			},
+		},
+		{costs: "Global/Read=1", want: []ByteCost{{Group: Global, Kind: Read, BytesPerToken: 1}}},
+		{costs: "tenant", wantErr: true},
+		{costs: "tenant=0", wantErr: true},
+		{costs: "tenant=x", wantErr: true},
+		{costs: "tenants=1", wantErr: true},
+		{costs: "tenant/delete=1", wantErr: true},
+	} {
+		got, err := ParseByteCosts(test.costs)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseByteCosts(%q) = %v, wantErr = %v", test.costs, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(got, test.want); diff != "" {
+			t.Errorf("ParseByteCosts(%q) diff (-got +want):\n%v", test.costs, diff)
+		}
+	}
+}
+
+func TestHostPrefix(t *testing.T) {
+	for _, test := range []struct {
+		ip   string
+		want string
+	}{
+		{ip: "10.1.2.3", want: "10.1.2.3/32"},
+		{ip: "::ffff:10.1.2.3", want: "10.1.2.3/32"},
+		{ip: "2001:db8::1", want: "2001:db8::1/128"},
+	} {
+		if got := HostPrefix(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("HostPrefix(%v) = %v, want = %v", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	for _, test := range []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{prefix: "10.0.0.0/8"},
+		{prefix: "2001:db8::/32"},
+		{prefix: "10.0.0.1/8", wantErr: true},
+		{prefix: "10.0.0.0", wantErr: true},
+		{prefix: "llama", wantErr: true},
+	} {
+		if _, err := ParsePrefix(test.prefix); (err != nil) != test.wantErr {
+			t.Errorf("ParsePrefix(%q) = %v, wantErr = %v", test.prefix, err, test.wantErr)
+		}
+	}
+}
+
+func TestContainingPrefixes(t *testing.T) {
+	prefixes := []string{"10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16", "10.1.2.3/32", "2001:db8::/32", "bad"}
+	for _, test := range []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "10.1.2.3/32", want: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32"}},
+		{prefix: "10.2.0.0/16", want: []string{"10.0.0.0/8", "10.2.0.0/16"}},
+		{prefix: "10.0.0.0/7"},
+		{prefix: "2001:db8::1/128", want: []string{"2001:db8::/32"}},
+		{prefix: "192.168.0.1/32"},
+		{prefix: "bad"},
+	} {
+		if diff := cmp.Diff(ContainingPrefixes(test.prefix, prefixes), test.want); diff != "" {
+			t.Errorf("ContainingPrefixes(%q) diff (-got +want):\n%s", test.prefix, diff)
+		}
+	}
+}
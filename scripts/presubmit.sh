@@ -115,6 +115,19 @@ main() {
 
     echo "running go test ${TEST_FLAGS} ./..."
     go test ${TEST_FLAGS} ./... -alsologtostderr
+
+    # Browser-based monitors verify proofs with the client packages, which
+    # must build for WebAssembly without cgo.
+    echo 'building verification packages for WebAssembly'
+    local wasm_pkgs=(. ./types/... ./client ./client/backoff ./client/sigstore)
+    for goos in js wasip1; do
+      GOOS="${goos}" GOARCH=wasm CGO_ENABLED=0 go vet "${wasm_pkgs[@]}"
+    done
+    if type -p node > /dev/null; then
+      echo 'running verification tests on WebAssembly'
+      GOOS=js GOARCH=wasm CGO_ENABLED=0 go test ${TEST_FLAGS} \
+        -exec="$(go env GOROOT)/lib/wasm/go_js_wasm_exec" "${wasm_pkgs[@]}"
+    fi
   fi
 
   if [[ "${run_lint}" -eq 1 ]]; then
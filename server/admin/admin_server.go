@@ -18,15 +18,20 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/server/notify"
 	"github.com/google/trillian/storage"
 	"google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// defaultMaxScrubCorruptions is the number of corruptions returned by ScrubTree
+// if the request doesn't specify it.
+const defaultMaxScrubCorruptions = 100
+
 // Server is an implementation of trillian.TrillianAdminServer.
 type Server struct {
 	registry         extension.Registry
@@ -38,6 +43,7 @@ type Server struct {
 // allowedTreeTypes defines which tree types may be created through this server,
 // with nil meaning unrestricted.
 func New(registry extension.Registry, allowedTreeTypes []trillian.TreeType) *Server {
+	log.InitScrubMetrics(registry.MetricFactory)
 	return &Server{
 		registry:         registry,
 		allowedTreeTypes: allowedTreeTypes,
@@ -120,15 +126,20 @@ func (s *Server) UpdateTree(ctx context.Context, req *trillian.UpdateTreeRequest
 		return nil, err
 	}
 
+	var wasFrozen bool
 	updatedTree, err := storage.UpdateTree(ctx, s.registry.AdminStorage, tree.TreeId, func(other *trillian.Tree) {
+		wasFrozen = other.TreeState == trillian.TreeState_FROZEN
 		if err := applyUpdateMask(tree, other, mask); err != nil {
 			// Should never happen (famous last words).
-			glog.Errorf("Error applying mask on tree update: %v", err)
+			logger.Error(ctx, "error applying mask on tree update", "tree_id", tree.TreeId, "err", err)
 		}
 	})
 	if err != nil {
 		return nil, err
 	}
+	if n := s.registry.Notifier; n != nil && !wasFrozen && updatedTree.TreeState == trillian.TreeState_FROZEN {
+		n.Notify(ctx, notify.Event{Type: notify.TreeFrozen, TreeID: updatedTree.TreeId})
+	}
 	return updatedTree, nil
 }
 
@@ -150,6 +161,24 @@ func applyUpdateMask(from, to *trillian.Tree, mask *field_mask.FieldMask) error
 			to.StorageSettings = from.StorageSettings
 		case "max_root_duration":
 			to.MaxRootDuration = from.MaxRootDuration
+		case "sequencer_guard_window":
+			to.SequencerGuardWindow = from.SequencerGuardWindow
+		case "sequencer_batch_size":
+			to.SequencerBatchSize = from.SequencerBatchSize
+		case "priority":
+			to.Priority = from.Priority
+		case "signer_affinity":
+			to.SignerAffinity = from.SignerAffinity
+		case "anti_affinity_group":
+			to.AntiAffinityGroup = from.AntiAffinityGroup
+		case "max_leaf_value_size":
+			to.MaxLeafValueSize = from.MaxLeafValueSize
+		case "max_extra_data_size":
+			to.MaxExtraDataSize = from.MaxExtraDataSize
+		case "max_unsequenced_leaves":
+			to.MaxUnsequencedLeaves = from.MaxUnsequencedLeaves
+		case "compact_retained_revisions":
+			to.CompactRetainedRevisions = from.CompactRetainedRevisions
 		default:
 			return status.Errorf(codes.InvalidArgument, "invalid update_mask path: %q", path)
 		}
@@ -163,6 +192,10 @@ func (s *Server) DeleteTree(ctx context.Context, req *trillian.DeleteTreeRequest
 	if err != nil {
 		return nil, err
 	}
+	if n := s.registry.Notifier; n != nil {
+		deleteTime := tree.DeleteTime.AsTime()
+		n.Notify(ctx, notify.Event{Type: notify.TreeDeletionScheduled, TreeID: tree.TreeId, DeleteTime: &deleteTime})
+	}
 	return tree, nil
 }
 
@@ -174,3 +207,57 @@ func (s *Server) UndeleteTree(ctx context.Context, req *trillian.UndeleteTreeReq
 	}
 	return tree, nil
 }
+
+// ScrubTree implements trillian.TrillianAdminServer.ScrubTree.
+func (s *Server) ScrubTree(ctx context.Context, req *trillian.ScrubTreeRequest) (*trillian.ScrubTreeResponse, error) {
+	if s.registry.LogStorage == nil {
+		return nil, status.Error(codes.Unimplemented, "ScrubTree requires log storage")
+	}
+	tree, err := storage.GetTree(ctx, s.registry.AdminStorage, req.GetTreeId())
+	if err != nil {
+		return nil, err
+	}
+	if tt := tree.TreeType; tt != trillian.TreeType_LOG && tt != trillian.TreeType_PREORDERED_LOG {
+		return nil, status.Errorf(codes.InvalidArgument, "ScrubTree is not supported for tree type %v", tt)
+	}
+	maxCorruptions := int(req.GetMaxCorruptions())
+	if maxCorruptions <= 0 {
+		maxCorruptions = defaultMaxScrubCorruptions
+	}
+	report, err := log.ScrubTree(ctx, tree, s.registry.LogStorage, log.ScrubOptions{
+		BatchSize:      int(req.GetBatchSize()),
+		MaxCorruptions: maxCorruptions,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to scrub tree: %v", err)
+	}
+	return &trillian.ScrubTreeResponse{
+		TreeSize:        int64(report.TreeSize),
+		LeavesChecked:   int64(report.Leaves),
+		NodesChecked:    int64(report.Nodes),
+		Corruptions:     report.Corruptions,
+		CorruptionCount: int64(report.CorruptionCount),
+	}, nil
+}
+
+// GetTreeUsage implements trillian.TrillianAdminServer.GetTreeUsage.
+func (s *Server) GetTreeUsage(ctx context.Context, req *trillian.GetTreeUsageRequest) (*trillian.TreeUsage, error) {
+	reporter, ok := s.registry.AdminStorage.(storage.UsageReporter)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "GetTreeUsage is not supported by the storage")
+	}
+	// Fail for unknown or deleted trees, rather than report them as empty.
+	if _, err := storage.GetTree(ctx, s.registry.AdminStorage, req.GetTreeId()); err != nil {
+		return nil, err
+	}
+	usage, err := reporter.TreeUsage(ctx, req.GetTreeId())
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.TreeUsage{
+		TreeId:        req.GetTreeId(),
+		LeafDataBytes: usage.LeafDataBytes,
+		SubtreeBytes:  usage.SubtreeBytes,
+		QueueBytes:    usage.QueueBytes,
+	}, nil
+}
@@ -16,7 +16,10 @@ package admin
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -25,8 +28,12 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/notify"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
 	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
 	"google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -412,9 +419,19 @@ func TestServer_UpdateTree(t *testing.T) {
 		Description:     "Brand New Tree Desc",
 		StorageSettings: settings,
 		MaxRootDuration: durationpb.New(2 * time.Nanosecond),
+
+		SequencerGuardWindow:     durationpb.New(5 * time.Second),
+		SequencerBatchSize:       100,
+		Priority:                 trillian.TreePriority_HIGH_PRIORITY,
+		SignerAffinity:           []string{"a"},
+		AntiAffinityGroup:        "hot",
+		MaxLeafValueSize:         1024,
+		MaxExtraDataSize:         256,
+		MaxUnsequencedLeaves:     1000,
+		CompactRetainedRevisions: 10,
 	}
 	successMask := &field_mask.FieldMask{
-		Paths: []string{"tree_state", "display_name", "description", "storage_settings", "max_root_duration"},
+		Paths: []string{"tree_state", "display_name", "description", "storage_settings", "max_root_duration", "sequencer_guard_window", "sequencer_batch_size", "priority", "signer_affinity", "anti_affinity_group", "max_leaf_value_size", "max_extra_data_size", "max_unsequenced_leaves", "compact_retained_revisions"},
 	}
 
 	successWant := proto.Clone(existingTree).(*trillian.Tree)
@@ -423,6 +440,15 @@ func TestServer_UpdateTree(t *testing.T) {
 	successWant.Description = successTree.Description
 	successWant.StorageSettings = successTree.StorageSettings
 	successWant.MaxRootDuration = successTree.MaxRootDuration
+	successWant.SequencerGuardWindow = successTree.SequencerGuardWindow
+	successWant.SequencerBatchSize = successTree.SequencerBatchSize
+	successWant.Priority = successTree.Priority
+	successWant.SignerAffinity = successTree.SignerAffinity
+	successWant.AntiAffinityGroup = successTree.AntiAffinityGroup
+	successWant.MaxLeafValueSize = successTree.MaxLeafValueSize
+	successWant.MaxExtraDataSize = successTree.MaxExtraDataSize
+	successWant.MaxUnsequencedLeaves = successTree.MaxUnsequencedLeaves
+	successWant.CompactRetainedRevisions = successTree.CompactRetainedRevisions
 
 	tests := []struct {
 		desc                           string
@@ -722,3 +748,145 @@ func setupAdminServer(ctrl *gomock.Controller, snapshot, shouldCommit, commitErr
 
 	return adminTestSetup{registry, as, tx, snapshotTX, s}
 }
+
+func TestServer_ScrubTree(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if err := registry.LogStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		root, err := (&types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot()}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		t.Fatalf("StoreSignedLogRoot() returned err = %v", err)
+	}
+
+	s := New(registry, nil)
+	resp, err := s.ScrubTree(ctx, &trillian.ScrubTreeRequest{TreeId: tree.TreeId})
+	if err != nil {
+		t.Fatalf("ScrubTree() returned err = %v", err)
+	}
+	if diff := cmp.Diff(resp, &trillian.ScrubTreeResponse{}, cmp.Comparer(proto.Equal)); diff != "" {
+		t.Errorf("ScrubTree() diff (-got +want):\n%v", diff)
+	}
+
+	if _, err := s.ScrubTree(ctx, &trillian.ScrubTreeRequest{TreeId: 12345}); err == nil {
+		t.Error("ScrubTree(unknown tree) returned err = nil, want non-nil")
+	}
+	noLog := New(extension.Registry{AdminStorage: registry.AdminStorage}, nil)
+	if _, err := noLog.ScrubTree(ctx, &trillian.ScrubTreeRequest{TreeId: tree.TreeId}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("ScrubTree(no log storage) returned err = %v, want code %v", err, codes.Unimplemented)
+	}
+}
+
+func TestServer_GetTreeUsage(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if err := registry.LogStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		root, err := (&types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot()}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		t.Fatalf("StoreSignedLogRoot() returned err = %v", err)
+	}
+	leaf := &trillian.LogLeaf{
+		LeafIdentityHash: make([]byte, 32),
+		MerkleLeafHash:   make([]byte, 32),
+		LeafValue:        []byte("value"),
+		ExtraData:        []byte("extra"),
+	}
+	if _, err := registry.LogStorage.QueueLeaves(ctx, tree, []*trillian.LogLeaf{leaf}, time.Now()); err != nil {
+		t.Fatalf("QueueLeaves() returned err = %v", err)
+	}
+
+	s := New(registry, nil)
+	resp, err := s.GetTreeUsage(ctx, &trillian.GetTreeUsageRequest{TreeId: tree.TreeId})
+	if err != nil {
+		t.Fatalf("GetTreeUsage() returned err = %v", err)
+	}
+	want := &trillian.TreeUsage{TreeId: tree.TreeId, LeafDataBytes: 74, QueueBytes: 64}
+	if diff := cmp.Diff(resp, want, cmp.Comparer(proto.Equal)); diff != "" {
+		t.Errorf("GetTreeUsage() diff (-got +want):\n%v", diff)
+	}
+
+	if _, err := s.GetTreeUsage(ctx, &trillian.GetTreeUsageRequest{TreeId: 12345}); err == nil {
+		t.Error("GetTreeUsage(unknown tree) returned err = nil, want non-nil")
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	noUsage := New(extension.Registry{AdminStorage: storage.NewMockAdminStorage(ctrl)}, nil)
+	if _, err := noUsage.GetTreeUsage(ctx, &trillian.GetTreeUsageRequest{TreeId: tree.TreeId}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetTreeUsage(no usage reporter) returned err = %v, want code %v", err, codes.Unimplemented)
+	}
+}
+
+func TestServer_Notifications(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan notify.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+	notifier := notify.New(notify.Options{URLs: []string{srv.URL}})
+	go notifier.Run(ctx)
+
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{AdminStorage: memory.NewAdminStorage(ts), Notifier: notifier}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	s := New(registry, nil)
+	update := func(state trillian.TreeState) {
+		t.Helper()
+		req := &trillian.UpdateTreeRequest{
+			Tree:       &trillian.Tree{TreeId: tree.TreeId, TreeState: state},
+			UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_state"}},
+		}
+		if _, err := s.UpdateTree(ctx, req); err != nil {
+			t.Fatalf("UpdateTree(%v) returned err = %v", state, err)
+		}
+	}
+	// Only the transition to FROZEN is notified.
+	update(trillian.TreeState_FROZEN)
+	update(trillian.TreeState_FROZEN)
+
+	select {
+	case e := <-events:
+		if e.Type != notify.TreeFrozen || e.TreeID != tree.TreeId {
+			t.Errorf("got %s event for tree %d, want %s for tree %d", e.Type, e.TreeID, notify.TreeFrozen, tree.TreeId)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for %s event", notify.TreeFrozen)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("got unexpected %s event", e.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
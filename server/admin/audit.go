@@ -0,0 +1,191 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/quota/etcd/quotapb"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// auditWriteTimeout bounds the write of an audit entry, which doesn't use the
+// context of the audited RPC so that it isn't abandoned by cancelled clients.
+const auditWriteTimeout = 10 * time.Second
+
+var auditLogWrite = trees.NewGetOpts(trees.QueueLog, trillian.TreeType_LOG)
+
+// AuditEntry is the value of the leaves of the admin audit log, as JSON. Each
+// entry records an administrative action which succeeded.
+type AuditEntry struct {
+	// Time is when the action completed.
+	Time time.Time `json:"time"`
+	// Method is the full name of the RPC, e.g.
+	// /trillian.TrillianAdmin/CreateTree.
+	Method string `json:"method"`
+	// Issuer and Subject identify the token which authenticated the request,
+	// if any.
+	Issuer  string `json:"issuer,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Peer is the address of the client.
+	Peer      string `json:"peer,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Request and Response are the messages of the RPC, as protobuf JSON.
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// AuditLog appends the tree and quota changes made through the admin RPCs to
+// a Trillian log of the same deployment, so that administrative actions are
+// recorded in an append-only, verifiable way. The log is an ordinary LOG tree,
+// which must be initialized and sequenced like any other.
+//
+// Entries are written after the actions succeed, on a best-effort basis:
+// failures to write them are logged and counted, but don't fail the RPCs,
+// whose changes are already made. The audit log itself can't be deleted.
+type AuditLog struct {
+	registry   extension.Registry
+	treeID     int64
+	timeSource clock.TimeSource
+	failures   monitoring.Counter
+}
+
+// NewAuditLog returns an AuditLog appending to the log treeID, through the
+// storage of registry.
+func NewAuditLog(registry extension.Registry, treeID int64, ts clock.TimeSource) *AuditLog {
+	mf := registry.MetricFactory
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &AuditLog{
+		registry:   registry,
+		treeID:     treeID,
+		timeSource: ts,
+		failures:   mf.NewCounter("admin_audit_log_failures", "Number of administrative actions which failed to be written to the audit log", "method"),
+	}
+}
+
+// audited returns whether req is the request of an administrative action.
+func audited(req interface{}) bool {
+	switch req.(type) {
+	case *trillian.CreateTreeRequest,
+		*trillian.UpdateTreeRequest,
+		*trillian.DeleteTreeRequest,
+		*trillian.UndeleteTreeRequest,
+		*quotapb.CreateConfigRequest,
+		*quotapb.UpdateConfigRequest,
+		*quotapb.DeleteConfigRequest:
+		return true
+	}
+	return false
+}
+
+// UnaryInterceptor writes the administrative actions made by unary RPCs to
+// the audit log. It must run after the Authenticator, if any, so that the
+// principal of the actions is known.
+func (a *AuditLog) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !audited(req) {
+		return handler(ctx, req)
+	}
+	if d, ok := req.(*trillian.DeleteTreeRequest); ok && d.GetTreeId() == a.treeID {
+		return nil, status.Errorf(codes.FailedPrecondition, "tree %d is the admin audit log, and can't be deleted", a.treeID)
+	}
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if err := a.append(ctx, info.FullMethod, req, resp); err != nil {
+		a.failures.Inc(info.FullMethod)
+		glog.Errorf("Failed to write %s to the admin audit log %d: %v", info.FullMethod, a.treeID, err)
+	}
+	return resp, nil
+}
+
+// append queues an entry recording an action to the audit log.
+func (a *AuditLog) append(ctx context.Context, method string, req, resp interface{}) error {
+	entry := AuditEntry{
+		Time:      a.timeSource.Now().UTC(),
+		Method:    method,
+		RequestID: logging.RequestID(ctx),
+	}
+	if claims, ok := interceptor.ClaimsFromContext(ctx); ok {
+		entry.Issuer, entry.Subject = claims.Issuer, claims.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.Peer = p.Addr.String()
+	}
+	var err error
+	if entry.Request, err = marshalAuditMessage(req); err != nil {
+		return err
+	}
+	if entry.Response, err = marshalAuditMessage(resp); err != nil {
+		return err
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(logging.WithRequestID(context.Background(), entry.RequestID), auditWriteTimeout)
+	defer cancel()
+	tree, err := trees.GetTree(ctx, a.registry.AdminStorage, a.treeID, auditLogWrite)
+	if err != nil {
+		return err
+	}
+	hash := rfc6962.DefaultHasher.HashLeaf(value)
+	leaf := &trillian.LogLeaf{LeafValue: value, MerkleLeafHash: hash, LeafIdentityHash: hash}
+	queued, err := a.registry.LogStorage.QueueLeaves(trees.NewContext(ctx, tree), tree, []*trillian.LogLeaf{leaf}, a.timeSource.Now())
+	if err != nil {
+		return err
+	}
+	if len(queued) != 1 {
+		return fmt.Errorf("QueueLeaves returned %d leaves, want 1", len(queued))
+	}
+	if st := queued[0].GetStatus(); st != nil && st.GetCode() != int32(codes.OK) {
+		return status.ErrorProto(st)
+	}
+	return nil
+}
+
+// marshalAuditMessage returns the protobuf JSON of m, or nil if it isn't a
+// message.
+func marshalAuditMessage(m interface{}) (json.RawMessage, error) {
+	pm, ok := m.(proto.Message)
+	if !ok || pm == nil {
+		return nil, nil
+	}
+	b, err := protojson.Marshal(pm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %v", m, err)
+	}
+	return b, nil
+}
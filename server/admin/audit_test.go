@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	auditTree, err := storage.CreateTree(ctx, registry.AdminStorage, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if err := registry.LogStorage.ReadWriteTransaction(ctx, auditTree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		root, err := (&types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot()}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		t.Fatalf("StoreSignedLogRoot() returned err = %v", err)
+	}
+
+	now := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+	audit := NewAuditLog(registry, auditTree.TreeId, clock.NewFake(now))
+	s := New(registry, nil)
+	call := func(ctx context.Context, method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+		t.Helper()
+		return audit.UnaryInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	authCtx := interceptor.NewClaimsContext(ctx, &interceptor.Claims{Issuer: "https://issuer", Subject: "admin"})
+	createReq := &trillian.CreateTreeRequest{Tree: proto.Clone(testonly.LogTree).(*trillian.Tree)}
+	resp, err := call(authCtx, "/trillian.TrillianAdmin/CreateTree", createReq, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.CreateTree(ctx, req.(*trillian.CreateTreeRequest))
+	})
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	created := resp.(*trillian.Tree)
+
+	// Failed and read-only actions aren't audited.
+	if _, err := call(ctx, "/trillian.TrillianAdmin/UpdateTree", &trillian.UpdateTreeRequest{}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("failed")
+	}); err == nil {
+		t.Error("UpdateTree() returned err = nil, want the handler error")
+	}
+	if _, err := call(ctx, "/trillian.TrillianAdmin/GetTree", &trillian.GetTreeRequest{TreeId: created.TreeId}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.GetTree(ctx, req.(*trillian.GetTreeRequest))
+	}); err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+
+	deleteAudit := &trillian.DeleteTreeRequest{TreeId: auditTree.TreeId}
+	if _, err := call(ctx, "/trillian.TrillianAdmin/DeleteTree", deleteAudit, func(context.Context, interface{}) (interface{}, error) {
+		t.Error("DeleteTree() of the audit log reached the handler")
+		return nil, nil
+	}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeleteTree() of the audit log returned err = %v, want code %v", err, codes.FailedPrecondition)
+	}
+
+	var leaves []*trillian.LogLeaf
+	if err := registry.LogStorage.ReadWriteTransaction(ctx, auditTree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		leaves, err = tx.DequeueLeaves(ctx, 10, now.Add(time.Hour))
+		return err
+	}); err != nil {
+		t.Fatalf("DequeueLeaves() returned err = %v", err)
+	}
+	if len(leaves) != 1 {
+		t.Fatalf("audit log has %d queued leaves, want 1", len(leaves))
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(leaves[0].LeafValue, &entry); err != nil {
+		t.Fatalf("failed to decode audit entry: %v", err)
+	}
+	if !entry.Time.Equal(now) || entry.Method != "/trillian.TrillianAdmin/CreateTree" || entry.Issuer != "https://issuer" || entry.Subject != "admin" {
+		t.Errorf("audit entry = %+v, want CreateTree by admin at %v", entry, now)
+	}
+	var loggedTree trillian.Tree
+	if err := protojson.Unmarshal(entry.Response, &loggedTree); err != nil {
+		t.Fatalf("failed to decode audited response: %v", err)
+	}
+	if loggedTree.TreeId != created.TreeId {
+		t.Errorf("audited tree ID = %d, want %d", loggedTree.TreeId, created.TreeId)
+	}
+}
@@ -23,8 +23,8 @@ import (
 	"sync"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/storage"
 )
 
@@ -37,6 +37,8 @@ var (
 	timeNow   = time.Now
 	timeSleep = time.Sleep
 
+	logger = logging.New("admin")
+
 	hardDeleteCounter monitoring.Counter
 	metricsOnce       sync.Once
 )
@@ -93,10 +95,10 @@ func (gc *DeletedTreeGC) Run(ctx context.Context) {
 
 		count, err := gc.RunOnce(ctx)
 		if err != nil {
-			glog.Errorf("DeletedTreeGC.Run: %v", err)
+			logger.Error(ctx, "tree garbage collection failed", "err", err)
 		}
 		if count > 0 {
-			glog.Infof("DeletedTreeGC.Run: successfully deleted %v trees", count)
+			logger.Info(ctx, "tree garbage collection deleted trees", "count", count)
 		}
 
 		d := gc.minRunInterval + time.Duration(rand.Int63n(gc.minRunInterval.Nanoseconds()))
@@ -138,7 +140,7 @@ func (gc *DeletedTreeGC) RunOnce(ctx context.Context) (int, error) {
 			continue
 		}
 
-		glog.Infof("DeletedTreeGC.RunOnce: Hard-deleting tree %v after %v", tree.TreeId, durationSinceDelete)
+		logger.Info(ctx, "hard-deleting tree", "tree_id", tree.TreeId, "deleted_for", durationSinceDelete)
 		if err := storage.HardDeleteTree(ctx, gc.admin, tree.TreeId); err != nil {
 			errs = append(errs, fmt.Errorf("error hard-deleting tree %v: %v", tree.TreeId, err))
 			incHardDeleteCounter(tree.TreeId, false, deleteErrReason)
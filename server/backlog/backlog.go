@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backlog applies backpressure to writers of logs whose queue of
+// unsequenced leaves grows too large.
+package backlog
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RetryAfterKey is the response header metadata key holding the number of
+	// seconds after which rejected requests may be retried.
+	RetryAfterKey = "retry-after"
+
+	// DefaultRetryAfter is the default delay suggested to rejected clients.
+	DefaultRetryAfter = 10 * time.Second
+	// DefaultRefreshInterval is the default interval between two counts of the
+	// backlog of a tree.
+	DefaultRefreshInterval = 10 * time.Second
+)
+
+var logger = logging.New("backlog")
+
+// Limiter rejects writes to logs whose backlog of unsequenced leaves has
+// reached its limit, and exports the backlog of each tree as a metric.
+//
+// Counting the backlog of a tree may be expensive, so the Limiter counts it at
+// most once per RefreshInterval, and in between estimates it from the last
+// count plus the leaves queued through the Limiter since then, as reported by
+// Queued.
+type Limiter struct {
+	// Default is the limit of trees whose MaxUnsequencedLeaves is zero. Zero
+	// means unlimited. Use SetDefault to change it in a Limiter in use.
+	Default int64
+	// RetryAfter is the delay suggested to rejected clients.
+	RetryAfter time.Duration
+	// RefreshInterval is the period during which the backlog of a tree is
+	// estimated instead of being counted again.
+	RefreshInterval time.Duration
+
+	counter    storage.UnsequencedCounter
+	timeSource clock.TimeSource
+	depth      monitoring.Gauge
+	rejected   monitoring.Counter
+
+	mu     sync.Mutex // Guards counts, and Default after SetDefault.
+	counts map[int64]*backlogCount
+}
+
+// backlogCount is the estimated backlog of a tree.
+type backlogCount struct {
+	leaves     int64
+	countedAt  time.Time
+	refreshing bool // Whether the backlog is being counted.
+}
+
+// NewLimiter returns a Limiter which counts the backlog of trees with counter,
+// and reports metrics using mf.
+func NewLimiter(counter storage.UnsequencedCounter, def int64, mf monitoring.MetricFactory) *Limiter {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &Limiter{
+		Default:         def,
+		RetryAfter:      DefaultRetryAfter,
+		RefreshInterval: DefaultRefreshInterval,
+		counter:         counter,
+		timeSource:      clock.System,
+		depth: mf.NewGauge(
+			"unsequenced_leaves",
+			"Number of leaves queued in the log but not yet sequenced",
+			"logid"),
+		rejected: mf.NewCounter(
+			"backlog_rejected_requests",
+			"Number of requests rejected because the unsequenced backlog of their log was full",
+			"logid"),
+		counts: make(map[int64]*backlogCount),
+	}
+}
+
+// Check returns a ResourceExhausted error if the backlog of tree has reached
+// its limit, i.e. its MaxUnsequencedLeaves or else the Default, and sets the
+// RetryAfterKey response header. The backlog of trees is counted, and
+// exported, even if they have no limit. Failures to count the backlog are
+// logged, and don't block writes.
+func (l *Limiter) Check(ctx context.Context, tree *trillian.Tree) error {
+	leaves, err := l.backlog(ctx, tree)
+	if err != nil {
+		logger.Warn(ctx, "failed to count unsequenced leaves", "tree_id", tree.TreeId, "err", err)
+		return nil
+	}
+	limit := l.limit(tree)
+	if limit <= 0 || leaves < limit {
+		return nil
+	}
+
+	l.rejected.Inc(strconv.FormatInt(tree.TreeId, 10))
+	retryAfter := strconv.FormatInt(int64(math.Ceil(l.RetryAfter.Seconds())), 10)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RetryAfterKey, retryAfter)); err != nil {
+		logger.Debug(ctx, "failed to set header", "tree_id", tree.TreeId, "header", RetryAfterKey, "err", err)
+	}
+	return status.Errorf(codes.ResourceExhausted, "tree %d has %d unsequenced leaves, limit is %d: retry after %ss", tree.TreeId, leaves, limit, retryAfter)
+}
+
+// Queued adds the leaves queued to tree to the estimate of its backlog. Only
+// the leaves whose status is OK are counted, as the others, e.g. duplicates,
+// didn't join the backlog.
+func (l *Limiter) Queued(tree *trillian.Tree, leaves []*trillian.QueuedLogLeaf) {
+	var n int64
+	for _, leaf := range leaves {
+		if leaf.GetStatus().GetCode() == int32(codes.OK) {
+			n++
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.counts[tree.TreeId]; ok {
+		c.leaves += n
+	}
+}
+
+// SetDefault replaces the Default limit of the Limiter. It is safe to call
+// while the Limiter is in use.
+func (l *Limiter) SetDefault(def int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Default = def
+}
+
+func (l *Limiter) limit(tree *trillian.Tree) int64 {
+	if tree.MaxUnsequencedLeaves > 0 {
+		return int64(tree.MaxUnsequencedLeaves)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Default
+}
+
+// backlog returns the estimated number of unsequenced leaves of tree,
+// counting them if the last count is older than RefreshInterval. Only one
+// caller counts the backlog of a tree at a time, while the others use the
+// current estimate.
+func (l *Limiter) backlog(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	now := l.timeSource.Now()
+	l.mu.Lock()
+	c, ok := l.counts[tree.TreeId]
+	if ok && (c.refreshing || now.Sub(c.countedAt) < l.RefreshInterval) {
+		leaves := c.leaves
+		l.mu.Unlock()
+		return leaves, nil
+	}
+	if !ok {
+		c = &backlogCount{}
+		l.counts[tree.TreeId] = c
+	}
+	c.refreshing = true
+	l.mu.Unlock()
+
+	leaves, err := l.counter.CountUnsequenced(ctx, tree)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.refreshing = false
+	if err != nil {
+		return 0, err
+	}
+	c.leaves, c.countedAt = leaves, now
+	l.depth.Set(float64(leaves), strconv.FormatInt(tree.TreeId, 10))
+	return leaves, nil
+}
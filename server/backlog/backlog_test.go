@@ -0,0 +1,138 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeCounter returns the backlogs of trees from a map, and counts calls.
+type fakeCounter struct {
+	backlogs map[int64]int64
+	err      error
+	calls    int
+}
+
+func (f *fakeCounter) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	f.calls++
+	return f.backlogs[tree.TreeId], f.err
+}
+
+func TestLimiterCheck(t *testing.T) {
+	counter := &fakeCounter{backlogs: map[int64]int64{1: 10, 2: 10, 3: 1000}}
+	l := NewLimiter(counter, 100, nil /* mf */)
+
+	for _, test := range []struct {
+		desc     string
+		tree     *trillian.Tree
+		wantCode codes.Code
+	}{
+		{desc: "per-tree limit reached", tree: &trillian.Tree{TreeId: 1, MaxUnsequencedLeaves: 5}, wantCode: codes.ResourceExhausted},
+		{desc: "under default limit", tree: &trillian.Tree{TreeId: 2}, wantCode: codes.OK},
+		{desc: "default limit reached", tree: &trillian.Tree{TreeId: 3}, wantCode: codes.ResourceExhausted},
+		{desc: "under per-tree limit", tree: &trillian.Tree{TreeId: 3, MaxUnsequencedLeaves: 2000}, wantCode: codes.OK},
+	} {
+		err := l.Check(context.Background(), test.tree)
+		if got := status.Code(err); got != test.wantCode {
+			t.Errorf("%v: Check(): %v, want code %v", test.desc, err, test.wantCode)
+		}
+	}
+}
+
+func TestLimiterRefresh(t *testing.T) {
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 1}
+	counter := &fakeCounter{backlogs: map[int64]int64{1: 10}}
+	ts := clock.NewFake(time.Unix(1000, 0))
+	l := NewLimiter(counter, 10, nil /* mf */)
+	l.timeSource = ts
+
+	if err := l.Check(ctx, tree); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Check(): %v, want code %v", err, codes.ResourceExhausted)
+	}
+
+	// The count is cached until RefreshInterval elapses.
+	counter.backlogs[1] = 0
+	ts.Set(ts.Now().Add(l.RefreshInterval / 2))
+	if err := l.Check(ctx, tree); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Check() before refresh: %v, want code %v", err, codes.ResourceExhausted)
+	}
+	if got, want := counter.calls, 1; got != want {
+		t.Errorf("CountUnsequenced() called %d times, want %d", got, want)
+	}
+
+	ts.Set(ts.Now().Add(l.RefreshInterval))
+	if err := l.Check(ctx, tree); err != nil {
+		t.Errorf("Check() after refresh: %v, want nil", err)
+	}
+	if got, want := counter.calls, 2; got != want {
+		t.Errorf("CountUnsequenced() called %d times, want %d", got, want)
+	}
+}
+
+func TestLimiterCountError(t *testing.T) {
+	counter := &fakeCounter{err: errors.New("count failed")}
+	l := NewLimiter(counter, 1, nil /* mf */)
+	// Counting failures must not block writes.
+	if err := l.Check(context.Background(), &trillian.Tree{TreeId: 1}); err != nil {
+		t.Errorf("Check(): %v, want nil", err)
+	}
+}
+
+func TestLimiterQueued(t *testing.T) {
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 1}
+	counter := &fakeCounter{backlogs: map[int64]int64{1: 8}}
+	l := NewLimiter(counter, 10, nil /* mf */)
+
+	if err := l.Check(ctx, tree); err != nil {
+		t.Fatalf("Check(): %v, want nil", err)
+	}
+	// Duplicates don't join the backlog.
+	l.Queued(tree, []*trillian.QueuedLogLeaf{{}, {Status: status.New(codes.AlreadyExists, "dup").Proto()}})
+	if err := l.Check(ctx, tree); err != nil {
+		t.Fatalf("Check() after 1 queued leaf: %v, want nil", err)
+	}
+	l.Queued(tree, []*trillian.QueuedLogLeaf{{}})
+	if err := l.Check(ctx, tree); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Check() after 2 queued leaves: %v, want code %v", err, codes.ResourceExhausted)
+	}
+	if got, want := counter.calls, 1; got != want {
+		t.Errorf("CountUnsequenced() called %d times, want %d", got, want)
+	}
+}
+
+func TestLimiterSetDefault(t *testing.T) {
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 1}
+	counter := &fakeCounter{backlogs: map[int64]int64{1: 10}}
+	l := NewLimiter(counter, 100, nil /* mf */)
+
+	if err := l.Check(ctx, tree); err != nil {
+		t.Fatalf("Check(): %v, want nil", err)
+	}
+	l.SetDefault(10)
+	if err := l.Check(ctx, tree); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Check() after SetDefault(): %v, want code %v", err, codes.ResourceExhausted)
+	}
+}
@@ -16,6 +16,7 @@ package errors
 
 import (
 	"database/sql"
+	"errors"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -24,7 +25,7 @@ import (
 // WrapError wraps err as a gRPC error if err is a well-known error instance
 // (such as canonical SQL errors), else err is returned unmodified.
 func WrapError(err error) error {
-	if err == sql.ErrNoRows {
+	if errors.Is(err, sql.ErrNoRows) {
 		return status.Errorf(codes.NotFound, err.Error())
 	}
 
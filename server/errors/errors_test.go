@@ -17,6 +17,7 @@ package errors
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
 
 	_ "github.com/golang/glog"
@@ -44,6 +45,10 @@ func TestWrapError(t *testing.T) {
 			err:     sql.ErrNoRows,
 			wantErr: status.Errorf(codes.NotFound, sql.ErrNoRows.Error()),
 		},
+		{
+			err:     fmt.Errorf("query failed: %w", sql.ErrNoRows),
+			wantErr: status.Errorf(codes.NotFound, "query failed: %v", sql.ErrNoRows),
+		},
 	}
 	for _, test := range tests {
 		// We can't use == for rpcErrors because grpc.Errorf returns *rpcError.
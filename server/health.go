@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+)
+
+// HealthChecker checks that the storage of a server is usable, and that the
+// signer keeps growing logs, by reading the latest root of a probe log.
+type HealthChecker struct {
+	registry    extension.Registry
+	probeTreeID int64
+	slack       time.Duration
+	timeSource  clock.TimeSource
+}
+
+// NewHealthChecker returns a HealthChecker reading the root of the given probe
+// log. The root must be at most the MaxRootDuration of the log old, plus
+// slack, which allows for the signer's run interval and election delays.
+func NewHealthChecker(registry extension.Registry, probeTreeID int64, slack time.Duration, timeSource clock.TimeSource) *HealthChecker {
+	return &HealthChecker{registry: registry, probeTreeID: probeTreeID, slack: slack, timeSource: timeSource}
+}
+
+// Check returns nil if the probe log can be read, and its root is recent
+// enough. The age of the root isn't checked if the log isn't active, or has no
+// MaxRootDuration, as the signer then doesn't need to produce new roots.
+func (h *HealthChecker) Check(ctx context.Context) error {
+	ctx, spanEnd := spanFor(ctx, "HealthChecker.Check")
+	defer spanEnd()
+
+	tree, err := storage.GetTree(ctx, h.registry.AdminStorage, h.probeTreeID)
+	if err != nil {
+		return fmt.Errorf("failed to read probe tree %d: %v", h.probeTreeID, err)
+	}
+	root, err := h.latestRoot(ctx, tree)
+	if err != nil {
+		return fmt.Errorf("failed to read root of probe tree %d: %v", h.probeTreeID, err)
+	}
+
+	maxRootDuration := tree.MaxRootDuration.AsDuration()
+	if tree.TreeState != trillian.TreeState_ACTIVE || maxRootDuration <= 0 {
+		return nil
+	}
+	age := h.timeSource.Now().Sub(time.Unix(0, int64(root.TimestampNanos)))
+	if maxAge := maxRootDuration + h.slack; age > maxAge {
+		return fmt.Errorf("root of probe tree %d is %v old, want at most %v", h.probeTreeID, age, maxAge)
+	}
+	return nil
+}
+
+func (h *HealthChecker) latestRoot(ctx context.Context, tree *trillian.Tree) (*types.LogRootV1, error) {
+	tx, err := h.registry.LogStorage.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.GetLogRoot()); err != nil {
+		return nil, err
+	}
+	return &root, tx.Commit(ctx)
+}
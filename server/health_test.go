@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestHealthChecker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	rootAt := func(age time.Duration) *trillian.SignedLogRoot {
+		root := &types.LogRootV1{TimestampNanos: uint64(now.Add(-age).UnixNano()), RootHash: []byte("A NICE HASH"), TreeSize: 7}
+		rootBytes, err := root.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return &trillian.SignedLogRoot{LogRoot: rootBytes}
+	}
+
+	for _, tc := range []struct {
+		desc            string
+		state           trillian.TreeState
+		maxRootDuration time.Duration
+		treeErr         error
+		root            *trillian.SignedLogRoot
+		rootErr         error
+		wantErr         string
+	}{
+		{desc: "fresh", maxRootDuration: time.Hour, root: rootAt(time.Hour + time.Minute)},
+		{desc: "stale", maxRootDuration: time.Hour, root: rootAt(time.Hour + 2*time.Minute), wantErr: "old, want at most 1h1m0s"},
+		{desc: "no max root duration", root: rootAt(24 * time.Hour)},
+		{desc: "frozen", state: trillian.TreeState_FROZEN, maxRootDuration: time.Hour, root: rootAt(24 * time.Hour)},
+		{desc: "tree error", treeErr: errors.New("connection refused"), wantErr: "failed to read probe tree 1: connection refused"},
+		{desc: "root error", rootErr: errors.New("connection refused"), wantErr: "failed to read root of probe tree 1: connection refused"},
+		{desc: "corrupt root", root: corruptLogRoot, wantErr: "failed to read root of probe tree 1"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tree := proto.Clone(tree1).(*trillian.Tree)
+			tree.TreeState = trillian.TreeState_ACTIVE
+			if tc.state != trillian.TreeState_UNKNOWN_TREE_STATE {
+				tree.TreeState = tc.state
+			}
+			tree.MaxRootDuration = durationpb.New(tc.maxRootDuration)
+
+			adminStorage := storage.NewMockAdminStorage(ctrl)
+			adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			adminStorage.EXPECT().Snapshot(gomock.Any()).Return(adminTX, nil)
+			adminTX.EXPECT().GetTree(gomock.Any(), logID1).Return(tree, tc.treeErr)
+			adminTX.EXPECT().Close().Return(nil)
+			adminTX.EXPECT().Commit().MaxTimes(1).Return(nil)
+
+			logStorage := storage.NewMockLogStorage(ctrl)
+			if tc.treeErr == nil {
+				mockTX := storage.NewMockLogTreeTX(ctrl)
+				logStorage.EXPECT().SnapshotForTree(gomock.Any(), cmpMatcher{tree}).Return(mockTX, nil)
+				mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(tc.root, tc.rootErr)
+				mockTX.EXPECT().Commit(gomock.Any()).MaxTimes(1).Return(nil)
+				mockTX.EXPECT().Close().Return(nil)
+			}
+
+			registry := extension.Registry{AdminStorage: adminStorage, LogStorage: logStorage}
+			h := NewHealthChecker(registry, logID1, time.Minute, clock.NewFake(now))
+			err := h.Check(ctx)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check() = %v, want nil", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Check() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inprocess runs a Trillian log server, log signer and admin server
+// within the calling process, so that small personalities can ship as a single
+// binary rather than run Trillian alongside.
+//
+// The services are served over an in-memory connection, and used through the
+// usual gRPC clients, so that code written against a remote Trillian works
+// unchanged. Storage is chosen by the caller, e.g.:
+//
+//	sp, err := storage.NewProvider("mysql", nil)
+//	...
+//	t, err := inprocess.New(ctx, extension.Registry{
+//		AdminStorage: sp.AdminStorage(),
+//		LogStorage:   sp.LogStorage(),
+//	}, inprocess.Options{})
+//	...
+//	defer t.Close(ctx)
+//	tree, err := t.Admin.CreateTree(ctx, ...)
+package inprocess
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+)
+
+const (
+	// DefaultBatchSize is the default maximum number of leaves sequenced per
+	// log in each pass.
+	DefaultBatchSize = 1000
+	// DefaultNumSequencers is the default number of logs sequenced in
+	// parallel.
+	DefaultNumSequencers = 10
+	// DefaultSequencerInterval is the default time between sequencing passes.
+	DefaultSequencerInterval = 100 * time.Millisecond
+
+	bufSize = 1 << 20
+)
+
+// Options holds the settings of an in-process Trillian. The zero value uses
+// the defaults of the Trillian binaries.
+type Options struct {
+	// BatchSize is the maximum number of leaves sequenced per log in each
+	// pass, for trees which don't set their own.
+	BatchSize int
+	// NumSequencers is the number of logs sequenced in parallel.
+	NumSequencers int
+	// SequencerInterval is the time between sequencing passes.
+	SequencerInterval time.Duration
+	// SequencerGuardWindow is the time elapsed before queued leaves are
+	// eligible for sequencing, for trees which don't set their own.
+	SequencerGuardWindow time.Duration
+	// TimeSource is used by the log server and signer. The system clock is
+	// used if it is nil.
+	TimeSource clock.TimeSource
+	// ServerOptions are added to the options of the gRPC server.
+	ServerOptions []grpc.ServerOption
+}
+
+// Trillian is a Trillian running in-process.
+type Trillian struct {
+	// Log is a client of the log server.
+	Log trillian.TrillianLogClient
+	// Admin is a client of the admin server.
+	Admin trillian.TrillianAdminClient
+	// Conn is the connection of the clients, e.g. for creating other clients.
+	Conn *grpc.ClientConn
+
+	grpcServer *grpc.Server
+	sequencer  *log.OperationManager
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// New starts a Trillian using the storage of registry, whose other fields are
+// optional: QuotaManager defaults to quota.Noop, and MetricFactory to
+// monitoring.InertMetricFactory. If ElectionFactory is nil, this process
+// sequences all the logs, so it must be the only one using the storage.
+//
+// The signer runs until Close is called, rather than until ctx is canceled.
+func New(ctx context.Context, registry extension.Registry, opts Options) (*Trillian, error) {
+	if registry.QuotaManager == nil {
+		registry.QuotaManager = quota.Noop()
+	}
+	if registry.MetricFactory == nil {
+		registry.MetricFactory = monitoring.InertMetricFactory{}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.NumSequencers <= 0 {
+		opts.NumSequencers = DefaultNumSequencers
+	}
+	if opts.SequencerInterval <= 0 {
+		opts.SequencerInterval = DefaultSequencerInterval
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+
+	ti := interceptor.New(registry.AdminStorage, registry.QuotaManager, false /* quotaDryRun */, registry.MetricFactory)
+	serverOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(interceptor.RequestID, interceptor.ErrorWrapper, ti.UnaryInterceptor)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(interceptor.StreamRequestID, interceptor.StreamErrorWrapper, ti.StreamInterceptor)),
+	}
+	grpcServer := grpc.NewServer(append(serverOpts, opts.ServerOptions...)...)
+	trillian.RegisterTrillianAdminServer(grpcServer, admin.New(registry, []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}))
+	trillian.RegisterTrillianLogServer(grpcServer, server.NewTrillianLogRPCServer(registry, opts.TimeSource))
+
+	lis := bufconn.Listen(bufSize)
+	conn, err := grpc.DialContext(ctx, "inprocess",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Trillian{
+		Log:        trillian.NewTrillianLogClient(conn),
+		Admin:      trillian.NewTrillianAdminClient(conn),
+		Conn:       conn,
+		grpcServer: grpcServer,
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if err := grpcServer.Serve(lis); err != nil {
+			glog.Errorf("In-process gRPC server stopped: %v", err)
+		}
+	}()
+
+	t.sequencer = log.NewOperationManager(log.OperationInfo{
+		Registry:    registry,
+		BatchSize:   opts.BatchSize,
+		NumWorkers:  opts.NumSequencers,
+		RunInterval: opts.SequencerInterval,
+		TimeSource:  opts.TimeSource,
+	}, log.NewSequencerManager(registry, opts.SequencerGuardWindow))
+	var opCtx context.Context
+	opCtx, t.cancel = context.WithCancel(context.Background())
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.sequencer.OperationLoop(opCtx)
+	}()
+	return t, nil
+}
+
+// Close stops the signer, letting the in-progress sequencing pass finish until
+// ctx is done, and the servers. It doesn't close the storage.
+func (t *Trillian) Close(ctx context.Context) error {
+	err := t.sequencer.Shutdown(ctx)
+	t.cancel()
+	t.Conn.Close()
+	t.grpcServer.GracefulStop()
+	t.wg.Wait()
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inprocess
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/types"
+	"google.golang.org/protobuf/proto"
+
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+func TestInProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tr, err := New(ctx, registry, Options{SequencerInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	defer func() {
+		if err := tr.Close(ctx); err != nil {
+			t.Errorf("Close(): %v", err)
+		}
+	}()
+
+	tree, err := tr.Admin.CreateTree(ctx, &trillian.CreateTreeRequest{Tree: proto.Clone(stestonly.LogTree).(*trillian.Tree)})
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	if _, err := tr.Log.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+	leaf := &trillian.LogLeaf{LeafValue: []byte("leaf")}
+	if _, err := tr.Log.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: leaf}); err != nil {
+		t.Fatalf("QueueLeaf(): %v", err)
+	}
+
+	// The signer integrates the leaf.
+	for {
+		resp, err := tr.Log.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: tree.TreeId})
+		if err != nil {
+			t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(resp.SignedLogRoot.LogRoot); err != nil {
+			t.Fatalf("UnmarshalBinary(): %v", err)
+		}
+		if root.TreeSize == 1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("leaf not integrated: %v", ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
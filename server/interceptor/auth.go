@@ -0,0 +1,607 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// AuthorizationMetadataKey is the gRPC metadata key of the bearer tokens
+	// authenticating requests, as "Bearer <token>".
+	AuthorizationMetadataKey = "authorization"
+
+	// DefaultKeyRefreshInterval is the default time after which the keys of
+	// OIDC issuers are fetched again.
+	DefaultKeyRefreshInterval = time.Hour
+	// DefaultAuthLeeway is the default clock skew tolerated when checking the
+	// validity period of tokens.
+	DefaultAuthLeeway = time.Minute
+
+	// minKeyRefreshInterval is the minimum time between fetches of the keys of
+	// an issuer triggered by tokens signed with unknown keys, which bounds the
+	// load that invalid tokens put on the issuer.
+	minKeyRefreshInterval = time.Minute
+	// maxDiscoveryBytes bounds the size of the discovery documents and key
+	// sets read from issuers.
+	maxDiscoveryBytes = 1 << 20
+)
+
+// validMethods are the signing algorithms accepted, which are the asymmetric
+// ones, as the keys of issuers are public.
+var validMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// DefaultPublicMethods are the methods of the standard gRPC health checking and
+// reflection services, which don't need authentication.
+var DefaultPublicMethods = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// OIDCIssuer is an OpenID Connect issuer whose tokens are accepted.
+type OIDCIssuer struct {
+	// URL identifies the issuer, and must match the iss claim of its tokens.
+	// Its keys are found through the discovery document at
+	// URL/.well-known/openid-configuration, unless JWKSURL is set.
+	URL string
+	// Audiences are the audiences accepted, one of which the aud claim of the
+	// tokens must contain, e.g. the URL or client ID of the Trillian server.
+	Audiences []string
+	// JWKSURL, if set, is the URL of the JSON Web Key Set of the issuer.
+	JWKSURL string
+}
+
+// AuthOptions configures an Authenticator.
+type AuthOptions struct {
+	// Issuers are the issuers whose tokens are accepted.
+	Issuers []OIDCIssuer
+	// AnonymousReads lets requests without a token call the RPCs of the Trillian
+	// services which don't write to storage. Writes always need a valid token.
+	AnonymousReads bool
+	// TenantClaim is the claim naming the tenant, or array of tenants, of the
+	// token subject. The tenants of authenticated requests are taken from this
+	// claim, rather than from their TenantMetadataKey metadata, which callers
+	// could set to anything. Requests are charged to no tenant if it's empty.
+	TenantClaim string
+	// RoleClaim is the claim naming the role, or array of roles, of the token
+	// subject.
+	RoleClaim string
+	// AdminRoles are the roles allowed to call the TrillianAdmin service, one
+	// of which the RoleClaim claim must contain. No one can call it if empty.
+	AdminRoles []string
+	// PublicMethods are full method names, or prefixes of them ending with "/"
+	// for whole services, which need no token. DefaultPublicMethods if nil.
+	PublicMethods []string
+	// Leeway is the clock skew tolerated when checking the validity period of
+	// tokens. DefaultAuthLeeway if zero.
+	Leeway time.Duration
+	// KeyRefreshInterval is the time after which the keys of issuers are
+	// fetched again. DefaultKeyRefreshInterval if zero.
+	KeyRefreshInterval time.Duration
+	// HTTPClient fetches the discovery documents and keys of issuers.
+	// http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// TimeSource is used to check the validity period of tokens. clock.System
+	// if nil.
+	TimeSource clock.TimeSource
+}
+
+// Claims are the claims of a verified token, which the Authenticator attaches
+// to the context of requests for quota and access control decisions.
+type Claims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expiry   time.Time
+	IssuedAt time.Time
+	// Tenants are the tenants named by the AuthOptions.TenantClaim claim.
+	Tenants []string
+	// Roles are the roles named by the AuthOptions.RoleClaim claim.
+	Roles []string
+	// Raw holds all the claims of the token, decoded from JSON.
+	Raw map[string]interface{}
+}
+
+type claimsContextKey struct{}
+
+// NewClaimsContext returns a copy of ctx carrying claims.
+func NewClaimsContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the token which authenticated the
+// request of ctx, if any.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+// Authenticator is a gRPC interceptor verifying the JWT bearer tokens of
+// requests against OIDC issuers. The claims of valid tokens are attached to the
+// request context, where ClaimsFromContext finds them, and the subject is
+// charged for User quota. The TenantMetadataKey metadata of requests is
+// replaced by the tenants of their token, if any. Requests with invalid tokens
+// are rejected, as are those without one, except for reads if AnonymousReads
+// is set. The TrillianAdmin service also needs one of the AdminRoles.
+type Authenticator struct {
+	opts    AuthOptions
+	issuers map[string]*issuerKeys
+}
+
+// NewAuthenticator returns an Authenticator for the given options. Keys are
+// fetched from the issuers when first needed.
+func NewAuthenticator(opts AuthOptions) (*Authenticator, error) {
+	if len(opts.Issuers) == 0 {
+		return nil, errors.New("no OIDC issuers")
+	}
+	if opts.PublicMethods == nil {
+		opts.PublicMethods = DefaultPublicMethods
+	}
+	if opts.Leeway == 0 {
+		opts.Leeway = DefaultAuthLeeway
+	}
+	if opts.KeyRefreshInterval == 0 {
+		opts.KeyRefreshInterval = DefaultKeyRefreshInterval
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	a := &Authenticator{opts: opts, issuers: make(map[string]*issuerKeys)}
+	for _, iss := range opts.Issuers {
+		if iss.URL == "" {
+			return nil, errors.New("OIDC issuer without URL")
+		}
+		if len(iss.Audiences) == 0 {
+			return nil, fmt.Errorf("OIDC issuer %q has no audiences", iss.URL)
+		}
+		if _, ok := a.issuers[iss.URL]; ok {
+			return nil, fmt.Errorf("duplicate OIDC issuer %q", iss.URL)
+		}
+		a.issuers[iss.URL] = &issuerKeys{OIDCIssuer: iss, client: opts.HTTPClient}
+	}
+	return a, nil
+}
+
+// UnaryInterceptor is a grpc.UnaryServerInterceptor authenticating requests.
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if a.public(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkAnonymous(ctx, req, info.FullMethod); err != nil {
+		return nil, err
+	}
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor is a grpc.StreamServerInterceptor authenticating streams.
+// The token is verified when the stream starts, and anonymous streams are
+// checked as each request is received.
+func (a *Authenticator) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if a.public(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx, a: a, method: info.FullMethod})
+}
+
+// authStream is a grpc.ServerStream carrying the claims of its token, which
+// rejects the requests an anonymous caller can't make.
+type authStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	a      *Authenticator
+	method string
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.a.checkAnonymous(s.ctx, m, s.method)
+}
+
+// public returns whether method needs no authentication.
+func (a *Authenticator) public(method string) bool {
+	for _, m := range a.opts.PublicMethods {
+		if method == m || (strings.HasSuffix(m, "/") && strings.HasPrefix(method, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns ctx with the claims of the bearer token of the request,
+// if there is one, or an Unauthenticated error if it's invalid. The tenants of
+// the incoming metadata of the returned context are those of the token.
+func (a *Authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Delete(TenantMetadataKey)
+	values := md.Get(AuthorizationMetadataKey)
+	if len(values) == 0 {
+		return metadata.NewIncomingContext(ctx, md), nil
+	}
+	const prefix = "bearer "
+	if len(values) > 1 || len(values[0]) <= len(prefix) || !strings.EqualFold(values[0][:len(prefix)], prefix) {
+		return nil, status.Error(codes.Unauthenticated, "authorization must be a single bearer token")
+	}
+	claims, err := a.Verify(ctx, strings.TrimSpace(values[0][len(prefix):]))
+	if err != nil {
+		logger.Info(ctx, "rejected bearer token", "err", err)
+		return nil, status.Errorf(codes.Unauthenticated, "invalid bearer token: %v", err)
+	}
+	md.Append(TenantMetadataKey, claims.Tenants...)
+	return NewClaimsContext(metadata.NewIncomingContext(ctx, md), claims), nil
+}
+
+// checkAnonymous returns an error if the request has no claims, and isn't a
+// read allowed by AnonymousReads.
+func (a *Authenticator) checkAnonymous(ctx context.Context, req interface{}, method string) error {
+	if _, ok := ClaimsFromContext(ctx); ok {
+		return nil
+	}
+	if a.opts.AnonymousReads && enabledServices[serviceName(method)] {
+		if info, err := newRPCInfoForRequest(req); err == nil && info.readonly {
+			return nil
+		}
+	}
+	return status.Errorf(codes.Unauthenticated, "%s needs a bearer token", method)
+}
+
+// authorize returns a PermissionDenied error if method is one of the
+// TrillianAdmin service, and the request has no claims with one of the
+// AdminRoles.
+func (a *Authenticator) authorize(ctx context.Context, method string) error {
+	if svc := serviceName(method); svc != "trillian.TrillianAdmin" && svc != "TrillianAdmin" {
+		return nil
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok && intersects(claims.Roles, a.opts.AdminRoles) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "%s needs an admin role", method)
+}
+
+// Verify returns the claims of token, if it's a valid JWT of one of the
+// issuers, issued for one of their audiences, and currently valid.
+func (a *Authenticator) Verify(ctx context.Context, token string) (*Claims, error) {
+	var claims *Claims
+	parser := jwt.Parser{
+		ValidMethods: validMethods,
+		// The validity period is checked below, with leeway.
+		SkipClaimsValidation: true,
+	}
+	_, err := parser.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		raw, ok := t.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, errors.New("malformed JWT claims")
+		}
+		var err error
+		if claims, err = parseClaims(raw); err != nil {
+			return nil, err
+		}
+		if a.opts.TenantClaim != "" {
+			if claims.Tenants, err = stringsClaim(raw[a.opts.TenantClaim]); err != nil {
+				return nil, fmt.Errorf("malformed %s claim: %v", a.opts.TenantClaim, err)
+			}
+		}
+		if a.opts.RoleClaim != "" {
+			if claims.Roles, err = stringsClaim(raw[a.opts.RoleClaim]); err != nil {
+				return nil, fmt.Errorf("malformed %s claim: %v", a.opts.RoleClaim, err)
+			}
+		}
+		iss, ok := a.issuers[claims.Issuer]
+		if !ok {
+			return nil, fmt.Errorf("unknown issuer %q", claims.Issuer)
+		}
+		if !intersects(claims.Audience, iss.Audiences) {
+			return nil, fmt.Errorf("audience %v not accepted", claims.Audience)
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, err := iss.key(ctx, kid, a.opts.TimeSource.Now(), a.opts.KeyRefreshInterval)
+		if err != nil {
+			return nil, err
+		}
+		// The library checks the key type, but not the curve of ECDSA keys.
+		if m, ok := t.Method.(*jwt.SigningMethodECDSA); ok {
+			if pub, ok := key.(*ecdsa.PublicKey); ok && pub.Curve.Params().BitSize != m.CurveBits {
+				return nil, fmt.Errorf("algorithm %q can't use an ECDSA key on %s", m.Alg(), pub.Curve.Params().Name)
+			}
+		}
+		return key, nil
+	})
+	if err != nil {
+		// The errors returned by the key function are wrapped.
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Inner != nil {
+			err = ve.Inner
+		}
+		return nil, err
+	}
+
+	now := a.opts.TimeSource.Now()
+	if claims.Expiry.IsZero() {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(claims.Expiry.Add(a.opts.Leeway)) {
+		return nil, fmt.Errorf("token expired at %v", claims.Expiry)
+	}
+	if nbf, ok := numericDate(claims.Raw["nbf"]); ok && now.Add(a.opts.Leeway).Before(nbf) {
+		return nil, fmt.Errorf("token not valid before %v", nbf)
+	}
+	return claims, nil
+}
+
+// parseClaims returns the registered claims of raw.
+func parseClaims(raw jwt.MapClaims) (*Claims, error) {
+	c := &Claims{Raw: raw}
+	var ok bool
+	if c.Issuer, ok = raw["iss"].(string); !ok {
+		return nil, errors.New("token has no issuer")
+	}
+	if c.Subject, ok = raw["sub"].(string); !ok || c.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	var err error
+	if c.Audience, err = stringsClaim(raw["aud"]); err != nil {
+		return nil, errors.New("malformed audience")
+	}
+	c.Expiry, _ = numericDate(raw["exp"])
+	c.IssuedAt, _ = numericDate(raw["iat"])
+	return c, nil
+}
+
+// stringsClaim returns the values of a claim which is either a string or an
+// array of strings, if it's set.
+func stringsClaim(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		ret := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a string", e)
+			}
+			ret = append(ret, s)
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("%v is not a string or array of strings", v)
+	}
+}
+
+// numericDate returns the time of a JWT NumericDate claim.
+func numericDate(v interface{}) (time.Time, bool) {
+	secs, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(secs*float64(time.Second))), true
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// issuerKeys caches the keys of an issuer.
+type issuerKeys struct {
+	OIDCIssuer
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// key returns the key of the issuer with the given ID, fetching the keys if
+// they're stale, or if the ID is unknown and they weren't fetched recently.
+func (k *issuerKeys) key(ctx context.Context, kid string, now time.Time, refresh time.Duration) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.keys[kid]
+	age := now.Sub(k.fetched)
+	if k.keys == nil || age >= refresh || (!ok && age >= minKeyRefreshInterval) {
+		keys, err := k.fetch(ctx)
+		if err != nil {
+			if k.keys == nil {
+				return nil, fmt.Errorf("failed to fetch keys of issuer %q: %v", k.URL, err)
+			}
+			// Keep using the stale keys while the issuer is unavailable.
+			logger.Warn(ctx, "failed to refresh OIDC issuer keys", "issuer", k.URL, "err", err)
+		} else {
+			k.keys, k.fetched = keys, now
+		}
+		key, ok = k.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q of issuer %q", kid, k.URL)
+	}
+	return key, nil
+}
+
+// fetch returns the keys of the issuer, by key ID.
+func (k *issuerKeys) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := k.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := k.get(ctx, strings.TrimSuffix(k.URL, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.Issuer != k.URL {
+			return nil, fmt.Errorf("discovery document is for issuer %q", discovery.Issuer)
+		}
+		jwksURL = discovery.JWKSURI
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := k.get(ctx, jwksURL, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, j := range jwks.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			// Skip the keys of unsupported types.
+			continue
+		}
+		keys[j.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *issuerKeys) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryBytes))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("GET %s: %v", url, err)
+	}
+	return nil
+}
+
+// jwk is a JSON Web Key, of which RSA and EC public keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,452 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const testAudience = "trillian-test"
+
+// fakeIssuer is an OIDC issuer serving its discovery document and keys.
+type fakeIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	kids    map[string]bool
+	fetches int
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	iss := &fakeIssuer{rsaKey: rsaKey, ecKey: ecKey, kids: map[string]bool{"rsa": true, "ec": true}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		iss.fetches++
+		var keys []map[string]string
+		if iss.kids["rsa"] {
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": "rsa", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			})
+		}
+		if iss.kids["ec"] {
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": "ec", "crv": "P-256",
+				"x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes()),
+			})
+		}
+		keys = append(keys, map[string]string{"kty": "oct", "kid": "hmac", "k": b64([]byte("secret"))})
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// token returns a JWT with the given claims, signed with the key kid.
+func (iss *fakeIssuer) token(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	default:
+		sig = []byte("unsigned")
+	}
+	if err != nil {
+		t.Fatalf("signing failed: %v", err)
+	}
+	return signed + "." + b64(sig)
+}
+
+// swapSignature returns token with the signature of other.
+func swapSignature(token, other string) string {
+	return token[:strings.LastIndex(token, ".")] + other[strings.LastIndex(other, "."):]
+}
+
+func (iss *fakeIssuer) claims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": iss.URL,
+		"sub": "alice",
+		"aud": []string{"other", testAudience},
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+		"grp": "admins",
+	}
+}
+
+func newTestAuthenticator(t *testing.T, iss *fakeIssuer, ts clock.TimeSource, anonymousReads bool) *Authenticator {
+	t.Helper()
+	a, err := NewAuthenticator(AuthOptions{
+		Issuers:        []OIDCIssuer{{URL: iss.URL, Audiences: []string{testAudience}}},
+		AnonymousReads: anonymousReads,
+		TimeSource:     ts,
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() = %v", err)
+	}
+	return a
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	for _, opts := range []AuthOptions{
+		{},
+		{Issuers: []OIDCIssuer{{Audiences: []string{testAudience}}}},
+		{Issuers: []OIDCIssuer{{URL: "https://issuer"}}},
+		{Issuers: []OIDCIssuer{{URL: "https://issuer", Audiences: []string{"a"}}, {URL: "https://issuer", Audiences: []string{"b"}}}},
+	} {
+		if _, err := NewAuthenticator(opts); err == nil {
+			t.Errorf("NewAuthenticator(%+v) = nil, want error", opts)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	iss := newFakeIssuer(t)
+	now := time.Unix(1660000000, 0)
+	a := newTestAuthenticator(t, iss, clock.NewFake(now), false)
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := iss.claims(now)
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+	for _, tc := range []struct {
+		desc    string
+		token   string
+		wantErr bool
+	}{
+		{desc: "RS256", token: iss.token(t, "RS256", "rsa", iss.claims(now))},
+		{desc: "PS256", token: iss.token(t, "PS256", "rsa", iss.claims(now))},
+		{desc: "ES256", token: iss.token(t, "ES256", "ec", iss.claims(now))},
+		{desc: "singleAudience", token: iss.token(t, "RS256", "rsa", with("aud", testAudience))},
+		{desc: "withinLeeway", token: iss.token(t, "RS256", "rsa", with("exp", now.Add(-30*time.Second).Unix()))},
+		{desc: "expired", token: iss.token(t, "RS256", "rsa", with("exp", now.Add(-time.Hour).Unix())), wantErr: true},
+		{desc: "noExpiry", token: iss.token(t, "RS256", "rsa", with("exp", nil)), wantErr: true},
+		{desc: "notYetValid", token: iss.token(t, "RS256", "rsa", with("nbf", now.Add(time.Hour).Unix())), wantErr: true},
+		{desc: "wrongAudience", token: iss.token(t, "RS256", "rsa", with("aud", "other")), wantErr: true},
+		{desc: "unknownIssuer", token: iss.token(t, "RS256", "rsa", with("iss", "https://evil")), wantErr: true},
+		{desc: "noSubject", token: iss.token(t, "RS256", "rsa", with("sub", nil)), wantErr: true},
+		{desc: "unknownKey", token: iss.token(t, "RS256", "other", iss.claims(now)), wantErr: true},
+		{desc: "symmetricKey", token: iss.token(t, "HS256", "hmac", iss.claims(now)), wantErr: true},
+		{desc: "algNone", token: iss.token(t, "none", "rsa", iss.claims(now)), wantErr: true},
+		{desc: "algMismatch", token: iss.token(t, "RS256", "ec", iss.claims(now)), wantErr: true},
+		{desc: "badSignature", token: swapSignature(iss.token(t, "RS256", "rsa", iss.claims(now)), iss.token(t, "RS256", "rsa", with("sub", "mallory"))), wantErr: true},
+		{desc: "malformed", token: "not.a-jwt", wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			claims, err := a.Verify(ctx, tc.token)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Verify() = %v, want error %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if claims.Issuer != iss.URL || claims.Subject != "alice" || claims.Raw["grp"] != "admins" {
+				t.Errorf("Verify() = %+v, want claims of alice", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	iss := newFakeIssuer(t)
+	now := time.Unix(1660000000, 0)
+	ts := clock.NewFake(now)
+	a := newTestAuthenticator(t, iss, ts, false)
+
+	iss.kids = map[string]bool{"rsa": true}
+	if _, err := a.Verify(ctx, iss.token(t, "RS256", "rsa", iss.claims(now))); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	// A token signed with a new key is rejected until the keys can be fetched
+	// again.
+	iss.mu.Lock()
+	iss.kids["ec"] = true
+	iss.mu.Unlock()
+	if _, err := a.Verify(ctx, iss.token(t, "ES256", "ec", iss.claims(now))); err == nil {
+		t.Error("Verify() with key fetched too recently = nil, want error")
+	}
+	ts.Set(now.Add(minKeyRefreshInterval))
+	if _, err := a.Verify(ctx, iss.token(t, "ES256", "ec", iss.claims(now))); err != nil {
+		t.Errorf("Verify() with new key = %v", err)
+	}
+	if iss.fetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", iss.fetches)
+	}
+}
+
+func TestAuthenticatorUnaryInterceptor(t *testing.T) {
+	iss := newFakeIssuer(t)
+	now := time.Now()
+	valid := iss.token(t, "RS256", "rsa", iss.claims(now))
+	read := &trillian.GetLatestSignedLogRootRequest{LogId: 1}
+	write := &trillian.QueueLeafRequest{LogId: 1}
+	for _, tc := range []struct {
+		desc           string
+		anonymousReads bool
+		auth           []string
+		method         string
+		req            interface{}
+		wantCode       codes.Code
+		wantSubject    string
+	}{
+		{desc: "validRead", auth: []string{"Bearer " + valid}, req: read, wantSubject: "alice"},
+		{desc: "validWrite", auth: []string{"bearer " + valid}, req: write, wantSubject: "alice"},
+		{desc: "anonymousRead", req: read, wantCode: codes.Unauthenticated},
+		{desc: "anonymousReadAllowed", anonymousReads: true, req: read},
+		{desc: "anonymousWrite", anonymousReads: true, req: write, wantCode: codes.Unauthenticated},
+		{desc: "invalidToken", anonymousReads: true, auth: []string{"Bearer " + valid + "x"}, req: read, wantCode: codes.Unauthenticated},
+		{desc: "notBearer", anonymousReads: true, auth: []string{"Basic YWxpY2U6"}, req: read, wantCode: codes.Unauthenticated},
+		{desc: "publicMethod", method: "/grpc.health.v1.Health/Check", req: "health"},
+		{desc: "otherServiceAnonymous", anonymousReads: true, method: "/quotapb.Quota/DeleteConfig", req: "quota", wantCode: codes.Unauthenticated},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			a := newTestAuthenticator(t, iss, nil, tc.anonymousReads)
+			ctx := context.Background()
+			if tc.auth != nil {
+				md := metadata.MD{}
+				md.Append(AuthorizationMetadataKey, tc.auth...)
+				ctx = metadata.NewIncomingContext(ctx, md)
+			}
+			method := tc.method
+			if method == "" {
+				method = "/trillian.TrillianLog/Method"
+			}
+			handler := fakeHandler{resp: "ok"}
+			_, err := a.UnaryInterceptor(ctx, tc.req, &grpc.UnaryServerInfo{FullMethod: method}, handler.run)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("UnaryInterceptor() = %v, want code %v", err, tc.wantCode)
+			}
+			if err != nil {
+				if handler.called {
+					t.Error("handler called for rejected request")
+				}
+				return
+			}
+			claims, ok := ClaimsFromContext(handler.ctx)
+			if tc.wantSubject == "" {
+				if ok {
+					t.Errorf("handler context has claims %+v, want none", claims)
+				}
+			} else if !ok || claims.Subject != tc.wantSubject {
+				t.Errorf("handler context claims = %+v, want subject %q", claims, tc.wantSubject)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorStreamInterceptor(t *testing.T) {
+	iss := newFakeIssuer(t)
+	a := newTestAuthenticator(t, iss, nil, true)
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+
+	// Anonymous streams can't send writes.
+	stream := &fakeServerStream{ctx: context.Background(), reqs: []proto.Message{&trillian.QueueLeafRequest{LogId: 1}}}
+	err := a.StreamInterceptor(nil, stream, info, func(srv interface{}, ss grpc.ServerStream) error {
+		return ss.RecvMsg(&trillian.QueueLeafRequest{})
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("anonymous write stream = %v, want Unauthenticated", err)
+	}
+
+	md := metadata.Pairs(AuthorizationMetadataKey, "Bearer "+iss.token(t, "ES256", "ec", iss.claims(time.Now())))
+	stream = &fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), md), reqs: []proto.Message{&trillian.QueueLeafRequest{LogId: 1}}}
+	err = a.StreamInterceptor(nil, stream, info, func(srv interface{}, ss grpc.ServerStream) error {
+		if claims, ok := ClaimsFromContext(ss.Context()); !ok || claims.Subject != "alice" {
+			t.Errorf("stream context claims = %+v, want subject alice", claims)
+		}
+		return ss.RecvMsg(&trillian.QueueLeafRequest{})
+	})
+	if err != nil {
+		t.Errorf("authenticated write stream = %v", err)
+	}
+}
+
+func TestAuthenticatorTenants(t *testing.T) {
+	iss := newFakeIssuer(t)
+	claims := iss.claims(time.Now())
+	claims["tenants"] = []string{"acme", "initech"}
+	token := iss.token(t, "RS256", "rsa", claims)
+	read := &trillian.GetLatestSignedLogRootRequest{LogId: 1}
+	for _, tc := range []struct {
+		desc        string
+		tenantClaim string
+		token       string
+		want        []string
+	}{
+		{desc: "fromClaim", tenantClaim: "tenants", token: token, want: []string{"acme", "initech"}},
+		{desc: "noTenantClaim", token: token},
+		{desc: "missingClaim", tenantClaim: "tenant", token: token},
+		{desc: "anonymous", tenantClaim: "tenants"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			a, err := NewAuthenticator(AuthOptions{
+				Issuers:        []OIDCIssuer{{URL: iss.URL, Audiences: []string{testAudience}}},
+				AnonymousReads: true,
+				TenantClaim:    tc.tenantClaim,
+			})
+			if err != nil {
+				t.Fatalf("NewAuthenticator() = %v", err)
+			}
+			// The tenants sent by the client are never trusted.
+			md := metadata.Pairs(TenantMetadataKey, "spoofed")
+			if tc.token != "" {
+				md.Append(AuthorizationMetadataKey, "Bearer "+tc.token)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			handler := fakeHandler{resp: "ok"}
+			if _, err := a.UnaryInterceptor(ctx, read, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/Method"}, handler.run); err != nil {
+				t.Fatalf("UnaryInterceptor() = %v", err)
+			}
+			if got := chargedTenants(handler.ctx); !cmp.Equal(got, tc.want) {
+				t.Errorf("chargedTenants() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorAdminRoles(t *testing.T) {
+	iss := newFakeIssuer(t)
+	claims := iss.claims(time.Now())
+	claims["roles"] = []string{"viewer", "tree-admin"}
+	admin := iss.token(t, "RS256", "rsa", claims)
+	claims["roles"] = "viewer"
+	viewer := iss.token(t, "RS256", "rsa", claims)
+	for _, tc := range []struct {
+		desc       string
+		adminRoles []string
+		token      string
+		method     string
+		wantCode   codes.Code
+	}{
+		{desc: "admin", adminRoles: []string{"tree-admin"}, token: admin, method: "/trillian.TrillianAdmin/DeleteTree"},
+		{desc: "unqualifiedAdmin", adminRoles: []string{"tree-admin"}, token: admin, method: "/TrillianAdmin/DeleteTree"},
+		{desc: "notAdmin", adminRoles: []string{"tree-admin"}, token: viewer, method: "/trillian.TrillianAdmin/DeleteTree", wantCode: codes.PermissionDenied},
+		{desc: "noAdminRoles", token: admin, method: "/trillian.TrillianAdmin/DeleteTree", wantCode: codes.PermissionDenied},
+		{desc: "anonymousAdminRead", adminRoles: []string{"tree-admin"}, method: "/trillian.TrillianAdmin/ListTrees", wantCode: codes.PermissionDenied},
+		{desc: "logNeedsNoRole", adminRoles: []string{"tree-admin"}, token: viewer, method: "/trillian.TrillianLog/QueueLeaf"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			a, err := NewAuthenticator(AuthOptions{
+				Issuers:        []OIDCIssuer{{URL: iss.URL, Audiences: []string{testAudience}}},
+				AnonymousReads: true,
+				RoleClaim:      "roles",
+				AdminRoles:     tc.adminRoles,
+			})
+			if err != nil {
+				t.Fatalf("NewAuthenticator() = %v", err)
+			}
+			md := metadata.MD{}
+			if tc.token != "" {
+				md.Append(AuthorizationMetadataKey, "Bearer "+tc.token)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			handler := fakeHandler{resp: "ok"}
+			_, err = a.UnaryInterceptor(ctx, &trillian.ListTreesRequest{}, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler.run)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Errorf("UnaryInterceptor() = %v, want code %v", err, tc.wantCode)
+			}
+
+			stream := &fakeServerStream{ctx: ctx}
+			err = a.StreamInterceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: tc.method}, func(interface{}, grpc.ServerStream) error { return nil })
+			if got := status.Code(err); got != tc.wantCode {
+				t.Errorf("StreamInterceptor() = %v, want code %v", err, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestChargedUsersIncludeSubject(t *testing.T) {
+	ctx := NewClaimsContext(context.Background(), &Claims{Subject: "alice"})
+	req := &trillian.QueueLeafRequest{LogId: 1, ChargeTo: &trillian.ChargeTo{User: []string{"bob"}}}
+	info, err := newRPCInfo(ctx, req)
+	if err != nil {
+		t.Fatalf("newRPCInfo() = %v", err)
+	}
+	var users []string
+	for _, s := range info.specs {
+		if s.Group == quota.User {
+			users = append(users, s.User)
+		}
+	}
+	if len(users) != 2 || users[0] != "bob" || users[1] != "alice" {
+		t.Errorf("charged users = %v, want [bob alice]", users)
+	}
+}
@@ -18,13 +18,14 @@ package interceptor
 import (
 	"context"
 	"fmt"
+	"net"
 	"regexp"
 	"sync"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/quota"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/server/errors"
@@ -32,7 +33,10 @@ import (
 	"github.com/google/trillian/trees"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -42,6 +46,23 @@ const (
 	getTreeStage             = "get_tree"
 	getTokensStage           = "get_tokens"
 	traceSpanRoot            = "/trillian/server/int"
+
+	// TenantMetadataKey is the gRPC metadata key that identifies the tenants a request is
+	// charged to. Requests are charged to the quota.Tenant specs of all values of the key
+	// which are valid tenant names, see ValidTenant. When requests are authenticated, the
+	// Authenticator replaces the values sent by clients with the tenants of their token.
+	TenantMetadataKey = "x-trillian-tenant"
+
+	// maxTenantLength is the maximum length of tenant names.
+	maxTenantLength = 64
+
+	// RequestIDMetadataKey is the gRPC metadata key of the ID that correlates the log records
+	// of a request. A valid ID sent by the client is used, otherwise one is generated. The ID
+	// is returned to the client in the response header of the same key.
+	RequestIDMetadataKey = "x-request-id"
+
+	// maxRequestIDLength is the maximum length of the request IDs accepted from clients.
+	maxRequestIDLength = 64
 )
 
 var (
@@ -52,8 +73,12 @@ var (
 
 	requestCounter       monitoring.Counter
 	requestDeniedCounter monitoring.Counter
+	dryRunDeniedCounter  monitoring.Counter
 	contextErrCounter    monitoring.Counter
 	metricsOnce          sync.Once
+	logger               = logging.New("interceptor")
+	validRequestID       = regexp.MustCompile(fmt.Sprintf("^[A-Za-z0-9._:-]{1,%d}$", maxRequestIDLength))
+	validTenant          = regexp.MustCompile(fmt.Sprintf("^[A-Za-z0-9._-]{1,%d}$", maxTenantLength))
 	enabledServices      = map[string]bool{
 		"trillian.TrillianLog":   true,
 		"trillian.TrillianAdmin": true,
@@ -78,26 +103,69 @@ type RequestProcessor interface {
 }
 
 // TrillianInterceptor checks that:
-// * Requests addressing a tree have the correct tree type and tree state;
-// * TODO(codingllama): Requests are properly authenticated / authorized ; and
+// * Requests addressing a tree have the correct tree type and tree state; and
 // * Requests are rate limited appropriately.
+// Authentication is done by an Authenticator, which must run before it.
 type TrillianInterceptor struct {
 	admin storage.AdminStorage
 	qm    quota.Manager
 
-	// quotaDryRun controls whether lack of tokens actually blocks requests (if set to true, no
-	// requests are blocked by lack of tokens).
-	quotaDryRun bool
+	// dryRunGroups holds the quota groups whose lack of tokens doesn't block requests. Tokens of
+	// these groups are acquired separately from those of the other groups, and requests that
+	// would have been denied are only counted.
+	dryRunGroups map[quota.Group]bool
+
+	// byteCosts holds the byte costs of the quota specs charged by size, by group and kind.
+	byteCosts map[groupKind]quota.ByteCost
+}
+
+type groupKind struct {
+	quota.Group
+	quota.Kind
+}
+
+// Option configures a TrillianInterceptor.
+type Option func(*TrillianInterceptor)
+
+// QuotaDryRunGroups makes lack of tokens of the given quota groups not block requests.
+func QuotaDryRunGroups(groups ...quota.Group) Option {
+	return func(i *TrillianInterceptor) {
+		for _, g := range groups {
+			i.dryRunGroups[g] = true
+		}
+	}
+}
+
+// QuotaByteCosts makes the quota specs of the given groups and kinds charged by size. Write
+// requests are charged by the size of their leaves before they're handled. Read requests are
+// charged one token before they're handled, and the tokens for the size of their responses
+// afterwards, which is best effort: responses are never denied, and the tokens aren't charged if
+// the quota doesn't have enough.
+func QuotaByteCosts(costs ...quota.ByteCost) Option {
+	return func(i *TrillianInterceptor) {
+		for _, c := range costs {
+			i.byteCosts[groupKind{c.Group, c.Kind}] = c
+		}
+	}
 }
 
 // New returns a new TrillianInterceptor instance.
-func New(admin storage.AdminStorage, qm quota.Manager, quotaDryRun bool, mf monitoring.MetricFactory) *TrillianInterceptor {
+// If quotaDryRun is true no requests are blocked by lack of tokens.
+func New(admin storage.AdminStorage, qm quota.Manager, quotaDryRun bool, mf monitoring.MetricFactory, opts ...Option) *TrillianInterceptor {
 	metricsOnce.Do(func() { initMetrics(mf) })
-	return &TrillianInterceptor{
-		admin:       admin,
-		qm:          qm,
-		quotaDryRun: quotaDryRun,
+	i := &TrillianInterceptor{
+		admin:        admin,
+		qm:           qm,
+		dryRunGroups: make(map[quota.Group]bool),
+		byteCosts:    make(map[groupKind]quota.ByteCost),
+	}
+	if quotaDryRun {
+		opts = append(opts, QuotaDryRunGroups(quota.Global, quota.Tree, quota.User, quota.Prefix, quota.Tenant))
+	}
+	for _, opt := range opts {
+		opt(i)
 	}
+	return i
 }
 
 func initMetrics(mf monitoring.MetricFactory) {
@@ -113,6 +181,10 @@ func initMetrics(mf monitoring.MetricFactory) {
 		"interceptor_request_denied_count",
 		"Number of requests by denied, labeled according to the reason for denial",
 		"reason", monitoring.TreeIDLabel, "quota_user")
+	dryRunDeniedCounter = mf.NewCounter(
+		"interceptor_request_dry_run_denied_count",
+		"Number of requests that would have been denied due to lack of tokens of quotas in dry run mode",
+		monitoring.TreeIDLabel, "quota_user")
 	contextErrCounter = mf.NewCounter(
 		"interceptor_context_err_counter",
 		"Total number of times request context has been cancelled or deadline exceeded by stage",
@@ -140,6 +212,122 @@ func (i *TrillianInterceptor) UnaryInterceptor(ctx context.Context, req interfac
 	return resp, err
 }
 
+// StreamInterceptor executes the TrillianInterceptor logic for streaming RPCs. Each request
+// received on the stream is processed as a unary request would be. The leaves of the responses
+// to QueueLeaves requests are attributed to the requests they were received in, in order, as the
+// server batches leaves across requests. Other responses sent on the stream are processed as
+// responses to the latest request.
+func (i *TrillianInterceptor) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ps := &processedStream{ServerStream: ss, parent: i, method: info.FullMethod}
+	err := handler(srv, ps)
+	ps.finish(err)
+	return err
+}
+
+// processedStream is a grpc.ServerStream which processes the messages it receives and sends
+// with a RequestProcessor.
+type processedStream struct {
+	grpc.ServerStream
+	parent *TrillianInterceptor
+	method string
+
+	// mu guards rp and queued, as messages may be received and sent concurrently.
+	mu sync.Mutex
+	rp RequestProcessor
+	// queued holds the QueueLeaves requests whose leaves haven't all been acknowledged yet, in
+	// the order they were received.
+	queued []*queuedRequest
+}
+
+// queuedRequest is a QueueLeaves request received on a stream, and the number of its leaves
+// which haven't been acknowledged yet.
+type queuedRequest struct {
+	rp      RequestProcessor
+	pending int
+}
+
+func (s *processedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	rp := s.parent.NewProcessor()
+	if _, err := rp.Before(s.Context(), m, s.method); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req, ok := m.(*trillian.QueueLeavesRequest); ok {
+		if n := len(req.GetLeaves()); n > 0 {
+			s.queued = append(s.queued, &queuedRequest{rp: rp, pending: n})
+		}
+		return nil
+	}
+	s.rp = rp
+	return nil
+}
+
+func (s *processedStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	rp := s.rp
+	s.mu.Unlock()
+	if resp, ok := m.(*trillian.StreamLeavesResponse); ok && rp != nil {
+		// The request itself is charged a single token, as the number of leaves streamed
+		// isn't known. Each response is charged for its leaves before it's sent, as
+		// GetLeavesByRange requests are.
+		if err := rp.(*trillianProcessor).chargeLeaves(s.Context(), len(resp.GetLeaves())); err != nil {
+			return err
+		}
+	}
+
+	err := s.ServerStream.SendMsg(m)
+	if resp, ok := m.(*trillian.QueueLeavesResponse); ok {
+		// The leaves were queued whether or not the response could be sent.
+		s.ackLeaves(resp.GetQueuedLeaves())
+		return err
+	}
+	if rp != nil {
+		rp.After(s.Context(), m, s.method, err)
+	}
+	return err
+}
+
+// ackLeaves processes the responses to the leaves of the queued requests, which are
+// acknowledged in the order they were received.
+func (s *processedStream) ackLeaves(leaves []*trillian.QueuedLogLeaf) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(leaves) > 0 && len(s.queued) > 0 {
+		q := s.queued[0]
+		n := q.pending
+		if n > len(leaves) {
+			n = len(leaves)
+		}
+		q.rp.After(s.Context(), &trillian.QueueLeavesResponse{QueuedLeaves: leaves[:n]}, s.method, nil)
+		leaves = leaves[n:]
+		if q.pending -= n; q.pending == 0 {
+			s.queued = s.queued[1:]
+		}
+	}
+}
+
+// finish processes the leaves of the queued requests which weren't acknowledged when the
+// stream ended, and so weren't queued.
+func (s *processedStream) finish(handlerErr error) {
+	st := status.Convert(handlerErr).Proto()
+	if handlerErr == nil {
+		st = status.New(codes.Aborted, "leaf not queued").Proto()
+	}
+	var leaves []*trillian.QueuedLogLeaf
+	s.mu.Lock()
+	for _, q := range s.queued {
+		for i := 0; i < q.pending; i++ {
+			leaves = append(leaves, &trillian.QueuedLogLeaf{Status: st})
+		}
+	}
+	s.mu.Unlock()
+	s.ackLeaves(leaves)
+}
+
 // NewProcessor returns a RequestProcessor for the TrillianInterceptor logic.
 func (i *TrillianInterceptor) NewProcessor() RequestProcessor {
 	return &trillianProcessor{parent: i}
@@ -148,6 +336,8 @@ func (i *TrillianInterceptor) NewProcessor() RequestProcessor {
 type trillianProcessor struct {
 	parent *TrillianInterceptor
 	info   *rpcInfo
+	// charged holds the tokens acquired in Before.
+	charged []charge
 }
 
 func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method string) (context.Context, error) {
@@ -159,17 +349,15 @@ func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method
 	// Don't want the Before to contain the action, so don't overwrite the ctx.
 	innerCtx, spanEnd := spanFor(ctx, "Before")
 	defer spanEnd()
-	info, err := newRPCInfo(req)
+	info, err := newRPCInfo(ctx, req)
 	if err != nil {
-		glog.Warningf("Failed to read tree info: %v", err)
+		logger.Warn(ctx, "failed to read tree info", "method", method, "err", err)
 		incRequestDeniedCounter(badInfoReason, 0, "")
 		return ctx, err
 	}
 	tp.info = info
 	requestCounter.Inc(fmt.Sprint(info.treeID))
 
-	// TODO(codingllama): Add auth interception
-
 	if info.getTree {
 		tree, err := trees.GetTree(
 			innerCtx, tp.parent.admin, info.treeID, trees.NewGetOpts(trees.Admin, info.treeTypes...))
@@ -185,16 +373,16 @@ func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method
 	}
 
 	if info.tokens > 0 && len(info.specs) > 0 {
-		err := tp.parent.qm.GetTokens(innerCtx, info.tokens, info.specs)
-		if err != nil {
-			if !tp.parent.quotaDryRun {
-				incRequestDeniedCounter(insufficientTokensReason, info.treeID, info.quotaUsers)
-				return ctx, status.Errorf(codes.ResourceExhausted, "quota exhausted: %v", err)
-			}
-			glog.Warningf("(quotaDryRun) Request %+v not denied due to dry run mode: %v", req, err)
+		enforced, dryRun := tp.parent.splitSpecs(info.specs)
+		if err := tp.getTokens(innerCtx, tp.parent.charges(info, enforced)); err != nil {
+			incRequestDeniedCounter(insufficientTokensReason, info.treeID, info.quotaUsers)
+			return ctx, status.Errorf(codes.ResourceExhausted, "quota exhausted: %v", err)
 		}
-		quota.Metrics.IncAcquired(info.tokens, info.specs, err == nil)
-		if err = innerCtx.Err(); err != nil {
+		if err := tp.getTokens(innerCtx, tp.parent.charges(info, dryRun)); err != nil {
+			dryRunDeniedCounter.Inc(fmt.Sprint(info.treeID), info.quotaUsers)
+			logger.Warn(ctx, "request not denied due to quota dry run mode", "method", method, "tree_id", info.treeID, "tokens", info.tokens, "err", err)
+		}
+		if err := innerCtx.Err(); err != nil {
 			contextErrCounter.Inc(getTokensStage)
 			return ctx, err
 		}
@@ -203,6 +391,102 @@ func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method
 	return ctx, nil
 }
 
+// charge is a number of tokens acquired from specs.
+type charge struct {
+	tokens int
+	specs  []quota.Spec
+}
+
+// splitSpecs splits specs into those enforced and those in dry run mode, keeping their order.
+func (i *TrillianInterceptor) splitSpecs(specs []quota.Spec) (enforced, dryRun []quota.Spec) {
+	for _, s := range specs {
+		if i.dryRunGroups[s.Group] {
+			dryRun = append(dryRun, s)
+		} else {
+			enforced = append(enforced, s)
+		}
+	}
+	return enforced, dryRun
+}
+
+// charges groups specs by the number of tokens charged for the request, which differs for specs
+// charged by size.
+func (i *TrillianInterceptor) charges(info *rpcInfo, specs []quota.Spec) []charge {
+	var cs []charge
+	for _, s := range specs {
+		tokens := info.tokens
+		if c, ok := i.byteCosts[groupKind{s.Group, s.Kind}]; ok {
+			// Responses aren't known yet, so reads are charged by size in After.
+			tokens = c.Tokens(info.bytes)
+		}
+		found := false
+		for j := range cs {
+			if cs[j].tokens == tokens {
+				cs[j].specs = append(cs[j].specs, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			cs = append(cs, charge{tokens: tokens, specs: []quota.Spec{s}})
+		}
+	}
+	return cs
+}
+
+// getTokens acquires the tokens of charges, and records them for After. If tokens can't be
+// acquired for a charge, the tokens already acquired for the others are returned.
+func (tp *trillianProcessor) getTokens(ctx context.Context, charges []charge) error {
+	for j, c := range charges {
+		err := tp.parent.qm.GetTokens(ctx, c.tokens, c.specs)
+		quota.Metrics.IncAcquired(c.tokens, c.specs, err == nil)
+		if err != nil {
+			for _, acquired := range charges[:j] {
+				if err := tp.parent.qm.PutTokens(ctx, acquired.tokens, acquired.specs); err != nil {
+					logger.Warn(ctx, "failed to return tokens", "tokens", acquired.tokens, "err", err)
+				}
+				quota.Metrics.IncReturned(acquired.tokens, acquired.specs, err == nil)
+			}
+			return err
+		}
+	}
+	tp.charged = append(tp.charged, charges...)
+	return nil
+}
+
+// chargeLeaves acquires a token per leaf sent on a stream from the quota specs of the request
+// which aren't charged by size. Those are charged for the size of the response in After. An
+// error is returned if the enforced specs don't have enough tokens.
+func (tp *trillianProcessor) chargeLeaves(ctx context.Context, leaves int) error {
+	if tp.info == nil || leaves == 0 || len(tp.info.specs) == 0 {
+		return nil
+	}
+	var specs []quota.Spec
+	for _, s := range tp.info.specs {
+		if _, ok := tp.parent.byteCosts[groupKind{s.Group, s.Kind}]; !ok {
+			specs = append(specs, s)
+		}
+	}
+	enforced, dryRun := tp.parent.splitSpecs(specs)
+	if len(enforced) > 0 {
+		err := tp.parent.qm.GetTokens(ctx, leaves, enforced)
+		quota.Metrics.IncAcquired(leaves, enforced, err == nil)
+		if err != nil {
+			incRequestDeniedCounter(insufficientTokensReason, tp.info.treeID, tp.info.quotaUsers)
+			return status.Errorf(codes.ResourceExhausted, "quota exhausted: %v", err)
+		}
+	}
+	if len(dryRun) > 0 {
+		err := tp.parent.qm.GetTokens(ctx, leaves, dryRun)
+		quota.Metrics.IncAcquired(leaves, dryRun, err == nil)
+		if err != nil {
+			dryRunDeniedCounter.Inc(fmt.Sprint(tp.info.treeID), tp.info.quotaUsers)
+			logger.Warn(ctx, "request not denied due to quota dry run mode", "tree_id", tp.info.treeID, "tokens", leaves, "err", err)
+		}
+	}
+	return nil
+}
+
 func (tp *trillianProcessor) After(ctx context.Context, resp interface{}, method string, handlerErr error) {
 	if !enabledServices[serviceName(method)] {
 		return
@@ -211,7 +495,7 @@ func (tp *trillianProcessor) After(ctx context.Context, resp interface{}, method
 	defer spanEnd()
 	switch {
 	case tp.info == nil:
-		glog.Warningf("After called with nil rpcInfo, resp = [%+v], handlerErr = [%v]", resp, handlerErr)
+		logger.Warn(ctx, "After called with nil rpcInfo", "method", method, "handler_err", handlerErr)
 		return
 	case tp.info.tokens == 0:
 		// After() currently only does quota processing
@@ -225,39 +509,66 @@ func (tp *trillianProcessor) After(ctx context.Context, resp interface{}, method
 	// * Requests that filter out duplicates (e.g., QueueLeaf, for the same reason as above:
 	//   duplicates aren't queued for sequencing)
 	// These are only applied for Refundable specs.
-	refunds := make([]quota.Spec, 0)
-	for _, s := range tp.info.specs {
-		if s.Refundable {
-			refunds = append(refunds, s)
-		}
-	}
-	if len(refunds) == 0 {
-		return
-	}
-
-	tokens := 0
+	leaves := 0
 	if handlerErr != nil {
 		// Return the tokens spent by invalid requests
-		tokens = tp.info.tokens
+		leaves = tp.info.tokens
 	} else {
 		switch resp := resp.(type) {
 		case *trillian.QueueLeafResponse:
 			if !isLeafOK(resp.GetQueuedLeaf()) {
-				tokens = 1
+				leaves = 1
 			}
 		case *trillian.AddSequencedLeavesResponse:
 			for _, leaf := range resp.GetResults() {
 				if !isLeafOK(leaf) {
-					tokens++
+					leaves++
+				}
+			}
+		case *trillian.QueueLeavesResponse:
+			for _, leaf := range resp.GetQueuedLeaves() {
+				if !isLeafOK(leaf) {
+					leaves++
 				}
 			}
 		}
 	}
-	if tokens > 0 {
+	var refunds []charge
+	for _, c := range tp.charged {
+		var specs []quota.Spec
+		for _, s := range c.specs {
+			if s.Refundable {
+				specs = append(specs, s)
+			}
+		}
+		// Specs charged by size are refunded in proportion to the leaves refunded.
+		if tokens := c.tokens * leaves / tp.info.tokens; tokens > 0 && len(specs) > 0 {
+			refunds = append(refunds, charge{tokens: tokens, specs: specs})
+		}
+	}
+
+	// Charge the specs of read requests charged by size for the size of the response. They were
+	// charged one token in Before.
+	var extra []charge
+	if m, ok := resp.(proto.Message); ok && handlerErr == nil && tp.info.readonly && len(tp.parent.byteCosts) > 0 {
+		size := proto.Size(m)
+		for _, c := range tp.charged {
+			for _, s := range c.specs {
+				if bc, ok := tp.parent.byteCosts[groupKind{s.Group, s.Kind}]; ok {
+					if tokens := bc.Tokens(size) - 1; tokens > 0 {
+						extra = append(extra, charge{tokens: tokens, specs: []quota.Spec{s}})
+					}
+				}
+			}
+		}
+	}
+
+	if len(refunds) > 0 || len(extra) > 0 {
 		// Run PutTokens in a separate goroutine and with a separate context.
 		// It shouldn't block RPC completion, nor should it share the RPC's context deadline.
 		go func() {
-			ctx, spanEnd := spanFor(context.Background(), "After.PutTokens")
+			// Keep the request ID, so that failures can be correlated with the request.
+			ctx, spanEnd := spanFor(logging.WithRequestID(context.Background(), logging.RequestID(ctx)), "After.PutTokens")
 			defer spanEnd()
 			ctx, cancel := context.WithTimeout(ctx, PutTokensTimeout)
 			defer cancel()
@@ -266,11 +577,20 @@ func (tp *trillianProcessor) After(ctx context.Context, resp interface{}, method
 			// this case, we may want to keep tabs on how many tokens we failed to replenish and bundle
 			// them up in the next PutTokens call (possibly as a QuotaManager decorator, or internally
 			// in its impl).
-			err := tp.parent.qm.PutTokens(ctx, tokens, refunds)
-			if err != nil {
-				glog.Warningf("Failed to replenish %v tokens: %v", tokens, err)
+			for _, c := range refunds {
+				err := tp.parent.qm.PutTokens(ctx, c.tokens, c.specs)
+				if err != nil {
+					logger.Warn(ctx, "failed to replenish tokens", "tokens", c.tokens, "err", err)
+				}
+				quota.Metrics.IncReturned(c.tokens, c.specs, err == nil)
+			}
+			for _, c := range extra {
+				err := tp.parent.qm.GetTokens(ctx, c.tokens, c.specs)
+				if err != nil {
+					logger.Warn(ctx, "failed to charge tokens for response size", "tokens", c.tokens, "spec", c.specs[0], "err", err)
+				}
+				quota.Metrics.IncAcquired(c.tokens, c.specs, err == nil)
 			}
-			quota.Metrics.IncReturned(tokens, refunds, err == nil)
 		}()
 	}
 }
@@ -308,6 +628,8 @@ type rpcInfo struct {
 
 	specs  []quota.Spec
 	tokens int
+	// bytes is the size of the leaves written by the request, for specs charged by size.
+	bytes int
 	// Single string describing all of the users against which quota is requested.
 	quotaUsers string
 }
@@ -318,18 +640,60 @@ type chargable interface {
 	GetChargeTo() *trillian.ChargeTo
 }
 
-// chargedUsers returns user identifiers for any chargable user quotas.
-func chargedUsers(req interface{}) []string {
-	c, ok := req.(chargable)
+// chargedUsers returns user identifiers for any chargable user quotas: the users the request is
+// charged to, and the subject of the token which authenticated it, if any.
+func chargedUsers(ctx context.Context, req interface{}) []string {
+	var users []string
+	if c, ok := req.(chargable); ok && c.GetChargeTo() != nil {
+		users = append(users, c.GetChargeTo().User...)
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		users = append(users, claims.Subject)
+	}
+	return users
+}
+
+// chargedTenants returns the valid tenants named by the TenantMetadataKey of the incoming
+// metadata.
+func chargedTenants(ctx context.Context) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil
 	}
-	chargeTo := c.GetChargeTo()
-	if chargeTo == nil {
-		return nil
+	var tenants []string
+	for _, tenant := range md.Get(TenantMetadataKey) {
+		if ValidTenant(tenant) {
+			tenants = append(tenants, tenant)
+		}
 	}
+	return tenants
+}
 
-	return chargeTo.User
+// ValidTenant returns whether tenant is a valid tenant name: between 1 and 64 ASCII letters,
+// digits, dots, dashes and underscores. Other characters, notably slashes, would corrupt the
+// names of the tenant's quotas.
+func ValidTenant(tenant string) bool {
+	return validTenant.MatchString(tenant)
+}
+
+// chargedPrefix returns the host prefix of the peer address, or "" if the peer doesn't have an
+// IP address (e.g., requests over Unix domain sockets).
+func chargedPrefix(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	var ip net.IP
+	switch addr := p.Addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	}
+	if ip == nil {
+		return ""
+	}
+	return quota.HostPrefix(ip)
 }
 
 func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
@@ -349,6 +713,7 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		*quotapb.CreateConfigRequest,
 		*quotapb.DeleteConfigRequest,
 		*quotapb.GetConfigRequest,
+		*quotapb.GetUsageRequest,
 		*quotapb.ListConfigsRequest,
 		*quotapb.UpdateConfigRequest:
 		info.getTree = false
@@ -364,7 +729,9 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		info.getTree = false // Zero to many trees
 
 	// Admin / readonly
-	case *trillian.GetTreeRequest:
+	case *trillian.GetTreeRequest,
+		*trillian.GetTreeUsageRequest,
+		*trillian.ScrubTreeRequest:
 		info.getTree = false // Read done within RPC handler
 
 	// Admin / readwrite
@@ -375,11 +742,14 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		info.readonly = false
 
 	// (Log + Pre-ordered Log) / readonly
-	case *trillian.GetConsistencyProofRequest,
+	case *trillian.GetCompactRangeRequest,
+		*trillian.GetConsistencyProofRequest,
 		*trillian.GetEntryAndProofRequest,
 		*trillian.GetInclusionProofByHashRequest,
 		*trillian.GetInclusionProofRequest,
-		*trillian.GetLatestSignedLogRootRequest:
+		*trillian.GetLatestSignedLogRootRequest,
+		*trillian.GetSignedLogRootAtTimeRequest,
+		*trillian.StreamLeavesRequest:
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
 		info.tokens = 1
 	case *trillian.GetLeavesByRangeRequest:
@@ -388,17 +758,42 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		if c := req.GetCount(); c > 1 {
 			info.tokens = int(c)
 		}
+	case *trillian.GetLeavesByHashRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+		info.tokens = 1
+		if c := len(req.GetLeafHash()); c > 1 {
+			info.tokens = c
+		}
+	case *trillian.GetLeavesByIndexListRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+		info.tokens = 1
+		if c := len(req.GetLeafIndex()); c > 1 {
+			info.tokens = c
+		}
+	// Pre-ordered Log / readonly
+	case *trillian.GetImportProgressRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_PREORDERED_LOG}
+		info.tokens = 1
+
 	// Log / readwrite
 	case *trillian.QueueLeafRequest:
 		info.readonly = false
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG}
 		info.tokens = 1
+		info.bytes = leafBytes(req.GetLeaf())
+
+	case *trillian.QueueLeavesRequest:
+		info.readonly = false
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG}
+		info.tokens = len(req.GetLeaves())
+		info.bytes = leafBytes(req.GetLeaves()...)
 
 	// Pre-ordered Log / readwrite
 	case *trillian.AddSequencedLeavesRequest:
 		info.readonly = false
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_PREORDERED_LOG}
 		info.tokens = len(req.GetLeaves())
+		info.bytes = leafBytes(req.GetLeaves()...)
 
 	// (Log + Pre-ordered Log) / readwrite
 	case *trillian.InitLogRequest:
@@ -413,7 +808,16 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 	return info, nil
 }
 
-func newRPCInfo(req interface{}) (*rpcInfo, error) {
+// leafBytes returns the size of the values and extra data of leaves.
+func leafBytes(leaves ...*trillian.LogLeaf) int {
+	size := 0
+	for _, leaf := range leaves {
+		size += len(leaf.GetLeafValue()) + len(leaf.GetExtraData())
+	}
+	return size
+}
+
+func newRPCInfo(ctx context.Context, req interface{}) (*rpcInfo, error) {
 	info, err := newRPCInfoForRequest(req)
 	if err != nil {
 		return nil, err
@@ -438,13 +842,19 @@ func newRPCInfo(req interface{}) (*rpcInfo, error) {
 			kind = quota.Read
 		}
 
-		for _, user := range chargedUsers(req) {
+		for _, user := range chargedUsers(ctx, req) {
 			info.specs = append(info.specs, quota.Spec{Group: quota.User, Kind: kind, User: user})
 			if len(info.quotaUsers) > 0 {
 				info.quotaUsers += "+"
 			}
 			info.quotaUsers += user
 		}
+		for _, tenant := range chargedTenants(ctx) {
+			info.specs = append(info.specs, quota.Spec{Group: quota.Tenant, Kind: kind, Tenant: tenant})
+		}
+		if prefix := chargedPrefix(ctx); prefix != "" {
+			info.specs = append(info.specs, quota.Spec{Group: quota.Prefix, Kind: kind, Prefix: prefix})
+		}
 		info.specs = append(info.specs, []quota.Spec{
 			{Group: quota.Tree, Kind: kind, TreeID: info.treeID},
 			{Group: quota.Global, Kind: kind, Refundable: true}, // Only Global tokens are refunded.
@@ -467,11 +877,129 @@ type treeRequest interface {
 }
 
 // ErrorWrapper is a grpc.UnaryServerInterceptor that wraps the errors emitted by the underlying handler.
+// Errors name the request ID of the context, and return it in an errdetails.RequestInfo detail.
 func ErrorWrapper(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	ctx, spanEnd := spanFor(ctx, "ErrorWrapper")
 	defer spanEnd()
 	rsp, err := handler(ctx, req)
-	return rsp, errors.WrapError(err)
+	return rsp, logging.WithRequestInfo(ctx, errors.WrapError(err))
+}
+
+// StreamErrorWrapper is a grpc.StreamServerInterceptor that wraps the errors emitted by the
+// underlying handler, like ErrorWrapper.
+func StreamErrorWrapper(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return logging.WithRequestInfo(ss.Context(), errors.WrapError(handler(srv, ss)))
+}
+
+// Deadline returns a grpc.UnaryServerInterceptor that sets a deadline of defaultTimeout, or
+// maxTimeout if defaultTimeout is zero, on requests that arrive without one, and rejects
+// requests whose deadline is more than maxTimeout away. This prevents a single client from
+// holding server resources, e.g. storage transactions, for too long. Zero values disable the
+// corresponding limit. Streaming RPCs aren't limited, as they don't hold a transaction for
+// their whole lifetime.
+func Deadline(defaultTimeout, maxTimeout time.Duration) grpc.UnaryServerInterceptor {
+	if defaultTimeout == 0 {
+		defaultTimeout = maxTimeout
+	}
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		deadline, ok := ctx.Deadline()
+		switch {
+		case !ok && defaultTimeout > 0:
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+			defer cancel()
+		case ok && maxTimeout > 0:
+			if timeout := time.Until(deadline); timeout > maxTimeout {
+				return nil, status.Errorf(codes.InvalidArgument, "deadline of %v exceeds the maximum of %v", timeout.Round(time.Second), maxTimeout)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ReadOnly is a grpc.UnaryServerInterceptor that rejects the requests of the Trillian services
+// which write to storage with PermissionDenied, for servers which are only allowed to read from
+// it, e.g. from a replica.
+func ReadOnly(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkReadOnly(req, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamReadOnly is the grpc.StreamServerInterceptor equivalent of ReadOnly, which checks each
+// request received on the stream.
+func StreamReadOnly(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &readOnlyStream{ServerStream: ss, method: info.FullMethod})
+}
+
+// readOnlyStream is a grpc.ServerStream which rejects the write requests it receives.
+type readOnlyStream struct {
+	grpc.ServerStream
+	method string
+}
+
+func (s *readOnlyStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkReadOnly(m, s.method)
+}
+
+// checkReadOnly returns an error if req is a request of a Trillian service which writes to
+// storage.
+func checkReadOnly(req interface{}, method string) error {
+	if !enabledServices[serviceName(method)] {
+		return nil
+	}
+	info, err := newRPCInfoForRequest(req)
+	if err != nil {
+		return err
+	}
+	if !info.readonly {
+		return status.Errorf(codes.PermissionDenied, "%s not allowed: the server is read-only", method)
+	}
+	return nil
+}
+
+// RequestID is a grpc.UnaryServerInterceptor that attaches a request ID to the context of the
+// underlying handler, so that it's added to the records logged by the monitoring/logging
+// package while handling the request.
+func RequestID(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+// StreamRequestID is the grpc.StreamServerInterceptor equivalent of RequestID.
+func StreamRequestID(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+// withRequestID returns a copy of ctx carrying the request ID sent by the client, if valid, or
+// a new one, and sets it as a response header.
+func withRequestID(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDMetadataKey); len(ids) > 0 && validRequestID.MatchString(ids[0]) {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id)); err != nil {
+		logger.Debug(ctx, "failed to set request ID header", "err", err)
+	}
+	return logging.WithRequestID(ctx, id)
+}
+
+// contextStream is a grpc.ServerStream with a replaced context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
 }
 
 func spanFor(ctx context.Context, name string) (context.Context, func()) {
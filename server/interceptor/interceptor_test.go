@@ -16,14 +16,19 @@ package interceptor
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/quota"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/storage"
@@ -31,6 +36,8 @@ import (
 	"github.com/google/trillian/trees"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -192,8 +199,14 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 	charge1 := "alpaca"
 	charge2 := "cama"
 	charges := &trillian.ChargeTo{User: []string{charge1, charge2}}
+	peerCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1234},
+	})
+	tenantCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		TenantMetadataKey, "acme", TenantMetadataKey, "initech"))
 	tests := []struct {
 		desc         string
+		ctx          context.Context
 		dryRun       bool
 		method       string
 		req          interface{}
@@ -276,6 +289,33 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 			},
 			wantTokens: 1,
 		},
+		{
+			desc:   "logWrite with peer address",
+			ctx:    peerCtx,
+			method: "/trillian.TrillianLog/QueueLeaf",
+			req:    &trillian.QueueLeafRequest{LogId: logTree.TreeId},
+			specs: []quota.Spec{
+				{Group: quota.Prefix, Kind: quota.Write, Prefix: "10.1.2.3/32"},
+				{Group: quota.Tree, Kind: quota.Write, TreeID: logTree.TreeId},
+				{Group: quota.Global, Kind: quota.Write, Refundable: true},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "logRead with tenants and charges",
+			ctx:    tenantCtx,
+			method: "/trillian.TrillianLog/GetLatestSignedLogRoot",
+			req:    &trillian.GetLatestSignedLogRootRequest{LogId: logTree.TreeId, ChargeTo: charges},
+			specs: []quota.Spec{
+				{Group: quota.User, Kind: quota.Read, User: charge1},
+				{Group: quota.User, Kind: quota.Read, User: charge2},
+				{Group: quota.Tenant, Kind: quota.Read, Tenant: "acme"},
+				{Group: quota.Tenant, Kind: quota.Read, Tenant: "initech"},
+				{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read, Refundable: true},
+			},
+			wantTokens: 1,
+		},
 		{
 			desc:   "batchSequencedLogLeavesRequest",
 			method: "/trillian.TrillianLog/AddSequencedLeaves",
@@ -331,7 +371,6 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 		},
 	}
 
-	ctx := context.Background()
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
@@ -349,6 +388,11 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 				qm.EXPECT().GetTokens(gomock.Any(), test.wantTokens, test.specs).Return(test.getTokensErr)
 			}
 
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
 			handler := &fakeHandler{resp: "ok"}
 			intercept := New(admin, qm, test.dryRun, nil /* mf */)
 
@@ -364,6 +408,177 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 	}
 }
 
+func TestTrillianInterceptor_QuotaDryRunGroups(t *testing.T) {
+	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 10
+	req := &trillian.QueueLeafRequest{LogId: logTree.TreeId, ChargeTo: &trillian.ChargeTo{User: []string{"alpaca"}}}
+	userSpecs := []quota.Spec{{Group: quota.User, Kind: quota.Write, User: "alpaca"}}
+	treeSpecs := []quota.Spec{
+		{Group: quota.Tree, Kind: quota.Write, TreeID: logTree.TreeId},
+		{Group: quota.Global, Kind: quota.Write, Refundable: true},
+	}
+
+	tests := []struct {
+		desc                  string
+		treeErr, userErr      error
+		wantCode              codes.Code
+		wantUserGetTokens     bool
+		wantDryRunDeniedDelta float64
+	}{
+		{
+			desc:              "ok",
+			wantUserGetTokens: true,
+		},
+		{
+			desc:                  "dryRunGroupExhausted",
+			userErr:               errors.New("not enough tokens"),
+			wantUserGetTokens:     true,
+			wantDryRunDeniedDelta: 1,
+		},
+		{
+			desc:     "enforcedGroupExhausted",
+			treeErr:  errors.New("not enough tokens"),
+			wantCode: codes.ResourceExhausted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			admin := storage.NewMockAdminStorage(ctrl)
+			adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+			adminTX.EXPECT().GetTree(gomock.Any(), logTree.TreeId).AnyTimes().Return(logTree, nil)
+			adminTX.EXPECT().Close().AnyTimes().Return(nil)
+			adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+			qm := quota.NewMockManager(ctrl)
+			qm.EXPECT().GetTokens(gomock.Any(), 1, treeSpecs).Return(test.treeErr)
+			if test.wantUserGetTokens {
+				qm.EXPECT().GetTokens(gomock.Any(), 1, userSpecs).Return(test.userErr)
+			}
+
+			intercept := New(admin, qm, false /* quotaDryRun */, nil /* mf */, QuotaDryRunGroups(quota.User))
+			before := dryRunDeniedCounter.Value("10", "alpaca")
+			handler := &fakeHandler{resp: &trillian.QueueLeafResponse{}}
+			_, err := intercept.UnaryInterceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaf"}, handler.run)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("UnaryInterceptor() returned err = %v, wantCode = %v", err, test.wantCode)
+			}
+			if got := dryRunDeniedCounter.Value("10", "alpaca") - before; got != test.wantDryRunDeniedDelta {
+				t.Errorf("dry run denied count delta = %v, want %v", got, test.wantDryRunDeniedDelta)
+			}
+		})
+	}
+}
+
+func TestTrillianInterceptor_QuotaByteCosts(t *testing.T) {
+	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 10
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "acme"))
+	costs := []quota.ByteCost{
+		{Group: quota.Tenant, Kind: quota.Write, BytesPerToken: 100},
+		{Group: quota.Global, Kind: quota.Write, BytesPerToken: 100},
+		{Group: quota.Tenant, Kind: quota.Read, BytesPerToken: 10},
+	}
+	readResp := &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: make([]byte, 100)}}
+	readTokens := (proto.Size(readResp) + 9) / 10
+
+	tests := []struct {
+		desc   string
+		method string
+		req    interface{}
+		resp   interface{}
+		// getTokens holds the expected GetTokens calls, by number of tokens.
+		getTokens map[int][]quota.Spec
+		// afterTokens holds the expected GetTokens call in After, if any.
+		afterTokens int
+		afterSpecs  []quota.Spec
+		// putTokens holds the expected PutTokens call in After, if any.
+		putTokens int
+		putSpecs  []quota.Spec
+	}{
+		{
+			desc:   "write",
+			method: "/trillian.TrillianLog/QueueLeaves",
+			req: &trillian.QueueLeavesRequest{LogId: logTree.TreeId, Leaves: []*trillian.LogLeaf{
+				{LeafValue: make([]byte, 100), ExtraData: make([]byte, 50)},
+				{LeafValue: make([]byte, 150)},
+			}},
+			resp: &trillian.QueueLeavesResponse{QueuedLeaves: []*trillian.QueuedLogLeaf{
+				{},
+				{Status: status.New(codes.AlreadyExists, "duplicate leaf").Proto()},
+			}},
+			getTokens: map[int][]quota.Spec{
+				3: {
+					{Group: quota.Tenant, Kind: quota.Write, Tenant: "acme"},
+					{Group: quota.Global, Kind: quota.Write, Refundable: true},
+				},
+				2: {{Group: quota.Tree, Kind: quota.Write, TreeID: logTree.TreeId}},
+			},
+			// Half of the leaves are duplicates, so half of the global tokens are refunded.
+			putTokens: 1,
+			putSpecs:  []quota.Spec{{Group: quota.Global, Kind: quota.Write, Refundable: true}},
+		},
+		{
+			desc:   "read",
+			method: "/trillian.TrillianLog/GetLatestSignedLogRoot",
+			req:    &trillian.GetLatestSignedLogRootRequest{LogId: logTree.TreeId},
+			resp:   readResp,
+			getTokens: map[int][]quota.Spec{
+				1: {
+					{Group: quota.Tenant, Kind: quota.Read, Tenant: "acme"},
+					{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+					{Group: quota.Global, Kind: quota.Read, Refundable: true},
+				},
+			},
+			afterTokens: readTokens - 1,
+			afterSpecs:  []quota.Spec{{Group: quota.Tenant, Kind: quota.Read, Tenant: "acme"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			admin := storage.NewMockAdminStorage(ctrl)
+			adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+			adminTX.EXPECT().GetTree(gomock.Any(), logTree.TreeId).AnyTimes().Return(logTree, nil)
+			adminTX.EXPECT().Close().AnyTimes().Return(nil)
+			adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+			afterCh := make(chan bool, 1)
+			qm := quota.NewMockManager(ctrl)
+			for tokens, specs := range test.getTokens {
+				qm.EXPECT().GetTokens(gomock.Any(), tokens, specs).Return(nil)
+			}
+			if test.afterTokens > 0 {
+				qm.EXPECT().GetTokens(gomock.Any(), test.afterTokens, test.afterSpecs).Do(func(context.Context, int, []quota.Spec) {
+					afterCh <- true
+				}).Return(nil)
+			}
+			if test.putTokens > 0 {
+				qm.EXPECT().PutTokens(gomock.Any(), test.putTokens, test.putSpecs).Do(func(context.Context, int, []quota.Spec) {
+					afterCh <- true
+				}).Return(nil)
+			}
+
+			handler := &fakeHandler{resp: test.resp}
+			intercept := New(admin, qm, false /* quotaDryRun */, nil /* mf */, QuotaByteCosts(costs...))
+			if _, err := intercept.UnaryInterceptor(ctx, test.req, &grpc.UnaryServerInfo{FullMethod: test.method}, handler.run); err != nil {
+				t.Fatalf("UnaryInterceptor() returned err = %v", err)
+			}
+
+			// Tokens are returned or charged in a separate goroutine. Give it some time to complete.
+			select {
+			case <-afterCh:
+			case <-time.After(1 * time.Second):
+				// No need to error here, gomock will fail if the call is missing.
+			}
+		})
+	}
+}
+
 func TestTrillianInterceptor_QuotaInterception_ReturnsTokens(t *testing.T) {
 	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
 	logTree.TreeId = 10
@@ -482,6 +697,204 @@ func TestTrillianInterceptor_QuotaInterception_ReturnsTokens(t *testing.T) {
 	}
 }
 
+func TestTrillianInterceptor_StreamInterceptor(t *testing.T) {
+	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 10
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	admin := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(gomock.Any(), logTree.TreeId).AnyTimes().Return(logTree, nil)
+	adminTX.EXPECT().Close().AnyTimes().Return(nil)
+	adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+	specs := []quota.Spec{
+		{Group: quota.Tree, Kind: quota.Write, TreeID: logTree.TreeId},
+		{Group: quota.Global, Kind: quota.Write, Refundable: true},
+	}
+	putTokensCh := make(chan bool, 1)
+	qm := quota.NewMockManager(ctrl)
+	qm.EXPECT().GetTokens(gomock.Any(), 3, specs).Return(nil)
+	qm.EXPECT().PutTokens(gomock.Any(), 1, specs[1:]).Do(func(context.Context, int, []quota.Spec) {
+		putTokensCh <- true
+	}).Return(nil)
+
+	ss := &fakeServerStream{
+		ctx: context.Background(),
+		reqs: []proto.Message{&trillian.QueueLeavesRequest{
+			LogId:  logTree.TreeId,
+			Leaves: []*trillian.LogLeaf{{}, {}, {}},
+		}},
+	}
+	resp := &trillian.QueueLeavesResponse{QueuedLeaves: []*trillian.QueuedLogLeaf{
+		{},
+		{Status: status.New(codes.AlreadyExists, "duplicate leaf").Proto()},
+		{Status: status.New(codes.OK, "OK").Proto()},
+	}}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		var req trillian.QueueLeavesRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return stream.SendMsg(resp)
+	}
+
+	intercept := New(admin, qm, false /* quotaDryRun */, nil /* mf */)
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves", IsClientStream: true, IsServerStream: true}
+	if err := intercept.StreamInterceptor(nil, ss, info, handler); err != nil {
+		t.Fatalf("StreamInterceptor() returned err = %v", err)
+	}
+	if len(ss.sent) != 1 {
+		t.Errorf("StreamInterceptor() sent %d messages, want 1", len(ss.sent))
+	}
+
+	// PutTokens may be delegated to a separate goroutine. Give it some time to complete.
+	select {
+	case <-putTokensCh:
+	case <-time.After(1 * time.Second):
+	}
+}
+
+func TestTrillianInterceptor_StreamInterceptorBatchedLeaves(t *testing.T) {
+	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 10
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	admin := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(gomock.Any(), logTree.TreeId).AnyTimes().Return(logTree, nil)
+	adminTX.EXPECT().Close().AnyTimes().Return(nil)
+	adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+	specs := []quota.Spec{
+		{Group: quota.Tree, Kind: quota.Write, TreeID: logTree.TreeId},
+		{Group: quota.Global, Kind: quota.Write, Refundable: true},
+	}
+	// Each request is charged for its 2 leaves. The duplicate leaf of each request, and the
+	// leaf of the second request which is never acknowledged, are refunded one by one.
+	putTokensCh := make(chan bool, 3)
+	qm := quota.NewMockManager(ctrl)
+	qm.EXPECT().GetTokens(gomock.Any(), 2, specs).Times(2).Return(nil)
+	qm.EXPECT().PutTokens(gomock.Any(), 1, specs[1:]).Times(3).Do(func(context.Context, int, []quota.Spec) {
+		putTokensCh <- true
+	}).Return(nil)
+
+	leaves := []*trillian.LogLeaf{{}, {}}
+	ss := &fakeServerStream{
+		ctx: context.Background(),
+		reqs: []proto.Message{
+			&trillian.QueueLeavesRequest{LogId: logTree.TreeId, Leaves: leaves},
+			&trillian.QueueLeavesRequest{LogId: logTree.TreeId, Leaves: leaves},
+		},
+	}
+	// The server batches the leaves of both requests, and fails before the last one is queued.
+	duplicate := status.New(codes.AlreadyExists, "duplicate leaf").Proto()
+	resp := &trillian.QueueLeavesResponse{QueuedLeaves: []*trillian.QueuedLogLeaf{
+		{},
+		{Status: duplicate},
+		{Status: duplicate},
+	}}
+	handlerErr := status.Error(codes.Unavailable, "storage unavailable")
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		for i := 0; i < 2; i++ {
+			var req trillian.QueueLeavesRequest
+			if err := stream.RecvMsg(&req); err != nil {
+				return err
+			}
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+		return handlerErr
+	}
+
+	intercept := New(admin, qm, false /* quotaDryRun */, nil /* mf */)
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves", IsClientStream: true, IsServerStream: true}
+	if err := intercept.StreamInterceptor(nil, ss, info, handler); err != handlerErr {
+		t.Fatalf("StreamInterceptor() returned err = %v, want %v", err, handlerErr)
+	}
+
+	// PutTokens is delegated to separate goroutines.
+	for i := 0; i < 3; i++ {
+		select {
+		case <-putTokensCh:
+		case <-time.After(1 * time.Second):
+			t.Fatalf("PutTokens called %d times, want 3", i)
+		}
+	}
+}
+
+func TestTrillianInterceptor_StreamLeavesChargedPerLeaf(t *testing.T) {
+	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 10
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	admin := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(gomock.Any(), logTree.TreeId).AnyTimes().Return(logTree, nil)
+	adminTX.EXPECT().Close().AnyTimes().Return(nil)
+	adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+	specs := []quota.Spec{
+		{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+		{Group: quota.Global, Kind: quota.Read, Refundable: true},
+	}
+	qm := quota.NewMockManager(ctrl)
+	gomock.InOrder(
+		qm.EXPECT().GetTokens(gomock.Any(), 1, specs).Return(nil),
+		qm.EXPECT().GetTokens(gomock.Any(), 3, specs).Return(nil),
+		qm.EXPECT().GetTokens(gomock.Any(), 2, specs).Return(errors.New("not enough tokens")),
+	)
+
+	ss := &fakeServerStream{
+		ctx:  context.Background(),
+		reqs: []proto.Message{&trillian.StreamLeavesRequest{LogId: logTree.TreeId}},
+	}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		var req trillian.StreamLeavesRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		// A response without leaves isn't charged.
+		for _, n := range []int{3, 0, 2} {
+			if err := stream.SendMsg(&trillian.StreamLeavesResponse{Leaves: make([]*trillian.LogLeaf, n)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	intercept := New(admin, qm, false /* quotaDryRun */, nil /* mf */)
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/StreamLeaves", IsServerStream: true}
+	if err := intercept.StreamInterceptor(nil, ss, info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("StreamInterceptor() returned err = %v, want code %v", err, codes.ResourceExhausted)
+	}
+	if len(ss.sent) != 2 {
+		t.Errorf("StreamInterceptor() sent %d messages, want 2", len(ss.sent))
+	}
+}
+
+func TestStreamRequestID(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "client-id.1"))
+	var id string
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		id = logging.RequestID(stream.Context())
+		return nil
+	}
+	if err := StreamRequestID(nil, &fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{}, handler); err != nil {
+		t.Fatalf("StreamRequestID() returned err = %v", err)
+	}
+	if want := "client-id.1"; id != want {
+		t.Errorf("request ID = %q, want %q", id, want)
+	}
+}
+
 func TestTrillianInterceptor_NotIntercepted(t *testing.T) {
 	tests := []struct {
 		method string
@@ -494,6 +907,7 @@ func TestTrillianInterceptor_NotIntercepted(t *testing.T) {
 		{method: "/quotapb.Quota/CreateConfig", req: &quotapb.CreateConfigRequest{}},
 		{method: "/quotapb.Quota/DeleteConfig", req: &quotapb.DeleteConfigRequest{}},
 		{method: "/quotapb.Quota/GetConfig", req: &quotapb.GetConfigRequest{}},
+		{method: "/quotapb.Quota/GetUsage", req: &quotapb.GetUsageRequest{}},
 		{method: "/quotapb.Quota/ListConfigs", req: &quotapb.ListConfigsRequest{}},
 		{method: "/quotapb.Quota/UpdateConfig", req: &quotapb.UpdateConfigRequest{}},
 	}
@@ -718,10 +1132,171 @@ func TestErrorWrapper(t *testing.T) {
 	}
 }
 
+func TestErrorWrapperRequestID(t *testing.T) {
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	for _, test := range []struct {
+		desc, wantMsg string
+		err           error
+		wantCode      codes.Code
+	}{
+		{
+			desc:     "status",
+			err:      status.Errorf(codes.InvalidArgument, "Bad Llama"),
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "Bad Llama (request_id=req-1)",
+		},
+		{
+			desc:     "annotatedByStorage",
+			err:      logging.AnnotateError(ctx, sql.ErrNoRows),
+			wantCode: codes.NotFound,
+			wantMsg:  sql.ErrNoRows.Error() + " (request_id=req-1)",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			handler := fakeHandler{err: test.err}
+			_, err := ErrorWrapper(ctx, "req", &grpc.UnaryServerInfo{}, handler.run)
+			s := status.Convert(err)
+			if s.Code() != test.wantCode || s.Message() != test.wantMsg {
+				t.Errorf("ErrorWrapper() = %v, want code %v and message %q", err, test.wantCode, test.wantMsg)
+			}
+			if got := logging.RequestIDFromError(err); got != "req-1" {
+				t.Errorf("RequestIDFromError() = %q, want %q", got, "req-1")
+			}
+		})
+	}
+}
+
 func equalError(x, y error) bool {
 	return x == y || (x != nil && y != nil && x.Error() == y.Error())
 }
 
+func TestDeadline(t *testing.T) {
+	for _, test := range []struct {
+		desc                       string
+		defaultTimeout, maxTimeout time.Duration
+		clientTimeout              time.Duration
+		wantDeadline               bool
+		wantTimeout                time.Duration
+		wantCode                   codes.Code
+	}{
+		{desc: "disabled"},
+		{desc: "disabledWithClientDeadline", clientTimeout: time.Hour, wantDeadline: true, wantTimeout: time.Hour},
+		{desc: "default", defaultTimeout: time.Minute, maxTimeout: time.Hour, wantDeadline: true, wantTimeout: time.Minute},
+		{desc: "defaultFromMax", maxTimeout: time.Hour, wantDeadline: true, wantTimeout: time.Hour},
+		{desc: "clientDeadline", defaultTimeout: time.Minute, maxTimeout: time.Hour, clientTimeout: 10 * time.Minute, wantDeadline: true, wantTimeout: 10 * time.Minute},
+		{desc: "clientDeadlineTooLong", defaultTimeout: time.Minute, maxTimeout: time.Hour, clientTimeout: 2 * time.Hour, wantCode: codes.InvalidArgument},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			if test.clientTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.clientTimeout)
+				defer cancel()
+			}
+			handler := fakeHandler{resp: "ok"}
+			start := time.Now()
+			_, err := Deadline(test.defaultTimeout, test.maxTimeout)(ctx, "req", &grpc.UnaryServerInfo{}, handler.run)
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("Deadline() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if err != nil {
+				if handler.called {
+					t.Error("handler called for rejected request")
+				}
+				return
+			}
+			deadline, ok := handler.ctx.Deadline()
+			if ok != test.wantDeadline {
+				t.Fatalf("handler context has deadline: %v, want %v", ok, test.wantDeadline)
+			}
+			// Allow for the time elapsed between setting and checking the deadline.
+			if got := deadline.Sub(start); ok && (got > test.wantTimeout+time.Second || got < test.wantTimeout-time.Second) {
+				t.Errorf("handler context deadline in %v, want %v", got, test.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		req      interface{}
+		wantCode codes.Code
+	}{
+		{method: "/trillian.TrillianLog/GetInclusionProof", req: &trillian.GetInclusionProofRequest{}},
+		{method: "/trillian.TrillianLog/GetLeavesByRange", req: &trillian.GetLeavesByRangeRequest{}},
+		{method: "/trillian.TrillianLog/QueueLeaf", req: &trillian.QueueLeafRequest{}, wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianLog/AddSequencedLeaves", req: &trillian.AddSequencedLeavesRequest{}, wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianLog/InitLog", req: &trillian.InitLogRequest{}, wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianAdmin/GetTree", req: &trillian.GetTreeRequest{}},
+		{method: "/trillian.TrillianAdmin/ListTrees", req: &trillian.ListTreesRequest{}},
+		{method: "/trillian.TrillianAdmin/CreateTree", req: &trillian.CreateTreeRequest{}, wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianAdmin/DeleteTree", req: &trillian.DeleteTreeRequest{}, wantCode: codes.PermissionDenied},
+		// Quota configs aren't kept in the Trillian storage.
+		{method: "/quotapb.Quota/CreateConfig", req: &quotapb.CreateConfigRequest{}},
+	} {
+		t.Run(test.method, func(t *testing.T) {
+			handler := fakeHandler{resp: "ok"}
+			_, err := ReadOnly(context.Background(), test.req, &grpc.UnaryServerInfo{FullMethod: test.method}, handler.run)
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("ReadOnly() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if got, want := handler.called, err == nil; got != want {
+				t.Errorf("handler called: %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestStreamReadOnly(t *testing.T) {
+	ss := &fakeServerStream{
+		ctx:  context.Background(),
+		reqs: []proto.Message{&trillian.QueueLeavesRequest{Leaves: []*trillian.LogLeaf{{}}}},
+	}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		var req trillian.QueueLeavesRequest
+		return stream.RecvMsg(&req)
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves", IsClientStream: true, IsServerStream: true}
+	if err := StreamReadOnly(nil, ss, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("StreamReadOnly() returned err = %v, want code %v", err, codes.PermissionDenied)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		desc   string
+		md     metadata.MD
+		wantID string
+	}{
+		{desc: "noMetadata"},
+		{desc: "clientID", md: metadata.Pairs(RequestIDMetadataKey, "client-id.1"), wantID: "client-id.1"},
+		{desc: "invalidClientID", md: metadata.Pairs(RequestIDMetadataKey, "bad id")},
+		{desc: "tooLongClientID", md: metadata.Pairs(RequestIDMetadataKey, strings.Repeat("a", maxRequestIDLength+1))},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			if test.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, test.md)
+			}
+			handler := fakeHandler{resp: "ok"}
+			if _, err := RequestID(ctx, "req", &grpc.UnaryServerInfo{}, handler.run); err != nil {
+				t.Fatalf("RequestID() returned err = %v", err)
+			}
+			id := logging.RequestID(handler.ctx)
+			switch {
+			case id == "":
+				t.Error("handler context has no request ID")
+			case test.wantID != "" && id != test.wantID:
+				t.Errorf("request ID = %q, want %q", id, test.wantID)
+			case test.wantID == "" && test.md != nil && id == test.md.Get(RequestIDMetadataKey)[0]:
+				t.Errorf("request ID = %q, want a generated ID", id)
+			}
+		})
+	}
+}
+
 type fakeHandler struct {
 	called bool
 	resp   interface{}
@@ -741,6 +1316,33 @@ func (f *fakeHandler) run(ctx context.Context, req interface{}) (interface{}, er
 	return f.resp, f.err
 }
 
+// fakeServerStream is a grpc.ServerStream which receives reqs, and records the messages sent
+// on it.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	reqs []proto.Message
+	sent []interface{}
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(s.reqs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.reqs[0])
+	s.reqs = s.reqs[1:]
+	return nil
+}
+
+func (s *fakeServerStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
 type fakeInterceptor struct {
 	key    interface{}
 	val    interface{}
@@ -758,3 +1360,15 @@ func (f *fakeInterceptor) run(ctx context.Context, req interface{}, info *grpc.U
 	}
 	return handler(context.WithValue(ctx, f.key, f.val), req)
 }
+
+func TestChargedTenantsValid(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		TenantMetadataKey, "acme",
+		TenantMetadataKey, "acme/read",
+		TenantMetadataKey, "",
+		TenantMetadataKey, strings.Repeat("a", maxTenantLength+1),
+		TenantMetadataKey, "init-ech_2.0"))
+	if got, want := chargedTenants(ctx), []string{"acme", "init-ech_2.0"}; !cmp.Equal(got, want) {
+		t.Errorf("chargedTenants() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	requestBytesReason  = "request_bytes"
+	batchLeavesReason   = "batch_leaves"
+	inFlightBytesReason = "in_flight_bytes"
+)
+
+// RequestLimit bounds the requests of each caller to a tree. Zero fields are unlimited.
+type RequestLimit struct {
+	// MaxRequestBytes is the maximum serialized size of a request.
+	MaxRequestBytes int
+	// MaxBatchLeaves is the maximum number of leaves written by a request.
+	MaxBatchLeaves int
+	// MaxInFlightBytes is the maximum total serialized size of the unary requests of a caller
+	// being handled at once.
+	MaxInFlightBytes int64
+}
+
+// RequestLimiter is a gRPC interceptor which bounds the size of the requests to logs, so that
+// pathological batches can't exhaust the memory of the servers and of the sequencer. Requests
+// which can never be accepted, as they are larger or write more leaves than allowed, are rejected
+// with InvalidArgument. Requests which would take the requests of their caller being handled above
+// the limit of the tree are rejected with ResourceExhausted, and may be retried later.
+//
+// Callers are identified by the subject of their token if authenticated, or else by their first
+// tenant, or else by the host prefix of their address. Callers which have none of these share
+// their limits.
+type RequestLimiter struct {
+	rejected monitoring.Counter
+
+	mu sync.Mutex // Guards the fields below.
+	// def is the limit of trees without an entry in perTree.
+	def     RequestLimit
+	perTree map[int64]RequestLimit
+	// inFlight holds the size of the requests being handled, by caller and tree.
+	inFlight map[callerTree]int64
+}
+
+type callerTree struct {
+	caller string
+	treeID int64
+}
+
+// NewRequestLimiter returns a RequestLimiter which applies the given limits, and counts rejected
+// requests using mf.
+func NewRequestLimiter(def RequestLimit, perTree map[int64]RequestLimit, mf monitoring.MetricFactory) *RequestLimiter {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &RequestLimiter{
+		rejected: mf.NewCounter(
+			"request_limit_rejected_requests",
+			"Number of requests rejected for exceeding the request limits of their tree, by reason",
+			"reason", monitoring.TreeIDLabel),
+		def:      def,
+		perTree:  perTree,
+		inFlight: make(map[callerTree]int64),
+	}
+}
+
+// SetLimits replaces the default and per-tree limits of the RequestLimiter. It is safe to call
+// while the RequestLimiter is in use.
+func (l *RequestLimiter) SetLimits(def RequestLimit, perTree map[int64]RequestLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.def = def
+	l.perTree = perTree
+}
+
+func (l *RequestLimiter) limit(treeID int64) RequestLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit, ok := l.perTree[treeID]; ok {
+		return limit
+	}
+	return l.def
+}
+
+// UnaryInterceptor applies the limits to unary RPCs.
+func (l *RequestLimiter) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	treeID, size, err := l.check(ctx, req, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		release, err := l.acquire(ctx, treeID, size)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor applies the limits to each request received on streaming RPCs. Streams don't
+// count towards MaxInFlightBytes, as the server bounds the leaves it buffers for each stream.
+func (l *RequestLimiter) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &limitedStream{ServerStream: ss, parent: l, method: info.FullMethod})
+}
+
+// limitedStream is a grpc.ServerStream which rejects the requests exceeding their limits.
+type limitedStream struct {
+	grpc.ServerStream
+	parent *RequestLimiter
+	method string
+}
+
+func (s *limitedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	_, _, err := s.parent.check(s.Context(), m, s.method)
+	return err
+}
+
+// leavesRequest is satisfied by the requests which write a batch of leaves.
+type leavesRequest interface {
+	GetLeaves() []*trillian.LogLeaf
+}
+
+// check returns an InvalidArgument error if req exceeds the size or batch limits of its tree.
+// Otherwise it returns the tree ID of req, and its size if MaxInFlightBytes applies to it.
+func (l *RequestLimiter) check(ctx context.Context, req interface{}, method string) (int64, int64, error) {
+	if !enabledServices[serviceName(method)] {
+		return 0, 0, nil
+	}
+	r, ok := req.(logIDRequest)
+	if !ok {
+		return 0, 0, nil
+	}
+	treeID := r.GetLogId()
+	limit := l.limit(treeID)
+	if limit == (RequestLimit{}) {
+		return treeID, 0, nil
+	}
+
+	var size int
+	if m, ok := req.(proto.Message); ok {
+		size = proto.Size(m)
+	}
+	if max := limit.MaxRequestBytes; max > 0 && size > max {
+		l.reject(ctx, requestBytesReason, treeID)
+		return 0, 0, status.Errorf(codes.InvalidArgument, "request too large: %d bytes, tree %d allows at most %d", size, treeID, max)
+	}
+	leaves := 0
+	switch req := req.(type) {
+	case *trillian.QueueLeafRequest:
+		leaves = 1
+	case leavesRequest:
+		leaves = len(req.GetLeaves())
+	}
+	if max := limit.MaxBatchLeaves; max > 0 && leaves > max {
+		l.reject(ctx, batchLeavesReason, treeID)
+		return 0, 0, status.Errorf(codes.InvalidArgument, "too many leaves: %d, tree %d allows at most %d per request", leaves, treeID, max)
+	}
+	if limit.MaxInFlightBytes <= 0 {
+		return treeID, 0, nil
+	}
+	return treeID, int64(size), nil
+}
+
+// acquire adds size to the requests of the caller of ctx being handled for the tree, and returns
+// the function removing it once the request is handled. It returns a ResourceExhausted error if
+// that would exceed MaxInFlightBytes. Requests larger than MaxInFlightBytes are accepted while
+// the caller has no other request in flight, so that they are limited by MaxRequestBytes only.
+func (l *RequestLimiter) acquire(ctx context.Context, treeID, size int64) (func(), error) {
+	key := callerTree{caller: callerID(ctx), treeID: treeID}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	max := l.def.MaxInFlightBytes
+	if limit, ok := l.perTree[treeID]; ok {
+		max = limit.MaxInFlightBytes
+	}
+	if cur := l.inFlight[key]; max > 0 && cur > 0 && cur+size > max {
+		l.rejected.Inc(inFlightBytesReason, strconv.FormatInt(treeID, 10))
+		logger.Info(ctx, "request rejected", "reason", inFlightBytesReason, "tree_id", treeID, "caller", key.caller)
+		return nil, status.Errorf(codes.ResourceExhausted, "caller has %d bytes of requests in flight for tree %d, limit is %d", cur, treeID, max)
+	}
+	l.inFlight[key] += size
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.inFlight[key] -= size; l.inFlight[key] <= 0 {
+			delete(l.inFlight, key)
+		}
+	}, nil
+}
+
+func (l *RequestLimiter) reject(ctx context.Context, reason string, treeID int64) {
+	l.rejected.Inc(reason, strconv.FormatInt(treeID, 10))
+	logger.Info(ctx, "request rejected", "reason", reason, "tree_id", treeID, "caller", callerID(ctx))
+}
+
+// callerID returns the identity of the caller of ctx: the subject of its token, the host prefix
+// of its address, or its first tenant, in this order of preference. Tenants are only used for
+// callers without an IP address (e.g. over Unix sockets), since unauthenticated callers may pick
+// any tenant to spread their requests over.
+func callerID(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok && claims.Subject != "" {
+		return "user:" + claims.Subject
+	}
+	if prefix := chargedPrefix(ctx); prefix != "" {
+		return "prefix:" + prefix
+	}
+	if tenants := chargedTenants(ctx); len(tenants) > 0 {
+		return "tenant:" + tenants[0]
+	}
+	return ""
+}
+
+// ParseRequestLimits parses per-tree request limits from a comma-separated list of
+// "treeID:maxRequestBytes:maxBatchLeaves:maxInFlightBytes" entries, e.g.
+// "123:1048576:1000:8388608,456:0:100:0".
+func ParseRequestLimits(spec string) (map[int64]RequestLimit, error) {
+	limits := make(map[int64]RequestLimit)
+	if spec == "" {
+		return limits, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid request limit %q, want treeID:maxRequestBytes:maxBatchLeaves:maxInFlightBytes", entry)
+		}
+		treeID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree ID in request limit %q: %v", entry, err)
+		}
+		if _, ok := limits[treeID]; ok {
+			return nil, fmt.Errorf("duplicate request limit for tree %d", treeID)
+		}
+		var limit RequestLimit
+		limit.MaxRequestBytes, err = strconv.Atoi(parts[1])
+		if err != nil || limit.MaxRequestBytes < 0 {
+			return nil, fmt.Errorf("invalid maxRequestBytes in request limit %q", entry)
+		}
+		limit.MaxBatchLeaves, err = strconv.Atoi(parts[2])
+		if err != nil || limit.MaxBatchLeaves < 0 {
+			return nil, fmt.Errorf("invalid maxBatchLeaves in request limit %q", entry)
+		}
+		limit.MaxInFlightBytes, err = strconv.ParseInt(parts[3], 10, 64)
+		if err != nil || limit.MaxInFlightBytes < 0 {
+			return nil, fmt.Errorf("invalid maxInFlightBytes in request limit %q", entry)
+		}
+		limits[treeID] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func leavesOfSize(n, size int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, n)
+	for i := range leaves {
+		leaves[i] = &trillian.LogLeaf{LeafValue: make([]byte, size)}
+	}
+	return leaves
+}
+
+func TestRequestLimiterCheck(t *testing.T) {
+	ctx := context.Background()
+	l := NewRequestLimiter(RequestLimit{MaxRequestBytes: 1000, MaxBatchLeaves: 10}, map[int64]RequestLimit{
+		2: {MaxBatchLeaves: 2},
+		3: {},
+	}, nil /* mf */)
+
+	for _, test := range []struct {
+		desc     string
+		method   string
+		req      interface{}
+		wantCode codes.Code
+	}{
+		{
+			desc:   "small-batch",
+			method: "/trillian.TrillianLog/AddSequencedLeaves",
+			req:    &trillian.AddSequencedLeavesRequest{LogId: 1, Leaves: leavesOfSize(10, 10)},
+		},
+		{
+			desc:     "too-many-leaves",
+			method:   "/trillian.TrillianLog/AddSequencedLeaves",
+			req:      &trillian.AddSequencedLeavesRequest{LogId: 1, Leaves: leavesOfSize(11, 10)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:     "too-large",
+			method:   "/trillian.TrillianLog/QueueLeaf",
+			req:      &trillian.QueueLeafRequest{LogId: 1, Leaf: &trillian.LogLeaf{LeafValue: make([]byte, 1000)}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:     "per-tree-leaves",
+			method:   "/trillian.TrillianLog/QueueLeaves",
+			req:      &trillian.QueueLeavesRequest{LogId: 2, Leaves: leavesOfSize(3, 1)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:   "per-tree-size",
+			method: "/trillian.TrillianLog/QueueLeaf",
+			req:    &trillian.QueueLeafRequest{LogId: 2, Leaf: &trillian.LogLeaf{LeafValue: make([]byte, 5000)}},
+		},
+		{
+			desc:   "per-tree-unlimited",
+			method: "/trillian.TrillianLog/QueueLeaves",
+			req:    &trillian.QueueLeavesRequest{LogId: 3, Leaves: leavesOfSize(100, 100)},
+		},
+		{
+			desc:   "not-a-log-request",
+			method: "/trillian.TrillianAdmin/CreateTree",
+			req:    &trillian.CreateTreeRequest{Tree: &trillian.Tree{Description: string(make([]byte, 5000))}},
+		},
+		{
+			desc:   "other-service",
+			method: "/other.Service/QueueLeaf",
+			req:    &trillian.QueueLeafRequest{LogId: 1, Leaf: &trillian.LogLeaf{LeafValue: make([]byte, 1000)}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			handled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handled = true
+				return nil, nil
+			}
+			_, err := l.UnaryInterceptor(ctx, test.req, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("UnaryInterceptor() returned %v, want code %v", err, test.wantCode)
+			}
+			if want := test.wantCode == codes.OK; handled != want {
+				t.Errorf("UnaryInterceptor() handled request: %v, want %v", handled, want)
+			}
+		})
+	}
+}
+
+func TestRequestLimiterInFlight(t *testing.T) {
+	const method = "/trillian.TrillianLog/QueueLeaves"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	req := &trillian.QueueLeavesRequest{LogId: 1, Leaves: leavesOfSize(1, 100)}
+	size := int64(proto.Size(req))
+	l := NewRequestLimiter(RequestLimit{MaxInFlightBytes: 2 * size}, nil, nil /* mf */)
+
+	alice := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "alice"))
+	bob := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "bob"))
+	call := func(ctx context.Context, handler grpc.UnaryHandler) error {
+		_, err := l.UnaryInterceptor(ctx, req, info, handler)
+		return err
+	}
+	noop := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	// Alice's third request in flight exceeds the limit, while Bob's has its own.
+	var codesAlice, codesBob []codes.Code
+	err := call(alice, func(context.Context, interface{}) (interface{}, error) {
+		return nil, call(alice, func(context.Context, interface{}) (interface{}, error) {
+			codesAlice = append(codesAlice, status.Code(call(alice, noop)))
+			codesBob = append(codesBob, status.Code(call(bob, noop)))
+			return nil, nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("UnaryInterceptor(): %v", err)
+	}
+	if want := []codes.Code{codes.ResourceExhausted}; !cmp.Equal(codesAlice, want) {
+		t.Errorf("third request of caller got %v, want %v", codesAlice, want)
+	}
+	if want := []codes.Code{codes.OK}; !cmp.Equal(codesBob, want) {
+		t.Errorf("request of other caller got %v, want %v", codesBob, want)
+	}
+
+	// Once handled, requests are no longer in flight.
+	if err := call(alice, noop); err != nil {
+		t.Errorf("UnaryInterceptor() after requests were handled: %v", err)
+	}
+	if len(l.inFlight) != 0 {
+		t.Errorf("requests in flight: %v, want none", l.inFlight)
+	}
+
+	// A request larger than the limit is accepted if it's the only one in flight.
+	l.SetLimits(RequestLimit{MaxInFlightBytes: size / 2}, nil)
+	if err := call(alice, noop); err != nil {
+		t.Errorf("UnaryInterceptor() of large request: %v", err)
+	}
+}
+
+// fakeStream is a grpc.ServerStream which receives a fixed request.
+type fakeStream struct {
+	grpc.ServerStream
+	req *trillian.QueueLeavesRequest
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func TestRequestLimiterStream(t *testing.T) {
+	l := NewRequestLimiter(RequestLimit{MaxBatchLeaves: 2}, nil, nil /* mf */)
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+	for _, test := range []struct {
+		leaves   int
+		wantCode codes.Code
+	}{
+		{leaves: 2, wantCode: codes.OK},
+		{leaves: 3, wantCode: codes.InvalidArgument},
+	} {
+		ss := &fakeStream{req: &trillian.QueueLeavesRequest{LogId: 1, Leaves: leavesOfSize(test.leaves, 1)}}
+		err := l.StreamInterceptor(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			return ss.RecvMsg(&trillian.QueueLeavesRequest{})
+		})
+		if got := status.Code(err); got != test.wantCode {
+			t.Errorf("StreamInterceptor() with %d leaves returned %v, want code %v", test.leaves, err, test.wantCode)
+		}
+	}
+}
+
+func TestParseRequestLimits(t *testing.T) {
+	got, err := ParseRequestLimits("123:1048576:1000:8388608,456:0:100:0")
+	if err != nil {
+		t.Fatalf("ParseRequestLimits(): %v", err)
+	}
+	want := map[int64]RequestLimit{
+		123: {MaxRequestBytes: 1048576, MaxBatchLeaves: 1000, MaxInFlightBytes: 8388608},
+		456: {MaxBatchLeaves: 100},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseRequestLimits() diff (-want +got):\n%s", diff)
+	}
+
+	for _, spec := range []string{
+		"123",
+		"123:1:2",
+		"x:1:2:3",
+		"123:-1:2:3",
+		"123:1:x:3",
+		"123:1:2:-3",
+		"123:1:2:3,123:4:5:6",
+	} {
+		if _, err := ParseRequestLimits(spec); err == nil {
+			t.Errorf("ParseRequestLimits(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestCallerID(t *testing.T) {
+	tenant := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "alice"))
+	addr := peer.NewContext(tenant, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1234}})
+	user := NewClaimsContext(addr, &Claims{Subject: "bob"})
+	for _, test := range []struct {
+		desc string
+		ctx  context.Context
+		want string
+	}{
+		{desc: "none", ctx: context.Background(), want: ""},
+		{desc: "tenant", ctx: tenant, want: "tenant:alice"},
+		{desc: "address", ctx: addr, want: "prefix:" + chargedPrefix(addr)},
+		{desc: "subject", ctx: user, want: "user:bob"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := callerID(test.ctx); got != test.want {
+				t.Errorf("callerID() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -16,34 +16,62 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"strconv"
+	"sync"
+	"time"
 
-	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/server/readcache"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/trees"
 	"github.com/google/trillian/types"
 	"github.com/google/trillian/util/clock"
 	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
 	"github.com/transparency-dev/merkle/proof"
 	"github.com/transparency-dev/merkle/rfc6962"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // TODO: There is no access control in the server yet and clients could easily modify
 // any tree.
 
-const traceSpanRoot = "/trillian"
+const (
+	traceSpanRoot = "/trillian"
+
+	// importScanBatch is the number of leaves read at a time by GetImportProgress while
+	// looking for the end of the leaves added contiguously to a pre-ordered log.
+	importScanBatch = 1000
+
+	// maxLeavesByHashPageSize is the maximum number of leaves returned by a call to
+	// GetLeavesByHash, each of which may need an inclusion proof.
+	maxLeavesByHashPageSize = 100
+
+	// maxLeavesByHashCount is the maximum number of Merkle leaf hashes
+	// requested by a call to GetLeavesByHash.
+	maxLeavesByHashCount = 1000
+
+	// maxLeavesByIndexCount is the maximum number of indices requested by a
+	// call to GetLeavesByIndexList.
+	maxLeavesByIndexCount = 1000
+)
 
 var (
 	optsLogInit            = trees.NewGetOpts(trees.Admin, trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG)
 	optsLogRead            = trees.NewGetOpts(trees.Query, trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG)
 	optsLogWrite           = trees.NewGetOpts(trees.QueueLog, trillian.TreeType_LOG)
+	optsPreorderedLogRead  = trees.NewGetOpts(trees.Query, trillian.TreeType_PREORDERED_LOG)
 	optsPreorderedLogWrite = trees.NewGetOpts(trees.SequenceLog, trillian.TreeType_PREORDERED_LOG)
+
+	logger = logging.New("server")
 )
 
 // TrillianLogRPCServer implements the RPC API defined in the proto
@@ -53,6 +81,11 @@ type TrillianLogRPCServer struct {
 	leafCounter           monitoring.Counter
 	proofIndexPercentiles monitoring.Histogram
 	fetchedLeaves         monitoring.Counter
+
+	// addedSizesMu guards addedSizes, which holds the number of leaves known to be added
+	// contiguously to each pre-ordered log, so that GetImportProgress needn't scan them again.
+	addedSizesMu sync.Mutex
+	addedSizes   map[int64]int64
 }
 
 // NewTrillianLogRPCServer creates a new RPC server backed by a LogStorageProvider.
@@ -78,6 +111,7 @@ func NewTrillianLogRPCServer(registry extension.Registry, timeSource clock.TimeS
 			"fetched_leaves",
 			"Count of individual leaves fetched through GetLeaves* calls",
 		),
+		addedSizes: make(map[int64]int64),
 	}
 }
 
@@ -101,9 +135,35 @@ func (t *TrillianLogRPCServer) QueueLeaf(ctx context.Context, req *trillian.Queu
 		return nil, err
 	}
 
-	req.Leaf.MerkleLeafHash = hasher.HashLeaf(req.Leaf.LeafValue)
-	if len(req.Leaf.LeafIdentityHash) == 0 {
-		req.Leaf.LeafIdentityHash = req.Leaf.MerkleLeafHash
+	if t.registry.BacklogLimiter != nil {
+		if err := t.registry.BacklogLimiter.Check(ctx, tree); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.validateLeaves(ctx, tree, []*trillian.LogLeaf{req.Leaf}); err != nil {
+		return nil, err
+	}
+
+	if err := hashLeaves(tree, []*trillian.LogLeaf{req.Leaf}, hasher); err != nil {
+		return nil, err
+	}
+
+	if q, ok := t.registry.LogStorage.(storage.AsyncLeafQueuer); req.Async && ok {
+		// Duplicates aren't looked up, so the submitted leaf is always returned.
+		now := t.timeSource.Now()
+		if err := q.QueueLeavesAsync(trees.NewContext(ctx, tree), tree, []*trillian.LogLeaf{req.Leaf}, now); err != nil {
+			return nil, err
+		}
+		req.Leaf.QueueTimestamp = timestamppb.New(now)
+		queued := &trillian.QueuedLogLeaf{Leaf: req.Leaf}
+		if t.registry.BacklogLimiter != nil {
+			t.registry.BacklogLimiter.Queued(tree, []*trillian.QueuedLogLeaf{queued})
+		}
+		if t.registry.Meter != nil {
+			t.registry.Meter.RecordQueued(ctx, tree.TreeId, []*trillian.QueuedLogLeaf{queued})
+		}
+		return &trillian.QueueLeafResponse{QueuedLeaf: queued}, nil
 	}
 
 	ret, err := t.registry.LogStorage.QueueLeaves(trees.NewContext(ctx, tree), tree, []*trillian.LogLeaf{req.Leaf}, t.timeSource.Now())
@@ -117,18 +177,199 @@ func (t *TrillianLogRPCServer) QueueLeaf(ctx context.Context, req *trillian.Queu
 	if len(ret) != 1 {
 		return nil, status.Errorf(codes.Internal, "unexpected count of leaves %d", len(ret))
 	}
+	if t.registry.BacklogLimiter != nil {
+		t.registry.BacklogLimiter.Queued(tree, ret)
+	}
+	if t.registry.Meter != nil {
+		t.registry.Meter.RecordQueued(ctx, tree.TreeId, ret)
+	}
 	return &trillian.QueueLeafResponse{QueuedLeaf: ret[0]}, nil
 }
 
-func hashLeaves(leaves []*trillian.LogLeaf, hasher merkle.LogHasher) {
+// validateLeaves runs the registered LeafValidator, if any, on leaves. The whole request is
+// rejected if any of the leaves is invalid.
+func (t *TrillianLogRPCServer) validateLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf) error {
+	if t.registry.LeafValidator == nil {
+		return nil
+	}
+	for i, leaf := range leaves {
+		if err := t.registry.LeafValidator.ValidateLeaf(ctx, tree, leaf); err != nil {
+			t.leafCounter.Inc(strconv.FormatInt(tree.TreeId, 10), "rejected")
+			if _, ok := status.FromError(err); ok {
+				return err
+			}
+			return status.Errorf(codes.InvalidArgument, "leaf %d rejected: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// hashLeaves sets the MerkleLeafHash of leaves, and their LeafIdentityHash according to the
+// LeafDedupPolicy of tree. Storage deduplicates leaves by LeafIdentityHash.
+func hashLeaves(tree *trillian.Tree, leaves []*trillian.LogLeaf, hasher merkle.LogHasher) error {
 	for _, leaf := range leaves {
 		leaf.MerkleLeafHash = hasher.HashLeaf(leaf.LeafValue)
-		if len(leaf.LeafIdentityHash) == 0 {
+		switch policy := tree.LeafDedupPolicy; policy {
+		case trillian.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH:
+			if len(leaf.LeafIdentityHash) == 0 {
+				leaf.LeafIdentityHash = leaf.MerkleLeafHash
+			}
+		case trillian.LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH:
 			leaf.LeafIdentityHash = leaf.MerkleLeafHash
+		case trillian.LeafDedupPolicy_NO_DEDUP:
+			leaf.LeafIdentityHash = make([]byte, len(leaf.MerkleLeafHash))
+			if _, err := rand.Read(leaf.LeafIdentityHash); err != nil {
+				return status.Errorf(codes.Internal, "failed to generate leaf identity hash: %v", err)
+			}
+		default:
+			return status.Errorf(codes.FailedPrecondition, "tree %d has unknown leaf_dedup_policy: %v", tree.TreeId, policy)
+		}
+	}
+	return nil
+}
+
+// QueueLeavesBatchSize is the maximum number of leaves received on a QueueLeaves stream that
+// are queued, and acknowledged, together.
+var QueueLeavesBatchSize = 1000
+
+// QueueLeavesFlushInterval is the maximum time leaves received on a QueueLeaves stream are
+// buffered before being queued and acknowledged.
+var QueueLeavesFlushInterval = 100 * time.Millisecond
+
+// QueueLeaves queues the leaves received on the stream, in batches, and sends a response with
+// the status of each leaf of a batch once it has been processed. Leaves are acknowledged in the
+// order they were received. All the requests on a stream must be for the same log.
+func (t *TrillianLogRPCServer) QueueLeaves(stream trillian.TrillianLog_QueueLeavesServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "QueueLeaves")
+	defer spanEnd()
+
+	reqs := make(chan *trillian.QueueLeavesRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var tree *trillian.Tree
+	var hasher merkle.LogHasher
+	var leaves []*trillian.LogLeaf
+	flush := func() error {
+		if len(leaves) == 0 {
+			return nil
+		}
+		queued, err := t.queueLeaves(ctx, tree, hasher, leaves)
+		if err != nil {
+			return err
+		}
+		leaves = nil
+		return stream.Send(&trillian.QueueLeavesResponse{QueuedLeaves: queued})
+	}
+
+	timer := time.NewTimer(QueueLeavesFlushInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case req := <-reqs:
+			if tree == nil {
+				var err error
+				if tree, hasher, err = t.getTreeAndHasher(ctx, req.LogId, optsLogWrite); err != nil {
+					return err
+				}
+			} else if req.LogId != tree.TreeId {
+				return status.Errorf(codes.InvalidArgument, "QueueLeavesRequest.LogId: %d, want %d as in previous requests", req.LogId, tree.TreeId)
+			}
+			for _, leaf := range req.Leaves {
+				leaves = append(leaves, leaf)
+				if len(leaves) >= QueueLeavesBatchSize {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+		case <-timer.C:
+			if err := flush(); err != nil {
+				return err
+			}
+			timer.Reset(QueueLeavesFlushInterval)
+		case err := <-recvErr:
+			if ferr := flush(); ferr != nil {
+				return ferr
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
 		}
 	}
 }
 
+// queueLeaves queues a batch of leaves received on a QueueLeaves stream, and returns the
+// status of each of them. Unlike QueueLeaf, invalid leaves are rejected individually, and
+// don't prevent the rest of the batch from being queued.
+func (t *TrillianLogRPCServer) queueLeaves(ctx context.Context, tree *trillian.Tree, hasher merkle.LogHasher, leaves []*trillian.LogLeaf) ([]*trillian.QueuedLogLeaf, error) {
+	ret := make([]*trillian.QueuedLogLeaf, len(leaves))
+	if t.registry.BacklogLimiter != nil {
+		if err := t.registry.BacklogLimiter.Check(ctx, tree); err != nil {
+			st := status.Convert(err).Proto()
+			for i, leaf := range leaves {
+				ret[i] = &trillian.QueuedLogLeaf{Leaf: leaf, Status: st}
+			}
+			return ret, nil
+		}
+	}
+
+	valid := make([]*trillian.LogLeaf, 0, len(leaves))
+	for i, leaf := range leaves {
+		err := validateLogLeaf(leaf, fmt.Sprintf("QueueLeavesRequest.Leaves[%d]", i))
+		if err == nil {
+			err = t.validateLeaves(ctx, tree, []*trillian.LogLeaf{leaf})
+		}
+		if err != nil {
+			ret[i] = &trillian.QueuedLogLeaf{Leaf: leaf, Status: status.Convert(err).Proto()}
+			continue
+		}
+		valid = append(valid, leaf)
+	}
+	if len(valid) == 0 {
+		return ret, nil
+	}
+
+	if err := hashLeaves(tree, valid, hasher); err != nil {
+		return nil, err
+	}
+	queued, err := t.registry.LogStorage.QueueLeaves(trees.NewContext(ctx, tree), tree, valid, t.timeSource.Now())
+	if err != nil {
+		return nil, err
+	}
+	if got, want := len(queued), len(valid); got != want {
+		return nil, status.Errorf(codes.Internal, "QueueLeaves returned %d leaves, want: %d", got, want)
+	}
+	if t.registry.BacklogLimiter != nil {
+		t.registry.BacklogLimiter.Queued(tree, queued)
+	}
+	if t.registry.Meter != nil {
+		t.registry.Meter.RecordQueued(ctx, tree.TreeId, queued)
+	}
+	for i := range ret {
+		if ret[i] == nil {
+			ret[i], queued = queued[0], queued[1:]
+		}
+	}
+	return ret, nil
+}
+
 // AddSequencedLeaves submits a batch of sequenced leaves to a pre-ordered log
 // for later integration into its underlying tree.
 func (t *TrillianLogRPCServer) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
@@ -143,7 +384,13 @@ func (t *TrillianLogRPCServer) AddSequencedLeaves(ctx context.Context, req *tril
 		return nil, err
 	}
 
-	hashLeaves(req.Leaves, hasher)
+	if err := t.validateLeaves(ctx, tree, req.Leaves); err != nil {
+		return nil, err
+	}
+
+	if err := hashLeaves(tree, req.Leaves, hasher); err != nil {
+		return nil, err
+	}
 
 	ctx = trees.NewContext(ctx, tree)
 	leaves, err := t.registry.LogStorage.AddSequencedLeaves(ctx, tree, req.Leaves, t.timeSource.Now())
@@ -163,6 +410,10 @@ func (t *TrillianLogRPCServer) AddSequencedLeaves(ctx context.Context, req *tril
 		}
 	}
 
+	if t.registry.Meter != nil {
+		t.registry.Meter.RecordSequenced(ctx, tree.TreeId, leaves)
+	}
+
 	return &trillian.AddSequencedLeavesResponse{Results: leaves}, nil
 }
 
@@ -182,6 +433,14 @@ func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trill
 	}
 	ctx = trees.NewContext(ctx, tree)
 
+	if slr, root := t.cachedRoot(ctx, tree.TreeId); root != nil && uint64(req.TreeSize) <= root.TreeSize {
+		proof := &trillian.Proof{}
+		if t.getCached(ctx, tree.TreeId, readcache.KindInclusion, proof, req.LeafIndex, req.TreeSize) {
+			t.recordIndexPercent(req.LeafIndex, root.TreeSize)
+			return &trillian.GetInclusionProofResponse{SignedLogRoot: slr, Proof: proof}, nil
+		}
+	}
+
 	// Next we need to make sure the requested tree size corresponds to an STH, so that we
 	// have a usable tree revision
 	tx, err := t.snapshotForTree(ctx, tree, "GetInclusionProof")
@@ -214,6 +473,8 @@ func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trill
 	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	t.setCached(ctx, tree.TreeId, readcache.KindInclusion, proof, req.LeafIndex, req.TreeSize)
 
 	r.Proof = proof
 
@@ -236,6 +497,17 @@ func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req
 		return nil, err
 	}
 
+	if slr, root := t.cachedRoot(ctx, tree.TreeId); root != nil && uint64(req.TreeSize) <= root.TreeSize {
+		r := &trillian.GetInclusionProofByHashResponse{}
+		if t.getCached(ctx, tree.TreeId, readcache.KindInclusionByHash, r, req.LeafHash, req.TreeSize, req.OrderBySequence) {
+			for _, proof := range r.Proof {
+				t.recordIndexPercent(proof.LeafIndex, root.TreeSize)
+			}
+			r.SignedLogRoot = slr
+			return r, nil
+		}
+	}
+
 	// Next we need to make sure the requested tree size corresponds to an STH, so that we
 	// have a usable tree revision
 	tx, err := t.snapshotForTree(ctx, tree, "GetInclusionProofByHash")
@@ -282,6 +554,10 @@ func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req
 		return nil, status.Errorf(codes.NotFound,
 			"No leaf found for hash: %x in tree size %v", req.LeafHash, req.TreeSize)
 	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	if uint64(req.TreeSize) <= root.TreeSize {
+		t.setCached(ctx, tree.TreeId, readcache.KindInclusionByHash, &trillian.GetInclusionProofByHashResponse{Proof: proofs}, req.LeafHash, req.TreeSize, req.OrderBySequence)
+	}
 
 	// TODO(gbelvin): Rename "Proof" -> "Proofs"
 	return &trillian.GetInclusionProofByHashResponse{
@@ -307,6 +583,13 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 	}
 	ctx = trees.NewContext(ctx, tree)
 
+	if slr, root := t.cachedRoot(ctx, tree.TreeId); root != nil && uint64(req.SecondTreeSize) <= root.TreeSize {
+		proof := &trillian.Proof{}
+		if t.getCached(ctx, tree.TreeId, readcache.KindConsistency, proof, req.FirstTreeSize, req.SecondTreeSize) {
+			return &trillian.GetConsistencyProofResponse{SignedLogRoot: slr, Proof: proof}, nil
+		}
+	}
+
 	tx, err := t.snapshotForTree(ctx, tree, "GetConsistencyProof")
 	if err != nil {
 		return nil, err
@@ -335,12 +618,250 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	t.setCached(ctx, tree.TreeId, readcache.KindConsistency, proof, req.FirstTreeSize, req.SecondTreeSize)
 
 	// We have everything we need. Return the proof
 	r.Proof = proof
 	return r, nil
 }
 
+// GetCompactRange returns the hashes of the compact range covering leaves [begin, end) of
+// the log. Its nodes are perfect subtrees, which are stored and never change as the tree
+// grows, so unlike proof nodes they are returned without rehashing.
+func (t *TrillianLogRPCServer) GetCompactRange(ctx context.Context, req *trillian.GetCompactRangeRequest) (*trillian.GetCompactRangeResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetCompactRange")
+	defer spanEnd()
+	if err := validateGetCompactRangeRequest(req); err != nil {
+		return nil, err
+	}
+
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "GetCompactRange")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetCompactRange")
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+	r := &trillian.GetCompactRangeResponse{SignedLogRoot: slr}
+
+	if uint64(req.TreeSize) > root.TreeSize {
+		return r, nil
+	}
+	if ids := compact.RangeNodes(uint64(req.Begin), uint64(req.End), nil); len(ids) > 0 {
+		nodes, err := fetchNodes(ctx, tx, ids)
+		if err != nil {
+			return nil, err
+		}
+		r.Hashes = make([][]byte, len(nodes))
+		for i, node := range nodes {
+			r.Hashes[i] = node.Hash
+		}
+	}
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetCompactRange"); err != nil {
+		return nil, err
+	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	return r, nil
+}
+
+// GetLeavesByHash returns the leaves of a log with the given Merkle leaf hashes, in the order of
+// their indices, a page at a time. The page token is the index of the first leaf of the next page.
+func (t *TrillianLogRPCServer) GetLeavesByHash(ctx context.Context, req *trillian.GetLeavesByHashRequest) (*trillian.GetLeavesByHashResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByHash")
+	defer spanEnd()
+
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+	if err := validateGetLeavesByHashRequest(req, hasher); err != nil {
+		return nil, err
+	}
+	var start int64
+	if req.PageToken != "" {
+		if start, err = strconv.ParseInt(req.PageToken, 10, 64); err != nil || start < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "GetLeavesByHashRequest.PageToken: %q is invalid", req.PageToken)
+		}
+	}
+	pageSize := int(req.PageSize)
+	if pageSize == 0 || pageSize > maxLeavesByHashPageSize {
+		pageSize = maxLeavesByHashPageSize
+	}
+
+	tx, err := t.snapshotForTree(ctx, tree, "GetLeavesByHash")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLeavesByHash")
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+	r := &trillian.GetLeavesByHashResponse{SignedLogRoot: slr}
+
+	treeSize := req.TreeSize
+	if treeSize == 0 {
+		treeSize = int64(root.TreeSize)
+	}
+	if uint64(treeSize) > root.TreeSize {
+		return r, nil
+	}
+
+	// Read one more leaf than the page holds, to tell whether there is a next page.
+	leaves, err := storage.GetLeavesByHashPage(ctx, tx, req.LeafHash, start, treeSize, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) > pageSize {
+		r.NextPageToken = strconv.FormatInt(leaves[pageSize].LeafIndex, 10)
+		leaves = leaves[:pageSize]
+	}
+	r.Leaves = leaves
+	if req.IncludeProofs {
+		for _, leaf := range leaves {
+			proof, err := getInclusionProofForLeafIndex(ctx, tx, hasher, uint64(treeSize), uint64(leaf.LeafIndex))
+			if err != nil {
+				return nil, err
+			}
+			r.Proofs = append(r.Proofs, proof)
+			t.recordIndexPercent(leaf.LeafIndex, root.TreeSize)
+		}
+	}
+	t.fetchedLeaves.Add(float64(len(r.Leaves)))
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetLeavesByHash"); err != nil {
+		return nil, err
+	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	return r, nil
+}
+
+// GetLeavesByIndexList obtains the leaves at a list of leaf indices, which
+// needn't be contiguous, in ascending order of their indices. Indices beyond
+// the size of the latest root are skipped.
+func (t *TrillianLogRPCServer) GetLeavesByIndexList(ctx context.Context, req *trillian.GetLeavesByIndexListRequest) (*trillian.GetLeavesByIndexListResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByIndexList")
+	defer spanEnd()
+
+	if err := validateGetLeavesByIndexListRequest(req); err != nil {
+		return nil, err
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.snapshotForTree(ctx, tree, "GetLeavesByIndexList")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLeavesByIndexList")
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+
+	indices := make([]int64, 0, len(req.LeafIndex))
+	for _, index := range req.LeafIndex {
+		if uint64(index) < root.TreeSize {
+			indices = append(indices, index)
+		}
+	}
+	r := &trillian.GetLeavesByIndexListResponse{SignedLogRoot: slr}
+	if len(indices) > 0 {
+		if r.Leaves, err = storage.GetLeavesByIndex(ctx, tx, indices); err != nil {
+			return nil, err
+		}
+	}
+	t.fetchedLeaves.Add(float64(len(r.Leaves)))
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetLeavesByIndexList"); err != nil {
+		return nil, err
+	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	return r, nil
+}
+
+// GetSignedLogRootAtTime obtains the latest root of the log whose timestamp is at
+// or before the requested time, with a consistency proof from it to the latest
+// root.
+func (t *TrillianLogRPCServer) GetSignedLogRootAtTime(ctx context.Context, req *trillian.GetSignedLogRootAtTimeRequest) (*trillian.GetSignedLogRootAtTimeResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetSignedLogRootAtTime")
+	defer spanEnd()
+	if err := validateGetSignedLogRootAtTimeRequest(req); err != nil {
+		return nil, err
+	}
+
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+
+	tx, err := t.snapshotForTree(ctx, tree, "GetSignedLogRootAtTime")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetSignedLogRootAtTime")
+
+	latest, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var latestRoot types.LogRootV1
+	if err := latestRoot.UnmarshalBinary(latest.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+	slr, err := storage.SignedLogRootAt(ctx, tx, req.Timestamp.AsTime())
+	if err != nil {
+		return nil, err
+	}
+	if slr == nil {
+		return nil, status.Errorf(codes.NotFound, "log %d has no root at or before %v", req.LogId, req.Timestamp.AsTime())
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read log root: %v", err)
+	}
+
+	r := &trillian.GetSignedLogRootAtTimeResponse{SignedLogRoot: slr, LatestSignedLogRoot: latest}
+	if root.TreeSize > 0 && root.TreeSize < latestRoot.TreeSize {
+		if r.Proof, err = tryGetConsistencyProof(ctx, root.TreeSize, latestRoot.TreeSize, tx, hasher); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetSignedLogRootAtTime"); err != nil {
+		return nil, err
+	}
+	t.cacheRoot(ctx, tree.TreeId, latest)
+	return r, nil
+}
+
 // GetLatestSignedLogRoot obtains the latest published tree root for the Merkle Tree that
 // underlies the log.
 func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
@@ -351,6 +872,23 @@ func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *
 		return nil, err
 	}
 	ctx = trees.NewContext(ctx, tree)
+
+	if slr, root := t.cachedRoot(ctx, tree.TreeId); root != nil {
+		r := &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: slr}
+		if req.FirstTreeSize == 0 {
+			return r, nil
+		}
+		// A consistency proof to a tree size beyond the cached root is
+		// validated below with the root read from storage.
+		if req.FirstTreeSize <= int64(root.TreeSize) {
+			proof := &trillian.Proof{}
+			if t.getCached(ctx, tree.TreeId, readcache.KindConsistency, proof, req.FirstTreeSize, int64(root.TreeSize)) {
+				r.Proof = proof
+				return r, nil
+			}
+		}
+	}
+
 	tx, err := t.registry.LogStorage.SnapshotForTree(ctx, tree)
 	if err != nil {
 		return nil, err
@@ -374,6 +912,7 @@ func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *
 		if err := t.commitAndLog(ctx, req.LogId, tx, "GetLatestSignedLogRoot"); err != nil {
 			return nil, err
 		}
+		t.cacheRoot(ctx, tree.TreeId, slr)
 		return r, nil
 	}
 
@@ -393,6 +932,8 @@ func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *
 	if err := t.commitAndLog(ctx, req.LogId, tx, "GetLatestSignedLogRoot"); err != nil {
 		return nil, err
 	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	t.setCached(ctx, tree.TreeId, readcache.KindConsistency, proof, reqProof.FirstTreeSize, reqProof.SecondTreeSize)
 	// We have everything we need. Return the response
 	r.Proof = proof
 	return r, nil
@@ -424,6 +965,17 @@ func (t *TrillianLogRPCServer) GetLeavesByRange(ctx context.Context, req *trilli
 	if err != nil {
 		return nil, err
 	}
+
+	// Only ranges within the tree are cached, as they can't change.
+	if slr, root := t.cachedRoot(ctx, tree.TreeId); root != nil && req.StartIndex+req.Count <= int64(root.TreeSize) {
+		r := &trillian.GetLeavesByRangeResponse{}
+		if t.getCached(ctx, tree.TreeId, readcache.KindLeavesByRange, r, req.StartIndex, req.Count) {
+			t.fetchedLeaves.Add(float64(len(r.Leaves)))
+			r.SignedLogRoot = slr
+			return r, nil
+		}
+	}
+
 	tx, err := t.snapshotForTree(ctx, tree, "GetLeavesByRange")
 	if err != nil {
 		return nil, err
@@ -453,10 +1005,198 @@ func (t *TrillianLogRPCServer) GetLeavesByRange(ctx context.Context, req *trilli
 	if err := t.commitAndLog(ctx, req.LogId, tx, "GetLeavesByRange"); err != nil {
 		return nil, err
 	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	if req.StartIndex+req.Count <= int64(root.TreeSize) && int64(len(r.Leaves)) == req.Count {
+		t.setCached(ctx, tree.TreeId, readcache.KindLeavesByRange, &trillian.GetLeavesByRangeResponse{Leaves: r.Leaves}, req.StartIndex, req.Count)
+	}
 
 	return r, nil
 }
 
+// StreamLeavesBatchSize is the maximum number of leaves sent in a StreamLeaves response.
+var StreamLeavesBatchSize int64 = 1000
+
+// StreamLeavesPollInterval is how often a StreamLeaves stream which has caught up with the
+// head of its log checks for newly sequenced leaves.
+var StreamLeavesPollInterval = time.Second
+
+// StreamLeavesMaxDuration is the maximum lifetime of a StreamLeaves stream. Longer streams
+// end with UNAVAILABLE, and clients resume them with the resume token of their last response.
+// Zero means unlimited.
+var StreamLeavesMaxDuration = time.Hour
+
+// resumeTokenFormat formats the resume tokens of StreamLeaves responses from the log ID
+// and the index of the next leaf to stream.
+const resumeTokenFormat = "%d:%d"
+
+// StreamLeaves sends the leaves of a log from the requested index, in batches, and then
+// polls the log for newly sequenced leaves, which it sends as they're found, until the
+// stream is cancelled.
+func (t *TrillianLogRPCServer) StreamLeaves(req *trillian.StreamLeavesRequest, stream trillian.TrillianLog_StreamLeavesServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "StreamLeaves")
+	defer spanEnd()
+	next, err := validateStreamLeavesRequest(req)
+	if err != nil {
+		return err
+	}
+
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return err
+	}
+
+	var expired <-chan time.Time
+	if StreamLeavesMaxDuration > 0 {
+		timer := t.timeSource.NewTimer(StreamLeavesMaxDuration)
+		defer timer.Stop()
+		expired = timer.Chan()
+	}
+	for {
+		select {
+		case <-expired:
+			return status.Errorf(codes.Unavailable, "StreamLeaves stream exceeded its maximum duration of %v, resume from leaf %d", StreamLeavesMaxDuration, next)
+		default:
+		}
+		r, err := t.nextLeaves(ctx, tree, next)
+		if err != nil {
+			return err
+		}
+		if len(r.Leaves) == 0 {
+			if err := clock.SleepSource(ctx, StreamLeavesPollInterval, t.timeSource); err != nil {
+				return status.FromContextError(err).Err()
+			}
+			continue
+		}
+		next += int64(len(r.Leaves))
+		r.ResumeToken = fmt.Sprintf(resumeTokenFormat, tree.TreeId, next)
+		if err := stream.Send(r); err != nil {
+			return err
+		}
+	}
+}
+
+// nextLeaves reads up to StreamLeavesBatchSize leaves of a log from index next, within the
+// tree of its latest root. No leaves are returned if the log doesn't have a leaf at next yet.
+func (t *TrillianLogRPCServer) nextLeaves(ctx context.Context, tree *trillian.Tree, next int64) (*trillian.StreamLeavesResponse, error) {
+	tx, err := t.snapshotForTree(ctx, tree, "StreamLeaves")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "StreamLeaves")
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+
+	r := &trillian.StreamLeavesResponse{SignedLogRoot: slr}
+	if size := int64(root.TreeSize); next < size {
+		count := size - next
+		if count > StreamLeavesBatchSize {
+			count = StreamLeavesBatchSize
+		}
+		leaves, err := tx.GetLeavesByRange(ctx, next, count)
+		if err != nil {
+			return nil, err
+		}
+		for i, leaf := range leaves {
+			if leaf.LeafIndex != next+int64(i) {
+				return nil, status.Errorf(codes.Internal, "got leaf index %d, want %d", leaf.LeafIndex, next+int64(i))
+			}
+		}
+		t.fetchedLeaves.Add(float64(len(leaves)))
+		r.Leaves = leaves
+	}
+
+	if err := t.commitAndLog(ctx, tree.TreeId, tx, "StreamLeaves"); err != nil {
+		return nil, err
+	}
+	t.cacheRoot(ctx, tree.TreeId, slr)
+	return r, nil
+}
+
+// GetImportProgress returns the number of leaves integrated into a pre-ordered log, and the
+// number of leaves added to it contiguously from index 0, from which an importer resumes.
+func (t *TrillianLogRPCServer) GetImportProgress(ctx context.Context, req *trillian.GetImportProgressRequest) (*trillian.GetImportProgressResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetImportProgress")
+	defer spanEnd()
+
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsPreorderedLogRead)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "GetImportProgress")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetImportProgress")
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+
+	added, err := t.addedSize(ctx, tx, tree.TreeId, int64(root.TreeSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetImportProgress"); err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetImportProgressResponse{
+		IntegratedSize: int64(root.TreeSize),
+		AddedSize:      added,
+		SignedLogRoot:  slr,
+	}, nil
+}
+
+// addedSize returns the number of leaves added contiguously from index 0 to the pre-ordered
+// log with the given ID. Leaves are never removed from a log, so the scan starts from the
+// larger of the tree size and the size found by previous calls.
+func (t *TrillianLogRPCServer) addedSize(ctx context.Context, tx storage.ReadOnlyLogTreeTX, treeID, treeSize int64) (int64, error) {
+	t.addedSizesMu.Lock()
+	size := t.addedSizes[treeID]
+	t.addedSizesMu.Unlock()
+	if size < treeSize {
+		size = treeSize
+	}
+
+	for {
+		leaves, err := tx.GetLeavesByRange(ctx, size, importScanBatch)
+		if err != nil {
+			return 0, err
+		}
+		var n int64
+		for _, leaf := range leaves {
+			if leaf.LeafIndex != size+n {
+				break
+			}
+			n++
+		}
+		size += n
+		if n < importScanBatch {
+			break
+		}
+	}
+
+	t.addedSizesMu.Lock()
+	defer t.addedSizesMu.Unlock()
+	if size > t.addedSizes[treeID] {
+		t.addedSizes[treeID] = size
+	}
+	return size, nil
+}
+
 // GetEntryAndProof returns both a Merkle Leaf entry and an inclusion proof for a given index
 // and tree size.
 func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trillian.GetEntryAndProofRequest) (*trillian.GetEntryAndProofResponse, error) {
@@ -530,7 +1270,7 @@ func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trilli
 func (t *TrillianLogRPCServer) commitAndLog(ctx context.Context, logID int64, tx storage.ReadOnlyLogTreeTX, op string) error {
 	err := tx.Commit(ctx)
 	if err != nil {
-		glog.Warningf("%v: Commit failed for %v: %v", logID, op, err)
+		logger.Warn(ctx, "commit failed", "tree_id", logID, "op", op, "err", err)
 	}
 	return err
 }
@@ -538,7 +1278,7 @@ func (t *TrillianLogRPCServer) commitAndLog(ctx context.Context, logID int64, tx
 func (t *TrillianLogRPCServer) closeAndLog(ctx context.Context, logID int64, tx storage.ReadOnlyLogTreeTX, op string) {
 	err := tx.Close()
 	if err != nil {
-		glog.Warningf("%v: Close failed for %v: %v", logID, op, err)
+		logger.Warn(ctx, "close failed", "tree_id", logID, "op", op, "err", err)
 	}
 }
 
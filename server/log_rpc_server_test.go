@@ -15,18 +15,27 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/trillian"
+	"github.com/google/trillian/client"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/server/readcache"
+	"github.com/google/trillian/server/validator"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
 	stestonly "github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/storage/tree"
 	"github.com/google/trillian/types"
@@ -34,10 +43,12 @@ import (
 	"github.com/transparency-dev/merkle/compact"
 	"github.com/transparency-dev/merkle/rfc6962"
 	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // cmpMatcher is a custom gomock.Matcher that uses cmp.Equal combined with a
@@ -266,6 +277,441 @@ func TestGetLeavesByRange(t *testing.T) {
 	}
 }
 
+func TestGetImportProgress(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, stestonly.PreorderedLogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	if _, err := server.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+
+	for _, test := range []struct {
+		desc      string
+		indices   []int64
+		wantAdded int64
+	}{
+		{desc: "empty"},
+		{desc: "contiguous", indices: []int64{0, 1, 2}, wantAdded: 3},
+		{desc: "gap", indices: []int64{4, 5}, wantAdded: 3},
+		{desc: "gap-filled", indices: []int64{3}, wantAdded: 6},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			for _, index := range test.indices {
+				leaf := newTestLeaf([]byte(fmt.Sprintf("value%d", index)), nil, index)
+				req := &trillian.AddSequencedLeavesRequest{LogId: tree.TreeId, Leaves: []*trillian.LogLeaf{leaf}}
+				if _, err := server.AddSequencedLeaves(ctx, req); err != nil {
+					t.Fatalf("AddSequencedLeaves(%d): %v", index, err)
+				}
+			}
+			rsp, err := server.GetImportProgress(ctx, &trillian.GetImportProgressRequest{LogId: tree.TreeId})
+			if err != nil {
+				t.Fatalf("GetImportProgress(): %v", err)
+			}
+			if got, want := rsp.IntegratedSize, int64(0); got != want {
+				t.Errorf("GetImportProgress().IntegratedSize=%d, want %d", got, want)
+			}
+			if got, want := rsp.AddedSize, test.wantAdded; got != want {
+				t.Errorf("GetImportProgress().AddedSize=%d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestGetImportProgressNotPreordered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	registry := extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+		LogStorage:   storage.NewMockLogStorage(ctrl),
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	if _, err := server.GetImportProgress(context.Background(), &trillian.GetImportProgressRequest{LogId: logID1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetImportProgress(): %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestGetCompactRange(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, stestonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	if _, err := server.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+	const size = 13
+	var hashes [][]byte
+	for i := 0; i < size; i++ {
+		leaf := &trillian.LogLeaf{LeafValue: []byte(fmt.Sprintf("value%d", i))}
+		if _, err := server.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: leaf}); err != nil {
+			t.Fatalf("QueueLeaf(%d): %v", i, err)
+		}
+		hashes = append(hashes, th.HashLeaf(leaf.LeafValue))
+	}
+	log.InitMetrics(nil)
+	if n, err := log.IntegrateBatch(ctx, tree, size, 0, 0, clock.System, registry.LogStorage, quota.Noop()); err != nil || n != size {
+		t.Fatalf("IntegrateBatch(): %d, %v, want %d leaves", n, err, size)
+	}
+	rsp, err := server.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: tree.TreeId})
+	if err != nil {
+		t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(rsp.SignedLogRoot.LogRoot); err != nil {
+		t.Fatalf("UnmarshalBinary(): %v", err)
+	}
+
+	getRange := func(begin, end int64) [][]byte {
+		t.Helper()
+		rsp, err := server.GetCompactRange(ctx, &trillian.GetCompactRangeRequest{LogId: tree.TreeId, Begin: begin, End: end, TreeSize: size})
+		if err != nil {
+			t.Fatalf("GetCompactRange(%d, %d): %v", begin, end, err)
+		}
+		return rsp.Hashes
+	}
+	rf := compact.RangeFactory{Hash: th.HashChildren}
+	verifier := client.NewLogVerifier(th)
+	for _, r := range []struct{ begin, end int64 }{{0, 0}, {0, size}, {0, 5}, {3, 11}, {8, 9}, {12, size}} {
+		// The compact range of the leaves matches the one computed from them.
+		want := rf.NewEmptyRange(uint64(r.begin))
+		for _, hash := range hashes[r.begin:r.end] {
+			if err := want.Append(hash, nil); err != nil {
+				t.Fatalf("Append(): %v", err)
+			}
+		}
+		if got := getRange(r.begin, r.end); !cmp.Equal(got, want.Hashes(), cmpopts.EquateEmpty()) {
+			t.Errorf("GetCompactRange(%d, %d): %x, want %x", r.begin, r.end, got, want.Hashes())
+		}
+		// The leaves verify against the root with the ranges on either side.
+		if err := verifier.VerifyLeafRange(&root, uint64(r.begin), hashes[r.begin:r.end], getRange(0, r.begin), getRange(r.end, size)); err != nil {
+			t.Errorf("VerifyLeafRange(%d, %d): %v", r.begin, r.end, err)
+		}
+	}
+
+	// A tree size the server hasn't reached returns the root without hashes.
+	ahead, err := server.GetCompactRange(ctx, &trillian.GetCompactRangeRequest{LogId: tree.TreeId, Begin: 0, End: 1, TreeSize: size + 1})
+	if err != nil {
+		t.Fatalf("GetCompactRange(ahead): %v", err)
+	}
+	if len(ahead.Hashes) != 0 || ahead.SignedLogRoot == nil {
+		t.Errorf("GetCompactRange(ahead): %v, want root without hashes", ahead)
+	}
+
+	for _, req := range []*trillian.GetCompactRangeRequest{
+		{LogId: tree.TreeId, Begin: -1, End: 1, TreeSize: size},
+		{LogId: tree.TreeId, Begin: 2, End: 1, TreeSize: size},
+		{LogId: tree.TreeId, Begin: 0, End: 5, TreeSize: 4},
+	} {
+		if _, err := server.GetCompactRange(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetCompactRange(%v): %v, want %v", req, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestGetLeavesByHash(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, stestonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	if _, err := server.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+	// Values a and b are logged several times, with distinct identity hashes.
+	values := []string{"a", "b", "a", "c", "a", "b"}
+	for i, value := range values {
+		id := th.HashLeaf([]byte(fmt.Sprintf("id%d", i)))
+		leaf := &trillian.LogLeaf{LeafValue: []byte(value), LeafIdentityHash: id}
+		if _, err := server.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: leaf}); err != nil {
+			t.Fatalf("QueueLeaf(%d): %v", i, err)
+		}
+	}
+	log.InitMetrics(nil)
+	if n, err := log.IntegrateBatch(ctx, tree, len(values), 0, 0, clock.System, registry.LogStorage, quota.Noop()); err != nil || n != len(values) {
+		t.Fatalf("IntegrateBatch(): %d, %v, want %d leaves", n, err, len(values))
+	}
+	hashA, hashB := th.HashLeaf([]byte("a")), th.HashLeaf([]byte("b"))
+	verifier := client.NewLogVerifier(th)
+	// The root of the tree of size 4, to which proofs are requested below.
+	r4 := (&compact.RangeFactory{Hash: th.HashChildren}).NewEmptyRange(0)
+	for _, value := range values[:4] {
+		if err := r4.Append(th.HashLeaf([]byte(value)), nil); err != nil {
+			t.Fatalf("Append(): %v", err)
+		}
+	}
+	rootHash4, err := r4.GetRootHash(nil)
+	if err != nil {
+		t.Fatalf("GetRootHash(): %v", err)
+	}
+	root4 := &types.LogRootV1{TreeSize: 4, RootHash: rootHash4}
+
+	for _, test := range []struct {
+		desc        string
+		req         *trillian.GetLeavesByHashRequest
+		wantIndices [][]int64
+		wantProofs  bool
+	}{
+		{
+			desc:        "all",
+			req:         &trillian.GetLeavesByHashRequest{LeafHash: [][]byte{hashB, hashA}},
+			wantIndices: [][]int64{{0, 1, 2, 4, 5}},
+		},
+		{
+			desc:        "pages",
+			req:         &trillian.GetLeavesByHashRequest{LeafHash: [][]byte{hashA, hashB, hashA}, PageSize: 2},
+			wantIndices: [][]int64{{0, 1}, {2, 4}, {5}},
+		},
+		{
+			desc:        "proofs",
+			req:         &trillian.GetLeavesByHashRequest{LeafHash: [][]byte{hashA}, TreeSize: 4, IncludeProofs: true},
+			wantIndices: [][]int64{{0, 2}},
+			wantProofs:  true,
+		},
+		{
+			desc:        "unknown",
+			req:         &trillian.GetLeavesByHashRequest{LeafHash: [][]byte{th.HashLeaf([]byte("d"))}},
+			wantIndices: [][]int64{nil},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			req := proto.Clone(test.req).(*trillian.GetLeavesByHashRequest)
+			req.LogId = tree.TreeId
+			for page, want := range test.wantIndices {
+				rsp, err := server.GetLeavesByHash(ctx, req)
+				if err != nil {
+					t.Fatalf("GetLeavesByHash(): %v", err)
+				}
+				var got []int64
+				for _, leaf := range rsp.Leaves {
+					got = append(got, leaf.LeafIndex)
+				}
+				if !cmp.Equal(got, want) {
+					t.Errorf("page %d: leaf indices %v, want %v", page, got, want)
+				}
+				if test.wantProofs {
+					if len(rsp.Proofs) != len(rsp.Leaves) {
+						t.Fatalf("page %d: %d proofs for %d leaves", page, len(rsp.Proofs), len(rsp.Leaves))
+					}
+					for i, leaf := range rsp.Leaves {
+						if err := verifier.VerifyInclusionByHash(root4, leaf.MerkleLeafHash, rsp.Proofs[i]); err != nil {
+							t.Errorf("page %d: VerifyInclusionByHash(%d): %v", page, leaf.LeafIndex, err)
+						}
+					}
+				} else if len(rsp.Proofs) != 0 {
+					t.Errorf("page %d: %d proofs, want none", page, len(rsp.Proofs))
+				}
+				if last := page == len(test.wantIndices)-1; last != (rsp.NextPageToken == "") {
+					t.Errorf("page %d: NextPageToken %q, last page: %v", page, rsp.NextPageToken, last)
+				}
+				req.PageToken = rsp.NextPageToken
+			}
+		})
+	}
+
+	ahead, err := server.GetLeavesByHash(ctx, &trillian.GetLeavesByHashRequest{LogId: tree.TreeId, LeafHash: [][]byte{hashA}, TreeSize: 100})
+	if err != nil {
+		t.Fatalf("GetLeavesByHash(ahead): %v", err)
+	}
+	if len(ahead.Leaves) != 0 || ahead.SignedLogRoot == nil {
+		t.Errorf("GetLeavesByHash(ahead): %v, want root without leaves", ahead)
+	}
+	tooMany := make([][]byte, maxLeavesByHashCount+1)
+	for i := range tooMany {
+		tooMany[i] = hashA
+	}
+	for _, req := range []*trillian.GetLeavesByHashRequest{
+		{LogId: tree.TreeId},
+		{LogId: tree.TreeId, LeafHash: [][]byte{[]byte("short")}},
+		{LogId: tree.TreeId, LeafHash: [][]byte{hashA}, PageSize: -1},
+		{LogId: tree.TreeId, LeafHash: [][]byte{hashA}, PageToken: "x"},
+		{LogId: tree.TreeId, LeafHash: tooMany},
+	} {
+		if _, err := server.GetLeavesByHash(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetLeavesByHash(%v): %v, want %v", req, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestGetLeavesByIndexList(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, stestonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	if _, err := server.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+	const size = 10
+	for i := 0; i < size; i++ {
+		leaf := &trillian.LogLeaf{LeafValue: []byte(fmt.Sprintf("value%d", i))}
+		if _, err := server.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: leaf}); err != nil {
+			t.Fatalf("QueueLeaf(%d): %v", i, err)
+		}
+	}
+	log.InitMetrics(nil)
+	if n, err := log.IntegrateBatch(ctx, tree, size, 0, 0, clock.System, registry.LogStorage, quota.Noop()); err != nil || n != size {
+		t.Fatalf("IntegrateBatch(): %d, %v, want %d leaves", n, err, size)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		indices []int64
+		want    []int64
+	}{
+		{desc: "single", indices: []int64{3}, want: []int64{3}},
+		{desc: "sparse", indices: []int64{8, 1, 5, 2}, want: []int64{1, 2, 5, 8}},
+		{desc: "duplicates", indices: []int64{4, 0, 4, 0}, want: []int64{0, 4}},
+		{desc: "beyond", indices: []int64{9, size, 100}, want: []int64{9}},
+		{desc: "all beyond", indices: []int64{size}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rsp, err := server.GetLeavesByIndexList(ctx, &trillian.GetLeavesByIndexListRequest{LogId: tree.TreeId, LeafIndex: test.indices})
+			if err != nil {
+				t.Fatalf("GetLeavesByIndexList(): %v", err)
+			}
+			var got []int64
+			for _, leaf := range rsp.Leaves {
+				got = append(got, leaf.LeafIndex)
+				if want := fmt.Sprintf("value%d", leaf.LeafIndex); string(leaf.LeafValue) != want {
+					t.Errorf("leaf %d: value %q, want %q", leaf.LeafIndex, leaf.LeafValue, want)
+				}
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("leaf indices %v, want %v", got, test.want)
+			}
+			if rsp.SignedLogRoot == nil {
+				t.Error("SignedLogRoot missing")
+			}
+		})
+	}
+
+	for _, req := range []*trillian.GetLeavesByIndexListRequest{
+		{LogId: tree.TreeId},
+		{LogId: tree.TreeId, LeafIndex: []int64{1, -1}},
+		{LogId: tree.TreeId, LeafIndex: make([]int64, maxLeavesByIndexCount+1)},
+	} {
+		if _, err := server.GetLeavesByIndexList(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetLeavesByIndexList(%d indices): %v, want %v", len(req.LeafIndex), err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestGetSignedLogRootAtTime(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, stestonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	if _, err := server.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+	// The log grows to 2 leaves an hour after fakeTime, and to 5 leaves an
+	// hour later.
+	log.InitMetrics(nil)
+	prev := 0
+	for i, size := range []int{2, 5} {
+		for j := prev; j < size; j++ {
+			leaf := &trillian.LogLeaf{LeafValue: []byte(fmt.Sprintf("value%d", j))}
+			if _, err := server.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: leaf}); err != nil {
+				t.Fatalf("QueueLeaf(%d): %v", j, err)
+			}
+		}
+		now := clock.NewFake(fakeTime.Add(time.Duration(i+1) * time.Hour))
+		if n, err := log.IntegrateBatch(ctx, tree, size, 0, 0, now, registry.LogStorage, quota.Noop()); err != nil || n != size-prev {
+			t.Fatalf("IntegrateBatch(): %d, %v, want %d leaves", n, err, size-prev)
+		}
+		prev = size
+	}
+	verifier := client.NewLogVerifier(th)
+
+	for _, test := range []struct {
+		desc     string
+		at       time.Time
+		wantSize uint64
+		wantErr  codes.Code
+	}{
+		{desc: "before", at: fakeTime.Add(-time.Second), wantErr: codes.NotFound},
+		{desc: "init", at: fakeTime.Add(30 * time.Minute), wantSize: 0},
+		{desc: "exact", at: fakeTime.Add(time.Hour), wantSize: 2},
+		{desc: "between", at: fakeTime.Add(90 * time.Minute), wantSize: 2},
+		{desc: "latest", at: fakeTime.Add(24 * time.Hour), wantSize: 5},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rsp, err := server.GetSignedLogRootAtTime(ctx, &trillian.GetSignedLogRootAtTimeRequest{LogId: tree.TreeId, Timestamp: timestamppb.New(test.at)})
+			if got := status.Code(err); got != test.wantErr {
+				t.Fatalf("GetSignedLogRootAtTime(): %v, want code %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var root, latest types.LogRootV1
+			if err := root.UnmarshalBinary(rsp.SignedLogRoot.LogRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if err := latest.UnmarshalBinary(rsp.LatestSignedLogRoot.LogRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(latest): %v", err)
+			}
+			if root.TreeSize != test.wantSize || root.TimestampNanos > uint64(test.at.UnixNano()) {
+				t.Errorf("root of size %d at %d, want size %d at or before %d", root.TreeSize, root.TimestampNanos, test.wantSize, test.at.UnixNano())
+			}
+			if latest.TreeSize != 5 {
+				t.Errorf("latest root of size %d, want 5", latest.TreeSize)
+			}
+			if root.TreeSize == 0 || root.TreeSize == latest.TreeSize {
+				if rsp.Proof != nil {
+					t.Errorf("proof %v, want none", rsp.Proof)
+				}
+				return
+			}
+			if _, err := verifier.VerifyRoot(&root, rsp.LatestSignedLogRoot, rsp.Proof.GetHashes()); err != nil {
+				t.Errorf("VerifyRoot(): %v", err)
+			}
+		})
+	}
+
+	for _, req := range []*trillian.GetSignedLogRootAtTimeRequest{
+		{LogId: tree.TreeId},
+		{LogId: tree.TreeId, Timestamp: timestamppb.New(time.Unix(-1, 0))},
+	} {
+		if _, err := server.GetSignedLogRootAtTime(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetSignedLogRootAtTime(%v): %v, want %v", req, err, codes.InvalidArgument)
+		}
+	}
+}
+
 func TestQueueLeafStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -361,6 +807,327 @@ func TestQueueLeaf(t *testing.T) {
 	}
 }
 
+// asyncLogStorage is a storage.LogStorage implementing storage.AsyncLeafQueuer, which records
+// the leaves queued asynchronously.
+type asyncLogStorage struct {
+	storage.LogStorage
+	queued []*trillian.LogLeaf
+}
+
+func (s *asyncLogStorage) QueueLeavesAsync(_ context.Context, _ *trillian.Tree, leaves []*trillian.LogLeaf, _ time.Time) error {
+	s.queued = append(s.queued, leaves...)
+	return nil
+}
+
+func TestQueueLeafAsync(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	// Synchronous requests, and requests to storage without support for asynchronous ones,
+	// look for duplicates as usual.
+	mockStorage.EXPECT().QueueLeaves(gomock.Any(), cmpMatcher{tree1}, gomock.Any(), fakeTime).Times(2).Return([]*trillian.QueuedLogLeaf{dupeQueuedLeaf(leaf1)}, nil)
+	async := &asyncLogStorage{LogStorage: mockStorage}
+
+	for _, tc := range []struct {
+		name       string
+		storage    storage.LogStorage
+		async      bool
+		wantStatus codes.Code
+	}{
+		{name: "async", storage: async, async: true, wantStatus: codes.OK},
+		{name: "sync", storage: async, wantStatus: codes.AlreadyExists},
+		{name: "unsupported", storage: mockStorage, async: true, wantStatus: codes.AlreadyExists},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+				LogStorage:   tc.storage,
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+			req := &trillian.QueueLeafRequest{LogId: logID1, Leaf: proto.Clone(leaf1).(*trillian.LogLeaf), Async: tc.async}
+			rsp, err := server.QueueLeaf(ctx, req)
+			if err != nil {
+				t.Fatalf("QueueLeaf() = %v", err)
+			}
+			if got := status.FromProto(rsp.QueuedLeaf.Status).Code(); got != tc.wantStatus {
+				t.Errorf("QueueLeaf().Status=%v, want %v", got, tc.wantStatus)
+			}
+		})
+	}
+	if got, want := len(async.queued), 1; got != want {
+		t.Fatalf("Queued %d leaves asynchronously, want %d", got, want)
+	}
+	want := proto.Clone(leaf1).(*trillian.LogLeaf)
+	want.QueueTimestamp = timestamppb.New(fakeTime)
+	if got := async.queued[0]; !proto.Equal(got, want) {
+		t.Errorf("Queued leaf %v, want %v", got, want)
+	}
+}
+
+// fakeQueueLeavesStream is a trillian.TrillianLog_QueueLeavesServer which receives reqs, and
+// records the responses sent on it.
+type fakeQueueLeavesStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	reqs  []*trillian.QueueLeavesRequest
+	resps []*trillian.QueueLeavesResponse
+}
+
+func (s *fakeQueueLeavesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeQueueLeavesStream) Recv() (*trillian.QueueLeavesRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeQueueLeavesStream) Send(resp *trillian.QueueLeavesResponse) error {
+	s.resps = append(s.resps, resp)
+	return nil
+}
+
+func TestQueueLeaves(t *testing.T) {
+	defer func(size int, interval time.Duration) {
+		QueueLeavesBatchSize, QueueLeavesFlushInterval = size, interval
+	}(QueueLeavesBatchSize, QueueLeavesFlushInterval)
+	// Only flush when the batch is full or the stream ends, so that the batches are predictable.
+	QueueLeavesFlushInterval = time.Hour
+
+	l1 := proto.Clone(leaf1).(*trillian.LogLeaf)
+	l2 := proto.Clone(leaf2).(*trillian.LogLeaf)
+	invalid := &trillian.LogLeaf{ExtraData: []byte("no value")}
+	reqs := []*trillian.QueueLeavesRequest{
+		{LogId: logID1, Leaves: []*trillian.LogLeaf{l1, invalid}},
+		{LogId: logID1, Leaves: []*trillian.LogLeaf{l2}},
+	}
+
+	for _, test := range []struct {
+		desc      string
+		batchSize int
+		batches   [][]*trillian.LogLeaf
+		want      [][]codes.Code
+	}{
+		{
+			desc:      "one-batch",
+			batchSize: 10,
+			batches:   [][]*trillian.LogLeaf{{l1, l2}},
+			want:      [][]codes.Code{{codes.OK, codes.InvalidArgument, codes.AlreadyExists}},
+		},
+		{
+			desc:      "full-batches",
+			batchSize: 2,
+			batches:   [][]*trillian.LogLeaf{{l1}, {l2}},
+			want:      [][]codes.Code{{codes.OK, codes.InvalidArgument}, {codes.AlreadyExists}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			QueueLeavesBatchSize = test.batchSize
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			var prev *gomock.Call
+			for _, batch := range test.batches {
+				var queued []*trillian.QueuedLogLeaf
+				for _, leaf := range batch {
+					if leaf == l2 {
+						queued = append(queued, dupeQueuedLeaf(leaf))
+					} else {
+						queued = append(queued, okQueuedLeaf(leaf))
+					}
+				}
+				call := mockStorage.EXPECT().QueueLeaves(gomock.Any(), cmpMatcher{tree1}, cmpMatcher{batch}, fakeTime).Return(queued, nil)
+				if prev != nil {
+					call.After(prev)
+				}
+				prev = call
+			}
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+				LogStorage:   mockStorage,
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+			stream := &fakeQueueLeavesStream{ctx: context.Background(), reqs: append([]*trillian.QueueLeavesRequest(nil), reqs...)}
+			if err := server.QueueLeaves(stream); err != nil {
+				t.Fatalf("QueueLeaves(): %v", err)
+			}
+			var got [][]codes.Code
+			for _, resp := range stream.resps {
+				var leafCodes []codes.Code
+				for _, leaf := range resp.QueuedLeaves {
+					leafCodes = append(leafCodes, status.FromProto(leaf.Status).Code())
+				}
+				got = append(got, leafCodes)
+			}
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("QueueLeaves() statuses diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeStreamLeavesStream is a trillian.TrillianLog_StreamLeavesServer which passes the
+// responses sent on it to resps.
+type fakeStreamLeavesStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps chan *trillian.StreamLeavesResponse
+}
+
+func (s *fakeStreamLeavesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStreamLeavesStream) Send(resp *trillian.StreamLeavesResponse) error {
+	s.resps <- resp
+	return nil
+}
+
+func TestStreamLeaves(t *testing.T) {
+	defer func(size int64, interval time.Duration) {
+		StreamLeavesBatchSize, StreamLeavesPollInterval = size, interval
+	}(StreamLeavesBatchSize, StreamLeavesPollInterval)
+	StreamLeavesBatchSize = 2
+	StreamLeavesPollInterval = time.Millisecond
+
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+	}
+	tree, err := storage.CreateTree(ctx, registry.AdminStorage, stestonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	server := NewTrillianLogRPCServer(registry, clock.System)
+	if _, err := server.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId}); err != nil {
+		t.Fatalf("InitLog(): %v", err)
+	}
+	log.InitMetrics(nil)
+	size := 0
+	grow := func(n int) {
+		t.Helper()
+		for i := size; i < size+n; i++ {
+			leaf := &trillian.LogLeaf{LeafValue: []byte(fmt.Sprintf("value%d", i))}
+			if _, err := server.QueueLeaf(ctx, &trillian.QueueLeafRequest{LogId: tree.TreeId, Leaf: leaf}); err != nil {
+				t.Fatalf("QueueLeaf(%d): %v", i, err)
+			}
+		}
+		if got, err := log.IntegrateBatch(ctx, tree, n, 0, 0, clock.System, registry.LogStorage, quota.Noop()); err != nil || got != n {
+			t.Fatalf("IntegrateBatch(): %d, %v, want %d leaves", got, err, n)
+		}
+		size += n
+	}
+	grow(5)
+
+	// stream streams the leaves of req until the response resuming at index end.
+	stream := func(req *trillian.StreamLeavesRequest, end int64, onIdle func()) []int64 {
+		t.Helper()
+		ctx, cancel := context.WithCancel(ctx)
+		s := &fakeStreamLeavesStream{ctx: ctx, resps: make(chan *trillian.StreamLeavesResponse)}
+		errc := make(chan error, 1)
+		go func() { errc <- server.StreamLeaves(req, s) }()
+		var got []int64
+		for {
+			select {
+			case resp := <-s.resps:
+				var root types.LogRootV1
+				if err := root.UnmarshalBinary(resp.SignedLogRoot.GetLogRoot()); err != nil {
+					t.Fatalf("UnmarshalBinary(): %v", err)
+				}
+				var next int64
+				for _, leaf := range resp.Leaves {
+					got = append(got, leaf.LeafIndex)
+					if uint64(leaf.LeafIndex) >= root.TreeSize {
+						t.Errorf("leaf %d beyond root of size %d", leaf.LeafIndex, root.TreeSize)
+					}
+					next = leaf.LeafIndex + 1
+				}
+				if want := fmt.Sprintf("%d:%d", tree.TreeId, next); resp.ResumeToken != want {
+					t.Errorf("ResumeToken = %q, want %q", resp.ResumeToken, want)
+				}
+				if next == int64(size) && onIdle != nil {
+					onIdle()
+				}
+				if next < end {
+					continue
+				}
+				cancel()
+				if err := <-errc; status.Code(err) != codes.Canceled {
+					t.Errorf("StreamLeaves(): %v, want code %v", err, codes.Canceled)
+				}
+				return got
+			case err := <-errc:
+				cancel()
+				t.Fatalf("StreamLeaves(): %v", err)
+			}
+		}
+	}
+
+	// The stream catches up, and then tails the log as it grows.
+	got := stream(&trillian.StreamLeavesRequest{LogId: tree.TreeId, StartIndex: 1}, 8, func() {
+		if size < 8 {
+			grow(3)
+		}
+	})
+	if diff := cmp.Diff([]int64{1, 2, 3, 4, 5, 6, 7}, got); diff != "" {
+		t.Errorf("StreamLeaves() leaf indices diff (-want +got):\n%s", diff)
+	}
+	got = stream(&trillian.StreamLeavesRequest{LogId: tree.TreeId, StartIndex: 100, ResumeToken: fmt.Sprintf("%d:6", tree.TreeId)}, 8, nil)
+	if diff := cmp.Diff([]int64{6, 7}, got); diff != "" {
+		t.Errorf("StreamLeaves(resumed) leaf indices diff (-want +got):\n%s", diff)
+	}
+
+	// Streams end once they exceed their maximum duration, and are resumed by clients.
+	defer func(d time.Duration) { StreamLeavesMaxDuration = d }(StreamLeavesMaxDuration)
+	StreamLeavesMaxDuration = 20 * time.Millisecond
+	s := &fakeStreamLeavesStream{ctx: ctx, resps: make(chan *trillian.StreamLeavesResponse, 10)}
+	if err := server.StreamLeaves(&trillian.StreamLeavesRequest{LogId: tree.TreeId, StartIndex: int64(size)}, s); status.Code(err) != codes.Unavailable {
+		t.Errorf("StreamLeaves(past max duration): %v, want code %v", err, codes.Unavailable)
+	}
+
+	for _, req := range []*trillian.StreamLeavesRequest{
+		{LogId: tree.TreeId, StartIndex: -1},
+		{LogId: tree.TreeId, ResumeToken: "6"},
+		{LogId: tree.TreeId, ResumeToken: fmt.Sprintf("%d:-1", tree.TreeId)},
+		{LogId: tree.TreeId, ResumeToken: fmt.Sprintf("%d:6x", tree.TreeId)},
+		{LogId: tree.TreeId, ResumeToken: fmt.Sprintf("%d:6", tree.TreeId+1)},
+	} {
+		s := &fakeStreamLeavesStream{ctx: ctx, resps: make(chan *trillian.StreamLeavesResponse, 10)}
+		if err := server.StreamLeaves(req, s); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("StreamLeaves(%v): %v, want code %v", req, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestQueueLeavesMixedLogIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	registry := extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+		LogStorage:   storage.NewMockLogStorage(ctrl),
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+	stream := &fakeQueueLeavesStream{ctx: context.Background(), reqs: []*trillian.QueueLeavesRequest{
+		{LogId: logID1},
+		{LogId: logID2},
+	}}
+	if err := server.QueueLeaves(stream); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("QueueLeaves(): %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
 func TestAddSequencedLeavesStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -424,6 +1191,101 @@ func TestAddSequencedLeaves(t *testing.T) {
 	}
 }
 
+func TestLeafValidator(t *testing.T) {
+	ctx := context.Background()
+	errInvalid := errors.New("invalid leaf")
+	rejectAll := validator.LeafValidatorFunc(func(context.Context, *trillian.Tree, *trillian.LogLeaf) error {
+		return errInvalid
+	})
+	rejectPermission := validator.LeafValidatorFunc(func(context.Context, *trillian.Tree, *trillian.LogLeaf) error {
+		return status.Error(codes.PermissionDenied, "not allowed")
+	})
+
+	for _, test := range []struct {
+		desc     string
+		v        validator.LeafValidator
+		wantCode codes.Code
+	}{
+		{desc: "rejected", v: rejectAll, wantCode: codes.InvalidArgument},
+		{desc: "rejectedWithStatus", v: rejectPermission, wantCode: codes.PermissionDenied},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// No storage calls are expected, leaves are rejected before reaching storage.
+			mockStorage := storage.NewMockLogStorage(ctrl)
+
+			queueServer := NewTrillianLogRPCServer(extension.Registry{
+				AdminStorage:  fakeAdminStorage(ctrl, storageParams{treeID: queueRequest0.LogId, numSnapshots: 1}),
+				LogStorage:    mockStorage,
+				LeafValidator: test.v,
+			}, fakeTimeSource)
+			_, err := queueServer.QueueLeaf(ctx, &queueRequest0)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("QueueLeaf(): %v, want code %v", err, test.wantCode)
+			}
+
+			addServer := NewTrillianLogRPCServer(extension.Registry{
+				AdminStorage:  fakeAdminStorage(ctrl, storageParams{treeID: addSeqRequest0.LogId, preordered: true, numSnapshots: 1}),
+				LogStorage:    mockStorage,
+				LeafValidator: test.v,
+			}, fakeTimeSource)
+			_, err = addServer.AddSequencedLeaves(ctx, &addSeqRequest0)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("AddSequencedLeaves(): %v, want code %v", err, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestHashLeaves(t *testing.T) {
+	identityHash := []byte("identity")
+	leafHash := th.HashLeaf([]byte("value"))
+
+	for _, test := range []struct {
+		desc     string
+		policy   trillian.LeafDedupPolicy
+		identity []byte
+		want     []byte // nil means a random identity hash is expected.
+		wantErr  bool
+	}{
+		{desc: "identityDefault", policy: trillian.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH, want: leafHash},
+		{desc: "identitySet", policy: trillian.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH, identity: identityHash, want: identityHash},
+		{desc: "leafValueDefault", policy: trillian.LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH, want: leafHash},
+		{desc: "leafValueOverride", policy: trillian.LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH, identity: identityHash, want: leafHash},
+		{desc: "noDedup", policy: trillian.LeafDedupPolicy_NO_DEDUP, identity: identityHash},
+		{desc: "unknown", policy: trillian.LeafDedupPolicy(-1), wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			tree := &trillian.Tree{TreeId: logID1, LeafDedupPolicy: test.policy}
+			leaves := []*trillian.LogLeaf{
+				{LeafValue: []byte("value"), LeafIdentityHash: test.identity},
+				{LeafValue: []byte("value"), LeafIdentityHash: test.identity},
+			}
+			err := hashLeaves(tree, leaves, th)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("hashLeaves(): %v, wantErr %v", err, test.wantErr)
+			} else if gotErr {
+				return
+			}
+			for i, leaf := range leaves {
+				if !bytes.Equal(leaf.MerkleLeafHash, leafHash) {
+					t.Errorf("leaves[%d].MerkleLeafHash=%x, want %x", i, leaf.MerkleLeafHash, leafHash)
+				}
+				if test.want != nil && !bytes.Equal(leaf.LeafIdentityHash, test.want) {
+					t.Errorf("leaves[%d].LeafIdentityHash=%x, want %x", i, leaf.LeafIdentityHash, test.want)
+				}
+			}
+			if test.want == nil {
+				if bytes.Equal(leaves[0].LeafIdentityHash, leaves[1].LeafIdentityHash) || bytes.Equal(leaves[0].LeafIdentityHash, test.identity) {
+					t.Errorf("LeafIdentityHash not unique: %x, %x", leaves[0].LeafIdentityHash, leaves[1].LeafIdentityHash)
+				}
+			}
+		})
+	}
+}
+
 type latestRootTest struct {
 	desc        string
 	req         *trillian.GetLatestSignedLogRootRequest
@@ -953,6 +1815,45 @@ func TestGetProofByIndex(t *testing.T) {
 	}
 }
 
+func TestGetProofByIndexReadCache(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage := storage.NewMockLogStorage(ctrl)
+	tx := storage.NewMockLogTreeTX(ctrl)
+	// Storage is read by the first request, and after the root is invalidated.
+	fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), cmpMatcher{tree1}).Times(2).Return(tx, nil)
+	tx.EXPECT().LatestSignedLogRoot(gomock.Any()).Times(2).Return(signedRoot1, nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), nodeIdsInclusionSize7Index2).Times(2).Return([]tree.Node{
+		{ID: nodeIdsInclusionSize7Index2[0], Hash: []byte("nodehash0")},
+		{ID: nodeIdsInclusionSize7Index2[1], Hash: []byte("nodehash1")},
+		{ID: nodeIdsInclusionSize7Index2[2], Hash: []byte("nodehash2")},
+		{ID: nodeIdsInclusionSize7Index2[3], Hash: []byte("nodehash3")},
+	}, nil)
+	tx.EXPECT().Commit(gomock.Any()).Times(2).Return(nil)
+	tx.EXPECT().Close().Times(2).Return(nil)
+
+	rc := readcache.New(readcache.NewMemoryStore(readcache.DefaultMemoryMaxBytes), readcache.Options{RootTTL: time.Hour}, nil)
+	registry := extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 3}),
+		LogStorage:   fakeStorage,
+		ReadCache:    rc,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	want, err := server.GetInclusionProof(ctx, &getInclusionProofByIndexRequest7)
+	if err != nil {
+		t.Fatalf("GetInclusionProof() = %v", err)
+	}
+	if got, err := server.GetInclusionProof(ctx, &getInclusionProofByIndexRequest7); err != nil || !proto.Equal(got, want) {
+		t.Errorf("GetInclusionProof() from cache = %v, %v, want %v, nil", got, err, want)
+	}
+	rc.InvalidateRoot(ctx, logID1)
+	if got, err := server.GetInclusionProof(ctx, &getInclusionProofByIndexRequest7); err != nil || !proto.Equal(got, want) {
+		t.Errorf("GetInclusionProof() after InvalidateRoot() = %v, %v, want %v, nil", got, err, want)
+	}
+}
+
 func TestGetEntryAndProof(t *testing.T) {
 	for _, tc := range []struct {
 		name         string
@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+)
+
+// maxBigQueryRows is the maximum number of rows inserted per request, below
+// the limit recommended by BigQuery.
+const maxBigQueryRows = 500
+
+// BigQueryExporter streams usage into a BigQuery table, one row per tree and
+// tenant of each window. The table must have the columns window_start and
+// window_end of type TIMESTAMP, source and tenant of type STRING, and tree_id,
+// queued_leaves, queued_bytes, sequenced_leaves and sequenced_bytes of type
+// INTEGER. Rows are given insert IDs, so that BigQuery drops those inserted
+// again after failures.
+type BigQueryExporter struct {
+	svc                       *bigquery.Service
+	project, dataset, tableID string
+}
+
+// NewBigQueryExporter returns a BigQueryExporter inserting into table, as
+// project.dataset.table, with the application default credentials unless
+// opts say otherwise.
+func NewBigQueryExporter(ctx context.Context, table string, opts ...option.ClientOption) (*BigQueryExporter, error) {
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("BigQuery table %q isn't project.dataset.table", table)
+	}
+	svc, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %v", err)
+	}
+	return &BigQueryExporter{svc: svc, project: parts[0], dataset: parts[1], tableID: parts[2]}, nil
+}
+
+// Export implements Exporter.
+func (e *BigQueryExporter) Export(ctx context.Context, usage []Usage) error {
+	for len(usage) > 0 {
+		n := len(usage)
+		if n > maxBigQueryRows {
+			n = maxBigQueryRows
+		}
+		req := &bigquery.TableDataInsertAllRequest{}
+		for _, u := range usage[:n] {
+			req.Rows = append(req.Rows, &bigquery.TableDataInsertAllRequestRows{
+				InsertId: fmt.Sprintf("%d/%s/%d/%s", u.WindowStart.UnixNano(), u.Source, u.TreeID, u.Tenant),
+				Json: map[string]bigquery.JsonValue{
+					"window_start":     u.WindowStart.UTC().Format(time.RFC3339Nano),
+					"window_end":       u.WindowEnd.UTC().Format(time.RFC3339Nano),
+					"source":           u.Source,
+					"tree_id":          u.TreeID,
+					"tenant":           u.Tenant,
+					"queued_leaves":    u.QueuedLeaves,
+					"queued_bytes":     u.QueuedBytes,
+					"sequenced_leaves": u.SequencedLeaves,
+					"sequenced_bytes":  u.SequencedBytes,
+				},
+			})
+		}
+		resp, err := e.svc.Tabledata.InsertAll(e.project, e.dataset, e.tableID, req).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to insert usage into BigQuery: %v", err)
+		}
+		if len(resp.InsertErrors) > 0 {
+			ie := resp.InsertErrors[0]
+			msg := "unknown error"
+			if len(ie.Errors) > 0 {
+				msg = ie.Errors[0].Message
+			}
+			// Rows which were inserted are dropped as duplicates on retry.
+			return fmt.Errorf("failed to insert %d usage rows into BigQuery, e.g. row %d: %s", len(resp.InsertErrors), ie.Index, msg)
+		}
+		usage = usage[n:]
+	}
+	return nil
+}
+
+// Close implements Exporter.
+func (e *BigQueryExporter) Close() error {
+	return nil
+}
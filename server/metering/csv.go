@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns of the rows written by CSVExporter.
+var csvHeader = []string{"window_start", "window_end", "source", "tree_id", "tenant", "queued_leaves", "queued_bytes", "sequenced_leaves", "sequenced_bytes"}
+
+// CSVExporter appends usage to a CSV file, one row per tree and tenant of each
+// window. Times are formatted as RFC 3339.
+type CSVExporter struct {
+	f *os.File
+}
+
+// NewCSVExporter returns a CSVExporter appending to the file at path, which is
+// created with a header row if it doesn't exist.
+func NewCSVExporter(path string) (*CSVExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metering file: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open metering file: %v", err)
+	}
+	if fi.Size() == 0 {
+		w := csv.NewWriter(f)
+		w.Write(csvHeader)
+		if w.Flush(); w.Error() != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write metering file header: %v", w.Error())
+		}
+	}
+	return &CSVExporter{f: f}, nil
+}
+
+// Export implements Exporter.
+func (e *CSVExporter) Export(_ context.Context, usage []Usage) error {
+	w := csv.NewWriter(e.f)
+	for _, u := range usage {
+		w.Write([]string{
+			u.WindowStart.UTC().Format(time.RFC3339),
+			u.WindowEnd.UTC().Format(time.RFC3339),
+			u.Source,
+			strconv.FormatInt(u.TreeID, 10),
+			u.Tenant,
+			strconv.FormatInt(u.QueuedLeaves, 10),
+			strconv.FormatInt(u.QueuedBytes, 10),
+			strconv.FormatInt(u.SequencedLeaves, 10),
+			strconv.FormatInt(u.SequencedBytes, 10),
+		})
+	}
+	if w.Flush(); w.Error() != nil {
+		return fmt.Errorf("failed to write metering file: %v", w.Error())
+	}
+	return e.f.Sync()
+}
+
+// Close implements Exporter.
+func (e *CSVExporter) Close() error {
+	return e.f.Close()
+}
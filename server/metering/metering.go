@@ -0,0 +1,389 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metering aggregates the leaves written to logs per tree and tenant
+// over time windows, and exports the totals for billing.
+//
+// Leaves are metered when they're accepted by the log server: QueueLeaf and
+// QueueLeaves requests count as queued leaves, and AddSequencedLeaves requests
+// to pre-ordered logs as sequenced leaves. Leaves rejected as duplicates or
+// invalid aren't metered, except that duplicates aren't detected by async
+// QueueLeaf requests. The size of a leaf is that of its value and extra data.
+//
+// Leaves are attributed to the tenant named by the token which authenticated
+// their request, so callers can't bill others, and requests without a token
+// are metered without a tenant.
+package metering
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// DefaultWindow is the default duration of the windows usage is
+	// aggregated over.
+	DefaultWindow = time.Hour
+
+	// DefaultMaxMetricTenants is the default number of tenants with their own
+	// label in the metering counters.
+	DefaultMaxMetricTenants = 100
+	// DefaultMaxPending is the default number of usage records kept for an
+	// exporter which fails to export them.
+	DefaultMaxPending = 100000
+
+	// otherTenant labels the counters of the tenants beyond MaxMetricTenants.
+	otherTenant = "other"
+
+	// closeTimeout bounds the export of the last window on Close.
+	closeTimeout = 30 * time.Second
+)
+
+var (
+	exports     = flag.String("metering_exports", "", "If set, comma-separated destinations of the leaves written per tree and tenant, which are metered: prometheus (counters exported with the other metrics), csv:<path> (rows appended to a file) or bigquery:<project>.<dataset>.<table>")
+	window      = flag.Duration("metering_window", DefaultWindow, "Duration of the windows the leaves written are aggregated over before being exported to the csv and bigquery --metering_exports")
+	tenantClaim = flag.String("metering_tenant_claim", "", "Claim of the authentication tokens naming the tenant leaves are metered for. If unset, the token subject is used. Requests without a token are metered without a tenant")
+
+	logger = logging.New("metering")
+)
+
+// Usage is the leaves written to a tree on behalf of a tenant during a
+// window, as metered by one server.
+type Usage struct {
+	// WindowStart and WindowEnd bound the window.
+	WindowStart, WindowEnd time.Time
+	// Source identifies the server which metered the leaves, as each server
+	// exports the usage it meters separately.
+	Source string
+	TreeID int64
+	// Tenant is empty for requests which don't identify one.
+	Tenant string
+
+	QueuedLeaves, QueuedBytes       int64
+	SequencedLeaves, SequencedBytes int64
+}
+
+// Exporter writes usage to a billing system.
+type Exporter interface {
+	// Export writes the usage of a closed window. Usage which fails to be
+	// exported is passed again with the next window.
+	Export(ctx context.Context, usage []Usage) error
+	// Close releases the resources of the Exporter.
+	Close() error
+}
+
+// Options configures a Meter.
+type Options struct {
+	// Window is the duration of the windows usage is aggregated over, which
+	// are aligned to multiples of it. DefaultWindow if zero.
+	Window time.Duration
+	// TenantClaim is the claim of the authentication tokens naming the tenant.
+	// The token subject is used if empty.
+	TenantClaim string
+	// MaxMetricTenants bounds the number of tenants with their own label in
+	// the counters of MetricFactory. The leaves of further tenants are counted
+	// under the tenant "other". DefaultMaxMetricTenants if zero.
+	MaxMetricTenants int
+	// MaxPending bounds the number of usage records kept for each exporter
+	// while it fails to export them, beyond which the oldest are dropped.
+	// DefaultMaxPending if zero.
+	MaxPending int
+	// Source identifies the server in the exported usage. The host name if
+	// empty.
+	Source string
+	// Exporters receive the usage of each window.
+	Exporters []Exporter
+	// MetricFactory, if set, provides counters of the leaves and bytes
+	// metered, which are updated as leaves are written.
+	MetricFactory monitoring.MetricFactory
+	// TimeSource is clock.System if nil.
+	TimeSource clock.TimeSource
+}
+
+type usageKey struct {
+	treeID int64
+	tenant string
+}
+
+// sink holds the usage which an Exporter failed to export, until it's
+// retried with the next window.
+type sink struct {
+	exporter Exporter
+	pending  []Usage
+}
+
+// Meter aggregates the leaves written per tree and tenant, and exports them
+// at the end of each window. Run must be called for windows to be exported.
+type Meter struct {
+	opts   Options
+	leaves monitoring.Counter
+	bytes  monitoring.Counter
+
+	mu    sync.Mutex // Guards start, usage and metricTenants.
+	start time.Time
+	usage map[usageKey]*Usage
+	// metricTenants are the tenants with their own counter label.
+	metricTenants map[string]bool
+
+	exportMu sync.Mutex // Serializes exports.
+	sinks    []*sink
+}
+
+// New returns a Meter configured by opts.
+func New(opts Options) *Meter {
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	if opts.Source == "" {
+		opts.Source, _ = os.Hostname()
+	}
+	if opts.MaxMetricTenants <= 0 {
+		opts.MaxMetricTenants = DefaultMaxMetricTenants
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = DefaultMaxPending
+	}
+	m := &Meter{
+		opts:          opts,
+		start:         opts.TimeSource.Now().Truncate(opts.Window),
+		usage:         make(map[usageKey]*Usage),
+		metricTenants: make(map[string]bool),
+	}
+	for _, e := range opts.Exporters {
+		m.sinks = append(m.sinks, &sink{exporter: e})
+	}
+	if mf := opts.MetricFactory; mf != nil {
+		m.leaves = mf.NewCounter("metering_leaves", "Number of leaves written by tree, tenant and stage (queued or sequenced)", monitoring.TreeIDLabel, "tenant", "stage")
+		m.bytes = mf.NewCounter("metering_bytes", "Bytes of leaf values and extra data written by tree, tenant and stage (queued or sequenced)", monitoring.TreeIDLabel, "tenant", "stage")
+	}
+	return m
+}
+
+// NewMeterFromFlags returns a Meter configured by the --metering_* flags, or
+// nil if --metering_exports is unset. Counters are created by mf for the
+// prometheus destination.
+func NewMeterFromFlags(ctx context.Context, mf monitoring.MetricFactory) (*Meter, error) {
+	if *exports == "" {
+		return nil, nil
+	}
+	opts := Options{Window: *window, TenantClaim: *tenantClaim}
+	for _, dest := range strings.Split(*exports, ",") {
+		if dest == "prometheus" {
+			opts.MetricFactory = mf
+			continue
+		}
+		e, err := OpenExporter(ctx, dest)
+		if err != nil {
+			for _, e := range opts.Exporters {
+				e.Close()
+			}
+			return nil, err
+		}
+		opts.Exporters = append(opts.Exporters, e)
+	}
+	return New(opts), nil
+}
+
+// OpenExporter returns the Exporter of dest, which is csv:<path> or
+// bigquery:<project>.<dataset>.<table>.
+func OpenExporter(ctx context.Context, dest string) (Exporter, error) {
+	i := strings.Index(dest, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid metering destination %q", dest)
+	}
+	switch scheme, path := dest[:i], dest[i+1:]; scheme {
+	case "csv":
+		return NewCSVExporter(path)
+	case "bigquery":
+		return NewBigQueryExporter(ctx, path)
+	default:
+		return nil, fmt.Errorf("unknown metering destination %q", dest)
+	}
+}
+
+// RecordQueued meters the leaves queued to a log by the request of ctx. Only
+// the leaves whose status is OK are counted.
+func (m *Meter) RecordQueued(ctx context.Context, treeID int64, leaves []*trillian.QueuedLogLeaf) {
+	count, size := countWritten(leaves)
+	m.record(ctx, treeID, "queued", count, size)
+}
+
+// RecordSequenced meters the leaves added to a pre-ordered log by the request
+// of ctx. Only the leaves whose status is OK are counted.
+func (m *Meter) RecordSequenced(ctx context.Context, treeID int64, leaves []*trillian.QueuedLogLeaf) {
+	count, size := countWritten(leaves)
+	m.record(ctx, treeID, "sequenced", count, size)
+}
+
+// countWritten returns the number and size of the leaves which were written.
+func countWritten(leaves []*trillian.QueuedLogLeaf) (count, size int64) {
+	for _, l := range leaves {
+		if l.GetStatus() != nil && l.GetStatus().GetCode() != int32(codes.OK) {
+			continue
+		}
+		count++
+		size += int64(len(l.GetLeaf().GetLeafValue()) + len(l.GetLeaf().GetExtraData()))
+	}
+	return count, size
+}
+
+func (m *Meter) record(ctx context.Context, treeID int64, stage string, count, size int64) {
+	if count == 0 {
+		return
+	}
+	tenant := m.tenant(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.leaves != nil {
+		label := strconv.FormatInt(treeID, 10)
+		tenantLabel := m.metricTenant(tenant)
+		m.leaves.Add(float64(count), label, tenantLabel, stage)
+		m.bytes.Add(float64(size), label, tenantLabel, stage)
+	}
+	if len(m.sinks) == 0 {
+		return
+	}
+
+	key := usageKey{treeID: treeID, tenant: tenant}
+	u, ok := m.usage[key]
+	if !ok {
+		u = &Usage{TreeID: treeID, Tenant: tenant}
+		m.usage[key] = u
+	}
+	if stage == "queued" {
+		u.QueuedLeaves += count
+		u.QueuedBytes += size
+	} else {
+		u.SequencedLeaves += count
+		u.SequencedBytes += size
+	}
+}
+
+// tenant returns the tenant the request of ctx is metered for: the tenant
+// claim of its token, or its subject if TenantClaim is unset, or an empty
+// string if the request wasn't authenticated.
+func (m *Meter) tenant(ctx context.Context) string {
+	claims, ok := interceptor.ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if m.opts.TenantClaim == "" {
+		return claims.Subject
+	}
+	tenant, _ := claims.Raw[m.opts.TenantClaim].(string)
+	return tenant
+}
+
+// metricTenant returns the counter label of tenant: the tenant itself, unless
+// MaxMetricTenants other tenants have a label already. Must be called with mu
+// held.
+func (m *Meter) metricTenant(tenant string) string {
+	if m.metricTenants[tenant] {
+		return tenant
+	}
+	if len(m.metricTenants) >= m.opts.MaxMetricTenants {
+		return otherTenant
+	}
+	m.metricTenants[tenant] = true
+	return tenant
+}
+
+// Run exports the usage of each window once it's over, until ctx is done.
+func (m *Meter) Run(ctx context.Context) {
+	for {
+		now := m.opts.TimeSource.Now()
+		next := now.Truncate(m.opts.Window).Add(m.opts.Window)
+		if err := clock.SleepSource(ctx, next.Sub(now), m.opts.TimeSource); err != nil {
+			return
+		}
+		if err := m.Flush(ctx); err != nil {
+			logger.Warn(ctx, "failed to export usage", "err", err)
+		}
+	}
+}
+
+// Flush closes the current window, and exports its usage along with that
+// which failed to be exported before.
+func (m *Meter) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	now := m.opts.TimeSource.Now()
+	usage := make([]Usage, 0, len(m.usage))
+	for _, u := range m.usage {
+		u.WindowStart, u.WindowEnd, u.Source = m.start, now, m.opts.Source
+		usage = append(usage, *u)
+	}
+	m.start = now
+	m.usage = make(map[usageKey]*Usage)
+	m.mu.Unlock()
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].TreeID != usage[j].TreeID {
+			return usage[i].TreeID < usage[j].TreeID
+		}
+		return usage[i].Tenant < usage[j].Tenant
+	})
+
+	m.exportMu.Lock()
+	defer m.exportMu.Unlock()
+	var errs []string
+	for _, s := range m.sinks {
+		s.pending = append(s.pending, usage...)
+		if len(s.pending) == 0 {
+			continue
+		}
+		if dropped := len(s.pending) - m.opts.MaxPending; dropped > 0 {
+			logger.Warn(ctx, "dropping usage which failed to be exported", "records", dropped)
+			s.pending = append([]Usage(nil), s.pending[dropped:]...)
+		}
+		if err := s.exporter.Export(ctx, s.pending); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		s.pending = nil
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close exports the usage of the current window, and closes the exporters.
+func (m *Meter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	err := m.Flush(ctx)
+	for _, s := range m.sinks {
+		if cerr := s.exporter.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
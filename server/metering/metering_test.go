@@ -0,0 +1,313 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+type fakeExporter struct {
+	err      error
+	exported [][]Usage
+}
+
+func (e *fakeExporter) Export(_ context.Context, usage []Usage) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.exported = append(e.exported, usage)
+	return nil
+}
+
+func (e *fakeExporter) Close() error { return nil }
+
+func leaves(values ...string) []*trillian.QueuedLogLeaf {
+	var ret []*trillian.QueuedLogLeaf
+	for _, v := range values {
+		ret = append(ret, &trillian.QueuedLogLeaf{Leaf: &trillian.LogLeaf{LeafValue: []byte(v), ExtraData: []byte("x")}})
+	}
+	return ret
+}
+
+func TestMeter(t *testing.T) {
+	start := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+	ts := clock.NewFake(start.Add(time.Minute))
+	e := &fakeExporter{}
+	m := New(Options{Window: time.Hour, Source: "server", Exporters: []Exporter{e}, TimeSource: ts})
+
+	ctx := context.Background()
+	acme := interceptor.NewClaimsContext(ctx, &interceptor.Claims{Subject: "acme"})
+	dup := leaves("dup")
+	dup[0].Status = &status.Status{Code: int32(codes.AlreadyExists)}
+
+	m.RecordQueued(acme, 1, leaves("a", "bb"))
+	m.RecordQueued(acme, 1, append(leaves("ccc"), dup...))
+	m.RecordSequenced(acme, 2, leaves("d"))
+	m.RecordQueued(ctx, 1, leaves("e"))
+	m.RecordQueued(ctx, 3, dup)
+
+	end := start.Add(time.Hour)
+	ts.Set(end)
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	want := [][]Usage{{
+		{WindowStart: start, WindowEnd: end, Source: "server", TreeID: 1, QueuedLeaves: 1, QueuedBytes: 2},
+		{WindowStart: start, WindowEnd: end, Source: "server", TreeID: 1, Tenant: "acme", QueuedLeaves: 3, QueuedBytes: 9},
+		{WindowStart: start, WindowEnd: end, Source: "server", TreeID: 2, Tenant: "acme", SequencedLeaves: 1, SequencedBytes: 2},
+	}}
+	if diff := cmp.Diff(want, e.exported); diff != "" {
+		t.Errorf("exported usage diff (-want +got):\n%s", diff)
+	}
+
+	// Usage is kept until it's exported.
+	e.err = errors.New("unavailable")
+	m.RecordQueued(acme, 1, leaves("f"))
+	ts.Set(end.Add(time.Hour))
+	if err := m.Flush(ctx); err == nil {
+		t.Error("Flush() with failing exporter succeeded, want error")
+	}
+	e.err = nil
+	m.RecordQueued(acme, 1, leaves("g"))
+	ts.Set(end.Add(2 * time.Hour))
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	want = [][]Usage{{
+		{WindowStart: end, WindowEnd: end.Add(time.Hour), Source: "server", TreeID: 1, Tenant: "acme", QueuedLeaves: 1, QueuedBytes: 2},
+		{WindowStart: end.Add(time.Hour), WindowEnd: end.Add(2 * time.Hour), Source: "server", TreeID: 1, Tenant: "acme", QueuedLeaves: 1, QueuedBytes: 2},
+	}}
+	if diff := cmp.Diff(want, e.exported[1:]); diff != "" {
+		t.Errorf("exported usage after failure diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestMeterTenant(t *testing.T) {
+	claims := &interceptor.Claims{Subject: "user", Raw: map[string]interface{}{"org": "acme"}}
+	withMD := metadata.NewIncomingContext(context.Background(), metadata.Pairs(interceptor.TenantMetadataKey, "header"))
+	for _, test := range []struct {
+		desc  string
+		claim string
+		ctx   context.Context
+		want  string
+	}{
+		{desc: "none", ctx: context.Background(), want: ""},
+		{desc: "unauthenticatedMetadata", ctx: withMD, want: ""},
+		{desc: "subject", ctx: interceptor.NewClaimsContext(withMD, claims), want: "user"},
+		{desc: "claim", claim: "org", ctx: interceptor.NewClaimsContext(withMD, claims), want: "acme"},
+		{desc: "missingClaim", claim: "team", ctx: interceptor.NewClaimsContext(withMD, claims), want: ""},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			m := New(Options{TenantClaim: test.claim})
+			if got := m.tenant(test.ctx); got != test.want {
+				t.Errorf("tenant() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMeterMetrics(t *testing.T) {
+	m := New(Options{MetricFactory: monitoring.InertMetricFactory{}, MaxMetricTenants: 2})
+	ctx := interceptor.NewClaimsContext(context.Background(), &interceptor.Claims{Subject: "acme"})
+	m.RecordQueued(ctx, 1, leaves("a", "bb"))
+	m.RecordSequenced(ctx, 1, leaves("ccc"))
+	for _, test := range []struct {
+		stage         string
+		leaves, bytes float64
+	}{
+		{stage: "queued", leaves: 2, bytes: 5},
+		{stage: "sequenced", leaves: 1, bytes: 4},
+	} {
+		if got := m.leaves.Value("1", "acme", test.stage); got != test.leaves {
+			t.Errorf("%s leaves = %v, want %v", test.stage, got, test.leaves)
+		}
+		if got := m.bytes.Value("1", "acme", test.stage); got != test.bytes {
+			t.Errorf("%s bytes = %v, want %v", test.stage, got, test.bytes)
+		}
+	}
+
+	// Tenants beyond MaxMetricTenants share a label.
+	for _, tenant := range []string{"", "initech", "globex"} {
+		m.RecordQueued(interceptor.NewClaimsContext(context.Background(), &interceptor.Claims{Subject: tenant}), 1, leaves("a"))
+	}
+	for _, test := range []struct {
+		tenant string
+		leaves float64
+	}{
+		{tenant: "", leaves: 1},
+		{tenant: "initech", leaves: 0},
+		{tenant: "globex", leaves: 0},
+		{tenant: otherTenant, leaves: 2},
+	} {
+		if got := m.leaves.Value("1", test.tenant, "queued"); got != test.leaves {
+			t.Errorf("queued leaves of tenant %q = %v, want %v", test.tenant, got, test.leaves)
+		}
+	}
+}
+
+func TestMeterMaxPending(t *testing.T) {
+	start := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+	ts := clock.NewFake(start)
+	e := &fakeExporter{err: errors.New("unavailable")}
+	m := New(Options{Window: time.Hour, Source: "server", Exporters: []Exporter{e}, TimeSource: ts, MaxPending: 2})
+	ctx := context.Background()
+	for i := int64(1); i <= 3; i++ {
+		m.RecordQueued(ctx, i, leaves("a"))
+		ts.Set(start.Add(time.Duration(i) * time.Hour))
+		if err := m.Flush(ctx); err == nil {
+			t.Fatal("Flush() with failing exporter succeeded, want error")
+		}
+	}
+	e.err = nil
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	var trees []int64
+	for _, u := range e.exported[0] {
+		trees = append(trees, u.TreeID)
+	}
+	if want := []int64{2, 3}; !cmp.Equal(trees, want) {
+		t.Errorf("exported usage of trees %v, want %v", trees, want)
+	}
+}
+
+var testUsage = []Usage{{
+	WindowStart:     time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC),
+	WindowEnd:       time.Date(2022, 7, 1, 11, 0, 0, 0, time.UTC),
+	Source:          "server",
+	TreeID:          1,
+	Tenant:          "acme",
+	QueuedLeaves:    2,
+	QueuedBytes:     10,
+	SequencedLeaves: 3,
+	SequencedBytes:  20,
+}}
+
+func TestCSVExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.csv")
+	for i := 0; i < 2; i++ {
+		e, err := NewCSVExporter(path)
+		if err != nil {
+			t.Fatalf("NewCSVExporter() = %v", err)
+		}
+		if err := e.Export(context.Background(), testUsage); err != nil {
+			t.Fatalf("Export() = %v", err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() = %v", err)
+	}
+	row := "2022-07-01T10:00:00Z,2022-07-01T11:00:00Z,server,1,acme,2,10,3,20\n"
+	want := strings.Join(csvHeader, ",") + "\n" + row + row
+	if string(got) != want {
+		t.Errorf("CSV file = %q, want %q", got, want)
+	}
+}
+
+func TestBigQueryExporter(t *testing.T) {
+	var reqs []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/projects/p/datasets/d/tables/t/insertAll"; !strings.HasSuffix(r.URL.Path, want) {
+			t.Errorf("request path = %q, want suffix %q", r.URL.Path, want)
+		}
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		reqs = append(reqs, req)
+		w.Header().Set("Content-Type", "application/json")
+		if len(reqs) > 1 {
+			w.Write([]byte(`{"insertErrors": [{"index": 0, "errors": [{"message": "bad row"}]}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if _, err := NewBigQueryExporter(ctx, "p.d", option.WithEndpoint(srv.URL), option.WithoutAuthentication()); err == nil {
+		t.Error("NewBigQueryExporter(p.d) succeeded, want error")
+	}
+	e, err := NewBigQueryExporter(ctx, "p.d.t", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("NewBigQueryExporter() = %v", err)
+	}
+	if err := e.Export(ctx, testUsage); err != nil {
+		t.Fatalf("Export() = %v", err)
+	}
+	rows, _ := reqs[0]["rows"].([]interface{})
+	if len(rows) != 1 {
+		t.Fatalf("inserted %d rows, want 1", len(rows))
+	}
+	row := rows[0].(map[string]interface{})
+	if got, want := row["insertId"], "1656669600000000000/server/1/acme"; got != want {
+		t.Errorf("insertId = %v, want %v", got, want)
+	}
+	wantJSON := map[string]interface{}{
+		"window_start":     "2022-07-01T10:00:00Z",
+		"window_end":       "2022-07-01T11:00:00Z",
+		"source":           "server",
+		"tree_id":          float64(1),
+		"tenant":           "acme",
+		"queued_leaves":    float64(2),
+		"queued_bytes":     float64(10),
+		"sequenced_leaves": float64(3),
+		"sequenced_bytes":  float64(20),
+	}
+	if diff := cmp.Diff(wantJSON, row["json"]); diff != "" {
+		t.Errorf("inserted row diff (-want +got):\n%s", diff)
+	}
+
+	if err := e.Export(ctx, testUsage); err == nil || !strings.Contains(err.Error(), "bad row") {
+		t.Errorf("Export() with insert errors = %v, want bad row error", err)
+	}
+}
+
+func TestOpenExporter(t *testing.T) {
+	ctx := context.Background()
+	e, err := OpenExporter(ctx, "csv:"+filepath.Join(t.TempDir(), "usage.csv"))
+	if err != nil {
+		t.Fatalf("OpenExporter(csv) = %v", err)
+	}
+	e.Close()
+	for _, dest := range []string{"usage.csv", "parquet:usage", "bigquery:table"} {
+		if _, err := OpenExporter(ctx, dest); err == nil {
+			t.Errorf("OpenExporter(%q) succeeded, want error", dest)
+		}
+	}
+}
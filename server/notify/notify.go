@@ -0,0 +1,359 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify POSTs events about the lifecycle and sequencing of trees to
+// webhooks, so that operators are alerted without polling Trillian.
+//
+// Events are JSON objects, which are signed with HMAC-SHA256 if a secret is
+// configured: the X-Trillian-Signature header of each request holds
+// "sha256=" followed by the hex-encoded MAC of its body. Their text field
+// summarizes them, which is what chat tools such as Slack show of the payloads
+// of their incoming webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/util/clock"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	// TreeFrozen is sent when the state of a tree is changed to FROZEN.
+	TreeFrozen EventType = "tree_frozen"
+	// TreeDeletionScheduled is sent when a tree is soft-deleted, after which
+	// it's hard-deleted by the tree garbage collector unless it's undeleted.
+	TreeDeletionScheduled EventType = "tree_deletion_scheduled"
+	// SequencingStalled is sent when the oldest leaf queued to a log has
+	// waited to be sequenced for longer than the stall threshold, or when the
+	// latest root of a log is older than its MaxRootDuration plus the stall
+	// threshold. It's sent again only once the log has caught up.
+	SequencingStalled EventType = "sequencing_stalled"
+	// RootAdvanced is sent when a log signer integrates leaves into a log.
+	RootAdvanced EventType = "root_advanced"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 of the body of requests.
+	SignatureHeader = "X-Trillian-Signature"
+	// EventHeader holds the type of the event of requests.
+	EventHeader = "X-Trillian-Event"
+
+	// DefaultMaxAttempts is the default number of attempts to deliver an
+	// event to a webhook.
+	DefaultMaxAttempts = 5
+	// DefaultBackoff is the default delay before the second attempt to deliver
+	// an event, which doubles with each further attempt.
+	DefaultBackoff = time.Second
+	// DefaultStallThreshold is the default age of the oldest queued leaf of a
+	// log beyond which its sequencing is considered stalled.
+	DefaultStallThreshold = 10 * time.Minute
+	// DefaultStallCheckInterval is the default interval between checks of
+	// the logs for stalled sequencing.
+	DefaultStallCheckInterval = time.Minute
+
+	// queueSize is the number of events buffered for delivery, beyond which
+	// events are dropped.
+	queueSize = 1000
+	// requestTimeout bounds each attempt to deliver an event.
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	urls           = flag.String("webhook_urls", "", "If set, comma-separated URLs to which events about trees are POSTed as JSON")
+	secretFile     = flag.String("webhook_secret_file", "", "File holding the secret with which the events POSTed to --webhook_urls are signed, using HMAC-SHA256")
+	events         = flag.String("webhook_events", "tree_frozen,tree_deletion_scheduled,sequencing_stalled", "Comma-separated types of the events POSTed to --webhook_urls: tree_frozen, tree_deletion_scheduled, sequencing_stalled and root_advanced")
+	stallThreshold = flag.Duration("webhook_stall_threshold", DefaultStallThreshold, "Age of the oldest queued leaf of a log, or delay of its root beyond the tree's max root duration, beyond which a sequencing_stalled event is sent")
+	maxAttempts    = flag.Int("webhook_max_attempts", DefaultMaxAttempts, "Number of attempts to deliver each event to a webhook before it's dropped")
+
+	logger = logging.New("notify")
+)
+
+// Event is the JSON body POSTed to webhooks.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Source is the host name of the server which sent the event.
+	Source string `json:"source,omitempty"`
+	TreeID int64  `json:"tree_id"`
+	// Text summarizes the event for people.
+	Text string `json:"text"`
+
+	// TreeSize and RootHash describe the new root of RootAdvanced events.
+	// TreeSize is also the size of the latest root of SequencingStalled
+	// events.
+	TreeSize uint64 `json:"tree_size,omitempty"`
+	RootHash []byte `json:"root_hash,omitempty"`
+	// OldestUnsequenced is the queue time of the oldest leaf waiting to be
+	// sequenced, and RootTime the timestamp of the latest root, for
+	// SequencingStalled events.
+	OldestUnsequenced *time.Time `json:"oldest_unsequenced,omitempty"`
+	RootTime          *time.Time `json:"root_time,omitempty"`
+	// DeleteTime is when the tree was soft-deleted, for TreeDeletionScheduled
+	// events.
+	DeleteTime *time.Time `json:"delete_time,omitempty"`
+}
+
+// text returns the summary of e.
+func (e *Event) text() string {
+	switch e.Type {
+	case TreeFrozen:
+		return fmt.Sprintf("Tree %d was frozen", e.TreeID)
+	case TreeDeletionScheduled:
+		return fmt.Sprintf("Tree %d was deleted, and will be hard-deleted unless it's undeleted", e.TreeID)
+	case SequencingStalled:
+		if e.OldestUnsequenced != nil {
+			return fmt.Sprintf("Sequencing of log %d is stalled: its oldest queued leaf has waited since %v", e.TreeID, *e.OldestUnsequenced)
+		}
+		if e.RootTime != nil {
+			return fmt.Sprintf("Sequencing of log %d is stalled: its latest root was signed at %v", e.TreeID, *e.RootTime)
+		}
+	case RootAdvanced:
+		return fmt.Sprintf("Log %d grew to %d leaves", e.TreeID, e.TreeSize)
+	}
+	return fmt.Sprintf("Event %s on tree %d", e.Type, e.TreeID)
+}
+
+// Options configures a Notifier.
+type Options struct {
+	// URLs are the webhooks events are POSTed to.
+	URLs []string
+	// Secret, if set, is the key of the HMAC-SHA256 signing each request.
+	Secret []byte
+	// Events are the types of the events sent, or all types if empty.
+	Events []EventType
+	// StallThreshold is the age of the oldest queued leaf of a log beyond
+	// which its sequencing is considered stalled. DefaultStallThreshold if
+	// zero.
+	StallThreshold time.Duration
+	// MaxAttempts is the number of attempts to deliver each event to each
+	// webhook. DefaultMaxAttempts if zero.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt to deliver an event,
+	// which doubles with each further attempt. DefaultBackoff if zero.
+	Backoff time.Duration
+	// Source identifies the server in events. The host name if empty.
+	Source string
+	// Client sends the requests. http.DefaultClient if nil.
+	Client *http.Client
+	// MetricFactory, if set, provides counters of the events dropped and of
+	// the deliveries which failed.
+	MetricFactory monitoring.MetricFactory
+	// TimeSource is clock.System if nil.
+	TimeSource clock.TimeSource
+}
+
+// Notifier sends events to webhooks. Events are queued by Notify, and
+// delivered in the background by Run, with retries.
+type Notifier struct {
+	opts     Options
+	enabled  map[EventType]bool
+	queue    chan Event
+	dropped  monitoring.Counter
+	failures monitoring.Counter
+
+	mu      sync.Mutex // Guards stalled.
+	stalled map[int64]bool
+}
+
+// New returns a Notifier configured by opts.
+func New(opts Options) *Notifier {
+	if opts.StallThreshold <= 0 {
+		opts.StallThreshold = DefaultStallThreshold
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.Source == "" {
+		opts.Source, _ = os.Hostname()
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	mf := opts.MetricFactory
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	n := &Notifier{
+		opts:     opts,
+		queue:    make(chan Event, queueSize),
+		dropped:  mf.NewCounter("webhook_events_dropped", "Number of events dropped as the queue of events to deliver to webhooks was full", "event"),
+		failures: mf.NewCounter("webhook_delivery_failures", "Number of events which failed to be delivered to a webhook after all attempts", "event"),
+		stalled:  make(map[int64]bool),
+	}
+	if len(opts.Events) > 0 {
+		n.enabled = make(map[EventType]bool)
+		for _, t := range opts.Events {
+			n.enabled[t] = true
+		}
+	}
+	return n
+}
+
+// NewNotifierFromFlags returns a Notifier configured by the --webhook_* flags,
+// or nil if --webhook_urls is unset.
+func NewNotifierFromFlags(mf monitoring.MetricFactory) (*Notifier, error) {
+	if *urls == "" {
+		return nil, nil
+	}
+	opts := Options{
+		URLs:           strings.Split(*urls, ","),
+		StallThreshold: *stallThreshold,
+		MaxAttempts:    *maxAttempts,
+		MetricFactory:  mf,
+	}
+	for _, t := range strings.Split(*events, ",") {
+		switch t := EventType(t); t {
+		case TreeFrozen, TreeDeletionScheduled, SequencingStalled, RootAdvanced:
+			opts.Events = append(opts.Events, t)
+		default:
+			return nil, fmt.Errorf("unknown webhook event %q", t)
+		}
+	}
+	if *secretFile != "" {
+		secret, err := ioutil.ReadFile(*secretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook secret: %v", err)
+		}
+		opts.Secret = bytes.TrimRight(secret, "\r\n")
+	}
+	return New(opts), nil
+}
+
+// Enabled returns whether events of type t are sent.
+func (n *Notifier) Enabled(t EventType) bool {
+	return n.enabled == nil || n.enabled[t]
+}
+
+// Notify queues e for delivery, unless its type isn't enabled. Its Time,
+// Source and Text are set if they're empty. Events are dropped if the queue
+// is full, so Notify never blocks.
+func (n *Notifier) Notify(ctx context.Context, e Event) {
+	if !n.Enabled(e.Type) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = n.opts.TimeSource.Now().UTC()
+	}
+	if e.Source == "" {
+		e.Source = n.opts.Source
+	}
+	if e.Text == "" {
+		e.Text = e.text()
+	}
+	select {
+	case n.queue <- e:
+	default:
+		n.dropped.Inc(string(e.Type))
+		logger.Warn(ctx, "webhook queue full, dropping event", "event", e.Type, "tree_id", e.TreeID)
+	}
+}
+
+// Run delivers the queued events until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-n.queue:
+			n.deliver(ctx, e)
+		}
+	}
+}
+
+// deliver POSTs e to each webhook, retrying failed attempts.
+func (n *Notifier) deliver(ctx context.Context, e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		logger.Error(ctx, "failed to marshal event", "event", e.Type, "err", err)
+		return
+	}
+	for _, url := range n.opts.URLs {
+		backoff := n.opts.Backoff
+		for attempt := 1; ; attempt++ {
+			retry, err := n.post(ctx, url, e.Type, body)
+			if err == nil {
+				break
+			}
+			if !retry || attempt == n.opts.MaxAttempts || ctx.Err() != nil {
+				n.failures.Inc(string(e.Type))
+				logger.Warn(ctx, "failed to deliver event to webhook", "event", e.Type, "tree_id", e.TreeID, "url", url, "attempts", attempt, "err", err)
+				break
+			}
+			if err := clock.SleepSource(ctx, backoff, n.opts.TimeSource); err != nil {
+				return
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// post makes one attempt to deliver body to url, and returns whether a failed
+// attempt may be retried.
+func (n *Notifier) post(ctx context.Context, url string, t EventType, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(t))
+	if len(n.opts.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.opts.Secret, body))
+	}
+	resp, err := n.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// Sign returns the value of the SignatureHeader of a request with body, so
+// that receivers can check it with hmac.Equal.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
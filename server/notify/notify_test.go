@@ -0,0 +1,249 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// webhook records the events POSTed to it, after failing the first attempts
+// with the given status codes.
+type webhook struct {
+	t      *testing.T
+	secret []byte
+
+	mu       sync.Mutex
+	failures []int
+	attempts int
+	events   []Event
+	received chan struct{}
+}
+
+func newWebhook(t *testing.T, secret []byte, failures ...int) (*webhook, *httptest.Server) {
+	w := &webhook{t: t, secret: secret, failures: failures, received: make(chan struct{}, 10)}
+	srv := httptest.NewServer(w)
+	t.Cleanup(srv.Close)
+	return w, srv
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts++
+	if len(w.failures) > 0 {
+		rw.WriteHeader(w.failures[0])
+		w.failures = w.failures[1:]
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.t.Errorf("failed to read request: %v", err)
+	}
+	if got, want := r.Header.Get(SignatureHeader), Sign(w.secret, body); len(w.secret) > 0 && got != want {
+		w.t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		w.t.Errorf("failed to decode event: %v", err)
+	}
+	if got := r.Header.Get(EventHeader); got != string(e.Type) {
+		w.t.Errorf("%s = %q, want %q", EventHeader, got, e.Type)
+	}
+	w.events = append(w.events, e)
+	w.received <- struct{}{}
+}
+
+// wait waits for n events to be received.
+func (w *webhook) wait(n int) {
+	w.t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-w.received:
+		case <-time.After(10 * time.Second):
+			w.t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	secret := []byte("secret")
+	w1, srv1 := newWebhook(t, secret, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	w2, srv2 := newWebhook(t, secret)
+	n := New(Options{
+		URLs:    []string{srv1.URL, srv2.URL},
+		Secret:  secret,
+		Events:  []EventType{TreeFrozen, RootAdvanced},
+		Backoff: time.Millisecond,
+		Source:  "signer",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Notify(ctx, Event{Type: TreeDeletionScheduled, TreeID: 1})
+	now := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+	n.Notify(ctx, Event{Type: TreeFrozen, TreeID: 1, Time: now})
+	n.Notify(ctx, Event{Type: RootAdvanced, TreeID: 2, Time: now, TreeSize: 10, RootHash: []byte("root")})
+	w1.wait(2)
+	w2.wait(2)
+
+	want := []Event{
+		{Type: TreeFrozen, Time: now, Source: "signer", TreeID: 1, Text: "Tree 1 was frozen"},
+		{Type: RootAdvanced, Time: now, Source: "signer", TreeID: 2, Text: "Log 2 grew to 10 leaves", TreeSize: 10, RootHash: []byte("root")},
+	}
+	for _, w := range []*webhook{w1, w2} {
+		w.mu.Lock()
+		if diff := cmp.Diff(want, w.events); diff != "" {
+			t.Errorf("events diff (-want +got):\n%s", diff)
+		}
+		w.mu.Unlock()
+	}
+	w1.mu.Lock()
+	if got, want := w1.attempts, 4; got != want {
+		t.Errorf("webhook got %d attempts, want %d", got, want)
+	}
+	w1.mu.Unlock()
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	w, srv := newWebhook(t, nil, http.StatusBadRequest, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	n := New(Options{URLs: []string{srv.URL}, MaxAttempts: 2, Backoff: time.Millisecond})
+	ctx := context.Background()
+
+	// Client errors aren't retried, and other errors only up to MaxAttempts.
+	for _, wantAttempts := range []int{1, 3} {
+		n.deliver(ctx, Event{Type: TreeFrozen, TreeID: 1})
+		w.mu.Lock()
+		if w.attempts != wantAttempts || len(w.events) != 0 {
+			t.Errorf("webhook got %d attempts and %d events, want %d attempts and none", w.attempts, len(w.events), wantAttempts)
+		}
+		w.mu.Unlock()
+	}
+}
+
+func TestCheckStalled(t *testing.T) {
+	now := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+	n := New(Options{StallThreshold: 10 * time.Minute, TimeSource: clock.NewFake(now)})
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 1, MaxRootDuration: durationpb.New(time.Hour)}
+	recentRoot := &types.LogRootV1{TreeSize: 5, TimestampNanos: uint64(now.Add(-time.Minute).UnixNano())}
+	lateRoot := &types.LogRootV1{TreeSize: 5, TimestampNanos: uint64(now.Add(-2 * time.Hour).UnixNano())}
+	for _, test := range []struct {
+		desc      string
+		root      *types.LogRootV1
+		oldest    time.Time
+		want      bool
+		wantQueue bool
+	}{
+		{desc: "empty", root: recentRoot, want: false},
+		{desc: "recent", root: recentRoot, oldest: now.Add(-time.Minute), want: false},
+		{desc: "stalled", root: recentRoot, oldest: now.Add(-time.Hour), want: true, wantQueue: true},
+		{desc: "stillStalled", root: recentRoot, oldest: now.Add(-time.Hour), want: false},
+		{desc: "caughtUp", root: recentRoot, oldest: now.Add(-time.Minute), want: false},
+		{desc: "stalledAgain", root: recentRoot, oldest: now.Add(-time.Hour), want: true, wantQueue: true},
+		{desc: "caughtUpAgain", root: recentRoot, want: false},
+		{desc: "lateRoot", root: lateRoot, want: true},
+		{desc: "stillLateRoot", root: lateRoot, want: false},
+	} {
+		n.CheckStalled(ctx, tree, test.root, test.oldest)
+		select {
+		case e := <-n.queue:
+			if !test.want {
+				t.Errorf("%s: got event %+v, want none", test.desc, e)
+				continue
+			}
+			if e.Type != SequencingStalled || e.TreeID != 1 || e.TreeSize != 5 {
+				t.Errorf("%s: got event %+v, want %s for tree 1 of size 5", test.desc, e, SequencingStalled)
+			}
+			if got := e.OldestUnsequenced != nil; got != test.wantQueue {
+				t.Errorf("%s: got oldest unsequenced %v, want it set: %v", test.desc, e.OldestUnsequenced, test.wantQueue)
+			} else if got && !e.OldestUnsequenced.Equal(test.oldest) {
+				t.Errorf("%s: got oldest unsequenced %v, want %v", test.desc, e.OldestUnsequenced, test.oldest)
+			}
+			if want := time.Unix(0, int64(test.root.TimestampNanos)); e.RootTime == nil || !e.RootTime.Equal(want) {
+				t.Errorf("%s: got root time %v, want %v", test.desc, e.RootTime, want)
+			}
+		default:
+			if test.want {
+				t.Errorf("%s: got no event, want %s", test.desc, SequencingStalled)
+			}
+		}
+	}
+}
+
+func TestMonitorStalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now()
+	ts := clock.NewFake(now)
+	ms := memory.NewTreeStorage()
+	admin := memory.NewAdminStorage(ms)
+	ls := memory.NewLogStorage(ms, nil)
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.MaxRootDuration = durationpb.New(time.Hour)
+	tree, err := storage.CreateTree(ctx, admin, tree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	root, err := (&types.LogRootV1{TimestampNanos: uint64(now.Add(-48 * time.Hour).UnixNano()), RootHash: []byte{0}}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		t.Fatalf("StoreSignedLogRoot(): %v", err)
+	}
+
+	// The root of the log is late, although no signer ever ran it.
+	n := New(Options{TimeSource: ts})
+	go n.MonitorStalls(ctx, admin, ls, time.Minute)
+	select {
+	case e := <-n.queue:
+		if e.Type != SequencingStalled || e.TreeID != tree.TreeId {
+			t.Errorf("got event %+v, want %s for tree %d", e, SequencingStalled, tree.TreeId)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestNotifyDropsWhenFull(t *testing.T) {
+	n := New(Options{})
+	ctx := context.Background()
+	for i := 0; i < queueSize+1; i++ {
+		n.Notify(ctx, Event{Type: RootAdvanced, TreeID: int64(i)})
+	}
+	if got := len(n.queue); got != queueSize {
+		t.Errorf("queued %d events, want %d", got, queueSize)
+	}
+}
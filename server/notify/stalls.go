@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MonitorStalls checks the active logs of admin every interval until ctx is
+// done, and sends a SequencingStalled event for each log whose sequencing has
+// stalled. Stalls are detected from the contents of the logs rather than by
+// the log signers, so that they're reported even if no signer is running a
+// log, e.g. because it died or lost mastership.
+//
+// Each server running MonitorStalls sends its own events, which are told
+// apart by their Source.
+func (n *Notifier) MonitorStalls(ctx context.Context, admin storage.AdminStorage, ls storage.LogStorage, interval time.Duration) {
+	for {
+		trees, err := storage.ListTrees(ctx, admin, false /* includeDeleted */)
+		if err != nil {
+			logger.Warn(ctx, "failed to list trees for stall checks", "err", err)
+		}
+		for _, tree := range trees {
+			if tree.TreeState != trillian.TreeState_ACTIVE {
+				continue
+			}
+			if err := n.checkTree(ctx, ls, tree); err != nil {
+				logger.Warn(ctx, "failed to check tree for stalls", "tree_id", tree.TreeId, "err", err)
+			}
+		}
+		if err := clock.SleepSource(ctx, interval, n.opts.TimeSource); err != nil {
+			return
+		}
+	}
+}
+
+// checkTree reads the latest root and the oldest queued leaf of tree, and
+// passes them to CheckStalled.
+func (n *Notifier) checkTree(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree) error {
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.GetLogRoot()); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	var oldest time.Time
+	// Pre-ordered logs have no queue of their own.
+	if a, ok := ls.(storage.UnsequencedAger); ok && tree.TreeType == trillian.TreeType_LOG {
+		// Storage wrapping another one may not support reading queue
+		// timestamps, in which case only the root age is checked.
+		if oldest, err = a.OldestUnsequenced(ctx, tree); err != nil && status.Code(err) != codes.Unimplemented {
+			return err
+		}
+	}
+	n.CheckStalled(ctx, tree, &root, oldest)
+	return nil
+}
+
+// CheckStalled sends a SequencingStalled event for tree if the oldest of its
+// queued leaves, queued at oldest, is older than the stall threshold, or if
+// its latest root is older than its MaxRootDuration plus the stall threshold,
+// unless one was sent since the log last caught up. oldest is zero if no
+// leaves are queued.
+func (n *Notifier) CheckStalled(ctx context.Context, tree *trillian.Tree, root *types.LogRootV1, oldest time.Time) {
+	now := n.opts.TimeSource.Now()
+	queueStalled := !oldest.IsZero() && now.Sub(oldest) > n.opts.StallThreshold
+	rootTime := time.Unix(0, int64(root.TimestampNanos))
+	maxRootDuration := tree.MaxRootDuration.AsDuration()
+	rootStalled := maxRootDuration > 0 && now.Sub(rootTime) > maxRootDuration+n.opts.StallThreshold
+	stalled := queueStalled || rootStalled
+
+	n.mu.Lock()
+	notify := stalled && !n.stalled[tree.TreeId]
+	if stalled {
+		n.stalled[tree.TreeId] = true
+	} else {
+		delete(n.stalled, tree.TreeId)
+	}
+	n.mu.Unlock()
+	if !notify {
+		return
+	}
+	e := Event{Type: SequencingStalled, TreeID: tree.TreeId, TreeSize: root.TreeSize}
+	if queueStalled {
+		oldest := oldest.UTC()
+		e.OldestUnsequenced = &oldest
+	}
+	rootTime = rootTime.UTC()
+	e.RootTime = &rootTime
+	n.Notify(ctx, e)
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// cachedRoot returns the latest root of the tree from the read cache, with
+// its parsed form, or nils if there is no read cache or it doesn't hold the
+// root.
+func (t *TrillianLogRPCServer) cachedRoot(ctx context.Context, treeID int64) (*trillian.SignedLogRoot, *types.LogRootV1) {
+	c := t.registry.ReadCache
+	if c == nil {
+		return nil, nil
+	}
+	slr := c.Root(ctx, treeID)
+	if slr == nil {
+		return nil, nil
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, nil
+	}
+	return slr, &root
+}
+
+// cacheRoot stores the latest root of the tree read from storage in the read
+// cache, if any.
+func (t *TrillianLogRPCServer) cacheRoot(ctx context.Context, treeID int64, slr *trillian.SignedLogRoot) {
+	if c := t.registry.ReadCache; c != nil {
+		c.SetRoot(ctx, treeID, slr)
+	}
+}
+
+// getCached reads the cached response of the given kind for the request
+// identified by params into m, and returns whether it was found.
+func (t *TrillianLogRPCServer) getCached(ctx context.Context, treeID int64, kind string, m proto.Message, params ...interface{}) bool {
+	c := t.registry.ReadCache
+	return c != nil && c.Get(ctx, treeID, kind, m, params...)
+}
+
+// setCached stores the response of the given kind for the request identified
+// by params in the read cache, if any.
+func (t *TrillianLogRPCServer) setCached(ctx context.Context, treeID int64, kind string, m proto.Message, params ...interface{}) {
+	if c := t.registry.ReadCache; c != nil {
+		c.Set(ctx, treeID, kind, m, params...)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// memcacheStore is a Store held by memcached servers, which keys are spread
+// across.
+type memcacheStore struct {
+	client *memcache.Client
+}
+
+func newMemcacheStore(u *url.URL) (Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%v: missing servers, want memcache://host:port[,host:port...]", u)
+	}
+	return &memcacheStore{client: memcache.New(strings.Split(u.Host, ",")...)}, nil
+}
+
+func (s *memcacheStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	item, err := s.client.Get(key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return item.Value, true, nil
+}
+
+func (s *memcacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	// Expirations have a resolution of a second, and zero means none.
+	expiration := int32((ttl + time.Second - 1) / time.Second)
+	return s.client.Set(&memcache.Item{Key: key, Value: value, Expiration: expiration})
+}
+
+func (s *memcacheStore) Delete(_ context.Context, key string) error {
+	if err := s.client.Delete(key); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return err
+	}
+	return nil
+}
+
+func (s *memcacheStore) Close() error {
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+)
+
+// DefaultMemoryMaxBytes is the default size of a MemoryStore opened by
+// OpenStore.
+const DefaultMemoryMaxBytes = 64 << 20
+
+// entryOverhead approximates the memory used by an entry of a MemoryStore
+// besides its key and value.
+const entryOverhead = 128
+
+// MemoryStore is a Store local to the process, which evicts the least recently
+// used entries beyond its maximum size.
+type MemoryStore struct {
+	maxBytes   int64
+	timeSource clock.TimeSource
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // Of *memoryEntry, from the most recently used.
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(entryOverhead + len(e.key) + len(e.value))
+}
+
+// NewMemoryStore returns a MemoryStore holding up to about maxBytes.
+func NewMemoryStore(maxBytes int64) *MemoryStore {
+	return &MemoryStore{
+		maxBytes:   maxBytes,
+		timeSource: clock.System,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := elem.Value.(*memoryEntry)
+	if !s.timeSource.Now().Before(e.expires) {
+		s.removeLocked(elem)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(elem)
+	return e.value, true, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	e := &memoryEntry{key: key, value: value, expires: s.timeSource.Now().Add(ttl)}
+	if e.size() > s.maxBytes {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.removeLocked(elem)
+	}
+	s.entries[key] = s.lru.PushFront(e)
+	s.size += e.size()
+	for s.size > s.maxBytes {
+		s.removeLocked(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.removeLocked(elem)
+	}
+	return nil
+}
+
+// Close implements Store.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) removeLocked(elem *list.Element) {
+	e := s.lru.Remove(elem).(*memoryEntry)
+	delete(s.entries, e.key)
+	s.size -= e.size()
+}
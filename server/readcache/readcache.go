@@ -0,0 +1,225 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package readcache caches the responses of read requests to logs, which
+// popular logs receive many identical copies of.
+//
+// The latest signed root of each tree is cached for a short time, and is
+// invalidated when the tree grows. Proofs, and ranges of leaves within a tree,
+// never change for a given tree size, so they're cached until evicted.
+package readcache
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultRootTTL is the default time for which the latest root of a tree
+	// is cached, which bounds its staleness if it isn't invalidated.
+	DefaultRootTTL = time.Second
+	// DefaultTTL is the default time for which proofs and leaves are cached.
+	DefaultTTL = 10 * time.Minute
+)
+
+// Kinds of cached responses, which label the metrics.
+const (
+	KindRoot            = "root"
+	KindInclusion       = "inclusion"
+	KindInclusionByHash = "inclusion_by_hash"
+	KindConsistency     = "consistency"
+	KindLeavesByRange   = "leaves_by_range"
+)
+
+// keyPrefix prefixes the keys of cached values, to share stores with other
+// applications.
+const keyPrefix = "trillian/"
+
+var logger = logging.New("readcache")
+
+// Store is a key-value store holding cached responses. Implementations must
+// be safe for concurrent use.
+type Store interface {
+	// Get returns the value of key, and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value of key, which expires after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key, if present.
+	Delete(ctx context.Context, key string) error
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// OpenStore returns the store with the given URL, which is one of:
+//   - memory, or memory:?max_bytes=N, for a cache local to the process;
+//   - redis://[:password@]host:port[/db], for a Redis server;
+//   - memcache://host:port[,host:port...], for memcached servers.
+func OpenStore(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read cache URL %q: %v", rawURL, err)
+	}
+	switch u.Scheme {
+	case "":
+		if u.Path != "memory" {
+			break
+		}
+		fallthrough
+	case "memory":
+		maxBytes := int64(DefaultMemoryMaxBytes)
+		if s := u.Query().Get("max_bytes"); s != "" {
+			if maxBytes, err = strconv.ParseInt(s, 10, 64); err != nil || maxBytes <= 0 {
+				return nil, fmt.Errorf("invalid max_bytes %q in read cache URL", s)
+			}
+		}
+		return NewMemoryStore(maxBytes), nil
+	case "redis":
+		return newRedisStore(u)
+	case "memcache":
+		return newMemcacheStore(u)
+	}
+	return nil, fmt.Errorf("unsupported read cache URL %q, want memory, redis://... or memcache://...", rawURL)
+}
+
+// Options holds the settings of a Cache.
+type Options struct {
+	// RootTTL is the time for which the latest root of a tree is cached.
+	// DefaultRootTTL is used if it is zero.
+	RootTTL time.Duration
+	// TTL is the time for which proofs and leaves are cached. DefaultTTL is
+	// used if it is zero.
+	TTL time.Duration
+}
+
+// Cache caches read responses in a Store. Failures of the store are logged,
+// and treated as cache misses.
+type Cache struct {
+	store  Store
+	opts   Options
+	hits   monitoring.Counter
+	misses monitoring.Counter
+}
+
+// New returns a Cache storing responses in store, and reporting metrics using
+// mf.
+func New(store Store, opts Options, mf monitoring.MetricFactory) *Cache {
+	if opts.RootTTL <= 0 {
+		opts.RootTTL = DefaultRootTTL
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &Cache{
+		store:  store,
+		opts:   opts,
+		hits:   mf.NewCounter("read_cache_hits", "Number of read responses served from the read cache, by kind", "logid", "kind"),
+		misses: mf.NewCounter("read_cache_misses", "Number of read responses not found in the read cache, by kind", "logid", "kind"),
+	}
+}
+
+// Close closes the underlying store.
+func (c *Cache) Close() error {
+	return c.store.Close()
+}
+
+// Root returns the cached latest root of the tree, or nil.
+func (c *Cache) Root(ctx context.Context, treeID int64) *trillian.SignedLogRoot {
+	slr := &trillian.SignedLogRoot{}
+	if !c.get(ctx, treeID, KindRoot, rootKey(treeID), slr) {
+		return nil
+	}
+	return slr
+}
+
+// SetRoot caches the latest root of the tree.
+func (c *Cache) SetRoot(ctx context.Context, treeID int64, slr *trillian.SignedLogRoot) {
+	c.set(ctx, rootKey(treeID), slr, c.opts.RootTTL)
+}
+
+// InvalidateRoot removes the cached root of the tree, which must be called
+// when the tree grows so that readers see the new root.
+func (c *Cache) InvalidateRoot(ctx context.Context, treeID int64) {
+	if err := c.store.Delete(ctx, rootKey(treeID)); err != nil {
+		logger.Warn(ctx, "failed to invalidate cached root", "tree_id", treeID, "err", err)
+	}
+}
+
+// Get reads the cached response of the given kind for the request identified
+// by params into m, and returns whether it was found. The response must not
+// depend on the latest root of the tree.
+func (c *Cache) Get(ctx context.Context, treeID int64, kind string, m proto.Message, params ...interface{}) bool {
+	return c.get(ctx, treeID, kind, key(treeID, kind, params), m)
+}
+
+// Set caches the response of the given kind for the request identified by
+// params.
+func (c *Cache) Set(ctx context.Context, treeID int64, kind string, m proto.Message, params ...interface{}) {
+	c.set(ctx, key(treeID, kind, params), m, c.opts.TTL)
+}
+
+func (c *Cache) get(ctx context.Context, treeID int64, kind, key string, m proto.Message) bool {
+	label := strconv.FormatInt(treeID, 10)
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		logger.Warn(ctx, "failed to read cache", "key", key, "err", err)
+	} else if ok {
+		if err := proto.Unmarshal(data, m); err == nil {
+			c.hits.Inc(label, kind)
+			return true
+		}
+		logger.Warn(ctx, "invalid cached value", "key", key, "err", err)
+	}
+	c.misses.Inc(label, kind)
+	return false
+}
+
+func (c *Cache) set(ctx context.Context, key string, m proto.Message, ttl time.Duration) {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		logger.Warn(ctx, "failed to marshal cached value", "key", key, "err", err)
+		return
+	}
+	if err := c.store.Set(ctx, key, data, ttl); err != nil {
+		logger.Warn(ctx, "failed to write cache", "key", key, "err", err)
+	}
+}
+
+func rootKey(treeID int64) string {
+	return fmt.Sprintf("%s%d/%s", keyPrefix, treeID, KindRoot)
+}
+
+func key(treeID int64, kind string, params []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%d/%s", keyPrefix, treeID, kind)
+	for _, p := range params {
+		if h, ok := p.([]byte); ok {
+			fmt.Fprintf(&b, "/%x", h)
+		} else {
+			fmt.Fprintf(&b, "/%v", p)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readcache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/testonly"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	ts := clock.NewFake(time.Unix(1000, 0))
+	s := NewMemoryStore(3 * (entryOverhead + 2))
+	s.timeSource = ts
+
+	mustGet := func(key string, want bool) {
+		t.Helper()
+		_, ok, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%q) = %v", key, err)
+		}
+		if ok != want {
+			t.Errorf("Get(%q) found=%v, want %v", key, ok, want)
+		}
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Set(ctx, key, []byte("v"), time.Minute); err != nil {
+			t.Fatalf("Set(%q) = %v", key, err)
+		}
+	}
+	// Reading a makes b the least recently used entry.
+	mustGet("a", true)
+	if err := s.Set(ctx, "d", []byte("v"), time.Minute); err != nil {
+		t.Fatalf("Set(d) = %v", err)
+	}
+	mustGet("b", false)
+	mustGet("a", true)
+	mustGet("c", true)
+	mustGet("d", true)
+
+	if err := s.Delete(ctx, "c"); err != nil {
+		t.Fatalf("Delete(c) = %v", err)
+	}
+	mustGet("c", false)
+
+	if err := s.Set(ctx, "e", []byte("v"), time.Second); err != nil {
+		t.Fatalf("Set(e) = %v", err)
+	}
+	mustGet("e", true)
+	ts.Set(ts.Now().Add(time.Second))
+	mustGet("e", false)
+	mustGet("a", true)
+
+	// Values larger than the store aren't kept.
+	if err := s.Set(ctx, "big", make([]byte, 4*entryOverhead), time.Minute); err != nil {
+		t.Fatalf("Set(big) = %v", err)
+	}
+	mustGet("big", false)
+	mustGet("a", true)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(DefaultMemoryMaxBytes), Options{}, monitoring.InertMetricFactory{})
+	const treeID = 12345
+	hits := testonly.NewCounterSnapshot(c.hits, "12345", KindInclusion)
+	misses := testonly.NewCounterSnapshot(c.misses, "12345", KindInclusion)
+
+	if slr := c.Root(ctx, treeID); slr != nil {
+		t.Errorf("Root() = %v, want nil", slr)
+	}
+	root := &trillian.SignedLogRoot{LogRoot: []byte("root")}
+	c.SetRoot(ctx, treeID, root)
+	if got := c.Root(ctx, treeID); !proto.Equal(got, root) {
+		t.Errorf("Root() = %v, want %v", got, root)
+	}
+	if got := c.Root(ctx, treeID+1); got != nil {
+		t.Errorf("Root() of other tree = %v, want nil", got)
+	}
+	c.InvalidateRoot(ctx, treeID)
+	if got := c.Root(ctx, treeID); got != nil {
+		t.Errorf("Root() after InvalidateRoot() = %v, want nil", got)
+	}
+
+	proof := &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: 2, Hashes: [][]byte{[]byte("hash")}}}
+	var got trillian.GetInclusionProofResponse
+	if c.Get(ctx, treeID, KindInclusion, &got, int64(2), int64(7)) {
+		t.Errorf("Get() before Set() found %v", &got)
+	}
+	c.Set(ctx, treeID, KindInclusion, proof, int64(2), int64(7))
+	if !c.Get(ctx, treeID, KindInclusion, &got, int64(2), int64(7)) {
+		t.Error("Get() after Set() found nothing")
+	} else if !proto.Equal(&got, proof) {
+		t.Errorf("Get() = %v, want %v", &got, proof)
+	}
+	if c.Get(ctx, treeID, KindInclusion, &got, int64(2), int64(8)) {
+		t.Error("Get() with other params found a response")
+	}
+	// Invalidating the root leaves the proofs, which don't depend on it.
+	c.InvalidateRoot(ctx, treeID)
+	if !c.Get(ctx, treeID, KindInclusion, &got, int64(2), int64(7)) {
+		t.Error("Get() after InvalidateRoot() found nothing")
+	}
+
+	if got, want := hits.Delta(), 2.0; got != want {
+		t.Errorf("hits = %v, want %v", got, want)
+	}
+	if got, want := misses.Delta(), 2.0; got != want {
+		t.Errorf("misses = %v, want %v", got, want)
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, tc := range []struct {
+		params []interface{}
+		want   string
+	}{
+		{params: nil, want: "trillian/1/inclusion"},
+		{params: []interface{}{int64(2), int64(7)}, want: "trillian/1/inclusion/2/7"},
+		{params: []interface{}{[]byte{0xab, 0x01}, int64(7), true}, want: "trillian/1/inclusion/ab01/7/true"},
+	} {
+		if got := key(1, KindInclusion, tc.params); got != tc.want {
+			t.Errorf("key(%v) = %q, want %q", tc.params, got, tc.want)
+		}
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	for _, tc := range []struct {
+		url     string
+		wantErr string
+	}{
+		{url: "memory"},
+		{url: "memory:?max_bytes=1024"},
+		{url: "memory:?max_bytes=0", wantErr: "invalid max_bytes"},
+		{url: "memory:?max_bytes=x", wantErr: "invalid max_bytes"},
+		{url: "memcache://localhost:11211,localhost:11212"},
+		{url: "memcache://", wantErr: "missing servers"},
+		{url: "file:///tmp/cache", wantErr: "unsupported"},
+		{url: "other", wantErr: "unsupported"},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			s, err := OpenStore(tc.url)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("OpenStore(%q) = %v, want error containing %q", tc.url, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenStore(%q) = %v", tc.url, err)
+			}
+			s.Close()
+		})
+	}
+}
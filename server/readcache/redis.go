@@ -0,0 +1,58 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readcache
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisStore is a Store held by a Redis server.
+type redisStore struct {
+	client *redis.Client
+}
+
+func newRedisStore(u *url.URL) (Store, error) {
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return &redisStore{client: redis.NewClient(opts)}, nil
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.WithContext(ctx).Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.WithContext(ctx).Set(key, value, ttl).Err()
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.client.WithContext(ctx).Del(key).Err()
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
@@ -69,6 +69,87 @@ func validateGetConsistencyProofRequest(req *trillian.GetConsistencyProofRequest
 	return nil
 }
 
+func validateGetLeavesByHashRequest(req *trillian.GetLeavesByHashRequest, hasher merkle.LogHasher) error {
+	if len(req.LeafHash) == 0 {
+		return status.Error(codes.InvalidArgument, "GetLeavesByHashRequest.LeafHash empty")
+	}
+	if len(req.LeafHash) > maxLeavesByHashCount {
+		return status.Errorf(codes.InvalidArgument, "GetLeavesByHashRequest.LeafHash: %v hashes, want <= %v", len(req.LeafHash), maxLeavesByHashCount)
+	}
+	for i, hash := range req.LeafHash {
+		if err := validateLeafHash(hash, hasher); err != nil {
+			return status.Errorf(codes.InvalidArgument, "GetLeavesByHashRequest.LeafHash[%v]: %v", i, err)
+		}
+	}
+	if req.TreeSize < 0 {
+		return status.Errorf(codes.InvalidArgument, "GetLeavesByHashRequest.TreeSize: %v, want >= 0", req.TreeSize)
+	}
+	if req.PageSize < 0 {
+		return status.Errorf(codes.InvalidArgument, "GetLeavesByHashRequest.PageSize: %v, want >= 0", req.PageSize)
+	}
+	return nil
+}
+
+func validateGetLeavesByIndexListRequest(req *trillian.GetLeavesByIndexListRequest) error {
+	if len(req.LeafIndex) == 0 {
+		return status.Error(codes.InvalidArgument, "GetLeavesByIndexListRequest.LeafIndex empty")
+	}
+	if len(req.LeafIndex) > maxLeavesByIndexCount {
+		return status.Errorf(codes.InvalidArgument, "GetLeavesByIndexListRequest.LeafIndex: %v indices, want <= %v", len(req.LeafIndex), maxLeavesByIndexCount)
+	}
+	for i, index := range req.LeafIndex {
+		if index < 0 {
+			return status.Errorf(codes.InvalidArgument, "GetLeavesByIndexListRequest.LeafIndex[%v]: %v, want >= 0", i, index)
+		}
+	}
+	return nil
+}
+
+func validateGetSignedLogRootAtTimeRequest(req *trillian.GetSignedLogRootAtTimeRequest) error {
+	if req.Timestamp == nil {
+		return status.Error(codes.InvalidArgument, "GetSignedLogRootAtTimeRequest.Timestamp empty")
+	}
+	if err := req.Timestamp.CheckValid(); err != nil {
+		return status.Errorf(codes.InvalidArgument, "GetSignedLogRootAtTimeRequest.Timestamp: %v", err)
+	}
+	if req.Timestamp.Seconds < 0 {
+		return status.Errorf(codes.InvalidArgument, "GetSignedLogRootAtTimeRequest.Timestamp: %v, want after the Unix epoch", req.Timestamp.AsTime())
+	}
+	return nil
+}
+
+// validateStreamLeavesRequest returns the index of the first leaf to stream,
+// from the resume token of the request if it's set.
+func validateStreamLeavesRequest(req *trillian.StreamLeavesRequest) (int64, error) {
+	if req.ResumeToken == "" {
+		if req.StartIndex < 0 {
+			return 0, status.Errorf(codes.InvalidArgument, "StreamLeavesRequest.StartIndex: %v, want >= 0", req.StartIndex)
+		}
+		return req.StartIndex, nil
+	}
+	var logID, next int64
+	if _, err := fmt.Sscanf(req.ResumeToken, resumeTokenFormat, &logID, &next); err != nil || next < 0 || fmt.Sprintf(resumeTokenFormat, logID, next) != req.ResumeToken {
+		return 0, status.Errorf(codes.InvalidArgument, "StreamLeavesRequest.ResumeToken: %q is invalid", req.ResumeToken)
+	}
+	if logID != req.LogId {
+		return 0, status.Errorf(codes.InvalidArgument, "StreamLeavesRequest.ResumeToken: %q is for log %d, want %d", req.ResumeToken, logID, req.LogId)
+	}
+	return next, nil
+}
+
+func validateGetCompactRangeRequest(req *trillian.GetCompactRangeRequest) error {
+	if req.Begin < 0 {
+		return status.Errorf(codes.InvalidArgument, "GetCompactRangeRequest.Begin: %v, want >= 0", req.Begin)
+	}
+	if req.End < req.Begin {
+		return status.Errorf(codes.InvalidArgument, "GetCompactRangeRequest.End: %v < Begin: %v, want >= ", req.End, req.Begin)
+	}
+	if req.TreeSize < req.End {
+		return status.Errorf(codes.InvalidArgument, "GetCompactRangeRequest.TreeSize: %v < End: %v, want >= ", req.TreeSize, req.End)
+	}
+	return nil
+}
+
 func validateGetEntryAndProofRequest(req *trillian.GetEntryAndProofRequest) error {
 	if req.TreeSize <= 0 {
 		return status.Errorf(codes.InvalidArgument, "GetEntryAndProofRequest.TreeSize: %v, want > 0", req.TreeSize)
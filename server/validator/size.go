@@ -0,0 +1,75 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SizeLimit is the maximum size, in bytes, of the fields of a leaf. Zero means unlimited.
+type SizeLimit struct {
+	MaxLeafValueSize int
+	MaxExtraDataSize int
+}
+
+// SizeLimits is a LeafValidator that rejects leaves whose LeafValue or ExtraData are larger
+// than the limit of their tree, i.e. its MaxLeafValueSize and MaxExtraDataSize, or the default
+// limits for those which are zero.
+type SizeLimits struct {
+	// Default is the limit of trees which don't set their own.
+	Default SizeLimit
+
+	rejected monitoring.Counter
+}
+
+// NewSizeLimits returns a SizeLimits validator which counts rejected leaves using mf.
+func NewSizeLimits(def SizeLimit, mf monitoring.MetricFactory) *SizeLimits {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &SizeLimits{
+		Default: def,
+		rejected: mf.NewCounter(
+			"oversized_leaves",
+			"Number of leaves rejected for exceeding the size limits of their tree",
+			"logid", "field"),
+	}
+}
+
+// ValidateLeaf returns an InvalidArgument error if leaf exceeds the size limit of tree.
+func (s *SizeLimits) ValidateLeaf(ctx context.Context, tree *trillian.Tree, leaf *trillian.LogLeaf) error {
+	limit := s.Default
+	if max := tree.MaxLeafValueSize; max > 0 {
+		limit.MaxLeafValueSize = int(max)
+	}
+	if max := tree.MaxExtraDataSize; max > 0 {
+		limit.MaxExtraDataSize = int(max)
+	}
+	if max, got := limit.MaxLeafValueSize, len(leaf.LeafValue); max > 0 && got > max {
+		s.rejected.Inc(strconv.FormatInt(tree.TreeId, 10), "leaf_value")
+		return status.Errorf(codes.InvalidArgument, "LeafValue too large: %d bytes, tree %d allows at most %d", got, tree.TreeId, max)
+	}
+	if max, got := limit.MaxExtraDataSize, len(leaf.ExtraData); max > 0 && got > max {
+		s.rejected.Inc(strconv.FormatInt(tree.TreeId, 10), "extra_data")
+		return status.Errorf(codes.InvalidArgument, "ExtraData too large: %d bytes, tree %d allows at most %d", got, tree.TreeId, max)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSizeLimits(t *testing.T) {
+	v := NewSizeLimits(SizeLimit{MaxLeafValueSize: 4, MaxExtraDataSize: 2}, nil /* mf */)
+	def := &trillian.Tree{TreeId: 2}
+	perTree := &trillian.Tree{TreeId: 1, MaxLeafValueSize: 8}
+
+	for _, test := range []struct {
+		desc    string
+		tree    *trillian.Tree
+		leaf    *trillian.LogLeaf
+		wantErr bool
+	}{
+		{desc: "default ok", tree: def, leaf: &trillian.LogLeaf{LeafValue: []byte("1234"), ExtraData: []byte("12")}},
+		{desc: "default value too large", tree: def, leaf: &trillian.LogLeaf{LeafValue: []byte("12345")}, wantErr: true},
+		{desc: "default extra data too large", tree: def, leaf: &trillian.LogLeaf{ExtraData: []byte("123")}, wantErr: true},
+		{desc: "per-tree ok", tree: perTree, leaf: &trillian.LogLeaf{LeafValue: []byte("12345678")}},
+		{desc: "per-tree value too large", tree: perTree, leaf: &trillian.LogLeaf{LeafValue: []byte("123456789")}, wantErr: true},
+		{desc: "per-tree default extra data too large", tree: perTree, leaf: &trillian.LogLeaf{ExtraData: []byte("123")}, wantErr: true},
+	} {
+		err := v.ValidateLeaf(context.Background(), test.tree, test.leaf)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: ValidateLeaf(): %v, want error: %v", test.desc, err, test.wantErr)
+		}
+		if err != nil && status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%v: ValidateLeaf(): %v, want code %v", test.desc, err, codes.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validator provides an extension point to inspect, and possibly reject, leaves before
+// they're written to a log.
+//
+// Validators are registered by name, usually from the init function of the package that
+// implements them, and enabled by the log server through its --leaf_validators flag.
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/trillian"
+)
+
+// LeafValidator inspects leaves on the write path of the log server (QueueLeaf and
+// AddSequencedLeaves), before they reach storage.
+type LeafValidator interface {
+	// ValidateLeaf returns an error if leaf must not be added to tree.
+	// gRPC status errors are returned to the caller as is, other errors are returned with
+	// codes.InvalidArgument.
+	ValidateLeaf(ctx context.Context, tree *trillian.Tree, leaf *trillian.LogLeaf) error
+}
+
+// LeafValidatorFunc is an adapter to use ordinary functions as LeafValidators.
+type LeafValidatorFunc func(ctx context.Context, tree *trillian.Tree, leaf *trillian.LogLeaf) error
+
+// ValidateLeaf calls f(ctx, tree, leaf).
+func (f LeafValidatorFunc) ValidateLeaf(ctx context.Context, tree *trillian.Tree, leaf *trillian.LogLeaf) error {
+	return f(ctx, tree, leaf)
+}
+
+// Chain returns a LeafValidator that runs validators in order, failing on the first error.
+func Chain(validators ...LeafValidator) LeafValidator {
+	return LeafValidatorFunc(func(ctx context.Context, tree *trillian.Tree, leaf *trillian.LogLeaf) error {
+		for _, v := range validators {
+			if err := v.ValidateLeaf(ctx, tree, leaf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var (
+	lvMu     sync.RWMutex
+	lvByName map[string]LeafValidator
+)
+
+// Register registers a LeafValidator under name.
+func Register(name string, v LeafValidator) error {
+	lvMu.Lock()
+	defer lvMu.Unlock()
+
+	if lvByName == nil {
+		lvByName = make(map[string]LeafValidator)
+	}
+
+	if _, exists := lvByName[name]; exists {
+		return fmt.Errorf("leaf validator %v already registered", name)
+	}
+	lvByName[name] = v
+	return nil
+}
+
+// Validators returns a sorted slice of registered leaf validator names.
+func Validators() []string {
+	lvMu.RLock()
+	defer lvMu.RUnlock()
+
+	r := []string{}
+	for k := range lvByName {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// New returns a LeafValidator that chains the validators registered under names, in order.
+// It returns nil if names is empty.
+func New(names []string) (LeafValidator, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	lvMu.RLock()
+	defer lvMu.RUnlock()
+
+	validators := make([]LeafValidator, 0, len(names))
+	for _, name := range names {
+		v, exists := lvByName[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown leaf validator: %v", name)
+		}
+		validators = append(validators, v)
+	}
+	return Chain(validators...), nil
+}
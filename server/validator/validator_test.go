@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+)
+
+func TestNew(t *testing.T) {
+	var calls []string
+	validator := func(name string, err error) LeafValidator {
+		return LeafValidatorFunc(func(context.Context, *trillian.Tree, *trillian.LogLeaf) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+	for name, v := range map[string]LeafValidator{
+		"test_ok1": validator("test_ok1", nil),
+		"test_ok2": validator("test_ok2", nil),
+		"test_bad": validator("test_bad", errors.New("bad leaf")),
+	} {
+		if err := Register(name, v); err != nil {
+			t.Fatalf("Register(%q): %v", name, err)
+		}
+	}
+	if err := Register("test_ok1", validator("dup", nil)); err == nil {
+		t.Error("Register() of duplicate name succeeded, want error")
+	}
+	if got, want := Validators(), []string{"test_bad", "test_ok1", "test_ok2"}; !cmp.Equal(got, want) {
+		t.Errorf("Validators() = %v, want %v", got, want)
+	}
+
+	for _, test := range []struct {
+		desc      string
+		names     []string
+		wantNil   bool
+		wantErr   bool
+		wantCalls []string
+		wantBad   bool
+	}{
+		{desc: "none", wantNil: true},
+		{desc: "unknown", names: []string{"test_ok1", "unknown"}, wantErr: true},
+		{desc: "ok", names: []string{"test_ok2", "test_ok1"}, wantCalls: []string{"test_ok2", "test_ok1"}},
+		{desc: "bad", names: []string{"test_ok1", "test_bad", "test_ok2"}, wantCalls: []string{"test_ok1", "test_bad"}, wantBad: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			calls = nil
+			v, err := New(test.names)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("New(%v): %v, want error: %v", test.names, err, test.wantErr)
+			}
+			if gotNil := v == nil; gotNil != (test.wantNil || test.wantErr) {
+				t.Fatalf("New(%v) = %v, want nil: %v", test.names, v, test.wantNil)
+			}
+			if v == nil {
+				return
+			}
+			err = v.ValidateLeaf(context.Background(), &trillian.Tree{}, &trillian.LogLeaf{})
+			if gotBad := err != nil; gotBad != test.wantBad {
+				t.Errorf("ValidateLeaf(): %v, want error: %v", err, test.wantBad)
+			}
+			if diff := cmp.Diff(calls, test.wantCalls); diff != "" {
+				t.Errorf("ValidateLeaf() calls diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -104,3 +104,29 @@ type AdminWriter interface {
 	// is returned.
 	UndeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error)
 }
+
+// TreeUsage is the approximate number of bytes stored for a tree.
+type TreeUsage struct {
+	// LeafDataBytes is the size of the leaves, including their values and
+	// extra data, whether they're sequenced or still queued.
+	LeafDataBytes int64
+	// SubtreeBytes is the size of the Merkle subtrees, across all revisions.
+	SubtreeBytes int64
+	// QueueBytes is the size of the queue entries of unsequenced leaves.
+	QueueBytes int64
+}
+
+// Add adds the bytes of o to u.
+func (u *TreeUsage) Add(o TreeUsage) {
+	u.LeafDataBytes += o.LeafDataBytes
+	u.SubtreeBytes += o.SubtreeBytes
+	u.QueueBytes += o.QueueBytes
+}
+
+// UsageReporter is optionally implemented by AdminStorage implementations
+// whose log storage keeps track of the bytes it stores for each tree.
+type UsageReporter interface {
+	// TreeUsage returns the approximate number of bytes stored for the tree,
+	// which is zero for trees nothing was written to.
+	TreeUsage(ctx context.Context, treeID int64) (TreeUsage, error)
+}
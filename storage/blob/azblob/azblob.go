@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azblob provides a blob store backed by Azure Blob Storage, opened
+// with URLs like azblob://container/prefix.
+//
+// The storage account is named by the AZURE_STORAGE_ACCOUNT environment
+// variable, and accessed with the shared key in AZURE_STORAGE_KEY or the SAS
+// token in AZURE_STORAGE_SAS_TOKEN. The endpoint URL parameter overrides the
+// service URL of the account, e.g. for the Azurite emulator.
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/blob"
+)
+
+// Scheme is the URL scheme of Azure Blob Storage containers.
+const Scheme = "azblob"
+
+func init() {
+	if err := blob.Register(Scheme, open); err != nil {
+		glog.Fatalf("Failed to register blob store %v: %v", Scheme, err)
+	}
+}
+
+func open(_ context.Context, u *url.URL) (blob.Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%v: missing container, want %s://container/prefix", u, Scheme)
+	}
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if account == "" {
+		return nil, errors.New("AZURE_STORAGE_ACCOUNT is not set")
+	}
+	serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		serviceURL = strings.TrimSuffix(endpoint, "/")
+	}
+	containerURL := serviceURL + "/" + u.Host
+
+	var client *azblob.ContainerClient
+	var err error
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		cred, cerr := azblob.NewSharedKeyCredential(account, key)
+		if cerr != nil {
+			return nil, fmt.Errorf("invalid AZURE_STORAGE_KEY: %v", cerr)
+		}
+		client, err = azblob.NewContainerClientWithSharedKey(containerURL, cred, nil)
+	} else if sas := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); sas != "" {
+		client, err = azblob.NewContainerClientWithNoCredential(containerURL+"?"+strings.TrimPrefix(sas, "?"), nil)
+	} else {
+		return nil, errors.New("neither AZURE_STORAGE_KEY nor AZURE_STORAGE_SAS_TOKEN is set")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Blob Storage client: %v", err)
+	}
+	return &store{client: client, prefix: blob.Prefix(u)}, nil
+}
+
+type store struct {
+	client *azblob.ContainerClient
+	prefix string
+}
+
+func (s *store) Put(ctx context.Context, key string, data []byte) error {
+	bb, err := s.client.NewBlockBlobClient(s.prefix + key)
+	if err != nil {
+		return err
+	}
+	_, err = bb.UploadBuffer(ctx, data, azblob.UploadOption{})
+	return err
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.client.NewBlobClient(s.prefix + key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.Download(ctx, nil)
+	var serr *azblob.StorageError
+	if errors.As(err, &serr) && serr.ErrorCode == azblob.StorageErrorCodeBlobNotFound {
+		return nil, fmt.Errorf("%q: %w", key, blob.ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	body := resp.Body(nil)
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+func (s *store) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blob provides object stores which storage implementations can use
+// to keep large leaf values and extra data out of their databases.
+//
+// Stores are opened by URL, e.g. gs://bucket/prefix, and the scheme of the URL
+// selects the implementation. Implementations register themselves when their
+// packages are imported, similarly to storage providers.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/google/trillian"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotFound is returned by Store.Get for keys without a blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is an object store holding blobs by key. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	// Put stores data under key, replacing any blob already stored there.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the blob stored under key, or an error wrapping ErrNotFound
+	// if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// OpenFunc is the signature of a function which can be registered to open
+// stores with URLs of a scheme.
+type OpenFunc func(ctx context.Context, u *url.URL) (Store, error)
+
+var (
+	openMu       sync.RWMutex
+	openByScheme = make(map[string]OpenFunc)
+)
+
+// Register registers the function opening stores with URLs of the given scheme.
+func Register(scheme string, open OpenFunc) error {
+	openMu.Lock()
+	defer openMu.Unlock()
+
+	if _, exists := openByScheme[scheme]; exists {
+		return fmt.Errorf("blob store scheme %v already registered", scheme)
+	}
+	openByScheme[scheme] = open
+	return nil
+}
+
+// Schemes returns the registered URL schemes, sorted.
+func Schemes() []string {
+	openMu.RLock()
+	defer openMu.RUnlock()
+
+	r := make([]string, 0, len(openByScheme))
+	for k := range openByScheme {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// Open opens the store at the given URL, with the implementation registered
+// for its scheme.
+func Open(ctx context.Context, rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob store URL %q: %v", rawURL, err)
+	}
+	openMu.RLock()
+	open := openByScheme[u.Scheme]
+	openMu.RUnlock()
+	if open == nil {
+		return nil, fmt.Errorf("no blob store for scheme %q of %q, want one of %v", u.Scheme, rawURL, Schemes())
+	}
+	return open(ctx, u)
+}
+
+// LeafKey returns the key of the blob of a leaf, which is addressed by its
+// LeafIdentityHash within its tree.
+func LeafKey(treeID int64, leafIdentityHash []byte) string {
+	return fmt.Sprintf("%d/%x", treeID, leafIdentityHash)
+}
+
+// MarshalLeaf returns the blob holding the LeafValue and ExtraData of leaf.
+func MarshalLeaf(leaf *trillian.LogLeaf) ([]byte, error) {
+	return proto.Marshal(&trillian.LogLeaf{LeafValue: leaf.LeafValue, ExtraData: leaf.ExtraData})
+}
+
+// UnmarshalLeaf sets the LeafValue and ExtraData of leaf from a blob returned
+// by MarshalLeaf.
+func UnmarshalLeaf(data []byte, leaf *trillian.LogLeaf) error {
+	var stored trillian.LogLeaf
+	if err := proto.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid leaf blob: %v", err)
+	}
+	leaf.LeafValue, leaf.ExtraData = stored.LeafValue, stored.ExtraData
+	return nil
+}
+
+// Prefix returns the key prefix given by the path of a store URL, which is
+// empty or ends with a slash.
+func Prefix(u *url.URL) string {
+	p := u.Path
+	for len(p) > 0 && p[0] == '/' {
+		p = p[1:]
+	}
+	if p != "" && p[len(p)-1] != '/' {
+		p += "/"
+	}
+	return p
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blob
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/google/trillian"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	var opened *url.URL
+	if err := Register("test", func(_ context.Context, u *url.URL) (Store, error) {
+		opened = u
+		return NewMemoryStore(), nil
+	}); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+	if err := Register("test", nil); err == nil {
+		t.Error("Register() twice succeeded, want error")
+	}
+
+	if _, err := Open(ctx, "test://bucket/some/prefix"); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	if got, want := opened.Host, "bucket"; got != want {
+		t.Errorf("Open() host %q, want %q", got, want)
+	}
+	if got, want := Prefix(opened), "some/prefix/"; got != want {
+		t.Errorf("Prefix()=%q, want %q", got, want)
+	}
+	for _, u := range []string{"unknown://bucket", "bucket/prefix", "test://%zz"} {
+		if _, err := Open(ctx, u); err == nil {
+			t.Errorf("Open(%q) succeeded, want error", u)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{url: "gs://bucket", want: ""},
+		{url: "gs://bucket/", want: ""},
+		{url: "gs://bucket/a", want: "a/"},
+		{url: "gs://bucket/a/b/", want: "a/b/"},
+	} {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", test.url, err)
+		}
+		if got := Prefix(u); got != test.want {
+			t.Errorf("Prefix(%q)=%q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestLeafRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	leaf := &trillian.LogLeaf{
+		LeafIdentityHash: []byte{0x0a, 0x0b},
+		LeafValue:        []byte("value"),
+		ExtraData:        []byte("extra"),
+		LeafIndex:        7,
+	}
+	key := LeafKey(42, leaf.LeafIdentityHash)
+	if got, want := key, "42/0a0b"; got != want {
+		t.Errorf("LeafKey()=%q, want %q", got, want)
+	}
+	data, err := MarshalLeaf(leaf)
+	if err != nil {
+		t.Fatalf("MarshalLeaf(): %v", err)
+	}
+	if err := s.Put(ctx, key, data); err != nil {
+		t.Fatalf("Put(): %v", err)
+	}
+
+	if _, err := s.Get(ctx, LeafKey(43, leaf.LeafIdentityHash)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of missing blob: %v, want ErrNotFound", err)
+	}
+	got, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	read := &trillian.LogLeaf{LeafIdentityHash: leaf.LeafIdentityHash, LeafIndex: leaf.LeafIndex}
+	if err := UnmarshalLeaf(got, read); err != nil {
+		t.Fatalf("UnmarshalLeaf(): %v", err)
+	}
+	if !proto.Equal(read, leaf) {
+		t.Errorf("UnmarshalLeaf()=%v, want %v", read, leaf)
+	}
+	if err := UnmarshalLeaf([]byte{0xff}, read); err == nil {
+		t.Error("UnmarshalLeaf() of invalid blob succeeded, want error")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcs provides a blob store backed by Google Cloud Storage, opened
+// with URLs like gs://bucket/prefix. Credentials are found with Application
+// Default Credentials.
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/blob"
+)
+
+// Scheme is the URL scheme of Google Cloud Storage buckets.
+const Scheme = "gs"
+
+func init() {
+	if err := blob.Register(Scheme, open); err != nil {
+		glog.Fatalf("Failed to register blob store %v: %v", Scheme, err)
+	}
+}
+
+func open(ctx context.Context, u *url.URL) (blob.Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%v: missing bucket, want %s://bucket/prefix", u, Scheme)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Storage client: %v", err)
+	}
+	return &store{client: client, bucket: client.Bucket(u.Host), prefix: blob.Prefix(u)}, nil
+}
+
+type store struct {
+	client *storage.Client
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+func (s *store) Put(ctx context.Context, key string, data []byte) error {
+	w := s.bucket.Object(s.prefix + key).NewWriter(ctx)
+	// Leaf blobs are small enough to be sent in a single request.
+	w.ChunkSize = 0
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.bucket.Object(s.prefix + key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%q: %w", key, blob.ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (s *store) Close() error {
+	return s.client.Close()
+}
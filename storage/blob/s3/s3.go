@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3 provides a blob store backed by Amazon S3, or S3 compatible
+// services, opened with URLs like s3://bucket/prefix. Credentials and the
+// region are found like the AWS CLI finds them, and the region and the
+// endpoint can be overridden with the region and endpoint URL parameters, e.g.
+// s3://bucket/prefix?region=us-east-1&endpoint=http://localhost:9000.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/blob"
+)
+
+// Scheme is the URL scheme of S3 buckets.
+const Scheme = "s3"
+
+func init() {
+	if err := blob.Register(Scheme, open); err != nil {
+		glog.Fatalf("Failed to register blob store %v: %v", Scheme, err)
+	}
+}
+
+func open(_ context.Context, u *url.URL) (blob.Store, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%v: missing bucket, want %s://bucket/prefix", u, Scheme)
+	}
+	cfg := aws.NewConfig()
+	q := u.Query()
+	if region := q.Get("region"); region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	if endpoint := q.Get("endpoint"); endpoint != "" {
+		// S3 compatible services are usually addressed by path rather than by
+		// virtual host.
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return &store{client: s3.New(sess), bucket: u.Host, prefix: blob.Prefix(u)}, nil
+}
+
+type store struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func (s *store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, fmt.Errorf("%q: %w", key, blob.ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
+
+func (s *store) Close() error {
+	return nil
+}
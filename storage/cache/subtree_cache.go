@@ -16,10 +16,13 @@ package cache
 
 import (
 	"bytes"
+	"container/list"
 	"flag"
 	"fmt"
+	"strconv"
 	"sync"
 
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/tree"
 	"github.com/transparency-dev/merkle"
@@ -30,6 +33,55 @@ import (
 // TODO(al): move this up the stack
 var populateConcurrency = flag.Int("populate_subtree_concurrency", 256, "Max number of concurrent workers concurrently populating subtrees")
 
+var (
+	maxTiles = flag.Int("subtree_cache_max_tiles", 0, "Max number of unmodified tiles kept by the subtree cache of a transaction, 0 for no limit")
+	maxBytes = flag.Int64("subtree_cache_max_bytes", 64<<20, "Max approximate size in bytes of the tiles kept by the subtree cache of a transaction, 0 for no limit. Modified tiles are kept until written regardless")
+)
+
+// tileOverhead approximates the memory used by a cached tile besides its
+// hashes, and nodeOverhead that used by each node besides its hash.
+const (
+	tileOverhead = 256
+	nodeOverhead = 64
+)
+
+const logIDLabel = "logid"
+
+var (
+	metricsOnce sync.Once
+	hits        monitoring.Counter
+	misses      monitoring.Counter
+	evictions   monitoring.Counter
+)
+
+// InitMetrics sets up the metrics reported by SubtreeCaches. Can be called
+// more than once, but only the first call has any effect. If it isn't called,
+// the metrics are not exported.
+func InitMetrics(mf monitoring.MetricFactory) {
+	metricsOnce.Do(func() {
+		if mf == nil {
+			mf = monitoring.InertMetricFactory{}
+		}
+		hits = mf.NewCounter("subtree_cache_hits", "Number of tiles found in the subtree cache", logIDLabel)
+		misses = mf.NewCounter("subtree_cache_misses", "Number of tiles read from storage by the subtree cache", logIDLabel)
+		evictions = mf.NewCounter("subtree_cache_evictions", "Number of tiles evicted from the subtree cache to bound its size", logIDLabel)
+	})
+}
+
+// Options holds the optional settings of a SubtreeCache.
+type Options struct {
+	// TreeID labels the metrics reported by the cache.
+	TreeID int64
+	// MaxTiles is the maximum number of tiles kept by the cache. The value of
+	// the --subtree_cache_max_tiles flag is used if it is zero, and there is no
+	// limit if it is negative.
+	MaxTiles int
+	// MaxBytes is the maximum approximate size in bytes of the tiles kept by
+	// the cache. The value of the --subtree_cache_max_bytes flag is used if it
+	// is zero, and there is no limit if it is negative.
+	MaxBytes int64
+}
+
 // TODO(pavelkalinnikov): Rename subtrees to tiles.
 
 // GetSubtreesFunc describes a function which can return a number of Subtrees from storage.
@@ -40,6 +92,11 @@ type GetSubtreesFunc func(ids [][]byte) ([]*storagepb.SubtreeProto, error)
 // of depth 8. It is not possible to just change the constants above and have things still
 // work. This is because of issues like byte packing of node IDs.
 //
+// The cache is bounded in number of tiles and in size. Once a call to GetNodes
+// or SetNodes leaves it over its bounds, the least recently used tiles which
+// haven't been modified are evicted, and read again from storage if needed.
+// Modified tiles are kept until written.
+//
 // SubtreeCache is not thread-safe: GetNodes, SetNodes and Flush methods must
 // be called sequentially.
 type SubtreeCache struct {
@@ -47,26 +104,59 @@ type SubtreeCache struct {
 
 	// subtrees contains the Subtree data read from storage, and is updated by
 	// calls to SetNodes.
-	subtrees map[string]*storagepb.SubtreeProto
+	subtrees map[string]*cachedTile
+	// lru orders the IDs of the cached tiles from the most to the least
+	// recently used.
+	lru *list.List
+	// size is the approximate size in bytes of the cached tiles.
+	size int64
 	// dirtyPrefixes keeps track of all Subtrees which need to be written back
 	// to storage.
 	dirtyPrefixes map[string]bool
 
 	// populateConcurrency sets the amount of concurrency when repopulating subtrees.
 	populateConcurrency int
+
+	maxTiles int
+	maxBytes int64
+	label    string
+}
+
+// cachedTile is a tile held by a SubtreeCache, with its approximate size and
+// its position in the LRU list.
+type cachedTile struct {
+	tile *storagepb.SubtreeProto
+	size int64
+	elem *list.Element
 }
 
 // NewLogSubtreeCache creates and returns a SubtreeCache appropriate for use with a log
 // tree. The caller must supply a suitable LogHasher.
 func NewLogSubtreeCache(hasher merkle.LogHasher) *SubtreeCache {
+	return NewLogSubtreeCacheWithOpts(hasher, Options{})
+}
+
+// NewLogSubtreeCacheWithOpts creates and returns a SubtreeCache appropriate
+// for use with a log tree, with the given options.
+func NewLogSubtreeCacheWithOpts(hasher merkle.LogHasher, opts Options) *SubtreeCache {
 	if *populateConcurrency <= 0 {
 		panic(fmt.Errorf("populate_subtree_concurrency must be set to >= 1"))
 	}
+	if opts.MaxTiles == 0 {
+		opts.MaxTiles = *maxTiles
+	}
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = *maxBytes
+	}
 	return &SubtreeCache{
 		hasher:              hasher,
-		subtrees:            make(map[string]*storagepb.SubtreeProto),
+		subtrees:            make(map[string]*cachedTile),
+		lru:                 list.New(),
 		dirtyPrefixes:       make(map[string]bool),
 		populateConcurrency: *populateConcurrency,
+		maxTiles:            opts.MaxTiles,
+		maxBytes:            opts.MaxBytes,
+		label:               strconv.FormatInt(opts.TreeID, 10),
 	}
 }
 
@@ -76,12 +166,20 @@ func NewLogSubtreeCache(hasher merkle.LogHasher) *SubtreeCache {
 func (s *SubtreeCache) preload(ids []compact.NodeID, getSubtrees GetSubtreesFunc) ([]string, error) {
 	// Figure out the set of subtrees we need.
 	want := make(map[string]bool)
+	hit := make(map[string]bool)
 	for _, id := range ids {
 		subID := string(getTileID(id))
-		if _, ok := s.subtrees[subID]; !ok {
+		if c, ok := s.subtrees[subID]; ok {
+			s.lru.MoveToFront(c.elem)
+			hit[subID] = true
+		} else {
 			want[subID] = true
 		}
 	}
+	if hits != nil {
+		hits.Add(float64(len(hit)), s.label)
+		misses.Add(float64(len(want)), s.label)
+	}
 	// Don't make a read request for zero subtrees.
 	if len(want) == 0 {
 		return nil, nil
@@ -141,16 +239,60 @@ func (s *SubtreeCache) preload(ids []compact.NodeID, getSubtrees GetSubtreesFunc
 }
 
 func (s *SubtreeCache) cacheSubtree(t *storagepb.SubtreeProto) error {
-	if subtree, ok := s.subtrees[string(t.Prefix)]; ok {
-		if !proto.Equal(t, subtree) {
+	if c, ok := s.subtrees[string(t.Prefix)]; ok {
+		if !proto.Equal(t, c.tile) {
 			return fmt.Errorf("at %x: subtree mismatch", t.Prefix)
 		}
 		return nil
 	}
-	s.subtrees[string(t.Prefix)] = t
+	c := &cachedTile{tile: t, size: tileSize(t), elem: s.lru.PushFront(string(t.Prefix))}
+	s.subtrees[string(t.Prefix)] = c
+	s.size += c.size
 	return nil
 }
 
+// resize updates the size of a cached tile after it was modified.
+func (s *SubtreeCache) resize(c *cachedTile) {
+	size := tileSize(c.tile)
+	s.size += size - c.size
+	c.size = size
+}
+
+// evict removes the least recently used tiles which haven't been modified
+// until the cache is within its bounds, or only modified tiles are left.
+func (s *SubtreeCache) evict() {
+	over := func() bool {
+		return (s.maxTiles > 0 && len(s.subtrees) > s.maxTiles) || (s.maxBytes > 0 && s.size > s.maxBytes)
+	}
+	var evicted int
+	for e := s.lru.Back(); e != nil && over(); {
+		id := e.Value.(string)
+		prev := e.Prev()
+		if !s.dirtyPrefixes[id] {
+			s.lru.Remove(e)
+			s.size -= s.subtrees[id].size
+			delete(s.subtrees, id)
+			evicted++
+		}
+		e = prev
+	}
+	if evicted > 0 && evictions != nil {
+		evictions.Add(float64(evicted), s.label)
+	}
+}
+
+// tileSize returns the approximate memory used by a tile.
+func tileSize(t *storagepb.SubtreeProto) int64 {
+	size := int64(tileOverhead + len(t.Prefix))
+	for k, v := range t.Leaves {
+		size += int64(nodeOverhead + len(k) + len(v))
+	}
+	for k, v := range t.InternalNodes {
+		size += int64(nodeOverhead + len(k) + len(v))
+	}
+	return size
+}
+
 // GetNodes returns the requested nodes, calling the getSubtrees function if
 // they are not already cached.
 func (s *SubtreeCache) GetNodes(ids []compact.NodeID, getSubtrees GetSubtreesFunc) ([]tree.Node, error) {
@@ -159,6 +301,7 @@ func (s *SubtreeCache) GetNodes(ids []compact.NodeID, getSubtrees GetSubtreesFun
 	} else if r := len(notFound); r != 0 {
 		return nil, fmt.Errorf("preload did not get all tiles: %d not found", r)
 	}
+	defer s.evict()
 
 	ret := make([]tree.Node, 0, len(ids))
 	for _, id := range ids {
@@ -174,10 +317,11 @@ func (s *SubtreeCache) GetNodes(ids []compact.NodeID, getSubtrees GetSubtreesFun
 // getNodeHash returns a single node hash from the cache.
 func (s *SubtreeCache) getNodeHash(id compact.NodeID) ([]byte, error) {
 	subID, sx := splitID(id)
-	c := s.subtrees[string(subID)]
-	if c == nil {
+	cached := s.subtrees[string(subID)]
+	if cached == nil {
 		return nil, fmt.Errorf("tile %x not found", subID)
 	}
+	c := cached.tile
 
 	// Look up the hash in the appropriate map.
 	// The leaf hashes are stored in a separate map to the internal nodes so that
@@ -202,15 +346,25 @@ func (s *SubtreeCache) SetNodes(nodes []tree.Node, getSubtrees GetSubtreesFunc)
 		return err
 	}
 	for _, id := range notFound {
-		s.subtrees[id] = newEmptyTile([]byte(id))
+		if err := s.cacheSubtree(newEmptyTile([]byte(id))); err != nil {
+			return err
+		}
 	}
+	defer s.evict()
 
+	modified := make(map[string]*cachedTile)
+	defer func() {
+		for _, c := range modified {
+			s.resize(c)
+		}
+	}()
 	for _, n := range nodes {
 		subID, sx := splitID(n.ID)
-		c := s.subtrees[string(subID)]
-		if c == nil {
+		cached := s.subtrees[string(subID)]
+		if cached == nil {
 			return fmt.Errorf("tile %x not found", subID)
 		}
+		c := cached.tile
 
 		// Store the hash to the containing tile, and mark it as dirty if the hash
 		// differs from the previously stored one.
@@ -219,11 +373,13 @@ func (s *SubtreeCache) SetNodes(nodes []tree.Node, getSubtrees GetSubtreesFunc)
 			if !bytes.Equal(c.Leaves[sfxKey], n.Hash) {
 				c.Leaves[sfxKey] = n.Hash
 				s.dirtyPrefixes[string(subID)] = true
+				modified[string(subID)] = cached
 			}
 		} else { // This is an internal node.
 			if !bytes.Equal(c.InternalNodes[sfxKey], n.Hash) {
 				c.InternalNodes[sfxKey] = n.Hash
 				s.dirtyPrefixes[string(subID)] = true
+				modified[string(subID)] = cached
 			}
 		}
 	}
@@ -234,10 +390,11 @@ func (s *SubtreeCache) SetNodes(nodes []tree.Node, getSubtrees GetSubtreesFunc)
 // UpdatedTiles returns all updated tiles that need to be written to storage.
 func (s *SubtreeCache) UpdatedTiles() ([]*storagepb.SubtreeProto, error) {
 	var toWrite []*storagepb.SubtreeProto
-	for k, v := range s.subtrees {
+	for k, c := range s.subtrees {
 		if !s.dirtyPrefixes[k] {
 			continue
 		}
+		v := c.tile
 		if !bytes.Equal([]byte(k), v.Prefix) {
 			return nil, fmt.Errorf("inconsistent cache: prefix key is %v, but cached object claims %v", k, v.Prefix)
 		}
@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/testonly"
 	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/tree"
 	"github.com/transparency-dev/merkle/compact"
@@ -335,3 +337,83 @@ func TestIdempotentWrites(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheEviction(t *testing.T) {
+	InitMetrics(monitoring.InertMetricFactory{})
+	for _, tc := range []struct {
+		desc string
+		opts Options
+	}{
+		{desc: "max-tiles", opts: Options{TreeID: 1, MaxTiles: 2, MaxBytes: -1}},
+		{desc: "max-bytes", opts: Options{TreeID: 2, MaxTiles: -1, MaxBytes: 2*tileSize(newEmptyTile(make([]byte, 7))) + 100}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			label := fmt.Sprint(tc.opts.TreeID)
+			hitsBefore, missesBefore := testonly.NewCounterSnapshot(hits, label), testonly.NewCounterSnapshot(misses, label)
+			evictionsBefore := testonly.NewCounterSnapshot(evictions, label)
+
+			c := NewLogSubtreeCacheWithOpts(rfc6962.DefaultHasher, tc.opts)
+			reads := make(map[string]int)
+			get := func(ids [][]byte) ([]*storagepb.SubtreeProto, error) {
+				ret := make([]*storagepb.SubtreeProto, 0, len(ids))
+				for _, id := range ids {
+					reads[string(id)]++
+					ret = append(ret, newEmptyTile(id))
+				}
+				return ret, nil
+			}
+			// Tile i holds the leaves [256*i, 256*(i+1)).
+			getTile := func(i uint64) {
+				t.Helper()
+				if _, err := c.GetNodes([]compact.NodeID{compact.NewNodeID(0, i<<8)}, get); err != nil {
+					t.Fatalf("GetNodes: %v", err)
+				}
+			}
+			tileID := func(i uint64) string {
+				return string(getTileID(compact.NewNodeID(0, i<<8)))
+			}
+
+			for i := uint64(0); i < 3; i++ {
+				getTile(i)
+			}
+			getTile(2)
+			getTile(1)
+			if got, want := len(reads), 3; got != want {
+				t.Errorf("Read %d tiles, want %d", got, want)
+			}
+			// Tile 0 is the least recently used, so it was evicted.
+			getTile(0)
+			if got, want := reads[tileID(0)], 2; got != want {
+				t.Errorf("Read tile 0 %d times, want %d", got, want)
+			}
+			if got, want := len(c.subtrees), 2; got != want {
+				t.Errorf("Cached %d tiles, want %d", got, want)
+			}
+
+			// Modified tiles are kept until written.
+			if err := c.SetNodes([]tree.Node{{ID: compact.NewNodeID(0, 3<<8), Hash: []byte("hash")}}, get); err != nil {
+				t.Fatalf("SetNodes: %v", err)
+			}
+			for i := uint64(4); i < 7; i++ {
+				getTile(i)
+			}
+			tiles, err := c.UpdatedTiles()
+			if err != nil {
+				t.Fatalf("UpdatedTiles: %v", err)
+			}
+			if got, want := len(tiles), 1; got != want || string(tiles[0].Prefix) != tileID(3) {
+				t.Errorf("UpdatedTiles returned %d tiles, want %d with prefix %x", got, want, tileID(3))
+			}
+
+			if got, want := hitsBefore.Delta(), 2.0; got != want {
+				t.Errorf("Counted %v hits, want %v", got, want)
+			}
+			if got, want := missesBefore.Delta(), 8.0; got != want {
+				t.Errorf("Counted %v misses, want %v", got, want)
+			}
+			if got, want := evictionsBefore.Delta(), 6.0; got != want {
+				t.Errorf("Counted %v evictions, want %v", got, want)
+			}
+		})
+	}
+}
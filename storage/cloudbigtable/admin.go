@@ -0,0 +1,440 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	// ErrTransactionClosed is returned by interface methods when an operation is
+	// attempted on a transaction whose Commit or Close methods have already been
+	// called.
+	ErrTransactionClosed = errors.New("transaction is closed")
+
+	// ErrReadOnlyTransaction is returned when a write is attempted in a
+	// snapshot.
+	ErrReadOnlyTransaction = errors.New("write in a read-only transaction")
+
+	// TimeNow is the function used to get the current time. Exposed so it may
+	// be mocked by tests.
+	TimeNow = time.Now
+)
+
+// deleteBatchSize is the number of rows deleted by each bulk mutation when
+// deleting the data of a tree.
+const deleteBatchSize = 1000
+
+// adminStorage implements storage.AdminStorage.
+type adminStorage struct {
+	tbl *bigtable.Table
+}
+
+// NewAdminStorage returns a Bigtable-based storage.AdminStorage using tbl,
+// which must have the column families set up by CreateTable.
+func NewAdminStorage(tbl *bigtable.Table) storage.AdminStorage {
+	return &adminStorage{tbl: tbl}
+}
+
+// CheckDatabaseAccessible implements AdminStorage.CheckDatabaseAccessible.
+func (s *adminStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return checkTableAccessible(ctx, s.tbl)
+}
+
+// Snapshot implements AdminStorage.Snapshot.
+func (s *adminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return &adminTX{tbl: s.tbl, readonly: true}, nil
+}
+
+// ReadWriteTransaction implements AdminStorage.ReadWriteTransaction.
+func (s *adminStorage) ReadWriteTransaction(ctx context.Context, f storage.AdminTXFunc) error {
+	tx := &adminTX{tbl: s.tbl, writes: make(map[int64]*treeWrite)}
+	defer tx.Close()
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+	return tx.commit(ctx)
+}
+
+// treeWrite is a buffered write of a tree.
+type treeWrite struct {
+	// tree is the new value of the tree, or nil if it is hard deleted.
+	tree *trillian.Tree
+	// ver is the version of the tree row read by the transaction, or empty if
+	// the tree is created by it.
+	ver string
+}
+
+// adminTX implements both storage.ReadOnlyAdminTX and storage.AdminTX.
+//
+// Writes are buffered until the transaction commits, when each tree row is
+// updated conditionally on it not having changed since it was read. Updates
+// to several trees are not atomic.
+type adminTX struct {
+	tbl      *bigtable.Table
+	readonly bool
+
+	// mu guards closed and writes.
+	mu     sync.Mutex
+	closed bool
+	writes map[int64]*treeWrite
+}
+
+// Commit implements ReadOnlyAdminTX.Commit.
+func (t *adminTX) Commit() error {
+	return t.Close()
+}
+
+// Close implements ReadOnlyAdminTX.Close.
+func (t *adminTX) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return nil
+}
+
+// commit applies the buffered writes.
+func (t *adminTX) commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	t.closed = true
+
+	for id, w := range t.writes {
+		if err := t.applyWrite(ctx, id, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *adminTX) applyWrite(ctx context.Context, treeID int64, w *treeWrite) error {
+	key := treeRowKey(treeID)
+	set := bigtable.NewMutation()
+	if w.tree == nil {
+		set.DeleteRow()
+	} else {
+		treeBytes, err := proto.Marshal(w.tree)
+		if err != nil {
+			return err
+		}
+		set.Set(familyData, colTree, cellTime, treeBytes)
+		set.Set(familyData, colVersion, cellTime, []byte(nextVersion(w.ver)))
+	}
+
+	var m *bigtable.Mutation
+	var wantMatch bool
+	if w.ver == "" {
+		m = bigtable.NewCondMutation(versionFilter(""), nil, set)
+	} else {
+		m = bigtable.NewCondMutation(versionFilter(w.ver), set, nil)
+		wantMatch = true
+	}
+	var matched bool
+	if err := t.tbl.Apply(ctx, key, m, bigtable.GetCondMutationResult(&matched)); err != nil {
+		return fmt.Errorf("failed to write tree %d: %v", treeID, err)
+	}
+	if matched != wantMatch {
+		return status.Errorf(codes.Aborted, "tree %d was concurrently modified", treeID)
+	}
+	if w.tree == nil {
+		return deleteRows(ctx, t.tbl, treePrefix(treeID))
+	}
+	return nil
+}
+
+// GetTree implements ReadOnlyAdminTX.GetTree.
+func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	tree, _, err := t.readTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(tree).(*trillian.Tree), nil
+}
+
+// readTree returns the tree treeID as seen by the transaction, along with the
+// version of its row, which is empty if the tree was created by it.
+func (t *adminTX) readTree(ctx context.Context, treeID int64) (*trillian.Tree, string, error) {
+	t.mu.Lock()
+	w, ok := t.writes[treeID]
+	t.mu.Unlock()
+	if ok {
+		if w.tree == nil {
+			return nil, "", status.Errorf(codes.NotFound, "tree %v not found", treeID)
+		}
+		return w.tree, w.ver, nil
+	}
+
+	row, err := t.tbl.ReadRow(ctx, treeRowKey(treeID), bigtable.RowFilter(bigtable.FamilyFilter(familyData)))
+	if err != nil {
+		return nil, "", err
+	}
+	cells := rowCells(row, familyData)
+	treeBytes, ok := cells[colTree]
+	if !ok {
+		return nil, "", status.Errorf(codes.NotFound, "tree %v not found", treeID)
+	}
+	tree := &trillian.Tree{}
+	if err := proto.Unmarshal(treeBytes, tree); err != nil {
+		return nil, "", fmt.Errorf("failed to parse tree %d: %v", treeID, err)
+	}
+	return tree, string(cells[colVersion]), nil
+}
+
+// ListTrees implements ReadOnlyAdminTX.ListTrees.
+func (t *adminTX) ListTrees(ctx context.Context, includeDeleted bool) ([]*trillian.Tree, error) {
+	return listTrees(ctx, t.tbl, includeDeleted)
+}
+
+func listTrees(ctx context.Context, tbl *bigtable.Table, includeDeleted bool) ([]*trillian.Tree, error) {
+	trees := []*trillian.Tree{}
+	var parseErr error
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(familyData), bigtable.ColumnFilter(colTree))
+	if err := tbl.ReadRows(ctx, bigtable.PrefixRange(treesPrefix), func(r bigtable.Row) bool {
+		tree := &trillian.Tree{}
+		if parseErr = proto.Unmarshal(rowCells(r, familyData)[colTree], tree); parseErr != nil {
+			return false
+		}
+		if includeDeleted || !tree.Deleted {
+			trees = append(trees, tree)
+		}
+		return true
+	}, bigtable.RowFilter(filter)); err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse tree: %v", parseErr)
+	}
+	return trees, nil
+}
+
+// CreateTree implements AdminWriter.CreateTree.
+func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := storage.ValidateTreeForCreation(ctx, tree); err != nil {
+		return nil, err
+	}
+	if err := validateStorageSettings(tree); err != nil {
+		return nil, err
+	}
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := TimeNow()
+	newTree := proto.Clone(tree).(*trillian.Tree)
+	newTree.TreeId = id
+	newTree.CreateTime = timestamppb.New(now)
+	if err := newTree.CreateTime.CheckValid(); err != nil {
+		return nil, err
+	}
+	newTree.UpdateTime = timestamppb.New(now)
+	if err := newTree.UpdateTime.CheckValid(); err != nil {
+		return nil, err
+	}
+
+	if err := t.bufferWrite(id, &treeWrite{tree: newTree}); err != nil {
+		return nil, err
+	}
+	return proto.Clone(newTree).(*trillian.Tree), nil
+}
+
+// UpdateTree implements AdminWriter.UpdateTree.
+func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	return t.updateTree(ctx, treeID, func(tree *trillian.Tree) error {
+		beforeUpdate := proto.Clone(tree).(*trillian.Tree)
+		updateFunc(tree)
+		if err := storage.ValidateTreeForUpdate(ctx, beforeUpdate, tree); err != nil {
+			return err
+		}
+		if err := validateStorageSettings(tree); err != nil {
+			return err
+		}
+		tree.UpdateTime = timestamppb.New(TimeNow())
+		return tree.UpdateTime.CheckValid()
+	})
+}
+
+// SoftDeleteTree implements AdminWriter.SoftDeleteTree.
+func (t *adminTX) SoftDeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(ctx, treeID, func(tree *trillian.Tree) error {
+		if tree.Deleted {
+			return status.Errorf(codes.FailedPrecondition, "tree %v already soft deleted", treeID)
+		}
+		tree.Deleted = true
+		tree.DeleteTime = timestamppb.New(TimeNow())
+		return tree.DeleteTime.CheckValid()
+	})
+}
+
+// UndeleteTree implements AdminWriter.UndeleteTree.
+func (t *adminTX) UndeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(ctx, treeID, func(tree *trillian.Tree) error {
+		if !tree.Deleted {
+			return status.Errorf(codes.FailedPrecondition, "tree %v is not soft deleted", treeID)
+		}
+		tree.Deleted = false
+		tree.DeleteTime = nil
+		return nil
+	})
+}
+
+// HardDeleteTree implements AdminWriter.HardDeleteTree. The data of the tree
+// is deleted when the transaction commits.
+func (t *adminTX) HardDeleteTree(ctx context.Context, treeID int64) error {
+	tree, ver, err := t.readTree(ctx, treeID)
+	if err != nil {
+		return err
+	}
+	if !tree.Deleted {
+		return status.Errorf(codes.FailedPrecondition, "tree %v is not soft deleted", treeID)
+	}
+	return t.bufferWrite(treeID, &treeWrite{ver: ver})
+}
+
+// updateTree applies f to a copy of the tree treeID, and buffers the result
+// to be written at commit.
+func (t *adminTX) updateTree(ctx context.Context, treeID int64, f func(*trillian.Tree) error) (*trillian.Tree, error) {
+	stored, ver, err := t.readTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	tree := proto.Clone(stored).(*trillian.Tree)
+	if err := f(tree); err != nil {
+		return nil, err
+	}
+	if err := t.bufferWrite(treeID, &treeWrite{tree: tree, ver: ver}); err != nil {
+		return nil, err
+	}
+	return proto.Clone(tree).(*trillian.Tree), nil
+}
+
+func (t *adminTX) bufferWrite(treeID int64, w *treeWrite) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	if t.readonly {
+		return ErrReadOnlyTransaction
+	}
+	t.writes[treeID] = w
+	return nil
+}
+
+func validateStorageSettings(tree *trillian.Tree) error {
+	if tree.StorageSettings != nil {
+		return fmt.Errorf("storage_settings not supported, but got %v", tree.StorageSettings)
+	}
+	return nil
+}
+
+// versionFilter returns a filter matching the version cell of a row if its
+// value is ver, or if it exists at all when ver is empty.
+func versionFilter(ver string) bigtable.Filter {
+	filters := []bigtable.Filter{bigtable.FamilyFilter(familyData), bigtable.ColumnFilter(colVersion)}
+	if ver != "" {
+		// Versions are decimal numbers, which need no escaping.
+		filters = append(filters, bigtable.ValueFilter(ver))
+	}
+	return bigtable.ChainFilters(filters...)
+}
+
+// nextVersion returns the version following ver, or the first version if ver
+// is empty.
+func nextVersion(ver string) string {
+	v, _ := strconv.ParseInt(ver, 10, 64)
+	return strconv.FormatInt(v+1, 10)
+}
+
+// rowCells returns the values of the cells of the given family in row, keyed
+// by column. Only the latest version of each column is returned.
+func rowCells(row bigtable.Row, family string) map[string][]byte {
+	cells := make(map[string][]byte)
+	for _, item := range row[family] {
+		col := strings.TrimPrefix(item.Column, family+":")
+		if _, ok := cells[col]; !ok {
+			cells[col] = item.Value
+		}
+	}
+	return cells
+}
+
+// deleteRows deletes all the rows whose key starts with prefix.
+func deleteRows(ctx context.Context, tbl *bigtable.Table, prefix string) error {
+	var keys []string
+	filter := bigtable.ChainFilters(bigtable.CellsPerRowLimitFilter(1), bigtable.StripValueFilter())
+	if err := tbl.ReadRows(ctx, bigtable.PrefixRange(prefix), func(r bigtable.Row) bool {
+		keys = append(keys, r.Key())
+		return true
+	}, bigtable.RowFilter(filter)); err != nil {
+		return err
+	}
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > deleteBatchSize {
+			n = deleteBatchSize
+		}
+		muts := make([]*bigtable.Mutation, n)
+		for i := range muts {
+			muts[i] = bigtable.NewMutation()
+			muts[i].DeleteRow()
+		}
+		if err := applyBulk(ctx, tbl, keys[:n], muts); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// applyBulk applies muts to the rows with the given keys.
+func applyBulk(ctx context.Context, tbl *bigtable.Table, keys []string, muts []*bigtable.Mutation) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	errs, err := tbl.ApplyBulk(ctx, keys, muts)
+	if err != nil {
+		return err
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to write row %q: %v", keys[i], err)
+		}
+	}
+	return nil
+}
+
+func checkTableAccessible(ctx context.Context, tbl *bigtable.Table) error {
+	// Reading any row at all is enough, even one that doesn't exist.
+	_, err := tbl.ReadRow(ctx, treesPrefix)
+	return err
+}
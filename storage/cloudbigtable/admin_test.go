@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHardDeleteTreeData(t *testing.T) {
+	ctx := context.Background()
+	tbl := getTestTable(ctx, t)
+	as, ls := NewAdminStorage(tbl), NewLogStorage(tbl)
+
+	tree, err := storage.CreateTree(ctx, as, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	other, err := storage.CreateTree(ctx, as, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	for _, tr := range []int64{tree.TreeId, other.TreeId} {
+		storeRoot(ctx, t, ls, treeWithID(tr), &types.LogRootV1{})
+	}
+	if _, err := storage.SoftDeleteTree(ctx, as, tree.TreeId); err != nil {
+		t.Fatalf("SoftDeleteTree(): %v", err)
+	}
+	if err := storage.HardDeleteTree(ctx, as, tree.TreeId); err != nil {
+		t.Fatalf("HardDeleteTree(): %v", err)
+	}
+
+	if _, err := storage.GetTree(ctx, as, tree.TreeId); status.Code(err) != codes.NotFound {
+		t.Errorf("GetTree() of deleted tree: %v, want NotFound", err)
+	}
+	for _, test := range []struct {
+		treeID int64
+		want   bool
+	}{
+		{treeID: tree.TreeId, want: false},
+		{treeID: other.TreeId, want: true},
+	} {
+		if got := rowExists(ctx, t, tbl, rootRowKey(test.treeID)); got != test.want {
+			t.Errorf("root row of tree %d exists: %v, want %v", test.treeID, got, test.want)
+		}
+	}
+}
+
+func TestAdminConcurrentUpdate(t *testing.T) {
+	ctx := context.Background()
+	tbl := getTestTable(ctx, t)
+	as := NewAdminStorage(tbl)
+
+	tree, err := storage.CreateTree(ctx, as, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	err = as.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		if _, err := tx.UpdateTree(ctx, tree.TreeId, func(tree *trillian.Tree) { tree.DisplayName = "first" }); err != nil {
+			return err
+		}
+		// Update the tree in another transaction before this one commits.
+		_, err := storage.UpdateTree(ctx, as, tree.TreeId, func(tree *trillian.Tree) { tree.DisplayName = "second" })
+		return err
+	})
+	if got, want := status.Code(err), codes.Aborted; got != want {
+		t.Errorf("ReadWriteTransaction(): %v, want %v", err, want)
+	}
+	got, err := storage.GetTree(ctx, as, tree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree(): %v", err)
+	}
+	if got, want := got.DisplayName, "second"; got != want {
+		t.Errorf("DisplayName = %q, want %q", got, want)
+	}
+}
+
+// rowExists reports whether the row key has any cell.
+func rowExists(ctx context.Context, t *testing.T, tbl *bigtable.Table, key string) bool {
+	t.Helper()
+	row, err := tbl.ReadRow(ctx, key)
+	if err != nil {
+		t.Fatalf("ReadRow(%q): %v", key, err)
+	}
+	return len(row) > 0
+}
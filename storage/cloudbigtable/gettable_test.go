@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// The tests run against an in-memory fake of Bigtable by default. To run them
+// against Cloud Bigtable, or an emulator selected by BIGTABLE_EMULATOR_HOST,
+// set the -test_cloud_bigtable_instance flag:
+//
+//	go test ./storage/cloudbigtable -test_cloud_bigtable_instance=my-project/my-instance
+//
+// A table is created for each test, and deleted once it is done.
+var cloudInstance = flag.String("test_cloud_bigtable_instance", ":memory:", "Bigtable instance to run the tests in, as project/instance")
+
+var (
+	fakeOnce sync.Once
+	fakeAddr string
+	fakeErr  error
+
+	tableCount struct {
+		sync.Mutex
+		count int
+	}
+)
+
+// getTestTable returns a new empty table, with the column families created by
+// CreateTable.
+func getTestTable(ctx context.Context, t *testing.T) *bigtable.Table {
+	t.Helper()
+	project, instance := "fake-project", "fake-instance"
+	// The clients close their connection, so each needs its own.
+	opts := func() []option.ClientOption { return nil }
+	if *cloudInstance == ":memory:" {
+		fakeOnce.Do(func() {
+			var srv *bttest.Server
+			if srv, fakeErr = bttest.NewServer("localhost:0"); fakeErr == nil {
+				fakeAddr = srv.Addr
+			}
+		})
+		if fakeErr != nil {
+			t.Fatalf("bttest.NewServer(): %v", fakeErr)
+		}
+		opts = func() []option.ClientOption {
+			conn, err := grpc.Dial(fakeAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatalf("grpc.Dial(): %v", err)
+			}
+			return []option.ClientOption{option.WithGRPCConn(conn)}
+		}
+	} else {
+		parts := strings.Split(*cloudInstance, "/")
+		if len(parts) != 2 {
+			t.Fatalf("invalid -test_cloud_bigtable_instance %q, want project/instance", *cloudInstance)
+		}
+		project, instance = parts[0], parts[1]
+	}
+
+	tableCount.Lock()
+	tableCount.count++
+	table := fmt.Sprintf("test-%d-%d", os.Getpid(), tableCount.count)
+	tableCount.Unlock()
+
+	ac, err := bigtable.NewAdminClient(ctx, project, instance, opts()...)
+	if err != nil {
+		t.Fatalf("bigtable.NewAdminClient(): %v", err)
+	}
+	if err := CreateTable(ctx, ac, table); err != nil {
+		t.Fatalf("CreateTable(): %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ac.DeleteTable(ctx, table); err != nil {
+			t.Errorf("DeleteTable(%s): %v", table, err)
+		}
+		ac.Close()
+	})
+
+	client, err := bigtable.NewClient(ctx, project, instance, opts()...)
+	if err != nil {
+		t.Fatalf("bigtable.NewClient(): %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.Open(table)
+}
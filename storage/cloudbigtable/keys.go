@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudbigtable provides a Cloud Bigtable based implementation of
+// Trillian log storage.
+//
+// All the data lives in a single table. Trees are stored in rows keyed by
+// "trees/<tree ID>", and the data of a tree in rows prefixed with its ID in
+// fixed-width hex, so that the random tree IDs spread trees across tablets:
+//
+//	<tree>/root                  latest signed log root and commit journal
+//	<tree>/s/<prefix>            subtree, one cell version per tree revision
+//	<tree>/l/<index>             sequenced leaf
+//	<tree>/h/<merkle hash>       indices of the leaves with the hash
+//	<tree>/i/<identity hash>     leaf with the identity hash and its queue row, for deduping
+//	<tree>/q/<bucket>/<ts>/<id>  unsequenced leaf, in one of several buckets
+//
+// Bigtable only offers single-row transactions, so each log transaction
+// commits by atomically updating the root row of its tree, conditionally on
+// the row being unchanged since the transaction started, along with a journal
+// of the other rows to write. The journal is then rolled forward onto these
+// rows, by the committing transaction or whichever transaction on the tree
+// starts next.
+package cloudbigtable
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// Column families of the table.
+const (
+	// familyData holds single-version cells: trees, roots, leaves and queue
+	// entries.
+	familyData = "d"
+	// familySubtree holds the subtrees, with a cell version per tree revision.
+	familySubtree = "s"
+	// familyJournal holds the journal of the latest commit in root rows.
+	familyJournal = "j"
+)
+
+// Columns of familyData.
+const (
+	colTree    = "tree"
+	colVersion = "ver"
+	colRoot    = "slr"
+	colRev     = "rev"
+	colJRev    = "jrev"
+	colDone    = "done"
+	colLeaf    = "leaf"
+	colSubtree = "t"
+	// colQueueKey holds, in identity rows, the key of the queue row of the
+	// leaf which claimed the identity.
+	colQueueKey = "qkey"
+)
+
+// Prefixes of the journal columns, followed by the key of the row to update.
+const (
+	journalSubtree = "s/"
+	journalLeaf    = "l/"
+	journalDequeue = "q/"
+)
+
+// treesPrefix is the prefix of the rows holding trees.
+const treesPrefix = "trees/"
+
+// cellTime is the timestamp of the single-version cells, which are always
+// overwritten in place rather than relying on clocks to order versions.
+const cellTime = bigtable.Timestamp(0)
+
+// treeRowKey returns the key of the row holding the tree treeID.
+func treeRowKey(treeID int64) string {
+	return fmt.Sprintf("%s%016x", treesPrefix, treeID)
+}
+
+// treePrefix returns the prefix of all the rows holding data of treeID.
+func treePrefix(treeID int64) string {
+	return fmt.Sprintf("%016x/", treeID)
+}
+
+func rootRowKey(treeID int64) string {
+	return treePrefix(treeID) + "root"
+}
+
+func subtreeRowKey(treeID int64, prefix []byte) string {
+	return treePrefix(treeID) + "s/" + hex.EncodeToString(prefix)
+}
+
+func leafRowKey(treeID, index int64) string {
+	return treePrefix(treeID) + "l/" + indexKey(index)
+}
+
+func hashRowKey(treeID int64, merkleHash []byte) string {
+	return treePrefix(treeID) + "h/" + hex.EncodeToString(merkleHash)
+}
+
+func identityRowKey(treeID int64, identityHash []byte) string {
+	return treePrefix(treeID) + "i/" + hex.EncodeToString(identityHash)
+}
+
+// queuePrefix returns the prefix of the queue rows of treeID in bucket.
+func queuePrefix(treeID int64, bucket int) string {
+	return fmt.Sprintf("%sq/%02x/", treePrefix(treeID), bucket)
+}
+
+// queueRowKey returns the key of the queue row of a leaf queued at ts, which
+// sorts by queue time within its bucket. The key ends with the identity hash
+// of the leaf and a suffix telling apart the same leaf queued several times
+// when it isn't deduped.
+func queueRowKey(treeID int64, bucket int, ts time.Time, identityHash []byte, suffix uint32) string {
+	return fmt.Sprintf("%s%016x/%x-%08x", queuePrefix(treeID, bucket), ts.UnixNano(), identityHash, suffix)
+}
+
+// queueTime returns the queue time encoded in the queue row key.
+func queueTime(key string) (time.Time, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 5 {
+		return time.Time{}, fmt.Errorf("malformed queue row key %q", key)
+	}
+	nanos, err := strconv.ParseInt(parts[3], 16, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed queue row key %q: %v", key, err)
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// indexKey formats a leaf index so that the keys sort by index.
+func indexKey(index int64) string {
+	return fmt.Sprintf("%016x", index)
+}
+
+// revisionTime returns the timestamp of the subtree cells written at rev.
+// Bigtable tables have millisecond granularity by default.
+func revisionTime(rev int64) bigtable.Timestamp {
+	return bigtable.Timestamp(rev * 1000)
+}
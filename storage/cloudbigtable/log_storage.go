@@ -0,0 +1,1066 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/storagepb"
+	stree "github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultQueueBuckets is the default number of buckets of the queue of each
+// tree.
+const DefaultQueueBuckets = 16
+
+// LogStorageOptions are tuning, experiments and workarounds that can be used.
+type LogStorageOptions struct {
+	// QueueBuckets is the number of buckets the unsequenced leaves of each tree
+	// are spread across, by identity hash, so that queueing leaves doesn't
+	// write to a single tablet. Each dequeue reads from all the buckets, and
+	// the number must not be lowered while leaves are queued. Defaults to
+	// DefaultQueueBuckets.
+	QueueBuckets int
+}
+
+type logStorage struct {
+	tbl  *bigtable.Table
+	opts LogStorageOptions
+}
+
+// NewLogStorage returns a Bigtable-based storage.LogStorage using tbl, which
+// must have the column families set up by CreateTable.
+func NewLogStorage(tbl *bigtable.Table) storage.LogStorage {
+	return NewLogStorageWithOpts(tbl, LogStorageOptions{})
+}
+
+// NewLogStorageWithOpts returns a Bigtable-based storage.LogStorage using tbl,
+// configured with opts.
+func NewLogStorageWithOpts(tbl *bigtable.Table, opts LogStorageOptions) storage.LogStorage {
+	if opts.QueueBuckets <= 0 {
+		opts.QueueBuckets = DefaultQueueBuckets
+	}
+	return &logStorage{tbl: tbl, opts: opts}
+}
+
+// CheckDatabaseAccessible implements LogStorage.CheckDatabaseAccessible.
+func (ls *logStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return checkTableAccessible(ctx, ls.tbl)
+}
+
+// GetActiveLogIDs implements LogStorage.GetActiveLogIDs.
+func (ls *logStorage) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
+	trees, err := listTrees(ctx, ls.tbl, false /* includeDeleted */)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, tree := range trees {
+		switch tree.TreeType {
+		case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+			switch tree.TreeState {
+			case trillian.TreeState_ACTIVE, trillian.TreeState_DRAINING:
+				ids = append(ids, tree.TreeId)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// ReadWriteTransaction implements LogStorage.ReadWriteTransaction.
+func (ls *logStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.LogTXFunc) error {
+	tx, err := ls.begin(ctx, tree, false /* readonly */)
+	if err != nil && err != storage.ErrTreeNeedsInit {
+		return err
+	}
+	defer tx.Close()
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SnapshotForTree implements LogStorage.SnapshotForTree.
+func (ls *logStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
+	tx, err := ls.begin(ctx, tree, true /* readonly */)
+	if err != nil && err != storage.ErrTreeNeedsInit {
+		return nil, err
+	}
+	return tx, err
+}
+
+// begin starts a transaction on tree, after rolling forward the journal of the
+// latest commit on it if needed. It returns the transaction along with
+// storage.ErrTreeNeedsInit if the tree has no root yet.
+func (ls *logStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool) (*logTX, error) {
+	row, err := ls.tbl.ReadRow(ctx, rootRowKey(tree.TreeId), bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root of tree %d: %v", tree.TreeId, err)
+	}
+	cells := rowCells(row, familyData)
+	tx := &logTX{
+		ls:       ls,
+		treeID:   tree.TreeId,
+		treeType: tree.TreeType,
+		dedup:    tree.LeafDedupPolicy != trillian.LeafDedupPolicy_NO_DEDUP,
+		readonly: readonly,
+		ver:      string(cells[colVersion]),
+		cache:    cache.NewLogSubtreeCacheWithOpts(rfc6962.DefaultHasher, cache.Options{TreeID: tree.TreeId}),
+		dequeued: make(map[string][]string),
+	}
+
+	if _, done := cells[colDone]; !done && len(row[familyJournal]) > 0 {
+		j, err := parseJournal(tree.TreeId, cells, rowCells(row, familyJournal))
+		if err != nil {
+			return nil, err
+		}
+		glog.V(1).Infof("%d: rolling forward journal of version %s", tree.TreeId, tx.ver)
+		if err := ls.rollForward(ctx, tree.TreeId, tx.ver, j); err != nil {
+			return nil, err
+		}
+	}
+
+	slrBytes, ok := cells[colRoot]
+	if !ok {
+		return tx, storage.ErrTreeNeedsInit
+	}
+	tx.slr = &trillian.SignedLogRoot{}
+	if err := proto.Unmarshal(slrBytes, tx.slr); err != nil {
+		return nil, fmt.Errorf("failed to parse root of tree %d: %v", tree.TreeId, err)
+	}
+	if err := tx.root.UnmarshalBinary(tx.slr.LogRoot); err != nil {
+		return nil, err
+	}
+	rev, err := strconv.ParseInt(string(cells[colRev]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse revision of tree %d: %v", tree.TreeId, err)
+	}
+	tx.writeRev = rev + 1
+	return tx, nil
+}
+
+// QueueLeaves implements LogStorage.QueueLeaves.
+//
+// The leaves are written to the queue before they are deduped, and removed
+// from it if they turn out to be duplicates, so that a failure in between
+// can't lose a leaf. A queued leaf is only dequeued once it has claimed its
+// identity, which the sequencer does itself for the leaves which haven't yet,
+// so that it never sees a duplicate before it's removed.
+func (ls *logStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if got, want := len(leaf.LeafIdentityHash), rfc6962.DefaultHasher.Size(); got != want {
+			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d, got %d", want, got)
+		}
+	}
+	qts := timestamppb.New(queueTimestamp)
+	if err := qts.CheckValid(); err != nil {
+		return nil, fmt.Errorf("got invalid queue timestamp: %w", err)
+	}
+
+	queued := make([]*trillian.LogLeaf, len(leaves))
+	leafBytes := make([][]byte, len(leaves))
+	keys := make([]string, len(leaves))
+	muts := make([]*bigtable.Mutation, len(leaves))
+	for i, leaf := range leaves {
+		queued[i] = proto.Clone(leaf).(*trillian.LogLeaf)
+		queued[i].QueueTimestamp = qts
+		b, err := proto.Marshal(queued[i])
+		if err != nil {
+			return nil, err
+		}
+		leafBytes[i] = b
+		keys[i] = queueRowKey(tree.TreeId, ls.bucket(leaf.LeafIdentityHash), queueTimestamp, leaf.LeafIdentityHash, rand.Uint32())
+		muts[i] = bigtable.NewMutation()
+		muts[i].Set(familyData, colLeaf, cellTime, b)
+	}
+	if err := applyBulk(ctx, ls.tbl, keys, muts); err != nil {
+		return nil, fmt.Errorf("failed to queue leaves: %v", err)
+	}
+
+	ret := make([]*trillian.QueuedLogLeaf, len(leaves))
+	for i, leaf := range queued {
+		ret[i] = &trillian.QueuedLogLeaf{Leaf: leaf}
+	}
+	if tree.LeafDedupPolicy == trillian.LeafDedupPolicy_NO_DEDUP {
+		return ret, nil
+	}
+
+	existing := make([]*trillian.LogLeaf, len(leaves))
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range leaves {
+		i := i
+		g.Go(func() error {
+			leaf, queueKey, err := ls.claimIdentity(gctx, tree.TreeId, leaves[i].LeafIdentityHash, leafBytes[i], keys[i])
+			// The sequencer may have claimed the identity on behalf of this
+			// queue row already.
+			if queueKey != keys[i] {
+				existing[i] = leaf
+			}
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var dupKeys []string
+	var dupMuts []*bigtable.Mutation
+	for i, e := range existing {
+		if e == nil {
+			continue
+		}
+		ret[i] = &trillian.QueuedLogLeaf{
+			Leaf:   e,
+			Status: status.Newf(codes.AlreadyExists, "leaf already exists: %v", e.LeafIdentityHash).Proto(),
+		}
+		m := bigtable.NewMutation()
+		m.DeleteRow()
+		dupKeys = append(dupKeys, keys[i])
+		dupMuts = append(dupMuts, m)
+	}
+	if err := applyBulk(ctx, ls.tbl, dupKeys, dupMuts); err != nil {
+		return nil, fmt.Errorf("failed to unqueue duplicate leaves: %v", err)
+	}
+	return ret, nil
+}
+
+// claimIdentity writes leafBytes, and queueKey unless empty, to the row of
+// identityHash unless it holds a leaf already, in which case it returns that
+// leaf along with the key of the queue row which claimed the identity, if any.
+func (ls *logStorage) claimIdentity(ctx context.Context, treeID int64, identityHash, leafBytes []byte, queueKey string) (*trillian.LogLeaf, string, error) {
+	key := identityRowKey(treeID, identityHash)
+	set := bigtable.NewMutation()
+	set.Set(familyData, colLeaf, cellTime, leafBytes)
+	if queueKey != "" {
+		set.Set(familyData, colQueueKey, cellTime, []byte(queueKey))
+	}
+	cells, err := ls.applyUnlessLeaf(ctx, key, set)
+	if err != nil || cells == nil {
+		return nil, "", err
+	}
+	leaf, err := parseLeaf(key, cells)
+	if err != nil {
+		return nil, "", err
+	}
+	return leaf, string(cells[colQueueKey]), nil
+}
+
+// claimRow writes leafBytes to the row key unless it holds a leaf already, in
+// which case it returns that leaf.
+func (ls *logStorage) claimRow(ctx context.Context, key string, leafBytes []byte) (*trillian.LogLeaf, error) {
+	set := bigtable.NewMutation()
+	set.Set(familyData, colLeaf, cellTime, leafBytes)
+	cells, err := ls.applyUnlessLeaf(ctx, key, set)
+	if err != nil || cells == nil {
+		return nil, err
+	}
+	return parseLeaf(key, cells)
+}
+
+// applyUnlessLeaf applies set to the row key unless it holds a leaf already,
+// in which case it returns the data cells of the row.
+func (ls *logStorage) applyUnlessLeaf(ctx context.Context, key string, set *bigtable.Mutation) (map[string][]byte, error) {
+	cond := bigtable.ChainFilters(bigtable.FamilyFilter(familyData), bigtable.ColumnFilter(colLeaf))
+	var matched bool
+	if err := ls.tbl.Apply(ctx, key, bigtable.NewCondMutation(cond, nil, set), bigtable.GetCondMutationResult(&matched)); err != nil {
+		return nil, fmt.Errorf("failed to write row %q: %v", key, err)
+	}
+	if !matched {
+		return nil, nil
+	}
+	row, err := ls.tbl.ReadRow(ctx, key, bigtable.RowFilter(bigtable.FamilyFilter(familyData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read row %q: %v", key, err)
+	}
+	return rowCells(row, familyData), nil
+}
+
+// parseLeaf returns the leaf in the data cells of the row key.
+func parseLeaf(key string, cells map[string][]byte) (*trillian.LogLeaf, error) {
+	leaf := &trillian.LogLeaf{}
+	if err := proto.Unmarshal(cells[colLeaf], leaf); err != nil {
+		return nil, fmt.Errorf("failed to parse leaf of row %q: %v", key, err)
+	}
+	return leaf, nil
+}
+
+// AddSequencedLeaves implements LogStorage.AddSequencedLeaves.
+func (ls *logStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	qts := timestamppb.New(timestamp)
+	if err := qts.CheckValid(); err != nil {
+		return nil, fmt.Errorf("got invalid queue timestamp: %w", err)
+	}
+	for i, leaf := range leaves {
+		if got, want := len(leaf.LeafIdentityHash), rfc6962.DefaultHasher.Size(); got != want {
+			return nil, status.Errorf(codes.FailedPrecondition, "leaves[%d] has incorrect hash size %d, want %d", i, got, want)
+		}
+		if leaf.LeafIndex < 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "leaves[%d] has negative index %d", i, leaf.LeafIndex)
+		}
+	}
+
+	res := make([]*trillian.QueuedLogLeaf, len(leaves))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, leaf := range leaves {
+		i := i
+		stored := proto.Clone(leaf).(*trillian.LogLeaf)
+		stored.QueueTimestamp = qts
+		stored.IntegrateTimestamp = timestamppb.New(time.Unix(0, 0))
+		g.Go(func() error {
+			st, err := ls.addSequencedLeaf(gctx, tree, stored)
+			res[i] = &trillian.QueuedLogLeaf{Status: st.Proto()}
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// addSequencedLeaf writes leaf at its index unless the index is taken, then
+// claims its identity hash unless the tree doesn't dedup leaves. If a leaf with
+// the same identity exists, the newly written leaf is removed.
+func (ls *logStorage) addSequencedLeaf(ctx context.Context, tree *trillian.Tree, leaf *trillian.LogLeaf) (*status.Status, error) {
+	leafBytes, err := proto.Marshal(leaf)
+	if err != nil {
+		return nil, err
+	}
+	key := leafRowKey(tree.TreeId, leaf.LeafIndex)
+	if existing, err := ls.claimRow(ctx, key, leafBytes); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return status.New(codes.FailedPrecondition, "conflicting LeafIndex"), nil
+	}
+	if tree.LeafDedupPolicy == trillian.LeafDedupPolicy_NO_DEDUP {
+		return status.New(codes.OK, "OK"), nil
+	}
+
+	if existing, _, err := ls.claimIdentity(ctx, tree.TreeId, leaf.LeafIdentityHash, leafBytes, ""); err != nil {
+		return nil, err
+	} else if existing != nil {
+		m := bigtable.NewMutation()
+		m.DeleteRow()
+		if err := ls.tbl.Apply(ctx, key, m); err != nil {
+			return nil, fmt.Errorf("failed to remove duplicate leaf: %v", err)
+		}
+		return status.New(codes.FailedPrecondition, "conflicting LeafIdentityHash"), nil
+	}
+
+	// The leaf is only findable by its Merkle hash once it is also findable
+	// by its identity.
+	m := bigtable.NewMutation()
+	m.Set(familyData, indexKey(leaf.LeafIndex), cellTime, nil)
+	if err := ls.tbl.Apply(ctx, hashRowKey(tree.TreeId, leaf.MerkleLeafHash), m); err != nil {
+		return nil, fmt.Errorf("failed to index leaf: %v", err)
+	}
+	return status.New(codes.OK, "OK"), nil
+}
+
+// CountUnsequenced implements storage.UnsequencedCounter.
+func (ls *logStorage) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	counts := make([]int64, ls.opts.QueueBuckets)
+	filter := bigtable.ChainFilters(bigtable.CellsPerRowLimitFilter(1), bigtable.StripValueFilter())
+	g, gctx := errgroup.WithContext(ctx)
+	for b := range counts {
+		b := b
+		g.Go(func() error {
+			return ls.tbl.ReadRows(gctx, bigtable.PrefixRange(queuePrefix(tree.TreeId, b)), func(bigtable.Row) bool {
+				counts[b]++
+				return true
+			}, bigtable.RowFilter(filter))
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	var count int64
+	for _, c := range counts {
+		count += c
+	}
+	return count, nil
+}
+
+// OldestUnsequenced implements storage.UnsequencedAger.
+func (ls *logStorage) OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error) {
+	oldest := make([]string, ls.opts.QueueBuckets)
+	filter := bigtable.ChainFilters(bigtable.CellsPerRowLimitFilter(1), bigtable.StripValueFilter())
+	g, gctx := errgroup.WithContext(ctx)
+	for b := range oldest {
+		b := b
+		g.Go(func() error {
+			return ls.tbl.ReadRows(gctx, bigtable.PrefixRange(queuePrefix(tree.TreeId, b)), func(r bigtable.Row) bool {
+				oldest[b] = r.Key()
+				return false
+			}, bigtable.RowFilter(filter), bigtable.LimitRows(1))
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return time.Time{}, err
+	}
+	var ret time.Time
+	for _, key := range oldest {
+		if key == "" {
+			continue
+		}
+		ts, err := queueTime(key)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if ret.IsZero() || ts.Before(ret) {
+			ret = ts
+		}
+	}
+	return ret, nil
+}
+
+// bucket returns the queue bucket of the leaf with identityHash.
+func (ls *logStorage) bucket(identityHash []byte) int {
+	return int(binary.BigEndian.Uint16(identityHash) % uint16(ls.opts.QueueBuckets))
+}
+
+// journal holds the writes of a commit which are applied after updating the
+// root row.
+type journal struct {
+	// rev is the revision of the subtrees.
+	rev      int64
+	subtrees map[string][]byte
+	leaves   []*trillian.LogLeaf
+	// dequeued holds the keys of the queue rows of the leaves.
+	dequeued []string
+}
+
+// set adds the journal to the root row mutation m.
+func (j *journal) set(m *bigtable.Mutation) error {
+	m.DeleteCellsInFamily(familyJournal)
+	m.DeleteCellsInColumn(familyData, colDone)
+	m.Set(familyData, colJRev, cellTime, []byte(strconv.FormatInt(j.rev, 10)))
+	for prefix, b := range j.subtrees {
+		m.Set(familyJournal, journalSubtree+prefix, cellTime, b)
+	}
+	for _, leaf := range j.leaves {
+		b, err := proto.Marshal(leaf)
+		if err != nil {
+			return err
+		}
+		m.Set(familyJournal, journalLeaf+indexKey(leaf.LeafIndex), cellTime, b)
+	}
+	for _, key := range j.dequeued {
+		m.Set(familyJournal, journalDequeue+key, cellTime, nil)
+	}
+	return nil
+}
+
+// parseJournal returns the journal stored in a root row with the given data
+// and journal cells.
+func parseJournal(treeID int64, data, cells map[string][]byte) (*journal, error) {
+	rev, err := strconv.ParseInt(string(data[colJRev]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse journal revision of tree %d: %v", treeID, err)
+	}
+	j := &journal{rev: rev, subtrees: make(map[string][]byte)}
+	for col, b := range cells {
+		switch {
+		case strings.HasPrefix(col, journalSubtree):
+			j.subtrees[strings.TrimPrefix(col, journalSubtree)] = b
+		case strings.HasPrefix(col, journalLeaf):
+			leaf := &trillian.LogLeaf{}
+			if err := proto.Unmarshal(b, leaf); err != nil {
+				return nil, fmt.Errorf("failed to parse journal of tree %d: %v", treeID, err)
+			}
+			j.leaves = append(j.leaves, leaf)
+		case strings.HasPrefix(col, journalDequeue):
+			j.dequeued = append(j.dequeued, strings.TrimPrefix(col, journalDequeue))
+		default:
+			return nil, fmt.Errorf("unexpected journal column %q of tree %d", col, treeID)
+		}
+	}
+	return j, nil
+}
+
+// rollForward applies the journal j of the root row version ver, and marks it
+// as done. Applying a journal is idempotent.
+func (ls *logStorage) rollForward(ctx context.Context, treeID int64, ver string, j *journal) error {
+	var keys []string
+	var muts []*bigtable.Mutation
+	add := func(key string) *bigtable.Mutation {
+		m := bigtable.NewMutation()
+		keys = append(keys, key)
+		muts = append(muts, m)
+		return m
+	}
+
+	for prefix, b := range j.subtrees {
+		id, err := hex.DecodeString(prefix)
+		if err != nil {
+			return fmt.Errorf("malformed subtree prefix %q: %v", prefix, err)
+		}
+		add(subtreeRowKey(treeID, id)).Set(familySubtree, colSubtree, revisionTime(j.rev), b)
+	}
+	for _, leaf := range j.leaves {
+		b, err := proto.Marshal(leaf)
+		if err != nil {
+			return err
+		}
+		add(leafRowKey(treeID, leaf.LeafIndex)).Set(familyData, colLeaf, cellTime, b)
+		add(hashRowKey(treeID, leaf.MerkleLeafHash)).Set(familyData, indexKey(leaf.LeafIndex), cellTime, nil)
+	}
+	for _, key := range j.dequeued {
+		add(key).DeleteRow()
+	}
+	if err := applyBulk(ctx, ls.tbl, keys, muts); err != nil {
+		return fmt.Errorf("failed to roll forward journal of tree %d: %v", treeID, err)
+	}
+
+	// Another commit may have happened meanwhile, with its own journal.
+	done := bigtable.NewMutation()
+	done.Set(familyData, colDone, cellTime, nil)
+	if err := ls.tbl.Apply(ctx, rootRowKey(treeID), bigtable.NewCondMutation(versionFilter(ver), done, nil)); err != nil {
+		return fmt.Errorf("failed to mark journal of tree %d as done: %v", treeID, err)
+	}
+	return nil
+}
+
+// logTX implements storage.LogTreeTX. Reads see the state of the tree as of
+// the start of the transaction, ignoring the writes made after it started.
+// Writes are buffered until the transaction commits.
+type logTX struct {
+	ls       *logStorage
+	treeID   int64
+	treeType trillian.TreeType
+	dedup    bool
+	readonly bool
+
+	// mu guards closed and the buffered writes.
+	mu     sync.Mutex
+	closed bool
+
+	// ver is the version of the root row when the transaction started, empty
+	// if the row didn't exist.
+	ver string
+	// slr and root are the latest root when the transaction started, if any.
+	slr  *trillian.SignedLogRoot
+	root types.LogRootV1
+	// writeRev is the revision at which subtrees are written.
+	writeRev int64
+
+	cache   *cache.SubtreeCache
+	newRoot *trillian.SignedLogRoot
+	// sequenced holds the leaves passed to UpdateSequencedLeaves, and
+	// sequencedKeys the keys of their queue rows.
+	sequenced     []*trillian.LogLeaf
+	sequencedKeys []string
+	// dequeued holds the keys of the queue rows of the leaves dequeued by the
+	// transaction and not sequenced yet, by identity hash.
+	dequeued     map[string][]string
+	dequeuedKeys map[string]bool
+}
+
+// Commit implements ReadOnlyLogTreeTX.Commit.
+//
+// The root row is updated with the new root and the journal of the other
+// writes, conditionally on its version being the one read when the
+// transaction started. If it isn't, the transaction fails with codes.Aborted.
+func (t *logTX) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	t.closed = true
+	if t.readonly {
+		return nil
+	}
+
+	tiles, err := t.cache.UpdatedTiles()
+	if err != nil {
+		return err
+	}
+	if t.newRoot == nil && len(tiles) == 0 && len(t.sequenced) == 0 {
+		return nil
+	}
+
+	j := &journal{
+		rev:      t.writeRev,
+		subtrees: make(map[string][]byte),
+		leaves:   t.sequenced,
+		dequeued: t.sequencedKeys,
+	}
+	for _, st := range tiles {
+		b, err := proto.Marshal(st)
+		if err != nil {
+			return err
+		}
+		j.subtrees[hex.EncodeToString(st.Prefix)] = b
+	}
+
+	ver := nextVersion(t.ver)
+	m := bigtable.NewMutation()
+	if err := j.set(m); err != nil {
+		return err
+	}
+	m.Set(familyData, colVersion, cellTime, []byte(ver))
+	if t.newRoot != nil {
+		b, err := proto.Marshal(t.newRoot)
+		if err != nil {
+			return err
+		}
+		m.Set(familyData, colRoot, cellTime, b)
+		m.Set(familyData, colRev, cellTime, []byte(strconv.FormatInt(t.writeRev, 10)))
+	}
+
+	var cm *bigtable.Mutation
+	var wantMatch bool
+	if t.ver == "" {
+		cm = bigtable.NewCondMutation(versionFilter(""), nil, m)
+	} else {
+		cm = bigtable.NewCondMutation(versionFilter(t.ver), m, nil)
+		wantMatch = true
+	}
+	var matched bool
+	if err := t.ls.tbl.Apply(ctx, rootRowKey(t.treeID), cm, bigtable.GetCondMutationResult(&matched)); err != nil {
+		return fmt.Errorf("failed to write root of tree %d: %v", t.treeID, err)
+	}
+	if matched != wantMatch {
+		return status.Errorf(codes.Aborted, "tree %d was concurrently modified", t.treeID)
+	}
+
+	// The commit has happened, a failure to roll the journal forward will be
+	// recovered from by the next transaction.
+	if err := t.ls.rollForward(ctx, t.treeID, ver, j); err != nil {
+		glog.Warningf("%d: %v", t.treeID, err)
+	}
+	return nil
+}
+
+// Close implements ReadOnlyLogTreeTX.Close.
+func (t *logTX) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return nil
+}
+
+func (t *logTX) checkWritable() error {
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	if t.readonly {
+		return ErrReadOnlyTransaction
+	}
+	return nil
+}
+
+// LatestSignedLogRoot implements ReadOnlyLogTreeTX.LatestSignedLogRoot.
+func (t *logTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
+	if t.slr == nil {
+		return nil, storage.ErrTreeNeedsInit
+	}
+	return t.slr, nil
+}
+
+// StoreSignedLogRoot implements LogTreeTX.StoreSignedLogRoot.
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, slr *trillian.SignedLogRoot) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return err
+	}
+	t.newRoot = slr
+	return nil
+}
+
+// GetMerkleNodes implements ReadOnlyLogTreeTX.GetMerkleNodes.
+func (t *logTX) GetMerkleNodes(ctx context.Context, ids []compact.NodeID) ([]stree.Node, error) {
+	return t.cache.GetNodes(ids, t.getSubtreesAtRev(ctx, t.writeRev-1))
+}
+
+// SetMerkleNodes implements LogTreeTX.SetMerkleNodes.
+func (t *logTX) SetMerkleNodes(ctx context.Context, nodes []stree.Node) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	return t.cache.SetNodes(nodes, t.getSubtreesAtRev(ctx, t.writeRev-1))
+}
+
+// getSubtreesAtRev returns a GetSubtreesFunc which reads the latest version of
+// the subtrees at or below rev.
+func (t *logTX) getSubtreesAtRev(ctx context.Context, rev int64) cache.GetSubtreesFunc {
+	return func(ids [][]byte) ([]*storagepb.SubtreeProto, error) {
+		if rev < 0 || len(ids) == 0 {
+			return nil, nil
+		}
+		keys := make(bigtable.RowList, 0, len(ids))
+		for _, id := range ids {
+			keys = append(keys, subtreeRowKey(t.treeID, id))
+		}
+		filter := bigtable.ChainFilters(
+			bigtable.FamilyFilter(familySubtree),
+			bigtable.TimestampRangeFilterMicros(0, revisionTime(rev+1)),
+			bigtable.LatestNFilter(1))
+
+		var ret []*storagepb.SubtreeProto
+		var parseErr error
+		if err := t.ls.tbl.ReadRows(ctx, keys, func(r bigtable.Row) bool {
+			st := &storagepb.SubtreeProto{}
+			if parseErr = proto.Unmarshal(rowCells(r, familySubtree)[colSubtree], st); parseErr != nil {
+				return false
+			}
+			// The empty prefix of the top subtree is unmarshalled as nil.
+			if st.Prefix == nil {
+				st.Prefix = []byte{}
+			}
+			ret = append(ret, st)
+			return true
+		}, bigtable.RowFilter(filter)); err != nil {
+			return nil, err
+		}
+		if parseErr != nil {
+			return nil, fmt.Errorf("failed to parse subtree: %v", parseErr)
+		}
+		return ret, nil
+	}
+}
+
+// DequeueLeaves implements LogTreeTX.DequeueLeaves. Leaves of a LOG tree are
+// read from all the queue buckets, and the oldest ones are returned.
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, cutoff time.Time) ([]*trillian.LogLeaf, error) {
+	if t.treeType == trillian.TreeType_PREORDERED_LOG {
+		// Sequenced leaves beyond the tree size are integrated next.
+		return t.readLeaves(ctx, int64(t.root.TreeSize), int64(limit))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	buckets := make([][]queueEntry, t.ls.opts.QueueBuckets)
+	// Leaves dequeued earlier in this transaction are skipped.
+	rowLimit := int64(limit + len(t.dequeuedKeys))
+	g, gctx := errgroup.WithContext(ctx)
+	for b := range buckets {
+		b := b
+		prefix := queuePrefix(t.treeID, b)
+		end := fmt.Sprintf("%s%016x", prefix, cutoff.UnixNano()+1)
+		g.Go(func() error {
+			var parseErr error
+			if err := t.ls.tbl.ReadRows(gctx, bigtable.NewRange(prefix, end), func(r bigtable.Row) bool {
+				if t.dequeuedKeys[r.Key()] {
+					return true
+				}
+				leafBytes := rowCells(r, familyData)[colLeaf]
+				leaf := &trillian.LogLeaf{}
+				if parseErr = proto.Unmarshal(leafBytes, leaf); parseErr != nil {
+					return false
+				}
+				buckets[b] = append(buckets[b], queueEntry{key: r.Key(), leaf: leaf, leafBytes: leafBytes})
+				return true
+			}, bigtable.LimitRows(rowLimit)); err != nil {
+				return err
+			}
+			return parseErr
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to read queue: %v", err)
+	}
+
+	var entries []queueEntry
+	for _, b := range buckets {
+		entries = append(entries, b...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ti, tj := entries[i].leaf.QueueTimestamp.AsTime(), entries[j].leaf.QueueTimestamp.AsTime()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return entries[i].key < entries[j].key
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	if t.dedup {
+		var err error
+		if entries, err = t.settle(ctx, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.dequeuedKeys == nil {
+		t.dequeuedKeys = make(map[string]bool)
+	}
+	leaves := make([]*trillian.LogLeaf, 0, len(entries))
+	for _, e := range entries {
+		id := string(e.leaf.LeafIdentityHash)
+		t.dequeued[id] = append(t.dequeued[id], e.key)
+		t.dequeuedKeys[e.key] = true
+		leaves = append(leaves, e.leaf)
+	}
+	return leaves, nil
+}
+
+// queueEntry is a leaf read from the queue row key.
+type queueEntry struct {
+	key       string
+	leaf      *trillian.LogLeaf
+	leafBytes []byte
+}
+
+// settle returns the entries whose queue rows have claimed the identities of
+// their leaves, and removes the others, which are duplicates. QueueLeaves
+// claims the identities after writing the queue rows, so the entries which
+// haven't yet claim them here.
+func (t *logTX) settle(ctx context.Context, entries []queueEntry) ([]queueEntry, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	claims := make(map[string]string)
+	idKeys := make(bigtable.RowList, len(entries))
+	for i, e := range entries {
+		idKeys[i] = identityRowKey(t.treeID, e.leaf.LeafIdentityHash)
+	}
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(familyData), bigtable.ColumnFilter(colQueueKey))
+	if err := t.ls.tbl.ReadRows(ctx, idKeys, func(r bigtable.Row) bool {
+		claims[r.Key()] = string(rowCells(r, familyData)[colQueueKey])
+		return true
+	}, bigtable.RowFilter(filter)); err != nil {
+		return nil, fmt.Errorf("failed to read identities: %v", err)
+	}
+
+	var claimsMu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	for i, e := range entries {
+		if _, ok := claims[idKeys[i]]; ok {
+			continue
+		}
+		idKey, e := idKeys[i], e
+		g.Go(func() error {
+			existing, queueKey, err := t.ls.claimIdentity(gctx, t.treeID, e.leaf.LeafIdentityHash, e.leafBytes, e.key)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				// This entry claimed the identity.
+				queueKey = e.key
+			}
+			claimsMu.Lock()
+			defer claimsMu.Unlock()
+			claims[idKey] = queueKey
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	settled := entries[:0]
+	var dupKeys []string
+	var dupMuts []*bigtable.Mutation
+	for i, e := range entries {
+		if claims[idKeys[i]] == e.key {
+			settled = append(settled, e)
+			continue
+		}
+		m := bigtable.NewMutation()
+		m.DeleteRow()
+		dupKeys = append(dupKeys, e.key)
+		dupMuts = append(dupMuts, m)
+	}
+	if err := applyBulk(ctx, t.ls.tbl, dupKeys, dupMuts); err != nil {
+		return nil, fmt.Errorf("failed to unqueue duplicate leaves: %v", err)
+	}
+	return settled, nil
+}
+
+// UpdateSequencedLeaves implements LogTreeTX.UpdateSequencedLeaves. The leaves
+// are written, and removed from the queue, when the transaction commits.
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		if got, want := len(leaf.LeafIdentityHash), rfc6962.DefaultHasher.Size(); got != want {
+			return fmt.Errorf("sequenced leaf has incorrect hash size: got %v, want %v", got, want)
+		}
+		id := string(leaf.LeafIdentityHash)
+		keys := t.dequeued[id]
+		if len(keys) == 0 {
+			return fmt.Errorf("attempted to update leaf %x which wasn't dequeued", leaf.LeafIdentityHash)
+		}
+		t.dequeued[id] = keys[1:]
+		t.sequenced = append(t.sequenced, proto.Clone(leaf).(*trillian.LogLeaf))
+		t.sequencedKeys = append(t.sequencedKeys, keys[0])
+	}
+	return nil
+}
+
+// GetLeavesByRange implements ReadOnlyLogTreeTX.GetLeavesByRange.
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	if count <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid count %d", count)
+	}
+	if start < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid start %d", start)
+	}
+	if t.treeType == trillian.TreeType_PREORDERED_LOG {
+		// Allow requesting entries beyond the tree size.
+		return t.readLeaves(ctx, start, count)
+	}
+	treeSize := int64(t.root.TreeSize)
+	if start >= treeSize {
+		return nil, status.Errorf(codes.OutOfRange, "start index %d beyond tree size %d", start, treeSize)
+	}
+	if start+count > treeSize {
+		count = treeSize - start
+	}
+	return t.readLeaves(ctx, start, count)
+}
+
+// readLeaves returns the contiguous leaves in [start, start+count). Leaves
+// within the tree size must all exist, while the ones beyond it, which only
+// PREORDERED_LOG trees have, are returned up to the first missing one.
+func (t *logTX) readLeaves(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	treeSize := int64(t.root.TreeSize)
+	ret := make([]*trillian.LogLeaf, 0, count)
+	var readErr error
+	rng := bigtable.NewRange(leafRowKey(t.treeID, start), leafRowKey(t.treeID, start+count))
+	if err := t.ls.tbl.ReadRows(ctx, rng, func(r bigtable.Row) bool {
+		leaf := &trillian.LogLeaf{}
+		if readErr = proto.Unmarshal(rowCells(r, familyData)[colLeaf], leaf); readErr != nil {
+			return false
+		}
+		if want := start + int64(len(ret)); leaf.LeafIndex != want {
+			if want < treeSize {
+				readErr = fmt.Errorf("missing expected index %d", want)
+			}
+			return false
+		}
+		ret = append(ret, leaf)
+		return true
+	}, bigtable.RowFilter(bigtable.LatestNFilter(1))); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	if next := start + int64(len(ret)); next < treeSize && next < start+count {
+		return nil, fmt.Errorf("missing expected index %d", next)
+	}
+	return ret, nil
+}
+
+// GetLeavesByHash implements ReadOnlyLogTreeTX.GetLeavesByHash.
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	if len(leafHashes) == 0 {
+		return nil, nil
+	}
+	hashKeys := make(bigtable.RowList, 0, len(leafHashes))
+	for _, h := range leafHashes {
+		hashKeys = append(hashKeys, hashRowKey(t.treeID, h))
+	}
+	var leafKeys bigtable.RowList
+	seen := make(map[int64]bool)
+	if err := t.ls.tbl.ReadRows(ctx, hashKeys, func(r bigtable.Row) bool {
+		for col := range rowCells(r, familyData) {
+			index, err := strconv.ParseInt(col, 16, 64)
+			if err != nil {
+				continue
+			}
+			// Leaves beyond the tree size of a LOG tree aren't integrated yet.
+			if t.treeType == trillian.TreeType_LOG && index >= int64(t.root.TreeSize) || seen[index] {
+				continue
+			}
+			seen[index] = true
+			leafKeys = append(leafKeys, leafRowKey(t.treeID, index))
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.FamilyFilter(familyData))); err != nil {
+		return nil, err
+	}
+	if len(leafKeys) == 0 {
+		return nil, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, h := range leafHashes {
+		wanted[string(h)] = true
+	}
+	var ret []*trillian.LogLeaf
+	var parseErr error
+	if err := t.ls.tbl.ReadRows(ctx, leafKeys, func(r bigtable.Row) bool {
+		leaf := &trillian.LogLeaf{}
+		if parseErr = proto.Unmarshal(rowCells(r, familyData)[colLeaf], leaf); parseErr != nil {
+			return false
+		}
+		// The hash rows may refer to leaves which have been replaced.
+		if wanted[string(leaf.MerkleLeafHash)] {
+			ret = append(ret, leaf)
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.LatestNFilter(1))); err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if orderBySequence {
+		sort.Slice(ret, func(i, j int) bool { return ret[i].LeafIndex < ret[j].LeafIndex })
+	} else {
+		order := make(map[string]int)
+		for i, h := range leafHashes {
+			if _, ok := order[string(h)]; !ok {
+				order[string(h)] = i
+			}
+		}
+		sort.SliceStable(ret, func(i, j int) bool {
+			return order[string(ret[i].MerkleLeafHash)] < order[string(ret[j].MerkleLeafHash)]
+		})
+	}
+	return ret, nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func treeWithID(treeID int64) *trillian.Tree {
+	return &trillian.Tree{TreeId: treeID, TreeType: trillian.TreeType_LOG}
+}
+
+func storeRoot(ctx context.Context, t *testing.T, ls storage.LogStorage, tree *trillian.Tree, root *types.LogRootV1) {
+	t.Helper()
+	logRoot, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: logRoot})
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+}
+
+func testLeaves(n int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, n)
+	for i := range leaves {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		hash := sha256.Sum256(value)
+		leaves[i] = &trillian.LogLeaf{LeafIdentityHash: hash[:], MerkleLeafHash: hash[:], LeafValue: value}
+	}
+	return leaves
+}
+
+// sequence dequeues all the queued leaves of tree and sequences them from
+// start, without storing a new root.
+func sequence(ctx context.Context, t *testing.T, ls storage.LogStorage, tree *trillian.Tree, start int64) []*trillian.LogLeaf {
+	t.Helper()
+	var leaves []*trillian.LogLeaf
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		var err error
+		if leaves, err = tx.DequeueLeaves(ctx, 100, time.Now()); err != nil {
+			return err
+		}
+		for i, leaf := range leaves {
+			leaf.LeafIndex = start + int64(i)
+			leaf.IntegrateTimestamp = timestamppb.Now()
+		}
+		return tx.UpdateSequencedLeaves(ctx, leaves)
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+	return leaves
+}
+
+func TestQueueLeavesDedup(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		policy    trillian.LeafDedupPolicy
+		wantCount int64
+	}{
+		{policy: trillian.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH, wantCount: 3},
+		{policy: trillian.LeafDedupPolicy_NO_DEDUP, wantCount: 5},
+	} {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			ls := NewLogStorage(getTestTable(ctx, t))
+			tree := treeWithID(1)
+			tree.LeafDedupPolicy = test.policy
+			leaves := testLeaves(3)
+
+			if _, err := ls.QueueLeaves(ctx, tree, leaves, time.Now()); err != nil {
+				t.Fatalf("QueueLeaves(): %v", err)
+			}
+			// Queue duplicates, within the batch and of queued leaves.
+			queued, err := ls.QueueLeaves(ctx, tree, []*trillian.LogLeaf{leaves[0], leaves[0]}, time.Now())
+			if err != nil {
+				t.Fatalf("QueueLeaves(): %v", err)
+			}
+			for i, q := range queued {
+				wantCode := codes.OK
+				if test.policy != trillian.LeafDedupPolicy_NO_DEDUP {
+					wantCode = codes.AlreadyExists
+				}
+				if got := status.FromProto(q.Status).Code(); got != wantCode {
+					t.Errorf("QueueLeaves(): leaf %d status %v, want %v", i, got, wantCode)
+				}
+			}
+
+			count, err := ls.(storage.UnsequencedCounter).CountUnsequenced(ctx, tree)
+			if err != nil {
+				t.Fatalf("CountUnsequenced(): %v", err)
+			}
+			if count != test.wantCount {
+				t.Errorf("CountUnsequenced() = %d, want %d", count, test.wantCount)
+			}
+		})
+	}
+}
+
+func TestDequeueSettlesUnclaimedLeaves(t *testing.T) {
+	ctx := context.Background()
+	ls := NewLogStorage(getTestTable(ctx, t))
+	lsi := ls.(*logStorage)
+	tree := treeWithID(1)
+	storeRoot(ctx, t, ls, tree, &types.LogRootV1{})
+	leaves := testLeaves(2)
+	if _, err := ls.QueueLeaves(ctx, tree, leaves[:1], time.Now()); err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+
+	// Write queue rows without claiming their identities, as QueueLeaves does
+	// before deduping them: a new leaf and a duplicate.
+	queueRow := func(leaf *trillian.LogLeaf) (string, []byte) {
+		t.Helper()
+		leaf = proto.Clone(leaf).(*trillian.LogLeaf)
+		ts := time.Now()
+		leaf.QueueTimestamp = timestamppb.New(ts)
+		b, err := proto.Marshal(leaf)
+		if err != nil {
+			t.Fatalf("Marshal(): %v", err)
+		}
+		key := queueRowKey(tree.TreeId, lsi.bucket(leaf.LeafIdentityHash), ts, leaf.LeafIdentityHash, 0)
+		m := bigtable.NewMutation()
+		m.Set(familyData, colLeaf, cellTime, b)
+		if err := lsi.tbl.Apply(ctx, key, m); err != nil {
+			t.Fatalf("Apply(): %v", err)
+		}
+		return key, b
+	}
+	newKey, newBytes := queueRow(leaves[1])
+	queueRow(leaves[0])
+
+	got := sequence(ctx, t, ls, tree, 0)
+	if len(got) != 2 {
+		t.Fatalf("Dequeued %d leaves, want 2", len(got))
+	}
+	for i, leaf := range got {
+		if string(leaf.LeafValue) != string(leaves[i].LeafValue) {
+			t.Errorf("Dequeued leaf %d = %q, want %q", i, leaf.LeafValue, leaves[i].LeafValue)
+		}
+	}
+	count, err := lsi.CountUnsequenced(ctx, tree)
+	if err != nil {
+		t.Fatalf("CountUnsequenced(): %v", err)
+	}
+	if count != 0 {
+		t.Errorf("CountUnsequenced() = %d, want 0", count)
+	}
+
+	// The late claim of the queue row which the sequencer claimed for finds
+	// its own key, so QueueLeaves doesn't report the leaf as a duplicate.
+	if _, queueKey, err := lsi.claimIdentity(ctx, tree.TreeId, leaves[1].LeafIdentityHash, newBytes, newKey); err != nil {
+		t.Fatalf("claimIdentity(): %v", err)
+	} else if queueKey != newKey {
+		t.Errorf("claimIdentity() queue key = %q, want %q", queueKey, newKey)
+	}
+}
+
+func TestConcurrentCommit(t *testing.T) {
+	ctx := context.Background()
+	ls := NewLogStorage(getTestTable(ctx, t))
+	tree := treeWithID(1)
+	storeRoot(ctx, t, ls, tree, &types.LogRootV1{})
+
+	err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		// Commit another root before this transaction commits.
+		storeRoot(ctx, t, ls, tree, &types.LogRootV1{TimestampNanos: 1})
+		logRoot, err := (&types.LogRootV1{TimestampNanos: 2}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: logRoot})
+	})
+	if got, want := status.Code(err), codes.Aborted; got != want {
+		t.Fatalf("ReadWriteTransaction(): %v, want %v", err, want)
+	}
+
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree(): %v", err)
+	}
+	defer tx.Close()
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot(): %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := root.TimestampNanos, uint64(1); got != want {
+		t.Errorf("TimestampNanos = %d, want %d", got, want)
+	}
+}
+
+func TestRollForward(t *testing.T) {
+	ctx := context.Background()
+	tbl := getTestTable(ctx, t)
+	ls := NewLogStorage(tbl)
+	tree := treeWithID(1)
+	storeRoot(ctx, t, ls, tree, &types.LogRootV1{})
+
+	leaves := testLeaves(3)
+	if _, err := ls.QueueLeaves(ctx, tree, leaves, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+	sequenced := sequence(ctx, t, ls, tree, 0)
+	storeRoot(ctx, t, ls, tree, &types.LogRootV1{TreeSize: 3})
+
+	// Undo the roll forward of a journal writing the leaves, as if the commit
+	// failed after writing the root row.
+	j := &journal{subtrees: map[string][]byte{}, leaves: sequenced}
+	m := bigtable.NewMutation()
+	if err := j.set(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Apply(ctx, rootRowKey(tree.TreeId), m); err != nil {
+		t.Fatalf("Apply(): %v", err)
+	}
+	for _, leaf := range sequenced {
+		if err := deleteRows(ctx, tbl, leafRowKey(tree.TreeId, leaf.LeafIndex)); err != nil {
+			t.Fatalf("deleteRows(): %v", err)
+		}
+	}
+
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree(): %v", err)
+	}
+	defer tx.Close()
+	got, err := tx.GetLeavesByRange(ctx, 0, 3)
+	if err != nil {
+		t.Fatalf("GetLeavesByRange(): %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("GetLeavesByRange() returned %d leaves, want 3", len(got))
+	}
+	byHash, err := tx.GetLeavesByHash(ctx, [][]byte{sequenced[1].MerkleLeafHash}, false)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash(): %v", err)
+	}
+	if len(byHash) != 1 || byHash[0].LeafIndex != 1 {
+		t.Errorf("GetLeavesByHash() = %v, want leaf 1", byHash)
+	}
+}
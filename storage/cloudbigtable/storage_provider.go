@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+)
+
+var (
+	btProject      = flag.String("cloudbigtable_project", "", "Project of the Cloud Bigtable instance. The BIGTABLE_EMULATOR_HOST environment variable selects a Bigtable emulator instead.")
+	btInstance     = flag.String("cloudbigtable_instance", "", "Cloud Bigtable instance")
+	btTable        = flag.String("cloudbigtable_table", "trillian", "Cloud Bigtable table holding all the trees")
+	btAppProfile   = flag.String("cloudbigtable_app_profile", "", "Cloud Bigtable app profile to route requests with, the instance's default profile if unset.")
+	btQueueBuckets = flag.Int("cloudbigtable_queue_buckets", DefaultQueueBuckets, "Number of buckets the unsequenced leaves of each tree are spread across. Must not be lowered while leaves are queued.")
+	btCreateTable  = flag.Bool("cloudbigtable_create_table", false, "If true, the --cloudbigtable_table table and its column families are created if they don't exist.")
+
+	btMu              sync.Mutex
+	btStorageInstance *cloudBigtableProvider
+)
+
+func init() {
+	storage.MustRegisterProvider("cloud_bigtable", newCloudBigtableStorageProvider)
+}
+
+type cloudBigtableProvider struct {
+	client *bigtable.Client
+	tbl    *bigtable.Table
+}
+
+func newCloudBigtableStorageProvider(mf monitoring.MetricFactory) (storage.Provider, error) {
+	btMu.Lock()
+	defer btMu.Unlock()
+
+	if btStorageInstance != nil {
+		return btStorageInstance, nil
+	}
+	if *btProject == "" || *btInstance == "" {
+		return nil, errors.New("--cloudbigtable_project and --cloudbigtable_instance must be set")
+	}
+	cache.InitMetrics(mf)
+
+	ctx := context.TODO()
+	if *btCreateTable {
+		ac, err := bigtable.NewAdminClient(ctx, *btProject, *btInstance)
+		if err != nil {
+			return nil, err
+		}
+		defer ac.Close()
+		if err := CreateTable(ctx, ac, *btTable); err != nil {
+			return nil, err
+		}
+	}
+	client, err := bigtable.NewClientWithConfig(ctx, *btProject, *btInstance, bigtable.ClientConfig{AppProfile: *btAppProfile})
+	if err != nil {
+		return nil, err
+	}
+	btStorageInstance = &cloudBigtableProvider{
+		client: client,
+		tbl:    client.Open(*btTable),
+	}
+	return btStorageInstance, nil
+}
+
+// LogStorage builds and returns a new storage.LogStorage using Cloud Bigtable.
+func (s *cloudBigtableProvider) LogStorage() storage.LogStorage {
+	return NewLogStorageWithOpts(s.tbl, LogStorageOptions{QueueBuckets: *btQueueBuckets})
+}
+
+// AdminStorage builds and returns a new storage.AdminStorage using Cloud
+// Bigtable.
+func (s *cloudBigtableProvider) AdminStorage() storage.AdminStorage {
+	return NewAdminStorage(s.tbl)
+}
+
+// Close shuts down this provider. Calls to the other methods will fail
+// after this.
+func (s *cloudBigtableProvider) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudbigtable
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// families maps the column families of the table to their garbage collection
+// policies. Subtrees keep all their revisions, as older tree roots are read at
+// their revision.
+var families = map[string]bigtable.GCPolicy{
+	familyData:    bigtable.MaxVersionsPolicy(1),
+	familySubtree: bigtable.NoGcPolicy(),
+	familyJournal: bigtable.MaxVersionsPolicy(1),
+}
+
+// CreateTable creates the table with the column families used by the storage,
+// or adds the missing column families if the table already exists.
+func CreateTable(ctx context.Context, ac *bigtable.AdminClient, table string) error {
+	if err := ac.CreateTable(ctx, table); status.Code(err) == codes.AlreadyExists {
+		glog.Infof("Bigtable table %s already exists", table)
+	} else if err != nil {
+		return fmt.Errorf("failed to create table %s: %v", table, err)
+	}
+
+	info, err := ac.TableInfo(ctx, table)
+	if err != nil {
+		return fmt.Errorf("failed to read table %s: %v", table, err)
+	}
+	existing := make(map[string]bool)
+	for _, f := range info.FamilyInfos {
+		existing[f.Name] = true
+	}
+	for family, policy := range families {
+		if existing[family] {
+			continue
+		}
+		if err := ac.CreateColumnFamily(ctx, table, family); err != nil {
+			return fmt.Errorf("failed to create column family %s of table %s: %v", family, table, err)
+		}
+		if err := ac.SetGCPolicy(ctx, table, family, policy); err != nil {
+			return fmt.Errorf("failed to set GC policy of column family %s of table %s: %v", family, table, err)
+		}
+	}
+	return nil
+}
@@ -49,11 +49,45 @@ var (
 		trillian.TreeType_LOG:            spannerpb.TreeType_LOG,
 		trillian.TreeType_PREORDERED_LOG: spannerpb.TreeType_PREORDERED_LOG,
 	}
+	leafDedupPolicyMap = map[trillian.LeafDedupPolicy]spannerpb.LeafDedupPolicy{
+		trillian.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH:   spannerpb.LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH,
+		trillian.LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH: spannerpb.LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH,
+		trillian.LeafDedupPolicy_NO_DEDUP:                 spannerpb.LeafDedupPolicy_NO_DEDUP,
+	}
+	treePriorityMap = map[trillian.TreePriority]spannerpb.TreePriority{
+		trillian.TreePriority_NORMAL_PRIORITY: spannerpb.TreePriority_NORMAL_PRIORITY,
+		trillian.TreePriority_HIGH_PRIORITY:   spannerpb.TreePriority_HIGH_PRIORITY,
+		trillian.TreePriority_LOW_PRIORITY:    spannerpb.TreePriority_LOW_PRIORITY,
+	}
 
-	treeStateReverseMap = reverseTreeStateMap(treeStateMap)
-	treeTypeReverseMap  = reverseTreeTypeMap(treeTypeMap)
+	treeStateReverseMap       = reverseTreeStateMap(treeStateMap)
+	treeTypeReverseMap        = reverseTreeTypeMap(treeTypeMap)
+	leafDedupPolicyReverseMap = reverseLeafDedupPolicyMap(leafDedupPolicyMap)
+	treePriorityReverseMap    = reverseTreePriorityMap(treePriorityMap)
 )
 
+func reverseTreePriorityMap(m map[trillian.TreePriority]spannerpb.TreePriority) map[spannerpb.TreePriority]trillian.TreePriority {
+	reverse := make(map[spannerpb.TreePriority]trillian.TreePriority)
+	for k, v := range m {
+		if x, ok := reverse[v]; ok {
+			glog.Fatalf("Duplicate values for key %v: %v and %v", v, x, k)
+		}
+		reverse[v] = k
+	}
+	return reverse
+}
+
+func reverseLeafDedupPolicyMap(m map[trillian.LeafDedupPolicy]spannerpb.LeafDedupPolicy) map[spannerpb.LeafDedupPolicy]trillian.LeafDedupPolicy {
+	reverse := make(map[spannerpb.LeafDedupPolicy]trillian.LeafDedupPolicy)
+	for k, v := range m {
+		if x, ok := reverse[v]; ok {
+			glog.Fatalf("Duplicate values for key %v: %v and %v", v, x, k)
+		}
+		reverse[v] = k
+	}
+	return reverse
+}
+
 const nanosPerMilli = int64(time.Millisecond / time.Nanosecond)
 
 func reverseTreeStateMap(m map[trillian.TreeState]spannerpb.TreeState) map[spannerpb.TreeState]trillian.TreeState {
@@ -78,9 +112,21 @@ func reverseTreeTypeMap(m map[trillian.TreeType]spannerpb.TreeType) map[spannerp
 	return reverse
 }
 
+var (
+	readTreeIDsSQL = query{
+		googleSQL:  "SELECT t.TreeID FROM TreeRoots t",
+		postgreSQL: `SELECT t."TreeID" FROM "TreeRoots" t`,
+	}
+	readTreesSQL = query{
+		googleSQL:  "SELECT t.TreeInfo FROM TreeRoots t",
+		postgreSQL: `SELECT t."TreeInfo" FROM "TreeRoots" t`,
+	}
+)
+
 // adminTX implements both storage.ReadOnlyAdminTX and storage.AdminTX.
 type adminTX struct {
-	client *spanner.Client
+	client  *spanner.Client
+	dialect Dialect
 
 	// mu guards tx, but it's only actively used for Commit/Close. In other
 	// scenarios we trust Spanner to blow up if you try to use a closed tx.
@@ -100,11 +146,25 @@ type adminTX struct {
 // adminStorage implements storage.AdminStorage.
 type adminStorage struct {
 	client *spanner.Client
+	opts   AdminStorageOptions
+}
+
+// AdminStorageOptions holds various levers for configuring the admin storage
+// instance.
+type AdminStorageOptions struct {
+	// Dialect is the SQL dialect of the database, GoogleSQL by default.
+	Dialect Dialect
 }
 
 // NewAdminStorage returns a Spanner-based storage.AdminStorage implementation.
 func NewAdminStorage(client *spanner.Client) storage.AdminStorage {
-	return &adminStorage{client}
+	return NewAdminStorageWithOpts(client, AdminStorageOptions{})
+}
+
+// NewAdminStorageWithOpts returns a Spanner-based storage.AdminStorage
+// implementation configured with opts.
+func NewAdminStorageWithOpts(client *spanner.Client, opts AdminStorageOptions) storage.AdminStorage {
+	return &adminStorage{client: client, opts: opts}
 }
 
 // CheckDatabaseAccessible implements AdminStorage.CheckDatabaseAccessible.
@@ -115,7 +175,7 @@ func (s *adminStorage) CheckDatabaseAccessible(ctx context.Context) error {
 // Snapshot implements AdminStorage.Snapshot.
 func (s *adminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
 	tx := s.client.ReadOnlyTransaction()
-	return &adminTX{client: s.client, tx: tx}, nil
+	return &adminTX{client: s.client, dialect: s.opts.Dialect, tx: tx}, nil
 }
 
 // Begin implements AdminStorage.Begin.
@@ -126,7 +186,7 @@ func (s *adminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
 // ReadWriteTransaction implements AdminStorage.ReadWriteTransaction.
 func (s *adminStorage) ReadWriteTransaction(ctx context.Context, f storage.AdminTXFunc) error {
 	_, err := s.client.ReadWriteTransaction(ctx, func(ctx context.Context, stx *spanner.ReadWriteTransaction) error {
-		tx := &adminTX{client: s.client, tx: stx}
+		tx := &adminTX{client: s.client, dialect: s.opts.Dialect, tx: stx}
 		return f(ctx, tx)
 	})
 	return err
@@ -248,15 +308,18 @@ func (t *adminTX) ListTrees(ctx context.Context, includeDeleted bool) ([]*trilli
 }
 
 func (t *adminTX) readTrees(ctx context.Context, includeDeleted, idOnly bool, f func(*spanner.Row) error) error {
-	var stmt spanner.Statement
+	q := readTreesSQL
 	if idOnly {
-		stmt = spanner.NewStatement("SELECT t.TreeID FROM TreeRoots t")
-	} else {
-		stmt = spanner.NewStatement("SELECT t.TreeInfo FROM TreeRoots t")
+		q = readTreeIDsSQL
 	}
-	if !includeDeleted {
-		stmt.SQL += " WHERE t.Deleted = @deleted"
-		stmt.Params["deleted"] = false
+	var stmt spanner.Statement
+	if includeDeleted {
+		stmt = q.statement(t.dialect)
+	} else {
+		q.googleSQL += " WHERE t.Deleted = @deleted"
+		q.postgreSQL += ` WHERE t."Deleted" = $1`
+		q.params = []string{"deleted"}
+		stmt = q.statement(t.dialect, false)
 	}
 	rows := t.tx.Query(ctx, stmt)
 	return rows.Do(f)
@@ -322,10 +385,26 @@ func newTreeInfo(tree *trillian.Tree, treeID int64, now time.Time) (*spannerpb.T
 		return nil, status.Errorf(codes.Internal, "unexpected TreeType: %s", tree.TreeType)
 	}
 
+	dp, ok := leafDedupPolicyMap[tree.LeafDedupPolicy]
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected LeafDedupPolicy: %s", tree.LeafDedupPolicy)
+	}
+
+	tp, ok := treePriorityMap[tree.Priority]
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected TreePriority: %s", tree.Priority)
+	}
+
 	if err := tree.MaxRootDuration.CheckValid(); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "malformed MaxRootDuration: %v", err)
 	}
 	maxRootDuration := tree.MaxRootDuration.AsDuration()
+	if tree.SequencerGuardWindow != nil {
+		if err := tree.SequencerGuardWindow.CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "malformed SequencerGuardWindow: %v", err)
+		}
+	}
+	guardWindow := tree.SequencerGuardWindow.AsDuration()
 
 	info := &spannerpb.TreeInfo{
 		TreeId:                treeID,
@@ -333,9 +412,20 @@ func newTreeInfo(tree *trillian.Tree, treeID int64, now time.Time) (*spannerpb.T
 		Description:           tree.Description,
 		TreeState:             ts,
 		TreeType:              tt,
+		LeafDedupPolicy:       dp,
 		CreateTimeNanos:       now.UnixNano(),
 		UpdateTimeNanos:       now.UnixNano(),
 		MaxRootDurationMillis: int64(maxRootDuration / time.Millisecond),
+
+		SequencerGuardWindowMillis: int64(guardWindow / time.Millisecond),
+		SequencerBatchSize:         tree.SequencerBatchSize,
+		Priority:                   tp,
+		SignerAffinity:             tree.SignerAffinity,
+		AntiAffinityGroup:          tree.AntiAffinityGroup,
+		MaxLeafValueSize:           tree.MaxLeafValueSize,
+		MaxExtraDataSize:           tree.MaxExtraDataSize,
+		MaxUnsequencedLeaves:       tree.MaxUnsequencedLeaves,
+		CompactRetainedRevisions:   tree.CompactRetainedRevisions,
 	}
 
 	switch tt := tree.TreeType; tt {
@@ -400,10 +490,21 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 		return nil, status.Errorf(codes.Internal, "unexpected TreeState: %s", tree.TreeState)
 	}
 
+	tp, ok := treePriorityMap[tree.Priority]
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected TreePriority: %s", tree.Priority)
+	}
+
 	if err := tree.MaxRootDuration.CheckValid(); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "malformed MaxRootDuration: %v", err)
 	}
 	maxRootDuration := tree.MaxRootDuration.AsDuration()
+	if tree.SequencerGuardWindow != nil {
+		if err := tree.SequencerGuardWindow.CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "malformed SequencerGuardWindow: %v", err)
+		}
+	}
+	guardWindow := tree.SequencerGuardWindow.AsDuration()
 
 	// Update (just) the mutable fields in treeInfo.
 	now := TimeNow()
@@ -412,6 +513,15 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	info.Description = tree.Description
 	info.UpdateTimeNanos = now.UnixNano()
 	info.MaxRootDurationMillis = int64(maxRootDuration / time.Millisecond)
+	info.SequencerGuardWindowMillis = int64(guardWindow / time.Millisecond)
+	info.SequencerBatchSize = tree.SequencerBatchSize
+	info.Priority = tp
+	info.SignerAffinity = tree.SignerAffinity
+	info.AntiAffinityGroup = tree.AntiAffinityGroup
+	info.MaxLeafValueSize = tree.MaxLeafValueSize
+	info.MaxExtraDataSize = tree.MaxExtraDataSize
+	info.MaxUnsequencedLeaves = tree.MaxUnsequencedLeaves
+	info.CompactRetainedRevisions = tree.CompactRetainedRevisions
 
 	if err := t.updateTreeInfo(ctx, info); err != nil {
 		return nil, err
@@ -548,6 +658,28 @@ func toTrillianTree(info *spannerpb.TreeInfo) (*trillian.Tree, error) {
 	}
 	tree.TreeType = tt
 
+	dp, ok := leafDedupPolicyReverseMap[info.LeafDedupPolicy]
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected LeafDedupPolicy: %s", info.LeafDedupPolicy)
+	}
+	tree.LeafDedupPolicy = dp
+	if info.SequencerGuardWindowMillis > 0 {
+		tree.SequencerGuardWindow = durationpb.New(time.Duration(info.SequencerGuardWindowMillis) * time.Millisecond)
+	}
+	tree.SequencerBatchSize = info.SequencerBatchSize
+
+	tp, ok := treePriorityReverseMap[info.Priority]
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected TreePriority: %s", info.Priority)
+	}
+	tree.Priority = tp
+	tree.SignerAffinity = info.SignerAffinity
+	tree.AntiAffinityGroup = info.AntiAffinityGroup
+	tree.MaxLeafValueSize = info.MaxLeafValueSize
+	tree.MaxExtraDataSize = info.MaxExtraDataSize
+	tree.MaxUnsequencedLeaves = info.MaxUnsequencedLeaves
+	tree.CompactRetainedRevisions = info.CompactRetainedRevisions
+
 	var config proto.Message
 	switch tt := info.TreeType; tt {
 	case spannerpb.TreeType_PREORDERED_LOG:
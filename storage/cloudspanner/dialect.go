@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Dialect is the SQL dialect of a Spanner database.
+type Dialect int
+
+const (
+	// GoogleSQL is the dialect of databases created with spanner.sdl.
+	GoogleSQL Dialect = iota
+	// PostgreSQL is the dialect of databases created with spanner_pg.sdl,
+	// which are accessed through Spanner's PostgreSQL interface.
+	PostgreSQL
+)
+
+// String returns the name of the dialect, as accepted by ParseDialect.
+func (d Dialect) String() string {
+	switch d {
+	case GoogleSQL:
+		return "googlesql"
+	case PostgreSQL:
+		return "postgresql"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// ParseDialect returns the Dialect with the given name, which is either
+// "googlesql" or "postgresql".
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "googlesql":
+		return GoogleSQL, nil
+	case "postgresql":
+		return PostgreSQL, nil
+	}
+	return 0, fmt.Errorf("unknown Spanner dialect %q", name)
+}
+
+// query is an SQL query with a variant for each dialect.
+//
+// Parameters are named in the GoogleSQL variant (@tree_id), and numbered in
+// the PostgreSQL variant ($1), following the order of params. Table and
+// column names must be quoted in the PostgreSQL variant, as spanner_pg.sdl
+// creates them with mixed-case names.
+type query struct {
+	googleSQL  string
+	postgreSQL string
+	params     []string
+}
+
+// statement returns the Spanner statement running q in dialect d, binding
+// args to the parameters of q in order.
+func (q query) statement(d Dialect, args ...interface{}) spanner.Statement {
+	if len(args) != len(q.params) {
+		panic(fmt.Sprintf("query has %d parameters, got %d arguments", len(q.params), len(args)))
+	}
+	var stmt spanner.Statement
+	switch d {
+	case PostgreSQL:
+		stmt = spanner.NewStatement(q.postgreSQL)
+		for i, arg := range args {
+			// The PostgreSQL interface binds $n to the parameter named pn.
+			stmt.Params[fmt.Sprintf("p%d", i+1)] = arg
+		}
+	default:
+		stmt = spanner.NewStatement(q.googleSQL)
+		for i, arg := range args {
+			stmt.Params[q.params[i]] = arg
+		}
+	}
+	return stmt
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDialect(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		want    Dialect
+		wantErr bool
+	}{
+		{name: "googlesql", want: GoogleSQL},
+		{name: "PostgreSQL", want: PostgreSQL},
+		{name: "", wantErr: true},
+		{name: "mysql", wantErr: true},
+	} {
+		got, err := ParseDialect(test.name)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseDialect(%q): %v, want error: %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseDialect(%q)=%v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestQueryStatement(t *testing.T) {
+	q := query{
+		googleSQL:  "SELECT a FROM T WHERE TreeID = @tree_id AND b = @b",
+		postgreSQL: `SELECT "a" FROM "T" WHERE "TreeID" = $1 AND "b" = $2`,
+		params:     []string{"tree_id", "b"},
+	}
+	for _, test := range []struct {
+		dialect Dialect
+		want    spanner.Statement
+	}{
+		{
+			dialect: GoogleSQL,
+			want:    spanner.Statement{SQL: q.googleSQL, Params: map[string]interface{}{"tree_id": int64(1), "b": "x"}},
+		},
+		{
+			dialect: PostgreSQL,
+			want:    spanner.Statement{SQL: q.postgreSQL, Params: map[string]interface{}{"p1": int64(1), "p2": "x"}},
+		},
+	} {
+		got := q.statement(test.dialect, int64(1), "x")
+		if diff := cmp.Diff(got, test.want); diff != "" {
+			t.Errorf("statement(%v) diff (-got +want):\n%s", test.dialect, diff)
+		}
+	}
+}
+
+// TestQueryParams checks that both variants of the storage queries use the
+// parameters they declare.
+func TestQueryParams(t *testing.T) {
+	for name, q := range map[string]query{
+		"readTreeIDsSQL":        readTreeIDsSQL,
+		"readTreesSQL":          readTreesSQL,
+		"getActiveLogIDsSQL":    getActiveLogIDsSQL,
+		"countUnsequencedSQL":   countUnsequencedSQL,
+		"oldestUnsequencedSQL":  oldestUnsequencedSQL,
+		"getSequencedLeavesSQL": getSequencedLeavesSQL,
+		"getLeafDataSQL":        getLeafDataSQL,
+		"latestSTHSQL":          latestSTHSQL,
+		"getSubtreeSQL":         getSubtreeSQL,
+	} {
+		if got, want := strings.Count(q.googleSQL, "@"), len(q.params); got != want {
+			t.Errorf("%s: GoogleSQL variant has %d parameters, want %d", name, got, want)
+		}
+		if got, want := strings.Count(q.postgreSQL, "$"), len(q.params); got != want {
+			t.Errorf("%s: PostgreSQL variant has %d parameters, want %d", name, got, want)
+		}
+		for i, p := range q.params {
+			if !strings.Contains(q.googleSQL, "@"+p) {
+				t.Errorf("%s: GoogleSQL variant doesn't use @%s", name, p)
+			}
+			if !strings.Contains(q.postgreSQL, fmt.Sprintf("$%d", i+1)) {
+				t.Errorf("%s: PostgreSQL variant doesn't use $%d", name, i+1)
+			}
+		}
+	}
+}
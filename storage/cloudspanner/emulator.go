@@ -0,0 +1,156 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/golang/glog"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+)
+
+var (
+	csEmulatorHost   = flag.String("cloudspanner_emulator_host", "", "Address of a Cloud Spanner emulator to use instead of CloudSpanner, which is connected to without TLS or credentials. The SPANNER_EMULATOR_HOST environment variable is used if unset.")
+	csCreateDatabase = flag.Bool("cloudspanner_create_database", false, "If true, the --cloudspanner_uri database is created with the schema of --cloudspanner_dialect if it doesn't exist, along with its instance if using the emulator.")
+)
+
+// emulatorConfig is the name of the only instance configuration offered by
+// the Cloud Spanner emulator.
+const emulatorConfig = "emulator-config"
+
+var dbPathRE = regexp.MustCompile(`^projects/([^/]+)/instances/([^/]+)/databases/([^/]+)$`)
+
+// EmulatorOptions returns the client options connecting to the Cloud Spanner
+// emulator listening on host. The emulator doesn't support TLS or
+// authentication.
+func EmulatorOptions(host string) []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(host),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithoutAuthentication(),
+	}
+}
+
+// emulatorOptionsFromFlags returns the client options connecting to the
+// emulator set by --cloudspanner_emulator_host, if any. The Spanner client
+// libraries connect to SPANNER_EMULATOR_HOST on their own.
+func emulatorOptionsFromFlags() []option.ClientOption {
+	if *csEmulatorHost == "" {
+		return nil
+	}
+	return EmulatorOptions(*csEmulatorHost)
+}
+
+// CreateDatabase creates the database dbPath, of the form
+// projects/P/instances/I/databases/D, with the Trillian schema of dialect d.
+// It does nothing if the database already exists, whatever its schema.
+func CreateDatabase(ctx context.Context, dbPath string, d Dialect, opts ...option.ClientOption) error {
+	m := dbPathRE.FindStringSubmatch(dbPath)
+	if m == nil {
+		return fmt.Errorf("invalid database path %q, want projects/P/instances/I/databases/D", dbPath)
+	}
+	ddl, err := dialectDDL(d)
+	if err != nil {
+		return err
+	}
+	client, err := database.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create database admin client: %v", err)
+	}
+	defer client.Close()
+
+	req := &databasepb.CreateDatabaseRequest{
+		Parent:          fmt.Sprintf("projects/%s/instances/%s", m[1], m[2]),
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", m[3]),
+		ExtraStatements: ddl,
+	}
+	if d == PostgreSQL {
+		// PostgreSQL-dialect databases can't be created along with their schema.
+		req.CreateStatement = fmt.Sprintf("CREATE DATABASE %q", m[3])
+		req.ExtraStatements = nil
+		req.DatabaseDialect = databasepb.DatabaseDialect_POSTGRESQL
+	}
+	op, err := client.CreateDatabase(ctx, req)
+	if status.Code(err) == codes.AlreadyExists {
+		glog.Infof("CloudSpanner database %s already exists", dbPath)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to create database %s: %v", dbPath, err)
+	}
+	if _, err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to create database %s: %v", dbPath, err)
+	}
+	if d == PostgreSQL {
+		op, err := client.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database:   dbPath,
+			Statements: ddl,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create schema of database %s: %v", dbPath, err)
+		}
+		if err := op.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to create schema of database %s: %v", dbPath, err)
+		}
+	}
+	glog.Infof("Created CloudSpanner database %s", dbPath)
+	return nil
+}
+
+// CreateEmulatorInstance creates the instance of the database dbPath on the
+// Cloud Spanner emulator, which starts without any instance. It does nothing
+// if the instance already exists.
+func CreateEmulatorInstance(ctx context.Context, dbPath string, opts ...option.ClientOption) error {
+	m := dbPathRE.FindStringSubmatch(dbPath)
+	if m == nil {
+		return fmt.Errorf("invalid database path %q, want projects/P/instances/I/databases/D", dbPath)
+	}
+	client, err := instance.NewInstanceAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create instance admin client: %v", err)
+	}
+	defer client.Close()
+
+	project := "projects/" + m[1]
+	op, err := client.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     project,
+		InstanceId: m[2],
+		Instance: &instancepb.Instance{
+			Config:      project + "/instanceConfigs/" + emulatorConfig,
+			DisplayName: m[2],
+			NodeCount:   1,
+		},
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to create instance %s/instances/%s: %v", project, m[2], err)
+	}
+	if _, err := op.Wait(ctx); err != nil && status.Code(err) != codes.AlreadyExists {
+		return fmt.Errorf("failed to create instance %s/instances/%s: %v", project, m[2], err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	lropb "google.golang.org/genproto/googleapis/longrunning"
+	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+)
+
+// fakeAdmin records the instances and databases it is asked to create by its
+// instance and database admin servers.
+type fakeAdmin struct {
+	mu        sync.Mutex
+	instances map[string]*instancepb.CreateInstanceRequest
+	databases map[string]*databasepb.CreateDatabaseRequest
+	ddl       map[string][]string
+}
+
+type fakeInstanceAdmin struct {
+	instancepb.UnimplementedInstanceAdminServer
+	*fakeAdmin
+}
+
+type fakeDatabaseAdmin struct {
+	databasepb.UnimplementedDatabaseAdminServer
+	*fakeAdmin
+}
+
+func startFakeAdmin(t *testing.T) (*fakeAdmin, string) {
+	t.Helper()
+	f := &fakeAdmin{
+		instances: make(map[string]*instancepb.CreateInstanceRequest),
+		databases: make(map[string]*databasepb.CreateDatabaseRequest),
+		ddl:       make(map[string][]string),
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	s := grpc.NewServer()
+	databasepb.RegisterDatabaseAdminServer(s, &fakeDatabaseAdmin{fakeAdmin: f})
+	instancepb.RegisterInstanceAdminServer(s, &fakeInstanceAdmin{fakeAdmin: f})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return f, lis.Addr().String()
+}
+
+func (f *fakeInstanceAdmin) CreateInstance(ctx context.Context, req *instancepb.CreateInstanceRequest) (*lropb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.Parent + "/instances/" + req.InstanceId
+	if _, ok := f.instances[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "instance %s already exists", name)
+	}
+	f.instances[name] = req
+	a, _ := anypb.New(&instancepb.Instance{Name: name})
+	return &lropb.Operation{Name: "op", Done: true, Result: &lropb.Operation_Response{Response: a}}, nil
+}
+
+func (f *fakeDatabaseAdmin) CreateDatabase(ctx context.Context, req *databasepb.CreateDatabaseRequest) (*lropb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.Parent + "/databases/" + req.CreateStatement
+	if _, ok := f.databases[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "database %s already exists", name)
+	}
+	f.databases[name] = req
+	a, _ := anypb.New(&databasepb.Database{Name: name})
+	return &lropb.Operation{Name: "op", Done: true, Result: &lropb.Operation_Response{Response: a}}, nil
+}
+
+func (f *fakeDatabaseAdmin) UpdateDatabaseDdl(ctx context.Context, req *databasepb.UpdateDatabaseDdlRequest) (*lropb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ddl[req.Database] = append(f.ddl[req.Database], req.Statements...)
+	a, _ := anypb.New(&emptypb.Empty{})
+	return &lropb.Operation{Name: "op", Done: true, Result: &lropb.Operation_Response{Response: a}}, nil
+}
+
+func TestCreateDatabase(t *testing.T) {
+	ctx := context.Background()
+	const (
+		instancePath = "projects/p/instances/i"
+		dbPath       = instancePath + "/databases/d"
+	)
+	googleDDL, err := readDDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgDDL, err := readPGDDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		dialect       Dialect
+		wantStatement string
+		wantDialect   databasepb.DatabaseDialect
+		wantExtra     []string
+		wantDDL       []string
+	}{
+		{
+			dialect:       GoogleSQL,
+			wantStatement: "CREATE DATABASE `d`",
+			wantExtra:     googleDDL,
+		},
+		{
+			dialect:       PostgreSQL,
+			wantStatement: `CREATE DATABASE "d"`,
+			wantDialect:   databasepb.DatabaseDialect_POSTGRESQL,
+			wantDDL:       pgDDL,
+		},
+	} {
+		t.Run(test.dialect.String(), func(t *testing.T) {
+			f, addr := startFakeAdmin(t)
+			opts := EmulatorOptions(addr)
+
+			// Creating the instance and database again does nothing.
+			for i := 0; i < 2; i++ {
+				if err := CreateEmulatorInstance(ctx, dbPath, opts...); err != nil {
+					t.Fatalf("CreateEmulatorInstance(): %v", err)
+				}
+				if err := CreateDatabase(ctx, dbPath, test.dialect, opts...); err != nil {
+					t.Fatalf("CreateDatabase(): %v", err)
+				}
+			}
+
+			inst, ok := f.instances[instancePath]
+			if !ok {
+				t.Fatalf("instance %s not created, got %v", instancePath, f.instances)
+			}
+			if got, want := inst.Instance.Config, "projects/p/instanceConfigs/emulator-config"; got != want {
+				t.Errorf("instance config = %q, want %q", got, want)
+			}
+			req, ok := f.databases[instancePath+"/databases/"+test.wantStatement]
+			if !ok {
+				t.Fatalf("database not created with %q, got %v", test.wantStatement, f.databases)
+			}
+			if got := req.DatabaseDialect; got != test.wantDialect {
+				t.Errorf("database dialect = %v, want %v", got, test.wantDialect)
+			}
+			if got := len(req.ExtraStatements); got != len(test.wantExtra) {
+				t.Errorf("database created with %d statements, want %d", got, len(test.wantExtra))
+			}
+			if got := len(f.ddl[dbPath]); got != len(test.wantDDL) {
+				t.Errorf("schema updated with %d statements, want %d", got, len(test.wantDDL))
+			}
+		})
+	}
+}
+
+func TestCreateDatabaseInvalidPath(t *testing.T) {
+	ctx := context.Background()
+	for _, path := range []string{"", "projects/p/instances/i", "projects/p/instances/i/databases/d/x"} {
+		if err := CreateDatabase(ctx, path, GoogleSQL); err == nil {
+			t.Errorf("CreateDatabase(%q) succeeded, want error", path)
+		}
+		if err := CreateEmulatorInstance(ctx, path); err == nil {
+			t.Errorf("CreateEmulatorInstance(%q) succeeded, want error", path)
+		}
+	}
+}
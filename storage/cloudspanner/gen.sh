@@ -1,15 +1,20 @@
 #! /bin/sh
-cat > spanner.sdl.go <<EOF
+# gen writes the schema in $1 to the Go file $2, as the base64 constant $3.
+gen() {
+cat > $2 <<EOG
 // Code generated by storage/cloudspanner/gen.sh DO NOT EDIT.
 
 package cloudspanner
 
-const base64DDL = \`
-EOF
+const $3 = \`
+EOG
 if [[ "$OSTYPE" == "darwin"* ]]; then
-base64 -b 76 < spanner.sdl >> spanner.sdl.go
+base64 -b 76 < $1 >> $2
 else
-base64 -w 76 < spanner.sdl >> spanner.sdl.go
+base64 -w 76 < $1 >> $2
 fi
-echo '`' >> spanner.sdl.go
+echo '`' >> $2
+}
 
+gen spanner.sdl spanner.sdl.go base64DDL
+gen spanner_pg.sdl spanner_pg.sdl.go base64PGDDL
@@ -38,6 +38,12 @@ import (
 // To run cloudspanner tests,
 // 1) Set the -test_cloud_spanner_database flag
 // 2) Set application default credentials `gcloud auth application-default login`
+//
+// To run them against the Cloud Spanner emulator instead of the in-memory
+// fake, start it and set SPANNER_EMULATOR_HOST:
+//
+//	gcloud emulators spanner start
+//	SPANNER_EMULATOR_HOST=localhost:9010 go test ./storage/cloudspanner
 
 var cloudDBPath = flag.String("test_cloud_spanner_database", ":memory:", "eg: projects/my-project/instances/my-instance/database/my-db")
 
@@ -48,6 +54,9 @@ func GetTestDB(ctx context.Context, t *testing.T) *spanner.Client {
 		t.Skip("-test_cloud_spanner_database flag is unset")
 		return nil
 	case ":memory:":
+		if os.Getenv("SPANNER_EMULATOR_HOST") != "" {
+			return emulatorClient(ctx, t, emulatorDBName("test-project", "test-instance"))
+		}
 		ddl, err := readDDL()
 		if err != nil {
 			t.Fatal(err)
@@ -81,6 +90,16 @@ func uniqueDBName(project, instance string) string {
 	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, database)
 }
 
+// emulatorDBName returns a unique database name which is valid in Cloud
+// Spanner, unlike those of uniqueDBName: lower case, and at most 30
+// characters long.
+func emulatorDBName(project, instance string) string {
+	dbCount.Lock()
+	defer dbCount.Unlock()
+	dbCount.count++
+	return fmt.Sprintf("projects/%s/instances/%s/databases/test-%d-%d", project, instance, os.Getpid(), dbCount.count)
+}
+
 func inMemClient(ctx context.Context, t testing.TB, dbName string, statements []string) *spanner.Client {
 	t.Helper()
 	// Don't use SPANNER_EMULATOR_HOST because we need the raw connection for
@@ -126,6 +145,25 @@ func inMemClient(ctx context.Context, t testing.TB, dbName string, statements []
 	return client
 }
 
+// emulatorClient creates the database dbName on the emulator at
+// SPANNER_EMULATOR_HOST, which the Spanner client libraries connect to.
+func emulatorClient(ctx context.Context, t testing.TB, dbName string) *spanner.Client {
+	t.Helper()
+	t.Logf("Using Cloud Spanner emulator DB: %s", dbName)
+	if err := CreateEmulatorInstance(ctx, dbName); err != nil {
+		t.Fatalf("CreateEmulatorInstance(): %v", err)
+	}
+	if err := CreateDatabase(ctx, dbName, GoogleSQL); err != nil {
+		t.Fatalf("CreateDatabase(): %v", err)
+	}
+	client, err := spanner.NewClient(ctx, dbName)
+	if err != nil {
+		t.Fatalf("Connecting to emulator: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
 func cleanTestDB(ctx context.Context, t *testing.T, db *spanner.Client) {
 	if _, err := db.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
 		mutations := make([]*spanner.Mutation, 0)
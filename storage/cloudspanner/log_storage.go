@@ -45,13 +45,87 @@ const (
 	seqDataByMerkleHashIdx = "SequenceByMerkleHash"
 	seqDataTbl             = "SequencedLeafData"
 	unseqTable             = "Unsequenced"
+)
 
+var (
 	// t.TreeType: 1 = Log, 3 = PreorderedLog.
 	// t.TreeState: 1 = Active, 5 = Draining.
-	getActiveLogIDsSQL = `SELECT t.TreeID FROM TreeRoots t
+	getActiveLogIDsSQL = query{
+		googleSQL: `SELECT t.TreeID FROM TreeRoots t
 WHERE (t.TreeType = 1 OR t.TreeType = 3)
 AND (t.TreeState = 1 OR t.TreeState = 5)
-AND t.Deleted=false`
+AND t.Deleted=false`,
+		postgreSQL: `SELECT t."TreeID" FROM "TreeRoots" t
+WHERE (t."TreeType" = 1 OR t."TreeType" = 3)
+AND (t."TreeState" = 1 OR t."TreeState" = 5)
+AND t."Deleted"=false`,
+	}
+
+	countUnsequencedSQL = query{
+		googleSQL:  `SELECT COUNT(*) FROM Unsequenced WHERE TreeID = @tree_id`,
+		postgreSQL: `SELECT COUNT(*) FROM "Unsequenced" WHERE "TreeID" = $1`,
+		params:     []string{"tree_id"},
+	}
+	oldestUnsequencedSQL = query{
+		googleSQL:  `SELECT MIN(QueueTimestampNanos) FROM Unsequenced WHERE TreeID = @tree_id`,
+		postgreSQL: `SELECT MIN("QueueTimestampNanos") FROM "Unsequenced" WHERE "TreeID" = $1`,
+		params:     []string{"tree_id"},
+	}
+
+	// TODO: replace with INNER JOIN when spannertest supports JOINs
+	// https://github.com/googleapis/google-cloud-go/tree/master/spanner/spannertest
+	getSequencedLeavesSQL = query{
+		googleSQL: `SELECT 
+		   TreeID,
+		   SequenceNumber,
+		   LeafIdentityHash,
+		   MerkleLeafHash, 
+		   IntegrateTimestampNanos
+		 FROM 
+		   SequencedLeafData
+		 WHERE 
+		   TreeID = @tree_id AND 
+		   SequenceNumber >= @start AND 
+		   SequenceNumber < @xend`,
+		postgreSQL: `SELECT
+		   "TreeID",
+		   "SequenceNumber",
+		   "LeafIdentityHash",
+		   "MerkleLeafHash",
+		   "IntegrateTimestampNanos"
+		 FROM
+		   "SequencedLeafData"
+		 WHERE
+		   "TreeID" = $1 AND
+		   "SequenceNumber" >= $2 AND
+		   "SequenceNumber" < $3`,
+		params: []string{"tree_id", "start", "xend"},
+	}
+	getLeafDataSQL = query{
+		googleSQL: `SELECT 
+		   TreeID,
+		   LeafIdentityHash, 
+		   LeafValue, 
+		   ExtraData, 
+		   QueueTimestampNanos
+		 FROM 
+		   LeafData
+		 WHERE 
+		   TreeID = @tree_id AND 
+		   LeafIdentityHash IN UNNEST(@id_hashes)`,
+		postgreSQL: `SELECT
+		   "TreeID",
+		   "LeafIdentityHash",
+		   "LeafValue",
+		   "ExtraData",
+		   "QueueTimestampNanos"
+		 FROM
+		   "LeafData"
+		 WHERE
+		   "TreeID" = $1 AND
+		   "LeafIdentityHash" = ANY($2)`,
+		params: []string{"tree_id", "id_hashes"},
+	}
 )
 
 // LogStorageOptions are tuning, experiments and workarounds that can be used.
@@ -154,8 +228,8 @@ func (ls *logStorage) readOnlyTX() *spanner.ReadOnlyTransaction {
 func (ls *logStorage) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
 	ids := []int64{}
 	// We have to use SQL as Read() doesn't work against an index.
-	stmt := spanner.NewStatement(getActiveLogIDsSQL)
-	rows := ls.readOnlyTX().Query(ctx, stmt)
+	stmt := getActiveLogIDsSQL.statement(ls.opts.Dialect)
+	rows := ls.ts.query(ctx, ls.readOnlyTX(), stmt)
 	if err := rows.Do(func(r *spanner.Row) error {
 		var id int64
 		if err := r.Columns(&id); err != nil {
@@ -171,7 +245,7 @@ func (ls *logStorage) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
 }
 
 func newLogCache(tree *trillian.Tree) (*cache.SubtreeCache, error) {
-	return cache.NewLogSubtreeCache(rfc6962.DefaultHasher), nil
+	return cache.NewLogSubtreeCacheWithOpts(rfc6962.DefaultHasher, cache.Options{TreeID: tree.TreeId}), nil
 }
 
 func (ls *logStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool, stx spanRead) (*logTX, error) {
@@ -201,7 +275,7 @@ func (ls *logStorage) BeginForTree(ctx context.Context, treeID int64) (storage.L
 }
 
 func (ls *logStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.LogTXFunc) error {
-	_, err := ls.ts.client.ReadWriteTransaction(ctx, func(ctx context.Context, stx *spanner.ReadWriteTransaction) error {
+	_, err := ls.ts.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, stx *spanner.ReadWriteTransaction) error {
 		tx, err := ls.begin(ctx, tree, false /* readonly */, stx)
 		if err != nil && err != storage.ErrTreeNeedsInit {
 			return err
@@ -210,7 +284,7 @@ func (ls *logStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.T
 			return err
 		}
 		return tx.flushSubtrees(ctx)
-	})
+	}, ls.ts.transactionOptions())
 	return err
 }
 
@@ -269,7 +343,7 @@ func (ls *logStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree, leav
 				return
 			}
 
-			_, err = ls.ts.client.Apply(ctx, []*spanner.Mutation{m1, m2})
+			_, err = ls.ts.client.Apply(ctx, []*spanner.Mutation{m1, m2}, ls.ts.applyOptions()...)
 			if spanner.ErrCode(err) == codes.AlreadyExists {
 				k := string(l.LeafIdentityHash)
 				writeDupes[k] = append(writeDupes[k], i)
@@ -360,7 +434,7 @@ func (ls *logStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tre
 			go func() {
 				defer ls.writeSem.Release(1)
 				doneFunc(func() error {
-					_, err := ls.ts.client.Apply(ctx, m)
+					_, err := ls.ts.client.Apply(ctx, m, ls.ts.applyOptions()...)
 					return err
 				}())
 			}()
@@ -383,6 +457,33 @@ func (ls *logStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tre
 	return res, nil
 }
 
+// CountUnsequenced implements storage.UnsequencedCounter.
+func (ls *logStorage) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	stmt := countUnsequencedSQL.statement(ls.opts.Dialect, tree.TreeId)
+	var count int64
+	if err := ls.ts.query(ctx, ls.ts.client.Single(), stmt).Do(func(r *spanner.Row) error {
+		return r.Columns(&count)
+	}); err != nil {
+		return 0, fmt.Errorf("problem executing countUnsequencedSQL: %v", err)
+	}
+	return count, nil
+}
+
+// OldestUnsequenced implements storage.UnsequencedAger.
+func (ls *logStorage) OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error) {
+	stmt := oldestUnsequencedSQL.statement(ls.opts.Dialect, tree.TreeId)
+	var oldest spanner.NullInt64
+	if err := ls.ts.query(ctx, ls.ts.client.Single(), stmt).Do(func(r *spanner.Row) error {
+		return r.Columns(&oldest)
+	}); err != nil {
+		return time.Time{}, fmt.Errorf("problem executing oldestUnsequencedSQL: %v", err)
+	}
+	if !oldest.Valid {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, oldest.Int64), nil
+}
+
 // readDupeLeaves reads the leaves whose ids are passed as keys in the dupes map,
 // and stores them in results.
 func (ls *logStorage) readDupeLeaves(ctx context.Context, logID int64, dupes map[string][]int, results []*trillian.QueuedLogLeaf) error {
@@ -398,7 +499,7 @@ func (ls *logStorage) readDupeLeaves(ctx context.Context, logID int64, dupes map
 	}
 	dupesRead := 0
 	tx := ls.ts.client.Single()
-	err := readLeaves(ctx, tx, logID, ids, func(l *trillian.LogLeaf) {
+	err := ls.ts.readLeaves(ctx, tx, logID, ids, func(l *trillian.LogLeaf) {
 		glog.V(2).Infof("Found already exists dupe: %v", l)
 		dupesRead++
 
@@ -481,6 +582,24 @@ func (tx *logTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRo
 	return &trillian.SignedLogRoot{LogRoot: logRoot}, nil
 }
 
+// SignedLogRootAt implements storage.RootAtTimeReader.
+func (tx *logTX) SignedLogRootAt(ctx context.Context, ts time.Time) (*trillian.SignedLogRoot, error) {
+	th, err := tx.ts.sthAtTime(ctx, tx.stx, tx.treeID, ts.UnixNano())
+	if err != nil || th == nil {
+		return nil, err
+	}
+	logRoot, err := (&types.LogRootV1{
+		TimestampNanos: uint64(th.TsNanos),
+		RootHash:       th.RootHash,
+		TreeSize:       uint64(th.TreeSize),
+		Metadata:       th.Metadata,
+	}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.SignedLogRoot{LogRoot: logRoot}, nil
+}
+
 // StoreSignedLogRoot stores the provided root.
 // This method will return an error if the caller attempts to store more than
 // one root per log for a given tree size.
@@ -526,7 +645,7 @@ func (tx *logTX) StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLo
 	return stx.BufferWrite([]*spanner.Mutation{m})
 }
 
-func readLeaves(ctx context.Context, stx *spanner.ReadOnlyTransaction, logID int64, ids [][]byte, f func(*trillian.LogLeaf)) error {
+func (t *treeStorage) readLeaves(ctx context.Context, stx *spanner.ReadOnlyTransaction, logID int64, ids [][]byte, f func(*trillian.LogLeaf)) error {
 	leafTable := leafDataTbl
 	cols := []string{colLeafIdentityHash, colLeafValue, colExtraData, colQueueTimestampNanos}
 	keys := make([]spanner.KeySet, 0)
@@ -534,7 +653,7 @@ func readLeaves(ctx context.Context, stx *spanner.ReadOnlyTransaction, logID int
 		keys = append(keys, spanner.Key{logID, l})
 	}
 
-	rows := stx.Read(ctx, leafTable, spanner.KeySets(keys...), cols)
+	rows := t.read(ctx, stx, leafTable, "", spanner.KeySets(keys...), cols)
 	return rows.Do(func(r *spanner.Row) error {
 		var l trillian.LogLeaf
 		var qTimestamp int64
@@ -624,7 +743,7 @@ func (tx *logTX) DequeueLeaves(ctx context.Context, limit int, cutoff time.Time)
 
 	errBreak := errors.New("break")
 	ret := make([]*trillian.LogLeaf, 0, limit)
-	if err := tx.stx.Read(ctx, unseqTable, spanner.KeySets(keysets...),
+	if err := tx.ts.read(ctx, tx.stx, unseqTable, "", spanner.KeySets(keysets...),
 		[]string{"Bucket", colQueueTimestampNanos, colMerkleLeafHash, colLeafIdentityHash},
 	).Do(func(r *spanner.Row) error {
 		var l trillian.LogLeaf
@@ -790,7 +909,7 @@ func (tx *logTX) populateLeafData(ctx context.Context, byHash leavesByHash) erro
 		keySet = append(keySet, spanner.Key{tx.treeID, []byte(k)})
 	}
 	cols := []string{colLeafIdentityHash, colLeafValue, colExtraData, colQueueTimestampNanos}
-	rows := tx.stx.Read(ctx, leafDataTbl, spanner.KeySets(keySet...), cols)
+	rows := tx.ts.read(ctx, tx.stx, leafDataTbl, "", spanner.KeySets(keySet...), cols)
 	return rows.Do(byHash.addRow)
 }
 
@@ -828,26 +947,9 @@ func (tx *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*t
 		count = xend - start
 	}
 
-	// TODO: replace with INNER JOIN when spannertest supports JOINs
-	// https://github.com/googleapis/google-cloud-go/tree/master/spanner/spannertest
-	stmt := spanner.NewStatement(
-		`SELECT 
-		   TreeID,
-		   SequenceNumber,
-		   LeafIdentityHash,
-		   MerkleLeafHash, 
-		   IntegrateTimestampNanos
-		 FROM 
-		   SequencedLeafData
-		 WHERE 
-		   TreeID = @tree_id AND 
-		   SequenceNumber >= @start AND 
-		   SequenceNumber < @xend`)
-	stmt.Params["tree_id"] = tx.treeID
-	stmt.Params["start"] = start
-	stmt.Params["xend"] = xend
+	stmt := getSequencedLeavesSQL.statement(tx.ts.opts.Dialect, tx.treeID, start, xend)
 	seqLeaves := make(map[string]sequencedLeafDataCols)
-	if err := tx.stx.Query(ctx, stmt).Do(func(r *spanner.Row) error {
+	if err := tx.ts.query(ctx, tx.stx, stmt).Do(func(r *spanner.Row) error {
 		var seqLeaf sequencedLeafDataCols
 		if err := r.ToStruct(&seqLeaf); err != nil {
 			return err
@@ -863,25 +965,12 @@ func (tx *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*t
 		idHashes = append(idHashes, l.LeafIdentityHash)
 	}
 
-	stmt = spanner.NewStatement(
-		`SELECT 
-		   TreeID,
-		   LeafIdentityHash, 
-		   LeafValue, 
-		   ExtraData, 
-		   QueueTimestampNanos
-		 FROM 
-		   LeafData
-		 WHERE 
-		   TreeID = @tree_id AND 
-		   LeafIdentityHash IN UNNEST(@id_hashes)`)
-	stmt.Params["tree_id"] = tx.treeID
-	stmt.Params["id_hashes"] = idHashes
+	stmt = getLeafDataSQL.statement(tx.ts.opts.Dialect, tx.treeID, idHashes)
 
 	// Results need to be returned in order [start, end), all of which
 	// should be available (as we restricted xend/count to TreeSize).
 	leaves := make(leafmap)
-	if err := tx.stx.Query(ctx, stmt).
+	if err := tx.ts.query(ctx, tx.stx, stmt).
 		Do(leaves.addFullRow(seqLeaves)); err != nil {
 		return nil, err
 	}
@@ -940,7 +1029,7 @@ func (tx *logTX) getUsingIndex(ctx context.Context, idx string, keys [][]byte, b
 
 	leaves := make(leafSlice, 0, len(keys))
 	cols := []string{colSequenceNumber, colMerkleLeafHash, colLeafIdentityHash}
-	rows := tx.stx.ReadUsingIndex(ctx, seqDataTbl, idx, spanner.KeySets(keySet...), cols)
+	rows := tx.ts.read(ctx, tx.stx, seqDataTbl, idx, spanner.KeySets(keySet...), cols)
 	if err := rows.Do(leaves.addRow); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,39 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"fmt"
+	"strings"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// ParsePriority returns the Spanner request priority with the given name,
+// which is one of "low", "medium" or "high". The empty name is the
+// unspecified priority, which Spanner treats as high.
+func ParsePriority(name string) (sppb.RequestOptions_Priority, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return sppb.RequestOptions_PRIORITY_UNSPECIFIED, nil
+	case "low":
+		return sppb.RequestOptions_PRIORITY_LOW, nil
+	case "medium":
+		return sppb.RequestOptions_PRIORITY_MEDIUM, nil
+	case "high":
+		return sppb.RequestOptions_PRIORITY_HIGH, nil
+	}
+	return 0, fmt.Errorf("unknown Spanner request priority %q", name)
+}
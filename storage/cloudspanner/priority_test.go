@@ -0,0 +1,44 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"testing"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+func TestParsePriority(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		want    sppb.RequestOptions_Priority
+		wantErr bool
+	}{
+		{name: "", want: sppb.RequestOptions_PRIORITY_UNSPECIFIED},
+		{name: "low", want: sppb.RequestOptions_PRIORITY_LOW},
+		{name: "Medium", want: sppb.RequestOptions_PRIORITY_MEDIUM},
+		{name: "HIGH", want: sppb.RequestOptions_PRIORITY_HIGH},
+		{name: "urgent", wantErr: true},
+	} {
+		got, err := ParsePriority(test.name)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParsePriority(%q): %v, want error: %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParsePriority(%q)=%v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -16,6 +16,8 @@ package cloudspanner
 
 import (
 	"encoding/base64"
+	"fmt"
+	"strings"
 
 	"cloud.google.com/go/spanner/spansql"
 )
@@ -38,3 +40,38 @@ func readDDL() ([]string, error) {
 	}
 	return stmts, nil
 }
+
+// readPGDDL returns a list of DDL statements from the PostgreSQL-dialect
+// database schema. Statements are separated by semicolons, and comments take
+// whole lines.
+func readPGDDL() ([]string, error) {
+	ddlString, err := base64.StdEncoding.DecodeString(base64PGDDL)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(ddlString), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	var stmts []string
+	for _, s := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			stmts = append(stmts, s)
+		}
+	}
+	return stmts, nil
+}
+
+// dialectDDL returns the DDL statements creating the schema of a database of
+// dialect d.
+func dialectDDL(d Dialect) ([]string, error) {
+	switch d {
+	case GoogleSQL:
+		return readDDL()
+	case PostgreSQL:
+		return readPGDDL()
+	}
+	return nil, fmt.Errorf("no schema for dialect %v", d)
+}
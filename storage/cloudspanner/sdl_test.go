@@ -14,7 +14,10 @@
 
 package cloudspanner
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestDDL(t *testing.T) {
 	_, err := readDDL()
@@ -22,3 +25,22 @@ func TestDDL(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPGDDL(t *testing.T) {
+	stmts, err := readPGDDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ddl, err := readDDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(stmts), len(ddl); got != want {
+		t.Errorf("readPGDDL() returned %d statements, want %d as in spanner.sdl", got, want)
+	}
+	for _, stmt := range stmts {
+		if !strings.HasPrefix(stmt, "CREATE ") || strings.Contains(stmt, "--") {
+			t.Errorf("readPGDDL() returned malformed statement %q", stmt)
+		}
+	}
+}
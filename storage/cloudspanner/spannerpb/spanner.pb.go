@@ -292,6 +292,108 @@ func (SignatureAlgorithm) EnumDescriptor() ([]byte, []int) {
 	return file_spanner_proto_rawDescGZIP(), []int{4}
 }
 
+// Defines how leaves are deduplicated.
+// Mirrors trillian.LeafDedupPolicy.
+type LeafDedupPolicy int32
+
+const (
+	LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH   LeafDedupPolicy = 0
+	LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH LeafDedupPolicy = 1
+	LeafDedupPolicy_NO_DEDUP                 LeafDedupPolicy = 2
+)
+
+// Enum value maps for LeafDedupPolicy.
+var (
+	LeafDedupPolicy_name = map[int32]string{
+		0: "DEDUP_BY_IDENTITY_HASH",
+		1: "DEDUP_BY_LEAF_VALUE_HASH",
+		2: "NO_DEDUP",
+	}
+	LeafDedupPolicy_value = map[string]int32{
+		"DEDUP_BY_IDENTITY_HASH":   0,
+		"DEDUP_BY_LEAF_VALUE_HASH": 1,
+		"NO_DEDUP":                 2,
+	}
+)
+
+func (x LeafDedupPolicy) Enum() *LeafDedupPolicy {
+	p := new(LeafDedupPolicy)
+	*p = x
+	return p
+}
+
+func (x LeafDedupPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LeafDedupPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_spanner_proto_enumTypes[5].Descriptor()
+}
+
+func (LeafDedupPolicy) Type() protoreflect.EnumType {
+	return &file_spanner_proto_enumTypes[5]
+}
+
+func (x LeafDedupPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LeafDedupPolicy.Descriptor instead.
+func (LeafDedupPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_spanner_proto_rawDescGZIP(), []int{5}
+}
+
+// Defines the priority of a tree in the log signer.
+// Mirrors trillian.TreePriority.
+type TreePriority int32
+
+const (
+	TreePriority_NORMAL_PRIORITY TreePriority = 0
+	TreePriority_HIGH_PRIORITY   TreePriority = 1
+	TreePriority_LOW_PRIORITY    TreePriority = 2
+)
+
+// Enum value maps for TreePriority.
+var (
+	TreePriority_name = map[int32]string{
+		0: "NORMAL_PRIORITY",
+		1: "HIGH_PRIORITY",
+		2: "LOW_PRIORITY",
+	}
+	TreePriority_value = map[string]int32{
+		"NORMAL_PRIORITY": 0,
+		"HIGH_PRIORITY":   1,
+		"LOW_PRIORITY":    2,
+	}
+)
+
+func (x TreePriority) Enum() *TreePriority {
+	p := new(TreePriority)
+	*p = x
+	return p
+}
+
+func (x TreePriority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TreePriority) Descriptor() protoreflect.EnumDescriptor {
+	return file_spanner_proto_enumTypes[6].Descriptor()
+}
+
+func (TreePriority) Type() protoreflect.EnumType {
+	return &file_spanner_proto_enumTypes[6]
+}
+
+func (x TreePriority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TreePriority.Descriptor instead.
+func (TreePriority) EnumDescriptor() ([]byte, []int) {
+	return file_spanner_proto_rawDescGZIP(), []int{6}
+}
+
 // LogStorageConfig holds settings which tune the storage implementation for
 // a given log tree.
 type LogStorageConfig struct {
@@ -450,6 +552,32 @@ type TreeInfo struct {
 	Deleted bool `protobuf:"varint,18,opt,name=deleted,proto3" json:"deleted,omitempty"`
 	// Time of tree deletion, if any.
 	DeleteTimeNanos int64 `protobuf:"varint,19,opt,name=delete_time_nanos,json=deleteTimeNanos,proto3" json:"delete_time_nanos,omitempty"`
+	// leaf_dedup_policy defines how leaves submitted to the tree are
+	// deduplicated.
+	LeafDedupPolicy LeafDedupPolicy `protobuf:"varint,20,opt,name=leaf_dedup_policy,json=leafDedupPolicy,proto3,enum=spannerpb.LeafDedupPolicy" json:"leaf_dedup_policy,omitempty"`
+	// sequencer_guard_window_millis is the time after which submitted leaves
+	// are eligible for sequencing. If zero, the signer's guard window is used.
+	SequencerGuardWindowMillis int64 `protobuf:"varint,21,opt,name=sequencer_guard_window_millis,json=sequencerGuardWindowMillis,proto3" json:"sequencer_guard_window_millis,omitempty"`
+	// sequencer_batch_size is the maximum number of leaves sequenced per pass.
+	// If zero, the signer's batch size is used.
+	SequencerBatchSize int32 `protobuf:"varint,22,opt,name=sequencer_batch_size,json=sequencerBatchSize,proto3" json:"sequencer_batch_size,omitempty"`
+	// priority is the priority of the tree in the log signer.
+	Priority TreePriority `protobuf:"varint,23,opt,name=priority,proto3,enum=spannerpb.TreePriority" json:"priority,omitempty"`
+	// signer_affinity are the labels of the log signer instances which may run
+	// the tree.
+	SignerAffinity []string `protobuf:"bytes,24,rep,name=signer_affinity,json=signerAffinity,proto3" json:"signer_affinity,omitempty"`
+	// anti_affinity_group is the anti-affinity group of the tree.
+	AntiAffinityGroup string `protobuf:"bytes,25,opt,name=anti_affinity_group,json=antiAffinityGroup,proto3" json:"anti_affinity_group,omitempty"`
+	// max_leaf_value_size is the maximum size of the leaf values of the tree.
+	MaxLeafValueSize int32 `protobuf:"varint,26,opt,name=max_leaf_value_size,json=maxLeafValueSize,proto3" json:"max_leaf_value_size,omitempty"`
+	// max_extra_data_size is the maximum size of the extra data of the tree.
+	MaxExtraDataSize int32 `protobuf:"varint,27,opt,name=max_extra_data_size,json=maxExtraDataSize,proto3" json:"max_extra_data_size,omitempty"`
+	// max_unsequenced_leaves is the maximum backlog of unsequenced leaves of the
+	// tree.
+	MaxUnsequencedLeaves int32 `protobuf:"varint,28,opt,name=max_unsequenced_leaves,json=maxUnsequencedLeaves,proto3" json:"max_unsequenced_leaves,omitempty"`
+	// compact_retained_revisions is the number of latest revisions of the tree
+	// retained by subtree compaction.
+	CompactRetainedRevisions int32 `protobuf:"varint,29,opt,name=compact_retained_revisions,json=compactRetainedRevisions,proto3" json:"compact_retained_revisions,omitempty"`
 }
 
 func (x *TreeInfo) Reset() {
@@ -617,6 +745,76 @@ func (x *TreeInfo) GetDeleteTimeNanos() int64 {
 	return 0
 }
 
+func (x *TreeInfo) GetLeafDedupPolicy() LeafDedupPolicy {
+	if x != nil {
+		return x.LeafDedupPolicy
+	}
+	return LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH
+}
+
+func (x *TreeInfo) GetSequencerGuardWindowMillis() int64 {
+	if x != nil {
+		return x.SequencerGuardWindowMillis
+	}
+	return 0
+}
+
+func (x *TreeInfo) GetSequencerBatchSize() int32 {
+	if x != nil {
+		return x.SequencerBatchSize
+	}
+	return 0
+}
+
+func (x *TreeInfo) GetPriority() TreePriority {
+	if x != nil {
+		return x.Priority
+	}
+	return TreePriority_NORMAL_PRIORITY
+}
+
+func (x *TreeInfo) GetSignerAffinity() []string {
+	if x != nil {
+		return x.SignerAffinity
+	}
+	return nil
+}
+
+func (x *TreeInfo) GetAntiAffinityGroup() string {
+	if x != nil {
+		return x.AntiAffinityGroup
+	}
+	return ""
+}
+
+func (x *TreeInfo) GetMaxLeafValueSize() int32 {
+	if x != nil {
+		return x.MaxLeafValueSize
+	}
+	return 0
+}
+
+func (x *TreeInfo) GetMaxExtraDataSize() int32 {
+	if x != nil {
+		return x.MaxExtraDataSize
+	}
+	return 0
+}
+
+func (x *TreeInfo) GetMaxUnsequencedLeaves() int32 {
+	if x != nil {
+		return x.MaxUnsequencedLeaves
+	}
+	return 0
+}
+
+func (x *TreeInfo) GetCompactRetainedRevisions() int32 {
+	if x != nil {
+		return x.CompactRetainedRevisions
+	}
+	return 0
+}
+
 type isTreeInfo_StorageConfig interface {
 	isTreeInfo_StorageConfig()
 }
@@ -752,7 +950,7 @@ var file_spanner_proto_rawDesc = []byte{
 	0x6b, 0x6c, 0x65, 0x5f, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28,
 	0x03, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x4d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x42, 0x75, 0x63, 0x6b,
 	0x65, 0x74, 0x73, 0x22, 0x12, 0x0a, 0x10, 0x4d, 0x61, 0x70, 0x53, 0x74, 0x6f, 0x72, 0x61, 0x67,
-	0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0x8c, 0x07, 0x0a, 0x08, 0x54, 0x72, 0x65, 0x65,
+	0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0xa9, 0x0b, 0x0a, 0x08, 0x54, 0x72, 0x65, 0x65,
 	0x49, 0x6e, 0x66, 0x6f, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x12, 0x15, 0x0a,
 	0x06, 0x6b, 0x65, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x6b,
@@ -807,51 +1005,95 @@ var file_spanner_proto_rawDesc = []byte{
 	0x74, 0x65, 0x64, 0x18, 0x12, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74,
 	0x65, 0x64, 0x12, 0x2a, 0x0a, 0x11, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d,
 	0x65, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x13, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x64,
-	0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x42, 0x10,
-	0x0a, 0x0e, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67,
-	0x4a, 0x04, 0x08, 0x0c, 0x10, 0x0d, 0x22, 0xe9, 0x01, 0x0a, 0x08, 0x54, 0x72, 0x65, 0x65, 0x48,
-	0x65, 0x61, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08,
-	0x74, 0x73, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07,
-	0x74, 0x73, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x74, 0x72, 0x65, 0x65, 0x5f,
-	0x73, 0x69, 0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x74, 0x72, 0x65, 0x65,
-	0x53, 0x69, 0x7a, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x6f, 0x6f, 0x74, 0x5f, 0x68, 0x61, 0x73,
-	0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x72, 0x6f, 0x6f, 0x74, 0x48, 0x61, 0x73,
-	0x68, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x0a,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12,
-	0x23, 0x0a, 0x0d, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e,
-	0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x74, 0x72, 0x65, 0x65, 0x52, 0x65, 0x76, 0x69,
-	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
-	0x18, 0x09, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
-	0x4a, 0x04, 0x08, 0x05, 0x10, 0x06, 0x4a, 0x04, 0x08, 0x08, 0x10, 0x09, 0x4a, 0x04, 0x08, 0x07,
-	0x10, 0x08, 0x2a, 0x3b, 0x0a, 0x09, 0x54, 0x72, 0x65, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12,
-	0x16, 0x0a, 0x12, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54, 0x52, 0x45, 0x45, 0x5f,
-	0x53, 0x54, 0x41, 0x54, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x43, 0x54, 0x49, 0x56,
-	0x45, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x46, 0x52, 0x4f, 0x5a, 0x45, 0x4e, 0x10, 0x02, 0x2a,
-	0x3f, 0x0a, 0x08, 0x54, 0x72, 0x65, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55,
-	0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x4c, 0x4f, 0x47, 0x10,
-	0x01, 0x12, 0x12, 0x0a, 0x0e, 0x50, 0x52, 0x45, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x45, 0x44, 0x5f,
-	0x4c, 0x4f, 0x47, 0x10, 0x03, 0x22, 0x04, 0x08, 0x02, 0x10, 0x02, 0x2a, 0x03, 0x4d, 0x41, 0x50,
-	0x2a, 0x91, 0x01, 0x0a, 0x0c, 0x48, 0x61, 0x73, 0x68, 0x53, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67,
-	0x79, 0x12, 0x19, 0x0a, 0x15, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x48, 0x41, 0x53,
-	0x48, 0x5f, 0x53, 0x54, 0x52, 0x41, 0x54, 0x45, 0x47, 0x59, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08,
-	0x52, 0x46, 0x43, 0x5f, 0x36, 0x39, 0x36, 0x32, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x45,
-	0x53, 0x54, 0x5f, 0x4d, 0x41, 0x50, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x45, 0x52, 0x10, 0x02, 0x12,
-	0x19, 0x0a, 0x15, 0x4f, 0x42, 0x4a, 0x45, 0x43, 0x54, 0x5f, 0x52, 0x46, 0x43, 0x36, 0x39, 0x36,
-	0x32, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x03, 0x12, 0x15, 0x0a, 0x11, 0x43, 0x4f,
-	0x4e, 0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41, 0x35, 0x31, 0x32, 0x5f, 0x32, 0x35, 0x36, 0x10,
-	0x04, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x4f, 0x4e, 0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41, 0x32,
-	0x35, 0x36, 0x10, 0x05, 0x2a, 0x25, 0x0a, 0x0d, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f,
-	0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12,
-	0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x04, 0x2a, 0x37, 0x0a, 0x12, 0x53,
-	0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68,
-	0x6d, 0x12, 0x0d, 0x0a, 0x09, 0x41, 0x4e, 0x4f, 0x4e, 0x59, 0x4d, 0x4f, 0x55, 0x53, 0x10, 0x00,
-	0x12, 0x07, 0x0a, 0x03, 0x52, 0x53, 0x41, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x43, 0x44,
-	0x53, 0x41, 0x10, 0x03, 0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69,
-	0x61, 0x6e, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x2f, 0x63, 0x6c, 0x6f, 0x75, 0x64,
-	0x73, 0x70, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2f, 0x73, 0x70, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x70,
-	0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x46,
+	0x0a, 0x11, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x64, 0x65, 0x64, 0x75, 0x70, 0x5f, 0x70, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x73, 0x70, 0x61, 0x6e,
+	0x6e, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x4c, 0x65, 0x61, 0x66, 0x44, 0x65, 0x64, 0x75, 0x70, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0f, 0x6c, 0x65, 0x61, 0x66, 0x44, 0x65, 0x64, 0x75, 0x70,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x41, 0x0a, 0x1d, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x72, 0x5f, 0x67, 0x75, 0x61, 0x72, 0x64, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x5f, 0x6d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x18, 0x15, 0x20, 0x01, 0x28, 0x03, 0x52, 0x1a, 0x73,
+	0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x72, 0x47, 0x75, 0x61, 0x72, 0x64, 0x57, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x12, 0x30, 0x0a, 0x14, 0x73, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x72, 0x5f, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69, 0x7a,
+	0x65, 0x18, 0x16, 0x20, 0x01, 0x28, 0x05, 0x52, 0x12, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63,
+	0x65, 0x72, 0x42, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x33, 0x0a, 0x08, 0x70,
+	0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x17, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e,
+	0x73, 0x70, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x70, 0x62, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x50, 0x72,
+	0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79,
+	0x12, 0x27, 0x0a, 0x0f, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x5f, 0x61, 0x66, 0x66, 0x69, 0x6e,
+	0x69, 0x74, 0x79, 0x18, 0x18, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0e, 0x73, 0x69, 0x67, 0x6e, 0x65,
+	0x72, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x12, 0x2e, 0x0a, 0x13, 0x61, 0x6e, 0x74,
+	0x69, 0x5f, 0x61, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70,
+	0x18, 0x19, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11, 0x61, 0x6e, 0x74, 0x69, 0x41, 0x66, 0x66, 0x69,
+	0x6e, 0x69, 0x74, 0x79, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x2d, 0x0a, 0x13, 0x6d, 0x61, 0x78,
+	0x5f, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65,
+	0x18, 0x1a, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x6d, 0x61, 0x78, 0x4c, 0x65, 0x61, 0x66, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x2d, 0x0a, 0x13, 0x6d, 0x61, 0x78, 0x5f,
+	0x65, 0x78, 0x74, 0x72, 0x61, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18,
+	0x1b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x6d, 0x61, 0x78, 0x45, 0x78, 0x74, 0x72, 0x61, 0x44,
+	0x61, 0x74, 0x61, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x34, 0x0a, 0x16, 0x6d, 0x61, 0x78, 0x5f, 0x75,
+	0x6e, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x64, 0x5f, 0x6c, 0x65, 0x61, 0x76, 0x65,
+	0x73, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x14, 0x6d, 0x61, 0x78, 0x55, 0x6e, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x64, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x73, 0x12, 0x3c, 0x0a,
+	0x1a, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x5f, 0x72, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65,
+	0x64, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x1d, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x18, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x52, 0x65, 0x74, 0x61, 0x69, 0x6e,
+	0x65, 0x64, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x42, 0x10, 0x0a, 0x0e, 0x73,
+	0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x4a, 0x04, 0x08,
+	0x0c, 0x10, 0x0d, 0x22, 0xe9, 0x01, 0x0a, 0x08, 0x54, 0x72, 0x65, 0x65, 0x48, 0x65, 0x61, 0x64,
+	0x12, 0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x74, 0x73, 0x5f,
+	0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x74, 0x73, 0x4e,
+	0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x73, 0x69, 0x7a,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x74, 0x72, 0x65, 0x65, 0x53, 0x69, 0x7a,
+	0x65, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x6f, 0x6f, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x72, 0x6f, 0x6f, 0x74, 0x48, 0x61, 0x73, 0x68, 0x12, 0x1c,
+	0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x23, 0x0a, 0x0d,
+	0x74, 0x72, 0x65, 0x65, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0c, 0x74, 0x72, 0x65, 0x65, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x4a, 0x04, 0x08,
+	0x05, 0x10, 0x06, 0x4a, 0x04, 0x08, 0x08, 0x10, 0x09, 0x4a, 0x04, 0x08, 0x07, 0x10, 0x08, 0x2a,
+	0x3b, 0x0a, 0x09, 0x54, 0x72, 0x65, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x12,
+	0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54, 0x52, 0x45, 0x45, 0x5f, 0x53, 0x54, 0x41,
+	0x54, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x43, 0x54, 0x49, 0x56, 0x45, 0x10, 0x01,
+	0x12, 0x0a, 0x0a, 0x06, 0x46, 0x52, 0x4f, 0x5a, 0x45, 0x4e, 0x10, 0x02, 0x2a, 0x3f, 0x0a, 0x08,
+	0x54, 0x72, 0x65, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e,
+	0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x4c, 0x4f, 0x47, 0x10, 0x01, 0x12, 0x12,
+	0x0a, 0x0e, 0x50, 0x52, 0x45, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x45, 0x44, 0x5f, 0x4c, 0x4f, 0x47,
+	0x10, 0x03, 0x22, 0x04, 0x08, 0x02, 0x10, 0x02, 0x2a, 0x03, 0x4d, 0x41, 0x50, 0x2a, 0x91, 0x01,
+	0x0a, 0x0c, 0x48, 0x61, 0x73, 0x68, 0x53, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x12, 0x19,
+	0x0a, 0x15, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x5f, 0x53,
+	0x54, 0x52, 0x41, 0x54, 0x45, 0x47, 0x59, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x46, 0x43,
+	0x5f, 0x36, 0x39, 0x36, 0x32, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x45, 0x53, 0x54, 0x5f,
+	0x4d, 0x41, 0x50, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x45, 0x52, 0x10, 0x02, 0x12, 0x19, 0x0a, 0x15,
+	0x4f, 0x42, 0x4a, 0x45, 0x43, 0x54, 0x5f, 0x52, 0x46, 0x43, 0x36, 0x39, 0x36, 0x32, 0x5f, 0x53,
+	0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x03, 0x12, 0x15, 0x0a, 0x11, 0x43, 0x4f, 0x4e, 0x49, 0x4b,
+	0x53, 0x5f, 0x53, 0x48, 0x41, 0x35, 0x31, 0x32, 0x5f, 0x32, 0x35, 0x36, 0x10, 0x04, 0x12, 0x11,
+	0x0a, 0x0d, 0x43, 0x4f, 0x4e, 0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10,
+	0x05, 0x2a, 0x25, 0x0a, 0x0d, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74,
+	0x68, 0x6d, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06,
+	0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x04, 0x2a, 0x37, 0x0a, 0x12, 0x53, 0x69, 0x67, 0x6e,
+	0x61, 0x74, 0x75, 0x72, 0x65, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x0d,
+	0x0a, 0x09, 0x41, 0x4e, 0x4f, 0x4e, 0x59, 0x4d, 0x4f, 0x55, 0x53, 0x10, 0x00, 0x12, 0x07, 0x0a,
+	0x03, 0x52, 0x53, 0x41, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x43, 0x44, 0x53, 0x41, 0x10,
+	0x03, 0x2a, 0x59, 0x0a, 0x0f, 0x4c, 0x65, 0x61, 0x66, 0x44, 0x65, 0x64, 0x75, 0x70, 0x50, 0x6f,
+	0x6c, 0x69, 0x63, 0x79, 0x12, 0x1a, 0x0a, 0x16, 0x44, 0x45, 0x44, 0x55, 0x50, 0x5f, 0x42, 0x59,
+	0x5f, 0x49, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x54, 0x59, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x10, 0x00,
+	0x12, 0x1c, 0x0a, 0x18, 0x44, 0x45, 0x44, 0x55, 0x50, 0x5f, 0x42, 0x59, 0x5f, 0x4c, 0x45, 0x41,
+	0x46, 0x5f, 0x56, 0x41, 0x4c, 0x55, 0x45, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x10, 0x01, 0x12, 0x0c,
+	0x0a, 0x08, 0x4e, 0x4f, 0x5f, 0x44, 0x45, 0x44, 0x55, 0x50, 0x10, 0x02, 0x2a, 0x48, 0x0a, 0x0c,
+	0x54, 0x72, 0x65, 0x65, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x13, 0x0a, 0x0f,
+	0x4e, 0x4f, 0x52, 0x4d, 0x41, 0x4c, 0x5f, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49, 0x54, 0x59, 0x10,
+	0x00, 0x12, 0x11, 0x0a, 0x0d, 0x48, 0x49, 0x47, 0x48, 0x5f, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49,
+	0x54, 0x59, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x4c, 0x4f, 0x57, 0x5f, 0x50, 0x52, 0x49, 0x4f,
+	0x52, 0x49, 0x54, 0x59, 0x10, 0x02, 0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69, 0x6c,
+	0x6c, 0x69, 0x61, 0x6e, 0x2f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x2f, 0x63, 0x6c, 0x6f,
+	0x75, 0x64, 0x73, 0x70, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2f, 0x73, 0x70, 0x61, 0x6e, 0x6e, 0x65,
+	0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -866,7 +1108,7 @@ func file_spanner_proto_rawDescGZIP() []byte {
 	return file_spanner_proto_rawDescData
 }
 
-var file_spanner_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_spanner_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
 var file_spanner_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_spanner_proto_goTypes = []interface{}{
 	(TreeState)(0),           // 0: spannerpb.TreeState
@@ -874,26 +1116,30 @@ var file_spanner_proto_goTypes = []interface{}{
 	(HashStrategy)(0),        // 2: spannerpb.HashStrategy
 	(HashAlgorithm)(0),       // 3: spannerpb.HashAlgorithm
 	(SignatureAlgorithm)(0),  // 4: spannerpb.SignatureAlgorithm
-	(*LogStorageConfig)(nil), // 5: spannerpb.LogStorageConfig
-	(*MapStorageConfig)(nil), // 6: spannerpb.MapStorageConfig
-	(*TreeInfo)(nil),         // 7: spannerpb.TreeInfo
-	(*TreeHead)(nil),         // 8: spannerpb.TreeHead
-	(*anypb.Any)(nil),        // 9: google.protobuf.Any
+	(LeafDedupPolicy)(0),     // 5: spannerpb.LeafDedupPolicy
+	(TreePriority)(0),        // 6: spannerpb.TreePriority
+	(*LogStorageConfig)(nil), // 7: spannerpb.LogStorageConfig
+	(*MapStorageConfig)(nil), // 8: spannerpb.MapStorageConfig
+	(*TreeInfo)(nil),         // 9: spannerpb.TreeInfo
+	(*TreeHead)(nil),         // 10: spannerpb.TreeHead
+	(*anypb.Any)(nil),        // 11: google.protobuf.Any
 }
 var file_spanner_proto_depIdxs = []int32{
-	1, // 0: spannerpb.TreeInfo.tree_type:type_name -> spannerpb.TreeType
-	0, // 1: spannerpb.TreeInfo.tree_state:type_name -> spannerpb.TreeState
-	2, // 2: spannerpb.TreeInfo.hash_strategy:type_name -> spannerpb.HashStrategy
-	3, // 3: spannerpb.TreeInfo.hash_algorithm:type_name -> spannerpb.HashAlgorithm
-	4, // 4: spannerpb.TreeInfo.signature_algorithm:type_name -> spannerpb.SignatureAlgorithm
-	9, // 5: spannerpb.TreeInfo.private_key:type_name -> google.protobuf.Any
-	5, // 6: spannerpb.TreeInfo.log_storage_config:type_name -> spannerpb.LogStorageConfig
-	6, // 7: spannerpb.TreeInfo.map_storage_config:type_name -> spannerpb.MapStorageConfig
-	8, // [8:8] is the sub-list for method output_type
-	8, // [8:8] is the sub-list for method input_type
-	8, // [8:8] is the sub-list for extension type_name
-	8, // [8:8] is the sub-list for extension extendee
-	0, // [0:8] is the sub-list for field type_name
+	1,  // 0: spannerpb.TreeInfo.tree_type:type_name -> spannerpb.TreeType
+	0,  // 1: spannerpb.TreeInfo.tree_state:type_name -> spannerpb.TreeState
+	2,  // 2: spannerpb.TreeInfo.hash_strategy:type_name -> spannerpb.HashStrategy
+	3,  // 3: spannerpb.TreeInfo.hash_algorithm:type_name -> spannerpb.HashAlgorithm
+	4,  // 4: spannerpb.TreeInfo.signature_algorithm:type_name -> spannerpb.SignatureAlgorithm
+	11, // 5: spannerpb.TreeInfo.private_key:type_name -> google.protobuf.Any
+	7,  // 6: spannerpb.TreeInfo.log_storage_config:type_name -> spannerpb.LogStorageConfig
+	8,  // 7: spannerpb.TreeInfo.map_storage_config:type_name -> spannerpb.MapStorageConfig
+	5,  // 8: spannerpb.TreeInfo.leaf_dedup_policy:type_name -> spannerpb.LeafDedupPolicy
+	6,  // 9: spannerpb.TreeInfo.priority:type_name -> spannerpb.TreePriority
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_spanner_proto_init() }
@@ -960,7 +1206,7 @@ func file_spanner_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_spanner_proto_rawDesc,
-			NumEnums:      5,
+			NumEnums:      7,
 			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   0,
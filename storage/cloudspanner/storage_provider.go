@@ -21,6 +21,7 @@ import (
 	"encoding/base64"
 	"flag"
 	"io/ioutil"
+	"os"
 	"sync"
 	"time"
 
@@ -28,7 +29,9 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
 	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 )
 
 var (
@@ -43,6 +46,10 @@ var (
 	csSessionTrackHandles                = flag.Bool("cloudspanner_track_session_handles", false, "determines whether the session pool will keep track of the stacktrace of the goroutines that take sessions from the pool.")
 	csDequeueAcrossMerkleBucketsFraction = flag.Float64("cloudspanner_dequeue_bucket_fraction", 0.75, "Fraction of merkle keyspace to dequeue from, set to zero to disable.")
 	csReadOnlyStaleness                  = flag.Duration("cloudspanner_readonly_staleness", time.Minute, "How far in the past to perform readonly operations. Within limits, raising this should help to increase performance/reduce latency.")
+	csDialect                            = flag.String("cloudspanner_dialect", "googlesql", "SQL dialect of the CloudSpanner database, googlesql or postgresql. PostgreSQL-dialect databases must be created with spanner_pg.sdl.")
+	csReadPriority                       = flag.String("cloudspanner_read_priority", "", "Priority of CloudSpanner requests made outside of read-write transactions: low, medium or high. Readonly operations with a staleness are served by the nearest replicas.")
+	csWritePriority                      = flag.String("cloudspanner_write_priority", "", "Priority of CloudSpanner requests made in read-write transactions, and of commits: low, medium or high.")
+	csRequestTag                         = flag.String("cloudspanner_request_tag", "", "Tag of all CloudSpanner requests and transactions, shown in the query and transaction statistics.")
 	_                                    = flag.Uint64("cloudspanner_max_burst_sessions", 0, "No longer used")
 
 	csMu              sync.RWMutex
@@ -51,9 +58,7 @@ var (
 )
 
 func init() {
-	if err := storage.RegisterProvider("cloud_spanner", newCloudSpannerStorageProvider); err != nil {
-		panic(err)
-	}
+	storage.MustRegisterProvider("cloud_spanner", newCloudSpannerStorageProvider)
 }
 
 func warn() {
@@ -73,7 +78,12 @@ func warn() {
 }
 
 type cloudSpannerProvider struct {
-	client *spanner.Client
+	client  *spanner.Client
+	dialect Dialect
+	// readPriority and writePriority are the priorities of the requests made
+	// by the log storage.
+	readPriority  sppb.RequestOptions_Priority
+	writePriority sppb.RequestOptions_Priority
 }
 
 func configFromFlags() spanner.ClientConfig {
@@ -89,35 +99,73 @@ func configFromFlags() spanner.ClientConfig {
 }
 
 func optionsFromFlags() []option.ClientOption {
-	opts := []option.ClientOption{}
+	opts := emulatorOptionsFromFlags()
 	if numConns := *csNumChannels; numConns != 0 {
 		opts = append(opts, option.WithGRPCConnectionPool(numConns))
 	}
 	return opts
 }
 
-func newCloudSpannerStorageProvider(_ monitoring.MetricFactory) (storage.Provider, error) {
+func newCloudSpannerStorageProvider(mf monitoring.MetricFactory) (storage.Provider, error) {
 	csMu.Lock()
 	defer csMu.Unlock()
 
 	if csStorageInstance != nil {
 		return csStorageInstance, nil
 	}
+	cache.InitMetrics(mf)
 
-	client, err := spanner.NewClientWithConfig(context.TODO(), *csURI, configFromFlags(), optionsFromFlags()...)
+	dialect, err := ParseDialect(*csDialect)
+	if err != nil {
+		return nil, err
+	}
+	readPriority, err := ParsePriority(*csReadPriority)
+	if err != nil {
+		return nil, err
+	}
+	writePriority, err := ParsePriority(*csWritePriority)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.TODO()
+	if *csCreateDatabase {
+		if err := createDatabaseFromFlags(ctx, dialect); err != nil {
+			return nil, err
+		}
+	}
+	client, err := spanner.NewClientWithConfig(ctx, *csURI, configFromFlags(), optionsFromFlags()...)
 	if err != nil {
 		return nil, err
 	}
 	csStorageInstance = &cloudSpannerProvider{
-		client: client,
+		client:        client,
+		dialect:       dialect,
+		readPriority:  readPriority,
+		writePriority: writePriority,
 	}
 	return csStorageInstance, nil
 }
 
+// createDatabaseFromFlags creates the --cloudspanner_uri database if it
+// doesn't exist, and its instance if using the emulator.
+func createDatabaseFromFlags(ctx context.Context, dialect Dialect) error {
+	opts := emulatorOptionsFromFlags()
+	if *csEmulatorHost != "" || os.Getenv("SPANNER_EMULATOR_HOST") != "" {
+		if err := CreateEmulatorInstance(ctx, *csURI, opts...); err != nil {
+			return err
+		}
+	}
+	return CreateDatabase(ctx, *csURI, dialect, opts...)
+}
+
 // LogStorage builds and returns a new storage.LogStorage using CloudSpanner.
 func (s *cloudSpannerProvider) LogStorage() storage.LogStorage {
 	warn()
 	opts := LogStorageOptions{}
+	opts.Dialect = s.dialect
+	opts.ReadPriority = s.readPriority
+	opts.WritePriority = s.writePriority
+	opts.RequestTag = *csRequestTag
 	frac := *csDequeueAcrossMerkleBucketsFraction
 	if frac > 1.0 {
 		frac = 1.0
@@ -135,7 +183,7 @@ func (s *cloudSpannerProvider) LogStorage() storage.LogStorage {
 // AdminStorage builds and returns a new storage.AdminStorage using CloudSpanner.
 func (s *cloudSpannerProvider) AdminStorage() storage.AdminStorage {
 	warn()
-	return NewAdminStorage(s.client)
+	return NewAdminStorageWithOpts(s.client, AdminStorageOptions{Dialect: s.dialect})
 }
 
 // Close shuts down this provider. Calls to the other methods will fail
@@ -32,6 +32,7 @@ import (
 	"github.com/google/trillian/storage/tree"
 	"github.com/transparency-dev/merkle/compact"
 	"golang.org/x/sync/errgroup"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -64,6 +65,48 @@ const (
 	colRevision  = "Revision"
 )
 
+var (
+	latestSTHSQL = query{
+		googleSQL: "SELECT TreeID, TimestampNanos, TreeSize, RootHash, RootSignature, TreeRevision, TreeMetadata FROM TreeHeads" +
+			"   WHERE TreeID = @tree_id" +
+			"   ORDER BY TreeRevision DESC " +
+			"   LIMIT 1",
+		postgreSQL: `SELECT "TreeID", "TimestampNanos", "TreeSize", "RootHash", "RootSignature", "TreeRevision", "TreeMetadata" FROM "TreeHeads"` +
+			`   WHERE "TreeID" = $1` +
+			`   ORDER BY "TreeRevision" DESC ` +
+			"   LIMIT 1",
+		params: []string{"tree_id"},
+	}
+	sthAtTimeSQL = query{
+		googleSQL: "SELECT TreeID, TimestampNanos, TreeSize, RootHash, RootSignature, TreeRevision, TreeMetadata FROM TreeHeads" +
+			"   WHERE TreeID = @tree_id" +
+			"   AND   TimestampNanos <= @ts_nanos" +
+			"   ORDER BY TimestampNanos DESC " +
+			"   LIMIT 1",
+		postgreSQL: `SELECT "TreeID", "TimestampNanos", "TreeSize", "RootHash", "RootSignature", "TreeRevision", "TreeMetadata" FROM "TreeHeads"` +
+			`   WHERE "TreeID" = $1` +
+			`   AND   "TimestampNanos" <= $2` +
+			`   ORDER BY "TimestampNanos" DESC ` +
+			"   LIMIT 1",
+		params: []string{"tree_id", "ts_nanos"},
+	}
+	getSubtreeSQL = query{
+		googleSQL: "SELECT Revision, Subtree FROM SubtreeData" +
+			"  WHERE TreeID = @tree_id" +
+			"  AND   SubtreeID = @subtree_id" +
+			"  AND   Revision <= @revision" +
+			"  ORDER BY Revision DESC" +
+			"  LIMIT 1",
+		postgreSQL: `SELECT "Revision", "Subtree" FROM "SubtreeData"` +
+			`  WHERE "TreeID" = $1` +
+			`  AND   "SubtreeID" = $2` +
+			`  AND   "Revision" <= $3` +
+			`  ORDER BY "Revision" DESC` +
+			"  LIMIT 1",
+		params: []string{"tree_id", "subtree_id", "revision"},
+	}
+)
+
 // treeStorage provides a shared base for the concrete CloudSpanner-backed
 // implementation of the Trillian storage.LogStorage and storage.MapStorage
 // interfaces.
@@ -81,6 +124,18 @@ type TreeStorageOptions struct {
 	// to help with performance.
 	// See https://cloud.google.com/spanner/docs/timestamp-bounds for more details.
 	ReadOnlyStaleness time.Duration
+	// Dialect is the SQL dialect of the database, GoogleSQL by default.
+	Dialect Dialect
+	// ReadPriority is the priority of the requests made outside of read-write
+	// transactions, which can be lowered so that they don't delay sequencing.
+	// The default, unspecified, priority is high.
+	ReadPriority sppb.RequestOptions_Priority
+	// WritePriority is the priority of the requests made in read-write
+	// transactions and of the commits of writes.
+	WritePriority sppb.RequestOptions_Priority
+	// RequestTag, if set, tags all requests and transactions, so that they can
+	// be told apart in Spanner's query and transaction statistics.
+	RequestTag string
 }
 
 func newTreeStorageWithOpts(client *spanner.Client, opts TreeStorageOptions) *treeStorage {
@@ -93,19 +148,61 @@ type spanRead interface {
 	ReadUsingIndex(ctx context.Context, table, index string, keys spanner.KeySet, columns []string) *spanner.RowIterator
 	ReadRow(ctx context.Context, table string, key spanner.Key, columns []string) (*spanner.Row, error)
 	ReadWithOptions(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts *spanner.ReadOptions) (ri *spanner.RowIterator)
+	QueryWithOptions(ctx context.Context, statement spanner.Statement, opts spanner.QueryOptions) *spanner.RowIterator
+}
+
+// priority returns the priority of requests made in stx.
+func (t *treeStorage) priority(stx spanRead) sppb.RequestOptions_Priority {
+	if _, ok := stx.(*spanner.ReadWriteTransaction); ok {
+		return t.opts.WritePriority
+	}
+	return t.opts.ReadPriority
+}
+
+// query runs stmt in stx with the configured priority and request tag.
+func (t *treeStorage) query(ctx context.Context, stx spanRead, stmt spanner.Statement) *spanner.RowIterator {
+	return stx.QueryWithOptions(ctx, stmt, spanner.QueryOptions{Priority: t.priority(stx), RequestTag: t.opts.RequestTag})
+}
+
+// read reads the rows of table with the given keys in stx, through index
+// unless it's empty, with the configured priority and request tag.
+func (t *treeStorage) read(ctx context.Context, stx spanRead, table, index string, keys spanner.KeySet, columns []string) *spanner.RowIterator {
+	return stx.ReadWithOptions(ctx, table, keys, columns, &spanner.ReadOptions{Index: index, Priority: t.priority(stx), RequestTag: t.opts.RequestTag})
+}
+
+// transactionOptions returns the options of the read-write transactions of t.
+func (t *treeStorage) transactionOptions() spanner.TransactionOptions {
+	return spanner.TransactionOptions{CommitPriority: t.opts.WritePriority, TransactionTag: t.opts.RequestTag}
+}
+
+// applyOptions returns the options of the writes of t made with Apply.
+func (t *treeStorage) applyOptions() []spanner.ApplyOption {
+	return []spanner.ApplyOption{spanner.Priority(t.opts.WritePriority), spanner.TransactionTag(t.opts.RequestTag)}
 }
 
 // latestSTH reads and returns the newest STH.
 func (t *treeStorage) latestSTH(ctx context.Context, stx spanRead, treeID int64) (*spannerpb.TreeHead, error) {
-	query := spanner.NewStatement(
-		"SELECT TreeID, TimestampNanos, TreeSize, RootHash, RootSignature, TreeRevision, TreeMetadata FROM TreeHeads" +
-			"   WHERE TreeID = @tree_id" +
-			"   ORDER BY TreeRevision DESC " +
-			"   LIMIT 1")
-	query.Params["tree_id"] = treeID
+	th, err := t.readSTH(ctx, stx, latestSTHSQL.statement(t.opts.Dialect, treeID))
+	if err != nil {
+		return nil, err
+	}
+	if th == nil {
+		glog.Warningf("no head found for treeID %v", treeID)
+		return nil, storage.ErrTreeNeedsInit
+	}
+	return th, nil
+}
+
+// sthAtTime reads and returns the newest STH whose timestamp is at or before
+// tsNanos, or nil if there is no such STH.
+func (t *treeStorage) sthAtTime(ctx context.Context, stx spanRead, treeID, tsNanos int64) (*spannerpb.TreeHead, error) {
+	return t.readSTH(ctx, stx, sthAtTimeSQL.statement(t.opts.Dialect, treeID, tsNanos))
+}
 
+// readSTH returns the STH read by query, or nil if it returns no rows.
+func (t *treeStorage) readSTH(ctx context.Context, stx spanRead, query spanner.Statement) (*spannerpb.TreeHead, error) {
 	var th *spannerpb.TreeHead
-	rows := stx.Query(ctx, query)
+	rows := t.query(ctx, stx, query)
 	defer rows.Stop()
 	err := rows.Do(func(r *spanner.Row) error {
 		tth := &spannerpb.TreeHead{}
@@ -119,10 +216,6 @@ func (t *treeStorage) latestSTH(ctx context.Context, stx spanRead, treeID int64)
 	if err != nil {
 		return nil, err
 	}
-	if th == nil {
-		glog.Warningf("no head found for treeID %v", treeID)
-		return nil, storage.ErrTreeNeedsInit
-	}
 	return th, nil
 }
 
@@ -310,18 +403,9 @@ func (t *treeTX) readRevision(ctx context.Context) (int64, error) {
 // If no such subtree exists it returns nil.
 func (t *treeTX) getSubtree(ctx context.Context, rev int64, id []byte) (p *storagepb.SubtreeProto, e error) {
 	var ret *storagepb.SubtreeProto
-	stmt := spanner.NewStatement(
-		"SELECT Revision, Subtree FROM SubtreeData" +
-			"  WHERE TreeID = @tree_id" +
-			"  AND   SubtreeID = @subtree_id" +
-			"  AND   Revision <= @revision" +
-			"  ORDER BY Revision DESC" +
-			"  LIMIT 1")
-	stmt.Params["tree_id"] = t.treeID
-	stmt.Params["subtree_id"] = id
-	stmt.Params["revision"] = rev
+	stmt := getSubtreeSQL.statement(t.ts.opts.Dialect, t.treeID, id, rev)
 
-	rows := t.stx.Query(ctx, stmt)
+	rows := t.ts.query(ctx, t.stx, stmt)
 	err := rows.Do(func(r *spanner.Row) error {
 		if ret != nil {
 			return nil
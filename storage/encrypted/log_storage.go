@@ -0,0 +1,383 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encrypted provides a storage.LogStorage which encrypts the LeafValue
+// and ExtraData of leaves before they reach the underlying storage, and
+// decrypts them when they're read back.
+//
+// Merkle leaf hashes are computed over the plaintext before leaves reach
+// storage, so encryption doesn't change the tree. Leaves written before
+// encryption was enabled are still read as they were written.
+package encrypted
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/envelope"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	keyURI          = flag.String("leaf_encryption_key_uri", "", "If set, the URI of the key encrypting leaf values and extra data at rest, in which {tree_id} is replaced with the tree ID to use per-tree keys, e.g. gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/tree-{tree_id}, awskms://alias/trillian or local:///etc/trillian/keyring")
+	previousKeyURIs = flag.String("leaf_encryption_previous_key_uris", "", "Comma-separated URIs of keys which --leaf_encryption_key_uri replaced, still needed to decrypt the leaves they protect")
+	dekLifetime     = flag.Duration("leaf_encryption_dek_lifetime", envelope.DefaultDEKLifetime, "Time after which the data encryption key of each tree is rotated")
+)
+
+// Fields of leaves, which are bound to their ciphertexts.
+const (
+	leafValueField = 0
+	extraDataField = 1
+)
+
+// NewLogStorageFromFlags returns ls wrapped to encrypt leaf data with the keys
+// set by the --leaf_encryption flags, or ls itself if
+// --leaf_encryption_key_uri is unset. The returned function releases the KMSs
+// used by the returned storage.
+func NewLogStorageFromFlags(ls storage.LogStorage) (storage.LogStorage, func() error, error) {
+	if *keyURI == "" {
+		return ls, func() error { return nil }, nil
+	}
+	opts := envelope.Options{KeyURI: *keyURI, DEKLifetime: *dekLifetime}
+	if *previousKeyURIs != "" {
+		opts.PreviousKeyURIs = strings.Split(*previousKeyURIs, ",")
+	}
+	enc, err := envelope.NewEncrypter(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewLogStorage(ls, enc), enc.Close, nil
+}
+
+// NewLogStorage returns ls wrapped to encrypt leaf data with enc. The returned
+// storage implements storage.UnsequencedCounter, storage.UnsequencedAger and
+// storage.SubtreeCompactor, whose methods return an Unimplemented error if ls
+// doesn't implement them, and storage.AsyncLeafQueuer, which uses QueueLeaves
+// if ls doesn't implement it. Subtrees hold no leaf data, so compaction is
+// forwarded to ls as is.
+func NewLogStorage(ls storage.LogStorage, enc *envelope.Encrypter) storage.LogStorage {
+	return &logStorage{LogStorage: ls, enc: enc}
+}
+
+type logStorage struct {
+	storage.LogStorage
+	enc *envelope.Encrypter
+}
+
+func (s *logStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
+	tx, err := s.LogStorage.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyLogTX{ReadOnlyLogTreeTX: tx, c: &crypter{enc: s.enc, treeID: tree.TreeId}}, nil
+}
+
+func (s *logStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.LogTXFunc) error {
+	c := &crypter{enc: s.enc, treeID: tree.TreeId}
+	return s.LogStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return f(ctx, &logTX{LogTreeTX: tx, c: c})
+	})
+}
+
+func (s *logStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	c := &crypter{enc: s.enc, treeID: tree.TreeId}
+	encrypted, err := c.encryptLeaves(ctx, leaves)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.LogStorage.QueueLeaves(ctx, tree, encrypted, queueTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	return c.decryptQueued(ctx, res, leaves, encrypted)
+}
+
+// QueueLeavesAsync implements storage.AsyncLeafQueuer.
+func (s *logStorage) QueueLeavesAsync(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	c := &crypter{enc: s.enc, treeID: tree.TreeId}
+	encrypted, err := c.encryptLeaves(ctx, leaves)
+	if err != nil {
+		return err
+	}
+	if q, ok := s.LogStorage.(storage.AsyncLeafQueuer); ok {
+		return q.QueueLeavesAsync(ctx, tree, encrypted, queueTimestamp)
+	}
+	_, err = s.LogStorage.QueueLeaves(ctx, tree, encrypted, queueTimestamp)
+	return err
+}
+
+// CountUnsequenced implements storage.UnsequencedCounter, if the wrapped
+// storage does.
+func (s *logStorage) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	c, ok := s.LogStorage.(storage.UnsequencedCounter)
+	if !ok {
+		return 0, status.Error(codes.Unimplemented, "counting unsequenced leaves is not supported by the storage")
+	}
+	return c.CountUnsequenced(ctx, tree)
+}
+
+// OldestUnsequenced implements storage.UnsequencedAger, if the wrapped storage
+// does.
+func (s *logStorage) OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error) {
+	a, ok := s.LogStorage.(storage.UnsequencedAger)
+	if !ok {
+		return time.Time{}, status.Error(codes.Unimplemented, "reading the oldest unsequenced leaf is not supported by the storage")
+	}
+	return a.OldestUnsequenced(ctx, tree)
+}
+
+// CompactSubtrees implements storage.SubtreeCompactor, if the wrapped storage
+// does.
+func (s *logStorage) CompactSubtrees(ctx context.Context, tree *trillian.Tree, retained int64) (int64, error) {
+	c, ok := s.LogStorage.(storage.SubtreeCompactor)
+	if !ok {
+		return 0, status.Error(codes.Unimplemented, "subtree compaction is not supported by the storage")
+	}
+	return c.CompactSubtrees(ctx, tree, retained)
+}
+
+func (s *logStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	c := &crypter{enc: s.enc, treeID: tree.TreeId}
+	encrypted, err := c.encryptLeaves(ctx, leaves)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.LogStorage.AddSequencedLeaves(ctx, tree, encrypted, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return c.decryptQueued(ctx, res, leaves, encrypted)
+}
+
+type readOnlyLogTX struct {
+	storage.ReadOnlyLogTreeTX
+	c *crypter
+}
+
+func (t *readOnlyLogTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.ReadOnlyLogTreeTX.GetLeavesByRange(ctx, start, count)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+func (t *readOnlyLogTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.ReadOnlyLogTreeTX.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// GetLeavesByIndex implements storage.SparseLeafReader, using the reader of the
+// wrapped transaction if it has one.
+func (t *readOnlyLogTX) GetLeavesByIndex(ctx context.Context, indices []int64) ([]*trillian.LogLeaf, error) {
+	leaves, err := storage.GetLeavesByIndex(ctx, t.ReadOnlyLogTreeTX, indices)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// GetLeavesByHashPage implements storage.LeafByHashPager, using the pager of
+// the wrapped transaction if it has one.
+func (t *readOnlyLogTX) GetLeavesByHashPage(ctx context.Context, leafHashes [][]byte, start, end int64, limit int) ([]*trillian.LogLeaf, error) {
+	leaves, err := storage.GetLeavesByHashPage(ctx, t.ReadOnlyLogTreeTX, leafHashes, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// SignedLogRootAt implements storage.RootAtTimeReader, if the wrapped
+// transaction does.
+func (t *readOnlyLogTX) SignedLogRootAt(ctx context.Context, ts time.Time) (*trillian.SignedLogRoot, error) {
+	return storage.SignedLogRootAt(ctx, t.ReadOnlyLogTreeTX, ts)
+}
+
+type logTX struct {
+	storage.LogTreeTX
+	c *crypter
+}
+
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.LogTreeTX.GetLeavesByRange(ctx, start, count)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.LogTreeTX.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// GetLeavesByIndex implements storage.SparseLeafReader, using the reader of the
+// wrapped transaction if it has one.
+func (t *logTX) GetLeavesByIndex(ctx context.Context, indices []int64) ([]*trillian.LogLeaf, error) {
+	leaves, err := storage.GetLeavesByIndex(ctx, t.LogTreeTX, indices)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// GetLeavesByHashPage implements storage.LeafByHashPager, using the pager of
+// the wrapped transaction if it has one.
+func (t *logTX) GetLeavesByHashPage(ctx context.Context, leafHashes [][]byte, start, end int64, limit int) ([]*trillian.LogLeaf, error) {
+	leaves, err := storage.GetLeavesByHashPage(ctx, t.LogTreeTX, leafHashes, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// SignedLogRootAt implements storage.RootAtTimeReader, if the wrapped
+// transaction does.
+func (t *logTX) SignedLogRootAt(ctx context.Context, ts time.Time) (*trillian.SignedLogRoot, error) {
+	return storage.SignedLogRootAt(ctx, t.LogTreeTX, ts)
+}
+
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, cutoff time.Time) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.LogTreeTX.DequeueLeaves(ctx, limit, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	return t.c.decryptLeaves(ctx, leaves)
+}
+
+// crypter encrypts and decrypts the leaves of a tree. Each ciphertext is bound
+// to the tree, the LeafIdentityHash of its leaf and the field it's stored in,
+// so that it can't be substituted for another one.
+type crypter struct {
+	enc    *envelope.Encrypter
+	treeID int64
+}
+
+func (c *crypter) additionalData(leaf *trillian.LogLeaf, field byte) []byte {
+	ad := make([]byte, 8, 8+len(leaf.LeafIdentityHash)+1)
+	binary.BigEndian.PutUint64(ad, uint64(c.treeID))
+	ad = append(ad, leaf.LeafIdentityHash...)
+	return append(ad, field)
+}
+
+// encryptLeaves returns copies of the leaves with their LeafValue and
+// ExtraData encrypted. Empty values are left unencrypted.
+func (c *crypter) encryptLeaves(ctx context.Context, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	res := make([]*trillian.LogLeaf, len(leaves))
+	for i, leaf := range leaves {
+		value, err := c.encrypt(ctx, leaf, leaf.LeafValue, leafValueField)
+		if err != nil {
+			return nil, err
+		}
+		extra, err := c.encrypt(ctx, leaf, leaf.ExtraData, extraDataField)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = withData(leaf, value, extra)
+	}
+	return res, nil
+}
+
+// decryptLeaves returns copies of the leaves with their LeafValue and
+// ExtraData decrypted.
+func (c *crypter) decryptLeaves(ctx context.Context, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	for i, leaf := range leaves {
+		if leaf == nil {
+			continue
+		}
+		var err error
+		if leaves[i], err = c.decryptLeaf(ctx, leaf); err != nil {
+			return nil, err
+		}
+	}
+	return leaves, nil
+}
+
+func (c *crypter) decryptLeaf(ctx context.Context, leaf *trillian.LogLeaf) (*trillian.LogLeaf, error) {
+	value, err := c.decrypt(ctx, leaf, leaf.LeafValue, leafValueField)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := c.decrypt(ctx, leaf, leaf.ExtraData, extraDataField)
+	if err != nil {
+		return nil, err
+	}
+	return withData(leaf, value, extra), nil
+}
+
+// decryptQueued decrypts the leaves returned by QueueLeaves or
+// AddSequencedLeaves. Leaves returned as passed in are replaced with the
+// original plaintext leaves, and existing leaves are decrypted.
+func (c *crypter) decryptQueued(ctx context.Context, res []*trillian.QueuedLogLeaf, leaves, encrypted []*trillian.LogLeaf) ([]*trillian.QueuedLogLeaf, error) {
+	for i, r := range res {
+		if r.GetLeaf() == nil {
+			continue
+		}
+		leaf := r.Leaf
+		if i < len(encrypted) && leaf == encrypted[i] {
+			leaf = leaves[i]
+		} else {
+			var err error
+			if leaf, err = c.decryptLeaf(ctx, leaf); err != nil {
+				return nil, err
+			}
+		}
+		res[i] = &trillian.QueuedLogLeaf{Leaf: leaf, Status: r.Status}
+	}
+	return res, nil
+}
+
+func (c *crypter) encrypt(ctx context.Context, leaf *trillian.LogLeaf, data []byte, field byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	ct, err := c.enc.Encrypt(ctx, c.treeID, data, c.additionalData(leaf, field))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to encrypt leaf data: %v", err)
+	}
+	return ct, nil
+}
+
+func (c *crypter) decrypt(ctx context.Context, leaf *trillian.LogLeaf, data []byte, field byte) ([]byte, error) {
+	pt, err := c.enc.Decrypt(ctx, c.treeID, data, c.additionalData(leaf, field))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decrypt leaf %x: %v", leaf.LeafIdentityHash, err)
+	}
+	return pt, nil
+}
+
+// withData returns a copy of leaf with the given LeafValue and ExtraData.
+func withData(leaf *trillian.LogLeaf, value, extra []byte) *trillian.LogLeaf {
+	return &trillian.LogLeaf{
+		MerkleLeafHash:     leaf.MerkleLeafHash,
+		LeafValue:          value,
+		ExtraData:          extra,
+		LeafIndex:          leaf.LeafIndex,
+		LeafIdentityHash:   leaf.LeafIdentityHash,
+		QueueTimestamp:     leaf.QueueTimestamp,
+		IntegrateTimestamp: leaf.IntegrateTimestamp,
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/envelope"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func newEncrypter(t *testing.T) *envelope.Encrypter {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keyring")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	if err := os.WriteFile(path, []byte("k1 "+key+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write key ring: %v", err)
+	}
+	enc, err := envelope.NewEncrypter(envelope.Options{KeyURI: envelope.LocalScheme + "://" + path})
+	if err != nil {
+		t.Fatalf("NewEncrypter() = %v", err)
+	}
+	t.Cleanup(func() { enc.Close() })
+	return enc
+}
+
+func newTree(ctx context.Context, t *testing.T, ls storage.LogStorage, as storage.AdminStorage, treeType trillian.TreeType) *trillian.Tree {
+	t.Helper()
+	tree, err := storage.CreateTree(ctx, as, &trillian.Tree{
+		TreeType:        treeType,
+		TreeState:       trillian.TreeState_ACTIVE,
+		MaxRootDuration: durationpb.New(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateTree() = %v", err)
+	}
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		root, err := (&types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot()}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: root})
+	}); err != nil {
+		t.Fatalf("Failed to initialise tree: %v", err)
+	}
+	return tree
+}
+
+func newLeaves(first, n int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, 0, n)
+	for i := first; i < first+n; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		id := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafValue:        value,
+			ExtraData:        []byte(fmt.Sprintf("extra %d", i)),
+			LeafIdentityHash: id[:],
+			MerkleLeafHash:   rfc6962.DefaultHasher.HashLeaf(value),
+			LeafIndex:        int64(i),
+		})
+	}
+	return leaves
+}
+
+// checkLeaves checks that got has the values of want, encrypted if encrypted
+// is true.
+func checkLeaves(t *testing.T, got, want []*trillian.LogLeaf, encrypted bool) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Got %d leaves, want %d", len(got), len(want))
+	}
+	for i, leaf := range got {
+		if encrypted {
+			if !envelope.IsEncrypted(leaf.LeafValue) || !envelope.IsEncrypted(leaf.ExtraData) {
+				t.Errorf("Leaf %d has plaintext %q/%q, want encrypted", i, leaf.LeafValue, leaf.ExtraData)
+			}
+			continue
+		}
+		if !bytes.Equal(leaf.LeafValue, want[i].LeafValue) || !bytes.Equal(leaf.ExtraData, want[i].ExtraData) {
+			t.Errorf("Leaf %d is %q/%q, want %q/%q", i, leaf.LeafValue, leaf.ExtraData, want[i].LeafValue, want[i].ExtraData)
+		}
+	}
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	as := memory.NewAdminStorage(ts)
+	// The wrapped storage implements none of the optional interfaces.
+	ls := NewLogStorage(struct{ storage.LogStorage }{memory.NewLogStorage(ts, nil)}, newEncrypter(t))
+	tree := newTree(ctx, t, ls, as, trillian.TreeType_LOG)
+
+	if _, err := ls.(storage.UnsequencedCounter).CountUnsequenced(ctx, tree); status.Code(err) != codes.Unimplemented {
+		t.Errorf("CountUnsequenced() = %v, want code %v", err, codes.Unimplemented)
+	}
+	if _, err := ls.(storage.UnsequencedAger).OldestUnsequenced(ctx, tree); status.Code(err) != codes.Unimplemented {
+		t.Errorf("OldestUnsequenced() = %v, want code %v", err, codes.Unimplemented)
+	}
+	if _, err := ls.(storage.SubtreeCompactor).CompactSubtrees(ctx, tree, 1); status.Code(err) != codes.Unimplemented {
+		t.Errorf("CompactSubtrees() = %v, want code %v", err, codes.Unimplemented)
+	}
+	// Leaves are still queued, without reporting duplicates.
+	if err := ls.(storage.AsyncLeafQueuer).QueueLeavesAsync(ctx, tree, newLeaves(0, 2), time.Now()); err != nil {
+		t.Errorf("QueueLeavesAsync() = %v", err)
+	}
+}
+
+func TestPreorderedLog(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	raw, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	ls := NewLogStorage(raw, newEncrypter(t))
+	if _, ok := ls.(storage.UnsequencedCounter); !ok {
+		t.Errorf("%T doesn't implement storage.UnsequencedCounter", ls)
+	}
+	if _, ok := ls.(storage.SubtreeCompactor); !ok {
+		t.Errorf("%T doesn't implement storage.SubtreeCompactor", ls)
+	}
+	tree := newTree(ctx, t, ls, as, trillian.TreeType_PREORDERED_LOG)
+
+	// Leaves written before encryption was enabled are read unchanged.
+	leaves := newLeaves(0, 6)
+	if _, err := raw.AddSequencedLeaves(ctx, tree, leaves[:2], time.Now()); err != nil {
+		t.Fatalf("AddSequencedLeaves(raw) = %v", err)
+	}
+	if _, err := ls.AddSequencedLeaves(ctx, tree, leaves[2:], time.Now()); err != nil {
+		t.Fatalf("AddSequencedLeaves() = %v", err)
+	}
+	// The leaves passed in are left unencrypted.
+	checkLeaves(t, leaves, newLeaves(0, 6), false)
+
+	res, err := ls.AddSequencedLeaves(ctx, tree, newLeaves(3, 1), time.Now())
+	if err != nil {
+		t.Fatalf("AddSequencedLeaves(duplicate) = %v", err)
+	}
+	if got, want := codes.Code(res[0].GetStatus().GetCode()), codes.FailedPrecondition; got != want {
+		t.Errorf("AddSequencedLeaves(duplicate) status %v, want %v", got, want)
+	}
+
+	snapshot := func(ls storage.LogStorage) []*trillian.LogLeaf {
+		t.Helper()
+		tx, err := ls.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree() = %v", err)
+		}
+		defer tx.Close()
+		got, err := tx.GetLeavesByRange(ctx, 0, 6)
+		if err != nil {
+			t.Fatalf("GetLeavesByRange() = %v", err)
+		}
+		return got
+	}
+	checkLeaves(t, snapshot(ls), leaves, false)
+	stored := snapshot(raw)
+	checkLeaves(t, stored[:2], leaves[:2], false)
+	checkLeaves(t, stored[2:], leaves[2:], true)
+
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.DequeueLeaves(ctx, 6, time.Now())
+		if err != nil {
+			return err
+		}
+		checkLeaves(t, got, leaves, false)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction() = %v", err)
+	}
+
+	// Ciphertexts are bound to their leaves. The memory storage returns the
+	// stored leaves, so they're swapped in place.
+	if err := raw.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.GetLeavesByRange(ctx, 2, 2)
+		if err != nil {
+			return err
+		}
+		got[0].LeafValue, got[1].LeafValue = got[1].LeafValue, got[0].LeafValue
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction(raw) = %v", err)
+	}
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree() = %v", err)
+	}
+	defer tx.Close()
+	if got, err := tx.GetLeavesByRange(ctx, 2, 1); err == nil {
+		t.Errorf("GetLeavesByRange() of swapped ciphertexts = %v, want error", got)
+	}
+}
+
+func TestQueueLeaves(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	raw, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	ls := NewLogStorage(raw, newEncrypter(t))
+	tree := newTree(ctx, t, ls, as, trillian.TreeType_LOG)
+
+	leaves := newLeaves(0, 3)
+	leaves[2].ExtraData = nil
+	res, err := ls.QueueLeaves(ctx, tree, leaves, time.Now())
+	if err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+	var queued []*trillian.LogLeaf
+	for _, r := range res {
+		queued = append(queued, r.Leaf)
+	}
+	checkLeaves(t, queued, leaves, false)
+
+	if err := ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.DequeueLeaves(ctx, 3, time.Now().Add(time.Second))
+		if err != nil {
+			return err
+		}
+		checkLeaves(t, got, leaves, false)
+		if len(got[2].ExtraData) != 0 {
+			t.Errorf("Empty ExtraData read back as %x", got[2].ExtraData)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction() = %v", err)
+	}
+}
+
+func TestQueueLeavesAsync(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	raw, as := memory.NewLogStorage(ts, nil), memory.NewAdminStorage(ts)
+	ls := NewLogStorage(raw, newEncrypter(t))
+	tree := newTree(ctx, t, ls, as, trillian.TreeType_LOG)
+
+	// The memory storage doesn't queue leaves asynchronously, so QueueLeaves is used.
+	leaves := newLeaves(0, 3)
+	if err := ls.(storage.AsyncLeafQueuer).QueueLeavesAsync(ctx, tree, leaves, time.Now()); err != nil {
+		t.Fatalf("QueueLeavesAsync() = %v", err)
+	}
+
+	var dequeued []*trillian.LogLeaf
+	if err := raw.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		var err error
+		dequeued, err = tx.DequeueLeaves(ctx, 3, time.Now().Add(time.Second))
+		return err
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction() = %v", err)
+	}
+	checkLeaves(t, dequeued, leaves, true)
+}
@@ -16,6 +16,7 @@ package storage
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/google/trillian"
@@ -170,3 +171,158 @@ type LogStorage interface {
 	// be a good optimization. Could also be optional.
 	AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error)
 }
+
+// UnsequencedCounter is optionally implemented by LogStorage implementations
+// which can report the depth of the queue of a log.
+type UnsequencedCounter interface {
+	// CountUnsequenced returns the number of leaves queued in the tree which
+	// haven't been sequenced yet.
+	CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error)
+}
+
+// UnsequencedAger is optionally implemented by LogStorage implementations
+// which can report how long the leaves of a log have been queued.
+type UnsequencedAger interface {
+	// OldestUnsequenced returns the queue timestamp of the oldest leaf queued
+	// in the tree which hasn't been sequenced yet, or the zero time if there
+	// are no such leaves.
+	OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error)
+}
+
+// AsyncLeafQueuer is optionally implemented by LogStorage implementations
+// which can queue leaves without looking for duplicates of them first.
+type AsyncLeafQueuer interface {
+	// QueueLeavesAsync enqueues leaves for later integration into the tree,
+	// like QueueLeaves, but doesn't report the leaves which are duplicates of
+	// leaves already in the tree. Duplicates are instead dropped when the
+	// queued leaves are sequenced, so that each leaf is integrated once.
+	QueueLeavesAsync(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) error
+}
+
+// SubtreeCompactor is optionally implemented by LogStorage implementations
+// which keep the historical revisions of the subtrees of logs.
+type SubtreeCompactor interface {
+	// CompactSubtrees deletes the revisions of the subtrees of the tree which
+	// aren't read at any of its latest retained revisions, and returns the
+	// number of subtree revisions deleted. Reads of the tree at older
+	// revisions, such as by lagging replicas, may then miss nodes. Retained
+	// must be positive.
+	CompactSubtrees(ctx context.Context, tree *trillian.Tree, retained int64) (int64, error)
+}
+
+// RootAtTimeReader is optionally implemented by the ReadOnlyLogTreeTX of
+// LogStorage implementations which keep the historical roots of logs.
+type RootAtTimeReader interface {
+	// SignedLogRootAt returns the latest root of the tree whose timestamp is
+	// at or before ts, or nil if there is no such root.
+	SignedLogRootAt(ctx context.Context, ts time.Time) (*trillian.SignedLogRoot, error)
+}
+
+// SignedLogRootAt returns the latest root of tx's tree whose timestamp is at or
+// before ts, or nil if there is no such root. It returns an Unimplemented error
+// if tx doesn't implement RootAtTimeReader.
+func SignedLogRootAt(ctx context.Context, tx ReadOnlyLogTreeTX, ts time.Time) (*trillian.SignedLogRoot, error) {
+	r, ok := tx.(RootAtTimeReader)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "historical roots are not supported by the storage")
+	}
+	return r.SignedLogRootAt(ctx, ts)
+}
+
+// SparseLeafReader is optionally implemented by the ReadOnlyLogTreeTX of
+// LogStorage implementations which can read leaves at arbitrary, non-contiguous
+// indices in a single query.
+type SparseLeafReader interface {
+	// GetLeavesByIndex returns the sequenced leaves with the given indices,
+	// which are in ascending order and without duplicates. Indices without a
+	// sequenced leaf are skipped, so the leaves returned are also in ascending
+	// order of LeafIndex.
+	GetLeavesByIndex(ctx context.Context, indices []int64) ([]*trillian.LogLeaf, error)
+}
+
+// LeafByHashPager is optionally implemented by the ReadOnlyLogTreeTX of
+// LogStorage implementations which can read a page of the leaves with given
+// Merkle leaf hashes in a single query, without reading the leaves of earlier
+// pages.
+type LeafByHashPager interface {
+	// GetLeavesByHashPage returns at most limit sequenced leaves with the
+	// given Merkle leaf hashes, which are without duplicates, and indices in
+	// [start, end), in ascending order of LeafIndex.
+	GetLeavesByHashPage(ctx context.Context, leafHashes [][]byte, start, end int64, limit int) ([]*trillian.LogLeaf, error)
+}
+
+// GetLeavesByHashPage returns at most limit sequenced leaves of tx with the
+// given Merkle leaf hashes and indices in [start, end), in ascending order of
+// LeafIndex and without duplicates. It uses tx's LeafByHashPager
+// implementation if it has one, and otherwise reads all the leaves with
+// GetLeavesByHash.
+func GetLeavesByHashPage(ctx context.Context, tx ReadOnlyLogTreeTX, leafHashes [][]byte, start, end int64, limit int) ([]*trillian.LogLeaf, error) {
+	seen := make(map[string]bool)
+	var unique [][]byte
+	for _, hash := range leafHashes {
+		if !seen[string(hash)] {
+			seen[string(hash)] = true
+			unique = append(unique, hash)
+		}
+	}
+	if len(unique) == 0 || start >= end || limit <= 0 {
+		return nil, nil
+	}
+	if p, ok := tx.(LeafByHashPager); ok {
+		return p.GetLeavesByHashPage(ctx, unique, start, end, limit)
+	}
+
+	leaves, err := tx.GetLeavesByHash(ctx, unique, true)
+	if err != nil {
+		return nil, err
+	}
+	// Storage orders the leaves of each hash, but not those of different hashes.
+	sort.SliceStable(leaves, func(i, j int) bool { return leaves[i].LeafIndex < leaves[j].LeafIndex })
+	var ret []*trillian.LogLeaf
+	for _, leaf := range leaves {
+		if leaf.LeafIndex < start || leaf.LeafIndex >= end {
+			continue
+		}
+		if len(ret) == limit {
+			break
+		}
+		ret = append(ret, leaf)
+	}
+	return ret, nil
+}
+
+// GetLeavesByIndex returns the sequenced leaves of tx with the given indices,
+// in ascending order of LeafIndex and without duplicates. The indices must be
+// within the tree. It uses tx's SparseLeafReader implementation if it has one,
+// and otherwise reads each run of consecutive indices with GetLeavesByRange.
+func GetLeavesByIndex(ctx context.Context, tx ReadOnlyLogTreeTX, indices []int64) ([]*trillian.LogLeaf, error) {
+	sorted := append([]int64(nil), indices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var unique []int64
+	for _, index := range sorted {
+		if len(unique) == 0 || index != unique[len(unique)-1] {
+			unique = append(unique, index)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+	if r, ok := tx.(SparseLeafReader); ok {
+		return r.GetLeavesByIndex(ctx, unique)
+	}
+
+	var ret []*trillian.LogLeaf
+	for begin := 0; begin < len(unique); {
+		end := begin + 1
+		for end < len(unique) && unique[end] == unique[end-1]+1 {
+			end++
+		}
+		leaves, err := tx.GetLeavesByRange(ctx, unique[begin], int64(end-begin))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, leaves...)
+		begin = end
+	}
+	return ret, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian"
+)
+
+// rangeOnlyTX serves GetLeavesByRange from a log of size leaves, recording the
+// ranges it's asked for.
+type rangeOnlyTX struct {
+	ReadOnlyLogTreeTX
+	size   int64
+	ranges [][2]int64
+}
+
+func (t *rangeOnlyTX) GetLeavesByRange(_ context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	t.ranges = append(t.ranges, [2]int64{start, count})
+	var ret []*trillian.LogLeaf
+	for i := start; i < start+count && i < t.size; i++ {
+		ret = append(ret, &trillian.LogLeaf{LeafIndex: i})
+	}
+	return ret, nil
+}
+
+// sparseTX additionally implements SparseLeafReader.
+type sparseTX struct {
+	rangeOnlyTX
+	indices []int64
+}
+
+func (t *sparseTX) GetLeavesByIndex(_ context.Context, indices []int64) ([]*trillian.LogLeaf, error) {
+	t.indices = indices
+	var ret []*trillian.LogLeaf
+	for _, index := range indices {
+		ret = append(ret, &trillian.LogLeaf{LeafIndex: index})
+	}
+	return ret, nil
+}
+
+func TestGetLeavesByIndex(t *testing.T) {
+	ctx := context.Background()
+	indices := []int64{7, 2, 3, 9, 2, 8, 4}
+	want := []int64{2, 3, 4, 7, 8, 9}
+	leafIndices := func(leaves []*trillian.LogLeaf) []int64 {
+		var ret []int64
+		for _, leaf := range leaves {
+			ret = append(ret, leaf.LeafIndex)
+		}
+		return ret
+	}
+
+	t.Run("ranges", func(t *testing.T) {
+		tx := &rangeOnlyTX{size: 10}
+		leaves, err := GetLeavesByIndex(ctx, tx, indices)
+		if err != nil {
+			t.Fatalf("GetLeavesByIndex(): %v", err)
+		}
+		if diff := cmp.Diff(want, leafIndices(leaves)); diff != "" {
+			t.Errorf("leaf indices diff (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([][2]int64{{2, 3}, {7, 3}}, tx.ranges); diff != "" {
+			t.Errorf("ranges read diff (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("sparse", func(t *testing.T) {
+		tx := &sparseTX{rangeOnlyTX: rangeOnlyTX{size: 10}}
+		leaves, err := GetLeavesByIndex(ctx, tx, indices)
+		if err != nil {
+			t.Fatalf("GetLeavesByIndex(): %v", err)
+		}
+		if diff := cmp.Diff(want, leafIndices(leaves)); diff != "" {
+			t.Errorf("leaf indices diff (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(want, tx.indices); diff != "" {
+			t.Errorf("indices read diff (-want +got):\n%s", diff)
+		}
+		if len(tx.ranges) != 0 {
+			t.Errorf("read ranges %v, want none", tx.ranges)
+		}
+	})
+}
+
+// hashTX serves GetLeavesByHash from leaves, in the order of the requested
+// hashes.
+type hashTX struct {
+	ReadOnlyLogTreeTX
+	leaves []*trillian.LogLeaf
+}
+
+func (t *hashTX) GetLeavesByHash(_ context.Context, leafHashes [][]byte, _ bool) ([]*trillian.LogLeaf, error) {
+	var ret []*trillian.LogLeaf
+	for _, hash := range leafHashes {
+		for _, leaf := range t.leaves {
+			if bytes.Equal(leaf.MerkleLeafHash, hash) {
+				ret = append(ret, leaf)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// pagerTX additionally implements LeafByHashPager.
+type pagerTX struct {
+	hashTX
+	hashes [][]byte
+}
+
+func (t *pagerTX) GetLeavesByHashPage(ctx context.Context, leafHashes [][]byte, start, end int64, limit int) ([]*trillian.LogLeaf, error) {
+	t.hashes = leafHashes
+	return nil, nil
+}
+
+func TestGetLeavesByHashPage(t *testing.T) {
+	ctx := context.Background()
+	hashA, hashB := []byte("A"), []byte("B")
+	leaves := []*trillian.LogLeaf{
+		{MerkleLeafHash: hashA, LeafIndex: 1},
+		{MerkleLeafHash: hashA, LeafIndex: 6},
+		{MerkleLeafHash: hashB, LeafIndex: 3},
+		{MerkleLeafHash: hashB, LeafIndex: 4},
+		{MerkleLeafHash: hashB, LeafIndex: 8},
+	}
+	hashes := [][]byte{hashB, hashA, hashB}
+
+	for _, tc := range []struct {
+		start, end int64
+		limit      int
+		want       []int64
+	}{
+		{start: 0, end: 10, limit: 10, want: []int64{1, 3, 4, 6, 8}},
+		{start: 2, end: 10, limit: 2, want: []int64{3, 4}},
+		{start: 4, end: 8, limit: 10, want: []int64{4, 6}},
+		{start: 9, end: 10, limit: 10},
+	} {
+		got, err := GetLeavesByHashPage(ctx, &hashTX{leaves: leaves}, hashes, tc.start, tc.end, tc.limit)
+		if err != nil {
+			t.Fatalf("GetLeavesByHashPage(%d, %d, %d): %v", tc.start, tc.end, tc.limit, err)
+		}
+		var indices []int64
+		for _, leaf := range got {
+			indices = append(indices, leaf.LeafIndex)
+		}
+		if diff := cmp.Diff(tc.want, indices); diff != "" {
+			t.Errorf("GetLeavesByHashPage(%d, %d, %d) leaf indices diff (-want +got):\n%s", tc.start, tc.end, tc.limit, diff)
+		}
+	}
+
+	tx := &pagerTX{hashTX: hashTX{leaves: leaves}}
+	if _, err := GetLeavesByHashPage(ctx, tx, hashes, 0, 10, 10); err != nil {
+		t.Fatalf("GetLeavesByHashPage(): %v", err)
+	}
+	if diff := cmp.Diff([][]byte{hashB, hashA}, tx.hashes); diff != "" {
+		t.Errorf("hashes read by pager diff (-want +got):\n%s", diff)
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -55,6 +57,17 @@ func (s *memoryAdminStorage) CheckDatabaseAccessible(ctx context.Context) error
 	return nil
 }
 
+// TreeUsage implements storage.UsageReporter.
+func (s *memoryAdminStorage) TreeUsage(ctx context.Context, treeID int64) (storage.TreeUsage, error) {
+	tree := s.ms.getTree(treeID)
+	if tree == nil {
+		return storage.TreeUsage{}, status.Errorf(codes.NotFound, "tree %d not found", treeID)
+	}
+	tree.RLock()
+	defer tree.RUnlock()
+	return tree.usage, nil
+}
+
 type adminTX struct {
 	ms *TreeStorage
 
@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,12 +28,15 @@ import (
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/storagepb"
 	stree "github.com/google/trillian/storage/tree"
 	"github.com/google/trillian/types"
 	"github.com/transparency-dev/merkle/compact"
 	"github.com/transparency-dev/merkle/rfc6962"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const logIDLabel = "logid"
@@ -46,6 +50,7 @@ var (
 func createMetrics(mf monitoring.MetricFactory) {
 	queuedCounter = mf.NewCounter("mem_queued_leaves", "Number of leaves queued", logIDLabel)
 	dequeuedCounter = mf.NewCounter("mem_dequeued_leaves", "Number of leaves dequeued", logIDLabel)
+	cache.InitMetrics(mf)
 }
 
 func labelForTX(t *logTreeTX) string {
@@ -132,7 +137,7 @@ func (m *memoryLogStorage) beginInternal(ctx context.Context, tree *trillian.Tre
 		createMetrics(m.metricFactory)
 	})
 
-	stCache := cache.NewLogSubtreeCache(rfc6962.DefaultHasher)
+	stCache := cache.NewLogSubtreeCacheWithOpts(rfc6962.DefaultHasher, cache.Options{TreeID: tree.TreeId})
 	ttx, err := m.TreeStorage.beginTreeTX(ctx, tree.TreeId, rfc6962.DefaultHasher.Size(), stCache, readonly)
 	if err != nil {
 		return nil, err
@@ -175,7 +180,21 @@ func (m *memoryLogStorage) ReadWriteTransaction(ctx context.Context, tree *trill
 }
 
 func (m *memoryLogStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
-	return nil, status.Errorf(codes.Unimplemented, "AddSequencedLeaves is not implemented")
+	tx, err := m.beginInternal(ctx, tree, false /* readonly */)
+	if tx != nil {
+		defer tx.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.AddSequencedLeaves(ctx, leaves, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
 func (m *memoryLogStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
@@ -220,6 +239,88 @@ func (m *memoryLogStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree,
 	return ret, nil
 }
 
+// CountUnsequenced implements storage.UnsequencedCounter.
+func (m *memoryLogStorage) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	mTree := m.getTree(tree.TreeId)
+	if mTree == nil {
+		return 0, status.Errorf(codes.NotFound, "tree %d not found", tree.TreeId)
+	}
+	mTree.RLock()
+	defer mTree.RUnlock()
+	q := mTree.store.Get(unseqKey(tree.TreeId)).(*kv).v.(*list.List)
+	return int64(q.Len()), nil
+}
+
+// OldestUnsequenced implements storage.UnsequencedAger.
+func (m *memoryLogStorage) OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error) {
+	mTree := m.getTree(tree.TreeId)
+	if mTree == nil {
+		return time.Time{}, status.Errorf(codes.NotFound, "tree %d not found", tree.TreeId)
+	}
+	mTree.RLock()
+	defer mTree.RUnlock()
+	q := mTree.store.Get(unseqKey(tree.TreeId)).(*kv).v.(*list.List)
+	// Leaves are queued in order, so the front of the queue is the oldest.
+	if e := q.Front(); e != nil {
+		return e.Value.(*trillian.LogLeaf).QueueTimestamp.AsTime(), nil
+	}
+	return time.Time{}, nil
+}
+
+// CompactSubtrees implements storage.SubtreeCompactor.
+func (m *memoryLogStorage) CompactSubtrees(ctx context.Context, tree *trillian.Tree, retained int64) (int64, error) {
+	if retained < 1 {
+		return 0, fmt.Errorf("retained revisions %d, want > 0", retained)
+	}
+	mTree := m.getTree(tree.TreeId)
+	if mTree == nil {
+		return 0, status.Errorf(codes.NotFound, "tree %d not found", tree.TreeId)
+	}
+	mTree.Lock()
+	defer mTree.Unlock()
+	r := mTree.store.Get(revKey(tree.TreeId, mTree.currentSTH))
+	if r == nil {
+		return 0, nil
+	}
+	oldest := r.(*kv).v.(int64) - retained + 1
+
+	// Collect the revisions of each subtree which are at or before the oldest
+	// retained revision, keyed by the subtree's key without its revision.
+	revs := make(map[string][]int64)
+	prefix := fmt.Sprintf("/%d/subtree/", tree.TreeId)
+	mTree.store.AscendGreaterOrEqual(&kv{k: prefix}, func(i btree.Item) bool {
+		k := i.(*kv).k
+		if !strings.HasPrefix(k, prefix) {
+			return false
+		}
+		sep := strings.LastIndex(k, "/")
+		if rev, err := strconv.ParseInt(k[sep+1:], 10, 64); err == nil && rev <= oldest {
+			revs[k[:sep]] = append(revs[k[:sep]], rev)
+		}
+		return true
+	})
+
+	// Only the latest of these revisions is read at the retained revisions.
+	var deleted int64
+	for id, subtreeRevs := range revs {
+		var latest int64
+		for _, rev := range subtreeRevs {
+			if rev > latest {
+				latest = rev
+			}
+		}
+		for _, rev := range subtreeRevs {
+			if rev == latest {
+				continue
+			}
+			i := mTree.store.Delete(&kv{k: fmt.Sprintf("%s/%d", id, rev)})
+			mTree.usage.SubtreeBytes -= int64(proto.Size(i.(*kv).v.(*storagepb.SubtreeProto)))
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 type logTreeTX struct {
 	treeTX
 	ls   *memoryLogStorage
@@ -234,13 +335,22 @@ func (t *logTreeTX) GetMerkleNodes(ctx context.Context, ids []compact.NodeID) ([
 }
 
 func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	if t.tree.meta.GetTreeType() == trillian.TreeType_PREORDERED_LOG {
+		return t.getContiguousLeaves(int64(t.root.TreeSize), int64(limit)), nil
+	}
+
 	leaves := make([]*trillian.LogLeaf, 0, limit)
 
 	q := t.tx.Get(unseqKey(t.treeID)).(*kv).v.(*list.List)
 	e := q.Front()
 	for i := 0; i < limit && e != nil; i++ {
-		// TODO(al): consider cutoffTime
-		leaves = append(leaves, e.Value.(*trillian.LogLeaf))
+		leaf := e.Value.(*trillian.LogLeaf)
+		// Leaves are queued in order, so the ones after a leaf queued after the
+		// cutoff were too.
+		if leaf.QueueTimestamp.AsTime().After(cutoffTime) {
+			break
+		}
+		leaves = append(leaves, leaf)
 		e = e.Next()
 	}
 
@@ -260,13 +370,61 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 	k := unseqKey(t.treeID)
 	q := t.tx.Get(k).(*kv).v.(*list.List)
 	for _, l := range leaves {
+		l.QueueTimestamp = timestamppb.New(queueTimestamp)
 		q.PushBack(l)
+		t.usage.LeafDataBytes += leafDataBytes(l)
+		t.usage.QueueBytes += queueEntryBytes(l)
 	}
 	return make([]*trillian.LogLeaf, len(leaves)), nil
 }
 
+// AddSequencedLeaves stores the leaves at their LeafIndex positions. As with
+// QueueLeaves, there is no deduping by LeafIdentityHash in this storage, only
+// conflicting indices are reported.
 func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
-	return nil, status.Errorf(codes.Unimplemented, "AddSequencedLeaves is not implemented")
+	res := make([]*trillian.QueuedLogLeaf, len(leaves))
+	ok := status.New(codes.OK, "OK").Proto()
+	queueTS := timestamppb.New(timestamp)
+	if err := queueTS.CheckValid(); err != nil {
+		return nil, fmt.Errorf("got invalid queue timestamp: %w", err)
+	}
+
+	h2s := t.tx.Get(hashToSeqKey(t.treeID)).(*kv).v.(map[string][]int64)
+	for i, leaf := range leaves {
+		if got, want := len(leaf.LeafIdentityHash), t.hashSizeBytes; got != want {
+			return nil, status.Errorf(codes.FailedPrecondition, "leaves[%d] has incorrect hash size %d, want %d", i, got, want)
+		}
+		if leaf.LeafIndex < 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "leaves[%d] has negative index %d", i, leaf.LeafIndex)
+		}
+		k := seqLeafKey(t.treeID, leaf.LeafIndex)
+		if t.tx.Has(k) {
+			res[i] = &trillian.QueuedLogLeaf{Status: status.New(codes.FailedPrecondition, "conflicting LeafIndex").Proto()}
+			continue
+		}
+		stored := proto.Clone(leaf).(*trillian.LogLeaf)
+		stored.QueueTimestamp = queueTS
+		k.(*kv).v = stored
+		t.tx.ReplaceOrInsert(k)
+		h2s[string(leaf.MerkleLeafHash)] = append(h2s[string(leaf.MerkleLeafHash)], leaf.LeafIndex)
+		t.usage.LeafDataBytes += leafDataBytes(leaf)
+		res[i] = &trillian.QueuedLogLeaf{Status: ok}
+	}
+	return res, nil
+}
+
+// getContiguousLeaves returns the sequenced leaves in [start, start+count),
+// stopping at the first missing index.
+func (t *logTreeTX) getContiguousLeaves(start, count int64) []*trillian.LogLeaf {
+	ret := make([]*trillian.LogLeaf, 0, count)
+	for i := int64(0); i < count; i++ {
+		leaf := t.tx.Get(seqLeafKey(t.treeID, start+i))
+		if leaf == nil {
+			break
+		}
+		ret = append(ret, leaf.(*kv).v.(*trillian.LogLeaf))
+	}
+	return ret
 }
 
 func (t *logTreeTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
@@ -280,6 +438,17 @@ func (t *logTreeTX) GetLeavesByRange(ctx context.Context, start, count int64) ([
 	return ret, nil
 }
 
+// GetLeavesByIndex implements storage.SparseLeafReader.
+func (t *logTreeTX) GetLeavesByIndex(ctx context.Context, indices []int64) ([]*trillian.LogLeaf, error) {
+	ret := make([]*trillian.LogLeaf, 0, len(indices))
+	for _, index := range indices {
+		if leaf := t.tx.Get(seqLeafKey(t.treeID, index)); leaf != nil {
+			ret = append(ret, leaf.(*kv).v.(*trillian.LogLeaf))
+		}
+	}
+	return ret, nil
+}
+
 func (t *logTreeTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
 	m := t.tx.Get(hashToSeqKey(t.treeID)).(*kv).v.(map[string][]int64)
 
@@ -321,6 +490,19 @@ func (t *logTreeTX) fetchLatestRoot(ctx context.Context) (*trillian.SignedLogRoo
 	return sth, rev, nil
 }
 
+// SignedLogRootAt implements storage.RootAtTimeReader.
+func (t *logTreeTX) SignedLogRootAt(ctx context.Context, ts time.Time) (*trillian.SignedLogRoot, error) {
+	var slr *trillian.SignedLogRoot
+	prefix := fmt.Sprintf("/%d/sth/", t.treeID)
+	t.tx.DescendLessOrEqual(sthKey(t.treeID, uint64(ts.UnixNano())), func(i btree.Item) bool {
+		if r := i.(*kv); strings.HasPrefix(r.k, prefix) {
+			slr = r.v.(*trillian.SignedLogRoot)
+		}
+		return false
+	})
+	return slr, nil
+}
+
 func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, slr *trillian.SignedLogRoot) error {
 	var root types.LogRootV1
 	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
@@ -375,7 +557,7 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 		}
 	}
 	for _, e := range toRemove {
-		q.Remove(e)
+		t.usage.QueueBytes -= queueEntryBytes(q.Remove(e).(*trillian.LogLeaf))
 	}
 
 	if unknown := len(countByMerkleHash); unknown != 0 {
@@ -384,3 +566,14 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 
 	return nil
 }
+
+// leafDataBytes returns the number of bytes accounted for the data of leaf.
+func leafDataBytes(leaf *trillian.LogLeaf) int64 {
+	return int64(len(leaf.LeafIdentityHash) + len(leaf.MerkleLeafHash) + len(leaf.LeafValue) + len(leaf.ExtraData))
+}
+
+// queueEntryBytes returns the number of bytes accounted for the queue entry of
+// leaf, which only refers to its data.
+func queueEntryBytes(leaf *trillian.LogLeaf) int64 {
+	return int64(len(leaf.LeafIdentityHash) + len(leaf.MerkleLeafHash))
+}
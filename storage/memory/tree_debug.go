@@ -15,8 +15,11 @@
 package memory
 
 import (
+	"fmt"
+
 	"github.com/golang/glog"
 	"github.com/google/btree"
+	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/storagepb"
 )
@@ -51,3 +54,22 @@ func DumpSubtrees(ls storage.LogStorage, treeID int64, callback func(string, *st
 		return true
 	})
 }
+
+// TamperLeaf calls f on the stored sequenced leaf of the tree at index, which f
+// can modify in place, bypassing the storage API. It's meant for tests which
+// check that corrupted storage is detected.
+func TamperLeaf(ls storage.LogStorage, treeID, index int64, f func(*trillian.LogLeaf)) error {
+	m := ls.(*memoryLogStorage)
+	tree := m.getTree(treeID)
+	if tree == nil {
+		return fmt.Errorf("tree %d not found", treeID)
+	}
+	tree.Lock()
+	defer tree.Unlock()
+	item := tree.store.Get(seqLeafKey(treeID, index))
+	if item == nil {
+		return fmt.Errorf("leaf %d of tree %d not found", index, treeID)
+	}
+	f(item.(*kv).v.(*trillian.LogLeaf))
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/btree"
 	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/storagepb"
 	stree "github.com/google/trillian/storage/tree"
@@ -58,6 +59,8 @@ type tree struct {
 	// currentSTH is the timestamp of the current STH.
 	currentSTH uint64
 	meta       *trillian.Tree
+	// usage is the approximate number of bytes stored for the tree.
+	usage storage.TreeUsage
 }
 
 func (t *tree) Lock() {
@@ -146,6 +149,7 @@ func (m *TreeStorage) beginTreeTX(ctx context.Context, treeID int64, hashSizeByt
 		hashSizeBytes: hashSizeBytes,
 		subtreeCache:  cache,
 		writeRevision: -1,
+		readonly:      readonly,
 		unlock:        unlock,
 	}, nil
 }
@@ -159,7 +163,11 @@ type treeTX struct {
 	hashSizeBytes int
 	subtreeCache  *cache.SubtreeCache
 	writeRevision int64
+	readonly      bool
 	unlock        func()
+	// usage accumulates the changes to the bytes stored for the tree made by
+	// the transaction.
+	usage storage.TreeUsage
 }
 
 func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, ids [][]byte) ([]*storagepb.SubtreeProto, error) {
@@ -203,6 +211,7 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 		k := subtreeKey(t.treeID, t.writeRevision, s.Prefix)
 		k.(*kv).v = s
 		t.tx.ReplaceOrInsert(k)
+		t.usage.SubtreeBytes += int64(proto.Size(s))
 	}
 	return nil
 }
@@ -234,8 +243,12 @@ func (t *treeTX) Commit(ctx context.Context) error {
 		}
 	}
 	t.closed = true
-	// update the shared view of the tree post TX:
-	t.tree.store = t.tx
+	// update the shared view of the tree post TX. Read-only TXs only hold a
+	// read lock, and leave it as it is.
+	if !t.readonly {
+		t.tree.store = t.tx
+		t.tree.usage.Add(t.usage)
+	}
 	return nil
 }
 
@@ -18,10 +18,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/storage"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -50,13 +52,23 @@ const (
 			PublicKey,
 			MaxRootDurationMillis,
 			Deleted,
-			DeleteTimeMillis
+			DeleteTimeMillis,
+			LeafDedupPolicy,
+			SequencerGuardWindowMillis,
+			SequencerBatchSize,
+			TreePriority,
+			SignerAffinity,
+			AntiAffinityGroup,
+			MaxLeafValueSize,
+			MaxExtraDataSize,
+			MaxUnsequencedLeaves,
+			CompactRetainedRevisions
 		FROM Trees`
 	selectNonDeletedTrees = selectTrees + nonDeletedWhere
 	selectTreeByID        = selectTrees + " WHERE TreeId = ?"
 
 	updateTreeSQL = `UPDATE Trees
-		SET TreeState = ?, TreeType = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?, MaxRootDurationMillis = ?, PrivateKey = ?
+		SET TreeState = ?, TreeType = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?, MaxRootDurationMillis = ?, SequencerGuardWindowMillis = ?, SequencerBatchSize = ?, TreePriority = ?, SignerAffinity = ?, AntiAffinityGroup = ?, MaxLeafValueSize = ?, MaxExtraDataSize = ?, MaxUnsequencedLeaves = ?, CompactRetainedRevisions = ?, PrivateKey = ?
 		WHERE TreeId = ?`
 )
 
@@ -77,7 +89,7 @@ func (s *mysqlAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdmin
 func (s *mysqlAdminStorage) beginInternal(ctx context.Context) (storage.AdminTX, error) {
 	tx, err := s.db.BeginTx(ctx, nil /* opts */)
 	if err != nil {
-		return nil, err
+		return nil, logging.AnnotateError(ctx, err)
 	}
 	return &adminTX{tx: tx}, nil
 }
@@ -91,7 +103,7 @@ func (s *mysqlAdminStorage) ReadWriteTransaction(ctx context.Context, f storage.
 	if err := f(ctx, tx); err != nil {
 		return err
 	}
-	return tx.Commit()
+	return logging.AnnotateError(ctx, tx.Commit())
 }
 
 func (s *mysqlAdminStorage) CheckDatabaseAccessible(ctx context.Context) error {
@@ -205,6 +217,12 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		return nil, fmt.Errorf("could not parse MaxRootDuration: %w", err)
 	}
 	rootDuration := newTree.MaxRootDuration.AsDuration()
+	if newTree.SequencerGuardWindow != nil {
+		if err := newTree.SequencerGuardWindow.CheckValid(); err != nil {
+			return nil, fmt.Errorf("could not parse SequencerGuardWindow: %w", err)
+		}
+	}
+	guardWindow := newTree.SequencerGuardWindow.AsDuration()
 
 	insertTreeStmt, err := t.tx.PrepareContext(
 		ctx,
@@ -221,8 +239,18 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 			UpdateTimeMillis,
 			PrivateKey,
 			PublicKey,
-			MaxRootDurationMillis)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+			MaxRootDurationMillis,
+			LeafDedupPolicy,
+			SequencerGuardWindowMillis,
+			SequencerBatchSize,
+			TreePriority,
+			SignerAffinity,
+			AntiAffinityGroup,
+			MaxLeafValueSize,
+			MaxExtraDataSize,
+			MaxUnsequencedLeaves,
+			CompactRetainedRevisions)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
@@ -243,6 +271,16 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		[]byte{}, // Unused, filling in for backward compatibility.
 		[]byte{}, // Unused, filling in for backward compatibility.
 		rootDuration/time.Millisecond,
+		newTree.LeafDedupPolicy.String(),
+		guardWindow/time.Millisecond,
+		newTree.SequencerBatchSize,
+		newTree.Priority.String(),
+		strings.Join(newTree.SignerAffinity, ","),
+		newTree.AntiAffinityGroup,
+		newTree.MaxLeafValueSize,
+		newTree.MaxExtraDataSize,
+		newTree.MaxUnsequencedLeaves,
+		newTree.CompactRetainedRevisions,
 	)
 	if err != nil {
 		return nil, err
@@ -312,6 +350,12 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 		return nil, fmt.Errorf("could not parse MaxRootDuration: %w", err)
 	}
 	rootDuration := tree.MaxRootDuration.AsDuration()
+	if tree.SequencerGuardWindow != nil {
+		if err := tree.SequencerGuardWindow.CheckValid(); err != nil {
+			return nil, fmt.Errorf("could not parse SequencerGuardWindow: %w", err)
+		}
+	}
+	guardWindow := tree.SequencerGuardWindow.AsDuration()
 
 	stmt, err := t.tx.PrepareContext(ctx, updateTreeSQL)
 	if err != nil {
@@ -327,6 +371,15 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 		tree.Description,
 		nowMillis,
 		rootDuration/time.Millisecond,
+		guardWindow/time.Millisecond,
+		tree.SequencerBatchSize,
+		tree.Priority.String(),
+		strings.Join(tree.SignerAffinity, ","),
+		tree.AntiAffinityGroup,
+		tree.MaxLeafValueSize,
+		tree.MaxExtraDataSize,
+		tree.MaxUnsequencedLeaves,
+		tree.CompactRetainedRevisions,
 		[]byte{}, // Unused, filling in for backward compatibility.
 		tree.TreeId); err != nil {
 		return nil, err
@@ -367,6 +420,13 @@ func (t *adminTX) HardDeleteTree(ctx context.Context, treeID int64) error {
 	if _, err := t.tx.ExecContext(ctx, "DELETE FROM TreeControl WHERE TreeId = ?", treeID); err != nil {
 		return err
 	}
+	// The leaf tables have no foreign keys once partitioned by schema.PartitionLeafTables, so
+	// their rows aren't deleted by the cascade either.
+	for _, table := range []string{"SequencedLeafData", "LeafData"} {
+		if _, err := t.tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE TreeId = ?", table), treeID); err != nil {
+			return err
+		}
+	}
 	_, err := t.tx.ExecContext(ctx, "DELETE FROM Trees WHERE TreeId = ?", treeID)
 	return err
 }
@@ -182,6 +182,15 @@ func TestAdminTX_HardDeleteTree(t *testing.T) {
 		t.Fatalf("CreateTree() returned err = %v", err)
 	}
 
+	for _, stmt := range []string{
+		"INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,QueueTimestampNanos) VALUES(?,'id','value',0)",
+		"INSERT INTO SequencedLeafData(TreeId,SequenceNumber,LeafIdentityHash,MerkleLeafHash,IntegrateTimestampNanos) VALUES(?,0,'id','hash',0)",
+	} {
+		if _, err := DB.ExecContext(ctx, stmt, tree.TreeId); err != nil {
+			t.Fatalf("ExecContext(%q) returned err = %v", stmt, err)
+		}
+	}
+
 	if err := s.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
 		if _, err := tx.SoftDeleteTree(ctx, tree.TreeId); err != nil {
 			return err
@@ -198,6 +207,16 @@ func TestAdminTX_HardDeleteTree(t *testing.T) {
 	if err := DB.QueryRowContext(ctx, "SELECT DisplayName FROM Trees WHERE TreeId = ?", tree.TreeId).Scan(&name); err != sql.ErrNoRows {
 		t.Errorf("QueryRowContext() returned err = %v, want = %v", err, sql.ErrNoRows)
 	}
+	// The leaf tables are checked too, as they have no foreign keys once partitioned.
+	for _, table := range []string{"SequencedLeafData", "LeafData"} {
+		var count int
+		if err := DB.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE TreeId = ?", table), tree.TreeId).Scan(&count); err != nil {
+			t.Fatalf("QueryRowContext() returned err = %v", err)
+		}
+		if count != 0 {
+			t.Errorf("%s has %d rows of the deleted tree, want 0", table, count)
+		}
+	}
 }
 
 func TestCheckDatabaseAccessible_Fails(t *testing.T) {
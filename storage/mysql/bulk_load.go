@@ -0,0 +1,138 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage/storagepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// loadDataSQL loads tab-separated rows from a reader registered with the
+// driver into a table. Binary columns are hex-encoded, and loaded through user
+// variables which are decoded with UNHEX, so that they need no escaping.
+const loadDataSQL = "LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET binary " +
+	"FIELDS TERMINATED BY '\\t' LINES TERMINATED BY '\\n' (%s) SET %s"
+
+// loadCount makes the names of the readers registered with the driver unique.
+var loadCount uint64
+
+// bulkLoad returns whether rows written to a table should be loaded with
+// LOAD DATA rather than inserted.
+func (m *mySQLTreeStorage) bulkLoad(rows int) bool {
+	return m.bulkLoadRows > 0 && rows >= m.bulkLoadRows
+}
+
+// loadColumn is a column of the rows of a loadData call.
+type loadColumn struct {
+	name string
+	// binary columns hold []byte values, and other columns int64 values.
+	binary bool
+}
+
+// loadData writes rows to table with LOAD DATA LOCAL INFILE, in the
+// transaction of t. LOAD DATA skips the rows with duplicate keys instead of
+// failing, so an error is returned unless all the rows were written.
+func (t *treeTX) loadData(ctx context.Context, table string, columns []loadColumn, rows [][]interface{}) error {
+	names := make([]string, 0, len(columns))
+	var sets []string
+	for _, c := range columns {
+		if c.binary {
+			names = append(names, "@"+c.name)
+			sets = append(sets, fmt.Sprintf("%s=UNHEX(@%s)", c.name, c.name))
+		} else {
+			names = append(names, c.name)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for _, row := range rows {
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			switch v := v.(type) {
+			case []byte:
+				buf.WriteString(hex.EncodeToString(v))
+			case int64:
+				buf.WriteString(strconv.FormatInt(v, 10))
+			default:
+				return fmt.Errorf("unsupported value of type %T for column %s", v, columns[i].name)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+
+	name := fmt.Sprintf("trillian-%s-%d", table, atomic.AddUint64(&loadCount, 1))
+	mysql.RegisterReaderHandler(name, func() io.Reader { return buf })
+	defer mysql.DeregisterReaderHandler(name)
+	result, err := t.tx.ExecContext(ctx, fmt.Sprintf(loadDataSQL, name, table, strings.Join(names, ","), strings.Join(sets, ",")))
+	if err != nil {
+		glog.Warningf("Failed to load %d rows into %s: %s", len(rows), table, err)
+	}
+	return checkResultOkAndRowCountIs(result, err, int64(len(rows)))
+}
+
+// loadSequencedLeaves writes the SequencedLeafData rows of leaves with a
+// single LOAD DATA statement.
+func (t *logTreeTX) loadSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) error {
+	rows := make([][]interface{}, 0, len(leaves))
+	for _, leaf := range leaves {
+		if err := leaf.IntegrateTimestamp.CheckValid(); err != nil {
+			return fmt.Errorf("got invalid integrate timestamp: %w", err)
+		}
+		rows = append(rows, []interface{}{t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex, leaf.IntegrateTimestamp.AsTime().UnixNano()})
+	}
+	return t.loadData(ctx, "SequencedLeafData", []loadColumn{
+		{name: "TreeId"},
+		{name: "LeafIdentityHash", binary: true},
+		{name: "MerkleLeafHash", binary: true},
+		{name: "SequenceNumber"},
+		{name: "IntegrateTimestampNanos"},
+	}, rows)
+}
+
+// loadSubtrees writes subtrees with a single LOAD DATA statement.
+func (t *treeTX) loadSubtrees(ctx context.Context, subtrees []*storagepb.SubtreeProto) error {
+	rows := make([][]interface{}, 0, len(subtrees))
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []interface{}{t.treeID, s.Prefix, subtreeBytes, t.writeRevision})
+		t.usage.SubtreeBytes += int64(subtreeRowBytes + len(s.Prefix) + len(subtreeBytes))
+	}
+	return t.loadData(ctx, "Subtree", []loadColumn{
+		{name: "TreeId"},
+		{name: "SubtreeId", binary: true},
+		{name: "Nodes", binary: true},
+		{name: "SubtreeRevision"},
+	}, rows)
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestBulkLoad(t *testing.T) {
+	ctx := context.Background()
+	var localInfile bool
+	if err := DB.QueryRowContext(ctx, "SELECT @@GLOBAL.local_infile").Scan(&localInfile); err != nil {
+		t.Fatalf("Failed to read local_infile: %v", err)
+	}
+	if !localInfile {
+		t.Skip("local_infile is disabled on the test database")
+	}
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorageWithOpts(DB, nil, LogStorageOptions{BulkLoadRows: 2})
+	mustSignAndStoreLogRoot(ctx, t, s, tree, 0)
+
+	const count = 10
+	leaves := createTestLeaves(count, 0)
+	if _, err := s.QueueLeaves(ctx, tree, leaves, fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+	nodes, err := createLogNodesForTreeAtSize(t, 871, 1)
+	if err != nil {
+		t.Fatalf("Failed to create test tree: %v", err)
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		dequeued, err := tx.DequeueLeaves(ctx, count, fakeDequeueCutoffTime)
+		if err != nil {
+			t.Fatalf("DequeueLeaves() = %v", err)
+		}
+		iTimestamp := timestamppb.Now()
+		for i, l := range dequeued {
+			l.IntegrateTimestamp = iTimestamp
+			l.LeafIndex = int64(i)
+		}
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			t.Fatalf("UpdateSequencedLeaves(): %v", err)
+		}
+		// Rows with duplicate keys are skipped by LOAD DATA, which must fail.
+		if err := tx.(*logTreeTX).loadSequencedLeaves(ctx, dequeued); err == nil {
+			t.Error("loadSequencedLeaves() of duplicate leaves succeeded, want error")
+		}
+		if err := tx.SetMerkleNodes(ctx, nodes); err != nil {
+			t.Fatalf("SetMerkleNodes(): %v", err)
+		}
+		logRoot, err := (&types.LogRootV1{TreeSize: count, TimestampNanos: 1, RootHash: []byte{1}}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: logRoot})
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.GetLeavesByRange(ctx, 0, count)
+		if err != nil {
+			t.Fatalf("GetLeavesByRange(): %v", err)
+		}
+		if len(got) != count {
+			t.Fatalf("GetLeavesByRange() returned %d leaves, want %d", len(got), count)
+		}
+		byHash := make(map[string]bool)
+		for _, leaf := range leaves {
+			byHash[string(leaf.MerkleLeafHash)] = true
+		}
+		for _, leaf := range got {
+			if !byHash[string(leaf.MerkleLeafHash)] {
+				t.Errorf("GetLeavesByRange() returned unknown leaf %x", leaf.MerkleLeafHash)
+			}
+		}
+
+		ids := make([]compact.NodeID, len(nodes))
+		want := make(map[compact.NodeID][]byte, len(nodes))
+		for i, n := range nodes {
+			ids[i] = n.ID
+			want[n.ID] = n.Hash
+		}
+		read, err := tx.GetMerkleNodes(ctx, ids)
+		if err != nil {
+			t.Fatalf("GetMerkleNodes(): %v", err)
+		}
+		if len(read) != len(nodes) {
+			t.Fatalf("GetMerkleNodes() returned %d nodes, want %d", len(read), len(nodes))
+		}
+		for _, n := range read {
+			if !bytes.Equal(n.Hash, want[n.ID]) {
+				t.Errorf("GetMerkleNodes() returned hash %x for %v, want %x", n.Hash, n.ID, want[n.ID])
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+const (
+	// selectSubtreeRevisionsSQL returns the subtrees of a tree after a given
+	// subtree ID, with their latest revision at or before a given revision and
+	// the number of their revisions at or before it.
+	selectSubtreeRevisionsSQL = `SELECT SubtreeId,MAX(SubtreeRevision),COUNT(*)
+			FROM Subtree WHERE TreeId=? AND SubtreeId>? AND SubtreeRevision<=?
+			GROUP BY SubtreeId ORDER BY SubtreeId LIMIT ?`
+	selectObsoleteSubtreeBytesSQL = `SELECT COUNT(*),COALESCE(SUM(LENGTH(Nodes)),0)
+			FROM Subtree WHERE TreeId=? AND SubtreeId=? AND SubtreeRevision<?`
+	deleteObsoleteSubtreesSQL = "DELETE FROM Subtree WHERE TreeId=? AND SubtreeId=? AND SubtreeRevision<?"
+
+	// compactionBatchSize is the number of subtrees looked at by each query of
+	// CompactSubtrees.
+	compactionBatchSize = 256
+)
+
+// CompactSubtrees implements storage.SubtreeCompactor. The subtrees of the tree
+// are walked in batches in the order of their IDs, and the revisions of each
+// which precede the one read at the oldest retained revision are deleted.
+func (m *mySQLLogStorage) CompactSubtrees(ctx context.Context, tree *trillian.Tree, retained int64) (int64, error) {
+	if retained < 1 {
+		return 0, fmt.Errorf("retained revisions %d, want > 0", retained)
+	}
+	var latest int64
+	if err := m.db.QueryRowContext(ctx, selectLatestTreeRevisionSQL, tree.TreeId).Scan(&latest); err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	oldest := latest - retained + 1
+	if oldest <= 0 {
+		return 0, nil
+	}
+
+	var deleted int64
+	var usage storage.TreeUsage
+	defer func() { m.usage.record(tree.TreeId, usage) }()
+	after := []byte{}
+	for {
+		batch, err := m.subtreeBatch(ctx, tree.TreeId, after, oldest)
+		if err != nil {
+			return deleted, err
+		}
+		for _, s := range batch {
+			if s.count < 2 {
+				continue
+			}
+			var count, nodeBytes int64
+			if err := m.db.QueryRowContext(ctx, selectObsoleteSubtreeBytesSQL, tree.TreeId, s.id, s.kept).Scan(&count, &nodeBytes); err != nil {
+				return deleted, err
+			}
+			res, err := m.db.ExecContext(ctx, deleteObsoleteSubtreesSQL, tree.TreeId, s.id, s.kept)
+			if err != nil {
+				return deleted, err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+			usage.SubtreeBytes -= count*int64(subtreeRowBytes+len(s.id)) + nodeBytes
+		}
+		if len(batch) < compactionBatchSize {
+			return deleted, nil
+		}
+		after = batch[len(batch)-1].id
+	}
+}
+
+// subtreeRevisions describes the revisions of a subtree at or before the
+// oldest retained revision of its tree.
+type subtreeRevisions struct {
+	id []byte
+	// kept is the latest of the revisions, which is the one read at the oldest
+	// retained revision.
+	kept  int64
+	count int64
+}
+
+// subtreeBatch returns the revisions at or before revision oldest of a
+// batch of the subtrees of a tree, which follow the subtree with ID after.
+func (m *mySQLLogStorage) subtreeBatch(ctx context.Context, treeID int64, after []byte, oldest int64) ([]subtreeRevisions, error) {
+	rows, err := m.db.QueryContext(ctx, selectSubtreeRevisionsSQL, treeID, after, oldest, compactionBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []subtreeRevisions
+	for rows.Next() {
+		var s subtreeRevisions
+		if err := rows.Scan(&s.id, &s.kept, &s.count); err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, rows.Err()
+}
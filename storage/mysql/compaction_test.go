@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+	stree "github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+)
+
+func TestCompactSubtrees(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+	c := s.(storage.SubtreeCompactor)
+
+	// Rewrite the same subtree at each revision.
+	id := compact.NewNodeID(0, 0)
+	for rev := int64(1); rev <= 4; rev++ {
+		runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			forceWriteRevision(rev, tx)
+			if err := tx.SetMerkleNodes(ctx, []stree.Node{{ID: id, Hash: []byte{byte(rev)}}}); err != nil {
+				t.Fatalf("SetMerkleNodes() = %v", err)
+			}
+			logRoot, err := (&types.LogRootV1{TreeSize: 1, TimestampNanos: uint64(rev), RootHash: []byte{byte(rev)}}).MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() = %v", err)
+			}
+			return tx.StoreSignedLogRoot(ctx, &trillian.SignedLogRoot{LogRoot: logRoot})
+		})
+	}
+
+	if _, err := c.CompactSubtrees(ctx, tree, 0); err == nil {
+		t.Error("CompactSubtrees(0) succeeded, want error")
+	}
+	// Revisions 3 and 4 are retained, which read the subtree at revisions 3
+	// and 4, so revisions 1 and 2 are deleted once.
+	for _, want := range []int64{2, 0} {
+		got, err := c.CompactSubtrees(ctx, tree, 2)
+		if err != nil {
+			t.Fatalf("CompactSubtrees() = %v", err)
+		}
+		if got != want {
+			t.Errorf("CompactSubtrees() = %d, want %d", got, want)
+		}
+	}
+	var revs int
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM Subtree WHERE TreeId=?", tree.TreeId).Scan(&revs); err != nil {
+		t.Fatalf("Failed to count subtrees: %v", err)
+	}
+	if revs != 2 {
+		t.Errorf("%d subtree revisions left, want 2", revs)
+	}
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		nodes, err := tx.GetMerkleNodes(ctx, []compact.NodeID{id})
+		if err != nil {
+			t.Fatalf("GetMerkleNodes() = %v", err)
+		}
+		if err := nodesAreEqual(nodes, []stree.Node{{ID: id, Hash: []byte{4}}}); err != nil {
+			t.Errorf("GetMerkleNodes(): %v", err)
+		}
+		return nil
+	})
+}
@@ -1,5 +1,6 @@
 -- Caution - this removes all tables in our schema
 
+DROP TABLE IF EXISTS TreeUsage;
 DROP TABLE IF EXISTS Unsequenced;
 DROP TABLE IF EXISTS Subtree;
 DROP TABLE IF EXISTS SequencedLeafData;
@@ -7,3 +8,4 @@ DROP TABLE IF EXISTS TreeHead;
 DROP TABLE IF EXISTS LeafData;
 DROP TABLE IF EXISTS TreeControl;
 DROP TABLE IF EXISTS Trees;
+DROP TABLE IF EXISTS SchemaMigrations;
@@ -25,6 +25,9 @@ const (
 	errNumDuplicate = 1062
 	// ER_LOCK_DEADLOCK: Error returned when there was a deadlock.
 	errNumDeadlock = 1213
+	// ER_PARSE_ERROR: Error returned for syntax errors, e.g. for syntax which
+	// isn't supported by the server version.
+	errNumParse = 1064
 )
 
 // mysqlToGRPC converts some types of MySQL errors to GRPC errors. This gives
@@ -48,3 +51,12 @@ func isDuplicateErr(err error) bool {
 		return false
 	}
 }
+
+func isParseErr(err error) bool {
+	switch err := err.(type) {
+	case *mysql.MySQLError:
+		return err.Number == errNumParse
+	default:
+		return false
+	}
+}
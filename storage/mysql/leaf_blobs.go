@@ -0,0 +1,175 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage/blob"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// leafBlobConcurrency is the maximum number of concurrent requests to the
+	// leaf blob store per transaction.
+	leafBlobConcurrency = 16
+
+	selectOffloadedLeavesSQL = `SELECT l.LeafIdentityHash,MIN(s.MerkleLeafHash)
+			FROM LeafData l LEFT JOIN SequencedLeafData s ON (l.LeafIdentityHash = s.LeafIdentityHash AND l.TreeId = s.TreeId)
+			WHERE l.TreeId = ? AND l.Offloaded AND l.LeafIdentityHash > ?
+			GROUP BY l.LeafIdentityHash
+			ORDER BY l.LeafIdentityHash LIMIT ?`
+	checkLeafBlobsBatchSize = 1000
+)
+
+// insertLeafData inserts the LeafData row of leaf. Its LeafValue and ExtraData
+// are left out of the row if the storage has a leaf blob store, in which case
+// the caller must also pass the leaf to putLeafBlobs.
+func (t *logTreeTX) insertLeafData(ctx context.Context, leaf *trillian.LogLeaf, queueTimestamp time.Time) error {
+	value, extra, offloaded := leaf.LeafValue, leaf.ExtraData, false
+	if t.ls.leafBlobs != nil {
+		value, extra, offloaded = []byte{}, nil, true
+	}
+	_, err := t.tx.ExecContext(ctx, insertLeafDataSQL, t.treeID, leaf.LeafIdentityHash, value, extra, queueTimestamp.UnixNano(), offloaded)
+	return err
+}
+
+// putLeafBlobs stores the LeafValue and ExtraData of the inserted leaves in the
+// leaf blob store, if any. It's called before the transaction inserting the
+// leaves commits, so that committed leaves always have their blobs, and only
+// for leaves which weren't already present, whose blobs mustn't be replaced.
+func (t *logTreeTX) putLeafBlobs(ctx context.Context, leaves []*trillian.LogLeaf) error {
+	if t.ls.leafBlobs == nil || len(leaves) == 0 {
+		return nil
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(leafBlobConcurrency)
+	for _, leaf := range leaves {
+		leaf := leaf
+		g.Go(func() error {
+			data, err := blob.MarshalLeaf(leaf)
+			if err != nil {
+				return err
+			}
+			key := blob.LeafKey(t.treeID, leaf.LeafIdentityHash)
+			if err := t.ls.leafBlobs.Put(gctx, key, data); err != nil {
+				return status.Errorf(codes.Unavailable, "failed to store leaf blob %s: %v", key, err)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// getLeafBlobs sets the LeafValue and ExtraData of offloaded leaves from the
+// leaf blob store.
+func (t *logTreeTX) getLeafBlobs(ctx context.Context, leaves []*trillian.LogLeaf) error {
+	if len(leaves) == 0 {
+		return nil
+	}
+	if t.ls.leafBlobs == nil {
+		return status.Errorf(codes.FailedPrecondition, "LogID: %d has leaves in a leaf blob store, but none is configured", t.treeID)
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(leafBlobConcurrency)
+	for _, leaf := range leaves {
+		leaf := leaf
+		g.Go(func() error {
+			key := blob.LeafKey(t.treeID, leaf.LeafIdentityHash)
+			data, err := t.ls.leafBlobs.Get(gctx, key)
+			if errors.Is(err, blob.ErrNotFound) {
+				return status.Errorf(codes.DataLoss, "missing leaf blob %s", key)
+			} else if err != nil {
+				return status.Errorf(codes.Unavailable, "failed to read leaf blob %s: %v", key, err)
+			}
+			return blob.UnmarshalLeaf(data, leaf)
+		})
+	}
+	return g.Wait()
+}
+
+// CheckLeafBlobs checks that every leaf of the tree written to a leaf blob
+// store has a valid blob in store, and that the LeafValue of the blob of each
+// sequenced leaf hashes to its MerkleLeafHash. The blobs are read one at a
+// time, to limit the load on the store.
+//
+// Each inconsistent leaf is passed to report, and CheckLeafBlobs returns the
+// number of leaves checked. It returns an error only if the check could not be
+// completed, e.g. because the database or the store is unavailable.
+func CheckLeafBlobs(ctx context.Context, db *sql.DB, store blob.Store, treeID int64, report func(leafIdentityHash []byte, problem error)) (int64, error) {
+	var checked int64
+	after := []byte{}
+	for {
+		leaves, err := selectOffloadedLeaves(ctx, db, treeID, after)
+		if err != nil {
+			return checked, err
+		}
+		for _, leaf := range leaves {
+			key := blob.LeafKey(treeID, leaf.LeafIdentityHash)
+			data, err := store.Get(ctx, key)
+			if err != nil && !errors.Is(err, blob.ErrNotFound) {
+				return checked, fmt.Errorf("failed to read blob %s: %v", key, err)
+			}
+			checked++
+			if err != nil {
+				report(leaf.LeafIdentityHash, fmt.Errorf("missing blob %s", key))
+				continue
+			}
+			if err := blob.UnmarshalLeaf(data, leaf); err != nil {
+				report(leaf.LeafIdentityHash, fmt.Errorf("blob %s: %v", key, err))
+				continue
+			}
+			if len(leaf.MerkleLeafHash) == 0 {
+				continue
+			}
+			if hash := rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue); !bytes.Equal(hash, leaf.MerkleLeafHash) {
+				report(leaf.LeafIdentityHash, fmt.Errorf("blob %s: leaf value hashes to %x, stored Merkle leaf hash is %x", key, hash, leaf.MerkleLeafHash))
+			}
+		}
+		if len(leaves) < checkLeafBlobsBatchSize {
+			return checked, nil
+		}
+		after = leaves[len(leaves)-1].LeafIdentityHash
+	}
+}
+
+// selectOffloadedLeaves returns the next batch of offloaded leaves of the tree,
+// in LeafIdentityHash order after the given hash, with the MerkleLeafHash of
+// those which are sequenced.
+func selectOffloadedLeaves(ctx context.Context, db *sql.DB, treeID int64, after []byte) ([]*trillian.LogLeaf, error) {
+	rows, err := db.QueryContext(ctx, selectOffloadedLeavesSQL, treeID, after, checkLeafBlobsBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var leaves []*trillian.LogLeaf
+	for rows.Next() {
+		leaf := &trillian.LogLeaf{}
+		if err := rows.Scan(&leaf.LeafIdentityHash, &leaf.MerkleLeafHash); err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, leaf)
+	}
+	return leaves, rows.Err()
+}
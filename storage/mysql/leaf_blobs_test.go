@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestLeafBlobs(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	store := blob.NewMemoryStore()
+	s := NewLogStorageWithOpts(DB, nil, LogStorageOptions{LeafBlobs: store})
+	plain := NewLogStorage(DB, nil)
+	mustSignAndStoreLogRoot(ctx, t, s, tree, 0)
+
+	leaves := createTestLeaves(5, 0)
+	var hashes [][]byte
+	for _, leaf := range leaves {
+		leaf.MerkleLeafHash = rfc6962.DefaultHasher.HashLeaf(leaf.LeafValue)
+		hashes = append(hashes, leaf.MerkleLeafHash)
+	}
+	// Leaves written before the blob store was configured stay in the database.
+	if _, err := plain.QueueLeaves(ctx, tree, leaves[:2], fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves(plain) = %v", err)
+	}
+	if _, err := s.QueueLeaves(ctx, tree, leaves[2:], fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+	if got, want := store.Len(), 3; got != want {
+		t.Errorf("Stored %d blobs, want %d", got, want)
+	}
+	var inDB int
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM LeafData WHERE TreeId=? AND LENGTH(LeafValue)>0", tree.TreeId).Scan(&inDB); err != nil {
+		t.Fatalf("Failed to count leaf values: %v", err)
+	}
+	if got, want := inDB, 2; got != want {
+		t.Errorf("Found %d leaf values in the database, want %d", got, want)
+	}
+
+	// Duplicates are returned with the values of the existing leaves.
+	dup := &trillian.LogLeaf{LeafIdentityHash: leaves[3].LeafIdentityHash, MerkleLeafHash: leaves[3].MerkleLeafHash, LeafValue: []byte("other")}
+	existing, err := s.QueueLeaves(ctx, tree, []*trillian.LogLeaf{dup}, fakeQueueTime)
+	if err != nil {
+		t.Fatalf("QueueLeaves(duplicate) = %v", err)
+	}
+	if got, want := existing[0].GetLeaf().GetLeafValue(), leaves[3].LeafValue; !bytes.Equal(got, want) {
+		t.Errorf("QueueLeaves(duplicate) returned value %q, want %q", got, want)
+	}
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		dequeued, err := tx.DequeueLeaves(ctx, len(leaves), fakeDequeueCutoffTime)
+		if err != nil {
+			t.Fatalf("DequeueLeaves() = %v", err)
+		}
+		iTimestamp := timestamppb.Now()
+		for i, l := range dequeued {
+			l.IntegrateTimestamp = iTimestamp
+			l.LeafIndex = int64(i)
+		}
+		return tx.UpdateSequencedLeaves(ctx, dequeued)
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.GetLeavesByHash(ctx, hashes, false)
+		if err != nil {
+			t.Fatalf("GetLeavesByHash() = %v", err)
+		}
+		if len(got) != len(leaves) {
+			t.Fatalf("GetLeavesByHash() returned %d leaves, want %d", len(got), len(leaves))
+		}
+		for _, leaf := range got {
+			for _, want := range leaves {
+				if bytes.Equal(leaf.LeafIdentityHash, want.LeafIdentityHash) &&
+					(!bytes.Equal(leaf.LeafValue, want.LeafValue) || !bytes.Equal(leaf.ExtraData, want.ExtraData)) {
+					t.Errorf("GetLeavesByHash() returned %q/%q, want %q/%q", leaf.LeafValue, leaf.ExtraData, want.LeafValue, want.ExtraData)
+				}
+			}
+		}
+		return nil
+	})
+	err = plain.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		_, err := tx.GetLeavesByHash(ctx, hashes[2:3], false)
+		return err
+	})
+	if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+		t.Errorf("GetLeavesByHash() without blob store: %v, want code %v", err, want)
+	}
+
+	checkLeafBlobs := func(wantProblems int) {
+		t.Helper()
+		problems := 0
+		checked, err := CheckLeafBlobs(ctx, DB, store, tree.TreeId, func(leafIdentityHash []byte, problem error) {
+			t.Logf("Leaf %x: %v", leafIdentityHash, problem)
+			problems++
+		})
+		if err != nil {
+			t.Fatalf("CheckLeafBlobs() = %v", err)
+		}
+		if got, want := checked, int64(3); got != want {
+			t.Errorf("CheckLeafBlobs() checked %d leaves, want %d", got, want)
+		}
+		if problems != wantProblems {
+			t.Errorf("CheckLeafBlobs() found %d problems, want %d", problems, wantProblems)
+		}
+	}
+	checkLeafBlobs(0)
+
+	store.Delete(blob.LeafKey(tree.TreeId, leaves[2].LeafIdentityHash))
+	corrupt, err := blob.MarshalLeaf(&trillian.LogLeaf{LeafValue: []byte("corrupt")})
+	if err != nil {
+		t.Fatalf("MarshalLeaf() = %v", err)
+	}
+	if err := store.Put(ctx, blob.LeafKey(tree.TreeId, leaves[3].LeafIdentityHash), corrupt); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	checkLeafBlobs(2)
+
+	err = s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		_, err := tx.GetLeavesByHash(ctx, hashes[2:3], false)
+		return err
+	})
+	if got, want := status.Code(err), codes.DataLoss; got != want {
+		t.Errorf("GetLeavesByHash() of missing blob: %v, want code %v", err, want)
+	}
+}
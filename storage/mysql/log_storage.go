@@ -23,12 +23,14 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/blob"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/tree"
 	"github.com/google/trillian/types"
@@ -42,8 +44,10 @@ import (
 const (
 	valuesPlaceholder5 = "(?,?,?,?,?)"
 
-	insertLeafDataSQL      = "INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData,QueueTimestampNanos) VALUES" + valuesPlaceholder5
+	insertLeafDataSQL      = "INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData,QueueTimestampNanos,Offloaded) VALUES(?,?,?,?,?,?)"
 	insertSequencedLeafSQL = "INSERT INTO SequencedLeafData(TreeId,LeafIdentityHash,MerkleLeafHash,SequenceNumber,IntegrateTimestampNanos) VALUES"
+	// insertSequencedLeafMultiSQL needs to be expanded to the number of leaves inserted.
+	insertSequencedLeafMultiSQL = insertSequencedLeafSQL + placeholderSQL
 
 	selectNonDeletedTreeIDByTypeAndStateSQL = `
 		SELECT TreeId FROM Trees
@@ -51,41 +55,61 @@ const (
 		  AND TreeState IN(?,?)
 		  AND (Deleted IS NULL OR Deleted = 'false')`
 
+	// skipLockedSQL is appended to selectQueuedLeavesSQL to lock the dequeued leaves, skipping
+	// those locked by concurrent dequeues. It requires MySQL 8.0 or MariaDB 10.6.
+	skipLockedSQL = " FOR UPDATE SKIP LOCKED"
+
+	countUnsequencedSQL  = "SELECT COUNT(*) FROM Unsequenced WHERE TreeId = ?"
+	oldestUnsequencedSQL = "SELECT MIN(QueueTimestampNanos) FROM Unsequenced WHERE TreeId = ?"
+
 	selectLatestSignedLogRootSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
 			FROM TreeHead WHERE TreeId=?
 			ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+	selectSignedLogRootAtSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash
+			FROM TreeHead WHERE TreeId=? AND TreeHeadTimestamp<=?
+			ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+	selectLatestTreeRevisionSQL = `SELECT TreeRevision
+			FROM TreeHead WHERE TreeId=?
+			ORDER BY TreeHeadTimestamp DESC LIMIT 1`
 
-	selectLeavesByRangeSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos
+	selectLeavesByRangeSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos,l.Offloaded
 			FROM LeafData l,SequencedLeafData s
 			WHERE l.LeafIdentityHash = s.LeafIdentityHash
 			AND s.SequenceNumber >= ? AND s.SequenceNumber < ? AND l.TreeId = ? AND s.TreeId = l.TreeId` + orderBySequenceNumberSQL
 
 	// These statements need to be expanded to provide the correct number of parameter placeholders.
-	selectLeavesByMerkleHashSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos
+	selectLeavesByMerkleHashSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos,l.Offloaded
 			FROM LeafData l,SequencedLeafData s
 			WHERE l.LeafIdentityHash = s.LeafIdentityHash
 			AND s.MerkleLeafHash IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+	selectLeavesByIndexSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos,l.Offloaded
+			FROM LeafData l,SequencedLeafData s
+			WHERE l.LeafIdentityHash = s.LeafIdentityHash
+			AND s.SequenceNumber IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId` + orderBySequenceNumberSQL
 	// TODO(#1548): rework the code so the dummy hash isn't needed (e.g. this assumes hash size is 32)
 	dummyMerkleLeafHash = "00000000000000000000000000000000"
 	// This statement returns a dummy Merkle leaf hash value (which must be
 	// of the right size) so that its signature matches that of the other
 	// leaf-selection statements.
-	selectLeavesByLeafIdentityHashSQL = `SELECT '` + dummyMerkleLeafHash + `',l.LeafIdentityHash,l.LeafValue,-1,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos
+	selectLeavesByLeafIdentityHashSQL = `SELECT '` + dummyMerkleLeafHash + `',l.LeafIdentityHash,l.LeafValue,-1,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos,l.Offloaded
 			FROM LeafData l LEFT JOIN SequencedLeafData s ON (l.LeafIdentityHash = s.LeafIdentityHash AND l.TreeID = s.TreeID)
 			WHERE l.LeafIdentityHash IN (` + placeholderSQL + `) AND l.TreeId = ?`
 
 	// Same as above except with leaves ordered by sequence so we only incur this cost when necessary
 	orderBySequenceNumberSQL                     = " ORDER BY s.SequenceNumber"
 	selectLeavesByMerkleHashOrderedBySequenceSQL = selectLeavesByMerkleHashSQL + orderBySequenceNumberSQL
+	selectLeavesByMerkleHashPageSQL              = selectLeavesByMerkleHashSQL +
+		" AND s.SequenceNumber >= ? AND s.SequenceNumber < ?" + orderBySequenceNumberSQL + " LIMIT ?"
 
 	logIDLabel = "logid"
 )
 
 var (
-	once             sync.Once
-	queuedCounter    monitoring.Counter
-	queuedDupCounter monitoring.Counter
-	dequeuedCounter  monitoring.Counter
+	once               sync.Once
+	queuedCounter      monitoring.Counter
+	queuedDupCounter   monitoring.Counter
+	dequeuedCounter    monitoring.Counter
+	dequeuedDupCounter monitoring.Counter
 
 	queueLatency            monitoring.Histogram
 	queueInsertLatency      monitoring.Histogram
@@ -95,12 +119,17 @@ var (
 	dequeueLatency          monitoring.Histogram
 	dequeueSelectLatency    monitoring.Histogram
 	dequeueRemoveLatency    monitoring.Histogram
+
+	replicaSnapshotCounter monitoring.Counter
+
+	treeUsageGauge monitoring.Gauge
 )
 
 func createMetrics(mf monitoring.MetricFactory) {
 	queuedCounter = mf.NewCounter("mysql_queued_leaves", "Number of leaves queued", logIDLabel)
 	queuedDupCounter = mf.NewCounter("mysql_queued_dup_leaves", "Number of duplicate leaves queued", logIDLabel)
 	dequeuedCounter = mf.NewCounter("mysql_dequeued_leaves", "Number of leaves dequeued", logIDLabel)
+	dequeuedDupCounter = mf.NewCounter("mysql_dequeued_dup_leaves", "Number of dequeued leaves dropped as duplicates of sequenced leaves", logIDLabel)
 
 	queueLatency = mf.NewHistogram("mysql_queue_leaves_latency", "Latency of queue leaves operation in seconds", logIDLabel)
 	queueInsertLatency = mf.NewHistogram("mysql_queue_leaves_latency_insert", "Latency of insertion part of queue leaves operation in seconds", logIDLabel)
@@ -111,6 +140,12 @@ func createMetrics(mf monitoring.MetricFactory) {
 	dequeueLatency = mf.NewHistogram("mysql_dequeue_leaves_latency", "Latency of dequeue leaves operation in seconds", logIDLabel)
 	dequeueSelectLatency = mf.NewHistogram("mysql_dequeue_leaves_latency_select", "Latency of selection part of dequeue leaves operation in seconds", logIDLabel)
 	dequeueRemoveLatency = mf.NewHistogram("mysql_dequeue_leaves_latency_remove", "Latency of removal part of dequeue leaves operation in seconds", logIDLabel)
+
+	replicaSnapshotCounter = mf.NewCounter("mysql_replica_snapshots", "Number of snapshots requested from the replica, by result: replica, stale or error", logIDLabel, "result")
+
+	treeUsageGauge = mf.NewGauge("mysql_tree_usage_bytes", "Approximate number of bytes stored for the tree, by kind of data: leaf_data, subtrees or queue", logIDLabel, "kind")
+
+	cache.InitMetrics(mf)
 }
 
 func labelForTX(t *logTreeTX) string {
@@ -125,14 +160,90 @@ type mySQLLogStorage struct {
 	*mySQLTreeStorage
 	admin         storage.AdminStorage
 	metricFactory monitoring.MetricFactory
+
+	// replica serves the snapshots which are at most maxStaleness stale, if set.
+	replica      *mySQLLogStorage
+	maxStaleness time.Duration
+
+	// noSkipLocked is set to 1, atomically, once the database rejects skipLockedSQL.
+	noSkipLocked int32
+
+	// queueShards is the number of shards of each time bucket of the queue, see queueBucket.
+	queueShards int
+
+	// leafBlobs, if set, stores the values and extra data of the leaves written.
+	leafBlobs blob.Store
+}
+
+// LogStorageOptions holds the optional settings of a MySQL log storage.
+type LogStorageOptions struct {
+	// Replica, if set, is a read replica of the database, which serves the snapshots returned by
+	// SnapshotForTree. Read-write transactions, and all writes, use the primary database.
+	Replica *sql.DB
+	// ReplicaMaxStaleness bounds the staleness of the snapshots served by Replica. If the latest
+	// root of a tree on the replica is older than ReplicaMaxStaleness, and isn't the latest root
+	// on the primary database, the snapshot is taken from the primary database instead.
+	ReplicaMaxStaleness time.Duration
+
+	// QueueShards is the number of shards of each minute of the queue of a tree, at most 16. Zero
+	// queues leaves in a single unsharded bucket, like older versions, which don't dequeue from
+	// the sharded buckets.
+	QueueShards int
+
+	// LeafBlobs, if set, stores the LeafValue and ExtraData of the leaves written, which are then
+	// left out of the database. Leaves are read from LeafBlobs or the database, depending on where
+	// they were written, so LeafBlobs can be set for a tree with existing leaves. It must be set to
+	// read leaves written with it.
+	LeafBlobs blob.Store
+
+	// UsageFlushInterval is the interval between the background writes of the changes to the
+	// bytes stored for each tree, which are accumulated in memory in between, so that busy trees
+	// don't have their usage written by every transaction. Zero writes them after every
+	// transaction. Storage returned by the Provider writes the pending changes on Close.
+	UsageFlushInterval time.Duration
+
+	// BulkLoadRows, if positive, is the minimum number of sequenced leaves or subtrees written by
+	// a transaction which are written with a single LOAD DATA LOCAL INFILE statement instead of
+	// multi-row INSERTs, which is faster for large sequencing batches. The server must have
+	// local_infile enabled. The leaves added by AddSequencedLeaves are still inserted one by one,
+	// to report the status of each, but the subtrees written by their integration are loaded.
+	BulkLoadRows int
 }
 
 // NewLogStorage creates a storage.LogStorage instance for the specified MySQL URL.
 // It assumes storage.AdminStorage is backed by the same MySQL database as well.
 func NewLogStorage(db *sql.DB, mf monitoring.MetricFactory) storage.LogStorage {
+	return NewLogStorageWithOpts(db, mf, LogStorageOptions{})
+}
+
+// NewLogStorageWithOpts is like NewLogStorage, with the optional settings in opts.
+func NewLogStorageWithOpts(db *sql.DB, mf monitoring.MetricFactory, opts LogStorageOptions) storage.LogStorage {
+	return newLogStorageWithUsage(db, mf, opts, newUsageTracker(db, opts.UsageFlushInterval))
+}
+
+// newLogStorageWithUsage is like NewLogStorageWithOpts, with the changes to the
+// usage of trees recorded by usage, whose UsageFlushInterval prevails.
+func newLogStorageWithUsage(db *sql.DB, mf monitoring.MetricFactory, opts LogStorageOptions, usage *usageTracker) *mySQLLogStorage {
 	if mf == nil {
 		mf = monitoring.InertMetricFactory{}
 	}
+	ls := newLogStorage(db, mf)
+	ls.usage = usage
+	ls.queueShards = opts.QueueShards
+	ls.leafBlobs = opts.LeafBlobs
+	ls.bulkLoadRows = opts.BulkLoadRows
+	if ls.queueShards > maxQueueShards {
+		ls.queueShards = maxQueueShards
+	}
+	if opts.Replica != nil {
+		ls.replica = newLogStorage(opts.Replica, mf)
+		ls.replica.leafBlobs = opts.LeafBlobs
+		ls.maxStaleness = opts.ReplicaMaxStaleness
+	}
+	return ls
+}
+
+func newLogStorage(db *sql.DB, mf monitoring.MetricFactory) *mySQLLogStorage {
 	return &mySQLLogStorage{
 		admin:            NewAdminStorage(db),
 		mySQLTreeStorage: newTreeStorage(db),
@@ -152,6 +263,14 @@ func (m *mySQLLogStorage) getLeavesByMerkleHashStmt(ctx context.Context, num int
 	return m.getStmt(ctx, selectLeavesByMerkleHashSQL, num, "?", "?")
 }
 
+func (m *mySQLLogStorage) getLeavesByMerkleHashPageStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	return m.getStmt(ctx, selectLeavesByMerkleHashPageSQL, num, "?", "?")
+}
+
+func (m *mySQLLogStorage) getLeavesByIndexStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	return m.getStmt(ctx, selectLeavesByIndexSQL, num, "?", "?")
+}
+
 func (m *mySQLLogStorage) getLeavesByLeafIdentityHashStmt(ctx context.Context, num int) (*sql.Stmt, error) {
 	return m.getStmt(ctx, selectLeavesByLeafIdentityHashSQL, num, "?", "?")
 }
@@ -178,12 +297,33 @@ func (m *mySQLLogStorage) GetActiveLogIDs(ctx context.Context) ([]int64, error)
 	return ids, rows.Err()
 }
 
+// CountUnsequenced implements storage.UnsequencedCounter.
+func (m *mySQLLogStorage) CountUnsequenced(ctx context.Context, tree *trillian.Tree) (int64, error) {
+	var count int64
+	if err := m.db.QueryRowContext(ctx, countUnsequencedSQL, tree.TreeId).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// OldestUnsequenced implements storage.UnsequencedAger.
+func (m *mySQLLogStorage) OldestUnsequenced(ctx context.Context, tree *trillian.Tree) (time.Time, error) {
+	var oldest sql.NullInt64
+	if err := m.db.QueryRowContext(ctx, oldestUnsequencedSQL, tree.TreeId).Scan(&oldest); err != nil {
+		return time.Time{}, err
+	}
+	if !oldest.Valid {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, oldest.Int64), nil
+}
+
 func (m *mySQLLogStorage) beginInternal(ctx context.Context, tree *trillian.Tree) (*logTreeTX, error) {
 	once.Do(func() {
 		createMetrics(m.metricFactory)
 	})
 
-	stCache := cache.NewLogSubtreeCache(rfc6962.DefaultHasher)
+	stCache := cache.NewLogSubtreeCacheWithOpts(rfc6962.DefaultHasher, cache.Options{TreeID: tree.TreeId})
 	ttx, err := m.beginTreeTx(ctx, tree, rfc6962.DefaultHasher.Size(), stCache)
 	if err != nil && err != storage.ErrTreeNeedsInit {
 		return nil, err
@@ -250,6 +390,11 @@ func (m *mySQLLogStorage) AddSequencedLeaves(ctx context.Context, tree *trillian
 }
 
 func (m *mySQLLogStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
+	if m.replica != nil {
+		if tx := m.replicaSnapshot(ctx, tree); tx != nil {
+			return tx, nil
+		}
+	}
 	tx, err := m.beginInternal(ctx, tree)
 	if err != nil && err != storage.ErrTreeNeedsInit {
 		return nil, err
@@ -257,6 +402,38 @@ func (m *mySQLLogStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tr
 	return tx, err
 }
 
+// replicaSnapshot returns a snapshot of tree taken from the replica, or nil if it fails or is
+// stale, in which case the snapshot should be taken from the primary database.
+func (m *mySQLLogStorage) replicaSnapshot(ctx context.Context, tree *trillian.Tree) *logTreeTX {
+	label := strconv.FormatInt(tree.TreeId, 10)
+	tx, err := m.replica.beginInternal(ctx, tree)
+	if err != nil {
+		if tx != nil {
+			tx.Close()
+		}
+		result := "stale" // The tree may only be initialized on the primary yet.
+		if err != storage.ErrTreeNeedsInit {
+			glog.Warningf("%v: failed to read from the replica, reading from the primary: %v", tree.TreeId, err)
+			result = "error"
+		}
+		replicaSnapshotCounter.Inc(label, result)
+		return nil
+	}
+	if age := time.Since(time.Unix(0, int64(tx.root.TimestampNanos))); age <= m.maxStaleness {
+		replicaSnapshotCounter.Inc(label, "replica")
+		return tx
+	}
+	// The latest root on the replica may be old only because the tree hasn't changed since.
+	var rev int64
+	if err := m.db.QueryRowContext(ctx, selectLatestTreeRevisionSQL, tree.TreeId).Scan(&rev); err == nil && rev == tx.readRev {
+		replicaSnapshotCounter.Inc(label, "replica")
+		return tx
+	}
+	tx.Close()
+	replicaSnapshotCounter.Inc(label, "stale")
+	return nil
+}
+
 func (m *mySQLLogStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
 	tx, err := m.beginInternal(ctx, tree)
 	if tx != nil {
@@ -300,6 +477,39 @@ type logTreeTX struct {
 	dequeued map[string]dequeuedLeaf
 }
 
+// insertSequencedLeaves inserts the SequencedLeafData rows of leaves, with multi-row
+// statements of at most leafBatchRows rows, or loads them if there are enough of them.
+func (t *logTreeTX) insertSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) error {
+	if t.ls.bulkLoad(len(leaves)) {
+		return t.loadSequencedLeaves(ctx, leaves)
+	}
+	for _, n := range batchSizes(len(leaves), leafBatchRows) {
+		args := make([]interface{}, 0, 5*n)
+		for _, leaf := range leaves[:n] {
+			if err := leaf.IntegrateTimestamp.CheckValid(); err != nil {
+				return fmt.Errorf("got invalid integrate timestamp: %w", err)
+			}
+			args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex, leaf.IntegrateTimestamp.AsTime().UnixNano())
+		}
+		leaves = leaves[n:]
+
+		tmpl, err := t.ls.getStmt(ctx, insertSequencedLeafMultiSQL, n, valuesPlaceholder5, valuesPlaceholder5)
+		if err != nil {
+			return err
+		}
+		stx := t.tx.StmtContext(ctx, tmpl)
+		result, err := stx.ExecContext(ctx, args...)
+		stx.Close()
+		if err != nil {
+			glog.Warningf("Failed to update sequenced leaves: %s", err)
+		}
+		if err := checkResultOkAndRowCountIs(result, err, int64(n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetMerkleNodes returns the requested nodes at the read revision.
 func (t *logTreeTX) GetMerkleNodes(ctx context.Context, ids []compact.NodeID) ([]tree.Node, error) {
 	t.treeTX.mu.Lock()
@@ -318,7 +528,7 @@ func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime tim
 	}
 
 	start := time.Now()
-	stx, err := t.tx.PrepareContext(ctx, selectQueuedLeavesSQL)
+	stx, err := t.prepareDequeue(ctx)
 	if err != nil {
 		glog.Warningf("Failed to prepare dequeue select: %s", err)
 		return nil, err
@@ -326,7 +536,7 @@ func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime tim
 	defer stx.Close()
 
 	leaves := make([]*trillian.LogLeaf, 0, limit)
-	rows, err := stx.QueryContext(ctx, t.treeID, cutoffTime.UnixNano(), limit)
+	rows, err := stx.QueryContext(ctx, t.treeID, maxQueueBucket(cutoffTime), cutoffTime.UnixNano(), limit)
 	if err != nil {
 		glog.Warningf("Failed to select rows for work: %s", err)
 		return nil, err
@@ -356,6 +566,11 @@ func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime tim
 	if rows.Err() != nil {
 		return nil, rows.Err()
 	}
+	// The connection must be released before the next statements of the transaction.
+	rows.Close()
+	if leaves, err = t.dropSequencedLeaves(ctx, leaves); err != nil {
+		return nil, err
+	}
 	label := labelForTX(t)
 	observe(dequeueSelectLatency, time.Since(start), label)
 	observe(dequeueLatency, time.Since(start), label)
@@ -364,6 +579,22 @@ func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime tim
 	return leaves, nil
 }
 
+// prepareDequeue prepares the statement selecting the leaves to dequeue. The
+// statement locks the leaves with SKIP LOCKED, so that concurrent dequeues
+// from the same tree skip each other's leaves instead of waiting for them,
+// unless the database doesn't support it, in which case they aren't locked.
+func (t *logTreeTX) prepareDequeue(ctx context.Context) (*sql.Stmt, error) {
+	if atomic.LoadInt32(&t.ls.noSkipLocked) == 0 {
+		stx, err := t.tx.PrepareContext(ctx, selectQueuedLeavesSQL+skipLockedSQL)
+		if !isParseErr(err) {
+			return stx, err
+		}
+		glog.Warningf("Database doesn't support SKIP LOCKED, dequeuing leaves without locking them: %v", err)
+		atomic.StoreInt32(&t.ls.noSkipLocked, 1)
+	}
+	return t.tx.PrepareContext(ctx, selectQueuedLeavesSQL)
+}
+
 // sortLeavesForInsert returns a slice containing the passed in leaves sorted
 // by LeafIdentityHash, and paired with their original positions.
 // QueueLeaves and AddSequencedLeaves use this to make the order that LeafData
@@ -381,64 +612,15 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 	t.treeTX.mu.Lock()
 	defer t.treeTX.mu.Unlock()
 
-	// Don't accept batches if any of the leaves are invalid.
-	for _, leaf := range leaves {
-		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
-			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.hashSizeBytes)
-		}
-		leaf.QueueTimestamp = timestamppb.New(queueTimestamp)
-		if err := leaf.QueueTimestamp.CheckValid(); err != nil {
-			return nil, fmt.Errorf("got invalid queue timestamp: %w", err)
-		}
+	if err := t.setQueueTimestamps(leaves, queueTimestamp); err != nil {
+		return nil, err
 	}
 	start := time.Now()
 	label := labelForTX(t)
 
-	ordLeaves := sortLeavesForInsert(leaves)
-	existingCount := 0
-	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
-
-	for _, ol := range ordLeaves {
-		i, leaf := ol.idx, ol.leaf
-
-		leafStart := time.Now()
-		if err := leaf.QueueTimestamp.CheckValid(); err != nil {
-			return nil, fmt.Errorf("got invalid queue timestamp: %w", err)
-		}
-		qTimestamp := leaf.QueueTimestamp.AsTime()
-		_, err := t.tx.ExecContext(ctx, insertLeafDataSQL, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, qTimestamp.UnixNano())
-		insertDuration := time.Since(leafStart)
-		observe(queueInsertLeafLatency, insertDuration, label)
-		if isDuplicateErr(err) {
-			// Remember the duplicate leaf, using the requested leaf for now.
-			existingLeaves[i] = leaf
-			existingCount++
-			queuedDupCounter.Inc(label)
-			continue
-		}
-		if err != nil {
-			glog.Warningf("Error inserting %d into LeafData: %s", i, err)
-			return nil, mysqlToGRPC(err)
-		}
-
-		// Create the work queue entry
-		args := []interface{}{
-			t.treeID,
-			leaf.LeafIdentityHash,
-			leaf.MerkleLeafHash,
-		}
-		args = append(args, queueArgs(t.treeID, leaf.LeafIdentityHash, qTimestamp)...)
-		_, err = t.tx.ExecContext(
-			ctx,
-			insertUnsequencedEntrySQL,
-			args...,
-		)
-		if err != nil {
-			glog.Warningf("Error inserting into Unsequenced: %s", err)
-			return nil, mysqlToGRPC(err)
-		}
-		leafDuration := time.Since(leafStart)
-		observe(queueInsertEntryLatency, (leafDuration - insertDuration), label)
+	existingLeaves, existingCount, err := t.insertQueuedLeaves(ctx, leaves)
+	if err != nil {
+		return nil, err
 	}
 	insertDuration := time.Since(start)
 	observe(queueInsertLatency, insertDuration, label)
@@ -487,6 +669,88 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 	return existingLeaves, nil
 }
 
+// setQueueTimestamps sets the QueueTimestamp of leaves, after checking that
+// they're all valid, so that batches with invalid leaves aren't accepted.
+func (t *logTreeTX) setQueueTimestamps(leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.hashSizeBytes)
+		}
+		leaf.QueueTimestamp = timestamppb.New(queueTimestamp)
+		if err := leaf.QueueTimestamp.CheckValid(); err != nil {
+			return fmt.Errorf("got invalid queue timestamp: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertQueuedLeaves inserts the LeafData rows and queue entries of leaves, one
+// leaf at a time. It returns a slice holding the requested leaf at the
+// position of each leaf which was already present, and nil elsewhere, with the
+// number of such leaves.
+func (t *logTreeTX) insertQueuedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, int, error) {
+	label := labelForTX(t)
+	ordLeaves := sortLeavesForInsert(leaves)
+	existingCount := 0
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	var newLeaves []*trillian.LogLeaf
+
+	for _, ol := range ordLeaves {
+		i, leaf := ol.idx, ol.leaf
+
+		leafStart := time.Now()
+		if err := leaf.QueueTimestamp.CheckValid(); err != nil {
+			return nil, 0, fmt.Errorf("got invalid queue timestamp: %w", err)
+		}
+		qTimestamp := leaf.QueueTimestamp.AsTime()
+		err := t.insertLeafData(ctx, leaf, qTimestamp)
+		insertDuration := time.Since(leafStart)
+		observe(queueInsertLeafLatency, insertDuration, label)
+		if isDuplicateErr(err) {
+			// Remember the duplicate leaf, using the requested leaf for now.
+			existingLeaves[i] = leaf
+			existingCount++
+			queuedDupCounter.Inc(label)
+			continue
+		}
+		if err != nil {
+			glog.Warningf("Error inserting %d into LeafData: %s", i, err)
+			return nil, 0, mysqlToGRPC(err)
+		}
+
+		// Create the work queue entry
+		_, err = t.tx.ExecContext(
+			ctx,
+			insertUnsequencedEntrySQL,
+			t.unsequencedArgs(leaf, qTimestamp)...,
+		)
+		if err != nil {
+			glog.Warningf("Error inserting into Unsequenced: %s", err)
+			return nil, 0, mysqlToGRPC(err)
+		}
+		t.usage.LeafDataBytes += leafDataBytes(leaf)
+		t.usage.QueueBytes += queueEntryBytes(leaf)
+		leafDuration := time.Since(leafStart)
+		observe(queueInsertEntryLatency, (leafDuration - insertDuration), label)
+		newLeaves = append(newLeaves, leaf)
+	}
+	if err := t.putLeafBlobs(ctx, newLeaves); err != nil {
+		return nil, 0, err
+	}
+	return existingLeaves, existingCount, nil
+}
+
+// unsequencedArgs returns the values of the columns of the queue entry of leaf.
+func (t *logTreeTX) unsequencedArgs(leaf *trillian.LogLeaf, queueTimestamp time.Time) []interface{} {
+	args := []interface{}{
+		t.treeID,
+		t.ls.queueBucket(queueTimestamp),
+		leaf.LeafIdentityHash,
+		leaf.MerkleLeafHash,
+	}
+	return append(args, queueArgs(t.treeID, leaf.LeafIdentityHash, queueTimestamp)...)
+}
+
 func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
 	t.treeTX.mu.Lock()
 	defer t.treeTX.mu.Unlock()
@@ -511,6 +775,7 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 	// supplied in contiguous non-intersecting batches, the chance of having
 	// circular dependencies between transactions is significantly lower.
 	ordLeaves := sortLeavesForInsert(leaves)
+	var newLeaves []*trillian.LogLeaf
 	for _, ol := range ordLeaves {
 		i, leaf := ol.idx, ol.leaf
 
@@ -527,8 +792,7 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 		res[i] = &trillian.QueuedLogLeaf{Status: ok}
 
 		// TODO(pavelkalinnikov): Measure latencies.
-		_, err := t.tx.ExecContext(ctx, insertLeafDataSQL,
-			t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, timestamp.UnixNano())
+		err := t.insertLeafData(ctx, leaf, timestamp)
 		// TODO(pavelkalinnikov): Detach PREORDERED_LOG integration latency metric.
 
 		// TODO(pavelkalinnikov): Support opting out from duplicates detection.
@@ -554,6 +818,9 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 		} else if err != nil {
 			glog.Errorf("Error inserting leaves[%d] into SequencedLeafData: %s", i, err)
 			return nil, mysqlToGRPC(err)
+		} else {
+			newLeaves = append(newLeaves, leaf)
+			t.usage.LeafDataBytes += leafDataBytes(leaf) + sequencedLeafBytes(leaf)
 		}
 
 		// TODO(pavelkalinnikov): Load LeafData for conflicting entries.
@@ -563,6 +830,9 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 		glog.Errorf("Error releasing savepoint: %s", err)
 		return nil, mysqlToGRPC(err)
 	}
+	if err := t.putLeafBlobs(ctx, newLeaves); err != nil {
+		return nil, err
+	}
 
 	return res, nil
 }
@@ -604,9 +874,11 @@ func (t *logTreeTX) getLeavesByRangeInternal(ctx context.Context, start, count i
 	defer rows.Close()
 
 	ret := make([]*trillian.LogLeaf, 0, count)
+	var offloaded []*trillian.LogLeaf
 	for wantIndex := start; rows.Next(); wantIndex++ {
 		leaf := &trillian.LogLeaf{}
 		var qTimestamp, iTimestamp int64
+		var isOffloaded bool
 		if err := rows.Scan(
 			&leaf.MerkleLeafHash,
 			&leaf.LeafIdentityHash,
@@ -614,7 +886,8 @@ func (t *logTreeTX) getLeavesByRangeInternal(ctx context.Context, start, count i
 			&leaf.LeafIndex,
 			&leaf.ExtraData,
 			&qTimestamp,
-			&iTimestamp); err != nil {
+			&iTimestamp,
+			&isOffloaded); err != nil {
 			glog.Warningf("Failed to scan merkle leaves: %s", err)
 			return nil, err
 		}
@@ -632,12 +905,18 @@ func (t *logTreeTX) getLeavesByRangeInternal(ctx context.Context, start, count i
 		if err := leaf.IntegrateTimestamp.CheckValid(); err != nil {
 			return nil, fmt.Errorf("got invalid integrate timestamp: %w", err)
 		}
+		if isOffloaded {
+			offloaded = append(offloaded, leaf)
+		}
 		ret = append(ret, leaf)
 	}
 	if err := rows.Err(); err != nil {
 		glog.Warningf("Failed to read returned leaves: %s", err)
 		return nil, err
 	}
+	if err := t.getLeafBlobs(ctx, offloaded); err != nil {
+		return nil, err
+	}
 
 	return ret, nil
 }
@@ -654,6 +933,39 @@ func (t *logTreeTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, or
 	return t.getLeavesByHashInternal(ctx, leafHashes, tmpl, "merkle")
 }
 
+// GetLeavesByHashPage implements storage.LeafByHashPager.
+func (t *logTreeTX) GetLeavesByHashPage(ctx context.Context, leafHashes [][]byte, start, end int64, limit int) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	tmpl, err := t.ls.getLeavesByMerkleHashPageStmt(ctx, len(leafHashes))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(leafHashes)+4)
+	for _, hash := range leafHashes {
+		args = append(args, hash)
+	}
+	args = append(args, t.treeID, start, end, limit)
+	return t.queryLeaves(ctx, args, tmpl, "merkle hash page")
+}
+
+// GetLeavesByIndex implements storage.SparseLeafReader.
+func (t *logTreeTX) GetLeavesByIndex(ctx context.Context, indices []int64) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	tmpl, err := t.ls.getLeavesByIndexStmt(ctx, len(indices))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(indices)+1)
+	for _, index := range indices {
+		args = append(args, index)
+	}
+	return t.queryLeaves(ctx, append(args, t.treeID), tmpl, "index")
+}
+
 // getLeafDataByIdentityHash retrieves leaf data by LeafIdentityHash, returned
 // as a slice of LogLeaf objects for convenience.  However, note that the
 // returned LogLeaf objects will not have a valid MerkleLeafHash, LeafIndex, or IntegrateTimestamp.
@@ -676,6 +988,32 @@ func (t *logTreeTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLo
 	return t.slr, nil
 }
 
+// SignedLogRootAt implements storage.RootAtTimeReader.
+func (t *logTreeTX) SignedLogRootAt(ctx context.Context, ts time.Time) (*trillian.SignedLogRoot, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	var timestamp, treeSize int64
+	var rootHash []byte
+	if err := t.tx.QueryRowContext(ctx, selectSignedLogRootAtSQL, t.treeID, ts.UnixNano()).Scan(
+		&timestamp, &treeSize, &rootHash,
+	); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	logRoot, err := (&types.LogRootV1{
+		RootHash:       rootHash,
+		TimestampNanos: uint64(timestamp),
+		TreeSize:       uint64(treeSize),
+	}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.SignedLogRoot{LogRoot: logRoot}, nil
+}
+
 // fetchLatestRoot reads the latest root and the revision from the DB.
 func (t *logTreeTX) fetchLatestRoot(ctx context.Context) (*trillian.SignedLogRoot, int64, error) {
 	var timestamp, treeSize, treeRevision int64
@@ -731,23 +1069,29 @@ func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, root *trillian.Signe
 }
 
 func (t *logTreeTX) getLeavesByHashInternal(ctx context.Context, leafHashes [][]byte, tmpl *sql.Stmt, desc string) ([]*trillian.LogLeaf, error) {
-	stx := t.tx.StmtContext(ctx, tmpl)
-	defer stx.Close()
-
 	var args []interface{}
 	for _, hash := range leafHashes {
 		args = append(args, []byte(hash))
 	}
 	args = append(args, t.treeID)
+	return t.queryLeaves(ctx, args, tmpl, desc+" hash")
+}
+
+// queryLeaves runs one of the leaf-selection statements with args, and scans
+// the leaves it returns.
+func (t *logTreeTX) queryLeaves(ctx context.Context, args []interface{}, tmpl *sql.Stmt, desc string) ([]*trillian.LogLeaf, error) {
+	stx := t.tx.StmtContext(ctx, tmpl)
+	defer stx.Close()
+
 	rows, err := stx.QueryContext(ctx, args...)
 	if err != nil {
-		glog.Warningf("Query() %s hash = %v", desc, err)
+		glog.Warningf("Query() %s = %v", desc, err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	// The tree could include duplicates so we don't know how many results will be returned
-	var ret []*trillian.LogLeaf
+	var ret, offloaded []*trillian.LogLeaf
 	for rows.Next() {
 		leaf := &trillian.LogLeaf{}
 		// We might be using a LEFT JOIN in our statement, so leaves which are
@@ -757,8 +1101,9 @@ func (t *logTreeTX) getLeavesByHashInternal(ctx context.Context, leafHashes [][]
 		// check its validity below.
 		var integrateTS sql.NullInt64
 		var queueTS int64
+		var isOffloaded bool
 
-		if err := rows.Scan(&leaf.MerkleLeafHash, &leaf.LeafIdentityHash, &leaf.LeafValue, &leaf.LeafIndex, &leaf.ExtraData, &queueTS, &integrateTS); err != nil {
+		if err := rows.Scan(&leaf.MerkleLeafHash, &leaf.LeafIdentityHash, &leaf.LeafValue, &leaf.LeafIndex, &leaf.ExtraData, &queueTS, &integrateTS, &isOffloaded); err != nil {
 			glog.Warningf("LogID: %d Scan() %s = %s", t.treeID, desc, err)
 			return nil, err
 		}
@@ -777,12 +1122,18 @@ func (t *logTreeTX) getLeavesByHashInternal(ctx context.Context, leafHashes [][]
 			return nil, fmt.Errorf("LogID: %d Scanned leaf %s does not have hash length %d, got %d", t.treeID, desc, want, got)
 		}
 
+		if isOffloaded {
+			offloaded = append(offloaded, leaf)
+		}
 		ret = append(ret, leaf)
 	}
 	if err := rows.Err(); err != nil {
 		glog.Warningf("Failed to read returned leaves: %s", err)
 		return nil, err
 	}
+	if err := t.getLeafBlobs(ctx, offloaded); err != nil {
+		return nil, err
+	}
 
 	return ret, nil
 }
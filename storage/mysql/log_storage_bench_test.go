@@ -0,0 +1,40 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian/integration/storagebench"
+	"github.com/google/trillian/storage"
+)
+
+// benchProvider is a storage.Provider of the test database, which it cleans
+// rather than closes.
+type benchProvider struct {
+	mysqlProvider
+}
+
+func (p *benchProvider) Close() error {
+	cleanTestDB(p.db)
+	return nil
+}
+
+func BenchmarkLogStorage(b *testing.B) {
+	storagebench.RunLogStorageBenchmarks(b, func(context.Context, *testing.B) storage.Provider {
+		return &benchProvider{mysqlProvider{db: DB}}
+	})
+}
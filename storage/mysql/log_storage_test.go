@@ -21,6 +21,7 @@ import (
 	"database/sql"
 	"fmt"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,7 +37,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
-var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees"}
+var allTables = []string{"TreeUsage", "Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees"}
 
 // Must be 32 bytes to match sha256 length if it was a real hash
 var (
@@ -398,6 +399,163 @@ func TestDequeueLeavesTimeOrdering(t *testing.T) {
 	}
 }
 
+func TestDequeueLeavesSkipLocked(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil).(*mySQLLogStorage)
+	mustSignAndStoreLogRoot(ctx, t, s, tree, 0)
+
+	if _, err := s.QueueLeaves(ctx, tree, createTestLeaves(4, 0), fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+
+	// Dequeue concurrently, as two signers would during a mastership change.
+	tx1, err := s.beginInternal(ctx, tree)
+	if err != nil {
+		t.Fatalf("beginInternal(1st) = %v", err)
+	}
+	defer tx1.Close()
+	dequeue1, err := tx1.DequeueLeaves(ctx, 2, fakeDequeueCutoffTime)
+	if err != nil {
+		t.Fatalf("DequeueLeaves(1st) = %v", err)
+	}
+	if atomic.LoadInt32(&s.noSkipLocked) != 0 {
+		t.Skip("MySQL server doesn't support SKIP LOCKED")
+	}
+
+	tx2, err := s.beginInternal(ctx, tree)
+	if err != nil {
+		t.Fatalf("beginInternal(2nd) = %v", err)
+	}
+	defer tx2.Close()
+	dequeue2, err := tx2.DequeueLeaves(ctx, 4, fakeDequeueCutoffTime)
+	if err != nil {
+		t.Fatalf("DequeueLeaves(2nd) = %v", err)
+	}
+	if got, want := len(dequeue2), 2; got != want {
+		t.Fatalf("Dequeued %d leaves while others are locked, want %d", got, want)
+	}
+	ensureAllLeavesDistinct(append(dequeue1, dequeue2...), t)
+}
+
+func TestDequeueLeavesShardedQueue(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+	sharded := NewLogStorageWithOpts(DB, nil, LogStorageOptions{QueueShards: 4})
+	mustSignAndStoreLogRoot(ctx, t, s, tree, 0)
+
+	// Queue leaves in the unsharded layout, as before sharding was enabled, then
+	// in shards of a later time bucket.
+	queueTime := queueEpoch.Add(time.Hour)
+	leaves := createTestLeaves(3, 0)
+	leaves2 := createTestLeaves(20, 3)
+	if _, err := s.QueueLeaves(ctx, tree, leaves, queueTime.Add(time.Minute)); err != nil {
+		t.Fatalf("QueueLeaves(unsharded) = %v", err)
+	}
+	if _, err := sharded.QueueLeaves(ctx, tree, leaves2, queueTime); err != nil {
+		t.Fatalf("QueueLeaves(sharded) = %v", err)
+	}
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		dequeued, err := tx.DequeueLeaves(ctx, len(leaves), queueTime.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("DequeueLeaves(1st) = %v", err)
+		}
+		// The unsharded leaves are dequeued first, even though they were queued later.
+		for _, l := range dequeued {
+			if !leafInBatch(l, leaves) {
+				t.Errorf("Got leaf from wrong batch (1st dequeue): %v", l)
+			}
+		}
+		iTimestamp := timestamppb.Now()
+		for i, l := range dequeued {
+			l.IntegrateTimestamp = iTimestamp
+			l.LeafIndex = int64(i)
+		}
+		return tx.UpdateSequencedLeaves(ctx, dequeued)
+	})
+
+	// Sharded leaves are dequeued whatever the number of shards of the reader.
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if dequeued, err := tx.DequeueLeaves(ctx, 100, queueTime.Add(-time.Minute)); err != nil {
+			t.Fatalf("DequeueLeaves(before cutoff) = %v", err)
+		} else if len(dequeued) != 0 {
+			t.Errorf("Dequeued %d leaves queued after the cutoff, want 0", len(dequeued))
+		}
+		dequeued, err := tx.DequeueLeaves(ctx, 100, queueTime.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("DequeueLeaves(2nd) = %v", err)
+		}
+		if got, want := len(dequeued), len(leaves2); got != want {
+			t.Fatalf("Dequeued %d leaves, want %d", got, want)
+		}
+		ensureAllLeavesDistinct(dequeued, t)
+		iTimestamp := timestamppb.Now()
+		for i, l := range dequeued {
+			l.IntegrateTimestamp = iTimestamp
+			l.LeafIndex = int64(len(leaves) + i)
+		}
+		return tx.UpdateSequencedLeaves(ctx, dequeued)
+	})
+}
+
+func TestUpdateSequencedLeavesMultipleBatches(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+	mustSignAndStoreLogRoot(ctx, t, s, tree, 0)
+
+	// Take several multi-row statements to write, including partial ones.
+	count := leafBatchRows + leafBatchRows/2 + 3
+	leaves := createTestLeaves(int64(count), 0)
+	if _, err := s.QueueLeaves(ctx, tree, leaves, fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		dequeued, err := tx.DequeueLeaves(ctx, count, fakeDequeueCutoffTime)
+		if err != nil {
+			t.Fatalf("DequeueLeaves() = %v", err)
+		}
+		if got, want := len(dequeued), count; got != want {
+			t.Fatalf("Dequeued %d leaves, want %d", got, want)
+		}
+		iTimestamp := timestamppb.Now()
+		for i, l := range dequeued {
+			l.IntegrateTimestamp = iTimestamp
+			l.LeafIndex = int64(i)
+		}
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			t.Fatalf("UpdateSequencedLeaves(): %v", err)
+		}
+		return nil
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		dequeued, err := tx.DequeueLeaves(ctx, count, fakeDequeueCutoffTime)
+		if err != nil {
+			t.Fatalf("DequeueLeaves() = %v", err)
+		}
+		if len(dequeued) != 0 {
+			t.Errorf("Dequeued %d leaves after sequencing them all, want 0", len(dequeued))
+		}
+		return nil
+	})
+	var sequenced int
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM SequencedLeafData WHERE TreeId = ?", tree.TreeId).Scan(&sequenced); err != nil {
+		t.Fatalf("Failed to count sequenced leaves: %v", err)
+	}
+	if sequenced != count {
+		t.Errorf("Got %d sequenced leaves, want %d", sequenced, count)
+	}
+}
+
 func TestGetLeavesByHashNotPresent(t *testing.T) {
 	ctx := context.Background()
 	cleanTestDB(DB)
@@ -475,6 +633,42 @@ func TestGetLeavesByHashBigBatch(t *testing.T) {
 	})
 }
 
+func TestGetLeavesByIndex(t *testing.T) {
+	ctx := context.Background()
+
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+
+	for i := int64(0); i < 5; i++ {
+		data := []byte(fmt.Sprintf("data %d", i))
+		hash := sha256.Sum256(data)
+		createFakeLeaf(ctx, DB, tree.TreeId, hash[:], hash[:], data, someExtraData, sequenceNumber+i, t)
+	}
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		r, ok := tx.(storage.SparseLeafReader)
+		if !ok {
+			t.Fatalf("%T doesn't implement storage.SparseLeafReader", tx)
+		}
+		want := []int64{sequenceNumber, sequenceNumber + 2, sequenceNumber + 4}
+		leaves, err := r.GetLeavesByIndex(ctx, append(want, sequenceNumber+10))
+		if err != nil {
+			t.Fatalf("Unexpected error getting leaves by index: %v", err)
+		}
+		if len(leaves) != len(want) {
+			t.Fatalf("Got %d leaves, expected %d", len(leaves), len(want))
+		}
+		for i, leaf := range leaves {
+			data := []byte(fmt.Sprintf("data %d", want[i]-sequenceNumber))
+			hash := sha256.Sum256(data)
+			checkLeafContents(leaf, want[i], hash[:], hash[:], data, someExtraData, t)
+		}
+		return nil
+	})
+}
+
 func TestGetLeafDataByIdentityHash(t *testing.T) {
 	ctx := context.Background()
 
@@ -610,6 +804,54 @@ func TestLatestSignedLogRoot(t *testing.T) {
 	}
 }
 
+func TestSignedLogRootAt(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+
+	var roots []*trillian.SignedLogRoot
+	for i, ts := range []uint64{1000, 2000} {
+		root, err := SignLogRoot(&types.LogRootV1{
+			TimestampNanos: ts,
+			TreeSize:       uint64(16 * (i + 1)),
+			RootHash:       []byte(dummyHash),
+		})
+		if err != nil {
+			t.Fatalf("SignLogRoot(): %v", err)
+		}
+		runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+				t.Fatalf("Failed to store signed root: %v", err)
+			}
+			return nil
+		})
+		roots = append(roots, root)
+	}
+
+	for _, test := range []struct {
+		ts   int64
+		want *trillian.SignedLogRoot
+	}{
+		{ts: 999, want: nil},
+		{ts: 1000, want: roots[0]},
+		{ts: 1999, want: roots[0]},
+		{ts: 5000, want: roots[1]},
+	} {
+		runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			got, err := tx.(storage.RootAtTimeReader).SignedLogRootAt(ctx, time.Unix(0, test.ts))
+			if err != nil {
+				t.Fatalf("SignedLogRootAt(%d): %v", test.ts, err)
+			}
+			if !proto.Equal(got, test.want) {
+				t.Errorf("SignedLogRootAt(%d): <%v>, want: <%v>", test.ts, got, test.want)
+			}
+			return nil
+		})
+	}
+}
+
 func TestDuplicateSignedLogRoot(t *testing.T) {
 	ctx := context.Background()
 	cleanTestDB(DB)
@@ -682,6 +924,67 @@ func TestLogRootUpdate(t *testing.T) {
 	})
 }
 
+func TestSnapshotForTreeReplica(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	tree := mustCreateTree(ctx, t, NewAdminStorage(DB), testonly.LogTree)
+
+	// The replica is a separate database, to which roots are copied explicitly.
+	replica, done := openTestDBOrDie()
+	defer done(ctx)
+	var primaryName string
+	if err := DB.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&primaryName); err != nil {
+		t.Fatalf("Failed to read the database name: %v", err)
+	}
+	if _, err := replica.ExecContext(ctx, fmt.Sprintf("INSERT INTO Trees SELECT * FROM %s.Trees WHERE TreeId = ?", primaryName), tree.TreeId); err != nil {
+		t.Fatalf("Failed to copy the tree to the replica: %v", err)
+	}
+
+	storeRoot := func(db *sql.DB, size uint64, hash []byte, timestamp time.Time) {
+		t.Helper()
+		root, err := SignLogRoot(&types.LogRootV1{TimestampNanos: uint64(timestamp.UnixNano()), TreeSize: size, RootHash: hash})
+		if err != nil {
+			t.Fatalf("SignLogRoot(): %v", err)
+		}
+		runLogTX(NewLogStorage(db, nil), tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			return tx.StoreSignedLogRoot(ctx, root)
+		})
+	}
+	s := NewLogStorageWithOpts(DB, nil, LogStorageOptions{Replica: replica, ReplicaMaxStaleness: time.Minute})
+	checkSnapshot := func(desc string, wantSize uint64, wantHash []byte) {
+		t.Helper()
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("%s: SnapshotForTree(): %v", desc, err)
+		}
+		defer tx.Close()
+		slr, err := tx.LatestSignedLogRoot(ctx)
+		if err != nil {
+			t.Fatalf("%s: LatestSignedLogRoot(): %v", desc, err)
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+			t.Fatalf("%s: UnmarshalBinary(): %v", desc, err)
+		}
+		if root.TreeSize != wantSize || !bytes.Equal(root.RootHash, wantHash) {
+			t.Errorf("%s: got root of size %d with hash %x, want size %d with hash %x", desc, root.TreeSize, root.RootHash, wantSize, wantHash)
+		}
+	}
+
+	// The roots of the primary and of the replica have different hashes, to tell which
+	// database the snapshot is taken from.
+	old := time.Now().Add(-time.Hour)
+	storeRoot(DB, 1, dummyHash, old)
+	storeRoot(replica, 1, dummyHash2, old)
+	checkSnapshot("old but latest root", 1, dummyHash2)
+
+	storeRoot(DB, 2, dummyHash, old.Add(time.Second))
+	checkSnapshot("stale replica", 2, dummyHash)
+
+	storeRoot(replica, 2, dummyHash2, time.Now())
+	checkSnapshot("fresh replica", 2, dummyHash2)
+}
+
 func TestGetActiveLogIDs(t *testing.T) {
 	ctx := context.Background()
 
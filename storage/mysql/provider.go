@@ -15,13 +15,18 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"flag"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/mysql/schema"
 
 	// Load MySQL driver
 	_ "github.com/go-sql-driver/mysql"
@@ -32,6 +37,20 @@ var (
 	maxConns = flag.Int("mysql_max_conns", 0, "Maximum connections to the database")
 	maxIdle  = flag.Int("mysql_max_idle_conns", -1, "Maximum idle database connections in the connection pool")
 
+	autoMigrate = flag.Bool("auto_migrate", false, "If true, upgrade the MySQL schema to the version used by this binary on startup, instead of refusing to start when they differ")
+
+	replicaURI          = flag.String("mysql_replica_uri", "", "If set, connection URI for a read replica of the MySQL database, which serves the read-only snapshots of logs. Writes and read-write transactions use --mysql_uri")
+	replicaMaxStaleness = flag.Duration("mysql_replica_max_staleness", time.Minute, "Maximum age of the latest root of a log read from --mysql_replica_uri, unless it's also the latest root on --mysql_uri. Staler snapshots are read from --mysql_uri")
+
+	// LeafBlobURL is exported for tools checking the leaf blobs written by servers.
+	LeafBlobURL = flag.String("mysql_leaf_blob_url", "", "If set, URL of an object store holding the values and extra data of the leaves written, e.g. gs://bucket/prefix, s3://bucket/prefix or azblob://container/prefix, instead of the database. The binary must include the store for the scheme of the URL")
+
+	queueShards = flag.Int("mysql_queue_shards", 0, "Number of shards, up to 16, of each minute of the queue of a log, which spread concurrent writes to the queue. 0 keeps all queued leaves in a single bucket, which is the only one read by older versions")
+
+	bulkLoadRows = flag.Int("mysql_bulk_load_rows", 0, "If set, the minimum number of sequenced leaves or Merkle subtrees written by a transaction which are written with LOAD DATA LOCAL INFILE instead of INSERTs, which requires local_infile to be enabled on the server. 0 always uses INSERTs")
+
+	usageFlushInterval = flag.Duration("mysql_usage_flush_interval", 10*time.Second, "Interval between the background writes of the bytes stored for each tree to the database. Pending changes are also written when the storage is closed")
+
 	mysqlMu              sync.Mutex
 	mysqlErr             error
 	mysqlDB              *sql.DB
@@ -55,49 +74,104 @@ func init() {
 }
 
 type mysqlProvider struct {
-	db *sql.DB
-	mf monitoring.MetricFactory
+	db        *sql.DB
+	replicaDB *sql.DB
+	leafBlobs blob.Store
+	usage     *usageTracker
+	mf        monitoring.MetricFactory
 }
 
 func newMySQLStorageProvider(mf monitoring.MetricFactory) (storage.Provider, error) {
 	mysqlMu.Lock()
 	defer mysqlMu.Unlock()
 	if mysqlStorageInstance == nil {
+		if *queueShards < 0 || *queueShards > maxQueueShards {
+			return nil, fmt.Errorf("--mysql_queue_shards=%d, want 0 to %d", *queueShards, maxQueueShards)
+		}
 		db, err := getMySQLDatabaseLocked()
 		if err != nil {
 			return nil, err
 		}
+		if err := checkSchema(context.TODO(), db); err != nil {
+			return nil, err
+		}
+		var replicaDB *sql.DB
+		if *replicaURI != "" {
+			if replicaDB, err = openDBWithLimits(*replicaURI); err != nil {
+				return nil, err
+			}
+		}
+		var leafBlobs blob.Store
+		if *LeafBlobURL != "" {
+			if leafBlobs, err = blob.Open(context.TODO(), *LeafBlobURL); err != nil {
+				return nil, err
+			}
+		}
 		mysqlStorageInstance = &mysqlProvider{
-			db: db,
-			mf: mf,
+			db:        db,
+			replicaDB: replicaDB,
+			leafBlobs: leafBlobs,
+			usage:     newUsageTracker(db, *usageFlushInterval),
+			mf:        mf,
 		}
 	}
 	return mysqlStorageInstance, nil
 }
 
+// checkSchema returns an error unless the schema of db is at the version used
+// by this binary, after upgrading it if --auto_migrate is set.
+func checkSchema(ctx context.Context, db *sql.DB) error {
+	if *autoMigrate {
+		if err := schema.Migrate(ctx, db, schema.LatestVersion()); err != nil {
+			return fmt.Errorf("failed to migrate MySQL schema: %v", err)
+		}
+	}
+	if err := schema.Check(ctx, db); err != nil {
+		return fmt.Errorf("incompatible MySQL schema: %w", err)
+	}
+	return nil
+}
+
 // getMySQLDatabaseLocked returns an instance of MySQL database, or creates
 // one. Requires mysqlMu to be locked.
 func getMySQLDatabaseLocked() (*sql.DB, error) {
 	if mysqlDB != nil || mysqlErr != nil {
 		return mysqlDB, mysqlErr
 	}
-	db, err := OpenDB(*mySQLURI)
+	db, err := openDBWithLimits(*mySQLURI)
 	if err != nil {
 		mysqlErr = err
 		return nil, err
 	}
+	mysqlDB, mysqlErr = db, nil
+	return db, nil
+}
+
+// openDBWithLimits opens a database, and applies the connection pool limits
+// set by the flags to it.
+func openDBWithLimits(uri string) (*sql.DB, error) {
+	db, err := OpenDB(uri)
+	if err != nil {
+		return nil, err
+	}
 	if *maxConns > 0 {
 		db.SetMaxOpenConns(*maxConns)
 	}
 	if *maxIdle >= 0 {
 		db.SetMaxIdleConns(*maxIdle)
 	}
-	mysqlDB, mysqlErr = db, nil
 	return db, nil
 }
 
 func (s *mysqlProvider) LogStorage() storage.LogStorage {
-	return NewLogStorage(s.db, s.mf)
+	return newLogStorageWithUsage(s.db, s.mf, LogStorageOptions{
+		Replica:             s.replicaDB,
+		ReplicaMaxStaleness: *replicaMaxStaleness,
+		QueueShards:         *queueShards,
+		LeafBlobs:           s.leafBlobs,
+		UsageFlushInterval:  *usageFlushInterval,
+		BulkLoadRows:        *bulkLoadRows,
+	}, s.usage)
 }
 
 func (s *mysqlProvider) AdminStorage() storage.AdminStorage {
@@ -105,5 +179,17 @@ func (s *mysqlProvider) AdminStorage() storage.AdminStorage {
 }
 
 func (s *mysqlProvider) Close() error {
+	// Write the pending usage changes while the database is open.
+	s.usage.close()
+	if s.replicaDB != nil {
+		if err := s.replicaDB.Close(); err != nil {
+			glog.Warningf("Failed to close the replica database: %v", err)
+		}
+	}
+	if s.leafBlobs != nil {
+		if err := s.leafBlobs.Close(); err != nil {
+			glog.Warningf("Failed to close the leaf blob store: %v", err)
+		}
+	}
 	return s.db.Close()
 }
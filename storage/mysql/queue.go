@@ -31,32 +31,40 @@ import (
 
 const (
 	// If this statement ORDER BY clause is changed refer to the comment in removeSequencedLeaves
-	selectQueuedLeavesSQL = `SELECT LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos
+	selectQueuedLeavesSQL = `SELECT LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos,Bucket
 			FROM Unsequenced
 			WHERE TreeID=?
-			AND Bucket=0
+			AND Bucket<=?
 			AND QueueTimestampNanos<=?
-			ORDER BY QueueTimestampNanos,LeafIdentityHash ASC LIMIT ?`
+			ORDER BY Bucket,QueueTimestampNanos,LeafIdentityHash ASC LIMIT ?`
 	insertUnsequencedEntrySQL = `INSERT INTO Unsequenced(TreeId,Bucket,LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos)
-			VALUES(?,0,?,?,?)`
-	deleteUnsequencedSQL = "DELETE FROM Unsequenced WHERE TreeId=? AND Bucket=0 AND QueueTimestampNanos=? AND LeafIdentityHash=?"
+			VALUES(?,?,?,?,?)`
+	// insertUnsequencedMultiSQL needs to be expanded to the number of leaves queued, with
+	// unsequencedPlaceholder. Entries which are already queued are left as they are.
+	insertUnsequencedMultiSQL = `INSERT INTO Unsequenced(TreeId,Bucket,LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos)
+			VALUES` + placeholderSQL + ` ON DUPLICATE KEY UPDATE TreeId=TreeId`
+	unsequencedPlaceholder = "(?,?,?,?,?)"
+	// deleteUnsequencedSQL needs to be expanded to the number of leaves deleted.
+	deleteUnsequencedSQL = "DELETE FROM Unsequenced WHERE TreeId=? AND (Bucket,QueueTimestampNanos,LeafIdentityHash) IN (" + placeholderSQL + ")"
 )
 
 type dequeuedLeaf struct {
+	bucket              int64
 	queueTimestampNanos int64
 	leafIdentityHash    []byte
 }
 
-func dequeueInfo(leafIDHash []byte, queueTimestamp int64) dequeuedLeaf {
-	return dequeuedLeaf{queueTimestampNanos: queueTimestamp, leafIdentityHash: leafIDHash}
+func dequeueInfo(leafIDHash []byte, bucket, queueTimestamp int64) dequeuedLeaf {
+	return dequeuedLeaf{bucket: bucket, queueTimestampNanos: queueTimestamp, leafIdentityHash: leafIDHash}
 }
 
 func (t *logTreeTX) dequeueLeaf(rows *sql.Rows) (*trillian.LogLeaf, dequeuedLeaf, error) {
 	var leafIDHash []byte
 	var merkleHash []byte
 	var queueTimestamp int64
+	var bucket int64
 
-	err := rows.Scan(&leafIDHash, &merkleHash, &queueTimestamp)
+	err := rows.Scan(&leafIDHash, &merkleHash, &queueTimestamp, &bucket)
 	if err != nil {
 		glog.Warningf("Error scanning work rows: %s", err)
 		return nil, dequeuedLeaf{}, err
@@ -74,7 +82,7 @@ func (t *logTreeTX) dequeueLeaf(rows *sql.Rows) (*trillian.LogLeaf, dequeuedLeaf
 		MerkleLeafHash:   merkleHash,
 		QueueTimestamp:   queueTimestampProto,
 	}
-	return leaf, dequeueInfo(leafIDHash, queueTimestamp), nil
+	return leaf, dequeueInfo(leafIDHash, bucket, queueTimestamp), nil
 }
 
 func queueArgs(_ int64, _ []byte, queueTimestamp time.Time) []interface{} {
@@ -89,31 +97,25 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 			return errors.New("sequenced leaf has incorrect hash size")
 		}
 
-		if err := leaf.IntegrateTimestamp.CheckValid(); err != nil {
-			return fmt.Errorf("got invalid integrate timestamp: %w", err)
-		}
-		iTimestamp := leaf.IntegrateTimestamp.AsTime()
-		_, err := t.tx.ExecContext(
-			ctx,
-			insertSequencedLeafSQL+valuesPlaceholder5,
-			t.treeID,
-			leaf.LeafIdentityHash,
-			leaf.MerkleLeafHash,
-			leaf.LeafIndex,
-			iTimestamp.UnixNano())
-		if err != nil {
-			glog.Warningf("Failed to update sequenced leaves: %s", err)
-			return err
-		}
-
 		qe, ok := t.dequeued[string(leaf.LeafIdentityHash)]
 		if !ok {
 			return fmt.Errorf("attempting to update leaf that wasn't dequeued. IdentityHash: %x", leaf.LeafIdentityHash)
 		}
 		dequeuedLeaves = append(dequeuedLeaves, qe)
 	}
+	if err := t.insertSequencedLeaves(ctx, leaves); err != nil {
+		return err
+	}
 
-	return t.removeSequencedLeaves(ctx, dequeuedLeaves)
+	if err := t.removeSequencedLeaves(ctx, dequeuedLeaves); err != nil {
+		return err
+	}
+	t.accountSequencedLeaves(leaves)
+	return nil
+}
+
+func (m *mySQLLogStorage) getDeleteUnsequencedStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	return m.getStmt(ctx, deleteUnsequencedSQL, num, "(?,?,?)", "(?,?,?)")
 }
 
 // removeSequencedLeaves removes the passed in leaves slice (which may be
@@ -123,16 +125,23 @@ func (t *logTreeTX) removeSequencedLeaves(ctx context.Context, leaves []dequeued
 	// Don't need to re-sort because the query ordered by leaf hash. If that changes because
 	// the query is expensive then the sort will need to be done here. See comment in
 	// QueueLeaves.
-	stx, err := t.tx.PrepareContext(ctx, deleteUnsequencedSQL)
-	if err != nil {
-		glog.Warningf("Failed to prep delete statement for sequenced work: %v", err)
-		return err
-	}
-	defer stx.Close()
-	for _, dql := range leaves {
-		result, err := stx.ExecContext(ctx, t.treeID, dql.queueTimestampNanos, dql.leafIdentityHash)
-		err = checkResultOkAndRowCountIs(result, err, int64(1))
+	for _, n := range batchSizes(len(leaves), leafBatchRows) {
+		tmpl, err := t.ls.getDeleteUnsequencedStmt(ctx, n)
 		if err != nil {
+			glog.Warningf("Failed to prep delete statement for sequenced work: %v", err)
+			return err
+		}
+		args := make([]interface{}, 0, 1+3*n)
+		args = append(args, t.treeID)
+		for _, dql := range leaves[:n] {
+			args = append(args, dql.bucket, dql.queueTimestampNanos, dql.leafIdentityHash)
+		}
+		leaves = leaves[n:]
+
+		stx := t.tx.StmtContext(ctx, tmpl)
+		result, err := stx.ExecContext(ctx, args...)
+		stx.Close()
+		if err := checkResultOkAndRowCountIs(result, err, int64(n)); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,202 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+)
+
+const (
+	// insertLeafDataMultiSQL needs to be expanded to the number of leaves queued. The rows of
+	// leaves which are already present are left as they are.
+	insertLeafDataMultiSQL = "INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData,QueueTimestampNanos,Offloaded) VALUES" +
+		placeholderSQL + " ON DUPLICATE KEY UPDATE TreeId=TreeId"
+	valuesPlaceholder6 = "(?,?,?,?,?,?)"
+
+	// selectSequencedLeafIdentityHashesSQL needs to be expanded to the number of leaves looked up.
+	selectSequencedLeafIdentityHashesSQL = "SELECT LeafIdentityHash FROM SequencedLeafData WHERE TreeId=? AND LeafIdentityHash IN (" + placeholderSQL + ")"
+)
+
+// QueueLeavesAsync implements storage.AsyncLeafQueuer.
+func (m *mySQLLogStorage) QueueLeavesAsync(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	tx, err := m.beginInternal(ctx, tree)
+	if tx != nil {
+		defer tx.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.queueLeavesAsync(ctx, leaves, queueTimestamp); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// queueLeavesAsync inserts the LeafData rows and queue entries of leaves with multi-row
+// statements, which leave the rows of existing leaves as they are. Duplicate leaves are thus
+// queued again without being looked up, and are dropped by DequeueLeaves once the original
+// leaves are sequenced.
+func (t *logTreeTX) queueLeavesAsync(ctx context.Context, leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	if err := t.setQueueTimestamps(leaves, queueTimestamp); err != nil {
+		return err
+	}
+	start := time.Now()
+	label := labelForTX(t)
+
+	if t.ls.leafBlobs != nil {
+		// The blobs of existing leaves mustn't be replaced, so the leaves are inserted one at a
+		// time to find out which are new.
+		if _, _, err := t.insertQueuedLeaves(ctx, leaves); err != nil {
+			return err
+		}
+	} else {
+		// The rows are inserted in LeafIdentityHash order, like in QueueLeaves.
+		ordLeaves := sortLeavesForInsert(leaves)
+		sorted := make([]*trillian.LogLeaf, len(ordLeaves))
+		for i, ol := range ordLeaves {
+			sorted[i] = ol.leaf
+		}
+		if err := t.insertLeafDataMulti(ctx, sorted, queueTimestamp); err != nil {
+			return err
+		}
+		if err := t.insertUnsequencedMulti(ctx, sorted, queueTimestamp); err != nil {
+			return err
+		}
+		// Duplicates of existing leaves are counted as new leaves, as they aren't looked up.
+		for _, leaf := range leaves {
+			t.usage.LeafDataBytes += leafDataBytes(leaf)
+			t.usage.QueueBytes += queueEntryBytes(leaf)
+		}
+	}
+	observe(queueInsertLatency, time.Since(start), label)
+	queuedCounter.Add(float64(len(leaves)), label)
+	return nil
+}
+
+func (t *logTreeTX) insertLeafDataMulti(ctx context.Context, leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	for _, n := range batchSizes(len(leaves), leafBatchRows) {
+		args := make([]interface{}, 0, 6*n)
+		for _, leaf := range leaves[:n] {
+			args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, queueTimestamp.UnixNano(), false)
+		}
+		leaves = leaves[n:]
+
+		tmpl, err := t.ls.getStmt(ctx, insertLeafDataMultiSQL, n, valuesPlaceholder6, valuesPlaceholder6)
+		if err != nil {
+			return err
+		}
+		stx := t.tx.StmtContext(ctx, tmpl)
+		_, err = stx.ExecContext(ctx, args...)
+		stx.Close()
+		if err != nil {
+			glog.Warningf("Error inserting into LeafData: %s", err)
+			return mysqlToGRPC(err)
+		}
+	}
+	return nil
+}
+
+func (t *logTreeTX) insertUnsequencedMulti(ctx context.Context, leaves []*trillian.LogLeaf, queueTimestamp time.Time) error {
+	for _, n := range batchSizes(len(leaves), leafBatchRows) {
+		var args []interface{}
+		for _, leaf := range leaves[:n] {
+			args = append(args, t.unsequencedArgs(leaf, queueTimestamp)...)
+		}
+		leaves = leaves[n:]
+
+		tmpl, err := t.ls.getStmt(ctx, insertUnsequencedMultiSQL, n, unsequencedPlaceholder, unsequencedPlaceholder)
+		if err != nil {
+			return err
+		}
+		stx := t.tx.StmtContext(ctx, tmpl)
+		_, err = stx.ExecContext(ctx, args...)
+		stx.Close()
+		if err != nil {
+			glog.Warningf("Error inserting into Unsequenced: %s", err)
+			return mysqlToGRPC(err)
+		}
+	}
+	return nil
+}
+
+// dropSequencedLeaves removes the dequeued leaves which are already sequenced from leaves, and
+// deletes their queue entries. Such leaves are duplicates queued by QueueLeavesAsync.
+func (t *logTreeTX) dropSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	sequenced := make(map[string]bool)
+	rest := leaves
+	for _, n := range batchSizes(len(rest), leafBatchRows) {
+		args := make([]interface{}, 0, 1+n)
+		args = append(args, t.treeID)
+		for _, leaf := range rest[:n] {
+			args = append(args, leaf.LeafIdentityHash)
+		}
+		rest = rest[n:]
+
+		if err := t.selectSequencedLeafIdentityHashes(ctx, n, args, sequenced); err != nil {
+			return nil, err
+		}
+	}
+	if len(sequenced) == 0 {
+		return leaves, nil
+	}
+
+	kept := make([]*trillian.LogLeaf, 0, len(leaves)-len(sequenced))
+	dups := make([]dequeuedLeaf, 0, len(sequenced))
+	for _, leaf := range leaves {
+		k := string(leaf.LeafIdentityHash)
+		if !sequenced[k] {
+			kept = append(kept, leaf)
+			continue
+		}
+		dups = append(dups, t.dequeued[k])
+		delete(t.dequeued, k)
+		t.usage.QueueBytes -= queueEntryBytes(leaf)
+	}
+	if err := t.removeSequencedLeaves(ctx, dups); err != nil {
+		return nil, err
+	}
+	dequeuedDupCounter.Add(float64(len(dups)), labelForTX(t))
+	return kept, nil
+}
+
+func (t *logTreeTX) selectSequencedLeafIdentityHashes(ctx context.Context, num int, args []interface{}, sequenced map[string]bool) error {
+	tmpl, err := t.ls.getStmt(ctx, selectSequencedLeafIdentityHashesSQL, num, "?", "?")
+	if err != nil {
+		return err
+	}
+	stx := t.tx.StmtContext(ctx, tmpl)
+	defer stx.Close()
+	rows, err := stx.QueryContext(ctx, args...)
+	if err != nil {
+		glog.Warningf("Failed to select sequenced leaves: %s", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return err
+		}
+		sequenced[string(hash)] = true
+	}
+	return rows.Err()
+}
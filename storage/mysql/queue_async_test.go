@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestQueueLeavesAsync(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+	q := s.(storage.AsyncLeafQueuer)
+	mustSignAndStoreLogRoot(ctx, t, s, tree, 0)
+
+	leaves := createTestLeaves(3, 0)
+	if err := q.QueueLeavesAsync(ctx, tree, leaves, fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeavesAsync() = %v", err)
+	}
+	// A duplicate with another value and queue time is queued again, keeping the original value.
+	dup := &trillian.LogLeaf{LeafIdentityHash: leaves[0].LeafIdentityHash, MerkleLeafHash: leaves[0].MerkleLeafHash, LeafValue: []byte("other")}
+	if err := q.QueueLeavesAsync(ctx, tree, []*trillian.LogLeaf{dup}, fakeQueueTime.Add(time.Second)); err != nil {
+		t.Fatalf("QueueLeavesAsync(duplicate) = %v", err)
+	}
+	// Queueing a leaf again at the same time leaves its queue entry as it is.
+	if err := q.QueueLeavesAsync(ctx, tree, leaves[1:2], fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeavesAsync(same entry) = %v", err)
+	}
+	checkUnsequenced := func(want int64) {
+		t.Helper()
+		got, err := s.(storage.UnsequencedCounter).CountUnsequenced(ctx, tree)
+		if err != nil {
+			t.Fatalf("CountUnsequenced() = %v", err)
+		}
+		if got != want {
+			t.Errorf("CountUnsequenced() = %d, want %d", got, want)
+		}
+	}
+	checkUnsequenced(4)
+
+	sequence := func(want int) {
+		t.Helper()
+		runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			dequeued, err := tx.DequeueLeaves(ctx, 99, fakeDequeueCutoffTime)
+			if err != nil {
+				t.Fatalf("DequeueLeaves() = %v", err)
+			}
+			if len(dequeued) != want {
+				t.Fatalf("DequeueLeaves() returned %d leaves, want %d", len(dequeued), want)
+			}
+			iTimestamp := timestamppb.Now()
+			for i, l := range dequeued {
+				l.IntegrateTimestamp = iTimestamp
+				l.LeafIndex = int64(i)
+			}
+			return tx.UpdateSequencedLeaves(ctx, dequeued)
+		})
+	}
+	// The duplicate is left in the queue, as its original is dequeued in the same batch.
+	sequence(3)
+	checkUnsequenced(1)
+	// Then it's dropped, as its original is sequenced.
+	sequence(0)
+	checkUnsequenced(0)
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.GetLeavesByHash(ctx, [][]byte{leaves[0].MerkleLeafHash}, false)
+		if err != nil {
+			t.Fatalf("GetLeavesByHash() = %v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("GetLeavesByHash() returned %d leaves, want 1", len(got))
+		}
+		if !bytes.Equal(got[0].LeafValue, leaves[0].LeafValue) {
+			t.Errorf("GetLeavesByHash() returned value %q, want %q", got[0].LeafValue, leaves[0].LeafValue)
+		}
+		return nil
+	})
+}
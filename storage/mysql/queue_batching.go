@@ -23,7 +23,6 @@ import (
 	"database/sql"
 	"encoding/binary"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -36,11 +35,16 @@ const (
 	selectQueuedLeavesSQL = `SELECT LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos,QueueID
 			FROM Unsequenced
 			WHERE TreeID=?
-			AND Bucket=0
+			AND Bucket<=?
 			AND QueueTimestampNanos<=?
-			ORDER BY QueueTimestampNanos,LeafIdentityHash ASC LIMIT ?`
-	insertUnsequencedEntrySQL = `INSERT INTO Unsequenced(TreeId,Bucket,LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos,QueueID) VALUES(?,0,?,?,?,?)`
+			ORDER BY Bucket,QueueTimestampNanos,LeafIdentityHash ASC LIMIT ?`
+	insertUnsequencedEntrySQL = `INSERT INTO Unsequenced(TreeId,Bucket,LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos,QueueID) VALUES(?,?,?,?,?,?)`
 	deleteUnsequencedSQL      = "DELETE FROM Unsequenced WHERE QueueID IN (<placeholder>)"
+	// insertUnsequencedMultiSQL needs to be expanded to the number of leaves queued, with
+	// unsequencedPlaceholder. Entries which are already queued are left as they are.
+	insertUnsequencedMultiSQL = `INSERT INTO Unsequenced(TreeId,Bucket,LeafIdentityHash,MerkleLeafHash,QueueTimestampNanos,QueueID)
+			VALUES` + placeholderSQL + ` ON DUPLICATE KEY UPDATE TreeId=TreeId`
+	unsequencedPlaceholder = "(?,?,?,?,?,?)"
 )
 
 type dequeuedLeaf []byte
@@ -94,31 +98,23 @@ func queueArgs(treeID int64, identityHash []byte, queueTimestamp time.Time) []in
 }
 
 func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) error {
-	querySuffix := []string{}
-	args := []interface{}{}
 	dequeuedLeaves := make([]dequeuedLeaf, 0, len(leaves))
 	for _, leaf := range leaves {
-		if err := leaf.IntegrateTimestamp.CheckValid(); err != nil {
-			return fmt.Errorf("got invalid integrate timestamp: %w", err)
-		}
-		iTimestamp := leaf.IntegrateTimestamp.AsTime()
-		querySuffix = append(querySuffix, valuesPlaceholder5)
-		args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex, iTimestamp.UnixNano())
 		qe, ok := t.dequeued[string(leaf.LeafIdentityHash)]
 		if !ok {
 			return fmt.Errorf("attempting to update leaf that wasn't dequeued. IdentityHash: %x", leaf.LeafIdentityHash)
 		}
 		dequeuedLeaves = append(dequeuedLeaves, qe)
 	}
-	result, err := t.tx.ExecContext(ctx, insertSequencedLeafSQL+strings.Join(querySuffix, ","), args...)
-	if err != nil {
-		glog.Warningf("Failed to update sequenced leaves: %s", err)
-	}
-	if err := checkResultOkAndRowCountIs(result, err, int64(len(leaves))); err != nil {
+	if err := t.insertSequencedLeaves(ctx, leaves); err != nil {
 		return err
 	}
 
-	return t.removeSequencedLeaves(ctx, dequeuedLeaves)
+	if err := t.removeSequencedLeaves(ctx, dequeuedLeaves); err != nil {
+		return err
+	}
+	t.accountSequencedLeaves(leaves)
+	return nil
 }
 
 func (m *mySQLLogStorage) getDeleteUnsequencedStmt(ctx context.Context, num int) (*sql.Stmt, error) {
@@ -131,20 +127,28 @@ func (t *logTreeTX) removeSequencedLeaves(ctx context.Context, queueIDs []dequeu
 	// Don't need to re-sort because the query ordered by leaf hash. If that changes because
 	// the query is expensive then the sort will need to be done here. See comment in
 	// QueueLeaves.
-	tmpl, err := t.ls.getDeleteUnsequencedStmt(ctx, len(queueIDs))
-	if err != nil {
-		glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
-		return err
-	}
-	stx := t.tx.StmtContext(ctx, tmpl)
-	args := make([]interface{}, len(queueIDs))
-	for i, q := range queueIDs {
-		args[i] = []byte(q)
-	}
-	result, err := stx.ExecContext(ctx, args...)
-	if err != nil {
-		// Error is handled by checkResultOkAndRowCountIs() below
-		glog.Warningf("Failed to delete sequenced work: %s", err)
+	for _, n := range batchSizes(len(queueIDs), leafBatchRows) {
+		tmpl, err := t.ls.getDeleteUnsequencedStmt(ctx, n)
+		if err != nil {
+			glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
+			return err
+		}
+		args := make([]interface{}, n)
+		for i, q := range queueIDs[:n] {
+			args[i] = []byte(q)
+		}
+		queueIDs = queueIDs[n:]
+
+		stx := t.tx.StmtContext(ctx, tmpl)
+		result, err := stx.ExecContext(ctx, args...)
+		stx.Close()
+		if err != nil {
+			// Error is handled by checkResultOkAndRowCountIs() below
+			glog.Warningf("Failed to delete sequenced work: %s", err)
+		}
+		if err := checkResultOkAndRowCountIs(result, err, int64(n)); err != nil {
+			return err
+		}
 	}
-	return checkResultOkAndRowCountIs(result, err, int64(len(queueIDs)))
+	return nil
 }
@@ -0,0 +1,62 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"math/rand"
+	"time"
+)
+
+// The Bucket column of the Unsequenced table shards the queue of each tree by
+// time bucket and random shard, so that concurrent inserts are spread across
+// the primary key instead of all appending to its end. Bucket 0 holds the
+// leaves queued with the unsharded layout, and the buckets of a time bucket
+// follow those of the earlier ones, so dequeuing in primary key order scans the
+// leaves of the oldest time buckets first. Readers don't depend on the number
+// of shards, so that it can be changed, or sharding enabled, online.
+const (
+	// queueBucketWidth is the duration covered by a time bucket.
+	queueBucketWidth = time.Minute
+	// maxQueueShards is the maximum number of shards of a time bucket.
+	maxQueueShards = 16
+)
+
+// queueEpoch is the start of the first time bucket. It keeps the buckets of
+// the next centuries within the range of the INTEGER Bucket column.
+var queueEpoch = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// queueBucket returns the bucket of a leaf queued at ts in the given shard.
+func queueBucket(ts time.Time, shard int) int64 {
+	tb := int64(ts.Sub(queueEpoch)/queueBucketWidth) + 1
+	if tb < 1 {
+		tb = 1
+	}
+	return tb*maxQueueShards + int64(shard)
+}
+
+// maxQueueBucket returns the last bucket which may hold leaves queued at or
+// before ts.
+func maxQueueBucket(ts time.Time) int64 {
+	return queueBucket(ts, maxQueueShards-1)
+}
+
+// queueBucket returns the bucket of a leaf queued at ts, in a random shard, or
+// 0 if the queue isn't sharded.
+func (m *mySQLLogStorage) queueBucket(ts time.Time) int64 {
+	if m.queueShards <= 0 {
+		return 0
+	}
+	return queueBucket(ts, rand.Intn(m.queueShards))
+}
@@ -0,0 +1,347 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema holds versioned migrations of the MySQL schema of Trillian,
+// and applies them to databases.
+//
+// The version of the schema of a database is recorded in its SchemaMigrations
+// table. storage.sql creates the schema at the latest version directly, and
+// must be kept in sync with the migrations in the migrations directory.
+package schema
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// lockName is the name of the MySQL lock held while migrating, so that
+	// concurrently starting servers don't apply the same migrations.
+	lockName        = "trillian_schema_migrations"
+	lockTimeoutSecs = 60
+
+	createVersionTableSQL = `CREATE TABLE IF NOT EXISTS SchemaMigrations(
+  Version           INTEGER NOT NULL,
+  Dirty             BOOLEAN NOT NULL,
+  AppliedTimeMillis BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+)`
+	versionTableExistsSQL = `SELECT COUNT(*) FROM information_schema.tables
+WHERE table_schema = DATABASE() AND table_name = 'SchemaMigrations'`
+	countTablesSQL   = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE()"
+	selectVersionSQL = "SELECT Version, Dirty FROM SchemaMigrations ORDER BY Version DESC LIMIT 1"
+	insertVersionSQL = "INSERT INTO SchemaMigrations(Version, Dirty, AppliedTimeMillis) VALUES(?, ?, ?)"
+	forceVersionSQL  = "INSERT INTO SchemaMigrations(Version, Dirty, AppliedTimeMillis) VALUES(?, FALSE, ?) ON DUPLICATE KEY UPDATE Dirty = FALSE"
+	cleanVersionSQL  = "UPDATE SchemaMigrations SET Dirty = FALSE WHERE Version = ?"
+	dirtyVersionSQL  = "UPDATE SchemaMigrations SET Dirty = TRUE WHERE Version = ?"
+	deleteVersionSQL = "DELETE FROM SchemaMigrations WHERE Version > ?"
+)
+
+// ErrUnversioned is returned for databases without a schema version,
+// such as databases created before schema versioning.
+var ErrUnversioned = errors.New("database has no schema version")
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	migrations      = mustLoadMigrations(migrationFiles)
+	migrationFileRE = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+// Migration is a versioned change to the schema.
+type Migration struct {
+	Version int
+	Name    string
+	// Up holds the statements applying the migration, and Down the
+	// statements reverting it.
+	Up, Down []string
+}
+
+// Migrations returns the migrations of the schema, in increasing version
+// order starting from 1.
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// LatestVersion returns the version of the schema expected by this binary.
+func LatestVersion() int {
+	return len(migrations)
+}
+
+func mustLoadMigrations(fsys fs.FS) []Migration {
+	ms, err := loadMigrations(fsys)
+	if err != nil {
+		panic(fmt.Sprintf("invalid schema migrations: %v", err))
+	}
+	return ms
+}
+
+// loadMigrations reads the migrations in the migrations directory of fsys,
+// held in files called <version>_<name>.up.sql and <version>_<name>.down.sql.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		m := migrationFileRE.FindStringSubmatch(path.Base(file))
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q: %v", file, err)
+		}
+		script, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is called both %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = splitStatements(string(script))
+		} else {
+			mig.Down = splitStatements(string(script))
+		}
+	}
+
+	ms := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		ms = append(ms, *mig)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	for i, mig := range ms {
+		if mig.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if len(mig.Up) == 0 || len(mig.Down) == 0 {
+			return nil, fmt.Errorf("migration %d must have up and down statements", mig.Version)
+		}
+	}
+	return ms, nil
+}
+
+// splitStatements returns the statements of an SQL script, without comments.
+func splitStatements(script string) []string {
+	buf := &bytes.Buffer{}
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "--") {
+			continue // skip empty lines and comments
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(buf.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// queryer is implemented by sql.DB and sql.Conn.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Version returns the schema version of db, and whether a migration to or
+// from that version failed half-way, leaving the schema dirty. It returns
+// ErrUnversioned if db has no schema version.
+func Version(ctx context.Context, db *sql.DB) (int, bool, error) {
+	return version(ctx, db)
+}
+
+func version(ctx context.Context, q queryer) (int, bool, error) {
+	var tables int
+	if err := q.QueryRowContext(ctx, versionTableExistsSQL).Scan(&tables); err != nil {
+		return 0, false, err
+	}
+	if tables == 0 {
+		return 0, false, ErrUnversioned
+	}
+	var v int
+	var dirty bool
+	switch err := q.QueryRowContext(ctx, selectVersionSQL).Scan(&v, &dirty); {
+	case err == sql.ErrNoRows:
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+	return v, dirty, nil
+}
+
+// Check returns an error unless db has a clean schema at LatestVersion.
+func Check(ctx context.Context, db *sql.DB) error {
+	v, dirty, err := Version(ctx, db)
+	switch {
+	case errors.Is(err, ErrUnversioned):
+		return fmt.Errorf("%w: databases created before schema versioning must be marked with their version using cmd/migrateschema force (version 1 for the schema of Trillian v1.4.2)", err)
+	case err != nil:
+		return fmt.Errorf("failed to read schema version: %v", err)
+	case dirty:
+		return fmt.Errorf("schema is dirty at version %d: repair it, then mark it as clean with cmd/migrateschema force", v)
+	case v != LatestVersion():
+		return fmt.Errorf("schema is at version %d, want version %d: upgrade it with --auto_migrate or cmd/migrateschema up", v, LatestVersion())
+	}
+	return nil
+}
+
+// Migrate applies or reverts migrations to bring the schema of db to the
+// target version. Version 0 is the empty schema.
+//
+// MySQL doesn't support transactional schema changes, so a failed migration
+// leaves the schema dirty, and Migrate refuses to run against dirty schemas.
+func Migrate(ctx context.Context, db *sql.DB, target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d, want 0 to %d", target, LatestVersion())
+	}
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		v, dirty, err := version(ctx, conn)
+		if errors.Is(err, ErrUnversioned) {
+			// Only empty databases can be versioned implicitly, as the
+			// version of an existing schema is unknown.
+			var tables int
+			if err := conn.QueryRowContext(ctx, countTablesSQL).Scan(&tables); err != nil {
+				return err
+			}
+			if tables > 0 {
+				return fmt.Errorf("%w: mark the existing schema with its version using cmd/migrateschema force (version 1 for the schema of Trillian v1.4.2)", err)
+			}
+			if _, err := conn.ExecContext(ctx, createVersionTableSQL); err != nil {
+				return fmt.Errorf("failed to create version table: %v", err)
+			}
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read schema version: %v", err)
+		}
+		if dirty {
+			return fmt.Errorf("schema is dirty at version %d", v)
+		}
+		if v > LatestVersion() {
+			return fmt.Errorf("schema is at version %d, which is newer than this binary's version %d", v, LatestVersion())
+		}
+
+		for ; v < target; v++ {
+			m := migrations[v]
+			glog.Infof("Applying schema migration %d (%s)", m.Version, m.Name)
+			if _, err := conn.ExecContext(ctx, insertVersionSQL, m.Version, true, nowMillis()); err != nil {
+				return err
+			}
+			if err := execAll(ctx, conn, m.Up); err != nil {
+				return fmt.Errorf("failed to apply migration %d (%s): %v", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, cleanVersionSQL, m.Version); err != nil {
+				return err
+			}
+		}
+		for ; v > target; v-- {
+			m := migrations[v-1]
+			glog.Infof("Reverting schema migration %d (%s)", m.Version, m.Name)
+			if _, err := conn.ExecContext(ctx, dirtyVersionSQL, m.Version); err != nil {
+				return err
+			}
+			if err := execAll(ctx, conn, m.Down); err != nil {
+				return fmt.Errorf("failed to revert migration %d (%s): %v", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, deleteVersionSQL, m.Version-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Force records that the schema of db is cleanly at the given version,
+// without applying any migrations. It's used to adopt databases created
+// before schema versioning, and to recover from failed migrations once the
+// schema has been repaired by hand.
+func Force(ctx context.Context, db *sql.DB, target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d, want 0 to %d", target, LatestVersion())
+	}
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, createVersionTableSQL); err != nil {
+			return fmt.Errorf("failed to create version table: %v", err)
+		}
+		if _, err := conn.ExecContext(ctx, deleteVersionSQL, target); err != nil {
+			return err
+		}
+		if target == 0 {
+			return nil
+		}
+		_, err := conn.ExecContext(ctx, forceVersionSQL, target, nowMillis())
+		return err
+	})
+}
+
+func execAll(ctx context.Context, q queryer, stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error running statement %q: %v", stmt, err)
+		}
+	}
+	return nil
+}
+
+// withLock calls f with a connection to db holding the migration lock.
+func withLock(ctx context.Context, db *sql.DB, f func(*sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeoutSecs).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return errors.New("timed out waiting for migration lock")
+	}
+	defer func() {
+		var released sql.NullInt64
+		if err := conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName).Scan(&released); err != nil {
+			glog.Warningf("Failed to release migration lock: %v", err)
+		}
+	}()
+	return f(conn)
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
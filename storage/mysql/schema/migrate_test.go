@@ -0,0 +1,227 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/storage/testdb"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	for _, test := range []struct {
+		desc    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			desc: "valid",
+			files: fstest.MapFS{
+				"migrations/000002_two.up.sql":   file("-- Comment\nALTER TABLE T ADD COLUMN C INT;\nCREATE INDEX I\n  ON T(C);"),
+				"migrations/000002_two.down.sql": file("ALTER TABLE T DROP COLUMN C;"),
+				"migrations/000001_one.up.sql":   file("CREATE TABLE T(ID INT);"),
+				"migrations/000001_one.down.sql": file("# Comment\nDROP TABLE T;\n"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "one", Up: []string{"CREATE TABLE T(ID INT)"}, Down: []string{"DROP TABLE T"}},
+				{Version: 2, Name: "two", Up: []string{"ALTER TABLE T ADD COLUMN C INT", "CREATE INDEX I\nON T(C)"}, Down: []string{"ALTER TABLE T DROP COLUMN C"}},
+			},
+		},
+		{
+			desc: "missing version",
+			files: fstest.MapFS{
+				"migrations/000002_two.up.sql":   file("SELECT 1;"),
+				"migrations/000002_two.down.sql": file("SELECT 1;"),
+			},
+			wantErr: true,
+		},
+		{
+			desc:    "missing down",
+			files:   fstest.MapFS{"migrations/000001_one.up.sql": file("SELECT 1;")},
+			wantErr: true,
+		},
+		{
+			desc: "mismatched names",
+			files: fstest.MapFS{
+				"migrations/000001_one.up.sql":   file("SELECT 1;"),
+				"migrations/000001_uno.down.sql": file("SELECT 1;"),
+			},
+			wantErr: true,
+		},
+		{
+			desc:    "invalid name",
+			files:   fstest.MapFS{"migrations/one.sql": file("SELECT 1;")},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := loadMigrations(test.files)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("loadMigrations(): %v, want error: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(got, test.want); diff != "" && !test.wantErr {
+				t.Errorf("loadMigrations() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestStorageSQLVersion checks that storage.sql records the latest version.
+func TestStorageSQLVersion(t *testing.T) {
+	script, err := ioutil.ReadFile("storage.sql")
+	if err != nil {
+		t.Fatalf("ReadFile(): %v", err)
+	}
+	m := regexp.MustCompile(`INSERT IGNORE INTO SchemaMigrations\(.*\) VALUES \((\d+),`).FindSubmatch(script)
+	if m == nil {
+		t.Fatal("storage.sql doesn't insert the schema version")
+	}
+	if got, _ := strconv.Atoi(string(m[1])); got != LatestVersion() {
+		t.Errorf("storage.sql creates schema version %d, want %d", got, LatestVersion())
+	}
+}
+
+// columns returns the definitions of the columns of the tables in db.
+func columns(ctx context.Context, t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.QueryContext(ctx, `SELECT CONCAT_WS(' ', table_name, column_name, column_type, is_nullable, column_key)
+FROM information_schema.columns WHERE table_schema = DATABASE() ORDER BY table_name, column_name`)
+	if err != nil {
+		t.Fatalf("Failed to list columns: %v", err)
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			t.Fatalf("Failed to read column: %v", err)
+		}
+		cols = append(cols, col)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Failed to list columns: %v", err)
+	}
+	return cols
+}
+
+func TestMigrate(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	db, done, err := testdb.NewTrillianDB(ctx)
+	if err != nil {
+		t.Fatalf("NewTrillianDB(): %v", err)
+	}
+	defer done(ctx)
+
+	if err := Check(ctx, db); err != nil {
+		t.Fatalf("Check() on storage.sql schema: %v", err)
+	}
+	want := columns(ctx, t, db)
+
+	if err := Migrate(ctx, db, 0); err != nil {
+		t.Fatalf("Migrate(0): %v", err)
+	}
+	var tables int
+	if err := db.QueryRowContext(ctx, countTablesSQL).Scan(&tables); err != nil {
+		t.Fatalf("Failed to count tables: %v", err)
+	}
+	if tables != 1 {
+		t.Errorf("Migrate(0) left %d tables, want only the version table", tables)
+	}
+	if err := Check(ctx, db); err == nil {
+		t.Error("Check() at version 0 succeeded, want error")
+	}
+
+	if err := Migrate(ctx, db, LatestVersion()); err != nil {
+		t.Fatalf("Migrate(%d): %v", LatestVersion(), err)
+	}
+	if err := Check(ctx, db); err != nil {
+		t.Errorf("Check() after migrations: %v", err)
+	}
+	if diff := cmp.Diff(columns(ctx, t, db), want); diff != "" {
+		t.Errorf("migrated schema differs from storage.sql (-got +want):\n%s", diff)
+	}
+}
+
+func TestForce(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	db, done, err := testdb.NewTrillianDB(ctx)
+	if err != nil {
+		t.Fatalf("NewTrillianDB(): %v", err)
+	}
+	defer done(ctx)
+
+	// Simulate a database created before schema versioning.
+	if _, err := db.ExecContext(ctx, "DROP TABLE SchemaMigrations"); err != nil {
+		t.Fatalf("Failed to drop version table: %v", err)
+	}
+	if err := Check(ctx, db); !errors.Is(err, ErrUnversioned) {
+		t.Errorf("Check(): %v, want %v", err, ErrUnversioned)
+	}
+	if err := Migrate(ctx, db, LatestVersion()); !errors.Is(err, ErrUnversioned) {
+		t.Errorf("Migrate(): %v, want %v", err, ErrUnversioned)
+	}
+
+	if err := Force(ctx, db, LatestVersion()); err != nil {
+		t.Fatalf("Force(): %v", err)
+	}
+	if err := Check(ctx, db); err != nil {
+		t.Errorf("Check() after Force(): %v", err)
+	}
+}
+
+func TestPartitionLeafTables(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	db, done, err := testdb.NewTrillianDB(ctx)
+	if err != nil {
+		t.Fatalf("NewTrillianDB(): %v", err)
+	}
+	defer done(ctx)
+
+	if err := PartitionLeafTables(ctx, db, 0); err == nil {
+		t.Error("PartitionLeafTables(0) succeeded, want error")
+	}
+	want := map[string]int{"LeafData": 0, "SequencedLeafData": 0}
+	for _, partitions := range []int{4, 8} {
+		if err := PartitionLeafTables(ctx, db, partitions); err != nil {
+			t.Fatalf("PartitionLeafTables(%d): %v", partitions, err)
+		}
+		got, err := LeafTablePartitions(ctx, db)
+		if err != nil {
+			t.Fatalf("LeafTablePartitions(): %v", err)
+		}
+		for table := range want {
+			want[table] = partitions
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("partitions diff (-want +got):\n%s", diff)
+		}
+	}
+	if err := Check(ctx, db); err != nil {
+		t.Errorf("Check() after partitioning: %v", err)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN LeafDedupPolicy ENUM('DEDUP_BY_IDENTITY_HASH', 'DEDUP_BY_LEAF_VALUE_HASH', 'NO_DEDUP') NOT NULL DEFAULT 'DEDUP_BY_IDENTITY_HASH';
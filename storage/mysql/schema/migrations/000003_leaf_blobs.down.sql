@@ -0,0 +1 @@
+ALTER TABLE LeafData DROP COLUMN Offloaded;
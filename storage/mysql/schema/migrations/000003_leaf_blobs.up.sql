@@ -0,0 +1 @@
+ALTER TABLE LeafData ADD COLUMN Offloaded BOOLEAN NOT NULL DEFAULT FALSE;
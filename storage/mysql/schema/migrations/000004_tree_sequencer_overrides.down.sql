@@ -0,0 +1 @@
+ALTER TABLE Trees DROP COLUMN SequencerGuardWindowMillis, DROP COLUMN SequencerBatchSize;
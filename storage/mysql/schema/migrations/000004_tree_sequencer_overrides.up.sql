@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN SequencerGuardWindowMillis BIGINT NOT NULL DEFAULT 0, ADD COLUMN SequencerBatchSize INTEGER NOT NULL DEFAULT 0;
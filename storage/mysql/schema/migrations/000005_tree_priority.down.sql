@@ -0,0 +1 @@
+ALTER TABLE Trees DROP COLUMN TreePriority;
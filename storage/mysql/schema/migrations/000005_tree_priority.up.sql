@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN TreePriority ENUM('NORMAL_PRIORITY', 'HIGH_PRIORITY', 'LOW_PRIORITY') NOT NULL DEFAULT 'NORMAL_PRIORITY';
@@ -0,0 +1 @@
+ALTER TABLE Trees DROP COLUMN SignerAffinity, DROP COLUMN AntiAffinityGroup;
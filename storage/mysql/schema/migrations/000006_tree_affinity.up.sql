@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN SignerAffinity VARCHAR(255) NOT NULL DEFAULT '', ADD COLUMN AntiAffinityGroup VARCHAR(255) NOT NULL DEFAULT '';
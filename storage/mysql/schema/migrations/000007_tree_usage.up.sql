@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId               BIGINT NOT NULL,
+  LeafDataBytes        BIGINT NOT NULL,
+  SubtreeBytes         BIGINT NOT NULL,
+  QueueBytes           BIGINT NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
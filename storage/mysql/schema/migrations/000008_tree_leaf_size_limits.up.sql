@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN MaxLeafValueSize INTEGER NOT NULL DEFAULT 0, ADD COLUMN MaxExtraDataSize INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN MaxUnsequencedLeaves INTEGER NOT NULL DEFAULT 0;
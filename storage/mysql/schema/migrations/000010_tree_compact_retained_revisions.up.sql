@@ -0,0 +1 @@
+ALTER TABLE Trees ADD COLUMN CompactRetainedRevisions INTEGER NOT NULL DEFAULT 0;
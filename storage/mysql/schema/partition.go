@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golang/glog"
+)
+
+// MaxPartitions is the maximum number of partitions of a MySQL table.
+const MaxPartitions = 8192
+
+const (
+	selectForeignKeysSQL = `SELECT constraint_name FROM information_schema.referential_constraints
+WHERE constraint_schema = DATABASE() AND table_name = ?`
+	countPartitionsSQL = `SELECT COUNT(partition_name) FROM information_schema.partitions
+WHERE table_schema = DATABASE() AND table_name = ?`
+)
+
+// leafTables are the tables holding the leaves of logs, in the order their
+// foreign keys are dropped: SequencedLeafData references LeafData.
+var leafTables = []string{"SequencedLeafData", "LeafData"}
+
+// PartitionLeafTables partitions the LeafData and SequencedLeafData tables of
+// db into the given number of partitions by the hash of their TreeId, so that
+// each tree's rows are in a single partition, or repartitions them if they're
+// already partitioned. Deleting the rows of a tree then only locks its
+// partition, and queries for a tree only read its partition.
+//
+// MySQL doesn't support foreign keys on partitioned tables, so the foreign
+// keys of these tables are dropped, and the storage deletes the leaves of
+// trees explicitly instead. Partitioning rebuilds the tables, which takes a
+// long time for large tables.
+func PartitionLeafTables(ctx context.Context, db *sql.DB, partitions int) error {
+	if partitions < 1 || partitions > MaxPartitions {
+		return fmt.Errorf("invalid number of partitions %d, want 1 to %d", partitions, MaxPartitions)
+	}
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		for _, table := range leafTables {
+			fks, err := foreignKeys(ctx, conn, table)
+			if err != nil {
+				return fmt.Errorf("failed to list foreign keys of %s: %v", table, err)
+			}
+			for _, fk := range fks {
+				glog.Infof("Dropping foreign key %s of %s", fk, table)
+				if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY `%s`", table, fk)); err != nil {
+					return fmt.Errorf("failed to drop foreign key %s of %s: %v", fk, table, err)
+				}
+			}
+		}
+		for _, table := range leafTables {
+			glog.Infof("Partitioning %s into %d partitions", table, partitions)
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s PARTITION BY KEY(TreeId) PARTITIONS %d", table, partitions)); err != nil {
+				return fmt.Errorf("failed to partition %s: %v", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// LeafTablePartitions returns the number of partitions of the LeafData and
+// SequencedLeafData tables of db, which is zero for tables which aren't
+// partitioned.
+func LeafTablePartitions(ctx context.Context, db *sql.DB) (map[string]int, error) {
+	ret := make(map[string]int)
+	for _, table := range leafTables {
+		var n int
+		if err := db.QueryRowContext(ctx, countPartitionsSQL, table).Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to count partitions of %s: %v", table, err)
+		}
+		ret[table] = n
+	}
+	return ret, nil
+}
+
+func foreignKeys(ctx context.Context, conn *sql.Conn, table string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, selectForeignKeysSQL, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fks []string
+	for rows.Next() {
+		var fk string
+		if err := rows.Scan(&fk); err != nil {
+			return nil, err
+		}
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
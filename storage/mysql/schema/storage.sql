@@ -22,6 +22,17 @@ CREATE TABLE IF NOT EXISTS Trees(
   PublicKey             MEDIUMBLOB NOT NULL,
   Deleted               BOOLEAN,
   DeleteTimeMillis      BIGINT,
+  LeafDedupPolicy       ENUM('DEDUP_BY_IDENTITY_HASH', 'DEDUP_BY_LEAF_VALUE_HASH', 'NO_DEDUP') NOT NULL DEFAULT 'DEDUP_BY_IDENTITY_HASH',
+  SequencerGuardWindowMillis BIGINT NOT NULL DEFAULT 0,
+  SequencerBatchSize    INTEGER NOT NULL DEFAULT 0,
+  TreePriority          ENUM('NORMAL_PRIORITY', 'HIGH_PRIORITY', 'LOW_PRIORITY') NOT NULL DEFAULT 'NORMAL_PRIORITY',
+  -- Comma-separated signer affinity labels.
+  SignerAffinity        VARCHAR(255) NOT NULL DEFAULT '',
+  AntiAffinityGroup     VARCHAR(255) NOT NULL DEFAULT '',
+  MaxLeafValueSize      INTEGER NOT NULL DEFAULT 0,
+  MaxExtraDataSize      INTEGER NOT NULL DEFAULT 0,
+  MaxUnsequencedLeaves  INTEGER NOT NULL DEFAULT 0,
+  CompactRetainedRevisions INTEGER NOT NULL DEFAULT 0,
   PRIMARY KEY(TreeId)
 );
 
@@ -71,6 +82,9 @@ CREATE UNIQUE INDEX TreeHeadRevisionIdx
 -- optimize physical storage layout. Most engines allow multiple nulls in a
 -- unique index but some may not.
 
+-- LeafData and SequencedLeafData can be partitioned by tree with the partition
+-- command of cmd/migrateschema, which drops their foreign keys.
+
 -- A leaf that has not been sequenced has a row in this table. If duplicate leaves
 -- are allowed they will all reference this row.
 CREATE TABLE IF NOT EXISTS LeafData(
@@ -87,6 +101,9 @@ CREATE TABLE IF NOT EXISTS LeafData(
   ExtraData            LONGBLOB,
   -- The timestamp from when this leaf data was first queued for inclusion.
   QueueTimestampNanos  BIGINT NOT NULL,
+  -- Whether LeafValue and ExtraData are stored in object storage rather than in
+  -- this row, which then holds an empty LeafValue.
+  Offloaded            BOOLEAN NOT NULL DEFAULT FALSE,
   PRIMARY KEY(TreeId, LeafIdentityHash),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
@@ -135,3 +152,32 @@ CREATE TABLE IF NOT EXISTS Unsequenced(
   QueueID VARBINARY(32) DEFAULT NULL UNIQUE,
   PRIMARY KEY (TreeId, Bucket, QueueTimestampNanos, LeafIdentityHash)
 );
+
+-- The approximate number of bytes stored for each tree, which the storage layer
+-- adds the changes it makes to as it writes the other tables. Trees written
+-- before the table was created only have the usage of later writes.
+CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId               BIGINT NOT NULL,
+  LeafDataBytes        BIGINT NOT NULL,
+  SubtreeBytes         BIGINT NOT NULL,
+  QueueBytes           BIGINT NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- ---------------------------------------------
+-- Schema versioning
+-- ---------------------------------------------
+
+-- The versions of the migrations in the migrations directory which have been
+-- applied. This script creates the schema at the latest version, which must be
+-- updated whenever a migration is added.
+CREATE TABLE IF NOT EXISTS SchemaMigrations(
+  Version              INTEGER NOT NULL,
+  -- Whether the migration failed half-way, leaving the schema in an unknown state.
+  Dirty                BOOLEAN NOT NULL,
+  AppliedTimeMillis    BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+);
+
+INSERT IGNORE INTO SchemaMigrations(Version, Dirty, AppliedTimeMillis) VALUES (10, FALSE, 0);
@@ -25,8 +25,10 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/testdb"
@@ -37,6 +39,60 @@ import (
 	"github.com/transparency-dev/merkle/rfc6962"
 )
 
+func TestBatchSizes(t *testing.T) {
+	for _, test := range []struct {
+		n    int
+		want []int
+	}{
+		{n: 0},
+		{n: 1, want: []int{1}},
+		{n: 7, want: []int{4, 2, 1}},
+		{n: 8, want: []int{8}},
+		{n: 21, want: []int{8, 8, 4, 1}},
+	} {
+		if diff := cmp.Diff(batchSizes(test.n, 8), test.want); diff != "" {
+			t.Errorf("batchSizes(%d, 8) diff (-got +want):\n%s", test.n, diff)
+		}
+	}
+}
+
+func TestQueueBucket(t *testing.T) {
+	ts := queueEpoch.Add(90 * time.Minute)
+	for _, test := range []struct {
+		desc  string
+		ts    time.Time
+		shard int
+		want  int64
+	}{
+		{desc: "before-epoch", ts: queueEpoch.Add(-time.Hour), want: maxQueueShards},
+		{desc: "first-shard", ts: ts, want: 91 * maxQueueShards},
+		{desc: "last-shard", ts: ts, shard: maxQueueShards - 1, want: 92*maxQueueShards - 1},
+	} {
+		got := queueBucket(test.ts, test.shard)
+		if got != test.want {
+			t.Errorf("%s: queueBucket()=%d, want %d", test.desc, got, test.want)
+		}
+		if max := maxQueueBucket(test.ts); got > max {
+			t.Errorf("%s: queueBucket()=%d after maxQueueBucket()=%d", test.desc, got, max)
+		}
+	}
+	// Leaves queued later are in later buckets, whatever their shards.
+	if got, prev := queueBucket(ts.Add(queueBucketWidth), 0), maxQueueBucket(ts); got <= prev {
+		t.Errorf("queueBucket() of the next time bucket=%d, want > %d", got, prev)
+	}
+
+	ls := &mySQLLogStorage{}
+	if got := ls.queueBucket(ts); got != 0 {
+		t.Errorf("unsharded queueBucket()=%d, want 0", got)
+	}
+	ls.queueShards = 4
+	for i := 0; i < 100; i++ {
+		if got := ls.queueBucket(ts); got < queueBucket(ts, 0) || got > queueBucket(ts, 3) {
+			t.Fatalf("sharded queueBucket()=%d, want in [%d, %d]", got, queueBucket(ts, 0), queueBucket(ts, 3))
+		}
+	}
+}
+
 func TestNodeRoundTrip(t *testing.T) {
 	nodes := createSomeNodes(256)
 	nodeIDs := make([]compact.NodeID, len(nodes))
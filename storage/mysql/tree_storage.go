@@ -20,12 +20,15 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"fmt"
+	"math/bits"
 	"runtime/debug"
 	"strings"
 	"sync"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/tree"
@@ -53,6 +56,11 @@ const (
  AND Subtree.TreeId = x.TreeId
  AND Subtree.TreeId = ?`
 	placeholderSQL = "<placeholder>"
+
+	// subtreeBatchRows and leafBatchRows are the maximum numbers of rows written by a single
+	// multi-row statement. They're powers of two, see batchSizes.
+	subtreeBatchRows = 256
+	leafBatchRows    = 1024
 )
 
 // mySQLTreeStorage is shared between the mySQLLog- and (forthcoming) mySQLMap-
@@ -66,6 +74,13 @@ type mySQLTreeStorage struct {
 	// in the query to the statement that should be used.
 	statementMutex sync.Mutex
 	statements     map[string]map[int]*sql.Stmt
+
+	// usage, if set, keeps track of the bytes stored for each tree.
+	usage *usageTracker
+
+	// bulkLoadRows, if positive, is the number of rows from which the sequenced leaves and
+	// subtrees written by a transaction are loaded with LOAD DATA instead of inserted.
+	bulkLoadRows int
 }
 
 // OpenDB opens a database connection for all MySQL-based storage implementations.
@@ -133,6 +148,23 @@ func (m *mySQLTreeStorage) getStmt(ctx context.Context, statement string, num in
 	return s, nil
 }
 
+// batchSizes splits n rows into batches of at most max rows, which must be a power of two.
+// The sizes of the batches are powers of two too, so that writing any number of rows uses at
+// most log2(max)+1 distinct statements, which are cached by getStmt. The database/sql package
+// prepares each cached statement once per connection.
+func batchSizes(n, max int) []int {
+	var sizes []int
+	for n > 0 {
+		size := max
+		if n < max {
+			size = 1 << (bits.Len(uint(n)) - 1)
+		}
+		sizes = append(sizes, size)
+		n -= size
+	}
+	return sizes
+}
+
 func (m *mySQLTreeStorage) getSubtreeStmt(ctx context.Context, num int) (*sql.Stmt, error) {
 	return m.getStmt(ctx, selectSubtreeSQL, num, "?", "?")
 }
@@ -144,6 +176,7 @@ func (m *mySQLTreeStorage) setSubtreeStmt(ctx context.Context, num int) (*sql.St
 func (m *mySQLTreeStorage) beginTreeTx(ctx context.Context, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) (treeTX, error) {
 	t, err := m.db.BeginTx(ctx, nil /* opts */)
 	if err != nil {
+		err = logging.AnnotateError(ctx, err)
 		glog.Warningf("Could not start tree TX: %s", err)
 		return treeTX{}, err
 	}
@@ -170,6 +203,9 @@ type treeTX struct {
 	hashSizeBytes int
 	subtreeCache  *cache.SubtreeCache
 	writeRevision int64
+	// usage accumulates the changes to the bytes stored for the tree made by
+	// the transaction.
+	usage storage.TreeUsage
 }
 
 func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, ids [][]byte) ([]*storagepb.SubtreeProto, error) {
@@ -267,13 +303,23 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 	if len(subtrees) == 0 {
 		return nil
 	}
+	if t.ts.bulkLoad(len(subtrees)) {
+		return t.loadSubtrees(ctx, subtrees)
+	}
 
-	// TODO(al): probably need to be able to batch this in the case where we have
-	// a really large number of subtrees to store.
-	args := make([]interface{}, 0, len(subtrees))
+	for _, n := range batchSizes(len(subtrees), subtreeBatchRows) {
+		if err := t.storeSubtreeBatch(ctx, subtrees[:n]); err != nil {
+			return err
+		}
+		subtrees = subtrees[n:]
+	}
+	return nil
+}
 
+// storeSubtreeBatch writes subtrees with a single multi-row statement.
+func (t *treeTX) storeSubtreeBatch(ctx context.Context, subtrees []*storagepb.SubtreeProto) error {
+	args := make([]interface{}, 0, 4*len(subtrees))
 	for _, s := range subtrees {
-		s := s
 		if s.Prefix == nil {
 			panic(fmt.Errorf("nil prefix on %v", s))
 		}
@@ -281,10 +327,8 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 		if err != nil {
 			return err
 		}
-		args = append(args, t.treeID)
-		args = append(args, s.Prefix)
-		args = append(args, subtreeBytes)
-		args = append(args, t.writeRevision)
+		args = append(args, t.treeID, s.Prefix, subtreeBytes, t.writeRevision)
+		t.usage.SubtreeBytes += int64(subtreeRowBytes + len(s.Prefix) + len(subtreeBytes))
 	}
 
 	tmpl, err := t.ts.setSubtreeStmt(ctx, len(subtrees))
@@ -294,12 +338,10 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 	stx := t.tx.StmtContext(ctx, tmpl)
 	defer stx.Close()
 
-	r, err := stx.ExecContext(ctx, args...)
-	if err != nil {
+	if _, err := stx.ExecContext(ctx, args...); err != nil {
 		glog.Warningf("Failed to set merkle subtrees: %s", err)
 		return err
 	}
-	_, _ = r.RowsAffected()
 	return nil
 }
 
@@ -345,19 +387,23 @@ func (t *treeTX) Commit(ctx context.Context) error {
 	if t.writeRevision > -1 {
 		tiles, err := t.subtreeCache.UpdatedTiles()
 		if err != nil {
+			err = logging.AnnotateError(ctx, err)
 			glog.Warningf("SubtreeCache updated tiles error: %v", err)
 			return err
 		}
 		if err := t.storeSubtrees(ctx, tiles); err != nil {
+			err = logging.AnnotateError(ctx, err)
 			glog.Warningf("TX commit flush error: %v", err)
 			return err
 		}
 	}
 	t.closed = true
 	if err := t.tx.Commit(); err != nil {
+		err = logging.AnnotateError(ctx, err)
 		glog.Warningf("TX commit error: %s, stack:\n%s", err, string(debug.Stack()))
 		return err
 	}
+	t.ts.usage.record(t.treeID, t.usage)
 	return nil
 }
 
@@ -0,0 +1,251 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+const (
+	// upsertTreeUsageSQL is followed by one upsertTreeUsageRow per tree, and
+	// upsertTreeUsageSuffix.
+	upsertTreeUsageSQL    = "INSERT INTO TreeUsage(TreeId,LeafDataBytes,SubtreeBytes,QueueBytes) VALUES"
+	upsertTreeUsageRow    = "(?,?,?,?)"
+	upsertTreeUsageSuffix = ` ON DUPLICATE KEY UPDATE LeafDataBytes=LeafDataBytes+VALUES(LeafDataBytes),
+			SubtreeBytes=SubtreeBytes+VALUES(SubtreeBytes),QueueBytes=QueueBytes+VALUES(QueueBytes)`
+	selectTreeUsageSQL = "SELECT LeafDataBytes,SubtreeBytes,QueueBytes FROM TreeUsage WHERE TreeId=?"
+	// selectTreeUsagesSQL is followed by the placeholders of the tree IDs, and
+	// a closing parenthesis.
+	selectTreeUsagesSQL = "SELECT TreeId,LeafDataBytes,SubtreeBytes,QueueBytes FROM TreeUsage WHERE TreeId IN ("
+
+	// usageFlushTimeout bounds the time taken to write the pending changes.
+	usageFlushTimeout = 30 * time.Second
+
+	// The sizes of the fixed-size columns of each table, which are added to
+	// the sizes of the variable-size columns of the rows written.
+	leafDataRowBytes      = 8 + 8 + 1      // TreeId, QueueTimestampNanos, Offloaded
+	sequencedLeafRowBytes = 8 + 8 + 8      // TreeId, SequenceNumber, IntegrateTimestampNanos
+	unsequencedRowBytes   = 8 + 4 + 8 + 32 // TreeId, Bucket, QueueTimestampNanos, QueueID
+	subtreeRowBytes       = 8 + 4          // TreeId, SubtreeRevision
+)
+
+// leafDataBytes returns the approximate size of the LeafData row of leaf. The
+// values of leaves offloaded to object storage are counted too.
+func leafDataBytes(leaf *trillian.LogLeaf) int64 {
+	return int64(leafDataRowBytes + len(leaf.LeafIdentityHash) + len(leaf.LeafValue) + len(leaf.ExtraData))
+}
+
+// sequencedLeafBytes returns the approximate size of the SequencedLeafData row
+// of leaf.
+func sequencedLeafBytes(leaf *trillian.LogLeaf) int64 {
+	return int64(sequencedLeafRowBytes + len(leaf.LeafIdentityHash) + len(leaf.MerkleLeafHash))
+}
+
+// queueEntryBytes returns the approximate size of the Unsequenced row of leaf.
+func queueEntryBytes(leaf *trillian.LogLeaf) int64 {
+	return int64(unsequencedRowBytes + len(leaf.LeafIdentityHash) + len(leaf.MerkleLeafHash))
+}
+
+// usageTracker accumulates the changes to the bytes stored for each tree made
+// by committed transactions, and adds them to the TreeUsage table in the
+// background every interval, so that the rows of busy trees aren't written by
+// every transaction, and transactions don't wait for the writes. Changes which
+// fail to be written are dropped, as the usage is only approximate.
+type usageTracker struct {
+	db       *sql.DB
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[int64]storage.TreeUsage
+	started bool          // Whether run was started.
+	closed  bool          // Whether close was called.
+	flush   chan struct{} // Requests a write if interval is zero.
+	stop    chan struct{} // Closed by close.
+	done    chan struct{} // Closed when run returns.
+}
+
+func newUsageTracker(db *sql.DB, interval time.Duration) *usageTracker {
+	return &usageTracker{
+		db:       db,
+		interval: interval,
+		pending:  make(map[int64]storage.TreeUsage),
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// record adds the changes made to a tree by a committed transaction to the
+// pending changes, which are written in the background.
+func (u *usageTracker) record(treeID int64, delta storage.TreeUsage) {
+	if u == nil || delta == (storage.TreeUsage{}) {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := u.pending[treeID]
+	usage.Add(delta)
+	u.pending[treeID] = usage
+	if !u.started {
+		u.started = true
+		go u.run()
+	}
+	if u.interval <= 0 {
+		select {
+		case u.flush <- struct{}{}:
+		default: // A write is already requested.
+		}
+	}
+}
+
+// run writes the pending changes every interval, or when requested if the
+// interval is zero, until close is called.
+func (u *usageTracker) run() {
+	defer close(u.done)
+	var tick <-chan time.Time
+	if u.interval > 0 {
+		ticker := time.NewTicker(u.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+		case <-u.flush:
+		case <-u.stop:
+			u.write()
+			return
+		}
+		u.write()
+	}
+}
+
+// close writes the pending changes, and stops writing them in the background.
+func (u *usageTracker) close() {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	started, closed := u.started, u.closed
+	u.started = true // Prevents record from starting run after close.
+	u.closed = true
+	u.mu.Unlock()
+	if closed {
+		return
+	}
+	close(u.stop)
+	if started {
+		<-u.done
+	}
+}
+
+// write adds the pending changes of all trees to the TreeUsage table with a
+// single statement, and updates the usage metric from the resulting rows.
+func (u *usageTracker) write() {
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = make(map[int64]storage.TreeUsage)
+	u.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageFlushTimeout)
+	defer cancel()
+	ids := make([]int64, 0, len(pending))
+	args := make([]interface{}, 0, 4*len(pending))
+	for id, delta := range pending {
+		ids = append(ids, id)
+		args = append(args, id, delta.LeafDataBytes, delta.SubtreeBytes, delta.QueueBytes)
+	}
+	rows := strings.TrimSuffix(strings.Repeat(upsertTreeUsageRow+",", len(pending)), ",")
+	if _, err := u.db.ExecContext(ctx, upsertTreeUsageSQL+rows+upsertTreeUsageSuffix, args...); err != nil {
+		glog.Warningf("Failed to update the usage of %d trees: %v", len(pending), err)
+		return
+	}
+	if treeUsageGauge == nil {
+		return
+	}
+	usages, err := selectTreeUsages(ctx, u.db, ids)
+	if err != nil {
+		glog.Warningf("Failed to read the usage of %d trees: %v", len(ids), err)
+		return
+	}
+	for id, usage := range usages {
+		label := strconv.FormatInt(id, 10)
+		treeUsageGauge.Set(float64(usage.LeafDataBytes), label, "leaf_data")
+		treeUsageGauge.Set(float64(usage.SubtreeBytes), label, "subtrees")
+		treeUsageGauge.Set(float64(usage.QueueBytes), label, "queue")
+	}
+}
+
+// selectTreeUsages returns the usage of the trees with the given IDs which
+// have a TreeUsage row.
+func selectTreeUsages(ctx context.Context, db *sql.DB, treeIDs []int64) (map[int64]storage.TreeUsage, error) {
+	args := make([]interface{}, 0, len(treeIDs))
+	for _, id := range treeIDs {
+		args = append(args, id)
+	}
+	query := selectTreeUsagesSQL + strings.TrimSuffix(strings.Repeat("?,", len(treeIDs)), ",") + ")"
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	usages := make(map[int64]storage.TreeUsage, len(treeIDs))
+	for rows.Next() {
+		var id int64
+		var usage storage.TreeUsage
+		if err := rows.Scan(&id, &usage.LeafDataBytes, &usage.SubtreeBytes, &usage.QueueBytes); err != nil {
+			return nil, err
+		}
+		usages[id] = usage
+	}
+	return usages, rows.Err()
+}
+
+func selectTreeUsage(ctx context.Context, db *sql.DB, treeID int64) (storage.TreeUsage, error) {
+	var usage storage.TreeUsage
+	err := db.QueryRowContext(ctx, selectTreeUsageSQL, treeID).Scan(&usage.LeafDataBytes, &usage.SubtreeBytes, &usage.QueueBytes)
+	if err == sql.ErrNoRows {
+		// Nothing was written to the tree yet.
+		return storage.TreeUsage{}, nil
+	}
+	return usage, err
+}
+
+// TreeUsage implements storage.UsageReporter. Changes made by log storage
+// which haven't been written yet, see LogStorageOptions.UsageFlushInterval,
+// aren't included.
+func (s *mysqlAdminStorage) TreeUsage(ctx context.Context, treeID int64) (storage.TreeUsage, error) {
+	return selectTreeUsage(ctx, s.db, treeID)
+}
+
+// accountSequencedLeaves records the sequencing of the queued leaves.
+func (t *logTreeTX) accountSequencedLeaves(leaves []*trillian.LogLeaf) {
+	for _, leaf := range leaves {
+		t.usage.LeafDataBytes += sequencedLeafBytes(leaf)
+		t.usage.QueueBytes -= queueEntryBytes(leaf)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+)
+
+func TestUsageTrackerClose(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree1 := mustCreateTree(ctx, t, as, testonly.LogTree)
+	tree2 := mustCreateTree(ctx, t, as, testonly.LogTree)
+
+	// The pending changes are only written by close within the interval.
+	u := newUsageTracker(DB, time.Hour)
+	u.record(tree1.TreeId, storage.TreeUsage{LeafDataBytes: 10, QueueBytes: 5})
+	u.record(tree1.TreeId, storage.TreeUsage{LeafDataBytes: 1, QueueBytes: -5})
+	u.record(tree2.TreeId, storage.TreeUsage{SubtreeBytes: 7})
+	if got, err := selectTreeUsage(ctx, DB, tree1.TreeId); err != nil || got != (storage.TreeUsage{}) {
+		t.Errorf("selectTreeUsage() before close = %+v, %v, want no usage", got, err)
+	}
+	u.close()
+	u.close() // Closing again is a no-op.
+
+	for _, test := range []struct {
+		treeID int64
+		want   storage.TreeUsage
+	}{
+		{treeID: tree1.TreeId, want: storage.TreeUsage{LeafDataBytes: 11}},
+		{treeID: tree2.TreeId, want: storage.TreeUsage{SubtreeBytes: 7}},
+	} {
+		got, err := selectTreeUsage(ctx, DB, test.treeID)
+		if err != nil {
+			t.Fatalf("selectTreeUsage(%d): %v", test.treeID, err)
+		}
+		if got != test.want {
+			t.Errorf("selectTreeUsage(%d) = %+v, want %+v", test.treeID, got, test.want)
+		}
+	}
+}
+
+func TestUsageTrackerZeroInterval(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	tree := mustCreateTree(ctx, t, NewAdminStorage(DB), testonly.LogTree)
+
+	u := newUsageTracker(DB, 0)
+	defer u.close()
+	want := storage.TreeUsage{LeafDataBytes: 3}
+	u.record(tree.TreeId, want)
+	// The changes are written in the background soon after they're recorded.
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		got, err := selectTreeUsage(ctx, DB, tree.TreeId)
+		if err != nil {
+			t.Fatalf("selectTreeUsage(): %v", err)
+		}
+		if got == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("selectTreeUsage() = %+v, want %+v", got, want)
+		}
+	}
+}
@@ -15,7 +15,9 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/google/trillian/monitoring"
@@ -30,8 +32,21 @@ var (
 	spByName = make(map[string]NewProviderFunc)
 )
 
-// RegisterProvider registers the given storage Provider.
+// RegisterProvider registers the given storage Provider under name, which
+// binaries then select with their --storage_system flag.
+//
+// Storage implementations, including those which live outside of this
+// repository, usually register themselves from the init function of their
+// package, so that importing it for side effects is enough to make it
+// available to the Trillian binaries.
 func RegisterProvider(name string, sp NewProviderFunc) error {
+	if name == "" {
+		return errors.New("storage provider name must not be empty")
+	}
+	if sp == nil {
+		return fmt.Errorf("storage provider %v has no constructor", name)
+	}
+
 	spMu.Lock()
 	defer spMu.Unlock()
 
@@ -43,20 +58,28 @@ func RegisterProvider(name string, sp NewProviderFunc) error {
 	return nil
 }
 
+// MustRegisterProvider is like RegisterProvider, but panics if the provider
+// can't be registered. It is intended to be called from init functions.
+func MustRegisterProvider(name string, sp NewProviderFunc) {
+	if err := RegisterProvider(name, sp); err != nil {
+		panic(err)
+	}
+}
+
 // NewProvider returns a new Provider instance of the type specified by name.
 func NewProvider(name string, mf monitoring.MetricFactory) (Provider, error) {
 	spMu.RLock()
-	defer spMu.RUnlock()
-
 	sp := spByName[name]
+	spMu.RUnlock()
+
 	if sp == nil {
-		return nil, fmt.Errorf("no such storage provider %v", name)
+		return nil, fmt.Errorf("no such storage provider %v, registered providers: %v", name, Providers())
 	}
 
 	return sp(mf)
 }
 
-// Providers returns a slice of all registered storage provider names.
+// Providers returns the sorted names of all registered storage providers.
 func Providers() []string {
 	spMu.RLock()
 	defer spMu.RUnlock()
@@ -65,6 +88,7 @@ func Providers() []string {
 	for k := range spByName {
 		r = append(r, k)
 	}
+	sort.Strings(r)
 
 	return r
 }
@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/google/trillian/monitoring"
@@ -98,3 +99,53 @@ func TestProviders(t *testing.T) {
 		t.Errorf("Providers() gave %d 'b', want 1", b)
 	}
 }
+
+func TestRegisterProviderErrors(t *testing.T) {
+	newProvider := func(_ monitoring.MetricFactory) (Provider, error) {
+		return &provider{}, nil
+	}
+	if err := RegisterProvider("dup", newProvider); err != nil {
+		t.Fatalf("RegisterProvider(dup) = %v, want no error", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		name string
+		sp   NewProviderFunc
+	}{
+		{desc: "empty name", name: "", sp: newProvider},
+		{desc: "nil constructor", name: "nil constructor", sp: nil},
+		{desc: "duplicate", name: "dup", sp: newProvider},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := RegisterProvider(test.name, test.sp); err == nil {
+				t.Error("RegisterProvider() = no error, want error")
+			}
+		})
+	}
+}
+
+func TestMustRegisterProviderPanics(t *testing.T) {
+	newProvider := func(_ monitoring.MetricFactory) (Provider, error) {
+		return &provider{}, nil
+	}
+	MustRegisterProvider("must", newProvider)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustRegisterProvider(must) twice did not panic")
+		}
+	}()
+	MustRegisterProvider("must", newProvider)
+}
+
+func TestProvidersSorted(t *testing.T) {
+	for _, name := range []string{"sorted_c", "sorted_a", "sorted_b"} {
+		RegisterProvider(name, func(_ monitoring.MetricFactory) (Provider, error) {
+			return &provider{}, nil
+		})
+	}
+	if sp := Providers(); !sort.StringsAreSorted(sp) {
+		t.Errorf("Providers() = %v, want sorted names", sp)
+	}
+}
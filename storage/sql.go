@@ -17,6 +17,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/trillian"
@@ -51,8 +52,8 @@ func ReadTree(row Row) (*trillian.Tree, error) {
 	tree := &trillian.Tree{}
 
 	// Enums and Datetimes need an extra conversion step
-	var treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm string
-	var createMillis, updateMillis, maxRootDurationMillis int64
+	var treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, leafDedupPolicy, priority, signerAffinity string
+	var createMillis, updateMillis, maxRootDurationMillis, guardWindowMillis int64
 	var displayName, description sql.NullString
 	var privateKey, publicKey []byte
 	var deleted sql.NullBool
@@ -73,6 +74,16 @@ func ReadTree(row Row) (*trillian.Tree, error) {
 		&maxRootDurationMillis,
 		&deleted,
 		&deleteMillis,
+		&leafDedupPolicy,
+		&guardWindowMillis,
+		&tree.SequencerBatchSize,
+		&priority,
+		&signerAffinity,
+		&tree.AntiAffinityGroup,
+		&tree.MaxLeafValueSize,
+		&tree.MaxExtraDataSize,
+		&tree.MaxUnsequencedLeaves,
+		&tree.CompactRetainedRevisions,
 	)
 	if err != nil {
 		return nil, err
@@ -92,6 +103,16 @@ func ReadTree(row Row) (*trillian.Tree, error) {
 	} else {
 		return nil, fmt.Errorf("unknown TreeType: %v", treeType)
 	}
+	if p, ok := trillian.LeafDedupPolicy_value[leafDedupPolicy]; ok {
+		tree.LeafDedupPolicy = trillian.LeafDedupPolicy(p)
+	} else {
+		return nil, fmt.Errorf("unknown LeafDedupPolicy: %v", leafDedupPolicy)
+	}
+	if p, ok := trillian.TreePriority_value[priority]; ok {
+		tree.Priority = trillian.TreePriority(p)
+	} else {
+		return nil, fmt.Errorf("unknown TreePriority: %v", priority)
+	}
 	if hashStrategy != "RFC6962_SHA256" {
 		return nil, fmt.Errorf("unknown HashStrategy: %v", hashStrategy)
 	}
@@ -115,6 +136,12 @@ func ReadTree(row Row) (*trillian.Tree, error) {
 		return nil, fmt.Errorf("failed to parse update time: %w", err)
 	}
 	tree.MaxRootDuration = durationpb.New(time.Duration(maxRootDurationMillis * int64(time.Millisecond)))
+	if guardWindowMillis > 0 {
+		tree.SequencerGuardWindow = durationpb.New(time.Duration(guardWindowMillis) * time.Millisecond)
+	}
+	if signerAffinity != "" {
+		tree.SignerAffinity = strings.Split(signerAffinity, ",")
+	}
 
 	tree.Deleted = deleted.Valid && deleted.Bool
 	if tree.Deleted && deleteMillis.Valid {
@@ -86,6 +86,20 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 	validTreeWithoutOptionals.DisplayName = ""
 	validTreeWithoutOptionals.Description = ""
 
+	validTreeNoDedup := proto.Clone(LogTree).(*trillian.Tree)
+	validTreeNoDedup.LeafDedupPolicy = trillian.LeafDedupPolicy_NO_DEDUP
+
+	validTreeSequencerSettings := proto.Clone(LogTree).(*trillian.Tree)
+	validTreeSequencerSettings.SequencerGuardWindow = durationpb.New(5 * time.Second)
+	validTreeSequencerSettings.SequencerBatchSize = 100
+	validTreeSequencerSettings.Priority = trillian.TreePriority_HIGH_PRIORITY
+	validTreeSequencerSettings.SignerAffinity = []string{"a", "b"}
+	validTreeSequencerSettings.AntiAffinityGroup = "hot"
+	validTreeSequencerSettings.MaxLeafValueSize = 1024
+	validTreeSequencerSettings.MaxExtraDataSize = 256
+	validTreeSequencerSettings.MaxUnsequencedLeaves = 1000
+	validTreeSequencerSettings.CompactRetainedRevisions = 10
+
 	tests := []struct {
 		desc    string
 		tree    *trillian.Tree
@@ -108,6 +122,14 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 			desc: "validTreeWithoutOptionals",
 			tree: validTreeWithoutOptionals,
 		},
+		{
+			desc: "validTreeNoDedup",
+			tree: validTreeNoDedup,
+		},
+		{
+			desc: "validTreeSequencerSettings",
+			tree: validTreeSequencerSettings,
+		},
 	}
 
 	ctx := context.Background()
@@ -171,10 +193,28 @@ func (tester *AdminStorageTester) TestUpdateTree(t *testing.T) {
 	validLog.TreeState = trillian.TreeState_FROZEN
 	validLog.DisplayName = "Frozen Tree"
 	validLog.Description = "A Frozen Tree"
+	validLog.SequencerGuardWindow = durationpb.New(5 * time.Second)
+	validLog.SequencerBatchSize = 100
+	validLog.Priority = trillian.TreePriority_LOW_PRIORITY
+	validLog.SignerAffinity = []string{"a"}
+	validLog.AntiAffinityGroup = "hot"
+	validLog.MaxLeafValueSize = 1024
+	validLog.MaxExtraDataSize = 256
+	validLog.MaxUnsequencedLeaves = 1000
+	validLog.CompactRetainedRevisions = 10
 	validLogFunc := func(tree *trillian.Tree) {
 		tree.TreeState = validLog.TreeState
 		tree.DisplayName = validLog.DisplayName
 		tree.Description = validLog.Description
+		tree.SequencerGuardWindow = validLog.SequencerGuardWindow
+		tree.SequencerBatchSize = validLog.SequencerBatchSize
+		tree.Priority = validLog.Priority
+		tree.SignerAffinity = validLog.SignerAffinity
+		tree.AntiAffinityGroup = validLog.AntiAffinityGroup
+		tree.MaxLeafValueSize = validLog.MaxLeafValueSize
+		tree.MaxExtraDataSize = validLog.MaxExtraDataSize
+		tree.MaxUnsequencedLeaves = validLog.MaxUnsequencedLeaves
+		tree.CompactRetainedRevisions = validLog.CompactRetainedRevisions
 	}
 
 	validLogWithoutOptionalsFunc := func(tree *trillian.Tree) {
@@ -16,6 +16,7 @@ package storage
 
 import (
 	"context"
+	"regexp"
 
 	"github.com/google/trillian"
 	"google.golang.org/grpc/codes"
@@ -23,6 +24,10 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// affinityLabelRE matches valid signer affinity labels and anti-affinity groups.
+// Labels can't contain commas, which separate them in flags and storage.
+var affinityLabelRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
 // ValidateTreeForCreation returns nil if tree is valid for insertion, error
 // otherwise.
 // See the documentation on trillian.Tree for reference on which values are
@@ -35,6 +40,8 @@ func ValidateTreeForCreation(ctx context.Context, tree *trillian.Tree) error {
 		return status.Errorf(codes.InvalidArgument, "invalid tree_state: %s", tree.TreeState)
 	case tree.TreeType == trillian.TreeType_UNKNOWN_TREE_TYPE:
 		return status.Errorf(codes.InvalidArgument, "invalid tree_type: %s", tree.TreeType)
+	case trillian.LeafDedupPolicy_name[int32(tree.LeafDedupPolicy)] == "":
+		return status.Errorf(codes.InvalidArgument, "invalid leaf_dedup_policy: %s", tree.LeafDedupPolicy)
 	case tree.Deleted:
 		return status.Errorf(codes.InvalidArgument, "invalid deleted: %v", tree.Deleted)
 	case tree.DeleteTime != nil:
@@ -80,6 +87,8 @@ func ValidateTreeForUpdate(ctx context.Context, storedTree, newTree *trillian.Tr
 		if err := validateTreeTypeUpdate(storedTree, newTree); err != nil {
 			return err
 		}
+	case storedTree.LeafDedupPolicy != newTree.LeafDedupPolicy:
+		return status.Error(codes.InvalidArgument, "readonly field changed: leaf_dedup_policy")
 	case !proto.Equal(storedTree.CreateTime, newTree.CreateTime):
 		return status.Error(codes.InvalidArgument, "readonly field changed: create_time")
 	case !proto.Equal(storedTree.UpdateTime, newTree.UpdateTime):
@@ -101,6 +110,40 @@ func validateMutableTreeFields(ctx context.Context, tree *trillian.Tree) error {
 	} else if duration := tree.MaxRootDuration.AsDuration(); duration < 0 {
 		return status.Errorf(codes.InvalidArgument, "max_root_duration negative: %v", tree.MaxRootDuration)
 	}
+	// sequencer_guard_window is optional, unlike max_root_duration.
+	if window := tree.SequencerGuardWindow; window != nil {
+		if err := window.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "sequencer_guard_window malformed: %v", err)
+		} else if window.AsDuration() < 0 {
+			return status.Errorf(codes.InvalidArgument, "sequencer_guard_window negative: %v", window)
+		}
+	}
+	if tree.SequencerBatchSize < 0 {
+		return status.Errorf(codes.InvalidArgument, "sequencer_batch_size negative: %v", tree.SequencerBatchSize)
+	}
+	if trillian.TreePriority_name[int32(tree.Priority)] == "" {
+		return status.Errorf(codes.InvalidArgument, "invalid priority: %s", tree.Priority)
+	}
+	for _, label := range tree.SignerAffinity {
+		if !affinityLabelRE.MatchString(label) {
+			return status.Errorf(codes.InvalidArgument, "invalid signer_affinity label: %q", label)
+		}
+	}
+	if group := tree.AntiAffinityGroup; group != "" && !affinityLabelRE.MatchString(group) {
+		return status.Errorf(codes.InvalidArgument, "invalid anti_affinity_group: %q", group)
+	}
+	if tree.MaxLeafValueSize < 0 {
+		return status.Errorf(codes.InvalidArgument, "max_leaf_value_size negative: %v", tree.MaxLeafValueSize)
+	}
+	if tree.MaxExtraDataSize < 0 {
+		return status.Errorf(codes.InvalidArgument, "max_extra_data_size negative: %v", tree.MaxExtraDataSize)
+	}
+	if tree.MaxUnsequencedLeaves < 0 {
+		return status.Errorf(codes.InvalidArgument, "max_unsequenced_leaves negative: %v", tree.MaxUnsequencedLeaves)
+	}
+	if tree.CompactRetainedRevisions < 0 {
+		return status.Errorf(codes.InvalidArgument, "compact_retained_revisions negative: %v", tree.CompactRetainedRevisions)
+	}
 
 	// Implementations may vary, so let's assume storage_settings is mutable.
 	// Other than checking that it's a valid Any there isn't much to do at this layer, though.
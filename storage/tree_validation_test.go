@@ -44,6 +44,12 @@ func TestValidateTreeForCreation(t *testing.T) {
 	invalidType := newTree()
 	invalidType.TreeType = trillian.TreeType_UNKNOWN_TREE_TYPE
 
+	noDedup := newTree()
+	noDedup.LeafDedupPolicy = trillian.LeafDedupPolicy_NO_DEDUP
+
+	invalidDedup := newTree()
+	invalidDedup.LeafDedupPolicy = trillian.LeafDedupPolicy(-1)
+
 	invalidSettings := newTree()
 	invalidSettings.StorageSettings = &anypb.Any{Value: []byte("foobar")}
 
@@ -61,6 +67,32 @@ func TestValidateTreeForCreation(t *testing.T) {
 	invalidRootDuration := newTree()
 	invalidRootDuration.MaxRootDuration = durationpb.New(-1 * time.Second)
 
+	validSequencerSettings := newTree()
+	validSequencerSettings.SequencerGuardWindow = durationpb.New(5 * time.Second)
+	validSequencerSettings.SequencerBatchSize = 100
+
+	invalidGuardWindow := newTree()
+	invalidGuardWindow.SequencerGuardWindow = durationpb.New(-5 * time.Second)
+
+	invalidBatchSize := newTree()
+	invalidBatchSize.SequencerBatchSize = -1
+
+	validLeafLimits := newTree()
+	validLeafLimits.MaxLeafValueSize = 1024
+	validLeafLimits.MaxExtraDataSize = 256
+
+	invalidMaxLeafValueSize := newTree()
+	invalidMaxLeafValueSize.MaxLeafValueSize = -1
+
+	invalidMaxExtraDataSize := newTree()
+	invalidMaxExtraDataSize.MaxExtraDataSize = -1
+
+	validMaxUnsequencedLeaves := newTree()
+	validMaxUnsequencedLeaves.MaxUnsequencedLeaves = 1000
+
+	invalidMaxUnsequencedLeaves := newTree()
+	invalidMaxUnsequencedLeaves.MaxUnsequencedLeaves = -1
+
 	deletedTree := newTree()
 	deletedTree.Deleted = true
 
@@ -100,6 +132,15 @@ func TestValidateTreeForCreation(t *testing.T) {
 			tree:    invalidType,
 			wantErr: true,
 		},
+		{
+			desc: "noDedup",
+			tree: noDedup,
+		},
+		{
+			desc:    "invalidDedup",
+			tree:    invalidDedup,
+			wantErr: true,
+		},
 		{
 			desc:    "invalidSettings",
 			tree:    invalidSettings,
@@ -119,6 +160,43 @@ func TestValidateTreeForCreation(t *testing.T) {
 			tree:    invalidRootDuration,
 			wantErr: true,
 		},
+		{
+			desc: "validSequencerSettings",
+			tree: validSequencerSettings,
+		},
+		{
+			desc:    "invalidGuardWindow",
+			tree:    invalidGuardWindow,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidBatchSize",
+			tree:    invalidBatchSize,
+			wantErr: true,
+		},
+		{
+			desc: "validLeafLimits",
+			tree: validLeafLimits,
+		},
+		{
+			desc:    "invalidMaxLeafValueSize",
+			tree:    invalidMaxLeafValueSize,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidMaxExtraDataSize",
+			tree:    invalidMaxExtraDataSize,
+			wantErr: true,
+		},
+		{
+			desc: "validMaxUnsequencedLeaves",
+			tree: validMaxUnsequencedLeaves,
+		},
+		{
+			desc:    "invalidMaxUnsequencedLeaves",
+			tree:    invalidMaxUnsequencedLeaves,
+			wantErr: true,
+		},
 		{
 			desc:    "deletedTree",
 			tree:    deletedTree,
@@ -194,6 +272,96 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "validSequencerSettings",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SequencerGuardWindow = durationpb.New(5 * time.Second)
+				tree.SequencerBatchSize = 100
+			},
+		},
+		{
+			desc: "invalidGuardWindow",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SequencerGuardWindow = durationpb.New(-5 * time.Second)
+			},
+			wantErr: true,
+		},
+		{
+			desc: "validPriority",
+			updatefn: func(tree *trillian.Tree) {
+				tree.Priority = trillian.TreePriority_LOW_PRIORITY
+			},
+		},
+		{
+			desc: "invalidPriority",
+			updatefn: func(tree *trillian.Tree) {
+				tree.Priority = trillian.TreePriority(42)
+			},
+			wantErr: true,
+		},
+		{
+			desc: "validAffinity",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SignerAffinity = []string{"zone-a", "pool_1.x"}
+				tree.AntiAffinityGroup = "hot"
+			},
+		},
+		{
+			desc: "invalidSignerAffinity",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SignerAffinity = []string{"a,b"}
+			},
+			wantErr: true,
+		},
+		{
+			desc: "emptySignerAffinity",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SignerAffinity = []string{""}
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidAntiAffinityGroup",
+			updatefn: func(tree *trillian.Tree) {
+				tree.AntiAffinityGroup = "-"
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidBatchSize",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SequencerBatchSize = -1
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidMaxLeafValueSize",
+			updatefn: func(tree *trillian.Tree) {
+				tree.MaxLeafValueSize = -1
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidMaxExtraDataSize",
+			updatefn: func(tree *trillian.Tree) {
+				tree.MaxExtraDataSize = -1
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidMaxUnsequencedLeaves",
+			updatefn: func(tree *trillian.Tree) {
+				tree.MaxUnsequencedLeaves = -1
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidCompactRetainedRevisions",
+			updatefn: func(tree *trillian.Tree) {
+				tree.CompactRetainedRevisions = -1
+			},
+			wantErr: true,
+		},
 		// Changes on readonly fields
 		{
 			desc: "TreeId",
@@ -235,6 +403,13 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "LeafDedupPolicy",
+			updatefn: func(tree *trillian.Tree) {
+				tree.LeafDedupPolicy = trillian.LeafDedupPolicy_NO_DEDUP
+			},
+			wantErr: true,
+		},
 		{
 			desc: "CreateTime",
 			updatefn: func(tree *trillian.Tree) {
@@ -0,0 +1,451 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// FakeReply is a scripted outcome of an RPC of a fake client.
+type FakeReply struct {
+	// Delay is waited before replying. The call fails like a gRPC call if its
+	// context is done in the meantime.
+	Delay time.Duration
+	// Response is returned if Err is nil. It must be of the response type of
+	// the RPC, and defaults to an empty response.
+	Response proto.Message
+	// Err is returned if set.
+	Err error
+}
+
+// FakeHandler computes the reply of an RPC of a fake client from its request.
+type FakeHandler func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// fakeRPCs holds the scripts of the RPCs of a fake client, keyed by the RPC
+// method names, e.g. "QueueLeaf".
+type fakeRPCs struct {
+	mu       sync.Mutex
+	replies  map[string][]FakeReply
+	handlers map[string]FakeHandler
+	requests map[string][]proto.Message
+}
+
+// Script appends replies to those of the given RPC method. Each call of the
+// method consumes the next reply. Once they are exhausted, the calls go to the
+// handler of the method, or fail with codes.Unimplemented if it has none.
+func (f *fakeRPCs) Script(method string, replies ...FakeReply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.replies == nil {
+		f.replies = make(map[string][]FakeReply)
+	}
+	f.replies[method] = append(f.replies[method], replies...)
+}
+
+// Handle sets the handler of the calls of the given RPC method which aren't
+// scripted.
+func (f *fakeRPCs) Handle(method string, h FakeHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.handlers == nil {
+		f.handlers = make(map[string]FakeHandler)
+	}
+	f.handlers[method] = h
+}
+
+// Requests returns the requests received by the given RPC method so far, in
+// order.
+func (f *fakeRPCs) Requests(method string) []proto.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]proto.Message(nil), f.requests[method]...)
+}
+
+// call returns the reply to req, which is of the type of resp unless it's an
+// error.
+func (f *fakeRPCs) call(ctx context.Context, method string, req, resp proto.Message) (proto.Message, error) {
+	f.mu.Lock()
+	if f.requests == nil {
+		f.requests = make(map[string][]proto.Message)
+	}
+	f.requests[method] = append(f.requests[method], req)
+	var reply FakeReply
+	var handler FakeHandler
+	if replies := f.replies[method]; len(replies) > 0 {
+		reply, f.replies[method] = replies[0], replies[1:]
+	} else if handler = f.handlers[method]; handler == nil {
+		reply.Err = status.Errorf(codes.Unimplemented, "fake: no reply for %s", method)
+	}
+	f.mu.Unlock()
+
+	if handler != nil {
+		reply.Response, reply.Err = handler(ctx, req)
+	}
+	if reply.Delay > 0 {
+		timer := time.NewTimer(reply.Delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	} else if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	if reply.Response == nil {
+		return resp, nil
+	}
+	if got, want := reflect.TypeOf(reply.Response), reflect.TypeOf(resp); got != want {
+		return nil, status.Errorf(codes.Internal, "fake: reply of %s is a %v, want %v", method, got, want)
+	}
+	return reply.Response, nil
+}
+
+// FakeLogClient is a TrillianLogClient whose replies are scripted per RPC,
+// for testing the retries and verifications of personalities without a log
+// server. It is safe for concurrent use.
+type FakeLogClient struct {
+	fakeRPCs
+}
+
+var _ trillian.TrillianLogClient = &FakeLogClient{}
+
+// QueueLeaf implements TrillianLogClient.
+func (f *FakeLogClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, _ ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	resp, err := f.call(ctx, "QueueLeaf", in, &trillian.QueueLeafResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.QueueLeafResponse), nil
+}
+
+// QueueLeaves implements TrillianLogClient. Each request sent on the stream is
+// a call of the "QueueLeaves" method, whose reply is received in turn.
+func (f *FakeLogClient) QueueLeaves(ctx context.Context, _ ...grpc.CallOption) (trillian.TrillianLog_QueueLeavesClient, error) {
+	return &fakeQueueLeavesClient{ctx: ctx, f: f}, nil
+}
+
+// GetInclusionProof implements TrillianLogClient.
+func (f *FakeLogClient) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, _ ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	resp, err := f.call(ctx, "GetInclusionProof", in, &trillian.GetInclusionProofResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetInclusionProofResponse), nil
+}
+
+// GetInclusionProofByHash implements TrillianLogClient.
+func (f *FakeLogClient) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, _ ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	resp, err := f.call(ctx, "GetInclusionProofByHash", in, &trillian.GetInclusionProofByHashResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetInclusionProofByHashResponse), nil
+}
+
+// GetConsistencyProof implements TrillianLogClient.
+func (f *FakeLogClient) GetConsistencyProof(ctx context.Context, in *trillian.GetConsistencyProofRequest, _ ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	resp, err := f.call(ctx, "GetConsistencyProof", in, &trillian.GetConsistencyProofResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetConsistencyProofResponse), nil
+}
+
+// GetLatestSignedLogRoot implements TrillianLogClient.
+func (f *FakeLogClient) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, _ ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	resp, err := f.call(ctx, "GetLatestSignedLogRoot", in, &trillian.GetLatestSignedLogRootResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLatestSignedLogRootResponse), nil
+}
+
+// GetEntryAndProof implements TrillianLogClient.
+func (f *FakeLogClient) GetEntryAndProof(ctx context.Context, in *trillian.GetEntryAndProofRequest, _ ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
+	resp, err := f.call(ctx, "GetEntryAndProof", in, &trillian.GetEntryAndProofResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetEntryAndProofResponse), nil
+}
+
+// InitLog implements TrillianLogClient.
+func (f *FakeLogClient) InitLog(ctx context.Context, in *trillian.InitLogRequest, _ ...grpc.CallOption) (*trillian.InitLogResponse, error) {
+	resp, err := f.call(ctx, "InitLog", in, &trillian.InitLogResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.InitLogResponse), nil
+}
+
+// AddSequencedLeaves implements TrillianLogClient.
+func (f *FakeLogClient) AddSequencedLeaves(ctx context.Context, in *trillian.AddSequencedLeavesRequest, _ ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	resp, err := f.call(ctx, "AddSequencedLeaves", in, &trillian.AddSequencedLeavesResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.AddSequencedLeavesResponse), nil
+}
+
+// GetLeavesByRange implements TrillianLogClient.
+func (f *FakeLogClient) GetLeavesByRange(ctx context.Context, in *trillian.GetLeavesByRangeRequest, _ ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error) {
+	resp, err := f.call(ctx, "GetLeavesByRange", in, &trillian.GetLeavesByRangeResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByRangeResponse), nil
+}
+
+// GetImportProgress implements TrillianLogClient.
+func (f *FakeLogClient) GetImportProgress(ctx context.Context, in *trillian.GetImportProgressRequest, _ ...grpc.CallOption) (*trillian.GetImportProgressResponse, error) {
+	resp, err := f.call(ctx, "GetImportProgress", in, &trillian.GetImportProgressResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetImportProgressResponse), nil
+}
+
+// GetCompactRange implements TrillianLogClient.
+func (f *FakeLogClient) GetCompactRange(ctx context.Context, in *trillian.GetCompactRangeRequest, _ ...grpc.CallOption) (*trillian.GetCompactRangeResponse, error) {
+	resp, err := f.call(ctx, "GetCompactRange", in, &trillian.GetCompactRangeResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetCompactRangeResponse), nil
+}
+
+// GetLeavesByHash implements TrillianLogClient.
+func (f *FakeLogClient) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest, _ ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
+	resp, err := f.call(ctx, "GetLeavesByHash", in, &trillian.GetLeavesByHashResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByHashResponse), nil
+}
+
+// GetLeavesByIndexList implements TrillianLogClient.
+func (f *FakeLogClient) GetLeavesByIndexList(ctx context.Context, in *trillian.GetLeavesByIndexListRequest, _ ...grpc.CallOption) (*trillian.GetLeavesByIndexListResponse, error) {
+	resp, err := f.call(ctx, "GetLeavesByIndexList", in, &trillian.GetLeavesByIndexListResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByIndexListResponse), nil
+}
+
+// GetSignedLogRootAtTime implements TrillianLogClient.
+func (f *FakeLogClient) GetSignedLogRootAtTime(ctx context.Context, in *trillian.GetSignedLogRootAtTimeRequest, _ ...grpc.CallOption) (*trillian.GetSignedLogRootAtTimeResponse, error) {
+	resp, err := f.call(ctx, "GetSignedLogRootAtTime", in, &trillian.GetSignedLogRootAtTimeResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetSignedLogRootAtTimeResponse), nil
+}
+
+// StreamLeaves implements TrillianLogClient. Each response received on the
+// stream is the reply of a call of the "StreamLeaves" method with the request
+// of the stream.
+func (f *FakeLogClient) StreamLeaves(ctx context.Context, in *trillian.StreamLeavesRequest, _ ...grpc.CallOption) (trillian.TrillianLog_StreamLeavesClient, error) {
+	return &fakeStreamLeavesClient{ctx: ctx, f: f, req: in}, nil
+}
+
+// fakeQueueLeavesClient is a QueueLeaves stream of a FakeLogClient.
+type fakeQueueLeavesClient struct {
+	ctx    context.Context
+	f      *FakeLogClient
+	mu     sync.Mutex
+	resps  []*trillian.QueueLeavesResponse
+	errs   []error
+	closed bool
+}
+
+func (c *fakeQueueLeavesClient) Send(req *trillian.QueueLeavesRequest) error {
+	resp, err := c.f.call(c.ctx, "QueueLeaves", req, &trillian.QueueLeavesResponse{})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return status.Error(codes.Internal, "fake: Send after CloseSend")
+	}
+	r, _ := resp.(*trillian.QueueLeavesResponse)
+	c.resps = append(c.resps, r)
+	c.errs = append(c.errs, err)
+	return nil
+}
+
+func (c *fakeQueueLeavesClient) Recv() (*trillian.QueueLeavesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.resps) == 0 {
+		if c.closed {
+			return nil, io.EOF
+		}
+		return nil, status.Error(codes.Internal, "fake: Recv without a pending request")
+	}
+	resp, err := c.resps[0], c.errs[0]
+	c.resps, c.errs = c.resps[1:], c.errs[1:]
+	return resp, err
+}
+
+func (c *fakeQueueLeavesClient) CloseSend() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeQueueLeavesClient) Header() (metadata.MD, error) { return nil, nil }
+func (c *fakeQueueLeavesClient) Trailer() metadata.MD         { return nil }
+func (c *fakeQueueLeavesClient) Context() context.Context     { return c.ctx }
+
+func (c *fakeQueueLeavesClient) SendMsg(m interface{}) error {
+	req, ok := m.(*trillian.QueueLeavesRequest)
+	if !ok {
+		return fmt.Errorf("fake: SendMsg(%T), want a *QueueLeavesRequest", m)
+	}
+	return c.Send(req)
+}
+
+func (c *fakeQueueLeavesClient) RecvMsg(m interface{}) error {
+	out, ok := m.(*trillian.QueueLeavesResponse)
+	if !ok {
+		return fmt.Errorf("fake: RecvMsg(%T), want a *QueueLeavesResponse", m)
+	}
+	resp, err := c.Recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, resp)
+	return nil
+}
+
+// fakeStreamLeavesClient is a StreamLeaves stream of a FakeLogClient.
+type fakeStreamLeavesClient struct {
+	ctx context.Context
+	f   *FakeLogClient
+	req *trillian.StreamLeavesRequest
+}
+
+func (c *fakeStreamLeavesClient) Recv() (*trillian.StreamLeavesResponse, error) {
+	resp, err := c.f.call(c.ctx, "StreamLeaves", c.req, &trillian.StreamLeavesResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.StreamLeavesResponse), nil
+}
+
+func (c *fakeStreamLeavesClient) CloseSend() error             { return nil }
+func (c *fakeStreamLeavesClient) Header() (metadata.MD, error) { return nil, nil }
+func (c *fakeStreamLeavesClient) Trailer() metadata.MD         { return nil }
+func (c *fakeStreamLeavesClient) Context() context.Context     { return c.ctx }
+
+func (c *fakeStreamLeavesClient) SendMsg(m interface{}) error {
+	return status.Error(codes.Internal, "fake: SendMsg on a server-streaming call")
+}
+
+func (c *fakeStreamLeavesClient) RecvMsg(m interface{}) error {
+	out, ok := m.(*trillian.StreamLeavesResponse)
+	if !ok {
+		return fmt.Errorf("fake: RecvMsg(%T), want a *StreamLeavesResponse", m)
+	}
+	resp, err := c.Recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, resp)
+	return nil
+}
+
+// FakeAdminClient is a TrillianAdminClient whose replies are scripted per RPC,
+// like FakeLogClient.
+type FakeAdminClient struct {
+	fakeRPCs
+}
+
+var _ trillian.TrillianAdminClient = &FakeAdminClient{}
+
+// ListTrees implements TrillianAdminClient.
+func (f *FakeAdminClient) ListTrees(ctx context.Context, in *trillian.ListTreesRequest, _ ...grpc.CallOption) (*trillian.ListTreesResponse, error) {
+	resp, err := f.call(ctx, "ListTrees", in, &trillian.ListTreesResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.ListTreesResponse), nil
+}
+
+// GetTree implements TrillianAdminClient.
+func (f *FakeAdminClient) GetTree(ctx context.Context, in *trillian.GetTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	return f.treeCall(ctx, "GetTree", in)
+}
+
+// CreateTree implements TrillianAdminClient.
+func (f *FakeAdminClient) CreateTree(ctx context.Context, in *trillian.CreateTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	return f.treeCall(ctx, "CreateTree", in)
+}
+
+// UpdateTree implements TrillianAdminClient.
+func (f *FakeAdminClient) UpdateTree(ctx context.Context, in *trillian.UpdateTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	return f.treeCall(ctx, "UpdateTree", in)
+}
+
+// DeleteTree implements TrillianAdminClient.
+func (f *FakeAdminClient) DeleteTree(ctx context.Context, in *trillian.DeleteTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	return f.treeCall(ctx, "DeleteTree", in)
+}
+
+// UndeleteTree implements TrillianAdminClient.
+func (f *FakeAdminClient) UndeleteTree(ctx context.Context, in *trillian.UndeleteTreeRequest, _ ...grpc.CallOption) (*trillian.Tree, error) {
+	return f.treeCall(ctx, "UndeleteTree", in)
+}
+
+// ScrubTree implements TrillianAdminClient.
+func (f *FakeAdminClient) ScrubTree(ctx context.Context, in *trillian.ScrubTreeRequest, _ ...grpc.CallOption) (*trillian.ScrubTreeResponse, error) {
+	resp, err := f.call(ctx, "ScrubTree", in, &trillian.ScrubTreeResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.ScrubTreeResponse), nil
+}
+
+// GetTreeUsage implements TrillianAdminClient.
+func (f *FakeAdminClient) GetTreeUsage(ctx context.Context, in *trillian.GetTreeUsageRequest, _ ...grpc.CallOption) (*trillian.TreeUsage, error) {
+	resp, err := f.call(ctx, "GetTreeUsage", in, &trillian.TreeUsage{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.TreeUsage), nil
+}
+
+func (f *FakeAdminClient) treeCall(ctx context.Context, method string, in proto.Message) (*trillian.Tree, error) {
+	resp, err := f.call(ctx, method, in, &trillian.Tree{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.Tree), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFakeLogClient(t *testing.T) {
+	ctx := context.Background()
+	root := &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: []byte("root")}}
+	var f FakeLogClient
+	f.Script("GetLatestSignedLogRoot",
+		FakeReply{Err: status.Error(codes.Unavailable, "down")},
+		FakeReply{Response: root},
+		FakeReply{Response: &trillian.QueueLeafResponse{}},
+		FakeReply{Delay: time.Minute})
+	f.Handle("GetLatestSignedLogRoot", func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return &trillian.GetLatestSignedLogRootResponse{}, nil
+	})
+
+	req := &trillian.GetLatestSignedLogRootRequest{LogId: 1}
+	if _, err := f.GetLatestSignedLogRoot(ctx, req); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetLatestSignedLogRoot(1) = %v, want code %v", err, codes.Unavailable)
+	}
+	if got, err := f.GetLatestSignedLogRoot(ctx, req); err != nil || !proto.Equal(got, root) {
+		t.Errorf("GetLatestSignedLogRoot(2) = %v, %v, want %v, nil", got, err, root)
+	}
+	if _, err := f.GetLatestSignedLogRoot(ctx, req); status.Code(err) != codes.Internal {
+		t.Errorf("GetLatestSignedLogRoot(3) = %v, want code %v", err, codes.Internal)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.GetLatestSignedLogRoot(cctx, req); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("GetLatestSignedLogRoot(4) = %v, want code %v", err, codes.DeadlineExceeded)
+	}
+	if got, err := f.GetLatestSignedLogRoot(ctx, req); err != nil || got.SignedLogRoot != nil {
+		t.Errorf("GetLatestSignedLogRoot(5) = %v, %v, want the handler's reply", got, err)
+	}
+	if got := len(f.Requests("GetLatestSignedLogRoot")); got != 5 {
+		t.Errorf("Requests() returned %d requests, want 5", got)
+	}
+
+	// Unscripted RPCs fail.
+	if _, err := f.QueueLeaf(ctx, &trillian.QueueLeafRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("QueueLeaf() = %v, want code %v", err, codes.Unimplemented)
+	}
+}
+
+func TestFakeLogClientQueueLeaves(t *testing.T) {
+	ctx := context.Background()
+	var f FakeLogClient
+	f.Script("QueueLeaves", FakeReply{}, FakeReply{Err: status.Error(codes.ResourceExhausted, "quota")})
+
+	stream, err := f.QueueLeaves(ctx)
+	if err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(&trillian.QueueLeavesRequest{LogId: 1}); err != nil {
+			t.Fatalf("Send(%d) = %v", i, err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() = %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Errorf("Recv(0) = %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Recv(1) = %v, want code %v", err, codes.ResourceExhausted)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Recv(2) = %v, want EOF", err)
+	}
+}
+
+func TestFakeAdminClient(t *testing.T) {
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 12}
+	var f FakeAdminClient
+	f.Script("CreateTree", FakeReply{Response: tree})
+
+	if got, err := f.CreateTree(ctx, &trillian.CreateTreeRequest{}); err != nil || !proto.Equal(got, tree) {
+		t.Errorf("CreateTree() = %v, %v, want %v, nil", got, err, tree)
+	}
+	if _, err := f.GetTree(ctx, &trillian.GetTreeRequest{TreeId: 12}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetTree() = %v, want code %v", err, codes.Unimplemented)
+	}
+}
+
+func TestFakeLog(t *testing.T) {
+	ctx := context.Background()
+	l := NewFakeLog(5)
+	l.MaxCount = 2
+	f := l.Client()
+
+	resp, err := f.QueueLeaf(ctx, &trillian.QueueLeafRequest{Leaf: &trillian.LogLeaf{LeafValue: []byte("leaf 2"), MerkleLeafHash: l.Tree.LeafHash(2)}})
+	if err != nil || codes.Code(resp.QueuedLeaf.GetStatus().GetCode()) != codes.AlreadyExists {
+		t.Errorf("QueueLeaf() of a duplicate = %v, %v, want status %v", resp, err, codes.AlreadyExists)
+	}
+	if got, want := l.Tree.Size(), uint64(5); got != want {
+		t.Errorf("tree size %d after a duplicate, want %d", got, want)
+	}
+	leaves, err := f.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{StartIndex: 3, Count: 5})
+	if err != nil || len(leaves.Leaves) != 2 || leaves.Leaves[0].LeafIndex != 3 {
+		t.Errorf("GetLeavesByRange() = %v, %v, want leaves 3 and 4", leaves, err)
+	}
+	if _, err := f.GetCompactRange(ctx, &trillian.GetCompactRangeRequest{Begin: 2, End: 6}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetCompactRange() past the tree = %v, want code %v", err, codes.InvalidArgument)
+	}
+	// Other RPCs aren't handled by the log.
+	if _, err := f.GetEntryAndProof(ctx, &trillian.GetEntryAndProofRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetEntryAndProof() = %v, want code %v", err, codes.Unimplemented)
+	}
+}
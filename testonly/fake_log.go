@@ -0,0 +1,201 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+	inmemory "github.com/transparency-dev/merkle/testonly"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// FakeLog is an RFC 6962 log built in memory, which serves the reads and
+// writes of a FakeLogClient. Its roots and proofs are computed from the leaf
+// values as they were added, so tests can tamper with Leaves to check that
+// clients detect it.
+type FakeLog struct {
+	// Tree holds the Merkle tree of the added leaves.
+	Tree *inmemory.Tree
+	// Leaves are returned by GetLeavesByRange. They must not be changed while
+	// the log serves RPCs.
+	Leaves []*trillian.LogLeaf
+	// MaxCount, if positive, caps the number of leaves returned by each call
+	// of GetLeavesByRange.
+	MaxCount int64
+
+	mu sync.Mutex
+}
+
+// NewFakeLog returns a FakeLog holding size leaves, with the values
+// "leaf 0", "leaf 1" and so on.
+func NewFakeLog(size int) *FakeLog {
+	l := &FakeLog{Tree: inmemory.New(rfc6962.DefaultHasher)}
+	for i := 0; i < size; i++ {
+		l.Add([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return l
+}
+
+// Client returns a FakeLogClient whose calls of the log RPCs implemented by
+// FakeLog are handled by l. Other RPCs can be scripted on it as usual.
+func (l *FakeLog) Client() *FakeLogClient {
+	f := &FakeLogClient{}
+	f.Handle("QueueLeaf", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return l.QueueLeaf(req.(*trillian.QueueLeafRequest))
+	})
+	f.Handle("GetLatestSignedLogRoot", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return l.GetLatestSignedLogRoot(req.(*trillian.GetLatestSignedLogRootRequest))
+	})
+	f.Handle("GetInclusionProofByHash", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return l.GetInclusionProofByHash(req.(*trillian.GetInclusionProofByHashRequest))
+	})
+	f.Handle("GetConsistencyProof", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return l.GetConsistencyProof(req.(*trillian.GetConsistencyProofRequest))
+	})
+	f.Handle("GetLeavesByRange", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return l.GetLeavesByRange(req.(*trillian.GetLeavesByRangeRequest))
+	})
+	f.Handle("GetCompactRange", func(_ context.Context, req proto.Message) (proto.Message, error) {
+		return l.GetCompactRange(req.(*trillian.GetCompactRangeRequest))
+	})
+	return f
+}
+
+// Add appends a leaf with the given value to the log.
+func (l *FakeLog) Add(value []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(value)
+}
+
+func (l *FakeLog) add(value []byte) *trillian.LogLeaf {
+	l.Tree.AppendData(value)
+	leaf := &trillian.LogLeaf{
+		LeafValue:      value,
+		MerkleLeafHash: rfc6962.DefaultHasher.HashLeaf(value),
+		LeafIndex:      int64(len(l.Leaves)),
+	}
+	l.Leaves = append(l.Leaves, leaf)
+	return leaf
+}
+
+// QueueLeaf appends the leaf to the log, unless a leaf with the same Merkle
+// leaf hash is already in it.
+func (l *FakeLog) QueueLeaf(req *trillian.QueueLeafRequest) (*trillian.QueueLeafResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, leaf := range l.Leaves {
+		if bytes.Equal(leaf.MerkleLeafHash, req.Leaf.MerkleLeafHash) {
+			return &trillian.QueueLeafResponse{QueuedLeaf: &trillian.QueuedLogLeaf{Leaf: leaf, Status: &spb.Status{Code: int32(codes.AlreadyExists)}}}, nil
+		}
+	}
+	return &trillian.QueueLeafResponse{QueuedLeaf: &trillian.QueuedLogLeaf{Leaf: l.add(req.Leaf.LeafValue)}}, nil
+}
+
+// GetLatestSignedLogRoot returns an unsigned root of the whole log, with the
+// tree size as its timestamp, and a consistency proof from FirstTreeSize if it
+// is set.
+func (l *FakeLog) GetLatestSignedLogRoot(req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	size := l.Tree.Size()
+	root, err := (&types.LogRootV1{TreeSize: size, RootHash: l.Tree.Hash(), TimestampNanos: size}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	resp := &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: root}}
+	if first := uint64(req.FirstTreeSize); first > 0 && first <= size {
+		hashes, err := l.Tree.ConsistencyProof(first, size)
+		if err != nil {
+			return nil, err
+		}
+		resp.Proof = &trillian.Proof{Hashes: hashes}
+	}
+	return resp, nil
+}
+
+// GetInclusionProofByHash returns the inclusion proof of the first leaf with
+// the given hash, if it is within the requested tree size.
+func (l *FakeLog) GetInclusionProofByHash(req *trillian.GetInclusionProofByHashRequest) (*trillian.GetInclusionProofByHashResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := uint64(0); i < l.Tree.Size() && int64(i) < req.TreeSize; i++ {
+		if bytes.Equal(l.Tree.LeafHash(i), req.LeafHash) {
+			hashes, err := l.Tree.InclusionProof(i, uint64(req.TreeSize))
+			if err != nil {
+				return nil, err
+			}
+			return &trillian.GetInclusionProofByHashResponse{Proof: []*trillian.Proof{{LeafIndex: int64(i), Hashes: hashes}}}, nil
+		}
+	}
+	return &trillian.GetInclusionProofByHashResponse{}, nil
+}
+
+// GetConsistencyProof returns the consistency proof between two tree sizes.
+func (l *FakeLog) GetConsistencyProof(req *trillian.GetConsistencyProofRequest) (*trillian.GetConsistencyProofResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hashes, err := l.Tree.ConsistencyProof(uint64(req.FirstTreeSize), uint64(req.SecondTreeSize))
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetConsistencyProofResponse{Proof: &trillian.Proof{Hashes: hashes}}, nil
+}
+
+// GetLeavesByRange returns the requested Leaves, at most MaxCount of them if
+// it is positive.
+func (l *FakeLog) GetLeavesByRange(req *trillian.GetLeavesByRangeRequest) (*trillian.GetLeavesByRangeResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	count := req.Count
+	if l.MaxCount > 0 && count > l.MaxCount {
+		count = l.MaxCount
+	}
+	end := req.StartIndex + count
+	if end > int64(len(l.Leaves)) {
+		end = int64(len(l.Leaves))
+	}
+	if req.StartIndex >= end {
+		return &trillian.GetLeavesByRangeResponse{}, nil
+	}
+	return &trillian.GetLeavesByRangeResponse{Leaves: l.Leaves[req.StartIndex:end]}, nil
+}
+
+// GetCompactRange returns the hashes of the compact range [Begin, End).
+func (l *FakeLog) GetCompactRange(req *trillian.GetCompactRangeRequest) (*trillian.GetCompactRangeResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if req.Begin < 0 || req.Begin > req.End || uint64(req.End) > l.Tree.Size() {
+		return nil, status.Errorf(codes.InvalidArgument, "fake: range [%d, %d) of a tree of size %d", req.Begin, req.End, l.Tree.Size())
+	}
+	rf := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+	cr := rf.NewEmptyRange(uint64(req.Begin))
+	for i := req.Begin; i < req.End; i++ {
+		if err := cr.Append(l.Tree.LeafHash(uint64(i)), nil); err != nil {
+			return nil, err
+		}
+	}
+	return &trillian.GetCompactRangeResponse{Hashes: cr.Hashes()}, nil
+}
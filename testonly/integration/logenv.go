@@ -42,13 +42,27 @@ import (
 )
 
 var (
-	sequencerWindow = time.Duration(0)
-	batchSize       = 50
+	batchSize = 50
 	// SequencerInterval is the time between runs of the sequencer.
 	SequencerInterval = 500 * time.Millisecond
-	timeSource        = clock.System
 )
 
+// LogEnvOptions holds the optional settings of a LogEnv.
+type LogEnvOptions struct {
+	// TimeSource is used by the log server and the sequencer, so that tests can
+	// simulate the passing of time, e.g. with a clock.FakeTimeSource which is
+	// moved past the SequencerInterval for each run of the sequencer.
+	// clock.System is used if nil.
+	TimeSource clock.TimeSource
+	// SequencerGuardWindow is the time leaves stay queued before they can be
+	// sequenced, unless the tree sets its own.
+	SequencerGuardWindow time.Duration
+	// ServerOptions are additional options of the gRPC server.
+	ServerOptions []grpc.ServerOption
+	// ClientOptions replace the default options of the client connection.
+	ClientOptions []grpc.DialOption
+}
+
 // LogEnv is a test environment that contains both a log server and a connection to it.
 type LogEnv struct {
 	registry        extension.Registry
@@ -116,8 +130,20 @@ func NewLogEnvWithRegistry(ctx context.Context, numSequencers int, registry exte
 
 // NewLogEnvWithRegistryAndGRPCOptions works the same way as NewLogEnv, but allows callers to also set additional grpc.ServerOption and grpc.DialOption values.
 func NewLogEnvWithRegistryAndGRPCOptions(ctx context.Context, numSequencers int, registry extension.Registry, serverOpts []grpc.ServerOption, clientOpts []grpc.DialOption) (*LogEnv, error) {
+	return NewLogEnvWithOptions(ctx, numSequencers, registry, LogEnvOptions{ServerOptions: serverOpts, ClientOptions: clientOpts})
+}
+
+// NewLogEnvWithOptions uses the passed in Registry to create a log server, and
+// client, configured with opts. The numSequencers parameter indicates how many
+// sequencers to run in parallel.
+func NewLogEnvWithOptions(ctx context.Context, numSequencers int, registry extension.Registry, opts LogEnvOptions) (*LogEnv, error) {
+	timeSource := opts.TimeSource
+	if timeSource == nil {
+		timeSource = clock.System
+	}
+
 	// Create the GRPC Server.
-	serverOpts = append(serverOpts, grpc.UnaryInterceptor(interceptor.ErrorWrapper))
+	serverOpts := append(opts.ServerOptions, grpc.UnaryInterceptor(interceptor.ErrorWrapper), grpc.StreamInterceptor(interceptor.StreamErrorWrapper))
 	grpcServer := grpc.NewServer(serverOpts...)
 
 	// Setup the Admin Server.
@@ -129,7 +155,7 @@ func NewLogEnvWithRegistryAndGRPCOptions(ctx context.Context, numSequencers int,
 	trillian.RegisterTrillianLogServer(grpcServer, logServer)
 
 	// Create Sequencer.
-	sequencerManager := log.NewSequencerManager(registry, sequencerWindow)
+	sequencerManager := log.NewSequencerManager(registry, opts.SequencerGuardWindow)
 	var wg sync.WaitGroup
 	var sequencerTask *log.OperationManager
 	ctx, cancel := context.WithCancel(ctx)
@@ -164,6 +190,7 @@ func NewLogEnvWithRegistryAndGRPCOptions(ctx context.Context, numSequencers int,
 	}(&wg, grpcServer, lis)
 
 	// Connect to the server.
+	clientOpts := opts.ClientOptions
 	if clientOpts == nil {
 		clientOpts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
 	}
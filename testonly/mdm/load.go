@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdm
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Arrival processes of the operations of a MergeDelayMonitor.
+const (
+	// ArrivalClosed runs ParallelAdds workers, each submitting a leaf as soon
+	// as the previous one is merged.
+	ArrivalClosed = "closed"
+	// ArrivalPoisson starts operations at exponentially distributed
+	// intervals, regardless of how long the previous ones take.
+	ArrivalPoisson = "poisson"
+	// ArrivalOnOff is a Poisson process which alternates between OnPeriod
+	// with arrivals and OffPeriod without, for bursty traffic.
+	ArrivalOnOff = "onoff"
+)
+
+// Distributions of the sizes of the leaves submitted by a MergeDelayMonitor.
+const (
+	// LeafSizeFixed uses LeafSize bytes for every leaf.
+	LeafSizeFixed = "fixed"
+	// LeafSizeUniform draws sizes uniformly between 1 and 2*LeafSize-1 bytes.
+	LeafSizeUniform = "uniform"
+	// LeafSizeExponential draws sizes exponentially distributed with mean
+	// LeafSize bytes, of at least 1 byte.
+	LeafSizeExponential = "exponential"
+)
+
+// arrivalProcess returns the time between the operations of an open-loop
+// load.
+type arrivalProcess interface {
+	// next returns the time until the next arrival, given the time elapsed
+	// since the start of the load.
+	next(rng *rand.Rand, elapsed time.Duration) time.Duration
+}
+
+// newArrivalProcess returns the arrival process described by opts, or nil if
+// the load is closed-loop.
+func newArrivalProcess(opts MergeDelayOptions) (arrivalProcess, error) {
+	switch opts.Arrival {
+	case "", ArrivalClosed:
+		return nil, nil
+	case ArrivalPoisson:
+		if opts.Rate <= 0 {
+			return nil, fmt.Errorf("%s arrivals need a positive rate, got %v", opts.Arrival, opts.Rate)
+		}
+		return poisson{rate: opts.Rate}, nil
+	case ArrivalOnOff:
+		if opts.Rate <= 0 {
+			return nil, fmt.Errorf("%s arrivals need a positive rate, got %v", opts.Arrival, opts.Rate)
+		}
+		if opts.OnPeriod <= 0 || opts.OffPeriod < 0 {
+			return nil, fmt.Errorf("%s arrivals need a positive on period and a non-negative off period, got %v and %v", opts.Arrival, opts.OnPeriod, opts.OffPeriod)
+		}
+		return onOff{poisson: poisson{rate: opts.Rate}, on: opts.OnPeriod, off: opts.OffPeriod}, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival process %q", opts.Arrival)
+	}
+}
+
+// poisson is a Poisson process with the given rate per second.
+type poisson struct {
+	rate float64
+}
+
+func (p poisson) next(rng *rand.Rand, _ time.Duration) time.Duration {
+	return time.Duration(rng.ExpFloat64() / p.rate * float64(time.Second))
+}
+
+// onOff is a Poisson process which only runs during the on periods.
+type onOff struct {
+	poisson
+	on, off time.Duration
+}
+
+func (p onOff) next(rng *rand.Rand, elapsed time.Duration) time.Duration {
+	// The gap of the Poisson process is spent in on periods only.
+	gap := p.poisson.next(rng, elapsed)
+	cycle := p.on + p.off
+	at := elapsed
+	for {
+		pos := at % cycle
+		if pos >= p.on {
+			at += cycle - pos
+			continue
+		}
+		if left := p.on - pos; gap > left {
+			gap -= left
+			at += left
+			continue
+		}
+		return at + gap - elapsed
+	}
+}
+
+// checkLeafSizeDist returns an error if dist isn't a known leaf size
+// distribution.
+func checkLeafSizeDist(dist string) error {
+	switch dist {
+	case "", LeafSizeFixed, LeafSizeUniform, LeafSizeExponential:
+		return nil
+	default:
+		return fmt.Errorf("unknown leaf size distribution %q", dist)
+	}
+}
+
+// leafSize draws the size of a new leaf from the distribution dist with the
+// given mean.
+func leafSize(rng *rand.Rand, dist string, mean int) int {
+	switch dist {
+	case LeafSizeUniform:
+		if mean <= 1 {
+			return 1
+		}
+		return 1 + rng.Intn(2*mean-1)
+	case LeafSizeExponential:
+		if size := int(rng.ExpFloat64() * float64(mean)); size > 0 {
+			return size
+		}
+		return 1
+	default:
+		return mean
+	}
+}
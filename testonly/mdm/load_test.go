@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestArrivalProcesses(t *testing.T) {
+	const (
+		rate     = 100.0
+		arrivals = 20000
+	)
+	for _, tc := range []struct {
+		opts MergeDelayOptions
+		// wantRate is the expected overall number of arrivals per second.
+		wantRate float64
+	}{
+		{opts: MergeDelayOptions{Arrival: ArrivalPoisson, Rate: rate}, wantRate: rate},
+		{opts: MergeDelayOptions{Arrival: ArrivalOnOff, Rate: rate, OnPeriod: time.Second, OffPeriod: 3 * time.Second}, wantRate: rate / 4},
+	} {
+		t.Run(tc.opts.Arrival, func(t *testing.T) {
+			p, err := newArrivalProcess(tc.opts)
+			if err != nil {
+				t.Fatalf("newArrivalProcess() = %v", err)
+			}
+			rng := rand.New(rand.NewSource(1))
+			var elapsed time.Duration
+			for i := 0; i < arrivals; i++ {
+				gap := p.next(rng, elapsed)
+				if gap < 0 {
+					t.Fatalf("next(%v) = %v, want non-negative", elapsed, gap)
+				}
+				elapsed += gap
+				if tc.opts.OffPeriod > 0 {
+					if pos := elapsed % (tc.opts.OnPeriod + tc.opts.OffPeriod); pos > tc.opts.OnPeriod {
+						t.Fatalf("arrival at %v is in an off period", elapsed)
+					}
+				}
+			}
+			if got := arrivals / elapsed.Seconds(); math.Abs(got-tc.wantRate) > tc.wantRate*0.05 {
+				t.Errorf("got %v arrivals per second, want %v", got, tc.wantRate)
+			}
+		})
+	}
+}
+
+func TestNewArrivalProcessErrors(t *testing.T) {
+	for _, opts := range []MergeDelayOptions{
+		{Arrival: "unknown"},
+		{Arrival: ArrivalPoisson},
+		{Arrival: ArrivalOnOff, Rate: 1},
+		{Arrival: ArrivalOnOff, Rate: 1, OnPeriod: time.Second, OffPeriod: -time.Second},
+	} {
+		if _, err := newArrivalProcess(opts); err == nil {
+			t.Errorf("newArrivalProcess(%+v) = nil, want error", opts)
+		}
+	}
+	for _, arrival := range []string{"", ArrivalClosed} {
+		if p, err := newArrivalProcess(MergeDelayOptions{Arrival: arrival}); p != nil || err != nil {
+			t.Errorf("newArrivalProcess(%q) = %v, %v, want nil, nil", arrival, p, err)
+		}
+	}
+}
+
+func TestLeafSize(t *testing.T) {
+	const (
+		mean  = 500
+		count = 20000
+	)
+	rng := rand.New(rand.NewSource(1))
+	for _, dist := range []string{LeafSizeFixed, LeafSizeUniform, LeafSizeExponential} {
+		t.Run(dist, func(t *testing.T) {
+			if err := checkLeafSizeDist(dist); err != nil {
+				t.Fatalf("checkLeafSizeDist() = %v", err)
+			}
+			total := 0
+			for i := 0; i < count; i++ {
+				size := leafSize(rng, dist, mean)
+				if size < 1 {
+					t.Fatalf("leafSize() = %d, want positive", size)
+				}
+				total += size
+			}
+			if got := float64(total) / count; math.Abs(got-mean) > mean*0.05 {
+				t.Errorf("mean leaf size = %v, want %v", got, mean)
+			}
+		})
+	}
+	if err := checkLeafSizeDist("unknown"); err == nil {
+		t.Error("checkLeafSizeDist(unknown) = nil, want error")
+	}
+}
@@ -33,20 +33,41 @@ import (
 
 // MergeDelayMonitor submits leaves to a Log and measures merge delay.
 type MergeDelayMonitor struct {
-	client []*client.LogClient
-	logID  int64
-	opts   MergeDelayOptions
+	cl      trillian.TrillianLogClient
+	client  []*client.LogClient
+	logID   int64
+	opts    MergeDelayOptions
+	arrival arrivalProcess
+	stats   *opStats
 }
 
 // MergeDelayOptions holds the parameters for a MergeDelayMonitor.
 type MergeDelayOptions struct {
+	// ParallelAdds is the number of workers of a closed-loop load, and the
+	// maximum number of operations in flight of an open-loop one.
 	ParallelAdds  int
 	LeafSize      int
-	NewLeafChance int // percentage
+	LeafSizeDist  string // one of the LeafSize* distributions, fixed by default
+	NewLeafChance int    // percentage
 	EmitInterval  time.Duration
 	Deadline      time.Duration
 	MinMergeDelay time.Duration
 	MetricFactory monitoring.MetricFactory
+
+	// Arrival is one of the Arrival* processes, closed-loop by default. The
+	// other fields below only apply to open-loop loads.
+	Arrival string
+	// Rate is the mean number of operations started per second, during the
+	// on periods of ArrivalOnOff.
+	Rate                float64
+	OnPeriod, OffPeriod time.Duration
+	// ReadChance is the percentage of operations which read the log, rather
+	// than submit a leaf.
+	ReadChance int
+
+	// SLOs are checked by the Report of the run. If any are set, failed
+	// operations count against them rather than stop the run.
+	SLOs []SLO
 }
 
 // NewMonitor creates a MergeDelayMonitor instance for the given log ID, accessed
@@ -61,6 +82,13 @@ func NewMonitor(ctx context.Context, logID int64, cl trillian.TrillianLogClient,
 	if opts.Deadline <= 0 {
 		opts.Deadline = 60 * time.Second
 	}
+	arrival, err := newArrivalProcess(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLeafSizeDist(opts.LeafSizeDist); err != nil {
+		return nil, err
+	}
 	metricsOnce.Do(func() { initMetrics(opts.MetricFactory) })
 
 	tree, err := adminCl.GetTree(ctx, &trillian.GetTreeRequest{TreeId: logID})
@@ -77,25 +105,13 @@ func NewMonitor(ctx context.Context, logID int64, cl trillian.TrillianLogClient,
 		clients[i].MinMergeDelay = opts.MinMergeDelay
 	}
 
-	mdm := MergeDelayMonitor{logID: logID, opts: opts, client: clients}
+	mdm := MergeDelayMonitor{cl: cl, logID: logID, opts: opts, client: clients, arrival: arrival, stats: newOpStats()}
 	return &mdm, nil
 }
 
 // Monitor runs merge delay monitoring until its context is cancelled or an error occurs.
 func (m *MergeDelayMonitor) Monitor(ctx context.Context) error {
-	glog.Infof("starting %d parallel monitor instances", m.opts.ParallelAdds)
-	errs := make(chan error, m.opts.ParallelAdds)
-	var wg sync.WaitGroup
-	wg.Add(m.opts.ParallelAdds)
-	for i := 0; i < m.opts.ParallelAdds; i++ {
-		go func(i int) {
-			defer wg.Done()
-			if err := m.monitor(ctx, i); err != nil {
-				errs <- err
-			}
-		}(i)
-	}
-
+	m.stats.restart()
 	ticker := time.NewTicker(m.opts.EmitInterval)
 	defer ticker.Stop()
 	glog.V(1).Infof("start stats ticker every %v", m.opts.EmitInterval)
@@ -109,9 +125,33 @@ func (m *MergeDelayMonitor) Monitor(ctx context.Context) error {
 			if countF > 0 {
 				glog.Infof("dup leaves: %d in %f secs, average %f secs", countF, totalF, totalF/float64(countF))
 			}
+			logIDLabel := strconv.FormatInt(m.logID, 10)
+			if countR, totalR := readLatencyDist.Info(logIDLabel); countR > 0 {
+				glog.Infof("reads: %d in %f secs, average %f secs", countR, totalR, totalR/float64(countR))
+			}
+			if dropped := droppedOps.Value(logIDLabel); dropped > 0 {
+				glog.Infof("dropped operations: %v", dropped)
+			}
 		}
 	}(ticker.C)
 
+	if m.arrival != nil {
+		return m.runOpenLoop(ctx)
+	}
+
+	glog.Infof("starting %d parallel monitor instances", m.opts.ParallelAdds)
+	errs := make(chan error, m.opts.ParallelAdds)
+	var wg sync.WaitGroup
+	wg.Add(m.opts.ParallelAdds)
+	for i := 0; i < m.opts.ParallelAdds; i++ {
+		go func(i int) {
+			defer wg.Done()
+			if err := m.monitor(ctx, i); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
 	wg.Wait()
 	close(errs)
 	var lastErr error
@@ -123,39 +163,183 @@ func (m *MergeDelayMonitor) Monitor(ctx context.Context) error {
 }
 
 func (m *MergeDelayMonitor) monitor(ctx context.Context, idx int) error {
-	logIDLabel := strconv.FormatInt(m.logID, 10)
-	data := make([]byte, m.opts.LeafSize)
-	createNew := true // Always need a new leaf to start with
+	w := m.newWorker(idx)
 	for {
-		if rand.Intn(100) < m.opts.NewLeafChance {
-			createNew = true
+		if err := w.addLeaf(ctx); err != nil {
+			return err
 		}
-		if createNew {
-			rand.Read(data)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
 		}
+	}
+}
 
-		// Add the leaf data and wait for its inclusion.
-		glog.V(1).Infof("[%d] submit new=%t leaf and wait for inclusion (within %v)", idx, createNew, m.opts.Deadline)
-		cctx, cancel := context.WithTimeout(ctx, m.opts.Deadline)
-		start := time.Now()
-		err := m.client[idx].AddLeaf(cctx, data)
+// runOpenLoop starts operations following the arrival process of the
+// monitor, until its context is cancelled or an operation fails. Arrivals
+// finding ParallelAdds operations in flight are dropped, so that a slow log
+// doesn't slow down the load.
+func (m *MergeDelayMonitor) runOpenLoop(ctx context.Context) error {
+	glog.Infof("starting %s load of %v operations per second, with at most %d in flight", m.opts.Arrival, m.opts.Rate, m.opts.ParallelAdds)
+	logIDLabel := strconv.FormatInt(m.logID, 10)
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
 		cancel()
-		if err != nil {
-			return fmt.Errorf("failed to QueueLeaf: %v", err)
-		}
-		mergeDelay := time.Since(start)
-		mergeDelayDist.Observe(mergeDelay.Seconds(), logIDLabel, newLeafLabel[createNew])
-		glog.V(1).Infof("[%d] merge delay for new=%t leaf = %v", idx, createNew, mergeDelay)
+		wg.Wait()
+	}()
+
+	// Each worker is used by one operation at a time.
+	workers := make(chan *worker, m.opts.ParallelAdds)
+	for i := 0; i < m.opts.ParallelAdds; i++ {
+		workers <- m.newWorker(i)
+	}
+	errs := make(chan error, m.opts.ParallelAdds)
 
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	start := time.Now()
+	timer := time.NewTimer(m.arrival.next(rng, 0))
+	defer timer.Stop()
+	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case err := <-errs:
+			glog.Errorf("monitor failure: %v", err)
+			return err
+		case <-timer.C:
+		}
+		timer.Reset(m.arrival.next(rng, time.Since(start)))
+
+		read := rng.Intn(100) < m.opts.ReadChance
+		select {
+		case w := <-workers:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { workers <- w }()
+				op := w.addLeaf
+				if read {
+					op = w.read
+				}
+				if err := op(ctx); err != nil && ctx.Err() == nil {
+					errs <- err
+				}
+			}()
 		default:
+			droppedOps.Inc(logIDLabel)
 		}
-		createNew = false
 	}
 }
 
+// worker performs the operations of a monitor, one at a time.
+type worker struct {
+	m   *MergeDelayMonitor
+	idx int
+	rng *rand.Rand
+	// data is the value of the last leaf submitted, which is resubmitted
+	// unless a new leaf is chosen.
+	data []byte
+}
+
+func (m *MergeDelayMonitor) newWorker(idx int) *worker {
+	return &worker{m: m, idx: idx, rng: rand.New(rand.NewSource(time.Now().UnixNano() + int64(idx)))}
+}
+
+// addLeaf submits a new or duplicate leaf, and waits for its inclusion.
+func (w *worker) addLeaf(ctx context.Context) error {
+	m := w.m
+	logIDLabel := strconv.FormatInt(m.logID, 10)
+	// Always need a new leaf to start with.
+	createNew := w.data == nil || w.rng.Intn(100) < m.opts.NewLeafChance
+	if createNew {
+		w.data = make([]byte, leafSize(w.rng, m.opts.LeafSizeDist, m.opts.LeafSize))
+		w.rng.Read(w.data)
+	}
+
+	// Add the leaf data and wait for its inclusion.
+	glog.V(1).Infof("[%d] submit new=%t leaf and wait for inclusion (within %v)", w.idx, createNew, m.opts.Deadline)
+	cctx, cancel := context.WithTimeout(ctx, m.opts.Deadline)
+	defer cancel()
+	start := time.Now()
+	err := m.client[w.idx].QueueLeaf(cctx, w.data)
+	w.record(ctx, OpQueueLeaf, time.Since(start), err)
+	if err != nil {
+		return m.failed(fmt.Errorf("failed to QueueLeaf: %v", err))
+	}
+	err = m.client[w.idx].WaitForInclusion(cctx, w.data)
+	mergeDelay := time.Since(start)
+	w.record(ctx, OpMergeDelay, mergeDelay, err)
+	if err != nil {
+		return m.failed(fmt.Errorf("failed to WaitForInclusion: %v", err))
+	}
+	mergeDelayDist.Observe(mergeDelay.Seconds(), logIDLabel, newLeafLabel[createNew])
+	glog.V(1).Infof("[%d] merge delay for new=%t leaf = %v", w.idx, createNew, mergeDelay)
+	return nil
+}
+
+// read gets the latest root of the log, and a random leaf within it.
+func (w *worker) read(ctx context.Context) error {
+	m := w.m
+	logIDLabel := strconv.FormatInt(m.logID, 10)
+	start := time.Now()
+	err := w.readOnce(ctx)
+	latency := time.Since(start)
+	w.record(ctx, OpRead, latency, err)
+	if err != nil {
+		return m.failed(err)
+	}
+	readLatencyDist.Observe(latency.Seconds(), logIDLabel)
+	return nil
+}
+
+func (w *worker) readOnce(ctx context.Context) error {
+	m := w.m
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Deadline)
+	defer cancel()
+	resp, err := m.cl.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: m.logID})
+	if err != nil {
+		return fmt.Errorf("failed to GetLatestSignedLogRoot: %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(resp.GetSignedLogRoot().GetLogRoot()); err != nil {
+		return fmt.Errorf("failed to parse log root: %v", err)
+	}
+	if root.TreeSize > 0 {
+		index := w.rng.Int63n(int64(root.TreeSize))
+		if _, err := m.cl.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{LogId: m.logID, StartIndex: index, Count: 1}); err != nil {
+			return fmt.Errorf("failed to GetLeavesByRange: %v", err)
+		}
+	}
+	return nil
+}
+
+// record adds the outcome of an operation to the report of the run, unless
+// the operation was interrupted by the end of the run.
+func (w *worker) record(ctx context.Context, op string, latency time.Duration, err error) {
+	if ctx.Err() == nil {
+		w.m.stats.record(op, latency, err)
+	}
+}
+
+// failed returns the error of an operation if it stops the run, which it
+// doesn't if SLOs are checked: the errors then count against them.
+func (m *MergeDelayMonitor) failed(err error) error {
+	if len(m.opts.SLOs) > 0 {
+		glog.V(1).Infof("operation failed: %v", err)
+		return nil
+	}
+	return err
+}
+
+// Report returns the summary of the run so far, checked against the SLOs of
+// the monitor.
+func (m *MergeDelayMonitor) Report() *Report {
+	return m.stats.report(m.opts.SLOs)
+}
+
 // Stats returns the total count of requests and the total elapsed time across
 // all invocations.
 func (m *MergeDelayMonitor) Stats(newLeaf bool) (uint64, float64) {
@@ -164,11 +348,15 @@ func (m *MergeDelayMonitor) Stats(newLeaf bool) (uint64, float64) {
 }
 
 var (
-	metricsOnce    sync.Once
-	mergeDelayDist monitoring.Histogram
-	newLeafLabel   = map[bool]string{true: "true", false: "false"}
+	metricsOnce     sync.Once
+	mergeDelayDist  monitoring.Histogram
+	readLatencyDist monitoring.Histogram
+	droppedOps      monitoring.Counter
+	newLeafLabel    = map[bool]string{true: "true", false: "false"}
 )
 
 func initMetrics(mf monitoring.MetricFactory) {
 	mergeDelayDist = mf.NewHistogram("merge_delay", "Merge delay for submitted leaves", "log_id", "new_leaf")
+	readLatencyDist = mf.NewHistogram("read_latency", "Latency of the reads of open-loop loads", "log_id")
+	droppedOps = mf.NewCounter("dropped_operations", "Arrivals of open-loop loads dropped as too many operations were in flight", "log_id")
 }
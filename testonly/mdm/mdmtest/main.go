@@ -16,10 +16,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/golang/glog"
@@ -40,9 +44,18 @@ var (
 	rpcServer       = flag.String("rpc_server", "", "Trillian log server address:port")
 	adminServer     = flag.String("admin_server", "", "Trillian admin server address:port (defaults to --rpc_server value)")
 	metricsEndpoint = flag.String("metrics_endpoint", "", "Endpoint for serving metrics; if left empty, metrics will not be exposed")
-	leafSize        = flag.Uint("leaf_size", 500, "Size of leaf values")
+	leafSize        = flag.Uint("leaf_size", 500, "Size of leaf values, or their mean size with --leaf_size_dist")
+	leafSizeDist    = flag.String("leaf_size_dist", mdm.LeafSizeFixed, "Distribution of the sizes of leaf values: fixed, uniform or exponential")
 	newLeafChance   = flag.Uint("new_leaf_chance", 50, "Percentage chance of using a new leaf for each submission")
-	checkers        = flag.Int("checkers", 3, "Number of parallel checker goroutines to run")
+	checkers        = flag.Int("checkers", 3, "Number of parallel checker goroutines to run, or the maximum number of operations in flight of open-loop --arrival processes")
+	arrival         = flag.String("arrival", mdm.ArrivalClosed, "Arrival process of the operations: closed runs the checkers back-to-back, poisson and onoff start operations at --rate regardless of their completion")
+	rate            = flag.Float64("rate", 10, "Mean number of operations started per second by open-loop --arrival processes, during the on periods of onoff")
+	onPeriod        = flag.Duration("on_period", 10*time.Second, "Duration of the bursts of the onoff --arrival process")
+	offPeriod       = flag.Duration("off_period", 10*time.Second, "Duration of the pauses between the bursts of the onoff --arrival process")
+	readChance      = flag.Uint("read_chance", 0, "Percentage of the operations of open-loop --arrival processes which read the log rather than submit a leaf")
+	duration        = flag.Duration("duration", 0, "How long to run for; until interrupted if zero")
+	slos            = flag.String("slos", "", "Comma-separated SLOs checked over the run, e.g. \"queue_leaf:p99<200ms,merge_delay:p99<5s,read:error_rate<0.01\". If set, failed operations count against them rather than stop the run, and the run fails if any is violated")
+	reportFile      = flag.String("report_file", "", "File to write the JSON report of the run to, or - for stdout")
 	emitInterval    = flag.Duration("emit_interval", 10*time.Second, "How often to output the summary info")
 	deadline        = flag.Duration("deadline", 60*time.Second, "Deadline for single add+get-proof operation")
 	minMergeDelay   = flag.Duration("min_merge_delay", 3*time.Second, "Minimum merge delay; don't check for inclusion until this interval has passed")
@@ -114,11 +127,20 @@ func innerMain(ctx context.Context) error {
 	opts := mdm.MergeDelayOptions{
 		ParallelAdds:  *checkers,
 		LeafSize:      int(*leafSize),
+		LeafSizeDist:  *leafSizeDist,
 		NewLeafChance: int(*newLeafChance),
 		EmitInterval:  *emitInterval,
 		Deadline:      *deadline,
 		MinMergeDelay: *minMergeDelay,
 		MetricFactory: mf,
+		Arrival:       *arrival,
+		Rate:          *rate,
+		OnPeriod:      *onPeriod,
+		OffPeriod:     *offPeriod,
+		ReadChance:    int(*readChance),
+	}
+	if opts.SLOs, err = mdm.ParseSLOs(*slos); err != nil {
+		return err
 	}
 	monitor, err := mdm.NewMonitor(ctx, *logID, cl, adminCl, opts)
 	if err != nil {
@@ -127,9 +149,45 @@ func innerMain(ctx context.Context) error {
 
 	cctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	go util.AwaitSignal(ctx, cancel)
+	if *duration > 0 {
+		cctx, cancel = context.WithTimeout(cctx, *duration)
+		defer cancel()
+	}
+	go util.AwaitSignal(cctx, cancel)
 	if err := monitor.Monitor(cctx); err != nil {
 		return fmt.Errorf("merge delay monitoring failed: %v", err)
 	}
+
+	report := monitor.Report()
+	if *reportFile != "" {
+		if err := writeReport(*reportFile, report); err != nil {
+			return fmt.Errorf("failed to write report: %v", err)
+		}
+	}
+	for _, res := range report.SLOs {
+		if !res.Met {
+			glog.Errorf("SLO %s violated: %v", res.SLO, res.Value)
+		}
+	}
+	if !report.Passed {
+		return errors.New("SLOs violated")
+	}
 	return nil
 }
+
+// writeReport writes report as JSON to the given file, or stdout if it's "-".
+func writeReport(path string, report *mdm.Report) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	// Keep the SLOs, e.g. "queue_leaf:p99<200ms", readable.
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err := os.Stdout.Write(b.Bytes())
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations measured by a MergeDelayMonitor.
+const (
+	// OpQueueLeaf is the QueueLeaf call submitting a leaf.
+	OpQueueLeaf = "queue_leaf"
+	// OpMergeDelay is the time from the submission of a leaf to the
+	// verification of its inclusion.
+	OpMergeDelay = "merge_delay"
+	// OpRead is a read of the root of the log and of one of its leaves.
+	OpRead = "read"
+)
+
+// Metrics of the operations which SLOs can bound.
+const (
+	// MetricErrorRate is the fraction of the operations which failed.
+	MetricErrorRate = "error_rate"
+	// MetricMax is the latency of the slowest successful operation.
+	MetricMax = "max"
+)
+
+// SLO bounds a metric of an operation over a run, e.g. the 99th percentile
+// of the latency of OpQueueLeaf.
+type SLO struct {
+	// Op is one of the Op* operations.
+	Op string
+	// Metric is a latency percentile like "p99" or "p99.9", MetricMax, or
+	// MetricErrorRate.
+	Metric string
+	// Max is the bound of the metric, in seconds for latencies. The SLO is met
+	// if the metric is at most Max.
+	Max float64
+}
+
+// ParseSLOs parses a comma-separated list of SLOs of the form
+// <op>:<metric><<max>, e.g. "queue_leaf:p99<200ms,read:error_rate<0.01".
+// Latency bounds are durations, and error rate bounds fractions. Bounds are
+// inclusive, i.e. the metric may equal them.
+func ParseSLOs(s string) ([]SLO, error) {
+	var slos []SLO
+	for _, spec := range strings.Split(s, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		slo, err := parseSLO(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SLO %q: %v", spec, err)
+		}
+		slos = append(slos, slo)
+	}
+	return slos, nil
+}
+
+func parseSLO(spec string) (SLO, error) {
+	op, rest, ok := cut(spec, ":")
+	if !ok {
+		return SLO{}, fmt.Errorf("want <op>:<metric><<max>")
+	}
+	metric, max, ok := cut(rest, "<")
+	if !ok {
+		return SLO{}, fmt.Errorf("want <op>:<metric><<max>")
+	}
+	slo := SLO{Op: op, Metric: metric}
+	switch op {
+	case OpQueueLeaf, OpMergeDelay, OpRead:
+	default:
+		return SLO{}, fmt.Errorf("unknown operation %q", op)
+	}
+	if metric == MetricErrorRate {
+		v, err := strconv.ParseFloat(max, 64)
+		if err != nil || v < 0 || v > 1 {
+			return SLO{}, fmt.Errorf("error rate bound %q isn't a fraction", max)
+		}
+		slo.Max = v
+		return slo, nil
+	}
+	if _, err := percentile(metric); err != nil {
+		return SLO{}, err
+	}
+	d, err := time.ParseDuration(max)
+	if err != nil {
+		return SLO{}, fmt.Errorf("latency bound %q isn't a duration", max)
+	}
+	slo.Max = d.Seconds()
+	return slo, nil
+}
+
+// cut slices s around the first instance of sep.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// percentile returns the percentile of a latency metric, e.g. 99 for "p99".
+func percentile(metric string) (float64, error) {
+	if metric == MetricMax {
+		return 100, nil
+	}
+	if !strings.HasPrefix(metric, "p") {
+		return 0, fmt.Errorf("unknown metric %q", metric)
+	}
+	p, err := strconv.ParseFloat(metric[1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, fmt.Errorf("unknown metric %q", metric)
+	}
+	return p, nil
+}
+
+// Report summarizes a run of a MergeDelayMonitor, and the SLOs checked
+// against it.
+type Report struct {
+	Start    time.Time            `json:"start"`
+	Duration float64              `json:"duration_secs"`
+	Ops      map[string]*OpReport `json:"operations"`
+	SLOs     []SLOResult          `json:"slos,omitempty"`
+	// Passed is set if all the SLOs are met.
+	Passed bool `json:"passed"`
+}
+
+// OpReport summarizes the operations of one kind. Latencies are in seconds,
+// and only cover the successful operations.
+type OpReport struct {
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	P50       float64 `json:"p50"`
+	P90       float64 `json:"p90"`
+	P99       float64 `json:"p99"`
+	Max       float64 `json:"max"`
+
+	latencies []float64
+}
+
+// SLOResult is the outcome of an SLO over a run.
+type SLOResult struct {
+	SLO   string  `json:"slo"`
+	Value float64 `json:"value"`
+	Met   bool    `json:"met"`
+}
+
+func (slo SLO) String() string {
+	if slo.Metric == MetricErrorRate {
+		return fmt.Sprintf("%s:%s<%v", slo.Op, slo.Metric, slo.Max)
+	}
+	return fmt.Sprintf("%s:%s<%v", slo.Op, slo.Metric, time.Duration(slo.Max*float64(time.Second)))
+}
+
+// opStats accumulates the outcomes of the operations of a run.
+type opStats struct {
+	mu    sync.Mutex
+	start time.Time
+	ops   map[string]*OpReport
+}
+
+func newOpStats() *opStats {
+	return &opStats{start: time.Now(), ops: make(map[string]*OpReport)}
+}
+
+// restart drops the outcomes so far, and restarts the clock of the run.
+func (s *opStats) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start = time.Now()
+	s.ops = make(map[string]*OpReport)
+}
+
+// record adds the outcome of an operation.
+func (s *opStats) record(op string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.ops[op]
+	if !ok {
+		r = &OpReport{}
+		s.ops[op] = r
+	}
+	r.Count++
+	if err != nil {
+		r.Errors++
+		return
+	}
+	r.latencies = append(r.latencies, latency.Seconds())
+}
+
+// report returns the summary of the operations so far, checked against slos.
+func (s *opStats) report(slos []SLO) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := &Report{Start: s.start, Duration: time.Since(s.start).Seconds(), Ops: make(map[string]*OpReport), Passed: true}
+	for op, r := range s.ops {
+		lats := append([]float64(nil), r.latencies...)
+		sort.Float64s(lats)
+		rep.Ops[op] = &OpReport{
+			Count:     r.Count,
+			Errors:    r.Errors,
+			ErrorRate: float64(r.Errors) / float64(r.Count),
+			P50:       quantile(lats, 50),
+			P90:       quantile(lats, 90),
+			P99:       quantile(lats, 99),
+			Max:       quantile(lats, 100),
+			latencies: lats,
+		}
+	}
+	for _, slo := range slos {
+		res := SLOResult{SLO: slo.String()}
+		if r, ok := rep.Ops[slo.Op]; ok {
+			if slo.Metric == MetricErrorRate {
+				res.Value = r.ErrorRate
+			} else {
+				p, _ := percentile(slo.Metric)
+				res.Value = quantile(r.latencies, p)
+			}
+		}
+		// An operation which didn't run meets its SLOs vacuously.
+		res.Met = res.Value <= slo.Max
+		rep.Passed = rep.Passed && res.Met
+		rep.SLOs = append(rep.SLOs, res)
+	}
+	return rep
+}
+
+// quantile returns the p-th percentile of the sorted values, using the
+// nearest-rank method, or 0 if there are none.
+func quantile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSLOs(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    []SLO
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{
+			in: "queue_leaf:p99<200ms, merge_delay:max<5s,read:error_rate<0.01,read:p99.9<1s",
+			want: []SLO{
+				{Op: OpQueueLeaf, Metric: "p99", Max: 0.2},
+				{Op: OpMergeDelay, Metric: MetricMax, Max: 5},
+				{Op: OpRead, Metric: MetricErrorRate, Max: 0.01},
+				{Op: OpRead, Metric: "p99.9", Max: 1},
+			},
+		},
+		{in: "queue_leaf", wantErr: true},
+		{in: "queue_leaf:p99", wantErr: true},
+		{in: "write:p99<1s", wantErr: true},
+		{in: "read:mean<1s", wantErr: true},
+		{in: "read:p101<1s", wantErr: true},
+		{in: "read:p99<1", wantErr: true},
+		{in: "read:error_rate<2", wantErr: true},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseSLOs(tc.in)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseSLOs() = %v, want error %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseSLOs() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	s := newOpStats()
+	for i := 1; i <= 100; i++ {
+		s.record(OpQueueLeaf, time.Duration(i)*time.Millisecond, nil)
+	}
+	s.record(OpRead, time.Millisecond, nil)
+	s.record(OpRead, time.Second, errors.New("failed"))
+
+	slos, err := ParseSLOs("queue_leaf:p90<91ms,queue_leaf:p99<99ms,queue_leaf:p99<98ms,read:error_rate<0.6,merge_delay:p99<1s")
+	if err != nil {
+		t.Fatalf("ParseSLOs() = %v", err)
+	}
+	rep := s.report(slos)
+	if q := rep.Ops[OpQueueLeaf]; q.Count != 100 || q.P50 != 0.05 || q.P90 != 0.09 || q.P99 != 0.099 || q.Max != 0.1 {
+		t.Errorf("queue_leaf report = %+v, want 100 operations with p50=0.05 p90=0.09 p99=0.099 max=0.1", q)
+	}
+	if r := rep.Ops[OpRead]; r.Count != 2 || r.Errors != 1 || r.ErrorRate != 0.5 || r.Max != 0.001 {
+		t.Errorf("read report = %+v, want 2 operations with 1 error and max=0.001", r)
+	}
+	want := []SLOResult{
+		{SLO: "queue_leaf:p90<91ms", Value: 0.09, Met: true},
+		// Bounds are inclusive.
+		{SLO: "queue_leaf:p99<99ms", Value: 0.099, Met: true},
+		{SLO: "queue_leaf:p99<98ms", Value: 0.099, Met: false},
+		{SLO: "read:error_rate<0.6", Value: 0.5, Met: true},
+		{SLO: "merge_delay:p99<1s", Value: 0, Met: true},
+	}
+	if diff := cmp.Diff(want, rep.SLOs); diff != "" {
+		t.Errorf("SLO results diff (-want +got):\n%s", diff)
+	}
+	if rep.Passed {
+		t.Error("Passed = true, want false")
+	}
+}
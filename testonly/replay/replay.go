@@ -0,0 +1,368 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay records the gRPC calls of a client, e.g. during an
+// integration run against real servers, and serves them back without a
+// backend, for fast and deterministic regression tests of the code using the
+// client.
+//
+// A Recorder is installed with the interceptor dial options:
+//
+//	rec := replay.NewRecorder()
+//	conn, err := grpc.Dial(addr, append(opts, rec.DialOptions()...)...)
+//	... // Run the calls to record.
+//	err = rec.Save(w)
+//
+// and a Conn replays the recording to any gRPC client:
+//
+//	conn, err := replay.Load(r)
+//	logClient := trillian.NewTrillianLogClient(conn)
+//
+// Unary calls are replayed in the recorded order among the calls of the same
+// method and request, so repeated calls, e.g. polling the root of a log, get
+// the successive recorded responses. Streams are replayed in the recorded
+// order among the streams of the same method.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// record is a recorded call.
+type record struct {
+	method    string
+	stream    bool
+	requests  []proto.Message
+	responses []proto.Message
+	status    *status.Status
+	// used is set once the call is replayed.
+	used bool
+}
+
+// jsonRecord is the serialized form of a record. The messages are the JSON
+// encodings of Any messages, so that they can be decoded without knowing the
+// types of the RPCs.
+type jsonRecord struct {
+	Method    string            `json:"method"`
+	Stream    bool              `json:"stream,omitempty"`
+	Requests  []json.RawMessage `json:"requests,omitempty"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	Code      codes.Code        `json:"code,omitempty"`
+	Message   string            `json:"message,omitempty"`
+}
+
+// Recorder records the calls made through the connections it's installed on.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	records []*record
+}
+
+// NewRecorder returns a Recorder with no recorded calls.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// DialOptions returns the options installing the interceptors of the
+// recorder on a connection.
+func (r *Recorder) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(r.UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(r.StreamClientInterceptor),
+	}
+}
+
+// UnaryClientInterceptor records unary calls.
+func (r *Recorder) UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	rec := &record{method: method, requests: []proto.Message{clone(req)}, status: status.Convert(err)}
+	if err == nil {
+		rec.responses = []proto.Message{clone(reply)}
+	}
+	r.add(rec)
+	return err
+}
+
+// StreamClientInterceptor records streaming calls.
+func (r *Recorder) StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	rec := &record{method: method, stream: true}
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		rec.status = status.Convert(err)
+		r.add(rec)
+		return nil, err
+	}
+	r.add(rec)
+	return &recordingStream{ClientStream: cs, r: r, rec: rec}, nil
+}
+
+func (r *Recorder) add(rec *record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+}
+
+// Save writes the recorded calls to w, in a form which Load reads.
+func (r *Recorder) Save(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jrs := make([]jsonRecord, 0, len(r.records))
+	for _, rec := range r.records {
+		jr := jsonRecord{Method: rec.method, Stream: rec.stream, Code: rec.status.Code(), Message: rec.status.Message()}
+		var err error
+		if jr.Requests, err = marshalAll(rec.requests); err != nil {
+			return fmt.Errorf("%s: %v", rec.method, err)
+		}
+		if jr.Responses, err = marshalAll(rec.responses); err != nil {
+			return fmt.Errorf("%s: %v", rec.method, err)
+		}
+		jrs = append(jrs, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jrs)
+}
+
+// recordingStream records the messages of a stream.
+type recordingStream struct {
+	grpc.ClientStream
+	r   *Recorder
+	rec *record
+}
+
+func (s *recordingStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.r.mu.Lock()
+		s.rec.requests = append(s.rec.requests, clone(m))
+		s.r.mu.Unlock()
+	}
+	return err
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	switch err {
+	case nil:
+		s.rec.responses = append(s.rec.responses, clone(m))
+	case io.EOF:
+	default:
+		s.rec.status = status.Convert(err)
+	}
+	return err
+}
+
+// Conn is a grpc.ClientConnInterface replaying recorded calls. It is safe for
+// concurrent use.
+type Conn struct {
+	mu      sync.Mutex
+	records []*record
+}
+
+var _ grpc.ClientConnInterface = &Conn{}
+
+// Load returns a Conn replaying the calls saved by a Recorder.
+func Load(r io.Reader) (*Conn, error) {
+	var jrs []jsonRecord
+	if err := json.NewDecoder(r).Decode(&jrs); err != nil {
+		return nil, fmt.Errorf("failed to decode recording: %v", err)
+	}
+	c := &Conn{}
+	for _, jr := range jrs {
+		rec := &record{method: jr.Method, stream: jr.Stream, status: status.New(jr.Code, jr.Message)}
+		var err error
+		if rec.requests, err = unmarshalAll(jr.Requests); err != nil {
+			return nil, fmt.Errorf("%s: %v", jr.Method, err)
+		}
+		if rec.responses, err = unmarshalAll(jr.Responses); err != nil {
+			return nil, fmt.Errorf("%s: %v", jr.Method, err)
+		}
+		c.records = append(c.records, rec)
+	}
+	return c, nil
+}
+
+// Unused returns the methods of the recorded calls which haven't been
+// replayed, in order.
+func (c *Conn) Unused() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var methods []string
+	for _, rec := range c.records {
+		if !rec.used {
+			methods = append(methods, rec.method)
+		}
+	}
+	return methods
+}
+
+// Invoke replays the first unused unary call of method with an equal request.
+func (c *Conn) Invoke(ctx context.Context, method string, args, reply interface{}, _ ...grpc.CallOption) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	req, ok := args.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "replay: request of %s is a %T", method, args)
+	}
+	rec := c.next(func(rec *record) bool {
+		return rec.method == method && !rec.stream && len(rec.requests) == 1 && proto.Equal(rec.requests[0], req)
+	})
+	if rec == nil {
+		return status.Errorf(codes.NotFound, "replay: no recorded call of %s with request %v", method, req)
+	}
+	if err := rec.status.Err(); err != nil {
+		return err
+	}
+	return copyTo(reply, rec.responses[0])
+}
+
+// NewStream replays the first unused stream of method.
+func (c *Conn) NewStream(ctx context.Context, _ *grpc.StreamDesc, method string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	rec := c.next(func(rec *record) bool {
+		return rec.method == method && rec.stream
+	})
+	if rec == nil {
+		return nil, status.Errorf(codes.NotFound, "replay: no recorded stream of %s", method)
+	}
+	if len(rec.requests) == 0 && len(rec.responses) == 0 {
+		if err := rec.status.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return &replayStream{ctx: ctx, rec: rec}, nil
+}
+
+// next marks the first unused record matching match as used, and returns it.
+func (c *Conn) next(match func(*record) bool) *record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rec := range c.records {
+		if !rec.used && match(rec) {
+			rec.used = true
+			return rec
+		}
+	}
+	return nil
+}
+
+// replayStream replays a recorded stream. The requests sent must match the
+// recorded ones, and the recorded responses are received in turn, regardless
+// of the requests sent so far.
+type replayStream struct {
+	ctx  context.Context
+	mu   sync.Mutex
+	rec  *record
+	sent int
+	recv int
+}
+
+func (s *replayStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "replay: request of %s is a %T", s.rec.method, m)
+	}
+	if s.sent >= len(s.rec.requests) {
+		return status.Errorf(codes.FailedPrecondition, "replay: stream of %s sent more than the %d recorded requests", s.rec.method, len(s.rec.requests))
+	}
+	if want := s.rec.requests[s.sent]; !proto.Equal(req, want) {
+		return status.Errorf(codes.FailedPrecondition, "replay: stream of %s sent request %d %v, recorded %v", s.rec.method, s.sent, req, want)
+	}
+	s.sent++
+	return nil
+}
+
+func (s *replayStream) RecvMsg(m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recv >= len(s.rec.responses) {
+		if err := s.rec.status.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	s.recv++
+	return copyTo(m, s.rec.responses[s.recv-1])
+}
+
+func (s *replayStream) Header() (metadata.MD, error) { return nil, nil }
+func (s *replayStream) Trailer() metadata.MD         { return nil }
+func (s *replayStream) CloseSend() error             { return nil }
+func (s *replayStream) Context() context.Context     { return s.ctx }
+
+func clone(m interface{}) proto.Message {
+	return proto.Clone(m.(proto.Message))
+}
+
+// copyTo sets dst, which must be a message of the type of src, to src.
+func copyTo(dst interface{}, src proto.Message) error {
+	out, ok := dst.(proto.Message)
+	if !ok || out.ProtoReflect().Descriptor() != src.ProtoReflect().Descriptor() {
+		return status.Errorf(codes.Internal, "replay: recorded a %T, want a %T", src, dst)
+	}
+	proto.Reset(out)
+	proto.Merge(out, src)
+	return nil
+}
+
+func marshalAll(msgs []proto.Message) ([]json.RawMessage, error) {
+	var out []json.RawMessage
+	for _, m := range msgs {
+		a, err := anypb.New(m)
+		if err != nil {
+			return nil, err
+		}
+		b, err := protojson.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func unmarshalAll(raws []json.RawMessage) ([]proto.Message, error) {
+	var out []proto.Message
+	for _, raw := range raws {
+		var a anypb.Any
+		if err := protojson.Unmarshal(raw, &a); err != nil {
+			return nil, err
+		}
+		m, err := a.UnmarshalNew()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const getRootMethod = "/trillian.TrillianLog/GetLatestSignedLogRoot"
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	roots := []*trillian.GetLatestSignedLogRootResponse{
+		{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: []byte("root 1")}},
+		{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: []byte("root 2")}},
+	}
+	replies := []error{nil, nil, status.Error(codes.Unavailable, "down")}
+
+	rec := NewRecorder()
+	for i, wantErr := range replies {
+		i, wantErr := i, wantErr
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			if wantErr != nil {
+				return wantErr
+			}
+			proto.Merge(reply.(proto.Message), roots[i])
+			return nil
+		}
+		req := &trillian.GetLatestSignedLogRootRequest{LogId: 1}
+		if err := rec.UnaryClientInterceptor(ctx, getRootMethod, req, &trillian.GetLatestSignedLogRootResponse{}, nil, invoker); err != wantErr {
+			t.Fatalf("UnaryClientInterceptor(%d) = %v, want %v", i, err, wantErr)
+		}
+	}
+	var b bytes.Buffer
+	if err := rec.Save(&b); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	conn, err := Load(&b)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	client := trillian.NewTrillianLogClient(conn)
+	if _, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: 2}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetLatestSignedLogRoot(other log) = %v, want code %v", err, codes.NotFound)
+	}
+	for i, want := range roots {
+		got, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: 1})
+		if err != nil || !proto.Equal(got, want) {
+			t.Errorf("GetLatestSignedLogRoot(%d) = %v, %v, want %v, nil", i, got, err, want)
+		}
+	}
+	if _, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: 1}); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetLatestSignedLogRoot(2) = %v, want code %v", err, codes.Unavailable)
+	}
+	if _, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetLatestSignedLogRoot(3) = %v, want code %v", err, codes.NotFound)
+	}
+	if unused := conn.Unused(); len(unused) != 0 {
+		t.Errorf("Unused() = %v, want none", unused)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, in := range []string{
+		"not JSON",
+		`[{"method": "/m", "requests": [{"@type": "type.googleapis.com/unknown.Message"}]}]`,
+	} {
+		if _, err := Load(strings.NewReader(in)); err == nil {
+			t.Errorf("Load(%q) = nil, want error", in)
+		}
+	}
+}
@@ -80,6 +80,21 @@ func (mr *MockTrillianAdminServerMockRecorder) GetTree(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTree", reflect.TypeOf((*MockTrillianAdminServer)(nil).GetTree), arg0, arg1)
 }
 
+// GetTreeUsage mocks base method.
+func (m *MockTrillianAdminServer) GetTreeUsage(arg0 context.Context, arg1 *trillian.GetTreeUsageRequest) (*trillian.TreeUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreeUsage", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.TreeUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreeUsage indicates an expected call of GetTreeUsage.
+func (mr *MockTrillianAdminServerMockRecorder) GetTreeUsage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreeUsage", reflect.TypeOf((*MockTrillianAdminServer)(nil).GetTreeUsage), arg0, arg1)
+}
+
 // ListTrees mocks base method.
 func (m *MockTrillianAdminServer) ListTrees(arg0 context.Context, arg1 *trillian.ListTreesRequest) (*trillian.ListTreesResponse, error) {
 	m.ctrl.T.Helper()
@@ -95,6 +110,21 @@ func (mr *MockTrillianAdminServerMockRecorder) ListTrees(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrees", reflect.TypeOf((*MockTrillianAdminServer)(nil).ListTrees), arg0, arg1)
 }
 
+// ScrubTree mocks base method.
+func (m *MockTrillianAdminServer) ScrubTree(arg0 context.Context, arg1 *trillian.ScrubTreeRequest) (*trillian.ScrubTreeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScrubTree", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.ScrubTreeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ScrubTree indicates an expected call of ScrubTree.
+func (mr *MockTrillianAdminServerMockRecorder) ScrubTree(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScrubTree", reflect.TypeOf((*MockTrillianAdminServer)(nil).ScrubTree), arg0, arg1)
+}
+
 // UndeleteTree mocks base method.
 func (m *MockTrillianAdminServer) UndeleteTree(arg0 context.Context, arg1 *trillian.UndeleteTreeRequest) (*trillian.Tree, error) {
 	m.ctrl.T.Helper()
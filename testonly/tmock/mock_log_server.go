@@ -50,6 +50,21 @@ func (mr *MockTrillianLogServerMockRecorder) AddSequencedLeaves(arg0, arg1 inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSequencedLeaves", reflect.TypeOf((*MockTrillianLogServer)(nil).AddSequencedLeaves), arg0, arg1)
 }
 
+// GetCompactRange mocks base method.
+func (m *MockTrillianLogServer) GetCompactRange(arg0 context.Context, arg1 *trillian.GetCompactRangeRequest) (*trillian.GetCompactRangeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCompactRange", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetCompactRangeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCompactRange indicates an expected call of GetCompactRange.
+func (mr *MockTrillianLogServerMockRecorder) GetCompactRange(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompactRange", reflect.TypeOf((*MockTrillianLogServer)(nil).GetCompactRange), arg0, arg1)
+}
+
 // GetConsistencyProof mocks base method.
 func (m *MockTrillianLogServer) GetConsistencyProof(arg0 context.Context, arg1 *trillian.GetConsistencyProofRequest) (*trillian.GetConsistencyProofResponse, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +95,21 @@ func (mr *MockTrillianLogServerMockRecorder) GetEntryAndProof(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntryAndProof", reflect.TypeOf((*MockTrillianLogServer)(nil).GetEntryAndProof), arg0, arg1)
 }
 
+// GetImportProgress mocks base method.
+func (m *MockTrillianLogServer) GetImportProgress(arg0 context.Context, arg1 *trillian.GetImportProgressRequest) (*trillian.GetImportProgressResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImportProgress", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetImportProgressResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImportProgress indicates an expected call of GetImportProgress.
+func (mr *MockTrillianLogServerMockRecorder) GetImportProgress(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImportProgress", reflect.TypeOf((*MockTrillianLogServer)(nil).GetImportProgress), arg0, arg1)
+}
+
 // GetInclusionProof mocks base method.
 func (m *MockTrillianLogServer) GetInclusionProof(arg0 context.Context, arg1 *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
 	m.ctrl.T.Helper()
@@ -125,6 +155,36 @@ func (mr *MockTrillianLogServerMockRecorder) GetLatestSignedLogRoot(arg0, arg1 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestSignedLogRoot", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLatestSignedLogRoot), arg0, arg1)
 }
 
+// GetLeavesByHash mocks base method.
+func (m *MockTrillianLogServer) GetLeavesByHash(arg0 context.Context, arg1 *trillian.GetLeavesByHashRequest) (*trillian.GetLeavesByHashResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeavesByHash", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetLeavesByHashResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLeavesByHash indicates an expected call of GetLeavesByHash.
+func (mr *MockTrillianLogServerMockRecorder) GetLeavesByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByHash", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLeavesByHash), arg0, arg1)
+}
+
+// GetLeavesByIndexList mocks base method.
+func (m *MockTrillianLogServer) GetLeavesByIndexList(arg0 context.Context, arg1 *trillian.GetLeavesByIndexListRequest) (*trillian.GetLeavesByIndexListResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeavesByIndexList", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetLeavesByIndexListResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLeavesByIndexList indicates an expected call of GetLeavesByIndexList.
+func (mr *MockTrillianLogServerMockRecorder) GetLeavesByIndexList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByIndexList", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLeavesByIndexList), arg0, arg1)
+}
+
 // GetLeavesByRange mocks base method.
 func (m *MockTrillianLogServer) GetLeavesByRange(arg0 context.Context, arg1 *trillian.GetLeavesByRangeRequest) (*trillian.GetLeavesByRangeResponse, error) {
 	m.ctrl.T.Helper()
@@ -140,6 +200,21 @@ func (mr *MockTrillianLogServerMockRecorder) GetLeavesByRange(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByRange", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLeavesByRange), arg0, arg1)
 }
 
+// GetSignedLogRootAtTime mocks base method.
+func (m *MockTrillianLogServer) GetSignedLogRootAtTime(arg0 context.Context, arg1 *trillian.GetSignedLogRootAtTimeRequest) (*trillian.GetSignedLogRootAtTimeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignedLogRootAtTime", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetSignedLogRootAtTimeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignedLogRootAtTime indicates an expected call of GetSignedLogRootAtTime.
+func (mr *MockTrillianLogServerMockRecorder) GetSignedLogRootAtTime(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedLogRootAtTime", reflect.TypeOf((*MockTrillianLogServer)(nil).GetSignedLogRootAtTime), arg0, arg1)
+}
+
 // InitLog mocks base method.
 func (m *MockTrillianLogServer) InitLog(arg0 context.Context, arg1 *trillian.InitLogRequest) (*trillian.InitLogResponse, error) {
 	m.ctrl.T.Helper()
@@ -169,3 +244,31 @@ func (mr *MockTrillianLogServerMockRecorder) QueueLeaf(arg0, arg1 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueLeaf", reflect.TypeOf((*MockTrillianLogServer)(nil).QueueLeaf), arg0, arg1)
 }
+
+// QueueLeaves mocks base method.
+func (m *MockTrillianLogServer) QueueLeaves(arg0 trillian.TrillianLog_QueueLeavesServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueLeaves", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QueueLeaves indicates an expected call of QueueLeaves.
+func (mr *MockTrillianLogServerMockRecorder) QueueLeaves(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueLeaves", reflect.TypeOf((*MockTrillianLogServer)(nil).QueueLeaves), arg0)
+}
+
+// StreamLeaves mocks base method.
+func (m *MockTrillianLogServer) StreamLeaves(arg0 *trillian.StreamLeavesRequest, arg1 trillian.TrillianLog_StreamLeavesServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamLeaves", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamLeaves indicates an expected call of StreamLeaves.
+func (mr *MockTrillianLogServerMockRecorder) StreamLeaves(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamLeaves", reflect.TypeOf((*MockTrillianLogServer)(nil).StreamLeaves), arg0, arg1)
+}
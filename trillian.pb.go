@@ -285,6 +285,116 @@ func (TreeType) EnumDescriptor() ([]byte, []int) {
 	return file_trillian_proto_rawDescGZIP(), []int{3}
 }
 
+// Defines how a log deduplicates the leaves submitted to it.
+type LeafDedupPolicy int32
+
+const (
+	// Leaves are deduplicated by their leaf_identity_hash, which defaults to the
+	// Merkle leaf hash if not set by the personality.
+	LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH LeafDedupPolicy = 0
+	// Leaves are deduplicated by the Merkle leaf hash of their leaf_value. Any
+	// leaf_identity_hash set by the personality is overwritten.
+	LeafDedupPolicy_DEDUP_BY_LEAF_VALUE_HASH LeafDedupPolicy = 1
+	// Leaves are never deduplicated: every submitted leaf is added to the log.
+	// Any leaf_identity_hash set by the personality is overwritten with a
+	// unique value.
+	LeafDedupPolicy_NO_DEDUP LeafDedupPolicy = 2
+)
+
+// Enum value maps for LeafDedupPolicy.
+var (
+	LeafDedupPolicy_name = map[int32]string{
+		0: "DEDUP_BY_IDENTITY_HASH",
+		1: "DEDUP_BY_LEAF_VALUE_HASH",
+		2: "NO_DEDUP",
+	}
+	LeafDedupPolicy_value = map[string]int32{
+		"DEDUP_BY_IDENTITY_HASH":   0,
+		"DEDUP_BY_LEAF_VALUE_HASH": 1,
+		"NO_DEDUP":                 2,
+	}
+)
+
+func (x LeafDedupPolicy) Enum() *LeafDedupPolicy {
+	p := new(LeafDedupPolicy)
+	*p = x
+	return p
+}
+
+func (x LeafDedupPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LeafDedupPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_trillian_proto_enumTypes[4].Descriptor()
+}
+
+func (LeafDedupPolicy) Type() protoreflect.EnumType {
+	return &file_trillian_proto_enumTypes[4]
+}
+
+func (x LeafDedupPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LeafDedupPolicy.Descriptor instead.
+func (LeafDedupPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_trillian_proto_rawDescGZIP(), []int{4}
+}
+
+// Defines the priority of a tree when the log signer is overloaded.
+type TreePriority int32
+
+const (
+	// Trees are scheduled after high-priority trees.
+	TreePriority_NORMAL_PRIORITY TreePriority = 0
+	// Trees are scheduled before all other trees.
+	TreePriority_HIGH_PRIORITY TreePriority = 1
+	// Trees are scheduled after all other trees, e.g. test trees.
+	TreePriority_LOW_PRIORITY TreePriority = 2
+)
+
+// Enum value maps for TreePriority.
+var (
+	TreePriority_name = map[int32]string{
+		0: "NORMAL_PRIORITY",
+		1: "HIGH_PRIORITY",
+		2: "LOW_PRIORITY",
+	}
+	TreePriority_value = map[string]int32{
+		"NORMAL_PRIORITY": 0,
+		"HIGH_PRIORITY":   1,
+		"LOW_PRIORITY":    2,
+	}
+)
+
+func (x TreePriority) Enum() *TreePriority {
+	p := new(TreePriority)
+	*p = x
+	return p
+}
+
+func (x TreePriority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TreePriority) Descriptor() protoreflect.EnumDescriptor {
+	return file_trillian_proto_enumTypes[5].Descriptor()
+}
+
+func (TreePriority) Type() protoreflect.EnumType {
+	return &file_trillian_proto_enumTypes[5]
+}
+
+func (x TreePriority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TreePriority.Descriptor instead.
+func (TreePriority) EnumDescriptor() ([]byte, []int) {
+	return file_trillian_proto_rawDescGZIP(), []int{5}
+}
+
 // Represents a tree.
 // Readonly attributes are assigned at tree creation, after which they may not
 // be modified.
@@ -334,6 +444,39 @@ type Tree struct {
 	// Time of tree deletion, if any.
 	// Readonly.
 	DeleteTime *timestamppb.Timestamp `protobuf:"bytes,20,opt,name=delete_time,json=deleteTime,proto3" json:"delete_time,omitempty"`
+	// Policy used to deduplicate the leaves submitted to the tree.
+	// Readonly after Tree creation.
+	LeafDedupPolicy LeafDedupPolicy `protobuf:"varint,21,opt,name=leaf_dedup_policy,json=leafDedupPolicy,proto3,enum=trillian.LeafDedupPolicy" json:"leaf_dedup_policy,omitempty"`
+	// Time after which submitted leaves are eligible for sequencing.
+	// If zero or unset, the guard window of the log signer is used.
+	SequencerGuardWindow *durationpb.Duration `protobuf:"bytes,22,opt,name=sequencer_guard_window,json=sequencerGuardWindow,proto3" json:"sequencer_guard_window,omitempty"`
+	// Maximum number of leaves sequenced per pass.
+	// If zero, the batch size of the log signer is used.
+	SequencerBatchSize int32 `protobuf:"varint,23,opt,name=sequencer_batch_size,json=sequencerBatchSize,proto3" json:"sequencer_batch_size,omitempty"`
+	// Priority of the tree in the log signer, which runs the operations of
+	// higher-priority trees first.
+	Priority TreePriority `protobuf:"varint,24,opt,name=priority,proto3,enum=trillian.TreePriority" json:"priority,omitempty"`
+	// Labels of the log signer instances which may run the tree. If set, only
+	// signer instances with at least one of the labels compete for mastership
+	// of the tree.
+	SignerAffinity []string `protobuf:"bytes,25,rep,name=signer_affinity,json=signerAffinity,proto3" json:"signer_affinity,omitempty"`
+	// Anti-affinity group of the tree. Log signer instances holding mastership
+	// of a tree in the group defer to other instances for the other trees of
+	// the group, which spreads them across instances.
+	AntiAffinityGroup string `protobuf:"bytes,26,opt,name=anti_affinity_group,json=antiAffinityGroup,proto3" json:"anti_affinity_group,omitempty"`
+	// Maximum size in bytes of the leaf_value of leaves queued to the log.
+	// If zero, the limit of the log server is used.
+	MaxLeafValueSize int32 `protobuf:"varint,27,opt,name=max_leaf_value_size,json=maxLeafValueSize,proto3" json:"max_leaf_value_size,omitempty"`
+	// Maximum size in bytes of the extra_data of leaves queued to the log.
+	// If zero, the limit of the log server is used.
+	MaxExtraDataSize int32 `protobuf:"varint,28,opt,name=max_extra_data_size,json=maxExtraDataSize,proto3" json:"max_extra_data_size,omitempty"`
+	// Maximum number of unsequenced leaves queued to the log, above which new
+	// leaves are rejected. If zero, the limit of the log server is used.
+	MaxUnsequencedLeaves int32 `protobuf:"varint,29,opt,name=max_unsequenced_leaves,json=maxUnsequencedLeaves,proto3" json:"max_unsequenced_leaves,omitempty"`
+	// Number of latest revisions of the log whose subtrees are retained by the
+	// background compaction of the log signer, which deletes older subtree
+	// revisions. If zero, the setting of the log signer is used.
+	CompactRetainedRevisions int32 `protobuf:"varint,30,opt,name=compact_retained_revisions,json=compactRetainedRevisions,proto3" json:"compact_retained_revisions,omitempty"`
 }
 
 func (x *Tree) Reset() {
@@ -445,6 +588,76 @@ func (x *Tree) GetDeleteTime() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *Tree) GetLeafDedupPolicy() LeafDedupPolicy {
+	if x != nil {
+		return x.LeafDedupPolicy
+	}
+	return LeafDedupPolicy_DEDUP_BY_IDENTITY_HASH
+}
+
+func (x *Tree) GetSequencerGuardWindow() *durationpb.Duration {
+	if x != nil {
+		return x.SequencerGuardWindow
+	}
+	return nil
+}
+
+func (x *Tree) GetSequencerBatchSize() int32 {
+	if x != nil {
+		return x.SequencerBatchSize
+	}
+	return 0
+}
+
+func (x *Tree) GetPriority() TreePriority {
+	if x != nil {
+		return x.Priority
+	}
+	return TreePriority_NORMAL_PRIORITY
+}
+
+func (x *Tree) GetSignerAffinity() []string {
+	if x != nil {
+		return x.SignerAffinity
+	}
+	return nil
+}
+
+func (x *Tree) GetAntiAffinityGroup() string {
+	if x != nil {
+		return x.AntiAffinityGroup
+	}
+	return ""
+}
+
+func (x *Tree) GetMaxLeafValueSize() int32 {
+	if x != nil {
+		return x.MaxLeafValueSize
+	}
+	return 0
+}
+
+func (x *Tree) GetMaxExtraDataSize() int32 {
+	if x != nil {
+		return x.MaxExtraDataSize
+	}
+	return 0
+}
+
+func (x *Tree) GetMaxUnsequencedLeaves() int32 {
+	if x != nil {
+		return x.MaxUnsequencedLeaves
+	}
+	return 0
+}
+
+func (x *Tree) GetCompactRetainedRevisions() int32 {
+	if x != nil {
+		return x.CompactRetainedRevisions
+	}
+	return 0
+}
+
 // SignedLogRoot represents a commitment by a Log to a particular tree.
 type SignedLogRoot struct {
 	state         protoimpl.MessageState
@@ -596,7 +809,7 @@ var file_trillian_proto_rawDesc = []byte{
 	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e,
 	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xf1, 0x05, 0x0a, 0x04, 0x54, 0x72, 0x65, 0x65, 0x12,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x9a, 0x0a, 0x0a, 0x04, 0x54, 0x72, 0x65, 0x65, 0x12,
 	0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
 	0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x12, 0x32, 0x0a, 0x0a, 0x74, 0x72, 0x65, 0x65,
 	0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x74,
@@ -630,68 +843,113 @@ var file_trillian_proto_rawDesc = []byte{
 	0x0a, 0x0b, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x14, 0x20,
 	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
-	0x0a, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x4a, 0x04, 0x08, 0x04, 0x10,
-	0x08, 0x4a, 0x04, 0x08, 0x0a, 0x10, 0x0d, 0x4a, 0x04, 0x08, 0x0e, 0x10, 0x0f, 0x4a, 0x04, 0x08,
-	0x12, 0x10, 0x13, 0x52, 0x1e, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65,
+	0x0a, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x45, 0x0a, 0x11, 0x6c,
+	0x65, 0x61, 0x66, 0x5f, 0x64, 0x65, 0x64, 0x75, 0x70, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79,
+	0x18, 0x15, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61,
+	0x6e, 0x2e, 0x4c, 0x65, 0x61, 0x66, 0x44, 0x65, 0x64, 0x75, 0x70, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x52, 0x0f, 0x6c, 0x65, 0x61, 0x66, 0x44, 0x65, 0x64, 0x75, 0x70, 0x50, 0x6f, 0x6c, 0x69,
+	0x63, 0x79, 0x12, 0x4f, 0x0a, 0x16, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x72, 0x5f,
+	0x67, 0x75, 0x61, 0x72, 0x64, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x16, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x14, 0x73,
+	0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x72, 0x47, 0x75, 0x61, 0x72, 0x64, 0x57, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x12, 0x30, 0x0a, 0x14, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x72,
+	0x5f, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x17, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x12, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x72, 0x42, 0x61, 0x74, 0x63,
+	0x68, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x32, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74,
+	0x79, 0x18, 0x18, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69,
+	0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x52,
+	0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x69, 0x67,
+	0x6e, 0x65, 0x72, 0x5f, 0x61, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x18, 0x19, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0e, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69,
+	0x74, 0x79, 0x12, 0x2e, 0x0a, 0x13, 0x61, 0x6e, 0x74, 0x69, 0x5f, 0x61, 0x66, 0x66, 0x69, 0x6e,
+	0x69, 0x74, 0x79, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x11, 0x61, 0x6e, 0x74, 0x69, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x47, 0x72, 0x6f,
+	0x75, 0x70, 0x12, 0x2d, 0x0a, 0x13, 0x6d, 0x61, 0x78, 0x5f, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x10, 0x6d, 0x61, 0x78, 0x4c, 0x65, 0x61, 0x66, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x53, 0x69, 0x7a,
+	0x65, 0x12, 0x2d, 0x0a, 0x13, 0x6d, 0x61, 0x78, 0x5f, 0x65, 0x78, 0x74, 0x72, 0x61, 0x5f, 0x64,
+	0x61, 0x74, 0x61, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10,
+	0x6d, 0x61, 0x78, 0x45, 0x78, 0x74, 0x72, 0x61, 0x44, 0x61, 0x74, 0x61, 0x53, 0x69, 0x7a, 0x65,
+	0x12, 0x34, 0x0a, 0x16, 0x6d, 0x61, 0x78, 0x5f, 0x75, 0x6e, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x64, 0x5f, 0x6c, 0x65, 0x61, 0x76, 0x65, 0x73, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x14, 0x6d, 0x61, 0x78, 0x55, 0x6e, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x64,
+	0x4c, 0x65, 0x61, 0x76, 0x65, 0x73, 0x12, 0x3c, 0x0a, 0x1a, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x63,
+	0x74, 0x5f, 0x72, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x1e, 0x20, 0x01, 0x28, 0x05, 0x52, 0x18, 0x63, 0x6f, 0x6d, 0x70,
+	0x61, 0x63, 0x74, 0x52, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x52, 0x65, 0x76, 0x69, 0x73,
+	0x69, 0x6f, 0x6e, 0x73, 0x4a, 0x04, 0x08, 0x04, 0x10, 0x08, 0x4a, 0x04, 0x08, 0x0a, 0x10, 0x0d,
+	0x4a, 0x04, 0x08, 0x0e, 0x10, 0x0f, 0x4a, 0x04, 0x08, 0x12, 0x10, 0x13, 0x52, 0x1e, 0x63, 0x72,
+	0x65, 0x61, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d, 0x69, 0x6c, 0x6c, 0x69, 0x73,
+	0x5f, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x5f, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x52, 0x10, 0x64, 0x75,
+	0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0e,
+	0x68, 0x61, 0x73, 0x68, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x0d,
+	0x68, 0x61, 0x73, 0x68, 0x5f, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x52, 0x0b, 0x70,
+	0x72, 0x69, 0x76, 0x61, 0x74, 0x65, 0x5f, 0x6b, 0x65, 0x79, 0x52, 0x0a, 0x70, 0x75, 0x62, 0x6c,
+	0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x52, 0x13, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x16, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x5f, 0x73, 0x75,
+	0x69, 0x74, 0x65, 0x52, 0x1e, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65,
 	0x5f, 0x6d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x5f, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x5f, 0x65, 0x70,
-	0x6f, 0x63, 0x68, 0x52, 0x10, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x5f, 0x70,
-	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0e, 0x68, 0x61, 0x73, 0x68, 0x5f, 0x61, 0x6c, 0x67, 0x6f,
-	0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x0d, 0x68, 0x61, 0x73, 0x68, 0x5f, 0x73, 0x74, 0x72, 0x61,
-	0x74, 0x65, 0x67, 0x79, 0x52, 0x0b, 0x70, 0x72, 0x69, 0x76, 0x61, 0x74, 0x65, 0x5f, 0x6b, 0x65,
-	0x79, 0x52, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x52, 0x13, 0x73,
-	0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74,
-	0x68, 0x6d, 0x52, 0x16, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f, 0x63, 0x69,
-	0x70, 0x68, 0x65, 0x72, 0x5f, 0x73, 0x75, 0x69, 0x74, 0x65, 0x52, 0x1e, 0x75, 0x70, 0x64, 0x61,
-	0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x5f, 0x73,
-	0x69, 0x6e, 0x63, 0x65, 0x5f, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x22, 0x9d, 0x01, 0x0a, 0x0d, 0x53,
-	0x69, 0x67, 0x6e, 0x65, 0x64, 0x4c, 0x6f, 0x67, 0x52, 0x6f, 0x6f, 0x74, 0x12, 0x19, 0x0a, 0x08,
-	0x6c, 0x6f, 0x67, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07,
-	0x6c, 0x6f, 0x67, 0x52, 0x6f, 0x6f, 0x74, 0x4a, 0x04, 0x08, 0x01, 0x10, 0x08, 0x4a, 0x04, 0x08,
-	0x09, 0x10, 0x0a, 0x52, 0x08, 0x6b, 0x65, 0x79, 0x5f, 0x68, 0x69, 0x6e, 0x74, 0x52, 0x06, 0x6c,
-	0x6f, 0x67, 0x5f, 0x69, 0x64, 0x52, 0x12, 0x6c, 0x6f, 0x67, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x5f,
-	0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52, 0x09, 0x72, 0x6f, 0x6f, 0x74, 0x5f,
-	0x68, 0x61, 0x73, 0x68, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52,
-	0x0f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73,
-	0x52, 0x0d, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x52,
-	0x09, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x50, 0x0a, 0x05, 0x50, 0x72,
-	0x6f, 0x6f, 0x66, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x69, 0x6e, 0x64, 0x65,
-	0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x6c, 0x65, 0x61, 0x66, 0x49, 0x6e, 0x64,
-	0x65, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03,
-	0x28, 0x0c, 0x52, 0x06, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x4a, 0x04, 0x08, 0x02, 0x10, 0x03,
-	0x52, 0x0a, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x2a, 0x44, 0x0a, 0x0d,
-	0x4c, 0x6f, 0x67, 0x52, 0x6f, 0x6f, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1b, 0x0a,
-	0x17, 0x4c, 0x4f, 0x47, 0x5f, 0x52, 0x4f, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54,
-	0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x16, 0x0a, 0x12, 0x4c, 0x4f,
-	0x47, 0x5f, 0x52, 0x4f, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x56, 0x31,
-	0x10, 0x01, 0x2a, 0x97, 0x01, 0x0a, 0x0c, 0x48, 0x61, 0x73, 0x68, 0x53, 0x74, 0x72, 0x61, 0x74,
-	0x65, 0x67, 0x79, 0x12, 0x19, 0x0a, 0x15, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x48,
-	0x41, 0x53, 0x48, 0x5f, 0x53, 0x54, 0x52, 0x41, 0x54, 0x45, 0x47, 0x59, 0x10, 0x00, 0x12, 0x12,
-	0x0a, 0x0e, 0x52, 0x46, 0x43, 0x36, 0x39, 0x36, 0x32, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36,
-	0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x45, 0x53, 0x54, 0x5f, 0x4d, 0x41, 0x50, 0x5f, 0x48,
-	0x41, 0x53, 0x48, 0x45, 0x52, 0x10, 0x02, 0x12, 0x19, 0x0a, 0x15, 0x4f, 0x42, 0x4a, 0x45, 0x43,
-	0x54, 0x5f, 0x52, 0x46, 0x43, 0x36, 0x39, 0x36, 0x32, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36,
-	0x10, 0x03, 0x12, 0x15, 0x0a, 0x11, 0x43, 0x4f, 0x4e, 0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41,
-	0x35, 0x31, 0x32, 0x5f, 0x32, 0x35, 0x36, 0x10, 0x04, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x4f, 0x4e,
-	0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x05, 0x2a, 0x8b, 0x01, 0x0a,
-	0x09, 0x54, 0x72, 0x65, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x12, 0x55, 0x4e,
-	0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54, 0x52, 0x45, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45,
-	0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x43, 0x54, 0x49, 0x56, 0x45, 0x10, 0x01, 0x12, 0x0a,
-	0x0a, 0x06, 0x46, 0x52, 0x4f, 0x5a, 0x45, 0x4e, 0x10, 0x02, 0x12, 0x1f, 0x0a, 0x17, 0x44, 0x45,
-	0x50, 0x52, 0x45, 0x43, 0x41, 0x54, 0x45, 0x44, 0x5f, 0x53, 0x4f, 0x46, 0x54, 0x5f, 0x44, 0x45,
-	0x4c, 0x45, 0x54, 0x45, 0x44, 0x10, 0x03, 0x1a, 0x02, 0x08, 0x01, 0x12, 0x1f, 0x0a, 0x17, 0x44,
-	0x45, 0x50, 0x52, 0x45, 0x43, 0x41, 0x54, 0x45, 0x44, 0x5f, 0x48, 0x41, 0x52, 0x44, 0x5f, 0x44,
-	0x45, 0x4c, 0x45, 0x54, 0x45, 0x44, 0x10, 0x04, 0x1a, 0x02, 0x08, 0x01, 0x12, 0x0c, 0x0a, 0x08,
-	0x44, 0x52, 0x41, 0x49, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x05, 0x2a, 0x49, 0x0a, 0x08, 0x54, 0x72,
-	0x65, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57,
-	0x4e, 0x5f, 0x54, 0x52, 0x45, 0x45, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x10, 0x00, 0x12, 0x07, 0x0a,
-	0x03, 0x4c, 0x4f, 0x47, 0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x50, 0x52, 0x45, 0x4f, 0x52, 0x44,
-	0x45, 0x52, 0x45, 0x44, 0x5f, 0x4c, 0x4f, 0x47, 0x10, 0x03, 0x22, 0x04, 0x08, 0x02, 0x10, 0x02,
-	0x2a, 0x03, 0x4d, 0x41, 0x50, 0x42, 0x48, 0x0a, 0x19, 0x63, 0x6f, 0x6d, 0x2e, 0x67, 0x6f, 0x6f,
-	0x67, 0x6c, 0x65, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x42, 0x0d, 0x54, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x50, 0x72, 0x6f, 0x74,
-	0x6f, 0x50, 0x01, 0x5a, 0x1a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
-	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6f, 0x63, 0x68, 0x22, 0x9d, 0x01, 0x0a, 0x0d, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x4c, 0x6f,
+	0x67, 0x52, 0x6f, 0x6f, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x6c, 0x6f, 0x67, 0x5f, 0x72, 0x6f, 0x6f,
+	0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x6c, 0x6f, 0x67, 0x52, 0x6f, 0x6f, 0x74,
+	0x4a, 0x04, 0x08, 0x01, 0x10, 0x08, 0x4a, 0x04, 0x08, 0x09, 0x10, 0x0a, 0x52, 0x08, 0x6b, 0x65,
+	0x79, 0x5f, 0x68, 0x69, 0x6e, 0x74, 0x52, 0x06, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x52, 0x12,
+	0x6c, 0x6f, 0x67, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x5f, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x52, 0x09, 0x72, 0x6f, 0x6f, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x52, 0x09, 0x73,
+	0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52, 0x0f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x52, 0x0d, 0x74, 0x72, 0x65, 0x65, 0x5f,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x73,
+	0x69, 0x7a, 0x65, 0x22, 0x50, 0x0a, 0x05, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12, 0x1d, 0x0a, 0x0a,
+	0x6c, 0x65, 0x61, 0x66, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x09, 0x6c, 0x65, 0x61, 0x66, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x68,
+	0x61, 0x73, 0x68, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x06, 0x68, 0x61, 0x73,
+	0x68, 0x65, 0x73, 0x4a, 0x04, 0x08, 0x02, 0x10, 0x03, 0x52, 0x0a, 0x70, 0x72, 0x6f, 0x6f, 0x66,
+	0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x2a, 0x44, 0x0a, 0x0d, 0x4c, 0x6f, 0x67, 0x52, 0x6f, 0x6f, 0x74,
+	0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1b, 0x0a, 0x17, 0x4c, 0x4f, 0x47, 0x5f, 0x52, 0x4f,
+	0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57,
+	0x4e, 0x10, 0x00, 0x12, 0x16, 0x0a, 0x12, 0x4c, 0x4f, 0x47, 0x5f, 0x52, 0x4f, 0x4f, 0x54, 0x5f,
+	0x46, 0x4f, 0x52, 0x4d, 0x41, 0x54, 0x5f, 0x56, 0x31, 0x10, 0x01, 0x2a, 0x97, 0x01, 0x0a, 0x0c,
+	0x48, 0x61, 0x73, 0x68, 0x53, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x79, 0x12, 0x19, 0x0a, 0x15,
+	0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x5f, 0x53, 0x54, 0x52,
+	0x41, 0x54, 0x45, 0x47, 0x59, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x52, 0x46, 0x43, 0x36, 0x39,
+	0x36, 0x32, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x54,
+	0x45, 0x53, 0x54, 0x5f, 0x4d, 0x41, 0x50, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x45, 0x52, 0x10, 0x02,
+	0x12, 0x19, 0x0a, 0x15, 0x4f, 0x42, 0x4a, 0x45, 0x43, 0x54, 0x5f, 0x52, 0x46, 0x43, 0x36, 0x39,
+	0x36, 0x32, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x03, 0x12, 0x15, 0x0a, 0x11, 0x43,
+	0x4f, 0x4e, 0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41, 0x35, 0x31, 0x32, 0x5f, 0x32, 0x35, 0x36,
+	0x10, 0x04, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x4f, 0x4e, 0x49, 0x4b, 0x53, 0x5f, 0x53, 0x48, 0x41,
+	0x32, 0x35, 0x36, 0x10, 0x05, 0x2a, 0x8b, 0x01, 0x0a, 0x09, 0x54, 0x72, 0x65, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x12, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54,
+	0x52, 0x45, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41,
+	0x43, 0x54, 0x49, 0x56, 0x45, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x46, 0x52, 0x4f, 0x5a, 0x45,
+	0x4e, 0x10, 0x02, 0x12, 0x1f, 0x0a, 0x17, 0x44, 0x45, 0x50, 0x52, 0x45, 0x43, 0x41, 0x54, 0x45,
+	0x44, 0x5f, 0x53, 0x4f, 0x46, 0x54, 0x5f, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x44, 0x10, 0x03,
+	0x1a, 0x02, 0x08, 0x01, 0x12, 0x1f, 0x0a, 0x17, 0x44, 0x45, 0x50, 0x52, 0x45, 0x43, 0x41, 0x54,
+	0x45, 0x44, 0x5f, 0x48, 0x41, 0x52, 0x44, 0x5f, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x44, 0x10,
+	0x04, 0x1a, 0x02, 0x08, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x44, 0x52, 0x41, 0x49, 0x4e, 0x49, 0x4e,
+	0x47, 0x10, 0x05, 0x2a, 0x49, 0x0a, 0x08, 0x54, 0x72, 0x65, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x15, 0x0a, 0x11, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54, 0x52, 0x45, 0x45, 0x5f,
+	0x54, 0x59, 0x50, 0x45, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x4c, 0x4f, 0x47, 0x10, 0x01, 0x12,
+	0x12, 0x0a, 0x0e, 0x50, 0x52, 0x45, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x45, 0x44, 0x5f, 0x4c, 0x4f,
+	0x47, 0x10, 0x03, 0x22, 0x04, 0x08, 0x02, 0x10, 0x02, 0x2a, 0x03, 0x4d, 0x41, 0x50, 0x2a, 0x59,
+	0x0a, 0x0f, 0x4c, 0x65, 0x61, 0x66, 0x44, 0x65, 0x64, 0x75, 0x70, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x12, 0x1a, 0x0a, 0x16, 0x44, 0x45, 0x44, 0x55, 0x50, 0x5f, 0x42, 0x59, 0x5f, 0x49, 0x44,
+	0x45, 0x4e, 0x54, 0x49, 0x54, 0x59, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x10, 0x00, 0x12, 0x1c, 0x0a,
+	0x18, 0x44, 0x45, 0x44, 0x55, 0x50, 0x5f, 0x42, 0x59, 0x5f, 0x4c, 0x45, 0x41, 0x46, 0x5f, 0x56,
+	0x41, 0x4c, 0x55, 0x45, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x4e,
+	0x4f, 0x5f, 0x44, 0x45, 0x44, 0x55, 0x50, 0x10, 0x02, 0x2a, 0x48, 0x0a, 0x0c, 0x54, 0x72, 0x65,
+	0x65, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x13, 0x0a, 0x0f, 0x4e, 0x4f, 0x52,
+	0x4d, 0x41, 0x4c, 0x5f, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49, 0x54, 0x59, 0x10, 0x00, 0x12, 0x11,
+	0x0a, 0x0d, 0x48, 0x49, 0x47, 0x48, 0x5f, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49, 0x54, 0x59, 0x10,
+	0x01, 0x12, 0x10, 0x0a, 0x0c, 0x4c, 0x4f, 0x57, 0x5f, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49, 0x54,
+	0x59, 0x10, 0x02, 0x42, 0x48, 0x0a, 0x19, 0x63, 0x6f, 0x6d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x42, 0x0d, 0x54, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50,
+	0x01, 0x5a, 0x1a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -706,33 +964,38 @@ func file_trillian_proto_rawDescGZIP() []byte {
 	return file_trillian_proto_rawDescData
 }
 
-var file_trillian_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_trillian_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
 var file_trillian_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_trillian_proto_goTypes = []interface{}{
 	(LogRootFormat)(0),            // 0: trillian.LogRootFormat
 	(HashStrategy)(0),             // 1: trillian.HashStrategy
 	(TreeState)(0),                // 2: trillian.TreeState
 	(TreeType)(0),                 // 3: trillian.TreeType
-	(*Tree)(nil),                  // 4: trillian.Tree
-	(*SignedLogRoot)(nil),         // 5: trillian.SignedLogRoot
-	(*Proof)(nil),                 // 6: trillian.Proof
-	(*anypb.Any)(nil),             // 7: google.protobuf.Any
-	(*durationpb.Duration)(nil),   // 8: google.protobuf.Duration
-	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+	(LeafDedupPolicy)(0),          // 4: trillian.LeafDedupPolicy
+	(TreePriority)(0),             // 5: trillian.TreePriority
+	(*Tree)(nil),                  // 6: trillian.Tree
+	(*SignedLogRoot)(nil),         // 7: trillian.SignedLogRoot
+	(*Proof)(nil),                 // 8: trillian.Proof
+	(*anypb.Any)(nil),             // 9: google.protobuf.Any
+	(*durationpb.Duration)(nil),   // 10: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
 }
 var file_trillian_proto_depIdxs = []int32{
-	2, // 0: trillian.Tree.tree_state:type_name -> trillian.TreeState
-	3, // 1: trillian.Tree.tree_type:type_name -> trillian.TreeType
-	7, // 2: trillian.Tree.storage_settings:type_name -> google.protobuf.Any
-	8, // 3: trillian.Tree.max_root_duration:type_name -> google.protobuf.Duration
-	9, // 4: trillian.Tree.create_time:type_name -> google.protobuf.Timestamp
-	9, // 5: trillian.Tree.update_time:type_name -> google.protobuf.Timestamp
-	9, // 6: trillian.Tree.delete_time:type_name -> google.protobuf.Timestamp
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	2,  // 0: trillian.Tree.tree_state:type_name -> trillian.TreeState
+	3,  // 1: trillian.Tree.tree_type:type_name -> trillian.TreeType
+	9,  // 2: trillian.Tree.storage_settings:type_name -> google.protobuf.Any
+	10, // 3: trillian.Tree.max_root_duration:type_name -> google.protobuf.Duration
+	11, // 4: trillian.Tree.create_time:type_name -> google.protobuf.Timestamp
+	11, // 5: trillian.Tree.update_time:type_name -> google.protobuf.Timestamp
+	11, // 6: trillian.Tree.delete_time:type_name -> google.protobuf.Timestamp
+	4,  // 7: trillian.Tree.leaf_dedup_policy:type_name -> trillian.LeafDedupPolicy
+	10, // 8: trillian.Tree.sequencer_guard_window:type_name -> google.protobuf.Duration
+	5,  // 9: trillian.Tree.priority:type_name -> trillian.TreePriority
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_trillian_proto_init() }
@@ -783,7 +1046,7 @@ func file_trillian_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_trillian_proto_rawDesc,
-			NumEnums:      4,
+			NumEnums:      6,
 			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   0,
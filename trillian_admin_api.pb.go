@@ -35,6 +35,72 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Kind of the corruption.
+type TreeCorruption_Kind int32
+
+const (
+	// Unknown kind. Invalid.
+	TreeCorruption_UNKNOWN_CORRUPTION_KIND TreeCorruption_Kind = 0
+	// A leaf is missing from the sequenced leaves of the tree.
+	TreeCorruption_MISSING_LEAF TreeCorruption_Kind = 1
+	// The stored Merkle leaf hash doesn't match the hash of the leaf value.
+	TreeCorruption_LEAF_HASH_MISMATCH TreeCorruption_Kind = 2
+	// A Merkle node is missing from storage.
+	TreeCorruption_MISSING_NODE TreeCorruption_Kind = 3
+	// A stored Merkle node hash doesn't match the recomputed hash.
+	TreeCorruption_NODE_HASH_MISMATCH TreeCorruption_Kind = 4
+	// The root hash recomputed from the leaves doesn't match the latest
+	// signed root.
+	TreeCorruption_ROOT_HASH_MISMATCH TreeCorruption_Kind = 5
+)
+
+// Enum value maps for TreeCorruption_Kind.
+var (
+	TreeCorruption_Kind_name = map[int32]string{
+		0: "UNKNOWN_CORRUPTION_KIND",
+		1: "MISSING_LEAF",
+		2: "LEAF_HASH_MISMATCH",
+		3: "MISSING_NODE",
+		4: "NODE_HASH_MISMATCH",
+		5: "ROOT_HASH_MISMATCH",
+	}
+	TreeCorruption_Kind_value = map[string]int32{
+		"UNKNOWN_CORRUPTION_KIND": 0,
+		"MISSING_LEAF":            1,
+		"LEAF_HASH_MISMATCH":      2,
+		"MISSING_NODE":            3,
+		"NODE_HASH_MISMATCH":      4,
+		"ROOT_HASH_MISMATCH":      5,
+	}
+)
+
+func (x TreeCorruption_Kind) Enum() *TreeCorruption_Kind {
+	p := new(TreeCorruption_Kind)
+	*p = x
+	return p
+}
+
+func (x TreeCorruption_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TreeCorruption_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_trillian_admin_api_proto_enumTypes[0].Descriptor()
+}
+
+func (TreeCorruption_Kind) Type() protoreflect.EnumType {
+	return &file_trillian_admin_api_proto_enumTypes[0]
+}
+
+func (x TreeCorruption_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TreeCorruption_Kind.Descriptor instead.
+func (TreeCorruption_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_trillian_admin_api_proto_rawDescGZIP(), []int{8, 0}
+}
+
 // ListTrees request.
 // No filters or pagination options are provided.
 type ListTreesRequest struct {
@@ -391,6 +457,369 @@ func (x *UndeleteTreeRequest) GetTreeId() int64 {
 	return 0
 }
 
+// ScrubTree request.
+type ScrubTreeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ID of the log tree to scrub.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	// Maximum number of leaves read per storage transaction.
+	// If zero, a server-defined default is used.
+	BatchSize int64 `protobuf:"varint,2,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	// Maximum number of corruptions returned in the response.
+	// If zero, a server-defined default is used.
+	MaxCorruptions int64 `protobuf:"varint,3,opt,name=max_corruptions,json=maxCorruptions,proto3" json:"max_corruptions,omitempty"`
+}
+
+func (x *ScrubTreeRequest) Reset() {
+	*x = ScrubTreeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trillian_admin_api_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ScrubTreeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubTreeRequest) ProtoMessage() {}
+
+func (x *ScrubTreeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trillian_admin_api_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubTreeRequest.ProtoReflect.Descriptor instead.
+func (*ScrubTreeRequest) Descriptor() ([]byte, []int) {
+	return file_trillian_admin_api_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ScrubTreeRequest) GetTreeId() int64 {
+	if x != nil {
+		return x.TreeId
+	}
+	return 0
+}
+
+func (x *ScrubTreeRequest) GetBatchSize() int64 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *ScrubTreeRequest) GetMaxCorruptions() int64 {
+	if x != nil {
+		return x.MaxCorruptions
+	}
+	return 0
+}
+
+// TreeCorruption describes stored tree data which doesn't match the data
+// recomputed from the stored leaves.
+type TreeCorruption struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Kind of the corruption.
+	Kind TreeCorruption_Kind `protobuf:"varint,1,opt,name=kind,proto3,enum=trillian.TreeCorruption_Kind" json:"kind,omitempty"`
+	// Level of the affected Merkle node, where leaves are at level 0.
+	Level int32 `protobuf:"varint,2,opt,name=level,proto3" json:"level,omitempty"`
+	// Index of the affected Merkle node within its level. For leaves this is
+	// the leaf index.
+	Index int64 `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	// Human-readable description of the corruption.
+	Description string `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *TreeCorruption) Reset() {
+	*x = TreeCorruption{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trillian_admin_api_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TreeCorruption) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TreeCorruption) ProtoMessage() {}
+
+func (x *TreeCorruption) ProtoReflect() protoreflect.Message {
+	mi := &file_trillian_admin_api_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TreeCorruption.ProtoReflect.Descriptor instead.
+func (*TreeCorruption) Descriptor() ([]byte, []int) {
+	return file_trillian_admin_api_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TreeCorruption) GetKind() TreeCorruption_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return TreeCorruption_UNKNOWN_CORRUPTION_KIND
+}
+
+func (x *TreeCorruption) GetLevel() int32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+func (x *TreeCorruption) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *TreeCorruption) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+// ScrubTree response.
+type ScrubTreeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Size of the tree in the latest signed root, which the stored data was
+	// verified against.
+	TreeSize int64 `protobuf:"varint,1,opt,name=tree_size,json=treeSize,proto3" json:"tree_size,omitempty"`
+	// Number of leaves checked.
+	LeavesChecked int64 `protobuf:"varint,2,opt,name=leaves_checked,json=leavesChecked,proto3" json:"leaves_checked,omitempty"`
+	// Number of stored Merkle nodes checked.
+	NodesChecked int64 `protobuf:"varint,3,opt,name=nodes_checked,json=nodesChecked,proto3" json:"nodes_checked,omitempty"`
+	// Corruptions found, up to the requested maximum.
+	Corruptions []*TreeCorruption `protobuf:"bytes,4,rep,name=corruptions,proto3" json:"corruptions,omitempty"`
+	// Total number of corruptions found, which may exceed the number of
+	// corruptions returned.
+	CorruptionCount int64 `protobuf:"varint,5,opt,name=corruption_count,json=corruptionCount,proto3" json:"corruption_count,omitempty"`
+}
+
+func (x *ScrubTreeResponse) Reset() {
+	*x = ScrubTreeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trillian_admin_api_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ScrubTreeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubTreeResponse) ProtoMessage() {}
+
+func (x *ScrubTreeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trillian_admin_api_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubTreeResponse.ProtoReflect.Descriptor instead.
+func (*ScrubTreeResponse) Descriptor() ([]byte, []int) {
+	return file_trillian_admin_api_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ScrubTreeResponse) GetTreeSize() int64 {
+	if x != nil {
+		return x.TreeSize
+	}
+	return 0
+}
+
+func (x *ScrubTreeResponse) GetLeavesChecked() int64 {
+	if x != nil {
+		return x.LeavesChecked
+	}
+	return 0
+}
+
+func (x *ScrubTreeResponse) GetNodesChecked() int64 {
+	if x != nil {
+		return x.NodesChecked
+	}
+	return 0
+}
+
+func (x *ScrubTreeResponse) GetCorruptions() []*TreeCorruption {
+	if x != nil {
+		return x.Corruptions
+	}
+	return nil
+}
+
+func (x *ScrubTreeResponse) GetCorruptionCount() int64 {
+	if x != nil {
+		return x.CorruptionCount
+	}
+	return 0
+}
+
+// GetTreeUsage request.
+type GetTreeUsageRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ID of the tree to retrieve the storage usage of.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+}
+
+func (x *GetTreeUsageRequest) Reset() {
+	*x = GetTreeUsageRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trillian_admin_api_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetTreeUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTreeUsageRequest) ProtoMessage() {}
+
+func (x *GetTreeUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trillian_admin_api_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTreeUsageRequest.ProtoReflect.Descriptor instead.
+func (*GetTreeUsageRequest) Descriptor() ([]byte, []int) {
+	return file_trillian_admin_api_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetTreeUsageRequest) GetTreeId() int64 {
+	if x != nil {
+		return x.TreeId
+	}
+	return 0
+}
+
+// TreeUsage is the approximate number of bytes stored for a tree, broken down
+// by kind of data. It's updated incrementally by the storage layer as data is
+// written, so it may lag behind writes and doesn't account for storage
+// overheads such as indexes.
+type TreeUsage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ID of the tree.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	// Bytes of the leaves of the tree, including their values and extra data,
+	// whether they're sequenced or still queued.
+	LeafDataBytes int64 `protobuf:"varint,2,opt,name=leaf_data_bytes,json=leafDataBytes,proto3" json:"leaf_data_bytes,omitempty"`
+	// Bytes of the stored Merkle subtrees of the tree, across all revisions.
+	SubtreeBytes int64 `protobuf:"varint,3,opt,name=subtree_bytes,json=subtreeBytes,proto3" json:"subtree_bytes,omitempty"`
+	// Bytes of the queue entries of the leaves which aren't sequenced yet.
+	QueueBytes int64 `protobuf:"varint,4,opt,name=queue_bytes,json=queueBytes,proto3" json:"queue_bytes,omitempty"`
+}
+
+func (x *TreeUsage) Reset() {
+	*x = TreeUsage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trillian_admin_api_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TreeUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TreeUsage) ProtoMessage() {}
+
+func (x *TreeUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_trillian_admin_api_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TreeUsage.ProtoReflect.Descriptor instead.
+func (*TreeUsage) Descriptor() ([]byte, []int) {
+	return file_trillian_admin_api_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *TreeUsage) GetTreeId() int64 {
+	if x != nil {
+		return x.TreeId
+	}
+	return 0
+}
+
+func (x *TreeUsage) GetLeafDataBytes() int64 {
+	if x != nil {
+		return x.LeafDataBytes
+	}
+	return 0
+}
+
+func (x *TreeUsage) GetSubtreeBytes() int64 {
+	if x != nil {
+		return x.SubtreeBytes
+	}
+	return 0
+}
+
+func (x *TreeUsage) GetQueueBytes() int64 {
+	if x != nil {
+		return x.QueueBytes
+	}
+	return 0
+}
+
 var File_trillian_admin_api_proto protoreflect.FileDescriptor
 
 var file_trillian_admin_api_proto_rawDesc = []byte{
@@ -426,38 +855,99 @@ var file_trillian_admin_api_proto_rawDesc = []byte{
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x22, 0x2e,
 	0x0a, 0x13, 0x55, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65,
 	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x32, 0x86,
-	0x03, 0x0a, 0x0d, 0x54, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x41, 0x64, 0x6d, 0x69, 0x6e,
-	0x12, 0x46, 0x0a, 0x09, 0x4c, 0x69, 0x73, 0x74, 0x54, 0x72, 0x65, 0x65, 0x73, 0x12, 0x1a, 0x2e,
-	0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x54, 0x72, 0x65,
-	0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x74, 0x72, 0x69, 0x6c,
-	0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x54, 0x72, 0x65, 0x65, 0x73, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x35, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x54,
-	0x72, 0x65, 0x65, 0x12, 0x18, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x47,
-	0x65, 0x74, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x22, 0x73,
+	0x0a, 0x10, 0x53, 0x63, 0x72, 0x75, 0x62, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x62,
+	0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61,
+	0x78, 0x5f, 0x63, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0e, 0x6d, 0x61, 0x78, 0x43, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x22, 0xa3, 0x02, 0x0a, 0x0e, 0x54, 0x72, 0x65, 0x65, 0x43, 0x6f, 0x72, 0x72,
+	0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x31, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x1d, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e,
+	0x54, 0x72, 0x65, 0x65, 0x43, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x4b,
+	0x69, 0x6e, 0x64, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x65, 0x76,
+	0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x12,
+	0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05,
+	0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x8f, 0x01, 0x0a, 0x04, 0x4b, 0x69, 0x6e, 0x64,
+	0x12, 0x1b, 0x0a, 0x17, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x43, 0x4f, 0x52, 0x52,
+	0x55, 0x50, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x4b, 0x49, 0x4e, 0x44, 0x10, 0x00, 0x12, 0x10, 0x0a,
+	0x0c, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x5f, 0x4c, 0x45, 0x41, 0x46, 0x10, 0x01, 0x12,
+	0x16, 0x0a, 0x12, 0x4c, 0x45, 0x41, 0x46, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x5f, 0x4d, 0x49, 0x53,
+	0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c, 0x4d, 0x49, 0x53, 0x53, 0x49,
+	0x4e, 0x47, 0x5f, 0x4e, 0x4f, 0x44, 0x45, 0x10, 0x03, 0x12, 0x16, 0x0a, 0x12, 0x4e, 0x4f, 0x44,
+	0x45, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x5f, 0x4d, 0x49, 0x53, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10,
+	0x04, 0x12, 0x16, 0x0a, 0x12, 0x52, 0x4f, 0x4f, 0x54, 0x5f, 0x48, 0x41, 0x53, 0x48, 0x5f, 0x4d,
+	0x49, 0x53, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x05, 0x22, 0xe3, 0x01, 0x0a, 0x11, 0x53, 0x63,
+	0x72, 0x75, 0x62, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x74, 0x72, 0x65, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x25, 0x0a, 0x0e,
+	0x6c, 0x65, 0x61, 0x76, 0x65, 0x73, 0x5f, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6c, 0x65, 0x61, 0x76, 0x65, 0x73, 0x43, 0x68, 0x65, 0x63,
+	0x6b, 0x65, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x5f, 0x63, 0x68, 0x65,
+	0x63, 0x6b, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6e, 0x6f, 0x64, 0x65,
+	0x73, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x64, 0x12, 0x3a, 0x0a, 0x0b, 0x63, 0x6f, 0x72, 0x72,
+	0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x43, 0x6f, 0x72,
+	0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x63, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x63, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f,
+	0x63, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x22,
+	0x2e, 0x0a, 0x13, 0x47, 0x65, 0x74, 0x54, 0x72, 0x65, 0x65, 0x55, 0x73, 0x61, 0x67, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x22,
+	0x92, 0x01, 0x0a, 0x09, 0x54, 0x72, 0x65, 0x65, 0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x17, 0x0a,
+	0x07, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06,
+	0x74, 0x72, 0x65, 0x65, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x0f, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x64,
+	0x61, 0x74, 0x61, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0d, 0x6c, 0x65, 0x61, 0x66, 0x44, 0x61, 0x74, 0x61, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x23,
+	0x0a, 0x0d, 0x73, 0x75, 0x62, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x73, 0x75, 0x62, 0x74, 0x72, 0x65, 0x65, 0x42, 0x79,
+	0x74, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x71, 0x75, 0x65, 0x75, 0x65, 0x5f, 0x62, 0x79, 0x74,
+	0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x71, 0x75, 0x65, 0x75, 0x65, 0x42,
+	0x79, 0x74, 0x65, 0x73, 0x32, 0x94, 0x04, 0x0a, 0x0d, 0x54, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61,
+	0x6e, 0x41, 0x64, 0x6d, 0x69, 0x6e, 0x12, 0x46, 0x0a, 0x09, 0x4c, 0x69, 0x73, 0x74, 0x54, 0x72,
+	0x65, 0x65, 0x73, 0x12, 0x1a, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x4c,
+	0x69, 0x73, 0x74, 0x54, 0x72, 0x65, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1b, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x54,
+	0x72, 0x65, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x35,
+	0x0a, 0x07, 0x47, 0x65, 0x74, 0x54, 0x72, 0x65, 0x65, 0x12, 0x18, 0x2e, 0x74, 0x72, 0x69, 0x6c,
+	0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54,
+	0x72, 0x65, 0x65, 0x22, 0x00, 0x12, 0x3b, 0x0a, 0x0a, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x54,
+	0x72, 0x65, 0x65, 0x12, 0x1b, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x43,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x0e, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65,
+	0x22, 0x00, 0x12, 0x3b, 0x0a, 0x0a, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65,
+	0x12, 0x1b, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e,
 	0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x12,
-	0x3b, 0x0a, 0x0a, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1b, 0x2e,
-	0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x54,
+	0x3b, 0x0a, 0x0a, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1b, 0x2e,
+	0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54,
 	0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x74, 0x72, 0x69,
-	0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x12, 0x3b, 0x0a, 0x0a,
-	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1b, 0x2e, 0x74, 0x72, 0x69,
-	0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69,
-	0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x12, 0x3b, 0x0a, 0x0a, 0x44, 0x65, 0x6c,
-	0x65, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1b, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69,
-	0x61, 0x6e, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e,
-	0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x12, 0x3f, 0x0a, 0x0c, 0x55, 0x6e, 0x64, 0x65, 0x6c, 0x65,
-	0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1d, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61,
-	0x6e, 0x2e, 0x55, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e,
-	0x2e, 0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x42, 0x50, 0x0a, 0x19, 0x63, 0x6f, 0x6d, 0x2e, 0x67,
-	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x42, 0x15, 0x54, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x41, 0x64,
-	0x6d, 0x69, 0x6e, 0x41, 0x70, 0x69, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x1a, 0x67,
-	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
-	0x2f, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x12, 0x3f, 0x0a, 0x0c,
+	0x55, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1d, 0x2e, 0x74,
+	0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x55, 0x6e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x74, 0x72,
+	0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x54, 0x72, 0x65, 0x65, 0x22, 0x00, 0x12, 0x46, 0x0a,
+	0x09, 0x53, 0x63, 0x72, 0x75, 0x62, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1a, 0x2e, 0x74, 0x72, 0x69,
+	0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e, 0x53, 0x63, 0x72, 0x75, 0x62, 0x54, 0x72, 0x65, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61,
+	0x6e, 0x2e, 0x53, 0x63, 0x72, 0x75, 0x62, 0x54, 0x72, 0x65, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x44, 0x0a, 0x0c, 0x47, 0x65, 0x74, 0x54, 0x72, 0x65, 0x65,
+	0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x1d, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e,
+	0x2e, 0x47, 0x65, 0x74, 0x54, 0x72, 0x65, 0x65, 0x55, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x2e,
+	0x54, 0x72, 0x65, 0x65, 0x55, 0x73, 0x61, 0x67, 0x65, 0x22, 0x00, 0x42, 0x50, 0x0a, 0x19, 0x63,
+	0x6f, 0x6d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69,
+	0x61, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x42, 0x15, 0x54, 0x72, 0x69, 0x6c, 0x6c, 0x69,
+	0x61, 0x6e, 0x41, 0x64, 0x6d, 0x69, 0x6e, 0x41, 0x70, 0x69, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50,
+	0x01, 0x5a, 0x1a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x74, 0x72, 0x69, 0x6c, 0x6c, 0x69, 0x61, 0x6e, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -472,40 +962,53 @@ func file_trillian_admin_api_proto_rawDescGZIP() []byte {
 	return file_trillian_admin_api_proto_rawDescData
 }
 
-var file_trillian_admin_api_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_trillian_admin_api_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_trillian_admin_api_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_trillian_admin_api_proto_goTypes = []interface{}{
-	(*ListTreesRequest)(nil),      // 0: trillian.ListTreesRequest
-	(*ListTreesResponse)(nil),     // 1: trillian.ListTreesResponse
-	(*GetTreeRequest)(nil),        // 2: trillian.GetTreeRequest
-	(*CreateTreeRequest)(nil),     // 3: trillian.CreateTreeRequest
-	(*UpdateTreeRequest)(nil),     // 4: trillian.UpdateTreeRequest
-	(*DeleteTreeRequest)(nil),     // 5: trillian.DeleteTreeRequest
-	(*UndeleteTreeRequest)(nil),   // 6: trillian.UndeleteTreeRequest
-	(*Tree)(nil),                  // 7: trillian.Tree
-	(*fieldmaskpb.FieldMask)(nil), // 8: google.protobuf.FieldMask
+	(TreeCorruption_Kind)(0),      // 0: trillian.TreeCorruption.Kind
+	(*ListTreesRequest)(nil),      // 1: trillian.ListTreesRequest
+	(*ListTreesResponse)(nil),     // 2: trillian.ListTreesResponse
+	(*GetTreeRequest)(nil),        // 3: trillian.GetTreeRequest
+	(*CreateTreeRequest)(nil),     // 4: trillian.CreateTreeRequest
+	(*UpdateTreeRequest)(nil),     // 5: trillian.UpdateTreeRequest
+	(*DeleteTreeRequest)(nil),     // 6: trillian.DeleteTreeRequest
+	(*UndeleteTreeRequest)(nil),   // 7: trillian.UndeleteTreeRequest
+	(*ScrubTreeRequest)(nil),      // 8: trillian.ScrubTreeRequest
+	(*TreeCorruption)(nil),        // 9: trillian.TreeCorruption
+	(*ScrubTreeResponse)(nil),     // 10: trillian.ScrubTreeResponse
+	(*GetTreeUsageRequest)(nil),   // 11: trillian.GetTreeUsageRequest
+	(*TreeUsage)(nil),             // 12: trillian.TreeUsage
+	(*Tree)(nil),                  // 13: trillian.Tree
+	(*fieldmaskpb.FieldMask)(nil), // 14: google.protobuf.FieldMask
 }
 var file_trillian_admin_api_proto_depIdxs = []int32{
-	7,  // 0: trillian.ListTreesResponse.tree:type_name -> trillian.Tree
-	7,  // 1: trillian.CreateTreeRequest.tree:type_name -> trillian.Tree
-	7,  // 2: trillian.UpdateTreeRequest.tree:type_name -> trillian.Tree
-	8,  // 3: trillian.UpdateTreeRequest.update_mask:type_name -> google.protobuf.FieldMask
-	0,  // 4: trillian.TrillianAdmin.ListTrees:input_type -> trillian.ListTreesRequest
-	2,  // 5: trillian.TrillianAdmin.GetTree:input_type -> trillian.GetTreeRequest
-	3,  // 6: trillian.TrillianAdmin.CreateTree:input_type -> trillian.CreateTreeRequest
-	4,  // 7: trillian.TrillianAdmin.UpdateTree:input_type -> trillian.UpdateTreeRequest
-	5,  // 8: trillian.TrillianAdmin.DeleteTree:input_type -> trillian.DeleteTreeRequest
-	6,  // 9: trillian.TrillianAdmin.UndeleteTree:input_type -> trillian.UndeleteTreeRequest
-	1,  // 10: trillian.TrillianAdmin.ListTrees:output_type -> trillian.ListTreesResponse
-	7,  // 11: trillian.TrillianAdmin.GetTree:output_type -> trillian.Tree
-	7,  // 12: trillian.TrillianAdmin.CreateTree:output_type -> trillian.Tree
-	7,  // 13: trillian.TrillianAdmin.UpdateTree:output_type -> trillian.Tree
-	7,  // 14: trillian.TrillianAdmin.DeleteTree:output_type -> trillian.Tree
-	7,  // 15: trillian.TrillianAdmin.UndeleteTree:output_type -> trillian.Tree
-	10, // [10:16] is the sub-list for method output_type
-	4,  // [4:10] is the sub-list for method input_type
-	4,  // [4:4] is the sub-list for extension type_name
-	4,  // [4:4] is the sub-list for extension extendee
-	0,  // [0:4] is the sub-list for field type_name
+	13, // 0: trillian.ListTreesResponse.tree:type_name -> trillian.Tree
+	13, // 1: trillian.CreateTreeRequest.tree:type_name -> trillian.Tree
+	13, // 2: trillian.UpdateTreeRequest.tree:type_name -> trillian.Tree
+	14, // 3: trillian.UpdateTreeRequest.update_mask:type_name -> google.protobuf.FieldMask
+	0,  // 4: trillian.TreeCorruption.kind:type_name -> trillian.TreeCorruption.Kind
+	9,  // 5: trillian.ScrubTreeResponse.corruptions:type_name -> trillian.TreeCorruption
+	1,  // 6: trillian.TrillianAdmin.ListTrees:input_type -> trillian.ListTreesRequest
+	3,  // 7: trillian.TrillianAdmin.GetTree:input_type -> trillian.GetTreeRequest
+	4,  // 8: trillian.TrillianAdmin.CreateTree:input_type -> trillian.CreateTreeRequest
+	5,  // 9: trillian.TrillianAdmin.UpdateTree:input_type -> trillian.UpdateTreeRequest
+	6,  // 10: trillian.TrillianAdmin.DeleteTree:input_type -> trillian.DeleteTreeRequest
+	7,  // 11: trillian.TrillianAdmin.UndeleteTree:input_type -> trillian.UndeleteTreeRequest
+	8,  // 12: trillian.TrillianAdmin.ScrubTree:input_type -> trillian.ScrubTreeRequest
+	11, // 13: trillian.TrillianAdmin.GetTreeUsage:input_type -> trillian.GetTreeUsageRequest
+	2,  // 14: trillian.TrillianAdmin.ListTrees:output_type -> trillian.ListTreesResponse
+	13, // 15: trillian.TrillianAdmin.GetTree:output_type -> trillian.Tree
+	13, // 16: trillian.TrillianAdmin.CreateTree:output_type -> trillian.Tree
+	13, // 17: trillian.TrillianAdmin.UpdateTree:output_type -> trillian.Tree
+	13, // 18: trillian.TrillianAdmin.DeleteTree:output_type -> trillian.Tree
+	13, // 19: trillian.TrillianAdmin.UndeleteTree:output_type -> trillian.Tree
+	10, // 20: trillian.TrillianAdmin.ScrubTree:output_type -> trillian.ScrubTreeResponse
+	12, // 21: trillian.TrillianAdmin.GetTreeUsage:output_type -> trillian.TreeUsage
+	14, // [14:22] is the sub-list for method output_type
+	6,  // [6:14] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_trillian_admin_api_proto_init() }
@@ -599,19 +1102,80 @@ func file_trillian_admin_api_proto_init() {
 				return nil
 			}
 		}
+		file_trillian_admin_api_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ScrubTreeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_trillian_admin_api_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TreeCorruption); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_trillian_admin_api_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ScrubTreeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_trillian_admin_api_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetTreeUsageRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_trillian_admin_api_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TreeUsage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_trillian_admin_api_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   7,
+			NumEnums:      1,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_trillian_admin_api_proto_goTypes,
 		DependencyIndexes: file_trillian_admin_api_proto_depIdxs,
+		EnumInfos:         file_trillian_admin_api_proto_enumTypes,
 		MessageInfos:      file_trillian_admin_api_proto_msgTypes,
 	}.Build()
 	File_trillian_admin_api_proto = out.File
@@ -42,6 +42,14 @@ type TrillianAdminClient interface {
 	// A soft-deleted tree may be undeleted for a certain period, after which
 	// it'll be permanently deleted.
 	UndeleteTree(ctx context.Context, in *UndeleteTreeRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Scrubs a log tree.
+	// Recomputes the hashes of all stored leaves and Merkle nodes of the tree,
+	// and compares them with the stored values and the latest signed root.
+	// The cost of this operation is proportional to the size of the tree.
+	ScrubTree(ctx context.Context, in *ScrubTreeRequest, opts ...grpc.CallOption) (*ScrubTreeResponse, error)
+	// Retrieves the approximate storage usage of a tree.
+	// Returns UNIMPLEMENTED if the storage doesn't keep track of it.
+	GetTreeUsage(ctx context.Context, in *GetTreeUsageRequest, opts ...grpc.CallOption) (*TreeUsage, error)
 }
 
 type trillianAdminClient struct {
@@ -106,6 +114,24 @@ func (c *trillianAdminClient) UndeleteTree(ctx context.Context, in *UndeleteTree
 	return out, nil
 }
 
+func (c *trillianAdminClient) ScrubTree(ctx context.Context, in *ScrubTreeRequest, opts ...grpc.CallOption) (*ScrubTreeResponse, error) {
+	out := new(ScrubTreeResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/ScrubTree", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) GetTreeUsage(ctx context.Context, in *GetTreeUsageRequest, opts ...grpc.CallOption) (*TreeUsage, error) {
+	out := new(TreeUsage)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/GetTreeUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TrillianAdminServer is the server API for TrillianAdmin service.
 // All implementations should embed UnimplementedTrillianAdminServer
 // for forward compatibility
@@ -130,6 +156,14 @@ type TrillianAdminServer interface {
 	// A soft-deleted tree may be undeleted for a certain period, after which
 	// it'll be permanently deleted.
 	UndeleteTree(context.Context, *UndeleteTreeRequest) (*Tree, error)
+	// Scrubs a log tree.
+	// Recomputes the hashes of all stored leaves and Merkle nodes of the tree,
+	// and compares them with the stored values and the latest signed root.
+	// The cost of this operation is proportional to the size of the tree.
+	ScrubTree(context.Context, *ScrubTreeRequest) (*ScrubTreeResponse, error)
+	// Retrieves the approximate storage usage of a tree.
+	// Returns UNIMPLEMENTED if the storage doesn't keep track of it.
+	GetTreeUsage(context.Context, *GetTreeUsageRequest) (*TreeUsage, error)
 }
 
 // UnimplementedTrillianAdminServer should be embedded to have forward compatible implementations.
@@ -154,6 +188,12 @@ func (UnimplementedTrillianAdminServer) DeleteTree(context.Context, *DeleteTreeR
 func (UnimplementedTrillianAdminServer) UndeleteTree(context.Context, *UndeleteTreeRequest) (*Tree, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UndeleteTree not implemented")
 }
+func (UnimplementedTrillianAdminServer) ScrubTree(context.Context, *ScrubTreeRequest) (*ScrubTreeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScrubTree not implemented")
+}
+func (UnimplementedTrillianAdminServer) GetTreeUsage(context.Context, *GetTreeUsageRequest) (*TreeUsage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTreeUsage not implemented")
+}
 
 // UnsafeTrillianAdminServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to TrillianAdminServer will
@@ -274,6 +314,42 @@ func _TrillianAdmin_UndeleteTree_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianAdmin_ScrubTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScrubTreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).ScrubTree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/ScrubTree",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).ScrubTree(ctx, req.(*ScrubTreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_GetTreeUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).GetTreeUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/GetTreeUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).GetTreeUsage(ctx, req.(*GetTreeUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TrillianAdmin_ServiceDesc is the grpc.ServiceDesc for TrillianAdmin service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -305,6 +381,14 @@ var TrillianAdmin_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UndeleteTree",
 			Handler:    _TrillianAdmin_UndeleteTree_Handler,
 		},
+		{
+			MethodName: "ScrubTree",
+			Handler:    _TrillianAdmin_ScrubTree_Handler,
+		},
+		{
+			MethodName: "GetTreeUsage",
+			Handler:    _TrillianAdmin_GetTreeUsage_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_admin_api.proto",
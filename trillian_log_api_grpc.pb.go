@@ -66,6 +66,51 @@ type TrillianLogClient interface {
 	// GetLeavesByRange returns a batch of leaves whose leaf indices are in a
 	// sequential range.
 	GetLeavesByRange(ctx context.Context, in *GetLeavesByRangeRequest, opts ...grpc.CallOption) (*GetLeavesByRangeResponse, error)
+	// QueueLeaves adds a stream of leaves to the queue of pending leaves for a
+	// normal log. The server queues the received leaves in batches, and returns
+	// the results of each batch in a response, so that bulk ingesters needn't
+	// wait for a round trip per leaf. All requests of a stream must be for the
+	// same log.
+	QueueLeaves(ctx context.Context, opts ...grpc.CallOption) (TrillianLog_QueueLeavesClient, error)
+	// GetImportProgress returns how far the leaves of a pre-ordered log have
+	// been added and integrated, so that an interrupted bulk load through
+	// AddSequencedLeaves can resume from where it stopped instead of
+	// re-submitting leaves which were already added.
+	GetImportProgress(ctx context.Context, in *GetImportProgressRequest, opts ...grpc.CallOption) (*GetImportProgressResponse, error)
+	// GetCompactRange returns the compact range of leaves [begin, end) of the
+	// log, i.e. the hashes of the minimal set of perfect subtrees which cover
+	// them. Clients which fetch a contiguous section of the log can verify it
+	// against a root with a few hashes, instead of an inclusion proof per leaf,
+	// and combine compact ranges to build their own proofs.
+	GetCompactRange(ctx context.Context, in *GetCompactRangeRequest, opts ...grpc.CallOption) (*GetCompactRangeResponse, error)
+	// GetLeavesByHash returns the leaves of a log which have any of the given
+	// Merkle leaf hashes, including all the leaves with the same hash, in the
+	// order of their leaf indices. Results are returned a page at a time, and
+	// optionally with their inclusion proofs to a requested tree size.
+	//
+	// If the requested tree size is larger than the server is aware of, the
+	// response will include the latest known log root and no leaves.
+	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
+	// GetLeavesByIndexList returns the leaves of a log at the given, possibly
+	// non-contiguous, leaf indices in a single call, in the order of their leaf
+	// indices. Indices beyond the size of the latest signed log root are
+	// skipped.
+	GetLeavesByIndexList(ctx context.Context, in *GetLeavesByIndexListRequest, opts ...grpc.CallOption) (*GetLeavesByIndexListResponse, error)
+	// GetSignedLogRootAtTime returns the latest signed log root of a log whose
+	// timestamp is at or before a given time, with a consistency proof from it
+	// to the latest root, so that what the log contained at that time can be
+	// verified. Inclusion proofs relative to the returned root are requested
+	// with its tree size.
+	//
+	// If the log has no root that old, a NotFound error is returned.
+	GetSignedLogRootAtTime(ctx context.Context, in *GetSignedLogRootAtTimeRequest, opts ...grpc.CallOption) (*GetSignedLogRootAtTimeResponse, error)
+	// StreamLeaves streams the leaves of a log from a start index, in order, and
+	// then tails the log, streaming leaves as they are sequenced, until the
+	// client cancels the stream. Each response carries a resume token, with
+	// which a client whose stream was interrupted continues from the next leaf,
+	// so that mirrors and indexers needn't poll GetLeavesByRange to follow the
+	// head of a log.
+	StreamLeaves(ctx context.Context, in *StreamLeavesRequest, opts ...grpc.CallOption) (TrillianLog_StreamLeavesClient, error)
 }
 
 type trillianLogClient struct {
@@ -157,6 +202,114 @@ func (c *trillianLogClient) GetLeavesByRange(ctx context.Context, in *GetLeavesB
 	return out, nil
 }
 
+func (c *trillianLogClient) QueueLeaves(ctx context.Context, opts ...grpc.CallOption) (TrillianLog_QueueLeavesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TrillianLog_ServiceDesc.Streams[0], "/trillian.TrillianLog/QueueLeaves", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogQueueLeavesClient{stream}
+	return x, nil
+}
+
+type TrillianLog_QueueLeavesClient interface {
+	Send(*QueueLeavesRequest) error
+	Recv() (*QueueLeavesResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogQueueLeavesClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogQueueLeavesClient) Send(m *QueueLeavesRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *trillianLogQueueLeavesClient) Recv() (*QueueLeavesResponse, error) {
+	m := new(QueueLeavesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianLogClient) GetImportProgress(ctx context.Context, in *GetImportProgressRequest, opts ...grpc.CallOption) (*GetImportProgressResponse, error) {
+	out := new(GetImportProgressResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetImportProgress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetCompactRange(ctx context.Context, in *GetCompactRangeRequest, opts ...grpc.CallOption) (*GetCompactRangeResponse, error) {
+	out := new(GetCompactRangeResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetCompactRange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
+	out := new(GetLeavesByHashResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByIndexList(ctx context.Context, in *GetLeavesByIndexListRequest, opts ...grpc.CallOption) (*GetLeavesByIndexListResponse, error) {
+	out := new(GetLeavesByIndexListResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIndexList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetSignedLogRootAtTime(ctx context.Context, in *GetSignedLogRootAtTimeRequest, opts ...grpc.CallOption) (*GetSignedLogRootAtTimeResponse, error) {
+	out := new(GetSignedLogRootAtTimeResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetSignedLogRootAtTime", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) StreamLeaves(ctx context.Context, in *StreamLeavesRequest, opts ...grpc.CallOption) (TrillianLog_StreamLeavesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TrillianLog_ServiceDesc.Streams[1], "/trillian.TrillianLog/StreamLeaves", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogStreamLeavesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianLog_StreamLeavesClient interface {
+	Recv() (*StreamLeavesResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogStreamLeavesClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogStreamLeavesClient) Recv() (*StreamLeavesResponse, error) {
+	m := new(StreamLeavesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // TrillianLogServer is the server API for TrillianLog service.
 // All implementations should embed UnimplementedTrillianLogServer
 // for forward compatibility
@@ -205,6 +358,51 @@ type TrillianLogServer interface {
 	// GetLeavesByRange returns a batch of leaves whose leaf indices are in a
 	// sequential range.
 	GetLeavesByRange(context.Context, *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error)
+	// QueueLeaves adds a stream of leaves to the queue of pending leaves for a
+	// normal log. The server queues the received leaves in batches, and returns
+	// the results of each batch in a response, so that bulk ingesters needn't
+	// wait for a round trip per leaf. All requests of a stream must be for the
+	// same log.
+	QueueLeaves(TrillianLog_QueueLeavesServer) error
+	// GetImportProgress returns how far the leaves of a pre-ordered log have
+	// been added and integrated, so that an interrupted bulk load through
+	// AddSequencedLeaves can resume from where it stopped instead of
+	// re-submitting leaves which were already added.
+	GetImportProgress(context.Context, *GetImportProgressRequest) (*GetImportProgressResponse, error)
+	// GetCompactRange returns the compact range of leaves [begin, end) of the
+	// log, i.e. the hashes of the minimal set of perfect subtrees which cover
+	// them. Clients which fetch a contiguous section of the log can verify it
+	// against a root with a few hashes, instead of an inclusion proof per leaf,
+	// and combine compact ranges to build their own proofs.
+	GetCompactRange(context.Context, *GetCompactRangeRequest) (*GetCompactRangeResponse, error)
+	// GetLeavesByHash returns the leaves of a log which have any of the given
+	// Merkle leaf hashes, including all the leaves with the same hash, in the
+	// order of their leaf indices. Results are returned a page at a time, and
+	// optionally with their inclusion proofs to a requested tree size.
+	//
+	// If the requested tree size is larger than the server is aware of, the
+	// response will include the latest known log root and no leaves.
+	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
+	// GetLeavesByIndexList returns the leaves of a log at the given, possibly
+	// non-contiguous, leaf indices in a single call, in the order of their leaf
+	// indices. Indices beyond the size of the latest signed log root are
+	// skipped.
+	GetLeavesByIndexList(context.Context, *GetLeavesByIndexListRequest) (*GetLeavesByIndexListResponse, error)
+	// GetSignedLogRootAtTime returns the latest signed log root of a log whose
+	// timestamp is at or before a given time, with a consistency proof from it
+	// to the latest root, so that what the log contained at that time can be
+	// verified. Inclusion proofs relative to the returned root are requested
+	// with its tree size.
+	//
+	// If the log has no root that old, a NotFound error is returned.
+	GetSignedLogRootAtTime(context.Context, *GetSignedLogRootAtTimeRequest) (*GetSignedLogRootAtTimeResponse, error)
+	// StreamLeaves streams the leaves of a log from a start index, in order, and
+	// then tails the log, streaming leaves as they are sequenced, until the
+	// client cancels the stream. Each response carries a resume token, with
+	// which a client whose stream was interrupted continues from the next leaf,
+	// so that mirrors and indexers needn't poll GetLeavesByRange to follow the
+	// head of a log.
+	StreamLeaves(*StreamLeavesRequest, TrillianLog_StreamLeavesServer) error
 }
 
 // UnimplementedTrillianLogServer should be embedded to have forward compatible implementations.
@@ -238,6 +436,27 @@ func (UnimplementedTrillianLogServer) AddSequencedLeaves(context.Context, *AddSe
 func (UnimplementedTrillianLogServer) GetLeavesByRange(context.Context, *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLeavesByRange not implemented")
 }
+func (UnimplementedTrillianLogServer) QueueLeaves(TrillianLog_QueueLeavesServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueueLeaves not implemented")
+}
+func (UnimplementedTrillianLogServer) GetImportProgress(context.Context, *GetImportProgressRequest) (*GetImportProgressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetImportProgress not implemented")
+}
+func (UnimplementedTrillianLogServer) GetCompactRange(context.Context, *GetCompactRangeRequest) (*GetCompactRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCompactRange not implemented")
+}
+func (UnimplementedTrillianLogServer) GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLeavesByHash not implemented")
+}
+func (UnimplementedTrillianLogServer) GetLeavesByIndexList(context.Context, *GetLeavesByIndexListRequest) (*GetLeavesByIndexListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLeavesByIndexList not implemented")
+}
+func (UnimplementedTrillianLogServer) GetSignedLogRootAtTime(context.Context, *GetSignedLogRootAtTimeRequest) (*GetSignedLogRootAtTimeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignedLogRootAtTime not implemented")
+}
+func (UnimplementedTrillianLogServer) StreamLeaves(*StreamLeavesRequest, TrillianLog_StreamLeavesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLeaves not implemented")
+}
 
 // UnsafeTrillianLogServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to TrillianLogServer will
@@ -412,6 +631,143 @@ func _TrillianLog_GetLeavesByRange_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianLog_QueueLeaves_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TrillianLogServer).QueueLeaves(&trillianLogQueueLeavesServer{stream})
+}
+
+type TrillianLog_QueueLeavesServer interface {
+	Send(*QueueLeavesResponse) error
+	Recv() (*QueueLeavesRequest, error)
+	grpc.ServerStream
+}
+
+type trillianLogQueueLeavesServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogQueueLeavesServer) Send(m *QueueLeavesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *trillianLogQueueLeavesServer) Recv() (*QueueLeavesRequest, error) {
+	m := new(QueueLeavesRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TrillianLog_GetImportProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetImportProgressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetImportProgress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetImportProgress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetImportProgress(ctx, req.(*GetImportProgressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetCompactRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCompactRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetCompactRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetCompactRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetCompactRange(ctx, req.(*GetCompactRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByHash(ctx, req.(*GetLeavesByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByIndexList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByIndexListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByIndexList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByIndexList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByIndexList(ctx, req.(*GetLeavesByIndexListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetSignedLogRootAtTime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSignedLogRootAtTimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetSignedLogRootAtTime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetSignedLogRootAtTime",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetSignedLogRootAtTime(ctx, req.(*GetSignedLogRootAtTimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_StreamLeaves_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLeavesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianLogServer).StreamLeaves(m, &trillianLogStreamLeavesServer{stream})
+}
+
+type TrillianLog_StreamLeavesServer interface {
+	Send(*StreamLeavesResponse) error
+	grpc.ServerStream
+}
+
+type trillianLogStreamLeavesServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogStreamLeavesServer) Send(m *StreamLeavesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // TrillianLog_ServiceDesc is the grpc.ServiceDesc for TrillianLog service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -455,7 +811,39 @@ var TrillianLog_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetLeavesByRange",
 			Handler:    _TrillianLog_GetLeavesByRange_Handler,
 		},
+		{
+			MethodName: "GetImportProgress",
+			Handler:    _TrillianLog_GetImportProgress_Handler,
+		},
+		{
+			MethodName: "GetCompactRange",
+			Handler:    _TrillianLog_GetCompactRange_Handler,
+		},
+		{
+			MethodName: "GetLeavesByHash",
+			Handler:    _TrillianLog_GetLeavesByHash_Handler,
+		},
+		{
+			MethodName: "GetLeavesByIndexList",
+			Handler:    _TrillianLog_GetLeavesByIndexList_Handler,
+		},
+		{
+			MethodName: "GetSignedLogRootAtTime",
+			Handler:    _TrillianLog_GetSignedLogRootAtTime_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueueLeaves",
+			Handler:       _TrillianLog_QueueLeaves_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamLeaves",
+			Handler:       _TrillianLog_StreamLeaves_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_log_api.proto",
 }
@@ -18,7 +18,11 @@ package types
 
 import (
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/google/trillian/types/internal/tls"
 
@@ -100,3 +104,77 @@ func (l *LogRootV1) MarshalBinary() ([]byte, error) {
 		V1:      l,
 	})
 }
+
+// logRootJSON is the JSON encoding of a LogRootV1, with hex encoded hashes and
+// metadata, and an RFC 3339 timestamp.
+type logRootJSON struct {
+	TreeSize  uint64 `json:"tree_size"`
+	RootHash  string `json:"root_hash"`
+	Timestamp string `json:"timestamp"`
+	Revision  uint64 `json:"revision,omitempty"`
+	Metadata  string `json:"metadata,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of the root, e.g. for debugging tools
+// and REST gateways. Hashes and metadata are hex encoded, and the timestamp is
+// in RFC 3339 format with nanoseconds.
+func (l LogRootV1) MarshalJSON() ([]byte, error) {
+	if l.TimestampNanos > math.MaxInt64 {
+		return nil, fmt.Errorf("timestamp %d out of range", l.TimestampNanos)
+	}
+	return json.Marshal(logRootJSON{
+		TreeSize:  l.TreeSize,
+		RootHash:  hex.EncodeToString(l.RootHash),
+		Timestamp: time.Unix(0, int64(l.TimestampNanos)).UTC().Format(time.RFC3339Nano),
+		Revision:  l.Revision,
+		Metadata:  hex.EncodeToString(l.Metadata),
+	})
+}
+
+// UnmarshalJSON parses the JSON encoding of a root returned by MarshalJSON.
+func (l *LogRootV1) UnmarshalJSON(data []byte) error {
+	var j logRootJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	rootHash, err := hex.DecodeString(j.RootHash)
+	if err != nil {
+		return fmt.Errorf("invalid root_hash: %v", err)
+	}
+	metadata, err := hex.DecodeString(j.Metadata)
+	if err != nil {
+		return fmt.Errorf("invalid metadata: %v", err)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, j.Timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %v", err)
+	}
+	if ts.Before(time.Unix(0, 0)) {
+		return fmt.Errorf("timestamp %v before the UNIX epoch", ts)
+	}
+	*l = LogRootV1{
+		TreeSize:       j.TreeSize,
+		RootHash:       rootHash,
+		TimestampNanos: uint64(ts.UnixNano()),
+		Revision:       j.Revision,
+		Metadata:       metadata,
+	}
+	return nil
+}
+
+// String returns a human-readable description of the root, with its hash hex
+// encoded and its timestamp in RFC 3339 format.
+func (l LogRootV1) String() string {
+	ts := fmt.Sprintf("%dns", l.TimestampNanos)
+	if l.TimestampNanos <= math.MaxInt64 {
+		ts = time.Unix(0, int64(l.TimestampNanos)).UTC().Format(time.RFC3339Nano)
+	}
+	s := fmt.Sprintf("{TreeSize: %d, RootHash: %x, Timestamp: %s", l.TreeSize, l.RootHash, ts)
+	if l.Revision != 0 {
+		s += fmt.Sprintf(", Revision: %d", l.Revision)
+	}
+	if len(l.Metadata) > 0 {
+		s += fmt.Sprintf(", Metadata: %x", l.Metadata)
+	}
+	return s + "}"
+}
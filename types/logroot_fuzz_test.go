@@ -0,0 +1,64 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// FuzzLogRootV1UnmarshalBinary checks that parsing arbitrary log roots doesn't
+// panic, and that the roots which parse encode back to the bytes they were
+// parsed from. Run with: go test ./types -fuzz FuzzLogRootV1UnmarshalBinary
+func FuzzLogRootV1UnmarshalBinary(f *testing.F) {
+	for _, root := range []LogRootV1{
+		{},
+		{TreeSize: 1, RootHash: []byte("hash"), TimestampNanos: 2, Revision: 3, Metadata: []byte("metadata")},
+		{TreeSize: 1 << 63, RootHash: make([]byte, 128), Metadata: make([]byte, 65535)},
+	} {
+		b, err := root.MarshalBinary()
+		if err != nil {
+			f.Fatalf("MarshalBinary(): %v", err)
+		}
+		f.Add(b)
+	}
+	f.Add([]byte{0, 1, 0})
+	f.Add([]byte{0, 2, 0, 0, 0, 0, 0, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var root LogRootV1
+		if err := root.UnmarshalBinary(data); err != nil {
+			return
+		}
+		b, err := root.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() of parsed root %+v: %v", root, err)
+		}
+		// Bytes trailing the root are ignored.
+		if !bytes.HasPrefix(data, b) {
+			t.Errorf("Root parsed from %x encodes to %x", data, b)
+		}
+		var got LogRootV1
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary() of encoded root %x: %v", b, err)
+		}
+		if !reflect.DeepEqual(got, root) {
+			t.Errorf("UnmarshalBinary(MarshalBinary(%+v)) = %+v", root, got)
+		}
+	})
+}
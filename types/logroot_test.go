@@ -16,6 +16,9 @@ package types
 
 import (
 	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
@@ -90,6 +93,78 @@ func TestUnmarshalLogRoot(t *testing.T) {
 	}
 }
 
+func TestLogRootJSON(t *testing.T) {
+	for _, tc := range []struct {
+		root LogRootV1
+		want string
+	}{
+		{
+			root: LogRootV1{TreeSize: 5, RootHash: []byte{0xab, 0xcd}, TimestampNanos: 1650000000123456789, Revision: 6, Metadata: []byte{1}},
+			want: `{"tree_size":5,"root_hash":"abcd","timestamp":"2022-04-15T05:20:00.123456789Z","revision":6,"metadata":"01"}`,
+		},
+		{
+			root: LogRootV1{RootHash: []byte{}, Metadata: []byte{}},
+			want: `{"tree_size":0,"root_hash":"","timestamp":"1970-01-01T00:00:00Z"}`,
+		},
+	} {
+		b, err := json.Marshal(tc.root)
+		if err != nil {
+			t.Errorf("json.Marshal(%v): %v", tc.root, err)
+			continue
+		}
+		if string(b) != tc.want {
+			t.Errorf("json.Marshal(%v) = %s, want %s", tc.root, b, tc.want)
+		}
+		var got LogRootV1
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("json.Unmarshal(%s): %v", b, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.root) {
+			t.Errorf("JSON round trip failed. got %#v, want %#v", got, tc.root)
+		}
+	}
+
+	if _, err := json.Marshal(LogRootV1{TimestampNanos: math.MaxUint64}); err == nil {
+		t.Error("json.Marshal() of root with out of range timestamp succeeded, want error")
+	}
+	for _, data := range []string{
+		`{"root_hash":"xy","timestamp":"1970-01-01T00:00:00Z"}`,
+		`{"metadata":"xy","timestamp":"1970-01-01T00:00:00Z"}`,
+		`{"timestamp":"yesterday"}`,
+		`{"timestamp":"1969-12-31T23:59:59Z"}`,
+		`{"tree_size":-1,"timestamp":"1970-01-01T00:00:00Z"}`,
+	} {
+		var got LogRootV1
+		if err := json.Unmarshal([]byte(data), &got); err == nil {
+			t.Errorf("json.Unmarshal(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestLogRootString(t *testing.T) {
+	for _, tc := range []struct {
+		root LogRootV1
+		want string
+	}{
+		{
+			root: LogRootV1{TreeSize: 5, RootHash: []byte{0xab, 0xcd}, TimestampNanos: 1650000000123456789},
+			want: "{TreeSize: 5, RootHash: abcd, Timestamp: 2022-04-15T05:20:00.123456789Z}",
+		},
+		{
+			root: LogRootV1{TimestampNanos: math.MaxUint64, Revision: 3, Metadata: []byte{1}},
+			want: "{TreeSize: 0, RootHash: , Timestamp: 18446744073709551615ns, Revision: 3, Metadata: 01}",
+		},
+	} {
+		if got := tc.root.String(); got != tc.want {
+			t.Errorf("String() = %q, want %q", got, tc.want)
+		}
+		if got := fmt.Sprint(&tc.root); got != tc.want {
+			t.Errorf("fmt.Sprint() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
 func MustMarshalLogRoot(root *LogRootV1) []byte {
 	b, err := root.MarshalBinary()
 	if err != nil {
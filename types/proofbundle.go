@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/google/trillian/types/internal/tls"
+)
+
+// ProofBundleFormatV1 is the version tag of serialized ProofBundleV1s.
+const ProofBundleFormatV1 = 1
+
+// ProofBundleV1 is a leaf of a log, with an inclusion proof of it and the log
+// root the proof is for, so that the inclusion can be verified again later
+// without contacting the log, e.g. by client.LogVerifier.VerifyProofBundle.
+type ProofBundleV1 struct {
+	// TreeID is the ID of the log.
+	TreeID int64
+	// LeafIndex is the index of the leaf in the log.
+	LeafIndex uint64
+	// LeafValue is the data of the leaf.
+	LeafValue []byte
+	// InclusionProof holds the hashes of the inclusion proof of the leaf.
+	InclusionProof [][]byte
+	// LogRoot is the serialized LogRootV1 of the log which the proof is for,
+	// as in trillian.SignedLogRoot.
+	LogRoot []byte
+	// LogRootSignature is a signature of LogRoot, or empty if the log root
+	// isn't signed.
+	LogRootSignature []byte
+}
+
+// proofBundle holds the TLS-serialization of a ProofBundleV1, i.e. of the
+// following structure (described in RFC5246 section 4 notation):
+// enum { v1(1), (65535)} Version;
+// opaque MerkleHash<0..128>;
+//
+//	struct {
+//	  Version version;
+//	  uint64 tree_id;
+//	  uint64 leaf_index;
+//	  opaque leaf_value<0..2^24-1>;
+//	  MerkleHash inclusion_proof<0..2^16-1>;
+//	  opaque log_root<0..2^16-1>;
+//	  opaque log_root_signature<0..2^16-1>;
+//	} ProofBundle;
+type proofBundle struct {
+	Version          tls.Enum `tls:"size:2"`
+	TreeID           uint64
+	LeafIndex        uint64
+	LeafValue        []byte       `tls:"minlen:0,maxlen:16777215"`
+	InclusionProof   []merkleHash `tls:"minlen:0,maxlen:65535"`
+	LogRoot          []byte       `tls:"minlen:0,maxlen:65535"`
+	LogRootSignature []byte       `tls:"minlen:0,maxlen:65535"`
+}
+
+type merkleHash struct {
+	Hash []byte `tls:"minlen:0,maxlen:128"`
+}
+
+// MarshalBinary returns a canonical TLS serialization of the bundle.
+func (b *ProofBundleV1) MarshalBinary() ([]byte, error) {
+	if b.TreeID < 0 {
+		return nil, fmt.Errorf("invalid TreeID: %d", b.TreeID)
+	}
+	hashes := make([]merkleHash, 0, len(b.InclusionProof))
+	for _, h := range b.InclusionProof {
+		hashes = append(hashes, merkleHash{Hash: h})
+	}
+	return tls.Marshal(proofBundle{
+		Version:          tls.Enum(ProofBundleFormatV1),
+		TreeID:           uint64(b.TreeID),
+		LeafIndex:        b.LeafIndex,
+		LeafValue:        b.LeafValue,
+		InclusionProof:   hashes,
+		LogRoot:          b.LogRoot,
+		LogRootSignature: b.LogRootSignature,
+	})
+}
+
+// UnmarshalBinary verifies that data is a TLS serialized ProofBundleV1, and
+// populates the caller with it. Unlike LogRootV1.UnmarshalBinary, trailing
+// data is rejected.
+func (b *ProofBundleV1) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("proof bundle too short")
+	}
+	if version := binary.BigEndian.Uint16(data); version != ProofBundleFormatV1 {
+		return fmt.Errorf("invalid proof bundle version: %v, want %v", version, ProofBundleFormatV1)
+	}
+	var pb proofBundle
+	rest, err := tls.Unmarshal(data, &pb)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d bytes of trailing data after proof bundle", len(rest))
+	}
+	if pb.TreeID > math.MaxInt64 {
+		return fmt.Errorf("invalid tree ID: %d", pb.TreeID)
+	}
+	*b = ProofBundleV1{
+		TreeID:           int64(pb.TreeID),
+		LeafIndex:        pb.LeafIndex,
+		LeafValue:        pb.LeafValue,
+		LogRoot:          pb.LogRoot,
+		LogRootSignature: pb.LogRootSignature,
+	}
+	for _, h := range pb.InclusionProof {
+		b.InclusionProof = append(b.InclusionProof, h.Hash)
+	}
+	return nil
+}
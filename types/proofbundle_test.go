@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProofBundle(t *testing.T) {
+	for _, bundle := range []*ProofBundleV1{
+		{
+			TreeID:           123,
+			LeafIndex:        4,
+			LeafValue:        []byte("leaf"),
+			InclusionProof:   [][]byte{[]byte("hash1"), []byte("hash2")},
+			LogRoot:          MustMarshalLogRoot(&LogRootV1{TreeSize: 5, RootHash: []byte("root")}),
+			LogRootSignature: []byte("signature"),
+		},
+		{
+			LeafValue:        []byte{},
+			LogRoot:          []byte{},
+			LogRootSignature: []byte{},
+		},
+	} {
+		b, err := bundle.MarshalBinary()
+		if err != nil {
+			t.Errorf("%v MarshalBinary(): %v", bundle, err)
+			continue
+		}
+		var got ProofBundleV1
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Errorf("UnmarshalBinary(): %v", err)
+			continue
+		}
+		if !reflect.DeepEqual(&got, bundle) {
+			t.Errorf("serialize/parse round trip failed. got %#v, want %#v", got, bundle)
+		}
+	}
+}
+
+func TestProofBundleErrors(t *testing.T) {
+	if _, err := (&ProofBundleV1{TreeID: -1}).MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() with negative tree ID succeeded, want error")
+	}
+	if _, err := (&ProofBundleV1{InclusionProof: [][]byte{make([]byte, 129)}}).MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() with too long hash succeeded, want error")
+	}
+
+	valid, err := (&ProofBundleV1{TreeID: 1, LeafValue: []byte("leaf")}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	modify := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), valid...))
+	}
+	for _, tc := range []struct {
+		desc   string
+		bundle []byte
+	}{
+		{desc: "empty", bundle: nil},
+		{desc: "version", bundle: modify(func(b []byte) []byte { b[1] = 2; return b })},
+		{desc: "truncated", bundle: valid[:len(valid)-1]},
+		{desc: "trailing", bundle: modify(func(b []byte) []byte { return append(b, 0) })},
+		{desc: "tree-id", bundle: modify(func(b []byte) []byte { b[2] = 0x80; return b })},
+	} {
+		var got ProofBundleV1
+		if err := got.UnmarshalBinary(tc.bundle); err == nil {
+			t.Errorf("%s: UnmarshalBinary() succeeded, want error", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types/internal/tls"
+)
+
+// This file converts Trillian log roots and responses to the structures of
+// RFC 6962 (Certificate Transparency), for personalities serving CT-style
+// APIs. Byte slices are encoded to JSON in base64, as RFC 6962 requires.
+
+// CTLogEntryType is the type of an RFC 6962 log entry.
+type CTLogEntryType uint16
+
+// CT log entry types.
+const (
+	X509LogEntryType    CTLogEntryType = 0
+	PrecertLogEntryType CTLogEntryType = 1
+)
+
+// CTLeaf is a timestamped entry of an RFC 6962 log, whose TLS encoding as a
+// MerkleTreeLeaf is the leaf_input of get-entries, and the value of the
+// Trillian leaf.
+type CTLeaf struct {
+	// TimestampMillis is the timestamp of the entry, in milliseconds since the
+	// UNIX epoch.
+	TimestampMillis uint64
+	// EntryType is the type of the entry.
+	EntryType CTLogEntryType
+	// Cert is the DER encoded certificate of X.509 entries, or the DER encoded
+	// TBSCertificate of precertificate entries.
+	Cert []byte
+	// IssuerKeyHash is the SHA-256 hash of the public key of the issuer of
+	// precertificates. It's ignored for X.509 entries.
+	IssuerKeyHash [sha256.Size]byte
+	// Extensions are the CT extensions of the entry.
+	Extensions []byte
+}
+
+// ctMerkleTreeLeaf holds the TLS-serialization of a CTLeaf, i.e. of the
+// MerkleTreeLeaf structure of RFC 6962 section 3.4, with version v1(0) and
+// leaf type timestamped_entry(0).
+type ctMerkleTreeLeaf struct {
+	Version          tls.Enum            `tls:"maxval:255"`
+	LeafType         tls.Enum            `tls:"maxval:255"`
+	TimestampedEntry *ctTimestampedEntry `tls:"selector:LeafType,val:0"`
+}
+
+type ctTimestampedEntry struct {
+	Timestamp    uint64
+	EntryType    tls.Enum    `tls:"maxval:65535"`
+	X509Entry    *ctASN1Cert `tls:"selector:EntryType,val:0"`
+	PrecertEntry *ctPreCert  `tls:"selector:EntryType,val:1"`
+	Extensions   []byte      `tls:"minlen:0,maxlen:65535"`
+}
+
+type ctASN1Cert struct {
+	Data []byte `tls:"minlen:1,maxlen:16777215"`
+}
+
+type ctPreCert struct {
+	IssuerKeyHash  [sha256.Size]byte
+	TBSCertificate []byte `tls:"minlen:1,maxlen:16777215"`
+}
+
+// MarshalBinary returns the TLS encoding of the leaf as an RFC 6962
+// MerkleTreeLeaf.
+func (l *CTLeaf) MarshalBinary() ([]byte, error) {
+	entry := &ctTimestampedEntry{
+		Timestamp:  l.TimestampMillis,
+		EntryType:  tls.Enum(l.EntryType),
+		Extensions: l.Extensions,
+	}
+	switch l.EntryType {
+	case X509LogEntryType:
+		entry.X509Entry = &ctASN1Cert{Data: l.Cert}
+	case PrecertLogEntryType:
+		entry.PrecertEntry = &ctPreCert{IssuerKeyHash: l.IssuerKeyHash, TBSCertificate: l.Cert}
+	default:
+		return nil, fmt.Errorf("unknown entry type %d", l.EntryType)
+	}
+	if entry.Extensions == nil {
+		entry.Extensions = []byte{}
+	}
+	return tls.Marshal(ctMerkleTreeLeaf{TimestampedEntry: entry})
+}
+
+// UnmarshalBinary parses the TLS encoding of an RFC 6962 MerkleTreeLeaf, and
+// populates the caller with it. Trailing data is rejected.
+func (l *CTLeaf) UnmarshalBinary(data []byte) error {
+	var leaf ctMerkleTreeLeaf
+	rest, err := tls.Unmarshal(data, &leaf)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d bytes of trailing data after leaf", len(rest))
+	}
+	if leaf.Version != 0 {
+		return fmt.Errorf("invalid leaf version: %d, want 0", leaf.Version)
+	}
+	entry := leaf.TimestampedEntry
+	*l = CTLeaf{
+		TimestampMillis: entry.Timestamp,
+		EntryType:       CTLogEntryType(entry.EntryType),
+		Extensions:      entry.Extensions,
+	}
+	if entry.X509Entry != nil {
+		l.Cert = entry.X509Entry.Data
+	} else {
+		l.Cert = entry.PrecertEntry.TBSCertificate
+		l.IssuerKeyHash = entry.PrecertEntry.IssuerKeyHash
+	}
+	return nil
+}
+
+// CTSignedTreeHead is the response of the get-sth method of RFC 6962.
+type CTSignedTreeHead struct {
+	TreeSize       uint64 `json:"tree_size"`
+	Timestamp      uint64 `json:"timestamp"`
+	SHA256RootHash []byte `json:"sha256_root_hash"`
+	// TreeHeadSignature is the TLS encoded DigitallySigned structure signing
+	// the SignatureInput of the tree head.
+	TreeHeadSignature []byte `json:"tree_head_signature"`
+}
+
+// NewCTSignedTreeHead returns the RFC 6962 tree head of root, whose timestamp
+// is truncated to milliseconds, with the given signature. The signature is
+// usually added later, by signing the SignatureInput of the tree head.
+func NewCTSignedTreeHead(root *LogRootV1, signature []byte) (*CTSignedTreeHead, error) {
+	if len(root.RootHash) != sha256.Size {
+		return nil, fmt.Errorf("root hash has %d bytes, want %d", len(root.RootHash), sha256.Size)
+	}
+	return &CTSignedTreeHead{
+		TreeSize:          root.TreeSize,
+		Timestamp:         root.TimestampNanos / 1e6,
+		SHA256RootHash:    root.RootHash,
+		TreeHeadSignature: signature,
+	}, nil
+}
+
+// ctTreeHeadSignature holds the TLS-serialization of the TreeHeadSignature
+// structure of RFC 6962 section 3.5, with version v1(0) and signature type
+// tree_hash(1).
+type ctTreeHeadSignature struct {
+	Version        tls.Enum `tls:"maxval:255"`
+	SignatureType  tls.Enum `tls:"maxval:255"`
+	Timestamp      uint64
+	TreeSize       uint64
+	SHA256RootHash [sha256.Size]byte
+}
+
+// SignatureInput returns the TLS encoded TreeHeadSignature structure which
+// the log signs to produce the TreeHeadSignature of the tree head.
+func (sth *CTSignedTreeHead) SignatureInput() ([]byte, error) {
+	ths := ctTreeHeadSignature{
+		SignatureType: 1,
+		Timestamp:     sth.Timestamp,
+		TreeSize:      sth.TreeSize,
+	}
+	if n := copy(ths.SHA256RootHash[:], sth.SHA256RootHash); n != len(sth.SHA256RootHash) || n != sha256.Size {
+		return nil, fmt.Errorf("root hash has %d bytes, want %d", len(sth.SHA256RootHash), sha256.Size)
+	}
+	return tls.Marshal(ths)
+}
+
+// CTGetProofByHashResponse is the response of the get-proof-by-hash method of
+// RFC 6962.
+type CTGetProofByHashResponse struct {
+	LeafIndex int64    `json:"leaf_index"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// NewCTGetProofByHashResponse returns the get-proof-by-hash response of the
+// first proof of resp.
+func NewCTGetProofByHashResponse(resp *trillian.GetInclusionProofByHashResponse) (*CTGetProofByHashResponse, error) {
+	if len(resp.GetProof()) == 0 {
+		return nil, fmt.Errorf("no inclusion proof")
+	}
+	pf := resp.Proof[0]
+	return &CTGetProofByHashResponse{LeafIndex: pf.LeafIndex, AuditPath: nonNil(pf.Hashes)}, nil
+}
+
+// CTGetSTHConsistencyResponse is the response of the get-sth-consistency
+// method of RFC 6962.
+type CTGetSTHConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+// NewCTGetSTHConsistencyResponse returns the get-sth-consistency response of
+// the proof in resp.
+func NewCTGetSTHConsistencyResponse(resp *trillian.GetConsistencyProofResponse) *CTGetSTHConsistencyResponse {
+	return &CTGetSTHConsistencyResponse{Consistency: nonNil(resp.GetProof().GetHashes())}
+}
+
+// CTLeafEntry is an entry of the response of the get-entries method of RFC
+// 6962.
+type CTLeafEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// CTGetEntriesResponse is the response of the get-entries method of RFC 6962.
+type CTGetEntriesResponse struct {
+	Entries []CTLeafEntry `json:"entries"`
+}
+
+// NewCTGetEntriesResponse returns the get-entries response of the leaves in
+// resp, whose values must be TLS encoded MerkleTreeLeafs.
+func NewCTGetEntriesResponse(resp *trillian.GetLeavesByRangeResponse) *CTGetEntriesResponse {
+	entries := make([]CTLeafEntry, 0, len(resp.GetLeaves()))
+	for _, leaf := range resp.GetLeaves() {
+		entries = append(entries, CTLeafEntry{LeafInput: leaf.LeafValue, ExtraData: leaf.ExtraData})
+	}
+	return &CTGetEntriesResponse{Entries: entries}
+}
+
+// CTGetEntryAndProofResponse is the response of the get-entry-and-proof
+// method of RFC 6962.
+type CTGetEntryAndProofResponse struct {
+	LeafInput []byte   `json:"leaf_input"`
+	ExtraData []byte   `json:"extra_data"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// NewCTGetEntryAndProofResponse returns the get-entry-and-proof response of
+// the leaf and proof in resp.
+func NewCTGetEntryAndProofResponse(resp *trillian.GetEntryAndProofResponse) (*CTGetEntryAndProofResponse, error) {
+	if resp.GetLeaf() == nil {
+		return nil, fmt.Errorf("no leaf")
+	}
+	return &CTGetEntryAndProofResponse{
+		LeafInput: resp.Leaf.LeafValue,
+		ExtraData: resp.Leaf.ExtraData,
+		AuditPath: nonNil(resp.GetProof().GetHashes()),
+	}, nil
+}
+
+// nonNil returns hashes, or an empty slice if nil, so that empty proofs are
+// encoded to JSON as empty arrays rather than null.
+func nonNil(hashes [][]byte) [][]byte {
+	if hashes == nil {
+		return [][]byte{}
+	}
+	return hashes
+}
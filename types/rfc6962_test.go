@@ -0,0 +1,192 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("DecodeString(%q): %v", s, err)
+	}
+	return b
+}
+
+func TestCTLeaf(t *testing.T) {
+	var keyHash [32]byte
+	keyHash[0], keyHash[31] = 0xaa, 0xbb
+	for _, test := range []struct {
+		desc string
+		leaf CTLeaf
+		want string
+	}{
+		{
+			desc: "x509",
+			leaf: CTLeaf{TimestampMillis: 0x0102030405060708, EntryType: X509LogEntryType, Cert: []byte{1, 2, 3}, Extensions: []byte{}},
+			want: "00 00 0102030405060708 0000 000003 010203 0000",
+		},
+		{
+			desc: "precert",
+			leaf: CTLeaf{TimestampMillis: 1, EntryType: PrecertLogEntryType, Cert: []byte{4}, IssuerKeyHash: keyHash, Extensions: []byte{9}},
+			want: "00 00 0000000000000001 0001 aa" + strings.Repeat("00", 30) + "bb 000001 04 0001 09",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := test.leaf.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary(): %v", err)
+			}
+			if want := mustDecodeHex(t, test.want); !bytes.Equal(got, want) {
+				t.Errorf("MarshalBinary() = %x, want %x", got, want)
+			}
+			var leaf CTLeaf
+			if err := leaf.UnmarshalBinary(got); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if !reflect.DeepEqual(leaf, test.leaf) {
+				t.Errorf("UnmarshalBinary() = %+v, want %+v", leaf, test.leaf)
+			}
+		})
+	}
+}
+
+func TestCTLeafErrors(t *testing.T) {
+	if _, err := (&CTLeaf{EntryType: 2, Cert: []byte{1}}).MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() of unknown entry type succeeded, want error")
+	}
+	if _, err := (&CTLeaf{}).MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() without certificate succeeded, want error")
+	}
+	for _, leaf := range []string{
+		"",
+		"01 00 0000000000000001 0000 000001 01 0000",    // Version.
+		"00 01 0000000000000001 0000 000001 01 0000",    // Leaf type.
+		"00 00 0000000000000001 0002 000001 01 0000",    // Entry type.
+		"00 00 0000000000000001 0000 000002 01 0000",    // Truncated.
+		"00 00 0000000000000001 0000 000001 01 0000 00", // Trailing data.
+	} {
+		var l CTLeaf
+		if err := l.UnmarshalBinary(mustDecodeHex(t, leaf)); err == nil {
+			t.Errorf("UnmarshalBinary(%s) succeeded, want error", leaf)
+		}
+	}
+}
+
+func TestCTSignedTreeHead(t *testing.T) {
+	root := &LogRootV1{TreeSize: 3, RootHash: bytes.Repeat([]byte{1}, 32), TimestampNanos: 1500000000123456789}
+	sth, err := NewCTSignedTreeHead(root, []byte("sig"))
+	if err != nil {
+		t.Fatalf("NewCTSignedTreeHead(): %v", err)
+	}
+	got, err := json.Marshal(sth)
+	if err != nil {
+		t.Fatalf("json.Marshal(): %v", err)
+	}
+	want := `{"tree_size":3,"timestamp":1500000000123,"sha256_root_hash":"AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=","tree_head_signature":"c2ln"}`
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+
+	input, err := sth.SignatureInput()
+	if err != nil {
+		t.Fatalf("SignatureInput(): %v", err)
+	}
+	if want := mustDecodeHex(t, "00 01 0000015d3ef7987b 0000000000000003"+strings.Repeat("01", 32)); !bytes.Equal(input, want) {
+		t.Errorf("SignatureInput() = %x, want %x", input, want)
+	}
+
+	if _, err := NewCTSignedTreeHead(&LogRootV1{RootHash: []byte("short")}, nil); err == nil {
+		t.Error("NewCTSignedTreeHead() with short root hash succeeded, want error")
+	}
+	if _, err := (&CTSignedTreeHead{SHA256RootHash: make([]byte, 33)}).SignatureInput(); err == nil {
+		t.Error("SignatureInput() with long root hash succeeded, want error")
+	}
+}
+
+func TestCTResponses(t *testing.T) {
+	hashes := [][]byte{{1}, {2}}
+	leaf := &trillian.LogLeaf{LeafValue: []byte("input"), ExtraData: []byte("extra")}
+	proofByHash, err := NewCTGetProofByHashResponse(&trillian.GetInclusionProofByHashResponse{
+		Proof: []*trillian.Proof{{LeafIndex: 7, Hashes: hashes}},
+	})
+	if err != nil {
+		t.Fatalf("NewCTGetProofByHashResponse(): %v", err)
+	}
+	entryAndProof, err := NewCTGetEntryAndProofResponse(&trillian.GetEntryAndProofResponse{
+		Leaf:  leaf,
+		Proof: &trillian.Proof{Hashes: hashes},
+	})
+	if err != nil {
+		t.Fatalf("NewCTGetEntryAndProofResponse(): %v", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		resp interface{}
+		want string
+	}{
+		{
+			desc: "get-proof-by-hash",
+			resp: proofByHash,
+			want: `{"leaf_index":7,"audit_path":["AQ==","Ag=="]}`,
+		},
+		{
+			desc: "get-sth-consistency",
+			resp: NewCTGetSTHConsistencyResponse(&trillian.GetConsistencyProofResponse{Proof: &trillian.Proof{Hashes: hashes}}),
+			want: `{"consistency":["AQ==","Ag=="]}`,
+		},
+		{
+			desc: "get-sth-consistency-empty",
+			resp: NewCTGetSTHConsistencyResponse(&trillian.GetConsistencyProofResponse{}),
+			want: `{"consistency":[]}`,
+		},
+		{
+			desc: "get-entries",
+			resp: NewCTGetEntriesResponse(&trillian.GetLeavesByRangeResponse{Leaves: []*trillian.LogLeaf{leaf}}),
+			want: `{"entries":[{"leaf_input":"aW5wdXQ=","extra_data":"ZXh0cmE="}]}`,
+		},
+		{
+			desc: "get-entry-and-proof",
+			resp: entryAndProof,
+			want: `{"leaf_input":"aW5wdXQ=","extra_data":"ZXh0cmE=","audit_path":["AQ==","Ag=="]}`,
+		},
+	} {
+		got, err := json.Marshal(test.resp)
+		if err != nil {
+			t.Errorf("%s: json.Marshal(): %v", test.desc, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: json.Marshal() = %s, want %s", test.desc, got, test.want)
+		}
+	}
+
+	if _, err := NewCTGetProofByHashResponse(&trillian.GetInclusionProofByHashResponse{}); err == nil {
+		t.Error("NewCTGetProofByHashResponse() without proof succeeded, want error")
+	}
+	if _, err := NewCTGetEntryAndProofResponse(&trillian.GetEntryAndProofResponse{}); err == nil {
+		t.Error("NewCTGetEntryAndProofResponse() without leaf succeeded, want error")
+	}
+}
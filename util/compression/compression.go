@@ -0,0 +1,179 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compression provides the compressors that Trillian servers and
+// clients may use for gRPC messages.
+//
+// Compressors other than gzip aren't registered with gRPC when this package is
+// imported, so that binaries control which of them they accept: a server only
+// decompresses requests with the compressors passed to Register, and
+// compresses its responses with the compressor of the request. gzip is gRPC's
+// own compressor, which registers itself when imported, so it's always
+// accepted.
+package compression
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	// Gzip is the name of the gzip compressor, which is understood by most gRPC
+	// implementations.
+	Gzip = "gzip"
+	// Zstd is the name of the zstd compressor, which compresses faster and
+	// better than gzip.
+	Zstd = "zstd"
+)
+
+var compressors = map[string]func() encoding.Compressor{
+	Gzip: func() encoding.Compressor { return encoding.GetCompressor(gzip.Name) },
+	Zstd: newZstdCompressor,
+}
+
+// Names returns the names of the supported compressors, sorted.
+func Names() []string {
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register registers the named compressors with gRPC. Register isn't safe to
+// call concurrently with RPCs, and should be called during initialization.
+func Register(names ...string) error {
+	for _, name := range names {
+		if _, ok := compressors[name]; !ok {
+			return fmt.Errorf("unknown compressor %q, want one of %v", name, Names())
+		}
+	}
+	for _, name := range names {
+		encoding.RegisterCompressor(compressors[name]())
+	}
+	return nil
+}
+
+// ParseNames parses a comma-separated list of compressor names, as passed in
+// flags. It returns an error if any of the names isn't supported.
+func ParseNames(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := compressors[name]; !ok {
+			return nil, fmt.Errorf("unknown compressor %q, want one of %v", name, Names())
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// zstdCompressor is an encoding.Compressor which reuses zstd encoders and
+// decoders across messages.
+type zstdCompressor struct {
+	encoders, decoders sync.Pool
+}
+
+func newZstdCompressor() encoding.Compressor {
+	return &zstdCompressor{}
+}
+
+func (c *zstdCompressor) Name() string {
+	return Zstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := c.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		// Messages are compressed individually, so concurrency within a
+		// message isn't worth its overhead.
+		if enc, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	} else {
+		enc.Reset(w)
+	}
+	return &zstdWriter{enc: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := c.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		if dec, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	} else if err := dec.Reset(r); err != nil {
+		c.decoders.Put(dec)
+		return nil, err
+	}
+	return &zstdReader{dec: dec, pool: &c.decoders}, nil
+}
+
+// zstdWriter returns its zstd.Encoder to the pool when closed.
+type zstdWriter struct {
+	enc  *zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Write(p []byte) (int, error) {
+	if w.enc == nil {
+		return 0, errors.New("zstd: write to closed writer")
+	}
+	return w.enc.Write(p)
+}
+
+// Close closes the writer, and returns the encoder to the pool the first time
+// it's called.
+func (w *zstdWriter) Close() error {
+	if w.enc == nil {
+		return nil
+	}
+	enc := w.enc
+	w.enc = nil
+	defer w.pool.Put(enc)
+	return enc.Close()
+}
+
+// zstdReader returns its zstd.Decoder to the pool once fully read. Reads after
+// that return io.EOF, so the decoder is returned once even if the reader is
+// read again.
+type zstdReader struct {
+	dec  *zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.dec == nil {
+		return 0, io.EOF
+	}
+	n, err := r.dec.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r.dec)
+		r.dec = nil
+	}
+	return n, err
+}
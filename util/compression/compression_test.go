@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompressors(t *testing.T) {
+	msgs := [][]byte{
+		{},
+		[]byte("short"),
+		bytes.Repeat([]byte("highly compressible leaf value "), 10000),
+	}
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			c := compressors[name]()
+			if got := c.Name(); got != name {
+				t.Errorf("Name()=%q, want %q", got, name)
+			}
+			// Go through the messages twice, to use pooled writers and readers.
+			for i := 0; i < 2; i++ {
+				for _, msg := range msgs {
+					var buf bytes.Buffer
+					w, err := c.Compress(&buf)
+					if err != nil {
+						t.Fatalf("Compress(): %v", err)
+					}
+					if _, err := w.Write(msg); err != nil {
+						t.Fatalf("Write(): %v", err)
+					}
+					if err := w.Close(); err != nil {
+						t.Fatalf("Close(): %v", err)
+					}
+					if len(msg) > 1000 && buf.Len() > len(msg)/10 {
+						t.Errorf("compressed %d bytes to %d, want < %d", len(msg), buf.Len(), len(msg)/10)
+					}
+
+					r, err := c.Decompress(&buf)
+					if err != nil {
+						t.Fatalf("Decompress(): %v", err)
+					}
+					got, err := ioutil.ReadAll(r)
+					if err != nil {
+						t.Fatalf("ReadAll(): %v", err)
+					}
+					if !bytes.Equal(got, msg) {
+						t.Errorf("decompressed %d bytes, want the %d bytes compressed", len(got), len(msg))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestZstdReadAfterEOF(t *testing.T) {
+	c := newZstdCompressor()
+	compress := func(msg string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		if err != nil {
+			t.Fatalf("Compress(): %v", err)
+		}
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatalf("Write(): %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(): %v", err)
+		}
+		// Closing again doesn't return the encoder to the pool twice.
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() again: %v", err)
+		}
+		return buf.Bytes()
+	}
+	data := compress("message")
+
+	r, err := c.Decompress(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decompress(): %v", err)
+	}
+	if got, err := ioutil.ReadAll(r); err != nil || string(got) != "message" {
+		t.Fatalf("ReadAll() = %q, %v, want %q", got, err, "message")
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+			t.Errorf("Read() after EOF = %d, %v, want 0, EOF", n, err)
+		}
+	}
+
+	// The decoder was returned to the pool once, so concurrent readers don't
+	// share it.
+	r1, err := c.Decompress(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decompress(): %v", err)
+	}
+	r2, err := c.Decompress(bytes.NewReader(compress("other")))
+	if err != nil {
+		t.Fatalf("Decompress(): %v", err)
+	}
+	if r1.(*zstdReader).dec == r2.(*zstdReader).dec {
+		t.Fatal("Decompress() returned readers sharing a decoder")
+	}
+	for _, test := range []struct {
+		r    io.Reader
+		want string
+	}{{r1, "message"}, {r2, "other"}} {
+		if got, err := ioutil.ReadAll(test.r); err != nil || string(got) != test.want {
+			t.Errorf("ReadAll() = %q, %v, want %q", got, err, test.want)
+		}
+	}
+}
+
+func TestParseNames(t *testing.T) {
+	for _, test := range []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{list: ""},
+		{list: "gzip", want: []string{Gzip}},
+		{list: "zstd, gzip", want: []string{Zstd, Gzip}},
+		{list: "gzip,snappy", wantErr: true},
+	} {
+		got, err := ParseNames(test.list)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseNames(%q): %v, want error: %v", test.list, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(got, test.want); diff != "" {
+			t.Errorf("ParseNames(%q) diff (-got +want):\n%s", test.list, diff)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(Zstd, "snappy"); err == nil {
+		t.Error("Register() with unknown compressor succeeded, want error")
+	}
+	if c := encoding.GetCompressor(Zstd); c != nil {
+		t.Errorf("Register() with unknown compressor registered %q", Zstd)
+	}
+	if err := Register(Zstd); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+	if c := encoding.GetCompressor(Zstd); c == nil {
+		t.Errorf("Register() didn't register %q", Zstd)
+	}
+}
@@ -42,6 +42,9 @@ type RunnerConfig struct {
 	MasterHoldInterval time.Duration
 	// MasterHoldJitter is the maximum addition to MasterHoldInterval.
 	MasterHoldJitter time.Duration
+	// CampaignDelay, if set, returns how long to wait before each campaign for
+	// mastership of the given ID, e.g. to leave it to other instances.
+	CampaignDelay func(id string) time.Duration
 
 	TimeSource clock.TimeSource
 }
@@ -124,6 +127,14 @@ func (er *Runner) Run(ctx context.Context, pending chan<- Resignation) {
 
 func (er *Runner) beMaster(ctx context.Context, pending chan<- Resignation) error {
 	glog.V(1).Infof("%s: When I left you, I was but the learner", er.id)
+	if er.cfg.CampaignDelay != nil {
+		if delay := er.cfg.CampaignDelay(er.id); delay > 0 {
+			glog.V(1).Infof("%s: delay campaign for %v", er.id, delay)
+			if err := clock.SleepSource(ctx, delay, er.cfg.TimeSource); err != nil {
+				return err
+			}
+		}
+	}
 	if err := er.election.Await(ctx); err != nil {
 		return fmt.Errorf("election.Await() failed: %v", err)
 	}
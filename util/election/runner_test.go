@@ -157,3 +157,49 @@ func TestElectionRunnerRun(t *testing.T) {
 		})
 	}
 }
+
+func TestElectionRunnerCampaignDelay(t *testing.T) {
+	const logID = "6962"
+	const delay = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := to.NewDecorator(to.NewElection())
+	start := time.Now()
+	ts := clock.NewFake(start)
+	tracker := election.NewMasterTracker([]string{logID}, nil)
+	var delayed []string
+	cfg := election.RunnerConfig{
+		CampaignDelay: func(id string) time.Duration {
+			delayed = append(delayed, id)
+			return delay
+		},
+		TimeSource: ts,
+	}
+	er := election.NewRunner(logID, &cfg, tracker, nil, d)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		er.Run(ctx, make(chan election.Resignation, 100))
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	ts.Set(start.Add(election.MinPreElectionPause))
+	time.Sleep(100 * time.Millisecond)
+	if got := tracker.Count(); got != 0 {
+		t.Errorf("Count() before campaign delay = %d, want 0", got)
+	}
+	ts.Set(start.Add(election.MinPreElectionPause + delay))
+	time.Sleep(100 * time.Millisecond)
+	if got := tracker.Count(); got != 1 {
+		t.Errorf("Count() after campaign delay = %d, want 1", got)
+	}
+
+	cancel()
+	wg.Wait()
+	if len(delayed) != 1 || delayed[0] != logID {
+		t.Errorf("CampaignDelay() called for %v, want [%s]", delayed, logID)
+	}
+}
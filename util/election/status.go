@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import (
+	"context"
+
+	"github.com/google/trillian/util/election/electionpb"
+	"github.com/google/trillian/util/election2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// StatusServer implements the electionpb.Mastership service, which reports
+// the mastership state tracked by a MasterTracker.
+type StatusServer struct {
+	instanceID string
+	tracker    *MasterTracker
+	lookup     election2.MasterLookup
+}
+
+// NewStatusServer returns a StatusServer for the given instance. The lookup,
+// which can be nil, finds the masters of the resources the instance isn't
+// master for.
+func NewStatusServer(instanceID string, tracker *MasterTracker, lookup election2.MasterLookup) *StatusServer {
+	return &StatusServer{instanceID: instanceID, tracker: tracker, lookup: lookup}
+}
+
+// GetMastership implements electionpb.MastershipServer.
+func (s *StatusServer) GetMastership(ctx context.Context, req *electionpb.GetMastershipRequest) (*electionpb.GetMastershipResponse, error) {
+	ids := req.ResourceIds
+	if len(ids) == 0 {
+		ids = s.tracker.IDs()
+	}
+	resp := &electionpb.GetMastershipResponse{InstanceId: s.instanceID}
+	for _, id := range ids {
+		r := &electionpb.Resource{ResourceId: id}
+		if since, ok := s.tracker.MasterSince(id); ok {
+			r.MasterId = s.instanceID
+			r.IsMaster = true
+			r.MasterSince = timestamppb.New(since)
+		} else if s.lookup != nil {
+			master, err := s.lookup.Master(ctx, id)
+			if err != nil {
+				return nil, status.Errorf(codes.Unavailable, "failed to look up master for %s: %v", id, err)
+			}
+			r.MasterId = master
+		}
+		for _, t := range s.tracker.Transitions(id) {
+			r.Transitions = append(r.Transitions, &electionpb.Transition{Time: timestamppb.New(t.Time), IsMaster: t.IsMaster})
+		}
+		resp.Resources = append(resp.Resources, r)
+	}
+	return resp, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/util/clock"
+	"github.com/google/trillian/util/election"
+	"github.com/google/trillian/util/election/electionpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fakeLookup map[string]string
+
+func (f fakeLookup) Master(_ context.Context, resourceID string) (string, error) {
+	if resourceID == "bad" {
+		return "", errors.New("lookup failed")
+	}
+	return f[resourceID], nil
+}
+
+func TestStatusServer(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1000, 0)
+	tracker := election.NewMasterTracker([]string{"1", "2", "3"}, nil)
+	tracker.TimeSource = clock.NewFake(start)
+	tracker.Set("1", true)
+	lookup := fakeLookup{"2": "other"}
+
+	master1 := &electionpb.Resource{
+		ResourceId:  "1",
+		MasterId:    "self",
+		IsMaster:    true,
+		MasterSince: timestamppb.New(start),
+		Transitions: []*electionpb.Transition{{Time: timestamppb.New(start), IsMaster: true}},
+	}
+	for _, tc := range []struct {
+		desc    string
+		ids     []string
+		noLook  bool
+		want    []*electionpb.Resource
+		wantErr bool
+	}{
+		{
+			desc: "all",
+			want: []*electionpb.Resource{master1, {ResourceId: "2", MasterId: "other"}, {ResourceId: "3"}},
+		},
+		{
+			desc: "some",
+			ids:  []string{"2", "1"},
+			want: []*electionpb.Resource{{ResourceId: "2", MasterId: "other"}, master1},
+		},
+		{
+			desc:   "no-lookup",
+			ids:    []string{"1", "2"},
+			noLook: true,
+			want:   []*electionpb.Resource{master1, {ResourceId: "2"}},
+		},
+		{
+			desc:    "lookup-error",
+			ids:     []string{"bad"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := election.NewStatusServer("self", tracker, lookup)
+			if tc.noLook {
+				s = election.NewStatusServer("self", tracker, nil)
+			}
+			resp, err := s.GetMastership(ctx, &electionpb.GetMastershipRequest{ResourceIds: tc.ids})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GetMastership()=_,%v; want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := &electionpb.GetMastershipResponse{InstanceId: "self", Resources: tc.want}
+			if !proto.Equal(resp, want) {
+				t.Errorf("GetMastership() diff (-got +want):\n%s", cmp.Diff(resp, want, protocmp.Transform()))
+			}
+		})
+	}
+}
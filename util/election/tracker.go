@@ -19,16 +19,33 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/trillian/util/clock"
 )
 
+// MaxTransitions is the number of recent mastership transitions kept per ID.
+const MaxTransitions = 10
+
+// Transition is a change of the mastership status for an ID.
+type Transition struct {
+	Time     time.Time
+	IsMaster bool
+}
+
 // MasterTracker tracks the current mastership state across multiple IDs.
 type MasterTracker struct {
 	mu          sync.RWMutex
 	masterFor   map[string]bool
 	masterCount int
 	notify      func(id string, isMaster bool)
+	// transitions holds the recent transitions for each ID, oldest first.
+	transitions map[string][]Transition
+
+	// TimeSource is used for timing transitions. It must be set before the
+	// first call to Set.
+	TimeSource clock.TimeSource
 }
 
 // NewMasterTracker creates a new MasterTracker instance to track the
@@ -38,7 +55,12 @@ func NewMasterTracker(ids []string, notify func(id string, isMaster bool)) *Mast
 	for _, id := range ids {
 		mf[id] = false
 	}
-	return &MasterTracker{masterFor: mf, notify: notify}
+	return &MasterTracker{
+		masterFor:   mf,
+		notify:      notify,
+		transitions: make(map[string][]Transition),
+		TimeSource:  clock.System,
+	}
 }
 
 // Set changes the tracked mastership status for the given ID. This method
@@ -56,6 +78,13 @@ func (mt *MasterTracker) Set(id string, isMaster bool) {
 	} else if !isMaster && wasMaster {
 		mt.masterCount--
 	}
+	if isMaster != wasMaster {
+		ts := append(mt.transitions[id], Transition{Time: mt.TimeSource.Now(), IsMaster: isMaster})
+		if len(ts) > MaxTransitions {
+			ts = ts[len(ts)-MaxTransitions:]
+		}
+		mt.transitions[id] = ts
+	}
 	if mt.notify != nil {
 		mt.notify(id, isMaster)
 	}
@@ -82,6 +111,26 @@ func (mt *MasterTracker) Held() []string {
 	return ids
 }
 
+// Transitions returns the recent mastership transitions for the given ID,
+// oldest first.
+func (mt *MasterTracker) Transitions(id string) []Transition {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+	return append([]Transition(nil), mt.transitions[id]...)
+}
+
+// MasterSince returns the time at which mastership for the given ID was
+// captured, and whether it is currently held.
+func (mt *MasterTracker) MasterSince(id string) (time.Time, bool) {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+	if !mt.masterFor[id] {
+		return time.Time{}, false
+	}
+	ts := mt.transitions[id]
+	return ts[len(ts)-1].Time, true
+}
+
 // IDs returns a (sorted) list of the IDs that we are currently tracking.
 func (mt *MasterTracker) IDs() []string {
 	mt.mu.RLock()
@@ -17,6 +17,9 @@ package election
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/google/trillian/util/clock"
 )
 
 type testOperation struct {
@@ -107,3 +110,42 @@ func TestMasterTracker(t *testing.T) {
 		}
 	}
 }
+
+func TestMasterTrackerTransitions(t *testing.T) {
+	start := time.Unix(1000, 0)
+	ts := clock.NewFake(start)
+	mt := NewMasterTracker([]string{"1", "2"}, nil)
+	mt.TimeSource = ts
+
+	if _, ok := mt.MasterSince("1"); ok {
+		t.Error("MasterSince(1) reported mastership before it was captured")
+	}
+	mt.Set("1", true)
+	ts.Set(start.Add(time.Second))
+	mt.Set("1", true) // Not a transition.
+	mt.Set("2", false)
+	if since, ok := mt.MasterSince("1"); !ok || !since.Equal(start) {
+		t.Errorf("MasterSince(1)=%v,%v; want %v,true", since, ok, start)
+	}
+	mt.Set("1", false)
+	want := []Transition{{Time: start, IsMaster: true}, {Time: start.Add(time.Second), IsMaster: false}}
+	if got := mt.Transitions("1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Transitions(1)=%v; want %v", got, want)
+	}
+	if got := mt.Transitions("2"); len(got) != 0 {
+		t.Errorf("Transitions(2)=%v; want none", got)
+	}
+
+	// Only the most recent transitions are kept.
+	for i := 0; i < MaxTransitions; i++ {
+		ts.Set(start.Add(time.Duration(i+2) * time.Second))
+		mt.Set("1", i%2 == 0)
+	}
+	got := mt.Transitions("1")
+	if len(got) != MaxTransitions {
+		t.Fatalf("Transitions(1) returned %d transitions, want %d", len(got), MaxTransitions)
+	}
+	if got[0].Time != start.Add(2*time.Second) || !got[0].IsMaster {
+		t.Errorf("Transitions(1)[0]=%v; want the first transition after the initial ones", got[0])
+	}
+}
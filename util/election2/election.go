@@ -85,3 +85,11 @@ type Election interface {
 type Factory interface {
 	NewElection(ctx context.Context, resourceID string) (Election, error)
 }
+
+// MasterLookup is implemented by Factories which can tell which instance is
+// master for a resource, e.g. for debugging.
+type MasterLookup interface {
+	// Master returns the ID of the instance which is currently master for the
+	// given resource, or an empty string if there is none.
+	Master(ctx context.Context, resourceID string) (string, error)
+}
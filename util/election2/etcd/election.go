@@ -150,7 +150,7 @@ func (f *Factory) NewElection(ctx context.Context, resourceID string) (election2
 	if err != nil {
 		return nil, fmt.Errorf("failed to create etcd session: %v", err)
 	}
-	lockFile := fmt.Sprintf("%s/%s", strings.TrimRight(f.lockDir, "/"), resourceID)
+	lockFile := f.lockFile(resourceID)
 	election := concurrency.NewElection(session, lockFile)
 
 	el := Election{
@@ -165,3 +165,25 @@ func (f *Factory) NewElection(ctx context.Context, resourceID string) (election2
 
 	return &el, nil
 }
+
+// Master returns the ID of the instance which is master for the given
+// resource, i.e. the one whose campaign was created first, or an empty string
+// if there is none. It implements election2.MasterLookup.
+func (f *Factory) Master(ctx context.Context, resourceID string) (string, error) {
+	// Campaign keys are created under the lock file, like in concurrency.Election.
+	resp, err := f.client.Get(ctx, f.lockFile(resourceID)+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	if id := string(resp.Kvs[0].Value); id != resignID {
+		return id, nil
+	}
+	return "", nil
+}
+
+func (f *Factory) lockFile(resourceID string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimRight(f.lockDir, "/"), resourceID)
+}
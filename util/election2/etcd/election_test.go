@@ -72,3 +72,39 @@ func TestElection(t *testing.T) {
 		})
 	}
 }
+
+func TestMaster(t *testing.T) {
+	_, client, cleanup, err := etcd.StartEtcd()
+	if err != nil {
+		t.Fatalf("StartEtcd(): %v", err)
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	fact1 := NewFactory("serv1", client, "master/")
+	fact2 := NewFactory("serv2", client, "master/")
+	checkMaster := func(want string) {
+		t.Helper()
+		if got, err := fact2.Master(ctx, "10"); err != nil || got != want {
+			t.Errorf("Master(10)=%q,%v; want %q,nil", got, err, want)
+		}
+	}
+	checkMaster("")
+
+	el, err := fact1.NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(10): %v", err)
+	}
+	if err := el.Await(ctx); err != nil {
+		t.Fatalf("Await(10): %v", err)
+	}
+	checkMaster("serv1")
+
+	if err := el.Resign(ctx); err != nil {
+		t.Fatalf("Resign(10): %v", err)
+	}
+	checkMaster("")
+	if err := el.Close(ctx); err != nil {
+		t.Fatalf("Close(10): %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postgres provides an implementation of master election based on
+// PostgreSQL advisory locks.
+//
+// The master for a resource is the instance whose database session holds the
+// advisory lock of the resource. Each instance takes the locks of all its
+// resources in a single session, so that the number of connections doesn't
+// grow with the number of resources. PostgreSQL releases the locks when the
+// session ends, e.g. when the connection of a crashed instance is dropped, so
+// each instance checks that its session still holds the lock of a resource
+// every check interval, and cancels its mastership contexts as soon as this
+// can't be confirmed. The server only notices dead connections after its TCP
+// keepalive timeouts, which should be longer than the check interval so that a
+// master which lost its connection stops acting as such before another
+// instance can take over.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util/clock"
+	"github.com/google/trillian/util/election2"
+)
+
+// DefaultCheckInterval is the default time between the checks that an
+// instance still holds the lock of a resource, and between the attempts to
+// capture it.
+const DefaultCheckInterval = time.Second
+
+const (
+	setInstanceIDSQL = "SELECT set_config('application_name', $1, false)"
+	tryLockSQL       = "SELECT pg_try_advisory_lock($1)"
+	unlockSQL        = "SELECT pg_advisory_unlock($1)"
+	// PostgreSQL shows a bigint advisory lock key in pg_locks as the classid
+	// and objid of its high and low halves, with an objsubid of 1.
+	lockHeldSQL = `SELECT COUNT(*) FROM pg_locks
+WHERE pid = pg_backend_pid() AND locktype = 'advisory' AND classid::bigint = $1 AND objid::bigint = $2 AND objsubid = 1 AND granted`
+	// Advisory locks are scoped to a database, while pg_locks shows the locks
+	// of the whole cluster.
+	masterSQL = `SELECT a.application_name FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+AND l.classid::bigint = $1 AND l.objid::bigint = $2 AND l.objsubid = 1 AND l.granted`
+)
+
+var errNotHeld = errors.New("advisory lock is not held")
+
+// session is the database session in which an instance takes the locks of all
+// its resources.
+type session struct {
+	db         *sql.DB
+	instanceID string
+
+	// mu guards the fields below, and the epochs of the elections.
+	mu sync.Mutex
+	// conn is the connection of the session, or nil.
+	conn *sql.Conn
+	// owners maps the keys of the locks held by conn to the elections holding
+	// them.
+	owners map[int64]*Election
+}
+
+// connect returns the connection of the session, starting a new session if
+// there is none. Must be called with mu held.
+func (s *session) connect(ctx context.Context) (*sql.Conn, error) {
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %v", err)
+	}
+	// The instance ID is the application name of the session, which Master
+	// reads from pg_stat_activity.
+	if _, err := conn.ExecContext(ctx, setInstanceIDSQL, s.instanceID); err != nil {
+		closeConn(conn)
+		return nil, fmt.Errorf("failed to set application name: %v", err)
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// discard ends the session, which releases all its locks. The elections
+// holding them notice that they aren't the owners anymore and cancel their
+// mastership contexts. Must be called with mu held.
+func (s *session) discard() {
+	if s.conn == nil {
+		return
+	}
+	if len(s.owners) > 0 {
+		glog.Warningf("Ending Postgres election session holding %d locks", len(s.owners))
+	}
+	closeConn(s.conn)
+	s.conn = nil
+	s.owners = make(map[int64]*Election)
+}
+
+// closeConn closes the connection instead of returning it to the connection
+// pool, so that any locks its session holds are released.
+func closeConn(conn *sql.Conn) {
+	// Returning driver.ErrBadConn from Raw makes database/sql close the
+	// connection.
+	_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
+
+// Election is an implementation of election2.Election based on a PostgreSQL
+// advisory lock.
+type Election struct {
+	resourceID string
+	key        int64
+	interval   time.Duration
+
+	sess *session
+	// epoch is incremented every time the lock is captured, so that the
+	// mastership contexts of previous epochs are canceled even if the instance
+	// is the master again. Guarded by sess.mu.
+	epoch int64
+}
+
+// held returns whether the session holds the lock on behalf of this election.
+// Must be called with sess.mu held.
+func (e *Election) held() bool {
+	return e.sess.owners[e.key] == e
+}
+
+// Await blocks until the instance captures mastership.
+func (e *Election) Await(ctx context.Context) error {
+	for {
+		if locked, err := e.tryLock(ctx); err != nil {
+			return err
+		} else if locked {
+			return nil
+		}
+		if err := clock.SleepContext(ctx, e.interval); err != nil {
+			return err
+		}
+	}
+}
+
+// tryLock captures the lock unless it's held by another instance, and returns
+// whether the instance holds it.
+func (e *Election) tryLock(ctx context.Context) (bool, error) {
+	s := e.sess
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if owner, ok := s.owners[e.key]; ok {
+		if owner != e {
+			// Another election of this instance holds the lock. Advisory locks
+			// are reentrant within a session, so it must not be taken twice.
+			return false, nil
+		}
+		if err := e.checkHeld(ctx); err == nil {
+			return true, nil
+		} else if ctx.Err() != nil {
+			return false, err
+		}
+		// The lock was lost, try to capture it again.
+		delete(s.owners, e.key)
+	}
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, tryLockSQL, e.key).Scan(&locked); err != nil {
+		if ctx.Err() == nil {
+			s.discard()
+		}
+		return false, fmt.Errorf("failed to try lock: %v", err)
+	}
+	if locked {
+		s.owners[e.key] = e
+		e.epoch++
+		glog.Infof("%s: captured mastership", e.resourceID)
+	}
+	return locked, nil
+}
+
+// checkHeld returns an error unless the session still holds the lock. Ends the
+// session if it can't be queried. Must be called with sess.mu held, and only
+// when the session is believed to hold the lock.
+func (e *Election) checkHeld(ctx context.Context) error {
+	s := e.sess
+	var count int
+	if err := s.conn.QueryRowContext(ctx, lockHeldSQL, e.key>>32&0xffffffff, e.key&0xffffffff).Scan(&count); err != nil {
+		if ctx.Err() == nil {
+			// The session has likely ended, in which case all its locks are
+			// lost, so start a new one.
+			s.discard()
+		}
+		return err
+	}
+	if count == 0 {
+		return errNotHeld
+	}
+	return nil
+}
+
+// WithMastership returns a "mastership context" which remains active until the
+// instance stops being the master, or the passed in context is canceled.
+func (e *Election) WithMastership(ctx context.Context) (context.Context, error) {
+	cctx, cancel := context.WithCancel(ctx)
+	s := e.sess
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !e.held() {
+		// Not the master. Return a canceled context.
+		cancel()
+		return cctx, nil
+	}
+	// Verify that we are still the master, before returning context.
+	if err := e.checkHeld(ctx); err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		glog.Warningf("%s: mastership lost: %v", e.resourceID, err)
+		if e.held() {
+			delete(s.owners, e.key)
+		}
+		return cctx, nil
+	}
+
+	// Check that the lock is still held every interval, and cancel the context
+	// as soon as that can't be confirmed within an interval.
+	epoch := e.epoch
+	go func() {
+		defer func() {
+			cancel()
+			glog.Infof("%s: canceled mastership context", e.resourceID)
+		}()
+		for {
+			if err := clock.SleepContext(cctx, e.interval); err != nil {
+				return
+			}
+			if err := e.checkMastership(cctx, epoch); err != nil {
+				if cctx.Err() == nil {
+					glog.Warningf("%s: mastership lost: %v", e.resourceID, err)
+				}
+				return
+			}
+		}
+	}()
+	return cctx, nil
+}
+
+// checkMastership returns an error unless the instance is still the master
+// since the given epoch.
+func (e *Election) checkMastership(ctx context.Context, epoch int64) error {
+	s := e.sess
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !e.held() || e.epoch != epoch {
+		return errNotHeld
+	}
+	cctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	err := e.checkHeld(cctx)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if errors.Is(err, errNotHeld) {
+		delete(s.owners, e.key)
+	} else if s.conn != nil {
+		// The lock can't be confirmed in time. The mastership contexts are
+		// canceled in any case, so end the session, which releases the lock
+		// if it's still held, along with the other locks of the instance.
+		s.discard()
+	}
+	return err
+}
+
+// Resign releases mastership for this instance. The instance can be elected
+// again using Await. Idempotent, might be useful to retry if fails.
+func (e *Election) Resign(ctx context.Context) error {
+	s := e.sess
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !e.held() {
+		return nil // Resigning if not master is a no-op.
+	}
+	var unlocked bool
+	if err := s.conn.QueryRowContext(ctx, unlockSQL, e.key).Scan(&unlocked); err != nil {
+		return err
+	}
+	if !unlocked {
+		glog.Warningf("%s: resigned mastership which was already lost", e.resourceID)
+	}
+	delete(s.owners, e.key)
+	return nil
+}
+
+// Close resigns and permanently stops participating in election. No other
+// method should be called after Close.
+func (e *Election) Close(ctx context.Context) error {
+	if err := e.Resign(ctx); err != nil {
+		glog.Errorf("%s: Resign(): %v", e.resourceID, err)
+		// End the session, which releases the lock even if the above Resign
+		// call failed (e.g. due to ctx cancelation).
+		s := e.sess
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if e.held() {
+			s.discard()
+		}
+	}
+	return nil
+}
+
+// Factory creates Election instances.
+type Factory struct {
+	db         *sql.DB
+	lockPrefix string
+	interval   time.Duration
+	sess       *session
+}
+
+// NewFactory builds an election factory that uses the given parameters. The
+// elections share a single connection of the passed in database handle, which
+// should remain valid for the lifetime of the object. The locks of resources
+// are derived from lockPrefix, which must be the same for all instances, and
+// differ between unrelated users of the database.
+func NewFactory(instanceID string, db *sql.DB, lockPrefix string, interval time.Duration) *Factory {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	return &Factory{
+		db:         db,
+		lockPrefix: lockPrefix,
+		interval:   interval,
+		sess: &session{
+			db:         db,
+			instanceID: instanceID,
+			owners:     make(map[int64]*Election),
+		},
+	}
+}
+
+// NewElection creates a specific Election instance.
+func (f *Factory) NewElection(ctx context.Context, resourceID string) (election2.Election, error) {
+	el := &Election{
+		resourceID: resourceID,
+		key:        f.lockKey(resourceID),
+		interval:   f.interval,
+		sess:       f.sess,
+	}
+	glog.Infof("Election created: resource %s, instance %s, lock %d", resourceID, f.sess.instanceID, el.key)
+	return el, nil
+}
+
+// Master returns the ID of the instance which is master for the given
+// resource, i.e. the one whose session holds its lock, or an empty string if
+// there is none. It implements election2.MasterLookup.
+func (f *Factory) Master(ctx context.Context, resourceID string) (string, error) {
+	key := f.lockKey(resourceID)
+	var id string
+	err := f.db.QueryRowContext(ctx, masterSQL, key>>32&0xffffffff, key&0xffffffff).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// lockKey returns the advisory lock key of the resource.
+func (f *Factory) lockKey(resourceID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(fmt.Sprintf("%s/%s", strings.TrimRight(f.lockPrefix, "/"), resourceID)))
+	return int64(h.Sum64())
+}
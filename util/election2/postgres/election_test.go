@@ -0,0 +1,198 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/election2/testonly"
+
+	_ "github.com/lib/pq" // Register the Postgres driver.
+)
+
+// postgresURIEnv is the environment variable holding the URI of the Postgres
+// database the tests run against. The tests which need one are skipped if it
+// isn't set.
+const postgresURIEnv = "TEST_POSTGRES_URI"
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	uri := os.Getenv(postgresURIEnv)
+	if uri == "" {
+		t.Skipf("Skipping test as %s is not set", postgresURIEnv)
+	}
+	db, err := sql.Open("postgres", uri)
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLockKey(t *testing.T) {
+	f1 := NewFactory("serv", nil, "res/", 0)
+	f2 := NewFactory("serv", nil, "res", 0)
+	f3 := NewFactory("serv", nil, "other/", 0)
+	if got, want := f1.lockKey("10"), f2.lockKey("10"); got != want {
+		t.Errorf("lockKey(10) with prefixes res/ and res = %d, %d; want equal", got, want)
+	}
+	if f1.lockKey("10") == f1.lockKey("20") {
+		t.Error("lockKey(10) = lockKey(20), want different keys")
+	}
+	if f1.lockKey("10") == f3.lockKey("10") {
+		t.Error("lockKey(10) with prefixes res/ and other/ are equal, want different keys")
+	}
+}
+
+func TestElection(t *testing.T) {
+	db := openTestDB(t)
+	for _, nt := range testonly.Tests {
+		// Create a new Factory for each test for better isolation.
+		fact := NewFactory("testID", db, fmt.Sprintf("%s/resources/", nt.Name), 10*time.Millisecond)
+		t.Run(nt.Name, func(t *testing.T) {
+			nt.Run(t, fact)
+		})
+	}
+}
+
+func TestMaster(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	fact1 := NewFactory("serv1", db, "master/", 10*time.Millisecond)
+	fact2 := NewFactory("serv2", db, "master/", 10*time.Millisecond)
+	checkMaster := func(want string) {
+		t.Helper()
+		if got, err := fact2.Master(ctx, "10"); err != nil || got != want {
+			t.Errorf("Master(10)=%q,%v; want %q,nil", got, err, want)
+		}
+	}
+	checkMaster("")
+
+	el1, err := fact1.NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(10): %v", err)
+	}
+	if err := el1.Await(ctx); err != nil {
+		t.Fatalf("Await(10): %v", err)
+	}
+	checkMaster("serv1")
+
+	// Another instance can't capture mastership until it's resigned.
+	el2, err := fact2.NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(10): %v", err)
+	}
+	awaitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := el2.Await(awaitCtx); err == nil {
+		t.Error("Await(10) of second instance succeeded, want error")
+	}
+
+	if err := el1.Resign(ctx); err != nil {
+		t.Fatalf("Resign(10): %v", err)
+	}
+	checkMaster("")
+	if err := el2.Await(ctx); err != nil {
+		t.Fatalf("Await(10) of second instance: %v", err)
+	}
+	checkMaster("serv2")
+	for _, el := range []interface{ Close(context.Context) error }{el1, el2} {
+		if err := el.Close(ctx); err != nil {
+			t.Fatalf("Close(10): %v", err)
+		}
+	}
+}
+
+func TestMastershipLoss(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	fact := NewFactory("serv", db, "loss/", 10*time.Millisecond)
+	el, err := fact.NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(10): %v", err)
+	}
+	defer el.Close(ctx)
+	if err := el.Await(ctx); err != nil {
+		t.Fatalf("Await(10): %v", err)
+	}
+	mctx, err := el.WithMastership(ctx)
+	if err != nil {
+		t.Fatalf("WithMastership(10): %v", err)
+	}
+
+	// Terminate the session holding the lock, as if the connection was lost.
+	e := el.(*Election)
+	var pid int
+	e.sess.mu.Lock()
+	err = e.sess.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid)
+	e.sess.mu.Unlock()
+	if err != nil {
+		t.Fatalf("pg_backend_pid(): %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pid); err != nil {
+		t.Fatalf("pg_terminate_backend(): %v", err)
+	}
+
+	select {
+	case <-mctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Mastership context not canceled after losing the lock")
+	}
+	if err := el.Await(ctx); err != nil {
+		t.Fatalf("Await(10) after losing the lock: %v", err)
+	}
+}
+
+func TestSharedSession(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	fact := NewFactory("serv", db, "shared/", 10*time.Millisecond)
+	var els []*Election
+	for _, id := range []string{"10", "20", "10"} {
+		el, err := fact.NewElection(ctx, id)
+		if err != nil {
+			t.Fatalf("NewElection(%s): %v", id, err)
+		}
+		defer el.Close(ctx)
+		els = append(els, el.(*Election))
+	}
+	for _, el := range els[:2] {
+		if err := el.Await(ctx); err != nil {
+			t.Fatalf("Await(%s): %v", el.resourceID, err)
+		}
+	}
+	if got, want := els[0].sess, els[1].sess; got != want {
+		t.Error("Elections of the same factory use different sessions")
+	}
+
+	// Advisory locks are reentrant within a session, but another election of
+	// the same resource must not capture it.
+	awaitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := els[2].Await(awaitCtx); err == nil {
+		t.Error("Await(10) of second election succeeded, want error")
+	}
+	if err := els[0].Resign(ctx); err != nil {
+		t.Fatalf("Resign(10): %v", err)
+	}
+	if err := els[2].Await(ctx); err != nil {
+		t.Fatalf("Await(10) of second election: %v", err)
+	}
+}